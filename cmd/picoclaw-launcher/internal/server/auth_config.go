@@ -2,8 +2,10 @@ package server
 
 import (
 	"log"
+	"os"
 	"strings"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -75,7 +77,7 @@ func updateConfigAfterLogin(configPath, provider string, cred *auth.AuthCredenti
 		cfg.Agents.Defaults.ModelName = "gemini-flash"
 	}
 
-	if err := config.SaveConfig(configPath, cfg); err != nil {
+	if err := saveConfig(configPath, cfg, "auth login"); err != nil {
 		log.Printf("Warning: could not update config: %v", err)
 	}
 }
@@ -113,7 +115,7 @@ func clearAuthMethodInConfig(configPath, provider string) {
 		cfg.Providers.Antigravity.AuthMethod = ""
 	}
 
-	config.SaveConfig(configPath, cfg)
+	saveConfig(configPath, cfg, "auth logout")
 }
 
 // clearAllAuthMethodsInConfig clears auth_method for all providers in config.json.
@@ -128,11 +130,28 @@ func clearAllAuthMethodsInConfig(configPath string) {
 	cfg.Providers.OpenAI.AuthMethod = ""
 	cfg.Providers.Anthropic.AuthMethod = ""
 	cfg.Providers.Antigravity.AuthMethod = ""
-	config.SaveConfig(configPath, cfg)
+	saveConfig(configPath, cfg, "auth logout")
 }
 
 // ── Model identification helpers ─────────────────────────────────
 
+// saveConfig writes cfg to configPath and records the change in the audit
+// log, unless auditing is off.
+func saveConfig(configPath string, cfg *config.Config, action string) error {
+	before, _ := os.ReadFile(configPath)
+	if err := config.SaveConfig(configPath, cfg); err != nil {
+		return err
+	}
+	if !cfg.Audit.Enabled {
+		return nil
+	}
+	after, _ := os.ReadFile(configPath)
+	if err := audit.RecordConfigSave(cfg.AuditDir(), configPath, "launcher", action, before, after); err != nil {
+		log.Printf("Warning: could not audit config change: %v", err)
+	}
+	return nil
+}
+
 func isOpenAIModel(model string) bool {
 	return model == "openai" || strings.HasPrefix(model, "openai/")
 }
@@ -0,0 +1,309 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const chatHelp = `Chat commands:
+  /new [name]         start a new session
+  /sessions           list saved sessions
+  /sessions <n|name>  resume a session
+  /model              show the current model
+  /model <name>       switch model
+  /exit               leave (also Ctrl+D)
+Ctrl+C interrupts a running reply; on an empty line it leaves.
+`
+
+var chatSessionName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func NewChatCommand() *cobra.Command {
+	var (
+		sessionName string
+		newSession  bool
+		model       string
+		debug       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Chat with the agent in the terminal",
+		Long: "Interactive chat with line editing, streamed replies and saved sessions. " +
+			"Resumes the most recent session unless --session or --new is given.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return chatCmd(sessionName, newSession, model, debug)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	cmd.Flags().StringVarP(&sessionName, "session", "s", "", "Session to resume or create")
+	cmd.Flags().BoolVarP(&newSession, "new", "n", false, "Start a new session")
+	cmd.Flags().StringVarP(&model, "model", "", "", "Model to use")
+
+	return cmd
+}
+
+func chatCmd(sessionName string, newSession bool, model string, debug bool) error {
+	if sessionName != "" && !chatSessionName.MatchString(sessionName) {
+		return fmt.Errorf("invalid session name %q (use letters, digits, '.', '_' or '-')", sessionName)
+	}
+
+	agentLoop, msgBus, err := newAgentLoop(model, debug)
+	if err != nil {
+		return err
+	}
+	defer msgBus.Close()
+
+	agentID, sessions := agentLoop.DefaultAgentSessions()
+	if sessions == nil {
+		return errors.New("no agent configured")
+	}
+
+	prompt := fmt.Sprintf("%s You: ", internal.Logo)
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          prompt,
+		HistoryFile:     filepath.Join(internal.GetPicoclawHome(), "chat_history"),
+		HistoryLimit:    500,
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		return fmt.Errorf("error initializing readline: %w", err)
+	}
+	defer rl.Close()
+
+	agentLoop.SetExecApprover(newTerminalApprover(func(question string) (string, error) {
+		rl.SetPrompt(question)
+		defer rl.SetPrompt(prompt)
+		return rl.Readline()
+	}))
+
+	repl := &chatREPL{agent: agentLoop, agentID: agentID, sessions: sessions, out: rl.Stdout()}
+	switch {
+	case sessionName != "":
+		repl.session = sessionName
+	case !newSession:
+		if recent := repl.listSessions(); len(recent) > 0 {
+			repl.session = recent[0].name
+		}
+	}
+	if repl.session == "" {
+		repl.session = newChatSessionName()
+	}
+
+	fmt.Fprintf(repl.out, "%s Chat mode, session %q (/help for commands, Ctrl+D to exit)\n\n",
+		internal.Logo, repl.session)
+	for {
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			if line == "" {
+				break
+			}
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if repl.handle(line) {
+			break
+		}
+	}
+	fmt.Fprintln(repl.out, "Goodbye!")
+	return nil
+}
+
+// chatAgent is the part of the agent loop the REPL drives.
+type chatAgent interface {
+	ProcessDirectStream(ctx context.Context, content, sessionKey string, onDelta func(string)) (string, error)
+}
+
+// chatREPL holds the state of a chat: which session is active and where
+// output goes. Sessions are stored by the default agent under
+// "agent:<id>:cli:<name>" keys, so they persist like any other session.
+type chatREPL struct {
+	agent    chatAgent
+	agentID  string
+	sessions *session.SessionManager
+	session  string
+	out      io.Writer
+}
+
+type chatSessionInfo struct {
+	name     string
+	messages int
+	updated  time.Time
+	preview  string
+}
+
+func (r *chatREPL) sessionKey(name string) string {
+	return "agent:" + r.agentID + ":cli:" + name
+}
+
+// listSessions returns the saved chat sessions, most recently used first.
+func (r *chatREPL) listSessions() []chatSessionInfo {
+	prefix := r.sessionKey("")
+	var infos []chatSessionInfo
+	for _, key := range r.sessions.Keys() {
+		name, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		s, ok := r.sessions.Snapshot(key)
+		if !ok {
+			continue
+		}
+		info := chatSessionInfo{name: name, messages: len(s.Messages), updated: s.Updated}
+		for _, m := range s.Messages {
+			if m.Role == "user" {
+				info.preview = utils.Truncate(strings.Join(strings.Fields(m.Content), " "), 50)
+				break
+			}
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b chatSessionInfo) int { return b.updated.Compare(a.updated) })
+	return infos
+}
+
+// handle processes one line of input and reports whether the user asked to
+// leave. Commands the REPL does not know are passed to the agent.
+func (r *chatREPL) handle(line string) bool {
+	input := strings.TrimSpace(line)
+	if input == "" {
+		return false
+	}
+	if input == "exit" || input == "quit" {
+		return true
+	}
+	if !strings.HasPrefix(input, "/") {
+		r.send(input)
+		return false
+	}
+
+	fields := strings.Fields(input)
+	switch fields[0] {
+	case "/exit", "/quit":
+		return true
+	case "/help":
+		fmt.Fprint(r.out, chatHelp)
+		r.send(input)
+	case "/new":
+		name := newChatSessionName()
+		if len(fields) > 1 {
+			name = fields[1]
+		}
+		r.switchTo(name, true)
+	case "/sessions":
+		if len(fields) > 1 {
+			r.resume(fields[1])
+		} else {
+			r.printSessions()
+		}
+	case "/model":
+		if len(fields) > 1 {
+			r.send("/switch model to " + fields[1])
+		} else {
+			r.send("/show model")
+		}
+	default:
+		r.send(input)
+	}
+	return false
+}
+
+func (r *chatREPL) switchTo(name string, fresh bool) {
+	if !chatSessionName.MatchString(name) {
+		fmt.Fprintf(r.out, "Invalid session name %q (use letters, digits, '.', '_' or '-')\n\n", name)
+		return
+	}
+	if _, exists := r.sessions.Snapshot(r.sessionKey(name)); fresh && exists {
+		fmt.Fprintf(r.out, "Session %q already exists; resume it with /sessions %s\n\n", name, name)
+		return
+	}
+	r.session = name
+	if fresh {
+		fmt.Fprintf(r.out, "Started session %q\n\n", name)
+	} else {
+		fmt.Fprintf(r.out, "Resumed session %q\n\n", name)
+	}
+}
+
+// resume switches to a session given by its number in the /sessions list
+// or by name.
+func (r *chatREPL) resume(arg string) {
+	infos := r.listSessions()
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(infos) {
+			fmt.Fprintf(r.out, "No session #%d (see /sessions)\n\n", n)
+			return
+		}
+		arg = infos[n-1].name
+	} else if !slices.ContainsFunc(infos, func(i chatSessionInfo) bool { return i.name == arg }) {
+		fmt.Fprintf(r.out, "No session named %q (start one with /new %s)\n\n", arg, arg)
+		return
+	}
+	r.switchTo(arg, false)
+}
+
+func (r *chatREPL) printSessions() {
+	infos := r.listSessions()
+	if len(infos) == 0 {
+		fmt.Fprintln(r.out, "No saved sessions yet.")
+		fmt.Fprintln(r.out)
+		return
+	}
+	for i, info := range infos {
+		marker := " "
+		if info.name == r.session {
+			marker = "*"
+		}
+		fmt.Fprintf(r.out, "%s %2d. %-20s %3d msgs  %s  %s\n", marker, i+1, info.name, info.messages,
+			info.updated.Local().Format("2006-01-02 15:04"), info.preview)
+	}
+	fmt.Fprintln(r.out)
+}
+
+// send runs one turn, printing the reply as it streams. Ctrl+C cancels the
+// turn without leaving the chat.
+func (r *chatREPL) send(input string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Fprintf(r.out, "\n%s ", internal.Logo)
+	streamed := false
+	response, err := r.agent.ProcessDirectStream(ctx, input, r.sessionKey(r.session), func(delta string) {
+		streamed = true
+		fmt.Fprint(r.out, delta)
+	})
+	switch {
+	case ctx.Err() != nil:
+		fmt.Fprint(r.out, "[interrupted]")
+	case err != nil:
+		fmt.Fprintf(r.out, "Error: %v", err)
+	case !streamed:
+		fmt.Fprint(r.out, response)
+	}
+	fmt.Fprint(r.out, "\n\n")
+}
+
+func newChatSessionName() string {
+	return time.Now().Format("20060102-150405")
+}
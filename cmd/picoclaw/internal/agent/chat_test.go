@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// fakeChatAgent records what the REPL sends and streams a canned reply.
+type fakeChatAgent struct {
+	sessions *session.SessionManager
+	sent     []string
+	keys     []string
+}
+
+func (f *fakeChatAgent) ProcessDirectStream(
+	_ context.Context,
+	content, sessionKey string,
+	onDelta func(string),
+) (string, error) {
+	f.sent = append(f.sent, content)
+	f.keys = append(f.keys, sessionKey)
+	f.sessions.AddMessage(sessionKey, "user", content)
+	onDelta("streamed ")
+	onDelta("reply")
+	return "streamed reply", nil
+}
+
+func TestNewChatCommand(t *testing.T) {
+	cmd := NewChatCommand()
+
+	require.NotNil(t, cmd)
+	assert.Equal(t, "chat", cmd.Use)
+	assert.NotNil(t, cmd.RunE)
+
+	assert.NotNil(t, cmd.Flags().Lookup("session"))
+	assert.NotNil(t, cmd.Flags().Lookup("new"))
+	assert.NotNil(t, cmd.Flags().Lookup("model"))
+	assert.NotNil(t, cmd.Flags().Lookup("debug"))
+}
+
+func TestChatREPL(t *testing.T) {
+	sessions := session.NewSessionManager(t.TempDir())
+	fake := &fakeChatAgent{sessions: sessions}
+	var out strings.Builder
+	repl := &chatREPL{agent: fake, agentID: "main", sessions: sessions, session: "first", out: &out}
+
+	assert.False(t, repl.handle("hello there"))
+	assert.Contains(t, out.String(), "streamed reply")
+	assert.Equal(t, "agent:main:cli:first", fake.keys[0])
+
+	assert.False(t, repl.handle("/model"))
+	assert.False(t, repl.handle("/model gpt-4o"))
+	assert.False(t, repl.handle("/usage"))
+	assert.Equal(t, []string{"hello there", "/show model", "/switch model to gpt-4o", "/usage"}, fake.sent)
+
+	assert.False(t, repl.handle("/new second"))
+	assert.Equal(t, "second", repl.session)
+	repl.handle("another topic")
+	assert.Equal(t, "agent:main:cli:second", fake.keys[len(fake.keys)-1])
+
+	out.Reset()
+	repl.handle("/new first")
+	assert.Contains(t, out.String(), "already exists")
+	assert.Equal(t, "second", repl.session)
+
+	out.Reset()
+	repl.handle("/sessions")
+	assert.Contains(t, out.String(), "first")
+	assert.Contains(t, out.String(), "* ")
+	assert.Contains(t, out.String(), "hello there")
+
+	names := []string{}
+	for _, info := range repl.listSessions() {
+		names = append(names, info.name)
+	}
+	assert.Equal(t, []string{"second", "first"}, names, "most recent first")
+
+	repl.handle("/sessions 2")
+	assert.Equal(t, "first", repl.session)
+	repl.handle("/sessions second")
+	assert.Equal(t, "second", repl.session)
+
+	out.Reset()
+	repl.handle("/sessions 9")
+	repl.handle("/sessions nope")
+	repl.handle("/new bad/name")
+	assert.Equal(t, "second", repl.session)
+	assert.Contains(t, out.String(), "No session #9")
+	assert.Contains(t, out.String(), `No session named "nope"`)
+	assert.Contains(t, out.String(), "Invalid session name")
+
+	assert.True(t, repl.handle("/exit"))
+	assert.True(t, repl.handle("quit"))
+}
@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"context"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,3 +33,21 @@ func TestNewAgentCommand(t *testing.T) {
 	assert.NotNil(t, cmd.Flags().Lookup("session"))
 	assert.NotNil(t, cmd.Flags().Lookup("model"))
 }
+
+func TestTerminalApprover(t *testing.T) {
+	var asked string
+	answer := "yes\n"
+	approve := newTerminalApprover(func(question string) (string, error) {
+		asked = question
+		return answer, nil
+	})
+
+	assert.True(t, approve(context.Background(), "rm -rf build"))
+	assert.Contains(t, asked, `"rm -rf build"`)
+
+	answer = "\n"
+	assert.False(t, approve(context.Background(), "rm -rf build"))
+
+	failing := newTerminalApprover(func(string) (string, error) { return "", io.EOF })
+	assert.False(t, failing(context.Background(), "rm -rf build"))
+}
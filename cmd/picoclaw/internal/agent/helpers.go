@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/chzyer/readline"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
 func agentCmd(message, sessionKey, model string, debug bool) error {
@@ -23,14 +25,41 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 		sessionKey = "cli:default"
 	}
 
-	if debug {
-		logger.SetLevel(logger.DEBUG)
-		fmt.Println("🔍 Debug mode enabled")
+	agentLoop, msgBus, err := newAgentLoop(model, debug)
+	if err != nil {
+		return err
+	}
+	defer msgBus.Close()
+
+	if message != "" {
+		ctx := context.Background()
+		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
+		if err != nil {
+			return fmt.Errorf("error processing message: %w", err)
+		}
+		fmt.Printf("\n%s %s\n", internal.Logo, response)
+		return nil
 	}
 
+	fmt.Printf("%s Interactive mode (Ctrl+C to exit)\n\n", internal.Logo)
+	interactiveMode(agentLoop, sessionKey)
+
+	return nil
+}
+
+// newAgentLoop loads the config and builds the agent loop used by the
+// terminal commands. The caller closes the returned bus.
+func newAgentLoop(model string, debug bool) (*agent.AgentLoop, *bus.MessageBus, error) {
 	cfg, err := internal.LoadConfig()
 	if err != nil {
-		return fmt.Errorf("error loading config: %w", err)
+		return nil, nil, fmt.Errorf("error loading config: %w", err)
+	}
+	if err := internal.ConfigureLogging(cfg, debug); err != nil {
+		return nil, nil, fmt.Errorf("error setting up logging: %w", err)
+	}
+	internal.InstallCrashHandler(cfg)
+	if debug {
+		fmt.Println("🔍 Debug mode enabled")
 	}
 
 	if model != "" {
@@ -39,7 +68,7 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 
 	provider, modelID, err := providers.CreateProvider(cfg)
 	if err != nil {
-		return fmt.Errorf("error creating provider: %w", err)
+		return nil, nil, fmt.Errorf("error creating provider: %w", err)
 	}
 
 	// Use the resolved model ID from provider creation
@@ -48,7 +77,6 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 	}
 
 	msgBus := bus.NewMessageBus()
-	defer msgBus.Close()
 	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
 
 	// Print agent startup info (only for interactive mode)
@@ -59,21 +87,7 @@ func agentCmd(message, sessionKey, model string, debug bool) error {
 			"skills_total":     startupInfo["skills"].(map[string]any)["total"],
 			"skills_available": startupInfo["skills"].(map[string]any)["available"],
 		})
-
-	if message != "" {
-		ctx := context.Background()
-		response, err := agentLoop.ProcessDirect(ctx, message, sessionKey)
-		if err != nil {
-			return fmt.Errorf("error processing message: %w", err)
-		}
-		fmt.Printf("\n%s %s\n", internal.Logo, response)
-		return nil
-	}
-
-	fmt.Printf("%s Interactive mode (Ctrl+C to exit)\n\n", internal.Logo)
-	interactiveMode(agentLoop, sessionKey)
-
-	return nil
+	return agentLoop, msgBus, nil
 }
 
 func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
@@ -94,6 +108,12 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	}
 	defer rl.Close()
 
+	agentLoop.SetExecApprover(newTerminalApprover(func(question string) (string, error) {
+		rl.SetPrompt(question)
+		defer rl.SetPrompt(prompt)
+		return rl.Readline()
+	}))
+
 	for {
 		line, err := rl.Readline()
 		if err != nil {
@@ -128,6 +148,10 @@ func interactiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 
 func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 	reader := bufio.NewReader(os.Stdin)
+	agentLoop.SetExecApprover(newTerminalApprover(func(question string) (string, error) {
+		fmt.Print(question)
+		return reader.ReadString('\n')
+	}))
 	for {
 		fmt.Print(fmt.Sprintf("%s You: ", internal.Logo))
 		line, err := reader.ReadString('\n')
@@ -160,3 +184,23 @@ func simpleInteractiveMode(agentLoop *agent.AgentLoop, sessionKey string) {
 		fmt.Printf("\n%s %s\n\n", internal.Logo, response)
 	}
 }
+
+// newTerminalApprover asks on the terminal before a dangerous command runs.
+// Questions are serialized so parallel tool calls do not interleave.
+func newTerminalApprover(readLine func(question string) (string, error)) tools.ExecApprover {
+	var mu sync.Mutex
+	return func(_ context.Context, command string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		answer, err := readLine(fmt.Sprintf("⚠️  Allow command %q? [y/N] ", command))
+		if err != nil {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	}
+}
@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewAuditCommand() *cobra.Command {
+	var opts options
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show the audit log of security-relevant events",
+		Example: `picoclaw audit
+picoclaw audit --kind shell_exec,file_write --since 24h
+picoclaw audit --verify`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return auditCmd(cmd.Context(), cmd.OutOrStdout(), opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.kind, "kind", "",
+		"comma-separated kinds: tool_approval, file_write, shell_exec, config_change, auth_failure, erasure")
+	cmd.Flags().StringVar(&opts.since, "since", "", "only events after this time (RFC 3339) or this long ago (24h)")
+	cmd.Flags().StringVar(&opts.session, "session", "", "only events of this session key")
+	cmd.Flags().StringVar(&opts.actor, "actor", "", `only events by this actor, e.g. telegram:123 or "api"`)
+	cmd.Flags().IntVar(&opts.limit, "limit", 50, "show at most this many of the newest events; 0 shows all")
+	cmd.Flags().BoolVar(&opts.asJSON, "json", false, "print the events as JSON")
+	cmd.Flags().BoolVar(&opts.verify, "verify", false, "check the signatures of the whole log instead")
+
+	return cmd
+}
@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+)
+
+func TestNewAuditCommand(t *testing.T) {
+	cmd := NewAuditCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "audit", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.True(t, cmd.HasExample())
+	for _, flag := range []string{"kind", "since", "session", "actor", "limit", "json", "verify"} {
+		assert.NotNil(t, cmd.Flags().Lookup(flag), flag)
+	}
+}
+
+func TestPrintEvents(t *testing.T) {
+	var buf bytes.Buffer
+	printEvents(&buf, []audit.Event{
+		{
+			Seq: 1, Time: time.Now(), Kind: audit.ShellExec, Actor: "telegram:42",
+			Action: "exec", Target: "ls -la", Outcome: "ok",
+		},
+		{
+			Seq: 2, Time: time.Now(), Kind: audit.AuthFailure, Action: "api_key", Target: "10.0.0.5:4242",
+			Outcome: "denied", Detail: "GET /api/v1/sessions",
+		},
+	})
+	out := buf.String()
+
+	assert.Contains(t, out, "shell_exec")
+	assert.Contains(t, out, "telegram:42")
+	assert.Contains(t, out, "10.0.0.5:4242 (GET /api/v1/sessions)")
+}
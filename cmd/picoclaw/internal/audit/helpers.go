@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+type options struct {
+	kind, since, session, actor string
+	limit                       int
+	asJSON, verify              bool
+}
+
+func auditCmd(ctx context.Context, w io.Writer, opts options) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	log, err := audit.Open(cfg.AuditDir())
+	if err != nil {
+		return err
+	}
+
+	if opts.verify {
+		if err := log.Verify(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "✓ Audit log intact")
+		return nil
+	}
+
+	kinds, err := audit.ParseKinds(opts.kind)
+	if err != nil {
+		return err
+	}
+	since, err := audit.ParseSince(opts.since, time.Now())
+	if err != nil {
+		return err
+	}
+	events, err := log.Query(ctx, audit.Filter{
+		Kinds:      kinds,
+		Since:      since,
+		SessionKey: opts.session,
+		Actor:      opts.actor,
+		Limit:      opts.limit,
+	})
+	if err != nil {
+		return err
+	}
+	if opts.asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	}
+	if len(events) == 0 {
+		fmt.Fprintln(w, "No audit events.")
+		return nil
+	}
+	printEvents(w, events)
+	return nil
+}
+
+// printEvents lists events one per line, oldest first.
+func printEvents(w io.Writer, events []audit.Event) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ\tTIME\tKIND\tACTOR\tACTION\tOUTCOME\tTARGET")
+	for _, e := range events {
+		target := utils.Truncate(e.Target, 60)
+		if e.Detail != "" {
+			target += " (" + utils.Truncate(e.Detail, 60) + ")"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Seq, e.Time.Local().Format(time.DateTime),
+			e.Kind, orDash(e.Actor), e.Action, e.Outcome, target)
+	}
+	tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -81,7 +81,7 @@ func authLoginOpenAI(useDeviceCode bool) error {
 		// Update default model to use OpenAI
 		appCfg.Agents.Defaults.ModelName = "gpt-5.2"
 
-		if err = config.SaveConfig(internal.GetConfigPath(), appCfg); err != nil {
+		if err = internal.SaveConfig(appCfg, "auth login"); err != nil {
 			return fmt.Errorf("could not update config: %w", err)
 		}
 	}
@@ -155,7 +155,7 @@ func authLoginGoogleAntigravity() error {
 		// Update default model
 		appCfg.Agents.Defaults.ModelName = "gemini-flash"
 
-		if err := config.SaveConfig(internal.GetConfigPath(), appCfg); err != nil {
+		if err := internal.SaveConfig(appCfg, "auth login"); err != nil {
 			fmt.Printf("Warning: could not update config: %v\n", err)
 		}
 	}
@@ -232,7 +232,7 @@ func authLoginAnthropicSetupToken() error {
 			}
 		}
 
-		if err := config.SaveConfig(internal.GetConfigPath(), appCfg); err != nil {
+		if err := internal.SaveConfig(appCfg, "auth login"); err != nil {
 			return fmt.Errorf("could not update config: %w", err)
 		}
 	}
@@ -326,7 +326,7 @@ func authLoginPasteToken(provider string) error {
 			// Update default model
 			appCfg.Agents.Defaults.ModelName = "gpt-5.2"
 		}
-		if err := config.SaveConfig(internal.GetConfigPath(), appCfg); err != nil {
+		if err := internal.SaveConfig(appCfg, "auth login"); err != nil {
 			return fmt.Errorf("could not update config: %w", err)
 		}
 	}
@@ -374,7 +374,7 @@ func authLogoutCmd(provider string) error {
 			case "google-antigravity", "antigravity":
 				appCfg.Providers.Antigravity.AuthMethod = ""
 			}
-			config.SaveConfig(internal.GetConfigPath(), appCfg)
+			internal.SaveConfig(appCfg, "auth logout")
 		}
 
 		fmt.Printf("Logged out from %s\n", provider)
@@ -396,7 +396,7 @@ func authLogoutCmd(provider string) error {
 		appCfg.Providers.OpenAI.AuthMethod = ""
 		appCfg.Providers.Anthropic.AuthMethod = ""
 		appCfg.Providers.Antigravity.AuthMethod = ""
-		config.SaveConfig(internal.GetConfigPath(), appCfg)
+		internal.SaveConfig(appCfg, "auth logout")
 	}
 
 	fmt.Println("Logged out from all providers")
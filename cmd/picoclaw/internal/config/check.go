@@ -0,0 +1,88 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/diag"
+)
+
+func newCheckCommand() *cobra.Command {
+	var (
+		quiet       bool
+		showSecrets bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "check [file]",
+		Short: "Validate a config file and print the effective config",
+		Long: `Reports every unknown field, value of the wrong type and invalid setting
+of the config file, with its line and column. When the file is valid, prints
+the effective config: the defaults, overridden by the file, overridden by the
+PICOCLAW_* environment variables. Secrets are redacted unless --show-secrets
+is given. Checks the config in use when no file is given.`,
+		Example: `picoclaw config check
+picoclaw config check ./config.json --quiet`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := internal.GetConfigPath()
+			if len(args) > 0 {
+				path = args[0]
+			}
+			return checkCmd(cmd.OutOrStdout(), path, quiet, showSecrets)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "only report problems")
+	cmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "print API keys and tokens in the effective config")
+
+	return cmd
+}
+
+func checkCmd(w io.Writer, path string, quiet, showSecrets bool) error {
+	cfg, problems, err := config.CheckConfig(path)
+	if err != nil {
+		return fmt.Errorf("error reading config: %w", err)
+	}
+
+	unknown := 0
+	for _, p := range problems {
+		mark := "✗"
+		if p.Unknown {
+			mark = "⚠"
+			unknown++
+		}
+		sep := ":"
+		if p.Line == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(w, "%s %s%s%s\n", mark, path, sep, p)
+	}
+	if cfg == nil {
+		return fmt.Errorf("%s has %d problem(s)", path, len(problems)-unknown)
+	}
+	if unknown > 0 {
+		fmt.Fprintf(w, "✓ %s is valid; %d unknown field(s) are ignored\n", path, unknown)
+	} else {
+		fmt.Fprintf(w, "✓ %s is valid\n", path)
+	}
+	if quiet {
+		return nil
+	}
+
+	var effective any = cfg
+	if !showSecrets {
+		effective = diag.RedactConfig(cfg)
+	}
+	data, err := json.MarshalIndent(effective, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nEffective config:\n%s\n", data)
+	return nil
+}
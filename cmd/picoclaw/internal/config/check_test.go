@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCheckSubcommand(t *testing.T) {
+	cmd := newCheckCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "check [file]", cmd.Use)
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().Lookup("quiet"))
+	assert.NotNil(t, cmd.Flags().Lookup("show-secrets"))
+}
+
+func TestCheckCmd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+  "channels": {"telegram": {"token": "123:secret"}},
+  "colour": "blue"
+}`), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, checkCmd(&out, path, false, false))
+	assert.Contains(t, out.String(), "⚠ "+path+":3:3: colour: unknown field")
+	assert.Contains(t, out.String(), "is valid; 1 unknown field(s) are ignored")
+	assert.Contains(t, out.String(), `"token": "[REDACTED]"`)
+	assert.NotContains(t, out.String(), "123:secret")
+
+	out.Reset()
+	require.NoError(t, os.WriteFile(path, []byte(`{"heartbeat": {"interval": "often"}}`), 0o600))
+	err := checkCmd(&out, path, true, false)
+	require.Error(t, err)
+	assert.Contains(t, out.String(), "✗ "+path+":1:28: heartbeat.interval: want a number, got a string")
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigCommand(t *testing.T) {
+	cmd := NewConfigCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "config", cmd.Use)
+	assert.Equal(t, "Inspect the configuration", cmd.Short)
+
+	assert.Nil(t, cmd.Run)
+	assert.NotNil(t, cmd.RunE)
+
+	assert.True(t, cmd.HasSubCommands())
+
+	allowedCommands := []string{
+		"check",
+	}
+
+	subcommands := cmd.Commands()
+	assert.Len(t, subcommands, len(allowedCommands))
+
+	for _, subcmd := range subcommands {
+		found := false
+		for _, allowed := range allowedCommands {
+			if allowed == subcmd.Name() {
+				found = true
+				break
+			}
+		}
+		assert.True(t, found, "unexpected subcommand %q", subcmd.Name())
+	}
+}
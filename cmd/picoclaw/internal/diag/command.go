@@ -0,0 +1,30 @@
+package diag
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewDiagCommand() *cobra.Command {
+	var list bool
+
+	cmd := &cobra.Command{
+		Use:   "diag",
+		Short: "Collect a diagnostic bundle for a bug report",
+		Long: `Writes a zip file with the latest log entries, the config with its secrets
+redacted, the size of each store and the stacks of the latest crashes. Attach
+it to the bug report.`,
+		Example: `picoclaw diag
+picoclaw diag --list`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if list {
+				return listCmd(cmd.OutOrStdout())
+			}
+			return diagCmd(cmd.OutOrStdout())
+		},
+	}
+
+	cmd.Flags().BoolVar(&list, "list", false, "list the bundles written so far instead")
+
+	return cmd
+}
@@ -0,0 +1,36 @@
+package diag
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/diag"
+)
+
+func TestNewDiagCommand(t *testing.T) {
+	cmd := NewDiagCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "diag", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("list"))
+}
+
+func TestPrintBundles(t *testing.T) {
+	var buf bytes.Buffer
+	printBundles(&buf, []diag.Bundle{
+		{Path: "/ws/diag/crash-20260102-150405.000.zip", Kind: diag.KindCrash, Time: time.Now(), Size: 5000},
+	})
+	out := buf.String()
+
+	assert.Contains(t, out, "crash")
+	assert.Contains(t, out, "5 KB")
+	assert.Contains(t, out, "/ws/diag/crash-20260102-150405.000.zip")
+}
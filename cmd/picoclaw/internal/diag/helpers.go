@@ -0,0 +1,67 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/diag"
+)
+
+func diagCmd(w io.Writer) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	src := internal.DiagSource(cfg)
+	path, err := diag.Collect(src)
+	if err != nil {
+		return err
+	}
+	bundles, err := diag.List(src.Dir)
+	if err != nil {
+		return err
+	}
+	crashes := 0
+	for _, b := range bundles {
+		if b.Kind == diag.KindCrash {
+			crashes++
+		}
+	}
+
+	fmt.Fprintf(w, "✓ Diagnostic bundle written to %s\n", path)
+	if crashes > 0 {
+		fmt.Fprintf(w, "  It includes the latest of %d crash(es); see picoclaw diag --list.\n", crashes)
+	}
+	fmt.Fprintln(w, "  Secrets in the config are redacted. Check the log entries before sharing it.")
+	return nil
+}
+
+func listCmd(w io.Writer) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	bundles, err := diag.List(internal.DiagSource(cfg).Dir)
+	if err != nil {
+		return err
+	}
+	if len(bundles) == 0 {
+		fmt.Fprintln(w, "No diagnostic bundles.")
+		return nil
+	}
+	printBundles(w, bundles)
+	return nil
+}
+
+// printBundles lists bundles one per line, newest first.
+func printBundles(w io.Writer, bundles []diag.Bundle) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tKIND\tSIZE\tPATH")
+	for _, b := range bundles {
+		fmt.Fprintf(tw, "%s\t%s\t%d KB\t%s\n", b.Time.Format(time.DateTime), b.Kind, (b.Size+1023)/1024, b.Path)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,432 @@
+package doctor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type status int
+
+const (
+	statusOK status = iota
+	statusWarn
+	statusFail
+)
+
+func (s status) String() string {
+	switch s {
+	case statusWarn:
+		return "⚠"
+	case statusFail:
+		return "✗"
+	}
+	return "✓"
+}
+
+// result is the outcome of a check, with how to fix it when it is not ok.
+type result struct {
+	check  string
+	status status
+	detail string
+	fix    string
+}
+
+const (
+	pingTimeout  = 30 * time.Second
+	clockTimeout = 10 * time.Second
+	// maxClockSkew is how far the clock may be off before TLS, OAuth tokens
+	// and schedules start to go wrong.
+	maxClockSkew = 2 * time.Minute
+)
+
+// minSaneTime is a date the clock must be past; boards without a
+// real-time clock start in 1970 until NTP has run.
+var minSaneTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// storeDirs are the directories of the workspace that hold JSON and JSONL
+// stores.
+var storeDirs = []string{"sessions", "state", "cron", "approvals", "memory", "usage", "traces", "tasks", "sync"}
+
+func doctorCmd(ctx context.Context, w io.Writer, configPath string, offline bool) error {
+	fmt.Fprintf(w, "%s picoclaw doctor\n\n", internal.Logo)
+
+	var results []result
+	report := func(rs ...result) {
+		for _, r := range rs {
+			fmt.Fprintf(w, "%s %-10s %s\n", r.status, r.check, r.detail)
+			if r.fix != "" && r.status != statusOK {
+				fmt.Fprintf(w, "  fix: %s\n", r.fix)
+			}
+		}
+		results = append(results, rs...)
+	}
+
+	cfg, r := checkConfig(configPath)
+	report(r)
+	if cfg == nil {
+		fmt.Fprintln(w, "\nFix the config, then run picoclaw doctor again.")
+		return errors.New("the config is not usable")
+	}
+	report(checkConfigMode(configPath)...)
+	report(checkWorkspace(cfg.WorkspacePath()))
+	report(checkStores(cfg.WorkspacePath())...)
+
+	base := ""
+	if mc, err := cfg.GetModelConfig(cfg.Agents.Defaults.GetModelName()); err == nil {
+		base = providers.APIBase(mc)
+	}
+	report(checkClock(ctx, time.Now(), buildTime(), base, offline))
+	if offline {
+		report(result{check: "Provider", status: statusOK, detail: "skipped (--offline)"})
+	} else {
+		report(checkProvider(ctx, cfg))
+	}
+	report(checkGateway(cfg))
+
+	failed, warned := 0, 0
+	for _, r := range results {
+		switch r.status {
+		case statusFail:
+			failed++
+		case statusWarn:
+			warned++
+		}
+	}
+	fmt.Fprintln(w)
+	switch {
+	case failed > 0:
+		fmt.Fprintf(w, "%d problems, %d warnings\n", failed, warned)
+		return fmt.Errorf("%d checks failed", failed)
+	case warned > 0:
+		fmt.Fprintf(w, "No problems, %d warnings\n", warned)
+	default:
+		fmt.Fprintln(w, "No problems found")
+	}
+	return nil
+}
+
+// checkConfig reads the config strictly; the config is nil when it cannot
+// be used.
+func checkConfig(path string) (*config.Config, result) {
+	r := result{check: "Config"}
+	cfg, problems, err := config.CheckConfig(path)
+	if errors.Is(err, os.ErrNotExist) {
+		r.status, r.detail = statusFail, path+" does not exist"
+		r.fix = "run picoclaw onboard to create it"
+		return nil, r
+	}
+	if err != nil {
+		r.status, r.detail = statusFail, err.Error()
+		return nil, r
+	}
+	if len(problems) > 0 {
+		r.status, r.detail = statusWarn, fmt.Sprintf("%s: %s", path, problems[0])
+		if len(problems) > 1 {
+			r.detail += fmt.Sprintf(" (and %d more)", len(problems)-1)
+		}
+		r.fix = "run picoclaw config check " + path + " to see every problem"
+		if cfg == nil {
+			r.status = statusFail
+		}
+		return cfg, r
+	}
+	r.detail = path + " is valid"
+	return cfg, r
+}
+
+// checkConfigMode warns when a config with secrets written out in it can be
+// read by other users.
+func checkConfigMode(path string) []result {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm()&0o077 == 0 || !hasPlainSecrets(path) {
+		return nil
+	}
+	return []result{{
+		check:  "Config",
+		status: statusWarn,
+		detail: fmt.Sprintf("%s holds secrets and is readable by other users (mode %s)", path, info.Mode().Perm()),
+		fix:    fmt.Sprintf("chmod 600 %s, or move the secrets out with picoclaw secret set", path),
+	}}
+}
+
+// hasPlainSecrets reports whether the config file at path holds the value
+// of a secret setting rather than a reference to it.
+func hasPlainSecrets(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	var v any
+	if json.Unmarshal(data, &v) != nil {
+		return false
+	}
+	var walk func(v any, secret bool) bool
+	walk = func(v any, secret bool) bool {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, e := range v {
+				// auth_method names a method, such as oauth, not a secret.
+				if k != "auth_method" && walk(e, secret || config.IsSecretKey(k)) {
+					return true
+				}
+			}
+		case []any:
+			for _, e := range v {
+				if walk(e, secret) {
+					return true
+				}
+			}
+		case string:
+			return secret && v != "" && !strings.HasPrefix(v, "file:") && !strings.HasPrefix(v, "keyring:") &&
+				!strings.Contains(v, "${")
+		}
+		return false
+	}
+	return walk(v, false)
+}
+
+// checkWorkspace checks that the workspace is a directory picoclaw can
+// write to.
+func checkWorkspace(dir string) result {
+	r := result{check: "Workspace"}
+	info, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		r.status, r.detail = statusFail, dir+" does not exist"
+		r.fix = "run picoclaw onboard, or create it and fix agents.defaults.workspace"
+		return r
+	case err != nil:
+		r.status, r.detail = statusFail, err.Error()
+		return r
+	case !info.IsDir():
+		r.status, r.detail = statusFail, dir+" is not a directory"
+		r.fix = "point agents.defaults.workspace at a directory"
+		return r
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		r.status, r.detail = statusFail, dir+" is not writable: "+err.Error()
+		r.fix = fmt.Sprintf("make it writable by the user picoclaw runs as, e.g. chown -R $(id -un) %s", dir)
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.detail = dir + " is writable"
+	return r
+}
+
+// checkStores reads every JSON and JSONL file of the stores in the
+// workspace. A JSONL file whose last line is cut off, as left by a crash,
+// only gets a warning: the stores skip such a line.
+func checkStores(workspace string) []result {
+	var rs []result
+	files := 0
+	for _, name := range storeDirs {
+		dir := filepath.Join(workspace, name)
+		_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			var problem string
+			var partial bool
+			switch filepath.Ext(path) {
+			case ".json":
+				problem = checkJSONFile(path)
+			case ".jsonl":
+				problem, partial = checkJSONLFile(path)
+			default:
+				return nil
+			}
+			files++
+			if problem == "" {
+				return nil
+			}
+			r := result{
+				check:  "Stores",
+				status: statusFail,
+				detail: path + ": " + problem,
+				fix:    "move the file aside, or restore it from a backup; picoclaw starts that store afresh without it",
+			}
+			if partial {
+				r.status = statusWarn
+				r.fix = "none needed; picoclaw skips the cut-off line"
+			}
+			rs = append(rs, r)
+			return nil
+		})
+	}
+	if len(rs) == 0 {
+		rs = append(rs, result{check: "Stores", detail: fmt.Sprintf("%d files readable", files)})
+	}
+	return rs
+}
+
+func checkJSONFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+	if !json.Valid(data) {
+		return "not valid JSON"
+	}
+	return ""
+}
+
+// checkJSONLFile returns what is wrong with the JSONL file at path, and
+// whether it is only the last line.
+func checkJSONLFile(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err.Error(), false
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	bad, last, n := 0, 0, 0
+	for sc.Scan() {
+		n++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 || json.Valid(line) {
+			continue
+		}
+		if bad == 0 {
+			bad = n
+		}
+		last = n
+	}
+	if err := sc.Err(); err != nil {
+		return err.Error(), false
+	}
+	switch {
+	case bad == 0:
+		return "", false
+	case bad == n && last == n:
+		return fmt.Sprintf("line %d is cut off", n), true
+	}
+	return fmt.Sprintf("line %d is not valid JSON", bad), false
+}
+
+// buildTime returns when the binary was built, or the zero time when that
+// is unknown.
+func buildTime() time.Time {
+	build, _ := internal.FormatBuildInfo()
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05-0700"} {
+		if t, err := time.Parse(layout, build); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// checkClock checks that now is after the build and, unless offline,
+// close to the Date the provider's server at base sends.
+func checkClock(ctx context.Context, now, built time.Time, base string, offline bool) result {
+	r := result{check: "Clock"}
+	floor := minSaneTime
+	if built.After(floor) {
+		floor = built.Add(-24 * time.Hour)
+	}
+	if now.Before(floor) {
+		r.status, r.detail = statusFail, fmt.Sprintf("it is %s, before this picoclaw was built", now.Format(time.DateTime))
+		r.fix = "set the clock, e.g. enable NTP with timedatectl set-ntp true"
+		return r
+	}
+	if offline || !strings.HasPrefix(base, "http") {
+		r.detail = now.Format(time.DateTime) + " (not compared with a server)"
+		return r
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, clockTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, base, nil)
+	if err != nil {
+		r.detail = now.Format(time.DateTime) + " (not compared with a server)"
+		return r
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var invalid x509.CertificateInvalidError
+		if errors.As(err, &invalid) && invalid.Reason == x509.Expired {
+			r.status, r.detail = statusFail, "the server certificate looks expired or not yet valid: "+err.Error()
+			r.fix = "check the clock, e.g. enable NTP with timedatectl set-ntp true"
+			return r
+		}
+		r.detail = now.Format(time.DateTime) + " (could not reach " + base + " to compare)"
+		return r
+	}
+	resp.Body.Close()
+	server, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		r.detail = now.Format(time.DateTime) + " (the server sent no date to compare)"
+		return r
+	}
+	skew := now.Sub(server)
+	if skew.Abs() > maxClockSkew {
+		ahead := "ahead of"
+		if skew < 0 {
+			ahead = "behind"
+		}
+		r.status = statusWarn
+		r.detail = fmt.Sprintf("%s %s %s", skew.Abs().Round(time.Second), ahead, req.URL.Host)
+		r.fix = "set the clock, e.g. enable NTP with timedatectl set-ntp true"
+		return r
+	}
+	r.detail = fmt.Sprintf("within %s of %s", maxClockSkew, req.URL.Host)
+	return r
+}
+
+// checkProvider sends the default model a one-word request.
+func checkProvider(ctx context.Context, cfg *config.Config) result {
+	model := cfg.Agents.Defaults.GetModelName()
+	r := result{check: "Provider"}
+	provider, modelID, err := providers.CreateProvider(cfg)
+	if err != nil {
+		r.status, r.detail = statusFail, err.Error()
+		r.fix = "set agents.defaults.model_name to the model_name of an entry of model_list"
+		return r
+	}
+	if sp, ok := provider.(providers.StatefulProvider); ok {
+		defer sp.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err = provider.Chat(ctx, []providers.Message{{Role: "user", Content: "Reply with OK."}}, nil, modelID,
+		map[string]any{"max_tokens": 16})
+	if err != nil {
+		r.status, r.detail = statusFail, fmt.Sprintf("%s: %v", model, err)
+		r.fix = fmt.Sprintf("check the api_key, api_base and model of %q in model_list, and the network", model)
+		return r
+	}
+	r.detail = fmt.Sprintf("%s answered in %s", model, time.Since(start).Round(time.Millisecond))
+	return r
+}
+
+func checkGateway(cfg *config.Config) result {
+	r := result{check: "Gateway", detail: "not running"}
+	if internal.GatewayRunning(cfg) {
+		r.detail = fmt.Sprintf("running on port %d", cfg.Gateway.Port)
+	}
+	return r
+}
@@ -0,0 +1,167 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestDoctorCmd_Offline(t *testing.T) {
+	dir := t.TempDir()
+	workspace := filepath.Join(dir, "workspace")
+	require.NoError(t, os.MkdirAll(workspace, 0o755))
+	path := filepath.Join(dir, "config.json")
+	writeFile(t, path, `{
+  "agents": {"defaults": {"workspace": "`+workspace+`", "model_name": "m"}},
+  "model_list": [{"model_name": "m", "model": "openai/gpt-4o", "api_key": "${DOCTOR_TEST_KEY}"}],
+  "gateway": {"host": "127.0.0.1", "port": 1}
+}`)
+	t.Setenv("DOCTOR_TEST_KEY", "sk-test")
+
+	var buf bytes.Buffer
+	require.NoError(t, doctorCmd(context.Background(), &buf, path, true))
+	out := buf.String()
+	assert.Contains(t, out, "✓ Config     "+path+" is valid")
+	assert.Contains(t, out, "✓ Workspace  "+workspace+" is writable")
+	assert.Contains(t, out, "✓ Stores     0 files readable")
+	assert.Contains(t, out, "✓ Provider   skipped (--offline)")
+	assert.Contains(t, out, "No problems found")
+}
+
+func TestDoctorCmd_MissingConfig(t *testing.T) {
+	var buf bytes.Buffer
+	err := doctorCmd(context.Background(), &buf, filepath.Join(t.TempDir(), "config.json"), true)
+	require.Error(t, err)
+	assert.Contains(t, buf.String(), "fix: run picoclaw onboard")
+}
+
+func TestCheckConfigMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeFile(t, path, `{"model_list": [{"model_name": "m", "auth_method": "oauth", "api_key": "keyring:m"}]}`)
+	require.NoError(t, os.Chmod(path, 0o644))
+	assert.Empty(t, checkConfigMode(path), "references are not secrets")
+
+	writeFile(t, path, `{"model_list": [{"model_name": "m", "api_key": "sk-live"}]}`)
+	rs := checkConfigMode(path)
+	if len(rs) == 0 {
+		t.Skip("file modes are not enforced here")
+	}
+	assert.Equal(t, statusWarn, rs[0].status)
+	assert.Contains(t, rs[0].fix, "chmod 600")
+
+	require.NoError(t, os.Chmod(path, 0o600))
+	assert.Empty(t, checkConfigMode(path))
+}
+
+func TestCheckWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, statusOK, checkWorkspace(dir).status)
+
+	r := checkWorkspace(filepath.Join(dir, "missing"))
+	assert.Equal(t, statusFail, r.status)
+	assert.Contains(t, r.fix, "picoclaw onboard")
+
+	file := filepath.Join(dir, "file")
+	writeFile(t, file, "")
+	assert.Equal(t, statusFail, checkWorkspace(file).status)
+}
+
+func TestCheckStores(t *testing.T) {
+	workspace := t.TempDir()
+	writeFile(t, filepath.Join(workspace, "sessions", "ok.json"), `{"key": "ok"}`)
+	writeFile(t, filepath.Join(workspace, "sessions", "bad.json"), `{"key": `)
+	writeFile(t, filepath.Join(workspace, "usage", "crash.jsonl"), "{\"a\":1}\n{\"a\":")
+	writeFile(t, filepath.Join(workspace, "memory", "facts.jsonl"), "{\"a\":1}\nnot json\n{\"a\":2}\n")
+	writeFile(t, filepath.Join(workspace, "notes", "ignored.json"), `{`)
+
+	byFile := map[string]result{}
+	for _, r := range checkStores(workspace) {
+		path, _, _ := strings.Cut(r.detail, ": ")
+		byFile[filepath.Base(path)] = r
+	}
+	require.Len(t, byFile, 3)
+	assert.Equal(t, statusFail, byFile["bad.json"].status)
+	assert.Equal(t, statusWarn, byFile["crash.jsonl"].status)
+	assert.Contains(t, byFile["crash.jsonl"].detail, "line 2 is cut off")
+	assert.Equal(t, statusFail, byFile["facts.jsonl"].status)
+	assert.Contains(t, byFile["facts.jsonl"].detail, "line 2 is not valid JSON")
+
+	rs := checkStores(t.TempDir())
+	require.Len(t, rs, 1)
+	assert.Equal(t, statusOK, rs[0].status)
+}
+
+func TestCheckClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	r := checkClock(ctx, time.Unix(0, 0), time.Time{}, "", true)
+	assert.Equal(t, statusFail, r.status, "1970 is before any build")
+	r = checkClock(ctx, now, now.Add(72*time.Hour), "", true)
+	assert.Equal(t, statusFail, r.status, "before the build date")
+	r = checkClock(ctx, now, now.Add(-time.Hour), "", true)
+	assert.Equal(t, statusOK, r.status)
+
+	serverTime := now
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Date", serverTime.Format(http.TimeFormat))
+	}))
+	defer srv.Close()
+
+	r = checkClock(ctx, now, time.Time{}, srv.URL, false)
+	assert.Equal(t, statusOK, r.status, r.detail)
+
+	serverTime = now.Add(-10 * time.Minute)
+	r = checkClock(ctx, now, time.Time{}, srv.URL, false)
+	assert.Equal(t, statusWarn, r.status)
+	assert.Contains(t, r.detail, "10m0s ahead of")
+}
+
+func TestCheckProvider(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"error": {"message": "invalid api key"}}`))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "OK"}, "finish_reason": "stop"}]}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.ModelName = "m"
+	cfg.ModelList = []config.ModelConfig{{ModelName: "m", Model: "openai/gpt-4o", APIKey: "sk-test", APIBase: srv.URL}}
+
+	r := checkProvider(context.Background(), cfg)
+	assert.Equal(t, statusOK, r.status, r.detail)
+	assert.Contains(t, r.detail, "m answered in")
+
+	status = http.StatusUnauthorized
+	r = checkProvider(context.Background(), cfg)
+	assert.Equal(t, statusFail, r.status)
+	assert.Contains(t, r.fix, `"m"`)
+
+	cfg.Agents.Defaults.ModelName = "missing"
+	r = checkProvider(context.Background(), cfg)
+	assert.Equal(t, statusFail, r.status)
+	assert.Contains(t, r.fix, "model_name")
+}
@@ -0,0 +1,31 @@
+package doctor
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+)
+
+func NewDoctorCommand() *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the config, provider, stores and system for problems",
+		Long: `Checks that the config is valid, the default model answers, the stores in
+the workspace are readable, the workspace is writable and the clock is
+right, and says how to fix what is not. The provider check sends one short
+request to the default model; --offline skips it and the other network
+checks.`,
+		Example: `picoclaw doctor
+picoclaw doctor --offline`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return doctorCmd(cmd.Context(), cmd.OutOrStdout(), internal.GetConfigPath(), offline)
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "skip the checks that need the network")
+
+	return cmd
+}
@@ -0,0 +1,20 @@
+package doctor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDoctorCommand(t *testing.T) {
+	cmd := NewDoctorCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "doctor", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("offline"))
+}
@@ -0,0 +1,30 @@
+package erase
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewEraseCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "erase <user>",
+		Short: "Delete everything kept about a channel user",
+		Long: `Deletes the direct conversations of a user, such as telegram:123456 or a
+person named in session.identity_links, and of the accounts linked to them,
+with the facts learned from them, their attachments, and the usage records,
+tool calls, traces and audit entries of their conversations. Group chats are
+kept. Works on the default agent's workspace, and refuses to run while the
+gateway does, except with --dry-run.`,
+		Example: `picoclaw erase telegram:123456 --dry-run
+picoclaw erase telegram:123456`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eraseCmd(cmd.Context(), cmd.OutOrStdout(), args[0], dryRun)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list what would be deleted without deleting it")
+
+	return cmd
+}
@@ -0,0 +1,46 @@
+package erase
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/erasure"
+)
+
+func TestNewEraseCommand(t *testing.T) {
+	cmd := NewEraseCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "erase <user>", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
+}
+
+func TestPrintReport(t *testing.T) {
+	var buf bytes.Buffer
+	printReport(&buf, &erasure.Report{
+		DryRun:      true,
+		Person:      "anna",
+		Accounts:    []string{"discord:7", "telegram:1"},
+		Sessions:    []string{"agent:main:telegram:direct:1"},
+		Shared:      []string{"agent:main:main"},
+		Facts:       3,
+		Unlinked:    []string{"discord:7"},
+		ConfigLinks: []string{"telegram:1"},
+	})
+	out := buf.String()
+
+	assert.Contains(t, out, "Would erase anna (discord:7, telegram:1):")
+	assert.Contains(t, out, "  1 sessions\n    agent:main:telegram:direct:1\n")
+	assert.Contains(t, out, "  3 facts\n")
+	assert.NotContains(t, out, "attachments")
+	assert.Contains(t, out, "the /link links of discord:7")
+	assert.Contains(t, out, "Not erased: agent:main:main")
+	assert.Contains(t, out, "Remove telegram:1 from session.identity_links")
+}
@@ -0,0 +1,105 @@
+package erase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func eraseCmd(ctx context.Context, w io.Writer, userID string, dryRun bool) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if !dryRun && internal.GatewayRunning(cfg) {
+		return errors.New("the gateway is running and would write the data back; stop it first")
+	}
+	eraser, err := newEraser(cfg)
+	if err != nil {
+		return err
+	}
+	report, err := eraser.Erase(ctx, userID, dryRun)
+	if report != nil {
+		printReport(w, report)
+	}
+	return err
+}
+
+// newEraser opens the stores of the default workspace. The attachments are
+// only known to the gateway, which deletes them after a while anyway.
+func newEraser(cfg *config.Config) (*erasure.Eraser, error) {
+	workspace := cfg.WorkspacePath()
+	sessions, err := session.OpenManager(filepath.Join(workspace, "sessions"), cfg.SessionKey())
+	if err != nil {
+		return nil, err
+	}
+	ws := erasure.Workspace{Sessions: sessions}
+	if ws.Facts, err = memory.NewFactStore(filepath.Join(workspace, "memory")); err != nil {
+		return nil, err
+	}
+	if ws.Tasks, err = memory.NewTaskStore(filepath.Join(workspace, "memory")); err != nil {
+		return nil, err
+	}
+	if ws.Usage, err = memory.NewUsageLedger(filepath.Join(workspace, "usage")); err != nil {
+		return nil, err
+	}
+	if ws.ToolCalls, err = memory.NewToolCallLog(filepath.Join(workspace, "usage")); err != nil {
+		return nil, err
+	}
+	keep := cfg.Agents.Defaults.Traces.Keep
+	if ws.Traces, err = memory.NewTraceLog(filepath.Join(workspace, "traces"), keep); err != nil {
+		return nil, err
+	}
+
+	eraser := &erasure.Eraser{
+		Workspaces: []erasure.Workspace{ws},
+		Links:      identity.NewLinks(cfg.Session.IdentityLinks, workspace),
+	}
+	if cfg.Audit.Enabled {
+		if eraser.Audit, err = audit.Open(cfg.AuditDir()); err != nil {
+			return nil, err
+		}
+	}
+	return eraser, nil
+}
+
+func printReport(w io.Writer, r *erasure.Report) {
+	verb := "Erased"
+	if r.DryRun {
+		verb = "Would erase"
+	}
+	fmt.Fprintf(w, "%s %s (%s):\n", verb, r.Person, strings.Join(r.Accounts, ", "))
+	fmt.Fprintf(w, "  %d sessions\n", len(r.Sessions))
+	for _, key := range r.Sessions {
+		fmt.Fprintf(w, "    %s\n", key)
+	}
+	fmt.Fprintf(w, "  %d facts\n", r.Facts)
+	fmt.Fprintf(w, "  %d tasks\n", r.Tasks)
+	if r.Attachments > 0 {
+		fmt.Fprintf(w, "  %d attachments\n", r.Attachments)
+	}
+	fmt.Fprintf(w, "  %d usage records\n", r.UsageRecords)
+	fmt.Fprintf(w, "  %d tool calls\n", r.ToolCalls)
+	fmt.Fprintf(w, "  %d traced turns\n", r.Traces)
+	fmt.Fprintf(w, "  %d audit entries\n", r.AuditEntries)
+	if len(r.Unlinked) > 0 {
+		fmt.Fprintf(w, "  the /link links of %s\n", strings.Join(r.Unlinked, ", "))
+	}
+	for _, key := range r.Shared {
+		fmt.Fprintf(w, "Not erased: %s is shared by all direct chats; check it with picoclaw sessions show.\n", key)
+	}
+	if len(r.ConfigLinks) > 0 {
+		fmt.Fprintf(w, "Remove %s from session.identity_links in the config.\n", strings.Join(r.ConfigLinks, ", "))
+	}
+}
@@ -0,0 +1,32 @@
+package eval
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewEvalCommand() *cobra.Command {
+	var opts options
+
+	cmd := &cobra.Command{
+		Use:   "eval <suite.json>...",
+		Short: "Run evaluation suites of scripted conversations",
+		Long: `Runs each case of the suites with the configured agent, in a workspace of its
+own, and checks the tools called and the answers given. Without --live the
+model's answers come from the scripts in the suite; the tools really run.
+Exits with an error when a case fails.`,
+		Example: `picoclaw eval evals/basics.json
+picoclaw eval evals/*.json --run notes
+picoclaw eval evals/basics.json --live --json`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return evalCmd(cmd.Context(), cmd.OutOrStdout(), args, opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.live, "live", false, "ask the configured model instead of following the scripts")
+	cmd.Flags().StringVar(&opts.run, "run", "", "only run the cases whose name matches this regular expression")
+	cmd.Flags().BoolVar(&opts.asJSON, "json", false, "print the results as JSON")
+	cmd.Flags().BoolVarP(&opts.debug, "debug", "d", false, "enable debug logging")
+
+	return cmd
+}
@@ -0,0 +1,43 @@
+package eval
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/eval"
+)
+
+func TestNewEvalCommand(t *testing.T) {
+	cmd := NewEvalCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "eval <suite.json>...", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.True(t, cmd.HasExample())
+	for _, name := range []string{"live", "run", "json", "debug"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), name)
+	}
+}
+
+func TestPrintResults(t *testing.T) {
+	results := []eval.Result{
+		{Suite: "basics", Case: "reads notes", Duration: 21 * time.Millisecond},
+		{Suite: "basics", Case: "small talk", Failures: []string{"turn 1: unexpected call to exec"}},
+		{Suite: "basics", Case: "live only", Skipped: "no script for every turn; run it live"},
+	}
+	var buf bytes.Buffer
+	printResults(&buf, results)
+	out := buf.String()
+
+	assert.Contains(t, out, "PASS  basics/reads notes (21ms)")
+	assert.Contains(t, out, "FAIL  basics/small talk (0s)\n      turn 1: unexpected call to exec")
+	assert.Contains(t, out, "SKIP  basics/live only: no script")
+	assert.Contains(t, out, "1 passed, 1 failed, 1 skipped")
+	assert.Equal(t, 1, countFailed(results))
+}
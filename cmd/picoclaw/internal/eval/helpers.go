@@ -0,0 +1,111 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/eval"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type options struct {
+	live   bool
+	run    string
+	asJSON bool
+	debug  bool
+}
+
+func evalCmd(ctx context.Context, w io.Writer, paths []string, opts options) error {
+	var run eval.Options
+	if opts.run != "" {
+		re, err := regexp.Compile(opts.run)
+		if err != nil {
+			return fmt.Errorf("invalid --run: %w", err)
+		}
+		run.Filter = re
+	}
+	suites := make([]*eval.Suite, 0, len(paths))
+	for _, path := range paths {
+		s, err := eval.LoadSuite(path)
+		if err != nil {
+			return err
+		}
+		suites = append(suites, s)
+	}
+
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if err := internal.ConfigureLogging(cfg, opts.debug); err != nil {
+		return fmt.Errorf("error setting up logging: %w", err)
+	}
+	if opts.live {
+		provider, modelID, err := providers.CreateProvider(cfg)
+		if err != nil {
+			return fmt.Errorf("error creating provider: %w", err)
+		}
+		if modelID != "" {
+			cfg.Agents.Defaults.ModelName = modelID
+		}
+		run.Provider = provider
+	}
+
+	var results []eval.Result
+	for _, s := range suites {
+		results = append(results, eval.Run(ctx, cfg, s, run)...)
+	}
+	if opts.asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printResults(w, results)
+	}
+	if failed := countFailed(results); failed > 0 {
+		return fmt.Errorf("%d of %d cases failed", failed, len(results))
+	}
+	return nil
+}
+
+func printResults(w io.Writer, results []eval.Result) {
+	var passed, skipped int
+	for _, r := range results {
+		name := r.Suite + "/" + r.Case
+		switch {
+		case r.Skipped != "":
+			skipped++
+			fmt.Fprintf(w, "SKIP  %s: %s\n", name, r.Skipped)
+		case r.Passed():
+			passed++
+			fmt.Fprintf(w, "PASS  %s (%s)\n", name, r.Duration.Round(time.Millisecond))
+		default:
+			fmt.Fprintf(w, "FAIL  %s (%s)\n", name, r.Duration.Round(time.Millisecond))
+			for _, f := range r.Failures {
+				fmt.Fprintf(w, "      %s\n", f)
+			}
+		}
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No cases match.")
+		return
+	}
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d skipped\n", passed, countFailed(results), skipped)
+}
+
+func countFailed(results []eval.Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Skipped == "" && !r.Passed() {
+			n++
+		}
+	}
+	return n
+}
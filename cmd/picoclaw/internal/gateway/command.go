@@ -5,7 +5,7 @@ import (
 )
 
 func NewGatewayCommand() *cobra.Command {
-	var debug bool
+	var debug, pprof bool
 
 	cmd := &cobra.Command{
 		Use:     "gateway",
@@ -13,11 +13,12 @@ func NewGatewayCommand() *cobra.Command {
 		Short:   "Start picoclaw gateway",
 		Args:    cobra.NoArgs,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return gatewayCmd(debug)
+			return gatewayCmd(debug, pprof)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+	cmd.Flags().BoolVar(&pprof, "pprof", false, "Serve pprof and expvar endpoints (gateway.debug in the config)")
 
 	return cmd
 }
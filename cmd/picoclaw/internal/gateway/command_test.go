@@ -5,6 +5,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/heartbeat"
 )
 
 func TestNewGatewayCommand(t *testing.T) {
@@ -28,4 +30,41 @@ func TestNewGatewayCommand(t *testing.T) {
 
 	assert.True(t, cmd.HasFlags())
 	assert.NotNil(t, cmd.Flags().Lookup("debug"))
+	assert.NotNil(t, cmd.Flags().Lookup("pprof"))
+}
+
+func TestSplitChanges(t *testing.T) {
+	applied, restart := splitChanges([]string{
+		"agents.defaults.model",
+		"agents.defaults.personas.coach.prompt",
+		"agents.defaults.prompt",
+		"channels.telegram.token",
+		"heartbeat.interval",
+		"logging.components.agent",
+		"tools.exec.enabled",
+		"tools.exec.timeout_seconds",
+	})
+
+	assert.Equal(t, []string{
+		"agents.defaults.personas.coach.prompt",
+		"agents.defaults.prompt",
+		"heartbeat.interval",
+		"logging.components.agent",
+		"tools.exec.enabled",
+	}, applied)
+	assert.Equal(t, []string{
+		"agents.defaults.model",
+		"channels.telegram.token",
+		"tools.exec.timeout_seconds",
+	}, restart)
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	agentDone := make(chan struct{})
+	hb := heartbeat.NewHeartbeatService(t.TempDir(), 30, false)
+	check := watchdogCheck(agentDone, hb)
+
+	assert.NoError(t, check())
+	close(agentDone)
+	assert.EqualError(t, check(), "agent loop stopped")
 }
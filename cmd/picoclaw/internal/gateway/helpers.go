@@ -4,51 +4,71 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
-	"os/signal"
 	"path/filepath"
 	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/access"
 	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/api"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	_ "github.com/sipeed/picoclaw/pkg/channels/dingtalk"
 	_ "github.com/sipeed/picoclaw/pkg/channels/discord"
+	_ "github.com/sipeed/picoclaw/pkg/channels/email"
 	_ "github.com/sipeed/picoclaw/pkg/channels/feishu"
 	_ "github.com/sipeed/picoclaw/pkg/channels/irc"
 	_ "github.com/sipeed/picoclaw/pkg/channels/line"
 	_ "github.com/sipeed/picoclaw/pkg/channels/maixcam"
+	_ "github.com/sipeed/picoclaw/pkg/channels/matrix"
+	_ "github.com/sipeed/picoclaw/pkg/channels/mqtt"
 	_ "github.com/sipeed/picoclaw/pkg/channels/onebot"
 	_ "github.com/sipeed/picoclaw/pkg/channels/pico"
 	_ "github.com/sipeed/picoclaw/pkg/channels/qq"
+	_ "github.com/sipeed/picoclaw/pkg/channels/queue"
+	_ "github.com/sipeed/picoclaw/pkg/channels/serial"
 	_ "github.com/sipeed/picoclaw/pkg/channels/slack"
 	_ "github.com/sipeed/picoclaw/pkg/channels/telegram"
+	_ "github.com/sipeed/picoclaw/pkg/channels/voice"
+	_ "github.com/sipeed/picoclaw/pkg/channels/webhook"
 	_ "github.com/sipeed/picoclaw/pkg/channels/wecom"
 	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp"
 	_ "github.com/sipeed/picoclaw/pkg/channels/whatsapp_native"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/cron"
 	"github.com/sipeed/picoclaw/pkg/devices"
+	"github.com/sipeed/picoclaw/pkg/diag"
+	"github.com/sipeed/picoclaw/pkg/digest"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/heartbeat"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/memsync"
+	"github.com/sipeed/picoclaw/pkg/notify"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/shutdown"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/systemd"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
 	"github.com/sipeed/picoclaw/pkg/tools"
 	"github.com/sipeed/picoclaw/pkg/voice"
 )
 
-func gatewayCmd(debug bool) error {
+func gatewayCmd(debug, pprof bool) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	if err := internal.ConfigureLogging(cfg, debug); err != nil {
+		return fmt.Errorf("error setting up logging: %w", err)
+	}
+	internal.InstallCrashHandler(cfg)
 	if debug {
-		logger.SetLevel(logger.DEBUG)
 		fmt.Println("🔍 Debug mode enabled")
 	}
-
-	cfg, err := internal.LoadConfig()
+	stopTelemetry, err := telemetry.Setup(context.Background(), cfg.Telemetry, internal.GetVersion())
 	if err != nil {
-		return fmt.Errorf("error loading config: %w", err)
+		return fmt.Errorf("error setting up telemetry: %w", err)
 	}
 
 	provider, modelID, err := providers.CreateProvider(cfg)
@@ -113,10 +133,35 @@ func gatewayCmd(debug bool) error {
 		if response == "HEARTBEAT_OK" {
 			return tools.SilentResult("Heartbeat OK")
 		}
+		// With notifications.heartbeat the finding also goes out as a
+		// notification; repeats of the same finding are deduplicated.
+		if notifier := agentLoop.Notifier(); notifier != nil && notifier.Heartbeat() {
+			n := notify.Notification{Title: "Heartbeat", Message: response}
+			if _, err := notifier.Notify(context.Background(), n); err != nil {
+				logger.WarnCF("heartbeat", "Heartbeat notification not sent", map[string]any{"error": err.Error()})
+			}
+		}
 		// For heartbeat, always return silent - the subagent result will be
 		// sent to user via processSystemMessage when the async task completes
 		return tools.SilentResult(response)
 	})
+	if calCfg := cfg.Tools.Calendar; cfg.Tools.IsToolEnabled("calendar") && calCfg.URL != "" &&
+		calCfg.ReminderMinutes > 0 {
+		if client, err := tools.NewCalendarClient(calCfg); err != nil {
+			logger.WarnCF("heartbeat", "Calendar reminders disabled", map[string]any{"error": err.Error()})
+		} else {
+			lead := time.Duration(calCfg.ReminderMinutes) * time.Minute
+			reminders := tools.NewCalendarReminders(client, lead, heartbeatService.Interval())
+			heartbeatService.AddContextProvider(reminders.Context)
+		}
+	}
+	if cfg.Tools.IsToolEnabled("system_info") {
+		monitor := tools.NewSystemMonitor(cfg.Tools.SystemInfo, cfg.WorkspacePath())
+		heartbeatService.AddContextProvider(monitor.Context)
+	}
+	if taskList := agentLoop.DefaultAgentTasks(); taskList != nil {
+		heartbeatService.AddContextProvider(tools.NewTaskReminders(taskList).Context)
+	}
 
 	// Create media store for file lifecycle management with TTL cleanup
 	mediaStore := media.NewFileMediaStoreWithCleanup(media.MediaCleanerConfig{
@@ -136,6 +181,17 @@ func gatewayCmd(debug bool) error {
 	agentLoop.SetChannelManager(channelManager)
 	agentLoop.SetMediaStore(mediaStore)
 
+	if cfg.Access.Enabled {
+		accessController := access.NewController(cfg.Access, cfg.WorkspacePath())
+		accessController.SetAuditLog(agentLoop.AuditLog())
+		channelManager.SetAccessController(accessController)
+		agentLoop.SetAccessController(accessController)
+		if code := accessController.PairingCode(); code != "" {
+			fmt.Printf("🔑 No owner yet: send \"/pair %s\" to the bot to claim it\n", code)
+			logger.InfoCF("access", "Waiting for the owner to pair", map[string]any{"code": code})
+		}
+	}
+
 	// Wire up voice transcription if a supported provider is configured.
 	if transcriber := voice.DetectTranscriber(cfg); transcriber != nil {
 		agentLoop.SetTranscriber(transcriber)
@@ -181,38 +237,134 @@ func gatewayCmd(debug bool) error {
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
 	addr := fmt.Sprintf("%s:%d", cfg.Gateway.Host, cfg.Gateway.Port)
 	channelManager.SetupHTTPServer(addr, healthServer)
+	if l := activatedListener(); l != nil {
+		channelManager.SetListener(l)
+		addr = l.Addr().String()
+		fmt.Printf("✓ Listening on the socket passed by systemd (%s)\n", addr)
+	}
+	var apiServer *api.Server
+	if cfg.Gateway.API.Enabled {
+		if apiServer, err = api.NewServer(cfg.Gateway.API, agentLoop, heartbeatService.Trigger); err != nil {
+			fmt.Printf("⚠ REST API disabled: %v\n", err)
+			apiServer = nil
+		} else {
+			channelManager.Handle(api.Prefix, apiServer)
+			fmt.Printf("✓ REST API available at http://%s%s\n", addr, api.Prefix)
+		}
+	}
+
+	var syncer *memsync.Syncer
+	if cfg.Sync.Enabled {
+		if syncer, err = newSyncer(cfg, agentLoop); err != nil {
+			fmt.Printf("⚠ Sync disabled: %v\n", err)
+		} else {
+			channelManager.Handle(memsync.Path, syncer)
+			fmt.Printf("✓ Sync available at http://%s%s\n", addr, memsync.Path)
+		}
+	}
+
+	var debugServer *health.DebugServer
+	if dbg := cfg.Gateway.Debug; dbg.Enabled || pprof {
+		debugServer = health.NewDebugServer(dbg.Host, dbg.Port)
+		if err := debugServer.Start(); err != nil {
+			fmt.Printf("⚠ Debug endpoints disabled: %v\n", err)
+			debugServer = nil
+		} else {
+			fmt.Printf("✓ pprof and expvar available at http://%s/debug/pprof/ and /debug/vars\n", debugServer.Addr())
+			if !health.IsLoopback(dbg.Host) {
+				logger.WarnCF("debug", "Debug endpoints are reachable from the network without authentication",
+					map[string]any{"host": dbg.Host})
+			}
+		}
+	}
 
 	if err := channelManager.StartAll(ctx); err != nil {
 		fmt.Printf("Error starting channels: %v\n", err)
 		return err
 	}
 
-	fmt.Printf("✓ Health endpoints available at http://%s:%d/health and /ready\n", cfg.Gateway.Host, cfg.Gateway.Port)
-
-	go agentLoop.Run(ctx)
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt)
-	<-sigChan
-
-	fmt.Println("\nShutting down...")
-	if cp, ok := provider.(providers.StatefulProvider); ok {
-		cp.Close()
+	fmt.Printf("✓ Health endpoints available at http://%s/health and /ready\n", addr)
+
+	agentDone := make(chan struct{})
+	go func() {
+		defer close(agentDone)
+		defer diag.Recover()
+		agentLoop.Run(ctx)
+	}()
+
+	reload := &reloader{
+		path:      internal.GetConfigPath(),
+		debug:     debug,
+		current:   cfg,
+		heartbeat: heartbeatService,
+		agentLoop: agentLoop,
+	}
+	go config.WatchFile(ctx, reload.path, 2*time.Second, reload.reload)
+	if syncer != nil && cfg.Sync.Peer != "" {
+		go syncer.Run(ctx, cfg.Sync.Peer, time.Duration(cfg.Sync.IntervalSeconds)*time.Second)
+	}
+	if dc := cfg.Notifications.Digest; dc.Enabled && agentLoop.Notifier() != nil {
+		go digest.Run(ctx, dc.Schedule, func(ctx context.Context, since, until time.Time) {
+			if err := agentLoop.SendDigest(ctx, since, until); err != nil {
+				logger.WarnCF("digest", "Digest not sent", map[string]any{"error": err.Error()})
+			}
+		})
+		fmt.Printf("✓ Digest scheduled (%s)\n", dc.Schedule)
 	}
-	cancel()
-	msgBus.Close()
-
-	// Use a fresh context with timeout for graceful shutdown,
-	// since the original ctx is already canceled.
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer shutdownCancel()
 
-	channelManager.StopAll(shutdownCtx)
-	deviceService.Stop()
-	heartbeatService.Stop()
-	cronService.Stop()
-	mediaStore.Stop()
-	agentLoop.Stop()
+	go systemd.RunWatchdog(ctx, watchdogCheck(agentDone, heartbeatService))
+	healthServer.SetReady(true)
+	sdNotify(systemd.Ready, systemd.Status(readyStatus(enabledChannels)))
+
+	sig := shutdown.Wait()
+	fmt.Printf("\nShutting down (%s)...\n", sig)
+	healthServer.SetReady(false)
+
+	// Work in progress finishes first, with the channels still up to carry
+	// the replies; then the parts are stopped from the outside in.
+	drainTimeout := time.Duration(cfg.Gateway.ShutdownTimeoutSeconds) * time.Second
+	steps := shutdown.New()
+	steps.AddFunc("intake", func() {
+		msgBus.CloseInbound()
+		if apiServer != nil {
+			apiServer.Drain()
+		}
+		cronService.Stop()
+		heartbeatService.Stop()
+		deviceService.Stop()
+	})
+	if drainTimeout > 0 {
+		steps.Add("turns", drainTimeout, agentLoop.DrainTurns)
+		steps.Add("jobs", drainTimeout, agentLoop.FlushJobs)
+	}
+	steps.Add("agent", 15*time.Second, func(ctx context.Context) error {
+		// Cancels the turns that did not finish in time.
+		agentLoop.Stop()
+		cancel()
+		select {
+		case <-agentDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	steps.Add("channels", 15*time.Second, channelManager.StopAll)
+	steps.AddFunc("media", mediaStore.Stop)
+	steps.AddFunc("providers", func() {
+		if cp, ok := provider.(providers.StatefulProvider); ok {
+			cp.Close()
+		}
+	})
+	steps.AddFunc("bus", msgBus.Close)
+	if debugServer != nil {
+		steps.Add("debug endpoints", 5*time.Second, debugServer.Stop)
+	}
+	steps.Add("telemetry", 15*time.Second, stopTelemetry)
+	// Keeps systemd from killing the gateway while the turns drain.
+	sdNotify(systemd.Stopping, systemd.Status("Shutting down"), systemd.ExtendTimeout(steps.MaxDuration()))
+	if err := steps.Run(); err != nil {
+		fmt.Printf("⚠ Shutdown incomplete: %v\n", err)
+	}
 	fmt.Println("✓ Gateway stopped")
 
 	return nil
@@ -231,16 +383,22 @@ func setupCronTool(
 	// Create cron service
 	cronService := cron.NewCronService(cronStorePath, nil)
 
-	// Create and register CronTool if enabled
+	// Create and register CronTool and ScheduleTool if enabled. Both store
+	// jobs in cronService, which runs them through CronTool.ExecuteJob.
 	var cronTool *tools.CronTool
-	if cfg.Tools.IsToolEnabled("cron") {
+	if cfg.Tools.IsToolEnabled("cron") || cfg.Tools.IsToolEnabled("schedule") {
 		var err error
 		cronTool, err = tools.NewCronTool(cronService, agentLoop, msgBus, workspace, restrict, execTimeout, cfg)
 		if err != nil {
 			log.Fatalf("Critical error during CronTool initialization: %v", err)
 		}
 
-		agentLoop.RegisterTool(cronTool)
+		if cfg.Tools.IsToolEnabled("cron") {
+			agentLoop.RegisterTool(cronTool)
+		}
+		if cfg.Tools.IsToolEnabled("schedule") {
+			agentLoop.RegisterTool(tools.NewScheduleTool(cronService))
+		}
 	}
 
 	// Set onJob handler
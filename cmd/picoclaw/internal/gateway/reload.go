@@ -0,0 +1,98 @@
+package gateway
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/heartbeat"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// liveSettings are the config paths, or sections ending in ".", that a
+// running gateway applies without a restart. The tools.<name>.enabled
+// switches are handled by the agent loop.
+var liveSettings = []string{
+	"logging.",
+	"heartbeat.enabled",
+	"heartbeat.interval",
+	"agents.defaults.prompt.",
+	"agents.defaults.personas.",
+}
+
+// reloader applies the changes to the config file of a running gateway.
+type reloader struct {
+	mu        sync.Mutex
+	path      string
+	debug     bool
+	current   *config.Config
+	heartbeat *heartbeat.HeartbeatService
+	agentLoop *agent.AgentLoop
+}
+
+// reload loads the config file again and applies what changed. A file that
+// does not load keeps the running config.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.LoadConfig(r.path)
+	if err != nil {
+		logger.ErrorCF("config", "Config not reloaded", map[string]any{"path": r.path, "error": err.Error()})
+		fmt.Printf("⚠ Config not reloaded: %v\n", err)
+		return
+	}
+	applied, restart := splitChanges(config.ChangedPaths(r.current, cfg))
+	if len(applied) == 0 && len(restart) == 0 {
+		return
+	}
+
+	if slices.ContainsFunc(applied, func(p string) bool { return strings.HasPrefix(p, "logging.") }) {
+		if err := internal.ConfigureLogging(cfg, r.debug); err != nil {
+			logger.ErrorCF("config", "Logging settings not applied", map[string]any{"error": err.Error()})
+		}
+	}
+	r.heartbeat.Reconfigure(cfg.Heartbeat.Interval, cfg.Heartbeat.Enabled)
+	restart = append(restart, r.agentLoop.ApplyConfig(cfg)...)
+	r.current = cfg
+
+	applied = slices.DeleteFunc(applied, func(p string) bool { return slices.Contains(restart, p) })
+	if len(applied) > 0 {
+		logger.InfoCF("config", "Config reloaded", map[string]any{"applied": applied})
+		fmt.Printf("✓ Config reloaded: %s\n", strings.Join(applied, ", "))
+	}
+	if len(restart) > 0 {
+		logger.WarnCF("config", "Config changes need a restart", map[string]any{"settings": restart})
+		fmt.Printf("⚠ Restart the gateway to apply: %s\n", strings.Join(restart, ", "))
+	}
+}
+
+// splitChanges sorts the changed config paths into those a running gateway
+// applies and those that need a restart.
+func splitChanges(paths []string) (applied, restart []string) {
+	for _, path := range paths {
+		if isLive(path) {
+			applied = append(applied, path)
+		} else {
+			restart = append(restart, path)
+		}
+	}
+	return applied, restart
+}
+
+func isLive(path string) bool {
+	if key, ok := strings.CutPrefix(path, "tools."); ok {
+		key, ok = strings.CutSuffix(key, ".enabled")
+		return ok && !strings.Contains(key, ".")
+	}
+	for _, s := range liveSettings {
+		if path == strings.TrimSuffix(s, ".") || (strings.HasSuffix(s, ".") && strings.HasPrefix(path, s)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package gateway
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/memsync"
+)
+
+// newSyncer syncs the sessions and facts of the default agent. The gateway
+// serves the peer's exchanges and, when sync.peer is set, starts its own.
+func newSyncer(cfg *config.Config, agentLoop *agent.AgentLoop) (*memsync.Syncer, error) {
+	_, sessions := agentLoop.DefaultAgentSessions()
+	if sessions == nil {
+		return nil, errors.New("no agent configured")
+	}
+	stores := []memsync.Store{memsync.Sessions(sessions)}
+	if facts := agentLoop.DefaultAgentFacts(); facts != nil {
+		stores = append(stores, memsync.Facts(facts))
+	}
+	return memsync.New(filepath.Join(cfg.WorkspacePath(), "sync"), cfg.Sync.Key, stores...)
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/heartbeat"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/systemd"
+)
+
+// maxHeartbeatRun is how long a heartbeat may run before the watchdog
+// takes the gateway for stuck.
+const maxHeartbeatRun = 15 * time.Minute
+
+// activatedListener returns the socket systemd passed for the HTTP server,
+// or nil to listen on the configured address. With several sockets the one
+// named "http" (FileDescriptorName=http) is used.
+func activatedListener() net.Listener {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		logger.WarnCF("systemd", "Some activated sockets are unusable", map[string]any{"error": err.Error()})
+	}
+	if l, ok := listeners["http"]; ok {
+		return l
+	}
+	if len(listeners) == 1 {
+		for _, l := range listeners {
+			return l
+		}
+	}
+	if len(listeners) > 0 {
+		logger.WarnCF("systemd", "Several activated sockets and none named http; ignoring them",
+			map[string]any{"count": len(listeners)})
+	}
+	return nil
+}
+
+// watchdogCheck returns the health check the systemd watchdog pings on: the
+// agent loop still runs, and no heartbeat has been running for so long that
+// it must be stuck.
+func watchdogCheck(agentDone <-chan struct{}, hb *heartbeat.HeartbeatService) func() error {
+	return func() error {
+		select {
+		case <-agentDone:
+			return errors.New("agent loop stopped")
+		default:
+		}
+		if d := hb.Busy(); d > maxHeartbeatRun {
+			return fmt.Errorf("heartbeat running for %s", d.Round(time.Second))
+		}
+		return nil
+	}
+}
+
+// readyStatus returns the unit status shown in systemctl status once the
+// gateway is up.
+func readyStatus(channels []string) string {
+	if len(channels) == 0 {
+		return "Running"
+	}
+	return "Running: " + strings.Join(channels, ", ")
+}
+
+// sdNotify tells systemd about the gateway state, when it runs as a
+// Type=notify unit.
+func sdNotify(states ...string) {
+	if _, err := systemd.Notify(states...); err != nil {
+		logger.WarnCF("systemd", "Notification failed", map[string]any{"error": err.Error()})
+	}
+}
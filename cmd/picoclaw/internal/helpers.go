@@ -2,11 +2,19 @@ package internal
 
 import (
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"time"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/diag"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/snapshot"
 )
 
 const Logo = "🦞"
@@ -39,6 +47,111 @@ func LoadConfig() (*config.Config, error) {
 	return config.LoadConfig(GetConfigPath())
 }
 
+// SaveConfig writes cfg to the config file and, unless auditing is off,
+// records the change in the audit log under action, e.g. "auth login".
+func SaveConfig(cfg *config.Config, action string) error {
+	path := GetConfigPath()
+	before, _ := os.ReadFile(path)
+	if err := config.SaveConfig(path, cfg); err != nil {
+		return err
+	}
+	if !cfg.Audit.Enabled {
+		return nil
+	}
+	after, _ := os.ReadFile(path)
+	if err := audit.RecordConfigSave(cfg.AuditDir(), path, "local", action, before, after); err != nil {
+		logger.WarnCF("audit", "Failed to audit config change", map[string]any{"error": err.Error()})
+	}
+	return nil
+}
+
+// GatewayRunning reports whether a gateway answers on the health endpoint
+// configured in cfg, and so holds the sessions and other state in memory.
+func GatewayRunning(cfg *config.Config) bool {
+	host := cfg.Gateway.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://%s/health", net.JoinHostPort(host, strconv.Itoa(cfg.Gateway.Port))))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// ConfigureLogging sets up the logger from cfg.Logging; debug lowers the
+// level to DEBUG whatever the config says.
+func ConfigureLogging(cfg *config.Config, debug bool) error {
+	l := cfg.Logging
+	level, err := logger.ParseLevel(l.Level)
+	if err != nil {
+		return err
+	}
+	if debug {
+		level = logger.DEBUG
+	}
+	opts := logger.Options{
+		Level:      level,
+		Components: make(map[string]logger.LogLevel, len(l.Components)),
+		Format:     l.Format,
+		MaxSizeMB:  l.File.MaxSizeMB,
+		MaxFiles:   l.File.MaxFiles,
+	}
+	for component, name := range l.Components {
+		if opts.Components[component], err = logger.ParseLevel(name); err != nil {
+			return err
+		}
+	}
+	opts.Dir = logDir(cfg)
+	return logger.Configure(opts)
+}
+
+// logDir returns the directory of the log files, or "" when file logging is
+// off.
+func logDir(cfg *config.Config) string {
+	if !cfg.Logging.File.Enabled {
+		return ""
+	}
+	if cfg.Logging.File.Dir != "" {
+		return cfg.Logging.File.Dir
+	}
+	return filepath.Join(cfg.WorkspacePath(), "logs")
+}
+
+// DiagSource returns where the diagnostic bundles of cfg's workspace are
+// written and what goes into them.
+func DiagSource(cfg *config.Config) diag.Source {
+	return diag.Source{
+		Dir:       filepath.Join(cfg.WorkspacePath(), "diag"),
+		Workspace: cfg.WorkspacePath(),
+		LogDir:    logDir(cfg),
+		Config:    cfg,
+		Version:   FormatVersion(),
+	}
+}
+
+// SnapshotLayout returns where the state that a snapshot of cfg's instance
+// holds lives.
+func SnapshotLayout(cfg *config.Config) snapshot.Layout {
+	return snapshot.Layout{
+		Config:    GetConfigPath(),
+		Auth:      filepath.Join(GetPicoclawHome(), "auth.json"),
+		Secrets:   filepath.Join(GetPicoclawHome(), "secrets"),
+		Workspace: cfg.WorkspacePath(),
+		Logs:      logDir(cfg),
+	}
+}
+
+// InstallCrashHandler makes crashes write a diagnostic bundle to the
+// workspace. Failing to set it up is logged, not returned.
+func InstallCrashHandler(cfg *config.Config) {
+	if err := diag.Install(DiagSource(cfg)); err != nil {
+		logger.WarnCF("diag", "Crash handler not installed", map[string]any{"error": err.Error()})
+	}
+}
+
 // FormatVersion returns the version string with optional git commit
 func FormatVersion() string {
 	v := version
@@ -1,13 +1,21 @@
 package internal
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 func TestGetConfigPath(t *testing.T) {
@@ -126,3 +134,42 @@ func TestGetConfigPath_WithEnv(t *testing.T) {
 
 	assert.Equal(t, want, got)
 }
+
+func TestConfigureLogging(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Logging.Components = map[string]string{"heartbeat": "error"}
+	t.Cleanup(func() { logger.Configure(logger.Options{Level: logger.INFO}) })
+
+	require.NoError(t, ConfigureLogging(cfg, true))
+	assert.Equal(t, logger.DEBUG, logger.GetLevel())
+	logger.InfoC("heartbeat", "dropped")
+	logger.DebugC("agent", "kept")
+
+	data, err := os.ReadFile(filepath.Join(cfg.WorkspacePath(), "logs", "picoclaw.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "kept")
+	assert.NotContains(t, string(data), "dropped")
+
+	cfg.Logging.Level = "loud"
+	assert.Error(t, ConfigureLogging(cfg, false))
+}
+
+func TestGatewayRunning(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	cfg := config.DefaultConfig()
+	cfg.Gateway.Host = host
+	cfg.Gateway.Port, _ = strconv.Atoi(port)
+	assert.True(t, GatewayRunning(cfg))
+
+	srv.Close()
+	assert.False(t, GatewayRunning(cfg))
+}
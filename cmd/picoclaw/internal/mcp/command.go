@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewMCPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Model Context Protocol integration",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newServeCommand())
+
+	return cmd
+}
+
+func newServeCommand() *cobra.Command {
+	var (
+		httpAddr   string
+		allowWrite bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve memory, session history and workspace files as an MCP server",
+		Long: `Serve the default agent's long-term memory, daily notes, session history and
+workspace files to MCP clients such as Claude Desktop. The server speaks MCP
+over stdio unless --http is given, in which case it serves the streamable
+HTTP transport on that address.`,
+		Example: `picoclaw mcp serve
+picoclaw mcp serve --http 127.0.0.1:8765
+picoclaw mcp serve --allow-write`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return serveCmd(cmd.Context(), httpAddr, allowWrite)
+		},
+	}
+
+	cmd.Flags().StringVar(&httpAddr, "http", "", "serve streamable HTTP on this address instead of stdio")
+	cmd.Flags().BoolVar(&allowWrite, "allow-write", false, "also export the write_file and edit_file tools")
+
+	return cmd
+}
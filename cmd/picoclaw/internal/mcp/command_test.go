@@ -0,0 +1,23 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMCPCommand(t *testing.T) {
+	cmd := NewMCPCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "mcp", cmd.Use)
+	assert.True(t, cmd.HasSubCommands())
+
+	serve, _, err := cmd.Find([]string{"serve"})
+	require.NoError(t, err)
+	assert.Equal(t, "serve", serve.Name())
+	assert.NotNil(t, serve.Flags().Lookup("http"))
+	assert.NotNil(t, serve.Flags().Lookup("allow-write"))
+}
@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	picomcp "github.com/sipeed/picoclaw/pkg/mcp"
+)
+
+func serveCmd(ctx context.Context, httpAddr string, allowWrite bool) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	server := picomcp.NewServer(picomcp.ServerOptions{
+		Workspace:  cfg.WorkspacePath(),
+		Version:    internal.GetVersion(),
+		AllowWrite: allowWrite,
+		SessionKey: cfg.SessionKey(),
+	})
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	if httpAddr == "" {
+		// stdout carries the protocol; logs go to stderr.
+		return server.Run(ctx, &mcpsdk.StdioTransport{})
+	}
+
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server { return server }, nil)
+	return serveHTTP(ctx, httpAddr, handler)
+}
+
+// serveHTTP serves handler on addr until ctx is canceled.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() {
+		fmt.Fprintf(os.Stderr, "MCP server listening on http://%s\n", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
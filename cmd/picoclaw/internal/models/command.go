@@ -0,0 +1,29 @@
+package models
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewModelsCommand() *cobra.Command {
+	var modelName string
+
+	cmd := &cobra.Command{
+		Use:   "models [filter]",
+		Short: "List models available through OpenRouter with context size and prices",
+		Example: `picoclaw models
+picoclaw models claude
+picoclaw models --model my-openrouter gpt`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := ""
+			if len(args) > 0 {
+				filter = args[0]
+			}
+			return modelsCmd(cmd.Context(), cmd.OutOrStdout(), modelName, filter)
+		},
+	}
+
+	cmd.Flags().StringVarP(&modelName, "model", "m", "", "model_list entry (openrouter protocol) to use for credentials")
+
+	return cmd
+}
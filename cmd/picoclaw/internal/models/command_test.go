@@ -0,0 +1,45 @@
+package models
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestNewModelsCommand(t *testing.T) {
+	cmd := NewModelsCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "models [filter]", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.Flags().Lookup("model"))
+}
+
+func TestPrintModels_FiltersAndFormatsPrices(t *testing.T) {
+	catalog := []providers.OpenRouterModel{
+		{
+			ID: "anthropic/claude-sonnet-4.6", Name: "Anthropic: Claude Sonnet 4.6", ContextLength: 200000,
+			Pricing: providers.OpenRouterPricing{Prompt: "0.000003", Completion: "0.000015"},
+		},
+		{
+			ID: "openai/gpt-4o", Name: "OpenAI: GPT-4o", ContextLength: 128000,
+			Pricing: providers.OpenRouterPricing{Prompt: "0.0000025", Completion: "0.00001"},
+		},
+	}
+
+	var buf bytes.Buffer
+	printModels(&buf, catalog, "claude")
+	out := buf.String()
+
+	assert.Contains(t, out, "anthropic/claude-sonnet-4.6")
+	assert.Contains(t, out, "3.00")
+	assert.Contains(t, out, "15.00")
+	assert.NotContains(t, out, "openai/gpt-4o")
+	assert.Contains(t, out, "1 model(s)")
+}
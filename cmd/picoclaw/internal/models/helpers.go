@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func modelsCmd(ctx context.Context, w io.Writer, modelName, filter string) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	mc, err := openRouterEntry(cfg, modelName)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := providers.ListOpenRouterModelsFromConfig(ctx, mc)
+	if err != nil {
+		return fmt.Errorf("listing models: %w", err)
+	}
+
+	printModels(w, catalog, filter)
+	return nil
+}
+
+// openRouterEntry returns the model_list entry named modelName, or the first
+// entry using the openrouter protocol when modelName is empty. Without any
+// entry the public catalog is listed anonymously.
+func openRouterEntry(cfg *config.Config, modelName string) (*config.ModelConfig, error) {
+	if modelName != "" {
+		return cfg.GetModelConfig(modelName)
+	}
+	for i := range cfg.ModelList {
+		if protocol, _ := providers.ExtractProtocol(cfg.ModelList[i].Model); protocol == "openrouter" {
+			return &cfg.ModelList[i], nil
+		}
+	}
+	return &config.ModelConfig{ModelName: "openrouter", Model: "openrouter/auto"}, nil
+}
+
+func printModels(w io.Writer, catalog []providers.OpenRouterModel, filter string) {
+	filter = strings.ToLower(filter)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODEL\tCONTEXT\tINPUT $/1M\tOUTPUT $/1M")
+
+	shown := 0
+	for _, m := range catalog {
+		if filter != "" && !strings.Contains(strings.ToLower(m.ID+" "+m.Name), filter) {
+			continue
+		}
+		in, out := m.PricePerMillion()
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\n", m.ID, m.ContextLength, in, out)
+		shown++
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d model(s). Use one as \"openrouter/<model>\" in model_list; "+
+		"input_price/output_price take the prices above.\n", shown)
+}
@@ -25,7 +25,7 @@ func onboard() {
 	}
 
 	cfg := config.DefaultConfig()
-	if err := config.SaveConfig(configPath, cfg); err != nil {
+	if err := internal.SaveConfig(cfg, "onboard"); err != nil {
 		fmt.Printf("Error saving config: %v\n", err)
 		os.Exit(1)
 	}
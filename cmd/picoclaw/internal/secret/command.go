@@ -0,0 +1,20 @@
+package secret
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewSecretCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secret",
+		Short: "Keep API keys and tokens out of the config file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newSetCommand())
+
+	return cmd
+}
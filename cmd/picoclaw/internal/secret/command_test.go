@@ -0,0 +1,23 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSecretCommand(t *testing.T) {
+	cmd := NewSecretCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "secret", cmd.Use)
+
+	assert.Nil(t, cmd.Run)
+	assert.NotNil(t, cmd.RunE)
+
+	assert.True(t, cmd.HasSubCommands())
+	assert.Len(t, cmd.Commands(), 1)
+	assert.Equal(t, "set", cmd.Commands()[0].Name())
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9_.-]*$`)
+
+func newSetCommand() *cobra.Command {
+	var toFile bool
+
+	cmd := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Store a secret in the OS keyring or a private file",
+		Long: `Reads the secret from the terminal, without echoing it, or from standard
+input, and stores it in the OS keyring under the service "picoclaw". With
+--file it goes to a file only you can read in the picoclaw home instead,
+for devices without a keyring. Prints the reference to put in the config in
+place of the secret.`,
+		Example: `picoclaw secret set openai
+echo "$BOT_TOKEN" | picoclaw secret set telegram --file`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := readSecret(cmd.InOrStdin(), cmd.ErrOrStderr(), args[0])
+			if err != nil {
+				return err
+			}
+			return setCmd(cmd.OutOrStdout(), args[0], value, toFile)
+		},
+	}
+
+	cmd.Flags().BoolVar(&toFile, "file", false, "store the secret in a file instead of the OS keyring")
+
+	return cmd
+}
+
+// readSecret prompts for the secret called name on a terminal, or reads it
+// from in.
+func readSecret(in io.Reader, prompt io.Writer, name string) (string, error) {
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprintf(prompt, "Value of %s: ", name)
+		value, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(prompt)
+		return string(value), err
+	}
+	value, err := io.ReadAll(bufio.NewReader(in))
+	return strings.TrimRight(string(value), "\r\n"), err
+}
+
+func setCmd(w io.Writer, name, value string, toFile bool) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	if value == "" {
+		return fmt.Errorf("empty secret")
+	}
+
+	var ref string
+	if toFile {
+		dir := filepath.Join(internal.GetPicoclawHome(), "secrets")
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, name)
+		if err := fileutil.WriteFileAtomic(path, []byte(value+"\n"), 0o600); err != nil {
+			return err
+		}
+		ref = "file:" + path
+		fmt.Fprintf(w, "✓ Secret %s written to %s\n", name, path)
+	} else {
+		if err := config.SetKeyringSecret(name, value); err != nil {
+			return fmt.Errorf("error storing the secret in the OS keyring (try --file): %w", err)
+		}
+		ref = "keyring:" + name
+		fmt.Fprintf(w, "✓ Secret %s stored in the OS keyring\n", name)
+	}
+	fmt.Fprintf(w, "  Use it in the config as %q, e.g. \"api_key\": %q\n", ref, ref)
+	return nil
+}
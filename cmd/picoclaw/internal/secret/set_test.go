@@ -0,0 +1,62 @@
+package secret
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewSetSubcommand(t *testing.T) {
+	cmd := newSetCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "set <name>", cmd.Use)
+	assert.NotNil(t, cmd.Flags().Lookup("file"))
+}
+
+func TestSetCmd_Keyring(t *testing.T) {
+	keyring.MockInit()
+
+	var out bytes.Buffer
+	require.NoError(t, setCmd(&out, "openai", "sk-test", false))
+	assert.Contains(t, out.String(), `"keyring:openai"`)
+
+	value, err := keyring.Get(config.KeyringService, "openai")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test", value)
+}
+
+func TestSetCmd_File(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("PICOCLAW_HOME", home)
+
+	var out bytes.Buffer
+	require.NoError(t, setCmd(&out, "telegram", "123:abc", true))
+	path := filepath.Join(home, "secrets", "telegram")
+	assert.Contains(t, out.String(), `"file:`+path+`"`)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "123:abc\n", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	assert.Error(t, setCmd(&out, "../escape", "x", true))
+	assert.Error(t, setCmd(&out, "empty", "", true))
+}
+
+func TestReadSecret_Pipe(t *testing.T) {
+	value, err := readSecret(strings.NewReader("sk-piped\n"), &bytes.Buffer{}, "openai")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-piped", value)
+}
@@ -0,0 +1,62 @@
+package sessions
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func NewSessionsCommand() *cobra.Command {
+	st := &store{}
+
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Inspect and manage the stored conversations",
+		Long: `Works on the session files of the default agent's workspace. While the
+gateway runs it keeps the sessions in memory and writes them back, so
+truncate, rollback and delete refuse to run then; the other commands only
+read, or remove files no session uses.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		// Resolve the store at execution time so it reflects the current
+		// config and is shared across all subcommands.
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			st.dir = filepath.Join(cfg.WorkspacePath(), "sessions")
+			st.key = cfg.SessionKey()
+			st.traces = filepath.Join(cfg.WorkspacePath(), "traces")
+			st.readOnly = internal.GatewayRunning(cfg)
+			if st.key != "" {
+				if _, err := session.NewStore(st.dir, st.key); err != nil {
+					return fmt.Errorf("cannot open the sessions: %w", err)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		newListCommand(st),
+		newShowCommand(st),
+		newSearchCommand(st),
+		newExportCommand(st),
+		newTruncateCommand(st),
+		newCheckpointsCommand(st),
+		newRollbackCommand(st),
+		newDeleteCommand(st),
+		newStatsCommand(st),
+		newFeedbackCommand(st),
+		newKeyCommand(),
+	)
+
+	return cmd
+}
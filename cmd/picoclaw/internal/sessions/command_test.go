@@ -0,0 +1,60 @@
+package sessions
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestNewSessionsCommand(t *testing.T) {
+	cmd := NewSessionsCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "sessions", cmd.Use)
+	assert.NotNil(t, cmd.RunE)
+	assert.NotNil(t, cmd.PersistentPreRunE)
+
+	allowedCommands := []string{"list", "show", "search", "export", "truncate", "checkpoints", "rollback", "delete", "stats", "feedback", "key"}
+
+	subcommands := cmd.Commands()
+	assert.Len(t, subcommands, len(allowedCommands))
+
+	for _, subcmd := range subcommands {
+		assert.True(t, slices.Contains(allowedCommands, subcmd.Name()), "unexpected subcommand %q", subcmd.Name())
+		assert.NotNil(t, subcmd.RunE)
+		assert.True(t, subcmd.HasExample())
+	}
+}
+
+// testStore returns a store with a conversation about a dentist appointment
+// and one about an invoice.
+func testStore(t *testing.T) *store {
+	t.Helper()
+	st := &store{dir: t.TempDir()}
+	sm := st.open()
+
+	sm.AddMessage("agent:main:main", "user", "Book the dentist appointment for Friday")
+	sm.AddFullMessage("agent:main:main", providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: &providers.FunctionCall{Name: "calendar_add", Arguments: `{"title":"Dentist"}`},
+		}},
+	})
+	sm.AddFullMessage("agent:main:main", providers.Message{Role: "tool", Content: "added", ToolCallID: "call_1"})
+	sm.AddMessage("agent:main:main", "assistant", "Booked for Friday at 10.")
+	sm.SetSummary("agent:main:main", "Dentist booked.")
+
+	sm.AddMessage("agent:main:telegram:direct:42", "user", "Did the invoice arrive?")
+
+	for _, key := range sm.Keys() {
+		require.NoError(t, sm.Save(key))
+	}
+	return st
+}
@@ -0,0 +1,39 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+func newDeleteCommand(st *store) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "delete <session>",
+		Short:   "Delete a session and its messages",
+		Long:    `Deletes the session file. Refused while the gateway runs.`,
+		Example: `picoclaw sessions delete agent:main:telegram:direct:42`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return deleteCmd(cmd.OutOrStdout(), st, args[0])
+		},
+	}
+
+	return cmd
+}
+
+func deleteCmd(w io.Writer, st *store, key string) error {
+	sm, err := st.openWritable()
+	if err != nil {
+		return err
+	}
+	ok, err := sm.Delete(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("session %q not found", key)
+	}
+	fmt.Fprintf(w, "✓ Deleted session %s\n", key)
+	return nil
+}
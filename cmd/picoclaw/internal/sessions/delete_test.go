@@ -0,0 +1,30 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, deleteCmd(&buf, st, "agent:main:telegram:direct:42"))
+	assert.Equal(t, "✓ Deleted session agent:main:telegram:direct:42\n", buf.String())
+	assert.Equal(t, []string{"agent:main:main"}, st.open().Keys())
+
+	assert.EqualError(t, deleteCmd(&buf, st, "agent:main:telegram:direct:42"),
+		`session "agent:main:telegram:direct:42" not found`)
+}
+
+func TestDeleteCmd_GatewayRunning(t *testing.T) {
+	st := testStore(t)
+	st.readOnly = true
+
+	var buf bytes.Buffer
+	assert.ErrorContains(t, deleteCmd(&buf, st, "agent:main:main"), "the gateway is running")
+	assert.Len(t, st.open().Keys(), 2)
+}
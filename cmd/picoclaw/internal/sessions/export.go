@@ -0,0 +1,151 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/transcript"
+)
+
+func newExportCommand(st *store) *cobra.Command {
+	var (
+		format, output string
+		from, to       int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export <session>",
+		Short: "Write a session out as JSON, Markdown or an HTML page",
+		Example: `picoclaw sessions export agent:main:main > main.json
+picoclaw sessions export agent:main:main --format markdown -o main.md
+picoclaw sessions export agent:main:main --format html --from 40 --to 52 -o dentist.html`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var buf bytes.Buffer
+			if err := exportCmd(&buf, st, args[0], format, from, to); err != nil {
+				return err
+			}
+			if output == "" {
+				_, err := cmd.OutOrStdout().Write(buf.Bytes())
+				return err
+			}
+			if err := os.WriteFile(output, buf.Bytes(), 0o600); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✓ Exported %s to %s\n", args[0], output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "json",
+		"json, the session file as stored, markdown, a transcript, or html, a page to share")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write to this file instead of standard output")
+	cmd.Flags().IntVar(&from, "from", 0, "first message to export, numbered as sessions show does")
+	cmd.Flags().IntVar(&to, "to", 0, "last message to export")
+
+	return cmd
+}
+
+func exportCmd(w io.Writer, st *store, key, format string, from, to int) error {
+	snap, err := snapshot(st.open(), key)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		if from != 0 || to != 0 {
+			return errors.New("--from and --to need the markdown or html format")
+		}
+		return writeJSON(w, snap)
+	case "markdown", "md", "html":
+		first, last, err := messageRange(len(snap.Messages), from, to)
+		if err != nil {
+			return err
+		}
+		if format == "html" {
+			return transcript.WriteHTML(w, snap, transcript.Options{
+				From:  first,
+				To:    last,
+				Times: messageTimes(st.traces, snap),
+			})
+		}
+		snap.Messages = snap.Messages[first-1 : last]
+		writeMarkdown(w, snap)
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, want json, markdown or html", format)
+}
+
+// messageRange returns the messages from and to select of n, numbered from
+// 1, with 0 for the first or last.
+func messageRange(n, from, to int) (int, int, error) {
+	first, last := max(from, 1), to
+	if last <= 0 || last > n {
+		last = n
+	}
+	if from < 0 || to < 0 || n > 0 && first > last {
+		return 0, 0, fmt.Errorf("no messages %d-%d in a session of %d", from, to, n)
+	}
+	return first, last, nil
+}
+
+// messageTimes returns when the user messages of s were sent, taken from
+// the traces of its turns. Both are matched from the end, since the oldest
+// traces and messages are the ones dropped. The first message of a topic
+// without a trace gets the time the topic was created.
+func messageTimes(dir string, s session.Session) map[int]time.Time {
+	times := make(map[int]time.Time)
+	if _, err := os.Stat(dir); err == nil {
+		if traces, err := memory.NewTraceLog(dir, 1); err == nil {
+			turns, _ := traces.Turns(context.Background(), s.Key)
+			next := len(turns) - 1
+			for i := len(s.Messages) - 1; i >= 0 && next >= 0; i-- {
+				if s.Messages[i].Role != "user" {
+					continue
+				}
+				for j := next; j >= 0; j-- {
+					if turns[j].UserMessage == s.Messages[i].Content {
+						times[i], next = turns[j].Started, j-1
+						break
+					}
+				}
+			}
+		}
+	}
+	for _, t := range s.Topics {
+		if _, ok := times[t.Start]; !ok && !t.Created.IsZero() {
+			times[t.Start] = t.Created
+		}
+	}
+	return times
+}
+
+// writeMarkdown writes s as a transcript: the summary, then each message
+// under the name of its role.
+func writeMarkdown(w io.Writer, s session.Session) {
+	fmt.Fprintf(w, "# %s\n\n", s.Key)
+	fmt.Fprintf(w, "Created %s, updated %s, %d messages.\n", s.Created.Local().Format(time.DateTime),
+		s.Updated.Local().Format(time.DateTime), len(s.Messages))
+	if s.Summary != "" {
+		fmt.Fprintf(w, "\n## Summary\n\n%s\n", strings.TrimSpace(s.Summary))
+	}
+	for _, m := range s.Messages {
+		fmt.Fprintf(w, "\n## %s\n", m.Role)
+		if m.Content != "" {
+			fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(m.Content))
+		}
+		for _, call := range toolCalls(m) {
+			fmt.Fprintf(w, "\n```\n%s\n```\n", call)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestExportCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, exportCmd(&buf, st, "agent:main:main", "json", 0, 0))
+	var s session.Session
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &s))
+	assert.Equal(t, "agent:main:main", s.Key)
+	assert.Len(t, s.Messages, 4)
+
+	buf.Reset()
+	require.NoError(t, exportCmd(&buf, st, "agent:main:main", "markdown", 0, 0))
+	out := buf.String()
+	assert.Contains(t, out, "# agent:main:main\n")
+	assert.Contains(t, out, "## Summary\n\nDentist booked.\n")
+	assert.Contains(t, out, "## user\n\nBook the dentist appointment for Friday\n")
+	assert.Contains(t, out, "```\ncalendar_add({\"title\":\"Dentist\"})\n```")
+
+	buf.Reset()
+	require.NoError(t, exportCmd(&buf, st, "agent:main:main", "markdown", 4, 0))
+	out = buf.String()
+	assert.Contains(t, out, "## assistant\n\nBooked for Friday at 10.\n")
+	assert.NotContains(t, out, "Book the dentist")
+
+	assert.Error(t, exportCmd(&buf, st, "agent:main:main", "pdf", 0, 0))
+	assert.Error(t, exportCmd(&buf, st, "agent:main:main", "json", 1, 2), "json is the whole session")
+	assert.Error(t, exportCmd(&buf, st, "agent:main:main", "markdown", 3, 2))
+}
+
+func TestExportCmd_HTML(t *testing.T) {
+	st := testStore(t)
+	st.traces = t.TempDir()
+	traces, err := memory.NewTraceLog(st.traces, 10)
+	require.NoError(t, err)
+	started := time.Date(2026, 3, 6, 9, 30, 0, 0, time.Local)
+	require.NoError(t, traces.Record(context.Background(), &memory.TurnTrace{
+		SessionKey:  "agent:main:main",
+		Started:     started,
+		UserMessage: "Book the dentist appointment for Friday",
+	}))
+
+	var buf bytes.Buffer
+	require.NoError(t, exportCmd(&buf, st, "agent:main:main", "html", 0, 0))
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "<!DOCTYPE html>"))
+	assert.Contains(t, out, "Book the dentist appointment for Friday")
+	assert.Contains(t, out, "#1 · 2026-03-06 09:30:00")
+	assert.Contains(t, out, "<summary>Called calendar_add</summary>")
+	assert.Contains(t, out, "<summary>Result of calendar_add</summary>")
+
+	buf.Reset()
+	require.NoError(t, exportCmd(&buf, st, "agent:main:main", "html", 4, 4))
+	out = buf.String()
+	assert.Contains(t, out, "showing messages 4-4")
+	assert.NotContains(t, out, "Book the dentist")
+}
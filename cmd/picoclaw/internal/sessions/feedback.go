@@ -0,0 +1,73 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newFeedbackCommand(st *store) *cobra.Command {
+	var (
+		low    bool
+		since  string
+		limit  int
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "feedback",
+		Short: "List the ratings and corrections users gave the answers",
+		Example: `picoclaw sessions feedback --low
+picoclaw sessions feedback --since 168h --json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			from, err := audit.ParseSince(since, time.Now())
+			if err != nil {
+				return err
+			}
+			filter := session.FeedbackFilter{Low: low, Since: from, Limit: limit}
+			return feedbackCmd(cmd.OutOrStdout(), st.open(), filter, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&low, "low", false, "only bad ratings and corrections")
+	cmd.Flags().StringVar(&since, "since", "", "only feedback since a time (RFC 3339) or for a duration (24h)")
+	cmd.Flags().IntVar(&limit, "limit", 50, "show at most this many, the latest first")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the feedback as JSON")
+
+	return cmd
+}
+
+func feedbackCmd(w io.Writer, sm *session.SessionManager, filter session.FeedbackFilter, asJSON bool) error {
+	feedback := sm.ListFeedback(filter)
+	if asJSON {
+		if feedback == nil {
+			feedback = []session.RatedAnswer{}
+		}
+		return writeJSON(w, feedback)
+	}
+	if len(feedback) == 0 {
+		fmt.Fprintln(w, "No feedback.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "GIVEN\tSESSION\tRATING\tANSWER\tCORRECTION")
+	for _, fb := range feedback {
+		rating := ""
+		switch fb.Rating {
+		case session.RatingGood:
+			rating = "good"
+		case session.RatingBad:
+			rating = "bad"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", fb.Created.Local().Format(time.DateTime), fb.Session, rating,
+			oneLine(fb.Answer, 60), oneLine(fb.Correction, 60))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,41 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestFeedbackCmd(t *testing.T) {
+	st := testStore(t)
+	sm := st.open()
+	sm.AddMessage("agent:main:telegram:direct:42", "assistant", "Yes, it is due on the 15th.")
+	require.True(t, sm.AddFeedback("agent:main:main", "", session.Feedback{Rating: session.RatingGood}))
+	require.True(t, sm.AddFeedback("agent:main:telegram:direct:42", "", session.Feedback{
+		Rating: session.RatingBad, Correction: "The invoice is due on the 30th.",
+	}))
+	require.NoError(t, sm.Save("agent:main:main"))
+	require.NoError(t, sm.Save("agent:main:telegram:direct:42"))
+
+	var buf bytes.Buffer
+	require.NoError(t, feedbackCmd(&buf, st.open(), session.FeedbackFilter{}, false))
+	out := buf.String()
+	assert.Contains(t, out, "good")
+	assert.Contains(t, out, "The invoice is due on the 30th.")
+
+	buf.Reset()
+	require.NoError(t, feedbackCmd(&buf, st.open(), session.FeedbackFilter{Low: true}, true))
+	var low []session.RatedAnswer
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &low))
+	require.Len(t, low, 1)
+	assert.Equal(t, "agent:main:telegram:direct:42", low[0].Session)
+
+	buf.Reset()
+	require.NoError(t, feedbackCmd(&buf, (&store{dir: t.TempDir()}).open(), session.FeedbackFilter{}, false))
+	assert.Equal(t, "No feedback.\n", buf.String())
+}
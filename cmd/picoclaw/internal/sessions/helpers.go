@@ -0,0 +1,77 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// store is where the sessions are kept.
+type store struct {
+	dir    string
+	key    string // the session.encryption.key, when the sessions are encrypted
+	traces string // the turn traces, which date the messages of an export
+	// readOnly is set while the gateway runs: it would write its copy of a
+	// changed session back over the change.
+	readOnly bool
+}
+
+// open loads the sessions. The key was checked by the command already, so
+// a store that cannot be opened is left empty.
+func (s *store) open() *session.SessionManager {
+	sm := session.NewSessionManager("")
+	if backend, err := session.NewStore(s.dir, s.key); err == nil {
+		sm.SetStore(backend)
+	}
+	return sm
+}
+
+// openWritable opens the store for a change, or fails while the gateway
+// runs.
+func (s *store) openWritable() (*session.SessionManager, error) {
+	if s.readOnly {
+		return nil, errors.New("the gateway is running and would undo the change; stop it first")
+	}
+	return s.open(), nil
+}
+
+// snapshot returns the session called key.
+func snapshot(sm *session.SessionManager, key string) (session.Session, error) {
+	snap, ok := sm.Snapshot(key)
+	if !ok {
+		return session.Session{}, fmt.Errorf("session %q not found", key)
+	}
+	return snap, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// toolCalls returns the tool calls of m as name(arguments).
+func toolCalls(m providers.Message) []string {
+	var calls []string
+	for _, tc := range m.ToolCalls {
+		name, args := tc.Name, ""
+		if tc.Function != nil {
+			name, args = tc.Function.Name, tc.Function.Arguments
+		} else if len(tc.Arguments) > 0 {
+			data, _ := json.Marshal(tc.Arguments)
+			args = string(data)
+		}
+		calls = append(calls, name+"("+args+")")
+	}
+	return calls
+}
+
+func oneLine(s string, n int) string {
+	return utils.Truncate(strings.Join(strings.Fields(s), " "), n)
+}
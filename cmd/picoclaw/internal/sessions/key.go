@@ -0,0 +1,30 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newKeyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "key",
+		Short: "Create a session encryption key",
+		Long: `Prints a new random key for session.encryption.key, ideally stored as a
+reference such as "keyring:sessions" (see picoclaw secret set). Keep a copy:
+the sessions cannot be read without it.`,
+		Example: `picoclaw sessions key`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return keyCmd(cmd.OutOrStdout())
+		},
+	}
+}
+
+func keyCmd(w io.Writer) error {
+	_, err := fmt.Fprintln(w, session.NewKey())
+	return err
+}
@@ -0,0 +1,20 @@
+package sessions
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestKeyCmd(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, keyCmd(&out))
+
+	_, err := session.NewEncryptedStore(t.TempDir(), strings.TrimSpace(out.String()))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,75 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// sessionInfo describes a session in the list, without its messages.
+type sessionInfo struct {
+	Key      string    `json:"key"`
+	Messages int       `json:"messages"`
+	Summary  string    `json:"summary,omitempty"`
+	Person   string    `json:"person,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+func newListCommand(st *store) *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List the sessions, most recently active first",
+		Example: `picoclaw sessions list`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return listCmd(cmd.OutOrStdout(), st.open(), asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the list as JSON")
+
+	return cmd
+}
+
+func listCmd(w io.Writer, sm *session.SessionManager, asJSON bool) error {
+	infos := []sessionInfo{}
+	for _, key := range sm.Keys() {
+		snap, ok := sm.Snapshot(key)
+		if !ok {
+			continue
+		}
+		infos = append(infos, sessionInfo{
+			Key:      key,
+			Messages: len(snap.Messages),
+			Summary:  snap.Summary,
+			Person:   snap.Person,
+			Created:  snap.Created,
+			Updated:  snap.Updated,
+		})
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Updated.After(infos[j].Updated) })
+
+	if asJSON {
+		return writeJSON(w, infos)
+	}
+	if len(infos) == 0 {
+		fmt.Fprintln(w, "No sessions.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tMESSAGES\tUPDATED\tSUMMARY")
+	for _, s := range infos {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", s.Key, s.Messages, s.Updated.Local().Format(time.DateTime),
+			oneLine(s.Summary, 60))
+	}
+	return tw.Flush()
+}
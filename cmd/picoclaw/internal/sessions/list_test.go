@@ -0,0 +1,53 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestListCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, listCmd(&buf, st.open(), false))
+	out := buf.String()
+	assert.Contains(t, out, "KEY")
+	assert.Contains(t, out, "agent:main:main")
+	assert.Contains(t, out, "Dentist booked.")
+	assert.Contains(t, out, "agent:main:telegram:direct:42")
+
+	buf.Reset()
+	require.NoError(t, listCmd(&buf, st.open(), true))
+	var infos []sessionInfo
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &infos))
+	require.Len(t, infos, 2)
+	assert.Equal(t, "agent:main:telegram:direct:42", infos[0].Key, "most recently active first")
+	assert.Equal(t, 4, infos[1].Messages)
+}
+
+func TestListCmd_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, listCmd(&buf, (&store{dir: t.TempDir()}).open(), false))
+	assert.Equal(t, "No sessions.\n", buf.String())
+}
+
+func TestListCmd_Encrypted(t *testing.T) {
+	st := &store{dir: t.TempDir(), key: session.NewKey()}
+	sm := st.open()
+	sm.AddMessage("agent:main:main", "user", "Book the dentist for Friday.")
+	require.NoError(t, sm.Save("agent:main:main"))
+
+	var buf bytes.Buffer
+	require.NoError(t, listCmd(&buf, st.open(), false))
+	assert.Contains(t, buf.String(), "agent:main:main")
+
+	buf.Reset()
+	require.NoError(t, listCmd(&buf, (&store{dir: st.dir}).open(), false))
+	assert.Equal(t, "No sessions.\n", buf.String(), "the encrypted sessions are not read as plain ones")
+}
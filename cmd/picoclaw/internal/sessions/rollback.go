@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newCheckpointsCommand(st *store) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "checkpoints <session>",
+		Short: "List the checkpoints a session can be rolled back to",
+		Long: `Lists the copies of a session's history taken before it was summarized,
+compressed or truncated, the newest first.`,
+		Example: `picoclaw sessions checkpoints agent:main:main`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return checkpointsCmd(cmd.OutOrStdout(), st.open(), args[0])
+		},
+	}
+
+	return cmd
+}
+
+func checkpointsCmd(w io.Writer, sm *session.SessionManager, key string) error {
+	if _, err := snapshot(sm, key); err != nil {
+		return err
+	}
+	checkpoints, err := sm.Checkpoints(key)
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		fmt.Fprintln(w, "No checkpoints.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tMESSAGES\tLABEL")
+	for _, cp := range slices.Backward(checkpoints) {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", cp.ID, cp.Created.Local().Format(time.DateTime), len(cp.Messages), cp.Label)
+	}
+	return tw.Flush()
+}
+
+func newRollbackCommand(st *store) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback <session> <checkpoint>",
+		Short: "Restore a session's history and summary from a checkpoint",
+		Long: `Restores the history and summary of a session as they were at a checkpoint
+listed by "picoclaw sessions checkpoints". The checkpoint is kept. Refused
+while the gateway runs.`,
+		Example: `picoclaw sessions rollback agent:main:main 3f9c2a7d1e5b8c04`,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rollbackCmd(cmd.OutOrStdout(), st, args[0], args[1])
+		},
+	}
+
+	return cmd
+}
+
+func rollbackCmd(w io.Writer, st *store, key, id string) error {
+	sm, err := st.openWritable()
+	if err != nil {
+		return err
+	}
+	if _, err := snapshot(sm, key); err != nil {
+		return err
+	}
+	if err := sm.Rollback(key, id); errors.Is(err, session.ErrCheckpointNotFound) {
+		return fmt.Errorf("session %s has no checkpoint %q", key, id)
+	} else if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "✓ Rolled %s back to checkpoint %s\n", key, id)
+	return nil
+}
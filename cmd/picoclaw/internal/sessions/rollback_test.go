@@ -0,0 +1,44 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, truncateCmd(&buf, st, "agent:main:main", 1))
+	require.Len(t, st.open().GetHistory("agent:main:main"), 1)
+
+	buf.Reset()
+	require.NoError(t, checkpointsCmd(&buf, st.open(), "agent:main:main"))
+	assert.Contains(t, buf.String(), "before truncate")
+	checkpoints, err := st.open().Checkpoints("agent:main:main")
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 1)
+
+	buf.Reset()
+	require.NoError(t, rollbackCmd(&buf, st, "agent:main:main", checkpoints[0].ID))
+	assert.Equal(t, "✓ Rolled agent:main:main back to checkpoint "+checkpoints[0].ID+"\n", buf.String())
+	assert.Len(t, st.open().GetHistory("agent:main:main"), 4)
+
+	assert.ErrorContains(t, rollbackCmd(&buf, st, "agent:main:main", "nope"), "has no checkpoint")
+	assert.Error(t, rollbackCmd(&buf, st, "nope", checkpoints[0].ID))
+
+	st.readOnly = true
+	assert.ErrorContains(t, rollbackCmd(&buf, st, "agent:main:main", checkpoints[0].ID), "the gateway is running")
+}
+
+func TestCheckpointsCmd_None(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, checkpointsCmd(&buf, st.open(), "agent:main:main"))
+	assert.Equal(t, "No checkpoints.\n", buf.String())
+	assert.Error(t, checkpointsCmd(&buf, st.open(), "nope"))
+}
@@ -0,0 +1,56 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newSearchCommand(st *store) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search the user and assistant messages of all sessions",
+		Example: `picoclaw sessions search "dentist appointment"
+picoclaw sessions search invoice --limit 50`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return searchCmd(cmd.OutOrStdout(), st.open(), strings.Join(args, " "), limit)
+		},
+	}
+
+	cmd.Flags().IntVar(&limit, "limit", 20, "show at most this many messages")
+
+	return cmd
+}
+
+func searchCmd(w io.Writer, sm *session.SessionManager, query string, limit int) error {
+	terms := memory.SearchTerms(query)
+	if len(terms) == 0 {
+		return errors.New("the query has no words to search for")
+	}
+	matches := sm.SearchMessages(
+		func(*session.Session) bool { return true },
+		func(content string) float64 { return memory.KeywordScore(terms, content) },
+		limit,
+	)
+	if len(matches) == 0 {
+		fmt.Fprintf(w, "No messages match %q.\n", query)
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SESSION\tUPDATED\tROLE\tMESSAGE")
+	for _, m := range matches {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.SessionKey, m.Updated.Local().Format("2006-01-02"), m.Role,
+			oneLine(m.Content, 80))
+	}
+	return tw.Flush()
+}
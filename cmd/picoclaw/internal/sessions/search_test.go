@@ -0,0 +1,26 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, searchCmd(&buf, st.open(), "dentist friday", 10))
+	out := buf.String()
+	assert.Contains(t, out, "agent:main:main")
+	assert.Contains(t, out, "Book the dentist appointment for Friday")
+	assert.NotContains(t, out, "invoice")
+
+	buf.Reset()
+	require.NoError(t, searchCmd(&buf, st.open(), "holiday", 10))
+	assert.Equal(t, "No messages match \"holiday\".\n", buf.String())
+
+	assert.Error(t, searchCmd(&buf, st.open(), "?!", 10))
+}
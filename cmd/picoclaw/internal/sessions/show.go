@@ -0,0 +1,101 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newShowCommand(st *store) *cobra.Command {
+	var (
+		last   int
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "show <session>",
+		Short: "Show the summary and messages of a session",
+		Example: `picoclaw sessions show agent:main:main
+picoclaw sessions show agent:main:telegram:direct:42 --last 0`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showCmd(cmd.OutOrStdout(), st.open(), args[0], last, asJSON)
+		},
+	}
+
+	cmd.Flags().IntVarP(&last, "last", "n", 20, "show only the last n messages; 0 shows all")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the whole session as JSON")
+
+	return cmd
+}
+
+func showCmd(w io.Writer, sm *session.SessionManager, key string, last int, asJSON bool) error {
+	snap, err := snapshot(sm, key)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeJSON(w, snap)
+	}
+
+	fmt.Fprintf(w, "Session %s\n", snap.Key)
+	fmt.Fprintf(w, "Created %s, updated %s, %d messages\n", snap.Created.Local().Format(time.DateTime),
+		snap.Updated.Local().Format(time.DateTime), len(snap.Messages))
+	if snap.Person != "" {
+		fmt.Fprintf(w, "Person %s\n", snap.Person)
+	}
+	if snap.Persona != "" {
+		fmt.Fprintf(w, "Persona %s\n", snap.Persona)
+	}
+	if len(snap.PII) > 0 {
+		var kinds []string
+		for _, kind := range slices.Sorted(maps.Keys(snap.PII)) {
+			kinds = append(kinds, fmt.Sprintf("%d %s", snap.PII[kind], kind))
+		}
+		fmt.Fprintf(w, "Personal data %s\n", strings.Join(kinds, ", "))
+	}
+	if snap.Summary != "" {
+		fmt.Fprintf(w, "\nSummary:\n%s\n", indent(snap.Summary))
+	}
+
+	topics := sm.ListTopics(key)
+	if len(topics) > 0 {
+		fmt.Fprintln(w, "\nTopics:")
+		for i, t := range topics {
+			fmt.Fprintf(w, "  %d. %s (messages %d-%d)\n", i+1, t.Title, t.Start+1, t.End)
+		}
+	}
+
+	messages, first := snap.Messages, 0
+	if last > 0 && len(messages) > last {
+		first = len(messages) - last
+		fmt.Fprintf(w, "\n(%d earlier messages; --last 0 shows them)\n", first)
+		messages = messages[first:]
+	}
+	for i, m := range messages {
+		for _, t := range topics {
+			if t.Start == first+i {
+				fmt.Fprintf(w, "\n== %s ==\n", t.Title)
+			}
+		}
+		fmt.Fprintf(w, "\n[%s]\n", m.Role)
+		if m.Content != "" {
+			fmt.Fprintln(w, indent(m.Content))
+		}
+		for _, call := range toolCalls(m) {
+			fmt.Fprintf(w, "  -> %s\n", oneLine(call, 200))
+		}
+	}
+	return nil
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
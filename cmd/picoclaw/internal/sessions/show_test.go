@@ -0,0 +1,54 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, showCmd(&buf, st.open(), "agent:main:main", 0, false))
+	out := buf.String()
+	assert.Contains(t, out, "Session agent:main:main")
+	assert.Contains(t, out, "4 messages")
+	assert.Contains(t, out, "Summary:\n  Dentist booked.")
+	assert.Contains(t, out, "[user]\n  Book the dentist appointment for Friday")
+	assert.Contains(t, out, `-> calendar_add({"title":"Dentist"})`)
+
+	buf.Reset()
+	require.NoError(t, showCmd(&buf, st.open(), "agent:main:main", 1, false))
+	assert.Contains(t, buf.String(), "(3 earlier messages; --last 0 shows them)")
+	assert.NotContains(t, buf.String(), "Book the dentist")
+
+	assert.EqualError(t, showCmd(&buf, st.open(), "nope", 0, false), `session "nope" not found`)
+}
+
+func TestShowCmd_Topics(t *testing.T) {
+	st := testStore(t)
+	sm := st.open()
+	require.True(t, sm.AddTopic("agent:main:main", 0, "Dentist appointment"))
+	require.NoError(t, sm.Save("agent:main:main"))
+
+	var buf bytes.Buffer
+	require.NoError(t, showCmd(&buf, st.open(), "agent:main:main", 0, false))
+	assert.Contains(t, buf.String(), "Topics:\n  1. Dentist appointment (messages 1-4)")
+	assert.Contains(t, buf.String(), "== Dentist appointment ==\n\n[user]\n  Book the dentist")
+}
+
+func TestShowCmd_PII(t *testing.T) {
+	st := testStore(t)
+	sm := st.open()
+	snap, ok := sm.Snapshot("agent:main:main")
+	require.True(t, ok)
+	snap.PII = map[string]int{"phone": 1, "email": 2}
+	require.NoError(t, sm.Put(snap))
+
+	var buf bytes.Buffer
+	require.NoError(t, showCmd(&buf, st.open(), "agent:main:main", 0, false))
+	assert.Contains(t, buf.String(), "Personal data 2 email, 1 phone\n")
+}
@@ -0,0 +1,54 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newStatsCommand(st *store) *cobra.Command {
+	var compact, asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show the disk space the sessions take",
+		Long: `Measures the session files and the stale ones no session uses: temporary
+files of saves cut short by a crash or power loss, and checkpoints of
+deleted sessions. --compact removes the stale files; the gateway does the
+same on its own once session.compact_threshold messages were dropped.`,
+		Example: `picoclaw sessions stats --compact`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return statsCmd(cmd.OutOrStdout(), st.open(), compact, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&compact, "compact", false, "remove the stale files first")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the stats as JSON")
+
+	return cmd
+}
+
+func statsCmd(w io.Writer, sm *session.SessionManager, compact, asJSON bool) error {
+	if compact {
+		if _, err := sm.Compact(); err != nil {
+			return err
+		}
+	}
+	stats, err := sm.Stats()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeJSON(w, stats)
+	}
+	fmt.Fprintf(w, "Sessions:    %d (%d bytes)\n", stats.Sessions, stats.Bytes)
+	fmt.Fprintf(w, "Stale files: %d (%d bytes)\n", stats.StaleFiles, stats.StaleBytes)
+	if compact {
+		fmt.Fprintf(w, "Reclaimed:   %d bytes\n", stats.Reclaimed)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package sessions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCmd(t *testing.T) {
+	st := testStore(t)
+	stale := filepath.Join(st.dir, "session-1.tmp")
+	require.NoError(t, os.WriteFile(stale, []byte("left over"), 0o600))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(stale, old, old))
+
+	var buf bytes.Buffer
+	require.NoError(t, statsCmd(&buf, st.open(), false, false))
+	assert.Contains(t, buf.String(), "Sessions:    2 (")
+	assert.Contains(t, buf.String(), "Stale files: 1 (9 bytes)")
+
+	buf.Reset()
+	require.NoError(t, statsCmd(&buf, st.open(), true, false))
+	assert.Contains(t, buf.String(), "Stale files: 0 (0 bytes)")
+	assert.Contains(t, buf.String(), "Reclaimed:   9 bytes")
+	assert.NoFileExists(t, stale)
+
+	buf.Reset()
+	require.NoError(t, statsCmd(&buf, st.open(), false, true))
+	assert.Contains(t, buf.String(), `"sessions": 2`)
+}
@@ -0,0 +1,55 @@
+package sessions
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+func newTruncateCommand(st *store) *cobra.Command {
+	var keep int
+
+	cmd := &cobra.Command{
+		Use:   "truncate <session>",
+		Short: "Drop all but the last messages of a session",
+		Long: `Drops the older messages of a session and keeps its summary. A checkpoint
+is taken first, to undo it with "picoclaw sessions rollback". Refused while
+the gateway runs.`,
+		Example: `picoclaw sessions truncate agent:main:main --keep 10`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return truncateCmd(cmd.OutOrStdout(), st, args[0], keep)
+		},
+	}
+
+	cmd.Flags().IntVar(&keep, "keep", 0, "how many of the latest messages to keep; 0 drops all")
+	_ = cmd.MarkFlagRequired("keep")
+
+	return cmd
+}
+
+func truncateCmd(w io.Writer, st *store, key string, keep int) error {
+	if keep < 0 {
+		return fmt.Errorf("--keep must not be negative, got %d", keep)
+	}
+	sm, err := st.openWritable()
+	if err != nil {
+		return err
+	}
+	snap, err := snapshot(sm, key)
+	if err != nil {
+		return err
+	}
+	id, err := sm.Checkpoint(key, "before truncate")
+	if err != nil {
+		return err
+	}
+	sm.TruncateHistory(key, keep)
+	if err := sm.Save(key); err != nil {
+		return err
+	}
+	kept := min(keep, len(snap.Messages))
+	fmt.Fprintf(w, "✓ Kept the last %d of %d messages of %s (checkpoint %s)\n", kept, len(snap.Messages), key, id)
+	return nil
+}
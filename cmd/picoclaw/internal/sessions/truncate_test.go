@@ -0,0 +1,34 @@
+package sessions
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateCmd(t *testing.T) {
+	st := testStore(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, truncateCmd(&buf, st, "agent:main:main", 1))
+	assert.Regexp(t, `^✓ Kept the last 1 of 4 messages of agent:main:main \(checkpoint [0-9a-f]+\)\n$`, buf.String())
+
+	history := st.open().GetHistory("agent:main:main")
+	require.Len(t, history, 1)
+	assert.Equal(t, "Booked for Friday at 10.", history[0].Content)
+	assert.Equal(t, "Dentist booked.", st.open().GetSummary("agent:main:main"))
+
+	assert.Error(t, truncateCmd(&buf, st, "agent:main:main", -1))
+	assert.Error(t, truncateCmd(&buf, st, "nope", 1))
+}
+
+func TestTruncateCmd_GatewayRunning(t *testing.T) {
+	st := testStore(t)
+	st.readOnly = true
+
+	var buf bytes.Buffer
+	assert.ErrorContains(t, truncateCmd(&buf, st, "agent:main:main", 0), "the gateway is running")
+	assert.Len(t, st.open().GetHistory("agent:main:main"), 4)
+}
@@ -269,6 +269,27 @@ func skillsShowCmd(loader *skills.SkillsLoader, skillName string) {
 	fmt.Printf("\n📦 Skill: %s\n", skillName)
 	fmt.Println("----------------------")
 	fmt.Println(content)
+
+	var tools []skills.ToolSpec
+	for _, t := range loader.ListTools() {
+		if t.Skill == skillName {
+			tools = append(tools, t)
+		}
+	}
+	if len(tools) > 0 {
+		fmt.Println("Tools (commands need tools.skill_tools on, wasm modules tools.wasm):")
+		for _, t := range tools {
+			if t.Wasm != "" {
+				caps := "no capabilities"
+				if len(t.Capabilities) > 0 {
+					caps = strings.Join(t.Capabilities, ", ")
+				}
+				fmt.Printf("  %s: %s\n    runs %s in the wasm sandbox (%s)\n", t.FullName(), t.Description, t.Wasm, caps)
+				continue
+			}
+			fmt.Printf("  %s: %s\n    runs %s\n", t.FullName(), t.Description, strings.Join(t.Command, " "))
+		}
+	}
 }
 
 func copyDirectory(src, dst string) error {
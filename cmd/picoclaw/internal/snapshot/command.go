@@ -0,0 +1,27 @@
+package snapshot
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewSnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Back up this instance to one file, or restore it",
+		Long: `Writes the config, the stored credentials, the secret files, the workspace
+with its memory, sessions and notes, and the logs into one zip file, and
+restores such a file on this or another device. Use it to move picoclaw to a
+new board or to recover it after losing one.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newCreateCommand(),
+		newRestoreCommand(),
+	)
+
+	return cmd
+}
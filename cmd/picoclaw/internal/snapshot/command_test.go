@@ -0,0 +1,29 @@
+package snapshot
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSnapshotCommand(t *testing.T) {
+	cmd := NewSnapshotCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "snapshot", cmd.Use)
+	assert.NotNil(t, cmd.RunE)
+
+	allowedCommands := []string{"create", "restore"}
+
+	subcommands := cmd.Commands()
+	assert.Len(t, subcommands, len(allowedCommands))
+
+	for _, subcmd := range subcommands {
+		assert.True(t, slices.Contains(allowedCommands, subcmd.Name()), "unexpected subcommand %q", subcmd.Name())
+		assert.NotNil(t, subcmd.RunE)
+		assert.True(t, subcmd.HasExample())
+	}
+}
@@ -0,0 +1,73 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/snapshot"
+)
+
+func newCreateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "create [file]",
+		Short: "Write a snapshot of this instance",
+		Long: `Writes a snapshot to file, by default picoclaw-snapshot-<time>.zip in the
+current directory. It can be taken while the gateway runs. The snapshot holds
+the credentials in the config, so keep it somewhere safe.`,
+		Example: `picoclaw snapshot create
+picoclaw snapshot create /mnt/usb/board.zip`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			path := fmt.Sprintf("picoclaw-snapshot-%s.zip", time.Now().Format("20060102-150405"))
+			if len(args) == 1 {
+				path = args[0]
+			}
+			return createCmd(cmd.OutOrStdout(), cfg, path)
+		},
+	}
+}
+
+func createCmd(w io.Writer, cfg *config.Config, path string) error {
+	layout := internal.SnapshotLayout(cfg)
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(layout.Workspace, abs); err == nil && filepath.IsLocal(rel) {
+		return errors.New("write the snapshot outside the workspace, which it holds")
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(abs), ".picoclaw-snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	m, err := snapshot.Write(f, layout, internal.FormatVersion())
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), abs); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "✓ Snapshot written to %s (%d files, %d KB)\n", path, len(m.Files), (m.Size()+1023)/1024)
+	fmt.Fprintln(w, "  It holds the credentials in the config; keep it somewhere safe.")
+	fmt.Fprintln(w, "  Secrets in the OS keyring are not included.")
+	return nil
+}
@@ -0,0 +1,46 @@
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// testInstance makes a picoclaw home with a config, whose workspace holds
+// one note, and returns the config.
+func testInstance(t *testing.T) *config.Config {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("PICOCLAW_HOME", home)
+	t.Setenv("PICOCLAW_CONFIG", "")
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = filepath.Join(home, "workspace")
+	cfg.Gateway.Port = 1 // nothing answers, so the gateway counts as stopped
+	require.NoError(t, config.SaveConfig(filepath.Join(home, "config.json"), cfg))
+	require.NoError(t, os.MkdirAll(filepath.Join(cfg.WorkspacePath(), "notes"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(cfg.WorkspacePath(), "notes", "todo.md"), []byte("- call Anna\n"), 0o600))
+	return cfg
+}
+
+func TestCreateCmd(t *testing.T) {
+	cfg := testInstance(t)
+	path := filepath.Join(t.TempDir(), "snap.zip")
+
+	var out bytes.Buffer
+	require.NoError(t, createCmd(&out, cfg, path))
+	assert.Contains(t, out.String(), "Snapshot written to "+path+" (2 files")
+	assert.FileExists(t, path)
+}
+
+func TestCreateCmd_InsideWorkspace(t *testing.T) {
+	cfg := testInstance(t)
+
+	err := createCmd(&bytes.Buffer{}, cfg, filepath.Join(cfg.WorkspacePath(), "snap.zip"))
+	assert.ErrorContains(t, err, "outside the workspace")
+}
@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/snapshot"
+)
+
+func newRestoreCommand() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore a snapshot on this device",
+		Long: `Puts the config, credentials, secret files, workspace and logs of a
+snapshot in place. The workspace goes where the restored config puts it.
+Every file is checked before any is restored, so a damaged snapshot changes
+nothing. Stop the gateway first.
+
+State that is already here is only replaced with --force, and is then kept
+beside it as <path>.before-restore-<time>.`,
+		Example: `picoclaw snapshot restore picoclaw-snapshot-20260102-150405.zip
+picoclaw snapshot restore --force /mnt/usb/board.zip`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cur, err := internal.LoadConfig()
+			if err != nil {
+				// The config may be what is broken; the defaults still tell
+				// where to look for a gateway.
+				cur = config.DefaultConfig()
+			}
+			if internal.GatewayRunning(cur) {
+				return errors.New("the gateway is running; stop it before restoring")
+			}
+			return restoreCmd(cmd.OutOrStdout(), cur, args[0], force)
+		},
+	}
+
+	cmd.Flags().BoolVar(&force, "force", false, "replace the state already on this device")
+
+	return cmd
+}
+
+func restoreCmd(w io.Writer, cur *config.Config, path string, force bool) error {
+	s, err := snapshot.Open(path)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	target := cur
+	if s.Has(snapshot.PartConfig) {
+		data, err := s.ReadFile(snapshot.PartConfig)
+		if err != nil {
+			return err
+		}
+		target = config.DefaultConfig()
+		if err := json.Unmarshal(data, target); err != nil {
+			return fmt.Errorf("the config in the snapshot is not valid JSON: %w", err)
+		}
+	}
+	layout := internal.SnapshotLayout(target)
+
+	if !force {
+		for _, p := range []string{layout.Config, layout.Workspace} {
+			if exists(p) {
+				return fmt.Errorf("%s already exists; pass --force to replace it (it is kept beside it)", p)
+			}
+		}
+	}
+	kept, err := s.Restore(layout)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "✓ Restored the snapshot of %s from %s (%d files)\n",
+		s.Host, s.Created.Format("2006-01-02 15:04"), len(s.Files))
+	fmt.Fprintf(w, "  Config:    %s\n", layout.Config)
+	fmt.Fprintf(w, "  Workspace: %s\n", layout.Workspace)
+	for _, p := range kept {
+		fmt.Fprintf(w, "  Kept the previous state in %s\n", p)
+	}
+	if _, err := config.LoadConfig(layout.Config); err != nil {
+		fmt.Fprintf(w, "⚠ The restored config does not load on this device: %v\n", err)
+		fmt.Fprintln(w, "  Secrets in the OS keyring are not part of a snapshot; set them again with picoclaw secret set.")
+	}
+	return nil
+}
+
+// exists reports whether there is a file at path, or a directory with
+// anything in it.
+func exists(path string) bool {
+	entries, err := os.ReadDir(path)
+	if err == nil {
+		return len(entries) > 0
+	}
+	_, err = os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
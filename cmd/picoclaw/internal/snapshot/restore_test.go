@@ -0,0 +1,40 @@
+package snapshot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestRestoreCmd(t *testing.T) {
+	old := testInstance(t)
+	path := filepath.Join(t.TempDir(), "snap.zip")
+	require.NoError(t, createCmd(&bytes.Buffer{}, old, path))
+
+	// The old device is lost; the new one keeps its config elsewhere.
+	require.NoError(t, os.RemoveAll(old.WorkspacePath()))
+	home := t.TempDir()
+	t.Setenv("PICOCLAW_HOME", home)
+
+	var out bytes.Buffer
+	require.NoError(t, restoreCmd(&out, config.DefaultConfig(), path, false))
+	assert.Contains(t, out.String(), "Restored the snapshot of")
+	data, err := os.ReadFile(filepath.Join(old.WorkspacePath(), "notes", "todo.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "- call Anna\n", string(data))
+	assert.FileExists(t, filepath.Join(home, "config.json"))
+
+	// Again, over the state just restored.
+	err = restoreCmd(&bytes.Buffer{}, config.DefaultConfig(), path, false)
+	assert.ErrorContains(t, err, "pass --force")
+
+	out.Reset()
+	require.NoError(t, restoreCmd(&out, config.DefaultConfig(), path, true))
+	assert.Contains(t, out.String(), "Kept the previous state in "+filepath.Join(home, "config.json")+".before-restore-")
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/outbox"
 )
 
 func statusCmd() {
@@ -83,6 +84,14 @@ func statusCmd() {
 			fmt.Println("Ollama: not set")
 		}
 
+		if cfg.Outbox.Enabled {
+			if pending, failed, err := outbox.Count(cfg.OutboxDir()); err != nil {
+				fmt.Println("Outbox: ✗", err)
+			} else {
+				fmt.Printf("Outbox: %d pending, %d failed\n", pending, failed)
+			}
+		}
+
 		store, _ := auth.LoadStore()
 		if store != nil && len(store.Credentials) > 0 {
 			fmt.Println("\nOAuth/Token Auth:")
@@ -0,0 +1,29 @@
+package sync
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Keep sessions and facts in sync with another instance",
+		Long: `Syncs the sessions and facts of the default agent with a second picoclaw,
+for example on a laptop and on a board at home, over an exchange encrypted
+with a shared key. Both instances set sync.enabled and the same sync.key; the
+one that sets sync.peer to the other's gateway URL syncs every
+sync.interval_seconds while its gateway runs.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(
+		newKeyCommand(),
+		newNowCommand(),
+		newStatusCommand(),
+	)
+
+	return cmd
+}
@@ -0,0 +1,42 @@
+package sync
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewSyncCommand(t *testing.T) {
+	cmd := NewSyncCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "sync", cmd.Use)
+	assert.NotNil(t, cmd.RunE)
+
+	allowedCommands := []string{"key", "now", "status"}
+
+	subcommands := cmd.Commands()
+	assert.Len(t, subcommands, len(allowedCommands))
+
+	for _, subcmd := range subcommands {
+		assert.True(t, slices.Contains(allowedCommands, subcmd.Name()), "unexpected subcommand %q", subcmd.Name())
+		assert.NotNil(t, subcmd.RunE)
+		assert.True(t, subcmd.HasExample())
+	}
+}
+
+// testConfig returns a config with sync on and its own workspace.
+func testConfig(t *testing.T, key string) *config.Config {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Sync.Enabled = true
+	cfg.Sync.Key = key
+	cfg.Gateway.Port = 1 // nothing answers, so the gateway counts as stopped
+	return cfg
+}
@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/memsync"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func loadConfig() (*config.Config, error) {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+	if !cfg.Sync.Enabled {
+		return nil, errors.New("sync is off; set sync.enabled and sync.key in the config")
+	}
+	return cfg, nil
+}
+
+func syncDir(cfg *config.Config) string {
+	return filepath.Join(cfg.WorkspacePath(), "sync")
+}
+
+// openSyncer opens the stores of the workspace like the gateway does.
+func openSyncer(cfg *config.Config) (*memsync.Syncer, error) {
+	workspace := cfg.WorkspacePath()
+	sessions, err := session.OpenManager(filepath.Join(workspace, "sessions"), cfg.SessionKey())
+	if err != nil {
+		return nil, err
+	}
+	stores := []memsync.Store{memsync.Sessions(sessions)}
+	if cfg.Tools.IsToolEnabled("memory") {
+		facts, err := memory.NewFactStore(filepath.Join(workspace, "memory"))
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, memsync.Facts(facts))
+	}
+	return memsync.New(syncDir(cfg), cfg.Sync.Key, stores...)
+}
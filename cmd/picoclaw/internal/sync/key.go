@@ -0,0 +1,29 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/memsync"
+)
+
+func newKeyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "key",
+		Short: "Create a shared sync key",
+		Long: `Prints a new random key. Put the same key in sync.key on both instances,
+ideally as a reference such as "keyring:sync" (see picoclaw secret set).`,
+		Example: `picoclaw sync key`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return keyCmd(cmd.OutOrStdout())
+		},
+	}
+}
+
+func keyCmd(w io.Writer) error {
+	_, err := fmt.Fprintln(w, memsync.NewKey())
+	return err
+}
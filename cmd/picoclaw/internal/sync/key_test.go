@@ -0,0 +1,20 @@
+package sync
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memsync"
+)
+
+func TestKeyCmd(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, keyCmd(&out))
+
+	_, err := memsync.ParseKey(strings.TrimSpace(out.String()))
+	assert.NoError(t, err)
+}
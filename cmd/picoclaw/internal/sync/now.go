@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newNowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "now",
+		Short: "Sync with the peer once",
+		Long: `Exchanges changes with the gateway at sync.peer right away. While the
+gateway of this instance runs it syncs on its own, so this refuses to run.`,
+		Example: `picoclaw sync now`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			return nowCmd(cmd.Context(), cmd.OutOrStdout(), cfg)
+		},
+	}
+}
+
+func nowCmd(ctx context.Context, w io.Writer, cfg *config.Config) error {
+	if cfg.Sync.Peer == "" {
+		return errors.New("sync.peer is not set; run this on the instance that syncs with the other")
+	}
+	if internal.GatewayRunning(cfg) {
+		return fmt.Errorf("the gateway is running and syncs every %ds; stop it to sync from here",
+			cfg.Sync.IntervalSeconds)
+	}
+	s, err := openSyncer(cfg)
+	if err != nil {
+		return err
+	}
+	res, err := s.Exchange(ctx, cfg.Sync.Peer)
+	if err != nil {
+		return fmt.Errorf("sync with %s failed: %w", cfg.Sync.Peer, err)
+	}
+	fmt.Fprintf(w, "✓ Synced with %s: sent %d and received %d changes\n", cfg.Sync.Peer, res.Sent, res.Received)
+	if res.Conflicts > 0 {
+		fmt.Fprintf(w, "⚠ %d items were changed on both sides; the older versions are in %s\n",
+			res.Conflicts, filepath.Join(syncDir(cfg), "conflicts"))
+	}
+	return nil
+}
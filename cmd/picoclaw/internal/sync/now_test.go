@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memsync"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestNowCmd(t *testing.T) {
+	key := memsync.NewKey()
+	board := session.NewSessionManager(filepath.Join(t.TempDir(), "sessions"))
+	boardSyncer, err := memsync.New(filepath.Join(t.TempDir(), "sync"), key, memsync.Sessions(board))
+	require.NoError(t, err)
+	srv := httptest.NewServer(boardSyncer)
+	defer srv.Close()
+
+	cfg := testConfig(t, key)
+	cfg.Sync.Peer = srv.URL
+	laptop := session.NewSessionManager(filepath.Join(cfg.WorkspacePath(), "sessions"))
+	laptop.AddMessage("agent:main:main", "user", "Book the dentist for Friday")
+	require.NoError(t, laptop.Save("agent:main:main"))
+
+	var out bytes.Buffer
+	require.NoError(t, nowCmd(context.Background(), &out, cfg))
+	assert.Contains(t, out.String(), "sent 1 and received 0 changes")
+	assert.Len(t, board.GetHistory("agent:main:main"), 1)
+}
+
+func TestNowCmd_NoPeer(t *testing.T) {
+	err := nowCmd(context.Background(), &bytes.Buffer{}, testConfig(t, memsync.NewKey()))
+	assert.ErrorContains(t, err, "sync.peer is not set")
+}
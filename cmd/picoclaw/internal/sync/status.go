@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newStatusCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the sync state",
+		Long: `Shows the ID of this instance, the peer and the last sync. Pending counts
+the changes recorded at the last sync that the peer has not got yet.`,
+		Example: `picoclaw sync status`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			return statusCmd(cmd.OutOrStdout(), cfg)
+		},
+	}
+}
+
+func statusCmd(w io.Writer, cfg *config.Config) error {
+	s, err := openSyncer(cfg)
+	if err != nil {
+		return err
+	}
+	st, err := s.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Device:    %s\n", st.Device)
+	peer := cfg.Sync.Peer
+	if peer == "" {
+		peer = "(answers the other instance)"
+	}
+	fmt.Fprintf(w, "Peer:      %s\n", peer)
+	if st.Peer == "" {
+		fmt.Fprintln(w, "Last sync: never")
+	} else {
+		fmt.Fprintf(w, "Last sync: %s with device %s\n", st.LastSync.Format(time.DateTime), st.Peer)
+	}
+	fmt.Fprintf(w, "Pending:   %d changes\n", st.Pending)
+	if st.Conflicts > 0 {
+		fmt.Fprintf(w, "Conflicts: %d older versions in %s\n", st.Conflicts, filepath.Join(syncDir(cfg), "conflicts"))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package sync
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memsync"
+)
+
+func TestStatusCmd(t *testing.T) {
+	cfg := testConfig(t, memsync.NewKey())
+
+	var out bytes.Buffer
+	require.NoError(t, statusCmd(&out, cfg))
+	assert.Contains(t, out.String(), "Peer:      (answers the other instance)")
+	assert.Contains(t, out.String(), "Last sync: never")
+	assert.Contains(t, out.String(), "Pending:   0 changes")
+}
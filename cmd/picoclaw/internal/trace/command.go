@@ -0,0 +1,29 @@
+package trace
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewTraceCommand() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "trace <session> [turn]",
+		Short: "Show how the agent handled recent turns of a session",
+		Example: `picoclaw trace agent:main:main
+picoclaw trace agent:main:main last
+picoclaw trace agent:main:telegram:direct:42 7 --json`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			turn := ""
+			if len(args) > 1 {
+				turn = args[1]
+			}
+			return traceCmd(cmd.Context(), cmd.OutOrStdout(), args[0], turn, asJSON)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the trace as JSON, without shortening anything")
+
+	return cmd
+}
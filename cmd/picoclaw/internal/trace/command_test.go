@@ -0,0 +1,61 @@
+package trace
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestNewTraceCommand(t *testing.T) {
+	cmd := NewTraceCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "trace <session> [turn]", cmd.Use)
+	assert.False(t, cmd.HasSubCommands())
+	assert.NotNil(t, cmd.RunE)
+	assert.True(t, cmd.HasExample())
+	assert.NotNil(t, cmd.Flags().Lookup("json"))
+}
+
+func TestPrintTrace(t *testing.T) {
+	tr := &memory.TurnTrace{
+		Turn:        3,
+		SessionKey:  "agent:main:main",
+		Started:     time.Now(),
+		DurationMS:  1500,
+		UserMessage: "what is in a.md?",
+		Prompt: []providers.Message{
+			{Role: "system", Content: "You are picoclaw.\n\nBe brief."},
+			{Role: "user", Content: "what is in a.md?"},
+		},
+		LLMCalls: []memory.LLMCallTrace{
+			{Iteration: 1, Model: "gpt4", Messages: 2, ToolCalls: []string{"read_file"}},
+			{Iteration: 2, Model: "gpt4", Messages: 4, Content: "It lists three tasks."},
+		},
+		ToolCalls: []memory.ToolCallTrace{
+			{Iteration: 1, Name: "read_file", Arguments: map[string]any{"path": "a.md"}, Result: "- one\n- two\n- three"},
+		},
+		Response: "It lists three tasks.",
+	}
+
+	var buf bytes.Buffer
+	printTrace(&buf, tr)
+	out := buf.String()
+
+	assert.Contains(t, out, "Turn 3 of agent:main:main")
+	assert.Contains(t, out, "[system] You are picoclaw. Be brief.")
+	assert.Contains(t, out, `tool read_file, 0s: {"path":"a.md"}`)
+	assert.Contains(t, out, "-> - one - two - three")
+	assert.Contains(t, out, "Response: It lists three tasks.")
+
+	buf.Reset()
+	printTurns(&buf, []memory.TurnTrace{*tr})
+	assert.Contains(t, buf.String(), "what is in a.md?")
+}
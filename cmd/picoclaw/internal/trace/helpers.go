@@ -0,0 +1,145 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// previewLen caps each message, result and reply in the text output.
+const previewLen = 300
+
+func traceCmd(ctx context.Context, w io.Writer, sessionKey, turn string, asJSON bool) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+	traces, err := memory.NewTraceLog(filepath.Join(cfg.WorkspacePath(), "traces"), cfg.Agents.Defaults.Traces.Keep)
+	if err != nil {
+		return err
+	}
+
+	if turn == "" {
+		turns, err := traces.Turns(ctx, sessionKey)
+		if err != nil {
+			return err
+		}
+		if len(turns) == 0 {
+			return fmt.Errorf("no traces for session %q (is agents.defaults.traces enabled?)", sessionKey)
+		}
+		if asJSON {
+			return writeJSON(w, turns)
+		}
+		printTurns(w, turns)
+		return nil
+	}
+
+	n := 0
+	if turn != "last" {
+		if n, err = strconv.Atoi(turn); err != nil || n < 1 {
+			return fmt.Errorf(`turn must be a positive number or "last", got %q`, turn)
+		}
+	}
+	tr, err := traces.Turn(ctx, sessionKey, n)
+	if errors.Is(err, memory.ErrTraceNotFound) {
+		return fmt.Errorf("no trace of turn %s in session %q", turn, sessionKey)
+	}
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		return writeJSON(w, tr)
+	}
+	printTrace(w, tr)
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTurns lists the traced turns of a session, one per line.
+func printTurns(w io.Writer, turns []memory.TurnTrace) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TURN\tSTARTED\tDURATION\tLLM\tTOOLS\tTOKENS\tMESSAGE")
+	for _, t := range turns {
+		msg := oneLine(t.UserMessage, 60)
+		if t.Error != "" {
+			msg = "(failed) " + msg
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%d\t%s\n", t.Turn, t.Started.Local().Format(time.DateTime),
+			duration(t.DurationMS), len(t.LLMCalls), len(t.ToolCalls), t.Usage.TotalTokens, msg)
+	}
+	tw.Flush()
+}
+
+// printTrace shows a turn step by step: the prompt, then each LLM call
+// followed by the tool calls it asked for, then the reply.
+func printTrace(w io.Writer, t *memory.TurnTrace) {
+	fmt.Fprintf(w, "Turn %d of %s\n", t.Turn, t.SessionKey)
+	fmt.Fprintf(w, "Started %s, took %s, %d tokens (%d prompt, %d completion, %d cached)\n",
+		t.Started.Local().Format(time.DateTime), duration(t.DurationMS), t.Usage.TotalTokens,
+		t.Usage.PromptTokens, t.Usage.CompletionTokens, t.Usage.CachedTokens)
+	if t.Channel != "" {
+		fmt.Fprintf(w, "Channel %s, chat %s\n", t.Channel, t.ChatID)
+	}
+
+	fmt.Fprintf(w, "\nPrompt (%d messages):\n", len(t.Prompt))
+	for _, m := range t.Prompt {
+		fmt.Fprintf(w, "  [%s] %s\n", m.Role, oneLine(m.Content, previewLen))
+	}
+
+	for _, call := range t.LLMCalls {
+		fmt.Fprintf(w, "\nLLM call %d: %s, %s, %d messages, %d tools, %d+%d tokens\n",
+			call.Iteration, call.Model, duration(call.DurationMS), call.Messages, len(call.Tools),
+			call.PromptTokens, call.CompletionTokens)
+		if call.Error != "" {
+			fmt.Fprintf(w, "  error: %s\n", call.Error)
+		}
+		if call.Reasoning != "" {
+			fmt.Fprintf(w, "  reasoning: %s\n", oneLine(call.Reasoning, previewLen))
+		}
+		if call.Content != "" {
+			fmt.Fprintf(w, "  content: %s\n", oneLine(call.Content, previewLen))
+		}
+		for _, tc := range t.ToolCalls {
+			if tc.Iteration != call.Iteration {
+				continue
+			}
+			args, _ := json.Marshal(tc.Arguments)
+			status := ""
+			if tc.IsError {
+				status = " (error)"
+			}
+			fmt.Fprintf(w, "  tool %s%s, %s: %s\n", tc.Name, status, duration(tc.DurationMS), oneLine(string(args), 200))
+			fmt.Fprintf(w, "    -> %s\n", oneLine(tc.Result, previewLen))
+		}
+	}
+
+	if t.Error != "" {
+		fmt.Fprintf(w, "\nFailed: %s\n", t.Error)
+		return
+	}
+	fmt.Fprintf(w, "\nResponse: %s\n", oneLine(t.Response, previewLen))
+}
+
+func oneLine(s string, n int) string {
+	return utils.Truncate(strings.Join(strings.Fields(s), " "), n)
+}
+
+func duration(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
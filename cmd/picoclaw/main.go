@@ -14,14 +14,28 @@ import (
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/agent"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/audit"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/auth"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/config"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/cron"
+	diagcmd "github.com/sipeed/picoclaw/cmd/picoclaw/internal/diag"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/doctor"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/erase"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/eval"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/gateway"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/mcp"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/migrate"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/models"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/onboard"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/secret"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/sessions"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/skills"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/snapshot"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/status"
+	synccmd "github.com/sipeed/picoclaw/cmd/picoclaw/internal/sync"
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/trace"
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal/version"
+	"github.com/sipeed/picoclaw/pkg/diag"
 )
 
 func NewPicoclawCommand() *cobra.Command {
@@ -36,11 +50,25 @@ func NewPicoclawCommand() *cobra.Command {
 	cmd.AddCommand(
 		onboard.NewOnboardCommand(),
 		agent.NewAgentCommand(),
+		agent.NewChatCommand(),
 		auth.NewAuthCommand(),
 		gateway.NewGatewayCommand(),
 		status.NewStatusCommand(),
+		doctor.NewDoctorCommand(),
+		config.NewConfigCommand(),
+		secret.NewSecretCommand(),
+		sessions.NewSessionsCommand(),
+		snapshot.NewSnapshotCommand(),
+		synccmd.NewSyncCommand(),
+		trace.NewTraceCommand(),
+		audit.NewAuditCommand(),
+		erase.NewEraseCommand(),
+		eval.NewEvalCommand(),
+		diagcmd.NewDiagCommand(),
 		cron.NewCronCommand(),
+		mcp.NewMCPCommand(),
 		migrate.NewMigrateCommand(),
+		models.NewModelsCommand(),
 		skills.NewSkillsCommand(),
 		version.NewVersionCommand(),
 	)
@@ -62,6 +90,7 @@ const (
 )
 
 func main() {
+	defer diag.Recover()
 	fmt.Printf("%s", banner)
 	cmd := NewPicoclawCommand()
 	if err := cmd.Execute(); err != nil {
@@ -34,13 +34,27 @@ func TestNewPicoclawCommand(t *testing.T) {
 
 	allowedCommands := []string{
 		"agent",
+		"audit",
 		"auth",
+		"chat",
+		"config",
 		"cron",
+		"diag",
+		"doctor",
+		"erase",
+		"eval",
 		"gateway",
+		"mcp",
 		"migrate",
+		"models",
 		"onboard",
+		"secret",
+		"sessions",
 		"skills",
+		"snapshot",
 		"status",
+		"sync",
+		"trace",
 		"version",
 	}
 
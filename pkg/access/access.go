@@ -0,0 +1,334 @@
+// Package access decides who may talk to the bot and with which role. Every
+// channel asks it before publishing a message; the roles it hands out feed
+// the tool policy, and the owner manages them with the /access command.
+package access
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	RoleOwner   = "owner"
+	RoleTrusted = "trusted"
+	RoleGuest   = "guest"
+)
+
+const (
+	// maxPairAttempts wrong codes invalidate the pairing code.
+	maxPairAttempts = 5
+	// maxTurnedAway bounds the list of refused senders shown to the owner.
+	maxTurnedAway = 10
+	// codeAlphabet leaves out characters that are easy to misread.
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	codeLength   = 8
+)
+
+// Controller resolves the role of senders. Roles fixed in the config cannot
+// be changed at runtime; roles granted by pairing or by the owner are kept
+// in the workspace state directory.
+type Controller struct {
+	cfg  config.AccessConfig
+	path string
+
+	mu         sync.Mutex
+	granted    map[string]string // canonical ID → role
+	code       string
+	attempts   int
+	turnedAway []string // "telegram:123 (Bob)", newest last
+	audit      *audit.Log
+}
+
+type storedAccess struct {
+	Users map[string]string `json:"users"`
+}
+
+// NewController creates a controller for cfg, loading the roles granted
+// earlier in workspace.
+func NewController(cfg config.AccessConfig, workspace string) *Controller {
+	c := &Controller{
+		cfg:     cfg,
+		path:    filepath.Join(workspace, "state", "access.json"),
+		granted: make(map[string]string),
+	}
+	if data, err := os.ReadFile(c.path); err == nil {
+		var stored storedAccess
+		if err := json.Unmarshal(data, &stored); err != nil {
+			logger.WarnCF("access", "Failed to load granted roles", map[string]any{"error": err.Error()})
+		} else if stored.Users != nil {
+			c.granted = stored.Users
+		}
+	}
+	return c
+}
+
+// SetAuditLog makes the controller audit wrong pairing codes.
+func (c *Controller) SetAuditLog(l *audit.Log) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.audit = l
+}
+
+// normalizeSender fills in the canonical ID of a sender, building one from
+// the legacy sender ID ("123456|username") when the channel gave no
+// structured sender.
+func normalizeSender(channel string, sender bus.SenderInfo, senderID string) bus.SenderInfo {
+	if sender.CanonicalID != "" {
+		return sender
+	}
+	if sender.PlatformID == "" {
+		id, user, _ := strings.Cut(senderID, "|")
+		sender.PlatformID = id
+		if sender.Username == "" {
+			sender.Username = user
+		}
+	}
+	if sender.Platform == "" {
+		sender.Platform = channel
+	}
+	sender.CanonicalID = identity.BuildCanonicalID(sender.Platform, sender.PlatformID)
+	return sender
+}
+
+// Admit returns the role of a sender and whether it may talk to the bot.
+// listed reports that the channel's allow_from names the sender.
+func (c *Controller) Admit(channel string, sender bus.SenderInfo, senderID string, listed bool) (string, bool) {
+	sender = normalizeSender(channel, sender, senderID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if role := c.roleLocked(sender); role != "" {
+		return role, true
+	}
+	if listed {
+		return RoleTrusted, true
+	}
+	if c.cfg.DefaultRole != "" {
+		return c.cfg.DefaultRole, true
+	}
+	c.turnAwayLocked(sender)
+	return "", false
+}
+
+func (c *Controller) roleLocked(sender bus.SenderInfo) string {
+	for _, u := range c.cfg.Users {
+		if identity.MatchAllowed(sender, u.ID) {
+			return u.Role
+		}
+	}
+	return c.granted[sender.CanonicalID]
+}
+
+func (c *Controller) turnAwayLocked(sender bus.SenderInfo) {
+	entry := sender.CanonicalID
+	if name := sender.DisplayName; name != "" {
+		entry += " (" + name + ")"
+	} else if sender.Username != "" {
+		entry += " (@" + sender.Username + ")"
+	}
+	for i, seen := range c.turnedAway {
+		if seen == entry {
+			c.turnedAway = append(c.turnedAway[:i], c.turnedAway[i+1:]...)
+			break
+		}
+	}
+	c.turnedAway = append(c.turnedAway, entry)
+	if len(c.turnedAway) > maxTurnedAway {
+		c.turnedAway = c.turnedAway[1:]
+	}
+}
+
+func (c *Controller) hasOwnerLocked() bool {
+	for _, u := range c.cfg.Users {
+		if u.Role == RoleOwner {
+			return true
+		}
+	}
+	for _, role := range c.granted {
+		if role == RoleOwner {
+			return true
+		}
+	}
+	return false
+}
+
+// PairingCode returns the one-time code that claims the bot, creating it
+// on first use. It is empty once the bot has an owner or after too many
+// wrong attempts.
+func (c *Controller) PairingCode() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hasOwnerLocked() || c.attempts >= maxPairAttempts {
+		return ""
+	}
+	if c.code == "" {
+		c.code = newCode()
+	}
+	return c.code
+}
+
+func newCode() string {
+	b := make([]byte, codeLength)
+	_, _ = rand.Read(b) // never fails on supported platforms
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b)
+}
+
+// Pair makes the sender the owner if code is the pairing code, and returns
+// the reply to send.
+func (c *Controller) Pair(channel string, sender bus.SenderInfo, senderID, code string) string {
+	sender = normalizeSender(channel, sender, senderID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hasOwnerLocked() {
+		return "This bot already has an owner."
+	}
+	if code == "" {
+		return "Usage: /pair <code>"
+	}
+	if c.code == "" || sender.CanonicalID == "" {
+		return "Invalid or expired pairing code."
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.ToUpper(code)), []byte(c.code)) != 1 {
+		c.attempts++
+		detail := "wrong pairing code"
+		if c.attempts >= maxPairAttempts {
+			c.code = ""
+			detail += "; code invalidated"
+			logger.WarnCF("access", "Pairing code invalidated after too many wrong attempts; restart to get a new one",
+				map[string]any{"last_sender": sender.CanonicalID})
+		}
+		c.auditLocked(audit.Event{
+			Kind:    audit.AuthFailure,
+			Actor:   sender.CanonicalID,
+			Channel: channel,
+			Action:  "pair",
+			Outcome: "denied",
+			Detail:  detail,
+		})
+		return "Invalid or expired pairing code."
+	}
+
+	c.code = ""
+	c.granted[sender.CanonicalID] = RoleOwner
+	if err := c.saveLocked(); err != nil {
+		logger.ErrorCF("access", "Failed to save owner", map[string]any{"error": err.Error()})
+	}
+	logger.InfoCF("access", "Bot paired with its owner", map[string]any{"owner": sender.CanonicalID})
+	return "Paired. You are now the owner of this bot."
+}
+
+// auditLocked records e, if there is an audit log. The caller holds c.mu.
+func (c *Controller) auditLocked(e audit.Event) {
+	if c.audit == nil {
+		return
+	}
+	if err := c.audit.Record(context.Background(), e); err != nil {
+		logger.WarnCF("access", "Failed to audit event", map[string]any{"error": err.Error()})
+	}
+}
+
+// Grant gives user, a canonical ID such as "telegram:123456", a role.
+func (c *Controller) Grant(user, role string) error {
+	platform, id, ok := identity.ParseCanonicalID(user)
+	if !ok {
+		return fmt.Errorf("user must look like platform:id, e.g. telegram:123456")
+	}
+	user = identity.BuildCanonicalID(platform, id)
+	if role != RoleOwner && role != RoleTrusted && role != RoleGuest {
+		return fmt.Errorf("unknown role %q (want owner, trusted or guest)", role)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fixedLocked(user) {
+		return fmt.Errorf("the role of %s is set in the config file", user)
+	}
+	c.granted[user] = role
+	return c.saveLocked()
+}
+
+// Revoke removes the role granted to user. The last owner cannot be
+// revoked, so the bot is never left without one.
+func (c *Controller) Revoke(user string) error {
+	if platform, id, ok := identity.ParseCanonicalID(user); ok {
+		user = identity.BuildCanonicalID(platform, id)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fixedLocked(user) {
+		return fmt.Errorf("the role of %s is set in the config file", user)
+	}
+	role, ok := c.granted[user]
+	if !ok {
+		return fmt.Errorf("%s has no granted role", user)
+	}
+	delete(c.granted, user)
+	if role == RoleOwner && !c.hasOwnerLocked() {
+		c.granted[user] = role
+		return fmt.Errorf("%s is the only owner", user)
+	}
+	return c.saveLocked()
+}
+
+func (c *Controller) fixedLocked(user string) bool {
+	for _, u := range c.cfg.Users {
+		if strings.EqualFold(u.ID, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// List describes the users with a role, followed by the senders recently
+// turned away.
+func (c *Controller) List() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var lines []string
+	for _, u := range c.cfg.Users {
+		lines = append(lines, fmt.Sprintf("%s: %s (config)", u.ID, u.Role))
+	}
+	users := make([]string, 0, len(c.granted))
+	for user := range c.granted {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	for _, user := range users {
+		lines = append(lines, fmt.Sprintf("%s: %s", user, c.granted[user]))
+	}
+	if c.cfg.DefaultRole != "" {
+		lines = append(lines, "everyone else: "+c.cfg.DefaultRole)
+	}
+	for i := len(c.turnedAway) - 1; i >= 0; i-- {
+		lines = append(lines, "turned away: "+c.turnedAway[i])
+	}
+	return lines
+}
+
+func (c *Controller) saveLocked() error {
+	data, err := json.MarshalIndent(storedAccess{Users: c.granted}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal access: %w", err)
+	}
+	return fileutil.WriteFileAtomic(c.path, data, 0o600)
+}
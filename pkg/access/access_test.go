@@ -0,0 +1,94 @@
+package access
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func sender(id, name string) bus.SenderInfo {
+	return bus.SenderInfo{Platform: "telegram", PlatformID: id, CanonicalID: "telegram:" + id, DisplayName: name}
+}
+
+func TestController_Admit(t *testing.T) {
+	c := NewController(config.AccessConfig{
+		Enabled: true,
+		Users:   []config.AccessUser{{ID: "@alice", Role: RoleOwner}},
+	}, t.TempDir())
+
+	alice := sender("1", "Alice")
+	alice.Username = "alice"
+	if role, ok := c.Admit("telegram", alice, "1", false); !ok || role != RoleOwner {
+		t.Errorf("alice: role = %q, ok = %v", role, ok)
+	}
+	if role, ok := c.Admit("telegram", sender("2", "Bob"), "2", true); !ok || role != RoleTrusted {
+		t.Errorf("allow_from sender: role = %q, ok = %v", role, ok)
+	}
+	if _, ok := c.Admit("telegram", sender("3", "Eve"), "3", false); ok {
+		t.Error("stranger admitted without a default role")
+	}
+	// Legacy sender IDs are matched by their canonical form.
+	if err := c.Grant("slack:U9", RoleGuest); err != nil {
+		t.Fatal(err)
+	}
+	if role, ok := c.Admit("slack", bus.SenderInfo{}, "U9|carol", false); !ok || role != RoleGuest {
+		t.Errorf("legacy sender: role = %q, ok = %v", role, ok)
+	}
+
+	lines := strings.Join(c.List(), "\n")
+	if lines != "@alice: owner (config)\nslack:U9: guest\nturned away: telegram:3 (Eve)" {
+		t.Errorf("List =\n%s", lines)
+	}
+
+	c.cfg.DefaultRole = RoleGuest
+	if role, ok := c.Admit("telegram", sender("3", "Eve"), "3", false); !ok || role != RoleGuest {
+		t.Errorf("default role: role = %q, ok = %v", role, ok)
+	}
+}
+
+func TestController_Pair(t *testing.T) {
+	workspace := t.TempDir()
+	c := NewController(config.AccessConfig{Enabled: true}, workspace)
+	code := c.PairingCode()
+	if len(code) != codeLength {
+		t.Fatalf("code = %q", code)
+	}
+
+	if reply := c.Pair("telegram", sender("3", "Eve"), "3", "WRONG"); reply != "Invalid or expired pairing code." {
+		t.Errorf("wrong code reply = %q", reply)
+	}
+	if reply := c.Pair("telegram", sender("1", "Alice"), "1", strings.ToLower(code)); !strings.HasPrefix(reply, "Paired.") {
+		t.Fatalf("pair reply = %q", reply)
+	}
+	if c.PairingCode() != "" {
+		t.Error("pairing code still offered after pairing")
+	}
+	if reply := c.Pair("telegram", sender("3", "Eve"), "3", code); reply != "This bot already has an owner." {
+		t.Errorf("second pair reply = %q", reply)
+	}
+
+	// The owner survives a restart and cannot revoke themselves away.
+	c = NewController(config.AccessConfig{Enabled: true}, workspace)
+	if role, _ := c.Admit("telegram", sender("1", "Alice"), "1", false); role != RoleOwner {
+		t.Errorf("role after restart = %q", role)
+	}
+	if err := c.Revoke("telegram:1"); err == nil {
+		t.Error("revoked the only owner")
+	}
+}
+
+func TestController_PairAttemptsExhausted(t *testing.T) {
+	c := NewController(config.AccessConfig{Enabled: true}, t.TempDir())
+	code := c.PairingCode()
+	for range maxPairAttempts {
+		c.Pair("telegram", sender("3", "Eve"), "3", "WRONG")
+	}
+	if reply := c.Pair("telegram", sender("3", "Eve"), "3", code); reply != "Invalid or expired pairing code." {
+		t.Errorf("reply after too many attempts = %q", reply)
+	}
+	if c.PairingCode() != "" {
+		t.Error("new pairing code offered after too many attempts")
+	}
+}
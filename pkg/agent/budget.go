@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+const budgetRefusal = "Sorry, the %s has been used up, so I can't answer until it resets %s."
+
+// budgetLimit is a budget limit that has been reached.
+type budgetLimit struct {
+	scope  string // "session" or "global"
+	period string // "daily" or "monthly"
+	limit  string // the amount, e.g. "$1.00" or "100000 tokens"
+}
+
+// String describes the limit for the user.
+func (l budgetLimit) String() string {
+	if l.scope == "session" {
+		return fmt.Sprintf("%s budget of this conversation (%s)", l.period, l.limit)
+	}
+	return fmt.Sprintf("%s budget (%s)", l.period, l.limit)
+}
+
+func (l budgetLimit) resets() string {
+	if l.period == "daily" {
+		return "tomorrow"
+	}
+	return "next month"
+}
+
+// overBudget returns the first budget limit reached by the session or by all
+// sessions together, or nil when there is budget left. Usage that cannot be
+// read does not stop turns.
+func (al *AgentLoop) overBudget(ctx context.Context, sessionKey string) *budgetLimit {
+	b := al.cfg.Budgets
+	if !b.Enabled {
+		return nil
+	}
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	for _, scope := range []struct {
+		name    string
+		limits  config.BudgetLimits
+		session string
+	}{
+		{"session", b.Session, sessionKey},
+		{"global", b.Global, ""},
+	} {
+		for _, period := range []struct {
+			name   string
+			since  time.Time
+			tokens int
+			usd    float64
+		}{
+			{"daily", day, scope.limits.DailyTokens, scope.limits.DailyUSD},
+			{"monthly", month, scope.limits.MonthlyTokens, scope.limits.MonthlyUSD},
+		} {
+			if period.tokens <= 0 && period.usd <= 0 {
+				continue
+			}
+			used, err := al.usageSince(ctx, period.since, scope.session)
+			if err != nil {
+				logger.WarnCF("agent", "Cannot check budget", map[string]any{"error": err.Error()})
+				return nil
+			}
+			limit := budgetLimit{scope: scope.name, period: period.name}
+			switch {
+			case period.tokens > 0 && used.TotalTokens >= period.tokens:
+				limit.limit = fmt.Sprintf("%d tokens", period.tokens)
+			case period.usd > 0 && used.CostUSD >= period.usd:
+				limit.limit = fmt.Sprintf("$%.2f", period.usd)
+			default:
+				continue
+			}
+			return &limit
+		}
+	}
+	return nil
+}
+
+// usageSince adds up the usage of every agent since the given time, of one
+// session or, when sessionKey is "", of all of them.
+func (al *AgentLoop) usageSince(ctx context.Context, since time.Time, sessionKey string) (memory.UsageTotals, error) {
+	var total memory.UsageTotals
+	seen := map[*memory.UsageLedger]bool{}
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok || agent.Usage == nil || seen[agent.Usage] {
+			continue
+		}
+		seen[agent.Usage] = true
+		t, err := agent.Usage.Totals(ctx, since, sessionKey)
+		if err != nil {
+			return total, err
+		}
+		total.Requests += t.Requests
+		total.TotalTokens += t.TotalTokens
+		total.CostUSD += t.CostUSD
+	}
+	return total, nil
+}
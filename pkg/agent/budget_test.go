@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func budgetConfig(t *testing.T, budgets config.BudgetsConfig) *config.Config {
+	return &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "strong",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "strong", Model: "openai/gpt-4o"},
+			{ModelName: "cheap", Model: "openai/gpt-4o-mini"},
+		},
+		Budgets: budgets,
+	}
+}
+
+func spend(t *testing.T, al *AgentLoop, sessionKey string, tokens int, usd float64) {
+	t.Helper()
+	rec := memory.UsageRecord{SessionKey: sessionKey, Model: "strong", TotalTokens: tokens, CostUSD: usd}
+	if err := al.registry.GetDefaultAgent().Usage.Record(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessDirect_BudgetRefuses(t *testing.T) {
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(budgetConfig(t, config.BudgetsConfig{
+		Enabled: true,
+		Session: config.BudgetLimits{DailyUSD: 1},
+		Global:  config.BudgetLimits{MonthlyTokens: 10_000},
+	}), bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	spend(t, al, "agent:main:main", 100, 0.5)
+	if _, err := al.ProcessDirect(ctx, "hello", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.models) != 1 {
+		t.Fatalf("got %d LLM calls within budget, want 1", len(provider.models))
+	}
+
+	spend(t, al, "agent:main:main", 100, 0.5)
+	reply, err := al.ProcessDirect(ctx, "hello again", "cli:direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "daily budget of this conversation ($1.00)"; !strings.Contains(reply, want) ||
+		!strings.Contains(reply, "tomorrow") {
+		t.Errorf("reply = %q, want it to name the %s", reply, want)
+	}
+	if len(provider.models) != 1 {
+		t.Error("a turn over budget reached the model")
+	}
+
+	// Other sessions still have their own budget, until the global one runs out.
+	if _, err := al.ProcessDirect(ctx, "hi", "agent:main:other"); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.models) != 2 {
+		t.Errorf("session within budget refused")
+	}
+	spend(t, al, "agent:main:third", 10_000, 0)
+	reply, _ = al.ProcessDirect(ctx, "hi", "agent:main:other")
+	if !strings.Contains(reply, "monthly budget (10000 tokens)") {
+		t.Errorf("reply = %q, want the global monthly budget", reply)
+	}
+}
+
+func TestProcessDirect_BudgetFallsBackToCheaperModel(t *testing.T) {
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(budgetConfig(t, config.BudgetsConfig{
+		Enabled:       true,
+		Global:        config.BudgetLimits{DailyTokens: 1000},
+		FallbackModel: "cheap",
+	}), bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	spend(t, al, "agent:main:main", 1000, 0)
+	reply, err := al.ProcessDirect(ctx, "hello", "cli:direct")
+	if err != nil || reply != "ok" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+	if len(provider.models) != 1 || provider.models[0] != "cheap" {
+		t.Errorf("models = %v, want the fallback model", provider.models)
+	}
+}
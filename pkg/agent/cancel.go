@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const cancelledResponse = "Stopped."
+
+// errTurnCancelled is the cause of a turn's context when the conversation
+// asked to stop it, as opposed to the agent shutting down.
+var errTurnCancelled = errors.New("turn cancelled by the conversation")
+
+// cancelWords end the running turn of a conversation when they make up a
+// whole message. While nothing runs they are ordinary messages; /cancel is
+// also a command.
+var cancelWords = []string{"/cancel", "stop", "cancel"}
+
+// isCancelRequest reports whether content asks to stop the running turn.
+func isCancelRequest(content string) bool {
+	word := strings.ToLower(strings.Trim(strings.TrimSpace(content), ".!"))
+	if i := strings.Index(word, "@"); strings.HasPrefix(word, "/") && i >= 0 {
+		word = word[:i] // Telegram's /cancel@bot
+	}
+	for _, w := range cancelWords {
+		if word == w {
+			return true
+		}
+	}
+	return false
+}
+
+// activeTurns tracks the running turns of each conversation so that they
+// can be cancelled from the conversation. A conversation has several when
+// its messages go to different sessions.
+type activeTurns struct {
+	mu    sync.Mutex
+	turns map[string]map[*activeTurn]struct{}
+}
+
+type activeTurn struct {
+	cancel context.CancelCauseFunc
+}
+
+type turnCtxKey struct{}
+
+func newActiveTurns() *activeTurns {
+	return &activeTurns{turns: make(map[string]map[*activeTurn]struct{})}
+}
+
+func conversationKey(channel, chatID string) string {
+	return channel + "\x00" + chatID
+}
+
+// start derives the context of a turn for msg from ctx and registers it.
+// The returned function must be called when the turn is over.
+func (t *activeTurns) start(ctx context.Context, msg bus.InboundMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	key := conversationKey(msg.Channel, msg.ChatID)
+	turn := &activeTurn{cancel: cancel}
+	ctx = context.WithValue(ctx, turnCtxKey{}, turn)
+
+	t.mu.Lock()
+	if t.turns[key] == nil {
+		t.turns[key] = make(map[*activeTurn]struct{})
+	}
+	t.turns[key][turn] = struct{}{}
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.turns[key], turn)
+		if len(t.turns[key]) == 0 {
+			delete(t.turns, key)
+		}
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel stops the running turns of a conversation and reports whether
+// there were any. A turn asking from within, as /cancel does, does not stop
+// itself.
+func (t *activeTurns) cancel(ctx context.Context, channel, chatID string) bool {
+	self, _ := ctx.Value(turnCtxKey{}).(*activeTurn)
+	var stop []*activeTurn
+	t.mu.Lock()
+	for turn := range t.turns[conversationKey(channel, chatID)] {
+		if turn != self {
+			stop = append(stop, turn)
+		}
+	}
+	t.mu.Unlock()
+	if len(stop) == 0 {
+		return false
+	}
+	logger.InfoCF("agent", "Cancelling running turns",
+		map[string]any{"channel": channel, "chat_id": chatID, "turns": len(stop)})
+	for _, turn := range stop {
+		turn.cancel(errTurnCancelled)
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// blockingProvider answers "quick" at once and blocks every other request
+// until it is cancelled.
+type blockingProvider struct {
+	started chan struct{}
+}
+
+func (p *blockingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if messages[len(messages)-1].Content == "quick" {
+		return &providers.LLMResponse{Content: "quick answer"}, nil
+	}
+	p.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *blockingProvider) GetDefaultModel() string { return "blocking" }
+
+func TestIsCancelRequest(t *testing.T) {
+	for _, text := range []string{"stop", " Stop! ", "cancel", "/cancel", "/cancel@picoclaw_bot"} {
+		if !isCancelRequest(text) {
+			t.Errorf("isCancelRequest(%q) = false", text)
+		}
+	}
+	for _, text := range []string{"don't stop", "/cancelled", "stop the backup job", ""} {
+		if isCancelRequest(text) {
+			t.Errorf("isCancelRequest(%q) = true", text)
+		}
+	}
+}
+
+func TestRun_CancelsRunningTurn(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &blockingProvider{started: make(chan struct{}, 1)}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go al.Run(ctx)
+
+	send := func(chatID, content string) {
+		msgBus.PublishInbound(ctx, bus.InboundMessage{
+			Channel: "telegram", SenderID: "telegram:" + chatID, ChatID: chatID, Content: content,
+			SessionKey: "agent:main:telegram:direct:" + chatID,
+		})
+	}
+	reply := func() bus.OutboundMessage {
+		t.Helper()
+		waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+		defer waitCancel()
+		msg, ok := msgBus.SubscribeOutbound(waitCtx)
+		if !ok {
+			t.Fatal("no reply")
+		}
+		return msg
+	}
+
+	send("1", "summarize the logs")
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not start")
+	}
+	// A stop from another chat leaves the turn alone; it is queued.
+	send("2", "quick")
+	send("1", "stop")
+
+	if msg := reply(); msg.ChatID != "1" || msg.Content != cancelledResponse {
+		t.Errorf("first reply = %+v, want %q to chat 1", msg, cancelledResponse)
+	}
+	if msg := reply(); msg.ChatID != "2" || msg.Content != "quick answer" {
+		t.Errorf("second reply = %+v", msg)
+	}
+
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:telegram:direct:1")
+	if len(history) != 2 || history[1].Content != cancelledResponse {
+		t.Errorf("history = %+v", history)
+	}
+
+	// With nothing running, /cancel says so.
+	send("1", "/cancel")
+	if msg := reply(); msg.Content != "Nothing is running." {
+		t.Errorf("idle /cancel reply = %q", msg.Content)
+	}
+}
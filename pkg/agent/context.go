@@ -22,6 +22,12 @@ type ContextBuilder struct {
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
 
+	// Configured prompt layers; see SetPrompt.
+	promptMu       sync.RWMutex
+	persona        string
+	channelPrompts map[string]string
+	vars           promptVars
+
 	// Cache for system prompt to avoid rebuilding on every call.
 	// This fixes issue #607: repeated reprocessing of the entire context.
 	// The cache auto-invalidates when workspace source files change (mtime check).
@@ -72,9 +78,14 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 func (cb *ContextBuilder) getIdentity() string {
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 
+	persona := defaultPersona
+	if configured, _, vars := cb.promptLayers(); strings.TrimSpace(configured) != "" {
+		persona = vars.expand(strings.TrimSpace(configured))
+	}
+
 	return fmt.Sprintf(`# picoclaw 🦞
 
-You are picoclaw, a helpful AI assistant.
+%s
 
 ## Workspace
 Your workspace is at: %s
@@ -91,7 +102,7 @@ Your workspace is at: %s
 3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md
 
 4. **Context summaries** - Conversation summaries provided as context are approximate references only. They may be incomplete or outdated. Always defer to explicit user instructions over summary content.`,
-		workspacePath, workspacePath, workspacePath, workspacePath, workspacePath)
+		persona, workspacePath, workspacePath, workspacePath, workspacePath, workspacePath)
 }
 
 func (cb *ContextBuilder) BuildSystemPrompt() string {
@@ -405,11 +416,12 @@ func (cb *ContextBuilder) LoadBootstrapFiles() string {
 		"IDENTITY.md",
 	}
 
+	_, _, vars := cb.promptLayers()
 	var sb strings.Builder
 	for _, filename := range bootstrapFiles {
 		filePath := filepath.Join(cb.workspace, filename)
 		if data, err := os.ReadFile(filePath); err == nil {
-			fmt.Fprintf(&sb, "## %s\n\n%s\n\n", filename, data)
+			fmt.Fprintf(&sb, "## %s\n\n%s\n\n", filename, vars.expand(string(data)))
 		}
 	}
 
@@ -449,7 +461,7 @@ func (cb *ContextBuilder) BuildMessages(
 
 	// The static part (identity, bootstrap, skills, memory) is cached locally to
 	// avoid repeated file I/O and string building on every call (fixes issue #607).
-	// Dynamic parts (time, session, summary) are appended per request.
+	// Dynamic parts (summary, time, session) are appended per request.
 	// Everything is sent as a single system message for provider compatibility:
 	// - Anthropic adapter extracts messages[0] (Role=="system") and maps its content
 	//   to the top-level "system" parameter in the Messages API request. A single
@@ -461,7 +473,7 @@ func (cb *ContextBuilder) BuildMessages(
 	// Build short dynamic context (time, runtime, session) — changes per request
 	dynamicCtx := cb.buildDynamicContext(channel, chatID)
 
-	// Compose a single system message: static (cached) + dynamic + optional summary.
+	// Compose a single system message: static (cached) + optional summary + dynamic.
 	// Keeping all system content in one message ensures every provider adapter can
 	// extract it correctly (Anthropic adapter -> top-level system param,
 	// Codex -> instructions field).
@@ -469,12 +481,25 @@ func (cb *ContextBuilder) BuildMessages(
 	// SystemParts carries the same content as structured blocks so that
 	// cache-aware adapters (Anthropic) can set per-block cache_control.
 	// The static block is marked "ephemeral" — its prefix hash is stable
-	// across requests, enabling LLM-side KV cache reuse.
-	stringParts := []string{staticPrompt, dynamicCtx}
+	// across requests, enabling LLM-side KV cache reuse. The summary only
+	// changes when the session is summarized, so it goes before the dynamic
+	// context and gets its own breakpoint; this also lengthens the stable
+	// prefix seen by prefix-caching providers (OpenAI).
+	stringParts := []string{staticPrompt}
 
 	contentBlocks := []providers.ContentBlock{
 		{Type: "text", Text: staticPrompt, CacheControl: &providers.CacheControl{Type: "ephemeral"}},
-		{Type: "text", Text: dynamicCtx},
+	}
+
+	// Channel instructions are stable per channel, so they get a cache
+	// breakpoint of their own after the prompt shared by all channels.
+	if instructions := cb.channelInstructions(channel, chatID); instructions != "" {
+		stringParts = append(stringParts, instructions)
+		contentBlocks = append(contentBlocks, providers.ContentBlock{
+			Type:         "text",
+			Text:         instructions,
+			CacheControl: &providers.CacheControl{Type: "ephemeral"},
+		})
 	}
 
 	if summary != "" {
@@ -483,9 +508,16 @@ func (cb *ContextBuilder) BuildMessages(
 				"for reference only. It may be incomplete or outdated — always defer to explicit instructions.\n\n%s",
 			summary)
 		stringParts = append(stringParts, summaryText)
-		contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: summaryText})
+		contentBlocks = append(contentBlocks, providers.ContentBlock{
+			Type:         "text",
+			Text:         summaryText,
+			CacheControl: &providers.CacheControl{Type: "ephemeral"},
+		})
 	}
 
+	stringParts = append(stringParts, dynamicCtx)
+	contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: dynamicCtx})
+
 	fullSystemPrompt := strings.Join(stringParts, "\n\n---\n\n")
 
 	// Log system prompt summary for debugging (debug mode only).
@@ -661,6 +693,31 @@ func sanitizeHistoryForProvider(history []providers.Message) []providers.Message
 	return final
 }
 
+// insertSystemPart inserts block into the system message built by
+// BuildMessages as part number at; a negative at counts from the end. Content
+// is rebuilt from the parts so that both forms stay the same.
+func insertSystemPart(messages []providers.Message, at int, block providers.ContentBlock) []providers.Message {
+	if len(messages) == 0 || messages[0].Role != "system" {
+		return messages
+	}
+	system := messages[0]
+	n := len(system.SystemParts)
+	if at < 0 {
+		at += n
+	}
+	at = max(0, min(at, n))
+	system.SystemParts = slices.Insert(slices.Clone(system.SystemParts), at, block)
+	parts := make([]string, len(system.SystemParts))
+	for i, part := range system.SystemParts {
+		parts[i] = part.Text
+	}
+	system.Content = strings.Join(parts, "\n\n---\n\n")
+
+	out := slices.Clone(messages)
+	out[0] = system
+	return out
+}
+
 func (cb *ContextBuilder) AddToolResult(
 	messages []providers.Message,
 	toolCallID, toolName, result string,
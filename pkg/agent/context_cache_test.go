@@ -126,6 +126,38 @@ func TestSingleSystemMessage(t *testing.T) {
 	}
 }
 
+// TestSummaryCacheBreakpoint verifies that the summary sits between the static
+// prompt and the per-request dynamic context and is marked cacheable, so the
+// cached prefix survives turns that only change the time or session info.
+func TestSummaryCacheBreakpoint(t *testing.T) {
+	tmpDir := setupWorkspace(t, map[string]string{
+		"IDENTITY.md": "# Identity\nTest agent.",
+	})
+	defer os.RemoveAll(tmpDir)
+
+	cb := NewContextBuilder(tmpDir)
+	msgs := cb.BuildMessages(nil, "earlier we discussed X", "hello", nil, "test", "chat1")
+
+	parts := msgs[0].SystemParts
+	if len(parts) != 3 {
+		t.Fatalf("len(SystemParts) = %d, want 3", len(parts))
+	}
+	if !strings.Contains(parts[1].Text, "CONTEXT_SUMMARY:") {
+		t.Errorf("SystemParts[1] should be the summary, got %q", parts[1].Text)
+	}
+	if parts[1].CacheControl == nil || parts[1].CacheControl.Type != "ephemeral" {
+		t.Errorf("summary block CacheControl = %+v, want ephemeral", parts[1].CacheControl)
+	}
+	if !strings.Contains(parts[2].Text, "Current Time") || parts[2].CacheControl != nil {
+		t.Errorf("last block should be uncached dynamic context, got %+v", parts[2])
+	}
+
+	sys := msgs[0].Content
+	if strings.Index(sys, "CONTEXT_SUMMARY:") > strings.Index(sys, "## Current Time") {
+		t.Error("summary should precede dynamic context in the flattened system prompt")
+	}
+}
+
 // TestMtimeAutoInvalidation verifies that the cache detects source file changes
 // via mtime without requiring explicit InvalidateCache().
 // Fix: original implementation had no auto-invalidation — edits to bootstrap files,
@@ -667,3 +699,75 @@ func BenchmarkBuildMessagesWithCache(b *testing.B) {
 		_ = cb.BuildMessages(history, "summary", "new message", nil, "cli", "test")
 	}
 }
+
+// TestPromptLayers verifies the configured persona, template variables in
+// workspace files, and per-channel instructions with their own breakpoint.
+func TestPromptLayers(t *testing.T) {
+	tmpDir := setupWorkspace(t, map[string]string{
+		"SOUL.md": "Speak like a {{tone}} pirate. Keep {{unknown}} as is.",
+	})
+	defer os.RemoveAll(tmpDir)
+
+	cb := NewContextBuilder(tmpDir)
+	cb.SetPrompt(
+		"You are {{agent_name}}, the ops bot.",
+		map[string]string{"slack": "Reply in threads of {{chat_id}} on {{channel}}."},
+		promptVariables(map[string]string{"tone": "friendly", "agent_name": "ignored"}, tmpDir, "ops", "", "gpt-4"),
+	)
+
+	msgs := cb.BuildMessages(nil, "", "hello", nil, "slack", "C1")
+	sys := msgs[0].Content
+	for _, want := range []string{
+		"You are ops, the ops bot.",
+		"Speak like a friendly pirate. Keep {{unknown}} as is.",
+		"## Channel Instructions\n\nReply in threads of C1 on slack.",
+	} {
+		if !strings.Contains(sys, want) {
+			t.Errorf("system prompt lacks %q", want)
+		}
+	}
+	if strings.Contains(sys, "You are picoclaw") {
+		t.Error("persona should replace the default introduction")
+	}
+	parts := msgs[0].SystemParts
+	if len(parts) != 3 || !strings.HasPrefix(parts[1].Text, "## Channel Instructions") ||
+		parts[1].CacheControl == nil {
+		t.Errorf("SystemParts = %+v, want channel instructions as a cached second block", parts)
+	}
+
+	// Other channels get the shared prompt only.
+	msgs = cb.BuildMessages(nil, "", "hello", nil, "telegram", "42")
+	if strings.Contains(msgs[0].Content, "Channel Instructions") {
+		t.Error("telegram got slack's instructions")
+	}
+}
+
+// TestCacheBreakpointsWithAllLayers verifies that a session with a persona,
+// channel instructions and a summary leaves room for the tool breakpoint
+// within Anthropic's limit of four.
+func TestCacheBreakpointsWithAllLayers(t *testing.T) {
+	tmpDir := setupWorkspace(t, map[string]string{
+		"IDENTITY.md": "# Identity\nTest agent.",
+	})
+	defer os.RemoveAll(tmpDir)
+
+	cb := NewContextBuilder(tmpDir)
+	cb.SetPrompt("", map[string]string{"slack": "Reply in threads."}, nil)
+	msgs := cb.BuildMessages(nil, "earlier we discussed X", "hello", nil, "slack", "C1")
+	msgs = cb.withPersona(msgs, &Persona{Name: "ops", Prompt: "Be terse."}, "slack", "C1")
+
+	parts := msgs[0].SystemParts
+	if len(parts) != 5 {
+		t.Fatalf("len(SystemParts) = %d, want 5", len(parts))
+	}
+	breakpoints := 0
+	for _, part := range parts {
+		if part.CacheControl != nil {
+			breakpoints++
+		}
+	}
+	// One more goes on the last tool.
+	if breakpoints+1 > 4 {
+		t.Errorf("system breakpoints = %d, want at most 3", breakpoints)
+	}
+}
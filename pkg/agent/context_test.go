@@ -6,7 +6,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
-func msg(role, content string) providers.Message {
+func msg(role providers.Role, content string) providers.Message {
 	return providers.Message{Role: role, Content: content}
 }
 
@@ -188,15 +188,15 @@ func TestSanitizeHistoryForProvider_PlainConversation(t *testing.T) {
 	assertRoles(t, result, "user", "assistant", "user", "assistant")
 }
 
-func roles(msgs []providers.Message) []string {
-	r := make([]string, len(msgs))
+func roles(msgs []providers.Message) []providers.Role {
+	r := make([]providers.Role, len(msgs))
 	for i, m := range msgs {
 		r[i] = m.Role
 	}
 	return r
 }
 
-func assertRoles(t *testing.T, msgs []providers.Message, expected ...string) {
+func assertRoles(t *testing.T, msgs []providers.Message, expected ...providers.Role) {
 	t.Helper()
 	if len(msgs) != len(expected) {
 		t.Fatalf("role count mismatch: got %v, want %v", roles(msgs), expected)
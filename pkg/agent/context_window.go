@@ -0,0 +1,112 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package agent
+
+import (
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
+)
+
+// promptBudget returns how many tokens the prompt of a request may use: the
+// context window minus room for the completion. The reservation is capped at
+// a quarter of the window, so agents whose window defaults to max_tokens
+// still have space for history.
+func (a *AgentInstance) promptBudget() int {
+	return a.ContextWindow - min(a.MaxTokens, a.ContextWindow/4)
+}
+
+// history returns the messages of the session a turn is built from: the
+// latest MaxHistoryMessages of them, or all when that is 0.
+func (a *AgentInstance) history(sessionKey string) []providers.Message {
+	return a.Sessions.GetRecentHistory(sessionKey, a.MaxHistoryMessages)
+}
+
+// fitContextWindow keeps a freshly built request within the agent's context
+// window. When the estimated prompt (messages plus tool definitions) is over
+// budget, older turns are summarized into the session summary and dropped
+// from stored history, and the request is rebuilt. If that is not enough,
+// for example because the remaining turns are themselves large, the oldest
+// stored messages are dropped until the prompt fits.
+//
+// Background summarization after each turn usually keeps sessions well below
+// the window; this is the synchronous backstop for long or bursty turns, so
+// the provider never sees a request that is known not to fit.
+func (al *AgentLoop) fitContextWindow(
+	agent *AgentInstance,
+	opts processOptions,
+	messages []providers.Message,
+) []providers.Message {
+	budget := agent.promptBudget()
+	if budget <= 0 {
+		return messages
+	}
+
+	toolTokens := tokenizer.CountTools(agent.Tools.ToProviderDefs())
+	used := tokenizer.CountMessages(messages) + toolTokens
+	if used <= budget {
+		return messages
+	}
+
+	logger.InfoCF("agent", "Prompt exceeds context window, compacting history",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": opts.SessionKey,
+			"tokens":      used,
+			"budget":      budget,
+		})
+
+	rebuild := func() []providers.Message {
+		messages := agent.ContextBuilder.BuildMessages(
+			agent.history(opts.SessionKey),
+			agent.Sessions.GetSummary(opts.SessionKey),
+			opts.UserMessage,
+			opts.Media,
+			opts.Channel,
+			opts.ChatID,
+		)
+		return agent.ContextBuilder.withTurnContext(messages, opts)
+	}
+
+	// Share the background summarizer's guard so a session is never
+	// summarized twice at once; if it is already running, fall through to
+	// truncation.
+	summarizeKey := agent.ID + ":" + opts.SessionKey
+	if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); !loading {
+		al.summarizeSession(agent, opts.SessionKey)
+		al.summarizing.Delete(summarizeKey)
+
+		messages = rebuild()
+		used = tokenizer.CountMessages(messages) + toolTokens
+		if used <= budget {
+			return messages
+		}
+	}
+
+	history := agent.history(opts.SessionKey)
+	keep := len(history)
+	for used > budget && keep > 0 {
+		used -= tokenizer.CountMessage(history[len(history)-keep])
+		keep--
+	}
+	if keep == len(history) {
+		return messages
+	}
+
+	checkpoint(agent, opts.SessionKey, "before truncate")
+	agent.Sessions.TruncateHistory(opts.SessionKey, keep)
+	agent.Sessions.Save(opts.SessionKey)
+	logger.WarnCF("agent", "Dropped oldest messages to fit context window",
+		map[string]any{
+			"agent_id":    agent.ID,
+			"session_key": opts.SessionKey,
+			"dropped":     len(history) - keep,
+			"tokens":      used,
+			"budget":      budget,
+		})
+
+	return rebuild()
+}
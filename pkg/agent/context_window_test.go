@@ -0,0 +1,244 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
+)
+
+// summarizingProvider answers summarization and merge prompts with a fixed
+// summary and records the messages of every other call.
+type summarizingProvider struct {
+	mu       sync.Mutex
+	requests [][]providers.Message
+}
+
+func (p *summarizingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if len(messages) == 1 && (strings.HasPrefix(messages[0].Content, "Provide a concise summary") ||
+		strings.HasPrefix(messages[0].Content, "Merge these")) {
+		return &providers.LLMResponse{Content: "earlier turns about topic zero"}, nil
+	}
+	p.mu.Lock()
+	p.requests = append(p.requests, messages)
+	p.mu.Unlock()
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (p *summarizingProvider) GetDefaultModel() string { return "summarizing" }
+
+func newContextWindowTestLoop(t *testing.T, provider providers.LLMProvider) (*AgentLoop, *AgentInstance) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	// Size the window so the bare prompt fits with room for ~1500 tokens of
+	// history, and reserve nothing for the completion.
+	base := agent.ContextBuilder.BuildMessages(nil, "", "next question", nil, "cli", "direct")
+	agent.MaxTokens = 0
+	agent.ContextWindow = tokenizer.CountMessages(base) + tokenizer.CountTools(agent.Tools.ToProviderDefs()) + 1500
+	return al, agent
+}
+
+// seedHistory stores n user/assistant pairs of about 250 tokens each.
+func seedHistory(agent *AgentInstance, sessionKey string, n int) {
+	for i := range n {
+		agent.Sessions.AddMessage(sessionKey, "user", fmt.Sprintf("question %d %s", i, strings.Repeat("q", 1000)))
+		agent.Sessions.AddMessage(sessionKey, "assistant", fmt.Sprintf("answer %d %s", i, strings.Repeat("a", 1000)))
+	}
+}
+
+func TestProcessDirect_CompactsHistoryOverContextWindow(t *testing.T) {
+	provider := &summarizingProvider{}
+	al, agent := newContextWindowTestLoop(t, provider)
+	sessionKey := "agent:main:main"
+	seedHistory(agent, sessionKey, 8)
+
+	if _, err := al.ProcessDirect(context.Background(), "next question", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	if got := agent.Sessions.GetSummary(sessionKey); got != "earlier turns about topic zero" {
+		t.Errorf("summary = %q, want the summarized older turns", got)
+	}
+
+	provider.mu.Lock()
+	sent := provider.requests[0]
+	provider.mu.Unlock()
+	if used := tokenizer.CountMessages(sent); used > agent.promptBudget() {
+		t.Errorf("request uses %d tokens, budget is %d", used, agent.promptBudget())
+	}
+	if !strings.Contains(sent[0].Content, "earlier turns about topic zero") {
+		t.Error("system prompt does not carry the new summary")
+	}
+	for _, m := range sent {
+		if strings.HasPrefix(m.Content, "question 0 ") {
+			t.Error("oldest turn was sent after compaction")
+		}
+	}
+	if last := sent[len(sent)-1]; last.Role != "user" || last.Content != "next question" {
+		t.Errorf("last message = %+v, want the current user message", last)
+	}
+}
+
+func TestProcessDirect_DoesNotCompactHistoryWithinContextWindow(t *testing.T) {
+	provider := &summarizingProvider{}
+	al, agent := newContextWindowTestLoop(t, provider)
+	sessionKey := "agent:main:main"
+	seedHistory(agent, sessionKey, 2)
+
+	if _, err := al.ProcessDirect(context.Background(), "next question", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	if got := agent.Sessions.GetSummary(sessionKey); got != "" {
+		t.Errorf("summary = %q, want none", got)
+	}
+	provider.mu.Lock()
+	sent := provider.requests[0]
+	provider.mu.Unlock()
+	if len(sent) != 6 {
+		t.Errorf("request has %d messages, want system + 4 history + user", len(sent))
+	}
+}
+
+func TestProcessDirect_MaxHistoryMessages(t *testing.T) {
+	provider := &summarizingProvider{}
+	al, agent := newContextWindowTestLoop(t, provider)
+	agent.MaxHistoryMessages = 2
+	sessionKey := "agent:main:main"
+	seedHistory(agent, sessionKey, 2)
+
+	if _, err := al.ProcessDirect(context.Background(), "next question", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	provider.mu.Lock()
+	sent := provider.requests[0]
+	provider.mu.Unlock()
+	if len(sent) != 4 || !strings.HasPrefix(sent[1].Content, "question 1 ") {
+		t.Errorf("request has %d messages, want system + the last 2 history + user", len(sent))
+	}
+}
+
+func TestFitContextWindow_TruncatesWhenSummaryIsNotEnough(t *testing.T) {
+	al, agent := newContextWindowTestLoop(t, &summarizingProvider{})
+	sessionKey := "agent:main:main"
+	// Four large messages: summarization keeps the last four, so only
+	// truncation can make room.
+	for i := range 4 {
+		agent.Sessions.AddMessage(sessionKey, "user", fmt.Sprintf("big %d %s", i, strings.Repeat("b", 2400)))
+	}
+
+	opts := processOptions{SessionKey: sessionKey, UserMessage: "next question", Channel: "cli", ChatID: "direct"}
+	messages := agent.ContextBuilder.BuildMessages(
+		agent.Sessions.GetHistory(sessionKey), "", opts.UserMessage, nil, opts.Channel, opts.ChatID,
+	)
+	messages = al.fitContextWindow(agent, opts, messages)
+
+	history := agent.Sessions.GetHistory(sessionKey)
+	if len(history) != 2 {
+		t.Fatalf("history has %d messages, want the newest 2", len(history))
+	}
+	if !strings.HasPrefix(history[0].Content, "big 2 ") {
+		t.Errorf("history[0] = %q, want big 2", history[0].Content[:8])
+	}
+	toolTokens := tokenizer.CountTools(agent.Tools.ToProviderDefs())
+	if used := tokenizer.CountMessages(messages) + toolTokens; used > agent.promptBudget() {
+		t.Errorf("request uses %d tokens, budget is %d", used, agent.promptBudget())
+	}
+}
+
+// bulletsProvider answers every call with a bullet list and records the
+// model and prompt of each.
+type bulletsProvider struct {
+	mu      sync.Mutex
+	models  []string
+	prompts []string
+}
+
+func (p *bulletsProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	p.models = append(p.models, model)
+	p.prompts = append(p.prompts, messages[len(messages)-1].Content)
+	p.mu.Unlock()
+	return &providers.LLMResponse{Content: "- Flight booked for May 3"}, nil
+}
+
+func (p *bulletsProvider) GetDefaultModel() string { return "bullets" }
+
+func TestSummarizeSession_SessionStrategyAndModel(t *testing.T) {
+	provider := &bulletsProvider{}
+	al, agent := newContextWindowTestLoop(t, provider)
+	agent.Summarization.Model = "cheap"
+	sessionKey := "agent:main:main"
+	seedHistory(agent, sessionKey, 4)
+	agent.Sessions.SetSummarization(sessionKey, "bullets")
+
+	al.summarizeSession(agent, sessionKey)
+
+	if got := agent.Sessions.GetSummary(sessionKey); got != "- Flight booked for May 3" {
+		t.Errorf("summary = %q", got)
+	}
+	if got := len(agent.Sessions.GetHistory(sessionKey)); got != 4 {
+		t.Errorf("history has %d messages, want the last 4", got)
+	}
+	if len(provider.prompts) != 1 || !strings.HasPrefix(provider.prompts[0], "Extract the points") {
+		t.Errorf("prompts = %q, want one bullet extraction", provider.prompts)
+	}
+	if provider.models[0] != "cheap" {
+		t.Errorf("model = %q, want summarization.model", provider.models[0])
+	}
+}
+
+func TestSummarizeSession_TakesCheckpoint(t *testing.T) {
+	al, agent := newContextWindowTestLoop(t, &bulletsProvider{})
+	sessionKey := "agent:main:main"
+	seedHistory(agent, sessionKey, 4)
+	before := agent.Sessions.GetHistory(sessionKey)
+	agent.Sessions.SetSummarization(sessionKey, "bullets")
+
+	al.summarizeSession(agent, sessionKey)
+
+	checkpoints, err := agent.Sessions.Checkpoints(sessionKey)
+	if err != nil || len(checkpoints) != 1 || checkpoints[0].Label != "before summarize" {
+		t.Fatalf("Checkpoints = %+v, %v, want one taken before summarize", checkpoints, err)
+	}
+	if err := agent.Sessions.Rollback(sessionKey, checkpoints[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := agent.Sessions.GetHistory(sessionKey); len(got) != len(before) {
+		t.Errorf("history after rollback has %d messages, want %d", len(got), len(before))
+	}
+	if got := agent.Sessions.GetSummary(sessionKey); got != "" {
+		t.Errorf("summary after rollback = %q, want none", got)
+	}
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/digest"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/notify"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	digestPrompt = "Summarize this conversation between a user and an AI assistant in one or two " +
+		"sentences: what the user wanted and what came of it. Reply with the summary only."
+
+	// digestSessionKey is what the summaries are charged to in the usage
+	// ledger.
+	digestSessionKey = "digest"
+)
+
+// Digest reports what the agents did in [since, until). With
+// notifications.digest.summarize, each session whose history changed in the
+// period is summarized too; a summary that fails is left out.
+func (al *AgentLoop) Digest(ctx context.Context, since, until time.Time) (*digest.Report, error) {
+	// Agents that share a workspace share its history and logs.
+	var sources []digest.Source
+	seen := make(map[string]bool)
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok || seen[agent.Workspace] {
+			continue
+		}
+		seen[agent.Workspace] = true
+		sources = append(sources, digest.Source{
+			AgentID: id, Sessions: agent.Sessions, Usage: agent.Usage, ToolCalls: agent.ToolCalls,
+		})
+	}
+	report, err := digest.Build(ctx, sources, since, until)
+	if err != nil {
+		return nil, err
+	}
+	if al.cfg.Notifications.Digest.Summarize {
+		if err := report.Summarize(ctx, al.summarizeForDigest); err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			logger.WarnCF("digest", "Some sessions could not be summarized", map[string]any{"error": err.Error()})
+		}
+	}
+	return report, nil
+}
+
+// SendDigest sends the digest of [since, until) as a notification. Nothing
+// is sent for a period without activity.
+func (al *AgentLoop) SendDigest(ctx context.Context, since, until time.Time) error {
+	if al.notifier == nil {
+		return errors.New("notifications are not enabled")
+	}
+	report, err := al.Digest(ctx, since, until)
+	if err != nil {
+		return err
+	}
+	if report.Empty() {
+		logger.InfoCF("digest", "No activity, digest not sent", map[string]any{"since": since, "until": until})
+		return nil
+	}
+	// Validated with the config.
+	priority, _ := notify.ParsePriority(al.cfg.Notifications.Digest.Priority)
+	_, err = al.notifier.Notify(ctx, notify.Notification{
+		Title:    "Digest",
+		Message:  report.Text(),
+		Priority: priority,
+		Key:      "digest:" + until.Format(time.RFC3339),
+	})
+	return err
+}
+
+// summarizeForDigest asks the model of the session's agent, or the
+// configured digest model, for a summary of the session's excerpt.
+func (al *AgentLoop) summarizeForDigest(ctx context.Context, s *digest.Session) (string, error) {
+	agent, ok := al.registry.GetAgent(s.AgentID)
+	if !ok {
+		if agent = al.registry.GetDefaultAgent(); agent == nil {
+			return "", errors.New("no agent configured")
+		}
+	}
+	model := al.cfg.Notifications.Digest.Model
+	if model == "" {
+		model = agent.Model
+	}
+
+	var sb strings.Builder
+	for _, m := range s.Excerpt {
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
+	}
+	resp, err := agent.Provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: digestPrompt},
+		{Role: "user", Content: sb.String()},
+	}, nil, model, map[string]any{
+		"max_tokens":  256,
+		"temperature": 0.3,
+	})
+	if err != nil {
+		return "", err
+	}
+	al.recordUsage(agent, digestSessionKey, model, resp.Usage)
+	return resp.Content, nil
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestSendDigest(t *testing.T) {
+	payloads := make(chan map[string]string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]string
+		json.NewDecoder(r.Body).Decode(&p)
+		payloads <- p
+	}))
+	defer srv.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.Defaults.Model = "test-model"
+	cfg.Notifications.Enabled = true
+	cfg.Notifications.Sinks = []config.NotificationSink{{Name: "hook", Type: "webhook", URL: srv.URL}}
+	cfg.Notifications.Digest = config.DigestConfig{Enabled: true, Schedule: "0 21 * * *", Summarize: true}
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{Content: "Tulips like sun.", Usage: &providers.UsageInfo{TotalTokens: 30}},
+		&providers.LLMResponse{Content: "The user asked about tulips."},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	// Nothing happened yet, so nothing is sent.
+	since := time.Now().Add(-time.Minute)
+	if err := al.SendDigest(ctx, since, time.Now()); err != nil {
+		t.Fatalf("SendDigest on an empty period: %v", err)
+	}
+	select {
+	case p := <-payloads:
+		t.Fatalf("digest of an empty period sent: %v", p)
+	default:
+	}
+
+	if _, err := al.ProcessDirect(ctx, "what do tulips like?", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if err := al.SendDigest(ctx, since, time.Now().Add(time.Second)); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+	select {
+	case p := <-payloads:
+		if p["title"] != "Digest" || !strings.Contains(p["message"], "1 session(s), 1 model call(s), 30 tokens") ||
+			!strings.Contains(p["message"], "agent:main:main") ||
+			!strings.Contains(p["message"], "The user asked about tulips.") {
+			t.Errorf("digest = %v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("digest not sent")
+	}
+	if provider.Remaining() != 0 {
+		t.Error("the session was not summarized")
+	}
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// drainPoll is how often DrainTurns and FlushJobs check for remaining work.
+const drainPoll = 50 * time.Millisecond
+
+// DrainTurns waits until no turn is running or waiting, including the
+// messages still on the bus and the ProcessDirect calls, or until ctx is
+// done. Close the bus's inbound side and stop the API from starting turns
+// first, or new messages keep it waiting. The turns left when ctx is
+// done go on until the loop's own context is cancelled.
+func (al *AgentLoop) DrainTurns(ctx context.Context) error {
+	return al.waitFor(ctx, al.turnsIdle)
+}
+
+// FlushJobs waits for the background jobs and the spawned subagents to
+// finish, and for the turns their results start, or until ctx is done. It
+// then cancels the jobs still running.
+func (al *AgentLoop) FlushJobs(ctx context.Context) error {
+	err := al.waitFor(ctx, func() bool {
+		return al.backgroundIdle() && al.turnsIdle()
+	})
+	if err != nil && al.jobs != nil {
+		if n := al.jobs.CancelAll(); n > 0 {
+			logger.WarnCF("agent", "Background jobs cancelled at shutdown", map[string]any{"jobs": n})
+		}
+	}
+	return err
+}
+
+func (al *AgentLoop) turnsIdle() bool {
+	if al.bus.PendingInbound() > 0 {
+		return false
+	}
+	return al.scheduler.idle()
+}
+
+func (al *AgentLoop) backgroundIdle() bool {
+	if al.jobs != nil && al.jobs.Running() > 0 {
+		return false
+	}
+	for _, sm := range al.subagents {
+		if sm.Running() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// waitFor polls idle until it holds twice in a row, which covers a message
+// the loop has taken off the bus but not yet scheduled, or until ctx is done.
+func (al *AgentLoop) waitFor(ctx context.Context, idle func() bool) error {
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	streak := 0
+	for {
+		if idle() {
+			streak++
+		} else {
+			streak = 0
+		}
+		if streak >= 2 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
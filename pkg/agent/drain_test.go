@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// gatedProvider answers once release is closed.
+type gatedProvider struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *gatedProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.started <- struct{}{}
+	<-p.release
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (p *gatedProvider) GetDefaultModel() string { return "gated" }
+
+func drainTestConfig(t *testing.T) *config.Config {
+	return &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+}
+
+func TestDrainTurns(t *testing.T) {
+	provider := &gatedProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(drainTestConfig(t), msgBus, provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go al.Run(ctx)
+
+	msgBus.PublishInbound(ctx, bus.InboundMessage{
+		Channel: "telegram", SenderID: "telegram:1", ChatID: "1", Content: "hello",
+	})
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not start")
+	}
+	msgBus.CloseInbound()
+
+	short, shortCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer shortCancel()
+	if err := al.DrainTurns(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DrainTurns with a turn running = %v", err)
+	}
+
+	close(provider.release)
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	if err := al.DrainTurns(waitCtx); err != nil {
+		t.Fatalf("DrainTurns: %v", err)
+	}
+	if msg, ok := msgBus.SubscribeOutbound(waitCtx); !ok || msg.Content != "done" {
+		t.Errorf("reply = %+v, %v", msg, ok)
+	}
+}
+
+func TestDrainTurns_WaitsForDirectTurns(t *testing.T) {
+	provider := &gatedProvider{started: make(chan struct{}, 1), release: make(chan struct{})}
+	al := NewAgentLoop(drainTestConfig(t), bus.NewMessageBus(), provider)
+
+	done := make(chan string, 1)
+	go func() {
+		response, _ := al.ProcessDirectWithCallbacks(context.Background(), "hello", "agent:main:api:s1",
+			"api", "s1", StreamCallbacks{})
+		done <- response
+	}()
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not start")
+	}
+
+	short, shortCancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer shortCancel()
+	if err := al.DrainTurns(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DrainTurns with a direct turn running = %v", err)
+	}
+
+	close(provider.release)
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer waitCancel()
+	if err := al.DrainTurns(waitCtx); err != nil {
+		t.Fatalf("DrainTurns: %v", err)
+	}
+	if response := <-done; response != "done" {
+		t.Errorf("response = %q, want %q", response, "done")
+	}
+}
+
+func TestFlushJobs_CancelsAtDeadline(t *testing.T) {
+	al := NewAgentLoop(drainTestConfig(t), bus.NewMessageBus(), &simpleMockProvider{response: "ok"})
+	stopped := make(chan struct{})
+	job := al.jobs.Start(context.Background(), "exec", func(ctx context.Context) *tools.ToolResult {
+		<-ctx.Done()
+		close(stopped)
+		return tools.ErrorResult("cancelled")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := al.FlushJobs(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FlushJobs = %v", err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job %s was not cancelled", job.ID)
+	}
+	if n := al.jobs.Running(); n != 0 {
+		t.Errorf("%d jobs still running", n)
+	}
+}
@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Options configures an agent that another Go program embeds with New.
+// Every field may be left out.
+type Options struct {
+	// Config is the configuration, as config.LoadConfig returns it; nil
+	// for config.DefaultConfig().
+	Config *config.Config
+
+	// Provider answers the model calls; nil creates the one the config
+	// names, as the gateway does.
+	Provider providers.LLMProvider
+
+	// Channels are created with the agent's bus and run next to the
+	// channels the config enables, under the given names.
+	Channels map[string]channels.ChannelFactory
+
+	// Tools are registered with every agent, after the built-in ones.
+	Tools []tools.Tool
+
+	// Sessions returns where the agent with the given ID keeps its
+	// conversations; nil, or a nil Store, keeps them as files in the
+	// agent's workspace.
+	Sessions func(agentID string) session.Store
+}
+
+// Agent is picoclaw embedded in another program: the agent loop with its
+// bus, channels and media store, wired as the gateway wires them.
+type Agent struct {
+	bus      *bus.MessageBus
+	loop     *AgentLoop
+	channels *channels.Manager
+	media    *media.FileMediaStore
+	provider providers.StatefulProvider // created by New, closed by Close
+}
+
+// New creates an agent from opts. It does not start anything: Run serves
+// the channels, Ask answers a message directly, and Close releases what
+// New acquired.
+func New(opts Options) (*Agent, error) {
+	cfg := opts.Config
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	// NewAgentLoop treats bad guardrails as fatal, which a library must not.
+	if _, err := guardrails.New(cfg.Guardrails); err != nil {
+		return nil, fmt.Errorf("guardrails: %w", err)
+	}
+
+	a := &Agent{bus: bus.NewMessageBus()}
+	provider := opts.Provider
+	if provider == nil {
+		var modelID string
+		var err error
+		if provider, modelID, err = providers.CreateProvider(cfg); err != nil {
+			return nil, fmt.Errorf("creating provider: %w", err)
+		}
+		if modelID != "" {
+			cfg.Agents.Defaults.ModelName = modelID
+		}
+		a.provider, _ = provider.(providers.StatefulProvider)
+	}
+
+	a.loop = NewAgentLoop(cfg, a.bus, provider)
+	if opts.Sessions != nil {
+		for _, id := range a.loop.registry.ListAgentIDs() {
+			instance, _ := a.loop.registry.GetAgent(id)
+			store := opts.Sessions(id)
+			if store == nil {
+				continue
+			}
+			if err := instance.Sessions.SetStore(piiStore(cfg.Session.PII, store)); err != nil {
+				a.Close()
+				return nil, fmt.Errorf("loading the sessions of agent %s: %w", id, err)
+			}
+		}
+	}
+	for _, tool := range opts.Tools {
+		a.loop.RegisterTool(tool)
+	}
+
+	a.media = media.NewFileMediaStoreWithCleanup(media.MediaCleanerConfig{
+		Enabled:  cfg.Tools.MediaCleanup.Enabled,
+		MaxAge:   time.Duration(cfg.Tools.MediaCleanup.MaxAge) * time.Minute,
+		Interval: time.Duration(cfg.Tools.MediaCleanup.Interval) * time.Minute,
+	})
+	var err error
+	if a.channels, err = channels.NewManager(cfg, a.bus, a.media); err != nil {
+		a.Close()
+		return nil, fmt.Errorf("creating channel manager: %w", err)
+	}
+	for name, factory := range opts.Channels {
+		ch, err := factory(cfg, a.bus)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("creating channel %s: %w", name, err)
+		}
+		a.channels.RegisterChannel(name, ch)
+	}
+	a.loop.SetChannelManager(a.channels)
+	a.loop.SetMediaStore(a.media)
+	return a, nil
+}
+
+// Loop returns the agent loop, for what Agent does not cover.
+func (a *Agent) Loop() *AgentLoop {
+	return a.loop
+}
+
+// Bus returns the bus the channels and the loop exchange messages on.
+func (a *Agent) Bus() *bus.MessageBus {
+	return a.bus
+}
+
+// Run serves the channels until ctx is done, then stops them.
+func (a *Agent) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	a.media.Start()
+	if len(a.channels.GetEnabledChannels()) > 0 {
+		if err := a.channels.StartAll(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			stopCtx, stop := context.WithTimeout(context.Background(), 15*time.Second)
+			defer stop()
+			a.channels.StopAll(stopCtx)
+		}()
+	}
+	return a.loop.Run(ctx)
+}
+
+// Ask answers content in the conversation sessionKey and returns the
+// reply, without going through a channel. A key that does not start with
+// "agent:" is mapped to one by the routing rules, as for the CLI. Ask works
+// whether or not Run is serving the channels.
+func (a *Agent) Ask(ctx context.Context, content, sessionKey string) (string, error) {
+	return a.loop.ProcessDirect(ctx, content, sessionKey)
+}
+
+// Close releases the media store, the bus and a provider New created. Call
+// it after Run returned.
+func (a *Agent) Close() {
+	a.loop.Stop()
+	if a.media != nil {
+		a.media.Stop()
+	}
+	if a.provider != nil {
+		a.provider.Close()
+	}
+	a.bus.Close()
+}
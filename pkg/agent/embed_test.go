@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// memStore is a session.Store that keeps the sessions in a map.
+type memStore struct {
+	mu       sync.Mutex
+	sessions map[string]session.Session
+}
+
+func (m *memStore) Load() ([]session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []session.Session
+	for _, s := range m.sessions {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (m *memStore) Save(s session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.Key] = s
+	return nil
+}
+
+func (m *memStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, key)
+	return nil
+}
+
+func TestNew_Embedded(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.Defaults.Model = "test-model"
+
+	store := &memStore{sessions: map[string]session.Session{
+		"app:1": {Key: "app:1", Summary: "about tulips"},
+	}}
+	var storeFor []string
+	a, err := New(Options{
+		Config:   cfg,
+		Provider: &mockProvider{},
+		Channels: map[string]channels.ChannelFactory{
+			"app": func(*config.Config, *bus.MessageBus) (channels.Channel, error) { return &fakeChannel{}, nil },
+		},
+		Tools: []tools.Tool{&mockCustomTool{}},
+		Sessions: func(agentID string) session.Store {
+			storeFor = append(storeFor, agentID)
+			return store
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer a.Close()
+
+	if len(storeFor) != 1 || storeFor[0] != "main" {
+		t.Errorf("Sessions called for %v, want [main]", storeFor)
+	}
+	_, sessions := a.Loop().DefaultAgentSessions()
+	if got := sessions.GetSummary("app:1"); got != "about tulips" {
+		t.Errorf("summary loaded from the store = %q", got)
+	}
+	agent := a.Loop().registry.GetDefaultAgent()
+	if _, ok := agent.Tools.Get("mock_custom"); !ok {
+		t.Error("custom tool not registered")
+	}
+	if _, ok := a.channels.GetChannel("app"); !ok {
+		t.Error("custom channel not registered")
+	}
+
+	reply, err := a.Ask(context.Background(), "hello", "agent:main:app:2")
+	if err != nil {
+		t.Fatalf("Ask: %v", err)
+	}
+	if reply != "Mock response" {
+		t.Errorf("reply = %q", reply)
+	}
+	store.mu.Lock()
+	saved, ok := store.sessions["agent:main:app:2"]
+	store.mu.Unlock()
+	if !ok || len(saved.Messages) != 2 {
+		t.Errorf("session saved to the store = %+v, %v; want the turn's two messages", saved, ok)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was canceled")
+	}
+}
+
+func TestNew_InvalidGuardrails(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Guardrails.Enabled = true
+	cfg.Guardrails.Redact = []string{"("}
+
+	if _, err := New(Options{Config: cfg, Provider: &mockProvider{}}); err == nil {
+		t.Error("New accepted a guardrail pattern that does not compile")
+	}
+}
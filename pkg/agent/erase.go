@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/erasure"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// EraseUser deletes the direct conversations of a channel user and the
+// other accounts linked to them, with the facts, tasks, attachments, usage
+// records, tool calls, traces and audit entries that belong to them. With
+// dryRun it only reports what would go.
+func (al *AgentLoop) EraseUser(ctx context.Context, userID string, dryRun bool) (*erasure.Report, error) {
+	eraser := &erasure.Eraser{Links: al.identities, Audit: al.audit, Media: al.mediaStore}
+	// Agents that share a workspace share its stores.
+	seen := make(map[string]bool)
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok || seen[agent.Workspace] {
+			continue
+		}
+		seen[agent.Workspace] = true
+		eraser.Workspaces = append(eraser.Workspaces, erasure.Workspace{
+			Sessions:  agent.Sessions,
+			Facts:     agent.Facts,
+			Tasks:     agent.Tasks,
+			Usage:     agent.Usage,
+			ToolCalls: agent.ToolCalls,
+			Traces:    agent.Traces,
+		})
+	}
+	report, err := eraser.Erase(ctx, userID, dryRun)
+	if report != nil && !dryRun {
+		logger.InfoCF("agent", "User erased", map[string]any{
+			"sessions": len(report.Sessions), "facts": report.Facts, "audit_entries": report.AuditEntries,
+		})
+	}
+	return report, err
+}
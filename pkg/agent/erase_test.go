@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestEraseUser(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.Defaults.Model = "test-model"
+	cfg.Session.DMScope = "per-channel-peer"
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{})
+	helper := testHelper{al: al}
+	ctx := context.Background()
+
+	for _, user := range []string{"user1", "user2"} {
+		helper.executeAndGetResponse(t, ctx, bus.InboundMessage{
+			Channel: "whatsapp", SenderID: user, ChatID: user, Content: "hello",
+			Peer: bus.Peer{Kind: "direct", ID: user},
+		})
+	}
+	agent := al.registry.GetDefaultAgent()
+	key := "agent:main:whatsapp:direct:user1"
+	if !slices.Contains(agent.Sessions.Keys(), key) {
+		t.Fatalf("sessions = %v, want %s", agent.Sessions.Keys(), key)
+	}
+
+	report, err := al.EraseUser(ctx, "whatsapp:user1", true)
+	if err != nil || len(report.Sessions) != 1 || report.Sessions[0] != key || report.Traces != 1 {
+		t.Fatalf("dry run = %+v, %v", report, err)
+	}
+	if !slices.Contains(agent.Sessions.Keys(), key) {
+		t.Fatal("dry run deleted the session")
+	}
+
+	if _, err := al.EraseUser(ctx, "whatsapp:user1", false); err != nil {
+		t.Fatal(err)
+	}
+	if keys := agent.Sessions.Keys(); slices.Contains(keys, key) || len(keys) != 1 {
+		t.Errorf("sessions after erasure = %v, want only user2's", keys)
+	}
+	if turns, _ := agent.Traces.Turns(ctx, key); len(turns) != 0 {
+		t.Errorf("traces of the erased session = %+v", turns)
+	}
+}
@@ -0,0 +1,44 @@
+package agent
+
+import (
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+var errNoAnswer = errors.New("there is no answer to rate yet")
+
+// recordFeedback attaches the feedback a channel passed on, such as a 👍
+// on an answer, to the answer in the session of the chat. It runs no turn
+// and sends no reply.
+func (al *AgentLoop) recordFeedback(msg bus.InboundMessage) {
+	route, agent, err := al.resolveMessageRoute(msg)
+	if err != nil {
+		logger.WarnCF("agent", "Cannot route feedback", map[string]any{"channel": msg.Channel, "error": err.Error()})
+		return
+	}
+	key := resolveScopeKey(route, msg.SessionKey)
+	if err := addFeedback(agent, key, accountOf(msg), *msg.Feedback); err != nil {
+		logger.DebugCF("agent", "Feedback not recorded", map[string]any{"session_key": key, "error": err.Error()})
+	}
+}
+
+// addFeedback attaches fb from the account from to an answer of the
+// session key and stores the session.
+func addFeedback(agent *AgentInstance, key, from string, fb bus.Feedback) error {
+	if !agent.Sessions.AddFeedback(key, fb.Answer, session.Feedback{
+		Rating:     fb.Rating,
+		Correction: fb.Correction,
+		From:       from,
+	}) {
+		return errNoAnswer
+	}
+	logger.InfoCF("agent", "Recorded feedback", map[string]any{
+		"session_key": key,
+		"rating":      fb.Rating,
+		"correction":  fb.Correction != "",
+	})
+	return agent.Sessions.Save(key)
+}
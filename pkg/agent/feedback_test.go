@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestFeedback_CommandAndReaction(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.Defaults.Model = "test-model"
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{Content: "Lyon is the capital of France."},
+		&providers.LLMResponse{Content: "It has about 2 million people."},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+	ctx := context.Background()
+	for _, q := range []string{"What is the capital of France?", "How many people live there?"} {
+		if _, err := al.ProcessDirect(ctx, q, "cli:direct"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A reaction names the answer it rates.
+	al.recordFeedback(bus.InboundMessage{
+		Channel:  "cli",
+		SenderID: "cron",
+		ChatID:   "direct",
+		Feedback: &bus.Feedback{Rating: session.RatingBad, Answer: "Lyon is the capital"},
+	})
+	reply, err := al.ProcessDirect(ctx, "/feedback bad Paris has about 2.1 million.", "cli:direct")
+	if err != nil || reply != "Thanks, noted." {
+		t.Fatalf("/feedback = %q, %v", reply, err)
+	}
+
+	low := agent.Sessions.ListFeedback(session.FeedbackFilter{Low: true})
+	if len(low) != 2 {
+		t.Fatalf("low-rated answers = %+v", low)
+	}
+	byAnswer := map[string]session.RatedAnswer{}
+	for _, fb := range low {
+		byAnswer[fb.Answer] = fb
+	}
+	if fb := byAnswer["Lyon is the capital of France."]; fb.Question != "What is the capital of France?" {
+		t.Errorf("reaction feedback = %+v", fb)
+	}
+	if fb := byAnswer["It has about 2 million people."]; fb.Correction != "Paris has about 2.1 million." {
+		t.Errorf("command feedback = %+v", fb)
+	}
+	if history := agent.Sessions.GetHistory("agent:main:main"); len(history) != 4 {
+		t.Errorf("feedback changed the history: %d messages", len(history))
+	}
+}
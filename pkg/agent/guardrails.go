@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"maps"
+
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// AddGuardrail adds rule to the filters every message, reply and tool
+// result goes through, after the configured ones.
+func (al *AgentLoop) AddGuardrail(rule guardrails.Rule) {
+	al.guardrails.Add(rule)
+}
+
+// guard runs text through the guardrails for stage and logs what they found,
+// with fields saying where the text was going.
+func (al *AgentLoop) guard(stage guardrails.Stage, text string, fields map[string]any) guardrails.Result {
+	res := al.guardrails.Filter(stage, text)
+	logViolations(stage, res, fields)
+	return res
+}
+
+func logViolations(stage guardrails.Stage, res guardrails.Result, fields map[string]any) {
+	for _, v := range res.Violations {
+		action := "redacted"
+		if v.Blocked {
+			action = "blocked"
+		}
+		f := maps.Clone(fields)
+		if f == nil {
+			f = map[string]any{}
+		}
+		f["stage"] = string(stage)
+		f["rule"] = v.Rule
+		f["category"] = v.Category
+		f["action"] = action
+		f["matches"] = v.Matches
+		logger.WarnCF("guardrails", "Guardrail violation", f)
+	}
+}
+
+// guardToolResult filters what a tool returned for the model and the user.
+// An error without content for the model becomes its content, so that it is
+// filtered too.
+func (al *AgentLoop) guardToolResult(result *tools.ToolResult, fields map[string]any) {
+	if result.ForLLM == "" && result.Err != nil {
+		result.ForLLM = result.Err.Error()
+	}
+	result.ForLLM = al.guard(guardrails.ToolResult, result.ForLLM, fields).Text
+	result.ForUser = al.guard(guardrails.ToolResult, result.ForUser, fields).Text
+}
+
+// turnFields describes a turn in guardrail logs.
+func turnFields(agent *AgentInstance, opts processOptions) map[string]any {
+	return map[string]any{"agent_id": agent.ID, "session_key": opts.SessionKey, "channel": opts.Channel}
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestProcessDirect_Guardrails(t *testing.T) {
+	workspace := t.TempDir()
+	secret := "sk-proj-abcdefghijklmnopqrstuvwxyz123456"
+	if err := os.WriteFile(filepath.Join(workspace, ".env"), []byte("OPENAI_API_KEY="+secret), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+			},
+		},
+		Tools: config.ToolsConfig{ReadFile: config.ToolConfig{Enabled: true}},
+		Guardrails: config.GuardrailsConfig{
+			Enabled:       true,
+			RedactSecrets: true,
+			Categories: map[string]config.GuardrailCategory{
+				"weapons": {Patterns: []string{`build a bomb`}, Stages: []string{"input"}, Message: "I can't help with that."},
+			},
+		},
+	}
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": ".env"},
+		}}},
+		&providers.LLMResponse{Content: "The key is " + secret},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	reply, err := al.ProcessDirect(ctx, "How do I build a bomb?", "cli:direct")
+	if err != nil || reply != "I can't help with that." {
+		t.Fatalf("blocked reply = %q, err = %v", reply, err)
+	}
+	if provider.Remaining() != 2 {
+		t.Error("a blocked message reached the model")
+	}
+
+	reply, err = al.ProcessDirect(ctx, "what is in .env?", "cli:direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "The key is [REDACTED]" {
+		t.Errorf("reply = %q, want the key redacted", reply)
+	}
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	for _, m := range history {
+		if strings.Contains(m.Content, secret) {
+			t.Errorf("%s message in history holds the secret: %q", m.Role, m.Content)
+		}
+		if strings.Contains(m.Content, "bomb") {
+			t.Errorf("blocked message was saved: %q", m.Content)
+		}
+	}
+}
+
+type denyRule struct{}
+
+func (denyRule) Name() string { return "deny" }
+
+func (denyRule) Check(stage guardrails.Stage, text string) (string, *guardrails.Violation) {
+	if stage == guardrails.Output {
+		return text, &guardrails.Violation{Blocked: true, Message: "denied"}
+	}
+	return text, nil
+}
+
+func TestAgentLoop_AddGuardrail(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "hello"})
+	al.AddGuardrail(denyRule{})
+	reply, err := al.ProcessDirect(context.Background(), "hi", "cli:direct")
+	if err != nil || reply != "denied" {
+		t.Errorf("reply = %q, err = %v, want the custom rule to block it", reply, err)
+	}
+}
@@ -7,11 +7,17 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/pii"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tasks"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -24,6 +30,10 @@ type AgentInstance struct {
 	Fallbacks                 []string
 	Workspace                 string
 	MaxIterations             int
+	MaxParallelTools          int           // 0 runs every tool call of a turn at once
+	MaxHistoryMessages        int           // 0 loads the whole session history for a turn
+	MaxTurnDuration           time.Duration // 0 means no limit
+	MaxRepeatedToolCalls      int           // identical calls allowed per turn; 0 disables loop detection
 	MaxTokens                 int
 	Temperature               float64
 	ThinkingLevel             ThinkingLevel
@@ -32,6 +42,10 @@ type AgentInstance struct {
 	SummarizeTokenPercent     int
 	Provider                  providers.LLMProvider
 	Sessions                  *session.SessionManager
+	Usage                     *memory.UsageLedger
+	ToolCalls                 *memory.ToolCallLog
+	Facts                     *memory.FactStore // nil when the memory tools are disabled
+	Tasks                     *memory.TaskStore // nil when the tasks tool is disabled
 	ContextBuilder            *ContextBuilder
 	Tools                     *tools.ToolRegistry
 	Subagents                 *config.SubagentsConfig
@@ -39,12 +53,41 @@ type AgentInstance struct {
 	Candidates                []providers.FallbackCandidate
 
 	// Router is non-nil when model routing is configured and the light model
-	// was successfully resolved. It scores each incoming message and decides
-	// whether to route to LightCandidates or stay with Candidates.
+	// or at least one task route was successfully resolved. It scores each
+	// incoming message and decides whether to route to RouteCandidates,
+	// LightCandidates or stay with Candidates.
 	Router *routing.Router
 	// LightCandidates holds the resolved provider candidates for the light model.
 	// Pre-computed at agent creation to avoid repeated model_list lookups at runtime.
 	LightCandidates []providers.FallbackCandidate
+	// RouteCandidates holds the resolved provider candidates per task class route.
+	RouteCandidates map[routing.TaskClass][]providers.FallbackCandidate
+	// Personas are the profiles a session can switch to, by name. They
+	// change when the config is reloaded, so use personaNamed.
+	Personas   map[string]*Persona
+	personasMu sync.RWMutex
+	// Recall configures the memories put into the prompt of each turn.
+	Recall config.RecallConfig
+	// Reflection configures the review of answers before they are sent; nil
+	// when it is not configured.
+	Reflection *config.ReflectionConfig
+	// BudgetModel is the model turns switch to once a budget is used up, and
+	// BudgetCandidates its resolved candidates; empty when turns are refused.
+	BudgetModel      string
+	BudgetCandidates []providers.FallbackCandidate
+	// Plans holds the plans made with /plan; nil when they cannot be stored.
+	Plans        *tasks.Queue
+	MaxPlanSteps int
+	// Traces keeps how recent turns went; nil when traces are off.
+	Traces *memory.TraceLog
+	// Topics configures the division of sessions into topics.
+	Topics config.TopicsConfig
+	// Summarization chooses how history is summarized when it grows too long.
+	Summarization config.SummarizationConfig
+
+	// skillTools registers the tools of the workspace's skills; nil when
+	// tools.skill_tools and tools.wasm are both off.
+	skillTools *skillToolSet
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -68,16 +111,34 @@ func NewAgentInstance(
 	allowWritePaths := compilePatterns(cfg.Tools.AllowWritePaths)
 
 	toolsRegistry := tools.NewToolRegistry()
+	configureToolTimeouts(toolsRegistry, &cfg.Tools)
+	// The store is set up even when no tool is listed, because tools may
+	// ask for approval of individual calls (see tools.ApprovalGate). The
+	// file is only written once something is waiting.
+	approval := cfg.Tools.Approval
+	approvals := tools.NewApprovalStore(filepath.Join(workspace, "approvals", "pending.json"),
+		time.Duration(approval.ExpiryMinutes)*time.Minute)
+	toolsRegistry.RequireApproval(approvals, approval.Tools)
+	toolsRegistry.SetPolicy(tools.NewToolPolicy(cfg.Tools.Policy))
+
+	maxFileBytes := cfg.Tools.MaxFileBytes
 
 	if cfg.Tools.IsToolEnabled("read_file") {
-		toolsRegistry.Register(tools.NewReadFileTool(workspace, readRestrict, allowReadPaths))
+		readTool := tools.NewReadFileTool(workspace, readRestrict, allowReadPaths)
+		readTool.SetMaxFileBytes(maxFileBytes)
+		toolsRegistry.Register(readTool)
 	}
 	if cfg.Tools.IsToolEnabled("write_file") {
-		toolsRegistry.Register(tools.NewWriteFileTool(workspace, restrict, allowWritePaths))
+		writeTool := tools.NewWriteFileTool(workspace, restrict, allowWritePaths)
+		writeTool.SetMaxFileBytes(maxFileBytes)
+		toolsRegistry.Register(writeTool)
 	}
 	if cfg.Tools.IsToolEnabled("list_dir") {
 		toolsRegistry.Register(tools.NewListDirTool(workspace, readRestrict, allowReadPaths))
 	}
+	if cfg.Tools.IsToolEnabled("glob") {
+		toolsRegistry.Register(tools.NewGlobTool(workspace, readRestrict, allowReadPaths))
+	}
 	if cfg.Tools.IsToolEnabled("exec") {
 		execTool, err := tools.NewExecToolWithConfig(workspace, restrict, cfg)
 		if err != nil {
@@ -87,14 +148,80 @@ func NewAgentInstance(
 	}
 
 	if cfg.Tools.IsToolEnabled("edit_file") {
-		toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict, allowWritePaths))
+		editTool := tools.NewEditFileTool(workspace, restrict, allowWritePaths)
+		editTool.SetMaxFileBytes(maxFileBytes)
+		toolsRegistry.Register(editTool)
 	}
 	if cfg.Tools.IsToolEnabled("append_file") {
-		toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict, allowWritePaths))
+		appendTool := tools.NewAppendFileTool(workspace, restrict, allowWritePaths)
+		appendTool.SetMaxFileBytes(maxFileBytes)
+		toolsRegistry.Register(appendTool)
+	}
+
+	sessionsManager := session.NewSessionManager("")
+	if store, err := session.NewStore(filepath.Join(workspace, "sessions"), cfg.SessionKey()); err != nil {
+		logger.ErrorCF("agent", "Session store unavailable; conversations are kept in memory only",
+			map[string]any{"error": err.Error()})
+	} else {
+		sessionsManager.SetStore(piiStore(cfg.Session.PII, store))
+	}
+	sessionsManager.SetCompactThreshold(cfg.Session.CompactThreshold)
+
+	usageLedger, err := memory.NewUsageLedger(filepath.Join(workspace, "usage"))
+	if err != nil {
+		logger.WarnCF("agent", "Usage ledger unavailable", map[string]any{"error": err.Error()})
+	}
+	toolCalls, err := memory.NewToolCallLog(filepath.Join(workspace, "usage"))
+	if err != nil {
+		logger.WarnCF("agent", "Tool call log unavailable", map[string]any{"error": err.Error()})
+	} else {
+		toolsRegistry.SetCallLog(toolCalls)
+	}
+
+	plans, err := tasks.NewQueue(filepath.Join(workspace, "tasks"))
+	if err != nil {
+		logger.WarnCF("agent", "Plan queue unavailable", map[string]any{"error": err.Error()})
+	}
+
+	var traces *memory.TraceLog
+	if defaults.Traces.Enabled {
+		if traces, err = memory.NewTraceLog(filepath.Join(workspace, "traces"), defaults.Traces.Keep); err != nil {
+			logger.WarnCF("agent", "Turn traces unavailable", map[string]any{"error": err.Error()})
+		}
 	}
 
-	sessionsDir := filepath.Join(workspace, "sessions")
-	sessionsManager := session.NewSessionManager(sessionsDir)
+	var facts *memory.FactStore
+	if cfg.Tools.IsToolEnabled("memory") {
+		facts = newFactStore(workspace, cfg, defaults)
+		if facts != nil {
+			for _, tool := range tools.NewMemoryTools(facts) {
+				toolsRegistry.Register(tool)
+			}
+		}
+	}
+
+	var taskStore *memory.TaskStore
+	if cfg.Tools.IsToolEnabled("tasks") {
+		if taskStore, err = memory.NewTaskStore(filepath.Join(workspace, "memory")); err != nil {
+			logger.WarnCF("agent", "Task list unavailable", map[string]any{"error": err.Error()})
+		} else {
+			toolsRegistry.Register(tools.NewTasksTool(taskStore))
+		}
+	}
+
+	if cfg.Tools.IsToolEnabled("scratchpad") {
+		toolsRegistry.Register(tools.NewScratchpadTool(sessionsManager))
+	}
+
+	if defaults.Topics.Enabled {
+		toolsRegistry.Register(tools.NewTopicsTool(sessionsManager))
+	}
+
+	if cfg.Tools.IsToolEnabled("memory_query") {
+		queryTool := tools.NewMemoryQueryTool(memoryQueryTables(sessionsManager, usageLedger, toolCalls, facts, taskStore))
+		queryTool.SetCacheKiB(cfg.Tools.MemoryQuery.CacheKiB)
+		toolsRegistry.Register(queryTool)
+	}
 
 	contextBuilder := NewContextBuilder(workspace)
 
@@ -109,6 +236,9 @@ func NewAgentInstance(
 		subagents = agentCfg.Subagents
 		skillsFilter = agentCfg.Skills
 	}
+	if p := defaults.Prompt; p != nil {
+		contextBuilder.SetPrompt(p.Persona, p.Channels, promptVariables(p.Variables, workspace, agentID, agentName, model))
+	}
 
 	maxIter := defaults.MaxToolIterations
 	if maxIter == 0 {
@@ -125,9 +255,14 @@ func NewAgentInstance(
 		temperature = *defaults.Temperature
 	}
 
+	// Without a configured window, max_tokens doubles as the context size.
+	contextWindow := maxTokens
 	var thinkingLevelStr string
 	if mc, err := cfg.GetModelConfig(model); err == nil {
 		thinkingLevelStr = mc.ThinkingLevel
+		if mc.ContextWindow > 0 {
+			contextWindow = mc.ContextWindow
+		}
 	}
 	thinkingLevel := parseThinkingLevel(thinkingLevelStr)
 
@@ -146,7 +281,129 @@ func NewAgentInstance(
 		Primary:   model,
 		Fallbacks: fallbacks,
 	}
-	resolveFromModelList := func(raw string) (string, bool) {
+	resolveFromModelList := modelListLookup(cfg)
+
+	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, resolveFromModelList)
+
+	// Model routing setup: pre-resolve light model and task route candidates at
+	// creation time to avoid repeated model_list lookups on every incoming message.
+	var router *routing.Router
+	var lightCandidates []providers.FallbackCandidate
+	var routeCandidates map[routing.TaskClass][]providers.FallbackCandidate
+	if rc := defaults.Routing; rc != nil && rc.Enabled {
+		routerCfg := routing.RouterConfig{Threshold: rc.Threshold}
+		if rc.LightModel != "" {
+			lightModelCfg := providers.ModelConfig{Primary: rc.LightModel}
+			resolved := providers.ResolveCandidatesWithLookup(lightModelCfg, defaults.Provider, resolveFromModelList)
+			if len(resolved) > 0 {
+				routerCfg.LightModel = rc.LightModel
+				lightCandidates = resolved
+			} else {
+				log.Printf("routing: light_model %q not found in model_list — complexity routing disabled for agent %q",
+					rc.LightModel, agentID)
+			}
+		}
+		for class, modelName := range rc.Routes {
+			if modelName == "" {
+				continue
+			}
+			routeCfg := providers.ModelConfig{Primary: modelName}
+			resolved := providers.ResolveCandidatesWithLookup(routeCfg, defaults.Provider, resolveFromModelList)
+			if len(resolved) == 0 {
+				log.Printf("routing: route %q model %q not found in model_list — route ignored for agent %q",
+					class, modelName, agentID)
+				continue
+			}
+			if routeCandidates == nil {
+				routeCandidates = make(map[routing.TaskClass][]providers.FallbackCandidate)
+				routerCfg.Routes = make(map[routing.TaskClass]string)
+			}
+			routeCandidates[routing.TaskClass(class)] = resolved
+			routerCfg.Routes[routing.TaskClass(class)] = modelName
+		}
+		if len(lightCandidates) > 0 || len(routeCandidates) > 0 {
+			router = routing.New(routerCfg)
+		}
+	}
+
+	personas := newPersonas(defaults.Personas, personaResolver(cfg, defaults), agentID)
+
+	var budgetModel string
+	var budgetCandidates []providers.FallbackCandidate
+	if fm := cfg.Budgets.FallbackModel; fm != "" && cfg.Budgets.Enabled {
+		budgetCandidates = providers.ResolveCandidatesWithLookup(
+			providers.ModelConfig{Primary: fm}, defaults.Provider, resolveFromModelList)
+		if len(budgetCandidates) > 0 {
+			budgetModel = fm
+		} else {
+			log.Printf("budgets: fallback_model %q not found in model_list — turns over budget are refused for agent %q",
+				fm, agentID)
+		}
+	}
+
+	var skillTools *skillToolSet
+	if cfg.Tools.IsToolEnabled("skill_tools") || cfg.Tools.IsToolEnabled("wasm") {
+		skillTools = newSkillToolSet(contextBuilder.skillsLoader, workspace, skillsFilter, &cfg.Tools)
+		skillTools.reload(toolsRegistry)
+	}
+
+	return &AgentInstance{
+		ID:                        agentID,
+		Name:                      agentName,
+		Model:                     model,
+		Fallbacks:                 fallbacks,
+		Workspace:                 workspace,
+		MaxIterations:             maxIter,
+		MaxParallelTools:          defaults.MaxParallelTools,
+		MaxHistoryMessages:        defaults.MaxHistoryMessages,
+		MaxTurnDuration:           time.Duration(defaults.MaxTurnSeconds) * time.Second,
+		MaxRepeatedToolCalls:      defaults.MaxRepeatedToolCalls,
+		MaxTokens:                 maxTokens,
+		Temperature:               temperature,
+		ThinkingLevel:             thinkingLevel,
+		ContextWindow:             contextWindow,
+		SummarizeMessageThreshold: summarizeMessageThreshold,
+		SummarizeTokenPercent:     summarizeTokenPercent,
+		Provider:                  provider,
+		Sessions:                  sessionsManager,
+		Usage:                     usageLedger,
+		ToolCalls:                 toolCalls,
+		Facts:                     facts,
+		Tasks:                     taskStore,
+		ContextBuilder:            contextBuilder,
+		Tools:                     toolsRegistry,
+		Subagents:                 subagents,
+		SkillsFilter:              skillsFilter,
+		Candidates:                candidates,
+		Router:                    router,
+		LightCandidates:           lightCandidates,
+		RouteCandidates:           routeCandidates,
+		Personas:                  personas,
+		Recall:                    defaults.Recall,
+		Reflection:                defaults.Reflection,
+		BudgetModel:               budgetModel,
+		BudgetCandidates:          budgetCandidates,
+		Plans:                     plans,
+		MaxPlanSteps:              defaults.Planning.MaxSteps,
+		Traces:                    traces,
+		Topics:                    defaults.Topics,
+		Summarization:             defaults.Summarization,
+		skillTools:                skillTools,
+	}
+}
+
+// personaResolver returns the function newPersonas resolves models with.
+func personaResolver(cfg *config.Config, defaults *config.AgentDefaults) func(string) []providers.FallbackCandidate {
+	lookup := modelListLookup(cfg)
+	return func(model string) []providers.FallbackCandidate {
+		return providers.ResolveCandidatesWithLookup(providers.ModelConfig{Primary: model}, defaults.Provider, lookup)
+	}
+}
+
+// modelListLookup returns a function that resolves a model name or model ID
+// to the provider/model of its model_list entry in cfg.
+func modelListLookup(cfg *config.Config) func(raw string) (string, bool) {
+	return func(raw string) (string, bool) {
 		ensureProtocol := func(model string) string {
 			model = strings.TrimSpace(model)
 			if model == "" {
@@ -185,50 +442,58 @@ func NewAgentInstance(
 
 		return "", false
 	}
+}
 
-	candidates := providers.ResolveCandidatesWithLookup(modelCfg, defaults.Provider, resolveFromModelList)
+// piiStore wraps store to mask or flag personal data as session.pii says.
+func piiStore(cfg config.PIIConfig, store session.Store) session.Store {
+	if !cfg.Enabled {
+		return store
+	}
+	// Both are validated with the config.
+	kinds, _ := pii.ParseKinds(cfg.Kinds)
+	mode, _ := pii.ParseMode(cfg.Mode)
+	return pii.NewStore(store, pii.NewDetector(kinds...), mode)
+}
 
-	// Model routing setup: pre-resolve light model candidates at creation time
-	// to avoid repeated model_list lookups on every incoming message.
-	var router *routing.Router
-	var lightCandidates []providers.FallbackCandidate
-	if rc := defaults.Routing; rc != nil && rc.Enabled && rc.LightModel != "" {
-		lightModelCfg := providers.ModelConfig{Primary: rc.LightModel}
-		resolved := providers.ResolveCandidatesWithLookup(lightModelCfg, defaults.Provider, resolveFromModelList)
-		if len(resolved) > 0 {
-			router = routing.New(routing.RouterConfig{
-				LightModel: rc.LightModel,
-				Threshold:  rc.Threshold,
-			})
-			lightCandidates = resolved
-		} else {
-			log.Printf("routing: light_model %q not found in model_list — routing disabled for agent %q",
-				rc.LightModel, agentID)
+// newFactStore opens the agent's fact store in workspace/memory, with
+// semantic search when defaults.EmbeddingModel names a usable model_list
+// entry. It returns nil if the store cannot be created.
+func newFactStore(workspace string, cfg *config.Config, defaults *config.AgentDefaults) *memory.FactStore {
+	facts, err := memory.NewFactStore(filepath.Join(workspace, "memory"))
+	if err != nil {
+		logger.WarnCF("agent", "Fact store unavailable", map[string]any{"error": err.Error()})
+		return nil
+	}
+	if defaults.EmbeddingModel == "" {
+		return facts
+	}
+
+	mc, err := cfg.GetModelConfig(defaults.EmbeddingModel)
+	if err == nil {
+		var embedder providers.EmbeddingProvider
+		if embedder, err = providers.CreateEmbeddingProviderFromConfig(mc); err == nil {
+			facts.SetEmbedder(embedder)
+			return facts
 		}
 	}
+	logger.WarnCF("agent", "Embedding model unavailable, memory search uses keywords only",
+		map[string]any{"embedding_model": defaults.EmbeddingModel, "error": err.Error()})
+	return facts
+}
 
-	return &AgentInstance{
-		ID:                        agentID,
-		Name:                      agentName,
-		Model:                     model,
-		Fallbacks:                 fallbacks,
-		Workspace:                 workspace,
-		MaxIterations:             maxIter,
-		MaxTokens:                 maxTokens,
-		Temperature:               temperature,
-		ThinkingLevel:             thinkingLevel,
-		ContextWindow:             maxTokens,
-		SummarizeMessageThreshold: summarizeMessageThreshold,
-		SummarizeTokenPercent:     summarizeTokenPercent,
-		Provider:                  provider,
-		Sessions:                  sessionsManager,
-		ContextBuilder:            contextBuilder,
-		Tools:                     toolsRegistry,
-		Subagents:                 subagents,
-		SkillsFilter:              skillsFilter,
-		Candidates:                candidates,
-		Router:                    router,
-		LightCandidates:           lightCandidates,
+// configureToolTimeouts applies tools.timeout_seconds and the per-tool
+// overrides in tools.timeouts. The exec tool kills commands after its own
+// tools.exec.timeout_seconds, so unless overridden it is given at least that
+// long plus a little time to report the result.
+func configureToolTimeouts(registry *tools.ToolRegistry, cfg *config.ToolsConfig) {
+	registry.SetTimeout(time.Duration(cfg.TimeoutSeconds) * time.Second)
+	for name, seconds := range cfg.Timeouts {
+		registry.SetToolTimeout(name, time.Duration(seconds)*time.Second)
+	}
+	if _, ok := cfg.Timeouts["exec"]; !ok && cfg.TimeoutSeconds > 0 {
+		if execSeconds := cfg.Exec.TimeoutSeconds; execSeconds >= cfg.TimeoutSeconds {
+			registry.SetToolTimeout("exec", time.Duration(execSeconds+10)*time.Second)
+		}
 	}
 }
 
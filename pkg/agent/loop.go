@@ -19,18 +19,28 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/sipeed/picoclaw/pkg/access"
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/commands"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/dataset"
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/identity"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/notify"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/summarize"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
 	"github.com/sipeed/picoclaw/pkg/tools"
 	"github.com/sipeed/picoclaw/pkg/utils"
 	"github.com/sipeed/picoclaw/pkg/voice"
@@ -43,34 +53,53 @@ type AgentLoop struct {
 	state          *state.Manager
 	running        atomic.Bool
 	summarizing    sync.Map
+	segmenting     sync.Map
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
 	mediaStore     media.MediaStore
 	transcriber    voice.Transcriber
 	cmdRegistry    *commands.Registry
+	access         *access.Controller
+	identities     *identity.Links
+	notifier       *notify.Router
+	turns          *activeTurns
+	guardrails     *guardrails.Pipeline
+	audit          *audit.Log
+	jobs           *tools.JobManager
+	subagents      []*tools.SubagentManager
+	scheduler      *turnScheduler // runs the turns of Run and of the ProcessDirect calls
 }
 
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string   // Session identifier for history/context
-	Channel         string   // Target channel for tool execution
-	ChatID          string   // Target chat ID for tool execution
-	UserMessage     string   // User message content (may include prefix)
-	Media           []string // media:// refs from inbound message
-	DefaultResponse string   // Response when LLM returns empty
-	EnableSummary   bool     // Whether to trigger summarization
-	SendResponse    bool     // Whether to send response via bus
-	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	SessionKey      string            // Session identifier for history/context
+	Channel         string            // Target channel for tool execution
+	ChatID          string            // Target chat ID for tool execution
+	UserMessage     string            // User message content (may include prefix)
+	Media           []string          // media:// refs from inbound message
+	DefaultResponse string            // Response when LLM returns empty
+	EnableSummary   bool              // Whether to trigger summarization
+	SendResponse    bool              // Whether to send response via bus
+	NoHistory       bool              // If true, don't load session history (for heartbeat)
+	Task            routing.TaskClass // Model routing hint; empty lets the router classify the turn
+	Caller          tools.Caller      // Who the turn is for; checked by the tool policy
+	Persona         *Persona          // Persona of the session; nil for the agent's own settings
+	Memories        string            // Memories recalled for the turn, as a prompt block
+	OverBudget      bool              // A budget is used up; the turn runs on the agent's BudgetModel
+	Stream          StreamCallbacks   // Progress reports for interactive frontends
+	Trace           *memory.TurnTrace // What the turn has done so far; nil when it is not traced
 }
 
 const (
 	defaultResponse           = "I've completed processing but have no response to give. Increase `max_tool_iterations` in config.json."
 	sessionKeyAgentPrefix     = "agent:"
+	inboundQueueSize          = 64 // messages read ahead of the running turns
 	metadataKeyAccountID      = "account_id"
 	metadataKeyGuildID        = "guild_id"
 	metadataKeyTeamID         = "team_id"
 	metadataKeyParentPeerKind = "parent_peer_kind"
 	metadataKeyParentPeerID   = "parent_peer_id"
+	metadataKeyThreadID       = "thread_id"
 )
 
 func NewAgentLoop(
@@ -78,10 +107,39 @@ func NewAgentLoop(
 	msgBus *bus.MessageBus,
 	provider providers.LLMProvider,
 ) *AgentLoop {
+	if cfg.Dataset.Enabled {
+		// Wrapping the provider before the agents get it records the calls
+		// of every agent, subagent and background job.
+		if rec, err := dataset.NewRecorder(cfg.DatasetDir(), cfg.Guardrails.Redact); err != nil {
+			logger.ErrorCF("agent", "Dataset recording disabled", map[string]any{"error": err.Error()})
+		} else {
+			provider = providers.WithMiddleware(provider, rec.Middleware())
+		}
+	}
 	registry := NewAgentRegistry(cfg, provider)
 
+	// LoadConfig has checked the patterns already.
+	guard, err := guardrails.New(cfg.Guardrails)
+	if err != nil {
+		logger.FatalCF("agent", "Invalid guardrails config", map[string]any{"error": err.Error()})
+	}
+
+	// The audit log is shared by all agents. It is set before the shared
+	// tools, since subagents get a copy of each agent's registry.
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		if auditLog, err = audit.Open(cfg.AuditDir()); err != nil {
+			logger.ErrorCF("agent", "Audit log disabled", map[string]any{"error": err.Error()})
+		}
+		for _, id := range registry.ListAgentIDs() {
+			if agent, ok := registry.GetAgent(id); ok {
+				agent.Tools.SetAuditLog(auditLog)
+			}
+		}
+	}
+
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	jobs, subagents := registerSharedTools(cfg, msgBus, registry, provider, guard)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
@@ -102,24 +160,64 @@ func NewAgentLoop(
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
 		cmdRegistry: commands.NewRegistry(commands.BuiltinDefinitions()),
+		identities:  identity.NewLinks(cfg.Session.IdentityLinks, cfg.WorkspacePath()),
+		turns:       newActiveTurns(),
+		guardrails:  guard,
+		audit:       auditLog,
+		jobs:        jobs,
+		subagents:   subagents,
+		scheduler:   newTurnScheduler(cfg.Agents.Defaults.MaxConcurrentTurns, inboundQueueSize),
+	}
+
+	if cfg.Notifications.Enabled {
+		if notifier, err := notify.NewRouter(cfg.Notifications, msgBus); err != nil {
+			logger.ErrorCF("agent", "Notifications disabled", map[string]any{"error": err.Error()})
+		} else {
+			al.notifier = notifier
+			al.RegisterTool(tools.NewNotifyTool(notifier))
+		}
 	}
 
 	return al
 }
 
+// AuditLog returns the audit log, or nil when auditing is disabled.
+func (al *AgentLoop) AuditLog() *audit.Log {
+	return al.audit
+}
+
+// Notifier returns the notification router, or nil when notifications are
+// disabled.
+func (al *AgentLoop) Notifier() *notify.Router {
+	return al.notifier
+}
+
 // registerSharedTools registers tools that are shared across all agents (web, message, spawn).
+// It returns the managers of the work they run in the background.
 func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
 	provider providers.LLMProvider,
-) {
+	guard *guardrails.Pipeline,
+) (*tools.JobManager, []*tools.SubagentManager) {
+	var subagents []*tools.SubagentManager
+
+	// Background jobs are shared by all agents so that a job's result
+	// reaches its conversation whichever agent started it.
+	jobs := tools.NewJobManager(jobNotifier(msgBus))
+
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
 		if !ok {
 			continue
 		}
 
+		agent.Tools.SetJobManager(jobs)
+		if cfg.Tools.IsToolEnabled("jobs") {
+			agent.Tools.Register(tools.NewJobsTool(jobs))
+		}
+
 		// Web tools
 		if cfg.Tools.IsToolEnabled("web") {
 			searchTool, err := tools.NewWebSearchTool(tools.WebSearchToolOptions{
@@ -151,27 +249,27 @@ func registerSharedTools(
 				agent.Tools.Register(searchTool)
 			}
 		}
-		if cfg.Tools.IsToolEnabled("web_fetch") {
-			fetchTool, err := tools.NewWebFetchToolWithProxy(50000, cfg.Tools.Web.Proxy, cfg.Tools.Web.FetchLimitBytes)
-			if err != nil {
-				logger.ErrorCF("agent", "Failed to create web fetch tool", map[string]any{"error": err.Error()})
-			} else {
-				agent.Tools.Register(fetchTool)
-			}
-		}
 
-		// Hardware tools (I2C, SPI) - Linux only, returns error on other platforms
-		if cfg.Tools.IsToolEnabled("i2c") {
-			agent.Tools.Register(tools.NewI2CTool())
-		}
-		if cfg.Tools.IsToolEnabled("spi") {
-			agent.Tools.Register(tools.NewSPITool())
-		}
+		// Self-contained tools (web_fetch, i2c, spi, ...) register a factory
+		// from init() and decide on their own whether they are enabled.
+		agent.Tools.RegisterFromFactories(tools.ToolEnv{
+			Config:    cfg,
+			AgentID:   agentID,
+			Workspace: agent.Workspace,
+		})
 
 		// Message tool
 		if cfg.Tools.IsToolEnabled("message") {
 			messageTool := tools.NewMessageTool()
+			// Messages the model sends itself are filtered like its replies.
+			guardMessage := func(channel, chatID, content string) string {
+				fields := map[string]any{"agent_id": agentID, "channel": channel, "chat_id": chatID, "tool": "message"}
+				res := guard.Filter(guardrails.Output, content)
+				logViolations(guardrails.Output, res, fields)
+				return res.Text
+			}
 			messageTool.SetSendCallback(func(channel, chatID, content string) error {
+				content = guardMessage(channel, chatID, content)
 				pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
 				defer pubCancel()
 				return msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{
@@ -180,6 +278,17 @@ func registerSharedTools(
 					Content: content,
 				})
 			})
+			messageTool.SetOptionsCallback(func(channel, chatID, content string, options []string) error {
+				content = guardMessage(channel, chatID, content)
+				pubCtx, pubCancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer pubCancel()
+				return msgBus.PublishOutbound(pubCtx, bus.OutboundMessage{
+					Channel: channel,
+					ChatID:  chatID,
+					Content: content,
+					Buttons: options,
+				})
+			})
 			agent.Tools.Register(messageTool)
 		}
 
@@ -217,21 +326,71 @@ func registerSharedTools(
 			}
 		}
 
-		// Spawn tool with allowlist checker
-		if cfg.Tools.IsToolEnabled("spawn") {
-			if cfg.Tools.IsToolEnabled("subagent") {
-				subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace)
-				subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
+		// Subagent tools. Subagents use the agent's own tools, looked up when
+		// they run so that tools registered later are included.
+		if cfg.Tools.IsToolEnabled("subagent") {
+			subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace)
+			subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
+			subagentManager.SetLimits(cfg.Tools.Subagent.MaxIterations, cfg.Tools.Subagent.TokenBudget)
+			subagentManager.SetTools(agent.Tools)
+			agent.Tools.Register(tools.NewSubagentTool(subagentManager))
+			subagents = append(subagents, subagentManager)
+
+			// Spawn tool with allowlist checker
+			if cfg.Tools.IsToolEnabled("spawn") {
 				spawnTool := tools.NewSpawnTool(subagentManager)
 				currentAgentID := agentID
 				spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
 					return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
 				})
 				agent.Tools.Register(spawnTool)
-			} else {
-				logger.WarnCF("agent", "spawn tool requires subagent to be enabled", nil)
 			}
+		} else if cfg.Tools.IsToolEnabled("spawn") {
+			logger.WarnCF("agent", "spawn tool requires subagent to be enabled", nil)
+		}
+	}
+	return jobs, subagents
+}
+
+// jobNotifier delivers background job events to the job's conversation:
+// progress goes straight to the user, and the final result is published as
+// a system message so the agent can act on it in the job's session.
+func jobNotifier(msgBus *bus.MessageBus) tools.JobNotifier {
+	return func(ev tools.JobEvent) {
+		job := ev.Job
+		if job.Channel == "" || constants.IsInternalChannel(job.Channel) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if ev.Result == nil {
+			_ = msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel: job.Channel,
+				ChatID:  job.ChatID,
+				Content: fmt.Sprintf("⏳ %s (job %s): %s", job.Tool, job.ID, job.Progress),
+			})
+			return
+		}
+
+		if !ev.Result.Silent && ev.Result.ForUser != "" {
+			_ = msgBus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel: job.Channel,
+				ChatID:  job.ChatID,
+				Content: ev.Result.ForUser,
+			})
+		}
+		content := ev.Result.ForLLM
+		if content == "" && ev.Result.Err != nil {
+			content = ev.Result.Err.Error()
 		}
+		_ = msgBus.PublishInbound(ctx, bus.InboundMessage{
+			Channel:    "system",
+			SenderID:   "job:" + job.Tool,
+			ChatID:     job.Channel + ":" + job.ChatID,
+			SessionKey: job.SessionKey,
+			Content:    fmt.Sprintf("Background job %s (%s) %s.\n\nResult:\n%s", job.ID, job.Tool, job.Status, content),
+		})
 	}
 }
 
@@ -305,6 +464,13 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 		}
 	}
 
+	go al.watchSkillTools(ctx, skillToolsPollInterval)
+
+	// Turns run on the scheduler's workers, so that this loop keeps reading
+	// and can cancel a running turn when its conversation asks to stop.
+	defer al.scheduler.wait()
+	al.resumePlans(ctx)
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -314,66 +480,99 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 			if !ok {
 				continue
 			}
+			if isCancelRequest(msg.Content) && al.turns.cancel(ctx, msg.Channel, msg.ChatID) {
+				continue
+			}
+			if msg.Feedback != nil {
+				al.recordFeedback(msg)
+				continue
+			}
+			if !al.schedule(ctx, msg) {
+				return nil
+			}
+		}
+	}
 
-			// Process message
-			func() {
-				// TODO: Re-enable media cleanup after inbound media is properly consumed by the agent.
-				// Currently disabled because files are deleted before the LLM can access their content.
-				// defer func() {
-				// 	if al.mediaStore != nil && msg.MediaScope != "" {
-				// 		if releaseErr := al.mediaStore.ReleaseAll(msg.MediaScope); releaseErr != nil {
-				// 			logger.WarnCF("agent", "Failed to release media", map[string]any{
-				// 				"scope": msg.MediaScope,
-				// 				"error": releaseErr.Error(),
-				// 			})
-				// 		}
-				// 	}
-				// }()
-
-				response, err := al.processMessage(ctx, msg)
-				if err != nil {
-					response = fmt.Sprintf("Error processing message: %v", err)
-				}
+	return nil
+}
 
-				if response != "" {
-					// Check if the message tool already sent a response during this round.
-					// If so, skip publishing to avoid duplicate messages to the user.
-					// Use default agent's tools to check (message tool is shared).
-					alreadySent := false
-					defaultAgent := al.registry.GetDefaultAgent()
-					if defaultAgent != nil {
-						if tool, ok := defaultAgent.Tools.Get("message"); ok {
-							if mt, ok := tool.(*tools.MessageTool); ok {
-								alreadySent = mt.HasSentInRound()
-							}
-						}
-					}
+// schedule queues msg to be handled behind the other turns of its session.
+func (al *AgentLoop) schedule(ctx context.Context, msg bus.InboundMessage) bool {
+	return al.scheduler.submit(ctx, al.turnKey(msg), func() { al.handleInbound(ctx, msg) })
+}
 
-					if !alreadySent {
-						al.bus.PublishOutbound(ctx, bus.OutboundMessage{
-							Channel: msg.Channel,
-							ChatID:  msg.ChatID,
-							Content: response,
-						})
-						logger.InfoCF("agent", "Published outbound response",
-							map[string]any{
-								"channel":     msg.Channel,
-								"chat_id":     msg.ChatID,
-								"content_len": len(response),
-							})
-					} else {
-						logger.DebugCF(
-							"agent",
-							"Skipped outbound (message tool already sent)",
-							map[string]any{"channel": msg.Channel},
-						)
-					}
-				}
-			}()
+// turnKey returns the session msg belongs to, which orders it with the other
+// messages of that session.
+func (al *AgentLoop) turnKey(msg bus.InboundMessage) string {
+	if msg.Channel == "system" {
+		if msg.SessionKey != "" {
+			return msg.SessionKey
+		}
+		if agent := al.registry.GetDefaultAgent(); agent != nil {
+			return routing.BuildAgentMainSessionKey(agent.ID)
 		}
+	} else if route, _, err := al.resolveMessageRoute(msg); err == nil {
+		return resolveScopeKey(route, msg.SessionKey)
 	}
+	return conversationKey(msg.Channel, msg.ChatID)
+}
 
-	return nil
+// handleInbound processes msg and publishes the response.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	// TODO: Re-enable media cleanup after inbound media is properly consumed by the agent.
+	// Currently disabled because files are deleted before the LLM can access their content.
+	// defer func() {
+	// 	if al.mediaStore != nil && msg.MediaScope != "" {
+	// 		if releaseErr := al.mediaStore.ReleaseAll(msg.MediaScope); releaseErr != nil {
+	// 			logger.WarnCF("agent", "Failed to release media", map[string]any{
+	// 				"scope": msg.MediaScope,
+	// 				"error": releaseErr.Error(),
+	// 			})
+	// 		}
+	// 	}
+	// }()
+
+	// The reply goes out on ctx even when the turn was cancelled.
+	turnCtx, done := al.turns.start(ctx, msg)
+	defer done()
+	turnCtx = tools.WithMessageRound(turnCtx)
+
+	stream := al.openReplyStream(ctx, msg)
+	response, err := al.processMessageStream(turnCtx, msg, stream.callbacks(ctx))
+	if err != nil {
+		response = fmt.Sprintf("Error processing message: %v", err)
+	}
+
+	if response != "" && stream.finalize(ctx, response) {
+		logger.InfoCF("agent", "Streamed outbound response",
+			map[string]any{
+				"channel":     msg.Channel,
+				"chat_id":     msg.ChatID,
+				"content_len": len(response),
+			})
+	} else if response != "" {
+		// Check if the message tool already sent a response during this round.
+		// If so, skip publishing to avoid duplicate messages to the user.
+		if !tools.SentInMessageRound(turnCtx) {
+			al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+				Channel: msg.Channel,
+				ChatID:  msg.ChatID,
+				Content: response,
+			})
+			logger.InfoCF("agent", "Published outbound response",
+				map[string]any{
+					"channel":     msg.Channel,
+					"chat_id":     msg.ChatID,
+					"content_len": len(response),
+				})
+		} else {
+			logger.DebugCF(
+				"agent",
+				"Skipped outbound (message tool already sent)",
+				map[string]any{"channel": msg.Channel},
+			)
+		}
+	}
 }
 
 func (al *AgentLoop) Stop() {
@@ -388,20 +587,65 @@ func (al *AgentLoop) RegisterTool(tool tools.Tool) {
 	}
 }
 
+// SetExecApprover sets the approver every agent's exec tool asks about
+// dangerous commands when tools.exec.approval is "ask".
+func (al *AgentLoop) SetExecApprover(approver tools.ExecApprover) {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(agentID)
+		if !ok {
+			continue
+		}
+		if tool, ok := agent.Tools.Get("exec"); ok {
+			if execTool, ok := tool.(*tools.ExecTool); ok {
+				execTool.SetApprover(approver)
+			}
+		}
+	}
+}
+
+// UnregisterTool removes a tool from every agent.
+func (al *AgentLoop) UnregisterTool(name string) {
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Tools.Unregister(name)
+		}
+	}
+}
+
 func (al *AgentLoop) SetChannelManager(cm *channels.Manager) {
 	al.channelManager = cm
+	// Notification webhooks share the outbox of the channel messages, which
+	// is only delivered from while channels run.
+	if al.notifier != nil && cm != nil && cm.Outbox() != nil && len(cm.GetEnabledChannels()) > 0 {
+		al.notifier.SetOutbox(cm.Outbox())
+	}
+}
+
+// SetAccessController lets the owner manage access with the /access command.
+func (al *AgentLoop) SetAccessController(ac *access.Controller) {
+	al.access = ac
 }
 
 // SetMediaStore injects a MediaStore for media lifecycle management.
 func (al *AgentLoop) SetMediaStore(s media.MediaStore) {
 	al.mediaStore = s
 
-	// Propagate store to send_file tools in all agents.
+	// Propagate store to the tools that send media in all agents.
 	al.registry.ForEachTool("send_file", func(t tools.Tool) {
 		if sf, ok := t.(*tools.SendFileTool); ok {
 			sf.SetMediaStore(s)
 		}
 	})
+	al.registry.ForEachTool("browser", func(t tools.Tool) {
+		if bt, ok := t.(*tools.BrowserTool); ok {
+			bt.SetMediaStore(s)
+		}
+	})
+	al.registry.ForEachTool("image_generate", func(t tools.Tool) {
+		if it, ok := t.(*tools.ImageGenerateTool); ok {
+			it.SetMediaStore(s)
+		}
+	})
 }
 
 // SetTranscriber injects a voice transcriber for agent-level audio transcription.
@@ -529,7 +773,91 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 		SessionKey: sessionKey,
 	}
 
-	return al.processMessage(ctx, msg)
+	return al.processDirect(ctx, msg, StreamCallbacks{})
+}
+
+// ProcessDirectStream is ProcessDirect for interactive frontends: when the
+// provider can stream, onDelta receives the response text as it is
+// generated. The text of successive model calls in one turn is separated by
+// a blank line. The returned string is the final response, as ProcessDirect
+// returns it.
+func (al *AgentLoop) ProcessDirectStream(
+	ctx context.Context,
+	content, sessionKey string,
+	onDelta func(string),
+) (string, error) {
+	return al.ProcessDirectWithCallbacks(ctx, content, sessionKey, "cli", "direct", StreamCallbacks{OnDelta: onDelta})
+}
+
+// ProcessDirectWithCallbacks is ProcessDirectWithChannel that reports the
+// progress of the turn (streamed text, tool calls and their results) to cb.
+func (al *AgentLoop) ProcessDirectWithCallbacks(
+	ctx context.Context,
+	content, sessionKey, channel, chatID string,
+	cb StreamCallbacks,
+) (string, error) {
+	msg := bus.InboundMessage{
+		Channel:    channel,
+		SenderID:   "cron",
+		ChatID:     chatID,
+		Content:    content,
+		SessionKey: sessionKey,
+	}
+	return al.processDirect(ctx, msg, cb)
+}
+
+// processDirect processes msg as a turn of its session on the scheduler, so
+// that it waits for the turns of that session already running or queued and
+// counts against max_concurrent_turns like the turns of channel messages.
+func (al *AgentLoop) processDirect(
+	ctx context.Context,
+	msg bus.InboundMessage,
+	cb StreamCallbacks,
+) (response string, err error) {
+	ran := al.scheduler.do(ctx, al.turnKey(msg), func() {
+		response, err = al.processMessageStream(ctx, msg, cb)
+	})
+	if !ran {
+		return "", ctx.Err()
+	}
+	return response, err
+}
+
+// DefaultAgentSessions returns the ID and session store of the default
+// agent, for frontends that let the user pick a session.
+func (al *AgentLoop) DefaultAgentSessions() (string, *session.SessionManager) {
+	agent := al.registry.GetDefaultAgent()
+	if agent == nil {
+		return "", nil
+	}
+	return agent.ID, agent.Sessions
+}
+
+// DefaultAgentFacts returns the fact store of the default agent, or nil when
+// its memory tools are off.
+func (al *AgentLoop) DefaultAgentFacts() *memory.FactStore {
+	if agent := al.registry.GetDefaultAgent(); agent != nil {
+		return agent.Facts
+	}
+	return nil
+}
+
+// DefaultAgentTasks returns the task list of the default agent, or nil when
+// its tasks tool is off.
+func (al *AgentLoop) DefaultAgentTasks() *memory.TaskStore {
+	if agent := al.registry.GetDefaultAgent(); agent != nil {
+		return agent.Tasks
+	}
+	return nil
+}
+
+// DefaultAgentTraces returns the turn traces of the default agent, or nil
+// when it keeps none.
+func (al *AgentLoop) DefaultAgentTraces() *memory.TraceLog {
+	if agent := al.registry.GetDefaultAgent(); agent != nil {
+		return agent.Traces
+	}
+	return nil
 }
 
 // ProcessHeartbeat processes a heartbeat request without session history.
@@ -551,10 +879,19 @@ func (al *AgentLoop) ProcessHeartbeat(
 		EnableSummary:   false,
 		SendResponse:    false,
 		NoHistory:       true, // Don't load session history for heartbeat
+		Task:            routing.TaskHeartbeat,
 	})
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	return al.processMessageStream(ctx, msg, StreamCallbacks{})
+}
+
+func (al *AgentLoop) processMessageStream(
+	ctx context.Context,
+	msg bus.InboundMessage,
+	stream StreamCallbacks,
+) (string, error) {
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -580,13 +917,23 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return al.processSystemMessage(ctx, msg)
 	}
 
+	// Memory follows the person across channels; sessions stay per channel.
+	if person := al.identities.Person(accountOf(msg)); person != "" {
+		ctx = memory.WithPerson(ctx, person)
+	}
+
 	route, agent, routeErr := al.resolveMessageRoute(msg)
 
 	// Commands are checked before requiring a successful route.
 	// Global commands (/help, /show, /switch) work even when routing fails;
 	// context-dependent commands check their own Runtime fields and report
 	// "unavailable" when the required capability is nil.
-	if response, handled := al.handleCommand(ctx, msg, agent); handled {
+	var scopeKey string
+	if routeErr == nil {
+		// Resolve session key from route, while preserving explicit agent-scoped keys.
+		scopeKey = resolveScopeKey(route, msg.SessionKey)
+	}
+	if response, handled := al.handleCommand(ctx, msg, agent, scopeKey); handled {
 		return response, nil
 	}
 
@@ -594,15 +941,6 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		return "", routeErr
 	}
 
-	// Reset message-tool state for this round so we don't skip publishing due to a previous round.
-	if tool, ok := agent.Tools.Get("message"); ok {
-		if resetter, ok := tool.(interface{ ResetSentInRound() }); ok {
-			resetter.ResetSentInRound()
-		}
-	}
-
-	// Resolve session key from route, while preserving explicit agent-scoped keys.
-	scopeKey := resolveScopeKey(route, msg.SessionKey)
 	sessionKey := scopeKey
 
 	logger.InfoCF("agent", "Routed message",
@@ -624,9 +962,25 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		DefaultResponse: defaultResponse,
 		EnableSummary:   true,
 		SendResponse:    false,
+		Caller:          callerOf(msg),
+		Stream:          stream,
 	})
 }
 
+// callerOf identifies the sender of msg for the tool policy.
+func callerOf(msg bus.InboundMessage) tools.Caller {
+	return tools.Caller{SenderID: msg.SenderID, Sender: msg.Sender, PeerKind: msg.Peer.Kind, Role: msg.Role}
+}
+
+// accountOf returns the account msg was sent from, or "" for messages no
+// user sent, such as cron jobs.
+func accountOf(msg bus.InboundMessage) string {
+	if msg.Channel != "cli" && msg.Sender.CanonicalID == "" && msg.Sender.PlatformID == "" {
+		return ""
+	}
+	return identity.AccountID(msg.Channel, msg.Sender, msg.SenderID)
+}
+
 func (al *AgentLoop) resolveMessageRoute(msg bus.InboundMessage) (routing.ResolvedRoute, *AgentInstance, error) {
 	route := al.registry.ResolveRoute(routing.RouteInput{
 		Channel:    msg.Channel,
@@ -635,6 +989,8 @@ func (al *AgentLoop) resolveMessageRoute(msg bus.InboundMessage) (routing.Resolv
 		ParentPeer: extractParentPeer(msg),
 		GuildID:    inboundMetadata(msg, metadataKeyGuildID),
 		TeamID:     inboundMetadata(msg, metadataKeyTeamID),
+		SenderID:   extractSenderID(msg),
+		ThreadID:   inboundMetadata(msg, metadataKeyThreadID),
 	})
 
 	agent, ok := al.registry.GetAgent(route.AgentID)
@@ -707,7 +1063,10 @@ func (al *AgentLoop) processSystemMessage(
 	}
 
 	// Use the origin session for context
-	sessionKey := routing.BuildAgentMainSessionKey(agent.ID)
+	sessionKey := msg.SessionKey
+	if sessionKey == "" {
+		sessionKey = routing.BuildAgentMainSessionKey(agent.ID)
+	}
 
 	return al.runAgentLoop(ctx, agent, processOptions{
 		SessionKey:      sessionKey,
@@ -725,7 +1084,10 @@ func (al *AgentLoop) runAgentLoop(
 	ctx context.Context,
 	agent *AgentInstance,
 	opts processOptions,
-) (string, error) {
+) (_ string, err error) {
+	ctx, span := startTurnSpan(ctx, agent, opts)
+	defer func() { telemetry.End(span, err) }()
+
 	// 0. Record last channel for heartbeat notifications (skip internal channels and cli)
 	if opts.Channel != "" && opts.ChatID != "" {
 		if !constants.IsInternalChannel(opts.Channel) {
@@ -740,13 +1102,41 @@ func (al *AgentLoop) runAgentLoop(
 		}
 	}
 
+	// The message may be blocked before it reaches the model or the session.
+	in := al.guard(guardrails.Input, opts.UserMessage, turnFields(agent, opts))
+	if in.Blocked {
+		return al.replyDirectly(ctx, opts, in.Text)
+	}
+	opts.UserMessage = in.Text
+
+	// Over budget, the turn runs on the cheaper model or not at all.
+	if limit := al.overBudget(ctx, opts.SessionKey); limit != nil {
+		fields := map[string]any{"agent_id": agent.ID, "session_key": opts.SessionKey, "limit": limit.String()}
+		if agent.BudgetModel == "" {
+			logger.WarnCF("agent", "Budget used up, refusing turn", fields)
+			return al.replyDirectly(ctx, opts, fmt.Sprintf(budgetRefusal, limit, limit.resets()))
+		}
+		fields["model"] = agent.BudgetModel
+		logger.InfoCF("agent", "Budget used up, using the fallback model", fields)
+		opts.OverBudget = true
+	}
+
 	// 1. Build messages (skip history for heartbeat)
 	var history []providers.Message
 	var summary string
 	if !opts.NoHistory {
-		history = agent.Sessions.GetHistory(opts.SessionKey)
+		history = agent.history(opts.SessionKey)
 		summary = agent.Sessions.GetSummary(opts.SessionKey)
 	}
+	opts.Persona = agent.persona(opts.SessionKey)
+	if !opts.NoHistory {
+		// Conversations with one person can be recalled in their others.
+		if person := memory.PersonFrom(ctx); person != "" &&
+			(opts.Caller.PeerKind == "direct" || opts.Caller.PeerKind == "") {
+			agent.Sessions.SetPerson(opts.SessionKey, person)
+		}
+		opts.Memories = recallMemories(ctx, agent, opts.SessionKey, opts.UserMessage)
+	}
 	messages := agent.ContextBuilder.BuildMessages(
 		history,
 		summary,
@@ -755,17 +1145,27 @@ func (al *AgentLoop) runAgentLoop(
 		opts.Channel,
 		opts.ChatID,
 	)
+	messages = agent.ContextBuilder.withTurnContext(messages, opts)
+	if !opts.NoHistory {
+		messages = al.fitContextWindow(agent, opts, messages)
+	}
+
+	opts.Trace = startTrace(agent, opts, messages)
 
 	// Resolve media:// refs to base64 data URLs (streaming)
 	maxMediaSize := al.cfg.Agents.Defaults.GetMaxMediaSize()
 	messages = resolveMediaRefs(messages, al.mediaStore, maxMediaSize)
 
 	// 2. Save user message to session
-	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
+	if err := agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage); err != nil {
+		finishTrace(agent, opts.Trace, "", err)
+		return "", fmt.Errorf("save user message: %w", err)
+	}
 
 	// 3. Run LLM iteration loop
 	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
+		finishTrace(agent, opts.Trace, "", err)
 		return "", err
 	}
 
@@ -776,14 +1176,20 @@ func (al *AgentLoop) runAgentLoop(
 	if finalContent == "" {
 		finalContent = opts.DefaultResponse
 	}
+	finalContent = al.guard(guardrails.Output, finalContent, turnFields(agent, opts)).Text
 
 	// 5. Save final assistant message to session
-	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
-	agent.Sessions.Save(opts.SessionKey)
+	if err := agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent); err != nil {
+		finishTrace(agent, opts.Trace, "", err)
+		return "", fmt.Errorf("save assistant message: %w", err)
+	}
+	saveSession(ctx, agent, opts.SessionKey)
+	finishTrace(agent, opts.Trace, finalContent, nil)
 
-	// 6. Optional: summarization
+	// 6. Optional: summarization and topic detection
 	if opts.EnableSummary {
 		al.maybeSummarize(agent, opts.SessionKey, opts.Channel, opts.ChatID)
+		al.maybeSegment(agent, opts.SessionKey)
 	}
 
 	// 7. Optional: send response via bus
@@ -808,6 +1214,14 @@ func (al *AgentLoop) runAgentLoop(
 	return finalContent, nil
 }
 
+// replyDirectly answers a turn without involving the model.
+func (al *AgentLoop) replyDirectly(ctx context.Context, opts processOptions, content string) (string, error) {
+	if opts.SendResponse {
+		al.bus.PublishOutbound(ctx, bus.OutboundMessage{Channel: opts.Channel, ChatID: opts.ChatID, Content: content})
+	}
+	return content, nil
+}
+
 func (al *AgentLoop) targetReasoningChannelID(channelName string) (chatID string) {
 	if al.channelManager == nil {
 		return ""
@@ -878,10 +1292,50 @@ func (al *AgentLoop) runLLMIteration(
 	// selectCandidates evaluates routing once and the decision is sticky for
 	// all tool-follow-up iterations within the same turn so that a multi-step
 	// tool chain doesn't switch models mid-way through.
-	activeCandidates, activeModel := al.selectCandidates(agent, opts.UserMessage, messages)
+	activeCandidates, activeModel := al.selectCandidates(agent, opts.UserMessage, messages, opts.Task)
+	// A persona with a model of its own pins it for the session.
+	if p := opts.Persona; p != nil && len(p.Candidates) > 0 {
+		activeCandidates, activeModel = p.Candidates, p.Model
+	}
+	if opts.OverBudget {
+		activeCandidates, activeModel = agent.BudgetCandidates, agent.BudgetModel
+	}
+	toolRegistry := opts.Persona.toolRegistry(agent.Tools)
+
+	// An answer that is reviewed is held back until it has passed, so it is
+	// not streamed.
+	review := agent.Reflection.EnabledFor(opts.Channel)
+	var onDelta func(string)
+	joiner := &deltaJoiner{fn: opts.Stream.OnDelta}
+	if opts.Stream.OnDelta != nil && !review {
+		onDelta = joiner.write
+	}
+
+	var deadline time.Time
+	if agent.MaxTurnDuration > 0 {
+		deadline = time.Now().Add(agent.MaxTurnDuration)
+	}
+	repeats := newRepeatGuard(agent.MaxRepeatedToolCalls)
 
 	for iteration < agent.MaxIterations {
+		if ctx.Err() != nil {
+			finalContent = cancelledResponse
+			break
+		}
+		// The limit is checked between LLM calls, so a call or tool that is
+		// already running is not cut short.
+		if iteration > 0 && !deadline.IsZero() && time.Now().After(deadline) {
+			logger.WarnCF("agent", "Turn time limit reached",
+				map[string]any{
+					"agent_id":  agent.ID,
+					"iteration": iteration,
+					"limit":     agent.MaxTurnDuration.String(),
+				})
+			finalContent = turnTimeoutResponse
+			break
+		}
 		iteration++
+		joiner.startCall()
 
 		logger.DebugCF("agent", "LLM iteration",
 			map[string]any{
@@ -891,7 +1345,7 @@ func (al *AgentLoop) runLLMIteration(
 			})
 
 		// Build tool definitions
-		providerToolDefs := agent.Tools.ToProviderDefs()
+		providerToolDefs := toolRegistry.ToProviderDefs()
 
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
@@ -923,6 +1377,10 @@ func (al *AgentLoop) runLLMIteration(
 			"temperature":      agent.Temperature,
 			"prompt_cache_key": agent.ID,
 		}
+		if p := opts.Persona; p != nil && p.Temperature != nil {
+			llmOpts["temperature"] = *p.Temperature
+		}
+		applyGenerationParams(llmOpts, agent.Sessions.GetGenerationParams(opts.SessionKey))
 		// parseThinkingLevel guarantees ThinkingOff for empty/unknown values,
 		// so checking != ThinkingOff is sufficient.
 		if agent.ThinkingLevel != ThinkingOff {
@@ -940,7 +1398,7 @@ func (al *AgentLoop) runLLMIteration(
 					ctx,
 					activeCandidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, llmOpts)
+						return chatLLM(ctx, agent.Provider, messages, providerToolDefs, model, llmOpts, onDelta)
 					},
 				)
 				if fbErr != nil {
@@ -956,14 +1414,21 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, activeModel, llmOpts)
+			return chatLLM(ctx, agent.Provider, messages, providerToolDefs, activeModel, llmOpts, onDelta)
+		}
+
+		// A malformed sequence, such as a tool result without its call, is
+		// rejected by most providers; refuse it here with a clear error.
+		if err := providers.ValidateHistory(messages); err != nil {
+			return "", iteration, fmt.Errorf("invalid message history: %w", err)
 		}
 
 		// Retry loop for context/token errors
+		callStart := time.Now()
 		maxRetries := 2
 		for retry := 0; retry <= maxRetries; retry++ {
 			response, err = callLLM()
-			if err == nil {
+			if err == nil || ctx.Err() != nil {
 				break
 			}
 
@@ -994,7 +1459,10 @@ func (al *AgentLoop) runLLMIteration(
 					"retry":   retry,
 					"backoff": backoff.String(),
 				})
-				time.Sleep(backoff)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+				}
 				continue
 			}
 
@@ -1017,17 +1485,26 @@ func (al *AgentLoop) runLLMIteration(
 				}
 
 				al.forceCompression(agent, opts.SessionKey)
-				newHistory := agent.Sessions.GetHistory(opts.SessionKey)
+				newHistory := agent.history(opts.SessionKey)
 				newSummary := agent.Sessions.GetSummary(opts.SessionKey)
 				messages = agent.ContextBuilder.BuildMessages(
 					newHistory, newSummary, "",
 					nil, opts.Channel, opts.ChatID,
 				)
+				messages = agent.ContextBuilder.withTurnContext(messages, opts)
 				continue
 			}
 			break
 		}
 
+		traceLLMCall(opts.Trace, iteration, activeModel, messages, providerToolDefs, callStart, response, err)
+
+		if err != nil && ctx.Err() != nil {
+			logger.InfoCF("agent", "Turn cancelled",
+				map[string]any{"agent_id": agent.ID, "iteration": iteration})
+			finalContent = cancelledResponse
+			break
+		}
 		if err != nil {
 			logger.ErrorCF("agent", "LLM call failed",
 				map[string]any{
@@ -1038,6 +1515,8 @@ func (al *AgentLoop) runLLMIteration(
 			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
+		al.recordUsage(agent, opts.SessionKey, activeModel, response.Usage)
+
 		go al.handleReasoning(
 			ctx,
 			response.Reasoning,
@@ -1051,6 +1530,7 @@ func (al *AgentLoop) runLLMIteration(
 				"iteration":      iteration,
 				"content_chars":  len(response.Content),
 				"tool_calls":     len(response.ToolCalls),
+				"cached_tokens":  response.Usage.CachedTokens(),
 				"reasoning":      response.Reasoning,
 				"target_channel": al.targetReasoningChannelID(opts.Channel),
 				"channel":        opts.Channel,
@@ -1064,6 +1544,17 @@ func (al *AgentLoop) runLLMIteration(
 					"iteration":     iteration,
 					"content_chars": len(finalContent),
 				})
+			if review && iteration < agent.MaxIterations && finalContent != "" {
+				review = false // one retry at most
+				problems := al.reviewAnswer(ctx, agent, opts.SessionKey, opts.UserMessage, finalContent)
+				if problems != "" {
+					messages = append(messages,
+						providers.Message{Role: "assistant", Content: finalContent},
+						providers.Message{Role: "user", Content: fmt.Sprintf(reviewFeedback, problems)},
+					)
+					continue
+				}
+			}
 			break
 		}
 
@@ -1116,23 +1607,53 @@ func (al *AgentLoop) runLLMIteration(
 		messages = append(messages, assistantMsg)
 
 		// Save assistant message with tool calls to session
-		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
+		if err := agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg); err != nil {
+			return "", iteration, fmt.Errorf("save assistant message: %w", err)
+		}
 
-		// Execute tool calls in parallel
+		// Execute tool calls in parallel, at most MaxParallelTools at a time
 		type indexedAgentResult struct {
-			result *tools.ToolResult
-			tc     providers.ToolCall
+			result   *tools.ToolResult
+			tc       providers.ToolCall
+			duration time.Duration
 		}
 
 		agentResults := make([]indexedAgentResult, len(normalizedToolCalls))
+		looping := false
+		for i, tc := range normalizedToolCalls {
+			if !repeats.allow(tc) {
+				logger.WarnCF("agent", "Refused repeated tool call",
+					map[string]any{
+						"agent_id":  agent.ID,
+						"tool":      tc.Name,
+						"iteration": iteration,
+					})
+				agentResults[i].result = tools.ErrorResult(repeats.loopFeedback(tc))
+				looping = true
+			}
+		}
 		var wg sync.WaitGroup
+		var sem chan struct{}
+		if agent.MaxParallelTools > 0 {
+			sem = make(chan struct{}, agent.MaxParallelTools)
+		}
 
 		for i, tc := range normalizedToolCalls {
 			agentResults[i].tc = tc
+			if opts.Stream.OnToolCall != nil {
+				opts.Stream.OnToolCall(tc)
+			}
+			if agentResults[i].result != nil {
+				continue // refused as a repeat
+			}
 
 			wg.Add(1)
 			go func(idx int, tc providers.ToolCall) {
 				defer wg.Done()
+				if sem != nil {
+					sem <- struct{}{}
+					defer func() { <-sem }()
+				}
 
 				argsJSON, _ := json.Marshal(tc.Arguments)
 				argsPreview := utils.Truncate(string(argsJSON), 200)
@@ -1186,8 +1707,9 @@ func (al *AgentLoop) runLLMIteration(
 					})
 				}
 
-				toolResult := agent.Tools.ExecuteWithContext(
-					ctx,
+				start := time.Now()
+				toolResult := toolRegistry.ExecuteWithContext(
+					tools.WithCaller(tools.WithSessionKey(ctx, opts.SessionKey), opts.Caller),
 					tc.Name,
 					tc.Arguments,
 					opts.Channel,
@@ -1195,12 +1717,20 @@ func (al *AgentLoop) runLLMIteration(
 					asyncCallback,
 				)
 				agentResults[idx].result = toolResult
+				agentResults[idx].duration = time.Since(start)
 			}(i, tc)
 		}
 		wg.Wait()
 
 		// Process results in original order (send to user, save to session)
 		for _, r := range agentResults {
+			al.guardToolResult(r.result, map[string]any{
+				"agent_id": agent.ID, "session_key": opts.SessionKey, "channel": opts.Channel, "tool": r.tc.Name,
+			})
+			if opts.Stream.OnToolResult != nil {
+				opts.Stream.OnToolResult(r.tc, r.result)
+			}
+
 			// Send ForUser content to user immediately if not Silent
 			if !r.result.Silent && r.result.ForUser != "" && opts.SendResponse {
 				al.bus.PublishOutbound(ctx, bus.OutboundMessage{
@@ -1248,9 +1778,36 @@ func (al *AgentLoop) runLLMIteration(
 				ToolCallID: r.tc.ID,
 			}
 			messages = append(messages, toolResultMsg)
+			if opts.Trace != nil {
+				opts.Trace.ToolCalls = append(opts.Trace.ToolCalls, memory.ToolCallTrace{
+					Iteration:  iteration,
+					Name:       r.tc.Name,
+					Arguments:  r.tc.Arguments,
+					Result:     contentForLLM,
+					IsError:    r.result.IsError,
+					DurationMS: r.duration.Milliseconds(),
+				})
+			}
 
 			// Save tool result message to session
-			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+			if err := agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg); err != nil {
+				return "", iteration, fmt.Errorf("save tool result: %w", err)
+			}
+		}
+
+		// A model that repeats itself after being told it is looping will
+		// not stop on its own.
+		if looping {
+			if repeats.warned {
+				logger.WarnCF("agent", "Ending turn stuck in a tool loop",
+					map[string]any{
+						"agent_id":  agent.ID,
+						"iteration": iteration,
+					})
+				finalContent = loopingResponse
+				break
+			}
+			repeats.warned = true
 		}
 	}
 
@@ -1258,9 +1815,9 @@ func (al *AgentLoop) runLLMIteration(
 }
 
 // selectCandidates returns the model candidates and resolved model name to use
-// for a conversation turn. When model routing is configured and the incoming
-// message scores below the complexity threshold, it returns the light model
-// candidates instead of the primary ones.
+// for a conversation turn. When a route is configured for the turn's task class
+// it wins; otherwise, if the incoming message scores below the complexity
+// threshold, the light model candidates are returned instead of the primary ones.
 //
 // The returned (candidates, model) pair is used for all LLM calls within one
 // turn — tool follow-up iterations use the same tier as the initial call so
@@ -1269,8 +1826,24 @@ func (al *AgentLoop) selectCandidates(
 	agent *AgentInstance,
 	userMsg string,
 	history []providers.Message,
+	task routing.TaskClass,
 ) (candidates []providers.FallbackCandidate, model string) {
-	if agent.Router == nil || len(agent.LightCandidates) == 0 {
+	if agent.Router == nil {
+		return agent.Candidates, agent.Model
+	}
+
+	class := routing.ClassifyTask(task, history)
+	if routeModel, ok := agent.Router.Route(class); ok {
+		logger.InfoCF("agent", "Model routing: task route selected",
+			map[string]any{
+				"agent_id": agent.ID,
+				"task":     string(class),
+				"model":    routeModel,
+			})
+		return agent.RouteCandidates[class], routeModel
+	}
+
+	if len(agent.LightCandidates) == 0 {
 		return agent.Candidates, agent.Model
 	}
 
@@ -1313,6 +1886,16 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 	}
 }
 
+// checkpoint saves the history of a session before it is summarized or cut
+// short, so that the change can be rolled back. A failure is logged and does
+// not stop the change.
+func checkpoint(agent *AgentInstance, sessionKey, label string) {
+	if _, err := agent.Sessions.Checkpoint(sessionKey, label); err != nil {
+		logger.WarnCF("agent", "Cannot checkpoint session",
+			map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "label": label, "error": err.Error()})
+	}
+}
+
 // forceCompression aggressively reduces context when the limit is hit.
 // It drops the oldest 50% of messages (keeping system prompt and last user message).
 func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
@@ -1356,7 +1939,14 @@ func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
 	newHistory = append(newHistory, history[len(history)-1]) // Last message
 
 	// Update session
-	agent.Sessions.SetHistory(sessionKey, newHistory)
+	checkpoint(agent, sessionKey, "before compression")
+	if err := agent.Sessions.SetHistory(sessionKey, newHistory); err != nil {
+		logger.WarnCF("agent", "Forced compression skipped", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+		return
+	}
 	agent.Sessions.Save(sessionKey)
 
 	logger.WarnCF("agent", "Forced compression executed", map[string]any{
@@ -1489,39 +2079,42 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		return
 	}
 
-	// Multi-Part Summarization
-	var finalSummary string
-	if len(validMessages) > 10 {
-		mid := len(validMessages) / 2
-		part1 := validMessages[:mid]
-		part2 := validMessages[mid:]
-
-		s1, _ := al.summarizeBatch(ctx, agent, part1, "")
-		s2, _ := al.summarizeBatch(ctx, agent, part2, "")
+	name := agent.Sessions.GetSummarization(sessionKey)
+	if name == "" {
+		name = agent.Summarization.Strategy
+	}
+	strategy, err := summarize.New(name, agent.Summarization.ChunkMessages)
+	if err != nil {
+		// A strategy that is no longer known falls back to the default.
+		logger.WarnCF("agent", "Unknown summarization strategy",
+			map[string]any{"session_key": sessionKey, "error": err.Error()})
+		strategy, _ = summarize.New("", agent.Summarization.ChunkMessages)
+	}
 
-		mergePrompt := fmt.Sprintf(
-			"Merge these two conversation summaries into one cohesive summary:\n\n1: %s\n\n2: %s",
-			s1,
-			s2,
-		)
+	model := summaryModel(agent)
+	complete := func(ctx context.Context, prompt string) (string, error) {
 		resp, err := agent.Provider.Chat(
 			ctx,
-			[]providers.Message{{Role: "user", Content: mergePrompt}},
+			[]providers.Message{{Role: "user", Content: prompt}},
 			nil,
-			agent.Model,
+			model,
 			map[string]any{
 				"max_tokens":       1024,
 				"temperature":      0.3,
 				"prompt_cache_key": agent.ID,
 			},
 		)
-		if err == nil {
-			finalSummary = resp.Content
-		} else {
-			finalSummary = s1 + " " + s2
+		if err != nil {
+			return "", err
 		}
-	} else {
-		finalSummary, _ = al.summarizeBatch(ctx, agent, validMessages, summary)
+		al.recordUsage(agent, sessionKey, model, resp.Usage)
+		return resp.Content, nil
+	}
+	finalSummary, err := strategy.Summarize(ctx, complete, summary, validMessages)
+	if err != nil {
+		logger.WarnCF("agent", "Summarization failed",
+			map[string]any{"session_key": sessionKey, "strategy": name, "error": err.Error()})
+		return
 	}
 
 	if omitted && finalSummary != "" {
@@ -1529,49 +2122,63 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	}
 
 	if finalSummary != "" {
+		checkpoint(agent, sessionKey, "before summarize")
 		agent.Sessions.SetSummary(sessionKey, finalSummary)
 		agent.Sessions.TruncateHistory(sessionKey, 4)
 		agent.Sessions.Save(sessionKey)
 	}
 }
 
-// summarizeBatch summarizes a batch of messages.
-func (al *AgentLoop) summarizeBatch(
-	ctx context.Context,
-	agent *AgentInstance,
-	batch []providers.Message,
-	existingSummary string,
-) (string, error) {
-	var sb strings.Builder
-	sb.WriteString(
-		"Provide a concise summary of this conversation segment, preserving core context and key points.\n",
-	)
-	if existingSummary != "" {
-		sb.WriteString("Existing context: ")
-		sb.WriteString(existingSummary)
-		sb.WriteString("\n")
+// applyGenerationParams overlays a session's generation overrides on the
+// agent's default LLM options.
+func applyGenerationParams(llmOpts map[string]any, params session.GenerationParams) {
+	if params.Temperature != nil {
+		llmOpts["temperature"] = *params.Temperature
+	}
+	if params.TopP != nil {
+		llmOpts["top_p"] = *params.TopP
+	}
+	if params.MaxTokens > 0 {
+		llmOpts["max_tokens"] = params.MaxTokens
 	}
-	sb.WriteString("\nCONVERSATION:\n")
-	for _, m := range batch {
-		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
+	if len(params.Stop) > 0 {
+		llmOpts["stop"] = params.Stop
 	}
-	prompt := sb.String()
+}
 
-	response, err := agent.Provider.Chat(
-		ctx,
-		[]providers.Message{{Role: "user", Content: prompt}},
-		nil,
-		agent.Model,
-		map[string]any{
-			"max_tokens":       1024,
-			"temperature":      0.3,
-			"prompt_cache_key": agent.ID,
-		},
-	)
-	if err != nil {
-		return "", err
+// summaryModel returns the model for summarization calls: the one set in
+// summarization.model, else the summarization route when one is configured,
+// otherwise the agent's primary model.
+func summaryModel(agent *AgentInstance) string {
+	if agent.Summarization.Model != "" {
+		return agent.Summarization.Model
+	}
+	if agent.Router != nil {
+		if model, ok := agent.Router.Route(routing.TaskSummarization); ok {
+			return model
+		}
+	}
+	return agent.Model
+}
+
+// recordUsage appends provider-reported token usage to the agent's usage
+// ledger. Cost is filled in when the model has pricing configured.
+func (al *AgentLoop) recordUsage(agent *AgentInstance, sessionKey, model string, usage *providers.UsageInfo) {
+	if agent.Usage == nil || usage == nil {
+		return
+	}
+	rec := memory.NewUsageRecord(sessionKey, agent.ID, model, usage)
+	if usage.Cost > 0 {
+		rec.CostUSD = usage.Cost
+	} else if mc, ok := al.cfg.LookupModelConfig(model); ok {
+		rec.CostUSD = mc.CostUSD(rec.PromptTokens, rec.CompletionTokens)
+	}
+	if err := agent.Usage.Record(context.Background(), rec); err != nil {
+		logger.WarnCF("agent", "Failed to record token usage", map[string]any{
+			"agent_id": agent.ID,
+			"error":    err.Error(),
+		})
 	}
-	return response.Content, nil
 }
 
 // estimateTokens estimates the number of tokens in a message list.
@@ -1590,6 +2197,7 @@ func (al *AgentLoop) handleCommand(
 	ctx context.Context,
 	msg bus.InboundMessage,
 	agent *AgentInstance,
+	sessionKey string,
 ) (string, bool) {
 	if !commands.HasCommandPrefix(msg.Content) {
 		return "", false
@@ -1599,14 +2207,30 @@ func (al *AgentLoop) handleCommand(
 		return "", false
 	}
 
-	rt := al.buildCommandsRuntime(agent)
+	rt := al.buildCommandsRuntime(agent, sessionKey)
+	rt.CancelTurn = func(channel, chatID string) bool {
+		return al.turns.cancel(ctx, channel, chatID)
+	}
+	if account := accountOf(msg); account != "" {
+		al.addLinkCommands(rt, account)
+	}
+	if agent != nil && sessionKey != "" {
+		rt.RateAnswer = func(rating int, correction string) error {
+			return addFeedback(agent, sessionKey, accountOf(msg), bus.Feedback{Rating: rating, Correction: correction})
+		}
+	}
+	origin := msg
+	origin.Content, origin.Media = "", nil
+	al.addPlanCommands(rt, agent, sessionKey, origin)
 	executor := commands.NewExecutor(al.cmdRegistry, rt)
 
 	var commandReply string
-	result := executor.Execute(ctx, commands.Request{
+	// An approved call runs on behalf of whoever sent /approve.
+	result := executor.Execute(tools.WithCaller(ctx, callerOf(msg)), commands.Request{
 		Channel:  msg.Channel,
 		ChatID:   msg.ChatID,
 		SenderID: msg.SenderID,
+		Role:     msg.Role,
 		Text:     msg.Content,
 		Reply: func(text string) error {
 			commandReply = text
@@ -1628,7 +2252,7 @@ func (al *AgentLoop) handleCommand(
 	}
 }
 
-func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance) *commands.Runtime {
+func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance, sessionKey string) *commands.Runtime {
 	rt := &commands.Runtime{
 		Config:          al.cfg,
 		ListAgentIDs:    al.registry.ListAgentIDs,
@@ -1649,6 +2273,11 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance) *commands.Runtim
 			return nil
 		},
 	}
+	if al.access != nil {
+		rt.ListAccess = al.access.List
+		rt.GrantAccess = al.access.Grant
+		rt.RevokeAccess = al.access.Revoke
+	}
 	if agent != nil {
 		rt.GetModelInfo = func() (string, string) {
 			return agent.Model, al.cfg.Agents.Defaults.Provider
@@ -1658,10 +2287,189 @@ func (al *AgentLoop) buildCommandsRuntime(agent *AgentInstance) *commands.Runtim
 			agent.Model = value
 			return oldModel, nil
 		}
+		if agent.Usage != nil {
+			rt.GetDailyUsage = func(since time.Time) ([]memory.DailyUsage, error) {
+				return agent.Usage.Daily(context.Background(), since)
+			}
+			if sessionKey != "" {
+				rt.GetSessionUsage = func() (memory.UsageTotals, error) {
+					return agent.Usage.SessionTotals(context.Background(), sessionKey)
+				}
+			}
+		}
+		if agent.ToolCalls != nil {
+			rt.GetToolStats = func(since time.Time) ([]memory.ToolCallStats, error) {
+				return agent.ToolCalls.Stats(context.Background(), memory.ToolCallFilter{Since: since})
+			}
+		}
+		if approvals := agent.Tools.Approvals(); approvals != nil && sessionKey != "" {
+			rt.ResolveApproval = func(ctx context.Context, channel, chatID, id string, approve bool) (string, error) {
+				return al.resolveApproval(ctx, agent, sessionKey, channel, chatID, id, approve)
+			}
+			rt.ListApprovals = func(channel, chatID string) ([]string, error) {
+				pending, err := approvals.List(channel, chatID)
+				if err != nil {
+					return nil, err
+				}
+				lines := make([]string, 0, len(pending))
+				for _, p := range pending {
+					args, _ := json.Marshal(p.Args)
+					lines = append(lines, fmt.Sprintf("%s: %s %s (expires %s)",
+						p.ID, p.Tool, utils.Truncate(string(args), 100), p.ExpiresAt.Format("15:04")))
+				}
+				return lines, nil
+			}
+		}
+		if sessionKey != "" {
+			rt.GetGenerationParams = func() session.GenerationParams {
+				return agent.Sessions.GetGenerationParams(sessionKey)
+			}
+			rt.SetGenerationParams = func(params session.GenerationParams) error {
+				agent.Sessions.SetGenerationParams(sessionKey, params)
+				return agent.Sessions.Save(sessionKey)
+			}
+			rt.ListPersonas = agent.personaList
+			rt.GetPersona = func() string {
+				return agent.Sessions.GetPersona(sessionKey)
+			}
+			rt.SetPersona = func(name string) error {
+				if _, ok := agent.personaNamed(name); name != "" && !ok {
+					return fmt.Errorf("unknown persona %q, see /persona list", name)
+				}
+				agent.Sessions.SetPersona(sessionKey, name)
+				return agent.Sessions.Save(sessionKey)
+			}
+			rt.GetSummarization = func() (string, bool) {
+				if name := agent.Sessions.GetSummarization(sessionKey); name != "" {
+					return name, true
+				}
+				if agent.Summarization.Strategy != "" {
+					return agent.Summarization.Strategy, false
+				}
+				return summarize.MapReduce, false
+			}
+			rt.SetSummarization = func(name string) error {
+				if name != "" {
+					if _, err := summarize.New(name, 0); err != nil {
+						return err
+					}
+				}
+				agent.Sessions.SetSummarization(sessionKey, name)
+				return agent.Sessions.Save(sessionKey)
+			}
+		}
 	}
 	return rt
 }
 
+// addLinkCommands lets the sender of a command link account with their
+// accounts on other channels.
+func (al *AgentLoop) addLinkCommands(rt *commands.Runtime, account string) {
+	rt.StartLink = func() (string, error) {
+		return al.identities.StartLink(account), nil
+	}
+	rt.CompleteLink = func(ctx context.Context, code string) ([]string, error) {
+		from, to, err := al.identities.CompleteLink(account, code)
+		if err != nil {
+			return nil, err
+		}
+		// What was learned from this account so far follows it.
+		for _, id := range al.registry.ListAgentIDs() {
+			if agent, ok := al.registry.GetAgent(id); ok && agent.Facts != nil {
+				if _, err := agent.Facts.MovePerson(ctx, from, to); err != nil {
+					logger.WarnCF("agent", "Failed to move facts to linked person",
+						map[string]any{"agent_id": id, "error": err.Error()})
+				}
+			}
+		}
+		return al.identities.Accounts(to), nil
+	}
+	rt.Unlink = func() error {
+		return al.identities.Unlink(account)
+	}
+}
+
+// resolveApproval answers a pending tool approval. An approved call runs
+// now; either way the outcome is handed to the agent as a system note in the
+// session that requested it, and the agent's reply is returned.
+func (al *AgentLoop) resolveApproval(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, channel, chatID, id string,
+	approve bool,
+) (string, error) {
+	p, err := agent.Tools.Approvals().Take(id, channel, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	al.auditApproval(ctx, p, sessionKey, channel, chatID, approve)
+	var note string
+	if approve {
+		logger.InfoCF("agent", "Tool call approved",
+			map[string]any{"tool": p.Tool, "approval_id": p.ID, "channel": channel})
+		toolCtx := tools.WithSessionKey(tools.WithApproval(ctx), sessionKey)
+		result := agent.Tools.ExecuteWithContext(toolCtx, p.Tool, p.Args, channel, chatID, nil)
+		content := result.ForLLM
+		if content == "" && result.Err != nil {
+			content = result.Err.Error()
+		}
+		status := "succeeded"
+		if result.IsError {
+			status = "failed"
+		}
+		note = fmt.Sprintf("[System: approval] The user approved %s (id %s). It %s:\n%s", p.Tool, p.ID, status, content)
+	} else {
+		logger.InfoCF("agent", "Tool call denied",
+			map[string]any{"tool": p.Tool, "approval_id": p.ID, "channel": channel})
+		note = fmt.Sprintf("[System: approval] The user denied %s (id %s); it was not run.", p.Tool, p.ID)
+	}
+
+	return al.runAgentLoop(ctx, agent, processOptions{
+		SessionKey:      sessionKey,
+		Channel:         channel,
+		ChatID:          chatID,
+		UserMessage:     note,
+		DefaultResponse: defaultResponse,
+		EnableSummary:   true,
+		SendResponse:    false,
+		Caller:          tools.ToolCaller(ctx),
+	})
+}
+
+// auditApproval records who answered a pending tool call, and how.
+func (al *AgentLoop) auditApproval(
+	ctx context.Context,
+	p tools.PendingApproval,
+	sessionKey, channel, chatID string,
+	approve bool,
+) {
+	if al.audit == nil {
+		return
+	}
+	caller := tools.ToolCaller(ctx)
+	e := audit.Event{
+		Kind:       audit.ToolApproval,
+		Actor:      caller.SenderID,
+		SessionKey: sessionKey,
+		Channel:    channel,
+		ChatID:     chatID,
+		Action:     "deny",
+		Target:     p.Tool,
+		Outcome:    "denied",
+		Detail:     "approval " + p.ID,
+	}
+	if e.Actor == "" {
+		e.Actor = caller.Sender.CanonicalID
+	}
+	if approve {
+		e.Action, e.Outcome = "approve", "approved"
+	}
+	if err := al.audit.Record(ctx, e); err != nil {
+		logger.WarnCF("agent", "Failed to audit approval", map[string]any{"error": err.Error()})
+	}
+}
+
 func mapCommandError(result commands.ExecuteResult) string {
 	if result.Command == "" {
 		return fmt.Sprintf("Failed to execute command: %v", result.Err)
@@ -1685,6 +2493,15 @@ func extractPeer(msg bus.InboundMessage) *routing.RoutePeer {
 	return &routing.RoutePeer{Kind: msg.Peer.Kind, ID: peerID}
 }
 
+// extractSenderID returns the sender's platform ID, which direct chat peers
+// use, so a per-user session is the one of the sender's direct chat.
+func extractSenderID(msg bus.InboundMessage) string {
+	if msg.Sender.PlatformID != "" {
+		return msg.Sender.PlatformID
+	}
+	return msg.SenderID
+}
+
 func inboundMetadata(msg bus.InboundMessage, key string) string {
 	if msg.Metadata == nil {
 		return ""
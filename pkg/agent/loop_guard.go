@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	turnTimeoutResponse = "I ran out of time for this request before finishing. " +
+		"Increase `max_turn_seconds` in config.json."
+	loopingResponse = "I stopped because I kept making the same tool call without getting anywhere."
+)
+
+// repeatGuard counts the tool calls of one turn to catch a model that keeps
+// making the same call with the same arguments.
+type repeatGuard struct {
+	limit  int // calls allowed per signature; 0 disables the guard
+	counts map[string]int
+	warned bool // the model has been told it is looping
+}
+
+func newRepeatGuard(limit int) *repeatGuard {
+	return &repeatGuard{limit: limit, counts: make(map[string]int)}
+}
+
+// allow records tc and reports whether it may run. Once a call has been made
+// limit times, further identical calls are refused.
+func (g *repeatGuard) allow(tc providers.ToolCall) bool {
+	if g.limit <= 0 {
+		return true
+	}
+	// Maps marshal with sorted keys, so equal arguments give equal JSON.
+	args, _ := json.Marshal(tc.Arguments)
+	sig := tc.Name + "\x00" + string(args)
+	g.counts[sig]++
+	return g.counts[sig] <= g.limit
+}
+
+// loopFeedback is the tool result given instead of running a refused call.
+func (g *repeatGuard) loopFeedback(tc providers.ToolCall) string {
+	return fmt.Sprintf(
+		"You're looping: %s has already been called %d times in this turn with exactly these arguments, "+
+			"so it was not run again. Use the results you already have, try a different approach, "+
+			"or answer the user with what you know.",
+		tc.Name, g.limit)
+}
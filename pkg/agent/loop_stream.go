@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// StreamCallbacks report the progress of a turn to interactive frontends.
+// Each may be nil. They are called from the goroutine running the turn:
+// OnToolCall for every call before the batch runs, OnToolResult for every
+// result once the batch has finished, both in the order the model made the
+// calls.
+type StreamCallbacks struct {
+	OnDelta      func(text string)
+	OnToolCall   func(call providers.ToolCall)
+	OnToolResult func(call providers.ToolCall, result *tools.ToolResult)
+}
+
+// chatLLM calls provider. With onDelta set and a provider that can stream,
+// the response text is passed to onDelta as it arrives; otherwise this is a
+// plain Chat call.
+func chatLLM(
+	ctx context.Context,
+	provider providers.LLMProvider,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(string),
+) (*providers.LLMResponse, error) {
+	streamer, ok := provider.(providers.StreamingProvider)
+	if onDelta == nil || !ok {
+		return provider.Chat(ctx, messages, tools, model, options)
+	}
+
+	deltas, err := streamer.ChatStream(ctx, messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+	for delta := range deltas {
+		if delta.Done {
+			if delta.Err != nil {
+				return nil, delta.Err
+			}
+			if delta.Response == nil {
+				return nil, errors.New("stream ended without a response")
+			}
+			return delta.Response, nil
+		}
+		if delta.Content != "" {
+			onDelta(delta.Content)
+		}
+	}
+	// Providers close the stream without a final delta when ctx ends.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("stream ended without a response")
+}
+
+// deltaJoiner forwards streamed text to fn, putting a blank line between the
+// text of successive model calls so "Let me check." and the answer that
+// follows the tool calls do not run together.
+type deltaJoiner struct {
+	fn      func(string)
+	wrote   bool
+	newCall bool
+}
+
+// startCall marks the start of a model call.
+func (j *deltaJoiner) startCall() {
+	j.newCall = true
+}
+
+func (j *deltaJoiner) write(text string) {
+	if j.newCall && j.wrote {
+		j.fn("\n\n")
+	}
+	j.newCall = false
+	j.wrote = true
+	j.fn(text)
+}
+
+// replyStream shows the reply to an inbound message in its chat while it
+// is generated, for channels that can. A nil *replyStream does nothing.
+type replyStream struct {
+	streamer bus.Streamer
+	text     strings.Builder
+	failed   bool
+	// filter applies the output guardrails to the text shown so far; false
+	// stops the stream, leaving the reply to be filtered when it is done.
+	filter func(text string) (string, bool)
+}
+
+// openReplyStream returns a stream to msg's chat, or nil if its channel
+// cannot show replies as they are generated.
+func (al *AgentLoop) openReplyStream(ctx context.Context, msg bus.InboundMessage) *replyStream {
+	streamer, ok := al.bus.GetStreamer(ctx, msg.Channel, msg.ChatID)
+	if !ok {
+		return nil
+	}
+	return &replyStream{streamer: streamer, filter: func(text string) (string, bool) {
+		res := al.guardrails.Filter(guardrails.Output, text)
+		return res.Text, !res.Blocked
+	}}
+}
+
+func (rs *replyStream) callbacks(ctx context.Context) StreamCallbacks {
+	if rs == nil {
+		return StreamCallbacks{}
+	}
+	return StreamCallbacks{OnDelta: func(text string) {
+		if rs.failed {
+			return
+		}
+		rs.text.WriteString(text)
+		shown, ok := rs.text.String(), true
+		if rs.filter != nil {
+			shown, ok = rs.filter(shown)
+		}
+		if !ok {
+			rs.failed = true
+			return
+		}
+		if err := rs.streamer.Update(ctx, shown); err != nil {
+			// Stop updating; finalize still replaces what was shown.
+			rs.failed = true
+			logger.WarnCF("agent", "Streaming reply failed", map[string]any{"error": err.Error()})
+		}
+	}}
+}
+
+// finalize replaces the streamed text with response. It returns false if
+// nothing was streamed or the stream broke, and the response must be
+// published as usual.
+func (rs *replyStream) finalize(ctx context.Context, response string) bool {
+	if rs == nil || rs.text.Len() == 0 {
+		return false
+	}
+	if err := rs.streamer.Finalize(ctx, response); err != nil {
+		if errors.Is(err, bus.ErrNotStreamed) {
+			return false
+		}
+		logger.WarnCF("agent", "Finishing streamed reply failed", map[string]any{"error": err.Error()})
+		return false
+	}
+	return true
+}
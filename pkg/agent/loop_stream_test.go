@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// streamingScriptedProvider streams each scripted response word by word.
+type streamingScriptedProvider struct {
+	*providers.ReplayProvider
+}
+
+func (p streamingScriptedProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan providers.StreamDelta, error) {
+	resp, err := p.Chat(ctx, messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan providers.StreamDelta, 16)
+	go func() {
+		defer close(out)
+		for _, word := range strings.SplitAfter(resp.Content, " ") {
+			out <- providers.StreamDelta{Content: word}
+		}
+		out <- providers.StreamDelta{Done: true, Response: resp}
+	}()
+	return out, nil
+}
+
+func newStreamTestLoop(t *testing.T, provider providers.LLMProvider) *AgentLoop {
+	t.Helper()
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "notes.md"), []byte("buy milk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+			},
+		},
+		Tools: config.ToolsConfig{ReadFile: config.ToolConfig{Enabled: true}},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+}
+
+func TestProcessDirectStream_StreamsAcrossToolCalls(t *testing.T) {
+	provider := streamingScriptedProvider{providers.NewScriptedProvider(
+		&providers.LLMResponse{Content: "Let me look.", ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": "notes.md"},
+		}}},
+		&providers.LLMResponse{Content: "Your note says: buy milk"},
+	)}
+	al := newStreamTestLoop(t, provider)
+
+	var streamed strings.Builder
+	reply, err := al.ProcessDirectStream(context.Background(), "what is in my notes?", "agent:main:cli:test",
+		func(s string) { streamed.WriteString(s) })
+	if err != nil {
+		t.Fatalf("ProcessDirectStream() error = %v", err)
+	}
+	if reply != "Your note says: buy milk" {
+		t.Errorf("reply = %q", reply)
+	}
+	if got, want := streamed.String(), "Let me look.\n\nYour note says: buy milk"; got != want {
+		t.Errorf("streamed = %q, want %q", got, want)
+	}
+
+	_, sessions := al.DefaultAgentSessions()
+	if len(sessions.GetHistory("agent:main:cli:test")) == 0 {
+		t.Error("expected the turn to be saved in the requested session")
+	}
+}
+
+func TestProcessDirectStream_NonStreamingProvider(t *testing.T) {
+	al := newStreamTestLoop(t, providers.NewScriptedProvider(&providers.LLMResponse{Content: "hello"}))
+
+	var deltas int
+	reply, err := al.ProcessDirectStream(context.Background(), "hi", "agent:main:cli:test",
+		func(string) { deltas++ })
+	if err != nil {
+		t.Fatalf("ProcessDirectStream() error = %v", err)
+	}
+	if reply != "hello" || deltas != 0 {
+		t.Errorf("reply = %q, deltas = %d; want the plain response and no deltas", reply, deltas)
+	}
+}
+
+func TestProcessDirectWithCallbacks_ToolEvents(t *testing.T) {
+	provider := streamingScriptedProvider{providers.NewScriptedProvider(
+		&providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": "notes.md"},
+		}}},
+		&providers.LLMResponse{Content: "Your note says: buy milk"},
+	)}
+	al := newStreamTestLoop(t, provider)
+
+	var events []string
+	reply, err := al.ProcessDirectWithCallbacks(context.Background(), "what is in my notes?",
+		"agent:main:api:test", "api", "test", StreamCallbacks{
+			OnDelta: func(string) { events = append(events, "delta") },
+			OnToolCall: func(call providers.ToolCall) {
+				events = append(events, "call "+call.ID+" "+call.Name)
+			},
+			OnToolResult: func(call providers.ToolCall, result *tools.ToolResult) {
+				events = append(events, "result "+call.ID+" "+strconv.FormatBool(strings.Contains(result.ForLLM, "buy milk")))
+			},
+		})
+	if err != nil {
+		t.Fatalf("ProcessDirectWithCallbacks() error = %v", err)
+	}
+	if reply != "Your note says: buy milk" {
+		t.Errorf("reply = %q", reply)
+	}
+	if len(events) < 3 || events[0] != "call call_1 read_file" || events[1] != "result call_1 true" ||
+		events[2] != "delta" {
+		t.Errorf("events = %v, want the tool call, its result, then streamed text", events)
+	}
+}
+
+// fakeStreamer records what a channel would show of a streamed reply.
+type fakeStreamer struct {
+	updates []string
+	final   chan string
+}
+
+func (f *fakeStreamer) Update(_ context.Context, content string) error {
+	f.updates = append(f.updates, content)
+	return nil
+}
+
+func (f *fakeStreamer) Finalize(_ context.Context, content string) error {
+	f.final <- content
+	return nil
+}
+
+func (f *fakeStreamer) GetStreamer(context.Context, string, string) (bus.Streamer, bool) {
+	return f, true
+}
+
+func TestRun_StreamsReplyToChannel(t *testing.T) {
+	provider := streamingScriptedProvider{providers.NewScriptedProvider(&providers.LLMResponse{Content: "Hello there"})}
+	al := newStreamTestLoop(t, provider)
+	streamer := &fakeStreamer{final: make(chan string, 1)}
+	al.bus.SetStreamDelegate(streamer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go al.Run(ctx)
+	al.bus.PublishInbound(ctx, bus.InboundMessage{
+		Channel: "telegram", SenderID: "telegram:1", ChatID: "1", Content: "hi",
+		SessionKey: "agent:main:telegram:direct:1",
+	})
+
+	select {
+	case final := <-streamer.final:
+		if final != "Hello there" || len(streamer.updates) != 2 || streamer.updates[0] != "Hello " {
+			t.Errorf("final = %q, updates = %q", final, streamer.updates)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply not finalized")
+	}
+	// The streamed reply is not sent a second time.
+	probe, probeCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer probeCancel()
+	if msg, ok := al.bus.SubscribeOutbound(probe); ok {
+		t.Errorf("unexpected outbound %+v", msg)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/sipeed/picoclaw/pkg/media"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -575,6 +577,538 @@ func TestProcessMessage_SwitchModelShowModelConsistency(t *testing.T) {
 	}
 }
 
+type usageMockProvider struct{}
+
+func (m *usageMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{
+		Content: "ok",
+		Usage:   &providers.UsageInfo{PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200},
+	}, nil
+}
+
+func (m *usageMockProvider) GetDefaultModel() string {
+	return "usage-mock-model"
+}
+
+func TestProcessMessage_RecordsUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "priced-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{{
+			ModelName:   "priced-model",
+			Model:       "openai/gpt-4o",
+			InputPrice:  2.5,
+			OutputPrice: 10,
+		}},
+	}
+
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &usageMockProvider{})
+	helper := testHelper{al: al}
+	msg := bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Content:  "hello",
+		Peer:     bus.Peer{Kind: "direct", ID: "user1"},
+	}
+	_ = helper.executeAndGetResponse(t, context.Background(), msg)
+
+	msg.Content = "/usage session"
+	reply := helper.executeAndGetResponse(t, context.Background(), msg)
+	want := "Session usage: 1 requests, 1200 tokens (1000 in / 200 out), $0.0045"
+	if reply != want {
+		t.Fatalf("/usage session reply = %q, want %q", reply, want)
+	}
+}
+
+// modelRecordingProvider records the model and options requested on each call.
+type modelRecordingProvider struct {
+	mu      sync.Mutex
+	models  []string
+	options []map[string]any
+	tools   [][]string
+	systems []string
+}
+
+func (m *modelRecordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.mu.Lock()
+	m.models = append(m.models, model)
+	m.options = append(m.options, opts)
+	var names []string
+	for _, td := range tools {
+		names = append(names, td.Function.Name)
+	}
+	m.tools = append(m.tools, names)
+	m.systems = append(m.systems, messages[0].Content)
+	m.mu.Unlock()
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (m *modelRecordingProvider) GetDefaultModel() string {
+	return "recording-model"
+}
+
+func TestProcessDirect_AppliesSessionGenerationParams(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	temp := 0.1
+	al.registry.GetDefaultAgent().Sessions.SetGenerationParams("agent:main:main", session.GenerationParams{
+		Temperature: &temp,
+		MaxTokens:   256,
+		Stop:        []string{"END"},
+	})
+	if _, err := al.ProcessDirect(context.Background(), "hi", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	opts := provider.options[0]
+	if opts["temperature"] != 0.1 || opts["max_tokens"] != 256 {
+		t.Errorf("temperature=%v max_tokens=%v, want session overrides", opts["temperature"], opts["max_tokens"])
+	}
+	if stop, _ := opts["stop"].([]string); !slices.Equal(stop, []string{"END"}) {
+		t.Errorf("stop = %v, want [END]", opts["stop"])
+	}
+	if _, ok := opts["top_p"]; ok {
+		t.Error("top_p should not be sent without an override")
+	}
+}
+
+func TestProcessDirect_Persona(t *testing.T) {
+	temp := 0.2
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "strong",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Personas: map[string]config.PersonaConfig{
+					"ops": {
+						Description: "terse operations bot",
+						Prompt:      "You are a terse ops bot on {{channel}}.",
+						Model:       "cheap",
+						Temperature: &temp,
+						Tools:       []string{"lookup"},
+					},
+				},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "strong", Model: "openai/gpt-4o"},
+			{ModelName: "cheap", Model: "openai/gpt-4o-mini"},
+		},
+	}
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	for _, name := range []string{"lookup", "other"} {
+		al.RegisterTool(tools.NewFuncTool(name, "A test tool", nil,
+			func(ctx context.Context, args map[string]any) *tools.ToolResult {
+				return tools.NewToolResult("ok")
+			}))
+	}
+	ctx := context.Background()
+
+	reply, err := al.ProcessDirect(ctx, "/persona use pirate", "cli:direct")
+	if err != nil || !strings.Contains(reply, `unknown persona "pirate"`) {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+	if _, err := al.ProcessDirect(ctx, "/persona use ops", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.ProcessDirect(ctx, "disk usage?", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.models) != 1 {
+		t.Fatalf("got %d LLM calls, want 1", len(provider.models))
+	}
+	if provider.models[0] != "cheap" || provider.options[0]["temperature"] != 0.2 {
+		t.Errorf("model=%q temperature=%v, want the persona's", provider.models[0], provider.options[0]["temperature"])
+	}
+	if !slices.Equal(provider.tools[0], []string{"lookup"}) {
+		t.Errorf("tools = %v, want [lookup]", provider.tools[0])
+	}
+	if !strings.Contains(provider.systems[0], "You are a terse ops bot on cli.") {
+		t.Errorf("system prompt lacks the persona:\n%s", provider.systems[0])
+	}
+
+	// The persona is kept with the session and can be dropped again.
+	if got := al.registry.GetDefaultAgent().Sessions.GetPersona("agent:main:main"); got != "ops" {
+		t.Errorf("session persona = %q", got)
+	}
+	if _, err := al.ProcessDirect(ctx, "/persona reset", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.ProcessDirect(ctx, "disk usage?", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if provider.models[1] != "strong" || strings.Contains(provider.systems[1], "## Persona") ||
+		len(provider.tools[1]) < 2 {
+		t.Errorf("after reset: model=%q tools=%v", provider.models[1], provider.tools[1])
+	}
+}
+
+func TestProcessHeartbeat_UsesHeartbeatRoute(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "strong",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Routing: &config.RoutingConfig{
+					Enabled: true,
+					Routes:  map[string]string{"heartbeat": "cheap", "summarization": "cheap"},
+				},
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "strong", Model: "openai/gpt-4o"},
+			{ModelName: "cheap", Model: "openai/gpt-4o-mini"},
+		},
+	}
+
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	if _, err := al.ProcessHeartbeat(context.Background(), "check tasks", "cli", "direct"); err != nil {
+		t.Fatalf("ProcessHeartbeat() error = %v", err)
+	}
+	if _, err := al.ProcessDirect(context.Background(), "hello", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if len(provider.models) != 2 || provider.models[0] != "cheap" || provider.models[1] != "strong" {
+		t.Fatalf("models = %v, want [cheap strong]", provider.models)
+	}
+	if got := summaryModel(al.registry.GetDefaultAgent()); got != "cheap" {
+		t.Errorf("summaryModel() = %q, want cheap", got)
+	}
+}
+
+func TestProcessDirect_ScriptedToolTurnRunsOffline(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "notes.md"), []byte("buy milk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+			},
+		},
+		Tools: config.ToolsConfig{ReadFile: config.ToolConfig{Enabled: true}},
+	}
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        "call_1",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": "notes.md"},
+		}}},
+		&providers.LLMResponse{Content: "Your note says: buy milk"},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	reply, err := al.ProcessDirect(context.Background(), "what is in my notes?", "cli:direct")
+	if err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+	if reply != "Your note says: buy milk" {
+		t.Errorf("reply = %q", reply)
+	}
+	if provider.Remaining() != 0 {
+		t.Errorf("Remaining() = %d, want 0", provider.Remaining())
+	}
+
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	var toolResult string
+	for _, m := range history {
+		if m.Role == "tool" {
+			toolResult = m.Content
+		}
+	}
+	if !strings.Contains(toolResult, "buy milk") {
+		t.Errorf("tool result = %q, want file contents", toolResult)
+	}
+}
+
+func TestProcessDirect_ToolApprovalFlow(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+			},
+		},
+		Tools: config.ToolsConfig{
+			WriteFile: config.ToolConfig{Enabled: true},
+			Approval:  config.ApprovalConfig{Tools: []string{"write_file"}},
+		},
+	}
+	writeCall := func(id string) *providers.LLMResponse {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        id,
+			Name:      "write_file",
+			Arguments: map[string]any{"path": "todo.md", "content": "call mum"},
+		}}}
+	}
+	provider := providers.NewScriptedProvider(
+		writeCall("call_1"),
+		&providers.LLMResponse{Content: "Please approve the write."},
+		&providers.LLMResponse{Content: "Saved your todo."},
+		writeCall("call_2"),
+		&providers.LLMResponse{Content: "Please approve the write."},
+		&providers.LLMResponse{Content: "OK, I won't write it."},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	approvals := al.registry.GetDefaultAgent().Tools.Approvals()
+	ctx := context.Background()
+	path := filepath.Join(workspace, "todo.md")
+
+	pendingID := func() string {
+		t.Helper()
+		pending, err := approvals.List("cli", "direct")
+		if err != nil || len(pending) != 1 {
+			t.Fatalf("pending approvals = %+v, %v; want one", pending, err)
+		}
+		return pending[0].ID
+	}
+
+	if _, err := al.ProcessDirect(ctx, "note: call mum", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file written before approval (stat err = %v)", err)
+	}
+
+	reply, err := al.ProcessDirect(ctx, "/approve "+pendingID(), "cli:direct")
+	if err != nil || reply != "Saved your todo." {
+		t.Fatalf("/approve reply = %q, %v", reply, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "call mum" {
+		t.Fatalf("todo.md = %q, %v; want written after approval", data, err)
+	}
+
+	os.Remove(path)
+	if _, err := al.ProcessDirect(ctx, "note: call mum", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+	reply, err = al.ProcessDirect(ctx, "/deny "+pendingID(), "cli:direct")
+	if err != nil || reply != "OK, I won't write it." {
+		t.Fatalf("/deny reply = %q, %v", reply, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file written after deny (stat err = %v)", err)
+	}
+
+	reply, _ = al.ProcessDirect(ctx, "/approve nope", "cli:direct")
+	if !strings.Contains(reply, "no pending approval") {
+		t.Errorf("/approve unknown id reply = %q", reply)
+	}
+}
+
+// concurrencyTool records the peak number of overlapping executions.
+type concurrencyTool struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (t *concurrencyTool) Name() string               { return "slow_echo" }
+func (t *concurrencyTool) Description() string        { return "Echoes its input after a short delay" }
+func (t *concurrencyTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+
+func (t *concurrencyTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	t.mu.Lock()
+	t.running++
+	t.peak = max(t.peak, t.running)
+	t.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	t.mu.Lock()
+	t.running--
+	t.mu.Unlock()
+	return tools.SilentResult(fmt.Sprint(args["text"]))
+}
+
+func TestProcessDirect_ParallelToolCallsRespectLimit(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				MaxParallelTools:  2,
+			},
+		},
+	}
+	var calls []providers.ToolCall
+	for i := range 5 {
+		calls = append(calls, providers.ToolCall{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      "slow_echo",
+			Arguments: map[string]any{"text": fmt.Sprintf("result %d", i)},
+		})
+	}
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{ToolCalls: calls},
+		&providers.LLMResponse{Content: "done"},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	tool := &concurrencyTool{}
+	al.RegisterTool(tool)
+
+	if _, err := al.ProcessDirect(context.Background(), "echo five things", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+	if tool.peak != 2 {
+		t.Errorf("peak concurrency = %d, want 2", tool.peak)
+	}
+
+	var results []string
+	for _, m := range al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:main") {
+		if m.Role == "tool" {
+			results = append(results, m.ToolCallID+"="+m.Content)
+		}
+	}
+	want := []string{"call_0=result 0", "call_1=result 1", "call_2=result 2", "call_3=result 3", "call_4=result 4"}
+	if !slices.Equal(results, want) {
+		t.Errorf("tool results = %v, want %v", results, want)
+	}
+}
+
+func TestProcessDirect_StopsRepeatedToolCalls(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:            t.TempDir(),
+				Model:                "test-model",
+				MaxTokens:            4096,
+				MaxToolIterations:    10,
+				MaxRepeatedToolCalls: 2,
+			},
+		},
+	}
+	var script []*providers.LLMResponse
+	for i := range 5 {
+		script = append(script, &providers.LLMResponse{ToolCalls: []providers.ToolCall{{
+			ID:        fmt.Sprintf("call_%d", i),
+			Name:      "lookup",
+			Arguments: map[string]any{"q": "weather"},
+		}}})
+	}
+	provider := providers.NewScriptedProvider(append(script, &providers.LLMResponse{Content: "done"})...)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	runs := 0
+	al.RegisterTool(tools.NewFuncTool("lookup", "Look something up", nil,
+		func(ctx context.Context, args map[string]any) *tools.ToolResult {
+			runs++
+			return tools.NewToolResult("no results")
+		}))
+
+	reply, err := al.ProcessDirect(context.Background(), "what's the weather?", "cli:direct")
+	if err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+	// Two calls run, the third is answered with loop feedback, and the
+	// fourth ends the turn.
+	if runs != 2 {
+		t.Errorf("tool ran %d times, want 2", runs)
+	}
+	if reply != loopingResponse {
+		t.Errorf("reply = %q", reply)
+	}
+	if provider.Remaining() != 2 {
+		t.Errorf("Remaining() = %d, want 2", provider.Remaining())
+	}
+	var feedback int
+	for _, m := range al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:main") {
+		if m.Role == "tool" && strings.HasPrefix(m.Content, "You're looping") {
+			feedback++
+		}
+	}
+	if feedback != 2 {
+		t.Errorf("got %d loop feedback results, want 2", feedback)
+	}
+}
+
+func TestProcessDirect_TurnTimeLimit(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	call := func(id string) *providers.LLMResponse {
+		return &providers.LLMResponse{ToolCalls: []providers.ToolCall{{ID: id, Name: "slow", Arguments: map[string]any{}}}}
+	}
+	provider := providers.NewScriptedProvider(call("call_1"), call("call_2"), &providers.LLMResponse{Content: "done"})
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	al.registry.GetDefaultAgent().MaxTurnDuration = time.Millisecond
+	al.RegisterTool(tools.NewFuncTool("slow", "Take a while", nil,
+		func(ctx context.Context, args map[string]any) *tools.ToolResult {
+			time.Sleep(10 * time.Millisecond)
+			return tools.NewToolResult("ok")
+		}))
+
+	reply, err := al.ProcessDirect(context.Background(), "do it", "cli:direct")
+	if err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+	if reply != turnTimeoutResponse {
+		t.Errorf("reply = %q", reply)
+	}
+	if provider.Remaining() != 2 {
+		t.Errorf("Remaining() = %d, want 2", provider.Remaining())
+	}
+}
+
 // TestToolResult_SilentToolDoesNotSendUserMessage verifies silent tools don't trigger outbound
 func TestToolResult_SilentToolDoesNotSendUserMessage(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
@@ -1116,3 +1650,74 @@ func TestResolveMediaRefs_UsesMetaContentType(t *testing.T) {
 		t.Fatalf("expected jpeg prefix, got %q", result[0].Media[0][:30])
 	}
 }
+
+func TestJobNotifier_DeliversToConversation(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	defer msgBus.Close()
+	notify := jobNotifier(msgBus)
+
+	job := tools.Job{
+		ID:         "a1b2c3",
+		Tool:       "exec",
+		Channel:    "telegram",
+		ChatID:     "42",
+		SessionKey: "agent:main:telegram:direct:42",
+		Status:     tools.JobDone,
+		Progress:   "50%",
+	}
+	notify(tools.JobEvent{Job: job})
+	notify(tools.JobEvent{Job: job, Result: tools.NewToolResult("build ok")})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || out.ChatID != "42" || !strings.Contains(out.Content, "50%") {
+		t.Fatalf("progress message = %+v, %v", out, ok)
+	}
+	in, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound result message")
+	}
+	if in.Channel != "system" || in.ChatID != "telegram:42" || in.SessionKey != job.SessionKey {
+		t.Errorf("inbound routing = %+v", in)
+	}
+	if !strings.Contains(in.Content, "a1b2c3") || !strings.HasSuffix(in.Content, "Result:\nbuild ok") {
+		t.Errorf("inbound content = %q", in.Content)
+	}
+
+	// Jobs started from internal channels are not reported anywhere.
+	job.Channel = "cli"
+	notify(tools.JobEvent{Job: job, Result: tools.NewToolResult("quiet")})
+	short, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	if msg, ok := msgBus.ConsumeInbound(short); ok {
+		t.Errorf("internal channel job published %+v", msg)
+	}
+}
+
+func TestNewAgentLoop_RecordsDataset(t *testing.T) {
+	workspace := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		Dataset: config.DatasetConfig{Enabled: true},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &simpleMockProvider{response: "Noted, bob@example.com."})
+	if _, err := al.ProcessDirect(context.Background(), "hello", "cli:direct"); err != nil {
+		t.Fatalf("ProcessDirect() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(workspace, "dataset", time.Now().Format("2006-01-02")+".jsonl"))
+	if err != nil {
+		t.Fatalf("dataset not written: %v", err)
+	}
+	if !strings.Contains(string(data), `"content":"Noted, [EMAIL]."`) {
+		t.Errorf("dataset = %s, want the masked answer", data)
+	}
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// memoryQueryTables exposes the agent's sessions, usage ledger, tool call
+// log, facts and tasks to the memory_query tool. usage, calls, facts and
+// tasks may be nil, in which case their tables are empty. Like facts and
+// tasks, sessions and their messages are limited to the person asking.
+func memoryQueryTables(
+	sessions *session.SessionManager,
+	usage *memory.UsageLedger,
+	calls *memory.ToolCallLog,
+	facts *memory.FactStore,
+	tasks *memory.TaskStore,
+) []tools.QueryTable {
+	return []tools.QueryTable{
+		{
+			Name:    "sessions",
+			Columns: []string{"key TEXT", "created_at TEXT", "updated_at TEXT", "message_count INTEGER", "summary TEXT"},
+			Doc:     "one row per conversation; key is \"agent:<id>:<channel>:...\"",
+			Rows: func(ctx context.Context) ([][]any, error) {
+				var rows [][]any
+				for _, s := range visibleSessions(ctx, sessions) {
+					rows = append(rows, []any{
+						s.Key, queryTime(s.Created), queryTime(s.Updated), len(s.Messages), s.Summary,
+					})
+				}
+				return rows, nil
+			},
+		},
+		{
+			Name:    "messages",
+			Columns: []string{"session_key TEXT", "seq INTEGER", "role TEXT", "content TEXT", "tool_calls TEXT"},
+			Doc: "history kept in each session (older messages are summarized away); " +
+				"role is user, assistant or tool; no timestamps, use usage for time ranges",
+			Rows: func(ctx context.Context) ([][]any, error) {
+				var rows [][]any
+				for _, s := range visibleSessions(ctx, sessions) {
+					for i, m := range s.Messages {
+						var calls []string
+						for _, tc := range m.ToolCalls {
+							if tc.Function != nil {
+								calls = append(calls, tc.Function.Name)
+							} else {
+								calls = append(calls, tc.Name)
+							}
+						}
+						rows = append(rows, []any{s.Key, i, m.Role, m.Content, strings.Join(calls, ",")})
+					}
+				}
+				return rows, nil
+			},
+		},
+		{
+			Name: "usage",
+			Columns: []string{
+				"time TEXT", "session_key TEXT", "agent_id TEXT", "model TEXT", "prompt_tokens INTEGER",
+				"completion_tokens INTEGER", "total_tokens INTEGER", "cached_tokens INTEGER", "cost_usd REAL",
+			},
+			Doc: "one row per LLM call; counts the turns handled over time",
+			Rows: func(ctx context.Context) ([][]any, error) {
+				if usage == nil {
+					return nil, nil
+				}
+				records, err := usage.Records(ctx)
+				if err != nil {
+					return nil, err
+				}
+				rows := make([][]any, 0, len(records))
+				for _, r := range records {
+					rows = append(rows, []any{
+						queryTime(r.Time), r.SessionKey, r.AgentID, r.Model, r.PromptTokens,
+						r.CompletionTokens, r.TotalTokens, r.CachedTokens, r.CostUSD,
+					})
+				}
+				return rows, nil
+			},
+		},
+		{
+			Name: "tool_calls",
+			Columns: []string{
+				"time TEXT", "session_key TEXT", "channel TEXT", "chat_id TEXT", "sender_id TEXT", "tool TEXT",
+				"args_hash TEXT", "duration_ms INTEGER", "outcome TEXT", "error TEXT",
+			},
+			Doc: "one row per tool call; outcome is ok, error, invalid, denied, pending or started; " +
+				"args_hash is the SHA-256 of the arguments, which are not stored",
+			Rows: func(ctx context.Context) ([][]any, error) {
+				if calls == nil {
+					return nil, nil
+				}
+				records, err := calls.Records(ctx, memory.ToolCallFilter{})
+				if err != nil {
+					return nil, err
+				}
+				rows := make([][]any, 0, len(records))
+				for _, r := range records {
+					rows = append(rows, []any{
+						queryTime(r.Time), r.SessionKey, r.Channel, r.ChatID, r.SenderID, r.Tool,
+						r.ArgsHash, r.DurationMS, r.Outcome, r.Error,
+					})
+				}
+				return rows, nil
+			},
+		},
+		{
+			Name:    "facts",
+			Columns: []string{"id TEXT", "content TEXT", "tags TEXT", "source TEXT", "person TEXT", "created_at TEXT"},
+			Doc:     "long-term facts saved with memory_save; tags are comma-separated, person is empty for shared facts",
+			Rows: func(ctx context.Context) ([][]any, error) {
+				if facts == nil {
+					return nil, nil
+				}
+				list, err := facts.List(ctx)
+				if err != nil {
+					return nil, err
+				}
+				rows := make([][]any, 0, len(list))
+				for _, f := range list {
+					rows = append(rows, []any{f.ID, f.Content, strings.Join(f.Tags, ","), f.Source, f.Person, queryTime(f.CreatedAt)})
+				}
+				return rows, nil
+			},
+		},
+		{
+			Name: "tasks",
+			Columns: []string{
+				"id TEXT", "title TEXT", "notes TEXT", "state TEXT", "due TEXT", "source TEXT", "person TEXT",
+				"created_at TEXT", "updated_at TEXT", "done_at TEXT",
+			},
+			Doc: "the to-do list of the tasks tool; state is pending, in_progress, blocked or done",
+			Rows: func(ctx context.Context) ([][]any, error) {
+				if tasks == nil {
+					return nil, nil
+				}
+				list, err := tasks.List(ctx)
+				if err != nil {
+					return nil, err
+				}
+				rows := make([][]any, 0, len(list))
+				for _, t := range list {
+					rows = append(rows, []any{
+						t.ID, t.Title, t.Notes, string(t.State), queryTime(t.Due), t.Source, t.Person,
+						queryTime(t.CreatedAt), queryTime(t.UpdatedAt), queryTime(t.DoneAt),
+					})
+				}
+				return rows, nil
+			},
+		},
+	}
+}
+
+// visibleSessions returns the sessions the caller in ctx may read, in key
+// order: the direct conversations of its person and the session the call
+// comes from. Without a person all of them are visible, as facts are.
+func visibleSessions(ctx context.Context, sessions *session.SessionManager) []session.Session {
+	person := memory.PersonFrom(ctx)
+	own := tools.ToolSessionKey(ctx)
+	var visible []session.Session
+	for _, key := range sessions.Keys() {
+		s, ok := sessions.Snapshot(key)
+		if !ok || person != "" && s.Person != person && s.Key != own {
+			continue
+		}
+		visible = append(visible, s)
+	}
+	return visible
+}
+
+// queryTime formats t the way SQLite's date functions expect, in UTC.
+func queryTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(time.DateTime)
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+func TestMemoryQueryTables(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	sessions := session.NewSessionManager("")
+	sessions.AddMessage("telegram:1", "user", "hello")
+	sessions.AddMessage("telegram:1", "assistant", "hi there")
+	sessions.AddMessage("discord:2", "user", "ping")
+
+	usage, err := memory.NewUsageLedger(dir)
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	lastWeek := time.Now().AddDate(0, 0, -3)
+	for _, rec := range []memory.UsageRecord{
+		{Time: lastWeek, SessionKey: "telegram:1", Model: "gpt", TotalTokens: 10},
+		{Time: lastWeek, SessionKey: "discord:2", Model: "gpt", TotalTokens: 20},
+		{Time: time.Now().AddDate(0, -2, 0), SessionKey: "telegram:1", Model: "gpt", TotalTokens: 30},
+	} {
+		if err := usage.Record(ctx, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	calls, err := memory.NewToolCallLog(dir)
+	if err != nil {
+		t.Fatalf("NewToolCallLog: %v", err)
+	}
+	for _, rec := range []memory.ToolCallRecord{
+		{SessionKey: "telegram:1", Tool: "exec", DurationMS: 120, Outcome: memory.ToolOutcomeOK},
+		{SessionKey: "telegram:1", Tool: "exec", DurationMS: 30, Outcome: memory.ToolOutcomeDenied},
+		{SessionKey: "discord:2", Tool: "web_fetch", DurationMS: 800, Outcome: memory.ToolOutcomeError},
+	} {
+		if err := calls.Record(ctx, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	tasks, err := memory.NewTaskStore(dir)
+	if err != nil {
+		t.Fatalf("NewTaskStore: %v", err)
+	}
+	for _, task := range []memory.Task{
+		{Title: "Renew the passport", Due: time.Now().AddDate(0, 0, -3)},
+		{Title: "Pay the rent", Due: time.Now().AddDate(0, 0, 3)},
+		{Title: "Call the plumber", State: memory.TaskDone},
+	} {
+		if _, err := tasks.Add(ctx, task); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	tool := tools.NewMemoryQueryTool(memoryQueryTables(sessions, usage, calls, nil, tasks))
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			"SELECT count(*) AS n FROM usage WHERE time >= datetime('now', '-7 days')",
+			"n\n2\n(1 row)",
+		},
+		{
+			"SELECT session_key, count(*) FROM messages WHERE role = 'user' GROUP BY session_key ORDER BY 1",
+			"session_key | count(*)\ndiscord:2 | 1\ntelegram:1 | 1\n(2 rows)",
+		},
+		{
+			"SELECT key, message_count FROM sessions ORDER BY message_count DESC",
+			"key | message_count\ntelegram:1 | 2\ndiscord:2 | 1\n(2 rows)",
+		},
+		{
+			"SELECT tool, outcome FROM tool_calls WHERE session_key = 'telegram:1' ORDER BY outcome",
+			"tool | outcome\nexec | denied\nexec | ok\n(2 rows)",
+		},
+		{"SELECT count(*) AS n FROM facts", "n\n0\n(1 row)"},
+		{
+			"SELECT title FROM tasks WHERE state != 'done' AND due < datetime('now')",
+			"title\nRenew the passport\n(1 row)",
+		},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(ctx, map[string]any{"query": tt.query})
+		if result.IsError {
+			t.Fatalf("%s: %s", tt.query, result.ForLLM)
+		}
+		if result.ForLLM != tt.want {
+			t.Errorf("%s\ngot:\n%s\nwant:\n%s", tt.query, result.ForLLM, tt.want)
+		}
+	}
+}
+
+func TestMemoryQueryTables_OnlyThePersonsSessions(t *testing.T) {
+	sessions := session.NewSessionManager("")
+	sessions.AddMessage("telegram:alice", "user", "my salary is 5000")
+	sessions.SetPerson("telegram:alice", "alice")
+	sessions.AddMessage("discord:alice", "user", "hello")
+	sessions.SetPerson("discord:alice", "alice")
+	sessions.AddMessage("telegram:bob", "user", "my PIN is 4711")
+	sessions.SetPerson("telegram:bob", "bob")
+	sessions.AddMessage("telegram:group", "user", "lunch?")
+
+	tool := tools.NewMemoryQueryTool(memoryQueryTables(sessions, nil, nil, nil, nil))
+	ctx := tools.WithSessionKey(memory.WithPerson(context.Background(), "alice"), "telegram:group")
+	for query, want := range map[string]string{
+		"SELECT key FROM sessions ORDER BY key":                         "key\ndiscord:alice\ntelegram:alice\ntelegram:group\n(3 rows)",
+		"SELECT count(*) AS n FROM messages WHERE content LIKE '%PIN%'": "n\n0\n(1 row)",
+	} {
+		result := tool.Execute(ctx, map[string]any{"query": query})
+		if result.IsError {
+			t.Fatalf("%s: %s", query, result.ForLLM)
+		}
+		if result.ForLLM != want {
+			t.Errorf("%s\ngot:\n%s\nwant:\n%s", query, result.ForLLM, want)
+		}
+	}
+}
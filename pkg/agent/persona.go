@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Persona is a configured profile a session can switch to with /persona.
+type Persona struct {
+	Name        string
+	Description string
+	Prompt      string
+	Model       string // "" keeps the agent's model
+	Temperature *float64
+	Tools       []string // nil keeps all of the agent's tools
+	// Candidates holds the resolved provider candidates for Model.
+	Candidates []providers.FallbackCandidate
+}
+
+// newPersonas builds the configured personas, resolving their models with
+// resolve. A persona whose model is not in model_list keeps the agent's.
+func newPersonas(
+	cfgs map[string]config.PersonaConfig,
+	resolve func(model string) []providers.FallbackCandidate,
+	agentID string,
+) map[string]*Persona {
+	if len(cfgs) == 0 {
+		return nil
+	}
+	personas := make(map[string]*Persona, len(cfgs))
+	for name, pc := range cfgs {
+		p := &Persona{
+			Name:        name,
+			Description: pc.Description,
+			Prompt:      strings.TrimSpace(pc.Prompt),
+			Temperature: pc.Temperature,
+			Tools:       pc.Tools,
+		}
+		if pc.Model != "" {
+			if resolved := resolve(pc.Model); len(resolved) > 0 {
+				p.Model, p.Candidates = pc.Model, resolved
+			} else {
+				log.Printf("persona %q: model %q not found in model_list — using the model of agent %q",
+					name, pc.Model, agentID)
+			}
+		}
+		personas[name] = p
+	}
+	return personas
+}
+
+// persona returns the persona the session has switched to, or nil.
+func (a *AgentInstance) persona(sessionKey string) *Persona {
+	name := a.Sessions.GetPersona(sessionKey)
+	if name == "" {
+		return nil
+	}
+	p, ok := a.personaNamed(name)
+	if !ok {
+		logger.WarnCF("agent", "Session persona is no longer configured, using defaults",
+			map[string]any{"agent_id": a.ID, "session_key": sessionKey, "persona": name})
+	}
+	return p
+}
+
+// personaNamed returns the persona called name, if it is configured.
+func (a *AgentInstance) personaNamed(name string) (*Persona, bool) {
+	a.personasMu.RLock()
+	defer a.personasMu.RUnlock()
+	p, ok := a.Personas[name]
+	return p, ok
+}
+
+// setPersonas replaces the agent's personas, as when the config is
+// reloaded. Sessions keep the persona they switched to by name.
+func (a *AgentInstance) setPersonas(personas map[string]*Persona) {
+	a.personasMu.Lock()
+	defer a.personasMu.Unlock()
+	a.Personas = personas
+}
+
+// personaList describes the agent's personas for /persona list.
+func (a *AgentInstance) personaList() []string {
+	a.personasMu.RLock()
+	defer a.personasMu.RUnlock()
+	lines := make([]string, 0, len(a.Personas))
+	for name, p := range a.Personas {
+		if p.Description != "" {
+			name += " - " + p.Description
+		}
+		lines = append(lines, name)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// toolRegistry returns the tools available under p.
+func (p *Persona) toolRegistry(all *tools.ToolRegistry) *tools.ToolRegistry {
+	if p == nil || p.Tools == nil {
+		return all
+	}
+	return all.Subset(func(name string) bool { return slices.Contains(p.Tools, name) })
+}
+
+// withPersona adds the prompt of p to the system message built by
+// BuildMessages, right after the prompt shared by all sessions. The block
+// has no breakpoint of its own: the channel or summary breakpoint after it
+// caches it along with the rest of the prefix, and Anthropic accepts only
+// four breakpoints per request.
+func (cb *ContextBuilder) withPersona(
+	messages []providers.Message,
+	p *Persona,
+	channel, chatID string,
+) []providers.Message {
+	if p == nil || p.Prompt == "" {
+		return messages
+	}
+	_, _, vars := cb.promptLayers()
+	text := "## Persona\n\nIn this conversation, take on the following persona. " +
+		"Where it conflicts with the introduction above, the persona wins.\n\n" +
+		vars.with("channel", channel, "chat_id", chatID).expand(p.Prompt)
+	return insertSystemPart(messages, 1, providers.ContentBlock{Type: "text", Text: text})
+}
@@ -0,0 +1,339 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/commands"
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tasks"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	planPrompt = "Break the user's task into at most %d steps, to be carried out one after another " +
+		"with the tools listed below. Each step should be a concrete action whose outcome can be checked; " +
+		"use a single step when the task is simple. The last step gives the user the answer.\n\n" +
+		"Reply with only a JSON object such as {\"steps\": [\"first step\", \"second step\"]}."
+
+	// planResultLen caps how much of each finished step's result is shown
+	// to the steps after it.
+	planResultLen = 1000
+)
+
+// addPlanCommands lets /plan make and run plans in the session. origin is
+// the message carrying the command, without its content.
+func (al *AgentLoop) addPlanCommands(
+	rt *commands.Runtime,
+	agent *AgentInstance,
+	sessionKey string,
+	origin bus.InboundMessage,
+) {
+	if agent.Plans == nil {
+		return
+	}
+	rt.StartPlan = func(ctx context.Context, task string) (string, error) {
+		return al.startPlan(ctx, agent, sessionKey, origin, task)
+	}
+	rt.ResumePlan = func(ctx context.Context) (string, error) {
+		plan, err := unfinishedPlan(agent, sessionKey)
+		if err != nil {
+			return "", err
+		}
+		plan.Status = tasks.Running
+		return al.runPlan(ctx, agent, plan)
+	}
+	rt.CancelPlan = func() (string, error) {
+		plan, err := unfinishedPlan(agent, sessionKey)
+		if err != nil {
+			return "", err
+		}
+		plan.Status = tasks.Cancelled
+		if err := agent.Plans.Save(plan); err != nil {
+			return "", err
+		}
+		return "Cancelled the plan: " + plan.Goal, nil
+	}
+	rt.PlanStatus = func() (string, error) {
+		plan, err := agent.Plans.Latest(sessionKey)
+		if err != nil {
+			return "", err
+		}
+		if plan == nil {
+			return "No plan in this conversation yet. Start one with /plan start <task>.", nil
+		}
+		return formatPlan(plan), nil
+	}
+}
+
+func unfinishedPlan(agent *AgentInstance, sessionKey string) (*tasks.Plan, error) {
+	plan, err := agent.Plans.Latest(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil || plan.Finished() {
+		return nil, errors.New("there is no unfinished plan in this conversation")
+	}
+	return plan, nil
+}
+
+// startPlan has the model break task into steps, stores the plan and works
+// through it. The reply is the result of the last step.
+func (al *AgentLoop) startPlan(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey string,
+	origin bus.InboundMessage,
+	task string,
+) (string, error) {
+	if plan, err := agent.Plans.Latest(sessionKey); err == nil && plan != nil && !plan.Finished() {
+		return "", fmt.Errorf("this conversation has an unfinished plan (%s); "+
+			"use /plan resume to continue it or /plan cancel to drop it", plan.Goal)
+	}
+
+	// Planning is a turn of its own as far as guardrails and budgets go.
+	fields := map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "channel": origin.Channel}
+	in := al.guard(guardrails.Input, task, fields)
+	if in.Blocked {
+		return in.Text, nil
+	}
+	model := agent.Model
+	if limit := al.overBudget(ctx, sessionKey); limit != nil {
+		if agent.BudgetModel == "" {
+			return fmt.Sprintf(budgetRefusal, limit, limit.resets()), nil
+		}
+		model = agent.BudgetModel
+	}
+
+	steps, err := al.makePlan(ctx, agent, sessionKey, model, in.Text)
+	if err != nil {
+		return "", err
+	}
+	plan := &tasks.Plan{
+		AgentID:    agent.ID,
+		SessionKey: sessionKey,
+		Goal:       in.Text,
+		Status:     tasks.Running,
+		Origin:     origin,
+	}
+	for _, s := range steps {
+		plan.Steps = append(plan.Steps, tasks.Step{Description: s, Status: tasks.Pending})
+	}
+	if err := agent.Plans.Add(plan); err != nil {
+		return "", err
+	}
+	logger.InfoCF("agent", "Plan created",
+		map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "plan_id": plan.ID, "steps": len(steps)})
+	al.planProgress(ctx, plan, formatPlan(plan))
+	return al.runPlan(ctx, agent, plan)
+}
+
+// makePlan asks the model for the steps of task.
+func (al *AgentLoop) makePlan(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, model, task string,
+) ([]string, error) {
+	maxSteps := max(agent.MaxPlanSteps, 1)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, planPrompt, maxSteps)
+	sb.WriteString("\n\n## Tools\n")
+	for _, def := range agent.persona(sessionKey).toolRegistry(agent.Tools).ToProviderDefs() {
+		fmt.Fprintf(&sb, "\n- %s: %s", def.Function.Name, def.Function.Description)
+	}
+
+	resp, err := agent.Provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: sb.String()},
+		{Role: "user", Content: task},
+	}, nil, model, map[string]any{
+		"max_tokens":  1024,
+		"temperature": 0.2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("planning failed: %w", err)
+	}
+	al.recordUsage(agent, sessionKey, model, resp.Usage)
+
+	steps, err := parsePlan(resp.Content)
+	if err != nil {
+		logger.WarnCF("agent", "Unusable plan from the model",
+			map[string]any{"agent_id": agent.ID, "model": model, "error": err.Error(), "content": resp.Content})
+		return nil, fmt.Errorf("planning failed: %w", err)
+	}
+	if len(steps) > maxSteps {
+		steps = steps[:maxSteps]
+	}
+	return steps, nil
+}
+
+// parsePlan reads the steps from the planner's reply, which may wrap the
+// JSON object in prose or a code fence.
+func parsePlan(content string) ([]string, error) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("the reply holds no JSON object")
+	}
+	var reply struct {
+		Steps []string `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &reply); err != nil {
+		return nil, fmt.Errorf("the reply is not a plan: %w", err)
+	}
+	var steps []string
+	for _, s := range reply.Steps {
+		if s = strings.TrimSpace(s); s != "" {
+			steps = append(steps, s)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("the plan has no steps")
+	}
+	return steps, nil
+}
+
+// runPlan carries out the steps of plan that are not done yet, each as a
+// turn of the plan's session, and stores the plan after every step. When the
+// conversation stops the turn, the plan is paused; when the agent shuts down
+// it stays running and is resumed on the next start.
+func (al *AgentLoop) runPlan(ctx context.Context, agent *AgentInstance, plan *tasks.Plan) (string, error) {
+	save := func() {
+		if err := agent.Plans.Save(plan); err != nil {
+			logger.WarnCF("agent", "Failed to save plan",
+				map[string]any{"agent_id": agent.ID, "plan_id": plan.ID, "error": err.Error()})
+		}
+	}
+	origin := plan.Origin
+	for i := plan.Next(); i >= 0; i = plan.Next() {
+		step := &plan.Steps[i]
+		step.Status = tasks.Running
+		save()
+
+		// Each step is a message round of its own, so one that sends its
+		// report with the message tool does not hold back the final reply.
+		result, err := al.runAgentLoop(tools.WithMessageRound(ctx), agent, processOptions{
+			SessionKey:      plan.SessionKey,
+			Channel:         origin.Channel,
+			ChatID:          origin.ChatID,
+			UserMessage:     stepPrompt(plan, i),
+			DefaultResponse: defaultResponse,
+			EnableSummary:   true,
+			SendResponse:    false,
+			Caller:          callerOf(origin),
+		})
+		if ctx.Err() != nil {
+			step.Status = tasks.Pending
+			if errors.Is(context.Cause(ctx), errTurnCancelled) {
+				plan.Status = tasks.Paused
+			}
+			save()
+			return fmt.Sprintf("Paused the plan at step %d of %d. Send /plan resume to continue.",
+				i+1, len(plan.Steps)), nil
+		}
+		if err != nil {
+			step.Status, step.Result = tasks.Failed, err.Error()
+			plan.Status = tasks.Failed
+			save()
+			return "", fmt.Errorf("step %d of the plan failed: %w", i+1, err)
+		}
+		step.Status, step.Result, step.FinishedAt = tasks.Done, result, time.Now()
+		save()
+		if plan.Next() >= 0 {
+			al.planProgress(ctx, plan, fmt.Sprintf("Step %d/%d done: %s", i+1, len(plan.Steps), step.Description))
+		}
+	}
+	plan.Status = tasks.Done
+	save()
+	logger.InfoCF("agent", "Plan finished",
+		map[string]any{"agent_id": agent.ID, "session_key": plan.SessionKey, "plan_id": plan.ID})
+	if len(plan.Steps) == 0 {
+		return "", nil
+	}
+	return plan.Steps[len(plan.Steps)-1].Result, nil
+}
+
+// planProgress tells the plan's conversation how it is going. Nothing
+// reads outbound messages for the CLI, which only gets the final reply.
+func (al *AgentLoop) planProgress(ctx context.Context, plan *tasks.Plan, content string) {
+	if plan.Origin.Channel == "" || plan.Origin.Channel == "cli" {
+		return
+	}
+	al.bus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: plan.Origin.Channel,
+		ChatID:  plan.Origin.ChatID,
+		Content: content,
+	})
+}
+
+// stepPrompt is the message that has the agent carry out step i of plan; it
+// shows the whole plan and what the finished steps came up with.
+func stepPrompt(plan *tasks.Plan, i int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[Plan step %d of %d] Task: %s\n\n", i+1, len(plan.Steps), plan.Goal)
+	for j, s := range plan.Steps {
+		fmt.Fprintf(&sb, "%d. %s\n", j+1, s.Description)
+	}
+	for j, s := range plan.Steps[:i] {
+		if j == 0 {
+			sb.WriteString("\nResults so far:\n")
+		}
+		fmt.Fprintf(&sb, "\nStep %d: %s\n", j+1, utils.Truncate(s.Result, planResultLen))
+	}
+	if i == len(plan.Steps)-1 {
+		fmt.Fprintf(&sb, "\nCarry out step %d, the last one, and answer the task for the user.", i+1)
+	} else {
+		fmt.Fprintf(&sb, "\nCarry out step %d only and report briefly what it did or found.", i+1)
+	}
+	return sb.String()
+}
+
+// formatPlan describes plan and its progress for /plan show.
+func formatPlan(plan *tasks.Plan) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Plan: %s (%s)\n", plan.Goal, plan.Status)
+	for i, s := range plan.Steps {
+		mark := " "
+		switch s.Status {
+		case tasks.Done:
+			mark = "x"
+		case tasks.Running:
+			mark = ">"
+		case tasks.Failed:
+			mark = "!"
+		}
+		fmt.Fprintf(&sb, "\n%d. [%s] %s", i+1, mark, s.Description)
+	}
+	return sb.String()
+}
+
+// resumePlans queues the plans that a shutdown interrupted to carry on where
+// they stopped, on behalf of whoever started them.
+func (al *AgentLoop) resumePlans(ctx context.Context) {
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok || agent.Plans == nil {
+			continue
+		}
+		plans, err := agent.Plans.List(func(p *tasks.Plan) bool { return p.Status == tasks.Running })
+		if err != nil {
+			logger.WarnCF("agent", "Cannot list plans to resume", map[string]any{"agent_id": id, "error": err.Error()})
+			continue
+		}
+		for _, plan := range plans {
+			logger.InfoCF("agent", "Resuming plan",
+				map[string]any{"agent_id": id, "plan_id": plan.ID, "session_key": plan.SessionKey})
+			msg := plan.Origin
+			msg.Content = "/plan resume"
+			if !al.schedule(ctx, msg) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tasks"
+)
+
+func planConfig(workspace string) *config.Config {
+	return &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         workspace,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Planning:          config.PlanningConfig{MaxSteps: 2},
+			},
+		},
+	}
+}
+
+func TestProcessDirect_PlanStart(t *testing.T) {
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{Content: "```json\n" +
+			`{"steps": ["list the downloads", "move old files to the archive", "report"]}` + "\n```"},
+		&providers.LLMResponse{Content: "found 3 old files"},
+		&providers.LLMResponse{Content: "moved 3 files to the archive"},
+	)
+	al := NewAgentLoop(planConfig(t.TempDir()), bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	reply, err := al.ProcessDirect(ctx, "/plan start tidy up my downloads", "cli:direct")
+	if err != nil || reply != "moved 3 files to the archive" {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+	if provider.Remaining() != 0 {
+		t.Errorf("%d scripted responses left", provider.Remaining())
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	plan, err := agent.Plans.Latest("agent:main:main")
+	if err != nil || plan == nil {
+		t.Fatalf("stored plan = %v, %v", plan, err)
+	}
+	if plan.Status != tasks.Done || len(plan.Steps) != 2 || plan.Steps[0].Result != "found 3 old files" {
+		t.Errorf("stored plan = %+v, want two done steps (capped at max_steps)", plan)
+	}
+
+	// The second step sees what the first one found.
+	var prompts []string
+	for _, m := range agent.Sessions.GetHistory("agent:main:main") {
+		if m.Role == "user" {
+			prompts = append(prompts, m.Content)
+		}
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "Step 1: found 3 old files") {
+		t.Errorf("step prompts = %q", prompts)
+	}
+
+	reply, _ = al.ProcessDirect(ctx, "/plan show", "cli:direct")
+	if !strings.Contains(reply, "tidy up my downloads (done)") || !strings.Contains(reply, "2. [x]") {
+		t.Errorf("/plan show = %q", reply)
+	}
+}
+
+func TestRun_ResumesUnfinishedPlan(t *testing.T) {
+	workspace := t.TempDir()
+	sessionKey := "agent:main:telegram:direct:42"
+	origin := bus.InboundMessage{
+		Channel: "telegram", SenderID: "telegram:42", ChatID: "42", SessionKey: sessionKey,
+	}
+
+	// A plan interrupted during its second step, as a shutdown leaves it.
+	queue, err := tasks.NewQueue(filepath.Join(workspace, "tasks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.Add(&tasks.Plan{
+		AgentID:    "main",
+		SessionKey: sessionKey,
+		Goal:       "tidy up my downloads",
+		Status:     tasks.Running,
+		Steps: []tasks.Step{
+			{Description: "list the downloads", Status: tasks.Done, Result: "found 3 old files"},
+			{Description: "move old files to the archive", Status: tasks.Running},
+		},
+		Origin: origin,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := providers.NewScriptedProvider(&providers.LLMResponse{Content: "moved 3 files"})
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(planConfig(workspace), msgBus, provider)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go al.Run(ctx)
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer waitCancel()
+	msg, ok := msgBus.SubscribeOutbound(waitCtx)
+	if !ok {
+		t.Fatal("the plan was not resumed")
+	}
+	if msg.ChatID != "42" || msg.Content != "moved 3 files" {
+		t.Errorf("reply = %+v, want the last step's result in chat 42", msg)
+	}
+	plan, err := queue.Latest(sessionKey)
+	if err != nil || plan.Status != tasks.Done || plan.Steps[0].Result != "found 3 old files" {
+		t.Errorf("plan after resuming = %+v, %v", plan, err)
+	}
+}
+
+func TestParsePlan(t *testing.T) {
+	steps, err := parsePlan("Here is the plan: {\"steps\": [\" one \", \"\", \"two\"]} Good luck.")
+	if err != nil || len(steps) != 2 || steps[0] != "one" {
+		t.Errorf("parsePlan = %q, %v", steps, err)
+	}
+	for _, content := range []string{"no plan", `{"steps": []}`, `{"steps": "one"}`} {
+		if _, err := parsePlan(content); err == nil {
+			t.Errorf("parsePlan(%q) succeeded", content)
+		}
+	}
+}
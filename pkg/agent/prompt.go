@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"maps"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const defaultPersona = "You are picoclaw, a helpful AI assistant."
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*[A-Za-z_][A-Za-z0-9_]*\s*\}\}`)
+
+// promptVars are the values substituted for {{name}} in prompt text.
+type promptVars map[string]string
+
+// expand replaces the placeholders in text. Unknown names are left as they
+// are, so that braces meant literally in a workspace file survive.
+func (v promptVars) expand(text string) string {
+	if len(v) == 0 || !strings.Contains(text, "{{") {
+		return text
+	}
+	return placeholderRe.ReplaceAllStringFunc(text, func(m string) string {
+		if value, ok := v[strings.TrimSpace(m[2:len(m)-2])]; ok {
+			return value
+		}
+		return m
+	})
+}
+
+// with returns a copy of v with the given name/value pairs added.
+func (v promptVars) with(pairs ...string) promptVars {
+	out := maps.Clone(v)
+	if out == nil {
+		out = promptVars{}
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		out[pairs[i]] = pairs[i+1]
+	}
+	return out
+}
+
+// SetPrompt sets the configured prompt layers: a persona replacing the
+// default introduction, instructions per channel, and the variables
+// expanded in them and in the workspace prompt files. It may be called
+// while the builder is in use, when the config is reloaded.
+func (cb *ContextBuilder) SetPrompt(persona string, channels map[string]string, vars map[string]string) {
+	cb.promptMu.Lock()
+	cb.persona = persona
+	cb.channelPrompts = channels
+	cb.vars = vars
+	cb.promptMu.Unlock()
+	cb.InvalidateCache()
+}
+
+// promptLayers returns what SetPrompt set.
+func (cb *ContextBuilder) promptLayers() (persona string, channels map[string]string, vars promptVars) {
+	cb.promptMu.RLock()
+	defer cb.promptMu.RUnlock()
+	return cb.persona, cb.channelPrompts, cb.vars
+}
+
+// channelInstructions returns the instructions for messages from channel, or
+// "" when there are none.
+func (cb *ContextBuilder) channelInstructions(channel, chatID string) string {
+	_, channels, vars := cb.promptLayers()
+	text := strings.TrimSpace(channels[channel])
+	if text == "" {
+		return ""
+	}
+	return "## Channel Instructions\n\n" + vars.with("channel", channel, "chat_id", chatID).expand(text)
+}
+
+// promptVariables returns the configured prompt variables together with the
+// built-in ones, which take precedence.
+func promptVariables(configured map[string]string, workspace, agentID, agentName, model string) map[string]string {
+	vars := maps.Clone(configured)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	if agentName == "" {
+		agentName = agentID
+	}
+	absWorkspace, _ := filepath.Abs(workspace)
+	vars["workspace"] = absWorkspace
+	vars["agent_id"] = agentID
+	vars["agent_name"] = agentName
+	vars["model"] = model
+	return vars
+}
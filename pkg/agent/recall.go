@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	recallHeader = "## Relevant Memories\n\n" +
+		"Retrieved from long-term memory because they may relate to the current message. " +
+		"They can be outdated or beside the point; use them only where they help, " +
+		"and say where a memory came from when you rely on it."
+
+	recallSnippetLen = 300
+)
+
+// recallMemories returns a prompt block with the saved facts and the
+// messages of other conversations relevant to query, each with where it came
+// from, or "" when recall is off or nothing relevant is found. Facts are
+// those visible to the person in ctx; conversations are the direct ones with
+// that person, or all of them when ctx has no person.
+func recallMemories(ctx context.Context, agent *AgentInstance, sessionKey, query string) string {
+	rc := agent.Recall
+	if !rc.Enabled || strings.TrimSpace(query) == "" {
+		return ""
+	}
+
+	var facts []string
+	if rc.Facts > 0 && agent.Facts != nil {
+		matches, err := agent.Facts.Search(ctx, query, rc.Facts)
+		if err != nil {
+			logger.WarnCF("agent", "Fact recall failed", map[string]any{"agent_id": agent.ID, "error": err.Error()})
+		}
+		for _, m := range matches {
+			if m.Score < rc.MinScore {
+				continue
+			}
+			source := "saved " + m.CreatedAt.Format("2006-01-02")
+			if m.Source != "" {
+				source += " in " + m.Source
+			}
+			facts = append(facts, fmt.Sprintf("- %s (fact %s, %s)", m.Content, m.ID, source))
+		}
+	}
+
+	var messages []string
+	if rc.Messages > 0 {
+		terms := memory.SearchTerms(query)
+		person := memory.PersonFrom(ctx)
+		matches := agent.Sessions.SearchMessages(
+			func(s *session.Session) bool {
+				return s.Key != sessionKey && (person == "" || s.Person == person)
+			},
+			func(content string) float64 {
+				if score := memory.KeywordScore(terms, content); score >= rc.MinScore {
+					return score
+				}
+				return 0
+			},
+			rc.Messages,
+		)
+		for _, m := range matches {
+			messages = append(messages, fmt.Sprintf("- %s: %q (conversation %s, %s)",
+				m.Role, utils.Truncate(m.Content, recallSnippetLen), m.SessionKey, m.Updated.Format("2006-01-02")))
+		}
+	}
+
+	if len(facts) == 0 && len(messages) == 0 {
+		return ""
+	}
+	logger.DebugCF("agent", "Recalled memories",
+		map[string]any{"agent_id": agent.ID, "facts": len(facts), "messages": len(messages)})
+
+	parts := []string{recallHeader}
+	if len(facts) > 0 {
+		parts = append(parts, "### Saved facts\n\n"+strings.Join(facts, "\n"))
+	}
+	if len(messages) > 0 {
+		parts = append(parts, "### Earlier conversations\n\n"+strings.Join(messages, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// withTurnContext adds the session's persona and the memories recalled for
+// the turn to messages built by BuildMessages. The memories change with
+// every message, so they go with the uncached context at the end.
+func (cb *ContextBuilder) withTurnContext(messages []providers.Message, opts processOptions) []providers.Message {
+	messages = cb.withPersona(messages, opts.Persona, opts.Channel, opts.ChatID)
+	if opts.Memories != "" {
+		messages = insertSystemPart(messages, -1, providers.ContentBlock{Type: "text", Text: opts.Memories})
+	}
+	return messages
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func newRecallTestLoop(t *testing.T, provider *modelRecordingProvider) (*AgentLoop, *AgentInstance) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Recall:            config.RecallConfig{Enabled: true, Facts: 3, Messages: 3, MinScore: 0.5},
+			},
+		},
+		Tools: config.ToolsConfig{Memory: config.ToolConfig{Enabled: true}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	return al, al.registry.GetDefaultAgent()
+}
+
+func TestProcessDirect_RecallsMemories(t *testing.T) {
+	provider := &modelRecordingProvider{}
+	al, agent := newRecallTestLoop(t, provider)
+	ctx := context.Background()
+
+	fact, err := agent.Facts.Save(ctx, "The NAS backup runs nightly at 3am", []string{"backup"}, "telegram:42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.ProcessDirect(ctx, "move the nas backup to sunday night", "agent:main:old"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.ProcessDirect(ctx, "when does the nas backup run?", "agent:main:new"); err != nil {
+		t.Fatal(err)
+	}
+	system := provider.systems[1]
+	for _, want := range []string{
+		"## Relevant Memories",
+		"- The NAS backup runs nightly at 3am (fact " + fact.ID + ", saved ",
+		`- user: "move the nas backup to sunday night" (conversation agent:main:old, `,
+	} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt lacks %q:\n%s", want, system)
+		}
+	}
+
+	// The current conversation is already in the history.
+	if _, err := al.ProcessDirect(ctx, "so when does the nas backup run now?", "agent:main:new"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(provider.systems[2], `"when does the nas backup run?"`) {
+		t.Error("message of the current session was recalled")
+	}
+}
+
+func TestRecallMemories_OtherPeopleStayPrivate(t *testing.T) {
+	_, agent := newRecallTestLoop(t, &modelRecordingProvider{})
+	agent.Sessions.AddMessage("telegram:alice", "user", "my salary review is next friday")
+	agent.Sessions.SetPerson("telegram:alice", "alice")
+	agent.Sessions.AddMessage("telegram:group", "user", "salary review rumours in the group")
+
+	bob := memory.WithPerson(context.Background(), "bob")
+	if got := recallMemories(bob, agent, "telegram:bob", "when is the salary review?"); got != "" {
+		t.Errorf("bob recalled:\n%s", got)
+	}
+	alice := memory.WithPerson(context.Background(), "alice")
+	got := recallMemories(alice, agent, "telegram:alice2", "when is the salary review?")
+	if !strings.Contains(got, "next friday") || strings.Contains(got, "rumours") {
+		t.Errorf("alice recalled:\n%s", got)
+	}
+
+	agent.Recall.Enabled = false
+	if got := recallMemories(alice, agent, "telegram:alice2", "when is the salary review?"); got != "" {
+		t.Errorf("recall disabled but got:\n%s", got)
+	}
+}
@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	reviewPrompt = "You review the draft of an AI assistant's answer before it is sent to the user. " +
+		"Check that it answers the user's message, is not wrong or made up as far as you can tell, " +
+		"and follows the rules below, if any. Do not judge style or length.\n\n" +
+		"Reply with exactly OK when there is nothing to fix. Otherwise list the problems, " +
+		"one per line, without rewriting the answer."
+
+	reviewFeedback = "Before this answer was sent, a review found these problems with it:\n\n%s\n\n" +
+		"Write the answer again with the problems fixed. The user has not seen the first version."
+)
+
+// reviewAnswer has the agent's reviewer check draft, the answer to question,
+// and returns the problems it found, or "" when there are none. If the
+// review cannot be done, the draft goes out as it is.
+func (al *AgentLoop) reviewAnswer(
+	ctx context.Context,
+	agent *AgentInstance,
+	sessionKey, question, draft string,
+) string {
+	rc := agent.Reflection
+	model := rc.Model
+	if model == "" {
+		model = agent.Model
+	}
+	system := reviewPrompt
+	if len(rc.Rules) > 0 {
+		system += "\n\n## Rules\n\n- " + strings.Join(rc.Rules, "\n- ")
+	}
+
+	resp, err := agent.Provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: fmt.Sprintf("## User's message\n\n%s\n\n## Draft answer\n\n%s", question, draft)},
+	}, nil, model, map[string]any{
+		"max_tokens":  512,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		logger.WarnCF("agent", "Answer review failed, sending the draft",
+			map[string]any{"agent_id": agent.ID, "model": model, "error": err.Error()})
+		return ""
+	}
+	al.recordUsage(agent, sessionKey, model, resp.Usage)
+
+	verdict := strings.TrimSpace(resp.Content)
+	if verdict == "" || strings.EqualFold(strings.TrimRight(verdict, "."), "ok") {
+		return ""
+	}
+	logger.InfoCF("agent", "Answer review found problems, retrying",
+		map[string]any{"agent_id": agent.ID, "model": model, "problems": verdict})
+	return verdict
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// reviewProvider answers as the agent with answers in turn and as the
+// reviewer (model "reviewer") with verdicts in turn.
+type reviewProvider struct {
+	mu       sync.Mutex
+	answers  []string
+	verdicts []string
+	reviews  []string // what the reviewer was shown
+	last     []providers.Message
+}
+
+func (p *reviewProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := &p.answers
+	if model == "reviewer" {
+		queue = &p.verdicts
+		p.reviews = append(p.reviews, messages[0].Content+"\n"+messages[1].Content)
+	} else {
+		p.last = messages
+	}
+	content := (*queue)[0]
+	*queue = (*queue)[1:]
+	return &providers.LLMResponse{Content: content}, nil
+}
+
+func (p *reviewProvider) GetDefaultModel() string { return "reviewed" }
+
+func newReflectionLoop(t *testing.T, reflection *config.ReflectionConfig, provider providers.LLMProvider) *AgentLoop {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+				Reflection:        reflection,
+			},
+		},
+	}
+	return NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+}
+
+func TestProcessDirect_ReviewRetriesOnce(t *testing.T) {
+	provider := &reviewProvider{
+		answers:  []string{"Paris is in Italy.", "Paris is in France."},
+		verdicts: []string{"- Paris is in France, not Italy."},
+	}
+	al := newReflectionLoop(t, &config.ReflectionConfig{
+		Enabled: true,
+		Model:   "reviewer",
+		Rules:   []string{"Never give medical advice."},
+	}, provider)
+
+	reply, err := al.ProcessDirect(context.Background(), "Where is Paris?", "cli:direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Paris is in France." {
+		t.Errorf("reply = %q, want the corrected answer", reply)
+	}
+	if len(provider.reviews) != 1 {
+		t.Fatalf("got %d reviews, want 1 (the retry is not reviewed again)", len(provider.reviews))
+	}
+	for _, want := range []string{"Never give medical advice.", "Where is Paris?", "Paris is in Italy."} {
+		if !strings.Contains(provider.reviews[0], want) {
+			t.Errorf("review request lacks %q:\n%s", want, provider.reviews[0])
+		}
+	}
+	feedback := provider.last[len(provider.last)-1]
+	if feedback.Role != "user" || !strings.Contains(feedback.Content, "not Italy") {
+		t.Errorf("retry did not get the review: %+v", feedback)
+	}
+
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory("agent:main:main")
+	if len(history) != 2 || history[1].Content != "Paris is in France." {
+		t.Errorf("history = %+v, want only the question and the final answer", history)
+	}
+}
+
+func TestProcessDirect_ReviewPassesAndChannelOverride(t *testing.T) {
+	provider := &reviewProvider{answers: []string{"Paris is in France."}, verdicts: []string{"OK."}}
+	al := newReflectionLoop(t, &config.ReflectionConfig{Enabled: true, Model: "reviewer"}, provider)
+	reply, err := al.ProcessDirect(context.Background(), "Where is Paris?", "cli:direct")
+	if err != nil || reply != "Paris is in France." {
+		t.Fatalf("reply = %q, err = %v", reply, err)
+	}
+	if len(provider.reviews) != 1 {
+		t.Errorf("got %d reviews, want 1", len(provider.reviews))
+	}
+
+	provider = &reviewProvider{answers: []string{"Paris is in France."}}
+	al = newReflectionLoop(t, &config.ReflectionConfig{
+		Enabled: true, Model: "reviewer", Channels: map[string]bool{"cli": false},
+	}, provider)
+	if _, err := al.ProcessDirect(context.Background(), "Where is Paris?", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.reviews) != 0 {
+		t.Errorf("answer on a channel with review turned off was reviewed")
+	}
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ApplyConfig applies the settings of cfg that can change while the loop
+// runs: the prompt layers and personas of agents.defaults, and which tools
+// are enabled. It returns the paths of the tool settings that changed since
+// the loop was created but only take effect after a restart, such as a tool
+// that was off then and so was never set up.
+func (al *AgentLoop) ApplyConfig(cfg *config.Config) []string {
+	defaults := &cfg.Agents.Defaults
+	resolve := personaResolver(cfg, defaults)
+	haveTools := map[string]bool{} // config keys of the registered tools
+
+	for _, id := range al.registry.ListAgentIDs() {
+		agent, ok := al.registry.GetAgent(id)
+		if !ok {
+			continue
+		}
+		if p := defaults.Prompt; p != nil {
+			agent.ContextBuilder.SetPrompt(p.Persona, p.Channels,
+				promptVariables(p.Variables, agent.Workspace, agent.ID, agent.Name, agent.Model))
+		} else {
+			agent.ContextBuilder.SetPrompt("", nil, nil)
+		}
+		agent.setPersonas(newPersonas(defaults.Personas, resolve, agent.ID))
+
+		var off []string
+		for _, name := range agent.Tools.ListAll() {
+			haveTools[toolConfigKey(name)] = true
+			if name == "find_skills" || name == "install_skill" {
+				haveTools["skills"] = true
+			}
+			if !toolEnabled(&cfg.Tools, name) {
+				off = append(off, name)
+			}
+		}
+		if agent.skillTools != nil {
+			haveTools["skill_tools"], haveTools["wasm"] = true, true
+			agent.skillTools.configure(&cfg.Tools)
+			agent.skillTools.reload(agent.Tools)
+		}
+		agent.Tools.SetDisabled(off)
+		if len(off) > 0 {
+			logger.InfoCF("agent", "Tools turned off by the config",
+				map[string]any{"agent_id": agent.ID, "tools": off})
+		}
+	}
+
+	var restart []string
+	for _, path := range config.ChangedPaths(al.cfg, cfg) {
+		key, isTools := strings.CutPrefix(path, "tools.")
+		key, isSwitch := strings.CutSuffix(key, ".enabled")
+		if isTools && isSwitch && !strings.Contains(key, ".") && !haveTools[key] {
+			restart = append(restart, path)
+		}
+	}
+	return restart
+}
+
+// toolConfigKey returns the entry of the tools config that enables the tool
+// called name; for most tools it is their name.
+func toolConfigKey(name string) string {
+	switch {
+	case name == "web_search":
+		return "web"
+	case strings.HasPrefix(name, "mcp_"):
+		return "mcp"
+	case name == "memory_save", name == "memory_search", name == "memory_forget":
+		return "memory"
+	case strings.HasPrefix(name, "calendar_"):
+		return "calendar"
+	case strings.HasPrefix(name, "email_"):
+		return "email"
+	case strings.HasPrefix(name, "skill_"):
+		return "skill_tools"
+	}
+	return name
+}
+
+// toolEnabled reports whether t enables the tool called name.
+func toolEnabled(t *config.ToolsConfig, name string) bool {
+	switch name {
+	case "find_skills", "install_skill":
+		// The skill registries are only set up with tools.skills on.
+		if !t.IsToolEnabled("skills") {
+			return false
+		}
+	}
+	if strings.HasPrefix(name, "skill_") {
+		// The skill tool set only registers the kinds that are on.
+		return true
+	}
+	return t.IsToolEnabled(toolConfigKey(name))
+}
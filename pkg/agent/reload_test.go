@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestApplyConfig(t *testing.T) {
+	newCfg := func() *config.Config {
+		return &config.Config{
+			Agents: config.AgentsConfig{
+				Defaults: config.AgentDefaults{
+					Workspace:         t.TempDir(),
+					Model:             "test-model",
+					MaxTokens:         4096,
+					MaxToolIterations: 10,
+					Prompt:            &config.PromptConfig{Persona: "You are Ada."},
+				},
+			},
+			Tools: config.ToolsConfig{
+				ReadFile: config.ToolConfig{Enabled: true},
+				ListDir:  config.ToolConfig{Enabled: true},
+			},
+		}
+	}
+	cfg := newCfg()
+	provider := &modelRecordingProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	reloaded := newCfg()
+	reloaded.Agents.Defaults.Workspace = cfg.Agents.Defaults.Workspace
+	reloaded.Agents.Defaults.Prompt.Persona = "You are Grace."
+	reloaded.Agents.Defaults.Personas = map[string]config.PersonaConfig{"ops": {Prompt: "Be terse."}}
+	reloaded.Tools.ListDir.Enabled = false
+	reloaded.Tools.WriteFile.Enabled = true // never set up
+	restart := al.ApplyConfig(reloaded)
+	if !slices.Equal(restart, []string{"tools.write_file.enabled"}) {
+		t.Errorf("restart = %v, want [tools.write_file.enabled]", restart)
+	}
+
+	ctx := context.Background()
+	if _, err := al.ProcessDirect(ctx, "/persona use ops", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := al.ProcessDirect(ctx, "hello", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	last := len(provider.systems) - 1
+	if last < 0 {
+		t.Fatal("no LLM call")
+	}
+	system := provider.systems[last]
+	if !strings.Contains(system, "You are Grace.") || strings.Contains(system, "You are Ada.") {
+		t.Errorf("system prompt does not use the reloaded persona text:\n%s", system)
+	}
+	if !slices.Contains(provider.tools[last], "read_file") || slices.Contains(provider.tools[last], "list_dir") {
+		t.Errorf("tools = %v, want read_file without list_dir", provider.tools[last])
+	}
+
+	// Turned back on, the tool is offered again.
+	if restart := al.ApplyConfig(cfg); len(restart) != 0 {
+		t.Errorf("restart = %v after restoring the config", restart)
+	}
+	if _, err := al.ProcessDirect(ctx, "hello again", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	if tools := provider.tools[len(provider.tools)-1]; !slices.Contains(tools, "list_dir") {
+		t.Errorf("tools = %v, want list_dir back", tools)
+	}
+}
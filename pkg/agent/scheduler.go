@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/diag"
+)
+
+// turnScheduler runs turns on at most a fixed number of workers. Turns with
+// the same key, the session they belong to, run one at a time in the order
+// they were submitted; turns of different sessions run side by side.
+type turnScheduler struct {
+	workers chan struct{} // one token per running turn
+	pending chan struct{} // one token per turn waiting for a worker
+
+	mu     sync.Mutex
+	queues map[string][]func() // present while a session has a turn running or waiting
+	idled  *sync.Cond          // broadcast when a session's queue is removed
+}
+
+func newTurnScheduler(workers, pending int) *turnScheduler {
+	s := &turnScheduler{
+		workers: make(chan struct{}, max(workers, 1)),
+		pending: make(chan struct{}, max(pending, 1)),
+		queues:  make(map[string][]func()),
+	}
+	s.idled = sync.NewCond(&s.mu)
+	return s
+}
+
+// submit queues turn behind the other turns of key. It blocks while too
+// many turns are waiting, and fails only when ctx is done first.
+func (s *turnScheduler) submit(ctx context.Context, key string, turn func()) bool {
+	select {
+	case s.pending <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	s.mu.Lock()
+	if queue, busy := s.queues[key]; busy {
+		s.queues[key] = append(queue, turn)
+		s.mu.Unlock()
+		return true
+	}
+	s.queues[key] = nil
+	s.mu.Unlock()
+
+	go s.drain(key, turn)
+	return true
+}
+
+// do runs turn as submit would and waits for it to finish. It returns false
+// without running turn when ctx is done before turn's place comes up.
+func (s *turnScheduler) do(ctx context.Context, key string, turn func()) bool {
+	done := make(chan bool, 1)
+	queued := s.submit(ctx, key, func() {
+		if ctx.Err() != nil {
+			done <- false
+			return
+		}
+		turn()
+		done <- true
+	})
+	if !queued {
+		return false
+	}
+	select {
+	case ran := <-done:
+		return ran
+	case <-ctx.Done():
+		// turn is skipped when its place comes up, or is already running
+		// with ctx and stops on its own.
+		return false
+	}
+}
+
+// drain runs turn and then the turns queued behind it for key.
+func (s *turnScheduler) drain(key string, turn func()) {
+	defer diag.Recover()
+	for {
+		s.workers <- struct{}{}
+		<-s.pending
+		turn()
+		<-s.workers
+
+		s.mu.Lock()
+		queue := s.queues[key]
+		if len(queue) == 0 {
+			delete(s.queues, key)
+			s.idled.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+		turn, s.queues[key] = queue[0], queue[1:]
+		s.mu.Unlock()
+	}
+}
+
+// wait blocks until no turn is running or waiting.
+func (s *turnScheduler) wait() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queues) > 0 {
+		s.idled.Wait()
+	}
+}
+
+// idle reports whether no turn is running or waiting.
+func (s *turnScheduler) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queues) == 0
+}
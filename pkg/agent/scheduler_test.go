@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestTurnScheduler_OrdersEachSession(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		got     = map[string][]string{}
+		running atomic.Int32
+		peak    atomic.Int32
+	)
+	s := newTurnScheduler(2, 8)
+	run := func(msg bus.InboundMessage) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		got[msg.ChatID] = append(got[msg.ChatID], msg.Content)
+		mu.Unlock()
+		running.Add(-1)
+	}
+
+	ctx := context.Background()
+	for _, content := range []string{"1", "2", "3", "4", "5"} {
+		for _, key := range []string{"a", "b", "c"} {
+			msg := bus.InboundMessage{ChatID: key, Content: content}
+			s.submit(ctx, key, func() { run(msg) })
+		}
+	}
+	s.wait()
+
+	for _, key := range []string{"a", "b", "c"} {
+		if want := []string{"1", "2", "3", "4", "5"}; !slices.Equal(got[key], want) {
+			t.Errorf("session %s ran %v, want %v", key, got[key], want)
+		}
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("peak concurrency = %d, want 2", p)
+	}
+}
+
+func TestTurnScheduler_SubmitStopsWithContext(t *testing.T) {
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	s := newTurnScheduler(1, 1)
+	turn := func() {
+		started <- struct{}{}
+		<-release
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if !s.submit(ctx, "a", turn) {
+		t.Fatal("first submit failed")
+	}
+	<-started // the first message no longer holds the only pending slot
+	if !s.submit(ctx, "a", turn) {
+		t.Fatal("second submit failed")
+	}
+	cancel()
+	if s.submit(ctx, "a", turn) {
+		t.Error("submit with a full queue and a done context succeeded")
+	}
+	close(release)
+	s.wait()
+}
+
+func TestRun_SlowSessionDoesNotBlockOthers(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:          t.TempDir(),
+				Model:              "test-model",
+				MaxTokens:          4096,
+				MaxToolIterations:  10,
+				MaxConcurrentTurns: 2,
+			},
+		},
+	}
+	provider := &blockingProvider{started: make(chan struct{}, 1)}
+	msgBus := bus.NewMessageBus()
+	al := NewAgentLoop(cfg, msgBus, provider)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go al.Run(ctx)
+
+	send := func(chatID, content string) {
+		msgBus.PublishInbound(ctx, bus.InboundMessage{
+			Channel: "telegram", SenderID: "telegram:" + chatID, ChatID: chatID, Content: content,
+			SessionKey: "agent:main:telegram:direct:" + chatID,
+		})
+	}
+	reply := func() bus.OutboundMessage {
+		t.Helper()
+		waitCtx, waitCancel := context.WithTimeout(ctx, 5*time.Second)
+		defer waitCancel()
+		msg, ok := msgBus.SubscribeOutbound(waitCtx)
+		if !ok {
+			t.Fatal("no reply")
+		}
+		return msg
+	}
+
+	send("1", "summarize the logs")
+	select {
+	case <-provider.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("turn did not start")
+	}
+	send("2", "quick")
+	if msg := reply(); msg.ChatID != "2" || msg.Content != "quick answer" {
+		t.Errorf("reply while chat 1 runs = %+v, want the quick answer to chat 2", msg)
+	}
+
+	send("1", "stop")
+	if msg := reply(); msg.ChatID != "1" || msg.Content != cancelledResponse {
+		t.Errorf("reply after stop = %+v, want %q to chat 1", msg, cancelledResponse)
+	}
+}
+
+// overlapProvider answers with the last message after a short pause and
+// records how many calls were in flight at once.
+type overlapProvider struct {
+	running atomic.Int32
+	peak    atomic.Int32
+}
+
+func (p *overlapProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	n := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if n <= peak || p.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return &providers.LLMResponse{Content: "re: " + messages[len(messages)-1].Content}, nil
+}
+
+func (p *overlapProvider) GetDefaultModel() string { return "overlap" }
+
+func TestProcessDirect_OrdersEachSession(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:          t.TempDir(),
+				Model:              "test-model",
+				MaxTokens:          4096,
+				MaxToolIterations:  10,
+				MaxConcurrentTurns: 4,
+			},
+		},
+	}
+	provider := &overlapProvider{}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	const key = "agent:main:api:s1"
+	var wg sync.WaitGroup
+	for _, content := range []string{"one", "two"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := al.ProcessDirectWithCallbacks(context.Background(), content, key, "api", "s1",
+				StreamCallbacks{}); err != nil {
+				t.Errorf("ProcessDirectWithCallbacks(%q) error: %v", content, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := provider.peak.Load(); p != 1 {
+		t.Errorf("peak concurrent calls for one session = %d, want 1", p)
+	}
+	history := al.registry.GetDefaultAgent().Sessions.GetHistory(key)
+	if len(history) != 4 {
+		t.Fatalf("len(history) = %d, want 4", len(history))
+	}
+	for i := 0; i < len(history); i += 2 {
+		user, reply := history[i], history[i+1]
+		if user.Role != "user" || reply.Role != "assistant" || reply.Content != "re: "+user.Content {
+			t.Errorf("history[%d:%d] = %+v, %+v, want a user message and its reply", i, i+2, user, reply)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package agent
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// skillToolsPollInterval is how often the skill directories are checked for
+// changed tool manifests while the loop runs.
+const skillToolsPollInterval = 2 * time.Second
+
+// skillToolSet keeps the tools that an agent's skills declare in their
+// tools.json registered, replacing them when the skills change.
+type skillToolSet struct {
+	loader    *skills.SkillsLoader
+	workspace string
+	filter    []string       // skills whose tools are registered; empty for all
+	timeouts  map[string]int // tools.timeouts, which win over the manifests
+
+	mu       sync.Mutex
+	commands bool                    // tools.skill_tools: register the tools that run commands
+	wasm     *config.WasmToolsConfig // tools.wasm when it is on
+	stamp    string
+	names    []string // registered so far
+}
+
+func newSkillToolSet(
+	loader *skills.SkillsLoader, workspace string, filter []string, cfg *config.ToolsConfig,
+) *skillToolSet {
+	s := &skillToolSet{loader: loader, workspace: workspace, filter: filter, timeouts: cfg.Timeouts}
+	s.configure(cfg)
+	return s
+}
+
+// configure sets which kinds of tools are registered from cfg; when that
+// changed, the next reload registers them anew.
+func (s *skillToolSet) configure(cfg *config.ToolsConfig) {
+	commands := cfg.IsToolEnabled("skill_tools")
+	var wasm *config.WasmToolsConfig
+	if cfg.IsToolEnabled("wasm") {
+		wasm = &cfg.Wasm
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if commands != s.commands || !reflect.DeepEqual(wasm, s.wasm) {
+		s.commands, s.wasm, s.stamp = commands, wasm, ""
+	}
+}
+
+// reload registers the current skill tools in registry when the skills
+// changed since the last call, and unregisters the ones that are gone.
+func (s *skillToolSet) reload(registry *tools.ToolRegistry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stamp := "off"
+	if s.commands || s.wasm != nil {
+		stamp = s.loader.ToolsStamp()
+	}
+	if stamp == s.stamp {
+		return
+	}
+	s.stamp = stamp
+
+	var specs []skills.ToolSpec
+	if stamp != "off" {
+		for _, spec := range s.loader.ListTools() {
+			if len(s.filter) == 0 || slices.Contains(s.filter, spec.Skill) {
+				specs = append(specs, spec)
+			}
+		}
+	}
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		var tool interface {
+			tools.Tool
+			Timeout() time.Duration
+		}
+		switch {
+		case spec.Wasm != "" && s.wasm != nil:
+			wasmTool, err := tools.NewWasmTool(spec, s.workspace, *s.wasm)
+			if err != nil {
+				logger.WarnCF("agent", "Skipping a skill's wasm tool",
+					map[string]any{"tool": spec.FullName(), "error": err.Error()})
+				continue
+			}
+			tool = wasmTool
+		case spec.Wasm == "" && s.commands:
+			tool = tools.NewSkillTool(spec, s.workspace)
+		default:
+			continue
+		}
+		if _, ok := s.timeouts[tool.Name()]; !ok && tool.Timeout() > 0 {
+			registry.SetToolTimeout(tool.Name(), tool.Timeout())
+		}
+		if slices.Contains(s.names, tool.Name()) {
+			unregisterSkillTool(registry, tool.Name()) // replaced, not a clash
+		}
+		registry.Register(tool)
+		names = append(names, tool.Name())
+	}
+	for _, name := range s.names {
+		if !slices.Contains(names, name) {
+			unregisterSkillTool(registry, name)
+		}
+	}
+	if len(names) > 0 || len(s.names) > 0 {
+		logger.InfoCF("agent", "Skill tools loaded", map[string]any{"workspace": s.workspace, "tools": names})
+	}
+	s.names = names
+}
+
+// unregisterSkillTool removes a skill tool from registry, releasing the
+// runtime of a wasm tool.
+func unregisterSkillTool(registry *tools.ToolRegistry, name string) {
+	if tool, ok := registry.Get(name); ok {
+		if closer, ok := tool.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	registry.Unregister(name)
+}
+
+// watchSkillTools reloads the skill tools of every agent whenever a skill
+// changes, until ctx is done.
+func (al *AgentLoop) watchSkillTools(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, id := range al.registry.ListAgentIDs() {
+			if agent, ok := al.registry.GetAgent(id); ok && agent.skillTools != nil {
+				agent.skillTools.reload(agent.Tools)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSkillTools_Reload(t *testing.T) {
+	workspace := t.TempDir()
+	skillDir := filepath.Join(workspace, "skills", "garden")
+	if err := os.MkdirAll(skillDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(skillDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("SKILL.md", "---\nname: garden\ndescription: Look after the plants\n---\n")
+	write("tools.json", `{"tools": [{"name": "water", "description": "Water a pot", "command": ["./water.sh"]}]}`)
+
+	newCfg := func() *config.Config {
+		return &config.Config{
+			Agents: config.AgentsConfig{Defaults: config.AgentDefaults{
+				Workspace: workspace, Model: "test-model", MaxTokens: 4096, MaxToolIterations: 10,
+			}},
+			Tools: config.ToolsConfig{SkillTools: config.ToolConfig{Enabled: true}},
+		}
+	}
+	al := NewAgentLoop(newCfg(), bus.NewMessageBus(), &modelRecordingProvider{})
+	agent := al.registry.GetDefaultAgent()
+	has := func(name string) bool { return slices.Contains(agent.Tools.ListAll(), name) }
+
+	if !has("skill_garden_water") {
+		t.Fatalf("tools = %v, want skill_garden_water", agent.Tools.ListAll())
+	}
+
+	// A tool added to the manifest replaces the set.
+	write("tools.json", `{"tools": [{"name": "light", "description": "Switch the light", "command": ["./light.sh"]}]}`)
+	agent.skillTools.reload(agent.Tools)
+	if has("skill_garden_water") || !has("skill_garden_light") {
+		t.Fatalf("after the manifest changed, tools = %v", agent.Tools.ListAll())
+	}
+
+	// Turning skill_tools off in the config takes them away.
+	off := newCfg()
+	off.Tools.SkillTools.Enabled = false
+	if restart := al.ApplyConfig(off); len(restart) != 0 {
+		t.Errorf("restart = %v, want none", restart)
+	}
+	if has("skill_garden_light") {
+		t.Errorf("skill tools still registered with tools.skill_tools off: %v", agent.Tools.ListAll())
+	}
+}
+
+func TestSkillTools_Wasm(t *testing.T) {
+	workspace := t.TempDir()
+	skillDir := filepath.Join(workspace, "skills", "weather")
+	if err := os.MkdirAll(skillDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"),
+		[]byte("---\nname: weather\ndescription: Forecasts\n---\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"tools": [
+		{"name": "now", "description": "Current weather", "wasm": "now.wasm", "capabilities": ["read_workspace"]},
+		{"name": "forecast", "description": "Forecast", "wasm": "forecast.wasm", "capabilities": ["fetch"]},
+		{"name": "script", "description": "Run a script", "command": ["./run.sh"]}
+	]}`
+	if err := os.WriteFile(filepath.Join(skillDir, "tools.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{Defaults: config.AgentDefaults{
+			Workspace: workspace, Model: "test-model", MaxTokens: 4096, MaxToolIterations: 10,
+		}},
+		Tools: config.ToolsConfig{Wasm: config.WasmToolsConfig{
+			ToolConfig: config.ToolConfig{Enabled: true}, Capabilities: []string{"read_workspace"},
+		}},
+	}
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), &modelRecordingProvider{})
+	agent := al.registry.GetDefaultAgent()
+	got := agent.Tools.ListAll()
+
+	// Only the module whose capabilities are granted; commands stay off
+	// with tools.skill_tools off.
+	if !slices.Contains(got, "skill_weather_now") {
+		t.Errorf("tools = %v, want skill_weather_now", got)
+	}
+	if slices.Contains(got, "skill_weather_forecast") || slices.Contains(got, "skill_weather_script") {
+		t.Errorf("tools = %v, want neither the ungranted module nor the command", got)
+	}
+
+	// Granting fetch on reload brings the other module in.
+	cfg2 := *cfg
+	cfg2.Tools.Wasm.Capabilities = []string{"read_workspace", "fetch"}
+	al.ApplyConfig(&cfg2)
+	if !slices.Contains(agent.Tools.ListAll(), "skill_weather_forecast") {
+		t.Errorf("after granting fetch, tools = %v", agent.Tools.ListAll())
+	}
+}
@@ -0,0 +1,28 @@
+package agent
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// startTurnSpan starts the span of a turn; the provider, tool and store
+// spans of the turn are its children.
+func startTurnSpan(ctx context.Context, agent *AgentInstance, opts processOptions) (context.Context, trace.Span) {
+	return telemetry.Start(ctx, "agent.turn",
+		attribute.String("picoclaw.agent", agent.ID),
+		attribute.String("picoclaw.session", opts.SessionKey),
+		attribute.String("picoclaw.channel", opts.Channel),
+	)
+}
+
+// saveSession writes a session to disk under a span of its own.
+func saveSession(ctx context.Context, agent *AgentInstance, sessionKey string) error {
+	_, span := telemetry.Start(ctx, "session.save", attribute.String("picoclaw.session", sessionKey))
+	err := agent.Sessions.Save(sessionKey)
+	telemetry.End(span, err)
+	return err
+}
@@ -0,0 +1,87 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestProcessDirect_TurnSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "a.md"), []byte("three tasks"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+			},
+		},
+		Tools: config.ToolsConfig{ReadFile: config.ToolConfig{Enabled: true}},
+	}
+	provider := providers.WithMiddleware(providers.NewScriptedProvider(
+		&providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "a.md"}}},
+		},
+		&providers.LLMResponse{Content: "It lists three tasks."},
+	), providers.TracingMiddleware())
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+
+	if _, err := al.ProcessDirect(context.Background(), "what is in a.md?", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := recorder.Ended()
+	var turn sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		if s.Name() == "agent.turn" {
+			turn = s
+		}
+	}
+	if turn == nil {
+		t.Fatalf("no agent.turn span among %d spans", len(spans))
+	}
+	children := map[string]int{}
+	for _, s := range spans {
+		if s.Parent().SpanID() == turn.SpanContext().SpanID() {
+			children[s.Name()]++
+		}
+	}
+	if children["provider.chat"] != 2 || children["tool.execute"] != 1 || children["session.save"] != 1 {
+		t.Errorf("children of the turn = %v", children)
+	}
+
+	// Store operations nest under what caused them.
+	names := map[trace.SpanID]string{}
+	for _, s := range spans {
+		names[s.SpanContext().SpanID()] = s.Name()
+	}
+	recorded := false
+	for _, s := range spans {
+		if s.Name() == "memory.tool_calls.record" {
+			recorded = names[s.Parent().SpanID()] == "tool.execute"
+		}
+	}
+	if !recorded {
+		t.Error("no memory.tool_calls.record span under tool.execute")
+	}
+}
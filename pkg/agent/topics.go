@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	topicTitlePrompt = "Give a title of at most six words for the subject of this conversation between " +
+		"a user and an AI assistant. Reply with the title only."
+
+	topicChangePrompt = "A conversation between a user and an AI assistant has been about %q. Below are its " +
+		"last messages, then the latest exchange. If the latest exchange goes on with the same subject, " +
+		"reply with exactly SAME. If the user turned to a different subject, reply with a title of at most " +
+		"six words for it, and nothing else."
+
+	// topicContextMessages is how many messages before the turn the model
+	// sees to tell whether the subject changed.
+	topicContextMessages = 6
+
+	maxTopicTitleChars = 80
+)
+
+// maybeSegment checks in the background whether the last turn of the
+// session started a new topic, when topics are on.
+func (al *AgentLoop) maybeSegment(agent *AgentInstance, sessionKey string) {
+	if !agent.Topics.Enabled {
+		return
+	}
+	key := agent.ID + ":" + sessionKey
+	if _, busy := al.segmenting.LoadOrStore(key, true); busy {
+		return
+	}
+	go func() {
+		defer al.segmenting.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		if err := al.segmentSession(ctx, agent, sessionKey); err != nil {
+			logger.WarnCF("agent", "Topic detection failed",
+				map[string]any{"agent_id": agent.ID, "session_key": sessionKey, "error": err.Error()})
+		}
+	}()
+}
+
+// segmentSession titles the first topic of the session after its first
+// turn. Later, once the current topic has agent.Topics.MinMessages
+// messages, it asks whether the last turn changed the subject, and starts a
+// topic at its user message if so.
+func (al *AgentLoop) segmentSession(ctx context.Context, agent *AgentInstance, sessionKey string) error {
+	history := agent.Sessions.GetHistory(sessionKey)
+	turn := lastUserMessage(history)
+	if turn < 0 {
+		return nil
+	}
+	topics := agent.Sessions.ListTopics(sessionKey)
+
+	var system string
+	var from int
+	if len(topics) == 0 {
+		system = topicTitlePrompt
+	} else {
+		current := topics[len(topics)-1]
+		if turn-current.Start < agent.Topics.MinMessages {
+			return nil
+		}
+		system = fmt.Sprintf(topicChangePrompt, current.Title)
+		from = max(current.Start, turn-topicContextMessages)
+	}
+
+	var sb strings.Builder
+	for i, m := range history[from:] {
+		if m.Role != "user" && m.Role != "assistant" || strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		if from+i == turn && len(topics) > 0 {
+			sb.WriteString("\n## Latest exchange\n\n")
+		}
+		fmt.Fprintf(&sb, "%s: %s\n", m.Role, utils.Truncate(m.Content, 1000))
+	}
+
+	model := agent.Topics.Model
+	if model == "" {
+		model = agent.Model
+	}
+	resp, err := agent.Provider.Chat(ctx, []providers.Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: sb.String()},
+	}, nil, model, map[string]any{
+		"max_tokens":  32,
+		"temperature": 0.0,
+	})
+	if err != nil {
+		return err
+	}
+	al.recordUsage(agent, sessionKey, model, resp.Usage)
+
+	title := strings.Trim(strings.TrimSpace(resp.Content), `"'.`)
+	if title == "" || len(topics) > 0 && strings.EqualFold(title, "same") {
+		return nil
+	}
+	start := 0
+	if len(topics) > 0 {
+		// The history may have been summarized meanwhile; find the turn
+		// again.
+		now := agent.Sessions.GetHistory(sessionKey)
+		if start = lastUserMessage(now); start < 0 || now[start].Content != history[turn].Content {
+			return nil
+		}
+	}
+	if agent.Sessions.AddTopic(sessionKey, start, utils.Truncate(title, maxTopicTitleChars)) {
+		logger.DebugCF("agent", "New topic", map[string]any{"session_key": sessionKey, "title": title})
+		return agent.Sessions.Save(sessionKey)
+	}
+	return nil
+}
+
+// lastUserMessage returns the index of the last user message in history,
+// or -1.
+func lastUserMessage(history []providers.Message) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
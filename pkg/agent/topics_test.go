@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestTopics_Segment(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.Defaults.Model = "test-model"
+	cfg.Agents.Defaults.Topics = config.TopicsConfig{Enabled: true, MinMessages: 2}
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{Content: "Plant them in autumn."},
+		&providers.LLMResponse{Content: `"Planting tulips."`},
+		&providers.LLMResponse{Content: "About 15 cm deep."},
+		&providers.LLMResponse{Content: "SAME"},
+		&providers.LLMResponse{Content: "April 30."},
+		&providers.LLMResponse{Content: "Tax deadline"},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+	if _, ok := agent.Tools.Get("topics"); !ok {
+		t.Error("topics tool not registered")
+	}
+
+	for i, msg := range []string{"when do I plant tulips?", "how deep?", "when is the tax deadline?"} {
+		if _, err := al.ProcessDirect(context.Background(), msg, "cli:direct"); err != nil {
+			t.Fatal(err)
+		}
+		// The check of the turn runs in the background on the next response.
+		deadline := time.Now().Add(5 * time.Second)
+		for segmenting(al) || provider.Remaining() > 4-2*i {
+			if time.Now().After(deadline) {
+				t.Fatalf("topic check of turn %d did not finish", i+1)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	topics := agent.Sessions.ListTopics("agent:main:main")
+	if len(topics) != 2 {
+		t.Fatalf("topics = %+v, want 2", topics)
+	}
+	if topics[0].Title != "Planting tulips" || topics[0].Start != 0 || topics[0].End != 4 {
+		t.Errorf("first topic = %+v", topics[0])
+	}
+	if topics[1].Title != "Tax deadline" || topics[1].Start != 4 || topics[1].End != 6 {
+		t.Errorf("second topic = %+v", topics[1])
+	}
+}
+
+// segmenting reports whether a topic check is running.
+func segmenting(al *AgentLoop) bool {
+	busy := false
+	al.segmenting.Range(func(any, any) bool {
+		busy = true
+		return false
+	})
+	return busy
+}
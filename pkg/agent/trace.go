@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// startTrace begins the trace of a turn whose prompt is messages, or
+// returns nil when the agent keeps no traces.
+func startTrace(agent *AgentInstance, opts processOptions, messages []providers.Message) *memory.TurnTrace {
+	if agent.Traces == nil {
+		return nil
+	}
+	prompt := make([]providers.Message, len(messages))
+	for i, m := range messages {
+		m.SystemParts = nil // the same text as Content, split for caching
+		prompt[i] = m
+	}
+	return &memory.TurnTrace{
+		SessionKey:  opts.SessionKey,
+		AgentID:     agent.ID,
+		Channel:     opts.Channel,
+		ChatID:      opts.ChatID,
+		Started:     time.Now(),
+		UserMessage: opts.UserMessage,
+		Prompt:      prompt,
+	}
+}
+
+// traceLLMCall adds an LLM call that started at start to tr.
+func traceLLMCall(
+	tr *memory.TurnTrace,
+	iteration int,
+	model string,
+	messages []providers.Message,
+	toolDefs []providers.ToolDefinition,
+	start time.Time,
+	resp *providers.LLMResponse,
+	err error,
+) {
+	if tr == nil {
+		return
+	}
+	call := memory.LLMCallTrace{
+		Iteration:  iteration,
+		Model:      model,
+		Messages:   len(messages),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	for _, def := range toolDefs {
+		call.Tools = append(call.Tools, def.Function.Name)
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	if resp != nil {
+		call.Content = resp.Content
+		call.Reasoning = resp.Reasoning
+		for _, tc := range resp.ToolCalls {
+			call.ToolCalls = append(call.ToolCalls, tc.Name)
+		}
+		if resp.Usage != nil {
+			call.PromptTokens = resp.Usage.PromptTokens
+			call.CompletionTokens = resp.Usage.CompletionTokens
+			call.CachedTokens = resp.Usage.CachedTokens()
+		}
+		tr.AddUsage(resp.Usage)
+	}
+	tr.LLMCalls = append(tr.LLMCalls, call)
+}
+
+// finishTrace stores tr with the outcome of its turn. Failing to store it
+// does not fail the turn.
+func finishTrace(agent *AgentInstance, tr *memory.TurnTrace, response string, err error) {
+	if tr == nil {
+		return
+	}
+	tr.DurationMS = time.Since(tr.Started).Milliseconds()
+	tr.Response = response
+	if err != nil {
+		tr.Error = err.Error()
+	}
+	if err := agent.Traces.Record(context.Background(), tr); err != nil {
+		logger.WarnCF("agent", "Failed to record turn trace",
+			map[string]any{"agent_id": agent.ID, "session_key": tr.SessionKey, "error": err.Error()})
+	}
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestProcessDirect_RecordsTrace(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "a.md"), []byte("three tasks"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+				Traces:              config.TracesConfig{Enabled: true, Keep: 5},
+			},
+		},
+		Tools: config.ToolsConfig{ReadFile: config.ToolConfig{Enabled: true}},
+	}
+	provider := providers.NewScriptedProvider(
+		&providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "a.md"}}},
+			Usage:     &providers.UsageInfo{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110},
+		},
+		&providers.LLMResponse{
+			Content: "It lists three tasks.",
+			Usage:   &providers.UsageInfo{PromptTokens: 120, CompletionTokens: 8, TotalTokens: 128},
+		},
+	)
+	al := NewAgentLoop(cfg, bus.NewMessageBus(), provider)
+	ctx := context.Background()
+
+	if _, err := al.ProcessDirect(ctx, "what is in a.md?", "cli:direct"); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := al.DefaultAgentTraces().Turn(ctx, "agent:main:main", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Turn != 1 || tr.UserMessage != "what is in a.md?" || tr.Response != "It lists three tasks." {
+		t.Errorf("trace = %+v", tr)
+	}
+	if len(tr.Prompt) < 2 || tr.Prompt[0].Role != "system" || tr.Prompt[len(tr.Prompt)-1].Content != tr.UserMessage {
+		t.Errorf("prompt = %+v, want the system prompt through the user message", tr.Prompt)
+	}
+	if len(tr.LLMCalls) != 2 || tr.LLMCalls[0].ToolCalls[0] != "read_file" ||
+		tr.LLMCalls[1].Messages != len(tr.Prompt)+2 {
+		t.Errorf("LLM calls = %+v", tr.LLMCalls)
+	}
+	if len(tr.ToolCalls) != 1 || tr.ToolCalls[0].Iteration != 1 || tr.ToolCalls[0].IsError {
+		t.Errorf("tool calls = %+v", tr.ToolCalls)
+	}
+	if tr.Usage.TotalTokens != 238 || tr.Usage.Requests != 2 {
+		t.Errorf("usage = %+v", tr.Usage)
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Frame types. The WebSocket and SSE endpoints carry the same frames.
+const (
+	// Sent by WebSocket clients.
+	frameTypeMessage = "message"
+	frameTypePing    = "ping"
+
+	// Sent by the server. Every frame of a turn carries the session key and
+	// the ID of the message that started it.
+	frameTypeDelta      = "delta"
+	frameTypeToolCall   = "tool_call"
+	frameTypeToolResult = "tool_result"
+	frameTypeDone       = "done"
+	frameTypeError      = "error"
+	frameTypePong       = "pong"
+
+	// frameTypeReset tells an SSE client that events it asked to resume
+	// from are no longer in the journal; it should reload the history.
+	frameTypeReset = "reset"
+)
+
+const (
+	maxToolResultPreview = 2000
+	journalSize          = 4096
+)
+
+// frame is the wire format of the streaming endpoints.
+type frame struct {
+	Type       string         `json:"type"`
+	EventID    uint64         `json:"event_id,omitempty"` // server: journal position
+	ID         string         `json:"id,omitempty"`
+	Session    string         `json:"session,omitempty"`     // client: session to talk in
+	SessionKey string         `json:"session_key,omitempty"` // server: resolved session key
+	Content    string         `json:"content,omitempty"`
+	Tool       string         `json:"tool,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	IsError    bool           `json:"is_error,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// journal keeps the most recent frames of all turns so SSE clients can
+// resume after a dropped connection (Last-Event-ID) and follow turns
+// started elsewhere. Event IDs start at the creation time in microseconds,
+// so they keep increasing across restarts and an ID from before a restart
+// is recognised as lost rather than matching an unrelated event.
+type journal struct {
+	mu      sync.Mutex
+	frames  []frame // ring buffer of at most size frames; the oldest is at start
+	start   int
+	size    int
+	next    uint64
+	changed chan struct{} // closed and replaced on every append
+}
+
+func newJournal(size int) *journal {
+	return &journal{size: size, next: uint64(time.Now().UnixMicro()), changed: make(chan struct{})}
+}
+
+// append assigns f the next event ID and stores it.
+func (j *journal) append(f frame) frame {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.next++
+	f.EventID = j.next
+	if len(j.frames) < j.size {
+		j.frames = append(j.frames, f)
+	} else {
+		j.frames[j.start] = f
+		j.start = (j.start + 1) % j.size
+	}
+	close(j.changed)
+	j.changed = make(chan struct{})
+	return f
+}
+
+// last returns the ID of the newest event.
+func (j *journal) last() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.next
+}
+
+// since returns the frames of sessionKey after event ID after, the ID to
+// pass next time, and a channel that is closed when the next frame is
+// appended. complete is false when frames after that ID have already been
+// dropped or the ID is not from this journal.
+func (j *journal) since(after uint64, sessionKey string) (frames []frame, next uint64, changed <-chan struct{},
+	complete bool,
+) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.frames) == 0 {
+		return nil, j.next, j.changed, after == j.next
+	}
+	complete = after <= j.next && after+1 >= j.frames[j.start].EventID
+	for i := range j.frames {
+		f := j.frames[(j.start+i)%len(j.frames)]
+		if f.EventID > after && f.SessionKey == sessionKey {
+			frames = append(frames, f)
+		}
+	}
+	return frames, j.next, j.changed, complete
+}
+
+// streamTurn runs one turn and records its progress in the journal, passing
+// each frame to emit as well. emit may be nil. Every turn goes through here,
+// whichever endpoint started it, so SSE clients can follow all of them.
+func (s *Server) streamTurn(
+	ctx context.Context,
+	id, sessionKey, content string,
+	emit func(frame),
+) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	publish := func(f frame) {
+		f.ID, f.SessionKey = id, sessionKey
+		f = s.journal.append(f)
+		if emit != nil {
+			emit(f)
+		}
+	}
+	response, err := s.agent.ProcessDirectWithCallbacks(ctx, content, sessionKey, Channel, sessionKey,
+		agent.StreamCallbacks{
+			OnDelta: func(text string) {
+				publish(frame{Type: frameTypeDelta, Content: text})
+			},
+			OnToolCall: func(call providers.ToolCall) {
+				publish(frame{Type: frameTypeToolCall, Tool: call.Name, ToolCallID: call.ID, Arguments: call.Arguments})
+			},
+			OnToolResult: func(call providers.ToolCall, result *tools.ToolResult) {
+				text := result.ForLLM
+				if text == "" && result.Err != nil {
+					text = result.Err.Error()
+				}
+				publish(frame{
+					Type:       frameTypeToolResult,
+					Tool:       call.Name,
+					ToolCallID: call.ID,
+					IsError:    result.IsError,
+					Content:    utils.Truncate(text, maxToolResultPreview),
+				})
+			},
+		})
+	if err != nil {
+		publish(frame{Type: frameTypeError, Error: err.Error()})
+		return "", err
+	}
+	publish(frame{Type: frameTypeDone, Content: response})
+	return response, nil
+}
@@ -0,0 +1,463 @@
+// Package api serves a small REST API that lets local programs talk to the
+// agent: send a message into a session, read session history, turn traces
+// and the feedback on answers, list sessions and trigger a heartbeat, plus
+// WebSocket and server-sent event endpoints that stream turns as they run.
+// It is mounted on the gateway's shared HTTP server under /api/v1 and
+// every request needs an API key.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// Prefix is the path the API is mounted under.
+	Prefix = "/api/v1/"
+
+	// Channel is the channel name turns sent through the API run under.
+	Channel = "api"
+
+	defaultSession       = "default"
+	defaultHistoryLimit  = 50
+	defaultAuditLimit    = 100
+	defaultFeedbackLimit = 100
+	maxRequestBytes      = 1 << 20
+
+	errShuttingDown = "the gateway is shutting down"
+)
+
+// Agent is the part of the agent loop the API drives.
+type Agent interface {
+	ProcessDirectWithCallbacks(
+		ctx context.Context,
+		content, sessionKey, channel, chatID string,
+		cb agent.StreamCallbacks,
+	) (string, error)
+	DefaultAgentSessions() (string, *session.SessionManager)
+	DefaultAgentTraces() *memory.TraceLog
+	AuditLog() *audit.Log
+}
+
+// Server handles the API requests.
+type Server struct {
+	agent     Agent
+	keys      [][]byte
+	timeout   time.Duration
+	heartbeat func() bool
+	journal   *journal
+	mux       *http.ServeMux
+	draining  atomic.Bool
+}
+
+// NewServer creates the API over agentLoop. heartbeat starts a heartbeat
+// and reports whether the heartbeat service is running; it may be nil.
+func NewServer(cfg config.GatewayAPIConfig, agentLoop Agent, heartbeat func() bool) (*Server, error) {
+	s := &Server{
+		agent:     agentLoop,
+		heartbeat: heartbeat,
+		timeout:   time.Duration(cfg.TimeoutSeconds) * time.Second,
+		journal:   newJournal(journalSize),
+	}
+	for _, key := range cfg.APIKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			s.keys = append(s.keys, []byte(key))
+		}
+	}
+	if len(s.keys) == 0 {
+		return nil, errors.New("gateway.api.api_keys is empty; refusing to serve the API without authentication")
+	}
+	if s.timeout <= 0 {
+		s.timeout = 300 * time.Second
+	}
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("POST /api/v1/messages", s.handleMessage)
+	s.mux.HandleFunc("GET /api/v1/sessions", s.handleSessions)
+	s.mux.HandleFunc("GET /api/v1/sessions/{key}/history", s.handleHistory)
+	s.mux.HandleFunc("GET /api/v1/sessions/{key}/events", s.handleEvents)
+	s.mux.HandleFunc("GET /api/v1/sessions/{key}/traces", s.handleTraces)
+	s.mux.HandleFunc("GET /api/v1/sessions/{key}/traces/{turn}", s.handleTrace)
+	s.mux.HandleFunc("GET /api/v1/audit", s.handleAudit)
+	s.mux.HandleFunc("GET /api/v1/feedback", s.handleFeedback)
+	s.mux.HandleFunc("POST /api/v1/heartbeat", s.handleHeartbeat)
+	s.mux.HandleFunc("GET "+wsPath, s.handleWebSocket)
+	return s, nil
+}
+
+// Drain makes the server refuse new turns with 503 Service Unavailable, so
+// that the gateway can wait for the running ones before it shuts down. The
+// other endpoints keep working.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// ServeHTTP checks the API key and dispatches the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		if log := s.agent.AuditLog(); log != nil {
+			err := log.Record(r.Context(), audit.Event{
+				Kind:    audit.AuthFailure,
+				Actor:   Channel,
+				Action:  "api_key",
+				Target:  r.RemoteAddr,
+				Outcome: "denied",
+				Detail:  r.Method + " " + r.URL.Path,
+			})
+			if err != nil {
+				logger.WarnCF("api", "Failed to audit failed authentication", map[string]any{"error": err.Error()})
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="picoclaw"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized accepts the key as "Authorization: Bearer <key>", in the
+// X-API-Key header or, for the WebSocket endpoint only, as ?api_key=.
+func (s *Server) authorized(r *http.Request) bool {
+	got := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		got = bearer
+	}
+	if got == "" && r.URL.Path == wsPath {
+		got = r.URL.Query().Get("api_key")
+	}
+	if got == "" {
+		return false
+	}
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(got), key) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionKey maps a session given by a client to a session key of the
+// default agent. Full "agent:" keys (as listed by /sessions) are used as is;
+// anything else names an API session.
+func (s *Server) sessionKey(name string) (string, *session.SessionManager) {
+	agentID, sessions := s.agent.DefaultAgentSessions()
+	if strings.HasPrefix(name, "agent:") {
+		return name, sessions
+	}
+	if name == "" {
+		name = defaultSession
+	}
+	return "agent:" + agentID + ":" + Channel + ":" + name, sessions
+}
+
+type messageRequest struct {
+	Session string `json:"session"`
+	Message string `json:"message"`
+	Stream  bool   `json:"stream"` // reply with server-sent events; also chosen by Accept: text/event-stream
+}
+
+type messageResponse struct {
+	SessionKey string `json:"session_key"`
+	Response   string `json:"response"`
+}
+
+func (s *Server) handleMessage(w http.ResponseWriter, r *http.Request) {
+	var req messageRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON body: %v", err))
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeError(w, http.StatusBadRequest, "message is required")
+		return
+	}
+	if s.draining.Load() {
+		w.Header().Set("Retry-After", "30")
+		writeError(w, http.StatusServiceUnavailable, errShuttingDown)
+		return
+	}
+	key, _ := s.sessionKey(req.Session)
+	if req.Stream || strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		s.streamMessage(w, r, key, req.Message)
+		return
+	}
+
+	// A turn can take much longer than the shared server's write timeout.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(s.timeout + 10*time.Second))
+
+	response, err := s.streamTurn(r.Context(), uuid.NewString(), key, req.Message, nil)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		logger.WarnCF("api", "Message failed", map[string]any{"session_key": key, "error": err.Error()})
+		writeError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, messageResponse{SessionKey: key, Response: response})
+}
+
+type sessionInfo struct {
+	Key      string    `json:"key"`
+	Messages int       `json:"messages"`
+	Summary  string    `json:"summary,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, _ *http.Request) {
+	_, sessions := s.agent.DefaultAgentSessions()
+	infos := []sessionInfo{}
+	if sessions != nil {
+		for _, key := range sessions.Keys() {
+			snap, ok := sessions.Snapshot(key)
+			if !ok {
+				continue
+			}
+			infos = append(infos, sessionInfo{
+				Key:      key,
+				Messages: len(snap.Messages),
+				Summary:  snap.Summary,
+				Created:  snap.Created,
+				Updated:  snap.Updated,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": infos})
+}
+
+type historyMessage struct {
+	Role       string `json:"role"`
+	Content    string `json:"content"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+type historyResponse struct {
+	Key      string           `json:"key"`
+	Summary  string           `json:"summary,omitempty"`
+	Total    int              `json:"total"`
+	Messages []historyMessage `json:"messages"`
+}
+
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	key, sessions := s.sessionKey(r.PathValue("key"))
+	if sessions == nil {
+		writeError(w, http.StatusNotFound, "no agent configured")
+		return
+	}
+	snap, ok := sessions.Snapshot(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("session %q not found", key))
+		return
+	}
+
+	history := snap.Messages
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	resp := historyResponse{Key: key, Summary: snap.Summary, Total: len(snap.Messages)}
+	resp.Messages = make([]historyMessage, 0, len(history))
+	for _, m := range history {
+		resp.Messages = append(resp.Messages, historyMessage{
+			Role:       string(m.Role),
+			Content:    m.Content,
+			ToolCallID: m.ToolCallID,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// traceSummary describes a turn in the list of a session's traces.
+type traceSummary struct {
+	Turn        int                `json:"turn"`
+	Started     time.Time          `json:"started"`
+	DurationMS  int64              `json:"duration_ms"`
+	UserMessage string             `json:"user_message"`
+	Response    string             `json:"response"`
+	LLMCalls    int                `json:"llm_calls"`
+	ToolCalls   int                `json:"tool_calls"`
+	Usage       memory.UsageTotals `json:"usage"`
+	Error       string             `json:"error,omitempty"`
+}
+
+func (s *Server) handleTraces(w http.ResponseWriter, r *http.Request) {
+	key, _ := s.sessionKey(r.PathValue("key"))
+	traces := s.agent.DefaultAgentTraces()
+	if traces == nil {
+		writeError(w, http.StatusNotFound, "turn traces are disabled")
+		return
+	}
+	turns, err := traces.Turns(r.Context(), key)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	summaries := make([]traceSummary, 0, len(turns))
+	for _, t := range turns {
+		summaries = append(summaries, traceSummary{
+			Turn:        t.Turn,
+			Started:     t.Started,
+			DurationMS:  t.DurationMS,
+			UserMessage: utils.Truncate(t.UserMessage, 200),
+			Response:    utils.Truncate(t.Response, 200),
+			LLMCalls:    len(t.LLMCalls),
+			ToolCalls:   len(t.ToolCalls),
+			Usage:       t.Usage,
+			Error:       t.Error,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"key": key, "turns": summaries})
+}
+
+// handleTrace returns the whole trace of a turn, or of the latest one for
+// "last".
+func (s *Server) handleTrace(w http.ResponseWriter, r *http.Request) {
+	key, _ := s.sessionKey(r.PathValue("key"))
+	traces := s.agent.DefaultAgentTraces()
+	if traces == nil {
+		writeError(w, http.StatusNotFound, "turn traces are disabled")
+		return
+	}
+	turn := 0
+	if v := r.PathValue("turn"); v != "last" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, `turn must be a positive integer or "last"`)
+			return
+		}
+		turn = n
+	}
+	trace, err := traces.Turn(r.Context(), key, turn)
+	if errors.Is(err, memory.ErrTraceNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no trace of turn %s in session %q", r.PathValue("turn"), key))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, trace)
+}
+
+func (s *Server) handleHeartbeat(w http.ResponseWriter, _ *http.Request) {
+	if s.heartbeat == nil || !s.heartbeat() {
+		writeError(w, http.StatusConflict, "heartbeat service is not running")
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "triggered"})
+}
+
+// handleAudit returns audit log entries, filtered by the kind (a
+// comma-separated list), since, session and actor parameters and limited to
+// the newest limit (default 100).
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	log := s.agent.AuditLog()
+	if log == nil {
+		writeError(w, http.StatusNotFound, "the audit log is disabled")
+		return
+	}
+	q := r.URL.Query()
+	kinds, err := audit.ParseKinds(q.Get("kind"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	since, err := audit.ParseSince(q.Get("since"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := defaultAuditLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+	events, err := log.Query(r.Context(), audit.Filter{
+		Kinds:      kinds,
+		Since:      since,
+		SessionKey: q.Get("session"),
+		Actor:      q.Get("actor"),
+		Limit:      limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": events})
+}
+
+// handleFeedback lists the feedback on the default agent's answers, the
+// latest first. ?low=true keeps the bad ratings and corrections, the
+// answers worth a look when tuning the prompts.
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := session.FeedbackFilter{Limit: defaultFeedbackLimit}
+	var err error
+	if v := q.Get("low"); v != "" {
+		if filter.Low, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "low must be true or false")
+			return
+		}
+	}
+	if filter.Since, err = audit.ParseSince(q.Get("since"), time.Now()); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+	}
+	feedback := []session.RatedAnswer{}
+	if _, sessions := s.agent.DefaultAgentSessions(); sessions != nil {
+		feedback = append(feedback, sessions.ListFeedback(filter)...)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"feedback": feedback})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.WarnCF("api", "Failed to encode response", map[string]any{"error": err.Error()})
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type fakeAgent struct {
+	sessions *session.SessionManager
+	traces   *memory.TraceLog
+	audit    *audit.Log
+
+	mu      sync.Mutex
+	channel string
+}
+
+// ProcessDirectWithCallbacks echoes content. "use tool" makes it report a
+// tool call first; "fail" makes the turn fail.
+func (f *fakeAgent) ProcessDirectWithCallbacks(
+	_ context.Context,
+	content, sessionKey, channel, _ string,
+	cb agent.StreamCallbacks,
+) (string, error) {
+	f.mu.Lock()
+	f.channel = channel
+	f.mu.Unlock()
+	if content == "fail" {
+		return "", errors.New("provider unavailable")
+	}
+	if content == "use tool" && cb.OnToolCall != nil {
+		call := providers.ToolCall{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "a.md"}}
+		cb.OnToolCall(call)
+		cb.OnToolResult(call, tools.NewToolResult("file contents"))
+	}
+	if cb.OnDelta != nil {
+		cb.OnDelta("echo: ")
+		cb.OnDelta(content)
+	}
+	f.sessions.AddMessage(sessionKey, "user", content)
+	f.sessions.AddMessage(sessionKey, "assistant", "echo: "+content)
+	return "echo: " + content, nil
+}
+
+func (f *fakeAgent) DefaultAgentSessions() (string, *session.SessionManager) {
+	return "main", f.sessions
+}
+
+func (f *fakeAgent) DefaultAgentTraces() *memory.TraceLog {
+	return f.traces
+}
+
+func (f *fakeAgent) AuditLog() *audit.Log {
+	return f.audit
+}
+
+func newTestServer(t *testing.T, heartbeat func() bool) (*Server, *fakeAgent) {
+	t.Helper()
+	fake := &fakeAgent{sessions: session.NewSessionManager(t.TempDir())}
+	s, err := NewServer(config.GatewayAPIConfig{APIKeys: []string{"k1"}}, fake, heartbeat)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s, fake
+}
+
+func do(t *testing.T, s *Server, method, path, body string, headers ...string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s %s: invalid JSON %q", method, path, rec.Body.String())
+	}
+	return rec.Code, out
+}
+
+func TestNewServer_RequiresKeys(t *testing.T) {
+	if _, err := NewServer(config.GatewayAPIConfig{APIKeys: []string{" "}}, &fakeAgent{}, nil); err == nil {
+		t.Error("expected error without API keys")
+	}
+}
+
+func TestServer_Auth(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+
+	if code, _ := do(t, s, "GET", "/api/v1/sessions", ""); code != http.StatusUnauthorized {
+		t.Errorf("no key: status = %d, want 401", code)
+	}
+	code, _ := do(t, s, "GET", "/api/v1/sessions", "", "Authorization", "Bearer wrong")
+	if code != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want 401", code)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions", "", "Authorization", "Bearer k1"); code != http.StatusOK {
+		t.Errorf("bearer key: status = %d, want 200", code)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions", "", "X-API-Key", "k1"); code != http.StatusOK {
+		t.Errorf("X-API-Key: status = %d, want 200", code)
+	}
+}
+
+func TestServer_MessageAndHistory(t *testing.T) {
+	s, fake := newTestServer(t, nil)
+	auth := []string{"X-API-Key", "k1"}
+
+	code, out := do(t, s, "POST", "/api/v1/messages", `{"session":"notes","message":"hello"}`, auth...)
+	if code != http.StatusOK || out["response"] != "echo: hello" || out["session_key"] != "agent:main:api:notes" {
+		t.Fatalf("message: %d %v", code, out)
+	}
+	if fake.channel != Channel {
+		t.Errorf("channel = %q, want %q", fake.channel, Channel)
+	}
+	if code, _ := do(t, s, "POST", "/api/v1/messages", `{"message":"  "}`, auth...); code != http.StatusBadRequest {
+		t.Errorf("empty message: status = %d, want 400", code)
+	}
+
+	code, out = do(t, s, "GET", "/api/v1/sessions", "", auth...)
+	if list, _ := out["sessions"].([]any); code != http.StatusOK || len(list) != 1 {
+		t.Fatalf("sessions: %d %v", code, out)
+	}
+
+	// Short names and full keys address the same session.
+	for _, path := range []string{"/api/v1/sessions/notes/history", "/api/v1/sessions/agent:main:api:notes/history"} {
+		code, out = do(t, s, "GET", path+"?limit=1", "", auth...)
+		msgs, _ := out["messages"].([]any)
+		if code != http.StatusOK || out["total"] != float64(2) || len(msgs) != 1 {
+			t.Fatalf("%s: %d %v", path, code, out)
+		}
+		if last := msgs[0].(map[string]any); last["role"] != "assistant" || last["content"] != "echo: hello" {
+			t.Errorf("%s: last message = %v", path, last)
+		}
+	}
+
+	if code, _ := do(t, s, "GET", "/api/v1/sessions/missing/history", "", auth...); code != http.StatusNotFound {
+		t.Errorf("missing session: status = %d, want 404", code)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions/notes/history?limit=0", "", auth...); code != http.StatusBadRequest {
+		t.Errorf("bad limit: status = %d, want 400", code)
+	}
+}
+
+func TestServer_DrainRefusesTurns(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	auth := []string{"X-API-Key", "k1"}
+	s.Drain()
+
+	code, out := do(t, s, "POST", "/api/v1/messages", `{"session":"notes","message":"hello"}`, auth...)
+	if code != http.StatusServiceUnavailable || out["error"] != errShuttingDown {
+		t.Errorf("message while draining: %d %v, want 503", code, out)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions", "", auth...); code != http.StatusOK {
+		t.Errorf("sessions while draining: status = %d, want 200", code)
+	}
+}
+
+func TestServer_Audit(t *testing.T) {
+	s, fake := newTestServer(t, nil)
+	auth := []string{"X-API-Key", "k1"}
+	if code, _ := do(t, s, "GET", "/api/v1/audit", "", auth...); code != http.StatusNotFound {
+		t.Errorf("audit off: status = %d, want 404", code)
+	}
+
+	log, err := audit.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.audit = log
+	err = log.Record(context.Background(), audit.Event{Kind: audit.ShellExec, Action: "exec", Outcome: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(t, s, "GET", "/api/v1/sessions", "", "X-API-Key", "wrong")
+
+	code, out := do(t, s, "GET", "/api/v1/audit?kind=auth_failure&since=1h", "", auth...)
+	events, _ := out["events"].([]any)
+	if code != http.StatusOK || len(events) != 1 {
+		t.Fatalf("audit: %d %v", code, out)
+	}
+	if e := events[0].(map[string]any); e["action"] != "api_key" || e["detail"] != "GET /api/v1/sessions" {
+		t.Errorf("failed authentication entry = %v", e)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/audit?kind=nope", "", auth...); code != http.StatusBadRequest {
+		t.Errorf("bad kind: status = %d, want 400", code)
+	}
+}
+
+func TestServer_Traces(t *testing.T) {
+	s, fake := newTestServer(t, nil)
+	auth := []string{"X-API-Key", "k1"}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions/notes/traces", "", auth...); code != http.StatusNotFound {
+		t.Errorf("traces off: status = %d, want 404", code)
+	}
+
+	traces, err := memory.NewTraceLog(t.TempDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.traces = traces
+	for _, msg := range []string{"hello", "read a.md"} {
+		tr := &memory.TurnTrace{
+			SessionKey:  "agent:main:api:notes",
+			UserMessage: msg,
+			LLMCalls:    []memory.LLMCallTrace{{Iteration: 1, Model: "test-model"}},
+			Response:    "echo: " + msg,
+		}
+		if err := traces.Record(context.Background(), tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, out := do(t, s, "GET", "/api/v1/sessions/notes/traces", "", auth...)
+	if turns, _ := out["turns"].([]any); code != http.StatusOK || len(turns) != 2 {
+		t.Fatalf("traces: %d %v", code, out)
+	}
+	code, out = do(t, s, "GET", "/api/v1/sessions/notes/traces/last", "", auth...)
+	if code != http.StatusOK || out["turn"] != float64(2) || out["user_message"] != "read a.md" {
+		t.Errorf("last trace: %d %v", code, out)
+	}
+	code, out = do(t, s, "GET", "/api/v1/sessions/agent:main:api:notes/traces/1", "", auth...)
+	if calls, _ := out["llm_calls"].([]any); code != http.StatusOK || len(calls) != 1 {
+		t.Errorf("trace 1: %d %v", code, out)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions/notes/traces/9", "", auth...); code != http.StatusNotFound {
+		t.Errorf("missing turn: status = %d, want 404", code)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/sessions/notes/traces/first", "", auth...); code != http.StatusBadRequest {
+		t.Errorf("bad turn: status = %d, want 400", code)
+	}
+}
+
+func TestServer_Heartbeat(t *testing.T) {
+	running := false
+	triggered := 0
+	s, _ := newTestServer(t, func() bool {
+		if running {
+			triggered++
+		}
+		return running
+	})
+	auth := []string{"X-API-Key", "k1"}
+
+	if code, _ := do(t, s, "POST", "/api/v1/heartbeat", "", auth...); code != http.StatusConflict {
+		t.Errorf("stopped service: status = %d, want 409", code)
+	}
+	running = true
+	if code, _ := do(t, s, "POST", "/api/v1/heartbeat", "", auth...); code != http.StatusAccepted || triggered != 1 {
+		t.Errorf("running service: status = %d, triggered = %d", code, triggered)
+	}
+}
+
+func TestServer_Feedback(t *testing.T) {
+	s, fake := newTestServer(t, nil)
+	auth := []string{"X-API-Key", "k1"}
+	for _, q := range []string{"museum hours?", "weather?"} {
+		body := `{"session":"s","message":"` + q + `"}`
+		if code, _ := do(t, s, "POST", "/api/v1/messages", body, auth...); code != http.StatusOK {
+			t.Fatalf("message: %d", code)
+		}
+	}
+	key := "agent:main:api:s"
+	fake.sessions.AddFeedback(key, "echo: museum hours?", session.Feedback{Rating: session.RatingBad, Correction: "6pm"})
+	fake.sessions.AddFeedback(key, "", session.Feedback{Rating: session.RatingGood})
+
+	code, out := do(t, s, "GET", "/api/v1/feedback", "", auth...)
+	if list, _ := out["feedback"].([]any); code != http.StatusOK || len(list) != 2 {
+		t.Fatalf("feedback: %d %v", code, out)
+	}
+	code, out = do(t, s, "GET", "/api/v1/feedback?low=true&since=1h", "", auth...)
+	list, _ := out["feedback"].([]any)
+	if code != http.StatusOK || len(list) != 1 {
+		t.Fatalf("low feedback: %d %v", code, out)
+	}
+	fb := list[0].(map[string]any)
+	if fb["session"] != key || fb["question"] != "museum hours?" || fb["correction"] != "6pm" ||
+		fb["rating"] != float64(-1) {
+		t.Errorf("low feedback = %v", fb)
+	}
+	if code, _ := do(t, s, "GET", "/api/v1/feedback?low=maybe", "", auth...); code != http.StatusBadRequest {
+		t.Errorf("bad low: status = %d, want 400", code)
+	}
+}
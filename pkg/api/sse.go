@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const ssePingInterval = 15 * time.Second
+
+// streamMessage answers POST /api/v1/messages with server-sent events that
+// mirror the WebSocket frames of the turn. The turn keeps running if the
+// client goes away; it can pick up the rest from
+// /api/v1/sessions/{key}/events with the last event ID it saw.
+func (s *Server) streamMessage(w http.ResponseWriter, r *http.Request, key, content string) {
+	id := uuid.NewString()
+	after := s.journal.last()
+	go s.streamTurn(context.WithoutCancel(r.Context()), id, key, content, nil)
+	s.follow(w, r, key, after, id)
+}
+
+// handleEvents streams the frames of a session's turns as server-sent
+// events, whichever endpoint started them. With a Last-Event-ID header (or
+// ?last_event_id=) it first replays what the client missed; a "reset"
+// event means some of that is gone and the client should reload the
+// history.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	key, _ := s.sessionKey(r.PathValue("key"))
+	after := s.journal.last()
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	if lastID != "" {
+		n, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Last-Event-ID must be an event ID")
+			return
+		}
+		after = n
+	}
+	s.follow(w, r, key, after, "")
+}
+
+// follow writes the session's frames after event ID after until the
+// client disconnects or, if untilTurn is set, that turn has finished.
+func (s *Server) follow(w http.ResponseWriter, r *http.Request, key string, after uint64, untilTurn string) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{}) // the stream outlives the shared server's write timeout
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ping := time.NewTicker(ssePingInterval)
+	defer ping.Stop()
+	for {
+		frames, next, changed, complete := s.journal.since(after, key)
+		if !complete {
+			writeSSE(w, frame{Type: frameTypeReset, SessionKey: key})
+		}
+		after = next
+		for _, f := range frames {
+			if err := writeSSE(w, f); err != nil {
+				return
+			}
+			if f.ID == untilTurn && (f.Type == frameTypeDone || f.Type == frameTypeError) {
+				rc.Flush()
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE writes f as one event, named after its type and carrying its
+// journal position as the event ID.
+func writeSSE(w http.ResponseWriter, f frame) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if f.EventID != 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", f.EventID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", f.Type, data)
+	return err
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sseEvent struct {
+	id    string
+	event string
+	data  frame
+}
+
+// readSSE reads events from body until n have arrived or the stream ends.
+func readSSE(t *testing.T, body *bufio.Reader, n int) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var cur sseEvent
+	for len(events) < n {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return events
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if cur.event != "" {
+				events = append(events, cur)
+			}
+			cur = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			cur.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			cur.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &cur.data); err != nil {
+				t.Fatalf("bad data line %q: %v", line, err)
+			}
+		}
+	}
+	return events
+}
+
+func sseRequest(t *testing.T, ctx context.Context, method, url, body string, headers ...string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	req.Header.Set("X-API-Key", "k1")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("%s %s: status %d, content type %q", method, url, resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp
+}
+
+func TestSSE_StreamAndResume(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp := sseRequest(t, ctx, "POST", srv.URL+"/api/v1/messages", `{"session":"kitchen","message":"use tool"}`,
+		"Accept", "text/event-stream")
+	events := readSSE(t, bufio.NewReader(resp.Body), 10)
+	var types []string
+	for _, e := range events {
+		types = append(types, e.event)
+		if e.id != strconv.FormatUint(e.data.EventID, 10) || e.data.SessionKey != "agent:main:api:kitchen" {
+			t.Errorf("event %+v lacks its ID or session key", e)
+		}
+	}
+	if got := strings.Join(types, ","); got != "tool_call,tool_result,delta,delta,done" {
+		t.Fatalf("event types = %s; the stream should end after done", got)
+	}
+	if events[4].data.Content != "echo: use tool" {
+		t.Errorf("done = %+v", events[4].data)
+	}
+
+	// Resuming after the second event replays the rest of the turn.
+	resp = sseRequest(t, ctx, "GET", srv.URL+"/api/v1/sessions/kitchen/events", "", "Last-Event-ID", events[1].id)
+	resumed := readSSE(t, bufio.NewReader(resp.Body), 3)
+	if len(resumed) != 3 || resumed[0].id != events[2].id || resumed[2].event != frameTypeDone {
+		t.Errorf("resumed = %+v, want the last three events", resumed)
+	}
+
+	// An ID the journal no longer covers gets a reset first.
+	resp = sseRequest(t, ctx, "GET", srv.URL+"/api/v1/sessions/kitchen/events?last_event_id=1", "")
+	if stale := readSSE(t, bufio.NewReader(resp.Body), 2); len(stale) != 2 || stale[0].event != frameTypeReset ||
+		stale[1].id != events[0].id {
+		t.Errorf("stale resume = %+v, want reset then the journaled events", stale)
+	}
+}
+
+func TestSSE_FollowsTurnsFromOtherEndpoints(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp := sseRequest(t, ctx, "GET", srv.URL+"/api/v1/sessions/kitchen/events", "")
+	body := bufio.NewReader(resp.Body)
+
+	// A plain REST turn in another session, then one in the followed session.
+	do(t, s, "POST", "/api/v1/messages", `{"session":"other","message":"elsewhere"}`, "X-API-Key", "k1")
+	do(t, s, "POST", "/api/v1/messages", `{"session":"kitchen","message":"lights on"}`, "X-API-Key", "k1")
+
+	events := readSSE(t, body, 3)
+	if len(events) != 3 || events[2].event != frameTypeDone || events[2].data.Content != "echo: lights on" {
+		t.Errorf("followed events = %+v, want only the kitchen turn", events)
+	}
+}
+
+func TestJournal_Wraps(t *testing.T) {
+	j := newJournal(3)
+	start := j.last()
+	for i := range 5 {
+		j.append(frame{Type: frameTypeDelta, SessionKey: "s", Content: strconv.Itoa(i)})
+	}
+
+	frames, next, _, complete := j.since(start+2, "s")
+	if !complete || len(frames) != 3 || frames[0].Content != "2" || next != start+5 {
+		t.Errorf("since(oldest-1) = %+v, %d, %v", frames, next, complete)
+	}
+	if frames, _, _, complete := j.since(start+1, "s"); complete || len(frames) != 3 {
+		t.Errorf("since(dropped) = %+v, %v; want incomplete", frames, complete)
+	}
+	if frames, _, _, complete := j.since(start+5, "other"); !complete || len(frames) != 0 {
+		t.Errorf("since(other session) = %+v, %v", frames, complete)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// wsPath is the WebSocket endpoint. Browsers cannot set headers on a
+// WebSocket handshake, so it also takes the key as ?api_key=.
+const wsPath = "/api/v1/ws"
+
+var upgrader = websocket.Upgrader{
+	// Every connection must present an API key, which a foreign page does
+	// not have, so the origin is not checked.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsConn serializes writes to one client; turns run concurrently.
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsConn) send(f frame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.conn.WriteJSON(f); err != nil {
+		logger.DebugCF("api", "WebSocket write failed", map[string]any{"error": err.Error()})
+	}
+}
+
+// handleWebSocket runs a client connection. Each "message" frame starts a
+// turn whose progress is streamed back: text deltas, tool calls and results,
+// and a final "done" frame with the complete reply. Turns end when the
+// connection closes.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has replied with an error
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxRequestBytes)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &wsConn{conn: conn}
+	var turns sync.WaitGroup
+	defer turns.Wait()
+
+	for {
+		var f frame
+		if err := conn.ReadJSON(&f); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.DebugCF("api", "WebSocket closed", map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		switch f.Type {
+		case frameTypePing:
+			c.send(frame{Type: frameTypePong, ID: f.ID})
+		case frameTypeMessage:
+			if strings.TrimSpace(f.Content) == "" {
+				c.send(frame{Type: frameTypeError, ID: f.ID, Error: "content is required"})
+				continue
+			}
+			if s.draining.Load() {
+				c.send(frame{Type: frameTypeError, ID: f.ID, Error: errShuttingDown})
+				continue
+			}
+			key, _ := s.sessionKey(f.Session)
+			turns.Add(1)
+			go func() {
+				defer turns.Done()
+				s.streamTurn(ctx, f.ID, key, f.Content, c.send)
+			}()
+		default:
+			c.send(frame{Type: frameTypeError, ID: f.ID, Error: "unknown frame type " + f.Type})
+		}
+	}
+}
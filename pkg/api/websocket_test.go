@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func dialWS(t *testing.T, s *Server, query string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + wsPath + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readUntil reads frames until one of type stop arrives.
+func readUntil(t *testing.T, conn *websocket.Conn, stop string) []frame {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var frames []frame
+	for {
+		var f frame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("read after %v: %v", frames, err)
+		}
+		frames = append(frames, f)
+		if f.Type == stop {
+			return frames
+		}
+	}
+}
+
+func TestWebSocket_RequiresKey(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	_, resp, err := dialWS(t, s, "?api_key=wrong")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("dial with wrong key: err = %v, resp = %v; want 401", err, resp)
+	}
+}
+
+func TestWebSocket_StreamsTurn(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	conn, _, err := dialWS(t, s, "?api_key=k1")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	conn.WriteJSON(frame{Type: frameTypePing, ID: "p"})
+	if frames := readUntil(t, conn, frameTypePong); frames[0].ID != "p" {
+		t.Errorf("pong = %+v", frames[0])
+	}
+
+	conn.WriteJSON(frame{Type: frameTypeMessage, ID: "m1", Session: "kitchen", Content: "use tool"})
+	frames := readUntil(t, conn, frameTypeDone)
+	var types []string
+	for _, f := range frames {
+		types = append(types, f.Type)
+		if f.ID != "m1" || f.SessionKey != "agent:main:api:kitchen" {
+			t.Errorf("frame %+v lacks the message ID or session key", f)
+		}
+	}
+	want := []string{frameTypeToolCall, frameTypeToolResult, frameTypeDelta, frameTypeDelta, frameTypeDone}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Fatalf("frame types = %v, want %v", types, want)
+	}
+	if call := frames[0]; call.Tool != "read_file" || call.ToolCallID != "call_1" || call.Arguments["path"] != "a.md" {
+		t.Errorf("tool_call = %+v", call)
+	}
+	if result := frames[1]; result.Content != "file contents" || result.IsError {
+		t.Errorf("tool_result = %+v", result)
+	}
+	if done := frames[4]; done.Content != "echo: use tool" {
+		t.Errorf("done = %+v", done)
+	}
+
+	conn.WriteJSON(frame{Type: frameTypeMessage, ID: "m2", Content: "fail"})
+	if frames := readUntil(t, conn, frameTypeError); frames[0].Error != "provider unavailable" {
+		t.Errorf("error frame = %+v", frames[0])
+	}
+	conn.WriteJSON(frame{Type: frameTypeMessage, ID: "m3"})
+	if frames := readUntil(t, conn, frameTypeError); frames[0].ID != "m3" {
+		t.Errorf("empty message: %+v", frames[0])
+	}
+}
@@ -0,0 +1,375 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package audit keeps an append-only log of security-relevant events: tool
+// approvals, file writes, shell commands, config changes and failed
+// authentication. It is separate from the debug log and is never rotated.
+//
+// Every entry is signed with HMAC-SHA256 over the entry and the signature of
+// the entry before it, using a key kept next to the log. Editing, removing
+// or reordering entries breaks the chain, which Verify reports. Remove,
+// which erasure of a person's data uses, signs the rest of the chain again.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+const (
+	logFile = "audit.jsonl"
+	keyFile = "audit.key"
+	// maxLineSize bounds one entry; details are short, so this is generous.
+	maxLineSize = 1 << 20
+)
+
+// Kind is the type of an audited event.
+type Kind string
+
+// Audited events.
+const (
+	ToolApproval Kind = "tool_approval" // a pending tool call was approved or denied
+	FileWrite    Kind = "file_write"    // a tool wrote, edited or appended to a file
+	ShellExec    Kind = "shell_exec"    // a tool ran a shell command
+	ConfigChange Kind = "config_change" // the config file was written
+	AuthFailure  Kind = "auth_failure"  // a wrong API key or pairing code
+	Erasure      Kind = "erasure"       // the data of a person was erased, entries included
+)
+
+// Event is one audit entry. Seq, Time, Prev and Sig are set by Record.
+type Event struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Kind       Kind      `json:"kind"`
+	Actor      string    `json:"actor,omitempty"` // canonical sender ID, "api" or "local"
+	SessionKey string    `json:"session_key,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	ChatID     string    `json:"chat_id,omitempty"`
+	Action     string    `json:"action"`           // tool name, "approve", "deny", "save", ...
+	Target     string    `json:"target,omitempty"` // file path, command, config path or remote address
+	Outcome    string    `json:"outcome"`
+	Detail     string    `json:"detail,omitempty"`
+	Prev       string    `json:"prev"`
+	Sig        string    `json:"sig"`
+}
+
+// ErrTampered is returned by Verify when the chain of signatures is broken.
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// Filter selects events for Query. Zero fields match everything.
+type Filter struct {
+	Kinds      []Kind
+	Since      time.Time
+	Until      time.Time
+	SessionKey string
+	Actor      string
+	Limit      int // keep only the newest Limit matches
+}
+
+func (f Filter) matches(e Event) bool {
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, k := range f.Kinds {
+			found = found || k == e.Kind
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if f.SessionKey != "" && e.SessionKey != f.SessionKey {
+		return false
+	}
+	return f.Actor == "" || e.Actor == f.Actor
+}
+
+// Log is the audit log in one directory. Several processes may append to
+// it, e.g. the gateway and a CLI command saving the config.
+type Log struct {
+	path string
+	key  []byte
+
+	mu   sync.Mutex
+	size int64 // file size after our last write; -1 before the first
+	seq  int64
+	sig  string
+}
+
+// Open opens the log in dir, creating dir and a new signing key on first use.
+func Open(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("audit: create directory: %w", err)
+	}
+	key, err := loadKey(filepath.Join(dir, keyFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Log{path: filepath.Join(dir, logFile), key: key, size: -1}, nil
+}
+
+func loadKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil || len(key) < 16 {
+			return nil, fmt.Errorf("audit: invalid key in %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("audit: read key: %w", err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("audit: generate key: %w", err)
+	}
+	// O_EXCL: if another process created the key meanwhile, use theirs.
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if os.IsExist(err) {
+		return loadKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: create key: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return nil, fmt.Errorf("audit: write key: %w", err)
+	}
+	return key, nil
+}
+
+// Record signs e and appends it to the log.
+func (l *Log) Record(_ context.Context, e Event) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Another process may have appended since; continue its chain.
+	if info, err := os.Stat(l.path); err == nil && info.Size() != l.size {
+		if err := l.loadTailLocked(); err != nil {
+			return err
+		}
+	}
+
+	e.Seq = l.seq + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Prev = l.sig
+	e.Sig = l.sign(e)
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: marshal event: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: open log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("audit: append event: %w", err)
+	}
+	l.size = -1 // unknown if Stat fails: read the tail again next time
+	if info, err := f.Stat(); err == nil {
+		l.size = info.Size()
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("audit: close log: %w", err)
+	}
+	l.seq, l.sig = e.Seq, e.Sig
+	return nil
+}
+
+// Query returns the events matching f, oldest first.
+func (l *Log) Query(_ context.Context, f Filter) ([]Event, error) {
+	var events []Event
+	err := l.scan(func(e Event) error {
+		if f.matches(e) {
+			events = append(events, e)
+		}
+		return nil
+	})
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[len(events)-f.Limit:]
+	}
+	return events, err
+}
+
+// Remove deletes the entries match selects, numbers and signs the rest
+// again so that the chain stays valid, and returns how many it deleted. The
+// caller should record an Erasure event afterwards, so that the log shows
+// entries were removed.
+func (l *Log) Remove(_ context.Context, match func(Event) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf []byte
+	var last Event
+	removed := 0
+	err := l.scanLocked(func(e Event) error {
+		if match(e) {
+			removed++
+			return nil
+		}
+		e.Seq, e.Prev = last.Seq+1, last.Sig
+		e.Sig = l.sign(e)
+		line, _ := json.Marshal(e) // an Event always marshals
+		buf = append(append(buf, line...), '\n')
+		last = e
+		return nil
+	})
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+	if err := fileutil.WriteFileAtomic(l.path, buf, 0o600); err != nil {
+		return 0, fmt.Errorf("audit: rewrite log: %w", err)
+	}
+	l.seq, l.sig, l.size = last.Seq, last.Sig, int64(len(buf))
+	return removed, nil
+}
+
+// Verify checks the signature chain of the whole log. A broken chain is
+// reported as ErrTampered with the sequence number of the first bad entry.
+// Entries removed from the end cannot be detected.
+func (l *Log) Verify(_ context.Context) error {
+	var seq int64
+	prev := ""
+	return l.scan(func(e Event) error {
+		seq++
+		if e.Seq != seq || e.Prev != prev || !hmac.Equal([]byte(e.Sig), []byte(l.sign(e))) {
+			return fmt.Errorf("%w: entry %d", ErrTampered, seq)
+		}
+		prev = e.Sig
+		return nil
+	})
+}
+
+// sign returns the signature of e, covering every field but Sig.
+func (l *Log) sign(e Event) string {
+	e.Sig = ""
+	data, _ := json.Marshal(e) // an Event always marshals
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// loadTailLocked reads the sequence number and signature of the last entry.
+func (l *Log) loadTailLocked() error {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("audit: open log: %w", err)
+	}
+	defer f.Close()
+	var last Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			last = e
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("audit: read log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("audit: stat log: %w", err)
+	}
+	l.seq, l.sig, l.size = last.Seq, last.Sig, info.Size()
+	return nil
+}
+
+// scan calls fn for every entry in order. An undecodable line is an error,
+// since nothing else writes to the file.
+func (l *Log) scan(fn func(e Event) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.scanLocked(fn)
+}
+
+func (l *Log) scanLocked(fn func(e Event) error) error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("audit: open log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return fmt.Errorf("%w: line %d is not an entry", ErrTampered, lineNum)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("audit: read log: %w", err)
+	}
+	return nil
+}
+
+// ParseKinds parses a comma-separated list of kinds, such as "shell_exec,file_write".
+func ParseKinds(list string) ([]Kind, error) {
+	var kinds []Kind
+	for _, name := range strings.Split(list, ",") {
+		switch k := Kind(strings.TrimSpace(name)); k {
+		case "":
+		case ToolApproval, FileWrite, ShellExec, ConfigChange, AuthFailure, Erasure:
+			kinds = append(kinds, k)
+		default:
+			return nil, fmt.Errorf("unknown audit event kind %q", name)
+		}
+	}
+	return kinds, nil
+}
+
+// ParseSince parses a start time given as RFC 3339 or as a duration before
+// now, such as "24h".
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("since must be a time like 2026-01-02T15:04:05Z or a duration like 24h")
+	}
+	return t, nil
+}
@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog_RecordQueryVerify(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for _, e := range []Event{
+		{Kind: ShellExec, Actor: "telegram:1", SessionKey: "s1", Action: "exec", Target: "ls", Outcome: "ok"},
+		{Kind: FileWrite, Actor: "telegram:1", SessionKey: "s1", Action: "write_file", Target: "a.md", Outcome: "ok"},
+		{Kind: AuthFailure, Actor: "api", Action: "api_key", Target: "127.0.0.1", Outcome: "denied"},
+	} {
+		if err := l.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A second process continues the chain.
+	other, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Record(ctx, Event{Kind: ConfigChange, Actor: "local", Action: "save", Outcome: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record(ctx, Event{Kind: ToolApproval, Action: "approve", Outcome: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Verify(ctx); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	events, err := l.Query(ctx, Filter{Kinds: []Kind{ShellExec, FileWrite}, Since: start})
+	if err != nil || len(events) != 2 || events[1].Target != "a.md" {
+		t.Errorf("Query(kinds) = %+v, %v", events, err)
+	}
+	events, _ = l.Query(ctx, Filter{Limit: 1})
+	if len(events) != 1 || events[0].Seq != 5 || events[0].Kind != ToolApproval {
+		t.Errorf("Query(limit 1) = %+v", events)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, keyFile))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file = %v, %v", info, err)
+	}
+}
+
+func TestLog_VerifyDetectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	l, _ := Open(dir)
+	for _, target := range []string{"rm -rf /tmp/x", "ls", "whoami"} {
+		if err := l.Record(ctx, Event{Kind: ShellExec, Action: "exec", Target: target, Outcome: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, logFile)
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	edited := strings.Replace(string(data), "rm -rf /tmp/x", "echo hi", 1)
+	for name, content := range map[string]string{
+		"edited":  edited,
+		"removed": lines[0] + lines[2],
+		"garbage": lines[0] + "not json\n" + lines[1],
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Verify(ctx); !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: Verify() = %v, want ErrTampered", name, err)
+		}
+	}
+}
+
+func TestLog_Remove(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	l, _ := Open(dir)
+	for _, actor := range []string{"telegram:1", "telegram:2", "telegram:1", "local"} {
+		if err := l.Record(ctx, Event{Kind: ShellExec, Actor: actor, Action: "exec", Outcome: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := l.Remove(ctx, func(e Event) bool { return e.Actor == "telegram:1" })
+	if err != nil || n != 2 {
+		t.Fatalf("Remove() = %d, %v, want 2", n, err)
+	}
+	if err := l.Record(ctx, Event{Kind: Erasure, Action: "erase", Outcome: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	// Reopened, so that the chain is read back from the file.
+	l, _ = Open(dir)
+	if err := l.Verify(ctx); err != nil {
+		t.Errorf("Verify() after Remove = %v", err)
+	}
+	events, _ := l.Query(ctx, Filter{})
+	if len(events) != 3 || events[0].Actor != "telegram:2" || events[2].Seq != 3 || events[2].Kind != Erasure {
+		t.Errorf("events = %+v", events)
+	}
+}
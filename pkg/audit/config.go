@@ -0,0 +1,63 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// RecordConfigSave audits a write of the config file at path by actor to the
+// log in dir; before and after are the file's contents. Only the names of the
+// top-level sections that changed are recorded, never their values, which
+// hold API keys.
+func RecordConfigSave(dir, path, actor, action string, before, after []byte) error {
+	l, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	detail := "new config file"
+	if len(before) > 0 {
+		detail = "changed: " + strings.Join(ChangedSections(before, after), ", ")
+	}
+	return l.Record(context.Background(), Event{
+		Kind:    ConfigChange,
+		Actor:   actor,
+		Action:  action,
+		Target:  path,
+		Outcome: "ok",
+		Detail:  detail,
+	})
+}
+
+// ChangedSections returns the sorted top-level keys whose values differ
+// between two JSON objects. Input that is not an object counts as empty.
+func ChangedSections(before, after []byte) []string {
+	var b, a map[string]json.RawMessage
+	json.Unmarshal(before, &b)
+	json.Unmarshal(after, &a)
+	changed := map[string]bool{}
+	for k, v := range a {
+		if old, ok := b[k]; !ok || !jsonEqual(old, v) {
+			changed[k] = true
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			changed[k] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(changed))
+}
+
+// jsonEqual compares two JSON values ignoring formatting and key order.
+func jsonEqual(x, y json.RawMessage) bool {
+	var vx, vy any
+	if json.Unmarshal(x, &vx) != nil || json.Unmarshal(y, &vy) != nil {
+		return string(x) == string(y)
+	}
+	ex, _ := json.Marshal(vx)
+	ey, _ := json.Marshal(vy)
+	return string(ex) == string(ey)
+}
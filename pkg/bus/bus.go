@@ -8,8 +8,13 @@ import (
 	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
-// ErrBusClosed is returned when publishing to a closed MessageBus.
-var ErrBusClosed = errors.New("message bus closed")
+var (
+	// ErrBusClosed is returned when publishing to a closed MessageBus.
+	ErrBusClosed = errors.New("message bus closed")
+	// ErrInboundClosed is returned when publishing a channel message after
+	// CloseInbound.
+	ErrInboundClosed = errors.New("message bus takes no more inbound messages")
+)
 
 const defaultBusBufferSize = 64
 
@@ -19,6 +24,8 @@ type MessageBus struct {
 	outboundMedia chan OutboundMediaMessage
 	done          chan struct{}
 	closed        atomic.Bool
+	inboundClosed atomic.Bool
+	streams       atomic.Pointer[StreamDelegate]
 }
 
 func NewMessageBus() *MessageBus {
@@ -34,6 +41,9 @@ func (mb *MessageBus) PublishInbound(ctx context.Context, msg InboundMessage) er
 	if mb.closed.Load() {
 		return ErrBusClosed
 	}
+	if mb.inboundClosed.Load() && msg.Channel != "system" {
+		return ErrInboundClosed
+	}
 	if err := ctx.Err(); err != nil {
 		return err
 	}
@@ -47,6 +57,19 @@ func (mb *MessageBus) PublishInbound(ctx context.Context, msg InboundMessage) er
 	}
 }
 
+// CloseInbound stops taking messages from the channels, so that the agent
+// can finish its work before the gateway stops. System messages, such as the
+// results of background jobs, are still taken.
+func (mb *MessageBus) CloseInbound() {
+	mb.inboundClosed.Store(true)
+}
+
+// PendingInbound returns the number of inbound messages waiting to be
+// consumed.
+func (mb *MessageBus) PendingInbound() int {
+	return len(mb.inbound)
+}
+
 func (mb *MessageBus) ConsumeInbound(ctx context.Context) (InboundMessage, bool) {
 	select {
 	case msg, ok := <-mb.inbound:
@@ -96,6 +96,23 @@ func TestPublishInbound_BusClosed(t *testing.T) {
 	}
 }
 
+func TestCloseInbound(t *testing.T) {
+	mb := NewMessageBus()
+	defer mb.Close()
+	mb.CloseInbound()
+	ctx := context.Background()
+
+	if err := mb.PublishInbound(ctx, InboundMessage{Channel: "telegram", Content: "hi"}); err != ErrInboundClosed {
+		t.Fatalf("expected ErrInboundClosed, got %v", err)
+	}
+	if err := mb.PublishInbound(ctx, InboundMessage{Channel: "system", Content: "job done"}); err != nil {
+		t.Fatalf("system message refused: %v", err)
+	}
+	if n := mb.PendingInbound(); n != 1 {
+		t.Fatalf("expected 1 pending message, got %d", n)
+	}
+}
+
 func TestPublishOutbound_BusClosed(t *testing.T) {
 	mb := NewMessageBus()
 	mb.Close()
@@ -0,0 +1,41 @@
+package bus
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotStreamed is returned by Streamer.Finalize when no text was shown,
+// for example because the channel declined to stream this reply. The
+// caller then sends the reply as a normal message.
+var ErrNotStreamed = errors.New("reply was not streamed")
+
+// Streamer shows a reply to the user while it is being generated.
+type Streamer interface {
+	// Update replaces the shown text with content, the reply so far.
+	// Implementations may skip updates to respect platform rate limits.
+	Update(ctx context.Context, content string) error
+	// Finalize replaces the shown text with the finished reply.
+	Finalize(ctx context.Context, content string) error
+}
+
+// StreamDelegate hands out streamers for chats whose channel can show
+// replies as they are generated. The channel manager implements it.
+type StreamDelegate interface {
+	GetStreamer(ctx context.Context, channel, chatID string) (Streamer, bool)
+}
+
+// SetStreamDelegate sets the delegate GetStreamer asks.
+func (mb *MessageBus) SetStreamDelegate(d StreamDelegate) {
+	mb.streams.Store(&d)
+}
+
+// GetStreamer returns a streamer for the chat, or false if its channel
+// cannot stream or no delegate is set.
+func (mb *MessageBus) GetStreamer(ctx context.Context, channel, chatID string) (Streamer, bool) {
+	d := mb.streams.Load()
+	if d == nil || *d == nil {
+		return nil, false
+	}
+	return (*d).GetStreamer(ctx, channel, chatID)
+}
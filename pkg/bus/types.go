@@ -19,6 +19,7 @@ type InboundMessage struct {
 	Channel    string            `json:"channel"`
 	SenderID   string            `json:"sender_id"`
 	Sender     SenderInfo        `json:"sender"`
+	Role       string            `json:"role,omitempty"` // access role of the sender: "owner", "trusted", "guest" or ""
 	ChatID     string            `json:"chat_id"`
 	Content    string            `json:"content"`
 	Media      []string          `json:"media,omitempty"`
@@ -27,12 +28,24 @@ type InboundMessage struct {
 	MediaScope string            `json:"media_scope,omitempty"` // media lifecycle scope
 	SessionKey string            `json:"session_key"`
 	Metadata   map[string]string `json:"metadata,omitempty"`
+
+	// Feedback, when set, makes the message a user's feedback on an answer
+	// instead of something to answer.
+	Feedback *Feedback `json:"feedback,omitempty"`
+}
+
+// Feedback rates or corrects an answer the bot sent.
+type Feedback struct {
+	Rating     int    `json:"rating,omitempty"`     // 1 good, -1 bad, 0 none (taking a rating back)
+	Correction string `json:"correction,omitempty"` // what the answer should have said
+	Answer     string `json:"answer,omitempty"`     // the text of the rated message, "" for the latest answer
 }
 
 type OutboundMessage struct {
-	Channel string `json:"channel"`
-	ChatID  string `json:"chat_id"`
-	Content string `json:"content"`
+	Channel string   `json:"channel"`
+	ChatID  string   `json:"chat_id"`
+	Content string   `json:"content"`
+	Buttons []string `json:"buttons,omitempty"` // replies the user can pick; tapping one sends its text back
 }
 
 // MediaPart describes a single media attachment to send.
@@ -10,6 +10,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/access"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/identity"
@@ -88,6 +89,8 @@ type BaseChannel struct {
 	groupTrigger        config.GroupTriggerConfig
 	mediaStore          media.MediaStore
 	placeholderRecorder PlaceholderRecorder
+	access              *access.Controller
+	rateLimiter         *UserRateLimiter
 	owner               Channel // the concrete channel that embeds this BaseChannel
 	reasoningChannelID  string
 }
@@ -225,6 +228,49 @@ func (c *BaseChannel) IsAllowedSender(sender bus.SenderInfo) bool {
 	return false
 }
 
+// admit decides whether the sender may reach the agent, and with which
+// role: the allow-list or access control, then the per-user rate limit,
+// which owners are exempt from. A sender over the limit is told so in
+// chatID, once.
+func (c *BaseChannel) admit(ctx context.Context, sender bus.SenderInfo, senderID, chatID string) (string, bool) {
+	// Use SenderInfo-based allow check when available, else fall back to string
+	var allowed bool
+	if sender.CanonicalID != "" || sender.PlatformID != "" {
+		allowed = c.IsAllowedSender(sender)
+	} else {
+		allowed = c.IsAllowed(senderID)
+	}
+
+	// With access control on, the role decides instead of allow_from alone.
+	var role string
+	if c.access != nil {
+		var ok bool
+		if role, ok = c.access.Admit(c.name, sender, senderID, allowed && len(c.allowList) > 0); !ok {
+			logger.DebugCF("channels", "Message rejected by access control", map[string]any{
+				"channel":   c.name,
+				"sender_id": senderID,
+			})
+			return "", false
+		}
+	} else if !allowed {
+		return "", false
+	}
+
+	if c.rateLimiter != nil && role != access.RoleOwner {
+		if ok, warn := c.rateLimiter.Allow(c.name, identity.AccountID(c.name, sender, senderID)); !ok {
+			logger.DebugCF("channels", "Message dropped by rate limit", map[string]any{
+				"channel":   c.name,
+				"sender_id": senderID,
+			})
+			if warn && c.rateLimiter.Message() != "" {
+				c.reply(ctx, chatID, c.rateLimiter.Message())
+			}
+			return "", false
+		}
+	}
+	return role, true
+}
+
 func (c *BaseChannel) HandleMessage(
 	ctx context.Context,
 	peer bus.Peer,
@@ -233,20 +279,20 @@ func (c *BaseChannel) HandleMessage(
 	metadata map[string]string,
 	senderOpts ...bus.SenderInfo,
 ) {
-	// Use SenderInfo-based allow check when available, else fall back to string
 	var sender bus.SenderInfo
 	if len(senderOpts) > 0 {
 		sender = senderOpts[0]
 	}
-	if sender.CanonicalID != "" || sender.PlatformID != "" {
-		if !c.IsAllowedSender(sender) {
-			return
-		}
-	} else {
-		if !c.IsAllowed(senderID) {
+	if c.access != nil {
+		if code, ok := pairCommand(content); ok {
+			c.reply(ctx, chatID, c.access.Pair(c.name, sender, senderID, code))
 			return
 		}
 	}
+	role, ok := c.admit(ctx, sender, senderID, chatID)
+	if !ok {
+		return
+	}
 
 	// Set SenderID to canonical if available, otherwise keep the raw senderID
 	resolvedSenderID := senderID
@@ -260,6 +306,7 @@ func (c *BaseChannel) HandleMessage(
 		Channel:    c.name,
 		SenderID:   resolvedSenderID,
 		Sender:     sender,
+		Role:       role,
 		ChatID:     chatID,
 		Content:    content,
 		Media:      media,
@@ -301,6 +348,34 @@ func (c *BaseChannel) HandleMessage(
 	}
 }
 
+// pairCommand reports whether content is "/pair <code>", also accepting
+// Telegram's "/pair@bot" form, and returns the code.
+func pairCommand(content string) (string, bool) {
+	fields := strings.Fields(content)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", false
+	}
+	name, _, _ := strings.Cut(fields[0], "@")
+	if !strings.EqualFold(name, "/pair") {
+		return "", false
+	}
+	if len(fields) == 1 {
+		return "", true
+	}
+	return fields[1], true
+}
+
+// reply sends text straight back to a chat, bypassing the agent.
+func (c *BaseChannel) reply(ctx context.Context, chatID, text string) {
+	if err := c.bus.PublishOutbound(ctx, bus.OutboundMessage{Channel: c.name, ChatID: chatID, Content: text}); err != nil {
+		logger.ErrorCF("channels", "Failed to publish reply", map[string]any{
+			"channel": c.name,
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+	}
+}
+
 func (c *BaseChannel) SetRunning(running bool) {
 	c.running.Store(running)
 }
@@ -321,6 +396,17 @@ func (c *BaseChannel) GetPlaceholderRecorder() PlaceholderRecorder {
 	return c.placeholderRecorder
 }
 
+// SetAccessController injects the access controller. Without one, only
+// allow_from decides who may talk to the channel.
+func (c *BaseChannel) SetAccessController(ac *access.Controller) {
+	c.access = ac
+}
+
+// SetRateLimiter injects the per-user rate limiter.
+func (c *BaseChannel) SetRateLimiter(l *UserRateLimiter) {
+	c.rateLimiter = l
+}
+
 // SetOwner injects the concrete channel that embeds this BaseChannel.
 // This allows HandleMessage to auto-trigger TypingCapable / ReactionCapable / PlaceholderCapable.
 func (c *BaseChannel) SetOwner(ch Channel) {
@@ -1,8 +1,11 @@
 package channels
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/sipeed/picoclaw/pkg/access"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 )
@@ -263,3 +266,48 @@ func TestIsAllowedSender(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleMessage_AccessControl(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ac := access.NewController(config.AccessConfig{Enabled: true}, t.TempDir())
+	code := ac.PairingCode()
+	ch := NewBaseChannel("telegram", nil, messageBus, nil)
+	ch.SetAccessController(ac)
+	ctx := context.Background()
+	alice := bus.SenderInfo{Platform: "telegram", PlatformID: "1", CanonicalID: "telegram:1"}
+
+	// Strangers are ignored until they pair.
+	ch.HandleMessage(ctx, bus.Peer{Kind: "direct", ID: "1"}, "m1", "1", "1", "hello", nil, nil, alice)
+	ch.HandleMessage(ctx, bus.Peer{Kind: "direct", ID: "1"}, "m2", "1", "1", "/pair@picobot "+code, nil, nil, alice)
+	out := consumeOutbound(t, messageBus)
+	if out.ChatID != "1" || out.Content != "Paired. You are now the owner of this bot." {
+		t.Errorf("pair reply = %+v", out)
+	}
+
+	ch.HandleMessage(ctx, bus.Peer{Kind: "direct", ID: "1"}, "m3", "1", "1", "hello", nil, nil, alice)
+	if msg := consumeInbound(t, messageBus); msg.MessageID != "m3" || msg.Role != access.RoleOwner {
+		t.Errorf("inbound = %+v", msg)
+	}
+}
+
+func consumeInbound(t *testing.T, messageBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := messageBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func consumeOutbound(t *testing.T, messageBus *bus.MessageBus) bus.OutboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := messageBus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("no outbound message")
+	}
+	return msg
+}
@@ -0,0 +1,556 @@
+// Package email implements a channel that talks to the agent by email. It
+// polls a mailbox over IMAP, so nothing needs to be exposed to the internet,
+// and answers over SMTP in the same thread.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	imapclient "github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset" // decode non-UTF-8 messages
+	gomail "github.com/emersion/go-message/mail"
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	emailTimeout        = 30 * time.Second
+	defaultPollInterval = 60 * time.Second
+	maxMessagesPerPoll  = 20
+	maxBodyRunes        = 8000
+	maxPartBytes        = 1 << 20
+)
+
+// thread is what Send needs to answer in an email thread.
+type thread struct {
+	replyTo    string   // address replies go to
+	subject    string   // subject of the latest message
+	lastID     string   // Message-ID of the latest message, for In-Reply-To
+	references []string // the thread's Message-IDs, oldest first
+}
+
+// EmailChannel converses over email. Each thread is a conversation: its
+// chat ID is derived from the Message-ID of the thread's first email, so
+// replies to any message of the thread land in the same session.
+type EmailChannel struct {
+	*channels.BaseChannel
+	config    config.EmailConfig
+	interval  time.Duration
+	tlsConfig *tls.Config // nil uses the system roots; set by tests
+
+	mu      sync.Mutex
+	threads map[string]*thread // chat ID -> thread
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewEmailChannel creates a new email channel.
+func NewEmailChannel(cfg config.EmailConfig, messageBus *bus.MessageBus) (*EmailChannel, error) {
+	if cfg.Address == "" || cfg.Password == "" {
+		return nil, errors.New("email address and password are required")
+	}
+	if cfg.IMAPHost == "" || cfg.SMTPHost == "" {
+		return nil, errors.New("email imap_host and smtp_host are required")
+	}
+	if cfg.Folder == "" {
+		cfg.Folder = "INBOX"
+	}
+	interval := time.Duration(cfg.PollIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	// Addresses are compared in lower case.
+	allowFrom := make([]string, 0, len(cfg.AllowFrom))
+	for _, a := range cfg.AllowFrom {
+		allowFrom = append(allowFrom, strings.ToLower(strings.TrimSpace(a)))
+	}
+	base := channels.NewBaseChannel("email", cfg, messageBus, allowFrom,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &EmailChannel{
+		BaseChannel: base,
+		config:      cfg,
+		interval:    interval,
+		threads:     make(map[string]*thread),
+	}, nil
+}
+
+// Start begins polling the mailbox.
+func (c *EmailChannel) Start(ctx context.Context) error {
+	logger.InfoCF("email", "Starting email channel", map[string]any{
+		"address": c.config.Address,
+		"folder":  c.config.Folder,
+	})
+	if len(c.config.AllowFrom) == 0 {
+		logger.WarnC("email", "allow_from is empty: anyone who can email "+c.config.Address+" can talk to the agent")
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.SetRunning(true)
+	go c.run()
+	return nil
+}
+
+// Stop stops polling.
+func (c *EmailChannel) Stop(ctx context.Context) error {
+	logger.InfoC("email", "Stopping email channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+	return nil
+}
+
+func (c *EmailChannel) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.poll(c.ctx); err != nil && c.ctx.Err() == nil {
+			logger.WarnCF("email", "Mailbox poll failed", map[string]any{"error": err.Error()})
+		}
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// poll hands every unread message in the folder to the agent and marks it
+// as read, including messages that are skipped, so none is handled twice.
+func (c *EmailChannel) poll(ctx context.Context) error {
+	cl, err := c.connect(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", c.config.IMAPHost, err)
+	}
+	defer cl.Logout()
+
+	if _, err := cl.Select(c.config.Folder, false); err != nil {
+		return fmt.Errorf("open %s: %w", c.config.Folder, err)
+	}
+	criteria := imap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{imap.SeenFlag}
+	uids, err := cl.UidSearch(criteria)
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+	if len(uids) > maxMessagesPerPoll {
+		uids = uids[:maxMessagesPerPoll] // oldest first; the rest wait for the next poll
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, len(uids))
+	if err := cl.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages); err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	for m := range messages {
+		if body := m.GetBody(section); body != nil {
+			c.handleMessage(ctx, body)
+		}
+	}
+
+	seen := imap.FormatFlagsOp(imap.AddFlags, true)
+	if err := cl.UidStore(seqset, seen, []any{imap.SeenFlag}, nil); err != nil {
+		return fmt.Errorf("mark as read: %w", err)
+	}
+	return nil
+}
+
+func (c *EmailChannel) connect(ctx context.Context) (*imapclient.Client, error) {
+	port := c.config.IMAPPort
+	if port == 0 {
+		port = 993
+	}
+	addr := net.JoinHostPort(c.config.IMAPHost, strconv.Itoa(port))
+	cl, err := imapclient.DialWithDialerTLS(&net.Dialer{Timeout: emailTimeout}, addr, c.tlsFor(c.config.IMAPHost))
+	if err != nil {
+		return nil, err
+	}
+	cl.Timeout = emailTimeout
+	// The client has no context support; close the connection when the
+	// channel stops so blocked commands return.
+	stop := context.AfterFunc(ctx, func() { cl.Terminate() })
+	go func() {
+		<-cl.LoggedOut()
+		stop()
+	}()
+
+	if err := cl.Login(c.username(), c.config.Password); err != nil {
+		cl.Logout()
+		return nil, err
+	}
+	return cl, nil
+}
+
+func (c *EmailChannel) username() string {
+	if c.config.Username != "" {
+		return c.config.Username
+	}
+	return c.config.Address
+}
+
+func (c *EmailChannel) tlsFor(host string) *tls.Config {
+	if c.tlsConfig != nil {
+		cfg := c.tlsConfig.Clone()
+		cfg.ServerName = host
+		return cfg
+	}
+	return &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+}
+
+// handleMessage parses one email and publishes it in its thread's
+// conversation. Mail from this address and automatic mail (vacation
+// replies, bounces, mailing lists) is skipped so two bots cannot loop.
+func (c *EmailChannel) handleMessage(ctx context.Context, r io.Reader) {
+	mr, err := gomail.CreateReader(r)
+	if err != nil {
+		logger.WarnCF("email", "Failed to parse message", map[string]any{"error": err.Error()})
+		return
+	}
+	h := mr.Header
+
+	from, err := h.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return
+	}
+	sender := from[0]
+	address := strings.ToLower(sender.Address)
+	if strings.EqualFold(address, c.config.Address) || isAutomated(h) {
+		logger.DebugCF("email", "Skipping own or automatic message", map[string]any{"from": address})
+		return
+	}
+	senderInfo := bus.SenderInfo{
+		Platform:    "email",
+		PlatformID:  address,
+		CanonicalID: identity.BuildCanonicalID("email", address),
+		Username:    address,
+		DisplayName: sender.Name,
+	}
+	if !c.IsAllowedSender(senderInfo) {
+		logger.DebugCF("email", "Message rejected by allowlist", map[string]any{"from": address})
+		return
+	}
+
+	text, err := messageText(mr)
+	if err != nil {
+		logger.WarnCF("email", "Failed to read message body", map[string]any{"from": address, "error": err.Error()})
+		return
+	}
+	subject, _ := h.Subject()
+	messageID, _ := h.MessageID()
+	references, _ := h.MsgIDList("References")
+	inReplyTo, _ := h.MsgIDList("In-Reply-To")
+
+	content := text
+	if len(references) == 0 && len(inReplyTo) == 0 && subject != "" {
+		content = "Subject: " + subject + "\n\n" + text
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	replyTo := address
+	if list, err := h.AddressList("Reply-To"); err == nil && len(list) > 0 {
+		replyTo = list[0].Address
+	}
+	// The thread is named after its first message: the first reference,
+	// else the message answered, else this message itself.
+	root := messageID
+	switch {
+	case len(references) > 0:
+		root = references[0]
+	case len(inReplyTo) > 0:
+		root = inReplyTo[0]
+	}
+	if root == "" {
+		root = uuid.NewString()
+	}
+	chatID := threadChatID(root)
+
+	refs := references
+	if len(refs) == 0 {
+		refs = inReplyTo
+	}
+	if messageID != "" {
+		refs = append(refs, messageID)
+	}
+	c.mu.Lock()
+	c.threads[chatID] = &thread{replyTo: replyTo, subject: subject, lastID: messageID, references: refs}
+	c.mu.Unlock()
+
+	if messageID == "" {
+		messageID = uuid.NewString()
+	}
+	metadata := map[string]string{
+		"platform": "email",
+		"subject":  subject,
+	}
+	peer := bus.Peer{Kind: "direct", ID: chatID}
+	c.HandleMessage(ctx, peer, messageID, address, chatID, content, nil, metadata, senderInfo)
+}
+
+// threadChatID hashes a thread's root Message-ID, which may contain
+// characters that are not safe in a session file name.
+func threadChatID(root string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(root)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// isAutomated reports whether a message was sent by a program rather than a
+// person (RFC 3834 Auto-Submitted, list and bulk mail).
+func isAutomated(h gomail.Header) bool {
+	if v := strings.ToLower(h.Get("Auto-Submitted")); v != "" && v != "no" {
+		return true
+	}
+	switch strings.ToLower(h.Get("Precedence")) {
+	case "bulk", "list", "junk":
+		return true
+	}
+	return h.Get("List-Id") != ""
+}
+
+var htmlTagRe = regexp.MustCompile(`(?s)<style.*?</style>|<script.*?</script>|<[^>]+>`)
+
+// messageText returns the new text of a message: the plain-text part (or
+// the HTML part with the tags stripped) without the quoted reply history,
+// which the session already has.
+func messageText(mr *gomail.Reader) (string, error) {
+	var plain, html string
+	var attachments []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch h := part.Header.(type) {
+		case *gomail.InlineHeader:
+			ct, _, _ := h.ContentType()
+			data, _ := io.ReadAll(io.LimitReader(part.Body, maxPartBytes))
+			switch {
+			case (ct == "text/plain" || ct == "") && plain == "":
+				plain = string(data)
+			case ct == "text/html" && html == "":
+				html = string(data)
+			}
+		case *gomail.AttachmentHeader:
+			name, _ := h.Filename()
+			attachments = append(attachments, name)
+		}
+	}
+
+	text := plain
+	if strings.TrimSpace(text) == "" && html != "" {
+		text = htmlTagRe.ReplaceAllString(html, " ")
+	}
+	text = utils.Truncate(strings.TrimSpace(stripQuoted(text)), maxBodyRunes)
+	if len(attachments) > 0 {
+		text += "\n\n(Attachments not included: " + strings.Join(attachments, ", ") + ")"
+	}
+	return text, nil
+}
+
+// stripQuoted removes quoted lines ("> ...") and the "On ..., X wrote:"
+// line that introduces them.
+func stripQuoted(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		if strings.HasSuffix(trimmed, "wrote:") && quoteFollows(lines[i+1:]) {
+			continue
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func quoteFollows(lines []string) bool {
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			return strings.HasPrefix(trimmed, ">")
+		}
+	}
+	return false
+}
+
+// Send answers in the thread msg.ChatID names. Threads are remembered from
+// the messages received since the gateway started.
+func (c *EmailChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	c.mu.Lock()
+	t, ok := c.threads[msg.ChatID]
+	var reply outgoing
+	if ok {
+		reply = outgoing{
+			from:       c.config.Address,
+			to:         t.replyTo,
+			subject:    replySubject(t.subject),
+			body:       msg.Content,
+			inReplyTo:  t.lastID,
+			references: append([]string(nil), t.references...),
+		}
+	}
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("email: unknown thread %q: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+
+	if err := c.send(ctx, reply); err != nil {
+		return fmt.Errorf("email send: %v: %w", err, channels.ErrTemporary)
+	}
+	return nil
+}
+
+func replySubject(subject string) string {
+	if subject == "" {
+		return "Re: your message"
+	}
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// outgoing is a reply ready to be sent.
+type outgoing struct {
+	from, to   string
+	subject    string
+	body       string
+	inReplyTo  string
+	references []string
+}
+
+// render formats the reply as RFC 5322 text with a quoted-printable body.
+// It is marked Auto-Submitted so other auto-responders do not answer it.
+func (m outgoing) render(now time.Time) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+
+	header("From", m.from)
+	header("To", m.to)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.subject))
+	header("Date", now.Format(time.RFC1123Z))
+	domain := "localhost"
+	if i := strings.LastIndex(m.from, "@"); i >= 0 {
+		domain = m.from[i+1:]
+	}
+	header("Message-ID", fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), uuid.NewString()[:8], domain))
+	if m.inReplyTo != "" {
+		header("In-Reply-To", "<"+m.inReplyTo+">")
+	}
+	if len(m.references) > 0 {
+		header("References", "<"+strings.Join(m.references, "> <")+">")
+	}
+	header("Auto-Submitted", "auto-replied")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(m.body, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
+
+func (c *EmailChannel) send(ctx context.Context, m outgoing) error {
+	port := c.config.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	host := c.config.SMTPHost
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	var conn net.Conn
+	var err error
+	if port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tlsFor(host)}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(2 * emailTimeout))
+
+	sc, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer sc.Close()
+
+	if port != 465 {
+		if ok, _ := sc.Extension("STARTTLS"); ok {
+			if err := sc.StartTLS(c.tlsFor(host)); err != nil {
+				return err
+			}
+		}
+	}
+	// PlainAuth refuses to send credentials without TLS, except to localhost.
+	if err := sc.Auth(smtp.PlainAuth("", c.username(), c.config.Password, host)); err != nil {
+		return err
+	}
+	if err := sc.Mail(m.from); err != nil {
+		return err
+	}
+	if err := sc.Rcpt(m.to); err != nil {
+		return fmt.Errorf("recipient %s: %w", m.to, err)
+	}
+	w, err := sc.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.render(time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return sc.Quit()
+}
@@ -0,0 +1,78 @@
+package channels
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Reactions that rate an answer. Others are not feedback.
+var (
+	goodReactions = []string{
+		"👍", "❤", "🔥", "👏", "💯", "👌", "🏆",
+		"+1", "thumbsup", "heart", "fire", "clap", "100", "ok_hand",
+	}
+	badReactions = []string{"👎", "💩", "🤮", "-1", "thumbsdown", "poop", "hankey"}
+)
+
+// ReactionRating returns the rating a reaction gives an answer: 1 for a
+// thumbs up or the like, -1 for a thumbs down, 0 for the others. Emoji and
+// Slack's names for them are understood.
+func ReactionRating(reaction string) int {
+	// Drop skin tones and the variation selector: 👍🏽 and ❤️ count as 👍 and ❤.
+	reaction = strings.Map(func(r rune) rune {
+		if r == '\uFE0F' || r >= 0x1F3FB && r <= 0x1F3FF {
+			return -1
+		}
+		return r
+	}, strings.Trim(reaction, ":"))
+	reaction, _, _ = strings.Cut(reaction, "::") // Slack's "+1::skin-tone-2"
+	switch {
+	case slices.Contains(goodReactions, reaction):
+		return 1
+	case slices.Contains(badReactions, reaction):
+		return -1
+	}
+	return 0
+}
+
+// HandleFeedback passes a user's feedback on an answer of the bot to the
+// agent, for the session of the chat the answer was sent in. Feedback is
+// admitted like a message: senders who may not talk to the bot are ignored,
+// and reactions count against the per-user rate limit.
+func (c *BaseChannel) HandleFeedback(
+	ctx context.Context,
+	peer bus.Peer,
+	senderID, chatID string,
+	sender bus.SenderInfo,
+	feedback bus.Feedback,
+) {
+	role, ok := c.admit(ctx, sender, senderID, chatID)
+	if !ok {
+		return
+	}
+
+	resolvedSenderID := senderID
+	if sender.CanonicalID != "" {
+		resolvedSenderID = sender.CanonicalID
+	}
+	msg := bus.InboundMessage{
+		Channel:  c.name,
+		SenderID: resolvedSenderID,
+		Sender:   sender,
+		Role:     role,
+		ChatID:   chatID,
+		Peer:     peer,
+		Feedback: &feedback,
+	}
+	if err := c.bus.PublishInbound(ctx, msg); err != nil {
+		logger.ErrorCF("channels", "Failed to publish feedback", map[string]any{
+			"channel": c.name,
+			"chat_id": chatID,
+			"error":   err.Error(),
+		})
+	}
+}
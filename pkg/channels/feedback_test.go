@@ -0,0 +1,41 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestReactionRating(t *testing.T) {
+	for reaction, want := range map[string]int{
+		"👍":                     1,
+		"👍🏽":                    1,
+		"❤️":                    1,
+		":+1:":                  1,
+		"thumbsup::skin-tone-3": 1,
+		"👎":                     -1,
+		"thumbsdown":            -1,
+		"🤔":                     0,
+		"eyes":                  0,
+	} {
+		if got := ReactionRating(reaction); got != want {
+			t.Errorf("ReactionRating(%q) = %d, want %d", reaction, got, want)
+		}
+	}
+}
+
+func TestHandleFeedback(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ch := NewBaseChannel("telegram", nil, messageBus, []string{"telegram:1"})
+	ctx := context.Background()
+	alice := bus.SenderInfo{Platform: "telegram", PlatformID: "1", CanonicalID: "telegram:1"}
+	mallory := bus.SenderInfo{Platform: "telegram", PlatformID: "2", CanonicalID: "telegram:2"}
+
+	ch.HandleFeedback(ctx, bus.Peer{Kind: "direct", ID: "2"}, "2", "2", mallory, bus.Feedback{Rating: -1})
+	ch.HandleFeedback(ctx, bus.Peer{Kind: "direct", ID: "1"}, "1", "1", alice, bus.Feedback{Rating: 1, Answer: "Hi"})
+	msg := consumeInbound(t, messageBus)
+	if msg.SenderID != "telegram:1" || msg.Feedback == nil || msg.Feedback.Rating != 1 || msg.Content != "" {
+		t.Errorf("inbound = %+v", msg)
+	}
+}
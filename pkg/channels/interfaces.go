@@ -3,6 +3,7 @@ package channels
 import (
 	"context"
 
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/commands"
 )
 
@@ -35,6 +36,25 @@ type PlaceholderCapable interface {
 	SendPlaceholder(ctx context.Context, chatID string) (messageID string, err error)
 }
 
+// StreamingCapable — channels that can show a reply while it is generated.
+// Manager calls BeginStream when the first text of a reply arrives;
+// messageID is the chat's placeholder, which the stream should take over,
+// or "" if there is none. Returning a nil streamer declines, and the reply
+// is sent as usual. The streamer is used from one goroutine and must split
+// replies that outgrow the platform's message length.
+type StreamingCapable interface {
+	BeginStream(ctx context.Context, chatID, messageID string) (bus.Streamer, error)
+}
+
+// ButtonCapable — channels that can show OutboundMessage.Buttons as buttons
+// the user can tap, sending the button's text back as the user's message.
+// Manager uses SendButtons instead of Send for messages with buttons,
+// passing the chat's placeholder (or "") for the message to replace. For
+// other channels Manager lists the buttons at the end of the text.
+type ButtonCapable interface {
+	SendButtons(ctx context.Context, msg bus.OutboundMessage, placeholderID string) error
+}
+
 // PlaceholderRecorder is injected into channels by Manager.
 // Channels call these methods on inbound to register typing/placeholder state.
 // Manager uses the registered state on outbound to stop typing and edit placeholders.
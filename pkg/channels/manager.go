@@ -8,21 +8,25 @@ package channels
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"net"
 	"net/http"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 
+	"github.com/sipeed/picoclaw/pkg/access"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/health"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/outbox"
 )
 
 const (
@@ -33,6 +37,9 @@ const (
 	baseBackoff             = 500 * time.Millisecond
 	maxBackoff              = 8 * time.Second
 
+	// outboxKind is the kind of the messages queued in the outbox.
+	outboxKind = "message"
+
 	janitorInterval = 10 * time.Second
 	typingStopTTL   = 5 * time.Minute
 	placeholderTTL  = 10 * time.Minute
@@ -80,9 +87,14 @@ type Manager struct {
 	bus           *bus.MessageBus
 	config        *config.Config
 	mediaStore    media.MediaStore
+	rateLimiter   *UserRateLimiter // nil when per-user rate limiting is off
+	outbox        *outbox.Queue    // nil when messages are sent directly
+	outboxDone    chan struct{}
 	dispatchTask  *asyncTask
 	mux           *http.ServeMux
 	httpServer    *http.Server
+	httpListener  net.Listener // nil to listen on the address of httpServer
+	extraHandlers bool         // Handle was called; keeps the HTTP server useful without channels
 	mu            sync.RWMutex
 	placeholders  sync.Map // "channel:chatID" → placeholderID (string)
 	typingStops   sync.Map // "channel:chatID" → func()
@@ -119,19 +131,8 @@ func (m *Manager) RecordReactionUndo(channel, chatID string, undo func()) {
 func (m *Manager) preSend(ctx context.Context, name string, msg bus.OutboundMessage, ch Channel) bool {
 	key := name + ":" + msg.ChatID
 
-	// 1. Stop typing
-	if v, loaded := m.typingStops.LoadAndDelete(key); loaded {
-		if entry, ok := v.(typingEntry); ok {
-			entry.stop() // idempotent, safe
-		}
-	}
-
-	// 2. Undo reaction
-	if v, loaded := m.reactionUndos.LoadAndDelete(key); loaded {
-		if entry, ok := v.(reactionEntry); ok {
-			entry.undo() // idempotent, safe
-		}
-	}
+	// 1. Stop typing, 2. undo reaction
+	m.stopIndicators(key)
 
 	// 3. Try editing placeholder
 	if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
@@ -156,14 +157,46 @@ func NewManager(cfg *config.Config, messageBus *bus.MessageBus, store media.Medi
 		config:     cfg,
 		mediaStore: store,
 	}
+	if cfg.Channels.RateLimit.Enabled {
+		m.rateLimiter = NewUserRateLimiter(cfg.Channels.RateLimit)
+	}
+	if cfg.Outbox.Enabled {
+		q, err := outbox.Open(cfg.OutboxDir(), outbox.Options{
+			RateLimit: cfg.Outbox.RateLimit,
+			MaxAge:    time.Duration(cfg.Outbox.MaxAgeHours) * time.Hour,
+		})
+		if err != nil {
+			logger.ErrorCF("channels", "Outbox disabled", map[string]any{"error": err.Error()})
+		} else {
+			q.Handle(outboxKind, m.deliverQueued)
+			m.outbox = q
+		}
+	}
 
 	if err := m.initChannels(); err != nil {
 		return nil, err
 	}
+	messageBus.SetStreamDelegate(m)
 
 	return m, nil
 }
 
+// Outbox returns the outbox, or nil when it is disabled.
+func (m *Manager) Outbox() *outbox.Queue {
+	return m.outbox
+}
+
+// SetAccessController makes every channel enforce access control with ac.
+func (m *Manager) SetAccessController(ac *access.Controller) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, ch := range m.channels {
+		if setter, ok := ch.(interface{ SetAccessController(ac *access.Controller) }); ok {
+			setter.SetAccessController(ac)
+		}
+	}
+}
+
 // initChannel is a helper that looks up a factory by name and creates the channel.
 func (m *Manager) initChannel(name, displayName string) {
 	f, ok := getFactory(name)
@@ -193,6 +226,12 @@ func (m *Manager) initChannel(name, displayName string) {
 		if setter, ok := ch.(interface{ SetPlaceholderRecorder(r PlaceholderRecorder) }); ok {
 			setter.SetPlaceholderRecorder(m)
 		}
+		// Inject the per-user rate limiter if enabled
+		if m.rateLimiter != nil {
+			if setter, ok := ch.(interface{ SetRateLimiter(l *UserRateLimiter) }); ok {
+				setter.SetRateLimiter(m.rateLimiter)
+			}
+		}
 		// Inject owner reference so BaseChannel.HandleMessage can auto-trigger typing/reaction
 		if setter, ok := ch.(interface{ SetOwner(ch Channel) }); ok {
 			setter.SetOwner(ch)
@@ -272,6 +311,34 @@ func (m *Manager) initChannels() error {
 		m.initChannel("irc", "IRC")
 	}
 
+	if m.config.Channels.MQTT.Enabled && m.config.Channels.MQTT.Broker != "" {
+		m.initChannel("mqtt", "MQTT")
+	}
+
+	if m.config.Channels.Matrix.Enabled && m.config.Channels.Matrix.Homeserver != "" {
+		m.initChannel("matrix", "Matrix")
+	}
+
+	if m.config.Channels.Webhook.Enabled && len(m.config.Channels.Webhook.Hooks) > 0 {
+		m.initChannel("webhook", "Webhook")
+	}
+
+	if m.config.Channels.Email.Enabled && m.config.Channels.Email.IMAPHost != "" {
+		m.initChannel("email", "Email")
+	}
+
+	if m.config.Channels.Voice.Enabled {
+		m.initChannel("voice", "Voice")
+	}
+
+	if m.config.Channels.Serial.Enabled && m.config.Channels.Serial.Device != "" {
+		m.initChannel("serial", "Serial")
+	}
+
+	if m.config.Channels.Queue.Enabled && m.config.Channels.Queue.URL != "" {
+		m.initChannel("queue", "Queue")
+	}
+
 	logger.InfoCF("channels", "Channel initialization completed", map[string]any{
 		"enabled_channels": len(m.channels),
 	})
@@ -316,11 +383,34 @@ func (m *Manager) SetupHTTPServer(addr string, healthServer *health.Server) {
 	}
 }
 
+// SetListener makes the shared HTTP server serve l, such as a socket passed
+// by systemd, instead of listening on its address. It must be called before
+// StartAll.
+func (m *Manager) SetListener(l net.Listener) {
+	m.httpListener = l
+}
+
+// Handle registers an additional handler, such as the REST API, on the
+// shared HTTP server. It must be called after SetupHTTPServer and before
+// StartAll.
+func (m *Manager) Handle(pattern string, handler http.Handler) {
+	if m.mux == nil {
+		return
+	}
+	m.mux.Handle(pattern, handler)
+	m.extraHandlers = true
+	logger.InfoCF("channels", "HTTP handler registered", map[string]any{
+		"path": pattern,
+	})
+}
+
 func (m *Manager) StartAll(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if len(m.channels) == 0 {
+	// Without channels the shared HTTP server is still worth running when
+	// it serves other handlers.
+	if len(m.channels) == 0 && !m.extraHandlers {
 		logger.WarnC("channels", "No channels enabled")
 		return errors.New("no channels enabled")
 	}
@@ -355,13 +445,33 @@ func (m *Manager) StartAll(ctx context.Context) error {
 	// Start the TTL janitor that cleans up stale typing/placeholder entries
 	go m.runTTLJanitor(dispatchCtx)
 
+	if m.outbox != nil {
+		m.outboxDone = make(chan struct{})
+		go func(done chan struct{}) {
+			defer close(done)
+			m.outbox.Run(dispatchCtx)
+		}(m.outboxDone)
+	}
+
 	// Start shared HTTP server if configured
-	if m.httpServer != nil {
+	if srv := m.httpServer; srv != nil {
+		// StopAll clears m.httpServer, possibly before this goroutine runs.
+		l := m.httpListener
 		go func() {
+			addr := srv.Addr
+			if l != nil {
+				addr = l.Addr().String()
+			}
 			logger.InfoCF("channels", "Shared HTTP server listening", map[string]any{
-				"addr": m.httpServer.Addr,
+				"addr": addr,
 			})
-			if err := m.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			var err error
+			if l != nil {
+				err = srv.Serve(l)
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
 				logger.ErrorCF("channels", "Shared HTTP server error", map[string]any{
 					"error": err.Error(),
 				})
@@ -374,6 +484,8 @@ func (m *Manager) StartAll(ctx context.Context) error {
 }
 
 func (m *Manager) StopAll(ctx context.Context) error {
+	m.stopOutbox(ctx)
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -437,6 +549,41 @@ func (m *Manager) StopAll(ctx context.Context) error {
 	return nil
 }
 
+// stopOutbox stops the dispatchers and waits for the outbox deliveries in
+// progress, which need the workers and the lock. What was not delivered
+// stays in the outbox for the next start.
+func (m *Manager) stopOutbox(ctx context.Context) {
+	m.mu.Lock()
+	if m.dispatchTask != nil {
+		m.dispatchTask.cancel()
+		m.dispatchTask = nil
+	}
+	done := m.outboxDone
+	m.outboxDone = nil
+	m.mu.Unlock()
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// stopIndicators stops the typing indicator and undoes the reaction
+// recorded for key ("channel:chatID").
+func (m *Manager) stopIndicators(key string) {
+	if v, loaded := m.typingStops.LoadAndDelete(key); loaded {
+		if entry, ok := v.(typingEntry); ok {
+			entry.stop() // idempotent, safe
+		}
+	}
+	if v, loaded := m.reactionUndos.LoadAndDelete(key); loaded {
+		if entry, ok := v.(reactionEntry); ok {
+			entry.undo() // idempotent, safe
+		}
+	}
+}
+
 // newChannelWorker creates a channelWorker with a rate limiter configured
 // for the given channel name.
 func newChannelWorker(name string, ch Channel) *channelWorker {
@@ -466,19 +613,26 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 			if !ok {
 				return
 			}
+			if _, ok := w.ch.(ButtonCapable); !ok && len(msg.Buttons) > 0 {
+				msg.Content = buttonsAsText(msg.Content, msg.Buttons)
+				msg.Buttons = nil
+			}
 			maxLen := 0
 			if mlp, ok := w.ch.(MessageLengthProvider); ok {
 				maxLen = mlp.MaxMessageLength()
 			}
 			if maxLen > 0 && len([]rune(msg.Content)) > maxLen {
 				chunks := SplitMessage(msg.Content, maxLen)
-				for _, chunk := range chunks {
+				for i, chunk := range chunks {
 					chunkMsg := msg
 					chunkMsg.Content = chunk
-					m.sendWithRetry(ctx, name, w, chunkMsg)
+					if i < len(chunks)-1 {
+						chunkMsg.Buttons = nil // buttons go under the last part
+					}
+					m.send(ctx, name, w, chunkMsg)
 				}
 			} else {
-				m.sendWithRetry(ctx, name, w, msg)
+				m.send(ctx, name, w, msg)
 			}
 		case <-ctx.Done():
 			return
@@ -486,6 +640,80 @@ func (m *Manager) runWorker(ctx context.Context, name string, w *channelWorker)
 	}
 }
 
+// send queues msg in the outbox, or sends it right away when there is none
+// or it cannot take the message.
+func (m *Manager) send(ctx context.Context, name string, w *channelWorker, msg bus.OutboundMessage) {
+	if m.outbox != nil {
+		_, err := m.outbox.Enqueue(outboxKind, name+":"+msg.ChatID, "", msg)
+		if err == nil {
+			return
+		}
+		logger.WarnCF("channels", "Outbox unavailable, sending directly", map[string]any{
+			"channel": name,
+			"error":   err.Error(),
+		})
+	}
+	m.sendWithRetry(ctx, name, w, msg)
+}
+
+// deliverQueued sends a message from the outbox, once; the outbox retries
+// it. Unknown channels and ErrSendFailed are permanent failures.
+func (m *Manager) deliverQueued(ctx context.Context, e outbox.Entry) error {
+	var msg bus.OutboundMessage
+	if err := json.Unmarshal(e.Payload, &msg); err != nil {
+		return outbox.Permanent(fmt.Errorf("invalid message: %w", err))
+	}
+	m.mu.RLock()
+	_, exists := m.channels[msg.Channel]
+	w := m.workers[msg.Channel]
+	m.mu.RUnlock()
+	if !exists {
+		return outbox.Permanent(fmt.Errorf("channel %s not found", msg.Channel))
+	}
+	if w == nil {
+		return fmt.Errorf("channel %s is not running: %w", msg.Channel, ErrNotRunning)
+	}
+	if err := w.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	send, edited := m.prepareSend(ctx, msg.Channel, w, msg)
+	if edited {
+		return nil
+	}
+	err := send(ctx, msg)
+	if errors.Is(err, ErrSendFailed) {
+		return outbox.Permanent(err)
+	}
+	return err
+}
+
+// prepareSend stops the chat's indicators and returns the function that
+// sends msg, or reports true when msg was edited into the chat's
+// placeholder instead.
+func (m *Manager) prepareSend(
+	ctx context.Context, name string, w *channelWorker, msg bus.OutboundMessage,
+) (func(context.Context, bus.OutboundMessage) error, bool) {
+	if bc, ok := w.ch.(ButtonCapable); ok && len(msg.Buttons) > 0 {
+		// The channel replaces the placeholder itself, keeping the buttons.
+		key := name + ":" + msg.ChatID
+		m.stopIndicators(key)
+		placeholderID := ""
+		if v, loaded := m.placeholders.LoadAndDelete(key); loaded {
+			if entry, ok := v.(placeholderEntry); ok {
+				placeholderID = entry.id
+			}
+		}
+		return func(ctx context.Context, msg bus.OutboundMessage) error {
+			return bc.SendButtons(ctx, msg, placeholderID)
+		}, false
+	}
+	// Pre-send: stop typing and try to edit placeholder
+	if m.preSend(ctx, name, msg, w.ch) {
+		return nil, true
+	}
+	return w.ch.Send, false
+}
+
 // sendWithRetry sends a message through the channel with rate limiting and
 // retry logic. It classifies errors to determine the retry strategy:
 //   - ErrNotRunning / ErrSendFailed: permanent, no retry
@@ -498,14 +726,14 @@ func (m *Manager) sendWithRetry(ctx context.Context, name string, w *channelWork
 		return
 	}
 
-	// Pre-send: stop typing and try to edit placeholder
-	if m.preSend(ctx, name, msg, w.ch) {
+	send, edited := m.prepareSend(ctx, name, w, msg)
+	if edited {
 		return // placeholder was edited successfully, skip Send
 	}
 
 	var lastErr error
 	for attempt := 0; attempt <= maxRetries; attempt++ {
-		lastErr = w.ch.Send(ctx, msg)
+		lastErr = send(ctx, msg)
 		if lastErr == nil {
 			return
 		}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"golang.org/x/time/rate"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/outbox"
 )
 
 // mockChannel is a test double that delegates Send to a configurable function.
@@ -860,3 +862,84 @@ func TestBuildMediaScope_WithMessageID(t *testing.T) {
 		t.Fatalf("expected %s, got %s", expected, scope)
 	}
 }
+
+func TestStartAll_ExtraHandlerWithoutChannels(t *testing.T) {
+	m := newTestManager()
+	m.bus = bus.NewMessageBus()
+	defer m.bus.Close()
+
+	if err := m.StartAll(context.Background()); err == nil {
+		t.Fatal("StartAll() without channels or handlers should fail")
+	}
+
+	m.SetupHTTPServer("127.0.0.1:0", nil)
+	m.Handle("/api/", http.NotFoundHandler())
+	if err := m.StartAll(context.Background()); err != nil {
+		t.Fatalf("StartAll() with an extra handler: %v", err)
+	}
+	m.StopAll(context.Background())
+}
+
+func TestManager_Outbox(t *testing.T) {
+	q, err := outbox.Open(t.TempDir(), outbox.Options{BaseBackoff: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestManager()
+	m.bus = bus.NewMessageBus()
+	defer m.bus.Close()
+	m.outbox = q
+	q.Handle(outboxKind, m.deliverQueued)
+
+	var mu sync.Mutex
+	var sent []string
+	failures := 2
+	m.RegisterChannel("test", &mockChannel{
+		sendFn: func(_ context.Context, msg bus.OutboundMessage) error {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case msg.ChatID == "gone":
+				return ErrSendFailed
+			case failures > 0:
+				failures--
+				return ErrTemporary
+			}
+			sent = append(sent, msg.Content)
+			return nil
+		},
+	})
+	if err := m.StartAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer m.StopAll(context.Background())
+
+	for _, msg := range []bus.OutboundMessage{
+		{Channel: "test", ChatID: "1", Content: "first"},
+		{Channel: "test", ChatID: "gone", Content: "lost"},
+		{Channel: "test", ChatID: "1", Content: "second"},
+		{Channel: "test", ChatID: "1", Content: "second"}, // pending duplicate
+		{Channel: "test", ChatID: "1", Content: "third"},
+	} {
+		m.bus.PublishOutbound(context.Background(), msg)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n >= 3 && q.Len() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sent %d messages, %d still queued", n, q.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(sent) != "[first second third]" {
+		t.Errorf("sent = %q, want first, second and third in order", sent)
+	}
+}
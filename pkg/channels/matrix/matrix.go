@@ -0,0 +1,720 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	clientAPI = "/_matrix/client/v3"
+
+	syncTimeout   = 30 * time.Second
+	retryDelay    = 5 * time.Second
+	typingTimeout = 30 * time.Second
+
+	// Matrix events are limited to 64 KiB of JSON.
+	maxMessageLength = 16000
+
+	// initialSyncFilter skips room history on the first sync, so the bot
+	// only answers messages sent after it started.
+	initialSyncFilter = `{"room":{"timeline":{"limit":1}}}`
+)
+
+// MatrixChannel connects to a Matrix homeserver as a regular user through
+// the client-server API. Each joined room is a conversation: rooms with two
+// members are direct chats, larger rooms are groups. Encrypted rooms are
+// not supported; their messages are skipped.
+type MatrixChannel struct {
+	*channels.BaseChannel
+	config     config.MatrixConfig
+	homeserver string
+	client     *http.Client
+
+	token       string
+	userID      string
+	displayName string
+	txnPrefix   string
+	txnSeq      atomic.Int64
+
+	mu        sync.Mutex
+	members   map[string]int  // room ID -> joined member count
+	encrypted map[string]bool // rooms already warned about
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewMatrixChannel creates a new Matrix channel.
+func NewMatrixChannel(cfg config.MatrixConfig, messageBus *bus.MessageBus) (*MatrixChannel, error) {
+	if cfg.Homeserver == "" {
+		return nil, errors.New("matrix homeserver is required")
+	}
+	if cfg.AccessToken == "" && (cfg.UserID == "" || cfg.Password == "") {
+		return nil, errors.New("matrix needs an access_token, or a user_id and password to log in")
+	}
+
+	// A bare user ID like "@alice:example.org" would read as the canonical
+	// form "platform:id", so qualify it with the platform.
+	allowFrom := make([]string, len(cfg.AllowFrom))
+	for i, allowed := range cfg.AllowFrom {
+		if strings.HasPrefix(allowed, "@") {
+			allowed = "matrix:" + allowed
+		}
+		allowFrom[i] = allowed
+	}
+
+	base := channels.NewBaseChannel("matrix", cfg, messageBus, allowFrom,
+		channels.WithMaxMessageLength(maxMessageLength),
+		channels.WithGroupTrigger(cfg.GroupTrigger),
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &MatrixChannel{
+		BaseChannel: base,
+		config:      cfg,
+		homeserver:  strings.TrimRight(cfg.Homeserver, "/"),
+		client:      &http.Client{Timeout: syncTimeout + 30*time.Second},
+		token:       cfg.AccessToken,
+		userID:      cfg.UserID,
+		members:     make(map[string]int),
+		encrypted:   make(map[string]bool),
+	}, nil
+}
+
+// Start authenticates, skips past the room history and starts syncing.
+func (c *MatrixChannel) Start(ctx context.Context) error {
+	logger.InfoC("matrix", "Starting Matrix channel")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.txnPrefix = fmt.Sprintf("picoclaw%d", time.Now().UnixNano())
+
+	if c.token == "" {
+		if err := c.login(c.ctx); err != nil {
+			return fmt.Errorf("matrix login failed: %w", err)
+		}
+	}
+	var whoami struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.do(c.ctx, http.MethodGet, clientAPI+"/account/whoami", nil, nil, &whoami); err != nil {
+		return fmt.Errorf("matrix authentication failed: %w", err)
+	}
+	c.userID = whoami.UserID
+
+	var profile struct {
+		DisplayName string `json:"displayname"`
+	}
+	path := clientAPI + "/profile/" + url.PathEscape(c.userID) + "/displayname"
+	if err := c.do(c.ctx, http.MethodGet, path, nil, nil, &profile); err == nil {
+		c.displayName = profile.DisplayName
+	}
+
+	initial, err := c.sync(c.ctx, "", initialSyncFilter, 0)
+	if err != nil {
+		return fmt.Errorf("matrix initial sync failed: %w", err)
+	}
+	c.processInvites(initial)
+
+	c.done = make(chan struct{})
+	go c.syncLoop(initial.NextBatch)
+
+	c.SetRunning(true)
+	logger.InfoCF("matrix", "Matrix channel started", map[string]any{
+		"homeserver": c.homeserver,
+		"user_id":    c.userID,
+	})
+	return nil
+}
+
+// Stop ends the sync loop.
+func (c *MatrixChannel) Stop(ctx context.Context) error {
+	logger.InfoC("matrix", "Stopping Matrix channel")
+	c.SetRunning(false)
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if c.done != nil {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+
+	logger.InfoC("matrix", "Matrix channel stopped")
+	return nil
+}
+
+func (c *MatrixChannel) login(ctx context.Context) error {
+	req := map[string]any{
+		"type":                        "m.login.password",
+		"identifier":                  map[string]string{"type": "m.id.user", "user": c.config.UserID},
+		"password":                    c.config.Password,
+		"initial_device_display_name": "PicoClaw",
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := c.do(ctx, http.MethodPost, clientAPI+"/login", nil, req, &resp); err != nil {
+		return err
+	}
+	c.token = resp.AccessToken
+	logger.WarnC("matrix", "Logged in with a password; set access_token to reuse one device across restarts")
+	return nil
+}
+
+// Send posts a text message to the room.
+func (c *MatrixChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("room ID is empty: %w", channels.ErrSendFailed)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	content := map[string]any{"msgtype": "m.text", "body": msg.Content}
+	if err := c.sendEvent(ctx, msg.ChatID, content); err != nil {
+		return err
+	}
+	logger.DebugCF("matrix", "Message sent", map[string]any{
+		"room_id": msg.ChatID,
+	})
+	return nil
+}
+
+// SendMedia implements channels.MediaSender by uploading each part to the
+// homeserver's content repository.
+func (c *MatrixChannel) SendMedia(ctx context.Context, msg bus.OutboundMediaMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return fmt.Errorf("no media store available: %w", channels.ErrSendFailed)
+	}
+
+	for _, part := range msg.Parts {
+		localPath, err := store.Resolve(part.Ref)
+		if err != nil {
+			logger.ErrorCF("matrix", "Failed to resolve media ref", map[string]any{
+				"ref":   part.Ref,
+				"error": err.Error(),
+			})
+			continue
+		}
+		filename := part.Filename
+		if filename == "" {
+			filename = filepath.Base(localPath)
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		uri, err := c.upload(ctx, localPath, filename, contentType)
+		if err != nil {
+			return err
+		}
+		content := map[string]any{
+			"msgtype":  mediaMsgType(part.Type),
+			"body":     filename,
+			"filename": filename,
+			"url":      uri,
+			"info":     map[string]any{"mimetype": contentType},
+		}
+		if part.Caption != "" {
+			content["body"] = part.Caption
+		}
+		if err := c.sendEvent(ctx, msg.ChatID, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mediaMsgType(partType string) string {
+	switch partType {
+	case "image", "audio", "video":
+		return "m." + partType
+	default:
+		return "m.file"
+	}
+}
+
+func (c *MatrixChannel) upload(ctx context.Context, localPath, filename, contentType string) (string, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %v: %w", localPath, err, channels.ErrSendFailed)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		c.homeserver+"/_matrix/media/v3/upload?filename="+url.QueryEscape(filename), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var resp struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := c.roundTrip(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.ContentURI, nil
+}
+
+// StartTyping implements channels.TypingCapable. Requires typing.enabled.
+func (c *MatrixChannel) StartTyping(ctx context.Context, chatID string) (func(), error) {
+	if !c.config.Typing.Enabled || chatID == "" {
+		return func() {}, nil
+	}
+
+	typingCtx, cancel := context.WithCancel(ctx)
+	if err := c.setTyping(typingCtx, chatID, true); err != nil {
+		cancel()
+		return func() {}, err
+	}
+	go func() {
+		// Typing notifications expire; refresh them until stopped.
+		ticker := time.NewTicker(typingTimeout - 5*time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-typingCtx.Done():
+				return
+			case <-ticker.C:
+				_ = c.setTyping(typingCtx, chatID, true)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer stopCancel()
+			_ = c.setTyping(stopCtx, chatID, false)
+		})
+	}, nil
+}
+
+func (c *MatrixChannel) setTyping(ctx context.Context, roomID string, typing bool) error {
+	body := map[string]any{"typing": typing}
+	if typing {
+		body["timeout"] = typingTimeout.Milliseconds()
+	}
+	path := clientAPI + "/rooms/" + url.PathEscape(roomID) + "/typing/" + url.PathEscape(c.userID)
+	return c.do(ctx, http.MethodPut, path, nil, body, nil)
+}
+
+func (c *MatrixChannel) sendEvent(ctx context.Context, roomID string, content map[string]any) error {
+	txnID := fmt.Sprintf("%s.%d", c.txnPrefix, c.txnSeq.Add(1))
+	path := clientAPI + "/rooms/" + url.PathEscape(roomID) + "/send/m.room.message/" + url.PathEscape(txnID)
+	return c.do(ctx, http.MethodPut, path, nil, content, nil)
+}
+
+// Sync response types, reduced to the fields the channel reads.
+type syncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join   map[string]joinedRoom  `json:"join"`
+		Invite map[string]invitedRoom `json:"invite"`
+	} `json:"rooms"`
+}
+
+type joinedRoom struct {
+	Summary struct {
+		JoinedMemberCount *int `json:"m.joined_member_count"`
+	} `json:"summary"`
+	Timeline struct {
+		Events []roomEvent `json:"events"`
+	} `json:"timeline"`
+}
+
+type invitedRoom struct {
+	InviteState struct {
+		Events []roomEvent `json:"events"`
+	} `json:"invite_state"`
+}
+
+type roomEvent struct {
+	Type     string          `json:"type"`
+	EventID  string          `json:"event_id"`
+	Sender   string          `json:"sender"`
+	StateKey *string         `json:"state_key"`
+	Content  json.RawMessage `json:"content"`
+}
+
+type messageContent struct {
+	MsgType  string `json:"msgtype"`
+	Body     string `json:"body"`
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Mentions *struct {
+		UserIDs []string `json:"user_ids"`
+	} `json:"m.mentions"`
+	RelatesTo *struct {
+		RelType string `json:"rel_type"`
+	} `json:"m.relates_to"`
+}
+
+type memberContent struct {
+	Membership string `json:"membership"`
+}
+
+func (c *MatrixChannel) sync(ctx context.Context, since, filter string, timeout time.Duration) (*syncResponse, error) {
+	query := url.Values{"timeout": {fmt.Sprint(timeout.Milliseconds())}}
+	if since != "" {
+		query.Set("since", since)
+	}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	var resp syncResponse
+	if err := c.do(ctx, http.MethodGet, clientAPI+"/sync", query, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *MatrixChannel) syncLoop(since string) {
+	defer close(c.done)
+	for {
+		resp, err := c.sync(c.ctx, since, "", syncTimeout)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			logger.WarnCF("matrix", "Sync failed, retrying", map[string]any{
+				"error": err.Error(),
+			})
+			select {
+			case <-time.After(retryDelay):
+				continue
+			case <-c.ctx.Done():
+				return
+			}
+		}
+		since = resp.NextBatch
+		c.processInvites(resp)
+		for roomID, room := range resp.Rooms.Join {
+			c.processRoom(roomID, room)
+		}
+	}
+}
+
+// processInvites joins rooms the bot was invited to by allowed users.
+func (c *MatrixChannel) processInvites(resp *syncResponse) {
+	if !c.config.AutoJoin {
+		return
+	}
+	for roomID, room := range resp.Rooms.Invite {
+		inviter := ""
+		for _, ev := range room.InviteState.Events {
+			if ev.Type == "m.room.member" && ev.StateKey != nil && *ev.StateKey == c.userID {
+				inviter = ev.Sender
+			}
+		}
+		if inviter == "" || !c.IsAllowedSender(c.senderInfo(inviter)) {
+			logger.InfoCF("matrix", "Ignoring invite", map[string]any{
+				"room_id": roomID,
+				"inviter": inviter,
+			})
+			continue
+		}
+		path := clientAPI + "/join/" + url.PathEscape(roomID)
+		if err := c.do(c.ctx, http.MethodPost, path, nil, map[string]any{}, nil); err != nil {
+			logger.WarnCF("matrix", "Failed to join room", map[string]any{
+				"room_id": roomID,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		logger.InfoCF("matrix", "Joined room", map[string]any{
+			"room_id": roomID,
+			"inviter": inviter,
+		})
+	}
+}
+
+func (c *MatrixChannel) processRoom(roomID string, room joinedRoom) {
+	if n := room.Summary.JoinedMemberCount; n != nil {
+		c.mu.Lock()
+		c.members[roomID] = *n
+		c.mu.Unlock()
+	}
+	for _, ev := range room.Timeline.Events {
+		switch ev.Type {
+		case "m.room.message":
+			if ev.Sender != c.userID {
+				c.handleMessage(roomID, ev)
+			}
+		case "m.room.member":
+			var member memberContent
+			if json.Unmarshal(ev.Content, &member) == nil && member.Membership != "join" {
+				// The count in the next summary may lag; look it up again.
+				c.mu.Lock()
+				delete(c.members, roomID)
+				c.mu.Unlock()
+			}
+		case "m.room.encrypted":
+			c.warnEncrypted(roomID)
+		}
+	}
+}
+
+func (c *MatrixChannel) warnEncrypted(roomID string) {
+	c.mu.Lock()
+	warned := c.encrypted[roomID]
+	c.encrypted[roomID] = true
+	c.mu.Unlock()
+	if !warned {
+		logger.WarnCF("matrix", "Skipping messages in encrypted room; use an unencrypted room", map[string]any{
+			"room_id": roomID,
+		})
+	}
+}
+
+func (c *MatrixChannel) handleMessage(roomID string, ev roomEvent) {
+	var msg messageContent
+	if err := json.Unmarshal(ev.Content, &msg); err != nil {
+		return
+	}
+	// Edits repeat the message they replace.
+	if msg.RelatesTo != nil && msg.RelatesTo.RelType == "m.replace" {
+		return
+	}
+
+	var mediaPaths []string
+	content := msg.Body
+	switch msg.MsgType {
+	case "m.text":
+	case "m.emote":
+		content = "* " + msg.Body
+	case "m.image", "m.audio", "m.video", "m.file":
+		kind := strings.TrimPrefix(msg.MsgType, "m.")
+		if ref := c.downloadMedia(roomID, ev.EventID, msg); ref != "" {
+			mediaPaths = append(mediaPaths, ref)
+		}
+		content = fmt.Sprintf("[%s]", kind)
+		if msg.Filename != "" && msg.Body != msg.Filename {
+			content += " " + msg.Body
+		}
+	default:
+		// m.notice is what bots send; answering it could start a loop.
+		return
+	}
+
+	sender := c.senderInfo(ev.Sender)
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	peer := bus.Peer{Kind: "direct", ID: ev.Sender}
+	if !c.isDirect(roomID) {
+		respond, cleaned := c.ShouldRespondInGroup(c.isMentioned(msg), c.stripMention(content))
+		if !respond {
+			logger.DebugCF("matrix", "Ignoring group message by group trigger", map[string]any{
+				"room_id": roomID,
+			})
+			return
+		}
+		content = cleaned
+		peer = bus.Peer{Kind: "group", ID: roomID}
+	}
+	if strings.TrimSpace(content) == "" && len(mediaPaths) == 0 {
+		return
+	}
+
+	logger.DebugCF("matrix", "Received message", map[string]any{
+		"sender_id": ev.Sender,
+		"room_id":   roomID,
+		"preview":   utils.Truncate(content, 50),
+	})
+	metadata := map[string]string{
+		"platform": "matrix",
+		"msgtype":  msg.MsgType,
+	}
+	c.HandleMessage(c.ctx, peer, ev.EventID, ev.Sender, roomID, content, mediaPaths, metadata, sender)
+}
+
+func (c *MatrixChannel) senderInfo(userID string) bus.SenderInfo {
+	username := strings.TrimPrefix(userID, "@")
+	if i := strings.IndexByte(username, ':'); i >= 0 {
+		username = username[:i]
+	}
+	return bus.SenderInfo{
+		Platform:    "matrix",
+		PlatformID:  userID,
+		CanonicalID: identity.BuildCanonicalID("matrix", userID),
+		Username:    username,
+		DisplayName: username,
+	}
+}
+
+// isDirect reports whether the room has at most two members.
+func (c *MatrixChannel) isDirect(roomID string) bool {
+	c.mu.Lock()
+	n, ok := c.members[roomID]
+	c.mu.Unlock()
+	if ok {
+		return n <= 2
+	}
+
+	var resp struct {
+		Joined map[string]json.RawMessage `json:"joined"`
+	}
+	path := clientAPI + "/rooms/" + url.PathEscape(roomID) + "/joined_members"
+	if err := c.do(c.ctx, http.MethodGet, path, nil, nil, &resp); err != nil {
+		logger.WarnCF("matrix", "Failed to count room members", map[string]any{
+			"room_id": roomID,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	c.mu.Lock()
+	c.members[roomID] = len(resp.Joined)
+	c.mu.Unlock()
+	return len(resp.Joined) <= 2
+}
+
+// isMentioned prefers the explicit mentions of newer clients and falls back
+// to looking for the bot's ID or display name in the text.
+func (c *MatrixChannel) isMentioned(msg messageContent) bool {
+	if msg.Mentions != nil && slices.Contains(msg.Mentions.UserIDs, c.userID) {
+		return true
+	}
+	return strings.Contains(msg.Body, c.userID) ||
+		(c.displayName != "" && strings.Contains(msg.Body, c.displayName))
+}
+
+// stripMention removes the bot's ID and display name, which clients insert
+// as "Name: message" when mentioning.
+func (c *MatrixChannel) stripMention(content string) string {
+	for _, name := range []string{c.userID, c.displayName} {
+		if name != "" {
+			content = strings.ReplaceAll(content, name, "")
+		}
+	}
+	return strings.TrimLeft(strings.TrimSpace(content), ":, ")
+}
+
+// downloadMedia fetches an attachment into the media store and returns its
+// ref, or "".
+func (c *MatrixChannel) downloadMedia(roomID, eventID string, msg messageContent) string {
+	server, mediaID, ok := strings.Cut(strings.TrimPrefix(msg.URL, "mxc://"), "/")
+	if !ok || !strings.HasPrefix(msg.URL, "mxc://") {
+		return ""
+	}
+	filename := msg.Filename
+	if filename == "" {
+		filename = msg.Body
+	}
+	filename = filepath.Base(filename)
+	if filename == "." || filename == "/" || filename == "" {
+		filename = mediaID
+	}
+
+	localPath := utils.DownloadFile(
+		c.homeserver+"/_matrix/client/v1/media/download/"+url.PathEscape(server)+"/"+url.PathEscape(mediaID),
+		filename,
+		utils.DownloadOptions{
+			LoggerPrefix: "matrix",
+			ExtraHeaders: map[string]string{"Authorization": "Bearer " + c.token},
+		},
+	)
+	if localPath == "" {
+		return ""
+	}
+	store := c.GetMediaStore()
+	if store == nil {
+		return localPath
+	}
+	ref, err := store.Store(localPath, media.MediaMeta{
+		Filename: filename,
+		Source:   "matrix",
+	}, channels.BuildMediaScope("matrix", roomID, eventID))
+	if err != nil {
+		return localPath
+	}
+	return ref
+}
+
+// do sends a JSON request to the client-server API and decodes the response
+// into out when it is non-nil.
+func (c *MatrixChannel) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	endpoint := c.homeserver + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.roundTrip(req, out)
+}
+
+func (c *MatrixChannel) roundTrip(req *http.Request, out any) error {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return channels.ClassifyNetError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			ErrCode string `json:"errcode"`
+			Error   string `json:"error"`
+		}
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(respBody, &apiErr) != nil || apiErr.ErrCode == "" {
+			apiErr.Error = strings.TrimSpace(string(respBody))
+		}
+		return channels.ClassifySendError(resp.StatusCode,
+			fmt.Errorf("matrix API error: %s %s", apiErr.ErrCode, apiErr.Error))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode matrix response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const botID = "@bot:example.org"
+
+// fakeHomeserver serves the client-server endpoints the channel uses. The
+// first sync returns an invite, the second the timeline events, later ones
+// wait for the client to give up.
+type fakeHomeserver struct {
+	*httptest.Server
+	events map[string][]map[string]any // room ID -> timeline events
+
+	mu     sync.Mutex
+	syncs  int
+	joined []string
+	sent   []map[string]any
+	typing []bool
+}
+
+func newFakeHomeserver(t *testing.T, events map[string][]map[string]any) *fakeHomeserver {
+	t.Helper()
+	hs := &fakeHomeserver{events: events}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /_matrix/client/v3/account/whoami", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"bad token"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"user_id": botID})
+	})
+	mux.HandleFunc("GET /_matrix/client/v3/profile/{user}/displayname", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"displayname": "PicoBot"})
+	})
+	mux.HandleFunc("GET /_matrix/client/v3/sync", hs.sync)
+	mux.HandleFunc("POST /_matrix/client/v3/join/{room}", func(w http.ResponseWriter, r *http.Request) {
+		hs.mu.Lock()
+		hs.joined = append(hs.joined, r.PathValue("room"))
+		hs.mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("PUT /_matrix/client/v3/rooms/{room}/send/m.room.message/{txn}",
+		func(w http.ResponseWriter, r *http.Request) {
+			var content map[string]any
+			json.NewDecoder(r.Body).Decode(&content)
+			content["room"] = r.PathValue("room")
+			hs.mu.Lock()
+			hs.sent = append(hs.sent, content)
+			hs.mu.Unlock()
+			w.Write([]byte(`{"event_id":"$sent"}`))
+		})
+	mux.HandleFunc("PUT /_matrix/client/v3/rooms/{room}/typing/{user}", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Typing bool `json:"typing"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		hs.mu.Lock()
+		hs.typing = append(hs.typing, body.Typing)
+		hs.mu.Unlock()
+		w.Write([]byte(`{}`))
+	})
+	hs.Server = httptest.NewServer(mux)
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func (hs *fakeHomeserver) sync(w http.ResponseWriter, r *http.Request) {
+	hs.mu.Lock()
+	hs.syncs++
+	n := hs.syncs
+	hs.mu.Unlock()
+
+	switch n {
+	case 1:
+		if r.URL.Query().Get("since") != "" {
+			http.Error(w, "first sync must not pass since", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"next_batch": "s1",
+			"rooms": map[string]any{"invite": map[string]any{
+				"!invited:example.org": inviteFrom("@alice:example.org"),
+				"!spam:example.org":    inviteFrom("@mallory:example.org"),
+			}},
+		})
+	case 2:
+		join := map[string]any{}
+		for room, events := range hs.events {
+			members := 2
+			if strings.HasPrefix(room, "!group") {
+				members = 5
+			}
+			join[room] = map[string]any{
+				"summary":  map[string]any{"m.joined_member_count": members},
+				"timeline": map[string]any{"events": events},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"next_batch": "s2", "rooms": map[string]any{"join": join}})
+	default:
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		json.NewEncoder(w).Encode(map[string]any{"next_batch": "s3"})
+	}
+}
+
+func inviteFrom(inviter string) map[string]any {
+	return map[string]any{"invite_state": map[string]any{"events": []map[string]any{{
+		"type": "m.room.member", "sender": inviter, "state_key": botID,
+		"content": map[string]any{"membership": "invite"},
+	}}}}
+}
+
+func message(id, sender string, content map[string]any) map[string]any {
+	return map[string]any{"type": "m.room.message", "event_id": id, "sender": sender, "content": content}
+}
+
+func text(body string) map[string]any {
+	return map[string]any{"msgtype": "m.text", "body": body}
+}
+
+func TestNewMatrixChannel(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	if _, err := NewMatrixChannel(config.MatrixConfig{AccessToken: "x"}, msgBus); err == nil {
+		t.Error("expected error for missing homeserver")
+	}
+	if _, err := NewMatrixChannel(config.MatrixConfig{Homeserver: "https://hs", UserID: botID}, msgBus); err == nil {
+		t.Error("expected error for missing credentials")
+	}
+	if _, err := NewMatrixChannel(config.MatrixConfig{Homeserver: "https://hs", AccessToken: "x"}, msgBus); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMatrixChannel_SyncAndReply(t *testing.T) {
+	hs := newFakeHomeserver(t, map[string][]map[string]any{
+		"!dm:example.org": {
+			message("$own", botID, text("my own reply")),
+			message("$stranger", "@mallory:example.org", text("not allowed")),
+			message("$notice", "@alice:example.org", map[string]any{"msgtype": "m.notice", "body": "bot output"}),
+			{"type": "m.room.encrypted", "event_id": "$enc", "sender": "@alice:example.org", "content": map[string]any{}},
+			message("$hello", "@alice:example.org", text("hello there")),
+		},
+		"!group:example.org": {
+			message("$chatter", "@alice:example.org", text("just chatting")),
+			message("$ask", "@alice:example.org", map[string]any{
+				"msgtype": "m.text", "body": "PicoBot: what time is it?",
+				"m.mentions": map[string]any{"user_ids": []string{botID}},
+			}),
+		},
+	})
+
+	msgBus := bus.NewMessageBus()
+	ch, err := NewMatrixChannel(config.MatrixConfig{
+		Homeserver:   hs.URL,
+		AccessToken:  "secret",
+		AutoJoin:     true,
+		AllowFrom:    []string{"@alice:example.org"},
+		GroupTrigger: config.GroupTriggerConfig{MentionOnly: true},
+		Typing:       config.TypingConfig{Enabled: true},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewMatrixChannel: %v", err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer ch.Stop(context.Background())
+
+	hs.mu.Lock()
+	joined := hs.joined
+	hs.mu.Unlock()
+	if len(joined) != 1 || joined[0] != "!invited:example.org" {
+		t.Errorf("joined = %v, want only the invite from an allowed user", joined)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	got := map[string]bus.InboundMessage{}
+	for range 2 {
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatalf("only got %d inbound messages", len(got))
+		}
+		got[msg.ChatID] = msg
+	}
+	if dm := got["!dm:example.org"]; dm.Content != "hello there" || dm.Peer.Kind != "direct" {
+		t.Errorf("direct message = %+v", dm)
+	}
+	group := got["!group:example.org"]
+	if group.Content != "what time is it?" || group.Peer.Kind != "group" || group.Peer.ID != "!group:example.org" {
+		t.Errorf("group message = %+v", group)
+	}
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if msg, ok := msgBus.ConsumeInbound(short); ok {
+		t.Errorf("unexpected inbound message %+v", msg)
+	}
+
+	stop, err := ch.StartTyping(context.Background(), "!dm:example.org")
+	if err != nil {
+		t.Fatalf("StartTyping: %v", err)
+	}
+	stop()
+	if err := ch.Send(context.Background(), bus.OutboundMessage{ChatID: "!dm:example.org", Content: "hi Alice"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if len(hs.sent) != 1 || hs.sent[0]["room"] != "!dm:example.org" || hs.sent[0]["body"] != "hi Alice" ||
+		hs.sent[0]["msgtype"] != "m.text" {
+		t.Errorf("sent = %+v", hs.sent)
+	}
+	if len(hs.typing) != 2 || !hs.typing[0] || hs.typing[1] {
+		t.Errorf("typing = %v, want [true false]", hs.typing)
+	}
+}
+
+func TestMatrixChannel_StartRejectsBadToken(t *testing.T) {
+	hs := newFakeHomeserver(t, nil)
+	ch, err := NewMatrixChannel(config.MatrixConfig{Homeserver: hs.URL, AccessToken: "wrong"}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewMatrixChannel: %v", err)
+	}
+	err = ch.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Errorf("Start = %v, want M_UNKNOWN_TOKEN error", err)
+	}
+}
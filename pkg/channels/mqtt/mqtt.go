@@ -0,0 +1,207 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultReplySuffix = "/reply"
+	mqttTimeout        = 10 * time.Second
+)
+
+// MQTTChannel starts agent turns from messages on MQTT topics, so devices
+// and automations can talk to the agent. A message is plain text or a JSON
+// object {"text": "...", "sender": "..."}; the conversation is the topic,
+// and replies are published to the topic plus the reply suffix.
+type MQTTChannel struct {
+	*channels.BaseChannel
+	config      config.MQTTConfig
+	replySuffix string
+	client      paho.Client
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+// NewMQTTChannel creates a new MQTT channel.
+func NewMQTTChannel(cfg config.MQTTConfig, messageBus *bus.MessageBus) (*MQTTChannel, error) {
+	if cfg.Broker == "" {
+		return nil, errors.New("mqtt broker is required")
+	}
+	if len(cfg.Topics) == 0 {
+		return nil, errors.New("mqtt needs at least one topic to subscribe to")
+	}
+	replySuffix := cfg.ReplySuffix
+	if replySuffix == "" {
+		replySuffix = defaultReplySuffix
+	}
+
+	base := channels.NewBaseChannel("mqtt", cfg, messageBus, cfg.AllowFrom,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &MQTTChannel{
+		BaseChannel: base,
+		config:      cfg,
+		replySuffix: replySuffix,
+	}, nil
+}
+
+// Start connects to the broker and subscribes to the configured topics.
+func (c *MQTTChannel) Start(ctx context.Context) error {
+	logger.InfoC("mqtt", "Starting MQTT channel")
+	c.ctx, c.cancel = context.WithCancel(ctx)
+
+	clientID := c.config.ClientID
+	if clientID == "" {
+		clientID = "picoclaw-" + uuid.NewString()[:8]
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(c.config.Broker).
+		SetClientID(clientID).
+		SetUsername(c.config.Username).
+		SetPassword(c.config.Password).
+		SetConnectTimeout(mqttTimeout).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		// Subscriptions do not survive a reconnect with a clean session.
+		SetOnConnectHandler(c.subscribe)
+
+	client := paho.NewClient(opts)
+	tok := client.Connect()
+	if !tok.WaitTimeout(mqttTimeout) {
+		return fmt.Errorf("mqtt connect to %s timed out", c.config.Broker)
+	}
+	if err := tok.Error(); err != nil {
+		return fmt.Errorf("mqtt connect failed: %w", err)
+	}
+	c.client = client
+
+	c.SetRunning(true)
+	logger.InfoCF("mqtt", "MQTT channel started", map[string]any{
+		"broker": c.config.Broker,
+		"topics": []string(c.config.Topics),
+	})
+	return nil
+}
+
+func (c *MQTTChannel) subscribe(client paho.Client) {
+	filters := make(map[string]byte, len(c.config.Topics))
+	for _, topic := range c.config.Topics {
+		filters[topic] = 1
+	}
+	tok := client.SubscribeMultiple(filters, func(_ paho.Client, m paho.Message) {
+		c.handleMessage(m.Topic(), m.Payload())
+	})
+	if tok.WaitTimeout(mqttTimeout) && tok.Error() == nil {
+		return
+	}
+	logger.ErrorCF("mqtt", "Failed to subscribe", map[string]any{
+		"topics": []string(c.config.Topics),
+		"error":  fmt.Sprint(tok.Error()),
+	})
+}
+
+// Stop disconnects from the broker.
+func (c *MQTTChannel) Stop(ctx context.Context) error {
+	logger.InfoC("mqtt", "Stopping MQTT channel")
+	c.SetRunning(false)
+
+	if c.client != nil {
+		c.client.Disconnect(250)
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	logger.InfoC("mqtt", "MQTT channel stopped")
+	return nil
+}
+
+// Send publishes a reply to the conversation's topic plus the reply suffix.
+func (c *MQTTChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	topic := msg.ChatID + c.replySuffix
+	tok := c.client.Publish(topic, 1, false, msg.Content)
+	select {
+	case <-tok.Done():
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(mqttTimeout):
+		return fmt.Errorf("mqtt publish to %s timed out: %w", topic, channels.ErrTemporary)
+	}
+	if err := tok.Error(); err != nil {
+		return fmt.Errorf("mqtt publish to %s: %v: %w", topic, err, channels.ErrTemporary)
+	}
+
+	logger.DebugCF("mqtt", "Message sent", map[string]any{
+		"topic": topic,
+	})
+	return nil
+}
+
+// inboundPayload is the JSON form of an inbound message.
+type inboundPayload struct {
+	Text   string `json:"text"`
+	Sender string `json:"sender"`
+}
+
+func (c *MQTTChannel) handleMessage(topic string, payload []byte) {
+	// Our own replies come back when a subscription covers them.
+	if strings.HasSuffix(topic, c.replySuffix) {
+		return
+	}
+
+	content := string(payload)
+	senderID := topic
+	var in inboundPayload
+	if json.Unmarshal(payload, &in) == nil && in.Text != "" {
+		content = in.Text
+		if in.Sender != "" {
+			senderID = in.Sender
+		}
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "mqtt",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("mqtt", senderID),
+		Username:    senderID,
+		DisplayName: senderID,
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	messageID := fmt.Sprintf("%s-%d", topic, time.Now().UnixNano())
+	metadata := map[string]string{
+		"platform": "mqtt",
+		"topic":    topic,
+	}
+	peer := bus.Peer{Kind: "direct", ID: topic}
+	c.HandleMessage(c.ctx, peer, messageID, senderID, topic, content, nil, metadata, sender)
+}
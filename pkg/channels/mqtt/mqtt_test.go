@@ -0,0 +1,121 @@
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// doneToken is a paho.Token that has already completed.
+type doneToken struct{ err error }
+
+func (t doneToken) Wait() bool                     { return true }
+func (t doneToken) WaitTimeout(time.Duration) bool { return true }
+func (t doneToken) Error() error                   { return t.err }
+
+func (t doneToken) Done() <-chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}
+
+type published struct {
+	topic   string
+	payload any
+}
+
+// fakeClient records publishes; other paho.Client methods are not used.
+type fakeClient struct {
+	paho.Client
+	published []published
+}
+
+func (c *fakeClient) Publish(topic string, _ byte, _ bool, payload any) paho.Token {
+	c.published = append(c.published, published{topic, payload})
+	return doneToken{}
+}
+
+func TestNewMQTTChannel(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	if _, err := NewMQTTChannel(config.MQTTConfig{Topics: []string{"a"}}, msgBus); err == nil {
+		t.Error("expected error for missing broker")
+	}
+	if _, err := NewMQTTChannel(config.MQTTConfig{Broker: "tcp://localhost:1883"}, msgBus); err == nil {
+		t.Error("expected error for missing topics")
+	}
+	ch, err := NewMQTTChannel(config.MQTTConfig{Broker: "tcp://localhost:1883", Topics: []string{"a/#"}}, msgBus)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ch.Name() != "mqtt" || ch.replySuffix != "/reply" {
+		t.Errorf("Name() = %q, replySuffix = %q", ch.Name(), ch.replySuffix)
+	}
+}
+
+func TestMQTTChannel_HandleMessage(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	cfg := config.MQTTConfig{
+		Broker:    "tcp://localhost:1883",
+		Topics:    []string{"picoclaw/in/#"},
+		AllowFrom: []string{"doorbell", "picoclaw/in/kitchen"},
+	}
+	ch, err := NewMQTTChannel(cfg, msgBus)
+	if err != nil {
+		t.Fatalf("NewMQTTChannel: %v", err)
+	}
+	ch.ctx = context.Background()
+
+	ch.handleMessage("picoclaw/in/kitchen/reply", []byte("echo of our reply"))
+	ch.handleMessage("picoclaw/in/garage", []byte("not allowed"))
+	ch.handleMessage("picoclaw/in/kitchen", []byte("turn on the lamp"))
+	ch.handleMessage("picoclaw/in/door", []byte(`{"text":"someone rang","sender":"doorbell"}`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []struct{ chatID, sender, content string }{
+		{"picoclaw/in/kitchen", "mqtt:picoclaw/in/kitchen", "turn on the lamp"},
+		{"picoclaw/in/door", "mqtt:doorbell", "someone rang"},
+	} {
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatalf("no inbound message for %s", want.chatID)
+		}
+		if msg.ChatID != want.chatID || msg.SenderID != want.sender || msg.Content != want.content {
+			t.Errorf("inbound = %+v, want %+v", msg, want)
+		}
+		if msg.Peer.Kind != "direct" || msg.Metadata["topic"] != want.chatID {
+			t.Errorf("peer = %+v, metadata = %v", msg.Peer, msg.Metadata)
+		}
+	}
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if msg, ok := msgBus.ConsumeInbound(short); ok {
+		t.Errorf("unexpected inbound message %+v", msg)
+	}
+}
+
+func TestMQTTChannel_SendPublishesReply(t *testing.T) {
+	ch, err := NewMQTTChannel(config.MQTTConfig{
+		Broker: "tcp://localhost:1883", Topics: []string{"picoclaw/in/#"}, ReplySuffix: "/out",
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatalf("NewMQTTChannel: %v", err)
+	}
+	client := &fakeClient{}
+	ch.client = client
+	ch.SetRunning(true)
+
+	reply := bus.OutboundMessage{ChatID: "picoclaw/in/kitchen", Content: "Lamp on"}
+	if err := ch.Send(context.Background(), reply); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(client.published) != 1 || client.published[0].topic != "picoclaw/in/kitchen/out" ||
+		client.published[0].payload != "Lamp on" {
+		t.Errorf("published = %+v", client.published)
+	}
+}
@@ -0,0 +1,16 @@
+package queue
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("queue", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		if !cfg.Channels.Queue.Enabled {
+			return nil, nil
+		}
+		return NewQueueChannel(cfg.Channels.Queue, b)
+	})
+}
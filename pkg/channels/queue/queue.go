@@ -0,0 +1,339 @@
+// Package queue implements a channel on a message bus, so picoclaw can work
+// as an agent worker inside a larger system: other services put prompts on
+// a NATS subject or a Redis list and read the agent's replies back.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	defaultReplySuffix = ".reply"
+	dialTimeout        = 10 * time.Second
+	reconnectDelay     = 5 * time.Second
+	// maxPayloadBytes bounds a single message read from the bus.
+	maxPayloadBytes = 8 << 20
+)
+
+// broker is a connection to a message bus that delivers requests from one
+// subject. receive is only called from one goroutine; publish may be called
+// concurrently with it.
+type broker interface {
+	receive() (delivery, error)
+	publish(subject string, payload []byte) error
+	Close() error
+}
+
+// delivery is a request read from the bus.
+type delivery struct {
+	subject string
+	replyTo string // the NATS reply subject, if the sender used request-reply
+	payload []byte
+}
+
+// dialer connects to the bus at u and subscribes to subject.
+type dialer func(ctx context.Context, u *url.URL, subject, group string) (broker, error)
+
+// QueueChannel starts agent turns from requests on a NATS subject or Redis
+// list. A request is plain text or a JSON object {"text": "...", "sender":
+// "...", "chat": "...", "id": "...", "reply_to": "..."}. The conversation is
+// chat, else sender, else the subject. Replies answer in kind: plain text,
+// or {"id": "...", "chat": "...", "text": "..."} for JSON requests.
+type QueueChannel struct {
+	*channels.BaseChannel
+	config      config.QueueConfig
+	url         *url.URL
+	dial        dialer
+	replySuffix string
+
+	mu      sync.Mutex // guards conn and replies
+	conn    broker
+	replies map[string]replyRoute // chat ID → where its replies go
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// replyRoute is where the replies of a conversation go, taken from its
+// latest request.
+type replyRoute struct {
+	subject   string
+	requestID string
+	json      bool
+}
+
+// NewQueueChannel creates a message queue channel.
+func NewQueueChannel(cfg config.QueueConfig, messageBus *bus.MessageBus) (*QueueChannel, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("queue url is required")
+	}
+	if cfg.Subject == "" {
+		return nil, errors.New("queue subject is required")
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid queue url: %w", err)
+	}
+	var dial dialer
+	switch u.Scheme {
+	case "nats", "tls":
+		if !validSubject(cfg.Subject) || (cfg.QueueGroup != "" && !validSubject(cfg.QueueGroup)) {
+			return nil, errors.New("queue subject and queue_group must not contain spaces")
+		}
+		dial = dialNATS
+	case "redis", "rediss":
+		dial = dialRedis
+	default:
+		return nil, fmt.Errorf("unsupported queue url scheme %q (want nats, tls, redis or rediss)", u.Scheme)
+	}
+	replySuffix := cfg.ReplySuffix
+	if replySuffix == "" {
+		replySuffix = defaultReplySuffix
+	}
+
+	base := channels.NewBaseChannel("queue", cfg, messageBus, cfg.AllowFrom,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &QueueChannel{
+		BaseChannel: base,
+		config:      cfg,
+		url:         u,
+		dial:        dial,
+		replySuffix: replySuffix,
+		replies:     make(map[string]replyRoute),
+	}, nil
+}
+
+// Start connects to the bus in the background; the connection is retried
+// until it succeeds and re-established whenever it drops.
+func (c *QueueChannel) Start(ctx context.Context) error {
+	logger.InfoCF("queue", "Starting queue channel", map[string]any{
+		"bus":     c.url.Scheme + "://" + c.url.Host,
+		"subject": c.config.Subject,
+	})
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.SetRunning(true)
+	go c.run()
+	return nil
+}
+
+// Stop closes the connection to the bus.
+func (c *QueueChannel) Stop(ctx context.Context) error {
+	logger.InfoC("queue", "Stopping queue channel")
+	c.SetRunning(false)
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close() // unblocks receive
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+	}
+	logger.InfoC("queue", "Queue channel stopped")
+	return nil
+}
+
+func (c *QueueChannel) run() {
+	defer close(c.done)
+	for c.ctx.Err() == nil {
+		conn, err := c.dial(c.ctx, c.url, c.config.Subject, c.config.QueueGroup)
+		if err != nil {
+			logger.WarnCF("queue", "Failed to connect to the bus", map[string]any{
+				"bus":   c.url.Host,
+				"error": err.Error(),
+			})
+		} else {
+			err = c.serve(conn)
+			if c.ctx.Err() == nil {
+				logger.WarnCF("queue", "Bus connection lost; reconnecting", map[string]any{"error": fmt.Sprint(err)})
+			}
+		}
+		select {
+		case <-time.After(reconnectDelay):
+		case <-c.ctx.Done():
+		}
+	}
+}
+
+// serve hands requests from conn to the agent until the connection fails.
+func (c *QueueChannel) serve(conn broker) error {
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		conn.Close()
+		return nil
+	}
+	c.conn = conn
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	logger.InfoCF("queue", "Connected to the bus", map[string]any{"subject": c.config.Subject})
+	for {
+		d, err := conn.receive()
+		if err != nil {
+			return err
+		}
+		c.handleDelivery(d)
+	}
+}
+
+// Send publishes a reply to where the conversation's latest request asked
+// for it.
+func (c *QueueChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	if msg.ChatID == "" {
+		return fmt.Errorf("chat ID is empty: %w", channels.ErrSendFailed)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	conn := c.conn
+	route, ok := c.replies[msg.ChatID]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no request seen for chat %s: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+	if conn == nil {
+		return fmt.Errorf("not connected to the bus: %w", channels.ErrTemporary)
+	}
+
+	payload := []byte(msg.Content)
+	if route.json {
+		payload, _ = json.Marshal(outboundPayload{ID: route.requestID, Chat: msg.ChatID, Text: msg.Content})
+	}
+	if err := conn.publish(route.subject, payload); err != nil {
+		return fmt.Errorf("queue publish to %s: %v: %w", route.subject, err, channels.ErrTemporary)
+	}
+
+	logger.DebugCF("queue", "Message sent", map[string]any{
+		"subject": route.subject,
+	})
+	return nil
+}
+
+// inboundPayload is the JSON form of a request.
+type inboundPayload struct {
+	Text    string `json:"text"`
+	Sender  string `json:"sender"`
+	Chat    string `json:"chat"`
+	ID      string `json:"id"`
+	ReplyTo string `json:"reply_to"`
+}
+
+// outboundPayload is the JSON form of a reply.
+type outboundPayload struct {
+	ID   string `json:"id,omitempty"`
+	Chat string `json:"chat"`
+	Text string `json:"text"`
+}
+
+func (c *QueueChannel) handleDelivery(d delivery) {
+	// Our own replies come back when a wildcard subscription covers them.
+	if strings.HasSuffix(d.subject, c.replySuffix) {
+		return
+	}
+
+	content := string(d.payload)
+	senderID := d.subject
+	chatID := d.subject
+	route := replyRoute{subject: d.subject + c.replySuffix}
+	var in inboundPayload
+	if json.Unmarshal(d.payload, &in) == nil && in.Text != "" {
+		content = in.Text
+		route.json = true
+		route.requestID = in.ID
+		if in.Sender != "" {
+			senderID, chatID = in.Sender, in.Sender
+		}
+		if in.Chat != "" {
+			chatID = in.Chat
+		}
+		if in.ReplyTo != "" {
+			route.subject = in.ReplyTo
+		}
+	}
+	if d.replyTo != "" {
+		route.subject = d.replyTo
+	}
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	if c.url.Scheme == "nats" || c.url.Scheme == "tls" {
+		if !validSubject(route.subject) {
+			logger.WarnCF("queue", "Dropping request with an invalid reply subject", map[string]any{
+				"subject": d.subject,
+			})
+			return
+		}
+	}
+
+	sender := bus.SenderInfo{
+		Platform:    "queue",
+		PlatformID:  senderID,
+		CanonicalID: identity.BuildCanonicalID("queue", senderID),
+		Username:    senderID,
+		DisplayName: senderID,
+	}
+	if !c.IsAllowedSender(sender) {
+		return
+	}
+
+	c.mu.Lock()
+	c.replies[chatID] = route
+	c.mu.Unlock()
+
+	messageID := route.requestID
+	if messageID == "" {
+		messageID = fmt.Sprintf("%s-%d", d.subject, time.Now().UnixNano())
+	}
+	metadata := map[string]string{
+		"platform": "queue",
+		"subject":  d.subject,
+		"reply_to": route.subject,
+	}
+	peer := bus.Peer{Kind: "direct", ID: chatID}
+	c.HandleMessage(c.ctx, peer, messageID, senderID, chatID, content, nil, metadata, sender)
+}
+
+// validSubject reports whether s can be written as a NATS subject: the
+// protocol is line based and separates fields with spaces.
+func validSubject(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r <= ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,373 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type published struct {
+	subject string
+	payload string
+}
+
+// fakeBroker delivers what is sent on in and records publishes.
+type fakeBroker struct {
+	in     chan delivery
+	closed chan struct{}
+	once   sync.Once
+
+	mu        sync.Mutex
+	published []published
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{in: make(chan delivery), closed: make(chan struct{})}
+}
+
+func (b *fakeBroker) receive() (delivery, error) {
+	select {
+	case d := <-b.in:
+		return d, nil
+	case <-b.closed:
+		return delivery{}, io.EOF
+	}
+}
+
+func (b *fakeBroker) publish(subject string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, published{subject, string(payload)})
+	return nil
+}
+
+func (b *fakeBroker) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
+
+func (b *fakeBroker) take() []published {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p := b.published
+	b.published = nil
+	return p
+}
+
+func TestNewQueueChannel(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	for _, cfg := range []config.QueueConfig{
+		{Subject: "a"},
+		{URL: "nats://localhost:4222"},
+		{URL: "amqp://localhost", Subject: "a"},
+		{URL: "nats://localhost:4222", Subject: "a b"},
+	} {
+		if _, err := NewQueueChannel(cfg, msgBus); err == nil {
+			t.Errorf("NewQueueChannel(%+v) succeeded", cfg)
+		}
+	}
+	ch, err := NewQueueChannel(config.QueueConfig{URL: "redis://localhost", Subject: "jobs"}, msgBus)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ch.Name() != "queue" || ch.replySuffix != ".reply" {
+		t.Errorf("Name() = %q, replySuffix = %q", ch.Name(), ch.replySuffix)
+	}
+}
+
+func TestQueueChannel_RequestAndReply(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewQueueChannel(config.QueueConfig{
+		URL:       "nats://localhost:4222",
+		Subject:   "jobs.>",
+		AllowFrom: []string{"jobs.summarize", "billing"},
+	}, msgBus)
+	if err != nil {
+		t.Fatalf("NewQueueChannel: %v", err)
+	}
+	fb := newFakeBroker()
+	ch.dial = func(context.Context, *url.URL, string, string) (broker, error) { return fb, nil }
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Stop(context.Background())
+
+	fb.in <- delivery{subject: "jobs.summarize.reply", payload: []byte("echo of our reply")}
+	fb.in <- delivery{subject: "jobs.other", payload: []byte("not allowed")}
+	fb.in <- delivery{subject: "jobs.summarize", replyTo: "_INBOX.1", payload: []byte("summarize this")}
+	fb.in <- delivery{
+		subject: "jobs.invoice",
+		payload: []byte(`{"text":"total?","sender":"billing","chat":"inv-7","id":"r1","reply_to":"billing.answers"}`),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	for _, want := range []struct{ chatID, sender, content string }{
+		{"jobs.summarize", "queue:jobs.summarize", "summarize this"},
+		{"inv-7", "queue:billing", "total?"},
+	} {
+		msg, ok := msgBus.ConsumeInbound(ctx)
+		if !ok {
+			t.Fatalf("no inbound message for %s", want.chatID)
+		}
+		if msg.ChatID != want.chatID || msg.SenderID != want.sender || msg.Content != want.content {
+			t.Errorf("inbound = %+v, want %+v", msg, want)
+		}
+	}
+
+	// Plain text requests get plain text replies on their reply subject.
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "jobs.summarize", Content: "Short."}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	// JSON requests get JSON replies at reply_to.
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "inv-7", Content: "42 EUR"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	got := fb.take()
+	if len(got) != 2 || got[0] != (published{"_INBOX.1", "Short."}) || got[1].subject != "billing.answers" {
+		t.Fatalf("published = %+v", got)
+	}
+	var reply outboundPayload
+	if err := json.Unmarshal([]byte(got[1].payload), &reply); err != nil ||
+		reply != (outboundPayload{ID: "r1", Chat: "inv-7", Text: "42 EUR"}) {
+		t.Errorf("reply = %s (%v)", got[1].payload, err)
+	}
+
+	if err := ch.Send(ctx, bus.OutboundMessage{ChatID: "unknown", Content: "x"}); err == nil {
+		t.Error("Send to a chat without a request succeeded")
+	}
+}
+
+func listen(t *testing.T) (net.Listener, *url.URL) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return ln, &url.URL{Host: ln.Addr().String()}
+}
+
+func TestNATSConn(t *testing.T) {
+	ln, u := listen(t)
+	u.Scheme, u.User = "nats", url.UserPassword("worker", "secret")
+	lines := make(chan string, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case line == "PING":
+				io.WriteString(conn, "PONG\r\nPING\r\nMSG jobs.a 1 _INBOX.9 5\r\nhello\r\n")
+			case strings.HasPrefix(line, "PUB "):
+				f := strings.Fields(line)
+				size, _ := strconv.Atoi(f[len(f)-1])
+				buf := make([]byte, size+2)
+				io.ReadFull(r, buf)
+				line += " " + string(buf[:size])
+			}
+			lines <- line
+		}
+	}()
+
+	b, err := dialNATS(context.Background(), u, "jobs.>", "workers")
+	if err != nil {
+		t.Fatalf("dialNATS: %v", err)
+	}
+	defer b.Close()
+	d, err := b.receive()
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if d.subject != "jobs.a" || d.replyTo != "_INBOX.9" || string(d.payload) != "hello" {
+		t.Errorf("delivery = %+v", d)
+	}
+	if err := b.publish(d.replyTo, []byte("hi there")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := b.publish("bad subject", nil); err == nil {
+		t.Error("publish to an invalid subject succeeded")
+	}
+
+	var got []string
+	for len(got) < 5 {
+		select {
+		case line := <-lines:
+			got = append(got, line)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("server saw %q", got)
+		}
+	}
+	var connect map[string]any
+	json.Unmarshal([]byte(strings.TrimPrefix(got[0], "CONNECT ")), &connect)
+	if connect["user"] != "worker" || connect["pass"] != "secret" || connect["verbose"] != false {
+		t.Errorf("CONNECT = %s", got[0])
+	}
+	want := []string{"SUB jobs.> workers 1", "PING", "PONG", "PUB _INBOX.9 8 hi there"}
+	for i, w := range want {
+		if got[i+1] != w {
+			t.Errorf("line %d = %q, want %q", i+1, got[i+1], w)
+		}
+	}
+}
+
+func TestNATSConn_AuthError(t *testing.T) {
+	ln, u := listen(t)
+	u.Scheme = "nats"
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "INFO {}\r\n")
+		bufio.NewReader(conn).ReadString('\n')
+		io.WriteString(conn, "-ERR 'Authorization Violation'\r\n")
+	}()
+	if _, err := dialNATS(context.Background(), u, "jobs", ""); err == nil ||
+		!strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("dialNATS error = %v", err)
+	}
+}
+
+// fakeRedis serves the commands the broker uses from in-memory lists.
+type fakeRedis struct {
+	mu    sync.Mutex
+	lists map[string][]string
+	cmds  []string
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.cmds = append(f.cmds, args[0])
+		var reply string
+		switch args[0] {
+		case "AUTH":
+			if args[len(args)-1] == "secret" {
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case "SELECT":
+			reply = "+OK\r\n"
+		case "RPUSH":
+			f.lists[args[1]] = append(f.lists[args[1]], args[2:]...)
+			reply = ":" + strconv.Itoa(len(f.lists[args[1]])) + "\r\n"
+		case "BLPOP":
+			if l := f.lists[args[1]]; len(l) > 0 {
+				f.lists[args[1]] = l[1:]
+				reply = "*2\r\n" + bulk(args[1]) + bulk(l[0])
+			} else {
+				reply = "*-1\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisBroker(t *testing.T) {
+	ln, u := listen(t)
+	u.Scheme, u.User, u.Path = "redis", url.UserPassword("", "secret"), "/2"
+	f := &fakeRedis{lists: map[string][]string{"jobs": {`{"text":"hi"}`}}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+
+	b, err := dialRedis(context.Background(), u, "jobs", "")
+	if err != nil {
+		t.Fatalf("dialRedis: %v", err)
+	}
+	defer b.Close()
+	d, err := b.receive()
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if d.subject != "jobs" || string(d.payload) != `{"text":"hi"}` {
+		t.Errorf("delivery = %+v", d)
+	}
+	if err := b.publish("jobs.reply", []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	f.mu.Lock()
+	if got := f.lists["jobs.reply"]; len(got) != 1 || got[0] != "hello" {
+		t.Errorf("jobs.reply = %q", got)
+	}
+	if strings.Join(f.cmds, " ") != "AUTH SELECT AUTH SELECT BLPOP RPUSH" {
+		t.Errorf("commands = %q", f.cmds)
+	}
+	f.mu.Unlock()
+
+	u.User = url.UserPassword("", "wrong")
+	var rerr redisError
+	if _, err := dialRedis(context.Background(), u, "jobs", ""); !errors.As(err, &rerr) {
+		t.Errorf("dialRedis with a wrong password: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisPollSeconds is how long one BLPOP waits for a request. Waiting in
+// rounds lets a dead connection show up as a read timeout.
+const redisPollSeconds = 30
+
+// redisBroker takes requests from a Redis list with BLPOP and pushes replies
+// onto other lists with RPUSH. A blocked BLPOP holds its connection, so
+// replies go out on a second one.
+type redisBroker struct {
+	key  string
+	recv *respConn
+
+	mu   sync.Mutex // guards send
+	send *respConn
+}
+
+// dialRedis connects to the Redis server at u, a URL such as
+// redis://:password@host:6379/0, to read requests from the list key.
+func dialRedis(ctx context.Context, u *url.URL, key, _ string) (broker, error) {
+	recv, err := dialRESP(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	send, err := dialRESP(ctx, u)
+	if err != nil {
+		recv.Close()
+		return nil, err
+	}
+	return &redisBroker{key: key, recv: recv, send: send}, nil
+}
+
+func (b *redisBroker) receive() (delivery, error) {
+	for {
+		b.recv.conn.SetDeadline(time.Now().Add(redisPollSeconds*time.Second + dialTimeout))
+		reply, err := b.recv.do("BLPOP", b.key, strconv.Itoa(redisPollSeconds))
+		if err != nil {
+			return delivery{}, err
+		}
+		// BLPOP answers nil on timeout, else [key, value].
+		if kv, ok := reply.([]any); ok && len(kv) == 2 {
+			if value, ok := kv[1].(string); ok {
+				return delivery{subject: b.key, payload: []byte(value)}, nil
+			}
+		}
+	}
+}
+
+func (b *redisBroker) publish(key string, payload []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.send.conn.SetDeadline(time.Now().Add(dialTimeout))
+	_, err := b.send.do("RPUSH", key, string(payload))
+	return err
+}
+
+func (b *redisBroker) Close() error {
+	b.recv.Close()
+	return b.send.Close()
+}
+
+// respConn is a Redis connection speaking RESP2.
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func dialRESP(ctx context.Context, u *url.URL) (*respConn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	d := net.Dialer{Timeout: dialTimeout}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "rediss" {
+		td := tls.Dialer{NetDialer: &d, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = td.DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &respConn{conn: conn, r: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	if err := c.login(u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// login authenticates with the URL's credentials and selects the database
+// named by its path.
+func (c *respConn) login(u *url.URL) error {
+	if u.User != nil {
+		pass, hasPass := u.User.Password()
+		var err error
+		switch {
+		case hasPass && u.User.Username() != "":
+			_, err = c.do("AUTH", u.User.Username(), pass)
+		case hasPass:
+			_, err = c.do("AUTH", pass)
+		default:
+			_, err = c.do("AUTH", u.User.Username())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" && db != "0" {
+		if _, err := strconv.Atoi(db); err != nil {
+			return fmt.Errorf("redis: invalid database %q", db)
+		}
+		if _, err := c.do("SELECT", db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// do sends a command and reads its reply: a string, an int64, nil, or a
+// []any of those. An error reply is returned as a redisError.
+func (c *respConn) do(args ...string) (any, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+func (c *respConn) readReply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size > maxPayloadBytes {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2) // value and CRLF
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n > 1024 {
+			return nil, fmt.Errorf("redis: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (c *respConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,97 @@
+package channels
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// pruneThreshold is the number of tracked users above which buckets that
+// have refilled completely are dropped; a full bucket is the same as none.
+const pruneThreshold = 256
+
+// UserRateLimiter gives every user of every channel a token bucket, so one
+// user flooding the bot can neither run up the API bill nor keep the device
+// busy. See config.RateLimitConfig.
+type UserRateLimiter struct {
+	cfg config.RateLimitConfig
+
+	mu      sync.Mutex
+	buckets map[string]*userBucket // "channel|account"
+}
+
+type userBucket struct {
+	limiter  *rate.Limiter
+	fullIn   time.Duration // time an empty bucket takes to refill
+	lastSeen time.Time
+	warned   bool // the user was told to slow down and has not recovered yet
+}
+
+// NewUserRateLimiter creates a limiter with the budgets of cfg.
+func NewUserRateLimiter(cfg config.RateLimitConfig) *UserRateLimiter {
+	return &UserRateLimiter{cfg: cfg, buckets: make(map[string]*userBucket)}
+}
+
+// budget returns the budget of a channel. A non-positive rate means no limit.
+func (l *UserRateLimiter) budget(channel string) (perMinute float64, burst int) {
+	perMinute, burst = l.cfg.PerMinute, l.cfg.Burst
+	if b, ok := l.cfg.Channels[channel]; ok {
+		perMinute, burst = b.PerMinute, b.Burst
+	}
+	return perMinute, max(burst, 1)
+}
+
+// Allow reports whether a message from account may go through. For a
+// message that may not, warn is true only the first time in a row, so the
+// reply asking the user to slow down does not become a flood itself.
+func (l *UserRateLimiter) Allow(channel, account string) (ok, warn bool) {
+	return l.allowAt(channel, account, time.Now())
+}
+
+func (l *UserRateLimiter) allowAt(channel, account string, now time.Time) (ok, warn bool) {
+	perMinute, burst := l.budget(channel)
+	if perMinute <= 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := channel + "|" + account
+	b, exists := l.buckets[key]
+	if !exists {
+		if len(l.buckets) >= pruneThreshold {
+			l.pruneLocked(now)
+		}
+		b = &userBucket{
+			limiter: rate.NewLimiter(rate.Limit(perMinute/60), burst),
+			fullIn:  time.Duration(float64(burst) / perMinute * float64(time.Minute)),
+		}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	if b.limiter.AllowN(now, 1) {
+		b.warned = false
+		return true, false
+	}
+	if b.warned {
+		return false, false
+	}
+	b.warned = true
+	return false, true
+}
+
+func (l *UserRateLimiter) pruneLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= b.fullIn {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Message returns the reply for users who are sending too fast.
+func (l *UserRateLimiter) Message() string {
+	return l.cfg.Message
+}
@@ -0,0 +1,99 @@
+package channels
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestUserRateLimiter(t *testing.T) {
+	l := NewUserRateLimiter(config.RateLimitConfig{
+		Enabled:   true,
+		PerMinute: 6, // one message every 10s
+		Burst:     2,
+		Channels:  map[string]config.RateBudget{"webhook": {PerMinute: 0}},
+	})
+	now := time.Now()
+
+	var got []string
+	for i := range 4 {
+		switch ok, warn := l.allowAt("telegram", "telegram:1", now.Add(time.Duration(i)*time.Second)); {
+		case ok:
+			got = append(got, "ok")
+		case warn:
+			got = append(got, "warn")
+		default:
+			got = append(got, "drop")
+		}
+	}
+	if want := "ok ok warn drop"; strings.Join(got, " ") != want {
+		t.Errorf("burst = %q, want %q", got, want)
+	}
+	// Other users and channels have their own buckets.
+	if ok, _ := l.allowAt("telegram", "telegram:2", now); !ok {
+		t.Error("second user limited")
+	}
+	if ok, _ := l.allowAt("discord", "telegram:1", now); !ok {
+		t.Error("same user on another channel limited")
+	}
+	// The bucket refills, and a new flood warns again.
+	if ok, _ := l.allowAt("telegram", "telegram:1", now.Add(15*time.Second)); !ok {
+		t.Error("not refilled after 15s")
+	}
+	if _, warn := l.allowAt("telegram", "telegram:1", now.Add(15*time.Second)); !warn {
+		t.Error("second flood not warned")
+	}
+	// A zero rate means no limit.
+	for range 10 {
+		if ok, _ := l.allowAt("webhook", "webhook:1", now); !ok {
+			t.Fatal("unlimited channel limited")
+		}
+	}
+}
+
+func TestHandleMessage_RateLimited(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ch := NewBaseChannel("telegram", nil, messageBus, nil)
+	ch.SetRateLimiter(NewUserRateLimiter(config.RateLimitConfig{
+		Enabled: true, PerMinute: 1, Burst: 1, Message: "Slow down, please.",
+	}))
+	ctx := context.Background()
+	sender := bus.SenderInfo{Platform: "telegram", PlatformID: "1", CanonicalID: "telegram:1"}
+	for _, id := range []string{"m1", "m2", "m3"} {
+		ch.HandleMessage(ctx, bus.Peer{Kind: "direct", ID: "1"}, id, "1", "1", "hi", nil, nil, sender)
+	}
+
+	if msg := consumeInbound(t, messageBus); msg.MessageID != "m1" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if out := consumeOutbound(t, messageBus); out.Content != "Slow down, please." {
+		t.Errorf("reply = %+v", out)
+	}
+	recvCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if msg, ok := messageBus.SubscribeOutbound(recvCtx); ok {
+		t.Errorf("second reply %+v", msg)
+	}
+}
+
+func TestHandleFeedback_RateLimited(t *testing.T) {
+	messageBus := bus.NewMessageBus()
+	ch := NewBaseChannel("telegram", nil, messageBus, nil)
+	ch.SetRateLimiter(NewUserRateLimiter(config.RateLimitConfig{Enabled: true, PerMinute: 1, Burst: 1}))
+	ctx := context.Background()
+	sender := bus.SenderInfo{Platform: "telegram", PlatformID: "1", CanonicalID: "telegram:1"}
+	for range 3 {
+		ch.HandleFeedback(ctx, bus.Peer{Kind: "direct", ID: "1"}, "1", "1", sender, bus.Feedback{Rating: 1})
+	}
+
+	if msg := consumeInbound(t, messageBus); msg.Feedback == nil {
+		t.Errorf("inbound = %+v", msg)
+	}
+	if n := messageBus.PendingInbound(); n != 0 {
+		t.Errorf("%d more reactions got through the rate limit", n)
+	}
+}
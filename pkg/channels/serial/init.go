@@ -0,0 +1,16 @@
+package serial
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("serial", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		if !cfg.Channels.Serial.Enabled {
+			return nil, nil
+		}
+		return NewSerialChannel(cfg.Channels.Serial, b)
+	})
+}
@@ -0,0 +1,278 @@
+// Package serial implements a channel on a serial console, for boards that
+// expose only a UART: prompts are typed in a terminal program such as
+// picocom or screen and the replies are printed there.
+package serial
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	maxLineBytes = 4096
+	reopenDelay  = 5 * time.Second
+	banner       = "picoclaw ready. Type a message and press Enter."
+)
+
+// SerialChannel reads lines from a serial port and sends each one to the
+// agent. It does its own line editing (backspace, Ctrl-C, Ctrl-U) because
+// the port is in raw mode. The port is reopened if it goes away, e.g. when
+// a USB adapter is replugged.
+type SerialChannel struct {
+	*channels.BaseChannel
+	config config.SerialConfig
+	chatID string
+	open   func() (io.ReadWriteCloser, error)
+
+	mu   sync.Mutex // guards port and line
+	port io.ReadWriteCloser
+	line []byte // the line being typed, redrawn after a reply
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSerialChannel creates a serial channel for cfg.Device.
+func NewSerialChannel(cfg config.SerialConfig, messageBus *bus.MessageBus) (*SerialChannel, error) {
+	if cfg.Device == "" {
+		return nil, errors.New("serial device is required")
+	}
+	if cfg.BaudRate == 0 {
+		cfg.BaudRate = 115200
+	}
+	base := channels.NewBaseChannel("serial", cfg, messageBus, nil,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	c := &SerialChannel{
+		BaseChannel: base,
+		config:      cfg,
+		chatID:      filepath.Base(cfg.Device), // no slashes: the chat ID ends up in a file name
+	}
+	c.open = func() (io.ReadWriteCloser, error) { return openPort(cfg.Device, cfg.BaudRate) }
+	return c, nil
+}
+
+// Start opens the port and begins reading.
+func (c *SerialChannel) Start(ctx context.Context) error {
+	logger.InfoCF("serial", "Starting serial channel", map[string]any{
+		"device": c.config.Device,
+		"baud":   c.config.BaudRate,
+	})
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+	c.SetRunning(true)
+	go c.run()
+	return nil
+}
+
+// Stop closes the port.
+func (c *SerialChannel) Stop(ctx context.Context) error {
+	logger.InfoC("serial", "Stopping serial channel")
+	c.SetRunning(false)
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	c.mu.Lock()
+	if c.port != nil {
+		c.port.Close() // unblocks the reader
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func (c *SerialChannel) run() {
+	defer close(c.done)
+	for c.ctx.Err() == nil {
+		port, err := c.open()
+		if err != nil {
+			logger.WarnCF("serial", "Failed to open serial port", map[string]any{
+				"device": c.config.Device,
+				"error":  err.Error(),
+			})
+		} else {
+			err = c.serve(port)
+			if c.ctx.Err() == nil {
+				logger.WarnCF("serial", "Serial port closed; reopening", map[string]any{"error": fmt.Sprint(err)})
+			}
+		}
+		select {
+		case <-time.After(reopenDelay):
+		case <-c.ctx.Done():
+		}
+	}
+}
+
+// serve reads from port until it fails.
+func (c *SerialChannel) serve(port io.ReadWriteCloser) error {
+	c.mu.Lock()
+	if c.ctx.Err() != nil {
+		c.mu.Unlock()
+		port.Close()
+		return nil
+	}
+	c.port = port
+	c.line = c.line[:0]
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.port = nil
+		c.mu.Unlock()
+		port.Close()
+	}()
+
+	c.write("\r\n" + banner + "\r\n" + c.config.Prompt)
+	r := bufio.NewReader(port)
+	var ed editor
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		echo, line, submitted := ed.feed(b)
+		c.line = append(c.line[:0], ed.buf...)
+		c.mu.Unlock()
+
+		if c.config.Echo && echo != "" {
+			c.write(echo)
+		}
+		if !submitted {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			c.write(c.config.Prompt)
+			continue
+		}
+		c.publish(line)
+	}
+}
+
+func (c *SerialChannel) publish(line string) {
+	sender := bus.SenderInfo{
+		Platform:    "serial",
+		PlatformID:  c.chatID,
+		CanonicalID: identity.BuildCanonicalID("serial", c.chatID),
+		Username:    c.chatID,
+		DisplayName: c.chatID,
+	}
+	messageID := fmt.Sprintf("serial-%d", time.Now().UnixNano())
+	metadata := map[string]string{
+		"platform": "serial",
+		"device":   c.config.Device,
+	}
+	peer := bus.Peer{Kind: "direct", ID: c.chatID}
+	c.HandleMessage(c.ctx, peer, messageID, c.chatID, c.chatID, line, nil, metadata, sender)
+}
+
+// Send prints the reply, then the prompt and whatever is being typed.
+func (c *SerialChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	c.mu.Lock()
+	pending := string(c.line)
+	connected := c.port != nil
+	c.mu.Unlock()
+	if !connected {
+		return fmt.Errorf("serial port %s is not open: %w", c.config.Device, channels.ErrTemporary)
+	}
+
+	text := strings.ReplaceAll(strings.TrimRight(msg.Content, "\n"), "\r\n", "\n")
+	out := "\r\n" + strings.ReplaceAll(text, "\n", "\r\n") + "\r\n\r\n" + c.config.Prompt + pending
+	if err := c.write(out); err != nil {
+		return fmt.Errorf("serial write: %v: %w", err, channels.ErrTemporary)
+	}
+	return nil
+}
+
+func (c *SerialChannel) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.port == nil {
+		return errors.New("port closed")
+	}
+	_, err := io.WriteString(c.port, s)
+	return err
+}
+
+// editor is a minimal line editor for a raw-mode terminal.
+type editor struct {
+	buf    []byte
+	escape int  // 1 after ESC, 2 inside an ESC [ sequence
+	sawCR  bool // the last byte was CR; an LF right after it is part of the same Enter
+}
+
+// feed processes one input byte. It returns what to echo and, when the
+// byte ends a line, the line.
+func (e *editor) feed(b byte) (echo, line string, submitted bool) {
+	crlf := e.sawCR && b == '\n'
+	e.sawCR = b == '\r'
+	switch {
+	case e.escape == 1: // arrow keys and friends are not supported; swallow them
+		e.escape = 0
+		if b == '[' || b == 'O' {
+			e.escape = 2
+		}
+		return "", "", false
+	case e.escape == 2:
+		if b >= 0x40 && b <= 0x7e {
+			e.escape = 0
+		}
+		return "", "", false
+	}
+
+	switch b {
+	case '\r', '\n':
+		if crlf {
+			return "", "", false
+		}
+		line = string(e.buf)
+		e.buf = e.buf[:0]
+		return "\r\n", line, true
+	case 0x7f, '\b': // backspace: drop the last rune
+		if len(e.buf) == 0 {
+			return "", "", false
+		}
+		_, size := utf8.DecodeLastRune(e.buf)
+		e.buf = e.buf[:len(e.buf)-size]
+		return "\b \b", "", false
+	case 0x03: // Ctrl-C: abandon the line
+		e.buf = e.buf[:0]
+		return "^C\r\n", "", true
+	case 0x15: // Ctrl-U: erase the line
+		n := utf8.RuneCount(e.buf)
+		e.buf = e.buf[:0]
+		return strings.Repeat("\b \b", n), "", false
+	case 0x1b:
+		e.escape = 1
+		return "", "", false
+	}
+	if b < 0x20 && b != '\t' {
+		return "", "", false
+	}
+	if len(e.buf) >= maxLineBytes {
+		return "\a", "", false
+	}
+	e.buf = append(e.buf, b)
+	return string([]byte{b}), "", false
+}
@@ -0,0 +1,115 @@
+package serial
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestEditor(t *testing.T) {
+	var e editor
+	var echoed strings.Builder
+	var lines []string
+	for _, b := range []byte("helo\x7flo\r\nwö\x7forld\x1b[Ax\n\r\x03") {
+		echo, line, submitted := e.feed(b)
+		echoed.WriteString(echo)
+		if submitted {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{"hello", "worldx", "", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if !strings.HasPrefix(echoed.String(), "helo\b \blo\r\nwö\b \bo") || !strings.HasSuffix(echoed.String(), "^C\r\n") {
+		t.Errorf("echo = %q", echoed.String())
+	}
+}
+
+// terminal collects what the channel writes to the other end of a pipe.
+type terminal struct {
+	conn net.Conn
+	mu   sync.Mutex
+	out  strings.Builder
+}
+
+func (term *terminal) read() {
+	buf := make([]byte, 256)
+	for {
+		n, err := term.conn.Read(buf)
+		term.mu.Lock()
+		term.out.Write(buf[:n])
+		term.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// waitFor waits until the output contains s.
+func (term *terminal) waitFor(t *testing.T, s string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		term.mu.Lock()
+		ok := strings.Contains(term.out.String(), s)
+		term.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	term.mu.Lock()
+	defer term.mu.Unlock()
+	t.Fatalf("output %q does not contain %q", term.out.String(), s)
+}
+
+func TestSerialChannel(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	ch, err := NewSerialChannel(config.SerialConfig{Device: "/dev/ttyS1", Echo: true, Prompt: "> "}, msgBus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, remote := net.Pipe()
+	ch.open = func() (io.ReadWriteCloser, error) { return local, nil }
+	term := &terminal{conn: remote}
+	go term.read()
+
+	ctx := context.Background()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Stop(ctx)
+	term.waitFor(t, banner+"\r\n> ")
+
+	if _, err := remote.Write([]byte("what time is it?\r")); err != nil {
+		t.Fatal(err)
+	}
+	recvCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(recvCtx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.Content != "what time is it?" || msg.ChatID != "ttyS1" || msg.Channel != "serial" {
+		t.Errorf("message = %+v", msg)
+	}
+	term.waitFor(t, "what time is it?\r\n")
+
+	// A reply arriving mid-line is printed before the partial input.
+	if _, err := remote.Write([]byte("and")); err != nil {
+		t.Fatal(err)
+	}
+	term.waitFor(t, "\r\nand")
+	if err := ch.Send(ctx, bus.OutboundMessage{Channel: "serial", ChatID: "ttyS1", Content: "Noon.\nSunny."}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	term.waitFor(t, "\r\nNoon.\r\nSunny.\r\n\r\n> and")
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package serial
+
+import (
+	"errors"
+	"os"
+)
+
+// openPort is a stub for non-Linux platforms.
+func openPort(string, int) (*os.File, error) {
+	return nil, errors.New("the serial channel is only supported on Linux")
+}
@@ -361,6 +361,7 @@ func (c *SlackChannel) handleMessageEvent(ev *slackevents.MessageEvent) {
 		"message_ts": messageTS,
 		"channel_id": channelID,
 		"thread_ts":  threadTS,
+		"thread_id":  threadTS,
 		"platform":   "slack",
 		"team_id":    c.teamID,
 	}
@@ -432,6 +433,7 @@ func (c *SlackChannel) handleAppMention(ev *slackevents.AppMentionEvent) {
 		"message_ts": messageTS,
 		"channel_id": channelID,
 		"thread_ts":  threadTS,
+		"thread_id":  threadTS,
 		"platform":   "slack",
 		"is_mention": "true",
 		"team_id":    c.teamID,
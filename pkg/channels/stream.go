@@ -0,0 +1,81 @@
+package channels
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// GetStreamer implements bus.StreamDelegate for StreamingCapable channels.
+func (m *Manager) GetStreamer(_ context.Context, channel, chatID string) (bus.Streamer, bool) {
+	m.mu.RLock()
+	ch, ok := m.channels[channel]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	sc, ok := ch.(StreamingCapable)
+	if !ok {
+		return nil, false
+	}
+	return &managedStreamer{m: m, ch: sc, key: channel + ":" + chatID, chatID: chatID}, true
+}
+
+// managedStreamer starts the channel's stream when the first text arrives,
+// handing it the placeholder and stopping the typing indicator then rather
+// than while the model is still thinking.
+type managedStreamer struct {
+	m        *Manager
+	ch       StreamingCapable
+	key      string
+	chatID   string
+	inner    bus.Streamer
+	declined bool
+}
+
+func (s *managedStreamer) Update(ctx context.Context, content string) error {
+	if s.inner == nil {
+		if s.declined {
+			return nil
+		}
+		placeholderID := ""
+		if v, ok := s.m.placeholders.Load(s.key); ok {
+			if entry, ok := v.(placeholderEntry); ok {
+				placeholderID = entry.id
+			}
+		}
+		inner, err := s.ch.BeginStream(ctx, s.chatID, placeholderID)
+		if err != nil {
+			return err
+		}
+		if inner == nil {
+			s.declined = true
+			return nil
+		}
+		s.inner = inner
+		s.m.placeholders.Delete(s.key)
+		s.m.stopIndicators(s.key)
+	}
+	return s.inner.Update(ctx, content)
+}
+
+func (s *managedStreamer) Finalize(ctx context.Context, content string) error {
+	if s.inner == nil {
+		return bus.ErrNotStreamed
+	}
+	return s.inner.Finalize(ctx, content)
+}
+
+// buttonsAsText appends buttons to content as a list of replies, for
+// channels that cannot show buttons.
+func buttonsAsText(content string, buttons []string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimRight(content, "\n"))
+	sb.WriteString("\n")
+	for _, b := range buttons {
+		sb.WriteString("\n• ")
+		sb.WriteString(b)
+	}
+	return sb.String()
+}
@@ -0,0 +1,159 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// mockButtonChannel implements ButtonCapable.
+type mockButtonChannel struct {
+	mockChannel
+	buttonsFn func(ctx context.Context, msg bus.OutboundMessage, placeholderID string) error
+}
+
+func (m *mockButtonChannel) SendButtons(ctx context.Context, msg bus.OutboundMessage, placeholderID string) error {
+	return m.buttonsFn(ctx, msg, placeholderID)
+}
+
+func TestRunWorker_ButtonsAsTextFallback(t *testing.T) {
+	m := newTestManager()
+	received := make(chan bus.OutboundMessage, 1)
+	ch := &mockChannel{sendFn: func(_ context.Context, msg bus.OutboundMessage) error {
+		received <- msg
+		return nil
+	}}
+	w := &channelWorker{
+		ch:      ch,
+		queue:   make(chan bus.OutboundMessage, 1),
+		done:    make(chan struct{}),
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+	go m.runWorker(t.Context(), "test", w)
+
+	w.queue <- bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "Which one?\n", Buttons: []string{"Red", "Blue"}}
+	select {
+	case msg := <-received:
+		if msg.Content != "Which one?\n\n• Red\n• Blue" || msg.Buttons != nil {
+			t.Errorf("message = %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not sent")
+	}
+}
+
+func TestSendWithRetry_ButtonsReplacePlaceholder(t *testing.T) {
+	m := newTestManager()
+	var typingStopped bool
+	m.RecordTypingStop("test", "1", func() { typingStopped = true })
+	m.RecordPlaceholder("test", "1", "99")
+
+	var gotPlaceholder string
+	ch := &mockButtonChannel{
+		mockChannel: mockChannel{sendFn: func(context.Context, bus.OutboundMessage) error {
+			t.Error("Send called for a message with buttons")
+			return nil
+		}},
+		buttonsFn: func(_ context.Context, msg bus.OutboundMessage, placeholderID string) error {
+			gotPlaceholder = placeholderID
+			return nil
+		},
+	}
+	w := &channelWorker{ch: ch, limiter: rate.NewLimiter(rate.Inf, 1)}
+	m.sendWithRetry(context.Background(), "test", w,
+		bus.OutboundMessage{Channel: "test", ChatID: "1", Content: "Which one?", Buttons: []string{"Red"}})
+
+	if gotPlaceholder != "99" || !typingStopped {
+		t.Errorf("placeholder = %q, typing stopped = %v", gotPlaceholder, typingStopped)
+	}
+	if _, ok := m.placeholders.Load("test:1"); ok {
+		t.Error("placeholder not consumed")
+	}
+}
+
+// mockStreamingChannel implements StreamingCapable.
+type mockStreamingChannel struct {
+	mockChannel
+	decline     bool
+	placeholder string
+	updates     []string
+	final       string
+}
+
+func (m *mockStreamingChannel) BeginStream(_ context.Context, _, messageID string) (bus.Streamer, error) {
+	if m.decline {
+		return nil, nil
+	}
+	m.placeholder = messageID
+	return m, nil
+}
+
+func (m *mockStreamingChannel) Update(_ context.Context, content string) error {
+	m.updates = append(m.updates, content)
+	return nil
+}
+
+func (m *mockStreamingChannel) Finalize(_ context.Context, content string) error {
+	m.final = content
+	return nil
+}
+
+func TestGetStreamer(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+	m.channels["plain"] = &mockChannel{}
+	if _, ok := m.GetStreamer(ctx, "plain", "1"); ok {
+		t.Error("streamer for a channel that cannot stream")
+	}
+
+	ch := &mockStreamingChannel{}
+	m.channels["test"] = ch
+	var typingStopped bool
+	m.RecordTypingStop("test", "1", func() { typingStopped = true })
+	m.RecordPlaceholder("test", "1", "99")
+
+	s, ok := m.GetStreamer(ctx, "test", "1")
+	if !ok {
+		t.Fatal("no streamer")
+	}
+	if typingStopped {
+		t.Error("typing stopped before any text arrived")
+	}
+	if err := s.Update(ctx, "Hel"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Finalize(ctx, "Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if ch.placeholder != "99" || !typingStopped || len(ch.updates) != 1 || ch.final != "Hello" {
+		t.Errorf("placeholder = %q, typing stopped = %v, updates = %q, final = %q",
+			ch.placeholder, typingStopped, ch.updates, ch.final)
+	}
+	if _, ok := m.placeholders.Load("test:1"); ok {
+		t.Error("placeholder not consumed")
+	}
+}
+
+func TestGetStreamer_Declined(t *testing.T) {
+	m := newTestManager()
+	ctx := context.Background()
+	m.channels["test"] = &mockStreamingChannel{decline: true}
+	m.RecordPlaceholder("test", "1", "99")
+
+	s, _ := m.GetStreamer(ctx, "test", "1")
+	if err := s.Update(ctx, "Hel"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Finalize(ctx, "Hello"); !errors.Is(err, bus.ErrNotStreamed) {
+		t.Errorf("Finalize = %v, want ErrNotStreamed", err)
+	}
+	// The placeholder is left for the reply sent the usual way.
+	if _, ok := m.placeholders.Load("test:1"); !ok {
+		t.Error("placeholder consumed by a declined stream")
+	}
+}
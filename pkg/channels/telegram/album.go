@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// albumWait is how long after the latest part of an album the album is
+// considered complete. Telegram delivers the parts within a second or so.
+const albumWait = 1500 * time.Millisecond
+
+// album collects the messages of one media group.
+type album struct {
+	sender bus.SenderInfo
+	parts  []*telego.Message
+	timer  *time.Timer
+}
+
+// queueAlbumPart adds message to its album, which is processed once no
+// more parts have arrived for albumWait.
+func (c *TelegramChannel) queueAlbumPart(message *telego.Message, sender bus.SenderInfo) {
+	key := message.MediaGroupID
+	c.albumMu.Lock()
+	defer c.albumMu.Unlock()
+	if c.albums == nil {
+		c.albums = make(map[string]*album)
+	}
+	a, ok := c.albums[key]
+	if !ok {
+		a = &album{sender: sender}
+		a.timer = time.AfterFunc(c.albumWait(), func() { c.flushAlbum(key) })
+		c.albums[key] = a
+	} else {
+		a.timer.Reset(c.albumWait())
+	}
+	a.parts = append(a.parts, message)
+}
+
+func (c *TelegramChannel) flushAlbum(key string) {
+	c.albumMu.Lock()
+	a, ok := c.albums[key]
+	delete(c.albums, key)
+	c.albumMu.Unlock()
+	if !ok || len(a.parts) == 0 {
+		return
+	}
+	slices.SortFunc(a.parts, func(x, y *telego.Message) int { return cmp.Compare(x.MessageID, y.MessageID) })
+	c.processMessages(c.ctx, a.sender, a.parts)
+}
+
+func (c *TelegramChannel) albumWait() time.Duration {
+	if c.albumDelay > 0 {
+		return c.albumDelay
+	}
+	return albumWait
+}
@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// buttonDataPrefix starts the callback data of reply buttons, followed by
+// the button's index. The text is read back from the keyboard because
+// callback data is limited to 64 bytes.
+const buttonDataPrefix = "reply:"
+
+// SendButtons implements channels.ButtonCapable. The buttons are shown one
+// per row under the message; the placeholder, if any, becomes the message.
+func (c *TelegramChannel) SendButtons(ctx context.Context, msg bus.OutboundMessage, placeholderID string) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+
+	chatID, err := parseChatID(msg.ChatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %s: %w", msg.ChatID, channels.ErrSendFailed)
+	}
+
+	markup := buttonKeyboard(msg.Buttons)
+	if mid, err := strconv.Atoi(placeholderID); err == nil {
+		if err := c.editText(ctx, chatID, mid, msg.Content, markup); err == nil {
+			return nil
+		}
+		// edit failed → send a new message
+	}
+	if _, err := c.sendText(ctx, chatID, msg.Content, markup); err != nil {
+		return fmt.Errorf("telegram send: %w", channels.ErrTemporary)
+	}
+	return nil
+}
+
+func buttonKeyboard(buttons []string) *telego.InlineKeyboardMarkup {
+	rows := make([][]telego.InlineKeyboardButton, 0, len(buttons))
+	for i, text := range buttons {
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(text).WithCallbackData(buttonDataPrefix+strconv.Itoa(i)),
+		))
+	}
+	return tu.InlineKeyboard(rows...)
+}
+
+// handleCallbackQuery handles a tap on a reply button: the buttons are
+// replaced by the choice, which is then sent to the agent as if the user
+// had typed it.
+func (c *TelegramChannel) handleCallbackQuery(ctx context.Context, query *telego.CallbackQuery) error {
+	answer := tu.CallbackQuery(query.ID)
+	defer func() { _ = c.bot.AnswerCallbackQuery(ctx, answer) }()
+
+	if query.Message == nil || !query.Message.IsAccessible() {
+		answer.Text = "This choice has expired."
+		return nil
+	}
+	message := query.Message.Message()
+	choice, ok := buttonText(message, query.Data)
+	if !ok {
+		answer.Text = "This choice has expired."
+		return nil
+	}
+
+	user := &query.From
+	platformID := fmt.Sprintf("%d", user.ID)
+	sender := bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    user.Username,
+		DisplayName: user.FirstName,
+	}
+	if !c.IsAllowedSender(sender) {
+		logger.DebugCF("telegram", "Button press rejected by allowlist", map[string]any{
+			"user_id": platformID,
+		})
+		return nil
+	}
+
+	// Keep the message's formatting; the choice is appended after it.
+	edit := tu.EditMessageText(tu.ID(message.Chat.ID), message.MessageID, message.Text+"\n\n✓ "+choice)
+	edit.Entities = message.Entities
+	if _, err := c.bot.EditMessageText(ctx, edit); err != nil {
+		logger.WarnCF("telegram", "Failed to mark button choice", map[string]any{
+			"error": err.Error(),
+		})
+	}
+
+	c.dispatch(user, message.Chat, message.MessageID, choice, nil, sender)
+	return nil
+}
+
+// buttonText returns the text of the button whose callback data is data.
+func buttonText(message *telego.Message, data string) (string, bool) {
+	if message.ReplyMarkup == nil || !strings.HasPrefix(data, buttonDataPrefix) {
+		return "", false
+	}
+	for _, row := range message.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == data {
+				return button.Text, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
+
+// maxSentMessages bounds how many sent messages are remembered for the
+// reactions to them.
+const maxSentMessages = 1000
+
+type sentKey struct {
+	chatID    int64
+	messageID int
+}
+
+// sentMessages remembers the text of the latest messages the bot sent:
+// a reaction names only the message, and the agent finds the answer it
+// rates by its text.
+type sentMessages struct {
+	mu    sync.Mutex
+	texts map[sentKey]string
+	order []sentKey // oldest first
+}
+
+func (s *sentMessages) remember(chatID int64, messageID int, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.texts == nil {
+		s.texts = make(map[sentKey]string)
+	}
+	key := sentKey{chatID, messageID}
+	if _, ok := s.texts[key]; !ok {
+		s.order = append(s.order, key)
+	}
+	s.texts[key] = text
+	if len(s.order) > maxSentMessages {
+		delete(s.texts, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *sentMessages) text(chatID int64, messageID int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text, ok := s.texts[sentKey{chatID, messageID}]
+	return text, ok
+}
+
+// reactionRating returns the rating of the first reaction that gives one.
+func reactionRating(reactions []telego.ReactionType) int {
+	for _, r := range reactions {
+		if emoji, ok := r.(*telego.ReactionTypeEmoji); ok {
+			if rating := channels.ReactionRating(emoji.Emoji); rating != 0 {
+				return rating
+			}
+		}
+	}
+	return 0
+}
+
+// handleReaction turns a 👍 or 👎 on one of the bot's answers into
+// feedback. Taking the reaction back takes back the rating.
+func (c *TelegramChannel) handleReaction(_ context.Context, r *telego.MessageReactionUpdated) error {
+	if r == nil || r.User == nil {
+		return nil // anonymous group admins cannot be told apart
+	}
+	text, ok := c.sent.text(r.Chat.ID, r.MessageID)
+	if !ok {
+		return nil // not a message of the bot, or sent too long ago
+	}
+	rating := reactionRating(r.NewReaction)
+	if rating == 0 && reactionRating(r.OldReaction) == 0 {
+		return nil
+	}
+
+	platformID := fmt.Sprintf("%d", r.User.ID)
+	sender := bus.SenderInfo{
+		Platform:    "telegram",
+		PlatformID:  platformID,
+		CanonicalID: identity.BuildCanonicalID("telegram", platformID),
+		Username:    r.User.Username,
+		DisplayName: r.User.FirstName,
+	}
+	peer := bus.Peer{Kind: "direct", ID: platformID}
+	if r.Chat.Type != "private" {
+		peer = bus.Peer{Kind: "group", ID: fmt.Sprintf("%d", r.Chat.ID)}
+	}
+	c.HandleFeedback(c.ctx, peer, platformID, fmt.Sprintf("%d", r.Chat.ID), sender,
+		bus.Feedback{Rating: rating, Answer: text})
+	return nil
+}
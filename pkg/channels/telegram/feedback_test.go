@@ -0,0 +1,57 @@
+package telegram
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestHandleReaction(t *testing.T) {
+	ch, _, messageBus := newRichTestChannel(t, 4096)
+	ctx := context.Background()
+	answer := bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: "Paris is **the** capital."}
+	if err := ch.Send(ctx, answer); err != nil {
+		t.Fatal(err)
+	}
+	sentID := 101 // the fake API numbers its answers from 101
+
+	thumbsUp := []telego.ReactionType{&telego.ReactionTypeEmoji{Type: telego.ReactionEmoji, Emoji: "👍"}}
+	reaction := &telego.MessageReactionUpdated{
+		Chat:        telego.Chat{ID: 42, Type: "private"},
+		MessageID:   sentID,
+		User:        &telego.User{ID: 42, FirstName: "Alice"},
+		NewReaction: thumbsUp,
+	}
+	if err := ch.handleReaction(ctx, reaction); err != nil {
+		t.Fatal(err)
+	}
+	msg := consumeInbound(t, messageBus)
+	if msg.Feedback == nil || msg.Feedback.Rating != 1 || msg.Feedback.Answer != "Paris is **the** capital." ||
+		msg.Sender.CanonicalID != "telegram:42" || msg.Peer.Kind != "direct" || msg.ChatID != "42" {
+		t.Fatalf("inbound = %+v", msg)
+	}
+
+	// Taking the reaction back takes back the rating.
+	reaction.OldReaction, reaction.NewReaction = thumbsUp, nil
+	ch.handleReaction(ctx, reaction)
+	if msg := consumeInbound(t, messageBus); msg.Feedback == nil || msg.Feedback.Rating != 0 {
+		t.Fatalf("inbound = %+v", msg)
+	}
+
+	// Reactions that rate nothing, or to messages the bot did not send, are
+	// not feedback.
+	reaction.OldReaction = nil
+	reaction.NewReaction = []telego.ReactionType{&telego.ReactionTypeEmoji{Type: telego.ReactionEmoji, Emoji: "🤔"}}
+	ch.handleReaction(ctx, reaction)
+	reaction.MessageID, reaction.NewReaction = 7, thumbsUp
+	ch.handleReaction(ctx, reaction)
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if msg, ok := messageBus.ConsumeInbound(waitCtx); ok {
+		t.Errorf("unexpected inbound %+v", msg)
+	}
+}
@@ -0,0 +1,249 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const testToken = "123456:abcdefghijklmnopqrstuvwxyz012345678"
+
+type apiCall struct {
+	method string
+	params map[string]any
+}
+
+// fakeBotAPI answers Bot API calls the way Telegram does and records them.
+type fakeBotAPI struct {
+	mu     sync.Mutex
+	calls  []apiCall
+	nextID int
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		w.Write([]byte("\xff\xd8\xff\xe0 jpeg"))
+		return
+	}
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	var params map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&params)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, apiCall{method: method, params: params})
+	f.nextID++
+	id := 100 + f.nextID
+	f.mu.Unlock()
+
+	var result any = true
+	switch method {
+	case "getMe":
+		result = map[string]any{"id": 1, "is_bot": true, "first_name": "Pico", "username": "picobot"}
+	case "getFile":
+		result = map[string]any{"file_id": params["file_id"], "file_unique_id": "u", "file_path": "photos/p.jpg"}
+	case "sendMessage":
+		chat := map[string]any{"id": params["chat_id"], "type": "private"}
+		result = map[string]any{"message_id": id, "date": 0, "chat": chat}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// take returns the calls recorded since the last take, except getMe.
+func (f *fakeBotAPI) take() []apiCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []apiCall
+	for _, c := range f.calls {
+		if c.method != "getMe" {
+			calls = append(calls, c)
+		}
+	}
+	f.calls = nil
+	return calls
+}
+
+func newRichTestChannel(t *testing.T, maxLen int) (*TelegramChannel, *fakeBotAPI, *bus.MessageBus) {
+	t.Helper()
+	api := &fakeBotAPI{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	bot, err := telego.NewBot(testToken, telego.WithAPIServer(srv.URL), telego.WithDiscardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageBus := bus.NewMessageBus()
+	cfg := &config.Config{}
+	cfg.Channels.Telegram.StreamReplies = true
+	ch := &TelegramChannel{
+		BaseChannel:    channels.NewBaseChannel("telegram", nil, messageBus, nil, channels.WithMaxMessageLength(maxLen)),
+		bot:            bot,
+		config:         cfg,
+		chatIDs:        make(map[string]int64),
+		ctx:            context.Background(),
+		albumDelay:     20 * time.Millisecond,
+		streamInterval: time.Nanosecond,
+	}
+	ch.SetRunning(true)
+	return ch, api, messageBus
+}
+
+func consumeInbound(t *testing.T, messageBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	msg, ok := messageBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestSendButtons(t *testing.T) {
+	ch, api, _ := newRichTestChannel(t, 4096)
+	ctx := context.Background()
+	msg := bus.OutboundMessage{Channel: "telegram", ChatID: "42", Content: "Which one?", Buttons: []string{"Red", "Blue"}}
+
+	// The placeholder becomes the question.
+	if err := ch.SendButtons(ctx, msg, "7"); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.take()
+	if len(calls) != 1 || calls[0].method != "editMessageText" || calls[0].params["message_id"] != float64(7) {
+		t.Fatalf("calls = %+v", calls)
+	}
+	rows := calls[0].params["reply_markup"].(map[string]any)["inline_keyboard"].([]any)
+	second := rows[1].([]any)[0].(map[string]any)
+	if len(rows) != 2 || second["text"] != "Blue" || second["callback_data"] != "reply:1" {
+		t.Errorf("keyboard = %v", rows)
+	}
+
+	if err := ch.SendButtons(ctx, msg, ""); err != nil {
+		t.Fatal(err)
+	}
+	if calls := api.take(); len(calls) != 1 || calls[0].method != "sendMessage" || calls[0].params["reply_markup"] == nil {
+		t.Errorf("calls = %+v", calls)
+	}
+}
+
+func TestHandleCallbackQuery(t *testing.T) {
+	ch, api, messageBus := newRichTestChannel(t, 4096)
+	question := &telego.Message{
+		MessageID:   7,
+		Chat:        telego.Chat{ID: 42, Type: "private"},
+		Text:        "Which one?",
+		ReplyMarkup: buttonKeyboard([]string{"Red", "Blue"}),
+	}
+	query := &telego.CallbackQuery{
+		ID:      "q1",
+		From:    telego.User{ID: 42, FirstName: "Alice"},
+		Message: question,
+		Data:    "reply:1",
+	}
+	if err := ch.handleCallbackQuery(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := consumeInbound(t, messageBus)
+	if msg.Content != "Blue" || msg.ChatID != "42" || msg.Sender.PlatformID != "42" {
+		t.Errorf("inbound = %+v", msg)
+	}
+	calls := api.take()
+	if len(calls) != 2 || calls[0].params["text"] != "Which one?\n\n✓ Blue" || calls[0].params["reply_markup"] != nil ||
+		calls[1].method != "answerCallbackQuery" {
+		t.Errorf("calls = %+v", calls)
+	}
+
+	// A tap on a button the message no longer has is only acknowledged.
+	query.Data = "reply:5"
+	if err := ch.handleCallbackQuery(context.Background(), query); err != nil {
+		t.Fatal(err)
+	}
+	if calls := api.take(); len(calls) != 1 || calls[0].params["text"] != "This choice has expired." {
+		t.Errorf("calls = %+v", calls)
+	}
+}
+
+func TestStreamer(t *testing.T) {
+	ch, api, _ := newRichTestChannel(t, 60)
+	ctx := context.Background()
+
+	s, err := ch.BeginStream(ctx, "42", "7")
+	if err != nil || s == nil {
+		t.Fatalf("BeginStream = %v, %v", s, err)
+	}
+	if err := s.Update(ctx, "Looking"); err != nil {
+		t.Fatal(err)
+	}
+	calls := api.take()
+	if len(calls) != 1 || calls[0].method != "editMessageText" || calls[0].params["text"] != "Looking" {
+		t.Fatalf("update calls = %+v", calls)
+	}
+
+	// A draft longer than one message continues in a new one.
+	long := strings.Repeat("word ", 20)
+	if err := s.Update(ctx, long); err != nil {
+		t.Fatal(err)
+	}
+	calls = api.take()
+	if len(calls) < 2 || calls[0].method != "editMessageText" || calls[len(calls)-1].method != "sendMessage" {
+		t.Fatalf("long update calls = %+v", calls)
+	}
+	added := len(calls) - 1
+
+	// A shorter final reply removes the extra messages.
+	if err := s.Finalize(ctx, "Done."); err != nil {
+		t.Fatal(err)
+	}
+	calls = api.take()
+	if len(calls) != 2 || calls[0].params["text"] != "Done." || calls[1].method != "deleteMessages" {
+		t.Fatalf("finalize calls = %+v", calls)
+	}
+	if ids := calls[1].params["message_ids"].([]any); len(ids) != added || ids[0] == float64(7) {
+		t.Errorf("deleted %v", ids)
+	}
+
+	ch.config.Channels.Telegram.StreamReplies = false
+	if s, err := ch.BeginStream(ctx, "42", ""); s != nil || err != nil {
+		t.Errorf("BeginStream with streaming off = %v, %v", s, err)
+	}
+}
+
+func TestHandleMessage_Album(t *testing.T) {
+	ch, _, messageBus := newRichTestChannel(t, 4096)
+	part := func(id int, caption string) *telego.Message {
+		return &telego.Message{
+			MessageID:    id,
+			MediaGroupID: "album1",
+			Chat:         telego.Chat{ID: 42, Type: "private"},
+			From:         &telego.User{ID: 42, FirstName: "Alice"},
+			Caption:      caption,
+			Photo:        []telego.PhotoSize{{FileID: "f" + caption}},
+		}
+	}
+	// Parts may arrive out of order.
+	for _, m := range []*telego.Message{part(11, ""), part(10, "What are these?")} {
+		if err := ch.handleMessage(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg := consumeInbound(t, messageBus)
+	for _, path := range msg.Media {
+		t.Cleanup(func() { os.Remove(path) })
+	}
+	if msg.Content != "What are these?\n[image: photo]\n[image: photo]" || len(msg.Media) != 2 || msg.MessageID != "10" {
+		t.Errorf("inbound = %+v", msg)
+	}
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+)
+
+// streamEditInterval spaces the edits of a streamed reply; Telegram
+// throttles bots that edit a chat's messages more than about once a second.
+const streamEditInterval = time.Second
+
+// BeginStream implements channels.StreamingCapable.
+func (c *TelegramChannel) BeginStream(_ context.Context, chatID, messageID string) (bus.Streamer, error) {
+	if !c.config.Channels.Telegram.StreamReplies {
+		return nil, nil
+	}
+	cid, err := parseChatID(chatID)
+	if err != nil {
+		return nil, err
+	}
+	s := &streamer{c: c, chatID: cid, interval: streamEditInterval}
+	if c.streamInterval > 0 {
+		s.interval = c.streamInterval
+	}
+	if mid, err := strconv.Atoi(messageID); err == nil {
+		s.ids = []int{mid}
+		s.shown = []string{""}
+	}
+	return s, nil
+}
+
+// streamer shows a reply by editing messages as it grows. A reply longer
+// than one message continues in further messages.
+type streamer struct {
+	c        *TelegramChannel
+	chatID   int64
+	interval time.Duration
+	ids      []int    // messages showing the reply, in order
+	shown    []string // the text each of them shows
+	last     time.Time
+}
+
+func (s *streamer) Update(ctx context.Context, content string) error {
+	if time.Since(s.last) < s.interval {
+		return nil
+	}
+	return s.render(ctx, content, false)
+}
+
+func (s *streamer) Finalize(ctx context.Context, content string) error {
+	return s.render(ctx, content, true)
+}
+
+// render makes the messages show content, splitting it where the channel
+// manager would. When final, messages left over from a longer draft are
+// deleted.
+func (s *streamer) render(ctx context.Context, content string, final bool) error {
+	s.last = time.Now()
+	chunks := channels.SplitMessage(content, s.c.MaxMessageLength())
+	for i, chunk := range chunks {
+		if i < len(s.ids) {
+			if s.shown[i] == chunk {
+				continue
+			}
+			if err := s.c.editText(ctx, s.chatID, s.ids[i], chunk, nil); err != nil {
+				return err
+			}
+			s.shown[i] = chunk
+			continue
+		}
+		sent, err := s.c.sendText(ctx, s.chatID, chunk, nil)
+		if err != nil {
+			return err
+		}
+		s.ids = append(s.ids, sent.MessageID)
+		s.shown = append(s.shown, chunk)
+	}
+	if final && len(s.ids) > len(chunks) {
+		extra := s.ids[len(chunks):]
+		if err := s.c.bot.DeleteMessages(ctx, &telego.DeleteMessagesParams{
+			ChatID:     tu.ID(s.chatID),
+			MessageIDs: extra,
+		}); err != nil {
+			return err
+		}
+		s.ids = s.ids[:len(chunks)]
+		s.shown = s.shown[:len(chunks)]
+	}
+	return nil
+}
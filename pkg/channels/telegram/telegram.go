@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mymmrac/telego"
@@ -49,6 +50,14 @@ type TelegramChannel struct {
 
 	registerFunc     func(context.Context, []commands.Definition) error
 	commandRegCancel context.CancelFunc
+
+	albumMu    sync.Mutex
+	albums     map[string]*album // media group ID → parts received so far
+	albumDelay time.Duration     // overrides albumWait in tests
+
+	streamInterval time.Duration // overrides streamEditInterval in tests
+
+	sent sentMessages // for the reactions to the bot's messages
 }
 
 func NewTelegramChannel(cfg *config.Config, bus *bus.MessageBus) (*TelegramChannel, error) {
@@ -108,6 +117,8 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 
 	updates, err := c.bot.UpdatesViaLongPolling(c.ctx, &telego.GetUpdatesParams{
 		Timeout: 30,
+		// Reactions are only delivered when asked for.
+		AllowedUpdates: []string{"message", "callback_query", "message_reaction"},
 	})
 	if err != nil {
 		c.cancel()
@@ -125,6 +136,14 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return c.handleMessage(ctx, &message)
 	}, th.AnyMessage())
 
+	bh.HandleCallbackQuery(func(ctx *th.Context, query telego.CallbackQuery) error {
+		return c.handleCallbackQuery(ctx, &query)
+	}, th.CallbackDataPrefix(buttonDataPrefix))
+
+	bh.HandleMessageReaction(func(ctx *th.Context, reaction telego.MessageReactionUpdated) error {
+		return c.handleReaction(ctx, &reaction)
+	})
+
 	c.SetRunning(true)
 	logger.InfoCF("telegram", "Telegram bot connected", map[string]any{
 		"username": c.bot.Username(),
@@ -173,23 +192,72 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		return fmt.Errorf("invalid chat ID %s: %w", msg.ChatID, channels.ErrSendFailed)
 	}
 
-	htmlContent := markdownToTelegramHTML(msg.Content)
-
 	// Typing/placeholder handled by Manager.preSend — just send the message
-	tgMsg := tu.Message(tu.ID(chatID), htmlContent)
+	if _, err = c.sendText(ctx, chatID, msg.Content, nil); err != nil {
+		return fmt.Errorf("telegram send: %w", channels.ErrTemporary)
+	}
+
+	return nil
+}
+
+// sendText sends markdown content as HTML, falling back to plain text if
+// Telegram rejects the HTML.
+func (c *TelegramChannel) sendText(
+	ctx context.Context,
+	chatID int64,
+	content string,
+	markup *telego.InlineKeyboardMarkup,
+) (*telego.Message, error) {
+	tgMsg := tu.Message(tu.ID(chatID), markdownToTelegramHTML(content))
 	tgMsg.ParseMode = telego.ModeHTML
+	if markup != nil {
+		tgMsg.ReplyMarkup = markup
+	}
 
-	if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
+	sent, err := c.bot.SendMessage(ctx, tgMsg)
+	if err != nil {
 		logger.ErrorCF("telegram", "HTML parse failed, falling back to plain text", map[string]any{
 			"error": err.Error(),
 		})
+		tgMsg.Text = content
 		tgMsg.ParseMode = ""
-		if _, err = c.bot.SendMessage(ctx, tgMsg); err != nil {
-			return fmt.Errorf("telegram send: %w", channels.ErrTemporary)
-		}
+		sent, err = c.bot.SendMessage(ctx, tgMsg)
+	}
+	if err == nil {
+		c.sent.remember(chatID, sent.MessageID, content)
 	}
+	return sent, err
+}
 
-	return nil
+// editText replaces the text of a message as sendText sends it. A nil
+// markup removes the message's buttons.
+func (c *TelegramChannel) editText(
+	ctx context.Context,
+	chatID int64,
+	messageID int,
+	content string,
+	markup *telego.InlineKeyboardMarkup,
+) error {
+	editMsg := tu.EditMessageText(tu.ID(chatID), messageID, markdownToTelegramHTML(content))
+	editMsg.ParseMode = telego.ModeHTML
+	editMsg.ReplyMarkup = markup
+
+	_, err := c.bot.EditMessageText(ctx, editMsg)
+	if err != nil && !isNotModified(err) {
+		editMsg.Text = content
+		editMsg.ParseMode = ""
+		_, err = c.bot.EditMessageText(ctx, editMsg)
+	}
+	if err == nil || isNotModified(err) {
+		c.sent.remember(chatID, messageID, content)
+		return nil
+	}
+	return err
+}
+
+// isNotModified reports Telegram's refusal of an edit that changes nothing.
+func isNotModified(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "message is not modified")
 }
 
 // StartTyping implements channels.TypingCapable.
@@ -235,8 +303,11 @@ func (c *TelegramChannel) EditMessage(ctx context.Context, chatID string, messag
 	htmlContent := markdownToTelegramHTML(content)
 	editMsg := tu.EditMessageText(tu.ID(cid), mid, htmlContent)
 	editMsg.ParseMode = telego.ModeHTML
-	_, err = c.bot.EditMessageText(ctx, editMsg)
-	return err
+	if _, err = c.bot.EditMessageText(ctx, editMsg); err != nil {
+		return err
+	}
+	c.sent.remember(cid, mid, content)
+	return nil
 }
 
 // SendPlaceholder implements channels.PlaceholderCapable.
@@ -373,22 +444,37 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		return nil
 	}
 
-	chatID := message.Chat.ID
-	c.chatIDs[platformID] = chatID
+	c.chatIDs[platformID] = message.Chat.ID
+
+	// The photos of an album arrive as separate messages; answer them once.
+	if message.MediaGroupID != "" {
+		c.queueAlbumPart(message, sender)
+		return nil
+	}
+	c.processMessages(ctx, sender, []*telego.Message{message})
+	return nil
+}
+
+// processMessages turns one message, or the parts of an album, into one
+// inbound message.
+func (c *TelegramChannel) processMessages(ctx context.Context, sender bus.SenderInfo, messages []*telego.Message) {
+	first := messages[0]
+	chatID := first.Chat.ID
 
 	content := ""
 	mediaPaths := []string{}
 
 	chatIDStr := fmt.Sprintf("%d", chatID)
-	messageIDStr := fmt.Sprintf("%d", message.MessageID)
+	messageIDStr := fmt.Sprintf("%d", first.MessageID)
 	scope := channels.BuildMediaScope("telegram", chatIDStr, messageIDStr)
 
 	// Helper to register a local file with the media store
-	storeMedia := func(localPath, filename string) string {
+	storeMedia := func(localPath, filename, contentType string) string {
 		if store := c.GetMediaStore(); store != nil {
 			ref, err := store.Store(localPath, media.MediaMeta{
-				Filename: filename,
-				Source:   "telegram",
+				Filename:    filename,
+				ContentType: contentType,
+				Source:      "telegram",
 			}, scope)
 			if err == nil {
 				return ref
@@ -396,61 +482,57 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 		}
 		return localPath // fallback: use raw path
 	}
-
-	if message.Text != "" {
-		content += message.Text
-	}
-
-	if message.Caption != "" {
+	appendLine := func(line string) {
 		if content != "" {
 			content += "\n"
 		}
-		content += message.Caption
+		content += line
 	}
 
-	if len(message.Photo) > 0 {
-		photo := message.Photo[len(message.Photo)-1]
-		photoPath := c.downloadPhoto(ctx, photo.FileID)
-		if photoPath != "" {
-			mediaPaths = append(mediaPaths, storeMedia(photoPath, "photo.jpg"))
-			if content != "" {
-				content += "\n"
-			}
-			content += "[image: photo]"
+	for _, message := range messages {
+		if message.Text != "" {
+			appendLine(message.Text)
 		}
-	}
 
-	if message.Voice != nil {
-		voicePath := c.downloadFile(ctx, message.Voice.FileID, ".ogg")
-		if voicePath != "" {
-			mediaPaths = append(mediaPaths, storeMedia(voicePath, "voice.ogg"))
+		if message.Caption != "" {
+			appendLine(message.Caption)
+		}
 
-			if content != "" {
-				content += "\n"
+		if len(message.Photo) > 0 {
+			photo := message.Photo[len(message.Photo)-1]
+			photoPath := c.downloadPhoto(ctx, photo.FileID)
+			if photoPath != "" {
+				mediaPaths = append(mediaPaths, storeMedia(photoPath, "photo.jpg", "image/jpeg"))
+				appendLine("[image: photo]")
 			}
-			content += "[voice]"
 		}
-	}
 
-	if message.Audio != nil {
-		audioPath := c.downloadFile(ctx, message.Audio.FileID, ".mp3")
-		if audioPath != "" {
-			mediaPaths = append(mediaPaths, storeMedia(audioPath, "audio.mp3"))
-			if content != "" {
-				content += "\n"
+		if message.Voice != nil {
+			voicePath := c.downloadFile(ctx, message.Voice.FileID, ".ogg")
+			if voicePath != "" {
+				mediaPaths = append(mediaPaths, storeMedia(voicePath, "voice.ogg", "audio/ogg"))
+				appendLine("[voice]")
 			}
-			content += "[audio]"
 		}
-	}
 
-	if message.Document != nil {
-		docPath := c.downloadFile(ctx, message.Document.FileID, "")
-		if docPath != "" {
-			mediaPaths = append(mediaPaths, storeMedia(docPath, "document"))
-			if content != "" {
-				content += "\n"
+		if message.Audio != nil {
+			audioPath := c.downloadFile(ctx, message.Audio.FileID, ".mp3")
+			if audioPath != "" {
+				mediaPaths = append(mediaPaths, storeMedia(audioPath, "audio.mp3", message.Audio.MimeType))
+				appendLine("[audio]")
+			}
+		}
+
+		if message.Document != nil {
+			docPath := c.downloadFile(ctx, message.Document.FileID, "")
+			if docPath != "" {
+				filename := message.Document.FileName
+				if filename == "" {
+					filename = "document"
+				}
+				mediaPaths = append(mediaPaths, storeMedia(docPath, filename, message.Document.MimeType))
+				appendLine("[file]")
 			}
-			content += "[file]"
 		}
 	}
 
@@ -459,18 +541,35 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 	}
 
 	// In group chats, apply unified group trigger filtering
-	if message.Chat.Type != "private" {
-		isMentioned := c.isBotMentioned(message)
+	if first.Chat.Type != "private" {
+		isMentioned := false
+		for _, message := range messages {
+			isMentioned = isMentioned || c.isBotMentioned(message)
+		}
 		if isMentioned {
 			content = c.stripBotMention(content)
 		}
 		respond, cleaned := c.ShouldRespondInGroup(isMentioned, content)
 		if !respond {
-			return nil
+			return
 		}
 		content = cleaned
 	}
 
+	c.dispatch(first.From, first.Chat, first.MessageID, content, mediaPaths, sender)
+}
+
+// dispatch publishes content from user in chat to the agent.
+func (c *TelegramChannel) dispatch(
+	user *telego.User,
+	chat telego.Chat,
+	messageID int,
+	content string,
+	mediaPaths []string,
+	sender bus.SenderInfo,
+) {
+	chatID := chat.ID
+
 	logger.DebugCF("telegram", "Received message", map[string]any{
 		"sender_id": sender.CanonicalID,
 		"chat_id":   fmt.Sprintf("%d", chatID),
@@ -481,32 +580,30 @@ func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Mes
 
 	peerKind := "direct"
 	peerID := fmt.Sprintf("%d", user.ID)
-	if message.Chat.Type != "private" {
+	if chat.Type != "private" {
 		peerKind = "group"
 		peerID = fmt.Sprintf("%d", chatID)
 	}
 
 	peer := bus.Peer{Kind: peerKind, ID: peerID}
-	messageID := fmt.Sprintf("%d", message.MessageID)
 
 	metadata := map[string]string{
 		"user_id":    fmt.Sprintf("%d", user.ID),
 		"username":   user.Username,
 		"first_name": user.FirstName,
-		"is_group":   fmt.Sprintf("%t", message.Chat.Type != "private"),
+		"is_group":   fmt.Sprintf("%t", chat.Type != "private"),
 	}
 
 	c.HandleMessage(c.ctx,
 		peer,
-		messageID,
-		platformID,
+		fmt.Sprintf("%d", messageID),
+		sender.PlatformID,
 		fmt.Sprintf("%d", chatID),
 		content,
 		mediaPaths,
 		metadata,
 		sender,
 	)
-	return nil
 }
 
 func (c *TelegramChannel) downloadPhoto(ctx context.Context, fileID string) string {
@@ -0,0 +1,26 @@
+package voice
+
+import (
+	"errors"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	stt "github.com/sipeed/picoclaw/pkg/voice"
+)
+
+func init() {
+	channels.RegisterFactory("voice", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		vc := cfg.Channels.Voice
+		if !vc.Enabled {
+			return nil, nil
+		}
+		var transcriber stt.Transcriber
+		if vc.WhisperModel != "" {
+			transcriber = stt.NewWhisperCppTranscriber(vc.WhisperBinary, vc.WhisperModel, vc.Language)
+		} else if transcriber = stt.DetectTranscriber(cfg); transcriber == nil {
+			return nil, errors.New("voice channel needs whisper_model or a Groq API key for transcription")
+		}
+		return NewVoiceChannel(vc, transcriber, b)
+	})
+}
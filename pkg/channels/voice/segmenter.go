@@ -0,0 +1,118 @@
+package voice
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	sampleRate     = 16000
+	bytesPerSample = 2
+	frameMs        = 30
+	frameBytes     = sampleRate * frameMs / 1000 * bytesPerSample
+	preRollFrames  = 10 // audio kept from before speech starts, so the first syllable is not cut off
+	minSpeechMs    = 300
+)
+
+// segmenter splits a stream of 16-bit little-endian mono PCM frames into
+// utterances with a simple energy detector: speech starts when a frame's
+// RMS level reaches threshold and ends after silenceFrames quiet frames or
+// at maxFrames.
+type segmenter struct {
+	threshold     float64
+	silenceFrames int
+	maxFrames     int
+
+	preRoll [][]byte
+	speech  []byte
+	active  bool
+	voiced  int
+	silent  int
+}
+
+func newSegmenter(threshold, silenceMs, maxSpeechSeconds int) *segmenter {
+	return &segmenter{
+		threshold:     float64(threshold),
+		silenceFrames: max(silenceMs/frameMs, 1),
+		maxFrames:     max(maxSpeechSeconds*1000/frameMs, 1),
+	}
+}
+
+// feed adds one frame of frameBytes bytes and returns the utterance it
+// completes, if any. Bursts shorter than minSpeechMs (clicks, knocks) are
+// dropped.
+func (s *segmenter) feed(frame []byte) []byte {
+	voiced := rms(frame) >= s.threshold
+	if !s.active {
+		if !voiced {
+			s.preRoll = append(s.preRoll, frame)
+			if len(s.preRoll) > preRollFrames {
+				s.preRoll = s.preRoll[1:]
+			}
+			return nil
+		}
+		s.active = true
+		for _, f := range s.preRoll {
+			s.speech = append(s.speech, f...)
+		}
+		s.preRoll = s.preRoll[:0]
+	}
+
+	s.speech = append(s.speech, frame...)
+	if voiced {
+		s.voiced++
+		s.silent = 0
+	} else {
+		s.silent++
+	}
+	if s.silent < s.silenceFrames && len(s.speech)/frameBytes < s.maxFrames {
+		return nil
+	}
+
+	utterance, voicedFrames := s.speech, s.voiced
+	s.reset()
+	if voicedFrames*frameMs < minSpeechMs {
+		return nil
+	}
+	return utterance
+}
+
+// reset discards any audio collected so far.
+func (s *segmenter) reset() {
+	s.speech = nil
+	s.preRoll = s.preRoll[:0]
+	s.active = false
+	s.voiced = 0
+	s.silent = 0
+}
+
+func rms(frame []byte) float64 {
+	n := len(frame) / bytesPerSample
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		v := float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// wavBytes wraps pcm in a WAV header for the transcriber.
+func wavBytes(pcm []byte) []byte {
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(out[20:], 1)  // PCM
+	binary.LittleEndian.PutUint16(out[22:], 1)  // mono
+	binary.LittleEndian.PutUint32(out[24:], sampleRate)
+	binary.LittleEndian.PutUint32(out[28:], sampleRate*bytesPerSample)
+	binary.LittleEndian.PutUint16(out[32:], bytesPerSample)
+	binary.LittleEndian.PutUint16(out[34:], 8*bytesPerSample)
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
@@ -0,0 +1,383 @@
+// Package voice implements a hands-free voice channel for boards with a
+// microphone and a speaker: it listens continuously, cuts the audio into
+// utterances, transcribes them, and speaks the agent's replies.
+package voice
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+	stt "github.com/sipeed/picoclaw/pkg/voice"
+)
+
+const (
+	// chatID is the single conversation of the device's microphone.
+	chatID = "local"
+
+	defaultRestartDelay = 5 * time.Second
+	transcribeTimeout   = 60 * time.Second
+	wakeAcknowledgement = "Yes?"
+)
+
+// VoiceChannel turns speech into messages and replies into speech. Input is
+// muted while a reply is spoken so the channel does not answer itself.
+type VoiceChannel struct {
+	*channels.BaseChannel
+	config       config.VoiceConfig
+	transcriber  stt.Transcriber
+	wakeWord     []string
+	followUp     time.Duration
+	restartDelay time.Duration
+
+	speaking   atomic.Bool
+	speakMu    sync.Mutex // one reply at a time
+	mu         sync.Mutex
+	awakeUntil time.Time // utterances before then need no wake word
+
+	utterances chan []byte
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewVoiceChannel creates a voice channel that transcribes with transcriber.
+func NewVoiceChannel(
+	cfg config.VoiceConfig,
+	transcriber stt.Transcriber,
+	messageBus *bus.MessageBus,
+) (*VoiceChannel, error) {
+	if len(cfg.RecordCommand) == 0 {
+		return nil, errors.New("voice record_command is required")
+	}
+	if len(cfg.TTSCommand) == 0 {
+		return nil, errors.New("voice tts_command is required")
+	}
+	if transcriber == nil {
+		return nil, errors.New("voice channel needs a transcriber")
+	}
+	if cfg.SilenceThreshold <= 0 {
+		cfg.SilenceThreshold = 500
+	}
+	if cfg.SilenceMs <= 0 {
+		cfg.SilenceMs = 800
+	}
+	if cfg.MaxSpeechSeconds <= 0 {
+		cfg.MaxSpeechSeconds = 15
+	}
+
+	base := channels.NewBaseChannel("voice", cfg, messageBus, nil,
+		channels.WithReasoningChannelID(cfg.ReasoningChannelID),
+	)
+	return &VoiceChannel{
+		BaseChannel:  base,
+		config:       cfg,
+		transcriber:  transcriber,
+		wakeWord:     words(cfg.WakeWord),
+		followUp:     time.Duration(cfg.FollowUpSeconds) * time.Second,
+		restartDelay: defaultRestartDelay,
+		utterances:   make(chan []byte, 4),
+	}, nil
+}
+
+// Start begins listening.
+func (c *VoiceChannel) Start(ctx context.Context) error {
+	logger.InfoCF("voice", "Starting voice channel", map[string]any{
+		"transcriber": c.transcriber.Name(),
+		"wake_word":   c.config.WakeWord,
+	})
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.SetRunning(true)
+	c.wg.Add(2)
+	go c.capture()
+	go c.transcribeLoop()
+	return nil
+}
+
+// Stop stops the recorder and waits for pending work to finish.
+func (c *VoiceChannel) Stop(ctx context.Context) error {
+	logger.InfoC("voice", "Stopping voice channel")
+	c.SetRunning(false)
+	if c.cancel != nil {
+		c.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+// capture runs the record command, restarting it if it exits (for example
+// when a USB microphone is replugged), and queues the utterances it hears.
+func (c *VoiceChannel) capture() {
+	defer c.wg.Done()
+	defer close(c.utterances)
+	for {
+		err := c.record()
+		if c.ctx.Err() != nil {
+			return
+		}
+		logger.WarnCF("voice", "Recorder stopped; restarting", map[string]any{
+			"command": c.config.RecordCommand[0],
+			"error":   fmt.Sprint(err),
+		})
+		select {
+		case <-time.After(c.restartDelay):
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *VoiceChannel) record() error {
+	cmd := exec.CommandContext(c.ctx, c.config.RecordCommand[0], c.config.RecordCommand[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	seg := newSegmenter(c.config.SilenceThreshold, c.config.SilenceMs, c.config.MaxSpeechSeconds)
+	r := bufio.NewReaderSize(stdout, 8*frameBytes)
+	var readErr error
+	for {
+		frame := make([]byte, frameBytes)
+		if _, readErr = io.ReadFull(r, frame); readErr != nil {
+			break
+		}
+		if c.speaking.Load() {
+			seg.reset()
+			continue
+		}
+		if utterance := seg.feed(frame); utterance != nil {
+			select {
+			case c.utterances <- utterance:
+			default:
+				logger.WarnC("voice", "Transcription is falling behind; dropping an utterance")
+			}
+		}
+	}
+
+	waitErr := cmd.Wait()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(utils.Truncate(msg, 300))
+	}
+	if waitErr != nil {
+		return waitErr
+	}
+	if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+		return errors.New("recorder exited")
+	}
+	return readErr
+}
+
+func (c *VoiceChannel) transcribeLoop() {
+	defer c.wg.Done()
+	for utterance := range c.utterances {
+		c.handleUtterance(utterance)
+	}
+}
+
+// handleUtterance transcribes one utterance and, if it is meant for the
+// agent, publishes it.
+func (c *VoiceChannel) handleUtterance(pcm []byte) {
+	text, err := c.transcribe(pcm)
+	if err != nil {
+		if c.ctx.Err() == nil {
+			logger.WarnCF("voice", "Transcription failed", map[string]any{"error": err.Error()})
+		}
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	prompt, ok := c.accept(text, time.Now())
+	if !ok {
+		logger.DebugCF("voice", "Ignoring speech without wake word", map[string]any{"text": utils.Truncate(text, 80)})
+		return
+	}
+	if prompt == "" {
+		// Just the wake word: acknowledge and keep listening for the request.
+		c.speak(c.ctx, wakeAcknowledgement)
+		return
+	}
+
+	logger.InfoCF("voice", "Heard request", map[string]any{"text": utils.Truncate(prompt, 80)})
+	sender := bus.SenderInfo{
+		Platform:    "voice",
+		PlatformID:  chatID,
+		CanonicalID: identity.BuildCanonicalID("voice", chatID),
+		Username:    chatID,
+		DisplayName: "Voice",
+	}
+	messageID := fmt.Sprintf("voice-%d", time.Now().UnixNano())
+	metadata := map[string]string{"platform": "voice"}
+	peer := bus.Peer{Kind: "direct", ID: chatID}
+	c.HandleMessage(c.ctx, peer, messageID, chatID, chatID, prompt, nil, metadata, sender)
+}
+
+func (c *VoiceChannel) transcribe(pcm []byte) (string, error) {
+	f, err := os.CreateTemp("", "picoclaw-voice-*.wav")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(wavBytes(pcm))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, transcribeTimeout)
+	defer cancel()
+	result, err := c.transcriber.Transcribe(ctx, f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Text), nil
+}
+
+// accept decides whether text is addressed to the agent and returns it
+// without the wake word. Without a configured wake word everything is.
+func (c *VoiceChannel) accept(text string, now time.Time) (string, bool) {
+	if len(c.wakeWord) == 0 {
+		return text, true
+	}
+	if rest, ok := stripWakeWord(text, c.wakeWord); ok {
+		if rest == "" {
+			c.mu.Lock()
+			c.awakeUntil = now.Add(max(c.followUp, 5*time.Second))
+			c.mu.Unlock()
+		}
+		return rest, true
+	}
+	c.mu.Lock()
+	awake := now.Before(c.awakeUntil)
+	c.mu.Unlock()
+	return text, awake
+}
+
+// stripWakeWord reports whether text starts with the wake word (ignoring
+// case and punctuation) and returns the rest.
+func stripWakeWord(text string, wake []string) (string, bool) {
+	fields := strings.Fields(text)
+	matched := 0
+	for i, field := range fields {
+		w := normalizeWord(field)
+		if w == "" {
+			continue
+		}
+		if w != wake[matched] {
+			return "", false
+		}
+		if matched++; matched == len(wake) {
+			return strings.TrimLeftFunc(strings.Join(fields[i+1:], " "), func(r rune) bool {
+				return unicode.IsPunct(r) || unicode.IsSpace(r)
+			}), true
+		}
+	}
+	return "", false
+}
+
+func words(s string) []string {
+	var out []string
+	for _, field := range strings.Fields(s) {
+		if w := normalizeWord(field); w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func normalizeWord(s string) string {
+	return strings.ToLower(strings.TrimFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }))
+}
+
+// Send speaks the reply. The wake word is not needed for a follow-up
+// question for a few seconds afterwards.
+func (c *VoiceChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	text := speakable(msg.Content)
+	if text == "" {
+		return nil
+	}
+	if err := c.speak(ctx, text); err != nil {
+		return fmt.Errorf("voice tts: %v: %w", err, channels.ErrSendFailed)
+	}
+	if c.followUp > 0 {
+		c.mu.Lock()
+		c.awakeUntil = time.Now().Add(c.followUp)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// speak runs the TTS command with text on stdin, muting the microphone
+// until it finishes.
+func (c *VoiceChannel) speak(ctx context.Context, text string) error {
+	c.speakMu.Lock()
+	defer c.speakMu.Unlock()
+	c.speaking.Store(true)
+	defer c.speaking.Store(false)
+
+	cmd := exec.CommandContext(ctx, c.config.TTSCommand[0], c.config.TTSCommand[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			msg = err.Error()
+		}
+		logger.WarnCF("voice", "TTS command failed", map[string]any{"error": utils.Truncate(msg, 300)})
+		return errors.New(msg)
+	}
+	return nil
+}
+
+var (
+	codeBlockRe = regexp.MustCompile("(?s)```.*?```")
+	mdLinkRe    = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	urlRe       = regexp.MustCompile(`https?://\S+`)
+	mdBulletRe  = regexp.MustCompile(`(?m)^\s*[-+]\s+`)
+	mdMarkRe    = regexp.MustCompile("[*_`#>|~]+")
+)
+
+// speakable turns a Markdown reply into text worth reading aloud.
+func speakable(s string) string {
+	s = codeBlockRe.ReplaceAllString(s, " (code omitted) ")
+	s = mdLinkRe.ReplaceAllString(s, "$1")
+	s = urlRe.ReplaceAllString(s, "a link")
+	s = mdBulletRe.ReplaceAllString(s, "")
+	s = mdMarkRe.ReplaceAllString(s, "")
+	return strings.Join(strings.Fields(s), " ")
+}
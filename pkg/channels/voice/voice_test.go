@@ -0,0 +1,198 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	stt "github.com/sipeed/picoclaw/pkg/voice"
+)
+
+// pcm returns n frames of a square wave at the given amplitude; 0 is silence.
+func pcm(n int, amplitude int16) []byte {
+	out := make([]byte, n*frameBytes)
+	for i := 0; i < len(out)/2; i++ {
+		v := amplitude
+		if i%2 == 1 {
+			v = -amplitude
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(v))
+	}
+	return out
+}
+
+func feedAll(s *segmenter, audio []byte) [][]byte {
+	var utterances [][]byte
+	for off := 0; off+frameBytes <= len(audio); off += frameBytes {
+		if u := s.feed(audio[off : off+frameBytes]); u != nil {
+			utterances = append(utterances, u)
+		}
+	}
+	return utterances
+}
+
+func TestSegmenter(t *testing.T) {
+	s := newSegmenter(500, 300, 15) // 10 frames of silence end an utterance
+
+	audio := append(pcm(20, 0), pcm(20, 3000)...)
+	audio = append(audio, pcm(12, 0)...)
+	got := feedAll(s, audio)
+	if len(got) != 1 {
+		t.Fatalf("utterances = %d, want 1", len(got))
+	}
+	// Pre-roll, speech and the silence that ended it.
+	if want := (preRollFrames + 20 + 10) * frameBytes; len(got[0]) != want {
+		t.Errorf("utterance = %d bytes, want %d", len(got[0]), want)
+	}
+
+	// A click is too short to be speech.
+	if got := feedAll(s, append(pcm(3, 3000), pcm(12, 0)...)); len(got) != 0 {
+		t.Errorf("click produced %d utterances", len(got))
+	}
+
+	// Speech that never pauses is cut at the maximum length.
+	s = newSegmenter(500, 300, 1)
+	if got := feedAll(s, pcm(70, 3000)); len(got) != 2 || len(got[0]) != 33*frameBytes {
+		t.Errorf("long speech: %d utterances", len(got))
+	}
+}
+
+func TestWavBytes(t *testing.T) {
+	wav := wavBytes(pcm(1, 100))
+	if string(wav[:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " || string(wav[36:40]) != "data" {
+		t.Fatalf("bad header % x", wav[:44])
+	}
+	if binary.LittleEndian.Uint32(wav[24:]) != sampleRate || binary.LittleEndian.Uint32(wav[40:]) != frameBytes {
+		t.Errorf("sample rate or data size wrong")
+	}
+}
+
+func TestStripWakeWord(t *testing.T) {
+	wake := words("Hey Pico")
+	tests := []struct {
+		text, rest string
+		ok         bool
+	}{
+		{"Hey, Pico! What time is it?", "What time is it?", true},
+		{"hey pico", "", true},
+		{"Hey Pico, — turn on the lights.", "turn on the lights.", true},
+		{"Hey there Pico", "", false},
+		{"What time is it?", "", false},
+	}
+	for _, tt := range tests {
+		rest, ok := stripWakeWord(tt.text, wake)
+		if rest != tt.rest || ok != tt.ok {
+			t.Errorf("stripWakeWord(%q) = %q, %v; want %q, %v", tt.text, rest, ok, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestSpeakable(t *testing.T) {
+	in := "**Done.** See [the docs](https://example.org) or https://x.org.\n\n```go\nfmt.Println()\n```\n- one\n- two"
+	if got := speakable(in); got != "Done. See the docs or a link (code omitted) one two" {
+		t.Errorf("speakable = %q", got)
+	}
+}
+
+type fakeTranscriber struct{ texts chan string }
+
+func (f *fakeTranscriber) Name() string { return "fake" }
+
+func (f *fakeTranscriber) Transcribe(_ context.Context, path string) (*stt.TranscriptionResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("RIFF")) {
+		return nil, errors.New("not a WAV file")
+	}
+	return &stt.TranscriptionResponse{Text: <-f.texts}, nil
+}
+
+func newTestChannel(t *testing.T, cfg config.VoiceConfig, texts ...string) (*VoiceChannel, *bus.MessageBus) {
+	t.Helper()
+	tr := &fakeTranscriber{texts: make(chan string, len(texts))}
+	for _, text := range texts {
+		tr.texts <- text
+	}
+	msgBus := bus.NewMessageBus()
+	ch, err := NewVoiceChannel(cfg, tr, msgBus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.restartDelay = time.Hour
+	return ch, msgBus
+}
+
+func TestVoiceChannel_WakeWordAndReply(t *testing.T) {
+	dir := t.TempDir()
+	// Two utterances separated by silence, played by a fake recorder.
+	audio := append(pcm(20, 3000), pcm(40, 0)...)
+	audio = append(audio, audio...)
+	recording := filepath.Join(dir, "mic.raw")
+	if err := os.WriteFile(recording, audio, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spoken := filepath.Join(dir, "spoken.txt")
+
+	ch, msgBus := newTestChannel(t, config.VoiceConfig{
+		RecordCommand:   []string{"cat", recording},
+		TTSCommand:      []string{"sh", "-c", "cat >> " + spoken},
+		WakeWord:        "hey pico",
+		FollowUpSeconds: 8,
+	}, "Turn it up", "Hey Pico, what time is it?")
+	ctx := context.Background()
+	if err := ch.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer ch.Stop(ctx)
+
+	// The first utterance lacks the wake word and is ignored.
+	recvCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(recvCtx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	if msg.Content != "what time is it?" || msg.Channel != "voice" || msg.ChatID != chatID {
+		t.Errorf("message = %+v", msg)
+	}
+
+	reply := bus.OutboundMessage{Channel: "voice", ChatID: chatID, Content: "It is **noon**."}
+	if err := ch.Send(ctx, reply); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if data, _ := os.ReadFile(spoken); string(data) != "It is noon." {
+		t.Errorf("spoken = %q", data)
+	}
+	// Right after a reply, a follow-up needs no wake word.
+	if text, ok := ch.accept("and tomorrow?", time.Now()); !ok || text != "and tomorrow?" {
+		t.Errorf("follow-up = %q, %v", text, ok)
+	}
+	if _, ok := ch.accept("and tomorrow?", time.Now().Add(10*time.Second)); ok {
+		t.Error("follow-up window did not close")
+	}
+}
+
+func TestNewVoiceChannel_Validation(t *testing.T) {
+	tr := &fakeTranscriber{}
+	msgBus := bus.NewMessageBus()
+	if _, err := NewVoiceChannel(config.VoiceConfig{TTSCommand: []string{"x"}}, tr, msgBus); err == nil {
+		t.Error("expected error without record_command")
+	}
+	if _, err := NewVoiceChannel(config.VoiceConfig{RecordCommand: []string{"x"}}, tr, msgBus); err == nil {
+		t.Error("expected error without tts_command")
+	}
+	cfg := config.VoiceConfig{RecordCommand: []string{"x"}, TTSCommand: []string{"y"}}
+	if _, err := NewVoiceChannel(cfg, nil, msgBus); err == nil || !strings.Contains(err.Error(), "transcriber") {
+		t.Errorf("err = %v, want missing transcriber", err)
+	}
+}
@@ -0,0 +1,16 @@
+package webhook
+
+import (
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func init() {
+	channels.RegisterFactory("webhook", func(cfg *config.Config, b *bus.MessageBus) (channels.Channel, error) {
+		if !cfg.Channels.Webhook.Enabled {
+			return nil, nil
+		}
+		return NewWebhookChannel(cfg.Channels.Webhook, b)
+	})
+}
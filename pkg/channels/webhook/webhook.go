@@ -0,0 +1,260 @@
+// Package webhook implements a receive-only channel for external events.
+// Each configured hook accepts POSTs at /hooks/<name> (from Grafana, GitHub,
+// Home Assistant and the like), renders the payload through its template and
+// hands the result to the agent as a message in the hook's session.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	webhookPath     = "/hooks/"
+	maxPayloadBytes = 1 << 20
+	maxPromptRunes  = 16000
+)
+
+// defaultTemplate is used by hooks without a template of their own.
+const defaultTemplate = "Webhook {{hook}} received:\n{{json .}}"
+
+// hook is a configured webhook with its parsed template.
+type hook struct {
+	config.WebhookHookConfig
+	tmpl *template.Template
+}
+
+// WebhookChannel turns incoming webhook payloads into agent messages. The
+// agent's reply is forwarded to the hook's reply_to chat, if it has one.
+type WebhookChannel struct {
+	*channels.BaseChannel
+	hooks   map[string]*hook
+	replyTo map[string]string // session -> "channel:chat_id"
+	bus     *bus.MessageBus
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// NewWebhookChannel creates a webhook channel from cfg.
+func NewWebhookChannel(cfg config.WebhookConfig, messageBus *bus.MessageBus) (*WebhookChannel, error) {
+	if len(cfg.Hooks) == 0 {
+		return nil, fmt.Errorf("webhook hooks are required")
+	}
+
+	c := &WebhookChannel{
+		BaseChannel: channels.NewBaseChannel("webhook", cfg, messageBus, nil),
+		hooks:       make(map[string]*hook, len(cfg.Hooks)),
+		replyTo:     make(map[string]string),
+		bus:         messageBus,
+	}
+	replyOwner := make(map[string]string) // session -> hook that set its reply_to
+	for _, hc := range cfg.Hooks {
+		if hc.Name == "" || strings.ContainsAny(hc.Name, "/?#") {
+			return nil, fmt.Errorf("webhook name %q must be non-empty and path-safe", hc.Name)
+		}
+		if _, dup := c.hooks[hc.Name]; dup {
+			return nil, fmt.Errorf("webhook %q is configured twice", hc.Name)
+		}
+		if hc.Secret == "" {
+			return nil, fmt.Errorf("webhook %q: secret is required", hc.Name)
+		}
+		if hc.Session == "" {
+			hc.Session = hc.Name
+		}
+		if hc.ReplyTo != "" {
+			if ch, chatID, ok := strings.Cut(hc.ReplyTo, ":"); !ok || ch == "" || chatID == "" {
+				return nil, fmt.Errorf("webhook %q: reply_to must be \"channel:chat_id\", got %q", hc.Name, hc.ReplyTo)
+			}
+			if prev, ok := c.replyTo[hc.Session]; ok && prev != hc.ReplyTo {
+				return nil, fmt.Errorf("webhooks %q and %q share session %q but reply to different chats",
+					replyOwner[hc.Session], hc.Name, hc.Session)
+			}
+			c.replyTo[hc.Session] = hc.ReplyTo
+			replyOwner[hc.Session] = hc.Name
+		}
+
+		text := hc.Template
+		if strings.TrimSpace(text) == "" {
+			text = defaultTemplate
+		}
+		tmpl, err := template.New(hc.Name).Funcs(templateFuncs(hc.Name, nil)).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %q: invalid template: %w", hc.Name, err)
+		}
+		c.hooks[hc.Name] = &hook{WebhookHookConfig: hc, tmpl: tmpl}
+	}
+	return c, nil
+}
+
+// templateFuncs returns the functions available to templates: json renders
+// a value as indented JSON, header returns a request header and hook the
+// hook's name.
+func templateFuncs(name string, header http.Header) template.FuncMap {
+	return template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.MarshalIndent(v, "", "  ")
+			return string(data), err
+		},
+		"header": func(key string) string { return header.Get(key) },
+		"hook":   func() string { return name },
+	}
+}
+
+// Start marks the channel running; requests arrive on the shared HTTP server.
+func (c *WebhookChannel) Start(ctx context.Context) error {
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.SetRunning(true)
+	logger.InfoCF("webhook", "Webhook channel started", map[string]any{"hooks": len(c.hooks)})
+	return nil
+}
+
+// Stop stops accepting webhooks.
+func (c *WebhookChannel) Stop(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.SetRunning(false)
+	logger.InfoC("webhook", "Webhook channel stopped")
+	return nil
+}
+
+// Send forwards the agent's reply for a hook session to its reply_to chat.
+// Sessions without one have nowhere to reply, so the reply is only logged.
+func (c *WebhookChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return channels.ErrNotRunning
+	}
+	target, ok := c.replyTo[msg.ChatID]
+	if !ok {
+		logger.DebugCF("webhook", "Dropping reply for session without reply_to", map[string]any{
+			"session": msg.ChatID,
+			"preview": utils.Truncate(msg.Content, 100),
+		})
+		return nil
+	}
+	channel, chatID, _ := strings.Cut(target, ":")
+	return c.bus.PublishOutbound(ctx, bus.OutboundMessage{Channel: channel, ChatID: chatID, Content: msg.Content})
+}
+
+// WebhookPath returns the path the hooks are served under.
+func (c *WebhookChannel) WebhookPath() string {
+	return webhookPath
+}
+
+// ServeHTTP accepts a POST to /hooks/<name>, checks the hook's secret and
+// queues the rendered prompt. It replies 202 without waiting for the agent.
+func (c *WebhookChannel) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h, ok := c.hooks[strings.TrimPrefix(r.URL.Path, webhookPath)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadBytes))
+	if err != nil {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+	if !authorized(r, body, h.Secret) {
+		logger.WarnCF("webhook", "Rejected webhook with invalid secret", map[string]any{"hook": h.Name})
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	prompt, err := render(h, r.Header, body)
+	if err != nil {
+		logger.WarnCF("webhook", "Template failed", map[string]any{"hook": h.Name, "error": err.Error()})
+		http.Error(w, "Template failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if strings.TrimSpace(prompt) == "" {
+		w.WriteHeader(http.StatusNoContent) // the template chose to ignore this event
+		return
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	senderID := "webhook:" + h.Name
+	sender := bus.SenderInfo{
+		Platform:    "webhook",
+		PlatformID:  h.Name,
+		CanonicalID: identity.BuildCanonicalID("webhook", h.Name),
+		Username:    h.Name,
+		DisplayName: h.Name,
+	}
+	messageID := fmt.Sprintf("%s-%d", h.Name, time.Now().UnixNano())
+	metadata := map[string]string{
+		"platform": "webhook",
+		"hook":     h.Name,
+	}
+	peer := bus.Peer{Kind: "direct", ID: h.Session}
+	c.HandleMessage(ctx, peer, messageID, senderID, h.Session, prompt, nil, metadata, sender)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// authorized accepts the hook's secret as a GitHub-style
+// X-Hub-Signature-256 HMAC of the body, as "Authorization: Bearer <secret>",
+// in the X-Webhook-Secret header or as ?token=.
+func authorized(r *http.Request, body []byte, secret string) bool {
+	if sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(expected), []byte(sig))
+	}
+
+	got := r.Header.Get("X-Webhook-Secret")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		got = bearer
+	}
+	if got == "" {
+		got = r.URL.Query().Get("token")
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// render executes the hook's template on the payload: the decoded JSON if
+// the body is JSON, otherwise the body as a string.
+func render(h *hook, header http.Header, body []byte) (string, error) {
+	var payload any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil || dec.More() {
+		payload = string(body)
+	}
+
+	tmpl, err := h.tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Funcs(templateFuncs(h.Name, header)).Execute(&buf, payload); err != nil {
+		return "", err
+	}
+	return utils.Truncate(buf.String(), maxPromptRunes), nil
+}
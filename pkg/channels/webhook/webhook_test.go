@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func newTestChannel(t *testing.T, hooks ...config.WebhookHookConfig) (*WebhookChannel, *bus.MessageBus) {
+	t.Helper()
+	msgBus := bus.NewMessageBus()
+	ch, err := NewWebhookChannel(config.WebhookConfig{Enabled: true, Hooks: hooks}, msgBus)
+	if err != nil {
+		t.Fatalf("NewWebhookChannel: %v", err)
+	}
+	if err := ch.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return ch, msgBus
+}
+
+func post(ch *WebhookChannel, path, body string, headers ...string) int {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	ch.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func consume(t *testing.T, msgBus *bus.MessageBus) bus.InboundMessage {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.ConsumeInbound(ctx)
+	if !ok {
+		t.Fatal("no inbound message")
+	}
+	return msg
+}
+
+func TestNewWebhookChannel(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	tests := []struct {
+		name  string
+		hooks []config.WebhookHookConfig
+	}{
+		{"no hooks", nil},
+		{"no secret", []config.WebhookHookConfig{{Name: "a"}}},
+		{"bad name", []config.WebhookHookConfig{{Name: "a/b", Secret: "s"}}},
+		{"duplicate", []config.WebhookHookConfig{{Name: "a", Secret: "s"}, {Name: "a", Secret: "t"}}},
+		{"bad template", []config.WebhookHookConfig{{Name: "a", Secret: "s", Template: "{{.x"}}},
+		{"bad reply_to", []config.WebhookHookConfig{{Name: "a", Secret: "s", ReplyTo: "telegram"}}},
+		{"conflicting reply_to", []config.WebhookHookConfig{
+			{Name: "a", Secret: "s", Session: "ops", ReplyTo: "telegram:1"},
+			{Name: "b", Secret: "s", Session: "ops", ReplyTo: "telegram:2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWebhookChannel(config.WebhookConfig{Hooks: tt.hooks}, msgBus); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestWebhookChannel_Template(t *testing.T) {
+	ch, msgBus := newTestChannel(t, config.WebhookHookConfig{
+		Name:     "grafana",
+		Secret:   "s3cret",
+		Session:  "alerts",
+		Template: `{{.title}} is {{.status}} (from {{header "User-Agent"}}, hook {{hook}})`,
+	})
+
+	code := post(ch, "/hooks/grafana", `{"title":"CPU high","status":"firing"}`,
+		"Authorization", "Bearer s3cret", "User-Agent", "Grafana")
+	if code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", code)
+	}
+	msg := consume(t, msgBus)
+	if msg.Content != "CPU high is firing (from Grafana, hook grafana)" {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.Channel != "webhook" || msg.ChatID != "alerts" || msg.Metadata["hook"] != "grafana" {
+		t.Errorf("message = %+v", msg)
+	}
+}
+
+func TestWebhookChannel_DefaultTemplate(t *testing.T) {
+	ch, msgBus := newTestChannel(t, config.WebhookHookConfig{Name: "ha", Secret: "s"})
+
+	if code := post(ch, "/hooks/ha?token=s", `{"entity_id":"door.front"}`); code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", code)
+	}
+	msg := consume(t, msgBus)
+	if !strings.HasPrefix(msg.Content, "Webhook ha received:") || !strings.Contains(msg.Content, `"door.front"`) {
+		t.Errorf("content = %q", msg.Content)
+	}
+	if msg.ChatID != "ha" {
+		t.Errorf("session = %q, want hook name", msg.ChatID)
+	}
+
+	// Non-JSON bodies are passed to the template as a string.
+	if code := post(ch, "/hooks/ha", "door opened", "X-Webhook-Secret", "s"); code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", code)
+	}
+	if msg := consume(t, msgBus); !strings.Contains(msg.Content, `"door opened"`) {
+		t.Errorf("content = %q", msg.Content)
+	}
+}
+
+func TestWebhookChannel_Auth(t *testing.T) {
+	ch, _ := newTestChannel(t, config.WebhookHookConfig{Name: "github", Secret: "s"})
+	body := `{"action":"opened"}`
+
+	if code := post(ch, "/hooks/github", body); code != http.StatusForbidden {
+		t.Errorf("no secret: status = %d, want 403", code)
+	}
+	if code := post(ch, "/hooks/github", body, "X-Webhook-Secret", "wrong"); code != http.StatusForbidden {
+		t.Errorf("wrong secret: status = %d, want 403", code)
+	}
+	if code := post(ch, "/hooks/github", body, "X-Hub-Signature-256", "sha256=00"); code != http.StatusForbidden {
+		t.Errorf("bad signature: status = %d, want 403", code)
+	}
+	mac := hmac.New(sha256.New, []byte("s"))
+	mac.Write([]byte(body))
+	sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if code := post(ch, "/hooks/github", body, "X-Hub-Signature-256", sig); code != http.StatusAccepted {
+		t.Errorf("valid signature: status = %d, want 202", code)
+	}
+	if code := post(ch, "/hooks/missing", body, "X-Webhook-Secret", "s"); code != http.StatusNotFound {
+		t.Errorf("unknown hook: status = %d, want 404", code)
+	}
+}
+
+func TestWebhookChannel_EmptyPromptIgnored(t *testing.T) {
+	ch, _ := newTestChannel(t, config.WebhookHookConfig{
+		Name:     "gh",
+		Secret:   "s",
+		Template: `{{if eq .action "opened"}}New issue: {{.title}}{{end}}`,
+	})
+	if code := post(ch, "/hooks/gh", `{"action":"closed"}`, "X-Webhook-Secret", "s"); code != http.StatusNoContent {
+		t.Errorf("status = %d, want 204", code)
+	}
+}
+
+func TestWebhookChannel_SendForwardsReply(t *testing.T) {
+	ch, msgBus := newTestChannel(t,
+		config.WebhookHookConfig{Name: "grafana", Secret: "s", Session: "alerts", ReplyTo: "telegram:42"},
+		config.WebhookHookConfig{Name: "quiet", Secret: "s"},
+	)
+	ctx := context.Background()
+
+	if err := ch.Send(ctx, bus.OutboundMessage{Channel: "webhook", ChatID: "alerts", Content: "restart it"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	subCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	out, ok := msgBus.SubscribeOutbound(subCtx)
+	if !ok || out.Channel != "telegram" || out.ChatID != "42" || out.Content != "restart it" {
+		t.Errorf("forwarded = %+v, %v", out, ok)
+	}
+
+	if err := ch.Send(ctx, bus.OutboundMessage{Channel: "webhook", ChatID: "quiet", Content: "ok"}); err != nil {
+		t.Errorf("Send without reply_to: %v", err)
+	}
+}
@@ -52,6 +52,7 @@ type WhatsAppNativeChannel struct {
 	storePath    string
 	client       *whatsmeow.Client
 	container    *sqlstore.Container
+	db           *sql.DB // the store's database, owned by container
 	mu           sync.Mutex
 	runCtx       context.Context
 	runCancel    context.CancelFunc
@@ -133,6 +134,7 @@ func (c *WhatsAppNativeChannel) Start(ctx context.Context) error {
 
 	c.mu.Lock()
 	c.container = container
+	c.db = db
 	c.client = client
 	c.mu.Unlock()
 
@@ -150,6 +152,7 @@ func (c *WhatsAppNativeChannel) Start(ctx context.Context) error {
 		c.mu.Lock()
 		c.client = nil
 		c.container = nil
+		c.db = nil
 		c.mu.Unlock()
 		_ = container.Close()
 	}()
@@ -231,6 +234,7 @@ func (c *WhatsAppNativeChannel) Stop(ctx context.Context) error {
 	c.mu.Lock()
 	client := c.client
 	container := c.container
+	db := c.db
 	c.mu.Unlock()
 
 	if client != nil {
@@ -257,8 +261,16 @@ func (c *WhatsAppNativeChannel) Stop(ctx context.Context) error {
 	c.mu.Lock()
 	c.client = nil
 	c.container = nil
+	c.db = nil
 	c.mu.Unlock()
 
+	if db != nil {
+		// Fold the write-ahead log into the database file, so that the store
+		// is complete on its own once the gateway has stopped.
+		if _, err := db.ExecContext(context.WithoutCancel(ctx), "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			logger.WarnCF("whatsapp", "Failed to checkpoint the session store", map[string]any{"error": err.Error()})
+		}
+	}
 	if container != nil {
 		_ = container.Close()
 	}
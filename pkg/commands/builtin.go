@@ -12,5 +12,17 @@ func BuiltinDefinitions() []Definition {
 		listCommand(),
 		switchCommand(),
 		checkCommand(),
+		usageCommand(),
+		paramsCommand(),
+		personaCommand(),
+		summaryCommand(),
+		feedbackCommand(),
+		cancelCommand(),
+		planCommand(),
+		approveCommand(),
+		denyCommand(),
+		accessCommand(),
+		linkCommand(),
+		unlinkCommand(),
 	}
 }
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/access"
+)
+
+const ownerOnlyMsg = "Only the owner can manage access."
+
+func accessCommand() Definition {
+	return Definition{
+		Name:        "access",
+		Description: "Manage who may talk to the bot",
+		SubCommands: []SubCommand{
+			{
+				Name:        "list",
+				Description: "Show users with a role and senders turned away",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ListAccess == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if req.Role != access.RoleOwner {
+						return req.Reply(ownerOnlyMsg)
+					}
+					lines := rt.ListAccess()
+					if len(lines) == 0 {
+						return req.Reply("No users have a role yet.")
+					}
+					return req.Reply(strings.Join(lines, "\n"))
+				},
+			},
+			{
+				Name:        "grant",
+				Description: "Give a user the owner, trusted or guest role",
+				ArgsUsage:   "<user> <role>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GrantAccess == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if req.Role != access.RoleOwner {
+						return req.Reply(ownerOnlyMsg)
+					}
+					// tokens: [/access, grant, <user>, <role>]
+					user, role := nthToken(req.Text, 2), strings.ToLower(nthToken(req.Text, 3))
+					if user == "" || role == "" {
+						return req.Reply("Usage: /access grant <platform:id> <owner|trusted|guest>")
+					}
+					if err := rt.GrantAccess(user, role); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(user + " is now " + role + ".")
+				},
+			},
+			{
+				Name:        "revoke",
+				Description: "Remove the role granted to a user",
+				ArgsUsage:   "<user>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.RevokeAccess == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if req.Role != access.RoleOwner {
+						return req.Reply(ownerOnlyMsg)
+					}
+					user := nthToken(req.Text, 2)
+					if user == "" {
+						return req.Reply("Usage: /access revoke <platform:id>")
+					}
+					if err := rt.RevokeAccess(user); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply("Revoked the role of " + user + ".")
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAccess_OwnerOnly(t *testing.T) {
+	var granted string
+	rt := &Runtime{
+		ListAccess: func() []string { return []string{"telegram:1: owner"} },
+		GrantAccess: func(user, role string) error {
+			granted = user + "=" + role
+			return nil
+		},
+		RevokeAccess: func(user string) error { return errors.New(user + " is the only owner") },
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	run := func(role, text string) string {
+		var reply string
+		res := ex.Execute(context.Background(), Request{
+			Channel: "telegram",
+			ChatID:  "1",
+			Role:    role,
+			Text:    text,
+			Reply:   func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+		return reply
+	}
+
+	if reply := run("trusted", "/access grant telegram:2 owner"); reply != ownerOnlyMsg || granted != "" {
+		t.Errorf("trusted grant: reply=%q granted=%q", reply, granted)
+	}
+	if reply := run("owner", "/access grant telegram:2 Trusted"); reply != "telegram:2 is now trusted." ||
+		granted != "telegram:2=trusted" {
+		t.Errorf("owner grant: reply=%q granted=%q", reply, granted)
+	}
+	if reply := run("owner", "/access list"); reply != "telegram:1: owner" {
+		t.Errorf("list reply = %q", reply)
+	}
+	if reply := run("owner", "/access revoke telegram:1"); reply != "telegram:1 is the only owner" {
+		t.Errorf("revoke reply = %q", reply)
+	}
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"strings"
+)
+
+func approveCommand() Definition {
+	return Definition{
+		Name:        "approve",
+		Description: "Run a tool call that is waiting for your approval",
+		Usage:       "/approve [id]",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			return resolveApproval(ctx, req, rt, true)
+		},
+	}
+}
+
+func denyCommand() Definition {
+	return Definition{
+		Name:        "deny",
+		Description: "Reject a tool call that is waiting for your approval",
+		Usage:       "/deny [id]",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			return resolveApproval(ctx, req, rt, false)
+		},
+	}
+}
+
+// resolveApproval answers the approval named in the command, or lists the
+// pending ones when no ID is given.
+func resolveApproval(ctx context.Context, req Request, rt *Runtime, approve bool) error {
+	if rt == nil || rt.ResolveApproval == nil || rt.ListApprovals == nil {
+		return req.Reply(unavailableMsg)
+	}
+
+	id := nthToken(req.Text, 1)
+	if id == "" {
+		pending, err := rt.ListApprovals(req.Channel, req.ChatID)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return req.Reply("No tool calls are waiting for approval.")
+		}
+		return req.Reply("Waiting for approval:\n" + strings.Join(pending, "\n"))
+	}
+
+	reply, err := rt.ResolveApproval(ctx, req.Channel, req.ChatID, id, approve)
+	if err != nil {
+		return req.Reply(err.Error())
+	}
+	return req.Reply(reply)
+}
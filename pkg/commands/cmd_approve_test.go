@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"context"
+	"testing"
+)
+
+func TestApprove_ListsOrResolves(t *testing.T) {
+	var gotID string
+	var gotApprove bool
+	rt := &Runtime{
+		ListApprovals: func(channel, chatID string) ([]string, error) {
+			if channel != "telegram" || chatID != "42" {
+				t.Errorf("ListApprovals(%q, %q), want the request's conversation", channel, chatID)
+			}
+			return []string{"a1b2c3: exec {\"command\":\"reboot\"} (expires 10:30)"}, nil
+		},
+		ResolveApproval: func(_ context.Context, _, _, id string, approve bool) (string, error) {
+			gotID, gotApprove = id, approve
+			return "done", nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	run := func(text string) string {
+		var reply string
+		res := ex.Execute(context.Background(), Request{
+			Channel: "telegram",
+			ChatID:  "42",
+			Text:    text,
+			Reply:   func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+		return reply
+	}
+
+	if reply := run("/approve"); reply != "Waiting for approval:\na1b2c3: exec {\"command\":\"reboot\"} (expires 10:30)" {
+		t.Errorf("/approve reply = %q", reply)
+	}
+	if reply := run("/deny a1b2c3"); reply != "done" || gotID != "a1b2c3" || gotApprove {
+		t.Errorf("/deny: reply=%q id=%q approve=%v", reply, gotID, gotApprove)
+	}
+	if run("/approve a1b2c3"); !gotApprove {
+		t.Error("/approve did not approve")
+	}
+}
@@ -0,0 +1,20 @@
+package commands
+
+import "context"
+
+func cancelCommand() Definition {
+	return Definition{
+		Name:        "cancel",
+		Description: "Stop the request that is running in this chat",
+		Usage:       "/cancel",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.CancelTurn == nil {
+				return req.Reply(unavailableMsg)
+			}
+			if !rt.CancelTurn(req.Channel, req.ChatID) {
+				return req.Reply("Nothing is running.")
+			}
+			return req.Reply("Stopping the running request.")
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package commands
+
+import "context"
+
+func feedbackCommand() Definition {
+	return Definition{
+		Name:        "feedback",
+		Description: "Rate or correct the last answer",
+		SubCommands: []SubCommand{
+			{
+				Name:        "good",
+				Description: "Mark the last answer as good",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.RateAnswer == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if err := rt.RateAnswer(1, ""); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply("Thanks for the feedback!")
+				},
+			},
+			{
+				Name:        "bad",
+				Description: "Mark the last answer as bad, optionally saying what it should have been",
+				ArgsUsage:   "[correction]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.RateAnswer == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if err := rt.RateAnswer(-1, textAfterTokens(req.Text, 2)); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply("Thanks, noted.")
+				},
+			},
+		},
+	}
+}
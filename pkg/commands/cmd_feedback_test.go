@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFeedback_GoodBad(t *testing.T) {
+	var rating int
+	var correction string
+	answered := true
+	rt := &Runtime{
+		RateAnswer: func(r int, c string) error {
+			if !answered {
+				return errors.New("no answer to rate yet")
+			}
+			rating, correction = r, c
+			return nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	run := func(text string) {
+		t.Helper()
+		res := ex.Execute(context.Background(), Request{
+			Text:  text,
+			Reply: func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+	}
+
+	run("/feedback good")
+	if rating != 1 || correction != "" || reply != "Thanks for the feedback!" {
+		t.Errorf("rating=%d correction=%q reply=%q", rating, correction, reply)
+	}
+	run("/feedback bad  The museum closes at 6, not 8.")
+	if rating != -1 || correction != "The museum closes at 6, not 8." || reply != "Thanks, noted." {
+		t.Errorf("rating=%d correction=%q reply=%q", rating, correction, reply)
+	}
+	answered = false
+	run("/feedback bad")
+	if reply != "no answer to rate yet" {
+		t.Errorf("reply=%q", reply)
+	}
+}
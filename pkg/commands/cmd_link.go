@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func linkCommand() Definition {
+	return Definition{
+		Name:        "link",
+		Description: "Share memory with your account on another channel",
+		Usage:       "/link [code]",
+		Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.StartLink == nil || rt.CompleteLink == nil {
+				return req.Reply(unavailableMsg)
+			}
+			code := nthToken(req.Text, 1)
+			if code == "" {
+				code, err := rt.StartLink()
+				if err != nil {
+					return err
+				}
+				return req.Reply(fmt.Sprintf(
+					"Send \"/link %s\" from your other account within 10 minutes to share memory between them.", code))
+			}
+			accounts, err := rt.CompleteLink(ctx, code)
+			if err != nil {
+				return req.Reply(err.Error())
+			}
+			return req.Reply(fmt.Sprintf("Linked %s. They now share memory; each channel keeps its own conversation.",
+				strings.Join(accounts, ", ")))
+		},
+	}
+}
+
+func unlinkCommand() Definition {
+	return Definition{
+		Name:        "unlink",
+		Description: "Stop sharing memory with your other accounts",
+		Usage:       "/unlink",
+		Handler: func(_ context.Context, req Request, rt *Runtime) error {
+			if rt == nil || rt.Unlink == nil {
+				return req.Reply(unavailableMsg)
+			}
+			if err := rt.Unlink(); err != nil {
+				return req.Reply(err.Error())
+			}
+			return req.Reply("This account no longer shares memory with your other accounts.")
+		},
+	}
+}
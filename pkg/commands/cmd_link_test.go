@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLink_StartAndComplete(t *testing.T) {
+	rt := &Runtime{
+		StartLink: func() (string, error) { return "K7M2QX", nil },
+		CompleteLink: func(_ context.Context, code string) ([]string, error) {
+			if code != "K7M2QX" {
+				return nil, errors.New("invalid or expired link code")
+			}
+			return []string{"cli:local", "telegram:1"}, nil
+		},
+		Unlink: func() error { return nil },
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	run := func(text string) string {
+		var reply string
+		res := ex.Execute(context.Background(), Request{
+			Channel: "telegram",
+			ChatID:  "1",
+			Text:    text,
+			Reply:   func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+		return reply
+	}
+
+	if reply := run("/link"); reply !=
+		"Send \"/link K7M2QX\" from your other account within 10 minutes to share memory between them." {
+		t.Errorf("/link reply = %q", reply)
+	}
+	if reply := run("/link K7M2QX"); reply !=
+		"Linked cli:local, telegram:1. They now share memory; each channel keeps its own conversation." {
+		t.Errorf("/link code reply = %q", reply)
+	}
+	if reply := run("/link WRONG"); reply != "invalid or expired link code" {
+		t.Errorf("/link wrong code reply = %q", reply)
+	}
+	if reply := run("/unlink"); reply != "This account no longer shares memory with your other accounts." {
+		t.Errorf("/unlink reply = %q", reply)
+	}
+}
@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func paramsCommand() Definition {
+	return Definition{
+		Name:        "params",
+		Description: "Show or override generation parameters for this session",
+		SubCommands: []SubCommand{
+			{
+				Name:        "show",
+				Description: "Show the session's generation overrides",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetGenerationParams == nil {
+						return req.Reply(unavailableMsg)
+					}
+					return req.Reply("Generation parameters: " + rt.GetGenerationParams().String())
+				},
+			},
+			{
+				Name:        "set",
+				Description: "Override temperature, top_p, max_tokens or stop",
+				ArgsUsage:   "<key> <value|default>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetGenerationParams == nil || rt.SetGenerationParams == nil {
+						return req.Reply(unavailableMsg)
+					}
+					// tokens: [/params, set, <key>, <value>...]
+					fields := strings.Fields(req.Text)
+					if len(fields) < 4 {
+						return req.Reply(fmt.Sprintf("Usage: /params set <%s> <value|default>",
+							strings.Join(session.GenerationParamKeys, "|")))
+					}
+					key := strings.ToLower(fields[2])
+					params := rt.GetGenerationParams()
+					if err := params.Set(key, strings.Join(fields[3:], " ")); err != nil {
+						return req.Reply(err.Error())
+					}
+					if err := rt.SetGenerationParams(params); err != nil {
+						return err
+					}
+					return req.Reply("Generation parameters: " + params.String())
+				},
+			},
+			{
+				Name:        "reset",
+				Description: "Drop all overrides and use the agent defaults",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetGenerationParams == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if err := rt.SetGenerationParams(session.GenerationParams{}); err != nil {
+						return err
+					}
+					return req.Reply("Generation parameters reset to defaults")
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestParams_SetShowReset(t *testing.T) {
+	var stored session.GenerationParams
+	rt := &Runtime{
+		GetGenerationParams: func() session.GenerationParams { return stored },
+		SetGenerationParams: func(p session.GenerationParams) error {
+			stored = p
+			return nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	run := func(text string) {
+		t.Helper()
+		res := ex.Execute(context.Background(), Request{
+			Text:  text,
+			Reply: func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+	}
+
+	run("/params set temperature 0.3")
+	run("/params set stop Observation:, END")
+	if want := "Generation parameters: temperature=0.3 stop=Observation:,END"; reply != want {
+		t.Errorf("reply=%q, want=%q", reply, want)
+	}
+
+	run("/params set top_p 7")
+	if reply != "top_p must be a number in (0, 1]" {
+		t.Errorf("reply=%q", reply)
+	}
+	if stored.TopP != nil {
+		t.Error("invalid value must not be stored")
+	}
+
+	run("/params reset")
+	run("/params show")
+	if reply != "Generation parameters: defaults" {
+		t.Errorf("reply=%q", reply)
+	}
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"strings"
+)
+
+func personaCommand() Definition {
+	return Definition{
+		Name:        "persona",
+		Description: "Switch this session to a configured persona",
+		SubCommands: []SubCommand{
+			{
+				Name:        "list",
+				Description: "List the configured personas",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ListPersonas == nil {
+						return req.Reply(unavailableMsg)
+					}
+					personas := rt.ListPersonas()
+					if len(personas) == 0 {
+						return req.Reply("No personas configured")
+					}
+					return req.Reply("Personas:\n" + strings.Join(personas, "\n"))
+				},
+			},
+			{
+				Name:        "show",
+				Description: "Show the session's persona",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetPersona == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if name := rt.GetPersona(); name != "" {
+						return req.Reply("Persona: " + name)
+					}
+					return req.Reply("Persona: default")
+				},
+			},
+			{
+				Name:        "use",
+				Description: "Switch to a persona",
+				ArgsUsage:   "<name>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetPersona == nil {
+						return req.Reply(unavailableMsg)
+					}
+					name := nthToken(req.Text, 2)
+					if name == "" {
+						return req.Reply("Usage: /persona use <name>")
+					}
+					if err := rt.SetPersona(name); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply("Switched to persona " + name)
+				},
+			},
+			{
+				Name:        "reset",
+				Description: "Go back to the agent's own settings",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetPersona == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if err := rt.SetPersona(""); err != nil {
+						return err
+					}
+					return req.Reply("Persona reset to default")
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestPersona_UseShowReset(t *testing.T) {
+	current := ""
+	rt := &Runtime{
+		ListPersonas: func() []string { return []string{"ops - terse operations bot"} },
+		GetPersona:   func() string { return current },
+		SetPersona: func(name string) error {
+			if name != "" && name != "ops" {
+				return fmt.Errorf("unknown persona %q", name)
+			}
+			current = name
+			return nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	run := func(text string) {
+		t.Helper()
+		res := ex.Execute(context.Background(), Request{
+			Text:  text,
+			Reply: func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+	}
+
+	run("/persona list")
+	if reply != "Personas:\nops - terse operations bot" {
+		t.Errorf("reply=%q", reply)
+	}
+	run("/persona use ops")
+	run("/persona show")
+	if current != "ops" || reply != "Persona: ops" {
+		t.Errorf("current=%q reply=%q", current, reply)
+	}
+	run("/persona use pirate")
+	if reply != `unknown persona "pirate"` || current != "ops" {
+		t.Errorf("current=%q reply=%q", current, reply)
+	}
+	run("/persona reset")
+	run("/persona show")
+	if reply != "Persona: default" {
+		t.Errorf("reply=%q", reply)
+	}
+}
@@ -0,0 +1,73 @@
+package commands
+
+import "context"
+
+func planCommand() Definition {
+	return Definition{
+		Name:        "plan",
+		Description: "Plan a complex task and carry it out step by step",
+		SubCommands: []SubCommand{
+			{
+				Name:        "start",
+				Description: "Plan a task and work through the steps",
+				ArgsUsage:   "<task>",
+				Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.StartPlan == nil {
+						return req.Reply(unavailableMsg)
+					}
+					task := textAfterTokens(req.Text, 2)
+					if task == "" {
+						return req.Reply("Usage: /plan start <task>")
+					}
+					reply, err := rt.StartPlan(ctx, task)
+					if err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(reply)
+				},
+			},
+			{
+				Name:        "show",
+				Description: "Show the latest plan and its progress",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.PlanStatus == nil {
+						return req.Reply(unavailableMsg)
+					}
+					status, err := rt.PlanStatus()
+					if err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(status)
+				},
+			},
+			{
+				Name:        "resume",
+				Description: "Continue a plan that was stopped",
+				Handler: func(ctx context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.ResumePlan == nil {
+						return req.Reply(unavailableMsg)
+					}
+					reply, err := rt.ResumePlan(ctx)
+					if err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(reply)
+				},
+			},
+			{
+				Name:        "cancel",
+				Description: "Drop the unfinished plan",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.CancelPlan == nil {
+						return req.Reply(unavailableMsg)
+					}
+					reply, err := rt.CancelPlan()
+					if err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply(reply)
+				},
+			},
+		},
+	}
+}
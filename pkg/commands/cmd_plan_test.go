@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPlan_Subcommands(t *testing.T) {
+	var task string
+	rt := &Runtime{
+		StartPlan: func(_ context.Context, t string) (string, error) {
+			task = t
+			return "all done", nil
+		},
+		ResumePlan: func(context.Context) (string, error) { return "", errors.New("nothing to resume") },
+		CancelPlan: func() (string, error) { return "Plan cancelled.", nil },
+		PlanStatus: func() (string, error) { return "Plan: tidy up", nil },
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	run := func(text string) {
+		t.Helper()
+		res := ex.Execute(context.Background(), Request{
+			Text:  text,
+			Reply: func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+	}
+
+	run("/plan start  tidy the   downloads folder ")
+	if task != "tidy the   downloads folder" || reply != "all done" {
+		t.Errorf("task=%q reply=%q", task, reply)
+	}
+	run("/plan start")
+	if reply != "Usage: /plan start <task>" {
+		t.Errorf("reply=%q", reply)
+	}
+	run("/plan show")
+	if reply != "Plan: tidy up" {
+		t.Errorf("reply=%q", reply)
+	}
+	run("/plan resume")
+	if reply != "nothing to resume" {
+		t.Errorf("reply=%q", reply)
+	}
+	run("/plan cancel")
+	if reply != "Plan cancelled." {
+		t.Errorf("reply=%q", reply)
+	}
+}
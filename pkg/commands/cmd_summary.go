@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+)
+
+func summaryCommand() Definition {
+	return Definition{
+		Name:        "summary",
+		Description: "Choose how this session's history is summarized",
+		SubCommands: []SubCommand{
+			{
+				Name:        "show",
+				Description: "Show the session's summarization strategy",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetSummarization == nil {
+						return req.Reply(unavailableMsg)
+					}
+					name, own := rt.GetSummarization()
+					if own {
+						return req.Reply("Summarization: " + name)
+					}
+					return req.Reply(fmt.Sprintf("Summarization: %s (agent default)", name))
+				},
+			},
+			{
+				Name:        "use",
+				Description: "Switch to a strategy: map_reduce, rolling or bullets",
+				ArgsUsage:   "<strategy>",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetSummarization == nil {
+						return req.Reply(unavailableMsg)
+					}
+					name := nthToken(req.Text, 2)
+					if name == "" {
+						return req.Reply("Usage: /summary use <map_reduce|rolling|bullets>")
+					}
+					if err := rt.SetSummarization(name); err != nil {
+						return req.Reply(err.Error())
+					}
+					return req.Reply("Summarizing this session with " + name)
+				},
+			},
+			{
+				Name:        "reset",
+				Description: "Go back to the agent's strategy",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.SetSummarization == nil {
+						return req.Reply(unavailableMsg)
+					}
+					if err := rt.SetSummarization(""); err != nil {
+						return err
+					}
+					return req.Reply("Summarization reset to default")
+				},
+			},
+		},
+	}
+}
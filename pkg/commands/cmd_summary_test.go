@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSummary_UseShowReset(t *testing.T) {
+	current := ""
+	rt := &Runtime{
+		GetSummarization: func() (string, bool) {
+			if current != "" {
+				return current, true
+			}
+			return "map_reduce", false
+		},
+		SetSummarization: func(name string) error {
+			if name != "" && name != "bullets" {
+				return fmt.Errorf("unknown summarization strategy %q", name)
+			}
+			current = name
+			return nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	run := func(text string) {
+		t.Helper()
+		res := ex.Execute(context.Background(), Request{
+			Text:  text,
+			Reply: func(s string) error { reply = s; return nil },
+		})
+		if res.Outcome != OutcomeHandled || res.Err != nil {
+			t.Fatalf("%s: outcome=%v err=%v", text, res.Outcome, res.Err)
+		}
+	}
+
+	run("/summary show")
+	if reply != "Summarization: map_reduce (agent default)" {
+		t.Errorf("reply=%q", reply)
+	}
+	run("/summary use bullets")
+	run("/summary show")
+	if current != "bullets" || reply != "Summarization: bullets" {
+		t.Errorf("current=%q reply=%q", current, reply)
+	}
+	run("/summary use abstractive")
+	if reply != `unknown summarization strategy "abstractive"` || current != "bullets" {
+		t.Errorf("current=%q reply=%q", current, reply)
+	}
+	run("/summary reset")
+	if current != "" || reply != "Summarization reset to default" {
+		t.Errorf("current=%q reply=%q", current, reply)
+	}
+}
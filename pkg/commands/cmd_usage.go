@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+const defaultUsageDays = 7
+
+func usageCommand() Definition {
+	return Definition{
+		Name:        "usage",
+		Description: "Show token usage and cost",
+		SubCommands: []SubCommand{
+			{
+				Name:        "session",
+				Description: "Usage of the current session",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetSessionUsage == nil {
+						return req.Reply(unavailableMsg)
+					}
+					totals, err := rt.GetSessionUsage()
+					if err != nil {
+						return err
+					}
+					return req.Reply("Session usage: " + formatUsageTotals(totals))
+				},
+			},
+			{
+				Name:        "daily",
+				Description: "Usage per day",
+				ArgsUsage:   "[days]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetDailyUsage == nil {
+						return req.Reply(unavailableMsg)
+					}
+					days, since, ok := usageDays(req.Text)
+					if !ok {
+						return req.Reply("Usage: /usage daily [days]")
+					}
+					daily, err := rt.GetDailyUsage(since)
+					if err != nil {
+						return err
+					}
+					if len(daily) == 0 {
+						return req.Reply(fmt.Sprintf("No usage recorded in the last %d day(s)", days))
+					}
+					var sb strings.Builder
+					fmt.Fprintf(&sb, "Usage (last %d day(s)):", days)
+					for _, d := range daily {
+						fmt.Fprintf(&sb, "\n%s: %s", d.Date, formatUsageTotals(d.UsageTotals))
+					}
+					return req.Reply(sb.String())
+				},
+			},
+			{
+				Name:        "tools",
+				Description: "Tool calls per tool",
+				ArgsUsage:   "[days]",
+				Handler: func(_ context.Context, req Request, rt *Runtime) error {
+					if rt == nil || rt.GetToolStats == nil {
+						return req.Reply(unavailableMsg)
+					}
+					days, since, ok := usageDays(req.Text)
+					if !ok {
+						return req.Reply("Usage: /usage tools [days]")
+					}
+					stats, err := rt.GetToolStats(since)
+					if err != nil {
+						return err
+					}
+					if len(stats) == 0 {
+						return req.Reply(fmt.Sprintf("No tool calls recorded in the last %d day(s)", days))
+					}
+					var sb strings.Builder
+					fmt.Fprintf(&sb, "Tool calls (last %d day(s)):", days)
+					for _, s := range stats {
+						fmt.Fprintf(&sb, "\n%s: %s", s.Tool, formatToolStats(s))
+					}
+					return req.Reply(sb.String())
+				},
+			},
+		},
+	}
+}
+
+// usageDays parses the optional [days] argument and returns the start of
+// the first day it covers.
+func usageDays(text string) (int, time.Time, bool) {
+	days := defaultUsageDays
+	if arg := nthToken(text, 2); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return 0, time.Time{}, false
+		}
+		days = n
+	}
+	now := time.Now()
+	return days, time.Date(now.Year(), now.Month(), now.Day()-(days-1), 0, 0, 0, 0, now.Location()), true
+}
+
+func formatUsageTotals(t memory.UsageTotals) string {
+	s := fmt.Sprintf("%d requests, %d tokens (%d in / %d out",
+		t.Requests, t.TotalTokens, t.PromptTokens, t.CompletionTokens)
+	if t.CachedTokens > 0 {
+		s += fmt.Sprintf(", %d cached", t.CachedTokens)
+	}
+	s += ")"
+	if t.CostUSD > 0 {
+		s += fmt.Sprintf(", $%.4f", t.CostUSD)
+	}
+	return s
+}
+
+func formatToolStats(s memory.ToolCallStats) string {
+	out := fmt.Sprintf("%d calls, avg %dms, max %dms", s.Calls, s.AvgMS(), s.MaxMS)
+	var failed []string
+	for _, outcome := range []string{
+		memory.ToolOutcomeError, memory.ToolOutcomeInvalid, memory.ToolOutcomeDenied, memory.ToolOutcomePending,
+	} {
+		if n := s.Outcomes[outcome]; n > 0 {
+			failed = append(failed, fmt.Sprintf("%d %s", n, outcome))
+		}
+	}
+	if len(failed) > 0 {
+		out += " (" + strings.Join(failed, ", ") + ")"
+	}
+	return out
+}
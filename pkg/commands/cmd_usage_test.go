@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func TestUsageSession_FormatsTotals(t *testing.T) {
+	rt := &Runtime{
+		GetSessionUsage: func() (memory.UsageTotals, error) {
+			return memory.UsageTotals{
+				Requests: 3, PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120, CostUSD: 0.0123,
+			}, nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	res := ex.Execute(context.Background(), Request{
+		Text:  "/usage session",
+		Reply: func(text string) error { reply = text; return nil },
+	})
+	if res.Outcome != OutcomeHandled || res.Err != nil {
+		t.Fatalf("outcome=%v err=%v", res.Outcome, res.Err)
+	}
+	want := "Session usage: 3 requests, 120 tokens (100 in / 20 out), $0.0123"
+	if reply != want {
+		t.Fatalf("reply=%q, want=%q", reply, want)
+	}
+}
+
+func TestUsageDaily_ParsesDays(t *testing.T) {
+	var gotSince time.Time
+	rt := &Runtime{
+		GetDailyUsage: func(since time.Time) ([]memory.DailyUsage, error) {
+			gotSince = since
+			return []memory.DailyUsage{
+				{Date: "2026-03-01", UsageTotals: memory.UsageTotals{Requests: 1, TotalTokens: 10}},
+			}, nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	ex.Execute(context.Background(), Request{
+		Text:  "/usage daily 3",
+		Reply: func(text string) error { reply = text; return nil },
+	})
+	if !strings.Contains(reply, "Usage (last 3 day(s)):") ||
+		!strings.Contains(reply, "2026-03-01: 1 requests, 10 tokens") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	now := time.Now()
+	wantSince := time.Date(now.Year(), now.Month(), now.Day()-2, 0, 0, 0, 0, now.Location())
+	if !gotSince.Equal(wantSince) {
+		t.Fatalf("since=%v, want=%v", gotSince, wantSince)
+	}
+
+	ex.Execute(context.Background(), Request{
+		Text:  "/usage daily nope",
+		Reply: func(text string) error { reply = text; return nil },
+	})
+	if reply != "Usage: /usage daily [days]" {
+		t.Fatalf("unexpected reply for bad arg: %q", reply)
+	}
+}
+
+func TestUsageTools_ListsStats(t *testing.T) {
+	rt := &Runtime{
+		GetToolStats: func(time.Time) ([]memory.ToolCallStats, error) {
+			return []memory.ToolCallStats{
+				{Tool: "exec", Calls: 4, TotalMS: 400, MaxMS: 250, Outcomes: map[string]int{"ok": 2, "denied": 2}},
+				{Tool: "read_file", Calls: 1, TotalMS: 3, MaxMS: 3, Outcomes: map[string]int{"ok": 1}},
+			}, nil
+		},
+	}
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), rt)
+
+	var reply string
+	ex.Execute(context.Background(), Request{
+		Text:  "/usage tools",
+		Reply: func(text string) error { reply = text; return nil },
+	})
+	want := "Tool calls (last 7 day(s)):\nexec: 4 calls, avg 100ms, max 250ms (2 denied)\n" +
+		"read_file: 1 calls, avg 3ms, max 3ms"
+	if reply != want {
+		t.Fatalf("reply=%q, want=%q", reply, want)
+	}
+}
+
+func TestUsage_UnavailableWithoutRuntime(t *testing.T) {
+	ex := NewExecutor(NewRegistry(BuiltinDefinitions()), &Runtime{})
+
+	var reply string
+	ex.Execute(context.Background(), Request{
+		Text:  "/usage session",
+		Reply: func(text string) error { reply = text; return nil },
+	})
+	if reply != unavailableMsg {
+		t.Fatalf("reply=%q, want=%q", reply, unavailableMsg)
+	}
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"context"
 	"strings"
+	"unicode"
 )
 
 type Handler func(ctx context.Context, req Request, rt *Runtime) error
@@ -11,6 +12,7 @@ type Request struct {
 	Channel  string
 	ChatID   string
 	SenderID string
+	Role     string // access role of the sender; empty when access control is off
 	Text     string
 	Reply    func(text string) error
 }
@@ -70,6 +72,20 @@ func nthToken(input string, n int) string {
 	return parts[n]
 }
 
+// textAfterTokens returns input without its first n whitespace-separated
+// tokens, trimmed.
+func textAfterTokens(input string, n int) string {
+	rest := strings.TrimSpace(input)
+	for range n {
+		i := strings.IndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			return ""
+		}
+		rest = strings.TrimSpace(rest[i:])
+	}
+	return rest
+}
+
 func normalizeCommandName(name string) string {
 	return strings.ToLower(strings.TrimSpace(name))
 }
@@ -1,6 +1,13 @@
 package commands
 
-import "github.com/sipeed/picoclaw/pkg/config"
+import (
+	"context"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
 
 // Runtime provides runtime dependencies to command handlers. It is constructed
 // per-request by the agent loop so that per-request state (like session scope)
@@ -13,4 +20,58 @@ type Runtime struct {
 	GetEnabledChannels func() []string
 	SwitchModel        func(value string) (oldModel string, err error)
 	SwitchChannel      func(value string) error
+	GetSessionUsage    func() (memory.UsageTotals, error)
+	GetDailyUsage      func(since time.Time) ([]memory.DailyUsage, error)
+	GetToolStats       func(since time.Time) ([]memory.ToolCallStats, error)
+
+	GetGenerationParams func() session.GenerationParams
+	SetGenerationParams func(params session.GenerationParams) error
+
+	// ListPersonas describes the configured personas. GetPersona and
+	// SetPersona read and switch the persona of the session; "" stands for
+	// the agent's own settings.
+	ListPersonas func() []string
+	GetPersona   func() string
+	SetPersona   func(name string) error
+
+	// GetSummarization returns the strategy the session's history is
+	// summarized with and whether the session chose it with
+	// SetSummarization; "" there goes back to the agent's strategy.
+	GetSummarization func() (name string, own bool)
+	SetSummarization func(name string) error
+
+	// RateAnswer records the sender's rating of the session's last answer,
+	// 1 good or -1 bad, with an optional correction.
+	RateAnswer func(rating int, correction string) error
+
+	// CancelTurn stops the running turn of a conversation and reports
+	// whether there was one.
+	CancelTurn func(channel, chatID string) bool
+
+	// StartPlan plans task and carries it out step by step, returning the
+	// final answer. ResumePlan continues the session's unfinished plan,
+	// CancelPlan drops it and PlanStatus describes the latest plan.
+	StartPlan  func(ctx context.Context, task string) (string, error)
+	ResumePlan func(ctx context.Context) (string, error)
+	CancelPlan func() (string, error)
+	PlanStatus func() (string, error)
+
+	// ResolveApproval answers a pending tool approval from the given
+	// conversation and returns the reply to send.
+	ResolveApproval func(ctx context.Context, channel, chatID, id string, approve bool) (string, error)
+	// ListApprovals describes the approvals pending in a conversation.
+	ListApprovals func(channel, chatID string) ([]string, error)
+
+	// ListAccess describes who has which role. GrantAccess and RevokeAccess
+	// change the role of a user ("telegram:123456").
+	ListAccess   func() []string
+	GrantAccess  func(user, role string) error
+	RevokeAccess func(user string) error
+
+	// StartLink returns a code that links another account of the sender
+	// when redeemed with CompleteLink, which returns the linked accounts.
+	// Unlink detaches the sender's account again.
+	StartLink    func() (string, error)
+	CompleteLink func(ctx context.Context, code string) ([]string, error)
+	Unlink       func() error
 }
@@ -0,0 +1,344 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Problem is a mistake in a config file.
+type Problem struct {
+	Line    int    // 1-based; 0 when the problem has no position in the file
+	Column  int    // 1-based, in bytes
+	Path    string // dotted path of the setting, such as "heartbeat.interval"
+	Message string
+	// Unknown is set for a field that is not a setting. LoadConfig ignores
+	// such fields with a warning; every other problem stops it.
+	Unknown bool
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", p.Line, p.Column)
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+// CheckConfig reads the config file at path strictly. It returns every
+// unknown field and every value of the wrong type with its position, and the
+// problems LoadConfig finds in the values. Unless there are problems other
+// than unknown fields, it also returns the effective config: the defaults,
+// then the file, then the environment. Unlike LoadConfig, a missing file is
+// an error.
+func CheckConfig(path string) (*Config, []Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	problems := checkJSON(data)
+	for _, p := range problems {
+		if !p.Unknown {
+			return nil, problems, nil
+		}
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, append(problems, Problem{Message: err.Error()}), nil
+	}
+	return cfg, problems, nil
+}
+
+// checkJSON returns the syntax error of data, or the unknown fields and the
+// values of the wrong type it holds for a Config.
+func checkJSON(data []byte) []Problem {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line, col := position(data, syntaxErr.Offset)
+			return []Problem{{Line: line, Column: col, Message: syntaxErr.Error()}}
+		}
+		return []Problem{{Message: err.Error()}}
+	}
+	c := &checker{data: data, dec: json.NewDecoder(bytes.NewReader(data))}
+	c.dec.UseNumber()
+	c.value(reflect.TypeFor[Config](), "")
+	return c.problems
+}
+
+// positionError adds the line and column to a JSON error of data.
+func positionError(data []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var offset int64
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	default:
+		return err
+	}
+	line, col := position(data, offset)
+	return fmt.Errorf("line %d, column %d: %w", line, col, err)
+}
+
+// position returns the 1-based line and column of offset in data.
+func position(data []byte, offset int64) (line, col int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return line, col
+}
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// checker walks the JSON tokens of a config file along the Go type they
+// decode into.
+type checker struct {
+	data     []byte
+	dec      *json.Decoder
+	problems []Problem
+}
+
+// next returns the offset of the next token, past the separators.
+func (c *checker) next() int64 {
+	off := c.dec.InputOffset()
+	for off < int64(len(c.data)) && strings.IndexByte(" \t\r\n:,", c.data[off]) >= 0 {
+		off++
+	}
+	return off
+}
+
+func (c *checker) report(off int64, path, msg string, unknown bool) {
+	line, col := position(c.data, off)
+	c.problems = append(c.problems, Problem{Line: line, Column: col, Path: path, Message: msg, Unknown: unknown})
+}
+
+// skip reads the next value whole.
+func (c *checker) skip() {
+	var raw json.RawMessage
+	_ = c.dec.Decode(&raw) // the file is valid JSON
+}
+
+func (c *checker) value(t reflect.Type, path string) {
+	off := c.next()
+	if off >= int64(len(c.data)) {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	got := jsonKind(c.data[off])
+	if got == "null" || t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(unmarshalerType) {
+		c.skip()
+		return
+	}
+	want := wantKind(t)
+	if want != got {
+		c.report(off, path, fmt.Sprintf("want %s, got %s", article(want), article(got)), false)
+		c.skip()
+		return
+	}
+
+	switch {
+	case want == "object" && t.Kind() == reflect.Struct:
+		c.object(path, func(key string) (reflect.Type, bool) {
+			f, ok := fieldByJSONName(t, key)
+			return f.Type, ok
+		}, func(key string) string {
+			return closestField(t, key)
+		})
+	case want == "object":
+		c.object(path, func(string) (reflect.Type, bool) { return t.Elem(), true }, nil)
+	case want == "list":
+		_, _ = c.dec.Token() // [
+		for i := 0; c.dec.More(); i++ {
+			c.value(t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+		_, _ = c.dec.Token() // ]
+	default:
+		tok, _ := c.dec.Token()
+		if n, ok := tok.(json.Number); ok {
+			if msg := checkNumber(t, n); msg != "" {
+				c.report(off, path, msg, false)
+			}
+		}
+	}
+}
+
+// object walks a JSON object; field returns the type of a key and whether
+// it is known, and suggest a known key close to an unknown one.
+func (c *checker) object(path string, field func(string) (reflect.Type, bool), suggest func(string) string) {
+	_, _ = c.dec.Token() // {
+	for c.dec.More() {
+		off := c.next()
+		tok, _ := c.dec.Token()
+		key, _ := tok.(string)
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+		t, ok := field(key)
+		if !ok && strings.HasPrefix(key, "_") {
+			c.skip() // a comment, such as the "_comment" keys of config.example.json
+			continue
+		}
+		if !ok {
+			msg := "unknown field"
+			if suggest != nil {
+				if s := suggest(key); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+			}
+			c.report(off, keyPath, msg, true)
+			c.skip()
+			continue
+		}
+		c.value(t, keyPath)
+	}
+	_, _ = c.dec.Token() // }
+}
+
+func jsonKind(b byte) string {
+	switch b {
+	case '{':
+		return "object"
+	case '[':
+		return "list"
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+func wantKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // []byte is base64
+		}
+		return "list"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	}
+	return "number"
+}
+
+func article(kind string) string {
+	if kind == "object" {
+		return "an object"
+	}
+	return "a " + kind
+}
+
+// checkNumber returns why n does not fit t, or "".
+func checkNumber(t reflect.Type, n json.Number) string {
+	var err error
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err = strconv.ParseInt(n.String(), 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err = strconv.ParseUint(n.String(), 10, t.Bits())
+	default:
+		return ""
+	}
+	if err == nil {
+		return ""
+	}
+	if strings.ContainsAny(n.String(), ".eE") {
+		return fmt.Sprintf("want a whole number, got %s", n)
+	}
+	return fmt.Sprintf("%s is out of range", n)
+}
+
+// fieldByJSONName finds the field of struct t that the JSON key decodes
+// into, matching case-insensitively like encoding/json.
+func fieldByJSONName(t reflect.Type, key string) (reflect.StructField, bool) {
+	var fold reflect.StructField
+	found := false
+	for _, f := range reflect.VisibleFields(t) {
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		if name == key {
+			return f, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			fold, found = f, true
+		}
+	}
+	return fold, found
+}
+
+// jsonName returns the JSON key of f, or false when f is not decoded.
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() || f.Anonymous {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+// closestField returns the JSON key of t closest to key, if one is at most
+// two edits away.
+func closestField(t reflect.Type, key string) string {
+	best, bestDist := "", 3
+	for _, f := range reflect.VisibleFields(t) {
+		name, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckConfig(t *testing.T) {
+	path := writeConfigFile(t, `{
+  "heartbeat": {
+    "enabled": "yes",
+    "intervl": 30
+  },
+  "agents": {
+    "defaults": { "max_tokens": 1.5, "model_name": "gpt-4o" }
+  },
+  "tools": { "exec": { "enabled": true } },
+  "gateway": { "port": [18790] }
+}`)
+
+	cfg, problems, err := CheckConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != nil {
+		t.Error("config returned despite type errors")
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`3:16: heartbeat.enabled: want a boolean, got a string`,
+		`4:5: heartbeat.intervl: unknown field (did you mean "interval"?)`,
+		`7:33: agents.defaults.max_tokens: want a whole number, got 1.5`,
+		`10:24: gateway.port: want a number, got a list`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCheckConfig_UnknownFieldsOnly(t *testing.T) {
+	path := writeConfigFile(t, `{"heartbeat": {"interval": 45}, "colour": "blue"}`)
+
+	cfg, problems, err := CheckConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !problems[0].Unknown || problems[0].Path != "colour" {
+		t.Errorf("problems = %+v", problems)
+	}
+	if cfg == nil || cfg.Heartbeat.Interval != 45 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	if cfg.Gateway.Port != DefaultConfig().Gateway.Port {
+		t.Errorf("defaults not merged: port %d", cfg.Gateway.Port)
+	}
+}
+
+func TestCheckConfig_SyntaxAndValues(t *testing.T) {
+	_, problems, _ := CheckConfig(writeConfigFile(t, "{\n  \"heartbeat\": {,}\n}"))
+	if len(problems) != 1 || problems[0].Line != 2 {
+		t.Errorf("syntax problems = %+v", problems)
+	}
+
+	_, problems, _ = CheckConfig(writeConfigFile(t, `{"logging": {"level": "loud"}}`))
+	if len(problems) != 1 || problems[0].Line != 0 || !strings.Contains(problems[0].Message, "logging") {
+		t.Errorf("value problems = %+v", problems)
+	}
+
+	if _, _, err := CheckConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file accepted")
+	}
+}
+
+func TestLoadConfig_ErrorPosition(t *testing.T) {
+	_, err := LoadConfig(writeConfigFile(t, "{\n  \"gateway\": {\"port\": \"80\"}\n}"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("err = %v, want the line of the bad value", err)
+	}
+}
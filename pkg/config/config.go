@@ -1,14 +1,24 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"sync/atomic"
 
+	"github.com/adhocore/gronx"
 	"github.com/caarlos0/env/v11"
 
 	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
 )
 
 // rrCounter is a global counter for round-robin load balancing across models.
@@ -58,6 +68,23 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+	Access    AccessConfig    `json:"access"`
+
+	Notifications NotificationsConfig `json:"notifications"`
+	Guardrails    GuardrailsConfig    `json:"guardrails"`
+	Budgets       BudgetsConfig       `json:"budgets"`
+	Logging       LoggingConfig       `json:"logging"`
+	Telemetry     TelemetryConfig     `json:"telemetry"`
+	Audit         AuditConfig         `json:"audit"`
+	Dataset       DatasetConfig       `json:"dataset"`
+	Outbox        OutboxConfig        `json:"outbox"`
+	Sync          SyncConfig          `json:"sync"`
+
+	// Profile tunes the whole configuration for a class of device, see
+	// applyProfile.
+	Profile string `json:"profile,omitempty" env:"PICOCLAW_PROFILE"`
+
+	secretRefs map[string]secretRef // by path, see resolveSecrets
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -78,7 +105,7 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 || len(c.Session.Policies) > 0 {
 		aux.Session = &c.Session
 	}
 
@@ -162,9 +189,82 @@ type AgentBinding struct {
 	Match   BindingMatch `json:"match"`
 }
 
+// SessionConfig controls which messages share a conversation. Policies
+// maps a channel name to "per-user", "per-chat", "per-thread" or "global";
+// channels without a policy keep one session per group or channel and
+// scope direct chats by DMScope.
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	Policies      map[string]string   `json:"policies,omitempty"`
+	PII           PIIConfig           `json:"pii"`
+	Encryption    EncryptionConfig    `json:"encryption"`
+	// CompactThreshold is how many messages truncating and compressing
+	// histories drop before the session store is compacted; 0 never.
+	CompactThreshold int `json:"compact_threshold,omitempty"`
+}
+
+// EncryptionConfig encrypts the stored sessions. Key is a base64 master
+// key, from which every person, and every group conversation, gets a key
+// of its own.
+type EncryptionConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_SESSION_ENCRYPTION_ENABLED"`
+	Key     string `json:"key"     env:"PICOCLAW_SESSION_ENCRYPTION_KEY"`
+}
+
+// SessionKey returns the key the sessions are encrypted with, or "" when
+// they are not.
+func (c *Config) SessionKey() string {
+	if !c.Session.Encryption.Enabled {
+		return ""
+	}
+	return c.Session.Encryption.Key
+}
+
+// PIIConfig looks for email addresses, phone numbers and card numbers in
+// the sessions as they are stored. Mode "mask" (the default) replaces them
+// with placeholders such as "[EMAIL]"; "flag" keeps them and counts them in
+// the session. Kinds limits the search to "email", "phone" and "card";
+// empty means all three.
+type PIIConfig struct {
+	Enabled bool     `json:"enabled"`
+	Mode    string   `json:"mode,omitempty"`
+	Kinds   []string `json:"kinds,omitempty"`
+}
+
+// Validate checks the session policies, the PII settings, the encryption
+// key and the compaction threshold.
+func (s SessionConfig) Validate() error {
+	for channel, policy := range s.Policies {
+		switch policy {
+		case "per-user", "per-chat", "per-thread", "global":
+		default:
+			return fmt.Errorf(
+				"policies.%s: unknown policy %q (want per-user, per-chat, per-thread or global)", channel, policy)
+		}
+	}
+	switch s.PII.Mode {
+	case "", "mask", "flag":
+	default:
+		return fmt.Errorf("pii.mode: unknown mode %q (want mask or flag)", s.PII.Mode)
+	}
+	for _, kind := range s.PII.Kinds {
+		switch kind {
+		case "email", "phone", "card":
+		default:
+			return fmt.Errorf("pii.kinds: unknown kind %q (want email, phone or card)", kind)
+		}
+	}
+	if s.Encryption.Enabled {
+		key, err := base64.StdEncoding.DecodeString(s.Encryption.Key)
+		if err != nil || len(key) != 32 {
+			return errors.New("encryption.key must be 32 base64-encoded bytes; create one with picoclaw sessions key")
+		}
+	}
+	if s.CompactThreshold < 0 {
+		return fmt.Errorf("compact_threshold: %d is negative", s.CompactThreshold)
+	}
+	return nil
 }
 
 // RoutingConfig controls the intelligent model routing feature.
@@ -177,6 +277,9 @@ type RoutingConfig struct {
 	Enabled    bool    `json:"enabled"`
 	LightModel string  `json:"light_model"` // model_name from model_list to use for simple tasks
 	Threshold  float64 `json:"threshold"`   // complexity score in [0,1]; score >= threshold → primary model
+	// Routes maps a task class (chat, tool_heavy, heartbeat, summarization) to a
+	// model_name from model_list. A matching route overrides complexity scoring.
+	Routes map[string]string `json:"routes,omitempty"`
 }
 
 type AgentDefaults struct {
@@ -189,13 +292,151 @@ type AgentDefaults struct {
 	ModelFallbacks            []string       `json:"model_fallbacks,omitempty"`
 	ImageModel                string         `json:"image_model,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_IMAGE_MODEL"`
 	ImageModelFallbacks       []string       `json:"image_model_fallbacks,omitempty"`
+	EmbeddingModel            string         `json:"embedding_model,omitempty"       env:"PICOCLAW_AGENTS_DEFAULTS_EMBEDDING_MODEL"`
 	MaxTokens                 int            `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
 	Temperature               *float64       `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
 	MaxToolIterations         int            `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxParallelTools          int            `json:"max_parallel_tools,omitempty"    env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOLS"`
+	MaxTurnSeconds            int            `json:"max_turn_seconds,omitempty"      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TURN_SECONDS"`
+	MaxRepeatedToolCalls      int            `json:"max_repeated_tool_calls"         env:"PICOCLAW_AGENTS_DEFAULTS_MAX_REPEATED_TOOL_CALLS"`
+	MaxConcurrentTurns        int            `json:"max_concurrent_turns"            env:"PICOCLAW_AGENTS_DEFAULTS_MAX_CONCURRENT_TURNS"`
+	MaxHistoryMessages        int            `json:"max_history_messages,omitempty"  env:"PICOCLAW_AGENTS_DEFAULTS_MAX_HISTORY_MESSAGES"`
 	SummarizeMessageThreshold int            `json:"summarize_message_threshold"     env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_MESSAGE_THRESHOLD"`
 	SummarizeTokenPercent     int            `json:"summarize_token_percent"         env:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZE_TOKEN_PERCENT"`
 	MaxMediaSize              int            `json:"max_media_size,omitempty"        env:"PICOCLAW_AGENTS_DEFAULTS_MAX_MEDIA_SIZE"`
 	Routing                   *RoutingConfig `json:"routing,omitempty"`
+	Prompt                    *PromptConfig  `json:"prompt,omitempty"`
+	// Personas are named profiles a session can switch to with /persona.
+	Personas map[string]PersonaConfig `json:"personas,omitempty"`
+	Recall   RecallConfig             `json:"recall"              envPrefix:"PICOCLAW_AGENTS_DEFAULTS_RECALL_"`
+	// Reflection has each answer reviewed before it is sent.
+	Reflection *ReflectionConfig `json:"reflection,omitempty"`
+	Planning   PlanningConfig    `json:"planning"             envPrefix:"PICOCLAW_AGENTS_DEFAULTS_PLANNING_"`
+	Traces     TracesConfig      `json:"traces"               envPrefix:"PICOCLAW_AGENTS_DEFAULTS_TRACES_"`
+	Topics     TopicsConfig      `json:"topics"               envPrefix:"PICOCLAW_AGENTS_DEFAULTS_TOPICS_"`
+	// Summarization chooses how history that no longer fits is summarized.
+	Summarization SummarizationConfig `json:"summarization" envPrefix:"PICOCLAW_AGENTS_DEFAULTS_SUMMARIZATION_"`
+}
+
+// SummarizationConfig chooses how the messages that leave a session's
+// history are summarized. Strategy is map_reduce (summaries of chunks of
+// ChunkMessages messages, merged), rolling (one summary updated in a single
+// call) or bullets (a list of facts, decisions and open questions); a
+// session can pick another with /summary. Model is a model_name from
+// model_list, typically a cheaper one; "" uses the summarization route, or
+// else the agent's model.
+type SummarizationConfig struct {
+	Strategy      string `json:"strategy"        env:"STRATEGY"`
+	Model         string `json:"model,omitempty" env:"MODEL"`
+	ChunkMessages int    `json:"chunk_messages"  env:"CHUNK_MESSAGES"`
+}
+
+// Validate checks the strategy name.
+func (c SummarizationConfig) Validate() error {
+	switch c.Strategy {
+	case "", "map_reduce", "rolling", "bullets":
+	default:
+		return fmt.Errorf("strategy: unknown strategy %q (want map_reduce, rolling or bullets)", c.Strategy)
+	}
+	if c.ChunkMessages < 0 {
+		return errors.New("chunk_messages must not be negative")
+	}
+	return nil
+}
+
+// TopicsConfig divides sessions into titled topics. Once the current topic
+// has MinMessages messages, Model (by default the agent's) is asked after
+// each turn whether the user's message started a new subject.
+type TopicsConfig struct {
+	Enabled     bool   `json:"enabled"         env:"ENABLED"`
+	Model       string `json:"model,omitempty" env:"MODEL"`
+	MinMessages int    `json:"min_messages"    env:"MIN_MESSAGES"`
+}
+
+// TracesConfig controls turn traces, kept under workspace/traces and shown
+// by `picoclaw trace`. Keep is how many of the latest turns of each session
+// are kept.
+type TracesConfig struct {
+	Enabled bool `json:"enabled" env:"ENABLED"`
+	Keep    int  `json:"keep"    env:"KEEP"`
+}
+
+// PlanningConfig configures /plan, which has the model break a task into at
+// most MaxSteps steps and then carries them out one at a time.
+type PlanningConfig struct {
+	MaxSteps int `json:"max_steps" env:"MAX_STEPS"`
+}
+
+// ReflectionConfig has a model review the draft of each answer against the
+// user's message and Rules before it is sent. When the reviewer finds
+// problems, the agent is given them and one more try. Model is a model_name
+// from model_list, typically a cheaper one than the agent's; "" uses the
+// agent's model. Channels turns the review on or off for the named channels,
+// overriding Enabled.
+type ReflectionConfig struct {
+	Enabled  bool            `json:"enabled"`
+	Model    string          `json:"model,omitempty"`
+	Rules    []string        `json:"rules,omitempty"`
+	Channels map[string]bool `json:"channels,omitempty"`
+}
+
+// EnabledFor reports whether answers to messages from channel are reviewed.
+func (r *ReflectionConfig) EnabledFor(channel string) bool {
+	if r == nil {
+		return false
+	}
+	if enabled, ok := r.Channels[channel]; ok {
+		return enabled
+	}
+	return r.Enabled
+}
+
+// RecallConfig puts memories relevant to each message into the prompt: up
+// to Facts saved facts and Messages messages from earlier conversations,
+// each with where it came from. Matches scoring below MinScore (0 to 1) are
+// left out.
+type RecallConfig struct {
+	Enabled  bool    `json:"enabled"   env:"ENABLED"`
+	Facts    int     `json:"facts"     env:"FACTS"`
+	Messages int     `json:"messages"  env:"MESSAGES"`
+	MinScore float64 `json:"min_score" env:"MIN_SCORE"`
+}
+
+// PromptConfig adds layers to the system prompt. Persona replaces the
+// built-in "You are picoclaw" introduction, and Channels adds instructions
+// for messages from the named channel. {{name}} in either, and in the
+// workspace prompt files, is replaced by a variable: one of Variables or
+// the built-in workspace, agent_id, agent_name and model, plus channel and
+// chat_id in channel instructions.
+type PromptConfig struct {
+	Persona   string            `json:"persona,omitempty"`
+	Channels  map[string]string `json:"channels,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// PersonaConfig is a profile a session can take on. Prompt is added to the
+// system prompt, Model (a model_name from model_list) and Temperature replace
+// the agent's, and Tools, when set, limits the agent to the named tools.
+// Empty fields keep the agent's settings.
+type PersonaConfig struct {
+	Description string   `json:"description,omitempty"`
+	Prompt      string   `json:"prompt,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+}
+
+// ValidatePersonas checks the persona names and temperatures.
+func (d *AgentDefaults) ValidatePersonas() error {
+	for name, p := range d.Personas {
+		if name == "" || strings.ContainsAny(name, " \t\n") {
+			return fmt.Errorf("personas: invalid name %q", name)
+		}
+		if p.Temperature != nil && (*p.Temperature < 0 || *p.Temperature > 2) {
+			return fmt.Errorf("personas.%s: temperature must be between 0 and 2", name)
+		}
+	}
+	return nil
 }
 
 const DefaultMaxMediaSize = 20 * 1024 * 1024 // 20 MB
@@ -232,6 +473,33 @@ type ChannelsConfig struct {
 	WeComAIBot WeComAIBotConfig `json:"wecom_aibot"`
 	Pico       PicoConfig       `json:"pico"`
 	IRC        IRCConfig        `json:"irc"`
+	MQTT       MQTTConfig       `json:"mqtt"`
+	Matrix     MatrixConfig     `json:"matrix"`
+	Webhook    WebhookConfig    `json:"webhook"`
+	Email      EmailConfig      `json:"email"`
+	Voice      VoiceConfig      `json:"voice"`
+	Serial     SerialConfig     `json:"serial"`
+	Queue      QueueConfig      `json:"queue"`
+	RateLimit  RateLimitConfig  `json:"rate_limit"`
+}
+
+// RateLimitConfig limits how fast each user can message the bot, per
+// channel. A user may send Burst messages at once, refilled at PerMinute
+// messages a minute; further messages are dropped and the user gets Message
+// once. Channels overrides the budget of individual channels. Owners are not
+// limited.
+type RateLimitConfig struct {
+	Enabled   bool                  `json:"enabled"            env:"PICOCLAW_CHANNELS_RATE_LIMIT_ENABLED"`
+	PerMinute float64               `json:"per_minute"         env:"PICOCLAW_CHANNELS_RATE_LIMIT_PER_MINUTE"`
+	Burst     int                   `json:"burst"              env:"PICOCLAW_CHANNELS_RATE_LIMIT_BURST"`
+	Message   string                `json:"message"            env:"PICOCLAW_CHANNELS_RATE_LIMIT_MESSAGE"`
+	Channels  map[string]RateBudget `json:"channels,omitempty"`
+}
+
+// RateBudget is the per-user message budget of one channel.
+type RateBudget struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
 }
 
 // GroupTriggerConfig controls when the bot responds in group chats.
@@ -269,6 +537,7 @@ type TelegramConfig struct {
 	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
 	Typing             TypingConfig        `json:"typing,omitempty"`
 	Placeholder        PlaceholderConfig   `json:"placeholder,omitempty"`
+	StreamReplies      bool                `json:"stream_replies"          env:"PICOCLAW_CHANNELS_TELEGRAM_STREAM_REPLIES"`
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_TELEGRAM_REASONING_CHANNEL_ID"`
 }
 
@@ -434,6 +703,123 @@ type IRCConfig struct {
 	ReasoningChannelID string              `json:"reasoning_channel_id"    env:"PICOCLAW_CHANNELS_IRC_REASONING_CHANNEL_ID"`
 }
 
+// MQTTConfig configures the MQTT channel. Every message on a topic matching
+// Topics starts a turn in a conversation named after the topic, and the
+// reply is published to the topic plus ReplySuffix (default "/reply").
+type MQTTConfig struct {
+	Enabled            bool                `json:"enabled"              env:"PICOCLAW_CHANNELS_MQTT_ENABLED"`
+	Broker             string              `json:"broker"               env:"PICOCLAW_CHANNELS_MQTT_BROKER"`
+	Username           string              `json:"username"             env:"PICOCLAW_CHANNELS_MQTT_USERNAME"`
+	Password           string              `json:"password"             env:"PICOCLAW_CHANNELS_MQTT_PASSWORD"`
+	ClientID           string              `json:"client_id,omitempty"  env:"PICOCLAW_CHANNELS_MQTT_CLIENT_ID"`
+	Topics             FlexibleStringSlice `json:"topics"               env:"PICOCLAW_CHANNELS_MQTT_TOPICS"`
+	ReplySuffix        string              `json:"reply_suffix"         env:"PICOCLAW_CHANNELS_MQTT_REPLY_SUFFIX"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_MQTT_ALLOW_FROM"`
+	ReasoningChannelID string              `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_MQTT_REASONING_CHANNEL_ID"`
+}
+
+// MatrixConfig configures the Matrix channel. Every joined room is a
+// conversation. The channel authenticates with AccessToken, or logs in as
+// UserID with Password when no token is set.
+type MatrixConfig struct {
+	Enabled            bool                `json:"enabled"              env:"PICOCLAW_CHANNELS_MATRIX_ENABLED"`
+	Homeserver         string              `json:"homeserver"           env:"PICOCLAW_CHANNELS_MATRIX_HOMESERVER"`
+	UserID             string              `json:"user_id"              env:"PICOCLAW_CHANNELS_MATRIX_USER_ID"`
+	AccessToken        string              `json:"access_token"         env:"PICOCLAW_CHANNELS_MATRIX_ACCESS_TOKEN"`
+	Password           string              `json:"password,omitempty"   env:"PICOCLAW_CHANNELS_MATRIX_PASSWORD"`
+	AutoJoin           bool                `json:"auto_join"            env:"PICOCLAW_CHANNELS_MATRIX_AUTO_JOIN"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_MATRIX_ALLOW_FROM"`
+	GroupTrigger       GroupTriggerConfig  `json:"group_trigger,omitempty"`
+	Typing             TypingConfig        `json:"typing,omitempty"`
+	ReasoningChannelID string              `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_MATRIX_REASONING_CHANNEL_ID"`
+}
+
+// EmailConfig configures the email channel. It polls Folder over IMAP for
+// unread mail, marks what it handles as read and replies over SMTP; each
+// email thread is its own conversation. Username defaults to Address.
+type EmailConfig struct {
+	Enabled             bool                `json:"enabled"               env:"PICOCLAW_CHANNELS_EMAIL_ENABLED"`
+	Address             string              `json:"address"               env:"PICOCLAW_CHANNELS_EMAIL_ADDRESS"`
+	Username            string              `json:"username,omitempty"    env:"PICOCLAW_CHANNELS_EMAIL_USERNAME"`
+	Password            string              `json:"password"              env:"PICOCLAW_CHANNELS_EMAIL_PASSWORD"`
+	IMAPHost            string              `json:"imap_host"             env:"PICOCLAW_CHANNELS_EMAIL_IMAP_HOST"`
+	IMAPPort            int                 `json:"imap_port"             env:"PICOCLAW_CHANNELS_EMAIL_IMAP_PORT"`
+	SMTPHost            string              `json:"smtp_host"             env:"PICOCLAW_CHANNELS_EMAIL_SMTP_HOST"`
+	SMTPPort            int                 `json:"smtp_port"             env:"PICOCLAW_CHANNELS_EMAIL_SMTP_PORT"`
+	Folder              string              `json:"folder,omitempty"      env:"PICOCLAW_CHANNELS_EMAIL_FOLDER"`
+	PollIntervalSeconds int                 `json:"poll_interval_seconds" env:"PICOCLAW_CHANNELS_EMAIL_POLL_INTERVAL_SECONDS"`
+	AllowFrom           FlexibleStringSlice `json:"allow_from"            env:"PICOCLAW_CHANNELS_EMAIL_ALLOW_FROM"`
+	ReasoningChannelID  string              `json:"reasoning_channel_id"  env:"PICOCLAW_CHANNELS_EMAIL_REASONING_CHANNEL_ID"`
+}
+
+// VoiceConfig configures the voice channel: RecordCommand writes raw 16 kHz
+// mono 16-bit PCM from the microphone to stdout, and TTSCommand reads a
+// reply on stdin and speaks it. Speech is transcribed by whisper.cpp when
+// WhisperModel is set, otherwise by the configured transcription API. With
+// a WakeWord, an utterance is only sent to the agent if it starts with it or
+// follows within FollowUpSeconds of the last reply.
+type VoiceConfig struct {
+	Enabled            bool     `json:"enabled"                env:"PICOCLAW_CHANNELS_VOICE_ENABLED"`
+	RecordCommand      []string `json:"record_command"`
+	TTSCommand         []string `json:"tts_command"`
+	WakeWord           string   `json:"wake_word"              env:"PICOCLAW_CHANNELS_VOICE_WAKE_WORD"`
+	WhisperBinary      string   `json:"whisper_binary"         env:"PICOCLAW_CHANNELS_VOICE_WHISPER_BINARY"`
+	WhisperModel       string   `json:"whisper_model"          env:"PICOCLAW_CHANNELS_VOICE_WHISPER_MODEL"`
+	Language           string   `json:"language"               env:"PICOCLAW_CHANNELS_VOICE_LANGUAGE"`
+	SilenceThreshold   int      `json:"silence_threshold"      env:"PICOCLAW_CHANNELS_VOICE_SILENCE_THRESHOLD"`
+	SilenceMs          int      `json:"silence_ms"             env:"PICOCLAW_CHANNELS_VOICE_SILENCE_MS"`
+	MaxSpeechSeconds   int      `json:"max_speech_seconds"     env:"PICOCLAW_CHANNELS_VOICE_MAX_SPEECH_SECONDS"`
+	FollowUpSeconds    int      `json:"follow_up_seconds"      env:"PICOCLAW_CHANNELS_VOICE_FOLLOW_UP_SECONDS"`
+	ReasoningChannelID string   `json:"reasoning_channel_id"   env:"PICOCLAW_CHANNELS_VOICE_REASONING_CHANNEL_ID"`
+}
+
+// SerialConfig configures the serial console channel: prompts are typed on
+// a terminal attached to Device and answered there. Echo repeats typed
+// characters back, which terminal programs like picocom and screen expect.
+type SerialConfig struct {
+	Enabled            bool   `json:"enabled"              env:"PICOCLAW_CHANNELS_SERIAL_ENABLED"`
+	Device             string `json:"device"               env:"PICOCLAW_CHANNELS_SERIAL_DEVICE"`
+	BaudRate           int    `json:"baud_rate"            env:"PICOCLAW_CHANNELS_SERIAL_BAUD_RATE"`
+	Echo               bool   `json:"echo"                 env:"PICOCLAW_CHANNELS_SERIAL_ECHO"`
+	Prompt             string `json:"prompt"               env:"PICOCLAW_CHANNELS_SERIAL_PROMPT"`
+	ReasoningChannelID string `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_SERIAL_REASONING_CHANNEL_ID"`
+}
+
+// QueueConfig configures the message queue channel, which makes picoclaw a
+// worker on a NATS or Redis bus. URL picks the bus: nats:// or tls:// for
+// NATS, redis:// or rediss:// for Redis. Requests are read from Subject, a
+// NATS subject shared by the workers in QueueGroup or a Redis list. Each
+// reply goes to the request's reply address, or to its subject plus
+// ReplySuffix (default ".reply").
+type QueueConfig struct {
+	Enabled            bool                `json:"enabled"              env:"PICOCLAW_CHANNELS_QUEUE_ENABLED"`
+	URL                string              `json:"url"                  env:"PICOCLAW_CHANNELS_QUEUE_URL"`
+	Subject            string              `json:"subject"              env:"PICOCLAW_CHANNELS_QUEUE_SUBJECT"`
+	QueueGroup         string              `json:"queue_group"          env:"PICOCLAW_CHANNELS_QUEUE_QUEUE_GROUP"`
+	ReplySuffix        string              `json:"reply_suffix"         env:"PICOCLAW_CHANNELS_QUEUE_REPLY_SUFFIX"`
+	AllowFrom          FlexibleStringSlice `json:"allow_from"           env:"PICOCLAW_CHANNELS_QUEUE_ALLOW_FROM"`
+	ReasoningChannelID string              `json:"reasoning_channel_id" env:"PICOCLAW_CHANNELS_QUEUE_REASONING_CHANNEL_ID"`
+}
+
+// WebhookConfig configures the inbound webhook channel. Each hook is served
+// at /hooks/<name> and turns the JSON it receives into a prompt.
+type WebhookConfig struct {
+	Enabled bool                `json:"enabled" env:"PICOCLAW_CHANNELS_WEBHOOK_ENABLED"`
+	Hooks   []WebhookHookConfig `json:"hooks"`
+}
+
+// WebhookHookConfig describes one webhook. Template is a Go text/template
+// executed on the decoded payload; Session names the conversation the
+// prompt goes to (default: the hook name). Replies are dropped unless
+// ReplyTo names a "channel:chat_id" to forward them to.
+type WebhookHookConfig struct {
+	Name     string `json:"name"`
+	Secret   string `json:"secret"`
+	Template string `json:"template,omitempty"`
+	Session  string `json:"session,omitempty"`
+	ReplyTo  string `json:"reply_to,omitempty"`
+}
+
 type HeartbeatConfig struct {
 	Enabled  bool `json:"enabled"  env:"PICOCLAW_HEARTBEAT_ENABLED"`
 	Interval int  `json:"interval" env:"PICOCLAW_HEARTBEAT_INTERVAL"` // minutes, min 5
@@ -444,6 +830,372 @@ type DevicesConfig struct {
 	MonitorUSB bool `json:"monitor_usb" env:"PICOCLAW_DEVICES_MONITOR_USB"`
 }
 
+// AccessConfig controls who may talk to the bot on every channel. When
+// enabled, each sender has a role: "owner", "trusted" or "guest". Users fixes
+// the role of an ID ("telegram:123456", "@alice"); senders admitted by a
+// channel's allow_from are trusted; anyone else gets DefaultRole, or is
+// ignored when it is empty. Until there is an owner, the gateway prints a
+// one-time pairing code and whoever sends "/pair <code>" becomes the owner.
+type AccessConfig struct {
+	Enabled     bool         `json:"enabled"         env:"PICOCLAW_ACCESS_ENABLED"`
+	DefaultRole string       `json:"default_role"    env:"PICOCLAW_ACCESS_DEFAULT_ROLE"`
+	Users       []AccessUser `json:"users,omitempty"`
+}
+
+// AccessUser gives a user a fixed role.
+type AccessUser struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+// Validate checks the roles. The default role cannot be owner.
+func (a AccessConfig) Validate() error {
+	if a.DefaultRole != "" && a.DefaultRole != "trusted" && a.DefaultRole != "guest" {
+		return fmt.Errorf("default_role: unknown role %q (want trusted, guest or empty)", a.DefaultRole)
+	}
+	for i, u := range a.Users {
+		if u.ID == "" {
+			return fmt.Errorf("users[%d]: missing id", i)
+		}
+		if u.Role != "owner" && u.Role != "trusted" && u.Role != "guest" {
+			return fmt.Errorf("users[%d]: unknown role %q (want owner, trusted or guest)", i, u.Role)
+		}
+	}
+	return nil
+}
+
+// BudgetsConfig limits what LLM calls may use, as recorded in the usage
+// ledger: Session applies to each session and Global to all of them
+// together. Once a limit is reached, turns run on FallbackModel, a cheaper
+// model_name from model_list, until the period is over; without one they are
+// refused. Dollar limits need pricing in model_list.
+type BudgetsConfig struct {
+	Enabled       bool         `json:"enabled"                  env:"PICOCLAW_BUDGETS_ENABLED"`
+	Session       BudgetLimits `json:"session"`
+	Global        BudgetLimits `json:"global"`
+	FallbackModel string       `json:"fallback_model,omitempty" env:"PICOCLAW_BUDGETS_FALLBACK_MODEL"`
+}
+
+// BudgetLimits are the daily and monthly limits of a budget, in tokens and
+// US dollars. Days and months follow local time; 0 means no limit.
+type BudgetLimits struct {
+	DailyTokens   int     `json:"daily_tokens,omitempty"`
+	MonthlyTokens int     `json:"monthly_tokens,omitempty"`
+	DailyUSD      float64 `json:"daily_usd,omitempty"`
+	MonthlyUSD    float64 `json:"monthly_usd,omitempty"`
+}
+
+// Validate checks that no limit is negative.
+func (b BudgetsConfig) Validate() error {
+	for scope, l := range map[string]BudgetLimits{"session": b.Session, "global": b.Global} {
+		if l.DailyTokens < 0 || l.MonthlyTokens < 0 || l.DailyUSD < 0 || l.MonthlyUSD < 0 {
+			return fmt.Errorf("%s: limits must not be negative", scope)
+		}
+	}
+	return nil
+}
+
+// SyncConfig keeps the sessions and facts of this instance in sync with
+// another one, see pkg/memsync. Both share Key, which `picoclaw sync key`
+// creates. The instance that sets Peer, the base URL of the other gateway,
+// starts an exchange every IntervalSeconds; the other only answers.
+type SyncConfig struct {
+	Enabled         bool   `json:"enabled"          env:"PICOCLAW_SYNC_ENABLED"`
+	Key             string `json:"key"              env:"PICOCLAW_SYNC_KEY"`
+	Peer            string `json:"peer"             env:"PICOCLAW_SYNC_PEER"`
+	IntervalSeconds int    `json:"interval_seconds" env:"PICOCLAW_SYNC_INTERVAL_SECONDS"`
+}
+
+// Validate checks the key and the peer URL of an enabled sync.
+func (s SyncConfig) Validate() error {
+	if !s.Enabled {
+		return nil
+	}
+	key, err := base64.StdEncoding.DecodeString(s.Key)
+	if err != nil || len(key) != 32 {
+		return errors.New("key must be 32 base64-encoded bytes; create one with picoclaw sync key")
+	}
+	if s.Peer != "" {
+		if u, err := url.Parse(s.Peer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("peer %q is not an http or https URL", s.Peer)
+		}
+	}
+	return nil
+}
+
+// LoggingConfig controls the log. Level is debug, info, warn or error, and
+// Components sets the level of single components, e.g. {"tool": "debug"}.
+// Format is how the console shows entries, "console" or "json".
+type LoggingConfig struct {
+	Level      string            `json:"level"                env:"PICOCLAW_LOGGING_LEVEL"`
+	Format     string            `json:"format"               env:"PICOCLAW_LOGGING_FORMAT"`
+	Components map[string]string `json:"components,omitempty"`
+	File       LogFileConfig     `json:"file"                 envPrefix:"PICOCLAW_LOGGING_FILE_"`
+}
+
+// LogFileConfig writes the log as JSON lines to picoclaw.log in Dir (by
+// default workspace/logs). Once the file reaches MaxSizeMB it is moved aside
+// and a new one started; MaxFiles old files are kept.
+type LogFileConfig struct {
+	Enabled   bool   `json:"enabled"       env:"ENABLED"`
+	Dir       string `json:"dir,omitempty" env:"DIR"`
+	MaxSizeMB int    `json:"max_size_mb"   env:"MAX_SIZE_MB"`
+	MaxFiles  int    `json:"max_files"     env:"MAX_FILES"`
+}
+
+// Validate checks the levels and the format.
+func (l LoggingConfig) Validate() error {
+	if _, err := logger.ParseLevel(l.Level); err != nil {
+		return err
+	}
+	for component, level := range l.Components {
+		if _, err := logger.ParseLevel(level); err != nil {
+			return fmt.Errorf("components.%s: %w", component, err)
+		}
+	}
+	switch l.Format {
+	case "", logger.FormatConsole, logger.FormatJSON:
+	default:
+		return fmt.Errorf("unknown format %q (want console or json)", l.Format)
+	}
+	if l.File.MaxSizeMB < 0 || l.File.MaxFiles < 0 {
+		return errors.New("file: max_size_mb and max_files must not be negative")
+	}
+	return nil
+}
+
+// TelemetryConfig exports OpenTelemetry traces of turns, provider calls, tool
+// calls and store operations over OTLP/HTTP. Endpoint is host:port of the
+// collector (default localhost:4318, or OTEL_EXPORTER_OTLP_ENDPOINT);
+// SampleRatio is the share of turns traced, from 0 to 1.
+type TelemetryConfig struct {
+	Enabled     bool              `json:"enabled"           env:"PICOCLAW_TELEMETRY_ENABLED"`
+	Endpoint    string            `json:"endpoint"          env:"PICOCLAW_TELEMETRY_ENDPOINT"`
+	Insecure    bool              `json:"insecure"          env:"PICOCLAW_TELEMETRY_INSECURE"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ServiceName string            `json:"service_name"      env:"PICOCLAW_TELEMETRY_SERVICE_NAME"`
+	SampleRatio float64           `json:"sample_ratio"      env:"PICOCLAW_TELEMETRY_SAMPLE_RATIO"`
+}
+
+// Validate checks the sample ratio.
+func (t TelemetryConfig) Validate() error {
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio %v is not between 0 and 1", t.SampleRatio)
+	}
+	return nil
+}
+
+// AuditConfig keeps the signed audit log of tool approvals, file writes,
+// shell commands, config changes and failed authentication in Dir (by
+// default workspace/audit).
+type AuditConfig struct {
+	Enabled bool   `json:"enabled"       env:"PICOCLAW_AUDIT_ENABLED"`
+	Dir     string `json:"dir,omitempty" env:"PICOCLAW_AUDIT_DIR"`
+}
+
+// DatasetConfig records every successful exchange with the model, with
+// secrets and personal data masked, as JSONL in Dir (by default
+// workspace/dataset), one file a day.
+type DatasetConfig struct {
+	Enabled bool   `json:"enabled"       env:"PICOCLAW_DATASET_ENABLED"`
+	Dir     string `json:"dir,omitempty" env:"PICOCLAW_DATASET_DIR"`
+}
+
+// DatasetDir returns the directory of the recorded exchanges.
+func (c *Config) DatasetDir() string {
+	if c.Dataset.Dir != "" {
+		return expandHome(c.Dataset.Dir)
+	}
+	return filepath.Join(c.WorkspacePath(), "dataset")
+}
+
+// OutboxConfig keeps the outbound channel messages and notification
+// webhook calls on disk in Dir (by default workspace/outbox) until they are
+// delivered. Failed ones are retried with backoff for up to MaxAgeHours;
+// RateLimit caps the deliveries per second, 0 for no cap.
+type OutboxConfig struct {
+	Enabled     bool    `json:"enabled"       env:"PICOCLAW_OUTBOX_ENABLED"`
+	Dir         string  `json:"dir,omitempty" env:"PICOCLAW_OUTBOX_DIR"`
+	RateLimit   float64 `json:"rate_limit"    env:"PICOCLAW_OUTBOX_RATE_LIMIT"`
+	MaxAgeHours int     `json:"max_age_hours" env:"PICOCLAW_OUTBOX_MAX_AGE_HOURS"`
+}
+
+// Validate checks the limits.
+func (o OutboxConfig) Validate() error {
+	if o.RateLimit < 0 {
+		return errors.New("rate_limit must not be negative")
+	}
+	if o.MaxAgeHours < 0 {
+		return errors.New("max_age_hours must not be negative")
+	}
+	return nil
+}
+
+// OutboxDir returns the directory of the outbox.
+func (c *Config) OutboxDir() string {
+	if c.Outbox.Dir != "" {
+		return expandHome(c.Outbox.Dir)
+	}
+	return filepath.Join(c.WorkspacePath(), "outbox")
+}
+
+// AuditDir returns the directory of the audit log.
+func (c *Config) AuditDir() string {
+	if c.Audit.Dir != "" {
+		return expandHome(c.Audit.Dir)
+	}
+	return filepath.Join(c.WorkspacePath(), "audit")
+}
+
+// GuardrailsConfig filters the messages the agent receives, the replies and
+// messages it sends, and the results of its tools. RedactSecrets replaces
+// API keys, tokens and private keys with "[REDACTED]", as do matches of the
+// Redact patterns (regular expressions). Text matching a pattern of one of
+// the Categories is blocked. Every violation is logged.
+type GuardrailsConfig struct {
+	Enabled       bool                         `json:"enabled"              env:"PICOCLAW_GUARDRAILS_ENABLED"`
+	RedactSecrets bool                         `json:"redact_secrets"       env:"PICOCLAW_GUARDRAILS_REDACT_SECRETS"`
+	Redact        []string                     `json:"redact,omitempty"`
+	Categories    map[string]GuardrailCategory `json:"categories,omitempty"`
+}
+
+// GuardrailCategory is a kind of content to block. Patterns are regular
+// expressions matched regardless of case. Stages limits the category to some
+// of "input", "output" and "tool" (by default it applies to all), and Message
+// is shown instead of the blocked text.
+type GuardrailCategory struct {
+	Patterns []string `json:"patterns"`
+	Stages   []string `json:"stages,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// Validate checks the patterns and stages.
+func (g GuardrailsConfig) Validate() error {
+	for i, p := range g.Redact {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("redact[%d]: %w", i, err)
+		}
+	}
+	for name, c := range g.Categories {
+		if name == "" {
+			return fmt.Errorf("categories: empty name")
+		}
+		if len(c.Patterns) == 0 {
+			return fmt.Errorf("categories.%s: no patterns", name)
+		}
+		for i, p := range c.Patterns {
+			if _, err := regexp.Compile("(?i)" + p); err != nil {
+				return fmt.Errorf("categories.%s.patterns[%d]: %w", name, i, err)
+			}
+		}
+		for _, stage := range c.Stages {
+			if stage != "input" && stage != "output" && stage != "tool" {
+				return fmt.Errorf("categories.%s: unknown stage %q (want input, output or tool)", name, stage)
+			}
+		}
+	}
+	return nil
+}
+
+// NotificationsConfig configures the notification router, which the notify
+// tool and the heartbeat use to push messages to Sinks. A notification goes
+// to every sink whose min_priority it reaches (by default every sink), and the same notification is
+// sent only once per DedupMinutes unless its priority rises. Templates are
+// text/template bodies a notification can name instead of giving a message.
+// With Heartbeat set, heartbeat results are sent as notifications instead of
+// to the last active chat. Digest sends a report of the conversations on a
+// schedule.
+type NotificationsConfig struct {
+	Enabled      bool               `json:"enabled"             env:"PICOCLAW_NOTIFICATIONS_ENABLED"`
+	DedupMinutes int                `json:"dedup_minutes"       env:"PICOCLAW_NOTIFICATIONS_DEDUP_MINUTES"`
+	Heartbeat    bool               `json:"heartbeat"           env:"PICOCLAW_NOTIFICATIONS_HEARTBEAT"`
+	Templates    map[string]string  `json:"templates,omitempty"`
+	Sinks        []NotificationSink `json:"sinks"`
+	Digest       DigestConfig       `json:"digest"`
+}
+
+// DigestConfig configures the digest: at each time the cron expression
+// Schedule gives, the gateway reports the sessions active since the previous
+// one, with their tool calls and cost, as a notification of Priority. With
+// Summarize, each session is also summarized by Model (by default the
+// default agent's model).
+type DigestConfig struct {
+	Enabled   bool   `json:"enabled"            env:"PICOCLAW_NOTIFICATIONS_DIGEST_ENABLED"`
+	Schedule  string `json:"schedule"           env:"PICOCLAW_NOTIFICATIONS_DIGEST_SCHEDULE"`
+	Summarize bool   `json:"summarize"          env:"PICOCLAW_NOTIFICATIONS_DIGEST_SUMMARIZE"`
+	Model     string `json:"model,omitempty"    env:"PICOCLAW_NOTIFICATIONS_DIGEST_MODEL"`
+	Priority  string `json:"priority,omitempty" env:"PICOCLAW_NOTIFICATIONS_DIGEST_PRIORITY"`
+}
+
+// NotificationSink is a destination for notifications. Type is "ntfy" (URL
+// is the topic URL, Token an optional access token), "pushover" (Token is
+// the application token, User the user key), "webhook" (the notification is
+// POSTed as JSON to URL with Headers), or the name of a chat channel such as
+// "telegram", which delivers to ChatID.
+type NotificationSink struct {
+	Name        string            `json:"name"`
+	Type        string            `json:"type"`
+	MinPriority string            `json:"min_priority,omitempty"`
+	ChatID      string            `json:"chat_id,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Token       string            `json:"token,omitempty"`
+	User        string            `json:"user,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// Validate checks that every sink has what its type needs, and the digest
+// schedule.
+func (n NotificationsConfig) Validate() error {
+	if d := n.Digest; d.Enabled {
+		if !gronx.New().IsValid(d.Schedule) {
+			return fmt.Errorf("digest: invalid schedule %q", d.Schedule)
+		}
+		switch d.Priority {
+		case "", "low", "normal", "high", "urgent":
+		default:
+			return fmt.Errorf("digest: unknown priority %q (want low, normal, high or urgent)", d.Priority)
+		}
+	}
+	names := map[string]bool{}
+	for i, s := range n.Sinks {
+		if s.Name == "" {
+			return fmt.Errorf("sinks[%d]: missing name", i)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("sinks[%d]: duplicate name %q", i, s.Name)
+		}
+		names[s.Name] = true
+		switch s.MinPriority {
+		case "", "low", "normal", "high", "urgent":
+		default:
+			return fmt.Errorf("sinks[%d]: unknown min_priority %q (want low, normal, high or urgent)", i, s.MinPriority)
+		}
+		var missing string
+		switch s.Type {
+		case "":
+			missing = "type"
+		case "ntfy", "webhook":
+			if s.URL == "" {
+				missing = "url"
+			}
+		case "pushover":
+			if s.Token == "" {
+				missing = "token"
+			} else if s.User == "" {
+				missing = "user"
+			}
+		default:
+			if s.ChatID == "" {
+				missing = "chat_id"
+			}
+		}
+		if missing != "" {
+			return fmt.Errorf("sinks[%d] (%s): missing %s", i, s.Name, missing)
+		}
+	}
+	return nil
+}
+
 type ProvidersConfig struct {
 	Anthropic     ProviderConfig       `json:"anthropic"`
 	OpenAI        OpenAIProviderConfig `json:"openai"`
@@ -529,18 +1281,89 @@ type ModelConfig struct {
 	// HTTP-based providers
 	APIBase string `json:"api_base,omitempty"` // API endpoint URL
 	APIKey  string `json:"api_key"`            // API authentication key
-	Proxy   string `json:"proxy,omitempty"`    // HTTP proxy URL
+	Proxy   string `json:"proxy,omitempty"`    // HTTP(S) or SOCKS5 proxy URL
+
+	// Transport tuning for HTTP-based providers (timeouts in seconds)
+	CAFile         string `json:"ca_file,omitempty"`         // PEM bundle of extra trusted root CAs
+	ConnectTimeout int    `json:"connect_timeout,omitempty"` // TCP dial + TLS handshake
+	ReadTimeout    int    `json:"read_timeout,omitempty"`    // Wait for response headers
+	KeepAlive      int    `json:"keep_alive,omitempty"`      // TCP keep-alive / idle connection lifetime; -1 disables
 
 	// Special providers (CLI-based, OAuth, etc.)
 	AuthMethod  string `json:"auth_method,omitempty"`  // Authentication method: oauth, token
 	ConnectMode string `json:"connect_mode,omitempty"` // Connection mode: stdio, grpc
 	Workspace   string `json:"workspace,omitempty"`    // Workspace path for CLI-based providers
+	Cassette    string `json:"cassette,omitempty"`     // JSONL file replayed by "replay/", recorded to for other protocols
 
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
+	TPM            int    `json:"tpm,omitempty"`              // Tokens per minute limit
+	MaxRetries     int    `json:"max_retries,omitempty"`      // Retries on 429/5xx with exponential backoff
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
 	ThinkingLevel  string `json:"thinking_level,omitempty"` // Extended thinking: off|low|medium|high|xhigh|adaptive
+	ContextWindow  int    `json:"context_window,omitempty"` // Prompt + completion tokens the model accepts
+
+	// Provider middleware
+	Headers     map[string]string `json:"headers,omitempty"`      // Extra HTTP headers sent with every request
+	Redact      []string          `json:"redact,omitempty"`       // Regexps masked in outgoing messages
+	LogRequests bool              `json:"log_requests,omitempty"` // Debug-log every call with latency and usage
+
+	// Pricing for usage accounting, in USD per million tokens.
+	InputPrice  float64 `json:"input_price,omitempty"`
+	OutputPrice float64 `json:"output_price,omitempty"`
+
+	// OpenRouter routing options, used by the "openrouter" protocol only.
+	OpenRouter *OpenRouterOptions `json:"openrouter,omitempty"`
+	// Azure OpenAI options, used by the "azure" protocol only.
+	Azure *AzureOptions `json:"azure,omitempty"`
+}
+
+// AzureOptions configures an Azure OpenAI resource. The model ID after
+// "azure/" is the deployment name; api_base is the resource endpoint
+// (https://<resource>.openai.azure.com). Without tenant_id/client_id the
+// entry's api_key is sent as the api-key header.
+type AzureOptions struct {
+	APIVersion string `json:"api_version,omitempty"` // api-version query parameter; defaults to a current GA version
+	// Microsoft Entra ID (AAD) client credentials. When set, requests carry
+	// a bearer token instead of an API key.
+	TenantID     string `json:"tenant_id,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	AuthorityURL string `json:"authority_url,omitempty"` // defaults to https://login.microsoftonline.com
+	Scope        string `json:"scope,omitempty"`         // defaults to https://cognitiveservices.azure.com/.default
+}
+
+// OpenRouterOptions configures OpenRouter's server-side routing.
+// See https://openrouter.ai/docs/features/model-routing and
+// https://openrouter.ai/docs/features/provider-routing.
+type OpenRouterOptions struct {
+	// Models are fallbacks OpenRouter tries in order when the primary model
+	// is unavailable, rate-limited or refuses the request.
+	Models []string `json:"models,omitempty"`
+	// Provider sets preferences for the upstream providers serving a model.
+	Provider *OpenRouterProviderPreferences `json:"provider,omitempty"`
+	// SiteURL and AppName are sent as HTTP-Referer and X-Title for app
+	// attribution on openrouter.ai.
+	SiteURL string `json:"site_url,omitempty"`
+	AppName string `json:"app_name,omitempty"`
+}
+
+// OpenRouterProviderPreferences mirrors OpenRouter's "provider" request object.
+type OpenRouterProviderPreferences struct {
+	Order             []string `json:"order,omitempty"`              // providers to try first, in order
+	AllowFallbacks    *bool    `json:"allow_fallbacks,omitempty"`    // false restricts routing to Order
+	RequireParameters bool     `json:"require_parameters,omitempty"` // only providers supporting every parameter
+	DataCollection    string   `json:"data_collection,omitempty"`    // "allow" or "deny"
+	Only              []string `json:"only,omitempty"`               // allowlist of providers
+	Ignore            []string `json:"ignore,omitempty"`             // providers to skip
+	Sort              string   `json:"sort,omitempty"`               // "price", "throughput" or "latency"
+}
+
+// CostUSD estimates the cost of a call from its token counts and the
+// configured prices. It returns 0 when no pricing is configured.
+func (c *ModelConfig) CostUSD(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*c.InputPrice + float64(completionTokens)*c.OutputPrice) / 1_000_000
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -555,8 +1378,31 @@ func (c *ModelConfig) Validate() error {
 }
 
 type GatewayConfig struct {
-	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
-	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	Host  string             `json:"host"  env:"PICOCLAW_GATEWAY_HOST"`
+	Port  int                `json:"port"  env:"PICOCLAW_GATEWAY_PORT"`
+	API   GatewayAPIConfig   `json:"api"`
+	Debug GatewayDebugConfig `json:"debug"`
+
+	// ShutdownTimeoutSeconds bounds how long a stopping gateway waits for
+	// the running turns, and then for the background jobs, to finish.
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds" env:"PICOCLAW_GATEWAY_SHUTDOWN_TIMEOUT_SECONDS"`
+}
+
+// GatewayDebugConfig configures the pprof and expvar endpoints. They are
+// served on a listener of their own, without authentication, so Host should
+// stay a loopback address.
+type GatewayDebugConfig struct {
+	Enabled bool   `json:"enabled" env:"PICOCLAW_GATEWAY_DEBUG_ENABLED"`
+	Host    string `json:"host"    env:"PICOCLAW_GATEWAY_DEBUG_HOST"`
+	Port    int    `json:"port"    env:"PICOCLAW_GATEWAY_DEBUG_PORT"`
+}
+
+// GatewayAPIConfig configures the REST API served on the gateway's HTTP
+// server under /api/v1. TimeoutSeconds bounds a single message turn.
+type GatewayAPIConfig struct {
+	Enabled        bool                `json:"enabled"         env:"PICOCLAW_GATEWAY_API_ENABLED"`
+	APIKeys        FlexibleStringSlice `json:"api_keys"        env:"PICOCLAW_GATEWAY_API_KEYS"`
+	TimeoutSeconds int                 `json:"timeout_seconds" env:"PICOCLAW_GATEWAY_API_TIMEOUT_SECONDS"`
 }
 
 type ToolConfig struct {
@@ -615,6 +1461,10 @@ type WebToolsConfig struct {
 	// For authenticated proxies, prefer HTTP_PROXY/HTTPS_PROXY env vars instead of embedding credentials in config.
 	Proxy           string `json:"proxy,omitempty"             env:"PICOCLAW_TOOLS_WEB_PROXY"`
 	FetchLimitBytes int64  `json:"fetch_limit_bytes,omitempty" env:"PICOCLAW_TOOLS_WEB_FETCH_LIMIT_BYTES"`
+	// FetchTimeoutSeconds bounds one web_fetch call (default 60). Results of
+	// successful fetches are reused for FetchCacheTTLSeconds; 0 disables the cache.
+	FetchTimeoutSeconds  int `json:"fetch_timeout_seconds,omitempty" env:"PICOCLAW_TOOLS_WEB_FETCH_TIMEOUT_SECONDS"`
+	FetchCacheTTLSeconds int `json:"fetch_cache_ttl_seconds"         env:"PICOCLAW_TOOLS_WEB_FETCH_CACHE_TTL_SECONDS"`
 }
 
 type CronToolsConfig struct {
@@ -627,7 +1477,156 @@ type ExecConfig struct {
 	EnableDenyPatterns  bool     `                                 env:"PICOCLAW_TOOLS_EXEC_ENABLE_DENY_PATTERNS"  json:"enable_deny_patterns"`
 	CustomDenyPatterns  []string `                                 env:"PICOCLAW_TOOLS_EXEC_CUSTOM_DENY_PATTERNS"  json:"custom_deny_patterns"`
 	CustomAllowPatterns []string `                                 env:"PICOCLAW_TOOLS_EXEC_CUSTOM_ALLOW_PATTERNS" json:"custom_allow_patterns"`
-	TimeoutSeconds      int      `                                 env:"PICOCLAW_TOOLS_EXEC_TIMEOUT_SECONDS"       json:"timeout_seconds"` // 0 means use default (60s)
+	TimeoutSeconds      int      `                                 env:"PICOCLAW_TOOLS_EXEC_TIMEOUT_SECONDS"       json:"timeout_seconds"`            // 0 means use default (60s)
+	AllowPatterns       []string `                                 env:"PICOCLAW_TOOLS_EXEC_ALLOW_PATTERNS"        json:"allow_patterns,omitempty"`   // When set, only matching commands may run
+	CPUSeconds          int      `                                 env:"PICOCLAW_TOOLS_EXEC_CPU_SECONDS"           json:"cpu_seconds,omitempty"`      // CPU time limit per command (Unix); 0 means none
+	MaxOutputChars      int      `                                 env:"PICOCLAW_TOOLS_EXEC_MAX_OUTPUT_CHARS"      json:"max_output_chars,omitempty"` // 0 means use default (10000)
+	Approval            string   `                                 env:"PICOCLAW_TOOLS_EXEC_APPROVAL"              json:"approval,omitempty"`         // Dangerous commands: "deny" (default) or "ask"
+}
+
+// ContainerConfig configures the container_exec tool, which runs
+// commands in a throwaway Docker or Podman container.
+type ContainerConfig struct {
+	ToolConfig     `         envPrefix:"PICOCLAW_TOOLS_CONTAINER_EXEC_"`
+	Runtime        string   `                                           env:"PICOCLAW_TOOLS_CONTAINER_EXEC_RUNTIME"         json:"runtime"`
+	Image          string   `                                           env:"PICOCLAW_TOOLS_CONTAINER_EXEC_IMAGE"           json:"image"`
+	Network        bool     `                                           env:"PICOCLAW_TOOLS_CONTAINER_EXEC_NETWORK"         json:"network"`
+	Mounts         []string `                                           env:"PICOCLAW_TOOLS_CONTAINER_EXEC_MOUNTS"          json:"mounts"`
+	TimeoutSeconds int      `                                           env:"PICOCLAW_TOOLS_CONTAINER_EXEC_TIMEOUT_SECONDS" json:"timeout_seconds"`
+	MemoryMB       int      `                                           env:"PICOCLAW_TOOLS_CONTAINER_EXEC_MEMORY_MB"       json:"memory_mb"`
+}
+
+// GitToolsConfig configures the git tool. Repos are paths, relative ones
+// resolved against the agent's workspace; when empty the workspace itself
+// is the only repository.
+type GitToolsConfig struct {
+	ToolConfig `         envPrefix:"PICOCLAW_TOOLS_GIT_"`
+	Repos      []string `                                env:"PICOCLAW_TOOLS_GIT_REPOS" json:"repos"`
+}
+
+// SubagentToolConfig configures the subagent and spawn tools. MaxIterations
+// bounds each subagent's tool loop and TokenBudget the tokens it may use in
+// all (0 means no limit); a call can ask for a smaller budget.
+type SubagentToolConfig struct {
+	ToolConfig    `    envPrefix:"PICOCLAW_TOOLS_SUBAGENT_"`
+	MaxIterations int `                                     env:"PICOCLAW_TOOLS_SUBAGENT_MAX_ITERATIONS" json:"max_iterations"`
+	TokenBudget   int `                                     env:"PICOCLAW_TOOLS_SUBAGENT_TOKEN_BUDGET"   json:"token_budget"`
+}
+
+// BrowserConfig configures the headless browser tool. It needs Chrome or
+// Chromium on the host and is off by default, since a browser is heavy for
+// small devices.
+// MemoryQueryConfig configures the memory_query tool. CacheKiB caps the
+// SQLite page cache of each query's copy of the stores; the copy then lives
+// in a temporary file instead of in memory. 0 keeps it in memory.
+type MemoryQueryConfig struct {
+	ToolConfig `    envPrefix:"PICOCLAW_TOOLS_MEMORY_QUERY_"`
+	CacheKiB   int `                                         env:"PICOCLAW_TOOLS_MEMORY_QUERY_CACHE_KIB" json:"cache_kib,omitempty"`
+}
+
+type BrowserConfig struct {
+	ToolConfig     `       envPrefix:"PICOCLAW_TOOLS_BROWSER_"`
+	ExecPath       string `                                    env:"PICOCLAW_TOOLS_BROWSER_EXEC_PATH"       json:"exec_path"`
+	TimeoutSeconds int    `                                    env:"PICOCLAW_TOOLS_BROWSER_TIMEOUT_SECONDS" json:"timeout_seconds"`
+	MaxChars       int    `                                    env:"PICOCLAW_TOOLS_BROWSER_MAX_CHARS"       json:"max_chars"`
+}
+
+// EmailAccountConfig is one mailbox the email tools can use. SMTP on port
+// 465 uses implicit TLS; other ports must offer STARTTLS. IMAP always uses
+// TLS. Username defaults to Address.
+type EmailAccountConfig struct {
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password"`
+	SMTPHost string `json:"smtp_host"`
+	SMTPPort int    `json:"smtp_port"`
+	IMAPHost string `json:"imap_host"`
+	IMAPPort int    `json:"imap_port"`
+}
+
+// EmailToolsConfig configures email_send and email_check. The first
+// account is the default.
+type EmailToolsConfig struct {
+	ToolConfig `                     envPrefix:"PICOCLAW_TOOLS_EMAIL_"`
+	Accounts   []EmailAccountConfig `                                  json:"accounts"`
+}
+
+// CalendarConfig configures calendar_list and calendar_create against one
+// CalDAV calendar collection. Token, when set, is sent as a bearer token
+// instead of basic auth (Google Calendar's CalDAV endpoint needs one).
+// ReminderMinutes is how far ahead the heartbeat is told about events.
+type CalendarConfig struct {
+	ToolConfig      `       envPrefix:"PICOCLAW_TOOLS_CALENDAR_"`
+	URL             string `                                     env:"PICOCLAW_TOOLS_CALENDAR_URL"              json:"url"`
+	Username        string `                                     env:"PICOCLAW_TOOLS_CALENDAR_USERNAME"         json:"username"`
+	Password        string `                                     env:"PICOCLAW_TOOLS_CALENDAR_PASSWORD"         json:"password"`
+	Token           string `                                     env:"PICOCLAW_TOOLS_CALENDAR_TOKEN"            json:"token,omitempty"`
+	ReminderMinutes int    `                                     env:"PICOCLAW_TOOLS_CALENDAR_REMINDER_MINUTES" json:"reminder_minutes"`
+}
+
+// ImageGenConfig configures image_generate. Provider "openai" calls an
+// OpenAI-compatible /images/generations endpoint; "sdwebui" calls the
+// txt2img API of a Stable Diffusion web UI (AUTOMATIC1111, Forge).
+type ImageGenConfig struct {
+	ToolConfig     `       envPrefix:"PICOCLAW_TOOLS_IMAGE_GENERATE_"`
+	Provider       string `                                           env:"PICOCLAW_TOOLS_IMAGE_GENERATE_PROVIDER"        json:"provider"`
+	APIBase        string `                                           env:"PICOCLAW_TOOLS_IMAGE_GENERATE_API_BASE"        json:"api_base,omitempty"`
+	APIKey         string `                                           env:"PICOCLAW_TOOLS_IMAGE_GENERATE_API_KEY"         json:"api_key,omitempty"`
+	Model          string `                                           env:"PICOCLAW_TOOLS_IMAGE_GENERATE_MODEL"           json:"model,omitempty"`
+	Size           string `                                           env:"PICOCLAW_TOOLS_IMAGE_GENERATE_SIZE"            json:"size"`
+	TimeoutSeconds int    `                                           env:"PICOCLAW_TOOLS_IMAGE_GENERATE_TIMEOUT_SECONDS" json:"timeout_seconds"`
+}
+
+// MQTTToolConfig configures the mqtt tool. Broker is a URL such as
+// tcp://localhost:1883, ssl://host:8883 or ws://host:9001. Topics lists the
+// topic filters (with + and # wildcards) the tool may publish to and read;
+// empty allows every topic.
+type MQTTToolConfig struct {
+	ToolConfig     `         envPrefix:"PICOCLAW_TOOLS_MQTT_"`
+	Broker         string   `                                 env:"PICOCLAW_TOOLS_MQTT_BROKER"          json:"broker"`
+	Username       string   `                                 env:"PICOCLAW_TOOLS_MQTT_USERNAME"        json:"username"`
+	Password       string   `                                 env:"PICOCLAW_TOOLS_MQTT_PASSWORD"        json:"password"`
+	ClientID       string   `                                 env:"PICOCLAW_TOOLS_MQTT_CLIENT_ID"       json:"client_id,omitempty"`
+	Topics         []string `                                 env:"PICOCLAW_TOOLS_MQTT_TOPICS"          json:"topics"`
+	TimeoutSeconds int      `                                 env:"PICOCLAW_TOOLS_MQTT_TIMEOUT_SECONDS" json:"timeout_seconds"`
+}
+
+// SystemInfoConfig configures system_info and the device health warnings
+// added to heartbeats. Disks lists the mount points to report (default "/"
+// and the workspace). A warning threshold of 0 disables that warning.
+type SystemInfoConfig struct {
+	ToolConfig        `         envPrefix:"PICOCLAW_TOOLS_SYSTEM_INFO_"`
+	Disks             []string `                                        env:"PICOCLAW_TOOLS_SYSTEM_INFO_DISKS"               json:"disks"`
+	DiskWarnPercent   int      `                                        env:"PICOCLAW_TOOLS_SYSTEM_INFO_DISK_WARN_PERCENT"   json:"disk_warn_percent"`
+	MemoryWarnPercent int      `                                        env:"PICOCLAW_TOOLS_SYSTEM_INFO_MEMORY_WARN_PERCENT" json:"memory_warn_percent"`
+	TempWarnCelsius   int      `                                        env:"PICOCLAW_TOOLS_SYSTEM_INFO_TEMP_WARN_CELSIUS"   json:"temp_warn_celsius"`
+}
+
+// WasmToolsConfig controls the tools skills ship as WebAssembly modules.
+// Capabilities are what such tools may be granted; a tool that asks for
+// one not listed is not loaded.
+type WasmToolsConfig struct {
+	ToolConfig    `         envPrefix:"PICOCLAW_TOOLS_WASM_"`
+	Capabilities  []string `                                 env:"PICOCLAW_TOOLS_WASM_CAPABILITIES"    json:"capabilities"`
+	MemoryLimitMB int      `                                 env:"PICOCLAW_TOOLS_WASM_MEMORY_LIMIT_MB" json:"memory_limit_mb"`
+}
+
+// WasmCapabilities are the capabilities a WebAssembly tool can ask for:
+// making HTTP requests, and reading or also writing the workspace.
+var WasmCapabilities = []string{"fetch", "read_workspace", "write_workspace"}
+
+// Validate checks the capability names and the memory limit.
+func (w WasmToolsConfig) Validate() error {
+	for _, c := range w.Capabilities {
+		if !slices.Contains(WasmCapabilities, c) {
+			return fmt.Errorf("unknown capability %q, want one of %s", c, strings.Join(WasmCapabilities, ", "))
+		}
+	}
+	if w.MemoryLimitMB < 0 || w.MemoryLimitMB > 4096 {
+		return fmt.Errorf("memory_limit_mb must be between 0 and 4096, got %d", w.MemoryLimitMB)
+	}
+	return nil
 }
 
 type SkillsToolsConfig struct {
@@ -643,27 +1642,103 @@ type MediaCleanupConfig struct {
 	Interval   int `                                    env:"PICOCLAW_MEDIA_CLEANUP_INTERVAL" json:"interval_minutes"`
 }
 
+// ApprovalConfig lists tools whose calls wait for the user to confirm them
+// in the conversation that made them (/approve or /deny).
+type ApprovalConfig struct {
+	Tools         []string `json:"tools"          env:"PICOCLAW_TOOLS_APPROVAL_TOOLS"`
+	ExpiryMinutes int      `json:"expiry_minutes" env:"PICOCLAW_TOOLS_APPROVAL_EXPIRY_MINUTES"`
+}
+
+// ToolPolicyRule matches tool calls by tool name, channel, chat ID, user,
+// access role ("owner", "trusted", "guest") and peer kind ("direct",
+// "group", "channel"); an empty field matches anything. Tools may use
+// path.Match patterns such as "mcp_*". Users take allow_from entries
+// ("123456", "@alice", "telegram:123456"). Action is "allow", "deny" or
+// "ask".
+type ToolPolicyRule struct {
+	Tools     []string `json:"tools"`
+	Channels  []string `json:"channels,omitempty"`
+	Chats     []string `json:"chats,omitempty"`
+	Users     []string `json:"users,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	PeerKinds []string `json:"peer_kinds,omitempty"`
+	Action    string   `json:"action"`
+}
+
+// ToolPolicyConfig decides which tool calls may run. Rules are checked in
+// order and the first match wins; Default ("allow" when empty) applies
+// when none match. "ask" adds a user approval on top of other approvals.
+type ToolPolicyConfig struct {
+	Default string           `json:"default" env:"PICOCLAW_TOOLS_POLICY_DEFAULT"`
+	Rules   []ToolPolicyRule `json:"rules"`
+}
+
+// Validate checks that every action is allow, deny or ask and that tool
+// patterns are well formed.
+func (p ToolPolicyConfig) Validate() error {
+	if !validPolicyAction(p.Default) && p.Default != "" {
+		return fmt.Errorf("default: unknown action %q (want allow, deny or ask)", p.Default)
+	}
+	for i, rule := range p.Rules {
+		if !validPolicyAction(rule.Action) {
+			return fmt.Errorf("rules[%d]: unknown action %q (want allow, deny or ask)", i, rule.Action)
+		}
+		for _, pattern := range rule.Tools {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rules[%d]: bad tool pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validPolicyAction(action string) bool {
+	return action == "allow" || action == "deny" || action == "ask"
+}
+
 type ToolsConfig struct {
 	AllowReadPaths  []string           `json:"allow_read_paths"  env:"PICOCLAW_TOOLS_ALLOW_READ_PATHS"`
 	AllowWritePaths []string           `json:"allow_write_paths" env:"PICOCLAW_TOOLS_ALLOW_WRITE_PATHS"`
+	MaxFileBytes    int                `json:"max_file_bytes"    env:"PICOCLAW_TOOLS_MAX_FILE_BYTES"`
+	TimeoutSeconds  int                `json:"timeout_seconds"   env:"PICOCLAW_TOOLS_TIMEOUT_SECONDS"`
+	Timeouts        map[string]int     `json:"timeouts,omitempty"`
 	Web             WebToolsConfig     `json:"web"`
 	Cron            CronToolsConfig    `json:"cron"`
 	Exec            ExecConfig         `json:"exec"`
+	ContainerExec   ContainerConfig    `json:"container_exec"`
+	Git             GitToolsConfig     `json:"git"`
+	Browser         BrowserConfig      `json:"browser"`
+	Email           EmailToolsConfig   `json:"email"`
+	Calendar        CalendarConfig     `json:"calendar"`
+	ImageGenerate   ImageGenConfig     `json:"image_generate"`
+	MQTT            MQTTToolConfig     `json:"mqtt"`
+	SystemInfo      SystemInfoConfig   `json:"system_info"`
 	Skills          SkillsToolsConfig  `json:"skills"`
 	MediaCleanup    MediaCleanupConfig `json:"media_cleanup"`
 	MCP             MCPConfig          `json:"mcp"`
+	Approval        ApprovalConfig     `json:"approval"`
+	Policy          ToolPolicyConfig   `json:"policy"`
 	AppendFile      ToolConfig         `json:"append_file"                                              envPrefix:"PICOCLAW_TOOLS_APPEND_FILE_"`
 	EditFile        ToolConfig         `json:"edit_file"                                                envPrefix:"PICOCLAW_TOOLS_EDIT_FILE_"`
 	FindSkills      ToolConfig         `json:"find_skills"                                              envPrefix:"PICOCLAW_TOOLS_FIND_SKILLS_"`
+	Glob            ToolConfig         `json:"glob"                                                     envPrefix:"PICOCLAW_TOOLS_GLOB_"`
 	I2C             ToolConfig         `json:"i2c"                                                      envPrefix:"PICOCLAW_TOOLS_I2C_"`
 	InstallSkill    ToolConfig         `json:"install_skill"                                            envPrefix:"PICOCLAW_TOOLS_INSTALL_SKILL_"`
+	Jobs            ToolConfig         `json:"jobs"                                                     envPrefix:"PICOCLAW_TOOLS_JOBS_"`
 	ListDir         ToolConfig         `json:"list_dir"                                                 envPrefix:"PICOCLAW_TOOLS_LIST_DIR_"`
+	Memory          ToolConfig         `json:"memory"                                                   envPrefix:"PICOCLAW_TOOLS_MEMORY_"`
+	MemoryQuery     MemoryQueryConfig  `json:"memory_query"`
 	Message         ToolConfig         `json:"message"                                                  envPrefix:"PICOCLAW_TOOLS_MESSAGE_"`
 	ReadFile        ToolConfig         `json:"read_file"                                                envPrefix:"PICOCLAW_TOOLS_READ_FILE_"`
+	Schedule        ToolConfig         `json:"schedule"                                                 envPrefix:"PICOCLAW_TOOLS_SCHEDULE_"`
+	Scratchpad      ToolConfig         `json:"scratchpad"                                               envPrefix:"PICOCLAW_TOOLS_SCRATCHPAD_"`
 	SendFile        ToolConfig         `json:"send_file"                                                envPrefix:"PICOCLAW_TOOLS_SEND_FILE_"`
+	SkillTools      ToolConfig         `json:"skill_tools"                                              envPrefix:"PICOCLAW_TOOLS_SKILL_TOOLS_"`
+	Wasm            WasmToolsConfig    `json:"wasm"`
 	Spawn           ToolConfig         `json:"spawn"                                                    envPrefix:"PICOCLAW_TOOLS_SPAWN_"`
 	SPI             ToolConfig         `json:"spi"                                                      envPrefix:"PICOCLAW_TOOLS_SPI_"`
-	Subagent        ToolConfig         `json:"subagent"                                                 envPrefix:"PICOCLAW_TOOLS_SUBAGENT_"`
+	Subagent        SubagentToolConfig `json:"subagent"`
+	Tasks           ToolConfig         `json:"tasks"                                                    envPrefix:"PICOCLAW_TOOLS_TASKS_"`
 	WebFetch        ToolConfig         `json:"web_fetch"                                                envPrefix:"PICOCLAW_TOOLS_WEB_FETCH_"`
 	WriteFile       ToolConfig         `json:"write_file"                                               envPrefix:"PICOCLAW_TOOLS_WRITE_FILE_"`
 }
@@ -735,14 +1810,20 @@ func LoadConfig(path string) (*Config, error) {
 	// entries; when count is 0 we keep DefaultConfig's built-in list as fallback.
 	var tmp Config
 	if err := json.Unmarshal(data, &tmp); err != nil {
-		return nil, err
+		return nil, positionError(data, err)
+	}
+	for _, p := range checkJSON(data) {
+		if p.Unknown {
+			logger.WarnCF("config", "Unknown config field ignored; run picoclaw config check",
+				map[string]any{"path": p.Path, "line": p.Line, "problem": p.Message})
+		}
 	}
 	if len(tmp.ModelList) > 0 {
 		cfg.ModelList = nil
 	}
 
 	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, err
+		return nil, positionError(data, err)
 	}
 
 	if err := env.Parse(cfg); err != nil {
@@ -757,10 +1838,56 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.ModelList = ConvertProvidersToModelList(cfg)
 	}
 
+	if err := resolveSecrets(cfg, filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
 	// Validate model_list for uniqueness and required fields
 	if err := cfg.ValidateModelList(); err != nil {
 		return nil, err
 	}
+	if err := cfg.applyProfile(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Tools.Policy.Validate(); err != nil {
+		return nil, fmt.Errorf("tools.policy: %w", err)
+	}
+	if err := cfg.Tools.Wasm.Validate(); err != nil {
+		return nil, fmt.Errorf("tools.wasm: %w", err)
+	}
+	if err := cfg.Access.Validate(); err != nil {
+		return nil, fmt.Errorf("access: %w", err)
+	}
+	if err := cfg.Notifications.Validate(); err != nil {
+		return nil, fmt.Errorf("notifications: %w", err)
+	}
+	if err := cfg.Session.Validate(); err != nil {
+		return nil, fmt.Errorf("session: %w", err)
+	}
+	if err := cfg.Guardrails.Validate(); err != nil {
+		return nil, fmt.Errorf("guardrails: %w", err)
+	}
+	if err := cfg.Sync.Validate(); err != nil {
+		return nil, fmt.Errorf("sync: %w", err)
+	}
+	if err := cfg.Budgets.Validate(); err != nil {
+		return nil, fmt.Errorf("budgets: %w", err)
+	}
+	if err := cfg.Logging.Validate(); err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	if err := cfg.Telemetry.Validate(); err != nil {
+		return nil, fmt.Errorf("telemetry: %w", err)
+	}
+	if err := cfg.Outbox.Validate(); err != nil {
+		return nil, fmt.Errorf("outbox: %w", err)
+	}
+	if err := cfg.Agents.Defaults.ValidatePersonas(); err != nil {
+		return nil, fmt.Errorf("agents.defaults.%w", err)
+	}
+	if err := cfg.Agents.Defaults.Summarization.Validate(); err != nil {
+		return nil, fmt.Errorf("agents.defaults.summarization.%w", err)
+	}
 
 	return cfg, nil
 }
@@ -779,6 +1906,10 @@ func (c *Config) migrateChannelConfigs() {
 }
 
 func SaveConfig(path string, cfg *Config) error {
+	cfg, err := withSecretRefs(cfg)
+	if err != nil {
+		return err
+	}
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
@@ -870,6 +2001,18 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// LookupModelConfig returns the first ModelConfig with the given model_name.
+// Unlike GetModelConfig it does not advance the load-balancing counter, so it
+// is safe for read-only lookups such as pricing.
+func (c *Config) LookupModelConfig(modelName string) (*ModelConfig, bool) {
+	for i := range c.ModelList {
+		if c.ModelList[i].ModelName == modelName {
+			return &c.ModelList[i], true
+		}
+	}
+	return nil, false
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
@@ -906,8 +2049,28 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.Cron.Enabled
 	case "exec":
 		return t.Exec.Enabled
+	case "container_exec":
+		return t.ContainerExec.Enabled
+	case "git":
+		return t.Git.Enabled
+	case "browser":
+		return t.Browser.Enabled
+	case "email":
+		return t.Email.Enabled
+	case "calendar":
+		return t.Calendar.Enabled
+	case "image_generate":
+		return t.ImageGenerate.Enabled
+	case "mqtt":
+		return t.MQTT.Enabled
+	case "system_info":
+		return t.SystemInfo.Enabled
 	case "skills":
 		return t.Skills.Enabled
+	case "skill_tools":
+		return t.SkillTools.Enabled
+	case "wasm":
+		return t.Wasm.Enabled
 	case "media_cleanup":
 		return t.MediaCleanup.Enabled
 	case "append_file":
@@ -916,22 +2079,36 @@ func (t *ToolsConfig) IsToolEnabled(name string) bool {
 		return t.EditFile.Enabled
 	case "find_skills":
 		return t.FindSkills.Enabled
+	case "glob":
+		return t.Glob.Enabled
 	case "i2c":
 		return t.I2C.Enabled
 	case "install_skill":
 		return t.InstallSkill.Enabled
+	case "jobs":
+		return t.Jobs.Enabled
 	case "list_dir":
 		return t.ListDir.Enabled
+	case "memory":
+		return t.Memory.Enabled
+	case "memory_query":
+		return t.MemoryQuery.Enabled
 	case "message":
 		return t.Message.Enabled
 	case "read_file":
 		return t.ReadFile.Enabled
+	case "schedule":
+		return t.Schedule.Enabled
+	case "scratchpad":
+		return t.Scratchpad.Enabled
 	case "spawn":
 		return t.Spawn.Enabled
 	case "spi":
 		return t.SPI.Enabled
 	case "subagent":
 		return t.Subagent.Enabled
+	case "tasks":
+		return t.Tasks.Enabled
 	case "web_fetch":
 		return t.WebFetch.Enabled
 	case "send_file":
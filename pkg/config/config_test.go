@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -479,3 +480,232 @@ func TestDefaultConfig_WorkspacePath_WithPicoclawHome(t *testing.T) {
 		t.Errorf("Workspace path with PICOCLAW_HOME = %q, want %q", cfg.Agents.Defaults.Workspace, want)
 	}
 }
+
+func TestToolPolicyConfig_Validate(t *testing.T) {
+	valid := ToolPolicyConfig{
+		Default: "deny",
+		Rules:   []ToolPolicyRule{{Tools: []string{"mcp_*"}, Action: "ask"}, {Action: "allow"}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, bad := range []ToolPolicyConfig{
+		{Default: "maybe"},
+		{Rules: []ToolPolicyRule{{Tools: []string{"exec"}}}},
+		{Rules: []ToolPolicyRule{{Tools: []string{"mcp_["}, Action: "deny"}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestNotificationsConfig_Validate(t *testing.T) {
+	valid := NotificationsConfig{Sinks: []NotificationSink{
+		{Name: "phone", Type: "ntfy", URL: "https://ntfy.sh/t", MinPriority: "high"},
+		{Name: "po", Type: "pushover", Token: "app", User: "u"},
+		{Name: "chat", Type: "telegram", ChatID: "42"},
+	}, Digest: DigestConfig{Enabled: true, Schedule: "0 21 * * *", Priority: "low"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, bad := range []NotificationsConfig{
+		{Sinks: []NotificationSink{{Type: "ntfy", URL: "https://ntfy.sh/t"}}},
+		{Sinks: []NotificationSink{{Name: "a", Type: "webhook"}}},
+		{Sinks: []NotificationSink{{Name: "a", Type: "pushover", Token: "app"}}},
+		{Sinks: []NotificationSink{{Name: "a", Type: "telegram"}}},
+		{Sinks: []NotificationSink{{Name: "a", Type: "telegram", ChatID: "1", MinPriority: "critical"}}},
+		{Sinks: []NotificationSink{{Name: "a", Type: "telegram", ChatID: "1"}, {Name: "a", Type: "slack", ChatID: "2"}}},
+		{Digest: DigestConfig{Enabled: true, Schedule: "every evening"}},
+		{Digest: DigestConfig{Enabled: true, Schedule: "0 21 * * *", Priority: "critical"}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestSessionConfig_Validate(t *testing.T) {
+	valid := SessionConfig{Policies: map[string]string{"slack": "per-thread", "telegram": "per-user"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	bad := SessionConfig{Policies: map[string]string{"slack": "per-channel"}}
+	if err := bad.Validate(); err == nil {
+		t.Error("unknown policy accepted")
+	}
+
+	pii := SessionConfig{PII: PIIConfig{Enabled: true, Mode: "flag", Kinds: []string{"email", "card"}}}
+	if err := pii.Validate(); err != nil {
+		t.Errorf("Validate(pii) = %v", err)
+	}
+	for _, bad := range []PIIConfig{{Mode: "hash"}, {Kinds: []string{"address"}}} {
+		if err := (SessionConfig{PII: bad}).Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	if err := (SessionConfig{Encryption: EncryptionConfig{Enabled: true, Key: key}}).Validate(); err != nil {
+		t.Errorf("Validate(encryption) = %v", err)
+	}
+	if err := (SessionConfig{Encryption: EncryptionConfig{Enabled: true, Key: "short"}}).Validate(); err == nil {
+		t.Error("a short encryption key was accepted")
+	}
+}
+
+func TestAgentDefaults_ValidatePersonas(t *testing.T) {
+	temp := 0.3
+	valid := AgentDefaults{Personas: map[string]PersonaConfig{"ops": {Prompt: "Be terse.", Temperature: &temp}}}
+	if err := valid.ValidatePersonas(); err != nil {
+		t.Errorf("ValidatePersonas() = %v", err)
+	}
+	hot := 2.5
+	for _, bad := range []map[string]PersonaConfig{
+		{"ops": {Temperature: &hot}},
+		{"on call": {}},
+	} {
+		d := AgentDefaults{Personas: bad}
+		if err := d.ValidatePersonas(); err == nil {
+			t.Errorf("ValidatePersonas(%v) accepted", bad)
+		}
+	}
+}
+
+func TestSummarizationConfig_Validate(t *testing.T) {
+	for _, good := range []SummarizationConfig{{}, {Strategy: "bullets", Model: "cheap"}, {ChunkMessages: 5}} {
+		if err := good.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", good, err)
+		}
+	}
+	for _, bad := range []SummarizationConfig{{Strategy: "abstractive"}, {ChunkMessages: -1}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestReflectionConfig_EnabledFor(t *testing.T) {
+	var off *ReflectionConfig
+	if off.EnabledFor("telegram") {
+		t.Error("nil config enabled")
+	}
+	r := &ReflectionConfig{Enabled: true, Channels: map[string]bool{"cli": false, "slack": true}}
+	for channel, want := range map[string]bool{"telegram": true, "cli": false, "slack": true} {
+		if got := r.EnabledFor(channel); got != want {
+			t.Errorf("EnabledFor(%q) = %v, want %v", channel, got, want)
+		}
+	}
+	r.Enabled = false
+	if r.EnabledFor("telegram") || !r.EnabledFor("slack") {
+		t.Error("channel overrides not applied when disabled by default")
+	}
+}
+
+func TestGuardrailsConfig_Validate(t *testing.T) {
+	valid := GuardrailsConfig{
+		Redact:     []string{`INTERNAL-\d+`},
+		Categories: map[string]GuardrailCategory{"weapons": {Patterns: []string{"bomb"}, Stages: []string{"input"}}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, bad := range []GuardrailsConfig{
+		{Redact: []string{"("}},
+		{Categories: map[string]GuardrailCategory{"weapons": {}}},
+		{Categories: map[string]GuardrailCategory{"weapons": {Patterns: []string{"["}}}},
+		{Categories: map[string]GuardrailCategory{"weapons": {Patterns: []string{"bomb"}, Stages: []string{"reply"}}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted", bad)
+		}
+	}
+}
+
+func TestBudgetsConfig_Validate(t *testing.T) {
+	valid := BudgetsConfig{Session: BudgetLimits{DailyUSD: 1}, Global: BudgetLimits{MonthlyTokens: 1_000_000}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	bad := BudgetsConfig{Global: BudgetLimits{DailyUSD: -1}}
+	if err := bad.Validate(); err == nil {
+		t.Error("negative limit accepted")
+	}
+}
+
+func TestSyncConfig_Validate(t *testing.T) {
+	key := "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+	tests := []struct {
+		name    string
+		cfg     SyncConfig
+		wantErr bool
+	}{
+		{"off", SyncConfig{Key: "short"}, false},
+		{"answering", SyncConfig{Enabled: true, Key: key}, false},
+		{"with peer", SyncConfig{Enabled: true, Key: key, Peer: "http://board.local:18790"}, false},
+		{"short key", SyncConfig{Enabled: true, Key: "c2hvcnQ="}, true},
+		{"peer without scheme", SyncConfig{Enabled: true, Key: key, Peer: "board.local:18790"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoggingConfig_Validate(t *testing.T) {
+	valid := LoggingConfig{Level: "warn", Format: "json", Components: map[string]string{"tool": "debug"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, bad := range []LoggingConfig{
+		{Level: "verbose"},
+		{Components: map[string]string{"tool": "loud"}},
+		{Format: "xml"},
+		{File: LogFileConfig{MaxFiles: -1}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestTelemetryConfig_Validate(t *testing.T) {
+	if err := (TelemetryConfig{SampleRatio: 0.25}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (TelemetryConfig{SampleRatio: 2}).Validate(); err == nil {
+		t.Error("Validate() accepted sample_ratio 2")
+	}
+}
+
+func TestOutboxConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Outbox.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, bad := range []OutboxConfig{{RateLimit: -1}, {MaxAgeHours: -1}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+}
+
+func TestAccessConfig_Validate(t *testing.T) {
+	valid := AccessConfig{
+		Enabled:     true,
+		DefaultRole: "guest",
+		Users:       []AccessUser{{ID: "telegram:1", Role: "owner"}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	for _, bad := range []AccessConfig{
+		{DefaultRole: "owner"},
+		{Users: []AccessUser{{ID: "telegram:1", Role: "admin"}}},
+		{Users: []AccessUser{{Role: "guest"}}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", bad)
+		}
+	}
+}
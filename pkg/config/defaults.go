@@ -33,8 +33,30 @@ func DefaultConfig() *Config {
 				MaxTokens:                 32768,
 				Temperature:               nil, // nil means use provider default
 				MaxToolIterations:         50,
+				MaxRepeatedToolCalls:      3,
+				MaxConcurrentTurns:        4,
 				SummarizeMessageThreshold: 20,
 				SummarizeTokenPercent:     75,
+				Recall: RecallConfig{
+					Enabled:  true,
+					Facts:    3,
+					Messages: 3,
+					MinScore: 0.5,
+				},
+				Planning: PlanningConfig{
+					MaxSteps: 8,
+				},
+				Traces: TracesConfig{
+					Enabled: true,
+					Keep:    20,
+				},
+				Topics: TopicsConfig{
+					MinMessages: 6,
+				},
+				Summarization: SummarizationConfig{
+					Strategy:      "map_reduce",
+					ChunkMessages: 10,
+				},
 			},
 		},
 		Bindings: []AgentBinding{},
@@ -58,6 +80,7 @@ func DefaultConfig() *Config {
 					Enabled: true,
 					Text:    "Thinking... 💭",
 				},
+				StreamReplies: true,
 			},
 			Feishu: FeishuConfig{
 				Enabled:           false,
@@ -158,6 +181,55 @@ func DefaultConfig() *Config {
 				MaxConnections: 100,
 				AllowFrom:      FlexibleStringSlice{},
 			},
+			MQTT: MQTTConfig{
+				Broker:      "tcp://localhost:1883",
+				Topics:      FlexibleStringSlice{"picoclaw/in/#"},
+				ReplySuffix: "/reply",
+				AllowFrom:   FlexibleStringSlice{},
+			},
+			Matrix: MatrixConfig{
+				Homeserver: "https://matrix.org",
+				AutoJoin:   true,
+				AllowFrom:  FlexibleStringSlice{},
+			},
+			Webhook: WebhookConfig{
+				Hooks: []WebhookHookConfig{},
+			},
+			Email: EmailConfig{
+				IMAPPort:            993,
+				SMTPPort:            587,
+				Folder:              "INBOX",
+				PollIntervalSeconds: 60,
+				AllowFrom:           FlexibleStringSlice{},
+			},
+			Voice: VoiceConfig{
+				RecordCommand:    []string{"arecord", "-q", "-t", "raw", "-f", "S16_LE", "-r", "16000", "-c", "1"},
+				TTSCommand:       []string{"espeak-ng", "--stdin"},
+				WakeWord:         "hey pico",
+				SilenceThreshold: 500,
+				SilenceMs:        800,
+				MaxSpeechSeconds: 15,
+				FollowUpSeconds:  8,
+			},
+			Serial: SerialConfig{
+				Device:   "/dev/ttyS0",
+				BaudRate: 115200,
+				Echo:     true,
+				Prompt:   "> ",
+			},
+			Queue: QueueConfig{
+				URL:         "nats://localhost:4222",
+				Subject:     "picoclaw.requests",
+				QueueGroup:  "picoclaw",
+				ReplySuffix: ".reply",
+				AllowFrom:   FlexibleStringSlice{},
+			},
+			RateLimit: RateLimitConfig{
+				Enabled:   true,
+				PerMinute: 20,
+				Burst:     10,
+				Message:   "You're sending messages too fast. Please wait a moment and try again.",
+			},
 		},
 		Providers: ProvidersConfig{
 			OpenAI: OpenAIProviderConfig{WebSearch: true},
@@ -331,10 +403,26 @@ func DefaultConfig() *Config {
 			},
 		},
 		Gateway: GatewayConfig{
-			Host: "127.0.0.1",
-			Port: 18790,
+			Host:                   "127.0.0.1",
+			Port:                   18790,
+			ShutdownTimeoutSeconds: 30,
+			API: GatewayAPIConfig{
+				TimeoutSeconds: 300,
+			},
+			Debug: GatewayDebugConfig{
+				Host: "127.0.0.1",
+				Port: 6060,
+			},
 		},
 		Tools: ToolsConfig{
+			MaxFileBytes:   1 << 20,
+			TimeoutSeconds: 300,
+			// A subagent runs a whole tool loop of its own and is bounded by
+			// its iteration limit instead.
+			Timeouts: map[string]int{"subagent": 0},
+			Approval: ApprovalConfig{
+				ExpiryMinutes: 30,
+			},
 			MediaCleanup: MediaCleanupConfig{
 				ToolConfig: ToolConfig{
 					Enabled: true,
@@ -346,8 +434,9 @@ func DefaultConfig() *Config {
 				ToolConfig: ToolConfig{
 					Enabled: true,
 				},
-				Proxy:           "",
-				FetchLimitBytes: 10 * 1024 * 1024, // 10MB by default
+				Proxy:                "",
+				FetchLimitBytes:      10 * 1024 * 1024, // 10MB by default
+				FetchCacheTTLSeconds: 300,
 				Brave: BraveConfig{
 					Enabled:    false,
 					APIKey:     "",
@@ -388,6 +477,34 @@ func DefaultConfig() *Config {
 				EnableDenyPatterns: true,
 				TimeoutSeconds:     60,
 			},
+			Browser: BrowserConfig{
+				TimeoutSeconds: 30,
+				MaxChars:       20000,
+			},
+			Calendar: CalendarConfig{
+				ReminderMinutes: 15,
+			},
+			ImageGenerate: ImageGenConfig{
+				Provider:       "openai",
+				Size:           "1024x1024",
+				TimeoutSeconds: 120,
+			},
+			MQTT: MQTTToolConfig{
+				TimeoutSeconds: 10,
+			},
+			SystemInfo: SystemInfoConfig{
+				ToolConfig: ToolConfig{
+					Enabled: true,
+				},
+				DiskWarnPercent:   90,
+				MemoryWarnPercent: 90,
+				TempWarnCelsius:   80,
+			},
+			ContainerExec: ContainerConfig{
+				Image:          "python:3.12-alpine",
+				TimeoutSeconds: 60,
+				MemoryMB:       256,
+			},
 			Skills: SkillsToolsConfig{
 				ToolConfig: ToolConfig{
 					Enabled: true,
@@ -422,28 +539,63 @@ func DefaultConfig() *Config {
 			FindSkills: ToolConfig{
 				Enabled: true,
 			},
+			Glob: ToolConfig{
+				Enabled: true,
+			},
 			I2C: ToolConfig{
 				Enabled: false, // Hardware tool - Linux only
 			},
 			InstallSkill: ToolConfig{
 				Enabled: true,
 			},
+			Jobs: ToolConfig{
+				Enabled: true,
+			},
 			ListDir: ToolConfig{
 				Enabled: true,
 			},
+			Memory: ToolConfig{
+				Enabled: true,
+			},
+			MemoryQuery: MemoryQueryConfig{
+				ToolConfig: ToolConfig{
+					Enabled: true,
+				},
+			},
 			Message: ToolConfig{
 				Enabled: true,
 			},
 			ReadFile: ToolConfig{
 				Enabled: true,
 			},
+			Schedule: ToolConfig{
+				Enabled: true,
+			},
+			Scratchpad: ToolConfig{
+				Enabled: true,
+			},
+			SkillTools: ToolConfig{
+				Enabled: false, // Runs programs that skills bring
+			},
+			Wasm: WasmToolsConfig{
+				ToolConfig:    ToolConfig{Enabled: true},
+				Capabilities:  []string{"read_workspace"},
+				MemoryLimitMB: 64,
+			},
 			Spawn: ToolConfig{
 				Enabled: true,
 			},
 			SPI: ToolConfig{
 				Enabled: false, // Hardware tool - Linux only
 			},
-			Subagent: ToolConfig{
+			Subagent: SubagentToolConfig{
+				ToolConfig: ToolConfig{
+					Enabled: true,
+				},
+				MaxIterations: 10,
+				TokenBudget:   100000,
+			},
+			Tasks: ToolConfig{
 				Enabled: true,
 			},
 			WebFetch: ToolConfig{
@@ -461,5 +613,38 @@ func DefaultConfig() *Config {
 			Enabled:    false,
 			MonitorUSB: true,
 		},
+		Notifications: NotificationsConfig{
+			DedupMinutes: 10,
+			Digest: DigestConfig{
+				Schedule:  "0 21 * * *",
+				Summarize: true,
+			},
+		},
+		Guardrails: GuardrailsConfig{
+			RedactSecrets: true,
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "console",
+			File: LogFileConfig{
+				Enabled:   true,
+				MaxSizeMB: 10,
+				MaxFiles:  3,
+			},
+		},
+		Audit: AuditConfig{
+			Enabled: true,
+		},
+		Outbox: OutboxConfig{
+			RateLimit:   5,
+			MaxAgeHours: 24,
+		},
+		Sync: SyncConfig{
+			IntervalSeconds: 300,
+		},
+		Telemetry: TelemetryConfig{
+			ServiceName: "picoclaw",
+			SampleRatio: 1,
+		},
 	}
 }
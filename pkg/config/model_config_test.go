@@ -29,6 +29,26 @@ func TestGetModelConfig_Found(t *testing.T) {
 	}
 }
 
+func TestLookupModelConfig_AndCost(t *testing.T) {
+	cfg := &Config{
+		ModelList: []ModelConfig{
+			{ModelName: "priced", Model: "openai/gpt-4o", InputPrice: 2.5, OutputPrice: 10},
+			{ModelName: "priced", Model: "openai/gpt-4o-mini"},
+		},
+	}
+
+	mc, ok := cfg.LookupModelConfig("priced")
+	if !ok {
+		t.Fatal("LookupModelConfig() did not find model")
+	}
+	if got := mc.CostUSD(1_000_000, 100_000); got != 3.5 {
+		t.Errorf("CostUSD() = %v, want 3.5", got)
+	}
+	if _, ok := cfg.LookupModelConfig("missing"); ok {
+		t.Error("LookupModelConfig() found nonexistent model")
+	}
+}
+
 func TestGetModelConfig_NotFound(t *testing.T) {
 	cfg := &Config{
 		ModelList: []ModelConfig{
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ProfileConstrained suits boards with 64–128 MB of RAM: one turn at a time,
+// a short history window, a small SQLite cache, a session store that is
+// compacted often and no browser or container tools. The profile only lowers settings, so values below its limits stay.
+const ProfileConstrained = "constrained"
+
+// Limits of the constrained profile.
+const (
+	constrainedMaxConcurrentTurns = 1
+	constrainedMaxParallelTools   = 1
+	constrainedMaxHistoryMessages = 40
+	constrainedSQLiteCacheKiB     = 512
+	constrainedCompactThreshold   = 200
+)
+
+// applyProfile brings the settings within the limits of c.Profile and logs
+// each one it changes.
+func (c *Config) applyProfile() error {
+	switch c.Profile {
+	case "":
+		return nil
+	case ProfileConstrained:
+	default:
+		return fmt.Errorf("profile: unknown profile %q, want %q", c.Profile, ProfileConstrained)
+	}
+
+	d := &c.Agents.Defaults
+	var changed []string
+	lower := func(name string, v *int, limit int) {
+		// 0 means no limit for all of these.
+		if *v == 0 || *v > limit {
+			*v = limit
+			changed = append(changed, name)
+		}
+	}
+	lower("agents.defaults.max_concurrent_turns", &d.MaxConcurrentTurns, constrainedMaxConcurrentTurns)
+	lower("agents.defaults.max_parallel_tools", &d.MaxParallelTools, constrainedMaxParallelTools)
+	lower("agents.defaults.max_history_messages", &d.MaxHistoryMessages, constrainedMaxHistoryMessages)
+	lower("tools.memory_query.cache_kib", &c.Tools.MemoryQuery.CacheKiB, constrainedSQLiteCacheKiB)
+	lower("session.compact_threshold", &c.Session.CompactThreshold, constrainedCompactThreshold)
+
+	disable := func(name string, enabled *bool) {
+		if *enabled {
+			*enabled = false
+			changed = append(changed, name)
+		}
+	}
+	disable("tools.browser.enabled", &c.Tools.Browser.Enabled)
+	disable("tools.container_exec.enabled", &c.Tools.ContainerExec.Enabled)
+
+	if len(changed) > 0 {
+		logger.InfoCF("config", "Settings limited by the profile",
+			map[string]any{"profile": c.Profile, "settings": changed})
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_ConstrainedProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.json")
+	configJSON := `{
+  "profile": "constrained",
+  "agents": {"defaults":{"workspace":"./workspace","model_name":"gpt4","max_history_messages":20}},
+  "model_list": [{"model_name":"gpt4","model":"openai/gpt-5.2","api_key":"x"}],
+  "tools": {"browser":{"enabled":true},"container_exec":{"enabled":true}}
+}`
+	if err := os.WriteFile(configPath, []byte(configJSON), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error: %v", err)
+	}
+	d := cfg.Agents.Defaults
+	if d.MaxConcurrentTurns != 1 || d.MaxParallelTools != 1 {
+		t.Errorf("max_concurrent_turns = %d, max_parallel_tools = %d, want 1 and 1",
+			d.MaxConcurrentTurns, d.MaxParallelTools)
+	}
+	if d.MaxHistoryMessages != 20 {
+		t.Errorf("max_history_messages = %d, want the configured 20", d.MaxHistoryMessages)
+	}
+	if cfg.Tools.MemoryQuery.CacheKiB != constrainedSQLiteCacheKiB {
+		t.Errorf("memory_query.cache_kib = %d, want %d", cfg.Tools.MemoryQuery.CacheKiB, constrainedSQLiteCacheKiB)
+	}
+	if cfg.Session.CompactThreshold != constrainedCompactThreshold {
+		t.Errorf("session.compact_threshold = %d, want %d", cfg.Session.CompactThreshold, constrainedCompactThreshold)
+	}
+	if cfg.Tools.Browser.Enabled || cfg.Tools.ContainerExec.Enabled {
+		t.Error("browser and container_exec are still enabled")
+	}
+	if !cfg.Tools.MemoryQuery.Enabled {
+		t.Error("memory_query was disabled")
+	}
+}
+
+func TestLoadConfig_UnknownProfile(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"profile": "tiny"}`), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil || !strings.Contains(err.Error(), `unknown profile "tiny"`) {
+		t.Fatalf("LoadConfig() error = %v, want unknown profile", err)
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+	"time"
+)
+
+// ChangedPaths returns the dotted paths of the settings that differ between
+// old and new, such as "heartbeat.interval" or
+// "agents.defaults.personas.coach.prompt", sorted. Lists are compared as a
+// whole.
+func ChangedPaths(old, new *Config) []string {
+	before, after := flattenConfig(old), flattenConfig(new)
+	changed := map[string]bool{}
+	for path, v := range after {
+		if prev, ok := before[path]; !ok || prev != v {
+			changed[path] = true
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed[path] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(changed))
+}
+
+// flattenConfig maps the path of every setting in cfg to its JSON value.
+func flattenConfig(cfg *Config) map[string]string {
+	leaves := map[string]string{}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return leaves
+	}
+	var v any
+	if json.Unmarshal(data, &v) == nil {
+		flattenJSON("", v, leaves)
+	}
+	return leaves
+}
+
+func flattenJSON(path string, v any, leaves map[string]string) {
+	if obj, ok := v.(map[string]any); ok && len(obj) > 0 {
+		for k, child := range obj {
+			if path != "" {
+				k = path + "." + k
+			}
+			flattenJSON(k, child, leaves)
+		}
+		return
+	}
+	data, _ := json.Marshal(v) // decoded JSON always marshals
+	leaves[path] = string(data)
+}
+
+// WatchFile calls onChange whenever the contents of the file at path change,
+// checking every interval until ctx is done. Saving the file without
+// changing it does not count.
+func WatchFile(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	var lastMod time.Time
+	var lastSize int64
+	last, _ := os.ReadFile(path)
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil || (info.ModTime().Equal(lastMod) && info.Size() == lastSize) {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+		data, err := os.ReadFile(path)
+		if err != nil || bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		onChange()
+	}
+}
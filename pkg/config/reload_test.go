@@ -0,0 +1,65 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestChangedPaths(t *testing.T) {
+	old := DefaultConfig()
+	cfg := DefaultConfig()
+	if got := ChangedPaths(old, cfg); len(got) != 0 {
+		t.Fatalf("ChangedPaths of equal configs = %v", got)
+	}
+
+	cfg.Heartbeat.Interval = 10
+	cfg.Logging.Components = map[string]string{"agent": "debug"}
+	cfg.Agents.Defaults.Personas = map[string]PersonaConfig{"coach": {Prompt: "Be encouraging."}}
+	cfg.Tools.Exec.Enabled = !old.Tools.Exec.Enabled
+	want := []string{
+		"agents.defaults.personas.coach.prompt",
+		"heartbeat.interval",
+		"logging.components.agent",
+		"tools.exec.enabled",
+	}
+	if got := ChangedPaths(old, cfg); !slices.Equal(got, want) {
+		t.Errorf("ChangedPaths = %v, want %v", got, want)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"a": 1}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 4)
+	go WatchFile(ctx, path, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte(`{"a": 22}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not noticed")
+	}
+
+	// Saved again unchanged, with a new modification time.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-changes:
+		t.Error("unchanged contents reported as a change")
+	default:
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// A secret setting, such as an api_key or a bot token, may say where its
+// value is kept instead of holding it:
+//
+//	"${OPENAI_API_KEY}"         environment variables, also within the value
+//	"file:/run/secrets/openai"  the contents of a file, without the final newline
+//	"keyring:openai"            an entry of the OS keyring, see SetKeyringSecret
+//
+// LoadConfig resolves the references and SaveConfig writes them back, so the
+// values never end up in the config file.
+const (
+	fileRefPrefix    = "file:"
+	keyringRefPrefix = "keyring:"
+
+	// KeyringService is the service the keyring entries are stored under.
+	KeyringService = "picoclaw"
+)
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// secretWords mark the settings whose values are secrets, such as api_key,
+// bot_token, app_secret or the telemetry headers. Matching by name errs on
+// the side of treating too much as secret.
+var secretWords = []string{"key", "token", "secret", "password", "passwd", "auth", "cookie", "credential", "headers"}
+
+// IsSecretKey reports whether the config key name holds a secret, and so
+// everything under it.
+func IsSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretRef is a secret setting that was read from a reference.
+type secretRef struct {
+	ref   string // as written in the file
+	value string // what it resolved to
+}
+
+// isSecretRef reports whether the secret setting s refers to its value.
+func isSecretRef(s string) bool {
+	return strings.HasPrefix(s, fileRefPrefix) || strings.HasPrefix(s, keyringRefPrefix) ||
+		envRefPattern.MatchString(s)
+}
+
+// resolveSecret returns the value ref refers to; relative file paths are
+// relative to dir.
+func resolveSecret(ref, dir string) (string, error) {
+	if path, ok := strings.CutPrefix(ref, fileRefPrefix); ok {
+		path = expandHome(path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if name, ok := strings.CutPrefix(ref, keyringRefPrefix); ok {
+		value, err := keyring.Get(KeyringService, name)
+		if err != nil {
+			return "", fmt.Errorf("keyring entry %q: %w", name, err)
+		}
+		return value, nil
+	}
+	var missing []string
+	value := envRefPattern.ReplaceAllStringFunc(ref, func(m string) string {
+		name := m[2 : len(m)-1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return value, nil
+}
+
+// resolveSecrets replaces the secret references of cfg with their values
+// and remembers them for SaveConfig. dir is the directory of the config
+// file.
+func resolveSecrets(cfg *Config, dir string) error {
+	refs := map[string]secretRef{}
+	err := walkSecrets(reflect.ValueOf(cfg), "", false, func(path, s string) (string, error) {
+		if !isSecretRef(s) {
+			return s, nil
+		}
+		value, err := resolveSecret(s, dir)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		refs[path] = secretRef{ref: s, value: value}
+		return value, nil
+	})
+	if len(refs) > 0 {
+		cfg.secretRefs = refs
+	}
+	return err
+}
+
+// withSecretRefs returns a copy of cfg with the settings that still hold
+// the values LoadConfig resolved set back to their references.
+func withSecretRefs(cfg *Config) (*Config, error) {
+	if len(cfg.secretRefs) == 0 {
+		return cfg, nil
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dup := &Config{}
+	if err := json.Unmarshal(data, dup); err != nil {
+		return nil, err
+	}
+	err = walkSecrets(reflect.ValueOf(dup), "", false, func(path, s string) (string, error) {
+		if r, ok := cfg.secretRefs[path]; ok && r.value == s {
+			return r.ref, nil
+		}
+		return s, nil
+	})
+	return dup, err
+}
+
+// walkSecrets calls fn with the path and value of every string under a
+// secret key of v and sets it to what fn returns. secret is set when v
+// itself is under a secret key.
+func walkSecrets(v reflect.Value, path string, secret bool, fn func(path, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walkSecrets(v.Elem(), path, secret, fn)
+	case reflect.Struct:
+		for _, f := range reflect.VisibleFields(v.Type()) {
+			name, ok := jsonName(f)
+			if !ok {
+				continue
+			}
+			err := walkSecrets(v.FieldByIndex(f.Index), joinPath(path, name), secret || IsSecretKey(name), fn)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := walkSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i), secret, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			// Map values are not addressable; walk a copy and store it back.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := walkSecrets(elem, joinPath(path, key), secret || IsSecretKey(key), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.String:
+		if !secret || v.String() == "" || !v.CanSet() {
+			return nil
+		}
+		s, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// SetKeyringSecret stores value in the OS keyring under name, for a
+// "keyring:<name>" reference.
+func SetKeyringSecret(name, value string) error {
+	return keyring.Set(KeyringService, name, value)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestLoadConfig_SecretRefs(t *testing.T) {
+	keyring.MockInit()
+	if err := SetKeyringSecret("openai", "sk-from-keyring"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_HONEYCOMB_KEY", "hc-123")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "telegram.token"), []byte("123:abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(`{
+  "model_list": [{"model_name": "gpt", "model": "openai/gpt-4o", "api_key": "keyring:openai"}],
+  "channels": {"telegram": {"token": "file:telegram.token"}},
+  "telemetry": {"headers": {"authorization": "Bearer ${TEST_HONEYCOMB_KEY}"}},
+  "heartbeat": {"interval": 45}
+}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.ModelList[0].APIKey; got != "sk-from-keyring" {
+		t.Errorf("api_key = %q", got)
+	}
+	if got := cfg.Channels.Telegram.Token; got != "123:abc" {
+		t.Errorf("token = %q", got)
+	}
+	if got := cfg.Telemetry.Headers["authorization"]; got != "Bearer hc-123" {
+		t.Errorf("header = %q", got)
+	}
+
+	// Saving writes the references back, and keeps a changed value.
+	cfg.Heartbeat.Interval = 60
+	cfg.Channels.Telegram.Token = "456:def"
+	if err := SaveConfig(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{`"keyring:openai"`, `"Bearer ${TEST_HONEYCOMB_KEY}"`, `"456:def"`, `"interval": 60`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("saved config lacks %s", want)
+		}
+	}
+	if strings.Contains(string(data), "sk-from-keyring") || strings.Contains(string(data), "hc-123") {
+		t.Errorf("saved config holds a resolved secret:\n%s", data)
+	}
+	if cfg.ModelList[0].APIKey != "sk-from-keyring" {
+		t.Error("SaveConfig changed the config it was given")
+	}
+}
+
+func TestLoadConfig_SecretRefErrors(t *testing.T) {
+	keyring.MockInit()
+	for name, ref := range map[string]string{
+		"env":     "${TEST_UNSET_SECRET}",
+		"file":    "file:missing.token",
+		"keyring": "keyring:missing",
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		content := `{"channels": {"telegram": {"token": "` + ref + `"}}}`
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadConfig(path)
+		if err == nil || !strings.Contains(err.Error(), "channels.telegram.token") {
+			t.Errorf("%s: err = %v, want one naming the setting", name, err)
+		}
+	}
+}
+
+func TestLoadConfig_RefsOnlyInSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"gateway": {"host": "${TEST_UNSET_HOST}"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Gateway.Host != "${TEST_UNSET_HOST}" {
+		t.Errorf("host = %q, want it as written", cfg.Gateway.Host)
+	}
+}
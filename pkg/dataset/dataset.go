@@ -0,0 +1,204 @@
+// Package dataset records the exchanges with the model as JSONL, to build
+// fine-tuning or evaluation sets from real use. Secrets and personal data
+// are masked before anything is written: the built-in secret patterns and
+// guardrails.redact become "[REDACTED]", and email addresses, phone numbers
+// and card numbers become "[EMAIL]", "[PHONE]" and "[CARD]".
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/guardrails"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/pii"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Exchange is one call to the model: what it was sent and what it
+// answered. Messages with Response appended is a conversation in the
+// OpenAI chat fine-tuning format.
+type Exchange struct {
+	Time         time.Time                  `json:"time"`
+	Model        string                     `json:"model"`
+	Messages     []Message                  `json:"messages"`
+	Tools        []providers.ToolDefinition `json:"tools,omitempty"`
+	Response     Message                    `json:"response"`
+	FinishReason string                     `json:"finish_reason,omitempty"`
+	Usage        *providers.UsageInfo       `json:"usage,omitempty"`
+	DurationMS   int64                      `json:"duration_ms"`
+}
+
+// Message is a message of an exchange. Attached media and reasoning are
+// left out.
+type Message struct {
+	Role       string               `json:"role"`
+	Content    string               `json:"content"`
+	ToolCalls  []providers.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string               `json:"tool_call_id,omitempty"`
+}
+
+// Recorder appends the exchanges that succeed to one file a day in its
+// directory, named after the day (2026-03-04.jsonl). Failed calls are not
+// recorded.
+type Recorder struct {
+	dir      string
+	redact   *guardrails.RedactRule
+	detector *pii.Detector
+	mu       sync.Mutex
+}
+
+// NewRecorder creates a recorder writing to dir. Text matching the
+// regular expressions in redact is masked along with the built-in secret
+// patterns.
+func NewRecorder(dir string, redact []string) (*Recorder, error) {
+	rule, err := guardrails.NewRedactRule(true, redact)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, redact: rule, detector: pii.NewDetector()}, nil
+}
+
+// Middleware returns the provider middleware that records each call.
+func (r *Recorder) Middleware() providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, req *providers.ChatRequest) (*providers.LLMResponse, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			ex := r.exchange(req, resp)
+			ex.Time, ex.DurationMS = start, time.Since(start).Milliseconds()
+			if recErr := r.append(ex); recErr != nil {
+				logger.WarnCF("dataset", "Failed to record exchange",
+					map[string]any{"dir": r.dir, "error": recErr.Error()})
+			}
+			return resp, err
+		}
+	}
+}
+
+// exchange returns the sanitized record of a call.
+func (r *Recorder) exchange(req *providers.ChatRequest, resp *providers.LLMResponse) Exchange {
+	ex := Exchange{
+		Model:        req.Model,
+		Messages:     make([]Message, 0, len(req.Messages)),
+		Tools:        req.Tools,
+		FinishReason: resp.FinishReason,
+		Usage:        resp.Usage,
+	}
+	for _, m := range req.Messages {
+		content := m.Content
+		if content == "" && len(m.SystemParts) > 0 {
+			parts := make([]string, len(m.SystemParts))
+			for i, p := range m.SystemParts {
+				parts[i] = p.Text
+			}
+			content = strings.Join(parts, "\n\n")
+		}
+		ex.Messages = append(ex.Messages, Message{
+			Role:       string(m.Role),
+			Content:    r.sanitize(content),
+			ToolCalls:  r.toolCalls(m.ToolCalls),
+			ToolCallID: m.ToolCallID,
+		})
+	}
+	ex.Response = Message{
+		Role:      "assistant",
+		Content:   r.sanitize(resp.Content),
+		ToolCalls: r.toolCalls(resp.ToolCalls),
+	}
+	return ex
+}
+
+// toolCalls returns calls in the OpenAI shape, with their arguments
+// sanitized.
+func (r *Recorder) toolCalls(calls []providers.ToolCall) []providers.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]providers.ToolCall, len(calls))
+	for i, tc := range calls {
+		name, args := tc.Name, ""
+		if tc.Function != nil {
+			name, args = tc.Function.Name, tc.Function.Arguments
+		} else if len(tc.Arguments) > 0 {
+			data, _ := json.Marshal(tc.Arguments)
+			args = string(data)
+		}
+		out[i] = providers.ToolCall{
+			ID:       tc.ID,
+			Type:     "function",
+			Function: &providers.FunctionCall{Name: name, Arguments: r.sanitizeJSON(args)},
+		}
+	}
+	return out
+}
+
+func (r *Recorder) sanitize(text string) string {
+	text, _ = r.redact.Check(guardrails.Output, text)
+	text, _ = r.detector.Mask(text)
+	return text
+}
+
+// sanitizeJSON sanitizes the strings inside a JSON document, so a mask
+// cannot break its quoting. Text that is not JSON is sanitized as a whole.
+func (r *Recorder) sanitizeJSON(doc string) string {
+	var v any
+	if json.Unmarshal([]byte(doc), &v) != nil {
+		return r.sanitize(doc)
+	}
+	data, err := json.Marshal(r.sanitizeValue(v))
+	if err != nil {
+		return r.sanitize(doc)
+	}
+	return string(data)
+}
+
+func (r *Recorder) sanitizeValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.sanitize(v)
+	case []any:
+		for i := range v {
+			v[i] = r.sanitizeValue(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = r.sanitizeValue(v[k])
+		}
+	}
+	return v
+}
+
+func (r *Recorder) append(ex Exchange) error {
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return fmt.Errorf("marshal exchange: %w", err)
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	path := filepath.Join(r.dir, ex.Time.Local().Format("2006-01-02")+".jsonl")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open dataset file: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("append exchange: %w", err)
+	}
+	return f.Close()
+}
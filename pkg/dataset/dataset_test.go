@@ -0,0 +1,105 @@
+package dataset
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func readExchanges(t *testing.T, dir string) []Exchange {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, time.Now().Format("2006-01-02")+".jsonl"))
+	require.NoError(t, err)
+	defer f.Close()
+	var out []Exchange
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ex Exchange
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &ex))
+		out = append(out, ex)
+	}
+	return out
+}
+
+func TestRecorder(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dataset")
+	rec, err := NewRecorder(dir, []string{`ACME-\d+`})
+	require.NoError(t, err)
+
+	handler := rec.Middleware()(func(context.Context, *providers.ChatRequest) (*providers.LLMResponse, error) {
+		return &providers.LLMResponse{
+			Content:      "Mailed jane@example.com about ACME-42.",
+			FinishReason: "tool_calls",
+			ToolCalls: []providers.ToolCall{{
+				ID: "call_1", Name: "message", Arguments: map[string]any{"to": "+1 415 555 0100", "n": 2},
+			}},
+			Usage: &providers.UsageInfo{PromptTokens: 10, CompletionTokens: 5},
+		}, nil
+	})
+	req := &providers.ChatRequest{
+		Model: "gpt-4o-mini",
+		Messages: []providers.Message{
+			{Role: "system", SystemParts: []providers.ContentBlock{{Text: "You are picoclaw."}, {Text: "Be brief."}}},
+			{Role: "user", Content: "My key is sk-abcdefghijklmnopqrstuvwx, mail jane@example.com", Media: []string{"a.png"}},
+			{Role: "assistant", ToolCalls: []providers.ToolCall{{
+				ID: "call_0", Function: &providers.FunctionCall{Name: "exec", Arguments: `{"command":"echo \"jane@example.com\""}`},
+			}}},
+			{Role: "tool", Content: "jane@example.com", ToolCallID: "call_0"},
+		},
+		Tools: []providers.ToolDefinition{{Type: "function", Function: providers.ToolFunctionDefinition{Name: "exec"}}},
+	}
+	_, err = handler(context.Background(), req)
+	require.NoError(t, err)
+
+	exchanges := readExchanges(t, dir)
+	require.Len(t, exchanges, 1)
+	ex := exchanges[0]
+	assert.Equal(t, "gpt-4o-mini", ex.Model)
+	assert.Equal(t, "You are picoclaw.\n\nBe brief.", ex.Messages[0].Content)
+	assert.Equal(t, "My key is [REDACTED], mail [EMAIL]", ex.Messages[1].Content)
+	assert.Equal(t, `{"command":"echo \"[EMAIL]\""}`, ex.Messages[2].ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "[EMAIL]", ex.Messages[3].Content)
+	assert.Equal(t, "call_0", ex.Messages[3].ToolCallID)
+	require.Len(t, ex.Tools, 1)
+
+	assert.Equal(t, "assistant", ex.Response.Role)
+	assert.Equal(t, "Mailed [EMAIL] about [REDACTED].", ex.Response.Content)
+	call := ex.Response.ToolCalls[0]
+	assert.Equal(t, "function", call.Type)
+	assert.Equal(t, "message", call.Function.Name)
+	assert.JSONEq(t, `{"to":"[PHONE]","n":2}`, call.Function.Arguments)
+	assert.Equal(t, "tool_calls", ex.FinishReason)
+	assert.Equal(t, 5, ex.Usage.CompletionTokens)
+
+	info, err := os.Stat(filepath.Join(dir, time.Now().Format("2006-01-02")+".jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestRecorder_SkipsFailures(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := NewRecorder(dir, nil)
+	require.NoError(t, err)
+	handler := rec.Middleware()(func(context.Context, *providers.ChatRequest) (*providers.LLMResponse, error) {
+		return nil, errors.New("rate limited")
+	})
+	_, err = handler(context.Background(), &providers.ChatRequest{Model: "m"})
+	assert.Error(t, err)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestNewRecorder_InvalidPattern(t *testing.T) {
+	_, err := NewRecorder(t.TempDir(), []string{"("})
+	assert.Error(t, err)
+}
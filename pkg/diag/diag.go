@@ -0,0 +1,402 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package diag writes diagnostic bundles for bug reports: zip files holding
+// the stack of a crash, the latest log entries, the config with its secrets
+// redacted and the size of each store in the workspace.
+//
+// After Install, a panic caught by Recover writes a crash bundle before the
+// process exits. Crashes Recover does not see, such as a panic in another
+// goroutine or a fatal runtime error, are written to crash.txt by the Go
+// runtime and turned into a crash bundle the next time Install or Collect
+// runs.
+package diag
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// Bundle kinds, which prefix their file names.
+const (
+	KindCrash = "crash" // written when the process crashed
+	KindDiag  = "diag"  // written by Collect, for a bug report
+)
+
+const (
+	crashFile = "crash.txt"
+	// logFile is the file the logger writes to in its directory.
+	logFile     = "picoclaw.log"
+	logTailSize = 256 << 10
+	// maxBundles of each kind are kept, so a crash loop cannot fill the disk.
+	maxBundles = 10
+	// maxCrashStacks is how many of the latest crashes a diag bundle holds.
+	maxCrashStacks = 3
+)
+
+// Source says where a bundle's contents come from.
+type Source struct {
+	Dir       string         // where bundles are written
+	Workspace string         // its directories are measured for stores.json
+	LogDir    string         // holds picoclaw.log; empty when file logging is off
+	Config    *config.Config // written with its secrets redacted
+	Version   string
+}
+
+// Bundle is a bundle file in a Source's Dir.
+type Bundle struct {
+	Path string
+	Kind string
+	Time time.Time
+	Size int64
+}
+
+var (
+	mu        sync.Mutex
+	installed *Source
+	started   = time.Now()
+)
+
+// Install bundles the crash of an earlier run, if any, and makes the
+// runtime write the output of the next crash to src.Dir. From then on,
+// Recover writes bundles from src.
+func Install(src Source) error {
+	if err := os.MkdirAll(src.Dir, 0o700); err != nil {
+		return fmt.Errorf("diag: create directory: %w", err)
+	}
+	if _, err := bundlePendingCrash(src); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(src.Dir, crashFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("diag: open crash output: %w", err)
+	}
+	// The runtime keeps its own duplicate of the file descriptor.
+	defer f.Close()
+	if err := debug.SetCrashOutput(f, debug.CrashOptions{}); err != nil {
+		return fmt.Errorf("diag: set crash output: %w", err)
+	}
+
+	mu.Lock()
+	installed = &src
+	mu.Unlock()
+	return nil
+}
+
+// Recover, deferred at the top of a goroutine, turns a panic into a crash
+// bundle: it prints the panic and its stack, writes the bundle and exits
+// with status 2, as an unrecovered panic would. Before Install, the panic
+// goes on unhandled.
+func Recover() {
+	p := recover()
+	if p == nil {
+		return
+	}
+	mu.Lock()
+	src := installed
+	mu.Unlock()
+	if src == nil {
+		panic(p)
+	}
+
+	stack := fmt.Appendf(nil, "panic: %v\n\n%s", p, debug.Stack())
+	os.Stderr.Write(stack)
+	path, err := Write(*src, KindCrash, fmt.Sprintf("panic: %v", p), stack)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nFailed to write diagnostic bundle: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "\nDiagnostic bundle written to %s\n"+
+			"Run \"picoclaw diag\" to collect it for a bug report.\n", path)
+	}
+	os.Exit(2)
+}
+
+// Collect bundles a crash not bundled yet and then writes a diag bundle,
+// which also holds the stacks of the latest crashes. It returns the path of
+// the diag bundle.
+func Collect(src Source) (string, error) {
+	if err := os.MkdirAll(src.Dir, 0o700); err != nil {
+		return "", fmt.Errorf("diag: create directory: %w", err)
+	}
+	if _, err := bundlePendingCrash(src); err != nil {
+		return "", err
+	}
+	return Write(src, KindDiag, "collected for a bug report", nil)
+}
+
+// Write writes a bundle of kind to src.Dir, removing the oldest bundles of
+// that kind beyond the limit, and returns its path. stack may be empty.
+func Write(src Source, kind, reason string, stack []byte) (string, error) {
+	now := time.Now()
+	files := []bundleFile{
+		{"info.json", jsonFile(newInfo(src, reason, now))},
+		{"config.json", jsonFile(RedactConfig(src.Config))},
+		{"stores.json", jsonFile(workspaceStats(src.Workspace, src.Dir))},
+	}
+	if len(stack) > 0 {
+		files = append(files, bundleFile{"stack.txt", stack})
+	}
+	if src.LogDir != "" {
+		files = append(files, bundleFile{logFile, logTail(filepath.Join(src.LogDir, logFile))})
+	}
+	if kind == KindDiag {
+		files = append(files, crashStacks(src.Dir)...)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return "", fmt.Errorf("diag: add %s: %w", f.name, err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return "", fmt.Errorf("diag: add %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("diag: finish bundle: %w", err)
+	}
+
+	path := filepath.Join(src.Dir, fmt.Sprintf("%s-%s.zip", kind, now.Format("20060102-150405.000")))
+	if err := fileutil.WriteFileAtomic(path, buf.Bytes(), 0o600); err != nil {
+		return "", fmt.Errorf("diag: write bundle: %w", err)
+	}
+	prune(src.Dir, kind)
+	return path, nil
+}
+
+// List returns the bundles in dir, newest first.
+func List(dir string) ([]Bundle, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("diag: list bundles: %w", err)
+	}
+	var bundles []Bundle
+	for _, entry := range entries {
+		kind, stamp, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".zip"), "-")
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".zip") || !ok {
+			continue
+		}
+		t, err := time.ParseInLocation("20060102-150405.000", stamp, time.Local)
+		if err != nil || (kind != KindCrash && kind != KindDiag) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		bundles = append(bundles, Bundle{Path: filepath.Join(dir, entry.Name()), Kind: kind, Time: t, Size: info.Size()})
+	}
+	slices.SortFunc(bundles, func(a, b Bundle) int { return b.Time.Compare(a.Time) })
+	return bundles, nil
+}
+
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+func jsonFile(v any) []byte {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Appendf(nil, "marshal failed: %v\n", err)
+	}
+	return append(data, '\n')
+}
+
+type info struct {
+	Time          time.Time `json:"time"`
+	Reason        string    `json:"reason"`
+	Version       string    `json:"version"`
+	GoVersion     string    `json:"go_version"`
+	OS            string    `json:"os"`
+	Arch          string    `json:"arch"`
+	NumCPU        int       `json:"num_cpu"`
+	PID           int       `json:"pid"`
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	Goroutines    int       `json:"goroutines"`
+	HeapBytes     uint64    `json:"heap_bytes"`
+	SysBytes      uint64    `json:"sys_bytes"`
+	NumGC         uint32    `json:"num_gc"`
+}
+
+func newInfo(src Source, reason string, now time.Time) info {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return info{
+		Time:          now,
+		Reason:        reason,
+		Version:       src.Version,
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		PID:           os.Getpid(),
+		UptimeSeconds: int64(now.Sub(started).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapBytes:     mem.HeapAlloc,
+		SysBytes:      mem.Sys,
+		NumGC:         mem.NumGC,
+	}
+}
+
+// storeStats is the size of one directory of the workspace.
+type storeStats struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// workspaceStats measures each top-level directory of workspace but skip;
+// the files directly in workspace are counted under ".".
+func workspaceStats(workspace, skip string) map[string]storeStats {
+	stats := make(map[string]storeStats)
+	if workspace == "" {
+		return stats
+	}
+	entries, _ := os.ReadDir(workspace)
+	for _, entry := range entries {
+		path := filepath.Join(workspace, entry.Name())
+		if path == filepath.Clean(skip) {
+			continue
+		}
+		if !entry.IsDir() {
+			s := stats["."]
+			if info, err := entry.Info(); err == nil {
+				s.Files++
+				s.Bytes += info.Size()
+			}
+			stats["."] = s
+			continue
+		}
+		var s storeStats
+		filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				s.Files++
+				s.Bytes += info.Size()
+			}
+			return nil
+		})
+		stats[entry.Name()] = s
+	}
+	return stats
+}
+
+// logTail returns the last entries of the log file at path, starting at a
+// line boundary.
+func logTail(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Appendf(nil, "log not available: %v\n", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Appendf(nil, "log not available: %v\n", err)
+	}
+	offset := max(info.Size()-logTailSize, 0)
+	data, err := io.ReadAll(io.NewSectionReader(f, offset, info.Size()-offset))
+	if err != nil {
+		return fmt.Appendf(nil, "log not available: %v\n", err)
+	}
+	if offset > 0 {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	return data
+}
+
+// bundlePendingCrash turns the crash output of an earlier run into a crash
+// bundle and empties it. It returns the bundle's path, or "" when there was
+// no crash.
+func bundlePendingCrash(src Source) (string, error) {
+	crashPath := filepath.Join(src.Dir, crashFile)
+	output, err := os.ReadFile(crashPath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && len(bytes.TrimSpace(output)) == 0) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("diag: read crash output: %w", err)
+	}
+	path, err := Write(src, KindCrash, "crashed in an earlier run", output)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Truncate(crashPath, 0); err != nil {
+		return "", fmt.Errorf("diag: clear crash output: %w", err)
+	}
+	return path, nil
+}
+
+// crashStacks returns the stacks of the latest crash bundles in dir as
+// crashes/<bundle>.txt.
+func crashStacks(dir string) []bundleFile {
+	bundles, _ := List(dir)
+	var files []bundleFile
+	for _, b := range bundles {
+		if b.Kind != KindCrash {
+			continue
+		}
+		if stack, err := readBundleFile(b.Path, "stack.txt"); err == nil {
+			name := "crashes/" + strings.TrimSuffix(filepath.Base(b.Path), ".zip") + ".txt"
+			files = append(files, bundleFile{name, stack})
+		}
+		if len(files) == maxCrashStacks {
+			break
+		}
+	}
+	return files
+}
+
+func readBundleFile(path, name string) ([]byte, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// prune removes the oldest bundles of kind in dir beyond maxBundles.
+func prune(dir, kind string) {
+	bundles, _ := List(dir)
+	kept := 0
+	for _, b := range bundles {
+		if b.Kind != kind {
+			continue
+		}
+		if kept++; kept > maxBundles {
+			os.Remove(b.Path)
+		}
+	}
+}
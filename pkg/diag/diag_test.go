@@ -0,0 +1,125 @@
+package diag
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func testSource(t *testing.T) Source {
+	t.Helper()
+	workspace := t.TempDir()
+	logDir := filepath.Join(workspace, "logs")
+	if err := os.MkdirAll(filepath.Join(workspace, "sessions"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "sessions", "a.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, logFile), []byte(`{"message":"started"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.Channels.Telegram.Token = "123:secret-bot-token"
+	return Source{
+		Dir:       filepath.Join(workspace, "diag"),
+		Workspace: workspace,
+		LogDir:    logDir,
+		Config:    cfg,
+		Version:   "test",
+	}
+}
+
+func TestWrite_Bundle(t *testing.T) {
+	src := testSource(t)
+	if err := os.MkdirAll(src.Dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path, err := Write(src, KindCrash, "panic: boom", []byte("panic: boom\n\ngoroutine 1"))
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"info.json":   `"reason": "panic: boom"`,
+		"stack.txt":   "goroutine 1",
+		"config.json": `"token": "[REDACTED]"`,
+		"stores.json": `"sessions": {`,
+		logFile:       "started",
+	} {
+		data, err := readBundleFile(path, name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s does not contain %q:\n%s", name, want, data)
+		}
+	}
+	config, _ := readBundleFile(path, "config.json")
+	if strings.Contains(string(config), "secret-bot-token") {
+		t.Error("config.json holds the bot token")
+	}
+
+	bundles, err := List(src.Dir)
+	if err != nil || len(bundles) != 1 || bundles[0].Kind != KindCrash || bundles[0].Path != path {
+		t.Errorf("List = %+v, %v", bundles, err)
+	}
+}
+
+func TestCollect_BundlesPendingCrash(t *testing.T) {
+	src := testSource(t)
+	if err := os.MkdirAll(src.Dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	crashPath := filepath.Join(src.Dir, crashFile)
+	if err := os.WriteFile(crashPath, []byte("fatal error: concurrent map writes\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := Collect(src)
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	bundles, _ := List(src.Dir)
+	if len(bundles) != 2 || bundles[0].Kind != KindDiag || bundles[1].Kind != KindCrash {
+		t.Fatalf("bundles = %+v, want a diag and a crash bundle", bundles)
+	}
+	if data, _ := os.ReadFile(crashPath); len(data) != 0 {
+		t.Errorf("crash output not cleared: %q", data)
+	}
+	name := "crashes/" + strings.TrimSuffix(filepath.Base(bundles[1].Path), ".zip") + ".txt"
+	stack, err := readBundleFile(path, name)
+	if err != nil || !strings.Contains(string(stack), "concurrent map writes") {
+		t.Errorf("%s = %q, %v", name, stack, err)
+	}
+}
+
+func TestRedactConfig(t *testing.T) {
+	v := redact(map[string]any{
+		"api_keys":   []any{"k1", "k2"},
+		"max_tokens": 4096.0,
+		"headers":    map[string]any{"x-honeycomb-team": "abc"},
+		"dsn":        "postgres://bob:hunter2@db:5432/app",
+		"model":      "gpt-4o",
+	}).(map[string]any)
+
+	if keys := v["api_keys"].([]any); keys[0] != redacted || keys[1] != redacted {
+		t.Errorf("api_keys = %v", keys)
+	}
+	if v["max_tokens"] != 4096.0 || v["model"] != "gpt-4o" {
+		t.Errorf("non-secrets changed: %v", v)
+	}
+	if h := v["headers"].(map[string]any); h["x-honeycomb-team"] != redacted {
+		t.Errorf("headers = %v", h)
+	}
+	if dsn := v["dsn"].(string); strings.Contains(dsn, "hunter2") || !strings.Contains(dsn, "bob") {
+		t.Errorf("dsn = %q", dsn)
+	}
+}
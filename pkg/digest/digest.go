@@ -0,0 +1,260 @@
+// Package digest builds reports of what the agents did over a period: the
+// sessions that were active, the tools they called and what the model calls
+// cost, from the session history, the usage ledger and the tool call log.
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// excerptMessages is how many of the last user and assistant messages of a
+// session a Summarizer is given.
+const excerptMessages = 12
+
+// Source is where Build reads one agent's activity. Any field but AgentID
+// may be nil.
+type Source struct {
+	AgentID   string
+	Sessions  *session.SessionManager
+	Usage     *memory.UsageLedger
+	ToolCalls *memory.ToolCallLog
+}
+
+// Session is the activity of one session over the period.
+type Session struct {
+	Key     string
+	AgentID string
+	Usage   memory.UsageTotals
+	Tools   map[string]int // calls per tool
+	Failed  int            // tool calls that failed, were invalid or denied
+
+	// Excerpt is the end of the conversation, when the session was
+	// updated in the period; Summary is what a Summarizer made of it.
+	Excerpt []providers.Message
+	Summary string
+}
+
+// ToolCalls returns the number of tool calls of the session.
+func (s *Session) ToolCalls() int {
+	n := 0
+	for _, c := range s.Tools {
+		n += c
+	}
+	return n
+}
+
+// Report is the activity of all sessions over [Since, Until).
+type Report struct {
+	Since    time.Time
+	Until    time.Time
+	Sessions []*Session // most expensive first
+	Usage    memory.UsageTotals
+}
+
+// Summarizer returns a short summary of the conversation in s.Excerpt.
+type Summarizer func(ctx context.Context, s *Session) (string, error)
+
+// Build collects the activity in [since, until) from sources. A session is
+// in the report when it made a model or tool call in the period, or when
+// its history changed in it.
+func Build(ctx context.Context, sources []Source, since, until time.Time) (*Report, error) {
+	r := &Report{Since: since, Until: until}
+	byKey := make(map[string]*Session)
+	get := func(key, agentID string) *Session {
+		s, ok := byKey[key]
+		if !ok {
+			s = &Session{Key: key, AgentID: agentID, Tools: make(map[string]int)}
+			byKey[key] = s
+		}
+		return s
+	}
+	in := func(t time.Time) bool { return !t.Before(since) && t.Before(until) }
+
+	for _, src := range sources {
+		if src.Usage != nil {
+			records, err := src.Usage.Records(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("usage of agent %s: %w", src.AgentID, err)
+			}
+			for _, rec := range records {
+				if in(rec.Time) {
+					addUsage(&get(rec.SessionKey, src.AgentID).Usage, rec)
+					addUsage(&r.Usage, rec)
+				}
+			}
+		}
+		if src.ToolCalls != nil {
+			records, err := src.ToolCalls.Records(ctx, memory.ToolCallFilter{Since: since})
+			if err != nil {
+				return nil, fmt.Errorf("tool calls of agent %s: %w", src.AgentID, err)
+			}
+			for _, rec := range records {
+				if !in(rec.Time) {
+					continue
+				}
+				s := get(rec.SessionKey, src.AgentID)
+				s.Tools[rec.Tool]++
+				switch rec.Outcome {
+				case memory.ToolOutcomeError, memory.ToolOutcomeInvalid, memory.ToolOutcomeDenied:
+					s.Failed++
+				}
+			}
+		}
+		if src.Sessions != nil {
+			for _, key := range src.Sessions.Keys() {
+				snap, ok := src.Sessions.Snapshot(key)
+				if !ok || !in(snap.Updated) {
+					continue
+				}
+				get(key, src.AgentID).Excerpt = excerpt(snap.Messages)
+			}
+		}
+	}
+
+	for _, s := range byKey {
+		r.Sessions = append(r.Sessions, s)
+	}
+	sort.Slice(r.Sessions, func(i, j int) bool {
+		a, b := r.Sessions[i], r.Sessions[j]
+		if a.Usage.CostUSD != b.Usage.CostUSD {
+			return a.Usage.CostUSD > b.Usage.CostUSD
+		}
+		if a.Usage.TotalTokens != b.Usage.TotalTokens {
+			return a.Usage.TotalTokens > b.Usage.TotalTokens
+		}
+		return a.Key < b.Key
+	})
+	return r, nil
+}
+
+// Empty reports whether nothing happened in the period.
+func (r *Report) Empty() bool {
+	return len(r.Sessions) == 0
+}
+
+// Summarize has fn summarize every session with an excerpt. The sessions
+// it fails for keep no summary; their errors are returned together.
+func (r *Report) Summarize(ctx context.Context, fn Summarizer) error {
+	var errs []error
+	for _, s := range r.Sessions {
+		if len(s.Excerpt) == 0 {
+			continue
+		}
+		summary, err := fn(ctx, s)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", s.Key, err))
+			continue
+		}
+		s.Summary = strings.TrimSpace(summary)
+	}
+	return errors.Join(errs...)
+}
+
+// Text renders the report as a notification body.
+func (r *Report) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s – %s: %d session(s), %d model call(s), %d tokens, $%.4f\n",
+		r.Since.Format("Jan 2 15:04"), r.Until.Format("Jan 2 15:04"),
+		len(r.Sessions), r.Usage.Requests, r.Usage.TotalTokens, r.Usage.CostUSD)
+	for _, s := range r.Sessions {
+		fmt.Fprintf(&sb, "\n• %s: %d model call(s), $%.4f", s.Key, s.Usage.Requests, s.Usage.CostUSD)
+		if n := s.ToolCalls(); n > 0 {
+			fmt.Fprintf(&sb, ", %d tool call(s)", n)
+			if s.Failed > 0 {
+				fmt.Fprintf(&sb, " (%d failed)", s.Failed)
+			}
+			sb.WriteString(": " + toolList(s.Tools))
+		}
+		sb.WriteString("\n")
+		if s.Summary != "" {
+			sb.WriteString("  " + strings.ReplaceAll(s.Summary, "\n", "\n  ") + "\n")
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Run calls send at each time the cron expression schedule gives, with
+// the period since the previous one, until ctx is done. The first period
+// starts at the tick before the first one.
+func Run(ctx context.Context, schedule string, send func(ctx context.Context, since, until time.Time)) {
+	next, err := gronx.NextTickAfter(schedule, time.Now(), false)
+	if err != nil {
+		logger.ErrorCF("digest", "Invalid digest schedule", map[string]any{"schedule": schedule, "error": err.Error()})
+		return
+	}
+	since, err := gronx.PrevTickBefore(schedule, next, false)
+	if err != nil {
+		since = next.Add(-24 * time.Hour)
+	}
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		send(ctx, since, next)
+		since = next
+		if next, err = gronx.NextTickAfter(schedule, time.Now(), false); err != nil {
+			return
+		}
+	}
+}
+
+func addUsage(t *memory.UsageTotals, rec memory.UsageRecord) {
+	t.Requests++
+	t.PromptTokens += rec.PromptTokens
+	t.CompletionTokens += rec.CompletionTokens
+	t.TotalTokens += rec.TotalTokens
+	t.CachedTokens += rec.CachedTokens
+	t.CostUSD += rec.CostUSD
+}
+
+// excerpt returns the last user and assistant messages that have text.
+func excerpt(history []providers.Message) []providers.Message {
+	var out []providers.Message
+	for i := len(history) - 1; i >= 0 && len(out) < excerptMessages; i-- {
+		m := history[i]
+		if (m.Role == "user" || m.Role == "assistant") && strings.TrimSpace(m.Content) != "" {
+			out = append(out, m)
+		}
+	}
+	slices.Reverse(out)
+	return out
+}
+
+// toolList renders calls per tool, most called first.
+func toolList(tools map[string]int) string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if tools[names[i]] != tools[names[j]] {
+			return tools[names[i]] > tools[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s ×%d", name, tools[name])
+	}
+	return strings.Join(parts, ", ")
+}
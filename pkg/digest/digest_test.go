@@ -0,0 +1,116 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func testSource(t *testing.T, since time.Time) Source {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+	usage, err := memory.NewUsageLedger(dir)
+	require.NoError(t, err)
+	calls, err := memory.NewToolCallLog(dir)
+	require.NoError(t, err)
+
+	for _, rec := range []memory.UsageRecord{
+		{Time: since.Add(time.Hour), SessionKey: "agent:main:telegram:1", TotalTokens: 100, CostUSD: 0.01},
+		{Time: since.Add(2 * time.Hour), SessionKey: "agent:main:telegram:1", TotalTokens: 50, CostUSD: 0.02},
+		{Time: since.Add(3 * time.Hour), SessionKey: "agent:main:cron", TotalTokens: 500, CostUSD: 0.05},
+		{Time: since.Add(-time.Hour), SessionKey: "agent:main:old", TotalTokens: 900, CostUSD: 1},
+	} {
+		require.NoError(t, usage.Record(ctx, rec))
+	}
+	for _, rec := range []memory.ToolCallRecord{
+		{Time: since.Add(time.Hour), SessionKey: "agent:main:telegram:1", Tool: "web_search", Outcome: "ok"},
+		{Time: since.Add(time.Hour), SessionKey: "agent:main:telegram:1", Tool: "web_search", Outcome: "ok"},
+		{Time: since.Add(time.Hour), SessionKey: "agent:main:telegram:1", Tool: "exec", Outcome: "denied"},
+		{Time: since.Add(-time.Hour), SessionKey: "agent:main:old", Tool: "exec", Outcome: "ok"},
+	} {
+		require.NoError(t, calls.Record(ctx, rec))
+	}
+
+	sessions := session.NewSessionManager("")
+	sessions.AddMessage("agent:main:telegram:1", "user", "find a tulip shop")
+	sessions.AddMessage("agent:main:telegram:1", "assistant", "There is one on Main Street.")
+	return Source{AgentID: "main", Sessions: sessions, Usage: usage, ToolCalls: calls}
+}
+
+func TestBuild(t *testing.T) {
+	since := time.Now().Add(-12 * time.Hour)
+	report, err := Build(context.Background(), []Source{testSource(t, since)}, since, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	require.Len(t, report.Sessions, 2)
+	assert.Equal(t, 3, report.Usage.Requests)
+	assert.Equal(t, 650, report.Usage.TotalTokens)
+	assert.InDelta(t, 0.08, report.Usage.CostUSD, 1e-9)
+
+	cron, chat := report.Sessions[0], report.Sessions[1]
+	assert.Equal(t, "agent:main:cron", cron.Key, "most expensive first")
+	assert.Empty(t, cron.Excerpt)
+	assert.Equal(t, "agent:main:telegram:1", chat.Key)
+	assert.Equal(t, map[string]int{"web_search": 2, "exec": 1}, chat.Tools)
+	assert.Equal(t, 1, chat.Failed)
+	assert.Len(t, chat.Excerpt, 2)
+}
+
+func TestBuild_Empty(t *testing.T) {
+	since := time.Now().Add(24 * time.Hour)
+	report, err := Build(context.Background(), []Source{testSource(t, time.Now())}, since, since.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, report.Empty())
+}
+
+func TestReport_SummarizeAndText(t *testing.T) {
+	since := time.Now().Add(-12 * time.Hour)
+	report, err := Build(context.Background(), []Source{testSource(t, since)}, since, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	var asked []string
+	err = report.Summarize(context.Background(), func(_ context.Context, s *Session) (string, error) {
+		asked = append(asked, s.Key)
+		return "Looked for a tulip shop.\n", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent:main:telegram:1"}, asked, "only sessions with an excerpt")
+
+	text := report.Text()
+	assert.Contains(t, text, "2 session(s), 3 model call(s), 650 tokens, $0.0800")
+	assert.Contains(t, text, "• agent:main:telegram:1: 2 model call(s), $0.0300, 3 tool call(s) (1 failed): "+
+		"web_search ×2, exec ×1\n  Looked for a tulip shop.")
+
+	err = report.Summarize(context.Background(), func(context.Context, *Session) (string, error) {
+		return "", errors.New("model down")
+	})
+	assert.ErrorContains(t, err, "agent:main:telegram:1: model down")
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// The six-field form has seconds: a tick comes every second.
+	periods := make(chan [2]time.Time, 1)
+	go Run(ctx, "* * * * * *", func(_ context.Context, since, until time.Time) {
+		select {
+		case periods <- [2]time.Time{since, until}:
+		default:
+		}
+	})
+	select {
+	case p := <-periods:
+		assert.Equal(t, time.Second, p[1].Sub(p[0]))
+	case <-ctx.Done():
+		t.Fatal("no digest period within 3s")
+	}
+}
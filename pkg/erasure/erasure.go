@@ -0,0 +1,332 @@
+// Package erasure removes what PicoClaw keeps about one person, for when a
+// user asks to be forgotten: their direct conversations, the facts learned
+// from them, their tasks, the attachments they sent, and the usage records,
+// tool calls, traces and audit entries of their conversations.
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// Workspace holds the stores of one agent workspace. Any field may be nil.
+type Workspace struct {
+	Sessions  *session.SessionManager
+	Facts     *memory.FactStore
+	Tasks     *memory.TaskStore
+	Usage     *memory.UsageLedger
+	ToolCalls *memory.ToolCallLog
+	Traces    *memory.TraceLog
+}
+
+// Eraser erases people from a set of workspaces. Links, Audit and Media may
+// be nil; without Links every account is a person of its own.
+type Eraser struct {
+	Workspaces []Workspace
+	Links      *identity.Links
+	Audit      *audit.Log
+	Media      media.MediaStore
+}
+
+// Report lists what Erase deleted, or would delete on a dry run.
+type Report struct {
+	DryRun   bool
+	Person   string
+	Accounts []string // the accounts of Person, the one asked for included
+	Sessions []string
+	// Shared lists main sessions, which every direct chat shares with
+	// dm_scope "main", last used by the person. They are kept.
+	Shared       []string
+	Facts        int
+	Tasks        int
+	Attachments  int
+	UsageRecords int
+	ToolCalls    int
+	Traces       int // turns
+	AuditEntries int
+	Unlinked     []string // accounts linked with /link, unlinked from the person
+	ConfigLinks  []string // accounts linked in identity_links, to be removed from the config by hand
+}
+
+// Erase deletes everything linked to userID, an account such as
+// "telegram:123456" or a person named in identity_links, and to the other
+// accounts of the same person. A conversation is theirs when it is a direct
+// chat with one of the accounts; group chats are kept. With dryRun nothing
+// is deleted and the report says what would be.
+func (e *Eraser) Erase(ctx context.Context, userID string, dryRun bool) (*Report, error) {
+	userID = strings.ToLower(strings.TrimSpace(userID))
+	if userID == "" {
+		return nil, errors.New("erasure: no user given")
+	}
+	report := &Report{DryRun: dryRun, Person: userID}
+	accounts := map[string]bool{userID: true}
+	if e.Links != nil {
+		report.Person = e.Links.Person(userID)
+		for _, acc := range e.Links.Accounts(report.Person) {
+			accounts[acc] = true
+		}
+	}
+	for acc := range accounts {
+		report.Accounts = append(report.Accounts, acc)
+	}
+	sort.Strings(report.Accounts)
+
+	var errs []error
+	erased := make(map[string]bool) // session keys, in any workspace
+	for _, ws := range e.Workspaces {
+		keys, refs := e.findSessions(ws, report, accounts)
+		for _, key := range keys {
+			erased[key] = true
+		}
+		errs = append(errs, e.eraseWorkspace(ctx, ws, report, accounts, keys, refs)...)
+	}
+	if e.Audit != nil {
+		errs = append(errs, e.eraseAudit(ctx, report, accounts, erased))
+	}
+	if e.Links != nil {
+		errs = append(errs, e.unlink(report))
+	}
+	sort.Strings(report.Sessions)
+	sort.Strings(report.Shared)
+	return report, errors.Join(errs...)
+}
+
+// findSessions returns the sessions of ws to erase and the media refs of
+// their messages, and adds the shared ones to report.
+func (e *Eraser) findSessions(ws Workspace, report *Report, accounts map[string]bool) ([]string, []string) {
+	if ws.Sessions == nil {
+		return nil, nil
+	}
+	var keys, refs []string
+	for _, key := range ws.Sessions.Keys() {
+		s, ok := ws.Sessions.Snapshot(key)
+		if !ok {
+			continue
+		}
+		if !isDirectWith(key, report.Person, accounts) {
+			if s.Person == report.Person && isMainKey(key) {
+				report.Shared = append(report.Shared, key)
+			}
+			if s.Person != report.Person || isMainKey(key) {
+				continue
+			}
+		}
+		keys = append(keys, key)
+		for _, m := range s.Messages {
+			for _, ref := range m.Media {
+				if strings.HasPrefix(ref, "media://") {
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+	return keys, refs
+}
+
+func (e *Eraser) eraseWorkspace(
+	ctx context.Context, ws Workspace, report *Report, accounts map[string]bool, keys, refs []string,
+) []error {
+	var errs []error
+	inSession := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		inSession[key] = true
+	}
+
+	// Resolve the attachments before their sessions go.
+	for _, ref := range refs {
+		if e.Media == nil {
+			break
+		}
+		path, err := e.Media.Resolve(ref)
+		if err != nil {
+			continue // released or expired already
+		}
+		if !report.DryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, fmt.Errorf("erasure: remove attachment: %w", err))
+				continue
+			}
+		}
+		report.Attachments++
+	}
+
+	for _, key := range keys {
+		if ws.Traces != nil {
+			n, err := countOrErase(report.DryRun, func() (int, error) {
+				turns, err := ws.Traces.Turns(ctx, key)
+				return len(turns), err
+			}, func() (int, error) { return ws.Traces.Delete(ctx, key) })
+			report.Traces += n
+			errs = append(errs, err)
+		}
+		if !report.DryRun {
+			if _, err := ws.Sessions.Delete(key); err != nil {
+				errs = append(errs, fmt.Errorf("erasure: delete session %s: %w", key, err))
+				continue
+			}
+		}
+		report.Sessions = append(report.Sessions, key)
+	}
+
+	if ws.Facts != nil {
+		match := func(f memory.Fact) bool {
+			return f.Person != "" && (f.Person == report.Person || accounts[f.Person]) || inSession[f.Source]
+		}
+		n, err := countOrErase(report.DryRun, func() (int, error) {
+			facts, err := ws.Facts.List(ctx)
+			return count(facts, match), err
+		}, func() (int, error) { return ws.Facts.Remove(ctx, match) })
+		report.Facts += n
+		errs = append(errs, err)
+	}
+	if ws.Tasks != nil {
+		match := func(t memory.Task) bool {
+			return t.Person != "" && (t.Person == report.Person || accounts[t.Person]) || inSession[t.Source]
+		}
+		n, err := countOrErase(report.DryRun, func() (int, error) {
+			tasks, err := ws.Tasks.List(ctx)
+			return count(tasks, match), err
+		}, func() (int, error) { return ws.Tasks.Remove(ctx, match) })
+		report.Tasks += n
+		errs = append(errs, err)
+	}
+	if ws.Usage != nil {
+		match := func(rec memory.UsageRecord) bool { return inSession[rec.SessionKey] }
+		n, err := countOrErase(report.DryRun, func() (int, error) {
+			records, err := ws.Usage.Records(ctx)
+			return count(records, match), err
+		}, func() (int, error) { return ws.Usage.Remove(ctx, match) })
+		report.UsageRecords += n
+		errs = append(errs, err)
+	}
+	if ws.ToolCalls != nil {
+		match := func(rec memory.ToolCallRecord) bool {
+			return inSession[rec.SessionKey] || accounts[accountOf(rec.Channel, rec.SenderID)]
+		}
+		n, err := countOrErase(report.DryRun, func() (int, error) {
+			records, err := ws.ToolCalls.Records(ctx, memory.ToolCallFilter{})
+			return count(records, match), err
+		}, func() (int, error) { return ws.ToolCalls.Remove(ctx, match) })
+		report.ToolCalls += n
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// eraseAudit removes the entries of the person's accounts and sessions,
+// and records that it did, without saying whose they were.
+func (e *Eraser) eraseAudit(ctx context.Context, report *Report, accounts, sessions map[string]bool) error {
+	match := func(ev audit.Event) bool {
+		return sessions[ev.SessionKey] || accounts[accountOf(ev.Channel, ev.Actor)]
+	}
+	n, err := countOrErase(report.DryRun, func() (int, error) {
+		events, err := e.Audit.Query(ctx, audit.Filter{})
+		return count(events, match), err
+	}, func() (int, error) { return e.Audit.Remove(ctx, match) })
+	report.AuditEntries = n
+	if err != nil || report.DryRun {
+		return err
+	}
+	return e.Audit.Record(ctx, audit.Event{
+		Kind:    audit.Erasure,
+		Action:  "erase",
+		Outcome: "ok",
+		Detail: fmt.Sprintf("%d sessions, %d facts, %d audit entries",
+			len(report.Sessions), report.Facts, report.AuditEntries),
+	})
+}
+
+// unlink undoes the /link links of the person. Those in the config are only
+// reported.
+func (e *Eraser) unlink(report *Report) error {
+	var errs []error
+	for _, acc := range report.Accounts {
+		switch {
+		case e.Links.InConfig(acc):
+			report.ConfigLinks = append(report.ConfigLinks, acc)
+		case acc == report.Person:
+			// Not a link: the person is named after this account.
+		case report.DryRun:
+			report.Unlinked = append(report.Unlinked, acc)
+		default:
+			if err := e.Links.Unlink(acc); err != nil {
+				errs = append(errs, fmt.Errorf("erasure: %w", err))
+				continue
+			}
+			report.Unlinked = append(report.Unlinked, acc)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isDirectWith reports whether key is the session of a direct chat with
+// person or one of accounts. The peer in the key is the platform ID, the
+// whole sender ID with the per-user policy, or the person when identity
+// links collapse the sessions.
+func isDirectWith(key, person string, accounts map[string]bool) bool {
+	parsed := routing.ParseAgentSessionKey(key)
+	if parsed == nil {
+		return false
+	}
+	rest := ":" + strings.ToLower(parsed.Rest)
+	i := strings.Index(rest, ":direct:")
+	if i < 0 {
+		return false
+	}
+	channel, _, _ := strings.Cut(rest[1:i], ":")
+	peer, _, _ := strings.Cut(rest[i+len(":direct:"):], ":thread:")
+	if peer == person || accounts[peer] {
+		return true
+	}
+	id, _, _ := strings.Cut(peer, "|")
+	for acc := range accounts {
+		platform, platformID, ok := identity.ParseCanonicalID(acc)
+		if ok && id == strings.ToLower(platformID) && (channel == "" || channel == platform) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMainKey(key string) bool {
+	parsed := routing.ParseAgentSessionKey(key)
+	return parsed != nil && parsed.Rest == routing.DefaultMainKey
+}
+
+// accountOf returns the account of a sender ID as logged, or "" for none.
+func accountOf(channel, senderID string) string {
+	if senderID == "" || !strings.Contains(senderID, ":") && channel == "" {
+		return ""
+	}
+	return strings.ToLower(identity.AccountID(channel, bus.SenderInfo{}, senderID))
+}
+
+// countOrErase runs count on a dry run and erase otherwise.
+func countOrErase(dryRun bool, count, erase func() (int, error)) (int, error) {
+	if dryRun {
+		return count()
+	}
+	return erase()
+}
+
+func count[T any](items []T, match func(T) bool) int {
+	n := 0
+	for _, item := range items {
+		if match(item) {
+			n++
+		}
+	}
+	return n
+}
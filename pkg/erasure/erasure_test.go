@@ -0,0 +1,193 @@
+package erasure
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/identity"
+	"github.com/sipeed/picoclaw/pkg/media"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+const (
+	annaTelegram = "agent:main:telegram:direct:1"
+	annaDiscord  = "agent:main:discord:direct:7"
+	group        = "agent:main:telegram:group:-100"
+	ben          = "agent:main:telegram:direct:2"
+	mainKey      = "agent:main:main"
+)
+
+// testEraser returns an eraser over one workspace where anna talked to the
+// agent on telegram:1, linked in the config, and on discord:7, linked with
+// /link, and ben on telegram:2. It also returns the file of the photo anna
+// sent.
+func testEraser(t *testing.T) (*Eraser, string) {
+	t.Helper()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	links := identity.NewLinks(map[string][]string{"anna": {"telegram:1"}}, dir)
+	_, _, err := links.CompleteLink("discord:7", links.StartLink("telegram:1"))
+	require.NoError(t, err)
+
+	photo := filepath.Join(dir, "photo.jpg")
+	require.NoError(t, os.WriteFile(photo, []byte("jpeg"), 0o600))
+	store := media.NewFileMediaStore()
+	ref, err := store.Store(photo, media.MediaMeta{Filename: "photo.jpg"}, "telegram:1:5")
+	require.NoError(t, err)
+
+	sessions := session.NewSessionManager(filepath.Join(dir, "sessions"))
+	sessions.AddFullMessage(annaTelegram, providers.Message{Role: "user", Content: "my photo", Media: []string{ref}})
+	sessions.AddMessage(annaDiscord, "user", "hi")
+	sessions.AddMessage(group, "user", "hello all")
+	sessions.AddMessage(ben, "user", "hello")
+	sessions.AddMessage(mainKey, "user", "from the main session")
+	sessions.SetPerson(mainKey, "anna")
+	for _, key := range []string{annaTelegram, annaDiscord, group, ben, mainKey} {
+		require.NoError(t, sessions.Save(key))
+	}
+
+	facts, err := memory.NewFactStore(filepath.Join(dir, "memory"))
+	require.NoError(t, err)
+	for _, f := range []memory.Fact{
+		{ID: "1", Content: "Anna loves tulips", Person: "anna"},
+		{ID: "2", Content: "Shared, saved in her chat", Source: annaDiscord},
+		{ID: "3", Content: "Ben keeps bees", Person: "telegram:2"},
+		{ID: "4", Content: "Shared"},
+	} {
+		require.NoError(t, facts.Put(ctx, f))
+	}
+
+	tasks, err := memory.NewTaskStore(filepath.Join(dir, "memory"))
+	require.NoError(t, err)
+	for _, task := range []struct {
+		ctx  context.Context
+		task memory.Task
+	}{
+		{memory.WithPerson(ctx, "anna"), memory.Task{Title: "Order tulips"}},
+		{ctx, memory.Task{Title: "Shared, added in her chat", Source: annaTelegram}},
+		{memory.WithPerson(ctx, "telegram:2"), memory.Task{Title: "Feed the bees"}},
+	} {
+		_, err := tasks.Add(task.ctx, task.task)
+		require.NoError(t, err)
+	}
+
+	usage, err := memory.NewUsageLedger(filepath.Join(dir, "usage"))
+	require.NoError(t, err)
+	calls, err := memory.NewToolCallLog(filepath.Join(dir, "usage"))
+	require.NoError(t, err)
+	traces, err := memory.NewTraceLog(filepath.Join(dir, "traces"), 10)
+	require.NoError(t, err)
+	for _, key := range []string{annaTelegram, group, ben} {
+		require.NoError(t, usage.Record(ctx, memory.UsageRecord{SessionKey: key, TotalTokens: 10}))
+		require.NoError(t, traces.Record(ctx, &memory.TurnTrace{SessionKey: key}))
+	}
+	// Anna's tool call in the group goes, ben's stays.
+	for _, sender := range []string{"telegram:1", "telegram:2"} {
+		require.NoError(t, calls.Record(ctx, memory.ToolCallRecord{
+			SessionKey: group, Channel: "telegram", SenderID: sender, Tool: "exec",
+		}))
+	}
+
+	log, err := audit.Open(filepath.Join(dir, "audit"))
+	require.NoError(t, err)
+	for _, e := range []audit.Event{
+		{Kind: audit.ShellExec, Actor: "telegram:1", SessionKey: group, Channel: "telegram", Action: "exec"},
+		{Kind: audit.FileWrite, Actor: "local", SessionKey: annaTelegram, Action: "write_file"},
+		{Kind: audit.ConfigChange, Actor: "local", Action: "save"},
+	} {
+		require.NoError(t, log.Record(ctx, e))
+	}
+
+	return &Eraser{
+		Workspaces: []Workspace{{
+			Sessions: sessions, Facts: facts, Tasks: tasks, Usage: usage, ToolCalls: calls, Traces: traces,
+		}},
+		Links: links,
+		Audit: log,
+		Media: store,
+	}, photo
+}
+
+func TestErase(t *testing.T) {
+	ctx := context.Background()
+	eraser, photo := testEraser(t)
+	ws := eraser.Workspaces[0]
+
+	dry, err := eraser.Erase(ctx, "Discord:7", true)
+	require.NoError(t, err)
+	want := &Report{
+		DryRun:       true,
+		Person:       "anna",
+		Accounts:     []string{"discord:7", "telegram:1"},
+		Sessions:     []string{annaDiscord, annaTelegram},
+		Shared:       []string{mainKey},
+		Facts:        2,
+		Tasks:        2,
+		Attachments:  1,
+		UsageRecords: 1,
+		ToolCalls:    1,
+		Traces:       1,
+		AuditEntries: 2,
+		Unlinked:     []string{"discord:7"},
+		ConfigLinks:  []string{"telegram:1"},
+	}
+	assert.Equal(t, want, dry)
+	assert.Len(t, ws.Sessions.Keys(), 5, "a dry run deletes nothing")
+	assert.FileExists(t, photo)
+
+	report, err := eraser.Erase(ctx, "discord:7", false)
+	require.NoError(t, err)
+	want.DryRun = false
+	assert.Equal(t, want, report)
+
+	assert.ElementsMatch(t, []string{group, ben, mainKey}, ws.Sessions.Keys())
+	reloaded := session.NewSessionManager(filepath.Join(filepath.Dir(photo), "sessions"))
+	assert.ElementsMatch(t, []string{group, ben, mainKey}, reloaded.Keys())
+	assert.NoFileExists(t, photo)
+
+	facts, err := ws.Facts.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, facts, 2)
+	assert.Equal(t, "3", facts[0].ID)
+	assert.Equal(t, "4", facts[1].ID)
+
+	tasks, err := ws.Tasks.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "Feed the bees", tasks[0].Title)
+
+	records, err := ws.Usage.Records(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+	calls, err := ws.ToolCalls.Records(ctx, memory.ToolCallFilter{})
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	assert.Equal(t, "telegram:2", calls[0].SenderID)
+	turns, err := ws.Traces.Turns(ctx, annaTelegram)
+	require.NoError(t, err)
+	assert.Empty(t, turns)
+
+	require.NoError(t, eraser.Audit.Verify(ctx))
+	events, err := eraser.Audit.Query(ctx, audit.Filter{})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, audit.ConfigChange, events[0].Kind)
+	assert.Equal(t, audit.Erasure, events[1].Kind)
+	assert.NotContains(t, events[1].Detail, "anna")
+
+	assert.Equal(t, "discord:7", eraser.Links.Person("discord:7"), "the /link link is undone")
+
+	again, err := eraser.Erase(ctx, "discord:7", true)
+	require.NoError(t, err)
+	assert.Empty(t, again.Sessions)
+	assert.Zero(t, again.Facts)
+}
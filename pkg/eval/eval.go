@@ -0,0 +1,415 @@
+// Package eval runs suites of scripted conversations against the agent and
+// checks the tools it calls and the answers it gives, so changes to the
+// prompts or the configuration can be regression-tested.
+//
+// A case runs in a workspace of its own, holding the configured workspace's
+// bootstrap files (AGENTS.md, SOUL.md, USER.md, IDENTITY.md) and the case's
+// files. Without a live model, each turn's script answers the agent's model
+// calls in order; the tools it calls really run, in that workspace.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// bootstrapFiles are copied from the configured workspace into each case's.
+var bootstrapFiles = []string{"AGENTS.md", "SOUL.md", "USER.md", "IDENTITY.md"}
+
+// Suite is a set of cases, read from a JSON file.
+type Suite struct {
+	Name  string `json:"name,omitempty"`
+	Cases []Case `json:"cases"`
+}
+
+// Case is one conversation.
+type Case struct {
+	Name string `json:"name"`
+	// Files are written into the workspace before the case runs, by path
+	// relative to it.
+	Files map[string]string `json:"files,omitempty"`
+	Turns []Turn            `json:"turns"`
+}
+
+// Turn is a message from the user and what should come of it.
+type Turn struct {
+	User string `json:"user"`
+	// Script is what the model answers during the turn, in order, when the
+	// suite runs without a live model.
+	Script []Reply `json:"script,omitempty"`
+	Expect Expect  `json:"expect"`
+}
+
+// Reply is a scripted answer of the model.
+type Reply struct {
+	Content   string `json:"content,omitempty"`
+	ToolCalls []Call `json:"tool_calls,omitempty"`
+}
+
+// Call is a tool call, scripted or expected.
+type Call struct {
+	Tool string         `json:"tool"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// Expect is what a turn is checked against. Text is compared without
+// regard to case.
+type Expect struct {
+	// Tools must be called during the turn in this order, with other calls
+	// allowed between them. A call matches when it has the given Args; it
+	// may have more.
+	Tools []Call `json:"tools,omitempty"`
+	// NoTools must not be called; "*" forbids every tool.
+	NoTools     []string `json:"no_tools,omitempty"`
+	Contains    []string `json:"contains,omitempty"`
+	NotContains []string `json:"not_contains,omitempty"`
+	// Matches is a regular expression the answer must match.
+	Matches string `json:"matches,omitempty"`
+}
+
+// LoadSuite reads a suite from path and checks it.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("eval: read suite: %w", err)
+	}
+	var s Suite
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("eval: %s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("eval: %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate checks that every case has a name and turns, and that the
+// regular expressions compile.
+func (s *Suite) Validate() error {
+	if len(s.Cases) == 0 {
+		return errors.New("no cases")
+	}
+	seen := make(map[string]bool)
+	for i, c := range s.Cases {
+		if c.Name == "" {
+			return fmt.Errorf("case %d has no name", i+1)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("two cases named %q", c.Name)
+		}
+		seen[c.Name] = true
+		if len(c.Turns) == 0 {
+			return fmt.Errorf("case %q has no turns", c.Name)
+		}
+		for j, t := range c.Turns {
+			if strings.TrimSpace(t.User) == "" {
+				return fmt.Errorf("case %q turn %d has no user message", c.Name, j+1)
+			}
+			if _, err := regexp.Compile("(?i)" + t.Expect.Matches); err != nil {
+				return fmt.Errorf("case %q turn %d: invalid matches: %w", c.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// scripted reports whether every turn of c has a script.
+func (c *Case) scripted() bool {
+	for _, t := range c.Turns {
+		if len(t.Script) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Options controls a run.
+type Options struct {
+	// Provider is the live model; nil runs the scripts.
+	Provider providers.LLMProvider
+	// Filter selects the cases to run by name; nil runs them all.
+	Filter *regexp.Regexp
+}
+
+// Result is the outcome of a case.
+type Result struct {
+	Suite    string        `json:"suite"`
+	Case     string        `json:"case"`
+	Skipped  string        `json:"skipped,omitempty"` // why the case did not run
+	Failures []string      `json:"failures,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Passed reports whether the case ran and met every expectation.
+func (r Result) Passed() bool {
+	return r.Skipped == "" && len(r.Failures) == 0
+}
+
+// Run runs the cases of s with the agent configured by cfg.
+func Run(ctx context.Context, cfg *config.Config, s *Suite, opts Options) []Result {
+	var results []Result
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if opts.Filter != nil && !opts.Filter.MatchString(c.Name) {
+			continue
+		}
+		res := Result{Suite: s.Name, Case: c.Name}
+		if opts.Provider == nil && !c.scripted() {
+			res.Skipped = "no script for every turn; run it live"
+			results = append(results, res)
+			continue
+		}
+		start := time.Now()
+		res.Failures = runCase(ctx, cfg, c, opts.Provider)
+		res.Duration = time.Since(start)
+		results = append(results, res)
+	}
+	return results
+}
+
+// runCase runs c and returns its failures.
+func runCase(ctx context.Context, cfg *config.Config, c *Case, live providers.LLMProvider) []string {
+	workspace, err := os.MkdirTemp("", "picoclaw-eval-")
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer os.RemoveAll(workspace)
+	if err := seedWorkspace(workspace, cfg.WorkspacePath(), c.Files); err != nil {
+		return []string{err.Error()}
+	}
+
+	provider := live
+	var script *providers.ReplayProvider
+	if live == nil {
+		script = providers.NewScriptedProvider(scriptResponses(c)...)
+		provider = script
+	}
+	rec := &callRecorder{}
+	loop := agent.NewAgentLoop(caseConfig(cfg, workspace, live == nil), bus.NewMessageBus(),
+		providers.WithMiddleware(provider, rec.middleware()))
+	defer loop.FlushJobs(ctx)
+
+	var failures []string
+	for i, turn := range c.Turns {
+		rec.reset()
+		answer, err := loop.ProcessDirect(ctx, turn.User, "eval:"+c.Name)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("turn %d: %v", i+1, err))
+			break
+		}
+		for _, f := range check(turn.Expect, answer, rec.calls()) {
+			failures = append(failures, fmt.Sprintf("turn %d: %s", i+1, f))
+		}
+	}
+	if script != nil && len(failures) == 0 && script.Remaining() > 0 {
+		failures = append(failures, fmt.Sprintf("the agent made %d fewer model calls than scripted", script.Remaining()))
+	}
+	return failures
+}
+
+// caseConfig returns a copy of cfg for a case run in workspace. Only the
+// default agent runs, and nothing leaves the run: no notifications, audit
+// entries or dataset records. Scripted runs also turn off the model calls
+// outside the turn (answer reviews, topics and background summaries), which
+// scripts do not answer.
+func caseConfig(cfg *config.Config, workspace string, scripted bool) *config.Config {
+	c := *cfg
+	c.Agents.Defaults.Workspace = workspace
+	c.Agents.List = nil
+	c.Bindings = nil
+	c.Notifications.Enabled = false
+	c.Audit.Enabled = false
+	c.Dataset.Enabled = false
+	c.Budgets.Enabled = false
+	if scripted {
+		c.Agents.Defaults.Reflection = nil
+		c.Agents.Defaults.Topics.Enabled = false
+		c.Agents.Defaults.SummarizeMessageThreshold = math.MaxInt
+	}
+	return &c
+}
+
+func seedWorkspace(workspace, from string, files map[string]string) error {
+	for _, name := range bootstrapFiles {
+		data, err := os.ReadFile(filepath.Join(from, name))
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(workspace, name), data, 0o644); err != nil {
+			return err
+		}
+	}
+	for name, content := range files {
+		path := filepath.Join(workspace, filepath.FromSlash(name))
+		if !strings.HasPrefix(path, workspace+string(filepath.Separator)) {
+			return fmt.Errorf("file %q is outside the workspace", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scriptResponses(c *Case) []*providers.LLMResponse {
+	var out []*providers.LLMResponse
+	n := 0
+	for _, t := range c.Turns {
+		for _, r := range t.Script {
+			resp := &providers.LLMResponse{Content: r.Content}
+			for _, call := range r.ToolCalls {
+				n++
+				resp.ToolCalls = append(resp.ToolCalls, providers.ToolCall{
+					ID:        fmt.Sprintf("call_%d", n),
+					Name:      call.Tool,
+					Arguments: call.Args,
+				})
+			}
+			out = append(out, resp)
+		}
+	}
+	return out
+}
+
+// callRecorder keeps the tool calls the model asks for during a turn.
+type callRecorder struct {
+	mu  sync.Mutex
+	got []Call
+}
+
+func (r *callRecorder) middleware() providers.Middleware {
+	return func(next providers.ChatHandler) providers.ChatHandler {
+		return func(ctx context.Context, req *providers.ChatRequest) (*providers.LLMResponse, error) {
+			resp, err := next(ctx, req)
+			if err == nil && resp != nil {
+				r.mu.Lock()
+				for _, tc := range resp.ToolCalls {
+					tc = providers.NormalizeToolCall(tc)
+					r.got = append(r.got, Call{Tool: tc.Name, Args: tc.Arguments})
+				}
+				r.mu.Unlock()
+			}
+			return resp, err
+		}
+	}
+}
+
+func (r *callRecorder) reset() {
+	r.mu.Lock()
+	r.got = nil
+	r.mu.Unlock()
+}
+
+func (r *callRecorder) calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.got...)
+}
+
+// check returns how answer and calls fall short of e.
+func check(e Expect, answer string, calls []Call) []string {
+	var failures []string
+	next := 0
+	for _, want := range e.Tools {
+		found := false
+		for ; next < len(calls); next++ {
+			if calls[next].matches(want) {
+				found, next = true, next+1
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("expected a call to %s, got %s", want, callList(calls)))
+			break
+		}
+	}
+	for _, name := range e.NoTools {
+		for _, c := range calls {
+			if name == "*" || c.Tool == name {
+				failures = append(failures, fmt.Sprintf("unexpected call to %s", c))
+				break
+			}
+		}
+	}
+
+	lower := strings.ToLower(answer)
+	for _, s := range e.Contains {
+		if !strings.Contains(lower, strings.ToLower(s)) {
+			failures = append(failures, fmt.Sprintf("answer does not contain %q: %s", s, quote(answer)))
+		}
+	}
+	for _, s := range e.NotContains {
+		if strings.Contains(lower, strings.ToLower(s)) {
+			failures = append(failures, fmt.Sprintf("answer contains %q: %s", s, quote(answer)))
+		}
+	}
+	if e.Matches != "" && !regexp.MustCompile("(?i)"+e.Matches).MatchString(answer) {
+		failures = append(failures, fmt.Sprintf("answer does not match %s: %s", e.Matches, quote(answer)))
+	}
+	return failures
+}
+
+// matches reports whether c is a call to want's tool with want's
+// arguments.
+func (c Call) matches(want Call) bool {
+	if c.Tool != want.Tool {
+		return false
+	}
+	for k, v := range want.Args {
+		got, ok := c.Args[k]
+		if !ok || !sameJSON(got, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Call) String() string {
+	if len(c.Args) == 0 {
+		return c.Tool
+	}
+	args, _ := json.Marshal(c.Args)
+	return c.Tool + " " + string(args)
+}
+
+func sameJSON(a, b any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
+
+func callList(calls []Call) string {
+	if len(calls) == 0 {
+		return "no tool calls"
+	}
+	names := make([]string, len(calls))
+	for i, c := range calls {
+		names[i] = c.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+func quote(answer string) string {
+	return fmt.Sprintf("%q", utils.Truncate(answer, 200))
+}
@@ -0,0 +1,161 @@
+package eval
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "AGENTS.md"), []byte("Answer in French."), 0o644))
+	return &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:           workspace,
+				RestrictToWorkspace: true,
+				Model:               "test-model",
+				MaxTokens:           4096,
+				MaxToolIterations:   10,
+			},
+		},
+		Tools: config.ToolsConfig{ReadFile: config.ToolConfig{Enabled: true}},
+	}
+}
+
+const suiteJSON = `{
+  "cases": [
+    {
+      "name": "reads notes",
+      "files": {"notes/todo.md": "buy milk"},
+      "turns": [{
+        "user": "what is in my notes?",
+        "script": [
+          {"tool_calls": [{"tool": "read_file", "args": {"path": "notes/todo.md"}}]},
+          {"content": "Your note says: buy milk"}
+        ],
+        "expect": {
+          "tools": [{"tool": "read_file", "args": {"path": "notes/todo.md"}}],
+          "contains": ["BUY MILK"],
+          "matches": "^your note"
+        }
+      }]
+    },
+    {
+      "name": "no tools for small talk",
+      "turns": [{
+        "user": "hi",
+        "script": [{"tool_calls": [{"tool": "read_file", "args": {"path": "x"}}]}, {"content": "Hello!"}],
+        "expect": {"no_tools": ["*"], "not_contains": ["hello"]}
+      }]
+    },
+    {
+      "name": "live only",
+      "turns": [{"user": "hi", "expect": {"contains": ["bonjour"]}}]
+    }
+  ]
+}`
+
+func loadTestSuite(t *testing.T) *Suite {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "basics.json")
+	require.NoError(t, os.WriteFile(path, []byte(suiteJSON), 0o644))
+	s, err := LoadSuite(path)
+	require.NoError(t, err)
+	return s
+}
+
+func TestRun_Scripted(t *testing.T) {
+	cfg := testConfig(t)
+	results := Run(context.Background(), cfg, loadTestSuite(t), Options{})
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "basics", results[0].Suite)
+	assert.True(t, results[0].Passed(), "%v", results[0].Failures)
+
+	assert.False(t, results[1].Passed())
+	assert.Equal(t, []string{
+		`turn 1: unexpected call to read_file {"path":"x"}`,
+		`turn 1: answer contains "hello": "Hello!"`,
+	}, results[1].Failures)
+
+	assert.NotEmpty(t, results[2].Skipped)
+	assert.False(t, results[2].Passed())
+
+	_, err := os.Stat(filepath.Join(cfg.WorkspacePath(), "notes"))
+	assert.True(t, os.IsNotExist(err), "cases do not touch the configured workspace")
+}
+
+func TestRun_Live(t *testing.T) {
+	var system string
+	provider := providers.WithMiddleware(
+		providers.NewScriptedProvider(&providers.LLMResponse{Content: "Bonjour !"}),
+		func(next providers.ChatHandler) providers.ChatHandler {
+			return func(ctx context.Context, req *providers.ChatRequest) (*providers.LLMResponse, error) {
+				system = req.Messages[0].Content
+				return next(ctx, req)
+			}
+		},
+	)
+	results := Run(context.Background(), testConfig(t), loadTestSuite(t), Options{
+		Provider: provider,
+		Filter:   regexp.MustCompile("^live"),
+	})
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Passed(), "%v", results[0].Failures)
+	assert.Contains(t, system, "Answer in French.", "the workspace's bootstrap files are part of the prompt")
+}
+
+func TestRun_ScriptMismatch(t *testing.T) {
+	s := &Suite{Name: "s", Cases: []Case{{
+		Name: "too many replies",
+		Turns: []Turn{{
+			User:   "hi",
+			Script: []Reply{{Content: "Hello"}, {Content: "never asked for"}},
+		}},
+	}}}
+	results := Run(context.Background(), testConfig(t), s, Options{})
+	assert.Equal(t, []string{"the agent made 1 fewer model calls than scripted"}, results[0].Failures)
+
+	s.Cases[0].Turns = append(s.Cases[0].Turns, Turn{User: "again", Script: []Reply{{
+		ToolCalls: []Call{{Tool: "read_file", Args: map[string]any{"path": "x"}}},
+	}}})
+	s.Cases[0].Turns[0].Script = s.Cases[0].Turns[0].Script[:1]
+	results = Run(context.Background(), testConfig(t), s, Options{})
+	require.Len(t, results[0].Failures, 1)
+	assert.Contains(t, results[0].Failures[0], "turn 2: ", "the script ran out")
+}
+
+func TestSuite_Validate(t *testing.T) {
+	for _, bad := range []Suite{
+		{},
+		{Cases: []Case{{Turns: []Turn{{User: "hi"}}}}},
+		{Cases: []Case{{Name: "a", Turns: []Turn{{User: "hi"}}}, {Name: "a", Turns: []Turn{{User: "hi"}}}}},
+		{Cases: []Case{{Name: "a"}}},
+		{Cases: []Case{{Name: "a", Turns: []Turn{{User: " "}}}}},
+		{Cases: []Case{{Name: "a", Turns: []Turn{{User: "hi", Expect: Expect{Matches: "("}}}}}},
+	} {
+		assert.Error(t, bad.Validate(), "%+v", bad)
+	}
+}
+
+func TestCheck_ToolOrder(t *testing.T) {
+	calls := []Call{
+		{Tool: "list_dir", Args: map[string]any{"path": "."}},
+		{Tool: "read_file", Args: map[string]any{"path": "a.md", "offset": float64(0)}},
+	}
+	assert.Empty(t, check(Expect{Tools: []Call{{Tool: "list_dir"}, {Tool: "read_file", Args: map[string]any{
+		"path": "a.md",
+	}}}}, "", calls))
+	assert.Equal(t, []string{`expected a call to list_dir, got list_dir {"path":"."}, read_file {"offset":0,"path":"a.md"}`},
+		check(Expect{Tools: []Call{{Tool: "read_file"}, {Tool: "list_dir"}}}, "", calls))
+}
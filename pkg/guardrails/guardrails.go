@@ -0,0 +1,219 @@
+// Package guardrails filters the text going into and out of the agent: the
+// messages it receives, the replies it sends and the results of its tools.
+// A Pipeline runs its rules in order. A rule may rewrite the text, as the
+// secret redaction does, or block it altogether.
+package guardrails
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Stage is where in the agent text is filtered.
+type Stage string
+
+const (
+	Input      Stage = "input"  // messages to the agent
+	Output     Stage = "output" // replies and messages from the agent
+	ToolResult Stage = "tool"   // results of tool calls, before the model sees them
+)
+
+// DefaultBlockedMessage replaces blocked text when its rule has no message.
+const DefaultBlockedMessage = "This content was blocked by a content filter."
+
+// Violation is a rule matching text.
+type Violation struct {
+	Rule     string
+	Category string
+	Blocked  bool   // the whole text is withheld; otherwise matches were redacted
+	Matches  int    // number of matches found
+	Message  string // shown instead of blocked text
+}
+
+// Rule checks text at a stage. It returns the text, rewritten if the rule
+// redacts part of it, and the violation it found, or nil.
+type Rule interface {
+	Name() string
+	Check(stage Stage, text string) (string, *Violation)
+}
+
+// Result is text after filtering.
+type Result struct {
+	Text       string // the filtered text, or the blocked message
+	Blocked    bool
+	Violations []Violation
+}
+
+// Pipeline runs text through its rules. It is safe for concurrent use.
+type Pipeline struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+// New builds the pipeline configured by cfg: the secret redaction first,
+// then the blocked categories in name order. It is empty when guardrails are
+// disabled; rules can still be added with Add.
+func New(cfg config.GuardrailsConfig) (*Pipeline, error) {
+	p := &Pipeline{}
+	if !cfg.Enabled {
+		return p, nil
+	}
+	if cfg.RedactSecrets || len(cfg.Redact) > 0 {
+		rule, err := NewRedactRule(cfg.RedactSecrets, cfg.Redact)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(rule)
+	}
+	names := make([]string, 0, len(cfg.Categories))
+	for name := range cfg.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rule, err := NewCategoryRule(name, cfg.Categories[name])
+		if err != nil {
+			return nil, err
+		}
+		p.Add(rule)
+	}
+	return p, nil
+}
+
+// Add appends rule to the pipeline.
+func (p *Pipeline) Add(rule Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, rule)
+}
+
+// Filter runs text through the rules for stage. It stops at the first rule
+// that blocks the text.
+func (p *Pipeline) Filter(stage Stage, text string) Result {
+	res := Result{Text: text}
+	if p == nil || text == "" {
+		return res
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, rule := range p.rules {
+		out, v := rule.Check(stage, res.Text)
+		if v == nil {
+			continue
+		}
+		if v.Rule == "" {
+			v.Rule = rule.Name()
+		}
+		res.Violations = append(res.Violations, *v)
+		if v.Blocked {
+			res.Blocked = true
+			res.Text = v.Message
+			if res.Text == "" {
+				res.Text = DefaultBlockedMessage
+			}
+			return res
+		}
+		res.Text = out
+	}
+	return res
+}
+
+// redactedPlaceholder replaces every redacted match.
+const redactedPlaceholder = "[REDACTED]"
+
+// secretPatterns match common credentials: provider API keys, cloud and chat
+// tokens, and PEM private keys.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`),              // Anthropic, OpenAI and compatible
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),                       // AWS access key IDs
+	regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_\w{22,})`), // GitHub
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),                     // Slack
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`),                             // Google
+	regexp.MustCompile(`\b\d{8,10}:AA[A-Za-z0-9_-]{33}\b`),                    // Telegram bot tokens
+	regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`),
+}
+
+// RedactRule replaces secrets and other configured patterns with
+// "[REDACTED]" at every stage.
+type RedactRule struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRedactRule returns a rule redacting the built-in secret patterns, when
+// secrets is set, and the regular expressions in extra.
+func NewRedactRule(secrets bool, extra []string) (*RedactRule, error) {
+	r := &RedactRule{}
+	if secrets {
+		r.patterns = append(r.patterns, secretPatterns...)
+	}
+	for _, p := range extra {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *RedactRule) Name() string { return "redact" }
+
+func (r *RedactRule) Check(_ Stage, text string) (string, *Violation) {
+	matches := 0
+	for _, re := range r.patterns {
+		text = re.ReplaceAllStringFunc(text, func(string) string {
+			matches++
+			return redactedPlaceholder
+		})
+	}
+	if matches == 0 {
+		return text, nil
+	}
+	return text, &Violation{Category: "secret", Matches: matches}
+}
+
+// CategoryRule blocks text matching any of the patterns of a category.
+type CategoryRule struct {
+	category string
+	patterns []*regexp.Regexp
+	stages   []Stage // nil applies to every stage
+	message  string
+}
+
+// NewCategoryRule returns the rule for the configured category name.
+func NewCategoryRule(name string, cfg config.GuardrailCategory) (*CategoryRule, error) {
+	r := &CategoryRule{category: name, message: cfg.Message}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("category %s: invalid pattern %q: %w", name, p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	for _, s := range cfg.Stages {
+		r.stages = append(r.stages, Stage(s))
+	}
+	return r, nil
+}
+
+func (r *CategoryRule) Name() string { return "category:" + r.category }
+
+func (r *CategoryRule) Check(stage Stage, text string) (string, *Violation) {
+	if r.stages != nil && !slices.Contains(r.stages, stage) {
+		return text, nil
+	}
+	matches := 0
+	for _, re := range r.patterns {
+		matches += len(re.FindAllStringIndex(text, -1))
+	}
+	if matches == 0 {
+		return text, nil
+	}
+	return text, &Violation{Category: r.category, Blocked: true, Matches: matches, Message: r.message}
+}
@@ -0,0 +1,88 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+var publishOnce sync.Once
+
+// DebugServer serves pprof profiles under /debug/pprof/ and expvar variables
+// under /debug/vars, to profile memory and CPU use in the field. It has no
+// authentication, so it should only listen on localhost.
+type DebugServer struct {
+	server *http.Server
+	ln     net.Listener
+}
+
+func NewDebugServer(host string, port int) *DebugServer {
+	started := time.Now()
+	publishOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+		expvar.Publish("uptime_seconds", expvar.Func(func() any { return int64(time.Since(started).Seconds()) }))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	return &DebugServer{server: &http.Server{
+		Addr:    net.JoinHostPort(host, fmt.Sprint(port)),
+		Handler: mux,
+		// No WriteTimeout: CPU profiles and traces stream for as long as
+		// the client asks (?seconds=30).
+		ReadHeaderTimeout: 5 * time.Second,
+	}}
+}
+
+// IsLoopback reports whether host only accepts connections from this
+// machine.
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Start listens and serves in the background. It fails if the address
+// cannot be bound.
+func (s *DebugServer) Start() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("debug server: %w", err)
+	}
+	s.ln = ln
+	go func() {
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.ErrorCF("debug", "Debug server stopped", map[string]any{"error": err.Error()})
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on once started.
+func (s *DebugServer) Addr() string {
+	if s.ln != nil {
+		return s.ln.Addr().String()
+	}
+	return s.server.Addr
+}
+
+func (s *DebugServer) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
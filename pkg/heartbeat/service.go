@@ -33,35 +33,68 @@ const (
 // channel and chatID are derived from the last active user channel.
 type HeartbeatHandler func(prompt, channel, chatID string) *tools.ToolResult
 
+// ContextProvider returns extra prompt content for a heartbeat at now, such
+// as upcoming calendar events, or "" when it has nothing to add.
+type ContextProvider func(now time.Time) string
+
 // HeartbeatService manages periodic heartbeat checks
 type HeartbeatService struct {
 	workspace string
 	bus       *bus.MessageBus
 	state     *state.Manager
 	handler   HeartbeatHandler
+	context   []ContextProvider
 	interval  time.Duration
 	enabled   bool
+	started   bool // Start was called; the loop only runs while enabled
 	mu        sync.RWMutex
 	stopChan  chan struct{}
+	busySince time.Time // when the running heartbeat started; zero when idle
 }
 
 // NewHeartbeatService creates a new heartbeat service
 func NewHeartbeatService(workspace string, intervalMinutes int, enabled bool) *HeartbeatService {
-	// Apply minimum interval
-	if intervalMinutes < minIntervalMinutes && intervalMinutes != 0 {
-		intervalMinutes = minIntervalMinutes
+	return &HeartbeatService{
+		workspace: workspace,
+		interval:  intervalDuration(intervalMinutes),
+		enabled:   enabled,
+		state:     state.NewManager(workspace),
 	}
+}
 
+// intervalDuration applies the default and the minimum to intervalMinutes.
+func intervalDuration(intervalMinutes int) time.Duration {
 	if intervalMinutes == 0 {
 		intervalMinutes = defaultIntervalMinutes
 	}
+	return time.Duration(max(intervalMinutes, minIntervalMinutes)) * time.Minute
+}
 
-	return &HeartbeatService{
-		workspace: workspace,
-		interval:  time.Duration(intervalMinutes) * time.Minute,
-		enabled:   enabled,
-		state:     state.NewManager(workspace),
+// Reconfigure changes the interval and whether heartbeats run, while the
+// service may be running. A new interval counts from now; it does not run a
+// heartbeat right away.
+func (hs *HeartbeatService) Reconfigure(intervalMinutes int, enabled bool) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	interval := intervalDuration(intervalMinutes)
+	if interval == hs.interval && enabled == hs.enabled {
+		return
+	}
+	wasRunning := hs.stopChan != nil
+	hs.interval, hs.enabled = interval, enabled
+	if wasRunning {
+		close(hs.stopChan)
+		hs.stopChan = nil
 	}
+	if hs.started && enabled {
+		hs.stopChan = make(chan struct{})
+		go hs.runLoop(hs.stopChan, interval, !wasRunning)
+	}
+	logger.InfoCF("heartbeat", "Heartbeat settings changed", map[string]any{
+		"enabled":          enabled,
+		"interval_minutes": interval.Minutes(),
+	})
 }
 
 // SetBus sets the message bus for delivering heartbeat results.
@@ -78,6 +111,21 @@ func (hs *HeartbeatService) SetHandler(handler HeartbeatHandler) {
 	hs.handler = handler
 }
 
+// AddContextProvider adds a source of extra content for heartbeat prompts.
+// Providers are asked in the order they were added.
+func (hs *HeartbeatService) AddContextProvider(provider ContextProvider) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.context = append(hs.context, provider)
+}
+
+// Interval returns the time between heartbeats.
+func (hs *HeartbeatService) Interval() time.Duration {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	return hs.interval
+}
+
 // Start begins the heartbeat service
 func (hs *HeartbeatService) Start() error {
 	hs.mu.Lock()
@@ -88,13 +136,14 @@ func (hs *HeartbeatService) Start() error {
 		return nil
 	}
 
+	hs.started = true
 	if !hs.enabled {
 		logger.InfoC("heartbeat", "Heartbeat service disabled")
 		return nil
 	}
 
 	hs.stopChan = make(chan struct{})
-	go hs.runLoop(hs.stopChan)
+	go hs.runLoop(hs.stopChan, hs.interval, true)
 
 	logger.InfoCF("heartbeat", "Heartbeat service started", map[string]any{
 		"interval_minutes": hs.interval.Minutes(),
@@ -108,6 +157,7 @@ func (hs *HeartbeatService) Stop() {
 	hs.mu.Lock()
 	defer hs.mu.Unlock()
 
+	hs.started = false
 	if hs.stopChan == nil {
 		return
 	}
@@ -124,15 +174,39 @@ func (hs *HeartbeatService) IsRunning() bool {
 	return hs.stopChan != nil
 }
 
-// runLoop runs the heartbeat ticker
-func (hs *HeartbeatService) runLoop(stopChan chan struct{}) {
-	ticker := time.NewTicker(hs.interval)
+// Busy returns how long the heartbeat that is running has been running, or
+// 0 when none is. A heartbeat that runs for much longer than a turn may
+// take is stuck.
+func (hs *HeartbeatService) Busy() time.Duration {
+	hs.mu.RLock()
+	defer hs.mu.RUnlock()
+	if hs.busySince.IsZero() {
+		return 0
+	}
+	return time.Since(hs.busySince)
+}
+
+// Trigger runs a heartbeat now, in the background, without waiting for the
+// next tick. It reports false if the service is not running.
+func (hs *HeartbeatService) Trigger() bool {
+	if !hs.IsRunning() {
+		return false
+	}
+	go hs.executeHeartbeat()
+	return true
+}
+
+// runLoop runs a heartbeat every interval until stopChan is closed, and
+// with runNow one more shortly after starting.
+func (hs *HeartbeatService) runLoop(stopChan chan struct{}, interval time.Duration, runNow bool) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	// Run first heartbeat after initial delay
-	time.AfterFunc(time.Second, func() {
-		hs.executeHeartbeat()
-	})
+	if runNow {
+		time.AfterFunc(time.Second, func() {
+			hs.executeHeartbeat()
+		})
+	}
 
 	for {
 		select {
@@ -179,7 +253,13 @@ func (hs *HeartbeatService) executeHeartbeat() {
 	// Debug log for channel resolution
 	hs.logInfof("Resolved channel: %s, chatID: %s (from lastChannel: %s)", channel, chatID, lastChannel)
 
+	hs.mu.Lock()
+	hs.busySince = time.Now()
+	hs.mu.Unlock()
 	result := handler(prompt, channel, chatID)
+	hs.mu.Lock()
+	hs.busySince = time.Time{}
+	hs.mu.Unlock()
 
 	if result == nil {
 		hs.logInfof("Heartbeat handler returned nil result")
@@ -232,11 +312,20 @@ func (hs *HeartbeatService) buildPrompt() string {
 	}
 
 	content := string(data)
+
+	hs.mu.RLock()
+	providers := hs.context
+	hs.mu.RUnlock()
+	now := time.Now()
+	for _, provider := range providers {
+		if extra := provider(now); extra != "" {
+			content = strings.TrimRight(content, "\n") + "\n\n" + extra + "\n"
+		}
+	}
 	if len(content) == 0 {
 		return ""
 	}
 
-	now := time.Now().Format("2006-01-02 15:04:05")
 	return fmt.Sprintf(`# Heartbeat Check
 
 Current time: %s
@@ -246,7 +335,7 @@ Review the following tasks and execute any necessary actions using available ski
 If there is nothing that requires attention, respond ONLY with: HEARTBEAT_OK
 
 %s
-`, now, content)
+`, now.Format("2006-01-02 15:04:05"), content)
 }
 
 // createDefaultHeartbeatTemplate creates the default HEARTBEAT.md file
@@ -345,25 +434,12 @@ func (hs *HeartbeatService) parseLastChannel(lastChannel string) (platform, user
 	return platform, userID
 }
 
-// logInfof logs an informational message to the heartbeat log
+// logInfof logs an informational message under the heartbeat component
 func (hs *HeartbeatService) logInfof(format string, args ...any) {
-	hs.logf("INFO", format, args...)
+	logger.InfoC("heartbeat", fmt.Sprintf(format, args...))
 }
 
-// logErrorf logs an error message to the heartbeat log
+// logErrorf logs an error message under the heartbeat component
 func (hs *HeartbeatService) logErrorf(format string, args ...any) {
-	hs.logf("ERROR", format, args...)
-}
-
-// logf writes a message to the heartbeat log file
-func (hs *HeartbeatService) logf(level, format string, args ...any) {
-	logFile := filepath.Join(hs.workspace, "heartbeat.log")
-	f, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Fprintf(f, "[%s] [%s] %s\n", timestamp, level, fmt.Sprintf(format, args...))
+	logger.ErrorC("heartbeat", fmt.Sprintf(format, args...))
 }
@@ -3,9 +3,11 @@ package heartbeat
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
@@ -79,6 +81,7 @@ func TestExecuteHeartbeat_ResultLogging(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			logDir := logToDir(t)
 			tmpDir, err := os.MkdirTemp("", "heartbeat-test-*")
 			if err != nil {
 				t.Fatalf("Failed to create temp dir: %v", err)
@@ -95,12 +98,11 @@ func TestExecuteHeartbeat_ResultLogging(t *testing.T) {
 			os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Test task"), 0o644)
 			hs.executeHeartbeat()
 
-			logFile := filepath.Join(tmpDir, "heartbeat.log")
-			data, err := os.ReadFile(logFile)
+			data, err := os.ReadFile(filepath.Join(logDir, "picoclaw.log"))
 			if err != nil {
 				t.Fatalf("Failed to read log file: %v", err)
 			}
-			if string(data) == "" {
+			if !strings.Contains(string(data), `"component":"heartbeat"`) {
 				t.Errorf("Expected log file to contain %s", tt.wantLog)
 			}
 		})
@@ -164,24 +166,58 @@ func TestExecuteHeartbeat_NilResult(t *testing.T) {
 	hs.executeHeartbeat()
 }
 
-// TestLogPath verifies heartbeat log is written to workspace directory
-func TestLogPath(t *testing.T) {
-	tmpDir, err := os.MkdirTemp("", "heartbeat-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+func TestHeartbeatService_Busy(t *testing.T) {
+	tmpDir := t.TempDir()
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.stopChan = make(chan struct{}) // Enable for testing
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Test task"), 0o644)
+
+	var during time.Duration
+	hs.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
+		time.Sleep(10 * time.Millisecond)
+		during = hs.Busy()
+		return tools.SilentResult("ok")
+	})
+
+	if d := hs.Busy(); d != 0 {
+		t.Fatalf("Busy before a heartbeat = %v, want 0", d)
 	}
-	defer os.RemoveAll(tmpDir)
+	hs.executeHeartbeat()
+	if during < 10*time.Millisecond {
+		t.Errorf("Busy during the heartbeat = %v, want at least 10ms", during)
+	}
+	if d := hs.Busy(); d != 0 {
+		t.Errorf("Busy after the heartbeat = %v, want 0", d)
+	}
+}
+
+// TestLogPath verifies heartbeat entries go to the shared log, not a
+// heartbeat.log of their own in the workspace
+func TestLogPath(t *testing.T) {
+	logDir := logToDir(t)
+	tmpDir := t.TempDir()
 
 	hs := NewHeartbeatService(tmpDir, 30, true)
+	hs.logInfof("Test log entry")
 
-	// Write a log entry
-	hs.logf("INFO", "Test log entry")
+	data, err := os.ReadFile(filepath.Join(logDir, "picoclaw.log"))
+	if err != nil || !strings.Contains(string(data), "Test log entry") {
+		t.Errorf("log file = %q, %v; want the entry", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "heartbeat.log")); !os.IsNotExist(err) {
+		t.Errorf("heartbeat.log was written to the workspace")
+	}
+}
 
-	// Verify log file exists at workspace root
-	expectedLogPath := filepath.Join(tmpDir, "heartbeat.log")
-	if _, err := os.Stat(expectedLogPath); os.IsNotExist(err) {
-		t.Errorf("Expected log file at %s, but it doesn't exist", expectedLogPath)
+// logToDir points the logger at a temporary directory for the test.
+func logToDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := logger.Configure(logger.Options{Level: logger.INFO, Dir: dir}); err != nil {
+		t.Fatal(err)
 	}
+	t.Cleanup(func() { logger.Configure(logger.Options{Level: logger.INFO}) })
+	return dir
 }
 
 // TestHeartbeatFilePath verifies HEARTBEAT.md is at workspace root
@@ -203,3 +239,78 @@ func TestHeartbeatFilePath(t *testing.T) {
 		t.Errorf("Expected HEARTBEAT.md at %s, but it doesn't exist", expectedPath)
 	}
 }
+
+// TestBuildPrompt_ContextProvider verifies provider content is appended to
+// the HEARTBEAT.md tasks.
+func TestBuildPrompt_ContextProvider(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Check the weather\n"), 0o644)
+
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	if prompt := hs.buildPrompt(); strings.Contains(prompt, "Upcoming") {
+		t.Errorf("prompt without providers = %q", prompt)
+	}
+
+	hs.AddContextProvider(func(time.Time) string { return "## Upcoming calendar events\n\n- Standup" })
+	hs.AddContextProvider(func(time.Time) string { return "" })
+	hs.AddContextProvider(func(time.Time) string { return "## Device health\n\n- Disk / is 95% full" })
+
+	prompt := hs.buildPrompt()
+	want := "Check the weather\n\n## Upcoming calendar events\n\n- Standup\n\n## Device health\n\n- Disk / is 95% full\n"
+	if !strings.Contains(prompt, want) {
+		t.Errorf("prompt = %q", prompt)
+	}
+}
+
+func TestHeartbeatService_Trigger(t *testing.T) {
+	tmpDir := t.TempDir()
+	hs := NewHeartbeatService(tmpDir, 30, true)
+	os.WriteFile(filepath.Join(tmpDir, "HEARTBEAT.md"), []byte("Test task"), 0o644)
+
+	called := make(chan string, 1)
+	hs.SetHandler(func(prompt, channel, chatID string) *tools.ToolResult {
+		called <- prompt
+		return tools.SilentResult("ok")
+	})
+
+	if hs.Trigger() {
+		t.Error("Trigger() = true before Start")
+	}
+
+	hs.stopChan = make(chan struct{}) // Enable for testing
+	if !hs.Trigger() {
+		t.Fatal("Trigger() = false on a running service")
+	}
+	select {
+	case prompt := <-called:
+		if !strings.Contains(prompt, "Test task") {
+			t.Errorf("prompt = %q, want HEARTBEAT.md contents", prompt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not called")
+	}
+}
+
+func TestHeartbeatService_Reconfigure(t *testing.T) {
+	hs := NewHeartbeatService(t.TempDir(), 30, false)
+	if err := hs.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer hs.Stop()
+	if hs.IsRunning() {
+		t.Fatal("disabled service is running")
+	}
+
+	hs.Reconfigure(10, true)
+	if !hs.IsRunning() || hs.Interval() != 10*time.Minute {
+		t.Errorf("after enabling: running %v, interval %v", hs.IsRunning(), hs.Interval())
+	}
+	hs.Reconfigure(1, true)
+	if hs.Interval() != minIntervalMinutes*time.Minute {
+		t.Errorf("interval = %v, want the minimum", hs.Interval())
+	}
+	hs.Reconfigure(1, false)
+	if hs.IsRunning() {
+		t.Error("service still running after disabling")
+	}
+}
@@ -0,0 +1,239 @@
+package identity
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// LocalAccount is the account of whoever uses the CLI on this machine.
+const LocalAccount = "cli:local"
+
+const (
+	// linkCodeTTL is how long a /link code can be redeemed.
+	linkCodeTTL = 10 * time.Minute
+	// linkCodeAlphabet leaves out characters that are easy to misread.
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	linkCodeLength   = 6
+)
+
+// ErrInvalidLinkCode is returned for unknown or expired link codes.
+var ErrInvalidLinkCode = errors.New("invalid or expired link code")
+
+// AccountID returns the account a message came from: the canonical sender
+// ID, or one built from the channel and legacy sender ID. Messages from the
+// CLI belong to LocalAccount.
+func AccountID(channel string, sender bus.SenderInfo, senderID string) string {
+	if channel == "cli" {
+		return LocalAccount
+	}
+	if sender.CanonicalID != "" {
+		return sender.CanonicalID
+	}
+	if sender.PlatformID != "" {
+		platform := sender.Platform
+		if platform == "" {
+			platform = channel
+		}
+		return BuildCanonicalID(platform, sender.PlatformID)
+	}
+	id, _, _ := strings.Cut(senderID, "|")
+	if strings.Contains(id, ":") {
+		return id
+	}
+	return BuildCanonicalID(channel, id)
+}
+
+// Links resolves the person behind an account, so that one human using
+// several channels shares memory while each channel keeps its own session.
+// Links come from session.identity_links in the config and from the /link
+// command; the latter are kept in the workspace state directory.
+type Links struct {
+	static map[string][]string // person → accounts, from the config
+	path   string
+
+	mu      sync.Mutex
+	linked  map[string]string // account → person
+	pending map[string]pendingLink
+}
+
+type pendingLink struct {
+	person  string
+	expires time.Time
+}
+
+type storedLinks struct {
+	Accounts map[string]string `json:"accounts"`
+}
+
+// NewLinks creates the links of a workspace. static maps person names to
+// their accounts, as session.identity_links does.
+func NewLinks(static map[string][]string, workspace string) *Links {
+	l := &Links{
+		static:  static,
+		path:    filepath.Join(workspace, "state", "identities.json"),
+		linked:  make(map[string]string),
+		pending: make(map[string]pendingLink),
+	}
+	if data, err := os.ReadFile(l.path); err == nil {
+		var stored storedLinks
+		if json.Unmarshal(data, &stored) == nil && stored.Accounts != nil {
+			l.linked = stored.Accounts
+		}
+	}
+	return l
+}
+
+// Person returns the person an account belongs to. An account that is not
+// linked is a person of its own.
+func (l *Links) Person(account string) string {
+	if account == "" {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.personLocked(account)
+}
+
+func (l *Links) personLocked(account string) string {
+	if person := l.staticPerson(account); person != "" {
+		return person
+	}
+	if person, ok := l.linked[strings.ToLower(account)]; ok {
+		return person
+	}
+	return strings.ToLower(account)
+}
+
+// staticPerson matches account against identity_links, whose entries may
+// also be bare platform IDs.
+func (l *Links) staticPerson(account string) string {
+	account = strings.ToLower(account)
+	_, bare, _ := ParseCanonicalID(account)
+	for person, ids := range l.static {
+		for _, id := range ids {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if id != "" && (id == account || id == bare) {
+				return strings.TrimSpace(person)
+			}
+		}
+	}
+	return ""
+}
+
+// InConfig reports whether account is linked in identity_links, which
+// Unlink cannot undo.
+func (l *Links) InConfig(account string) bool {
+	return l.staticPerson(account) != ""
+}
+
+// StartLink returns a one-time code that links another account to the
+// person of account when redeemed with CompleteLink.
+func (l *Links) StartLink(account string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for code, p := range l.pending {
+		if now.After(p.expires) {
+			delete(l.pending, code)
+		}
+	}
+	code := newLinkCode()
+	l.pending[code] = pendingLink{person: l.personLocked(account), expires: now.Add(linkCodeTTL)}
+	return code
+}
+
+func newLinkCode() string {
+	b := make([]byte, linkCodeLength)
+	_, _ = rand.Read(b) // never fails on supported platforms
+	for i := range b {
+		b[i] = linkCodeAlphabet[int(b[i])%len(linkCodeAlphabet)]
+	}
+	return string(b)
+}
+
+// CompleteLink links account to the person who created code. It returns the
+// person the account belonged to before, and the one it belongs to now.
+func (l *Links) CompleteLink(account, code string) (from, to string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	code = strings.ToUpper(strings.TrimSpace(code))
+	p, ok := l.pending[code]
+	if !ok || time.Now().After(p.expires) {
+		return "", "", ErrInvalidLinkCode
+	}
+	from = l.personLocked(account)
+	if l.staticPerson(account) != "" {
+		return "", "", fmt.Errorf("%s is linked in the config file", account)
+	}
+	delete(l.pending, code)
+	if from == p.person {
+		return from, from, nil
+	}
+	// Accounts already linked to this one follow it.
+	for acc, person := range l.linked {
+		if person == from {
+			l.linked[acc] = p.person
+		}
+	}
+	l.linked[strings.ToLower(account)] = p.person
+	return from, p.person, l.saveLocked()
+}
+
+// Unlink makes account a person of its own again.
+func (l *Links) Unlink(account string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.staticPerson(account) != "" {
+		return fmt.Errorf("%s is linked in the config file", account)
+	}
+	key := strings.ToLower(account)
+	if _, ok := l.linked[key]; !ok {
+		return fmt.Errorf("%s is not linked to another account", account)
+	}
+	delete(l.linked, key)
+	return l.saveLocked()
+}
+
+// Accounts lists the accounts of a person, including one named like the
+// person itself.
+func (l *Links) Accounts(person string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := map[string]bool{}
+	if _, _, ok := ParseCanonicalID(person); ok {
+		seen[person] = true
+	}
+	for _, id := range l.static[person] {
+		seen[strings.ToLower(strings.TrimSpace(id))] = true
+	}
+	for acc, p := range l.linked {
+		if p == person {
+			seen[acc] = true
+		}
+	}
+	accounts := make([]string, 0, len(seen))
+	for acc := range seen {
+		accounts = append(accounts, acc)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+func (l *Links) saveLocked() error {
+	data, err := json.MarshalIndent(storedLinks{Accounts: l.linked}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("identity: marshal links: %w", err)
+	}
+	return fileutil.WriteFileAtomic(l.path, data, 0o600)
+}
@@ -0,0 +1,70 @@
+package identity
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestAccountID(t *testing.T) {
+	tests := []struct {
+		channel  string
+		sender   bus.SenderInfo
+		senderID string
+		want     string
+	}{
+		{"telegram", bus.SenderInfo{CanonicalID: "telegram:1"}, "1", "telegram:1"},
+		{"discord", bus.SenderInfo{PlatformID: "2"}, "2", "discord:2"},
+		{"slack", bus.SenderInfo{}, "U3|carol", "slack:U3"},
+		{"cli", bus.SenderInfo{}, "cron", LocalAccount},
+	}
+	for _, tt := range tests {
+		if got := AccountID(tt.channel, tt.sender, tt.senderID); got != tt.want {
+			t.Errorf("AccountID(%q, %+v, %q) = %q, want %q", tt.channel, tt.sender, tt.senderID, got, tt.want)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	workspace := t.TempDir()
+	l := NewLinks(map[string][]string{"alice": {"telegram:1", "98765"}}, workspace)
+
+	if got := l.Person("telegram:1"); got != "alice" {
+		t.Errorf("Person(telegram:1) = %q, want alice", got)
+	}
+	if got := l.Person("discord:98765"); got != "alice" {
+		t.Errorf("bare ID link: Person = %q, want alice", got)
+	}
+	if got := l.Person("discord:2"); got != "discord:2" {
+		t.Errorf("unlinked: Person = %q", got)
+	}
+
+	code := l.StartLink("telegram:1")
+	if _, _, err := l.CompleteLink("discord:2", "NOPE"); !errors.Is(err, ErrInvalidLinkCode) {
+		t.Errorf("wrong code: err = %v", err)
+	}
+	from, to, err := l.CompleteLink(LocalAccount, code)
+	if err != nil || from != LocalAccount || to != "alice" {
+		t.Fatalf("CompleteLink = %q, %q, %v", from, to, err)
+	}
+	if _, _, err := l.CompleteLink("discord:2", code); !errors.Is(err, ErrInvalidLinkCode) {
+		t.Errorf("reused code: err = %v", err)
+	}
+
+	// Links made at runtime survive a restart.
+	l = NewLinks(map[string][]string{"alice": {"telegram:1", "98765"}}, workspace)
+	if got := l.Person(LocalAccount); got != "alice" {
+		t.Errorf("after restart: Person = %q, want alice", got)
+	}
+	if got := l.Accounts("alice"); !slices.Equal(got, []string{"98765", LocalAccount, "telegram:1"}) {
+		t.Errorf("Accounts = %q", got)
+	}
+	if err := l.Unlink("telegram:1"); err == nil {
+		t.Error("unlinked an account linked in the config")
+	}
+	if err := l.Unlink(LocalAccount); err != nil || l.Person(LocalAccount) != LocalAccount {
+		t.Errorf("Unlink = %v, Person = %q", err, l.Person(LocalAccount))
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -30,14 +32,22 @@ var (
 		FATAL: "FATAL",
 	}
 
-	currentLevel = INFO
-	logger       *Logger
-	once         sync.Once
-	mu           sync.RWMutex
+	currentLevel    = INFO
+	componentLevels map[string]LogLevel
+	consoleJSON     bool
+	logger          *Logger
+	once            sync.Once
+	mu              sync.RWMutex
+)
+
+// Console formats.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
 )
 
 type Logger struct {
-	file *os.File
+	file *rotatingFile
 }
 
 type LogEntry struct {
@@ -49,12 +59,97 @@ type LogEntry struct {
 	Caller    string         `json:"caller,omitempty"`
 }
 
+// Options configures the logger as a whole; see Configure.
+type Options struct {
+	Level LogLevel
+	// Components overrides Level for single components, such as "heartbeat".
+	Components map[string]LogLevel
+	// Format is how entries are written to the console: FormatConsole (the
+	// default) or FormatJSON. Log files always hold JSON.
+	Format string
+	// Dir, when set, is where the log files go: picoclaw.log, and once it
+	// grows past MaxSizeMB, picoclaw.log.1 to picoclaw.log.<MaxFiles> for the
+	// older entries.
+	Dir       string
+	MaxSizeMB int
+	MaxFiles  int
+}
+
+const (
+	logFileName      = "picoclaw.log"
+	defaultMaxSizeMB = 10
+	defaultMaxFiles  = 3
+)
+
 func init() {
 	once.Do(func() {
 		logger = &Logger{}
 	})
 }
 
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLevel parses a level name such as "debug" or "WARN".
+func ParseLevel(name string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "DEBUG":
+		return DEBUG, nil
+	case "INFO", "":
+		return INFO, nil
+	case "WARN", "WARNING":
+		return WARN, nil
+	case "ERROR":
+		return ERROR, nil
+	case "FATAL":
+		return FATAL, nil
+	}
+	return INFO, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", name)
+}
+
+// Configure replaces the level, component levels, console format and log
+// files. Without Dir, entries are only written to the console.
+func Configure(opts Options) error {
+	switch opts.Format {
+	case "", FormatConsole, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q (want %s or %s)", opts.Format, FormatConsole, FormatJSON)
+	}
+	var file *rotatingFile
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+		maxSize, maxFiles := opts.MaxSizeMB, opts.MaxFiles
+		if maxSize <= 0 {
+			maxSize = defaultMaxSizeMB
+		}
+		if maxFiles <= 0 {
+			maxFiles = defaultMaxFiles
+		}
+		var err error
+		file, err = openRotatingFile(filepath.Join(opts.Dir, logFileName), int64(maxSize)<<20, maxFiles)
+		if err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	currentLevel = opts.Level
+	componentLevels = maps.Clone(opts.Components)
+	consoleJSON = opts.Format == FormatJSON
+	if logger.file != nil {
+		logger.file.Close()
+	}
+	logger.file = file
+	return nil
+}
+
 func SetLevel(level LogLevel) {
 	mu.Lock()
 	defer mu.Unlock()
@@ -67,15 +162,26 @@ func GetLevel() LogLevel {
 	return currentLevel
 }
 
-func EnableFileLogging(filePath string) error {
+// SetComponentLevel sets the level of one component, overriding the global
+// level for its entries.
+func SetComponentLevel(component string, level LogLevel) {
 	mu.Lock()
 	defer mu.Unlock()
+	if componentLevels == nil {
+		componentLevels = make(map[string]LogLevel)
+	}
+	componentLevels[component] = level
+}
 
-	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+func EnableFileLogging(filePath string) error {
+	file, err := openRotatingFile(filePath, 0, 0)
 	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+		return err
 	}
 
+	mu.Lock()
+	defer mu.Unlock()
+
 	if logger.file != nil {
 		logger.file.Close()
 	}
@@ -96,8 +202,21 @@ func DisableFileLogging() {
 	}
 }
 
+// enabled reports whether entries of component at level are logged, and
+// returns where to write them.
+func enabled(level LogLevel, component string) (bool, *rotatingFile, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	threshold := currentLevel
+	if l, ok := componentLevels[component]; ok {
+		threshold = l
+	}
+	return level >= threshold, logger.file, consoleJSON
+}
+
 func logMessage(level LogLevel, component string, message string, fields map[string]any) {
-	if level < currentLevel {
+	ok, out, asJSON := enabled(level, component)
+	if !ok {
 		return
 	}
 
@@ -116,29 +235,38 @@ func logMessage(level LogLevel, component string, message string, fields map[str
 		}
 	}
 
-	if logger.file != nil {
-		jsonData, err := json.Marshal(entry)
-		if err == nil {
-			logger.file.Write(append(jsonData, '\n'))
+	var line []byte
+	if out != nil || asJSON {
+		if data, err := json.Marshal(entry); err == nil {
+			line = append(data, '\n')
 		}
 	}
+	if out != nil && line != nil {
+		out.Write(line)
+	}
 
-	var fieldStr string
-	if len(fields) > 0 {
-		fieldStr = " " + formatFields(fields)
+	if asJSON {
+		if line != nil {
+			log.Writer().Write(line)
+		}
 	} else {
-		fieldStr = ""
-	}
+		var fieldStr string
+		if len(fields) > 0 {
+			fieldStr = " " + formatFields(fields)
+		} else {
+			fieldStr = ""
+		}
 
-	logLine := fmt.Sprintf("[%s] [%s]%s %s%s",
-		entry.Timestamp,
-		logLevelNames[level],
-		formatComponent(component),
-		message,
-		fieldStr,
-	)
+		logLine := fmt.Sprintf("[%s] [%s]%s %s%s",
+			entry.Timestamp,
+			logLevelNames[level],
+			formatComponent(component),
+			message,
+			fieldStr,
+		)
 
-	log.Println(logLine)
+		log.Println(logLine)
+	}
 
 	if level == FATAL {
 		os.Exit(1)
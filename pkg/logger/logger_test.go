@@ -1,6 +1,10 @@
 package logger
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -137,3 +141,74 @@ func TestLoggerHelperFunctions(t *testing.T) {
 	DebugC("test", "Debug with component")
 	WarnF("Warning with fields", map[string]any{"key": "value"})
 }
+
+func TestConfigure_ComponentLevelsAndFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := Configure(Options{
+		Level:      WARN,
+		Components: map[string]LogLevel{"heartbeat": DEBUG},
+		Dir:        dir,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer Configure(Options{Level: INFO})
+
+	InfoC("agent", "hidden by the global level")
+	DebugCF("heartbeat", "shown by the component level", map[string]any{"n": 1})
+	WarnC("agent", "shown")
+
+	data, err := os.ReadFile(filepath.Join(dir, "picoclaw.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log file = %q, want 2 entries", data)
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != "DEBUG" || entry.Component != "heartbeat" || entry.Fields["n"] != float64(1) {
+		t.Errorf("entry = %+v", entry)
+	}
+
+	if err := Configure(Options{Format: "xml"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]LogLevel{"debug": DEBUG, "INFO": INFO, "warning": WARN, " error ": ERROR} {
+		if got, err := ParseLevel(name); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) succeeded")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "picoclaw.log")
+	r, err := openRotatingFile(path, 100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	line := []byte(strings.Repeat("x", 59) + "\n")
+	for range 5 {
+		if _, err := r.Write(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if info, err := os.Stat(name); err != nil || info.Size() != 60 {
+			t.Errorf("%s: %v, %v; want one 60-byte entry", filepath.Base(name), info, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than 2 old files kept: %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+const (
+	defaultMemorySearchLimit   = 20
+	defaultSessionHistoryLimit = 20
+)
+
+// ServerOptions configures the MCP server that exports a picoclaw workspace.
+type ServerOptions struct {
+	Workspace  string
+	Version    string
+	AllowWrite bool   // also export write_file and edit_file
+	SessionKey string // the key the sessions are encrypted with, if they are
+}
+
+// NewServer returns an MCP server exporting the workspace's memory (MEMORY.md
+// and daily notes), session history and files, so MCP clients can use a
+// picoclaw device as a memory backend. File access is confined to the
+// workspace. Run it with mcp.StdioTransport or serve it over HTTP with
+// mcp.NewStreamableHTTPHandler.
+func NewServer(opts ServerOptions) *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "picoclaw", Version: opts.Version}, &mcp.ServerOptions{
+		Instructions: "Long-term memory, daily notes, conversation history and workspace files of a picoclaw agent.",
+	})
+	for _, tool := range ServerTools(opts) {
+		addServerTool(server, tool)
+	}
+	return server
+}
+
+// ServerTools returns the tools NewServer exports.
+func ServerTools(opts ServerOptions) []tools.Tool {
+	ws := opts.Workspace
+	exported := []tools.Tool{
+		memorySearchTool(ws),
+		memoryReadTool(ws),
+		sessionListTool(ws, opts.SessionKey),
+		sessionHistoryTool(ws, opts.SessionKey),
+		tools.NewReadFileTool(ws, true),
+		tools.NewListDirTool(ws, true),
+	}
+	if opts.AllowWrite {
+		exported = append(exported, tools.NewWriteFileTool(ws, true), tools.NewEditFileTool(ws, true))
+	}
+	return exported
+}
+
+// addServerTool exports a picoclaw tool as an MCP tool.
+func addServerTool(server *mcp.Server, tool tools.Tool) {
+	server.AddTool(&mcp.Tool{
+		Name:        tool.Name(),
+		Description: tool.Description(),
+		InputSchema: tool.Parameters(),
+	}, func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := map[string]any{}
+		if len(req.Params.Arguments) > 0 {
+			if err := json.Unmarshal(req.Params.Arguments, &args); err != nil {
+				return errorResult(fmt.Sprintf("invalid arguments: %v", err)), nil
+			}
+		}
+		result := tool.Execute(ctx, args)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: result.ForLLM}},
+			IsError: result.IsError,
+		}, nil
+	})
+}
+
+func errorResult(msg string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: msg}}, IsError: true}
+}
+
+func memorySearchTool(workspace string) tools.Tool {
+	return tools.NewFuncTool(
+		"memory_search",
+		"Search long-term memory (MEMORY.md) and daily notes for lines containing all query words "+
+			"(case-insensitive). Returns matching lines as file:line: text.",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "Words to search for",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of matches (default 20)",
+				},
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]any) *tools.ToolResult {
+			query, _ := args["query"].(string)
+			terms := strings.Fields(strings.ToLower(query))
+			if len(terms) == 0 {
+				return tools.ErrorResult("query is required")
+			}
+			limit := intArg(args, "limit", defaultMemorySearchLimit)
+
+			matches, err := searchMemory(workspace, terms, limit)
+			if err != nil {
+				return tools.ErrorResult(fmt.Sprintf("searching memory: %v", err))
+			}
+			if len(matches) == 0 {
+				return tools.NewToolResult("No matches.")
+			}
+			return tools.NewToolResult(strings.Join(matches, "\n"))
+		},
+	)
+}
+
+// searchMemory scans the markdown files under workspace/memory for lines
+// containing every term, returning up to limit matches in path order.
+func searchMemory(workspace string, terms []string, limit int) ([]string, error) {
+	root := filepath.Join(workspace, "memory")
+	var matches []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".md" {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		rel, _ := filepath.Rel(workspace, path)
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			line := scanner.Text()
+			if !containsAll(strings.ToLower(line), terms) {
+				continue
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", filepath.ToSlash(rel), lineNum, strings.TrimSpace(line)))
+			if len(matches) >= limit {
+				return fs.SkipAll
+			}
+		}
+		return scanner.Err()
+	})
+	return matches, err
+}
+
+func containsAll(s string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(s, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func memoryReadTool(workspace string) tools.Tool {
+	return tools.NewFuncTool(
+		"memory_read",
+		"Read the agent's long-term memory (MEMORY.md).",
+		nil,
+		func(ctx context.Context, args map[string]any) *tools.ToolResult {
+			data, err := os.ReadFile(filepath.Join(workspace, "memory", "MEMORY.md"))
+			if os.IsNotExist(err) || (err == nil && len(data) == 0) {
+				return tools.NewToolResult("Long-term memory is empty.")
+			}
+			if err != nil {
+				return tools.ErrorResult(fmt.Sprintf("reading memory: %v", err))
+			}
+			return tools.NewToolResult(string(data))
+		},
+	)
+}
+
+func sessionListTool(workspace, sessionKey string) tools.Tool {
+	return tools.NewFuncTool(
+		"session_list",
+		"List stored conversation sessions with their message counts.",
+		nil,
+		func(ctx context.Context, args map[string]any) *tools.ToolResult {
+			sm, err := loadSessions(workspace, sessionKey)
+			if err != nil {
+				return tools.ErrorResult(err.Error())
+			}
+			keys := sm.Keys()
+			if len(keys) == 0 {
+				return tools.NewToolResult("No sessions.")
+			}
+			lines := make([]string, 0, len(keys))
+			for _, key := range keys {
+				lines = append(lines, fmt.Sprintf("%s (%d messages)", key, len(sm.GetHistory(key))))
+			}
+			return tools.NewToolResult(strings.Join(lines, "\n"))
+		},
+	)
+}
+
+func sessionHistoryTool(workspace, sessionKey string) tools.Tool {
+	return tools.NewFuncTool(
+		"session_history",
+		"Read the summary and most recent messages of a conversation session (see session_list).",
+		map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"session_key": map[string]any{
+					"type":        "string",
+					"description": "Session key as returned by session_list",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Number of most recent messages to return (default 20)",
+				},
+			},
+			"required": []string{"session_key"},
+		},
+		func(ctx context.Context, args map[string]any) *tools.ToolResult {
+			key, _ := args["session_key"].(string)
+			if key == "" {
+				return tools.ErrorResult("session_key is required")
+			}
+			limit := intArg(args, "limit", defaultSessionHistoryLimit)
+
+			sm, err := loadSessions(workspace, sessionKey)
+			if err != nil {
+				return tools.ErrorResult(err.Error())
+			}
+			history := sm.GetHistory(key)
+			summary := sm.GetSummary(key)
+			if len(history) == 0 && summary == "" {
+				return tools.ErrorResult(fmt.Sprintf("session %q not found", key))
+			}
+
+			var sb strings.Builder
+			if summary != "" {
+				fmt.Fprintf(&sb, "Summary: %s\n\n", summary)
+			}
+			if len(history) > limit {
+				fmt.Fprintf(&sb, "(%d earlier messages omitted)\n", len(history)-limit)
+				history = history[len(history)-limit:]
+			}
+			for _, m := range history {
+				if m.Content == "" {
+					continue
+				}
+				fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
+			}
+			return tools.NewToolResult(strings.TrimRight(sb.String(), "\n"))
+		},
+	)
+}
+
+// loadSessions reads the session store from disk. It is loaded per call so
+// results reflect what a concurrently running gateway has saved since.
+func loadSessions(workspace, key string) (*session.SessionManager, error) {
+	return session.OpenManager(filepath.Join(workspace, "sessions"), key)
+}
+
+// intArg reads a positive integer argument, which JSON decodes as float64.
+func intArg(args map[string]any, key string, def int) int {
+	if v, ok := args[key].(float64); ok && v >= 1 {
+		return int(v)
+	}
+	return def
+}
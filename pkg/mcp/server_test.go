@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func connectServer(t *testing.T, opts ServerOptions) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+	ss, err := NewServer(opts).Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server Connect() error = %v", err)
+	}
+	t.Cleanup(func() { ss.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "1.0"}, nil)
+	cs, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client Connect() error = %v", err)
+	}
+	t.Cleanup(func() { cs.Close() })
+	return cs
+}
+
+func callText(t *testing.T, cs *mcp.ClientSession, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	res, err := cs.CallTool(context.Background(), &mcp.CallToolParams{Name: name, Arguments: args})
+	if err != nil {
+		t.Fatalf("CallTool(%s) error = %v", name, err)
+	}
+	var sb strings.Builder
+	for _, c := range res.Content {
+		if text, ok := c.(*mcp.TextContent); ok {
+			sb.WriteString(text.Text)
+		}
+	}
+	return sb.String(), res.IsError
+}
+
+func newServerWorkspace(t *testing.T) string {
+	t.Helper()
+	ws := t.TempDir()
+	notesDir := filepath.Join(ws, "memory", "202610")
+	if err := os.MkdirAll(notesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ws, "memory", "MEMORY.md"),
+		[]byte("# Memory\nUser prefers green tea.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(notesDir, "20261014.md"),
+		[]byte("Bought Green TEA leaves\nWatered plants\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sm := session.NewSessionManager(filepath.Join(ws, "sessions"))
+	sm.AddMessage("telegram:42", "user", "what tea do I like?")
+	sm.AddMessage("telegram:42", "assistant", "green tea")
+	sm.SetSummary("telegram:42", "talked about tea")
+	if err := sm.Save("telegram:42"); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestServer_ListsReadOnlyToolsByDefault(t *testing.T) {
+	cs := connectServer(t, ServerOptions{Workspace: t.TempDir()})
+
+	res, err := cs.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+	var names []string
+	for _, tool := range res.Tools {
+		names = append(names, tool.Name)
+	}
+	wantTools := []string{"memory_search", "memory_read", "session_list", "session_history", "read_file", "list_dir"}
+	for _, want := range wantTools {
+		if !slices.Contains(names, want) {
+			t.Errorf("tools = %v, missing %s", names, want)
+		}
+	}
+	if slices.Contains(names, "write_file") {
+		t.Error("write_file exported without AllowWrite")
+	}
+}
+
+func TestServer_MemorySearch(t *testing.T) {
+	cs := connectServer(t, ServerOptions{Workspace: newServerWorkspace(t)})
+
+	out, isErr := callText(t, cs, "memory_search", map[string]any{"query": "green tea"})
+	if isErr {
+		t.Fatalf("memory_search error: %s", out)
+	}
+	if !strings.Contains(out, "memory/MEMORY.md:2: User prefers green tea.") {
+		t.Errorf("output missing MEMORY.md match:\n%s", out)
+	}
+	if !strings.Contains(out, "memory/202610/20261014.md:1: Bought Green TEA leaves") {
+		t.Errorf("output missing daily note match:\n%s", out)
+	}
+	if strings.Contains(out, "Watered") {
+		t.Errorf("output contains non-matching line:\n%s", out)
+	}
+
+	out, _ = callText(t, cs, "memory_search", map[string]any{"query": "tea", "limit": 1})
+	if n := strings.Count(out, "\n") + 1; n != 1 {
+		t.Errorf("limit 1 returned %d lines:\n%s", n, out)
+	}
+}
+
+func TestServer_SessionHistory(t *testing.T) {
+	cs := connectServer(t, ServerOptions{Workspace: newServerWorkspace(t)})
+
+	out, _ := callText(t, cs, "session_list", nil)
+	if out != "telegram:42 (2 messages)" {
+		t.Errorf("session_list = %q", out)
+	}
+
+	out, isErr := callText(t, cs, "session_history", map[string]any{"session_key": "telegram:42", "limit": 1})
+	if isErr {
+		t.Fatalf("session_history error: %s", out)
+	}
+	want := "Summary: talked about tea\n\n(1 earlier messages omitted)\nassistant: green tea"
+	if out != want {
+		t.Errorf("session_history = %q, want %q", out, want)
+	}
+
+	if _, isErr := callText(t, cs, "session_history", map[string]any{"session_key": "nope"}); !isErr {
+		t.Error("unknown session should be an error")
+	}
+}
+
+func TestServer_FileToolsStayInWorkspace(t *testing.T) {
+	ws := newServerWorkspace(t)
+	cs := connectServer(t, ServerOptions{Workspace: ws, AllowWrite: true})
+
+	if out, isErr := callText(t, cs, "write_file", map[string]any{"path": "notes.txt", "content": "hi"}); isErr {
+		t.Fatalf("write_file error: %s", out)
+	}
+	if out, _ := callText(t, cs, "read_file", map[string]any{"path": "notes.txt"}); !strings.Contains(out, "hi") {
+		t.Errorf("read_file = %q", out)
+	}
+	if _, isErr := callText(t, cs, "read_file", map[string]any{"path": "/etc/passwd"}); !isErr {
+		t.Error("reading outside the workspace should fail")
+	}
+}
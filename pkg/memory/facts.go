@@ -0,0 +1,475 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+const (
+	// factsFile is the name of the fact store inside its directory.
+	factsFile = "facts.jsonl"
+
+	// minSemanticScore is the cosine similarity a fact needs to match a query
+	// it shares no words with.
+	minSemanticScore = 0.35
+)
+
+// Fact is one piece of long-term knowledge the agent chose to remember.
+type Fact struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags,omitempty"`
+	Source    string    `json:"source,omitempty"` // session key the fact was saved from
+	Person    string    `json:"person,omitempty"` // who the fact was learned from; empty facts are shared
+	CreatedAt time.Time `json:"created_at"`
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+type personCtxKey struct{}
+
+// WithPerson scopes the facts seen through ctx to person: saves are
+// recorded for them, and searches see their facts and the shared ones.
+// Without a person every fact is visible.
+func WithPerson(ctx context.Context, person string) context.Context {
+	return context.WithValue(ctx, personCtxKey{}, person)
+}
+
+// PersonFrom returns the person ctx is scoped to, or "".
+func PersonFrom(ctx context.Context) string {
+	person, _ := ctx.Value(personCtxKey{}).(string)
+	return person
+}
+
+// visible reports whether f can be seen by person.
+func (f Fact) visible(person string) bool {
+	return person == "" || f.Person == "" || f.Person == person
+}
+
+// FactMatch is a search hit with its relevance score (higher is better).
+type FactMatch struct {
+	Fact
+	Score float64
+}
+
+// FactStore keeps facts in a JSONL file. Saves are appends; forgetting
+// rewrites the file. Searches scan every fact, which stays cheap for the few
+// thousand facts a personal agent accumulates.
+//
+// Search ranks facts by keyword overlap with the query. With an embedder set,
+// facts are embedded on save and ranked by semantic similarity as well, so
+// "what does my wife like" can find "Anna loves tulips".
+type FactStore struct {
+	path     string
+	embedder providers.EmbeddingProvider
+	mu       sync.Mutex
+}
+
+// NewFactStore creates a fact store stored as facts.jsonl inside dir.
+func NewFactStore(dir string) (*FactStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("memory: create directory: %w", err)
+	}
+	return &FactStore{path: filepath.Join(dir, factsFile)}, nil
+}
+
+// SetEmbedder enables semantic search. Facts saved before an embedder was
+// set are matched by keywords only.
+func (s *FactStore) SetEmbedder(embedder providers.EmbeddingProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.embedder = embedder
+}
+
+// Save stores a fact and returns it. Saving content that is already stored
+// (ignoring case and spacing) returns the existing fact instead of a duplicate.
+func (s *FactStore) Save(ctx context.Context, content string, tags []string, source string) (_ Fact, err error) {
+	ctx, span := telemetry.Start(ctx, "memory.facts.save")
+	defer func() { telemetry.End(span, err) }()
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return Fact{}, fmt.Errorf("memory: fact content is empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	person := PersonFrom(ctx)
+	facts, err := s.loadLocked()
+	if err != nil {
+		return Fact{}, err
+	}
+	for _, f := range facts {
+		if f.visible(person) && normalizeFact(f.Content) == normalizeFact(content) {
+			return f, nil
+		}
+	}
+
+	fact := Fact{
+		ID:        newFactID(),
+		Content:   content,
+		Tags:      tags,
+		Source:    source,
+		Person:    person,
+		CreatedAt: time.Now(),
+	}
+	if s.embedder != nil {
+		vectors, err := s.embedder.Embed(ctx, []string{content})
+		if err != nil || len(vectors) != 1 {
+			log.Printf("memory: saving fact without embedding: %v", err)
+		} else {
+			fact.Embedding = vectors[0]
+		}
+	}
+
+	line, err := json.Marshal(fact)
+	if err != nil {
+		return Fact{}, fmt.Errorf("memory: marshal fact: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return Fact{}, fmt.Errorf("memory: open fact store: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return Fact{}, fmt.Errorf("memory: append fact: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return Fact{}, fmt.Errorf("memory: close fact store: %w", err)
+	}
+	return fact, nil
+}
+
+// Search returns up to limit facts relevant to query, best first.
+func (s *FactStore) Search(ctx context.Context, query string, limit int) (_ []FactMatch, err error) {
+	ctx, span := telemetry.Start(ctx, "memory.facts.search")
+	defer func() { telemetry.End(span, err) }()
+
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	facts, err := s.visibleLocked(ctx)
+	embedder := s.embedder
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var queryVec []float32
+	if embedder != nil && len(facts) > 0 {
+		vectors, err := embedder.Embed(ctx, []string{query})
+		if err != nil || len(vectors) != 1 {
+			log.Printf("memory: falling back to keyword fact search: %v", err)
+		} else {
+			queryVec = vectors[0]
+		}
+	}
+
+	var matches []FactMatch
+	for _, f := range facts {
+		score := keywordScore(terms, f)
+		if queryVec != nil && len(f.Embedding) == len(queryVec) {
+			semantic := cosine(queryVec, f.Embedding)
+			if score == 0 && semantic < minSemanticScore {
+				continue
+			}
+			score = 0.6*semantic + 0.4*score
+		}
+		if score > 0 {
+			matches = append(matches, FactMatch{Fact: f, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// List returns the facts visible through ctx in the order they were saved.
+func (s *FactStore) List(ctx context.Context) ([]Fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.visibleLocked(ctx)
+}
+
+// Forget removes the fact with the given ID and reports whether it existed.
+// Facts of other people cannot be forgotten.
+func (s *FactStore) Forget(ctx context.Context, id string) (_ bool, err error) {
+	_, span := telemetry.Start(ctx, "memory.facts.forget")
+	defer func() { telemetry.End(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	facts, err := s.loadLocked()
+	if err != nil {
+		return false, err
+	}
+
+	person := PersonFrom(ctx)
+	kept := facts[:0]
+	found := false
+	for _, f := range facts {
+		if f.ID == id && f.visible(person) {
+			found = true
+			continue
+		}
+		kept = append(kept, f)
+	}
+	if !found {
+		return false, nil
+	}
+	return true, s.rewriteLocked(kept)
+}
+
+// Put stores f as it is, replacing the fact with the same ID, for copying
+// facts between stores. Unlike Save it neither merges duplicates nor embeds.
+func (s *FactStore) Put(_ context.Context, f Fact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	facts, err := s.loadLocked()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range facts {
+		if facts[i].ID == f.ID {
+			facts[i] = f
+			replaced = true
+		}
+	}
+	if !replaced {
+		facts = append(facts, f)
+	}
+	return s.rewriteLocked(facts)
+}
+
+// MovePerson hands the facts of one person to another, for when two
+// accounts turn out to be the same human. It returns how many moved.
+func (s *FactStore) MovePerson(_ context.Context, from, to string) (int, error) {
+	if from == "" || from == to {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	facts, err := s.loadLocked()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for i := range facts {
+		if facts[i].Person == from {
+			facts[i].Person = to
+			moved++
+		}
+	}
+	if moved == 0 {
+		return 0, nil
+	}
+	return moved, s.rewriteLocked(facts)
+}
+
+// Remove deletes the facts match selects, whoever they belong to, and
+// returns how many it deleted.
+func (s *FactStore) Remove(_ context.Context, match func(Fact) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	facts, err := s.loadLocked()
+	if err != nil {
+		return 0, err
+	}
+	kept := facts[:0]
+	for _, f := range facts {
+		if !match(f) {
+			kept = append(kept, f)
+		}
+	}
+	removed := len(facts) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.rewriteLocked(kept)
+}
+
+func (s *FactStore) rewriteLocked(facts []Fact) error {
+	var buf []byte
+	for _, f := range facts {
+		line, err := json.Marshal(f)
+		if err != nil {
+			return fmt.Errorf("memory: marshal fact: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if err := fileutil.WriteFileAtomic(s.path, buf, 0o600); err != nil {
+		return fmt.Errorf("memory: rewrite fact store: %w", err)
+	}
+	return nil
+}
+
+// visibleLocked returns the facts visible through ctx. Callers must hold
+// s.mu.
+func (s *FactStore) visibleLocked(ctx context.Context) ([]Fact, error) {
+	facts, err := s.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	person := PersonFrom(ctx)
+	if person == "" {
+		return facts, nil
+	}
+	visible := facts[:0]
+	for _, f := range facts {
+		if f.visible(person) {
+			visible = append(visible, f)
+		}
+	}
+	return visible, nil
+}
+
+// loadLocked reads every decodable fact. Corrupt lines are logged and
+// skipped, as in JSONLStore. Callers must hold s.mu.
+func (s *FactStore) loadLocked() ([]Fact, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory: open fact store: %w", err)
+	}
+	defer f.Close()
+
+	var facts []Fact
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineNum++
+		if len(line) == 0 {
+			continue
+		}
+		var fact Fact
+		if err := json.Unmarshal(line, &fact); err != nil {
+			log.Printf("memory: skipping corrupt fact line %d: %v", lineNum, err)
+			continue
+		}
+		facts = append(facts, fact)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("memory: scan fact store: %w", err)
+	}
+	return facts, nil
+}
+
+// SearchTerms lowercases text and splits it into words. Han, Hiragana,
+// Katakana and Hangul runes are separate terms, since those scripts do not
+// separate words with spaces.
+func SearchTerms(text string) []string {
+	var terms []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			terms = append(terms, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// KeywordScore is the fraction of distinct terms found in text.
+func KeywordScore(terms []string, text string) float64 {
+	textTerms := make(map[string]bool)
+	for _, t := range SearchTerms(text) {
+		textTerms[t] = true
+	}
+
+	seen := make(map[string]bool, len(terms))
+	matched := 0
+	for _, t := range terms {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		if textTerms[t] {
+			matched++
+		}
+	}
+	if len(seen) == 0 {
+		return 0
+	}
+	return float64(matched) / float64(len(seen))
+}
+
+// keywordScore is the fraction of distinct query terms found in the fact's
+// content or tags.
+func keywordScore(terms []string, f Fact) float64 {
+	return KeywordScore(terms, f.Content+" "+strings.Join(f.Tags, " "))
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func normalizeFact(content string) string {
+	return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
+
+// newFactID returns a short random ID the model can quote back to forget a fact.
+func newFactID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
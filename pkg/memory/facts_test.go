@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFactStore_SaveSearchForget(t *testing.T) {
+	store, err := NewFactStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	ctx := context.Background()
+
+	tulips, err := store.Save(ctx, "Anna loves tulips", []string{"wife", "flowers"}, "telegram:1")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := store.Save(ctx, "The office wifi password rotates monthly", nil, ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	dup, err := store.Save(ctx, "  anna LOVES   tulips ", nil, "")
+	if err != nil {
+		t.Fatalf("Save duplicate: %v", err)
+	}
+	if dup.ID != tulips.ID {
+		t.Errorf("duplicate save returned new fact %s, want existing %s", dup.ID, tulips.ID)
+	}
+
+	matches, err := store.Search(ctx, "what flowers does Anna like?", 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != tulips.ID {
+		t.Fatalf("Search = %+v, want only the tulips fact", matches)
+	}
+
+	removed, err := store.Forget(ctx, tulips.ID)
+	if err != nil || !removed {
+		t.Fatalf("Forget = %v, %v; want true, nil", removed, err)
+	}
+	if removed, _ := store.Forget(ctx, tulips.ID); removed {
+		t.Error("Forget of a removed fact reported true")
+	}
+
+	facts, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(facts) != 1 || !strings.Contains(facts[0].Content, "wifi") {
+		t.Errorf("List after Forget = %+v, want only the wifi fact", facts)
+	}
+}
+
+func TestFactStore_Person(t *testing.T) {
+	store, err := NewFactStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	alice := WithPerson(context.Background(), "alice")
+	bob := WithPerson(context.Background(), "discord:2")
+
+	if _, err := store.Save(context.Background(), "The office closes at six", nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	tea, err := store.Save(alice, "Prefers green tea", nil, "telegram:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Save(bob, "Prefers coffee", nil, "discord:2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each person sees their own facts and the shared ones.
+	facts, _ := store.List(alice)
+	if len(facts) != 2 || facts[1].ID != tea.ID || facts[1].Person != "alice" {
+		t.Errorf("alice's facts = %+v", facts)
+	}
+	if matches, _ := store.Search(bob, "prefers", 5); len(matches) != 1 ||
+		matches[0].Content != "Prefers coffee" {
+		t.Errorf("bob's search = %+v", matches)
+	}
+	if removed, _ := store.Forget(bob, tea.ID); removed {
+		t.Error("bob forgot alice's fact")
+	}
+
+	// Linking bob to alice hands his facts over.
+	if moved, err := store.MovePerson(context.Background(), "discord:2", "alice"); err != nil || moved != 1 {
+		t.Fatalf("MovePerson = %d, %v", moved, err)
+	}
+	if facts, _ := store.List(alice); len(facts) != 3 {
+		t.Errorf("alice's facts after move = %+v", facts)
+	}
+}
+
+func TestFactStore_SearchCJK(t *testing.T) {
+	store, err := NewFactStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := store.Save(ctx, "用户喜欢喝绿茶", nil, ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	matches, err := store.Search(ctx, "绿茶", 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 {
+		t.Errorf("Search(绿茶) = %d matches, want 1", len(matches))
+	}
+}
+
+// keyEmbedder maps texts to fixed vectors by the first known keyword they contain.
+type keyEmbedder map[string][]float32
+
+func (e keyEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = []float32{0, 0, 1}
+		for key, vec := range e {
+			if strings.Contains(strings.ToLower(text), key) {
+				out[i] = vec
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestFactStore_SemanticSearch(t *testing.T) {
+	store, err := NewFactStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	store.SetEmbedder(keyEmbedder{
+		"tulips": {1, 0, 0},
+		"wife":   {0.9, 0.1, 0},
+		"wifi":   {0, 1, 0},
+	})
+	ctx := context.Background()
+
+	tulips, _ := store.Save(ctx, "Anna loves tulips", nil, "")
+	if _, err := store.Save(ctx, "The office wifi password rotates monthly", nil, ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(tulips.Embedding) != 3 {
+		t.Fatalf("saved fact has embedding %v, want 3 dimensions", tulips.Embedding)
+	}
+
+	matches, err := store.Search(ctx, "gift ideas for my wife", 5)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(matches) != 1 || matches[0].ID != tulips.ID {
+		t.Errorf("Search = %+v, want only the tulips fact by similarity", matches)
+	}
+}
+
+func TestFactStore_SkipsCorruptLines(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFactStore(dir)
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := store.Save(ctx, "first fact", nil, ""); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, factsFile), os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	f.WriteString(`{"id":"broken","content":` + "\n")
+	f.Close()
+
+	if _, err := store.Save(ctx, "second fact", nil, ""); err != nil {
+		t.Fatalf("Save after corrupt line: %v", err)
+	}
+	facts, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(facts) != 2 {
+		t.Errorf("List = %d facts, want 2", len(facts))
+	}
+}
+
+func TestFactStore_Put(t *testing.T) {
+	store, err := NewFactStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	ctx := context.Background()
+
+	saved, err := store.Save(ctx, "Anna loves tulips", nil, "")
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	saved.Tags = []string{"flowers"}
+	if err := store.Put(ctx, saved); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, Fact{ID: "other", Content: "Anna loves tulips"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	facts, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(facts) != 2 || facts[0].ID != saved.ID || len(facts[0].Tags) != 1 || facts[1].ID != "other" {
+		t.Fatalf("List = %+v, want the replaced fact and the duplicate put as is", facts)
+	}
+}
+
+func TestFactStore_Remove(t *testing.T) {
+	store, err := NewFactStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFactStore: %v", err)
+	}
+	ctx := context.Background()
+	for _, f := range []Fact{{ID: "a", Person: "anna"}, {ID: "b", Person: "ben"}, {ID: "c"}} {
+		if err := store.Put(ctx, f); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	// Through a context of another person, as erasure may run in a chat.
+	n, err := store.Remove(WithPerson(ctx, "ben"), func(f Fact) bool { return f.Person == "anna" })
+	if err != nil || n != 1 {
+		t.Fatalf("Remove = %d, %v, want 1", n, err)
+	}
+	facts, _ := store.List(ctx)
+	if len(facts) != 2 || facts[0].ID != "b" || facts[1].ID != "c" {
+		t.Errorf("List after Remove = %+v", facts)
+	}
+}
@@ -203,7 +203,7 @@ func (s *JSONLStore) AddMessage(
 	_ context.Context, sessionKey, role, content string,
 ) error {
 	return s.addMsg(sessionKey, providers.Message{
-		Role:    role,
+		Role:    providers.Role(role),
 		Content: content,
 	})
 }
@@ -220,6 +220,10 @@ func (s *JSONLStore) addMsg(sessionKey string, msg providers.Message) error {
 	l.Lock()
 	defer l.Unlock()
 
+	if err := s.validateNext(sessionKey, msg); err != nil {
+		return err
+	}
+
 	// Append the message as a single JSON line.
 	line, err := json.Marshal(msg)
 	if err != nil {
@@ -267,6 +271,30 @@ func (s *JSONLStore) addMsg(sessionKey string, msg providers.Message) error {
 	return s.writeMeta(sessionKey, meta)
 }
 
+// validateNext checks msg before it is appended to a session, returning a
+// *providers.MessageError if it is malformed. Only a tool result depends on
+// the history it follows, which is then read back.
+func (s *JSONLStore) validateNext(sessionKey string, msg providers.Message) error {
+	if msg.Role != providers.RoleTool {
+		if err := providers.ValidateMessage(msg); err != nil {
+			return fmt.Errorf("memory: %w", err)
+		}
+		return nil
+	}
+	meta, err := s.readMeta(sessionKey)
+	if err != nil {
+		return err
+	}
+	history, err := readMessages(s.jsonlPath(sessionKey), meta.Skip)
+	if err != nil {
+		return err
+	}
+	if err := providers.ValidateNext(history, msg); err != nil {
+		return fmt.Errorf("memory: %w", err)
+	}
+	return nil
+}
+
 func (s *JSONLStore) GetHistory(
 	_ context.Context, sessionKey string,
 ) ([]providers.Message, error) {
@@ -365,6 +393,10 @@ func (s *JSONLStore) SetHistory(
 	sessionKey string,
 	history []providers.Message,
 ) error {
+	if err := providers.ValidateHistory(history); err != nil {
+		return fmt.Errorf("memory: %w", err)
+	}
+
 	l := s.sessionLock(sessionKey)
 	l.Lock()
 	defer l.Unlock()
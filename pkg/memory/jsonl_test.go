@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
@@ -130,13 +131,22 @@ func TestAddFullMessage_ToolCallID(t *testing.T) {
 	store := newTestStore(t)
 	ctx := context.Background()
 
+	// A tool result follows the call it answers.
+	call := providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call_abc", Type: "function"}},
+	}
 	msg := providers.Message{
 		Role:       "tool",
 		Content:    "search results here",
 		ToolCallID: "call_abc",
 	}
 
-	err := store.AddFullMessage(ctx, "tr", msg)
+	err := store.AddFullMessage(ctx, "tr", call)
+	if err != nil {
+		t.Fatalf("AddFullMessage: %v", err)
+	}
+	err = store.AddFullMessage(ctx, "tr", msg)
 	if err != nil {
 		t.Fatalf("AddFullMessage: %v", err)
 	}
@@ -145,11 +155,48 @@ func TestAddFullMessage_ToolCallID(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetHistory: %v", err)
 	}
-	if len(history) != 1 {
-		t.Fatalf("expected 1, got %d", len(history))
+	if len(history) != 2 {
+		t.Fatalf("expected 2, got %d", len(history))
+	}
+	if history[1].ToolCallID != "call_abc" {
+		t.Errorf("ToolCallID = %q", history[1].ToolCallID)
+	}
+}
+
+func TestAddFullMessage_RejectsMalformed(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	err := store.AddFullMessage(ctx, "bad", providers.Message{Role: "tool", Content: "x", ToolCallID: "call_1"})
+	var msgErr *providers.MessageError
+	if !errors.As(err, &msgErr) || !errors.Is(err, providers.ErrOrphanToolResult) || msgErr.ToolCallID != "call_1" {
+		t.Errorf("orphan tool result: %v", err)
 	}
-	if history[0].ToolCallID != "call_abc" {
-		t.Errorf("ToolCallID = %q", history[0].ToolCallID)
+	if err := store.AddMessage(ctx, "bad", "robot", "hi"); !errors.Is(err, providers.ErrInvalidRole) {
+		t.Errorf("unknown role: %v", err)
+	}
+	if history, _ := store.GetHistory(ctx, "bad"); len(history) != 0 {
+		t.Errorf("refused messages were stored: %+v", history)
+	}
+
+	// A truncation that drops the call leaves its results without one.
+	store.AddFullMessage(ctx, "bad", providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call_1"}},
+	})
+	store.AddMessage(ctx, "bad", "user", "never mind")
+	store.TruncateHistory(ctx, "bad", 1)
+	err = store.AddFullMessage(ctx, "bad", providers.Message{Role: "tool", ToolCallID: "call_1"})
+	if !errors.Is(err, providers.ErrOrphanToolResult) {
+		t.Errorf("tool result after truncation: %v", err)
+	}
+
+	err = store.SetHistory(ctx, "bad", []providers.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "tool", Content: "x", ToolCallID: "call_2"},
+	})
+	if !errors.As(err, &msgErr) || msgErr.Index != 1 {
+		t.Errorf("SetHistory(orphan) = %v", err)
 	}
 }
 
@@ -79,7 +79,11 @@ func MigrateFromJSON(
 		// process crashes after writing messages but before the
 		// rename below, a retry replaces the partial data cleanly
 		// instead of duplicating messages.
-		if setErr := store.SetHistory(ctx, key, sess.Messages); setErr != nil {
+		messages, dropped := validMessages(sess.Messages)
+		if dropped > 0 {
+			log.Printf("memory: migrate %s: dropped %d malformed messages", name, dropped)
+		}
+		if setErr := store.SetHistory(ctx, key, messages); setErr != nil {
 			return migrated, fmt.Errorf(
 				"memory: migrate %s: set history: %w",
 				name, setErr,
@@ -106,3 +110,16 @@ func MigrateFromJSON(
 
 	return migrated, nil
 }
+
+// validMessages returns the messages of a legacy session that pass
+// providers.ValidateNext, and how many it dropped. Those sessions could
+// lose a tool call to truncation and keep its results.
+func validMessages(msgs []providers.Message) ([]providers.Message, int) {
+	valid := make([]providers.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if providers.ValidateNext(valid, m) == nil {
+			valid = append(valid, m)
+		}
+	}
+	return valid, len(msgs) - len(valid)
+}
@@ -127,6 +127,37 @@ func TestMigrateFromJSON_WithToolCalls(t *testing.T) {
 	}
 }
 
+func TestMigrateFromJSON_DropsOrphanToolResults(t *testing.T) {
+	sessionsDir := t.TempDir()
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// The legacy store truncated away the call of the first result.
+	writeJSONSession(t, sessionsDir, "orphan.json", jsonSession{
+		Key: "orphan",
+		Messages: []providers.Message{
+			{Role: "tool", Content: "stale result", ToolCallID: "call_old"},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", Content: "hi"},
+		},
+	})
+
+	count, err := MigrateFromJSON(ctx, sessionsDir, store)
+	if err != nil {
+		t.Fatalf("MigrateFromJSON: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 migrated, got %d", count)
+	}
+	history, err := store.GetHistory(ctx, "orphan")
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Content != "hello" {
+		t.Errorf("history = %+v", history)
+	}
+}
+
 func TestMigrateFromJSON_MultipleFiles(t *testing.T) {
 	sessionsDir := t.TempDir()
 	store := newTestStore(t)
@@ -13,6 +13,8 @@ type Store interface {
 	AddMessage(ctx context.Context, sessionKey, role, content string) error
 
 	// AddFullMessage appends a complete message (with tool calls, etc.) to a session.
+	// A malformed message, or a tool result that does not follow its call,
+	// is refused with a *providers.MessageError; AddMessage checks the role.
 	AddFullMessage(ctx context.Context, sessionKey string, msg providers.Message) error
 
 	// GetHistory returns all messages for a session in insertion order.
@@ -31,6 +33,7 @@ type Store interface {
 	TruncateHistory(ctx context.Context, sessionKey string, keepLast int) error
 
 	// SetHistory replaces all messages in a session with the provided history.
+	// A history that fails providers.ValidateHistory is refused.
 	SetHistory(ctx context.Context, sessionKey string, history []providers.Message) error
 
 	// Compact reclaims storage by physically removing logically truncated
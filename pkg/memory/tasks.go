@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// tasksFile is the name of the task list inside its directory.
+const tasksFile = "tasks.jsonl"
+
+// ErrTaskNotFound is returned for an unknown task ID, or the task of
+// another person.
+var ErrTaskNotFound = errors.New("no such task")
+
+// TaskState is how far a task has come.
+type TaskState string
+
+const (
+	TaskPending    TaskState = "pending"
+	TaskInProgress TaskState = "in_progress"
+	TaskBlocked    TaskState = "blocked" // waiting on something or someone
+	TaskDone       TaskState = "done"
+)
+
+// ParseTaskState parses a state name; "" is TaskPending.
+func ParseTaskState(name string) (TaskState, error) {
+	switch s := TaskState(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")); s {
+	case "":
+		return TaskPending, nil
+	case TaskPending, TaskInProgress, TaskBlocked, TaskDone:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown state %q (want pending, in_progress, blocked or done)", name)
+	}
+}
+
+// Task is something the user wants done, kept until it is deleted.
+type Task struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Notes     string    `json:"notes,omitempty"`
+	State     TaskState `json:"state"`
+	Due       time.Time `json:"due,omitzero"`
+	Source    string    `json:"source,omitempty"` // session key the task was added from
+	Person    string    `json:"person,omitempty"` // who the task belongs to; empty tasks are shared
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	DoneAt    time.Time `json:"done_at,omitzero"`
+}
+
+// Overdue reports whether t is not done and was due before now.
+func (t Task) Overdue(now time.Time) bool {
+	return t.State != TaskDone && !t.Due.IsZero() && t.Due.Before(now)
+}
+
+// TaskStore keeps tasks in a JSONL file, rewritten on every change. Like
+// facts, tasks seen through a context scoped to a person (see WithPerson)
+// are theirs and the shared ones.
+type TaskStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewTaskStore creates a task store stored as tasks.jsonl inside dir.
+func NewTaskStore(dir string) (*TaskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("memory: create directory: %w", err)
+	}
+	return &TaskStore{path: filepath.Join(dir, tasksFile)}, nil
+}
+
+// Add stores a new task and returns it with its ID. The task belongs to the
+// person of ctx, and is pending unless it has a state.
+func (s *TaskStore) Add(ctx context.Context, t Task) (_ Task, err error) {
+	_, span := telemetry.Start(ctx, "memory.tasks.add")
+	defer func() { telemetry.End(span, err) }()
+
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return Task{}, errors.New("memory: task title is empty")
+	}
+	if t.State == "" {
+		t.State = TaskPending
+	}
+	now := time.Now()
+	t.ID = newFactID()
+	t.Person = PersonFrom(ctx)
+	t.CreatedAt, t.UpdatedAt = now, now
+	if t.State == TaskDone {
+		t.DoneAt = now
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tasks, err := s.loadLocked()
+	if err != nil {
+		return Task{}, err
+	}
+	return t, s.rewriteLocked(append(tasks, t))
+}
+
+// List returns the tasks visible through ctx, the ones due first (soonest
+// first), then the rest in the order they were added. With states only
+// tasks in one of them are listed.
+func (s *TaskStore) List(ctx context.Context, states ...TaskState) ([]Task, error) {
+	s.mu.Lock()
+	tasks, err := s.loadLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	person := PersonFrom(ctx)
+	visible := tasks[:0]
+	for _, t := range tasks {
+		if t.visible(person) && (len(states) == 0 || slices.Contains(states, t.State)) {
+			visible = append(visible, t)
+		}
+	}
+	sortTasks(visible)
+	return visible, nil
+}
+
+// Overdue returns the tasks of everyone that are not done and were due
+// before now, the longest overdue first.
+func (s *TaskStore) Overdue(_ context.Context, now time.Time) ([]Task, error) {
+	s.mu.Lock()
+	tasks, err := s.loadLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	overdue := tasks[:0]
+	for _, t := range tasks {
+		if t.Overdue(now) {
+			overdue = append(overdue, t)
+		}
+	}
+	sortTasks(overdue)
+	return overdue, nil
+}
+
+// Update changes the task with the given ID with fn and stores it. fn may
+// change the title, notes, state and due date; Update keeps the rest.
+func (s *TaskStore) Update(ctx context.Context, id string, fn func(*Task)) (_ Task, err error) {
+	_, span := telemetry.Start(ctx, "memory.tasks.update")
+	defer func() { telemetry.End(span, err) }()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, err := s.loadLocked()
+	if err != nil {
+		return Task{}, err
+	}
+	person := PersonFrom(ctx)
+	for i, old := range tasks {
+		if old.ID != id || !old.visible(person) {
+			continue
+		}
+		t := old
+		fn(&t)
+		t.Title = strings.TrimSpace(t.Title)
+		if t.Title == "" {
+			return Task{}, errors.New("memory: task title is empty")
+		}
+		t.ID, t.Person, t.Source, t.CreatedAt = old.ID, old.Person, old.Source, old.CreatedAt
+		t.UpdatedAt = time.Now()
+		switch {
+		case t.State != TaskDone:
+			t.DoneAt = time.Time{}
+		case old.State != TaskDone:
+			t.DoneAt = t.UpdatedAt
+		default:
+			t.DoneAt = old.DoneAt
+		}
+		tasks[i] = t
+		return t, s.rewriteLocked(tasks)
+	}
+	return Task{}, ErrTaskNotFound
+}
+
+// Delete removes the task with the given ID and reports whether it
+// existed. Tasks of other people cannot be deleted.
+func (s *TaskStore) Delete(ctx context.Context, id string) (bool, error) {
+	person := PersonFrom(ctx)
+	n, err := s.Remove(ctx, func(t Task) bool { return t.ID == id && t.visible(person) })
+	return n > 0, err
+}
+
+// Remove deletes the tasks match selects, whoever they belong to, and
+// returns how many it deleted.
+func (s *TaskStore) Remove(_ context.Context, match func(Task) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, err := s.loadLocked()
+	if err != nil {
+		return 0, err
+	}
+	kept := tasks[:0]
+	for _, t := range tasks {
+		if !match(t) {
+			kept = append(kept, t)
+		}
+	}
+	removed := len(tasks) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, s.rewriteLocked(kept)
+}
+
+func (t Task) visible(person string) bool {
+	return person == "" || t.Person == "" || t.Person == person
+}
+
+func sortTasks(tasks []Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i].Due, tasks[j].Due
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+}
+
+func (s *TaskStore) rewriteLocked(tasks []Task) error {
+	var buf []byte
+	for _, t := range tasks {
+		line, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Errorf("memory: marshal task: %w", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if err := fileutil.WriteFileAtomic(s.path, buf, 0o600); err != nil {
+		return fmt.Errorf("memory: rewrite task store: %w", err)
+	}
+	return nil
+}
+
+// loadLocked reads every decodable task. Corrupt lines are logged and
+// skipped, as in JSONLStore. Callers must hold s.mu.
+func (s *TaskStore) loadLocked() ([]Task, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory: open task store: %w", err)
+	}
+	defer f.Close()
+
+	var tasks []Task
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineNum++
+		if len(line) == 0 {
+			continue
+		}
+		var t Task
+		if err := json.Unmarshal(line, &t); err != nil {
+			log.Printf("memory: skipping corrupt task line %d: %v", lineNum, err)
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("memory: scan task store: %w", err)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTaskStore_AddUpdateDelete(t *testing.T) {
+	store, err := NewTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTaskStore: %v", err)
+	}
+	ctx := context.Background()
+	tomorrow := time.Now().Add(24 * time.Hour)
+
+	tax, err := store.Add(ctx, Task{Title: " File the tax return ", Due: tomorrow, Source: "agent:main:main"})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if tax.ID == "" || tax.Title != "File the tax return" || tax.State != TaskPending {
+		t.Fatalf("Add = %+v", tax)
+	}
+	if _, err := store.Add(ctx, Task{Title: "Buy a birthday present"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := store.Add(ctx, Task{Title: "  "}); err == nil {
+		t.Error("Add without a title succeeded")
+	}
+
+	done, err := store.Update(ctx, tax.ID, func(t *Task) {
+		t.State = TaskDone
+		t.ID, t.Source = "other", "" // ignored
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if done.ID != tax.ID || done.Source != "agent:main:main" || done.DoneAt.IsZero() {
+		t.Errorf("Update = %+v", done)
+	}
+	reopened, err := store.Update(ctx, tax.ID, func(t *Task) { t.State = TaskBlocked })
+	if err != nil || !reopened.DoneAt.IsZero() {
+		t.Errorf("reopen = %+v, %v", reopened, err)
+	}
+	if _, err := store.Update(ctx, "missing", func(*Task) {}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Update unknown = %v, want ErrTaskNotFound", err)
+	}
+
+	blocked, err := store.List(ctx, TaskBlocked)
+	if err != nil || len(blocked) != 1 || blocked[0].ID != tax.ID {
+		t.Errorf("List(blocked) = %+v, %v", blocked, err)
+	}
+
+	removed, err := store.Delete(ctx, tax.ID)
+	if err != nil || !removed {
+		t.Fatalf("Delete = %v, %v", removed, err)
+	}
+	reloaded, _ := NewTaskStore(filepath.Dir(store.path))
+	all, err := reloaded.List(ctx)
+	if err != nil || len(all) != 1 || all[0].Title != "Buy a birthday present" {
+		t.Errorf("List after delete = %+v, %v", all, err)
+	}
+}
+
+func TestTaskStore_PersonAndOverdue(t *testing.T) {
+	store, err := NewTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTaskStore: %v", err)
+	}
+	now := time.Now()
+	anna := WithPerson(context.Background(), "anna")
+	ben := WithPerson(context.Background(), "ben")
+
+	late, _ := store.Add(anna, Task{Title: "Renew the passport", Due: now.Add(-48 * time.Hour)})
+	_, _ = store.Add(ben, Task{Title: "Call the plumber", Due: now.Add(-time.Hour), State: TaskInProgress})
+	_, _ = store.Add(ben, Task{Title: "Water the plants", Due: now.Add(-time.Hour), State: TaskDone})
+	_, _ = store.Add(context.Background(), Task{Title: "Pay the rent", Due: now.Add(time.Hour)})
+
+	tasks, err := store.List(anna)
+	if err != nil || len(tasks) != 2 || tasks[0].ID != late.ID || tasks[1].Title != "Pay the rent" {
+		t.Errorf("List(anna) = %+v, %v", tasks, err)
+	}
+	if removed, _ := store.Delete(ben, late.ID); removed {
+		t.Error("ben deleted anna's task")
+	}
+
+	overdue, err := store.Overdue(context.Background(), now)
+	if err != nil || len(overdue) != 2 {
+		t.Fatalf("Overdue = %+v, %v", overdue, err)
+	}
+	if overdue[0].Title != "Renew the passport" || overdue[1].Title != "Call the plumber" {
+		t.Errorf("Overdue = %q, %q; want the longest overdue first", overdue[0].Title, overdue[1].Title)
+	}
+}
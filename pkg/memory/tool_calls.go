@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// toolCallLogFile is the name of the log inside its directory.
+const toolCallLogFile = "tool_calls.jsonl"
+
+// Tool call outcomes.
+const (
+	ToolOutcomeOK      = "ok"      // the tool ran and succeeded
+	ToolOutcomeError   = "error"   // the tool ran and failed, timed out or was not found
+	ToolOutcomeInvalid = "invalid" // the arguments did not match the tool's schema
+	ToolOutcomeDenied  = "denied"  // the tool policy refused the call
+	ToolOutcomePending = "pending" // the call is waiting for /approve
+	ToolOutcomeStarted = "started" // the call continues as an async call or background job
+)
+
+// ToolCallRecord is one log entry: a single tool invocation. Arguments are
+// stored only as a hash, so the log shows what ran without keeping file
+// contents, messages or secrets passed to tools.
+type ToolCallRecord struct {
+	Time       time.Time `json:"time"`
+	SessionKey string    `json:"session_key,omitempty"`
+	Channel    string    `json:"channel,omitempty"`
+	ChatID     string    `json:"chat_id,omitempty"`
+	SenderID   string    `json:"sender_id,omitempty"`
+	Tool       string    `json:"tool"`
+	ArgsHash   string    `json:"args_hash"`
+	DurationMS int64     `json:"duration_ms"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// HashToolArgs returns the hex SHA-256 of args encoded as JSON. Map keys
+// are encoded in sorted order, so equal arguments hash equally.
+func HashToolArgs(args map[string]any) string {
+	if args == nil {
+		args = map[string]any{}
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		data = fmt.Appendf(nil, "%v", args)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ToolCallFilter selects records in ToolCallLog.Records. Zero fields match
+// everything.
+type ToolCallFilter struct {
+	SessionKey string
+	Tool       string
+	Outcome    string
+	Since      time.Time
+	Limit      int // keep only the newest Limit records
+}
+
+func (f ToolCallFilter) matches(rec ToolCallRecord) bool {
+	return (f.SessionKey == "" || rec.SessionKey == f.SessionKey) &&
+		(f.Tool == "" || rec.Tool == f.Tool) &&
+		(f.Outcome == "" || rec.Outcome == f.Outcome) &&
+		(f.Since.IsZero() || !rec.Time.Before(f.Since))
+}
+
+// ToolCallStats aggregates the calls of one tool.
+type ToolCallStats struct {
+	Tool       string         `json:"tool"`
+	Calls      int            `json:"calls"`
+	Sessions   int            `json:"sessions"` // distinct sessions that called the tool
+	Outcomes   map[string]int `json:"outcomes"`
+	TotalMS    int64          `json:"total_ms"`
+	MaxMS      int64          `json:"max_ms"`
+	LastCalled time.Time      `json:"last_called"`
+}
+
+// AvgMS returns the mean duration of the calls in milliseconds.
+func (s ToolCallStats) AvgMS() int64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return s.TotalMS / int64(s.Calls)
+}
+
+func (s *ToolCallStats) add(rec ToolCallRecord) {
+	s.Calls++
+	s.Outcomes[rec.Outcome]++
+	s.TotalMS += rec.DurationMS
+	s.MaxMS = max(s.MaxMS, rec.DurationMS)
+	if rec.Time.After(s.LastCalled) {
+		s.LastCalled = rec.Time
+	}
+}
+
+// ToolCallLog is an append-only JSONL audit trail of tool invocations,
+// kept like UsageLedger: every write is one append and queries scan the
+// file.
+type ToolCallLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewToolCallLog creates a log stored as tool_calls.jsonl inside dir.
+func NewToolCallLog(dir string) (*ToolCallLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("memory: create directory: %w", err)
+	}
+	return &ToolCallLog{path: filepath.Join(dir, toolCallLogFile)}, nil
+}
+
+// Record appends rec to the log.
+func (l *ToolCallLog) Record(ctx context.Context, rec ToolCallRecord) (err error) {
+	_, span := telemetry.Start(ctx, "memory.tool_calls.record")
+	defer func() { telemetry.End(span, err) }()
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("memory: marshal tool call: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("memory: open tool call log: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("memory: append tool call: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("memory: close tool call log: %w", err)
+	}
+	return nil
+}
+
+// Records returns the records matching filter, oldest first.
+func (l *ToolCallLog) Records(_ context.Context, filter ToolCallFilter) ([]ToolCallRecord, error) {
+	var records []ToolCallRecord
+	err := l.scan(func(rec ToolCallRecord) {
+		if filter.matches(rec) {
+			records = append(records, rec)
+		}
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[len(records)-filter.Limit:]
+	}
+	return records, err
+}
+
+// Stats returns per-tool aggregates of the records matching filter, most
+// called first. filter.Limit is ignored.
+func (l *ToolCallLog) Stats(_ context.Context, filter ToolCallFilter) ([]ToolCallStats, error) {
+	byTool := make(map[string]*ToolCallStats)
+	sessions := make(map[string]map[string]struct{})
+	err := l.scan(func(rec ToolCallRecord) {
+		if !filter.matches(rec) {
+			return
+		}
+		s, ok := byTool[rec.Tool]
+		if !ok {
+			s = &ToolCallStats{Tool: rec.Tool, Outcomes: map[string]int{}}
+			byTool[rec.Tool] = s
+			sessions[rec.Tool] = map[string]struct{}{}
+		}
+		s.add(rec)
+		if rec.SessionKey != "" {
+			sessions[rec.Tool][rec.SessionKey] = struct{}{}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]ToolCallStats, 0, len(byTool))
+	for tool, s := range byTool {
+		s.Sessions = len(sessions[tool])
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Calls != stats[j].Calls {
+			return stats[i].Calls > stats[j].Calls
+		}
+		return stats[i].Tool < stats[j].Tool
+	})
+	return stats, nil
+}
+
+// Remove deletes the records match selects from the log and returns how
+// many it deleted.
+func (l *ToolCallLog) Remove(_ context.Context, match func(ToolCallRecord) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf []byte
+	removed := 0
+	err := l.scanLocked(func(rec ToolCallRecord) {
+		if match(rec) {
+			removed++
+			return
+		}
+		line, _ := json.Marshal(rec) // a decoded record always marshals
+		buf = append(append(buf, line...), '\n')
+	})
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+	if err := fileutil.WriteFileAtomic(l.path, buf, 0o644); err != nil {
+		return 0, fmt.Errorf("memory: rewrite tool call log: %w", err)
+	}
+	return removed, nil
+}
+
+// scan calls fn for every decodable record, skipping corrupt lines as
+// UsageLedger does.
+func (l *ToolCallLog) scan(fn func(ToolCallRecord)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.scanLocked(fn)
+}
+
+func (l *ToolCallLog) scanLocked(fn func(ToolCallRecord)) error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("memory: open tool call log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineNum++
+		if len(line) == 0 {
+			continue
+		}
+		var rec ToolCallRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("memory: skipping corrupt tool call line %d: %v", lineNum, err)
+			continue
+		}
+		fn(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("memory: scan tool call log: %w", err)
+	}
+	return nil
+}
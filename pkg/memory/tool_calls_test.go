@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestToolCallLog_RecordsAndStats(t *testing.T) {
+	calls, err := NewToolCallLog(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewToolCallLog: %v", err)
+	}
+	ctx := context.Background()
+
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i, rec := range []ToolCallRecord{
+		{SessionKey: "a", Tool: "exec", DurationMS: 100, Outcome: ToolOutcomeOK},
+		{SessionKey: "a", Tool: "exec", DurationMS: 300, Outcome: ToolOutcomeError, Error: "exit status 1"},
+		{SessionKey: "b", Tool: "exec", DurationMS: 0, Outcome: ToolOutcomeDenied},
+		{SessionKey: "b", Tool: "read_file", DurationMS: 5, Outcome: ToolOutcomeOK},
+	} {
+		rec.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := calls.Record(ctx, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	records, err := calls.Records(ctx, ToolCallFilter{Tool: "exec", Limit: 2})
+	if err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+	if len(records) != 2 || records[0].Outcome != ToolOutcomeError || records[1].Outcome != ToolOutcomeDenied {
+		t.Errorf("Records(exec, limit 2) = %+v", records)
+	}
+	recent := ToolCallFilter{SessionKey: "b", Since: start.Add(3 * time.Hour)}
+	if records, _ := calls.Records(ctx, recent); len(records) != 1 {
+		t.Errorf("Records(b, since) = %+v, want read_file only", records)
+	}
+
+	stats, err := calls.Stats(ctx, ToolCallFilter{})
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Tool != "exec" || stats[1].Tool != "read_file" {
+		t.Fatalf("Stats = %+v", stats)
+	}
+	exec := stats[0]
+	if exec.Calls != 3 || exec.Sessions != 2 || exec.MaxMS != 300 || exec.AvgMS() != 133 ||
+		exec.Outcomes[ToolOutcomeError] != 1 || !exec.LastCalled.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("exec stats = %+v", exec)
+	}
+}
+
+func TestHashToolArgs_StableAcrossKeyOrder(t *testing.T) {
+	a := HashToolArgs(map[string]any{"command": "ls", "cwd": "/tmp"})
+	b := HashToolArgs(map[string]any{"cwd": "/tmp", "command": "ls"})
+	if a != b || len(a) != 64 {
+		t.Errorf("hashes %q and %q", a, b)
+	}
+	if HashToolArgs(nil) != HashToolArgs(map[string]any{}) {
+		t.Error("nil and empty args hash differently")
+	}
+	if a == HashToolArgs(map[string]any{"command": "ls -la", "cwd": "/tmp"}) {
+		t.Error("different args hash equally")
+	}
+}
+
+func TestToolCallLog_Remove(t *testing.T) {
+	calls, err := NewToolCallLog(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewToolCallLog: %v", err)
+	}
+	ctx := context.Background()
+	for _, sender := range []string{"telegram:1", "telegram:2"} {
+		if err := calls.Record(ctx, ToolCallRecord{SenderID: sender, Tool: "exec", Outcome: ToolOutcomeOK}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	n, err := calls.Remove(ctx, func(rec ToolCallRecord) bool { return rec.SenderID == "telegram:1" })
+	if err != nil || n != 1 {
+		t.Fatalf("Remove = %d, %v, want 1", n, err)
+	}
+	records, err := calls.Records(ctx, ToolCallFilter{})
+	if err != nil || len(records) != 1 || records[0].SenderID != "telegram:2" {
+		t.Errorf("Records after Remove = %+v, %v", records, err)
+	}
+}
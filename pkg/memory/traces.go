@@ -0,0 +1,257 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// ErrTraceNotFound is returned for a turn that has no trace.
+var ErrTraceNotFound = errors.New("no trace for that turn")
+
+// TurnTrace records how the agent handled one turn: the prompt it built,
+// every LLM call and tool call with their timings, and the reply.
+type TurnTrace struct {
+	Turn        int                 `json:"turn"` // numbered from 1 within the session
+	SessionKey  string              `json:"session_key"`
+	AgentID     string              `json:"agent_id,omitempty"`
+	Channel     string              `json:"channel,omitempty"`
+	ChatID      string              `json:"chat_id,omitempty"`
+	Started     time.Time           `json:"started"`
+	DurationMS  int64               `json:"duration_ms"`
+	UserMessage string              `json:"user_message"`
+	Prompt      []providers.Message `json:"prompt"` // as sent with the first LLM call
+	LLMCalls    []LLMCallTrace      `json:"llm_calls"`
+	ToolCalls   []ToolCallTrace     `json:"tool_calls,omitempty"`
+	Response    string              `json:"response"`
+	Error       string              `json:"error,omitempty"`
+	Usage       UsageTotals         `json:"usage"`
+}
+
+// LLMCallTrace is one LLM call of a turn.
+type LLMCallTrace struct {
+	Iteration int    `json:"iteration"`
+	Model     string `json:"model"`
+	// Messages is how many messages were sent: the prompt followed by the
+	// turn's earlier tool calls and results.
+	Messages         int      `json:"messages"`
+	Tools            []string `json:"tools,omitempty"` // tools offered to the model
+	DurationMS       int64    `json:"duration_ms"`
+	Content          string   `json:"content,omitempty"`
+	Reasoning        string   `json:"reasoning,omitempty"`
+	ToolCalls        []string `json:"tool_calls,omitempty"` // names of the tools the model called
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	CachedTokens     int      `json:"cached_tokens,omitempty"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// ToolCallTrace is one tool call of a turn.
+type ToolCallTrace struct {
+	Iteration  int            `json:"iteration"`
+	Name       string         `json:"name"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	Result     string         `json:"result"` // what the model was given
+	IsError    bool           `json:"is_error,omitempty"`
+	DurationMS int64          `json:"duration_ms"`
+}
+
+// AddUsage counts the usage of an LLM call of the turn.
+func (t *TurnTrace) AddUsage(usage *providers.UsageInfo) {
+	t.Usage.add(NewUsageRecord(t.SessionKey, t.AgentID, "", usage))
+}
+
+// TraceLog keeps the traces of the latest turns of each session, one JSONL
+// file per session. Traces hold whole prompts, so only the last keep turns
+// of a session are kept.
+type TraceLog struct {
+	dir  string
+	keep int
+	mu   sync.Mutex
+	last map[string]traceTail // per session, filled on first use
+}
+
+type traceTail struct {
+	turn  int // number of the latest turn
+	count int // traces in the file
+}
+
+// NewTraceLog creates a log stored in dir that keeps the last keep turns of
+// each session; keep < 1 keeps one.
+func NewTraceLog(dir string, keep int) (*TraceLog, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("memory: create directory: %w", err)
+	}
+	return &TraceLog{dir: dir, keep: max(keep, 1), last: make(map[string]traceTail)}, nil
+}
+
+// Record numbers tr as the next turn of its session and stores it, dropping
+// the session's oldest traces beyond the limit.
+func (l *TraceLog) Record(ctx context.Context, tr *TurnTrace) (err error) {
+	_, span := telemetry.Start(ctx, "memory.traces.record")
+	defer func() { telemetry.End(span, err) }()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tail, ok := l.last[tr.SessionKey]
+	if !ok {
+		traces, err := l.read(tr.SessionKey)
+		if err != nil {
+			return err
+		}
+		tail.count = len(traces)
+		if len(traces) > 0 {
+			tail.turn = traces[len(traces)-1].Turn
+		}
+	}
+	tr.Turn = tail.turn + 1
+	line, err := json.Marshal(tr)
+	if err != nil {
+		return fmt.Errorf("memory: marshal trace: %w", err)
+	}
+	line = append(line, '\n')
+
+	path := l.path(tr.SessionKey)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("memory: open trace log: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("memory: append trace: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("memory: close trace log: %w", err)
+	}
+	tail.turn, tail.count = tr.Turn, tail.count+1
+	l.last[tr.SessionKey] = tail
+
+	// Trimming rewrites the file, so it waits for a few extra turns.
+	if tail.count > l.keep+l.keep/4 {
+		if err := l.trim(tr.SessionKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Turns returns the traces kept for a session, oldest first.
+func (l *TraceLog) Turns(_ context.Context, sessionKey string) ([]TurnTrace, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read(sessionKey)
+}
+
+// Delete removes the traces of a session and returns how many there were.
+func (l *TraceLog) Delete(_ context.Context, sessionKey string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	traces, err := l.read(sessionKey)
+	if err != nil || len(traces) == 0 {
+		return 0, err
+	}
+	if err := os.Remove(l.path(sessionKey)); err != nil {
+		return 0, fmt.Errorf("memory: delete traces: %w", err)
+	}
+	delete(l.last, sessionKey)
+	return len(traces), nil
+}
+
+// Turn returns the trace of one turn of a session, or of its latest turn
+// when turn < 1.
+func (l *TraceLog) Turn(ctx context.Context, sessionKey string, turn int) (*TurnTrace, error) {
+	traces, err := l.Turns(ctx, sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	if turn < 1 && len(traces) > 0 {
+		return &traces[len(traces)-1], nil
+	}
+	for i := range traces {
+		if traces[i].Turn == turn {
+			return &traces[i], nil
+		}
+	}
+	return nil, ErrTraceNotFound
+}
+
+func (l *TraceLog) path(sessionKey string) string {
+	return filepath.Join(l.dir, sanitizeKey(sessionKey)+".jsonl")
+}
+
+// trim keeps the last l.keep traces of a session. The caller holds l.mu.
+func (l *TraceLog) trim(sessionKey string) error {
+	traces, err := l.read(sessionKey)
+	if err != nil {
+		return err
+	}
+	if len(traces) > l.keep {
+		traces = traces[len(traces)-l.keep:]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range traces {
+		if err := enc.Encode(&traces[i]); err != nil {
+			return fmt.Errorf("memory: marshal trace: %w", err)
+		}
+	}
+	if err := fileutil.WriteFileAtomic(l.path(sessionKey), buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("memory: rewrite trace log: %w", err)
+	}
+	tail := l.last[sessionKey]
+	tail.count = len(traces)
+	l.last[sessionKey] = tail
+	return nil
+}
+
+// read returns the decodable traces of a session, skipping corrupt lines as
+// UsageLedger does. The caller holds l.mu.
+func (l *TraceLog) read(sessionKey string) ([]TurnTrace, error) {
+	f, err := os.Open(l.path(sessionKey))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memory: open trace log: %w", err)
+	}
+	defer f.Close()
+
+	var traces []TurnTrace
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineNum++
+		if len(line) == 0 {
+			continue
+		}
+		var tr TurnTrace
+		if err := json.Unmarshal(line, &tr); err != nil {
+			log.Printf("memory: skipping corrupt trace line %d: %v", lineNum, err)
+			continue
+		}
+		// Keys that sanitize to the same file name share it.
+		if tr.SessionKey == sessionKey {
+			traces = append(traces, tr)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return traces, fmt.Errorf("memory: read trace log: %w", err)
+	}
+	return traces, nil
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestTraceLog_RecordAndTrim(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	l, err := NewTraceLog(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 6 {
+		tr := &TurnTrace{SessionKey: "agent:main:main", UserMessage: string(rune('a' + i))}
+		tr.AddUsage(&providers.UsageInfo{PromptTokens: 10, CompletionTokens: 5})
+		if err := l.Record(ctx, tr); err != nil {
+			t.Fatal(err)
+		}
+		if tr.Turn != i+1 {
+			t.Fatalf("turn %d numbered %d", i+1, tr.Turn)
+		}
+	}
+	if err := l.Record(ctx, &TurnTrace{SessionKey: "agent:main:other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopened, as the CLI does; the two oldest turns are trimmed.
+	l, _ = NewTraceLog(dir, 4)
+	traces, err := l.Turns(ctx, "agent:main:main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 4 || traces[0].Turn != 3 || traces[0].UserMessage != "c" {
+		t.Errorf("kept traces = %+v, want turns 3 to 6", traces)
+	}
+	if traces[0].Usage.TotalTokens != 15 || traces[0].Usage.Requests != 1 {
+		t.Errorf("usage = %+v", traces[0].Usage)
+	}
+
+	last, err := l.Turn(ctx, "agent:main:main", 0)
+	if err != nil || last.Turn != 6 {
+		t.Errorf("latest turn = %+v, %v", last, err)
+	}
+	tr := &TurnTrace{SessionKey: "agent:main:main"}
+	if err := l.Record(ctx, tr); err != nil || tr.Turn != 7 {
+		t.Errorf("turn after reopening = %d, %v", tr.Turn, err)
+	}
+	if _, err := l.Turn(ctx, "agent:main:main", 1); !errors.Is(err, ErrTraceNotFound) {
+		t.Errorf("trimmed turn: err = %v, want ErrTraceNotFound", err)
+	}
+}
+
+func TestTraceLog_Delete(t *testing.T) {
+	ctx := context.Background()
+	l, err := NewTraceLog(t.TempDir(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := l.Record(ctx, &TurnTrace{SessionKey: "agent:main:main"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := l.Delete(ctx, "agent:main:main"); err != nil || n != 2 {
+		t.Fatalf("Delete() = %d, %v, want 2", n, err)
+	}
+	if traces, _ := l.Turns(ctx, "agent:main:main"); len(traces) != 0 {
+		t.Errorf("traces after Delete = %+v", traces)
+	}
+	// Turns are numbered from 1 again.
+	tr := &TurnTrace{SessionKey: "agent:main:main"}
+	if err := l.Record(ctx, tr); err != nil || tr.Turn != 1 {
+		t.Errorf("turn after Delete = %d, %v", tr.Turn, err)
+	}
+}
@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// usageLedgerFile is the name of the ledger inside its directory.
+const usageLedgerFile = "usage.jsonl"
+
+// UsageRecord is one ledger entry: the token usage of a single LLM call.
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	SessionKey       string    `json:"session_key"`
+	AgentID          string    `json:"agent_id,omitempty"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CachedTokens     int       `json:"cached_tokens,omitempty"` // prompt tokens served from the provider cache
+	CostUSD          float64   `json:"cost_usd,omitempty"`
+}
+
+// UsageTotals aggregates a set of usage records.
+type UsageTotals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CachedTokens     int     `json:"cached_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// DailyUsage is the aggregate for one calendar day (local time).
+type DailyUsage struct {
+	Date string `json:"date"` // YYYY-MM-DD
+	UsageTotals
+}
+
+func (t *UsageTotals) add(rec UsageRecord) {
+	t.Requests++
+	t.PromptTokens += rec.PromptTokens
+	t.CompletionTokens += rec.CompletionTokens
+	t.TotalTokens += rec.TotalTokens
+	t.CachedTokens += rec.CachedTokens
+	t.CostUSD += rec.CostUSD
+}
+
+// UsageLedger is an append-only JSONL log of token usage. Aggregates are
+// computed by scanning the file, which stays cheap at the volume a personal
+// agent produces and keeps every write a single append.
+type UsageLedger struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewUsageLedger creates a ledger stored as usage.jsonl inside dir.
+func NewUsageLedger(dir string) (*UsageLedger, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("memory: create directory: %w", err)
+	}
+	return &UsageLedger{path: filepath.Join(dir, usageLedgerFile)}, nil
+}
+
+// NewUsageRecord builds a record from provider-reported usage.
+// A nil usage yields a record with only the metadata set.
+func NewUsageRecord(sessionKey, agentID, model string, usage *providers.UsageInfo) UsageRecord {
+	rec := UsageRecord{
+		Time:       time.Now(),
+		SessionKey: sessionKey,
+		AgentID:    agentID,
+		Model:      model,
+	}
+	if usage != nil {
+		rec.PromptTokens = usage.PromptTokens
+		rec.CompletionTokens = usage.CompletionTokens
+		rec.TotalTokens = usage.TotalTokens
+		rec.CachedTokens = usage.CachedTokens()
+		if rec.TotalTokens == 0 {
+			rec.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		}
+	}
+	return rec
+}
+
+// Record appends rec to the ledger.
+func (l *UsageLedger) Record(ctx context.Context, rec UsageRecord) (err error) {
+	_, span := telemetry.Start(ctx, "memory.usage.record")
+	defer func() { telemetry.End(span, err) }()
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("memory: marshal usage: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("memory: open usage ledger: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("memory: append usage: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("memory: close usage ledger: %w", err)
+	}
+	return nil
+}
+
+// SessionTotals returns the aggregate usage of one session.
+func (l *UsageLedger) SessionTotals(_ context.Context, sessionKey string) (UsageTotals, error) {
+	var totals UsageTotals
+	err := l.scan(func(rec UsageRecord) {
+		if rec.SessionKey == sessionKey {
+			totals.add(rec)
+		}
+	})
+	return totals, err
+}
+
+// Totals returns the aggregate usage of the records at or after since, of
+// one session or, when sessionKey is "", of all of them.
+func (l *UsageLedger) Totals(_ context.Context, since time.Time, sessionKey string) (UsageTotals, error) {
+	var totals UsageTotals
+	err := l.scan(func(rec UsageRecord) {
+		if rec.Time.Before(since) || (sessionKey != "" && rec.SessionKey != sessionKey) {
+			return
+		}
+		totals.add(rec)
+	})
+	return totals, err
+}
+
+// Daily returns per-day aggregates for records at or after since, ordered
+// by date. A zero since includes the whole ledger.
+func (l *UsageLedger) Daily(_ context.Context, since time.Time) ([]DailyUsage, error) {
+	byDay := make(map[string]*UsageTotals)
+	err := l.scan(func(rec UsageRecord) {
+		if !since.IsZero() && rec.Time.Before(since) {
+			return
+		}
+		day := rec.Time.Local().Format(time.DateOnly)
+		t, ok := byDay[day]
+		if !ok {
+			t = &UsageTotals{}
+			byDay[day] = t
+		}
+		t.add(rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	days := make([]DailyUsage, 0, len(byDay))
+	for day, t := range byDay {
+		days = append(days, DailyUsage{Date: day, UsageTotals: *t})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+// Records returns every record in the ledger, oldest first.
+func (l *UsageLedger) Records(_ context.Context) ([]UsageRecord, error) {
+	var records []UsageRecord
+	err := l.scan(func(rec UsageRecord) {
+		records = append(records, rec)
+	})
+	return records, err
+}
+
+// Remove deletes the records match selects from the ledger and returns how
+// many it deleted.
+func (l *UsageLedger) Remove(_ context.Context, match func(UsageRecord) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf []byte
+	removed := 0
+	err := l.scanLocked(func(rec UsageRecord) {
+		if match(rec) {
+			removed++
+			return
+		}
+		line, _ := json.Marshal(rec) // a decoded record always marshals
+		buf = append(append(buf, line...), '\n')
+	})
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+	if err := fileutil.WriteFileAtomic(l.path, buf, 0o644); err != nil {
+		return 0, fmt.Errorf("memory: rewrite usage ledger: %w", err)
+	}
+	return removed, nil
+}
+
+// scan calls fn for every decodable record. Corrupt lines (e.g. a partial
+// write from a crash) are logged and skipped, as in JSONLStore.
+func (l *UsageLedger) scan(fn func(UsageRecord)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.scanLocked(fn)
+}
+
+func (l *UsageLedger) scanLocked(fn func(UsageRecord)) error {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("memory: open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineNum++
+		if len(line) == 0 {
+			continue
+		}
+		var rec UsageRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("memory: skipping corrupt usage line %d: %v", lineNum, err)
+			continue
+		}
+		fn(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("memory: scan usage ledger: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestUsageLedger_SessionTotals(t *testing.T) {
+	ledger, err := NewUsageLedger(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	ctx := context.Background()
+
+	records := []UsageRecord{
+		NewUsageRecord("s1", "main", "gpt", &providers.UsageInfo{
+			PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15,
+			PromptTokensDetails: &providers.PromptTokensDetails{CachedTokens: 8},
+		}),
+		NewUsageRecord("s1", "main", "gpt", &providers.UsageInfo{PromptTokens: 20, CompletionTokens: 10}),
+		NewUsageRecord("s2", "main", "gpt", &providers.UsageInfo{PromptTokens: 100, CompletionTokens: 1}),
+	}
+	records[0].CostUSD = 0.5
+	for _, rec := range records {
+		if err := ledger.Record(ctx, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	totals, err := ledger.SessionTotals(ctx, "s1")
+	if err != nil {
+		t.Fatalf("SessionTotals: %v", err)
+	}
+	want := UsageTotals{
+		Requests: 2, PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45, CachedTokens: 8, CostUSD: 0.5,
+	}
+	if totals != want {
+		t.Errorf("SessionTotals = %+v, want %+v", totals, want)
+	}
+
+	empty, err := ledger.SessionTotals(ctx, "missing")
+	if err != nil {
+		t.Fatalf("SessionTotals(missing): %v", err)
+	}
+	if empty != (UsageTotals{}) {
+		t.Errorf("SessionTotals(missing) = %+v, want zero", empty)
+	}
+}
+
+func TestUsageLedger_Totals(t *testing.T) {
+	ledger, err := NewUsageLedger(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, rec := range []UsageRecord{
+		{Time: now.Add(-48 * time.Hour), SessionKey: "s1", TotalTokens: 1000, CostUSD: 1},
+		{Time: now.Add(-time.Minute), SessionKey: "s1", TotalTokens: 10, CostUSD: 0.25},
+		{Time: now, SessionKey: "s2", TotalTokens: 20, CostUSD: 0.5},
+	} {
+		if err := ledger.Record(ctx, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	since := now.Add(-time.Hour)
+	for _, tc := range []struct {
+		session string
+		tokens  int
+		cost    float64
+	}{
+		{"s1", 10, 0.25},
+		{"", 30, 0.75},
+	} {
+		totals, err := ledger.Totals(ctx, since, tc.session)
+		if err != nil {
+			t.Fatalf("Totals: %v", err)
+		}
+		if totals.TotalTokens != tc.tokens || totals.CostUSD != tc.cost {
+			t.Errorf("Totals(%q) = %+v, want %d tokens and $%.2f", tc.session, totals, tc.tokens, tc.cost)
+		}
+	}
+}
+
+func TestUsageLedger_DailyGroupsAndFilters(t *testing.T) {
+	ledger, err := NewUsageLedger(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	ctx := context.Background()
+
+	day1 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	for _, rec := range []UsageRecord{
+		{Time: day1, SessionKey: "a", TotalTokens: 10},
+		{Time: day2, SessionKey: "a", TotalTokens: 20},
+		{Time: day2.Add(time.Hour), SessionKey: "b", TotalTokens: 5},
+	} {
+		if err := ledger.Record(ctx, rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	all, err := ledger.Daily(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("Daily: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Daily len = %d, want 2", len(all))
+	}
+	if all[0].Date != "2026-03-01" || all[0].TotalTokens != 10 {
+		t.Errorf("day 1 = %+v", all[0])
+	}
+	if all[1].Date != "2026-03-02" || all[1].TotalTokens != 25 || all[1].Requests != 2 {
+		t.Errorf("day 2 = %+v", all[1])
+	}
+
+	recent, err := ledger.Daily(ctx, time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("Daily(since): %v", err)
+	}
+	if len(recent) != 1 || recent[0].Date != "2026-03-02" {
+		t.Errorf("Daily(since) = %+v, want only 2026-03-02", recent)
+	}
+}
+
+func TestUsageLedger_SkipsCorruptLines(t *testing.T) {
+	dir := t.TempDir()
+	ledger, err := NewUsageLedger(dir)
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	ctx := context.Background()
+	if err := ledger.Record(ctx, UsageRecord{SessionKey: "s", TotalTokens: 7}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, usageLedgerFile), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"session_key":"s","total_tok`)
+	f.Close()
+
+	totals, err := ledger.SessionTotals(ctx, "s")
+	if err != nil {
+		t.Fatalf("SessionTotals: %v", err)
+	}
+	if totals.TotalTokens != 7 || totals.Requests != 1 {
+		t.Errorf("SessionTotals = %+v, want one record with 7 tokens", totals)
+	}
+}
+
+func TestUsageLedger_Remove(t *testing.T) {
+	dir := t.TempDir()
+	ledger, err := NewUsageLedger(dir)
+	if err != nil {
+		t.Fatalf("NewUsageLedger: %v", err)
+	}
+	ctx := context.Background()
+	for _, key := range []string{"s1", "s2", "s1"} {
+		if err := ledger.Record(ctx, NewUsageRecord(key, "main", "gpt", &providers.UsageInfo{TotalTokens: 1})); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	n, err := ledger.Remove(ctx, func(rec UsageRecord) bool { return rec.SessionKey == "s1" })
+	if err != nil || n != 2 {
+		t.Fatalf("Remove = %d, %v, want 2", n, err)
+	}
+	ledger, _ = NewUsageLedger(dir)
+	records, err := ledger.Records(ctx)
+	if err != nil || len(records) != 1 || records[0].SessionKey != "s2" {
+		t.Errorf("Records after Remove = %+v, %v", records, err)
+	}
+}
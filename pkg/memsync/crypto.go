@@ -0,0 +1,85 @@
+package memsync
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// keySize is the size of the shared key and of the AES-256 key derived from
+// it.
+const keySize = 32
+
+// NewKey returns a random shared key, encoded for the sync.key setting.
+func NewKey() string {
+	return base64.StdEncoding.EncodeToString(randomBytes(keySize))
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b) // never fails, see crypto/rand.Read
+	return b
+}
+
+// ParseKey checks a sync.key setting.
+func ParseKey(key string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64: %w", err)
+	}
+	if len(b) != keySize {
+		return nil, fmt.Errorf("key has %d bytes, want %d; create one with picoclaw sync key", len(b), keySize)
+	}
+	return b, nil
+}
+
+// sealer encrypts and authenticates the messages of an exchange with
+// AES-256-GCM. Only instances that share the key can read them or make ones
+// the other side accepts.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(key string) (*sealer, error) {
+	secret, err := ParseKey(key)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := hkdf.Key(sha256.New, secret, nil, "picoclaw sync v1", keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal returns nonce || ciphertext. ad binds the message to its context, so
+// that a reply cannot be passed off as a request.
+func (s *sealer) seal(plaintext, ad []byte) []byte {
+	nonce := randomBytes(s.aead.NonceSize())
+	return s.aead.Seal(nonce, nonce, plaintext, ad)
+}
+
+func (s *sealer) open(sealed, ad []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("message too short")
+	}
+	plaintext, err := s.aead.Open(nil, sealed[:n], sealed[n:], ad)
+	if err != nil {
+		return nil, errors.New("message does not decrypt; do both sides use the same sync.key?")
+	}
+	return plaintext, nil
+}
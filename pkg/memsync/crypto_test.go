@@ -0,0 +1,29 @@
+package memsync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKey(t *testing.T) {
+	_, err := ParseKey(NewKey())
+	assert.NoError(t, err)
+	_, err = ParseKey("c2hvcnQ=")
+	assert.ErrorContains(t, err, "want 32")
+	_, err = ParseKey("not base64!")
+	assert.ErrorContains(t, err, "not base64")
+}
+
+func TestSealer(t *testing.T) {
+	s, err := newSealer(NewKey())
+	require.NoError(t, err)
+	sealed := s.seal([]byte("hello"), requestAD)
+
+	plain, err := s.open(sealed, requestAD)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(plain))
+	_, err = s.open(sealed, replyAD(sealed))
+	assert.Error(t, err, "a request opened as a reply")
+}
@@ -0,0 +1,187 @@
+package memsync
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Change is one entry of the change journal: a new version of an item, or
+// its deletion when Hash is empty.
+type Change struct {
+	Seq     uint64 `json:"seq"`
+	Kind    string `json:"kind"`
+	Key     string `json:"key"`
+	Hash    string `json:"hash,omitempty"`
+	Parent  string `json:"parent,omitempty"` // hash of the version this one replaced
+	Version int64  `json:"version"`          // Unix milliseconds when the change was made
+	Device  string `json:"device"`           // instance that made the change
+}
+
+func (c Change) deleted() bool { return c.Hash == "" }
+
+// newer reports whether c wins a conflict with o: the later change wins,
+// and the device ID breaks ties so both sides pick the same one.
+func (c Change) newer(o Change) bool {
+	if c.Version != o.Version {
+		return c.Version > o.Version
+	}
+	return c.Device > o.Device
+}
+
+type itemID struct{ kind, key string }
+
+// journal records the changes of all stores in a JSONL file, each with a
+// sequence number that only grows. Only the latest change of an item
+// matters for syncing, so the file is compacted once most of it is
+// superseded.
+type journal struct {
+	path   string
+	mu     sync.Mutex
+	latest map[itemID]Change
+	head   uint64
+	stale  int // lines of the file that a later change superseded
+}
+
+func openJournal(path string) (*journal, error) {
+	j := &journal{path: path, latest: make(map[itemID]Change)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("memsync: open journal: %w", err)
+	}
+	defer f.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		lines++
+		var c Change
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			logger.WarnCF("memsync", "Skipping corrupt journal line", map[string]any{"line": lines, "error": err.Error()})
+			continue
+		}
+		j.latest[itemID{c.Kind, c.Key}] = c
+		j.head = max(j.head, c.Seq)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("memsync: read journal: %w", err)
+	}
+	j.stale = lines - len(j.latest)
+	return j, nil
+}
+
+// compact rewrites the file with only the latest change of each item once
+// more than half of it, and at least minStaleLines, is superseded.
+func (j *journal) compact() error {
+	const minStaleLines = 100
+	if j.stale < max(len(j.latest), minStaleLines) {
+		return nil
+	}
+	var buf []byte
+	for _, c := range j.since(0, "") {
+		line, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := fileutil.WriteFileAtomic(j.path, buf, 0o600); err != nil {
+		return fmt.Errorf("memsync: compact journal: %w", err)
+	}
+	j.stale = 0
+	return nil
+}
+
+// record appends c with the next sequence number and returns it.
+func (j *journal) record(c Change) (Change, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	c.Seq = j.head + 1
+	line, err := json.Marshal(c)
+	if err != nil {
+		return Change{}, err
+	}
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return Change{}, fmt.Errorf("memsync: open journal: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return Change{}, fmt.Errorf("memsync: append to journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return Change{}, fmt.Errorf("memsync: close journal: %w", err)
+	}
+	j.head = c.Seq
+	if _, ok := j.latest[itemID{c.Kind, c.Key}]; ok {
+		j.stale++
+	}
+	j.latest[itemID{c.Kind, c.Key}] = c
+	return c, nil
+}
+
+// get returns the latest change of an item.
+func (j *journal) get(kind, key string) (Change, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	c, ok := j.latest[itemID{kind, key}]
+	return c, ok
+}
+
+// current returns the hash of the item as the journal knows it, "" for an
+// item that is deleted or was never seen.
+func (j *journal) current(kind, key string) string {
+	c, _ := j.get(kind, key)
+	return c.Hash
+}
+
+// live returns the keys of the items of kind that are not deleted.
+func (j *journal) live(kind string) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var keys []string
+	for id, c := range j.latest {
+		if id.kind == kind && !c.deleted() {
+			keys = append(keys, id.key)
+		}
+	}
+	return keys
+}
+
+// since returns the latest change of every item changed after seq, in
+// journal order, leaving out the items whose latest change came from
+// skipDevice: that device has them already.
+func (j *journal) since(seq uint64, skipDevice string) []Change {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var changes []Change
+	for _, c := range j.latest {
+		if c.Seq > seq && (skipDevice == "" || c.Device != skipDevice) {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool { return changes[a].Seq < changes[b].Seq })
+	return changes
+}
+
+func (j *journal) last() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.head
+}
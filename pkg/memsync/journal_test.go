@@ -0,0 +1,31 @@
+package memsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), journalFile)
+	j, err := openJournal(path)
+	require.NoError(t, err)
+	for i := range 150 {
+		_, err := j.record(Change{Kind: "fact", Key: "f1", Hash: fmt.Sprint(i), Device: "d"})
+		require.NoError(t, err)
+	}
+
+	j, err = openJournal(path)
+	require.NoError(t, err)
+	require.NoError(t, j.compact())
+	assert.Equal(t, uint64(150), j.last())
+	assert.Equal(t, "149", j.current("fact", "f1"))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+}
@@ -0,0 +1,548 @@
+// Package memsync keeps the sessions and facts of two picoclaw instances,
+// such as a laptop and a board at home, in sync.
+//
+// Each instance keeps a change journal in workspace/sync. Before every
+// exchange it compares the stores with the journal and records what changed
+// since, with the hash of the version each change replaced. One instance,
+// the one with a peer configured, then posts the changes the other has not
+// seen to the other's gateway and gets the other's changes back. Both
+// messages are sealed with AES-256-GCM under the shared sync key, so the
+// exchange is private and authenticated even over plain HTTP.
+//
+// A change whose parent is the version an instance has replaces it. When
+// both sides changed an item since they last synced, the later change wins
+// on both, and the other version is kept in workspace/sync/conflicts.
+package memsync
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// Path is where the gateway serves exchanges.
+	Path = "/sync/v1/exchange"
+
+	maxMessageBytes = 64 << 20
+	// maxClockSkew bounds how old a request may be, which limits replays.
+	maxClockSkew = 5 * time.Minute
+
+	journalFile   = "journal.jsonl"
+	peerFile      = "peer.json"
+	deviceFile    = "device"
+	conflictsDir  = "conflicts"
+	exchangeLimit = 2 * time.Minute
+)
+
+var requestAD = []byte("picoclaw sync request")
+
+// replyAD binds a reply to the request it answers.
+func replyAD(request []byte) []byte {
+	return append([]byte("picoclaw sync reply "), request[:min(len(request), 12)]...)
+}
+
+// item is a change with the data of the version it made.
+type item struct {
+	Change
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type request struct {
+	Device  string    `json:"device"`
+	Time    time.Time `json:"time"`
+	Since   uint64    `json:"since"` // last change of the receiver's journal the sender has
+	Changes []item    `json:"changes"`
+}
+
+type reply struct {
+	Device  string    `json:"device"`
+	Time    time.Time `json:"time"`
+	Head    uint64    `json:"head"` // the receiver's journal covers the changes up to here
+	Changes []item    `json:"changes"`
+}
+
+// peerState is what an instance knows about the other one.
+type peerState struct {
+	Device   string    `json:"device"`
+	Received uint64    `json:"received"` // the peer's changes up to here are applied
+	Sent     uint64    `json:"sent"`     // the peer has this journal's changes up to here
+	LastSync time.Time `json:"last_sync"`
+}
+
+// Result counts what an exchange did.
+type Result struct {
+	Sent      int
+	Received  int
+	Conflicts int
+}
+
+// Status describes the sync state of an instance.
+type Status struct {
+	Device    string
+	Peer      string // device ID of the peer, "" before the first exchange
+	LastSync  time.Time
+	Pending   int // recorded changes the peer has not got yet
+	Conflicts int // versions kept in the conflicts directory
+}
+
+// Syncer syncs the stores of one instance. It runs one exchange at a time,
+// whether it started it or serves it.
+type Syncer struct {
+	dir     string
+	device  string
+	sealer  *sealer
+	stores  []Store
+	journal *journal
+	client  *http.Client
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// New creates a Syncer for stores that keeps its state in dir. key is the
+// shared sync key.
+func New(dir, key string, stores ...Store) (*Syncer, error) {
+	sealer, err := newSealer(key)
+	if err != nil {
+		return nil, fmt.Errorf("memsync: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("memsync: create directory: %w", err)
+	}
+	device, err := deviceID(filepath.Join(dir, deviceFile))
+	if err != nil {
+		return nil, err
+	}
+	j, err := openJournal(filepath.Join(dir, journalFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{
+		dir:     dir,
+		device:  device,
+		sealer:  sealer,
+		stores:  stores,
+		journal: j,
+		client:  &http.Client{Timeout: exchangeLimit},
+		now:     time.Now,
+	}, nil
+}
+
+// deviceID returns the ID of this instance, created on first use.
+func deviceID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && len(bytes.TrimSpace(data)) > 0 {
+		return string(bytes.TrimSpace(data)), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("memsync: read device ID: %w", err)
+	}
+	id := hex.EncodeToString(randomBytes(8))
+	if err := fileutil.WriteFileAtomic(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("memsync: write device ID: %w", err)
+	}
+	return id, nil
+}
+
+// Device returns the ID of this instance.
+func (s *Syncer) Device() string {
+	return s.device
+}
+
+// Run exchanges with the gateway at peerURL right away and then every
+// interval until ctx is done. Failures are logged and retried at the next
+// interval.
+func (s *Syncer) Run(ctx context.Context, peerURL string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if res, err := s.Exchange(ctx, peerURL); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.WarnCF("memsync", "Sync failed", map[string]any{"peer": peerURL, "error": err.Error()})
+		} else if res != (Result{}) {
+			logger.InfoCF("memsync", "Synced",
+				map[string]any{"sent": res.Sent, "received": res.Received, "conflicts": res.Conflicts})
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Exchange sends the local changes to the gateway at peerURL and applies
+// the ones it sends back.
+func (s *Syncer) Exchange(ctx context.Context, peerURL string) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peer, err := s.loadPeer()
+	if err != nil {
+		return Result{}, err
+	}
+	res, rep, head, err := s.exchangeLocked(ctx, peerURL, peer)
+	if err != nil {
+		return res, err
+	}
+	if peer.Device != "" && rep.Device != peer.Device {
+		// A different instance answers, for example after a reinstall: the
+		// cursors mean nothing to it, so start over with everything.
+		logger.WarnCF("memsync", "The peer is a different instance now, syncing everything",
+			map[string]any{"was": peer.Device, "now": rep.Device})
+		peer = peerState{Device: rep.Device}
+		if res, rep, head, err = s.exchangeLocked(ctx, peerURL, peer); err != nil {
+			return res, err
+		}
+	}
+	return res, s.savePeer(peerState{Device: rep.Device, Received: rep.Head, Sent: head, LastSync: time.Now()})
+}
+
+// exchangeLocked runs one exchange and applies the reply. head is the last
+// change of the local journal that was sent.
+func (s *Syncer) exchangeLocked(ctx context.Context, peerURL string, peer peerState) (Result, reply, uint64, error) {
+	snap, err := s.scan()
+	if err != nil {
+		return Result{}, reply{}, 0, err
+	}
+	head := s.journal.last()
+	req := request{
+		Device:  s.device,
+		Time:    time.Now(),
+		Since:   peer.Received,
+		Changes: withData(s.journal.since(peer.Sent, peer.Device), snap),
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Result{}, reply{}, 0, err
+	}
+	sealed := s.sealer.seal(body, requestAD)
+
+	url := strings.TrimSuffix(peerURL, "/") + Path
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(sealed))
+	if err != nil {
+		return Result{}, reply{}, 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return Result{}, reply{}, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageBytes))
+	if err != nil {
+		return Result{}, reply{}, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, reply{}, 0, fmt.Errorf("peer answered %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	plain, err := s.sealer.open(data, replyAD(sealed))
+	if err != nil {
+		return Result{}, reply{}, 0, fmt.Errorf("reply: %w", err)
+	}
+	var rep reply
+	if err := json.Unmarshal(plain, &rep); err != nil {
+		return Result{}, reply{}, 0, fmt.Errorf("reply: %w", err)
+	}
+	if rep.Device == s.device {
+		return Result{}, reply{}, 0, errors.New("the peer is this instance")
+	}
+
+	conflicts, err := s.apply(rep.Changes, snap)
+	res := Result{Sent: len(req.Changes), Received: len(rep.Changes), Conflicts: conflicts}
+	return res, rep, head, err
+}
+
+// ServeHTTP answers the exchanges of the peer.
+func (s *Syncer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sealed, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	plain, err := s.sealer.open(sealed, requestAD)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var req request
+	if err := json.Unmarshal(plain, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if skew := time.Since(req.Time); skew > maxClockSkew || skew < -maxClockSkew {
+		http.Error(w, fmt.Sprintf("request is %s off this clock; check the clocks of both devices",
+			skew.Round(time.Second)), http.StatusForbidden)
+		return
+	}
+	if req.Device == s.device {
+		http.Error(w, "the peer is this instance", http.StatusBadRequest)
+		return
+	}
+
+	rep, res, err := s.serve(req)
+	if err != nil {
+		logger.WarnCF("memsync", "Sync failed", map[string]any{"peer": req.Device, "error": err.Error()})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := json.Marshal(rep)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res != (Result{}) {
+		logger.InfoCF("memsync", "Synced",
+			map[string]any{"peer": req.Device, "sent": res.Sent, "received": res.Received, "conflicts": res.Conflicts})
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(s.sealer.seal(body, replyAD(sealed)))
+}
+
+func (s *Syncer) serve(req request) (reply, Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, err := s.scan()
+	if err != nil {
+		return reply{}, Result{}, err
+	}
+	conflicts, err := s.apply(req.Changes, snap)
+	if err != nil {
+		return reply{}, Result{}, err
+	}
+	rep := reply{
+		Device:  s.device,
+		Time:    time.Now(),
+		Head:    s.journal.last(),
+		Changes: withData(s.journal.since(req.Since, req.Device), snap),
+	}
+	// The peer keeps its own cursors. Those here only matter when this side
+	// starts exchanges as well, and stay valid unless the peer changed.
+	peer, err := s.loadPeer()
+	if err != nil {
+		return reply{}, Result{}, err
+	}
+	if peer.Device != req.Device {
+		peer = peerState{Device: req.Device}
+	}
+	peer.LastSync = rep.Time
+	if err := s.savePeer(peer); err != nil {
+		return reply{}, Result{}, err
+	}
+	return rep, Result{Sent: len(rep.Changes), Received: len(req.Changes), Conflicts: conflicts}, nil
+}
+
+// snapshot holds the data of every item by kind and key.
+type snapshot map[string]map[string][]byte
+
+// scan records the changes of the stores since the last scan and returns
+// what they hold.
+func (s *Syncer) scan() (snapshot, error) {
+	if err := s.journal.compact(); err != nil {
+		return nil, err
+	}
+	snap := make(snapshot, len(s.stores))
+	now := s.now().UnixMilli()
+	for _, st := range s.stores {
+		kind := st.Kind()
+		items, err := st.Items()
+		if err != nil {
+			return nil, fmt.Errorf("memsync: read %ss: %w", kind, err)
+		}
+		snap[kind] = items
+
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			hash, cur := hashOf(items[key]), s.journal.current(kind, key)
+			if hash == cur {
+				continue
+			}
+			c := Change{Kind: kind, Key: key, Hash: hash, Parent: cur, Version: now, Device: s.device}
+			if _, err := s.journal.record(c); err != nil {
+				return nil, err
+			}
+		}
+		for _, key := range s.journal.live(kind) {
+			if _, ok := items[key]; ok {
+				continue
+			}
+			c := Change{Kind: kind, Key: key, Parent: s.journal.current(kind, key), Version: now, Device: s.device}
+			if _, err := s.journal.record(c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return snap, nil
+}
+
+// apply applies the changes of the peer and returns how many conflicted
+// with local ones.
+func (s *Syncer) apply(items []item, snap snapshot) (int, error) {
+	conflicts := 0
+	for _, it := range items {
+		st := s.store(it.Kind)
+		if st == nil {
+			continue // from a newer version that syncs more
+		}
+		if !it.deleted() && hashOf(it.Data) != it.Hash {
+			return conflicts, fmt.Errorf("memsync: %s %s: data does not match its hash", it.Kind, it.Key)
+		}
+		cur, known := s.journal.get(it.Kind, it.Key)
+		if cur.Hash == it.Hash {
+			continue
+		}
+		if known && cur.Hash != it.Parent {
+			// Both sides changed the item: keep the loser aside.
+			conflicts++
+			if cur.newer(it.Change) {
+				if err := s.keepConflict(it.Change, it.Data); err != nil {
+					return conflicts, err
+				}
+				continue
+			}
+			if err := s.keepConflict(cur, snap[it.Kind][it.Key]); err != nil {
+				return conflicts, err
+			}
+		}
+
+		var err error
+		if it.deleted() {
+			err = st.Delete(it.Key)
+		} else {
+			err = st.Put(it.Key, it.Data)
+		}
+		if err != nil {
+			return conflicts, fmt.Errorf("memsync: %s %s: %w", it.Kind, it.Key, err)
+		}
+		c := it.Change
+		c.Parent = cur.Hash
+		if _, err := s.journal.record(c); err != nil {
+			return conflicts, err
+		}
+	}
+	return conflicts, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// keepConflict saves the version of an item that lost a conflict.
+func (s *Syncer) keepConflict(c Change, data []byte) error {
+	if len(data) == 0 {
+		return nil // a deletion lost; there is nothing to keep
+	}
+	dir := filepath.Join(s.dir, conflictsDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("memsync: create conflicts directory: %w", err)
+	}
+	name := fmt.Sprintf("%s-%s-%s-%d.json", c.Kind, unsafeFileChars.ReplaceAllString(c.Key, "_"), c.Device, c.Version)
+	if err := fileutil.WriteFileAtomic(filepath.Join(dir, name), data, 0o600); err != nil {
+		return fmt.Errorf("memsync: keep conflicting %s: %w", c.Kind, err)
+	}
+	logger.WarnCF("memsync", "Conflicting change kept aside",
+		map[string]any{"kind": c.Kind, "key": c.Key, "file": name})
+	return nil
+}
+
+func (s *Syncer) store(kind string) Store {
+	for _, st := range s.stores {
+		if st.Kind() == kind {
+			return st
+		}
+	}
+	return nil
+}
+
+// withData attaches the data of the snapshot to the changes.
+func withData(changes []Change, snap snapshot) []item {
+	items := make([]item, 0, len(changes))
+	for _, c := range changes {
+		it := item{Change: c}
+		if !c.deleted() {
+			data, ok := snap[c.Kind][c.Key]
+			if !ok {
+				continue
+			}
+			it.Data = data
+		}
+		items = append(items, it)
+	}
+	return items
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Status returns the sync state, as of the last exchange.
+func (s *Syncer) Status() (Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peer, err := s.loadPeer()
+	if err != nil {
+		return Status{}, err
+	}
+	st := Status{Device: s.device, Peer: peer.Device, LastSync: peer.LastSync}
+	st.Pending = len(s.journal.since(peer.Sent, peer.Device))
+	entries, err := os.ReadDir(filepath.Join(s.dir, conflictsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return Status{}, err
+	}
+	st.Conflicts = len(entries)
+	return st, nil
+}
+
+func (s *Syncer) loadPeer() (peerState, error) {
+	var peer peerState
+	data, err := os.ReadFile(filepath.Join(s.dir, peerFile))
+	if os.IsNotExist(err) {
+		return peer, nil
+	}
+	if err != nil {
+		return peer, fmt.Errorf("memsync: read peer state: %w", err)
+	}
+	if err := json.Unmarshal(data, &peer); err != nil {
+		return peer, fmt.Errorf("memsync: read peer state: %w", err)
+	}
+	return peer, nil
+}
+
+func (s *Syncer) savePeer(peer peerState) error {
+	data, err := json.MarshalIndent(peer, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(filepath.Join(s.dir, peerFile), data, 0o600); err != nil {
+		return fmt.Errorf("memsync: write peer state: %w", err)
+	}
+	return nil
+}
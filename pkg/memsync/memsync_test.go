@@ -0,0 +1,154 @@
+package memsync
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// instance is one side of a sync with its own workspace.
+type instance struct {
+	dir      string
+	sessions *session.SessionManager
+	facts    *memory.FactStore
+	syncer   *Syncer
+	clock    time.Time
+}
+
+func newInstance(t *testing.T, key string) *instance {
+	t.Helper()
+	in := &instance{dir: t.TempDir(), clock: time.UnixMilli(1_000_000)}
+	in.sessions = session.NewSessionManager(filepath.Join(in.dir, "sessions"))
+	facts, err := memory.NewFactStore(filepath.Join(in.dir, "memory"))
+	require.NoError(t, err)
+	in.facts = facts
+	in.syncer, err = New(filepath.Join(in.dir, "sync"), key, Sessions(in.sessions), Facts(in.facts))
+	require.NoError(t, err)
+	in.syncer.now = func() time.Time { return in.clock }
+	return in
+}
+
+// pair returns a laptop that syncs with a board serving over HTTP.
+func pair(t *testing.T) (laptop, board *instance, url string) {
+	t.Helper()
+	key := NewKey()
+	laptop, board = newInstance(t, key), newInstance(t, key)
+	srv := httptest.NewServer(board.syncer)
+	t.Cleanup(srv.Close)
+	return laptop, board, srv.URL
+}
+
+func (in *instance) tick() { in.clock = in.clock.Add(time.Second) }
+
+func TestExchange(t *testing.T) {
+	laptop, board, url := pair(t)
+	ctx := context.Background()
+
+	laptop.sessions.AddMessage("telegram:1", "user", "hello from the laptop")
+	require.NoError(t, laptop.sessions.Save("telegram:1"))
+	fact, err := board.facts.Save(ctx, "Anna loves tulips", nil, "")
+	require.NoError(t, err)
+
+	res, err := laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Sent: 1, Received: 1}, res)
+	assert.Equal(t, "hello from the laptop", board.sessions.GetHistory("telegram:1")[0].Content)
+	facts, err := laptop.facts.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, facts, 1)
+	assert.Equal(t, fact.ID, facts[0].ID)
+
+	// Nothing changed: nothing goes back and forth, not even the echoes.
+	res, err = laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, Result{}, res)
+
+	// A change on one side replaces the other's version.
+	board.tick()
+	board.sessions.AddMessage("telegram:1", "assistant", "hi from the board")
+	require.NoError(t, board.sessions.Save("telegram:1"))
+	_, err = laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+	assert.Len(t, laptop.sessions.GetHistory("telegram:1"), 2)
+
+	// Deletions travel too.
+	_, err = laptop.facts.Forget(ctx, fact.ID)
+	require.NoError(t, err)
+	_, err = laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+	facts, err = board.facts.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, facts)
+
+	st, err := laptop.syncer.Status()
+	require.NoError(t, err)
+	assert.Equal(t, board.syncer.Device(), st.Peer)
+	assert.Zero(t, st.Pending)
+}
+
+func TestExchange_Conflict(t *testing.T) {
+	laptop, board, url := pair(t)
+	ctx := context.Background()
+
+	laptop.sessions.AddMessage("telegram:1", "user", "hello")
+	_, err := laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+
+	// Both sides change the session; the board does so later and wins.
+	laptop.tick()
+	laptop.sessions.SetSummary("telegram:1", "laptop summary")
+	board.tick()
+	board.tick()
+	board.sessions.SetSummary("telegram:1", "board summary")
+
+	res, err := laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Conflicts)
+	assert.Equal(t, "board summary", laptop.sessions.GetSummary("telegram:1"))
+	assert.Equal(t, "board summary", board.sessions.GetSummary("telegram:1"))
+
+	for _, in := range []*instance{laptop, board} {
+		entries, err := os.ReadDir(filepath.Join(in.dir, "sync", conflictsDir))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		data, err := os.ReadFile(filepath.Join(in.dir, "sync", conflictsDir, entries[0].Name()))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "laptop summary")
+	}
+
+	// Settled: the next exchange has nothing to do.
+	res, err = laptop.syncer.Exchange(ctx, url)
+	require.NoError(t, err)
+	assert.Equal(t, Result{}, res)
+}
+
+func TestExchange_WrongKey(t *testing.T) {
+	_, board, url := pair(t)
+	stranger := newInstance(t, NewKey())
+
+	_, err := stranger.syncer.Exchange(context.Background(), url)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+	assert.Empty(t, board.sessions.Keys())
+}
+
+func TestServeHTTP_RejectsOldRequests(t *testing.T) {
+	_, board, _ := pair(t)
+
+	// A request sealed long ago, or by a device whose clock is far off.
+	body := board.syncer.sealer.seal([]byte(`{"device":"x","time":"2020-01-01T00:00:00Z"}`), requestAD)
+	rec := httptest.NewRecorder()
+	board.syncer.ServeHTTP(rec, httptest.NewRequest("POST", Path, strings.NewReader(string(body))))
+	assert.Equal(t, 403, rec.Code)
+	assert.Contains(t, rec.Body.String(), "check the clocks")
+}
@@ -0,0 +1,111 @@
+package memsync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// Store is a collection of items that is kept in sync, such as the sessions
+// or the facts. Items are identified by key within the store's kind and
+// exchanged as JSON.
+type Store interface {
+	Kind() string
+	Items() (map[string][]byte, error)
+	Put(key string, data []byte) error
+	Delete(key string) error
+}
+
+// Sessions makes the sessions of sm a Store.
+func Sessions(sm *session.SessionManager) Store {
+	return sessionStore{sm}
+}
+
+type sessionStore struct {
+	sm *session.SessionManager
+}
+
+func (sessionStore) Kind() string { return "session" }
+
+func (s sessionStore) Items() (map[string][]byte, error) {
+	items := make(map[string][]byte)
+	for _, key := range s.sm.Keys() {
+		snap, ok := s.sm.Snapshot(key)
+		if !ok {
+			continue
+		}
+		// Save writes an empty history as [], so the copy must match.
+		if snap.Messages == nil {
+			snap.Messages = []providers.Message{}
+		}
+		data, err := json.Marshal(snap)
+		if err != nil {
+			return nil, fmt.Errorf("session %s: %w", key, err)
+		}
+		items[key] = data
+	}
+	return items, nil
+}
+
+func (s sessionStore) Put(key string, data []byte) error {
+	var snap session.Session
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if snap.Key != key {
+		return fmt.Errorf("session data is for %q", snap.Key)
+	}
+	return s.sm.Put(snap)
+}
+
+func (s sessionStore) Delete(key string) error {
+	_, err := s.sm.Delete(key)
+	return err
+}
+
+// Facts makes the facts of fs a Store, across all people.
+func Facts(fs *memory.FactStore) Store {
+	return factStore{fs}
+}
+
+type factStore struct {
+	fs *memory.FactStore
+}
+
+func (factStore) Kind() string { return "fact" }
+
+func (s factStore) Items() (map[string][]byte, error) {
+	facts, err := s.fs.List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string][]byte, len(facts))
+	for _, f := range facts {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return nil, fmt.Errorf("fact %s: %w", f.ID, err)
+		}
+		items[f.ID] = data
+	}
+	return items, nil
+}
+
+func (s factStore) Put(key string, data []byte) error {
+	var f memory.Fact
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	if f.ID != key {
+		return fmt.Errorf("fact data is for %q", f.ID)
+	}
+	return s.fs.Put(context.Background(), f)
+}
+
+func (s factStore) Delete(key string) error {
+	_, err := s.fs.Forget(context.Background(), key)
+	return err
+}
@@ -0,0 +1,248 @@
+// Package notify pushes notifications to the sinks configured under
+// "notifications": chat channels, ntfy, Pushover and webhooks. The notify
+// tool and the heartbeat send through one Router, which picks the sinks by
+// priority and drops repeats of a notification sent shortly before.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/outbox"
+)
+
+// Priority is how urgent a notification is. The zero value is normal.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"low", "normal", "high", "urgent"}
+
+// ParsePriority parses "low", "normal", "high" or "urgent". The empty
+// string is normal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for i, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return Priority(i) + PriorityLow, nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("unknown priority %q (want low, normal, high or urgent)", s)
+}
+
+func (p Priority) String() string {
+	if p < PriorityLow || p > PriorityUrgent {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p-PriorityLow]
+}
+
+// Notification is a message to push.
+type Notification struct {
+	Title    string
+	Message  string
+	Priority Priority
+	// Template names a configured template, which is rendered with Data to
+	// make the message.
+	Template string
+	Data     map[string]any
+	// Key identifies the notification for deduplication. It defaults to the
+	// title and message.
+	Key string
+}
+
+// Result reports what happened to a notification.
+type Result struct {
+	Sent      []string // names of the sinks that got it
+	Duplicate bool     // it was sent shortly before and was dropped
+}
+
+// sink delivers a rendered notification to one destination.
+type sink interface {
+	send(ctx context.Context, n Notification) error
+}
+
+type routedSink struct {
+	name string
+	min  Priority
+	sink sink
+}
+
+type sentRecord struct {
+	at       time.Time
+	priority Priority
+}
+
+// Router sends notifications to the configured sinks.
+type Router struct {
+	sinks     []routedSink
+	templates *template.Template
+	window    time.Duration
+	heartbeat bool
+	now       func() time.Time
+
+	mu   sync.Mutex
+	sent map[string]sentRecord // dedup key → last delivery
+}
+
+// NewRouter creates a router for cfg. Chat channel sinks publish to
+// msgBus.
+func NewRouter(cfg config.NotificationsConfig, msgBus *bus.MessageBus) (*Router, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Router{
+		templates: template.New("notifications").Option("missingkey=zero"),
+		window:    time.Duration(cfg.DedupMinutes) * time.Minute,
+		heartbeat: cfg.Heartbeat,
+		now:       time.Now,
+		sent:      make(map[string]sentRecord),
+	}
+	for name, text := range cfg.Templates {
+		if _, err := r.templates.New(name).Parse(text); err != nil {
+			return nil, fmt.Errorf("template %q: %w", name, err)
+		}
+	}
+	for _, s := range cfg.Sinks {
+		minPriority := PriorityLow
+		if s.MinPriority != "" {
+			minPriority, _ = ParsePriority(s.MinPriority) // checked by Validate
+		}
+		r.sinks = append(r.sinks, routedSink{name: s.Name, min: minPriority, sink: newSink(s, msgBus)})
+	}
+	return r, nil
+}
+
+func newSink(cfg config.NotificationSink, msgBus *bus.MessageBus) sink {
+	switch cfg.Type {
+	case "ntfy":
+		return &ntfySink{url: cfg.URL, token: cfg.Token, client: httpClient}
+	case "pushover":
+		return &pushoverSink{apiURL: pushoverURL, token: cfg.Token, user: cfg.User, client: httpClient}
+	case "webhook":
+		return &webhookSink{url: cfg.URL, headers: cfg.Headers, client: httpClient}
+	default:
+		return &chatSink{bus: msgBus, channel: cfg.Type, chatID: cfg.ChatID}
+	}
+}
+
+// SetOutbox makes the webhook sinks queue their calls in q.
+func (r *Router) SetOutbox(q *outbox.Queue) {
+	for _, s := range r.sinks {
+		if hook, ok := s.sink.(*webhookSink); ok {
+			hook.outbox = q
+		}
+	}
+}
+
+// Templates returns the names of the configured templates.
+func (r *Router) Templates() []string {
+	var names []string
+	for _, t := range r.templates.Templates() {
+		if t.Name() != r.templates.Name() {
+			names = append(names, t.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Heartbeat reports whether heartbeat results should be sent as
+// notifications.
+func (r *Router) Heartbeat() bool {
+	return r.heartbeat
+}
+
+// Notify renders n and sends it to every sink its priority reaches. It
+// fails only if no sink got the notification; the error then joins the
+// errors of all sinks.
+func (r *Router) Notify(ctx context.Context, n Notification) (Result, error) {
+	if n.Template != "" {
+		var sb strings.Builder
+		if err := r.templates.ExecuteTemplate(&sb, n.Template, n.Data); err != nil {
+			return Result{}, fmt.Errorf("template %q: %w", n.Template, err)
+		}
+		n.Message = sb.String()
+	}
+	n.Title, n.Message = strings.TrimSpace(n.Title), strings.TrimSpace(n.Message)
+	if n.Message == "" {
+		return Result{}, errors.New("notification has no message")
+	}
+	key := n.Key
+	if key == "" {
+		key = n.Title + "\n" + n.Message
+	}
+	if r.duplicate(key, n.Priority) {
+		logger.DebugCF("notify", "Dropped duplicate notification", map[string]any{"key": key})
+		return Result{Duplicate: true}, nil
+	}
+
+	var res Result
+	var errs []error
+	for _, s := range r.sinks {
+		if n.Priority < s.min {
+			continue
+		}
+		if err := s.sink.send(ctx, n); err != nil {
+			logger.WarnCF("notify", "Notification not delivered", map[string]any{
+				"sink":  s.name,
+				"error": err.Error(),
+			})
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		res.Sent = append(res.Sent, s.name)
+	}
+	if len(res.Sent) == 0 {
+		if len(errs) == 0 {
+			return res, fmt.Errorf("no sink takes %s notifications", n.Priority)
+		}
+		return res, errors.Join(errs...)
+	}
+	r.record(key, n.Priority)
+	logger.InfoCF("notify", "Notification sent", map[string]any{
+		"priority": n.Priority.String(),
+		"sinks":    res.Sent,
+	})
+	return res, nil
+}
+
+// duplicate reports whether key was sent within the dedup window at the
+// same or a higher priority.
+func (r *Router) duplicate(key string, p Priority) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for k, rec := range r.sent {
+		if now.Sub(rec.at) >= r.window {
+			delete(r.sent, k)
+		}
+	}
+	rec, ok := r.sent[key]
+	return ok && p <= rec.priority
+}
+
+func (r *Router) record(key string, p Priority) {
+	if r.window <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent[key] = sentRecord{at: r.now(), priority: p}
+}
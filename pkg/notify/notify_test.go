@@ -0,0 +1,270 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/outbox"
+)
+
+// recordingSink records what it gets and fails when err is set.
+type recordingSink struct {
+	mu  sync.Mutex
+	got []Notification
+	err error
+}
+
+func (s *recordingSink) send(_ context.Context, n Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.got = append(s.got, n)
+	return nil
+}
+
+func newTestRouter(t *testing.T, cfg config.NotificationsConfig, sinks ...*recordingSink) *Router {
+	t.Helper()
+	r, err := NewRouter(cfg, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range sinks {
+		r.sinks[i].sink = s
+	}
+	return r
+}
+
+func TestRouter_PriorityRouting(t *testing.T) {
+	phone, chat := &recordingSink{}, &recordingSink{}
+	r := newTestRouter(t, config.NotificationsConfig{
+		Sinks: []config.NotificationSink{
+			{Name: "phone", Type: "ntfy", URL: "http://ntfy.invalid/t"},
+			{Name: "chat", Type: "telegram", ChatID: "42", MinPriority: "high"},
+		},
+	}, phone, chat)
+
+	res, err := r.Notify(context.Background(), Notification{Message: "backup done", Priority: PriorityNormal})
+	if err != nil || len(res.Sent) != 1 || res.Sent[0] != "phone" {
+		t.Fatalf("normal: %+v, %v", res, err)
+	}
+	res, err = r.Notify(context.Background(), Notification{Message: "disk full", Priority: PriorityUrgent})
+	if err != nil || len(res.Sent) != 2 {
+		t.Fatalf("urgent: %+v, %v", res, err)
+	}
+	if len(chat.got) != 1 || chat.got[0].Message != "disk full" {
+		t.Errorf("chat got %+v", chat.got)
+	}
+
+	if _, err := r.Notify(context.Background(), Notification{Message: "  "}); err == nil {
+		t.Error("empty notification was sent")
+	}
+}
+
+func TestRouter_Dedup(t *testing.T) {
+	s := &recordingSink{}
+	r := newTestRouter(t, config.NotificationsConfig{
+		DedupMinutes: 10,
+		Sinks:        []config.NotificationSink{{Name: "phone", Type: "ntfy", URL: "http://ntfy.invalid/t"}},
+	}, s)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	ctx := context.Background()
+	n := Notification{Title: "Disk", Message: "/data is 95% full", Priority: PriorityHigh}
+
+	if res, _ := r.Notify(ctx, n); res.Duplicate {
+		t.Fatal("first notification dropped")
+	}
+	now = now.Add(5 * time.Minute)
+	if res, _ := r.Notify(ctx, n); !res.Duplicate {
+		t.Error("repeat within the window was sent")
+	}
+	// A rise in priority goes through.
+	n.Priority = PriorityUrgent
+	if res, _ := r.Notify(ctx, n); res.Duplicate {
+		t.Error("escalated notification dropped")
+	}
+	now = now.Add(11 * time.Minute)
+	if res, _ := r.Notify(ctx, n); res.Duplicate {
+		t.Error("repeat after the window dropped")
+	}
+	if len(s.got) != 3 {
+		t.Errorf("sink got %d notifications, want 3", len(s.got))
+	}
+
+	// A failed delivery is not remembered, so it can be retried.
+	s.err = errors.New("offline")
+	other := Notification{Message: "fan stopped"}
+	if _, err := r.Notify(ctx, other); err == nil || !strings.Contains(err.Error(), "phone: offline") {
+		t.Errorf("err = %v", err)
+	}
+	s.err = nil
+	if res, err := r.Notify(ctx, other); err != nil || res.Duplicate {
+		t.Errorf("retry: %+v, %v", res, err)
+	}
+}
+
+func TestRouter_Templates(t *testing.T) {
+	s := &recordingSink{}
+	r := newTestRouter(t, config.NotificationsConfig{
+		Templates: map[string]string{"disk_full": "Disk {{.mount}} is {{.percent}}% full"},
+		Sinks:     []config.NotificationSink{{Name: "hook", Type: "webhook", URL: "http://hook.invalid"}},
+	}, s)
+	if names := r.Templates(); len(names) != 1 || names[0] != "disk_full" {
+		t.Errorf("Templates() = %v", names)
+	}
+	data := map[string]any{"mount": "/data", "percent": 95}
+	if _, err := r.Notify(context.Background(), Notification{Template: "disk_full", Data: data}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.got) != 1 || s.got[0].Message != "Disk /data is 95% full" {
+		t.Errorf("got %+v", s.got)
+	}
+	if _, err := r.Notify(context.Background(), Notification{Template: "missing"}); err == nil {
+		t.Error("unknown template accepted")
+	}
+
+	_, err := NewRouter(config.NotificationsConfig{Templates: map[string]string{"bad": "{{.x"}}, nil)
+	if err == nil {
+		t.Error("invalid template accepted")
+	}
+}
+
+type capturedRequest struct {
+	header http.Header
+	body   string
+}
+
+func capture(t *testing.T) (*httptest.Server, chan capturedRequest) {
+	t.Helper()
+	reqs := make(chan capturedRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- capturedRequest{r.Header, string(body)}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, reqs
+}
+
+func TestSinks(t *testing.T) {
+	ctx := context.Background()
+	n := Notification{Title: "Disk", Message: "/data is full", Priority: PriorityUrgent}
+
+	srv, reqs := capture(t)
+	ntfy := &ntfySink{url: srv.URL, token: "tk", client: srv.Client()}
+	if err := ntfy.send(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	req := <-reqs
+	if req.body != "/data is full" || req.header.Get("Title") != "Disk" || req.header.Get("Priority") != "5" ||
+		req.header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("ntfy request = %+v", req)
+	}
+
+	po := &pushoverSink{apiURL: srv.URL, token: "app", user: "u1", client: srv.Client()}
+	if err := po.send(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	form, _ := url.ParseQuery((<-reqs).body)
+	if form.Get("token") != "app" || form.Get("user") != "u1" || form.Get("priority") != "2" ||
+		form.Get("retry") == "" || form.Get("title") != "Disk" {
+		t.Errorf("pushover form = %v", form)
+	}
+
+	hook := &webhookSink{url: srv.URL, headers: map[string]string{"X-Key": "k"}, client: srv.Client()}
+	if err := hook.send(ctx, n); err != nil {
+		t.Fatal(err)
+	}
+	req = <-reqs
+	var payload webhookPayload
+	json.Unmarshal([]byte(req.body), &payload)
+	if payload.Message != "/data is full" || payload.Priority != "urgent" || req.header.Get("X-Key") != "k" {
+		t.Errorf("webhook request = %+v", req)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	ntfy.url = failing.URL
+	if err := ntfy.send(ctx, n); err == nil || !strings.Contains(err.Error(), "bad token") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRouter_WebhookOutbox(t *testing.T) {
+	srv, reqs := capture(t)
+	r, err := NewRouter(config.NotificationsConfig{
+		Sinks: []config.NotificationSink{
+			{Name: "hook", Type: "webhook", URL: srv.URL, Headers: map[string]string{"X-Key": "k"}},
+		},
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := outbox.Open(t.TempDir(), outbox.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetOutbox(q)
+
+	if res, err := r.Notify(context.Background(), Notification{Message: "backup done"}); err != nil || len(res.Sent) != 1 {
+		t.Fatalf("Notify() = %+v, %v", res, err)
+	}
+	if q.Len() != 1 {
+		t.Fatalf("outbox holds %d calls, want 1", q.Len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+	select {
+	case req := <-reqs:
+		var payload webhookPayload
+		json.Unmarshal([]byte(req.body), &payload)
+		if payload.Message != "backup done" || req.header.Get("X-Key") != "k" ||
+			req.header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook request = %+v", req)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the queued call was not made")
+	}
+}
+
+func TestChatSink(t *testing.T) {
+	msgBus := bus.NewMessageBus()
+	s := &chatSink{bus: msgBus, channel: "telegram", chatID: "42"}
+	if err := s.send(context.Background(), Notification{Title: "Disk", Message: "full", Priority: PriorityHigh}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || msg.Channel != "telegram" || msg.ChatID != "42" || msg.Content != "❗ Disk\n\nfull" {
+		t.Errorf("outbound = %+v", msg)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	if p, err := ParsePriority(""); err != nil || p != PriorityNormal {
+		t.Errorf("ParsePriority(\"\") = %v, %v", p, err)
+	}
+	if p, err := ParsePriority("URGENT"); err != nil || p != PriorityUrgent {
+		t.Errorf("ParsePriority(URGENT) = %v, %v", p, err)
+	}
+	if _, err := ParsePriority("critical"); err == nil {
+		t.Error("unknown priority accepted")
+	}
+}
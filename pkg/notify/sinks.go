@@ -0,0 +1,170 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/outbox"
+)
+
+const pushoverURL = "https://api.pushover.net/1/messages.json"
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// chatSink delivers to a chat through its channel, like the message tool.
+type chatSink struct {
+	bus     *bus.MessageBus
+	channel string
+	chatID  string
+}
+
+func (s *chatSink) send(ctx context.Context, n Notification) error {
+	text := n.Message
+	if n.Title != "" {
+		text = n.Title + "\n\n" + text
+	}
+	switch n.Priority {
+	case PriorityHigh:
+		text = "❗ " + text
+	case PriorityUrgent:
+		text = "🚨 " + text
+	}
+	return s.bus.PublishOutbound(ctx, bus.OutboundMessage{
+		Channel: s.channel,
+		ChatID:  s.chatID,
+		Content: text,
+	})
+}
+
+// ntfySink publishes to an ntfy topic (https://ntfy.sh or self-hosted).
+type ntfySink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// ntfyPriorities maps priorities to ntfy's 1 (min) to 5 (max) scale.
+var ntfyPriorities = map[Priority]string{
+	PriorityLow:    "2",
+	PriorityNormal: "3",
+	PriorityHigh:   "4",
+	PriorityUrgent: "5",
+}
+
+func (s *ntfySink) send(ctx context.Context, n Notification) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, strings.NewReader(n.Message))
+	if err != nil {
+		return err
+	}
+	if n.Title != "" {
+		req.Header.Set("Title", n.Title)
+	}
+	req.Header.Set("Priority", ntfyPriorities[n.Priority])
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return do(s.client, req)
+}
+
+// pushoverSink sends through the Pushover API.
+type pushoverSink struct {
+	apiURL string
+	token  string
+	user   string
+	client *http.Client
+}
+
+func (s *pushoverSink) send(ctx context.Context, n Notification) error {
+	form := url.Values{
+		"token":   {s.token},
+		"user":    {s.user},
+		"message": {n.Message},
+		// Our priorities are Pushover's -1 (quiet) to 2 (emergency).
+		"priority": {strconv.Itoa(int(n.Priority))},
+	}
+	if n.Title != "" {
+		form.Set("title", n.Title)
+	}
+	if n.Priority == PriorityUrgent {
+		// Emergency messages repeat every retry seconds until acknowledged,
+		// for at most expire seconds.
+		form.Set("retry", "60")
+		form.Set("expire", "3600")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(s.client, req)
+}
+
+// webhookSink POSTs the notification as JSON. With an outbox the call is
+// queued there, to be retried until the endpoint takes it.
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	outbox  *outbox.Queue
+}
+
+type webhookPayload struct {
+	Title    string `json:"title,omitempty"`
+	Message  string `json:"message"`
+	Priority string `json:"priority"`
+	Time     string `json:"time"`
+}
+
+func (s *webhookSink) send(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		Title:    n.Title,
+		Message:  n.Message,
+		Priority: n.Priority.String(),
+		Time:     time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if s.outbox != nil {
+		header := map[string]string{"Content-Type": "application/json"}
+		for k, v := range s.headers {
+			header[k] = v
+		}
+		_, err := s.outbox.EnqueueRequest(outbox.Request{
+			Method: http.MethodPost, URL: s.url, Header: header, Body: string(body),
+		})
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	return do(s.client, req)
+}
+
+// do sends req and turns a non-2xx answer into an error.
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
@@ -0,0 +1,382 @@
+// Package outbox keeps outbound actions, such as channel messages and
+// webhook calls, on disk until they are delivered. A flaky network or a
+// restart then delays a reply instead of losing it.
+//
+// Each action is one file in the pending directory, removed once it is
+// delivered. Actions with the same key (a chat, a webhook URL) are
+// delivered one at a time in the order they were queued; a failed one is
+// retried with exponential backoff and holds back the ones behind it.
+// Actions that fail permanently or for longer than the maximum age are
+// moved to the failed directory. Delivery is at least once: an action cut
+// short by a restart is sent again.
+package outbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	pendingDir = "pending"
+	failedDir  = "failed"
+
+	defaultMaxAge      = 24 * time.Hour
+	defaultBaseBackoff = 2 * time.Second
+	defaultMaxBackoff  = 5 * time.Minute
+)
+
+// Entry is a queued action.
+type Entry struct {
+	ID        string          `json:"id"`
+	Seq       uint64          `json:"seq"`
+	Kind      string          `json:"kind"`
+	Key       string          `json:"key"`
+	Payload   json.RawMessage `json:"payload"`
+	Created   time.Time       `json:"created"`
+	Attempts  int             `json:"attempts,omitempty"`
+	LastError string          `json:"last_error,omitempty"`
+}
+
+// Handler delivers the entries of one kind. An error wrapped with
+// Permanent is not retried.
+type Handler func(ctx context.Context, e Entry) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure that retrying cannot fix.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var pe permanentError
+	return errors.As(err, &pe)
+}
+
+// Options tunes a queue. Zero values take the defaults.
+type Options struct {
+	// RateLimit is the number of deliveries per second across the queue;
+	// zero for no limit.
+	RateLimit float64
+	// MaxAge is how long an action is retried before it is given up
+	// (default 24 hours).
+	MaxAge time.Duration
+	// BaseBackoff and MaxBackoff bound the wait between the attempts at
+	// an action (default 2 seconds, doubling up to 5 minutes).
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Queue is a durable outbox. Register the handlers, then call Run to
+// deliver; Enqueue may be called at any time.
+type Queue struct {
+	dir     string
+	opts    Options
+	limiter *rate.Limiter
+	now     func() time.Time
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	seq      uint64
+	pending  map[string][]*Entry // key → entries in queue order
+	ids      map[string]bool     // IDs of the pending entries
+	active   map[string]bool     // keys being delivered
+	ctx      context.Context     // set while Run runs
+	wg       sync.WaitGroup
+}
+
+// Open opens the queue in dir, loading the entries left pending by a
+// previous run. A handler for KindWebhook is registered.
+func Open(dir string, opts Options) (*Queue, error) {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultMaxAge
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = defaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	q := &Queue{
+		dir:      dir,
+		opts:     opts,
+		now:      time.Now,
+		handlers: make(map[string]Handler),
+		pending:  make(map[string][]*Entry),
+		ids:      make(map[string]bool),
+		active:   make(map[string]bool),
+	}
+	if opts.RateLimit > 0 {
+		q.limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), max(1, int(opts.RateLimit)))
+	}
+	if err := os.MkdirAll(filepath.Join(dir, pendingDir), 0o700); err != nil {
+		return nil, fmt.Errorf("create outbox: %w", err)
+	}
+	entries, err := readEntries(filepath.Join(dir, pendingDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		q.pending[e.Key] = append(q.pending[e.Key], e)
+		q.ids[e.ID] = true
+		q.seq = max(q.seq, e.Seq)
+	}
+	if failed, err := readEntries(filepath.Join(dir, failedDir)); err == nil {
+		for _, e := range failed {
+			q.seq = max(q.seq, e.Seq)
+		}
+	}
+	q.Handle(KindWebhook, webhookHandler(webhookClient))
+	return q, nil
+}
+
+// Handle registers the handler of kind.
+func (q *Queue) Handle(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue queues an action of kind with payload for key. id identifies the
+// action; while one with the same ID is pending, another is dropped and
+// Enqueue reports false. An empty id is derived from kind, key and payload,
+// so the same action queued twice is delivered once.
+func (q *Queue) Enqueue(kind, key, id string, payload any) (bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("marshal payload: %w", err)
+	}
+	if id == "" {
+		sum := sha256.Sum256([]byte(kind + "\x00" + key + "\x00" + string(data)))
+		id = hex.EncodeToString(sum[:8])
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ids[id] {
+		logger.DebugCF("outbox", "Dropped duplicate action", map[string]any{"kind": kind, "key": key, "id": id})
+		return false, nil
+	}
+	e := &Entry{ID: id, Seq: q.seq + 1, Kind: kind, Key: key, Payload: data, Created: q.now()}
+	if err := q.write(pendingDir, e); err != nil {
+		return false, err
+	}
+	q.seq = e.Seq
+	q.pending[key] = append(q.pending[key], e)
+	q.ids[id] = true
+	q.startLocked(key)
+	return true, nil
+}
+
+// Len returns the number of pending actions.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ids)
+}
+
+// Run delivers the pending actions, and those queued later, until ctx is
+// done. It returns once the deliveries in progress have stopped; what was
+// not delivered stays pending for the next run.
+func (q *Queue) Run(ctx context.Context) {
+	q.mu.Lock()
+	q.ctx = ctx
+	for key := range q.pending {
+		q.startLocked(key)
+	}
+	q.mu.Unlock()
+
+	<-ctx.Done()
+	q.mu.Lock()
+	q.ctx = nil
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+// startLocked starts delivering the entries of key unless that is already
+// going on or the queue is not running.
+func (q *Queue) startLocked(key string) {
+	if q.ctx == nil || q.active[key] || len(q.pending[key]) == 0 {
+		return
+	}
+	q.active[key] = true
+	q.wg.Add(1)
+	go q.deliver(q.ctx, key)
+}
+
+// deliver works through the entries of key in order.
+func (q *Queue) deliver(ctx context.Context, key string) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		if ctx.Err() != nil || len(q.pending[key]) == 0 {
+			delete(q.active, key)
+			q.mu.Unlock()
+			return
+		}
+		e := q.pending[key][0]
+		h := q.handlers[e.Kind]
+		q.mu.Unlock()
+
+		var err error
+		switch {
+		case h == nil:
+			err = Permanent(fmt.Errorf("no handler for %q actions", e.Kind))
+		case q.limiter != nil && q.limiter.Wait(ctx) != nil:
+			continue // ctx is done
+		default:
+			err = h(ctx, *e)
+		}
+		if err != nil && ctx.Err() != nil {
+			continue // cut short by the shutdown; tried again next run
+		}
+
+		switch {
+		case err == nil:
+			q.remove(e, "")
+		case IsPermanent(err) || q.now().Sub(e.Created) >= q.opts.MaxAge:
+			logger.ErrorCF("outbox", "Giving up on action", map[string]any{
+				"kind": e.Kind, "key": e.Key, "attempts": e.Attempts + 1, "error": err.Error(),
+			})
+			q.remove(e, err.Error())
+		default:
+			q.mu.Lock()
+			e.Attempts++
+			e.LastError = err.Error()
+			if werr := q.write(pendingDir, e); werr != nil {
+				logger.WarnCF("outbox", "Failed to update action", map[string]any{"error": werr.Error()})
+			}
+			q.mu.Unlock()
+			wait := q.backoff(e.Attempts)
+			logger.WarnCF("outbox", "Delivery failed; will retry", map[string]any{
+				"kind": e.Kind, "key": e.Key, "attempts": e.Attempts, "retry_in": wait.String(), "error": err.Error(),
+			})
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	wait := q.opts.BaseBackoff
+	for i := 1; i < attempts && wait < q.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, q.opts.MaxBackoff)
+}
+
+// remove takes e off the queue. With a reason it is kept in the failed
+// directory.
+func (q *Queue) remove(e *Entry, reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if reason != "" {
+		e.Attempts++
+		e.LastError = reason
+		if err := q.write(failedDir, e); err != nil {
+			logger.WarnCF("outbox", "Failed to keep failed action", map[string]any{"error": err.Error()})
+		}
+	}
+	if err := os.Remove(q.path(pendingDir, e)); err != nil && !os.IsNotExist(err) {
+		logger.WarnCF("outbox", "Failed to remove delivered action", map[string]any{"error": err.Error()})
+	}
+	list := q.pending[e.Key]
+	if len(list) > 0 && list[0] == e {
+		list = list[1:]
+	}
+	if len(list) == 0 {
+		delete(q.pending, e.Key)
+	} else {
+		q.pending[e.Key] = list
+	}
+	delete(q.ids, e.ID)
+}
+
+func (q *Queue) path(sub string, e *Entry) string {
+	return filepath.Join(q.dir, sub, fmt.Sprintf("%020d.json", e.Seq))
+}
+
+func (q *Queue) write(sub string, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal action: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(q.path(sub, e), data, 0o600); err != nil {
+		return fmt.Errorf("write action: %w", err)
+	}
+	return nil
+}
+
+// readEntries reads the entries in dir, oldest first. Unreadable files are
+// skipped with a warning.
+func readEntries(dir string) ([]*Entry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read outbox: %w", err)
+	}
+	var entries []*Entry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if _, err := strconv.ParseUint(strings.TrimSuffix(name, ".json"), 10, 64); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		var e Entry
+		if err == nil {
+			err = json.Unmarshal(data, &e)
+		}
+		if err != nil {
+			logger.WarnCF("outbox", "Skipping unreadable action", map[string]any{"file": name, "error": err.Error()})
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
+
+// Count returns the number of pending and failed actions in the outbox in
+// dir, for status reports.
+func Count(dir string) (pending, failed int, err error) {
+	p, err := readEntries(filepath.Join(dir, pendingDir))
+	if err != nil {
+		return 0, 0, err
+	}
+	f, err := readEntries(filepath.Join(dir, failedDir))
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(p), len(f), nil
+}
@@ -0,0 +1,200 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetries = Options{BaseBackoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond}
+
+// recorder is a handler that keeps the payloads it delivered and fails
+// the ones listed in fail as many times as given.
+type recorder struct {
+	mu        sync.Mutex
+	delivered []string
+	fail      map[string]int
+}
+
+func (r *recorder) handle(_ context.Context, e Entry) error {
+	var text string
+	json.Unmarshal(e.Payload, &text)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail[text] > 0 {
+		r.fail[text]--
+		return errors.New("network is unreachable")
+	}
+	r.delivered = append(r.delivered, text)
+	return nil
+}
+
+func (r *recorder) got() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.delivered...)
+}
+
+func run(t *testing.T, q *Queue) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestQueue_OrderAndRetries(t *testing.T) {
+	q, err := Open(t.TempDir(), fastRetries)
+	require.NoError(t, err)
+	rec := &recorder{fail: map[string]int{"a1": 2}}
+	q.Handle("message", rec.handle)
+	run(t, q)
+
+	for _, m := range []struct{ key, text string }{{"a", "a1"}, {"a", "a2"}, {"b", "b1"}} {
+		added, err := q.Enqueue("message", m.key, "", m.text)
+		require.NoError(t, err)
+		assert.True(t, added)
+	}
+	require.Eventually(t, func() bool { return len(rec.got()) == 3 }, 2*time.Second, 5*time.Millisecond)
+	got := rec.got()
+	assert.Equal(t, "b1", got[0], "a failing chat does not hold back the others")
+	assert.Equal(t, []string{"a1", "a2"}, got[1:], "a chat's actions keep their order")
+	assert.Zero(t, q.Len())
+}
+
+func TestQueue_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, fastRetries)
+	require.NoError(t, err)
+	for _, text := range []string{"first", "second"} {
+		_, err := q.Enqueue("message", "chat", "", text)
+		require.NoError(t, err)
+	}
+
+	q, err = Open(dir, fastRetries)
+	require.NoError(t, err)
+	assert.Equal(t, 2, q.Len())
+	rec := &recorder{}
+	q.Handle("message", rec.handle)
+	run(t, q)
+	require.Eventually(t, func() bool { return len(rec.got()) == 2 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"first", "second"}, rec.got())
+
+	_, err = q.Enqueue("message", "chat", "", "third")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return q.Len() == 0 }, 2*time.Second, 5*time.Millisecond)
+	q, err = Open(dir, fastRetries)
+	require.NoError(t, err)
+	assert.Zero(t, q.Len(), "delivered actions are removed")
+}
+
+func TestQueue_Dedup(t *testing.T) {
+	q, err := Open(t.TempDir(), fastRetries)
+	require.NoError(t, err)
+	added, _ := q.Enqueue("message", "chat", "", "hello")
+	assert.True(t, added)
+	added, _ = q.Enqueue("message", "chat", "", "hello")
+	assert.False(t, added, "the same action is pending already")
+	added, _ = q.Enqueue("message", "other", "", "hello")
+	assert.True(t, added)
+	added, _ = q.Enqueue("message", "chat", "n-1", "x")
+	assert.True(t, added)
+	added, _ = q.Enqueue("message", "chat", "n-1", "y")
+	assert.False(t, added, "explicit IDs are compared")
+	assert.Equal(t, 3, q.Len())
+
+	rec := &recorder{}
+	q.Handle("message", rec.handle)
+	run(t, q)
+	require.Eventually(t, func() bool { return q.Len() == 0 }, 2*time.Second, 5*time.Millisecond)
+	added, _ = q.Enqueue("message", "chat", "", "hello")
+	assert.True(t, added, "once delivered, an action can be queued again")
+}
+
+func TestQueue_GivesUp(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, fastRetries)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	q.Handle("message", func(context.Context, Entry) error {
+		calls.Add(1)
+		return Permanent(errors.New("chat not found"))
+	})
+	_, err = q.Enqueue("message", "chat", "", "lost")
+	require.NoError(t, err)
+	_, err = q.Enqueue("unknown", "chat", "", "x")
+	require.NoError(t, err)
+	run(t, q)
+
+	require.Eventually(t, func() bool { return q.Len() == 0 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load(), "permanent failures are not retried")
+	pending, failed, err := Count(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pending)
+	assert.Equal(t, 2, failed)
+}
+
+func TestQueue_MaxAge(t *testing.T) {
+	opts := fastRetries
+	opts.MaxAge = time.Hour
+	q, err := Open(t.TempDir(), opts)
+	require.NoError(t, err)
+	var calls atomic.Int32
+	q.Handle("message", func(context.Context, Entry) error {
+		calls.Add(1)
+		return errors.New("timeout")
+	})
+	_, err = q.Enqueue("message", "chat", "", "stale")
+	require.NoError(t, err)
+	q.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	run(t, q)
+	require.Eventually(t, func() bool { return q.Len() == 0 }, 2*time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWebhookHandler(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case calls.Add(1) == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case string(body) != `{"message":"hi"}` || r.Header.Get("X-Token") != "t":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	h := webhookHandler(srv.Client())
+	entry := func(path string) Entry {
+		payload, _ := json.Marshal(Request{
+			Method: http.MethodPost, URL: srv.URL + path, Header: map[string]string{"X-Token": "t"}, Body: `{"message":"hi"}`,
+		})
+		return Entry{Kind: KindWebhook, Payload: payload}
+	}
+	err := h(context.Background(), entry("/hook"))
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err), "5xx answers are retried")
+	assert.NoError(t, h(context.Background(), entry("/hook")))
+	assert.True(t, IsPermanent(h(context.Background(), entry("/gone"))))
+}
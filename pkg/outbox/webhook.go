@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// KindWebhook is the kind of the HTTP calls queued with EnqueueRequest.
+const KindWebhook = "webhook"
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// Request is a queued HTTP call.
+type Request struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body,omitempty"`
+}
+
+// EnqueueRequest queues an HTTP call, keyed by its URL so the calls to one
+// endpoint arrive in order.
+func (q *Queue) EnqueueRequest(req Request) (bool, error) {
+	return q.Enqueue(KindWebhook, "webhook:"+req.URL, "", req)
+}
+
+// webhookHandler makes the queued HTTP calls. Answers in the 2xx range are
+// deliveries; other 4xx answers than 408 and 429 are permanent failures.
+func webhookHandler(client *http.Client) Handler {
+	return func(ctx context.Context, e Entry) error {
+		var r Request
+		if err := json.Unmarshal(e.Payload, &r); err != nil {
+			return Permanent(fmt.Errorf("invalid request: %w", err))
+		}
+		req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, strings.NewReader(r.Body))
+		if err != nil {
+			return Permanent(err)
+		}
+		for k, v := range r.Header {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		err = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+		if resp.StatusCode/100 == 4 && resp.StatusCode != http.StatusRequestTimeout &&
+			resp.StatusCode != http.StatusTooManyRequests {
+			return Permanent(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,164 @@
+// Package pii finds personal data in text: email addresses, phone numbers
+// and payment card numbers. Sessions can be stored with them masked, or
+// with the kinds found recorded in the session, for deployments that must
+// keep track of personal data.
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Kind is a kind of personal data.
+type Kind string
+
+const (
+	Email Kind = "email"
+	Phone Kind = "phone"
+	Card  Kind = "card" // payment card numbers that pass the Luhn check
+)
+
+// Kinds lists every kind, in the order text is searched for them.
+var Kinds = []Kind{Email, Card, Phone}
+
+var (
+	emailPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+	cardPattern  = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// phonePattern is loose; phone filters out what has too few digits or
+	// looks like a date or a number.
+	phonePattern = regexp.MustCompile(`(?:\+|\()?\b\d[\d ().-]{5,18}\d\b`)
+	datePattern  = regexp.MustCompile(`^\d{4}[-./]\d{1,2}[-./]\d{1,2}\b|^\d{1,2}[-./]\d{1,2}[-./]\d{4}\b`)
+)
+
+// ParseKinds parses kind names; none means every kind.
+func ParseKinds(names []string) ([]Kind, error) {
+	if len(names) == 0 {
+		return Kinds, nil
+	}
+	var kinds []Kind
+	for _, name := range names {
+		switch k := Kind(strings.ToLower(strings.TrimSpace(name))); k {
+		case Email, Phone, Card:
+			kinds = append(kinds, k)
+		default:
+			return nil, fmt.Errorf("unknown kind %q (want email, phone or card)", name)
+		}
+	}
+	return kinds, nil
+}
+
+// Detector finds and masks personal data of some kinds.
+type Detector struct {
+	kinds []Kind
+}
+
+// NewDetector returns a detector of kinds, or of every kind when none are
+// given.
+func NewDetector(kinds ...Kind) *Detector {
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+	return &Detector{kinds: kinds}
+}
+
+// Count returns how much of each kind text holds, or nil for none.
+func (d *Detector) Count(text string) map[Kind]int {
+	var counts map[Kind]int
+	d.replace(text, func(k Kind, match string) string {
+		if counts == nil {
+			counts = make(map[Kind]int)
+		}
+		counts[k]++
+		return match
+	})
+	return counts
+}
+
+// Mask replaces what it finds with the kind in brackets, such as
+// "[EMAIL]", and returns how many it replaced.
+func (d *Detector) Mask(text string) (string, int) {
+	n := 0
+	masked := d.replace(text, func(k Kind, _ string) string {
+		n++
+		return "[" + strings.ToUpper(string(k)) + "]"
+	})
+	return masked, n
+}
+
+// replace runs fn on each match. Card numbers are searched before phone
+// numbers, which would otherwise match them too, and what fn returns is
+// not searched again.
+func (d *Detector) replace(text string, fn func(Kind, string) string) string {
+	if text == "" {
+		return text
+	}
+	for _, k := range Kinds {
+		if !slices.Contains(d.kinds, k) {
+			continue
+		}
+		switch k {
+		case Email:
+			text = emailPattern.ReplaceAllStringFunc(text, func(m string) string { return fn(Email, m) })
+		case Card:
+			text = cardPattern.ReplaceAllStringFunc(text, func(m string) string {
+				if !luhn(m) {
+					return m
+				}
+				return fn(Card, m)
+			})
+		case Phone:
+			text = phonePattern.ReplaceAllStringFunc(text, func(m string) string {
+				if !phone(m) {
+					return m
+				}
+				return fn(Phone, m)
+			})
+		}
+	}
+	return text
+}
+
+// luhn reports whether the digits of s pass the Luhn check.
+func luhn(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		n := int(c - '0')
+		if double {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// phone reports whether a match of phonePattern looks like a phone number:
+// 8 to 15 digits, and either written in international form, starting with
+// a trunk prefix or an area code in parentheses, or at least 10 digits
+// long. That leaves out dates, versions and most amounts.
+func phone(s string) bool {
+	if datePattern.MatchString(s) {
+		return false
+	}
+	digits := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c == '.' && !strings.ContainsAny(s, " -()"):
+			return false // 3.14159265 or 10.0.0.1
+		}
+	}
+	if digits < 8 || digits > 15 {
+		return false
+	}
+	return strings.HasPrefix(s, "+") || strings.HasPrefix(s, "0") || strings.Contains(s, "(") || digits >= 10
+}
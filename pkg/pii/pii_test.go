@@ -0,0 +1,54 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetector_Mask(t *testing.T) {
+	d := NewDetector()
+	for _, tc := range []struct{ in, want string }{
+		{"mail anna.berg@example.com today", "mail [EMAIL] today"},
+		{"card 4111 1111 1111 1111, exp 12/27", "card [CARD], exp 12/27"},
+		{"call +49 30 1234567 or (030) 1234-5678", "call [PHONE] or [PHONE]"},
+		{"my number is 0151 23456789", "my number is [PHONE]"},
+		{"US: 415-555-0132", "US: [PHONE]"},
+		// Not personal data.
+		{"4111 1111 1111 1112 fails the Luhn check", "4111 1111 1111 1112 fails the Luhn check"},
+		{"on 2026-10-14 12:30 for 1500000 EUR", "on 2026-10-14 12:30 for 1500000 EUR"},
+		{"pi is 3.14159265, the host 10.0.0.1", "pi is 3.14159265, the host 10.0.0.1"},
+		{"order 12345678", "order 12345678"},
+	} {
+		got, _ := d.Mask(tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestDetector_CountKinds(t *testing.T) {
+	text := "anna@example.com, bob@example.org, +44 20 7946 0958, 5500 0000 0000 0004"
+	assert.Equal(t, map[Kind]int{Email: 2, Phone: 1, Card: 1}, NewDetector().Count(text))
+	assert.Nil(t, NewDetector().Count("nothing here"))
+
+	masked, n := NewDetector(Email).Mask(text)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "[EMAIL], [EMAIL], +44 20 7946 0958, 5500 0000 0000 0004", masked)
+}
+
+func TestParse(t *testing.T) {
+	kinds, err := ParseKinds(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Kinds, kinds)
+	kinds, err = ParseKinds([]string{"Email", "card"})
+	require.NoError(t, err)
+	assert.Equal(t, []Kind{Email, Card}, kinds)
+	_, err = ParseKinds([]string{"address"})
+	assert.Error(t, err)
+
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, Mask, mode)
+	_, err = ParseMode("hash")
+	assert.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package pii
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// Mode is what a Store does with the personal data it finds.
+type Mode string
+
+const (
+	Mask Mode = "mask" // replace it before the session is written
+	Flag Mode = "flag" // keep it and count it in Session.PII
+)
+
+// ParseMode parses a mode name; "" is Mask.
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case "", Mask:
+		return Mask, nil
+	case Flag:
+		return Flag, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q (want mask or flag)", name)
+	}
+}
+
+// Store is a session.Store that looks for personal data in each session it
+// writes to another store. Only the stored copy is masked: the session in
+// memory keeps the text until it is loaded again.
+type Store struct {
+	store    session.Store
+	detector *Detector
+	mode     Mode
+}
+
+// NewStore returns a Store writing to store.
+func NewStore(store session.Store, detector *Detector, mode Mode) *Store {
+	return &Store{store: store, detector: detector, mode: mode}
+}
+
+func (s *Store) Load() ([]session.Session, error) {
+	return s.store.Load()
+}
+
+func (s *Store) Delete(key string) error {
+	return s.store.Delete(key)
+}
+
+// Save masks or counts the personal data in the messages, summary and
+// scratchpad of sess, and writes it. The feedback on the answers is masked
+// too; it repeats the messages, so it is not counted.
+func (s *Store) Save(sess session.Session) error {
+	if s.mode == Flag {
+		sess.PII = s.count(sess)
+		return s.store.Save(sess)
+	}
+
+	sess.Messages = s.maskMessages(sess.Messages)
+	sess.Summary = s.mask(sess.Summary)
+	if len(sess.Scratchpad) > 0 {
+		sess.Scratchpad = maps.Clone(sess.Scratchpad)
+		for name, text := range sess.Scratchpad {
+			sess.Scratchpad[name] = s.mask(text)
+		}
+	}
+	if len(sess.Feedback) > 0 {
+		feedback := make([]session.Feedback, len(sess.Feedback))
+		for i, fb := range sess.Feedback {
+			fb.Answer, fb.Question, fb.Correction = s.mask(fb.Answer), s.mask(fb.Question), s.mask(fb.Correction)
+			feedback[i] = fb
+		}
+		sess.Feedback = feedback
+	}
+	return s.store.Save(sess)
+}
+
+// LoadCheckpoints reads the checkpoints of a session from the store below,
+// if it keeps them.
+func (s *Store) LoadCheckpoints(key string) ([]session.Checkpoint, error) {
+	cs, ok := s.store.(session.CheckpointStore)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return cs.LoadCheckpoints(key)
+}
+
+// SaveCheckpoints masks the personal data in the checkpoints as Save does
+// in the session, and writes them to the store below, if it keeps them.
+func (s *Store) SaveCheckpoints(sess session.Session, checkpoints []session.Checkpoint) error {
+	cs, ok := s.store.(session.CheckpointStore)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	if s.mode == Flag {
+		return cs.SaveCheckpoints(sess, checkpoints)
+	}
+	masked := make([]session.Checkpoint, len(checkpoints))
+	for i, cp := range checkpoints {
+		cp.Messages = s.maskMessages(cp.Messages)
+		cp.Summary = s.mask(cp.Summary)
+		masked[i] = cp
+	}
+	return cs.SaveCheckpoints(sess, masked)
+}
+
+// Compact compacts the store below, if it can.
+func (s *Store) Compact() (int64, error) {
+	cs, ok := s.store.(session.CompactStore)
+	if !ok {
+		return 0, errors.ErrUnsupported
+	}
+	return cs.Compact()
+}
+
+// Stats measures the store below, if it can.
+func (s *Store) Stats() (session.StoreStats, error) {
+	cs, ok := s.store.(session.CompactStore)
+	if !ok {
+		return session.StoreStats{}, errors.ErrUnsupported
+	}
+	return cs.Stats()
+}
+
+// maskMessages returns a masked copy of messages. The caller may keep
+// messages, so nothing it shares is changed in place.
+func (s *Store) maskMessages(messages []providers.Message) []providers.Message {
+	masked := make([]providers.Message, len(messages))
+	for i, m := range messages {
+		m.Content = s.mask(m.Content)
+		m.ReasoningContent = s.mask(m.ReasoningContent)
+		if len(m.ToolCalls) > 0 {
+			calls := make([]providers.ToolCall, len(m.ToolCalls))
+			for j, tc := range m.ToolCalls {
+				if tc.Function != nil {
+					fn := *tc.Function
+					fn.Arguments = mapJSON(fn.Arguments, s.mask)
+					tc.Function = &fn
+				}
+				calls[j] = tc
+			}
+			m.ToolCalls = calls
+		}
+		masked[i] = m
+	}
+	return masked
+}
+
+func (s *Store) mask(text string) string {
+	masked, _ := s.detector.Mask(text)
+	return masked
+}
+
+// mapJSON runs fn on the strings in JSON tool call arguments, leaving
+// numbers and the structure alone, and returns the arguments with what fn
+// returned. Arguments that are not JSON are passed to fn whole.
+func mapJSON(args string, fn func(string) string) string {
+	var v any
+	if json.Unmarshal([]byte(args), &v) != nil {
+		return fn(args)
+	}
+	data, err := json.Marshal(mapValue(v, fn))
+	if err != nil {
+		return args
+	}
+	return string(data)
+}
+
+func mapValue(v any, fn func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case []any:
+		for i := range v {
+			v[i] = mapValue(v[i], fn)
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = mapValue(v[k], fn)
+		}
+	}
+	return v
+}
+
+// count adds up what the session holds of each kind, or returns nil for
+// nothing.
+func (s *Store) count(sess session.Session) map[string]int {
+	var counts map[string]int
+	add := func(text string) {
+		for k, n := range s.detector.Count(text) {
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+			counts[string(k)] += n
+		}
+	}
+	for _, m := range sess.Messages {
+		add(m.Content)
+		add(m.ReasoningContent)
+		for _, tc := range m.ToolCalls {
+			if tc.Function != nil {
+				mapJSON(tc.Function.Arguments, func(text string) string {
+					add(text)
+					return text
+				})
+			}
+		}
+	}
+	add(sess.Summary)
+	for _, text := range sess.Scratchpad {
+		add(text)
+	}
+	return counts
+}
@@ -0,0 +1,54 @@
+package pii
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func addMessages(sm *session.SessionManager, key string) {
+	sm.AddMessage(key, "user", "write to anna@example.com, she is on +49 30 1234567")
+	sm.AddFullMessage(key, providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{{
+		ID:       "1",
+		Function: &providers.FunctionCall{Name: "send_email", Arguments: `{"chat_id":12345678901,"to":"anna@example.com"}`},
+	}}})
+	sm.SetSummary(key, "Anna's address is anna@example.com.")
+}
+
+func TestStore_Mask(t *testing.T) {
+	dir := t.TempDir()
+	sm := session.NewSessionManager("")
+	require.NoError(t, sm.SetStore(NewStore(session.NewDirStore(dir), NewDetector(), Mask)))
+	addMessages(sm, "telegram:1")
+	require.NoError(t, sm.Save("telegram:1"))
+
+	// The session in memory is left as it is.
+	assert.Contains(t, sm.GetHistory("telegram:1")[0].Content, "anna@example.com")
+
+	stored := session.NewSessionManager(dir)
+	history := stored.GetHistory("telegram:1")
+	require.Len(t, history, 2)
+	assert.Equal(t, "write to [EMAIL], she is on [PHONE]", history[0].Content)
+	assert.JSONEq(t, `{"chat_id":12345678901,"to":"[EMAIL]"}`, history[1].ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "Anna's address is [EMAIL].", stored.GetSummary("telegram:1"))
+	snap, _ := stored.Snapshot("telegram:1")
+	assert.Nil(t, snap.PII)
+}
+
+func TestStore_Flag(t *testing.T) {
+	dir := t.TempDir()
+	sm := session.NewSessionManager("")
+	require.NoError(t, sm.SetStore(NewStore(session.NewDirStore(dir), NewDetector(Email, Phone), Flag)))
+	addMessages(sm, "telegram:1")
+	require.NoError(t, sm.Save("telegram:1"))
+
+	stored := session.NewSessionManager(dir)
+	snap, ok := stored.Snapshot("telegram:1")
+	require.True(t, ok)
+	assert.Contains(t, snap.Messages[0].Content, "anna@example.com")
+	assert.Equal(t, map[string]int{"email": 3, "phone": 1}, snap.PII)
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -18,14 +20,18 @@ type (
 	FunctionCall           = protocoltypes.FunctionCall
 	LLMResponse            = protocoltypes.LLMResponse
 	UsageInfo              = protocoltypes.UsageInfo
+	PromptTokensDetails    = protocoltypes.PromptTokensDetails
 	Message                = protocoltypes.Message
 	ToolDefinition         = protocoltypes.ToolDefinition
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+	StreamDelta            = protocoltypes.StreamDelta
 )
 
 const (
-	defaultBaseURL      = "https://api.anthropic.com"
-	anthropicBetaHeader = "oauth-2025-04-20"
+	defaultBaseURL        = "https://api.anthropic.com"
+	anthropicBetaHeader   = "oauth-2025-04-20"
+	defaultRequestTimeout = 120 * time.Second
+	streamBufferSize      = 16
 )
 
 type Provider struct {
@@ -53,6 +59,30 @@ func NewProviderWithBaseURL(token, apiBase string) *Provider {
 	}
 }
 
+// NewProviderWithAPIKey creates a provider that talks to the Messages API
+// with a console API key (sent as x-api-key) rather than an OAuth token.
+// rt and requestTimeoutSeconds are optional; zero values keep the defaults.
+func NewProviderWithAPIKey(apiKey, apiBase string, rt http.RoundTripper, requestTimeoutSeconds int) *Provider {
+	httpClient := &http.Client{
+		Timeout:   defaultRequestTimeout,
+		Transport: rt,
+	}
+	if requestTimeoutSeconds > 0 {
+		httpClient.Timeout = time.Duration(requestTimeoutSeconds) * time.Second
+	}
+
+	baseURL := normalizeBaseURL(apiBase)
+	client := anthropic.NewClient(
+		option.WithAPIKey(apiKey),
+		option.WithBaseURL(baseURL),
+		option.WithHTTPClient(httpClient),
+	)
+	return &Provider{
+		client:  &client,
+		baseURL: baseURL,
+	}
+}
+
 func NewProviderWithClient(client *anthropic.Client) *Provider {
 	return &Provider{
 		client:  client,
@@ -77,16 +107,9 @@ func (p *Provider) Chat(
 	model string,
 	options map[string]any,
 ) (*LLMResponse, error) {
-	var opts []option.RequestOption
-	if p.tokenSource != nil {
-		tok, err := p.tokenSource()
-		if err != nil {
-			return nil, fmt.Errorf("refreshing token: %w", err)
-		}
-		opts = append(opts,
-			option.WithAuthToken(tok),
-			option.WithHeader("anthropic-beta", anthropicBetaHeader),
-		)
+	opts, err := p.requestOptions(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	params, err := buildParams(messages, tools, model, options)
@@ -129,6 +152,101 @@ func (p *Provider) chatStreaming(
 	return parseResponse(&msg), nil
 }
 
+// ChatStream implements providers.StreamingProvider. Text and thinking
+// fragments are forwarded as they arrive; unless ctx is canceled, the channel
+// ends with exactly one terminal delta carrying the accumulated response or
+// the error that ended the stream.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	opts, err := p.requestOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := buildParams(messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := p.client.Messages.NewStreaming(ctx, params, opts...)
+	out := make(chan StreamDelta, streamBufferSize)
+	go func() {
+		defer close(out)
+		defer stream.Close()
+
+		var msg anthropic.Message
+		for stream.Next() {
+			event := stream.Current()
+			if err := msg.Accumulate(event); err != nil {
+				sendDelta(ctx, out, StreamDelta{Done: true, Err: fmt.Errorf("claude streaming accumulate: %w", err)})
+				return
+			}
+
+			if event.Type != "content_block_delta" {
+				continue
+			}
+			var d StreamDelta
+			switch delta := event.AsContentBlockDelta().Delta; delta.Type {
+			case "text_delta":
+				d.Content = delta.Text
+			case "thinking_delta":
+				d.ReasoningContent = delta.Thinking
+			}
+			if d.Content == "" && d.ReasoningContent == "" {
+				continue
+			}
+			if !sendDelta(ctx, out, d) {
+				return
+			}
+		}
+		if err := stream.Err(); err != nil {
+			sendDelta(ctx, out, StreamDelta{Done: true, Err: fmt.Errorf("claude API call: %w", err)})
+			return
+		}
+
+		sendDelta(ctx, out, StreamDelta{Done: true, Response: parseResponse(&msg)})
+	}()
+
+	return out, nil
+}
+
+// requestOptions returns per-request options. Providers backed by a token
+// source refresh the OAuth token on every call; extra headers attached to ctx
+// are sent as well.
+func (p *Provider) requestOptions(ctx context.Context) ([]option.RequestOption, error) {
+	var opts []option.RequestOption
+	if p.tokenSource != nil {
+		tok, err := p.tokenSource()
+		if err != nil {
+			return nil, fmt.Errorf("refreshing token: %w", err)
+		}
+		opts = append(opts,
+			option.WithAuthToken(tok),
+			option.WithHeader("anthropic-beta", anthropicBetaHeader),
+		)
+	}
+	for k, v := range protocoltypes.RequestHeaders(ctx) {
+		opts = append(opts, option.WithHeader(k, v))
+	}
+	return opts, nil
+}
+
+// sendDelta delivers d unless ctx is canceled first. It reports whether the
+// delta was delivered.
+func sendDelta(ctx context.Context, out chan<- StreamDelta, d StreamDelta) bool {
+	select {
+	case out <- d:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 func (p *Provider) GetDefaultModel() string {
 	return "claude-sonnet-4.6"
 }
@@ -169,9 +287,7 @@ func buildParams(
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
 			} else {
-				anthropicMessages = append(anthropicMessages,
-					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
-				)
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(userContentBlocks(msg)...))
 			}
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
@@ -227,9 +343,18 @@ func buildParams(
 		params.Temperature = anthropic.Float(temp)
 	}
 
+	if topP, ok := options["top_p"].(float64); ok {
+		params.TopP = anthropic.Float(topP)
+	}
+
+	if stop, ok := options["stop"].([]string); ok && len(stop) > 0 {
+		params.StopSequences = stop
+	}
+
 	if len(tools) > 0 {
 		params.Tools = translateTools(tools)
 	}
+	limitCacheBreakpoints(&params)
 
 	// Extended Thinking / Adaptive Thinking
 	// The thinking_level value directly determines the API parameter format:
@@ -242,6 +367,24 @@ func buildParams(
 	return params, nil
 }
 
+// userContentBlocks converts a user message into content blocks, placing
+// attached images before the text as Anthropic recommends.
+func userContentBlocks(msg Message) []anthropic.ContentBlockParamUnion {
+	images := msg.ImageParts()
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(images)+1)
+	for _, image := range images {
+		if image.URL != "" {
+			blocks = append(blocks, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: image.URL}))
+			continue
+		}
+		blocks = append(blocks, anthropic.NewImageBlockBase64(image.MediaType, image.Data))
+	}
+	if msg.Content != "" || len(blocks) == 0 {
+		blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
+	}
+	return blocks
+}
+
 // applyThinkingConfig sets thinking parameters based on the level value.
 // "adaptive" uses the adaptive thinking API (Claude 4.6+).
 // All other levels use budget_tokens which is universally supported.
@@ -328,9 +471,40 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 		}
 		result = append(result, anthropic.ToolUnionParam{OfTool: &tool})
 	}
+	// Tool schemas are the first part of the prompt prefix and rarely change.
+	// A breakpoint on the last tool keeps them cached even when the system
+	// prompt is rebuilt (e.g. after a memory file edit).
+	if len(result) > 0 {
+		result[len(result)-1].OfTool.CacheControl = anthropic.NewCacheControlEphemeralParam()
+	}
 	return result
 }
 
+// maxCacheBreakpoints is the most cache_control blocks Anthropic accepts in
+// one request; more are rejected with HTTP 400.
+const maxCacheBreakpoints = 4
+
+// limitCacheBreakpoints drops the earliest breakpoints (tools first, then
+// system blocks in order) until at most maxCacheBreakpoints remain. A later
+// breakpoint caches the whole prefix before it, so dropping earlier ones
+// only makes reuse coarser.
+func limitCacheBreakpoints(params *anthropic.MessageNewParams) {
+	var marks []*anthropic.CacheControlEphemeralParam
+	for i := range params.Tools {
+		if tool := params.Tools[i].OfTool; tool != nil && tool.CacheControl.Type != "" {
+			marks = append(marks, &tool.CacheControl)
+		}
+	}
+	for i := range params.System {
+		if params.System[i].CacheControl.Type != "" {
+			marks = append(marks, &params.System[i].CacheControl)
+		}
+	}
+	for _, mark := range marks[:max(0, len(marks)-maxCacheBreakpoints)] {
+		*mark = anthropic.CacheControlEphemeralParam{}
+	}
+}
+
 func parseResponse(resp *anthropic.Message) *LLMResponse {
 	var content strings.Builder
 	var reasoning strings.Builder
@@ -369,16 +543,27 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 		finishReason = "stop"
 	}
 
+	// input_tokens excludes cache reads and writes; fold them back in so
+	// PromptTokens means the same thing as for OpenAI-compatible providers.
+	promptTokens := int(resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens + resp.Usage.CacheReadInputTokens)
+	usage := &UsageInfo{
+		PromptTokens:     promptTokens,
+		CompletionTokens: int(resp.Usage.OutputTokens),
+		TotalTokens:      promptTokens + int(resp.Usage.OutputTokens),
+	}
+	if resp.Usage.CacheCreationInputTokens > 0 || resp.Usage.CacheReadInputTokens > 0 {
+		usage.PromptTokensDetails = &PromptTokensDetails{
+			CachedTokens:        int(resp.Usage.CacheReadInputTokens),
+			CacheCreationTokens: int(resp.Usage.CacheCreationInputTokens),
+		}
+	}
+
 	return &LLMResponse{
 		Content:      content.String(),
 		Reasoning:    reasoning.String(),
 		ToolCalls:    toolCalls,
 		FinishReason: finishReason,
-		Usage: &UsageInfo{
-			PromptTokens:     int(resp.Usage.InputTokens),
-			CompletionTokens: int(resp.Usage.OutputTokens),
-			TotalTokens:      int(resp.Usage.InputTokens + resp.Usage.OutputTokens),
-		},
+		Usage:        usage,
 	}
 }
 
@@ -9,6 +9,8 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 	anthropicoption "github.com/anthropics/anthropic-sdk-go/option"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 func TestBuildParams_BasicMessage(t *testing.T) {
@@ -32,6 +34,22 @@ func TestBuildParams_BasicMessage(t *testing.T) {
 	}
 }
 
+func TestBuildParams_SamplingOptions(t *testing.T) {
+	params, err := buildParams([]Message{{Role: "user", Content: "Hello"}}, nil, "claude-sonnet-4.6", map[string]any{
+		"top_p": 0.8,
+		"stop":  []string{"END"},
+	})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if !params.TopP.Valid() || params.TopP.Value != 0.8 {
+		t.Errorf("TopP = %v, want 0.8", params.TopP)
+	}
+	if len(params.StopSequences) != 1 || params.StopSequences[0] != "END" {
+		t.Errorf("StopSequences = %v, want [END]", params.StopSequences)
+	}
+}
+
 func TestBuildParams_SystemMessage(t *testing.T) {
 	messages := []Message{
 		{Role: "system", Content: "You are helpful"},
@@ -101,6 +119,71 @@ func TestBuildParams_WithTools(t *testing.T) {
 	if len(params.Tools) != 1 {
 		t.Fatalf("len(Tools) = %d, want 1", len(params.Tools))
 	}
+	if params.Tools[0].OfTool.CacheControl.Type != "ephemeral" {
+		t.Errorf("last tool CacheControl = %+v, want ephemeral breakpoint", params.Tools[0].OfTool.CacheControl)
+	}
+}
+
+func TestBuildParams_LimitsCacheBreakpoints(t *testing.T) {
+	ephemeral := &protocoltypes.CacheControl{Type: "ephemeral"}
+	messages := []Message{
+		{Role: "system", SystemParts: []protocoltypes.ContentBlock{
+			{Type: "text", Text: "static", CacheControl: ephemeral},
+			{Type: "text", Text: "persona", CacheControl: ephemeral},
+			{Type: "text", Text: "channel", CacheControl: ephemeral},
+			{Type: "text", Text: "summary", CacheControl: ephemeral},
+			{Type: "text", Text: "dynamic"},
+		}},
+		{Role: "user", Content: "Hi"},
+	}
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "echo"}}}
+
+	params, err := buildParams(messages, tools, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+
+	count := 0
+	if params.Tools[0].OfTool.CacheControl.Type != "" {
+		t.Error("tool breakpoint kept, want the earliest breakpoint dropped")
+		count++
+	}
+	for _, block := range params.System {
+		if block.CacheControl.Type != "" {
+			count++
+		}
+	}
+	if count != maxCacheBreakpoints {
+		t.Errorf("breakpoints = %d, want %d", count, maxCacheBreakpoints)
+	}
+	if params.System[3].CacheControl.Type != "ephemeral" {
+		t.Error("summary breakpoint dropped, want the latest breakpoints kept")
+	}
+}
+
+func TestParseResponse_CacheUsage(t *testing.T) {
+	resp := &anthropic.Message{
+		Content: []anthropic.ContentBlockUnion{},
+		Usage: anthropic.Usage{
+			InputTokens:              10,
+			CacheCreationInputTokens: 100,
+			CacheReadInputTokens:     900,
+			OutputTokens:             20,
+		},
+	}
+	result := parseResponse(resp)
+	if result.Usage.PromptTokens != 1010 {
+		t.Errorf("PromptTokens = %d, want 1010", result.Usage.PromptTokens)
+	}
+	if result.Usage.TotalTokens != 1030 {
+		t.Errorf("TotalTokens = %d, want 1030", result.Usage.TotalTokens)
+	}
+	if got := result.Usage.CachedTokens(); got != 900 {
+		t.Errorf("CachedTokens() = %d, want 900", got)
+	}
+	if got := result.Usage.PromptTokensDetails.CacheCreationTokens; got != 100 {
+		t.Errorf("CacheCreationTokens = %d, want 100", got)
+	}
 }
 
 func TestParseResponse_TextOnly(t *testing.T) {
@@ -143,6 +226,39 @@ func TestParseResponse_StopReasons(t *testing.T) {
 	}
 }
 
+func TestBuildParams_UserImages(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "What is in this photo?", Media: []string{
+			"data:image/jpeg;base64,/9j/4AAQ",
+			"https://example.com/photo.png",
+			"data:audio/ogg;base64,T2dn",
+		}},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+
+	blocks := params.Messages[0].Content
+	if len(blocks) != 3 {
+		t.Fatalf("len(Content) = %d, want 3 (2 images + text)", len(blocks))
+	}
+	img := blocks[0].OfImage
+	if img == nil || img.Source.OfBase64 == nil {
+		t.Fatalf("block 0 = %+v, want base64 image", blocks[0])
+	}
+	if img.Source.OfBase64.MediaType != "image/jpeg" || img.Source.OfBase64.Data != "/9j/4AAQ" {
+		t.Errorf("base64 source = %+v", img.Source.OfBase64)
+	}
+	if blocks[1].OfImage == nil || blocks[1].OfImage.Source.OfURL == nil ||
+		blocks[1].OfImage.Source.OfURL.URL != "https://example.com/photo.png" {
+		t.Errorf("block 1 = %+v, want URL image", blocks[1])
+	}
+	if blocks[2].OfText == nil || blocks[2].OfText.Text != "What is in this photo?" {
+		t.Errorf("block 2 = %+v, want text", blocks[2])
+	}
+}
+
 func TestProvider_ChatRoundTrip(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/v1/messages" {
@@ -193,6 +309,33 @@ func TestProvider_ChatRoundTrip(t *testing.T) {
 	}
 }
 
+func TestProvider_ChatSendsContextHeaders(t *testing.T) {
+	var gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Tenant")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg_test",
+			"type":        "message",
+			"role":        "assistant",
+			"stop_reason": "end_turn",
+			"content":     []map[string]any{{"type": "text", "text": "ok"}},
+			"usage":       map[string]any{"input_tokens": 1, "output_tokens": 1},
+		})
+	}))
+	defer server.Close()
+
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	ctx := protocoltypes.WithRequestHeaders(t.Context(), map[string]string{"X-Tenant": "acme"})
+	messages := []Message{{Role: "user", Content: "Hello"}}
+	if _, err := provider.Chat(ctx, messages, nil, "claude-sonnet-4.6", nil); err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if gotHeader != "acme" {
+		t.Errorf("X-Tenant = %q, want acme", gotHeader)
+	}
+}
+
 func TestProvider_GetDefaultModel(t *testing.T) {
 	p := NewProvider("test-token")
 	if got := p.GetDefaultModel(); got != "claude-sonnet-4.6" {
@@ -321,6 +464,123 @@ func TestProvider_ChatStreamingRoundTrip(t *testing.T) {
 	}
 }
 
+func TestProvider_ChatWithAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "sk-ant-test" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want empty for API key auth", got)
+		}
+
+		var reqBody map[string]any
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		if _, ok := reqBody["system"]; !ok {
+			t.Error("request is missing system prompt")
+		}
+
+		resp := map[string]any{
+			"id":          "msg_test",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       reqBody["model"],
+			"stop_reason": "tool_use",
+			"content": []map[string]any{
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": map[string]any{"city": "SF"}},
+			},
+			"usage": map[string]any{
+				"input_tokens":  20,
+				"output_tokens": 10,
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	p := NewProviderWithAPIKey("sk-ant-test", server.URL+"/v1", nil, 5)
+	resp, err := p.Chat(
+		t.Context(),
+		[]Message{
+			{Role: "system", Content: "You are helpful."},
+			{Role: "user", Content: "Weather in SF?"},
+		},
+		[]ToolDefinition{{
+			Type: "function",
+			Function: ToolFunctionDefinition{
+				Name:       "get_weather",
+				Parameters: map[string]any{"type": "object", "properties": map[string]any{}},
+			},
+		}},
+		"claude-sonnet-4.6",
+		map[string]any{},
+	)
+	if err != nil {
+		t.Fatalf("Chat() error: %v", err)
+	}
+	if resp.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want %q", resp.FinishReason, "tool_calls")
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" {
+		t.Fatalf("ToolCalls = %+v, want one get_weather call", resp.ToolCalls)
+	}
+	if resp.ToolCalls[0].Arguments["city"] != "SF" {
+		t.Errorf("Arguments[city] = %v, want SF", resp.ToolCalls[0].Arguments["city"])
+	}
+}
+
+func TestProvider_ChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_stream\",\"type\":\"message\",\"role\":\"assistant\",\"content\":[],\"model\":\"claude-sonnet-4-6\",\"stop_reason\":null,\"usage\":{\"input_tokens\":12,\"output_tokens\":0}}}\n\n",
+			"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hello\"}}\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" world\"}}\n\n",
+			"event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n",
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":5}}\n\n",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		}
+		for _, e := range events {
+			w.Write([]byte(e))
+		}
+	}))
+	defer server.Close()
+
+	p := NewProviderWithAPIKey("sk-ant-test", server.URL, nil, 0)
+	deltas, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "Hello"}}, nil, "claude-sonnet-4.6", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error: %v", err)
+	}
+
+	var fragments []string
+	var final StreamDelta
+	for d := range deltas {
+		if d.Done {
+			final = d
+			continue
+		}
+		fragments = append(fragments, d.Content)
+	}
+	if len(fragments) != 2 || fragments[0] != "Hello" || fragments[1] != " world" {
+		t.Errorf("fragments = %q, want [Hello, \" world\"]", fragments)
+	}
+	if final.Err != nil {
+		t.Fatalf("terminal delta error: %v", final.Err)
+	}
+	if final.Response == nil || final.Response.Content != "Hello world" {
+		t.Fatalf("final response = %+v, want content %q", final.Response, "Hello world")
+	}
+	if final.Response.Usage.CompletionTokens != 5 {
+		t.Errorf("CompletionTokens = %d, want 5", final.Response.Usage.CompletionTokens)
+	}
+}
+
 func createAnthropicTestClient(baseURL, token string) *anthropic.Client {
 	c := anthropic.NewClient(
 		anthropicoption.WithAuthToken(token),
@@ -172,6 +172,12 @@ type antigravityPart struct {
 	ThoughtSignatureSnake string                       `json:"thought_signature,omitempty"`
 	FunctionCall          *antigravityFunctionCall     `json:"functionCall,omitempty"`
 	FunctionResponse      *antigravityFunctionResponse `json:"functionResponse,omitempty"`
+	InlineData            *antigravityInlineData       `json:"inlineData,omitempty"`
+}
+
+type antigravityInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
 }
 
 type antigravityFunctionCall struct {
@@ -199,8 +205,10 @@ type antigravitySystemPrompt struct {
 }
 
 type antigravityGenConfig struct {
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     float64  `json:"temperature,omitempty"`
+	TopP            float64  `json:"topP,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
 }
 
 func (p *AntigravityProvider) buildRequest(
@@ -235,9 +243,19 @@ func (p *AntigravityProvider) buildRequest(
 					}},
 				})
 			} else {
+				parts := []antigravityPart{{Text: msg.Content}}
+				// Gemini only accepts inline image bytes; remote URLs are skipped.
+				for _, image := range msg.ImageParts() {
+					if image.Data == "" {
+						continue
+					}
+					parts = append(parts, antigravityPart{
+						InlineData: &antigravityInlineData{MimeType: image.MediaType, Data: image.Data},
+					})
+				}
 				req.Contents = append(req.Contents, antigravityContent{
 					Role:  "user",
-					Parts: []antigravityPart{{Text: msg.Content}},
+					Parts: parts,
 				})
 			}
 		case "assistant":
@@ -321,7 +339,13 @@ func (p *AntigravityProvider) buildRequest(
 	if temp, ok := options["temperature"].(float64); ok {
 		config.Temperature = temp
 	}
-	if config.MaxOutputTokens > 0 || config.Temperature > 0 {
+	if topP, ok := options["top_p"].(float64); ok {
+		config.TopP = topP
+	}
+	if stop, ok := options["stop"].([]string); ok {
+		config.StopSequences = stop
+	}
+	if config.MaxOutputTokens > 0 || config.Temperature > 0 || config.TopP > 0 || len(config.StopSequences) > 0 {
 		req.Config = config
 	}
 
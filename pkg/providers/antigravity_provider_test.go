@@ -54,3 +54,25 @@ func TestResolveToolResponseNameInfersNameFromGeneratedCallID(t *testing.T) {
 		t.Fatalf("expected inferred tool name search_docs, got %q", got)
 	}
 }
+
+func TestBuildRequestInlinesImages(t *testing.T) {
+	p := &AntigravityProvider{}
+
+	messages := []Message{{
+		Role:    "user",
+		Content: "what is this",
+		Media:   []string{"data:image/png;base64,iVBOR", "https://example.com/remote.png"},
+	}}
+
+	req := p.buildRequest(messages, nil, "", nil)
+	parts := req.Contents[0].Parts
+	if len(parts) != 2 {
+		t.Fatalf("expected text + inline image parts, got %d", len(parts))
+	}
+	if parts[1].InlineData == nil {
+		t.Fatal("expected inlineData part")
+	}
+	if parts[1].InlineData.MimeType != "image/png" || parts[1].InlineData.Data != "iVBOR" {
+		t.Fatalf("unexpected inlineData: %+v", parts[1].InlineData)
+	}
+}
@@ -0,0 +1,233 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+)
+
+const (
+	azureDefaultAPIVersion = "2024-10-21"
+	azureDefaultAuthority  = "https://login.microsoftonline.com"
+	azureDefaultScope      = "https://cognitiveservices.azure.com/.default"
+)
+
+// AzureConfig describes an Azure OpenAI resource.
+type AzureConfig struct {
+	Endpoint   string // https://<resource>.openai.azure.com
+	APIVersion string
+	APIKey     string            // sent as api-key; ignored when Token is set
+	Token      AzureTokenSource  // Microsoft Entra ID bearer tokens
+	Transport  http.RoundTripper // nil uses the default transport
+	Timeout    time.Duration
+}
+
+// AzureTokenSource returns a bearer token for Azure OpenAI requests.
+type AzureTokenSource func(ctx context.Context) (string, error)
+
+// AzureProvider talks to Azure OpenAI, which serves each model from a named
+// deployment (/openai/deployments/<name>/...) and versions its API with an
+// api-version query parameter. The model passed to Chat is the deployment
+// name, so fallbacks between deployments of one resource share a provider.
+type AzureProvider struct {
+	cfg       AzureConfig
+	transport http.RoundTripper
+
+	mu          sync.Mutex
+	deployments map[string]*openai_compat.Provider
+}
+
+// NewAzureProvider creates a provider for the resource described by cfg.
+func NewAzureProvider(cfg AzureConfig) *AzureProvider {
+	cfg.Endpoint = strings.TrimSuffix(strings.TrimRight(cfg.Endpoint, "/"), "/openai")
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = azureDefaultAPIVersion
+	}
+	base := cfg.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &AzureProvider{
+		cfg: cfg,
+		transport: &azureTransport{
+			base:       base,
+			apiVersion: cfg.APIVersion,
+			apiKey:     cfg.APIKey,
+			token:      cfg.Token,
+		},
+		deployments: make(map[string]*openai_compat.Provider),
+	}
+}
+
+// deployment returns the OpenAI-compatible client for a deployment.
+func (p *AzureProvider) deployment(name string) (*openai_compat.Provider, error) {
+	if name == "" {
+		return nil, fmt.Errorf("azure: deployment name is required (use model \"azure/<deployment>\")")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if d, ok := p.deployments[name]; ok {
+		return d, nil
+	}
+	d := openai_compat.NewProvider(
+		"", // authentication is added by azureTransport
+		p.cfg.Endpoint+"/openai/deployments/"+url.PathEscape(name),
+		"",
+		openai_compat.WithTransport(p.transport),
+		openai_compat.WithRequestTimeout(p.cfg.Timeout),
+	)
+	p.deployments[name] = d
+	return d, nil
+}
+
+func (p *AzureProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	d, err := p.deployment(model)
+	if err != nil {
+		return nil, err
+	}
+	return d.Chat(ctx, messages, tools, model, options)
+}
+
+// ChatStream implements StreamingProvider.
+func (p *AzureProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	d, err := p.deployment(model)
+	if err != nil {
+		return nil, err
+	}
+	return d.ChatStream(ctx, messages, tools, model, options)
+}
+
+func (p *AzureProvider) GetDefaultModel() string {
+	return ""
+}
+
+// azureTransport adds the api-version parameter and credentials to every
+// request sent to the resource.
+type azureTransport struct {
+	base       http.RoundTripper
+	apiVersion string
+	apiKey     string
+	token      AzureTokenSource
+}
+
+func (t *azureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	q := req.URL.Query()
+	if q.Get("api-version") == "" {
+		q.Set("api-version", t.apiVersion)
+		req.URL.RawQuery = q.Encode()
+	}
+
+	if t.token != nil {
+		tok, err := t.token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("azure: acquiring token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tok)
+	} else if t.apiKey != "" {
+		req.Header.Set("api-key", t.apiKey)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// NewAzureClientCredentialsTokenSource returns a token source that obtains
+// Microsoft Entra ID tokens with the OAuth2 client credentials grant and
+// caches them until shortly before they expire. Empty authority and scope
+// select the public cloud and the Cognitive Services scope.
+func NewAzureClientCredentialsTokenSource(
+	authority, tenantID, clientID, clientSecret, scope string,
+	rt http.RoundTripper,
+) AzureTokenSource {
+	if authority == "" {
+		authority = azureDefaultAuthority
+	}
+	if scope == "" {
+		scope = azureDefaultScope
+	}
+	tokenURL := strings.TrimRight(authority, "/") + "/" + url.PathEscape(tenantID) + "/oauth2/v2.0/token"
+	client := &http.Client{Timeout: 30 * time.Second, Transport: rt}
+
+	var (
+		mu      sync.Mutex
+		token   string
+		expires time.Time
+	)
+
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if token != "" && time.Now().Before(expires) {
+			return token, nil
+		}
+
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"scope":         {scope},
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			preview, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+			return "", fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(preview)))
+		}
+
+		var out struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return "", fmt.Errorf("parsing token response: %w", err)
+		}
+		if out.AccessToken == "" {
+			return "", fmt.Errorf("token response has no access_token")
+		}
+
+		// Refresh a minute early so in-flight requests never carry an
+		// expired token.
+		token = out.AccessToken
+		expires = time.Now().Add(time.Duration(out.ExpiresIn)*time.Second - time.Minute)
+		return token, nil
+	}
+}
@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const azureOKResponse = `{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`
+
+func TestAzureProvider_RoutesByDeploymentWithAPIKey(t *testing.T) {
+	var gotPath, gotVersion, gotKey, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotVersion = r.URL.Query().Get("api-version")
+		gotKey = r.Header.Get("api-key")
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(azureOKResponse))
+	}))
+	defer server.Close()
+
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "azure-gpt",
+		Model:     "azure/gpt4o-prod",
+		APIBase:   server.URL + "/",
+		APIKey:    "azure-key",
+		Azure:     &config.AzureOptions{APIVersion: "2025-01-01-preview"},
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, modelID, nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if gotPath != "/openai/deployments/gpt4o-prod/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotVersion != "2025-01-01-preview" {
+		t.Errorf("api-version = %q", gotVersion)
+	}
+	if gotKey != "azure-key" || gotAuth != "" {
+		t.Errorf("api-key = %q, Authorization = %q; want api-key only", gotKey, gotAuth)
+	}
+
+	// A fallback to another deployment of the same resource reuses the provider.
+	if _, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt4o-mini", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if gotPath != "/openai/deployments/gpt4o-mini/chat/completions" {
+		t.Errorf("path = %q", gotPath)
+	}
+}
+
+func TestAzureProvider_EntraIDClientCredentials(t *testing.T) {
+	var tokenRequests atomic.Int32
+	var gotAuth, gotKey string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenant-1/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		_ = r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_secret") != "s3cret" ||
+			r.PostForm.Get("scope") != azureDefaultScope {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "aad-token", "expires_in": 3600})
+	})
+	mux.HandleFunc("/openai/deployments/gpt4o/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotKey = r.Header.Get("api-key")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(azureOKResponse))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "azure-aad",
+		Model:     "azure/gpt4o",
+		APIBase:   server.URL,
+		APIKey:    "unused-key",
+		Azure: &config.AzureOptions{
+			TenantID:     "tenant-1",
+			ClientID:     "client-1",
+			ClientSecret: "s3cret",
+			AuthorityURL: server.URL,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	for range 2 {
+		if _, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, modelID, nil); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+
+	if gotAuth != "Bearer aad-token" || gotKey != "" {
+		t.Errorf("Authorization = %q, api-key = %q; want bearer token only", gotAuth, gotKey)
+	}
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("token requests = %d, want 1 (cached)", n)
+	}
+}
+
+func TestCreateProviderFromConfig_AzureValidation(t *testing.T) {
+	tests := []*config.ModelConfig{
+		{ModelName: "no-endpoint", Model: "azure/gpt4o", APIKey: "k"},
+		{ModelName: "no-credentials", Model: "azure/gpt4o", APIBase: "https://res.openai.azure.com"},
+		{
+			ModelName: "partial-aad", Model: "azure/gpt4o", APIBase: "https://res.openai.azure.com",
+			Azure: &config.AzureOptions{TenantID: "t", ClientID: "c"},
+		},
+	}
+	for _, cfg := range tests {
+		if _, _, err := CreateProviderFromConfig(cfg); err == nil {
+			t.Errorf("CreateProviderFromConfig(%s) expected error", cfg.ModelName)
+		}
+	}
+
+	p := NewAzureProvider(AzureConfig{Endpoint: "https://res.openai.azure.com", APIKey: "k"})
+	if _, err := p.Chat(t.Context(), nil, nil, "", nil); err == nil || !strings.Contains(err.Error(), "deployment") {
+		t.Errorf("Chat() without deployment error = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package providers
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	anthropicprovider "github.com/sipeed/picoclaw/pkg/providers/anthropic"
 )
@@ -37,6 +38,18 @@ func NewClaudeProviderWithTokenSourceAndBaseURL(
 	}
 }
 
+// NewClaudeProviderWithAPIKey creates a native Messages API provider that
+// authenticates with an API key instead of OAuth credentials.
+func NewClaudeProviderWithAPIKey(
+	apiKey, apiBase string,
+	rt http.RoundTripper,
+	requestTimeoutSeconds int,
+) *ClaudeProvider {
+	return &ClaudeProvider{
+		delegate: anthropicprovider.NewProviderWithAPIKey(apiKey, apiBase, rt, requestTimeoutSeconds),
+	}
+}
+
 func newClaudeProviderWithDelegate(delegate *anthropicprovider.Provider) *ClaudeProvider {
 	return &ClaudeProvider{delegate: delegate}
 }
@@ -51,6 +64,13 @@ func (p *ClaudeProvider) Chat(
 	return resp, nil
 }
 
+// ChatStream implements StreamingProvider.
+func (p *ClaudeProvider) ChatStream(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (<-chan StreamDelta, error) {
+	return p.delegate.ChatStream(ctx, messages, tools, model, options)
+}
+
 func (p *ClaudeProvider) GetDefaultModel() string {
 	return p.delegate.GetDefaultModel()
 }
@@ -13,6 +13,7 @@ import (
 
 	"github.com/sipeed/picoclaw/pkg/auth"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 const (
@@ -95,6 +96,10 @@ func (p *CodexProvider) Chat(
 		)
 	}
 
+	for k, v := range protocoltypes.RequestHeaders(ctx) {
+		opts = append(opts, option.WithHeader(k, v))
+	}
+
 	params := buildCodexParams(messages, tools, resolvedModel, options, p.enableWebSearch)
 
 	stream := p.client.Responses.NewStreaming(ctx, params, opts...)
@@ -228,7 +233,7 @@ func buildCodexParams(
 				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
 					OfMessage: &responses.EasyInputMessageParam{
 						Role:    responses.EasyInputMessageRoleUser,
-						Content: responses.EasyInputMessageContentUnionParam{OfString: openai.Opt(msg.Content)},
+						Content: codexUserContent(msg),
 					},
 				})
 			}
@@ -308,6 +313,26 @@ func buildCodexParams(
 	return params
 }
 
+// codexUserContent returns plain string content for text-only messages and an
+// input_text + input_image list when the message carries images.
+func codexUserContent(msg Message) responses.EasyInputMessageContentUnionParam {
+	images := msg.ImageParts()
+	if len(images) == 0 {
+		return responses.EasyInputMessageContentUnionParam{OfString: openai.Opt(msg.Content)}
+	}
+
+	parts := make(responses.ResponseInputMessageContentListParam, 0, len(images)+1)
+	if msg.Content != "" {
+		parts = append(parts, responses.ResponseInputContentParamOfInputText(msg.Content))
+	}
+	for _, image := range images {
+		part := responses.ResponseInputContentParamOfInputImage(responses.ResponseInputImageDetailAuto)
+		part.OfInputImage.ImageURL = openai.Opt(image.DataURL())
+		parts = append(parts, part)
+	}
+	return responses.EasyInputMessageContentUnionParam{OfInputItemContentList: parts}
+}
+
 func resolveCodexToolCall(tc ToolCall) (name string, arguments string, ok bool) {
 	name = tc.Name
 	if name == "" && tc.Function != nil {
@@ -401,6 +426,9 @@ func parseCodexResponse(resp *responses.Response) *LLMResponse {
 			CompletionTokens: int(resp.Usage.OutputTokens),
 			TotalTokens:      int(resp.Usage.TotalTokens),
 		}
+		if cached := resp.Usage.InputTokensDetails.CachedTokens; cached > 0 {
+			usage.PromptTokensDetails = &PromptTokensDetails{CachedTokens: int(cached)}
+		}
 	}
 
 	return &LLMResponse{
@@ -34,6 +34,28 @@ func TestBuildCodexParams_BasicMessage(t *testing.T) {
 	}
 }
 
+func TestBuildCodexParams_UserImage(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "describe", Media: []string{"data:image/jpeg;base64,AAAA"}},
+	}
+	params := buildCodexParams(messages, nil, "gpt-4o", nil, false)
+
+	msg := params.Input.OfInputItemList[0].OfMessage
+	if msg == nil {
+		t.Fatal("expected user message input item")
+	}
+	parts := msg.Content.OfInputItemContentList
+	if len(parts) != 2 {
+		t.Fatalf("expected text + image parts, got %d", len(parts))
+	}
+	if parts[0].OfInputText == nil || parts[0].OfInputText.Text != "describe" {
+		t.Errorf("first part = %+v, want input_text", parts[0])
+	}
+	if parts[1].OfInputImage == nil || parts[1].OfInputImage.ImageURL.Or("") != "data:image/jpeg;base64,AAAA" {
+		t.Errorf("second part = %+v, want input_image with data URL", parts[1])
+	}
+}
+
 func TestBuildCodexParams_SystemAsInstructions(t *testing.T) {
 	messages := []Message{
 		{Role: "system", Content: "You are helpful"},
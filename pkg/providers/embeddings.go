@@ -0,0 +1,152 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+)
+
+const defaultOllamaBase = "http://localhost:11434"
+
+// HTTPEmbedder implements EmbeddingProvider against an OpenAI-compatible
+// /embeddings endpoint.
+type HTTPEmbedder struct {
+	delegate *openai_compat.Provider
+	model    string
+}
+
+// NewHTTPEmbedder creates an embedder for model served at apiBase. rt may be
+// nil to use the default transport.
+func NewHTTPEmbedder(apiKey, apiBase, model string, rt http.RoundTripper, requestTimeoutSeconds int) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		delegate: openai_compat.NewProvider(
+			apiKey,
+			apiBase,
+			"",
+			openai_compat.WithTransport(rt),
+			openai_compat.WithRequestTimeout(time.Duration(requestTimeoutSeconds)*time.Second),
+		),
+		model: model,
+	}
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.delegate.Embed(ctx, e.model, texts)
+}
+
+// OllamaEmbedder implements EmbeddingProvider with Ollama's native /api/embed
+// endpoint, which batches inputs and works with every local embedding model.
+type OllamaEmbedder struct {
+	apiBase    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOllamaEmbedder creates an embedder for a local Ollama server. apiBase may
+// be given with or without the OpenAI-compatible "/v1" suffix; rt may be nil
+// to use the default transport.
+func NewOllamaEmbedder(apiBase, model string, rt http.RoundTripper, requestTimeoutSeconds int) *OllamaEmbedder {
+	apiBase = strings.TrimSuffix(strings.TrimRight(apiBase, "/"), "/v1")
+	if apiBase == "" {
+		apiBase = defaultOllamaBase
+	}
+
+	client := &http.Client{Timeout: 120 * time.Second, Transport: rt}
+	if requestTimeoutSeconds > 0 {
+		client.Timeout = time.Duration(requestTimeoutSeconds) * time.Second
+	}
+
+	return &OllamaEmbedder{apiBase: apiBase, model: model, httpClient: client}
+}
+
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	body, err := json.Marshal(map[string]any{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", e.apiBase+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return nil, &openai_compat.APIError{
+			StatusCode: resp.StatusCode,
+			Err: fmt.Errorf("ollama embed request failed:\n  Status: %d\n  Body:   %s",
+				resp.StatusCode, strings.TrimSpace(string(preview))),
+		}
+	}
+
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d inputs", len(out.Embeddings), len(texts))
+	}
+	return out.Embeddings, nil
+}
+
+// CreateEmbeddingProviderFromConfig creates an embedder for the model_list
+// entry cfg. The "ollama" protocol uses Ollama's native API; every other
+// OpenAI-compatible HTTP protocol uses the /embeddings endpoint.
+func CreateEmbeddingProviderFromConfig(cfg *config.ModelConfig) (EmbeddingProvider, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+
+	protocol, modelID := ExtractProtocol(cfg.Model)
+	rt, err := providerTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch protocol {
+	case "ollama":
+		return NewOllamaEmbedder(cfg.APIBase, modelID, rt, cfg.RequestTimeout), nil
+
+	case "openai", "litellm", "openrouter", "zhipu", "gemini", "nvidia",
+		"moonshot", "shengsuanyun", "deepseek", "volcengine", "vllm", "qwen", "mistral":
+		if cfg.APIKey == "" && cfg.APIBase == "" {
+			return nil, fmt.Errorf("api_key or api_base is required for HTTP-based protocol %q", protocol)
+		}
+		apiBase := cfg.APIBase
+		if apiBase == "" {
+			apiBase = getDefaultAPIBase(protocol)
+		}
+		return NewHTTPEmbedder(cfg.APIKey, apiBase, modelID, rt, cfg.RequestTimeout), nil
+
+	default:
+		return nil, fmt.Errorf("protocol %q does not support embeddings (model %q)", protocol, cfg.Model)
+	}
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestOllamaEmbedder_UsesNativeEndpoint(t *testing.T) {
+	var requestBody map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[0.1,0.2],[0.3,0.4]]}`))
+	}))
+	defer server.Close()
+
+	// The OpenAI-compatible "/v1" suffix used for chat is stripped.
+	e := NewOllamaEmbedder(server.URL+"/v1", "nomic-embed-text", nil, 0)
+	vectors, err := e.Embed(t.Context(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if requestBody["model"] != "nomic-embed-text" {
+		t.Errorf("model = %v, want nomic-embed-text", requestBody["model"])
+	}
+	if len(vectors) != 2 || vectors[1][1] != 0.4 {
+		t.Errorf("vectors = %v, want [[0.1 0.2] [0.3 0.4]]", vectors)
+	}
+}
+
+func TestOllamaEmbedder_CountMismatchIsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"embeddings":[[0.1]]}`))
+	}))
+	defer server.Close()
+
+	e := NewOllamaEmbedder(server.URL, "m", nil, 0)
+	if _, err := e.Embed(t.Context(), []string{"a", "b"}); err == nil {
+		t.Fatal("Embed() expected error for count mismatch")
+	}
+}
+
+func TestCreateEmbeddingProviderFromConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *config.ModelConfig
+		wantType string
+		wantErr  bool
+	}{
+		{
+			name:     "openai",
+			cfg:      &config.ModelConfig{Model: "openai/text-embedding-3-small", APIKey: "k"},
+			wantType: "*providers.HTTPEmbedder",
+		},
+		{
+			name:     "ollama without api_base",
+			cfg:      &config.ModelConfig{Model: "ollama/nomic-embed-text"},
+			wantType: "*providers.OllamaEmbedder",
+		},
+		{
+			name:    "openai without credentials",
+			cfg:     &config.ModelConfig{Model: "openai/text-embedding-3-small"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported protocol",
+			cfg:     &config.ModelConfig{Model: "anthropic/claude-sonnet-4.6", APIKey: "k"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := CreateEmbeddingProviderFromConfig(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %T", e)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateEmbeddingProviderFromConfig() error = %v", err)
+			}
+			if got := fmt.Sprintf("%T", e); got != tt.wantType {
+				t.Errorf("type = %s, want %s", got, tt.wantType)
+			}
+		})
+	}
+}
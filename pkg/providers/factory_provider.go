@@ -7,9 +7,13 @@ package providers
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+	"github.com/sipeed/picoclaw/pkg/providers/transport"
 )
 
 // createClaudeAuthProvider creates a Claude provider using OAuth credentials from auth store.
@@ -53,9 +57,59 @@ func ExtractProtocol(model string) (protocol, modelID string) {
 
 // CreateProviderFromConfig creates a provider based on the ModelConfig.
 // It uses the protocol prefix in the Model field to determine which provider to create.
-// Supported protocols: openai, litellm, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, litellm, anthropic, anthropic-messages, antigravity, claude-cli, codex-cli,
+// github-copilot, replay, azure
+// When rpm, tpm or max_retries is set, the provider is wrapped in a RateLimitedProvider.
+// When cassette is set on a non-replay protocol, traffic is recorded to it.
+// headers, redact and log_requests add the matching middleware around it all.
 // Returns the provider, the model ID (without protocol prefix), and any error.
 func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
+	provider, modelID, err := createProviderFromConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.RPM > 0 || cfg.TPM > 0 || cfg.MaxRetries > 0 {
+		provider = NewRateLimitedProvider(provider, RateLimitConfig{
+			RPM:        cfg.RPM,
+			TPM:        cfg.TPM,
+			MaxRetries: cfg.MaxRetries,
+		})
+	}
+	if protocol, _ := ExtractProtocol(cfg.Model); cfg.Cassette != "" && protocol != "replay" {
+		provider = NewRecordingProvider(provider, cfg.Cassette)
+	}
+	mws, err := middlewareFromConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(mws) > 0 {
+		provider = WithMiddleware(provider, mws...)
+	}
+	return provider, modelID, nil
+}
+
+// middlewareFromConfig returns the middleware requested by cfg, outermost
+// first. It wraps rate limiting and recording, so cassettes hold redacted
+// messages and the log reports one entry per call rather than per retry.
+func middlewareFromConfig(cfg *config.ModelConfig) ([]Middleware, error) {
+	var mws []Middleware
+	if cfg.LogRequests {
+		mws = append(mws, LoggingMiddleware())
+	}
+	if len(cfg.Redact) > 0 {
+		mw, err := RedactionMiddleware(cfg.Redact)
+		if err != nil {
+			return nil, err
+		}
+		mws = append(mws, mw)
+	}
+	if len(cfg.Headers) > 0 {
+		mws = append(mws, HeaderMiddleware(cfg.Headers))
+	}
+	return mws, nil
+}
+
+func createProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
 	if cfg == nil {
 		return nil, "", fmt.Errorf("config is nil")
 	}
@@ -84,15 +138,34 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		if apiBase == "" {
 			apiBase = getDefaultAPIBase(protocol)
 		}
-		return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
-			cfg.APIKey,
-			apiBase,
-			cfg.Proxy,
-			cfg.MaxTokensField,
-			cfg.RequestTimeout,
-		), modelID, nil
+		provider, err := newHTTPProviderFromConfig(cfg, apiBase)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
+
+	case "openrouter":
+		if cfg.APIKey == "" && cfg.APIBase == "" {
+			return nil, "", fmt.Errorf("api_key or api_base is required for HTTP-based protocol %q", protocol)
+		}
+		apiBase := cfg.APIBase
+		if apiBase == "" {
+			apiBase = getDefaultAPIBase(protocol)
+		}
+		provider, err := newHTTPProviderFromConfig(cfg, apiBase, openRouterRequestOptions(cfg.OpenRouter)...)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
 
-	case "litellm", "openrouter", "groq", "zhipu", "gemini", "nvidia",
+	case "azure", "azure-openai":
+		provider, err := newAzureProviderFromConfig(cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
+
+	case "litellm", "groq", "zhipu", "gemini", "nvidia",
 		"ollama", "moonshot", "shengsuanyun", "deepseek", "cerebras",
 		"volcengine", "vllm", "qwen", "mistral", "avian":
 		// All other OpenAI-compatible HTTP providers
@@ -103,13 +176,11 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		if apiBase == "" {
 			apiBase = getDefaultAPIBase(protocol)
 		}
-		return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
-			cfg.APIKey,
-			apiBase,
-			cfg.Proxy,
-			cfg.MaxTokensField,
-			cfg.RequestTimeout,
-		), modelID, nil
+		provider, err := newHTTPProviderFromConfig(cfg, apiBase)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
 
 	case "anthropic":
 		if cfg.AuthMethod == "oauth" || cfg.AuthMethod == "token" {
@@ -128,17 +199,43 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 		if cfg.APIKey == "" {
 			return nil, "", fmt.Errorf("api_key is required for anthropic protocol (model: %s)", cfg.Model)
 		}
-		return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
+		provider, err := newHTTPProviderFromConfig(cfg, apiBase)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
+
+	case "anthropic-messages":
+		// Native Anthropic Messages API (tool_use blocks, system prompt blocks)
+		// authenticated with an API key.
+		if cfg.APIKey == "" {
+			return nil, "", fmt.Errorf("api_key is required for anthropic-messages protocol (model: %s)", cfg.Model)
+		}
+		rt, err := providerTransport(cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return NewClaudeProviderWithAPIKey(
 			cfg.APIKey,
-			apiBase,
-			cfg.Proxy,
-			cfg.MaxTokensField,
+			cfg.APIBase,
+			rt,
 			cfg.RequestTimeout,
 		), modelID, nil
 
 	case "antigravity":
 		return NewAntigravityProvider(), modelID, nil
 
+	case "replay":
+		// Offline provider serving a cassette recorded earlier (see RecordingProvider).
+		if cfg.Cassette == "" {
+			return nil, "", fmt.Errorf("cassette is required for replay protocol (model: %s)", cfg.Model)
+		}
+		provider, err := LoadReplayProvider(cfg.Cassette)
+		if err != nil {
+			return nil, "", err
+		}
+		return provider, modelID, nil
+
 	case "claude-cli", "claudecli":
 		workspace := cfg.Workspace
 		if workspace == "" {
@@ -173,6 +270,95 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 	}
 }
 
+// newHTTPProviderFromConfig creates an OpenAI-compatible provider for cfg,
+// applying its transport settings.
+func newHTTPProviderFromConfig(
+	cfg *config.ModelConfig,
+	apiBase string,
+	extra ...openai_compat.Option,
+) (*HTTPProvider, error) {
+	rt, err := providerTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := append([]openai_compat.Option{
+		openai_compat.WithMaxTokensField(cfg.MaxTokensField),
+		openai_compat.WithRequestTimeout(time.Duration(cfg.RequestTimeout) * time.Second),
+		openai_compat.WithTransport(rt),
+	}, extra...)
+	return &HTTPProvider{
+		delegate: openai_compat.NewProvider(cfg.APIKey, apiBase, cfg.Proxy, opts...),
+	}, nil
+}
+
+// newAzureProviderFromConfig creates an Azure OpenAI provider, authenticating
+// with Entra ID client credentials when configured and the API key otherwise.
+func newAzureProviderFromConfig(cfg *config.ModelConfig) (*AzureProvider, error) {
+	if cfg.APIBase == "" {
+		return nil, fmt.Errorf("api_base (https://<resource>.openai.azure.com) is required for azure protocol (model: %s)",
+			cfg.Model)
+	}
+	rt, err := providerTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	azureCfg := AzureConfig{
+		Endpoint:  cfg.APIBase,
+		APIKey:    cfg.APIKey,
+		Transport: rt,
+		Timeout:   time.Duration(cfg.RequestTimeout) * time.Second,
+	}
+	if opts := cfg.Azure; opts != nil {
+		azureCfg.APIVersion = opts.APIVersion
+		if opts.TenantID != "" || opts.ClientID != "" {
+			if opts.TenantID == "" || opts.ClientID == "" || opts.ClientSecret == "" {
+				return nil, fmt.Errorf("azure: tenant_id, client_id and client_secret are all required for "+
+					"Entra ID auth (model: %s)", cfg.Model)
+			}
+			azureCfg.Token = NewAzureClientCredentialsTokenSource(
+				opts.AuthorityURL, opts.TenantID, opts.ClientID, opts.ClientSecret, opts.Scope, rt)
+		}
+	}
+	if azureCfg.Token == nil && azureCfg.APIKey == "" {
+		return nil, fmt.Errorf("api_key or azure client credentials are required for azure protocol (model: %s)",
+			cfg.Model)
+	}
+	return NewAzureProvider(azureCfg), nil
+}
+
+// providerTransport builds the HTTP transport described by cfg's proxy,
+// ca_file, connect_timeout, read_timeout and keep_alive settings. It returns
+// nil when none are set so the client keeps Go's default transport.
+func providerTransport(cfg *config.ModelConfig) (http.RoundTripper, error) {
+	opts := transport.Options{
+		Proxy:          cfg.Proxy,
+		CAFile:         cfg.CAFile,
+		ConnectTimeout: time.Duration(cfg.ConnectTimeout) * time.Second,
+		ReadTimeout:    time.Duration(cfg.ReadTimeout) * time.Second,
+		KeepAlive:      time.Duration(cfg.KeepAlive) * time.Second,
+	}
+	if opts.IsZero() {
+		return nil, nil
+	}
+	rt, err := transport.New(opts)
+	if err != nil {
+		return nil, fmt.Errorf("model %q: %w", cfg.ModelName, err)
+	}
+	return rt, nil
+}
+
+// APIBase returns the URL the provider of cfg talks to: its api_base, or
+// the default of its protocol. It is "" for providers without an HTTP API,
+// such as the CLI-based ones.
+func APIBase(cfg *config.ModelConfig) string {
+	if cfg.APIBase != "" {
+		return cfg.APIBase
+	}
+	protocol, _ := ExtractProtocol(cfg.Model)
+	return getDefaultAPIBase(protocol)
+}
+
 // getDefaultAPIBase returns the default API base URL for a given protocol.
 func getDefaultAPIBase(protocol string) string {
 	switch protocol {
@@ -6,8 +6,12 @@
 package providers
 
 import (
+	"encoding/pem"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -141,6 +145,23 @@ func TestGetDefaultAPIBase_LiteLLM(t *testing.T) {
 	}
 }
 
+func TestAPIBase(t *testing.T) {
+	tests := []struct {
+		cfg  config.ModelConfig
+		want string
+	}{
+		{config.ModelConfig{Model: "openai/gpt-4o"}, "https://api.openai.com/v1"},
+		{config.ModelConfig{Model: "gpt-4o"}, "https://api.openai.com/v1"},
+		{config.ModelConfig{Model: "openai/gpt-4o", APIBase: "http://10.0.0.2:8080/v1"}, "http://10.0.0.2:8080/v1"},
+		{config.ModelConfig{Model: "claude-cli/claude"}, ""},
+	}
+	for _, tt := range tests {
+		if got := APIBase(&tt.cfg); got != tt.want {
+			t.Errorf("APIBase(%q, %q) = %q, want %q", tt.cfg.Model, tt.cfg.APIBase, got, tt.want)
+		}
+	}
+}
+
 func TestCreateProviderFromConfig_LiteLLM(t *testing.T) {
 	cfg := &config.ModelConfig{
 		ModelName: "test-litellm",
@@ -180,6 +201,33 @@ func TestCreateProviderFromConfig_Anthropic(t *testing.T) {
 	}
 }
 
+func TestCreateProviderFromConfig_AnthropicMessages(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "test-anthropic-messages",
+		Model:     "anthropic-messages/claude-sonnet-4.6",
+		APIKey:    "test-key",
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*ClaudeProvider); !ok {
+		t.Fatalf("provider type = %T, want *ClaudeProvider", provider)
+	}
+	if _, ok := provider.(StreamingProvider); !ok {
+		t.Error("anthropic-messages provider should implement StreamingProvider")
+	}
+	if modelID != "claude-sonnet-4.6" {
+		t.Errorf("modelID = %q, want %q", modelID, "claude-sonnet-4.6")
+	}
+
+	cfg.APIKey = ""
+	if _, _, err := CreateProviderFromConfig(cfg); err == nil {
+		t.Error("CreateProviderFromConfig() expected error without api_key")
+	}
+}
+
 func TestCreateProviderFromConfig_Antigravity(t *testing.T) {
 	cfg := &config.ModelConfig{
 		ModelName: "test-antigravity",
@@ -316,3 +364,102 @@ func TestCreateProviderFromConfig_RequestTimeoutPropagation(t *testing.T) {
 		t.Fatalf("Chat() error = %q, want timeout-related error", errMsg)
 	}
 }
+
+func TestCreateProviderFromConfig_CAFileTrustsPrivateGateway(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, model := range []string{"openai/gpt-4o", "vllm/llama"} {
+		provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+			ModelName:      "private",
+			Model:          model,
+			APIBase:        server.URL,
+			CAFile:         caFile,
+			ConnectTimeout: 5,
+		})
+		if err != nil {
+			t.Fatalf("CreateProviderFromConfig(%s) error = %v", model, err)
+		}
+		resp, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, modelID, nil)
+		if err != nil {
+			t.Fatalf("Chat(%s) error = %v", model, err)
+		}
+		if resp.Content != "ok" {
+			t.Errorf("Content = %q, want ok", resp.Content)
+		}
+	}
+}
+
+func TestCreateProviderFromConfig_InvalidTransportSettings(t *testing.T) {
+	tests := []*config.ModelConfig{
+		{ModelName: "bad-proxy", Model: "openai/gpt-4o", APIKey: "k", Proxy: "ftp://127.0.0.1:21"},
+		{ModelName: "bad-ca", Model: "openai/gpt-4o", APIKey: "k", CAFile: "/nonexistent/ca.pem"},
+		{ModelName: "bad-ca-claude", Model: "anthropic-messages/claude", APIKey: "k", CAFile: "/nonexistent/ca.pem"},
+	}
+	for _, cfg := range tests {
+		if _, _, err := CreateProviderFromConfig(cfg); err == nil {
+			t.Errorf("CreateProviderFromConfig(%s) expected error", cfg.ModelName)
+		}
+	}
+}
+
+func TestCreateProviderFromConfig_MiddlewareHeadersAndRedaction(t *testing.T) {
+	var gotHeader, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Tenant")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "gateway",
+		Model:     "openai/gpt-4o",
+		APIBase:   server.URL,
+		Headers:   map[string]string{"X-Tenant": "acme"},
+		Redact:    []string{`sk-[A-Za-z0-9]+`},
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if _, ok := provider.(*MiddlewareProvider); !ok {
+		t.Fatalf("provider = %T, want *MiddlewareProvider", provider)
+	}
+
+	msgs := []Message{{Role: "user", Content: "my key is sk-abc123"}}
+	if _, err := provider.Chat(t.Context(), msgs, nil, modelID, nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if gotHeader != "acme" {
+		t.Errorf("X-Tenant = %q, want acme", gotHeader)
+	}
+	if strings.Contains(gotBody, "sk-abc123") || !strings.Contains(gotBody, "[REDACTED]") {
+		t.Errorf("request body was not redacted: %s", gotBody)
+	}
+	if msgs[0].Content != "my key is sk-abc123" {
+		t.Errorf("caller's message was modified: %q", msgs[0].Content)
+	}
+}
+
+func TestCreateProviderFromConfig_InvalidRedactPattern(t *testing.T) {
+	_, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "bad-redact",
+		Model:     "openai/gpt-4o",
+		APIKey:    "k",
+		Redact:    []string{"("},
+	})
+	if err == nil {
+		t.Fatal("CreateProviderFromConfig() expected error for invalid redact pattern")
+	}
+}
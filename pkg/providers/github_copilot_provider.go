@@ -84,7 +84,7 @@ func (p *GitHubCopilotProvider) Chat(
 	out := make([]tempMessage, 0, len(messages))
 	for _, msg := range messages {
 		out = append(out, tempMessage{
-			Role:    msg.Role,
+			Role:    string(msg.Role),
 			Content: msg.Content,
 		})
 	}
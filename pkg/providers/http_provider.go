@@ -55,3 +55,14 @@ func (p *HTTPProvider) Chat(
 func (p *HTTPProvider) GetDefaultModel() string {
 	return ""
 }
+
+// ChatStream implements StreamingProvider via the OpenAI-compatible SSE endpoint.
+func (p *HTTPProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	return p.delegate.ChatStream(ctx, messages, tools, model, options)
+}
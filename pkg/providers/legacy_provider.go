@@ -56,6 +56,9 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to create provider for model %q: %w", model, err)
 	}
+	if cfg.Telemetry.Enabled {
+		provider = WithMiddleware(provider, TracingMiddleware())
+	}
 
 	return provider, modelID, nil
 }
@@ -0,0 +1,295 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
+)
+
+// ChatRequest is one provider call as seen by middleware. Middleware may
+// replace its fields before calling the next handler; the slices and options
+// map belong to the caller and must be copied, not modified in place.
+type ChatRequest struct {
+	Messages []Message
+	Tools    []ToolDefinition
+	Model    string
+	Options  map[string]any
+}
+
+// ChatHandler performs a provider call.
+type ChatHandler func(ctx context.Context, req *ChatRequest) (*LLMResponse, error)
+
+// Middleware intercepts provider calls. It receives the next handler in the
+// chain and returns a handler that may inspect or rewrite the request, call
+// next (or not), and inspect or rewrite the response.
+type Middleware func(next ChatHandler) ChatHandler
+
+// MiddlewareProvider runs every call to the wrapped provider through a chain
+// of middleware, so cross-cutting concerns such as logging, redaction and
+// header injection live in one place instead of in each provider.
+type MiddlewareProvider struct {
+	inner LLMProvider
+	chain []Middleware
+}
+
+// WithMiddleware wraps inner with mws. The first middleware is the outermost:
+// it sees the request first and the response last.
+func WithMiddleware(inner LLMProvider, mws ...Middleware) *MiddlewareProvider {
+	return &MiddlewareProvider{inner: inner, chain: mws}
+}
+
+// handler builds the chain around terminal.
+func (p *MiddlewareProvider) handler(terminal ChatHandler) ChatHandler {
+	h := terminal
+	for i := len(p.chain) - 1; i >= 0; i-- {
+		h = p.chain[i](h)
+	}
+	return h
+}
+
+func (p *MiddlewareProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	h := p.handler(func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+		return p.inner.Chat(ctx, req.Messages, req.Tools, req.Model, req.Options)
+	})
+	return h(ctx, &ChatRequest{Messages: messages, Tools: tools, Model: model, Options: options})
+}
+
+// ChatStream implements StreamingProvider. Requests pass through the chain as
+// for Chat; fragments are forwarded as they arrive, and the terminal delta
+// carries the response as returned by the outermost middleware. A middleware
+// that calls next more than once (e.g. to retry) forwards fragments from
+// every attempt.
+func (p *MiddlewareProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	out := make(chan StreamDelta, 16)
+	ready := make(chan error, 1)
+	var started atomic.Bool
+
+	h := p.handler(func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+		deltas, err := ChatStream(ctx, p.inner, req.Messages, req.Tools, req.Model, req.Options)
+		if err != nil {
+			return nil, err
+		}
+		if started.CompareAndSwap(false, true) {
+			ready <- nil
+		}
+		return CollectStream(deltas, func(d StreamDelta) {
+			select {
+			case out <- d:
+			case <-ctx.Done():
+			}
+		})
+	})
+
+	go func() {
+		defer close(out)
+		resp, err := h(ctx, &ChatRequest{Messages: messages, Tools: tools, Model: model, Options: options})
+		if !started.Load() {
+			// The chain finished without opening a stream: either it failed
+			// before the stream was established or a middleware answered
+			// on its own.
+			if err != nil {
+				ready <- err
+				return
+			}
+			ready <- nil
+			if resp != nil && (resp.Content != "" || resp.ReasoningContent != "") {
+				out <- StreamDelta{Content: resp.Content, ReasoningContent: resp.ReasoningContent}
+			}
+		}
+		select {
+		case out <- StreamDelta{Done: true, Response: resp, Err: err}:
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := <-ready; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *MiddlewareProvider) GetDefaultModel() string {
+	return p.inner.GetDefaultModel()
+}
+
+// SupportsThinking forwards ThinkingCapable to the wrapped provider.
+func (p *MiddlewareProvider) SupportsThinking() bool {
+	tc, ok := p.inner.(ThinkingCapable)
+	return ok && tc.SupportsThinking()
+}
+
+// Close forwards StatefulProvider to the wrapped provider.
+func (p *MiddlewareProvider) Close() {
+	if sp, ok := p.inner.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+// Unwrap returns the wrapped provider.
+func (p *MiddlewareProvider) Unwrap() LLMProvider {
+	return p.inner
+}
+
+// LoggingMiddleware logs every call with its model, size, latency, token
+// usage and error at debug level.
+func LoggingMiddleware() Middleware {
+	return func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			start := time.Now()
+			resp, err := next(ctx, req)
+
+			fields := map[string]any{
+				"model":       req.Model,
+				"messages":    len(req.Messages),
+				"tools":       len(req.Tools),
+				"duration_ms": time.Since(start).Milliseconds(),
+			}
+			if resp != nil && resp.Usage != nil {
+				fields["prompt_tokens"] = resp.Usage.PromptTokens
+				fields["completion_tokens"] = resp.Usage.CompletionTokens
+				fields["cached_tokens"] = resp.Usage.CachedTokens()
+			}
+			if err != nil {
+				fields["error"] = err.Error()
+			}
+			logger.DebugCF("provider", "Provider call", fields)
+			return resp, err
+		}
+	}
+}
+
+// TracingMiddleware records every call as an OpenTelemetry span with its
+// model, size and token usage, as a child of the turn that made it.
+func TracingMiddleware() Middleware {
+	return func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			ctx, span := telemetry.Start(ctx, "provider.chat",
+				attribute.String("gen_ai.request.model", req.Model),
+				attribute.Int("picoclaw.messages", len(req.Messages)),
+				attribute.Int("picoclaw.tools", len(req.Tools)),
+			)
+			resp, err := next(ctx, req)
+			if resp != nil {
+				span.SetAttributes(attribute.Int("picoclaw.tool_calls", len(resp.ToolCalls)))
+				if resp.Usage != nil {
+					span.SetAttributes(
+						attribute.Int("gen_ai.usage.input_tokens", resp.Usage.PromptTokens),
+						attribute.Int("gen_ai.usage.output_tokens", resp.Usage.CompletionTokens),
+					)
+				}
+			}
+			telemetry.End(span, err)
+			return resp, err
+		}
+	}
+}
+
+// redactedPlaceholder replaces every match of a redaction pattern.
+const redactedPlaceholder = "[REDACTED]"
+
+// RedactionMiddleware replaces matches of patterns in outgoing message
+// content, system blocks and tool call arguments with "[REDACTED]", so
+// secrets that reached the conversation (pasted keys, tool output) are not
+// sent to the provider. Responses are passed through unchanged.
+func RedactionMiddleware(patterns []string) (Middleware, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+
+	redact := func(s string) string {
+		for _, re := range res {
+			s = re.ReplaceAllString(s, redactedPlaceholder)
+		}
+		return s
+	}
+
+	return func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			if len(res) == 0 {
+				return next(ctx, req)
+			}
+			redacted := *req
+			redacted.Messages = make([]Message, len(req.Messages))
+			for i, m := range req.Messages {
+				m.Content = redact(m.Content)
+				if len(m.SystemParts) > 0 {
+					parts := make([]ContentBlock, len(m.SystemParts))
+					for j, part := range m.SystemParts {
+						part.Text = redact(part.Text)
+						parts[j] = part
+					}
+					m.SystemParts = parts
+				}
+				if len(m.ToolCalls) > 0 {
+					calls := make([]ToolCall, len(m.ToolCalls))
+					for j, tc := range m.ToolCalls {
+						if tc.Function != nil {
+							fn := *tc.Function
+							fn.Arguments = redact(fn.Arguments)
+							tc.Function = &fn
+						}
+						calls[j] = tc
+					}
+					m.ToolCalls = calls
+				}
+				redacted.Messages[i] = m
+			}
+			return next(ctx, &redacted)
+		}
+	}, nil
+}
+
+// TokenCountingMiddleware calls record with the model and usage of every
+// successful call that reports usage.
+func TokenCountingMiddleware(record func(model string, usage *UsageInfo)) Middleware {
+	return func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			resp, err := next(ctx, req)
+			if err == nil && resp != nil && resp.Usage != nil {
+				record(req.Model, resp.Usage)
+			}
+			return resp, err
+		}
+	}
+}
+
+// HeaderMiddleware adds headers to every HTTP request the wrapped provider
+// makes for a call. It is honored by the OpenAI-compatible, Anthropic and
+// Codex providers; CLI-based providers ignore it.
+func HeaderMiddleware(headers map[string]string) Middleware {
+	return func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			return next(protocoltypes.WithRequestHeaders(ctx, headers), req)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+// tagMiddleware appends name to trace on the way in and out of a call.
+func tagMiddleware(name string, trace *[]string) Middleware {
+	return func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			*trace = append(*trace, name+">")
+			resp, err := next(ctx, req)
+			*trace = append(*trace, "<"+name)
+			return resp, err
+		}
+	}
+}
+
+func TestMiddlewareProvider_RunsChainOutermostFirst(t *testing.T) {
+	var trace []string
+	p := WithMiddleware(&cannedProvider{resp: &LLMResponse{Content: "ok"}},
+		tagMiddleware("a", &trace), tagMiddleware("b", &trace))
+
+	resp, err := p.Chat(t.Context(), nil, nil, "m", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want ok", resp.Content)
+	}
+	if want := []string{"a>", "b>", "<b", "<a"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+}
+
+func TestMiddlewareProvider_RewritesRequestAndResponse(t *testing.T) {
+	var gotModel string
+	inner := &modelCapturingProvider{model: &gotModel}
+	rewrite := func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			req.Model = "rewritten"
+			resp, err := next(ctx, req)
+			if resp != nil {
+				resp.Content += "!"
+			}
+			return resp, err
+		}
+	}
+
+	resp, err := WithMiddleware(inner, rewrite).Chat(t.Context(), nil, nil, "original", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if gotModel != "rewritten" {
+		t.Errorf("inner model = %q, want rewritten", gotModel)
+	}
+	if resp.Content != "ok!" {
+		t.Errorf("Content = %q, want ok!", resp.Content)
+	}
+}
+
+func TestMiddlewareProvider_ChatStreamAppliesChain(t *testing.T) {
+	var trace []string
+	p := WithMiddleware(&cannedProvider{resp: &LLMResponse{Content: "streamed"}}, tagMiddleware("a", &trace))
+
+	deltas, err := p.ChatStream(t.Context(), nil, nil, "m", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	var text string
+	resp, err := CollectStream(deltas, func(d StreamDelta) { text += d.Content })
+	if err != nil {
+		t.Fatalf("CollectStream() error = %v", err)
+	}
+	if text != "streamed" || resp.Content != "streamed" {
+		t.Errorf("text = %q, resp = %q, want streamed", text, resp.Content)
+	}
+	if want := []string{"a>", "<a"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %v, want %v", trace, want)
+	}
+}
+
+func TestMiddlewareProvider_ChatStreamReturnsEstablishmentError(t *testing.T) {
+	p := WithMiddleware(&cannedProvider{err: errors.New("connection refused")}, LoggingMiddleware())
+
+	if _, err := p.ChatStream(t.Context(), nil, nil, "m", nil); err == nil {
+		t.Fatal("ChatStream() expected error")
+	}
+}
+
+func TestMiddlewareProvider_ChatStreamShortCircuit(t *testing.T) {
+	cached := func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			return &LLMResponse{Content: "from cache"}, nil
+		}
+	}
+	inner := &cannedProvider{err: errors.New("must not be called")}
+
+	deltas, err := WithMiddleware(inner, cached).ChatStream(t.Context(), nil, nil, "m", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	resp, err := CollectStream(deltas, nil)
+	if err != nil {
+		t.Fatalf("CollectStream() error = %v", err)
+	}
+	if resp.Content != "from cache" {
+		t.Errorf("Content = %q, want from cache", resp.Content)
+	}
+}
+
+func TestRedactionMiddleware_MasksOutgoingContent(t *testing.T) {
+	var got *ChatRequest
+	capture := func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			got = req
+			return next(ctx, req)
+		}
+	}
+	redact, err := RedactionMiddleware([]string{`token=\w+`})
+	if err != nil {
+		t.Fatalf("RedactionMiddleware() error = %v", err)
+	}
+
+	msgs := []Message{
+		{Role: "system", SystemParts: []ContentBlock{{Type: "text", Text: "token=abc"}}},
+		{Role: "assistant", ToolCalls: []ToolCall{{
+			ID:       "call_1",
+			Function: &FunctionCall{Name: "exec", Arguments: `{"cmd":"curl ?token=abc"}`},
+		}}},
+		{Role: "tool", Content: "token=abc ok", ToolCallID: "call_1"},
+	}
+	p := WithMiddleware(&cannedProvider{resp: &LLMResponse{}}, redact, capture)
+	if _, err := p.Chat(t.Context(), msgs, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if got.Messages[0].SystemParts[0].Text != "[REDACTED]" {
+		t.Errorf("system part = %q", got.Messages[0].SystemParts[0].Text)
+	}
+	if args := got.Messages[1].ToolCalls[0].Function.Arguments; args != `{"cmd":"curl ?[REDACTED]"}` {
+		t.Errorf("tool call arguments = %q", args)
+	}
+	if got.Messages[2].Content != "[REDACTED] ok" {
+		t.Errorf("tool content = %q", got.Messages[2].Content)
+	}
+	if msgs[1].ToolCalls[0].Function.Arguments != `{"cmd":"curl ?token=abc"}` {
+		t.Error("caller's tool call was modified")
+	}
+}
+
+func TestTokenCountingMiddleware_RecordsUsage(t *testing.T) {
+	var total int
+	count := TokenCountingMiddleware(func(model string, usage *UsageInfo) {
+		if model == "m" {
+			total += usage.TotalTokens
+		}
+	})
+	inner := &cannedProvider{resp: &LLMResponse{Usage: &UsageInfo{TotalTokens: 7}}}
+	p := WithMiddleware(inner, count)
+
+	for range 2 {
+		if _, err := p.Chat(t.Context(), nil, nil, "m", nil); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	if total != 14 {
+		t.Errorf("total = %d, want 14", total)
+	}
+}
+
+func TestTracingMiddleware_RecordsSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	inner := &cannedProvider{resp: &LLMResponse{Usage: &UsageInfo{PromptTokens: 5, CompletionTokens: 2}}}
+	p := WithMiddleware(inner, TracingMiddleware())
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "provider.chat" {
+		t.Fatalf("spans = %v, want one provider.chat", spans)
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if attrs["gen_ai.request.model"].AsString() != "m" || attrs["gen_ai.usage.input_tokens"].AsInt64() != 5 {
+		t.Errorf("attributes = %v", attrs)
+	}
+}
+
+func TestHeaderMiddleware_AttachesHeadersToContext(t *testing.T) {
+	var got map[string]string
+	capture := func(next ChatHandler) ChatHandler {
+		return func(ctx context.Context, req *ChatRequest) (*LLMResponse, error) {
+			got = protocoltypes.RequestHeaders(ctx)
+			return next(ctx, req)
+		}
+	}
+	ctx := protocoltypes.WithRequestHeaders(t.Context(), map[string]string{"X-Base": "1", "X-Tenant": "old"})
+	p := WithMiddleware(&cannedProvider{resp: &LLMResponse{}},
+		HeaderMiddleware(map[string]string{"X-Tenant": "acme"}), capture)
+
+	if _, err := p.Chat(ctx, nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if want := map[string]string{"X-Base": "1", "X-Tenant": "acme"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("headers = %v, want %v", got, want)
+	}
+}
+
+type modelCapturingProvider struct {
+	model *string
+}
+
+func (p *modelCapturingProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	*p.model = model
+	return &LLMResponse{Content: "ok"}, nil
+}
+
+func (p *modelCapturingProvider) GetDefaultModel() string { return "capturing" }
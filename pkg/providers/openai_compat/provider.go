@@ -33,6 +33,8 @@ type Provider struct {
 	apiKey         string
 	apiBase        string
 	maxTokensField string // Field name for max tokens (e.g., "max_completion_tokens" for o1/glm models)
+	headers        map[string]string
+	extraBody      map[string]any
 	httpClient     *http.Client
 }
 
@@ -54,6 +56,33 @@ func WithRequestTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithTransport replaces the HTTP transport, including any proxy set via
+// NewProvider. A nil transport is ignored.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(p *Provider) {
+		if rt != nil {
+			p.httpClient.Transport = rt
+		}
+	}
+}
+
+// WithHeaders sets headers sent with every request. Headers attached to the
+// request context take precedence.
+func WithHeaders(headers map[string]string) Option {
+	return func(p *Provider) {
+		p.headers = headers
+	}
+}
+
+// WithExtraBody adds vendor-specific fields to every chat completion request
+// (e.g. OpenRouter's "models" and "provider"). Fields set from call options
+// take precedence.
+func WithExtraBody(fields map[string]any) Option {
+	return func(p *Provider) {
+		p.extraBody = fields
+	}
+}
+
 func NewProvider(apiKey, apiBase, proxy string, opts ...Option) *Provider {
 	client := &http.Client{
 		Timeout: defaultRequestTimeout,
@@ -113,6 +142,41 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("API base not configured")
 	}
 
+	requestBody := p.buildRequestBody(messages, tools, model, options)
+
+	resp, err := p.doRequest(ctx, "/chat/completions", requestBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+
+	// Peek without consuming so the full stream reaches the JSON decoder.
+	reader := bufio.NewReader(resp.Body)
+	prefix, err := reader.Peek(256) // io.EOF/ErrBufferFull are normal; only real errors abort
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to inspect response: %w", err)
+	}
+	if looksLikeHTML(prefix, contentType) {
+		return nil, wrapHTMLResponseError(resp.StatusCode, prefix, contentType, p.apiBase)
+	}
+
+	out, err := parseResponse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	return out, nil
+}
+
+// buildRequestBody assembles the /chat/completions payload shared by Chat and ChatStream.
+func (p *Provider) buildRequestBody(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) map[string]any {
 	model = normalizeModel(model, p.apiBase)
 
 	requestBody := map[string]any{
@@ -120,6 +184,12 @@ func (p *Provider) Chat(
 		"messages": serializeMessages(messages),
 	}
 
+	for k, v := range p.extraBody {
+		if _, exists := requestBody[k]; !exists {
+			requestBody[k] = v
+		}
+	}
+
 	if len(tools) > 0 {
 		requestBody["tools"] = tools
 		requestBody["tool_choice"] = "auto"
@@ -151,6 +221,14 @@ func (p *Provider) Chat(
 		}
 	}
 
+	if topP, ok := asFloat(options["top_p"]); ok {
+		requestBody["top_p"] = topP
+	}
+
+	if stop, ok := options["stop"].([]string); ok && len(stop) > 0 {
+		requestBody["stop"] = stop
+	}
+
 	// Prompt caching: pass a stable cache key so OpenAI can bucket requests
 	// with the same key and reuse prefix KV cache across calls.
 	// The key is typically the agent ID — stable per agent, shared across requests.
@@ -163,12 +241,19 @@ func (p *Provider) Chat(
 		}
 	}
 
+	return requestBody
+}
+
+// doRequest POSTs the request body to the given endpoint path (e.g.
+// "/chat/completions") and returns the raw response on HTTP 200. Non-200
+// responses are converted into descriptive errors and their bodies are closed.
+func (p *Provider) doRequest(ctx context.Context, path string, requestBody any) (*http.Response, error) {
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+"/chat/completions", bytes.NewReader(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiBase+path, bytes.NewReader(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -177,47 +262,43 @@ func (p *Provider) Chat(
 	if p.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	}
+	for k, v := range p.headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range protocoltypes.RequestHeaders(ctx) {
+		req.Header.Set(k, v)
+	}
 
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	contentType := resp.Header.Get("Content-Type")
 
 	// Non-200: read a prefix to tell HTML error page apart from JSON error body.
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		contentType := resp.Header.Get("Content-Type")
 		body, readErr := io.ReadAll(io.LimitReader(resp.Body, 256))
 		if readErr != nil {
 			return nil, fmt.Errorf("failed to read response: %w", readErr)
 		}
+		apiErr := &APIError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 		if looksLikeHTML(body, contentType) {
-			return nil, wrapHTMLResponseError(resp.StatusCode, body, contentType, p.apiBase)
+			apiErr.Err = wrapHTMLResponseError(resp.StatusCode, body, contentType, p.apiBase)
+			return nil, apiErr
 		}
-		return nil, fmt.Errorf(
+		apiErr.Err = fmt.Errorf(
 			"API request failed:\n  Status: %d\n  Body:   %s",
 			resp.StatusCode,
 			responsePreview(body, 128),
 		)
+		return nil, apiErr
 	}
 
-	// Peek without consuming so the full stream reaches the JSON decoder.
-	reader := bufio.NewReader(resp.Body)
-	prefix, err := reader.Peek(256) // io.EOF/ErrBufferFull are normal; only real errors abort
-	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
-		return nil, fmt.Errorf("failed to inspect response: %w", err)
-	}
-	if looksLikeHTML(prefix, contentType) {
-		return nil, wrapHTMLResponseError(resp.StatusCode, prefix, contentType, p.apiBase)
-	}
-
-	out, err := parseResponse(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
-	}
-
-	return out, nil
+	return resp, nil
 }
 
 func wrapHTMLResponseError(statusCode int, body []byte, contentType, apiBase string) error {
@@ -312,7 +393,7 @@ func parseResponse(body io.Reader) (*LLMResponse, error) {
 	choice := apiResponse.Choices[0]
 	toolCalls := make([]ToolCall, 0, len(choice.Message.ToolCalls))
 	for _, tc := range choice.Message.ToolCalls {
-		arguments := make(map[string]any)
+		var arguments map[string]any
 		name := ""
 
 		// Extract thought_signature from Gemini/Google-specific extra content
@@ -323,12 +404,9 @@ func parseResponse(body io.Reader) (*LLMResponse, error) {
 
 		if tc.Function != nil {
 			name = tc.Function.Name
-			if tc.Function.Arguments != "" {
-				if err := json.Unmarshal([]byte(tc.Function.Arguments), &arguments); err != nil {
-					log.Printf("openai_compat: failed to decode tool call arguments for %q: %v", name, err)
-					arguments["raw"] = tc.Function.Arguments
-				}
-			}
+			arguments = decodeToolArguments(name, tc.Function.Arguments)
+		} else {
+			arguments = make(map[string]any)
 		}
 
 		// Build ToolCall with ExtraContent for Gemini 3 thought_signature persistence
@@ -381,7 +459,7 @@ func serializeMessages(messages []Message) []any {
 	for _, m := range messages {
 		if len(m.Media) == 0 {
 			out = append(out, openaiMessage{
-				Role:             m.Role,
+				Role:             string(m.Role),
 				Content:          m.Content,
 				ReasoningContent: m.ReasoningContent,
 				ToolCalls:        m.ToolCalls,
@@ -398,15 +476,13 @@ func serializeMessages(messages []Message) []any {
 				"text": m.Content,
 			})
 		}
-		for _, mediaURL := range m.Media {
-			if strings.HasPrefix(mediaURL, "data:image/") {
-				parts = append(parts, map[string]any{
-					"type": "image_url",
-					"image_url": map[string]any{
-						"url": mediaURL,
-					},
-				})
-			}
+		for _, image := range m.ImageParts() {
+			parts = append(parts, map[string]any{
+				"type": "image_url",
+				"image_url": map[string]any{
+					"url": image.DataURL(),
+				},
+			})
 		}
 
 		msg := map[string]any{
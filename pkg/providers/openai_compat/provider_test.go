@@ -3,6 +3,7 @@ package openai_compat
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -215,6 +216,48 @@ func TestProviderChat_HTTPError(t *testing.T) {
 	}
 }
 
+func TestProviderChat_HTTPErrorExposesStatusAndRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "12")
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("StatusCode = %d, want 429", apiErr.StatusCode)
+	}
+	if apiErr.RetryAfter != 12*time.Second {
+		t.Errorf("RetryAfter = %v, want 12s", apiErr.RetryAfter)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"-1", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
 func TestProviderChat_JSONHTTPErrorDoesNotReportHTML(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -317,6 +360,25 @@ func TestProviderChat_SuccessResponseUsesStreamingDecoder(t *testing.T) {
 	}
 }
 
+func TestProviderChat_ParsesCachedPromptTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],` +
+			`"usage":{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,` +
+			`"prompt_tokens_details":{"cached_tokens":1920}}}`))
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	out, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if got := out.Usage.CachedTokens(); got != 1920 {
+		t.Errorf("CachedTokens() = %d, want 1920", got)
+	}
+}
+
 func TestProviderChat_LargeHTMLResponsePreviewIsTruncated(t *testing.T) {
 	body := append([]byte("<!DOCTYPE html><html><body>"), bytes.Repeat([]byte("A"), 2048)...)
 	body = append(body, []byte("</body></html>")...)
@@ -382,6 +444,27 @@ func TestProviderChat_StripsMoonshotPrefixAndNormalizesKimiTemperature(t *testin
 	}
 }
 
+func TestBuildRequestBody_SamplingOptions(t *testing.T) {
+	p := NewProvider("key", "https://api.example.com/v1", "")
+	body := p.buildRequestBody(
+		[]Message{{Role: "user", Content: "hi"}},
+		nil,
+		"gpt-4o",
+		map[string]any{"top_p": 0.5, "stop": []string{"###"}},
+	)
+	if body["top_p"] != 0.5 {
+		t.Errorf("top_p = %v, want 0.5", body["top_p"])
+	}
+	if stop, _ := body["stop"].([]string); len(stop) != 1 || stop[0] != "###" {
+		t.Errorf("stop = %v, want [###]", body["stop"])
+	}
+
+	body = p.buildRequestBody([]Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if _, ok := body["top_p"]; ok {
+		t.Error("top_p should be omitted when not set")
+	}
+}
+
 func TestProviderChat_StripsGroqAndOllamaPrefixes(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -639,6 +722,28 @@ func TestSerializeMessages_WithMedia(t *testing.T) {
 	}
 }
 
+func TestSerializeMessages_MediaURLsAndNonImages(t *testing.T) {
+	messages := []protocoltypes.Message{
+		{Role: "user", Content: "what is this", Media: []string{
+			"https://example.com/cat.jpg",
+			"data:audio/ogg;base64,xyz",
+			"media://unresolved",
+		}},
+	}
+	data, _ := json.Marshal(serializeMessages(messages))
+	var msgs []map[string]any
+	json.Unmarshal(data, &msgs)
+
+	content := msgs[0]["content"].([]any)
+	if len(content) != 2 {
+		t.Fatalf("expected text + 1 image part, got %d: %v", len(content), content)
+	}
+	imgURL := content[1].(map[string]any)["image_url"].(map[string]any)
+	if imgURL["url"] != "https://example.com/cat.jpg" {
+		t.Fatalf("image url mismatch: %v", imgURL["url"])
+	}
+}
+
 func TestSerializeMessages_MediaWithToolCallID(t *testing.T) {
 	messages := []protocoltypes.Message{
 		{Role: "tool", Content: "image result", Media: []string{"data:image/png;base64,xyz"}, ToolCallID: "call_1"},
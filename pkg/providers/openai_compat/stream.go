@@ -0,0 +1,257 @@
+package openai_compat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
+)
+
+type StreamDelta = protocoltypes.StreamDelta
+
+// streamBufferSize bounds how far the SSE reader can run ahead of a slow consumer.
+const streamBufferSize = 16
+
+// ChatStream sends a streaming chat completion request and returns a channel of
+// incremental deltas parsed from the server-sent event stream. Unless ctx is
+// canceled, the channel ends with exactly one terminal delta (Done=true) that
+// carries the accumulated Response or the error that ended the stream.
+//
+// Errors that occur before the stream is established (bad config, non-200
+// status, HTML error pages) are returned directly instead.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	if p.apiBase == "" {
+		return nil, fmt.Errorf("API base not configured")
+	}
+
+	requestBody := p.buildRequestBody(messages, tools, model, options)
+	requestBody["stream"] = true
+	requestBody["stream_options"] = map[string]any{"include_usage": true}
+
+	resp, err := p.doRequest(ctx, "/chat/completions", requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	reader := bufio.NewReader(resp.Body)
+	prefix, err := reader.Peek(256)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to inspect response: %w", err)
+	}
+	if looksLikeHTML(prefix, contentType) {
+		resp.Body.Close()
+		return nil, wrapHTMLResponseError(resp.StatusCode, prefix, contentType, p.apiBase)
+	}
+
+	out := make(chan StreamDelta, streamBufferSize)
+	go func() {
+		defer close(out)
+		defer resp.Body.Close()
+
+		final, err := readStream(ctx, reader, out)
+		if err != nil {
+			sendDelta(ctx, out, StreamDelta{Done: true, Err: err})
+			return
+		}
+		sendDelta(ctx, out, StreamDelta{Done: true, Response: final})
+	}()
+
+	return out, nil
+}
+
+// sendDelta delivers d unless ctx is canceled first. It reports whether the
+// delta was delivered.
+func sendDelta(ctx context.Context, out chan<- StreamDelta, d StreamDelta) bool {
+	select {
+	case out <- d:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// streamToolCall accumulates one tool call whose fields arrive in fragments.
+type streamToolCall struct {
+	id               string
+	name             string
+	arguments        strings.Builder
+	thoughtSignature string
+}
+
+// streamChunk is the wire format of one "data:" event in the SSE stream.
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Reasoning        string `json:"reasoning"`
+			ToolCalls        []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function *struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+				ExtraContent *struct {
+					Google *struct {
+						ThoughtSignature string `json:"thought_signature"`
+					} `json:"google"`
+				} `json:"extra_content"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *UsageInfo `json:"usage"`
+}
+
+// readStream parses SSE events from r, forwarding text fragments to out and
+// returning the accumulated response once the stream ends.
+func readStream(ctx context.Context, r io.Reader, out chan<- StreamDelta) (*LLMResponse, error) {
+	var (
+		content      strings.Builder
+		reasoning    strings.Builder
+		finishReason string
+		usage        *UsageInfo
+		calls        = make(map[int]*streamToolCall)
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		// Blank lines separate events; lines starting with ':' are SSE comments
+		// (some gateways send ": keep-alive").
+		if len(line) == 0 || line[0] == ':' {
+			continue
+		}
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if bytes.Equal(data, []byte("[DONE]")) {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finishReason = *choice.FinishReason
+			}
+
+			delta := choice.Delta
+			reasoningPart := delta.ReasoningContent
+			if reasoningPart == "" {
+				reasoningPart = delta.Reasoning
+			}
+			content.WriteString(delta.Content)
+			reasoning.WriteString(reasoningPart)
+
+			for _, tc := range delta.ToolCalls {
+				acc, ok := calls[tc.Index]
+				if !ok {
+					acc = &streamToolCall{}
+					calls[tc.Index] = acc
+				}
+				if tc.ID != "" {
+					acc.id = tc.ID
+				}
+				if tc.Function != nil {
+					if tc.Function.Name != "" {
+						acc.name = tc.Function.Name
+					}
+					acc.arguments.WriteString(tc.Function.Arguments)
+				}
+				if tc.ExtraContent != nil && tc.ExtraContent.Google != nil &&
+					tc.ExtraContent.Google.ThoughtSignature != "" {
+					acc.thoughtSignature = tc.ExtraContent.Google.ThoughtSignature
+				}
+			}
+
+			if delta.Content != "" || reasoningPart != "" {
+				if !sendDelta(ctx, out, StreamDelta{Content: delta.Content, ReasoningContent: reasoningPart}) {
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(calls))
+	for idx := range calls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	toolCalls := make([]ToolCall, 0, len(indexes))
+	for _, idx := range indexes {
+		acc := calls[idx]
+		toolCall := ToolCall{
+			ID:               acc.id,
+			Name:             acc.name,
+			Arguments:        decodeToolArguments(acc.name, acc.arguments.String()),
+			ThoughtSignature: acc.thoughtSignature,
+		}
+		if acc.thoughtSignature != "" {
+			toolCall.ExtraContent = &ExtraContent{
+				Google: &GoogleExtra{ThoughtSignature: acc.thoughtSignature},
+			}
+		}
+		toolCalls = append(toolCalls, toolCall)
+	}
+
+	if finishReason == "" {
+		finishReason = "stop"
+	}
+
+	return &LLMResponse{
+		Content:          content.String(),
+		ReasoningContent: reasoning.String(),
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
+		Usage:            usage,
+	}, nil
+}
+
+// decodeToolArguments parses a JSON-encoded arguments string. Malformed input
+// is preserved under the "raw" key so the tool layer can report it.
+func decodeToolArguments(name, raw string) map[string]any {
+	arguments := make(map[string]any)
+	if raw == "" {
+		return arguments
+	}
+	if err := json.Unmarshal([]byte(raw), &arguments); err != nil {
+		log.Printf("openai_compat: failed to decode tool call arguments for %q: %v", name, err)
+		arguments["raw"] = raw
+	}
+	return arguments
+}
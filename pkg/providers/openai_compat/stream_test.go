@@ -0,0 +1,155 @@
+package openai_compat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newSSEServer(t *testing.T, events []string, captured *map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if captured != nil {
+			if err := json.NewDecoder(r.Body).Decode(captured); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher, _ := w.(http.Flusher)
+		for _, ev := range events {
+			fmt.Fprintf(w, "%s\n\n", ev)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}))
+}
+
+func TestChatStream_TextDeltasAndUsage(t *testing.T) {
+	var body map[string]any
+	server := newSSEServer(t, []string{
+		`: keep-alive`,
+		`data: {"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`data: {"choices":[{"delta":{"content":"lo"}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+		`data: [DONE]`,
+	}, &body)
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	ch, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	var parts []string
+	var final *LLMResponse
+	for d := range ch {
+		if d.Done {
+			if d.Err != nil {
+				t.Fatalf("terminal delta error = %v", d.Err)
+			}
+			final = d.Response
+			continue
+		}
+		parts = append(parts, d.Content)
+	}
+
+	if got := strings.Join(parts, "|"); got != "Hel|lo" {
+		t.Fatalf("deltas = %q, want %q", got, "Hel|lo")
+	}
+	if final == nil {
+		t.Fatal("expected final response")
+	}
+	if final.Content != "Hello" || final.FinishReason != "stop" {
+		t.Fatalf("final = %+v", final)
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 7 {
+		t.Fatalf("usage = %+v, want total 7", final.Usage)
+	}
+	if body["stream"] != true {
+		t.Fatalf("request stream = %v, want true", body["stream"])
+	}
+}
+
+func TestChatStream_AccumulatesToolCallFragments(t *testing.T) {
+	server := newSSEServer(t, []string{
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"ci"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"SF\"}"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"noop","arguments":""}}]}}]}`,
+		`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, nil)
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	ch, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "weather?"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	var final *LLMResponse
+	for d := range ch {
+		if d.Done {
+			final = d.Response
+		}
+	}
+	if final == nil {
+		t.Fatal("expected final response")
+	}
+	if len(final.ToolCalls) != 2 {
+		t.Fatalf("tool calls = %d, want 2", len(final.ToolCalls))
+	}
+	tc := final.ToolCalls[0]
+	if tc.ID != "call_1" || tc.Name != "get_weather" || tc.Arguments["city"] != "SF" {
+		t.Fatalf("tool call 0 = %+v", tc)
+	}
+	if final.ToolCalls[1].Name != "noop" {
+		t.Fatalf("tool call 1 = %+v", final.ToolCalls[1])
+	}
+	if final.FinishReason != "tool_calls" {
+		t.Fatalf("finish reason = %q", final.FinishReason)
+	}
+}
+
+func TestChatStream_MalformedChunkEndsWithError(t *testing.T) {
+	server := newSSEServer(t, []string{
+		`data: {"choices":[{"delta":{"content":"ok"}}]}`,
+		`data: {not json`,
+	}, nil)
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	ch, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	var terminalErr error
+	for d := range ch {
+		if d.Done {
+			terminalErr = d.Err
+		}
+	}
+	if terminalErr == nil {
+		t.Fatal("expected terminal error for malformed chunk")
+	}
+}
+
+func TestChatStream_Non200ReturnsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.ChatStream(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("ChatStream() error = %v, want status 429", err)
+	}
+}
@@ -0,0 +1,149 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+)
+
+const (
+	openRouterDefaultSiteURL = "https://github.com/sipeed/picoclaw"
+	openRouterDefaultAppName = "PicoClaw"
+)
+
+// openRouterRequestOptions returns the openai_compat options for an
+// OpenRouter model entry: app attribution headers, usage accounting (so
+// responses carry their cost) and the configured fallback models and
+// provider preferences.
+func openRouterRequestOptions(o *config.OpenRouterOptions) []openai_compat.Option {
+	headers := map[string]string{
+		"HTTP-Referer": openRouterDefaultSiteURL,
+		"X-Title":      openRouterDefaultAppName,
+	}
+	extra := map[string]any{
+		"usage": map[string]any{"include": true},
+	}
+
+	if o != nil {
+		if o.SiteURL != "" {
+			headers["HTTP-Referer"] = o.SiteURL
+		}
+		if o.AppName != "" {
+			headers["X-Title"] = o.AppName
+		}
+		if len(o.Models) > 0 {
+			extra["models"] = o.Models
+		}
+		if o.Provider != nil {
+			extra["provider"] = o.Provider
+		}
+	}
+
+	return []openai_compat.Option{
+		openai_compat.WithHeaders(headers),
+		openai_compat.WithExtraBody(extra),
+	}
+}
+
+// OpenRouterModel is one entry of OpenRouter's model catalog.
+type OpenRouterModel struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	ContextLength int               `json:"context_length"`
+	Pricing       OpenRouterPricing `json:"pricing"`
+}
+
+// OpenRouterPricing holds prices as published by OpenRouter: decimal strings
+// in USD per token.
+type OpenRouterPricing struct {
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion"`
+}
+
+// PricePerMillion returns the input and output prices in USD per million
+// tokens, the unit used by model_list's input_price and output_price.
+// Unparseable or negative prices (OpenRouter uses "-1" for dynamic routers)
+// are returned as 0.
+func (m OpenRouterModel) PricePerMillion() (input, output float64) {
+	return perMillion(m.Pricing.Prompt), perMillion(m.Pricing.Completion)
+}
+
+func perMillion(perToken string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(perToken), 64)
+	if err != nil || v < 0 {
+		return 0
+	}
+	return v * 1_000_000
+}
+
+// ListOpenRouterModels fetches the model catalog from apiBase's /models
+// endpoint, sorted by ID. apiBase defaults to the public OpenRouter API and
+// rt may be nil to use the default transport.
+func ListOpenRouterModels(
+	ctx context.Context,
+	apiKey, apiBase string,
+	rt http.RoundTripper,
+) ([]OpenRouterModel, error) {
+	if apiBase == "" {
+		apiBase = getDefaultAPIBase("openrouter")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(apiBase, "/")+"/models", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second, Transport: rt}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		preview, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return nil, &openai_compat.APIError{
+			StatusCode: resp.StatusCode,
+			Err: fmt.Errorf("openrouter models request failed:\n  Status: %d\n  Body:   %s",
+				resp.StatusCode, strings.TrimSpace(string(preview))),
+		}
+	}
+
+	var out struct {
+		Data []OpenRouterModel `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to parse models response: %w", err)
+	}
+	sort.Slice(out.Data, func(i, j int) bool { return out.Data[i].ID < out.Data[j].ID })
+	return out.Data, nil
+}
+
+// ListOpenRouterModelsFromConfig lists the catalog reachable with the
+// credentials and transport settings of an "openrouter" model_list entry.
+func ListOpenRouterModelsFromConfig(ctx context.Context, cfg *config.ModelConfig) ([]OpenRouterModel, error) {
+	if protocol, _ := ExtractProtocol(cfg.Model); protocol != "openrouter" {
+		return nil, fmt.Errorf("model %q does not use the openrouter protocol", cfg.ModelName)
+	}
+	rt, err := providerTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return ListOpenRouterModels(ctx, cfg.APIKey, cfg.APIBase, rt)
+}
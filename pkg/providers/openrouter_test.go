@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestCreateProviderFromConfig_OpenRouterRoutingOptions(t *testing.T) {
+	var body map[string]any
+	var referer, title string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referer, title = r.Header.Get("HTTP-Referer"), r.Header.Get("X-Title")
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12,"cost":0.00042}}`))
+	}))
+	defer server.Close()
+
+	allowFallbacks := false
+	provider, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "router",
+		Model:     "openrouter/anthropic/claude-sonnet-4.6",
+		APIBase:   server.URL,
+		APIKey:    "sk-or-test",
+		OpenRouter: &config.OpenRouterOptions{
+			Models: []string{"openai/gpt-4o", "google/gemini-2.5-pro"},
+			Provider: &config.OpenRouterProviderPreferences{
+				Order:          []string{"anthropic"},
+				AllowFallbacks: &allowFallbacks,
+				Sort:           "price",
+			},
+			AppName: "my-bot",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	if modelID != "anthropic/claude-sonnet-4.6" {
+		t.Errorf("modelID = %q", modelID)
+	}
+
+	resp, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, modelID, nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if body["model"] != "anthropic/claude-sonnet-4.6" {
+		t.Errorf("model = %v", body["model"])
+	}
+	if models, _ := body["models"].([]any); len(models) != 2 || models[0] != "openai/gpt-4o" {
+		t.Errorf("models = %v", body["models"])
+	}
+	prefs, _ := body["provider"].(map[string]any)
+	if prefs["sort"] != "price" || prefs["allow_fallbacks"] != false {
+		t.Errorf("provider = %v", body["provider"])
+	}
+	if usage, _ := body["usage"].(map[string]any); usage["include"] != true {
+		t.Errorf("usage = %v, want include=true", body["usage"])
+	}
+	if referer != openRouterDefaultSiteURL || title != "my-bot" {
+		t.Errorf("attribution headers = %q, %q", referer, title)
+	}
+	if resp.Usage == nil || resp.Usage.Cost != 0.00042 {
+		t.Errorf("Usage = %+v, want cost 0.00042", resp.Usage)
+	}
+}
+
+func TestListOpenRouterModels(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[
+			{"id":"openai/gpt-4o","name":"OpenAI: GPT-4o","context_length":128000,
+			 "pricing":{"prompt":"0.0000025","completion":"0.00001"}},
+			{"id":"openrouter/auto","name":"Auto Router","context_length":2000000,
+			 "pricing":{"prompt":"-1","completion":"-1"}},
+			{"id":"anthropic/claude-sonnet-4.6","name":"Claude Sonnet 4.6","context_length":200000,
+			 "pricing":{"prompt":"0.000003","completion":"0.000015"}}
+		]}`))
+	}))
+	defer server.Close()
+
+	models, err := ListOpenRouterModels(t.Context(), "sk-or-test", server.URL, nil)
+	if err != nil {
+		t.Fatalf("ListOpenRouterModels() error = %v", err)
+	}
+	if gotAuth != "Bearer sk-or-test" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if len(models) != 3 || models[0].ID != "anthropic/claude-sonnet-4.6" {
+		t.Fatalf("models not sorted by ID: %+v", models)
+	}
+	if in, out := models[0].PricePerMillion(); in != 3 || out != 15 {
+		t.Errorf("PricePerMillion() = %v, %v, want 3, 15", in, out)
+	}
+	if in, out := models[2].PricePerMillion(); in != 0 || out != 0 {
+		t.Errorf("dynamic router prices = %v, %v, want 0, 0", in, out)
+	}
+}
+
+func TestListOpenRouterModelsFromConfig_RejectsOtherProtocols(t *testing.T) {
+	_, err := ListOpenRouterModelsFromConfig(t.Context(), &config.ModelConfig{ModelName: "gpt", Model: "openai/gpt-4o"})
+	if err == nil {
+		t.Fatal("expected error for non-openrouter model")
+	}
+}
@@ -0,0 +1,28 @@
+package protocoltypes
+
+import "context"
+
+type requestHeadersKey struct{}
+
+// WithRequestHeaders returns a context that asks HTTP-based providers to send
+// headers with every request made under it. Headers already attached to ctx
+// are kept unless overridden by name.
+func WithRequestHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	merged := make(map[string]string, len(headers))
+	for k, v := range RequestHeaders(ctx) {
+		merged[k] = v
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, requestHeadersKey{}, merged)
+}
+
+// RequestHeaders returns the extra headers attached to ctx, or nil.
+func RequestHeaders(ctx context.Context) map[string]string {
+	h, _ := ctx.Value(requestHeadersKey{}).(map[string]string)
+	return h
+}
@@ -0,0 +1,53 @@
+package protocoltypes
+
+import "strings"
+
+// ImagePart is an image attached to a message. Exactly one of Data (with
+// MediaType) or URL is set: inline images come from base64 data URLs, remote
+// images are passed to the provider by reference.
+type ImagePart struct {
+	MediaType string // e.g. "image/jpeg"; set for inline images
+	Data      string // base64 payload without the data URL prefix
+	URL       string // http(s) URL for remote images
+}
+
+// ImageParts extracts the images from m.Media. Base64 data URLs with an
+// image/* type and plain http(s) URLs are returned in order; other entries
+// (audio, documents, unresolved media:// refs) are skipped.
+func (m Message) ImageParts() []ImagePart {
+	var parts []ImagePart
+	for _, ref := range m.Media {
+		if part, ok := ParseImageRef(ref); ok {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// ParseImageRef interprets a single media entry as an image.
+func ParseImageRef(ref string) (ImagePart, bool) {
+	if rest, ok := strings.CutPrefix(ref, "data:"); ok {
+		header, data, found := strings.Cut(rest, ",")
+		if !found {
+			return ImagePart{}, false
+		}
+		mediaType, encoding, _ := strings.Cut(header, ";")
+		if !strings.HasPrefix(mediaType, "image/") || encoding != "base64" || data == "" {
+			return ImagePart{}, false
+		}
+		return ImagePart{MediaType: mediaType, Data: data}, true
+	}
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		return ImagePart{URL: ref}, true
+	}
+	return ImagePart{}, false
+}
+
+// DataURL returns the image as a URL suitable for APIs that accept either
+// remote URLs or inline data URLs.
+func (p ImagePart) DataURL() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return "data:" + p.MediaType + ";base64," + p.Data
+}
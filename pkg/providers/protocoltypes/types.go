@@ -42,9 +42,26 @@ type ReasoningDetail struct {
 }
 
 type UsageInfo struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+	Cost                float64              `json:"cost,omitempty"` // provider-reported cost in USD (OpenRouter)
+}
+
+// PromptTokensDetails breaks down PromptTokens by prompt-cache outcome.
+// Both counts are included in PromptTokens.
+type PromptTokensDetails struct {
+	CachedTokens        int `json:"cached_tokens"`                   // served from the provider's prompt cache
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"` // written to the cache (Anthropic)
+}
+
+// CachedTokens returns the number of prompt tokens served from the cache.
+func (u *UsageInfo) CachedTokens() int {
+	if u == nil || u.PromptTokensDetails == nil {
+		return 0
+	}
+	return u.PromptTokensDetails.CachedTokens
 }
 
 // CacheControl marks a content block for LLM-side prefix caching.
@@ -62,8 +79,27 @@ type ContentBlock struct {
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
+// Role is who a message of a conversation is from.
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool" // the result of a tool call
+)
+
+// Valid reports whether r is one of the roles above.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleSystem, RoleUser, RoleAssistant, RoleTool:
+		return true
+	}
+	return false
+}
+
 type Message struct {
-	Role             string         `json:"role"`
+	Role             Role           `json:"role"`
 	Content          string         `json:"content"`
 	Media            []string       `json:"media,omitempty"`
 	ReasoningContent string         `json:"reasoning_content,omitempty"`
@@ -82,3 +118,15 @@ type ToolFunctionDefinition struct {
 	Description string         `json:"description"`
 	Parameters  map[string]any `json:"parameters"`
 }
+
+// StreamDelta is one incremental event produced by a streaming chat call.
+// Content and ReasoningContent carry only the newly generated fragment.
+// The final event has Done set and carries the fully accumulated Response
+// (including any tool calls and usage), or Err if the stream failed.
+type StreamDelta struct {
+	Content          string       `json:"content,omitempty"`
+	ReasoningContent string       `json:"reasoning_content,omitempty"`
+	Done             bool         `json:"done,omitempty"`
+	Response         *LLMResponse `json:"response,omitempty"`
+	Err              error        `json:"-"`
+}
@@ -0,0 +1,298 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+)
+
+const (
+	rateLimitWindow       = time.Minute
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 60 * time.Second
+)
+
+// RateLimitConfig configures client-side throttling and retries for a provider.
+// Zero values disable the corresponding feature.
+type RateLimitConfig struct {
+	RPM        int           // max requests started per rolling minute
+	TPM        int           // max tokens (as reported by usage) per rolling minute
+	MaxRetries int           // retries after the first attempt on 429/5xx
+	BaseDelay  time.Duration // first backoff step; defaults to 1s
+	MaxDelay   time.Duration // cap for backoff and Retry-After; defaults to 60s
+}
+
+// RateLimitedProvider wraps an LLMProvider with RPM/TPM throttling and
+// retry-with-backoff on rate-limit (429) and server (5xx) errors.
+// A Retry-After hint from the server takes precedence over the computed backoff.
+type RateLimitedProvider struct {
+	inner LLMProvider
+	cfg   RateLimitConfig
+
+	mu       sync.Mutex
+	requests []time.Time  // start times of requests inside the window
+	tokens   []tokenUsage // token usage recorded inside the window
+
+	nowFunc   func() time.Time                                 // for testing
+	sleepFunc func(ctx context.Context, d time.Duration) error // for testing
+}
+
+type tokenUsage struct {
+	at     time.Time
+	tokens int
+}
+
+// NewRateLimitedProvider wraps inner with the given limits.
+func NewRateLimitedProvider(inner LLMProvider, cfg RateLimitConfig) *RateLimitedProvider {
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultRetryBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultRetryMaxDelay
+	}
+	return &RateLimitedProvider{
+		inner:     inner,
+		cfg:       cfg,
+		nowFunc:   time.Now,
+		sleepFunc: sleepContext,
+	}
+}
+
+func (p *RateLimitedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	for attempt := 0; ; attempt++ {
+		if err := p.acquire(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := p.inner.Chat(ctx, messages, tools, model, options)
+		if err == nil {
+			p.recordUsage(resp)
+			return resp, nil
+		}
+		if err := p.backoff(ctx, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ChatStream implements StreamingProvider. Only failures that happen before
+// the stream is established are retried; once deltas flow they are forwarded
+// as-is and the final usage is recorded against the TPM budget.
+func (p *RateLimitedProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	var deltas <-chan StreamDelta
+	for attempt := 0; ; attempt++ {
+		if err := p.acquire(ctx); err != nil {
+			return nil, err
+		}
+		var err error
+		deltas, err = ChatStream(ctx, p.inner, messages, tools, model, options)
+		if err == nil {
+			break
+		}
+		if err := p.backoff(ctx, attempt, err); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make(chan StreamDelta, cap(deltas))
+	go func() {
+		defer close(out)
+		for d := range deltas {
+			if d.Done && d.Response != nil {
+				p.recordUsage(d.Response)
+			}
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (p *RateLimitedProvider) GetDefaultModel() string {
+	return p.inner.GetDefaultModel()
+}
+
+// SupportsThinking forwards ThinkingCapable to the wrapped provider.
+func (p *RateLimitedProvider) SupportsThinking() bool {
+	tc, ok := p.inner.(ThinkingCapable)
+	return ok && tc.SupportsThinking()
+}
+
+// Close forwards StatefulProvider to the wrapped provider.
+func (p *RateLimitedProvider) Close() {
+	if sp, ok := p.inner.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+// Unwrap returns the wrapped provider.
+func (p *RateLimitedProvider) Unwrap() LLMProvider {
+	return p.inner
+}
+
+// backoff sleeps before the next attempt, or returns err unchanged when it
+// is not retriable or the retry budget is spent.
+func (p *RateLimitedProvider) backoff(ctx context.Context, attempt int, err error) error {
+	status, retryAfter := retryInfo(err)
+	if attempt >= p.cfg.MaxRetries || !isRetriableStatus(status) {
+		return err
+	}
+
+	delay := retryAfter
+	if delay <= 0 {
+		delay = p.cfg.BaseDelay << attempt
+		if delay <= 0 || delay > p.cfg.MaxDelay {
+			delay = p.cfg.MaxDelay
+		}
+		// Equal jitter: keep half the step, randomize the rest.
+		delay = delay/2 + rand.N(delay/2+1)
+	}
+	delay = min(delay, p.cfg.MaxDelay)
+
+	logger.WarnCF("provider", "Provider request failed, retrying after backoff", map[string]any{
+		"status":  status,
+		"attempt": attempt + 1,
+		"backoff": delay.String(),
+		"error":   err.Error(),
+	})
+	if sleepErr := p.sleepFunc(ctx, delay); sleepErr != nil {
+		return err
+	}
+	return nil
+}
+
+// acquire blocks until a request fits within the RPM and TPM budgets and then
+// reserves a request slot.
+func (p *RateLimitedProvider) acquire(ctx context.Context) error {
+	if p.cfg.RPM <= 0 && p.cfg.TPM <= 0 {
+		return nil
+	}
+	for {
+		p.mu.Lock()
+		now := p.nowFunc()
+		p.pruneLocked(now)
+
+		var wait time.Duration
+		if p.cfg.RPM > 0 && len(p.requests) >= p.cfg.RPM {
+			wait = p.requests[0].Add(rateLimitWindow).Sub(now)
+		}
+		if p.cfg.TPM > 0 {
+			if w := p.tokenWaitLocked(now); w > wait {
+				wait = w
+			}
+		}
+		if wait <= 0 {
+			p.requests = append(p.requests, now)
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+
+		logger.DebugCF("provider", "Client-side rate limit reached, waiting", map[string]any{
+			"wait": wait.String(),
+		})
+		if err := p.sleepFunc(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// tokenWaitLocked returns how long until enough recorded usage leaves the
+// window to bring the total below TPM.
+func (p *RateLimitedProvider) tokenWaitLocked(now time.Time) time.Duration {
+	total := 0
+	for _, u := range p.tokens {
+		total += u.tokens
+	}
+	for _, u := range p.tokens {
+		if total < p.cfg.TPM {
+			break
+		}
+		total -= u.tokens
+		if total < p.cfg.TPM {
+			return u.at.Add(rateLimitWindow).Sub(now)
+		}
+	}
+	return 0
+}
+
+func (p *RateLimitedProvider) pruneLocked(now time.Time) {
+	cutoff := now.Add(-rateLimitWindow)
+	i := 0
+	for i < len(p.requests) && !p.requests[i].After(cutoff) {
+		i++
+	}
+	p.requests = p.requests[i:]
+
+	j := 0
+	for j < len(p.tokens) && !p.tokens[j].at.After(cutoff) {
+		j++
+	}
+	p.tokens = p.tokens[j:]
+}
+
+func (p *RateLimitedProvider) recordUsage(resp *LLMResponse) {
+	if p.cfg.TPM <= 0 || resp == nil || resp.Usage == nil || resp.Usage.TotalTokens <= 0 {
+		return
+	}
+	p.mu.Lock()
+	p.tokens = append(p.tokens, tokenUsage{at: p.nowFunc(), tokens: resp.Usage.TotalTokens})
+	p.mu.Unlock()
+}
+
+// retryInfo extracts the HTTP status and Retry-After hint from provider errors.
+func retryInfo(err error) (int, time.Duration) {
+	var compatErr *openai_compat.APIError
+	if errors.As(err, &compatErr) {
+		return compatErr.StatusCode, compatErr.RetryAfter
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		var retryAfter time.Duration
+		if anthropicErr.Response != nil {
+			if secs, convErr := strconv.Atoi(anthropicErr.Response.Header.Get("Retry-After")); convErr == nil {
+				retryAfter = time.Duration(secs) * time.Second
+			}
+		}
+		return anthropicErr.StatusCode, retryAfter
+	}
+	return 0, 0
+}
+
+func isRetriableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500 && status <= 599
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
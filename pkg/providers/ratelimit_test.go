@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers/openai_compat"
+)
+
+// scriptedProvider returns errs in order, then a successful response.
+type scriptedProvider struct {
+	errs  []error
+	calls int
+	usage int
+}
+
+func (p *scriptedProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	if p.calls <= len(p.errs) {
+		return nil, p.errs[p.calls-1]
+	}
+	return &LLMResponse{Content: "ok", Usage: &UsageInfo{TotalTokens: p.usage}}, nil
+}
+
+func (p *scriptedProvider) GetDefaultModel() string { return "scripted" }
+
+func newTestRateLimitedProvider(inner LLMProvider, cfg RateLimitConfig) (*RateLimitedProvider, *[]time.Duration) {
+	p := NewRateLimitedProvider(inner, cfg)
+	now := time.Unix(1_700_000_000, 0)
+	var sleeps []time.Duration
+	p.nowFunc = func() time.Time { return now }
+	p.sleepFunc = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		now = now.Add(d)
+		return ctx.Err()
+	}
+	return p, &sleeps
+}
+
+func TestRateLimitedProvider_RetriesOn429HonoringRetryAfter(t *testing.T) {
+	inner := &scriptedProvider{errs: []error{
+		&openai_compat.APIError{StatusCode: 429, RetryAfter: 7 * time.Second, Err: errors.New("rate limited")},
+	}}
+	p, sleeps := newTestRateLimitedProvider(inner, RateLimitConfig{MaxRetries: 2})
+
+	resp, err := p.Chat(t.Context(), nil, nil, "m", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q, want ok", resp.Content)
+	}
+	if inner.calls != 2 {
+		t.Errorf("calls = %d, want 2", inner.calls)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != 7*time.Second {
+		t.Errorf("sleeps = %v, want [7s]", *sleeps)
+	}
+}
+
+func TestRateLimitedProvider_BackoffIsExponentialWithJitter(t *testing.T) {
+	serverErr := &openai_compat.APIError{StatusCode: 503, Err: errors.New("unavailable")}
+	inner := &scriptedProvider{errs: []error{serverErr, serverErr, serverErr}}
+	p, sleeps := newTestRateLimitedProvider(inner, RateLimitConfig{MaxRetries: 3, BaseDelay: time.Second})
+
+	if _, err := p.Chat(t.Context(), nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(*sleeps) != 3 {
+		t.Fatalf("sleeps = %v, want 3 entries", *sleeps)
+	}
+	for i, d := range *sleeps {
+		step := time.Second << i
+		if d < step/2 || d > step {
+			t.Errorf("sleep[%d] = %v, want within [%v, %v]", i, d, step/2, step)
+		}
+	}
+}
+
+func TestRateLimitedProvider_GivesUpAfterMaxRetries(t *testing.T) {
+	serverErr := &openai_compat.APIError{StatusCode: 500, Err: errors.New("boom")}
+	inner := &scriptedProvider{errs: []error{serverErr, serverErr, serverErr}}
+	p, _ := newTestRateLimitedProvider(inner, RateLimitConfig{MaxRetries: 1})
+
+	_, err := p.Chat(t.Context(), nil, nil, "m", nil)
+	if !errors.Is(err, serverErr) {
+		t.Fatalf("Chat() error = %v, want %v", err, serverErr)
+	}
+	if inner.calls != 2 {
+		t.Errorf("calls = %d, want 2", inner.calls)
+	}
+}
+
+func TestRateLimitedProvider_DoesNotRetryClientErrors(t *testing.T) {
+	inner := &scriptedProvider{errs: []error{
+		&openai_compat.APIError{StatusCode: 400, Err: errors.New("bad request")},
+		fmt.Errorf("plain error"),
+	}}
+	p, sleeps := newTestRateLimitedProvider(inner, RateLimitConfig{MaxRetries: 3})
+
+	if _, err := p.Chat(t.Context(), nil, nil, "m", nil); err == nil {
+		t.Fatal("Chat() expected error")
+	}
+	if inner.calls != 1 || len(*sleeps) != 0 {
+		t.Errorf("calls = %d, sleeps = %v; want 1 call and no sleeps", inner.calls, *sleeps)
+	}
+}
+
+func TestRateLimitedProvider_RPMWaitsForWindow(t *testing.T) {
+	inner := &scriptedProvider{}
+	p, sleeps := newTestRateLimitedProvider(inner, RateLimitConfig{RPM: 2})
+
+	for range 3 {
+		if _, err := p.Chat(t.Context(), nil, nil, "m", nil); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Minute {
+		t.Errorf("sleeps = %v, want [1m0s]", *sleeps)
+	}
+}
+
+func TestRateLimitedProvider_TPMWaitsForUsageToExpire(t *testing.T) {
+	inner := &scriptedProvider{usage: 600}
+	p, sleeps := newTestRateLimitedProvider(inner, RateLimitConfig{TPM: 1000})
+
+	for range 2 {
+		if _, err := p.Chat(t.Context(), nil, nil, "m", nil); err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+	}
+	if len(*sleeps) != 0 {
+		t.Fatalf("sleeps = %v, want none before budget is spent", *sleeps)
+	}
+	if _, err := p.Chat(t.Context(), nil, nil, "m", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Minute {
+		t.Errorf("sleeps = %v, want [1m0s]", *sleeps)
+	}
+}
+
+func TestRateLimitedProvider_HTTPRetryAfterEndToEnd(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error":"slow down"}`, http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName:  "retry",
+		Model:      "openai/gpt-4o",
+		APIBase:    server.URL,
+		APIKey:     "k",
+		MaxRetries: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	rl, ok := provider.(*RateLimitedProvider)
+	if !ok {
+		t.Fatalf("provider type = %T, want *RateLimitedProvider", provider)
+	}
+	rl.sleepFunc = func(context.Context, time.Duration) error { return nil }
+
+	resp, err := provider.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Content != "hi" {
+		t.Errorf("Content = %q, want hi", resp.Content)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
@@ -0,0 +1,259 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ReplayEntry is one recorded exchange in a cassette file. Cassettes are
+// JSONL: one entry per line, in call order.
+type ReplayEntry struct {
+	Model    string       `json:"model,omitempty"`
+	Messages []Message    `json:"messages,omitempty"`
+	Tools    []string     `json:"tools,omitempty"` // tool names offered to the model
+	Response *LLMResponse `json:"response,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// RecordingProvider wraps a real provider and appends every exchange to a
+// cassette file that ReplayProvider can serve later without network access.
+type RecordingProvider struct {
+	inner LLMProvider
+	path  string
+	mu    sync.Mutex
+}
+
+// NewRecordingProvider records inner's traffic to the cassette at path.
+func NewRecordingProvider(inner LLMProvider, path string) *RecordingProvider {
+	return &RecordingProvider{inner: inner, path: path}
+}
+
+func (p *RecordingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	resp, err := p.inner.Chat(ctx, messages, tools, model, options)
+
+	entry := ReplayEntry{Model: model, Messages: messages}
+	for _, t := range tools {
+		entry.Tools = append(entry.Tools, t.Function.Name)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Response = encodeReplayResponse(resp)
+	}
+	if recErr := p.append(entry); recErr != nil {
+		logger.WarnCF("provider", "Failed to record provider exchange", map[string]any{
+			"cassette": p.path,
+			"error":    recErr.Error(),
+		})
+	}
+	return resp, err
+}
+
+func (p *RecordingProvider) GetDefaultModel() string {
+	return p.inner.GetDefaultModel()
+}
+
+// SupportsThinking forwards ThinkingCapable to the wrapped provider.
+func (p *RecordingProvider) SupportsThinking() bool {
+	tc, ok := p.inner.(ThinkingCapable)
+	return ok && tc.SupportsThinking()
+}
+
+// Close forwards StatefulProvider to the wrapped provider.
+func (p *RecordingProvider) Close() {
+	if sp, ok := p.inner.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+// Unwrap returns the wrapped provider.
+func (p *RecordingProvider) Unwrap() LLMProvider {
+	return p.inner
+}
+
+func (p *RecordingProvider) append(entry ReplayEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open cassette: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("append entry: %w", err)
+	}
+	return f.Close()
+}
+
+// ReplayProvider serves recorded or scripted responses in order, so agent
+// loop and tool tests run offline and deterministically. Requests are not
+// matched by content because system prompts embed the current time; a model
+// mismatch is logged to help spot diverging flows.
+type ReplayProvider struct {
+	mu      sync.Mutex
+	entries []ReplayEntry
+	next    int
+}
+
+// NewReplayProvider serves entries in order.
+func NewReplayProvider(entries []ReplayEntry) *ReplayProvider {
+	return &ReplayProvider{entries: entries}
+}
+
+// NewScriptedProvider serves the given responses in order.
+func NewScriptedProvider(responses ...*LLMResponse) *ReplayProvider {
+	entries := make([]ReplayEntry, len(responses))
+	for i, resp := range responses {
+		entries[i] = ReplayEntry{Response: resp}
+	}
+	return NewReplayProvider(entries)
+}
+
+// LoadReplayProvider reads a cassette written by RecordingProvider.
+func LoadReplayProvider(path string) (*ReplayProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: open cassette: %w", err)
+	}
+	defer f.Close()
+
+	var entries []ReplayEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry ReplayEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("replay: %s line %d: %w", path, lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay: read cassette: %w", err)
+	}
+	return NewReplayProvider(entries), nil
+}
+
+func (p *ReplayProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	if p.next >= len(p.entries) {
+		n := len(p.entries)
+		p.mu.Unlock()
+		return nil, fmt.Errorf("replay: no recorded response left (served %d)", n)
+	}
+	entry := p.entries[p.next]
+	p.next++
+	p.mu.Unlock()
+
+	if entry.Model != "" && model != "" && entry.Model != model {
+		logger.WarnCF("provider", "Replay model differs from recording", map[string]any{
+			"recorded":  entry.Model,
+			"requested": model,
+		})
+	}
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+	if entry.Response == nil {
+		return nil, fmt.Errorf("replay: entry has neither response nor error")
+	}
+	return decodeReplayResponse(entry.Response), nil
+}
+
+func (p *ReplayProvider) GetDefaultModel() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.entries {
+		if e.Model != "" {
+			return e.Model
+		}
+	}
+	return "replay"
+}
+
+// Remaining returns how many entries have not been served yet.
+func (p *ReplayProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries) - p.next
+}
+
+// encodeReplayResponse copies resp with tool calls normalized so that their
+// name and arguments survive JSON (ToolCall.Name/Arguments are not serialized).
+func encodeReplayResponse(resp *LLMResponse) *LLMResponse {
+	if resp == nil {
+		return nil
+	}
+	out := *resp
+	out.ToolCalls = normalizedToolCalls(resp.ToolCalls)
+	return &out
+}
+
+// decodeReplayResponse returns a fresh copy of a stored response with tool
+// call names and arguments restored from their serialized form.
+func decodeReplayResponse(resp *LLMResponse) *LLMResponse {
+	out := *resp
+	out.ToolCalls = normalizedToolCalls(resp.ToolCalls)
+	return &out
+}
+
+// normalizedToolCalls returns normalized copies of calls without touching the
+// originals' Function structs.
+func normalizedToolCalls(calls []ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return calls
+	}
+	out := make([]ToolCall, len(calls))
+	for i, tc := range calls {
+		if tc.Function != nil {
+			fn := *tc.Function
+			tc.Function = &fn
+		}
+		out[i] = NormalizeToolCall(tc)
+	}
+	return out
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type cannedProvider struct {
+	resp *LLMResponse
+	err  error
+}
+
+func (p *cannedProvider) Chat(
+	ctx context.Context, messages []Message, tools []ToolDefinition, model string, options map[string]any,
+) (*LLMResponse, error) {
+	return p.resp, p.err
+}
+
+func (p *cannedProvider) GetDefaultModel() string { return "canned" }
+
+func TestRecordingProvider_RoundTripsThroughReplay(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "nested", "cassette.jsonl")
+	inner := &cannedProvider{resp: &LLMResponse{
+		Content:      "calling tool",
+		FinishReason: "tool_calls",
+		ToolCalls: []ToolCall{{
+			ID:        "call_1",
+			Type:      "function",
+			Name:      "read_file",
+			Arguments: map[string]any{"path": "notes.md"},
+		}},
+		Usage: &UsageInfo{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+	}}
+	rec := NewRecordingProvider(inner, cassette)
+
+	msgs := []Message{{Role: "user", Content: "read my notes"}}
+	tools := []ToolDefinition{{Type: "function", Function: ToolFunctionDefinition{Name: "read_file"}}}
+	if _, err := rec.Chat(t.Context(), msgs, tools, "gpt-4o", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	inner.resp, inner.err = nil, errors.New("upstream exploded")
+	if _, err := rec.Chat(t.Context(), msgs, nil, "gpt-4o", nil); err == nil {
+		t.Fatal("Chat() expected error from inner provider")
+	}
+
+	replay, err := LoadReplayProvider(cassette)
+	if err != nil {
+		t.Fatalf("LoadReplayProvider() error = %v", err)
+	}
+	if replay.Remaining() != 2 {
+		t.Fatalf("Remaining() = %d, want 2", replay.Remaining())
+	}
+
+	resp, err := replay.Chat(t.Context(), nil, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("replay Chat() error = %v", err)
+	}
+	if resp.Content != "calling tool" || len(resp.ToolCalls) != 1 {
+		t.Fatalf("replayed response = %+v", resp)
+	}
+	tc := resp.ToolCalls[0]
+	if tc.Name != "read_file" || tc.Arguments["path"] != "notes.md" {
+		t.Errorf("replayed tool call = name %q args %v, want read_file {path: notes.md}", tc.Name, tc.Arguments)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 12 {
+		t.Errorf("replayed usage = %+v, want 12 total tokens", resp.Usage)
+	}
+
+	if _, err := replay.Chat(t.Context(), nil, nil, "gpt-4o", nil); err == nil ||
+		!strings.Contains(err.Error(), "upstream exploded") {
+		t.Errorf("replayed error = %v, want upstream exploded", err)
+	}
+	if _, err := replay.Chat(t.Context(), nil, nil, "gpt-4o", nil); err == nil {
+		t.Error("Chat() past the end of the cassette expected error")
+	}
+}
+
+func TestScriptedProvider_ServesInOrder(t *testing.T) {
+	p := NewScriptedProvider(&LLMResponse{Content: "one"}, &LLMResponse{Content: "two"})
+	for _, want := range []string{"one", "two"} {
+		resp, err := p.Chat(t.Context(), nil, nil, "", nil)
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		if resp.Content != want {
+			t.Errorf("Content = %q, want %q", resp.Content, want)
+		}
+	}
+}
+
+func TestCreateProviderFromConfig_RecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"live"},"finish_reason":"stop"}]}`))
+	}))
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	live, modelID, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "live",
+		Model:     "openai/gpt-4o",
+		APIBase:   server.URL,
+		Cassette:  cassette,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig(live) error = %v", err)
+	}
+	if _, ok := live.(*RecordingProvider); !ok {
+		t.Fatalf("provider type = %T, want *RecordingProvider", live)
+	}
+	if _, err := live.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, modelID, nil); err != nil {
+		t.Fatalf("live Chat() error = %v", err)
+	}
+	server.Close()
+
+	replay, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "offline",
+		Model:     "replay/gpt-4o",
+		Cassette:  cassette,
+	})
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig(replay) error = %v", err)
+	}
+	resp, err := replay.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("replay Chat() error = %v", err)
+	}
+	if resp.Content != "live" {
+		t.Errorf("Content = %q, want live", resp.Content)
+	}
+
+	if _, _, err := CreateProviderFromConfig(&config.ModelConfig{Model: "replay/x"}); err == nil {
+		t.Error("replay without cassette expected error")
+	}
+}
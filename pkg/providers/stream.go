@@ -0,0 +1,57 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+)
+
+// ChatStream streams a completion from provider when it implements
+// StreamingProvider. Otherwise it falls back to a blocking Chat call and
+// emits the whole response as one content delta followed by the terminal
+// delta, so callers can consume every provider the same way.
+func ChatStream(
+	ctx context.Context,
+	provider LLMProvider,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (<-chan StreamDelta, error) {
+	if sp, ok := provider.(StreamingProvider); ok {
+		return sp.ChatStream(ctx, messages, tools, model, options)
+	}
+
+	resp, err := provider.Chat(ctx, messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan StreamDelta, 2)
+	if resp.Content != "" || resp.ReasoningContent != "" {
+		out <- StreamDelta{Content: resp.Content, ReasoningContent: resp.ReasoningContent}
+	}
+	out <- StreamDelta{Done: true, Response: resp}
+	close(out)
+	return out, nil
+}
+
+// CollectStream drains a delta channel and returns the final response.
+// onDelta, if non-nil, is invoked for every non-terminal delta in order.
+func CollectStream(deltas <-chan StreamDelta, onDelta func(StreamDelta)) (*LLMResponse, error) {
+	for d := range deltas {
+		if !d.Done {
+			if onDelta != nil {
+				onDelta(d)
+			}
+			continue
+		}
+		if d.Err != nil {
+			return nil, d.Err
+		}
+		if d.Response == nil {
+			return nil, fmt.Errorf("stream finished without a response")
+		}
+		return d.Response, nil
+	}
+	return nil, fmt.Errorf("stream closed before completion")
+}
@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type blockingProvider struct {
+	resp *LLMResponse
+	err  error
+}
+
+func (p *blockingProvider) Chat(
+	_ context.Context, _ []Message, _ []ToolDefinition, _ string, _ map[string]any,
+) (*LLMResponse, error) {
+	return p.resp, p.err
+}
+
+func (p *blockingProvider) GetDefaultModel() string { return "mock" }
+
+func TestChatStream_FallsBackToChat(t *testing.T) {
+	p := &blockingProvider{resp: &LLMResponse{Content: "whole answer", FinishReason: "stop"}}
+
+	ch, err := ChatStream(t.Context(), p, nil, nil, "mock", nil)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	var deltas []string
+	resp, err := CollectStream(ch, func(d StreamDelta) { deltas = append(deltas, d.Content) })
+	if err != nil {
+		t.Fatalf("CollectStream() error = %v", err)
+	}
+	if len(deltas) != 1 || deltas[0] != "whole answer" {
+		t.Fatalf("deltas = %v", deltas)
+	}
+	if resp.Content != "whole answer" {
+		t.Fatalf("resp.Content = %q", resp.Content)
+	}
+}
+
+func TestChatStream_FallbackPropagatesChatError(t *testing.T) {
+	p := &blockingProvider{err: errors.New("boom")}
+	if _, err := ChatStream(t.Context(), p, nil, nil, "mock", nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestCollectStream_TerminalError(t *testing.T) {
+	ch := make(chan StreamDelta, 2)
+	ch <- StreamDelta{Content: "partial"}
+	ch <- StreamDelta{Done: true, Err: errors.New("connection reset")}
+	close(ch)
+
+	if _, err := CollectStream(ch, nil); err == nil || err.Error() != "connection reset" {
+		t.Fatalf("CollectStream() error = %v", err)
+	}
+}
+
+func TestCollectStream_ClosedWithoutTerminal(t *testing.T) {
+	ch := make(chan StreamDelta)
+	close(ch)
+	if _, err := CollectStream(ch, nil); err == nil {
+		t.Fatal("expected error for stream without terminal delta")
+	}
+}
@@ -0,0 +1,110 @@
+// Package transport builds the HTTP transports providers use to reach their
+// APIs, so proxies, private CAs and timeouts can be tuned per model entry.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Options configures a provider transport. Zero values keep Go's defaults.
+type Options struct {
+	// Proxy is an http, https, socks5 or socks5h URL. When empty the
+	// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables apply.
+	Proxy string
+	// CAFile is a PEM bundle of extra root CAs trusted in addition to the
+	// system pool (for TLS-intercepting corporate proxies or private gateways).
+	CAFile string
+	// ConnectTimeout bounds TCP dial and the TLS handshake.
+	ConnectTimeout time.Duration
+	// ReadTimeout bounds the wait for response headers once the request has
+	// been written. Streamed bodies are not affected.
+	ReadTimeout time.Duration
+	// KeepAlive is the TCP keep-alive period and idle connection lifetime.
+	// A negative value disables keep-alives and connection reuse.
+	KeepAlive time.Duration
+}
+
+// IsZero reports whether o leaves every setting at its default.
+func (o Options) IsZero() bool {
+	return o == Options{}
+}
+
+// New returns a transport derived from http.DefaultTransport with o applied.
+func New(o Options) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if o.Proxy != "" {
+		proxyURL, err := parseProxy(o.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if o.CAFile != "" {
+		pool, err := loadCertPool(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if o.ConnectTimeout > 0 {
+		dialer.Timeout = o.ConnectTimeout
+		t.TLSHandshakeTimeout = o.ConnectTimeout
+	}
+	switch {
+	case o.KeepAlive < 0:
+		dialer.KeepAlive = -1
+		t.DisableKeepAlives = true
+	case o.KeepAlive > 0:
+		dialer.KeepAlive = o.KeepAlive
+		t.IdleConnTimeout = o.KeepAlive
+	}
+	t.DialContext = dialer.DialContext
+
+	if o.ReadTimeout > 0 {
+		t.ResponseHeaderTimeout = o.ReadTimeout
+	}
+
+	return t, nil
+}
+
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q (want http, https, socks5 or socks5h)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in CA file %s", path)
+	}
+	return pool, nil
+}
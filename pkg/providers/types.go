@@ -12,6 +12,7 @@ type (
 	FunctionCall           = protocoltypes.FunctionCall
 	LLMResponse            = protocoltypes.LLMResponse
 	UsageInfo              = protocoltypes.UsageInfo
+	PromptTokensDetails    = protocoltypes.PromptTokensDetails
 	Message                = protocoltypes.Message
 	ToolDefinition         = protocoltypes.ToolDefinition
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
@@ -19,6 +20,16 @@ type (
 	GoogleExtra            = protocoltypes.GoogleExtra
 	ContentBlock           = protocoltypes.ContentBlock
 	CacheControl           = protocoltypes.CacheControl
+	StreamDelta            = protocoltypes.StreamDelta
+	ImagePart              = protocoltypes.ImagePart
+	Role                   = protocoltypes.Role
+)
+
+const (
+	RoleSystem    = protocoltypes.RoleSystem
+	RoleUser      = protocoltypes.RoleUser
+	RoleAssistant = protocoltypes.RoleAssistant
+	RoleTool      = protocoltypes.RoleTool
 )
 
 type LLMProvider interface {
@@ -37,6 +48,31 @@ type StatefulProvider interface {
 	Close()
 }
 
+// StreamingProvider is an optional interface for providers that can emit
+// partial output while a completion is still being generated. Channels use it
+// to show text as it arrives instead of waiting for the full response.
+//
+// The returned channel yields content fragments and ends with a single delta
+// whose Done field is set; that delta carries either the accumulated Response
+// or the error that terminated the stream.
+type StreamingProvider interface {
+	LLMProvider
+	ChatStream(
+		ctx context.Context,
+		messages []Message,
+		tools []ToolDefinition,
+		model string,
+		options map[string]any,
+	) (<-chan StreamDelta, error)
+}
+
+// EmbeddingProvider turns texts into embedding vectors for semantic memory
+// search and retrieval. Implementations are bound to a single embedding model
+// and return one vector per input, in input order.
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
 // ThinkingCapable is an optional interface for providers that support
 // extended thinking (e.g. Anthropic). Used by the agent loop to warn
 // when thinking_level is configured but the active provider cannot use it.
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// The malformed messages and sequences a MessageError reports.
+var (
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrMissingToolCallID = errors.New("missing tool call id")
+	ErrMisplacedToolCall = errors.New("tool calls on a message that is not the assistant's")
+	ErrOrphanToolResult  = errors.New("tool result without a matching tool call")
+)
+
+// MessageError is a message that must not reach a provider, as it is or at
+// its place in a history. It wraps one of the errors above.
+type MessageError struct {
+	Index      int // position in the history; 0 from ValidateMessage
+	Role       Role
+	ToolCallID string
+	Err        error
+}
+
+func (e *MessageError) Error() string {
+	if e.ToolCallID != "" {
+		return fmt.Sprintf("message %d (%s, tool call %s): %v", e.Index, e.Role, e.ToolCallID, e.Err)
+	}
+	return fmt.Sprintf("message %d (%s): %v", e.Index, e.Role, e.Err)
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateMessage checks a message on its own: a known role, tool calls on
+// assistant messages only, and the tool call IDs that tie results to calls.
+func ValidateMessage(m Message) error {
+	return validateMessage(m, 0)
+}
+
+// validateMessage is ValidateMessage for the message at index of a history.
+func validateMessage(m Message, index int) error {
+	fail := func(err error) error {
+		return &MessageError{Index: index, Role: m.Role, ToolCallID: m.ToolCallID, Err: err}
+	}
+	switch {
+	case !m.Role.Valid():
+		return fail(ErrInvalidRole)
+	case len(m.ToolCalls) > 0 && m.Role != RoleAssistant:
+		return fail(ErrMisplacedToolCall)
+	case m.Role == RoleTool && m.ToolCallID == "":
+		return fail(ErrMissingToolCallID)
+	}
+	for _, tc := range m.ToolCalls {
+		if tc.ID == "" {
+			return fail(ErrMissingToolCallID)
+		}
+	}
+	return nil
+}
+
+// ValidateNext checks a message to be appended to history: valid on its
+// own, and a tool result only right after the assistant message that made
+// its call, among the results of that message's other calls, answering
+// each call once. history itself is not checked.
+func ValidateNext(history []Message, next Message) error {
+	if err := validateMessage(next, len(history)); err != nil {
+		return err
+	}
+	if next.Role != RoleTool {
+		return nil
+	}
+	i := len(history) - 1
+	for ; i >= 0 && history[i].Role == RoleTool; i-- {
+		if history[i].ToolCallID == next.ToolCallID {
+			i = -1 // answered already
+			break
+		}
+	}
+	if i < 0 || history[i].Role != RoleAssistant ||
+		!slices.ContainsFunc(history[i].ToolCalls, func(tc ToolCall) bool { return tc.ID == next.ToolCallID }) {
+		return &MessageError{Index: len(history), Role: next.Role, ToolCallID: next.ToolCallID, Err: ErrOrphanToolResult}
+	}
+	return nil
+}
+
+// ValidateHistory checks every message of history with ValidateNext and
+// returns the error of the first one that fails. Tool calls still waiting
+// for their results are not an error: the history may end mid-turn.
+func ValidateHistory(history []Message) error {
+	for i, m := range history {
+		if err := ValidateNext(history[:i], m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRole_Valid(t *testing.T) {
+	for _, r := range []Role{RoleSystem, RoleUser, RoleAssistant, RoleTool} {
+		if !r.Valid() {
+			t.Errorf("%q is not valid", r)
+		}
+	}
+	for _, r := range []Role{"", "User", "function", "model"} {
+		if r.Valid() {
+			t.Errorf("%q is valid", r)
+		}
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want error
+	}{
+		{"user", Message{Role: RoleUser, Content: "hi"}, nil},
+		{"tool call", Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "c1"}}}, nil},
+		{"unknown role", Message{Role: "bot", Content: "hi"}, ErrInvalidRole},
+		{"tool calls of the user", Message{Role: RoleUser, ToolCalls: []ToolCall{{ID: "c1"}}}, ErrMisplacedToolCall},
+		{"tool call without id", Message{Role: RoleAssistant, ToolCalls: []ToolCall{{}}}, ErrMissingToolCallID},
+		{"tool result without id", Message{Role: RoleTool, Content: "42"}, ErrMissingToolCallID},
+	}
+	for _, tt := range tests {
+		err := ValidateMessage(tt.msg)
+		if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%s: ValidateMessage() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidateHistory(t *testing.T) {
+	call := func(ids ...string) Message {
+		m := Message{Role: RoleAssistant}
+		for _, id := range ids {
+			m.ToolCalls = append(m.ToolCalls, ToolCall{ID: id, Type: "function"})
+		}
+		return m
+	}
+	result := func(id string) Message { return Message{Role: RoleTool, Content: "ok", ToolCallID: id} }
+	user := Message{Role: RoleUser, Content: "hi"}
+
+	tests := []struct {
+		name    string
+		history []Message
+		index   int // of the failing message, -1 for a valid history
+	}{
+		{"empty", nil, -1},
+		{"calls answered", []Message{user, call("a", "b"), result("b"), result("a"), {Role: RoleAssistant}}, -1},
+		{"calls pending", []Message{user, call("a", "b"), result("a")}, -1},
+		{"leading result", []Message{result("a"), user}, 0},
+		{"result after the user", []Message{user, call("a"), result("a"), user, result("a")}, 4},
+		{"result of another call", []Message{user, call("a"), result("b")}, 2},
+		{"answered twice", []Message{user, call("a", "b"), result("a"), result("a")}, 3},
+		{"bad role", []Message{user, {Role: "function"}}, 1},
+	}
+	for _, tt := range tests {
+		err := ValidateHistory(tt.history)
+		if tt.index < 0 {
+			if err != nil {
+				t.Errorf("%s: ValidateHistory() = %v", tt.name, err)
+			}
+			continue
+		}
+		var msgErr *MessageError
+		if !errors.As(err, &msgErr) || msgErr.Index != tt.index {
+			t.Errorf("%s: ValidateHistory() = %v, want an error at message %d", tt.name, err, tt.index)
+		}
+	}
+
+	err := ValidateNext([]Message{user}, result("x"))
+	var msgErr *MessageError
+	if !errors.As(err, &msgErr) || !errors.Is(err, ErrOrphanToolResult) || msgErr.ToolCallID != "x" || msgErr.Index != 1 {
+		t.Errorf("ValidateNext() = %#v", err)
+	}
+	if got := err.Error(); got != "message 1 (tool, tool call x): tool result without a matching tool call" {
+		t.Errorf("Error() = %q", got)
+	}
+}
@@ -2,9 +2,9 @@ package routing
 
 import (
 	"strings"
-	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 )
 
 // lookbackWindow is the number of recent history entries scanned for tool calls.
@@ -50,23 +50,10 @@ func ExtractFeatures(msg string, history []providers.Message) Features {
 	}
 }
 
-// estimateTokens returns a token count proxy that handles both CJK and Latin text.
-// CJK runes (U+2E80–U+9FFF, U+F900–U+FAFF, U+AC00–U+D7AF) map to roughly one
-// token each, while non-CJK runes average ~0.25 tokens/rune (≈4 chars per token
-// for English). Splitting the count this way avoids the 3x underestimation that a
-// flat rune_count/3 would produce for Chinese, Japanese, and Korean text.
+// estimateTokens returns a token count proxy that handles both CJK and Latin
+// text; see tokenizer.Count.
 func estimateTokens(msg string) int {
-	total := utf8.RuneCountInString(msg)
-	if total == 0 {
-		return 0
-	}
-	cjk := 0
-	for _, r := range msg {
-		if r >= 0x2E80 && r <= 0x9FFF || r >= 0xF900 && r <= 0xFAFF || r >= 0xAC00 && r <= 0xD7AF {
-			cjk++
-		}
-	}
-	return cjk + (total-cjk)/4
+	return tokenizer.Count(msg)
 }
 
 // countCodeBlocks counts the number of complete fenced code blocks.
@@ -14,6 +14,8 @@ type RouteInput struct {
 	ParentPeer *RoutePeer
 	GuildID    string
 	TeamID     string
+	SenderID   string
+	ThreadID   string
 }
 
 // ResolvedRoute is the result of agent routing.
@@ -49,6 +51,7 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 		dmScope = DMScopeMain
 	}
 	identityLinks := r.cfg.Session.IdentityLinks
+	policy := SessionPolicy(r.cfg.Session.Policies[channel])
 
 	bindings := r.filterBindings(channel, accountID)
 
@@ -61,6 +64,9 @@ func (r *RouteResolver) ResolveRoute(input RouteInput) ResolvedRoute {
 			Peer:          peer,
 			DMScope:       dmScope,
 			IdentityLinks: identityLinks,
+			Policy:        policy,
+			SenderID:      input.SenderID,
+			ThreadID:      input.ThreadID,
 		}))
 		mainSessionKey := strings.ToLower(BuildAgentMainSessionKey(resolvedAgentID))
 		return ResolvedRoute{
@@ -295,3 +295,37 @@ func TestResolveRoute_NoDefaultUsesFirst(t *testing.T) {
 		t.Errorf("AgentID = %q, want 'alpha' (first in list)", route.AgentID)
 	}
 }
+
+func TestResolveRoute_SessionPolicy(t *testing.T) {
+	cfg := testConfig(nil, nil)
+	cfg.Session.Policies = map[string]string{"slack": "per-thread", "discord": "global"}
+	r := NewRouteResolver(cfg)
+
+	route := r.ResolveRoute(RouteInput{
+		Channel:  "slack",
+		Peer:     &RoutePeer{Kind: "channel", ID: "C123"},
+		SenderID: "U9",
+		ThreadID: "1712345.678",
+	})
+	if want := "agent:main:slack:channel:c123:thread:1712345.678"; route.SessionKey != want {
+		t.Errorf("slack SessionKey = %q, want %q", route.SessionKey, want)
+	}
+
+	route = r.ResolveRoute(RouteInput{
+		Channel: "discord",
+		Peer:    &RoutePeer{Kind: "group", ID: "G1"},
+	})
+	if route.SessionKey != route.MainSessionKey {
+		t.Errorf("discord SessionKey = %q, want the main session", route.SessionKey)
+	}
+
+	// Channels without a policy are unchanged.
+	route = r.ResolveRoute(RouteInput{
+		Channel:  "telegram",
+		Peer:     &RoutePeer{Kind: "group", ID: "-100"},
+		ThreadID: "7",
+	})
+	if want := "agent:main:telegram:group:-100"; route.SessionKey != want {
+		t.Errorf("telegram SessionKey = %q, want %q", route.SessionKey, want)
+	}
+}
@@ -20,6 +20,10 @@ type RouterConfig struct {
 	// score >= Threshold → primary (heavy) model.
 	// score <  Threshold → light model.
 	Threshold float64
+
+	// Routes maps a task class to a model_name (from model_list). A matching
+	// route takes precedence over complexity scoring.
+	Routes map[TaskClass]string
 }
 
 // Router selects the appropriate model tier for each incoming message.
@@ -56,6 +60,8 @@ func newWithClassifier(cfg RouterConfig, c Classifier) *Router {
 //   - If score < cfg.Threshold: returns (cfg.LightModel, true, score)
 //   - Otherwise:               returns (primaryModel, false, score)
 //
+// With no light model configured the primary model is always returned.
+//
 // The caller is responsible for resolving the returned model name into
 // provider candidates (see AgentInstance.LightCandidates).
 func (r *Router) SelectModel(
@@ -65,7 +71,7 @@ func (r *Router) SelectModel(
 ) (model string, usedLight bool, score float64) {
 	features := ExtractFeatures(msg, history)
 	score = r.classifier.Score(features)
-	if score < r.cfg.Threshold {
+	if score < r.cfg.Threshold && r.cfg.LightModel != "" {
 		return r.cfg.LightModel, true, score
 	}
 	return primaryModel, false, score
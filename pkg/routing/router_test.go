@@ -412,3 +412,52 @@ func TestRouter_SelectModel_ReturnsScore(t *testing.T) {
 		t.Errorf("score: got %f, want 0.42", score)
 	}
 }
+
+// ── Task routes ──────────────────────────────────────────────────────────────
+
+func TestClassifyTask(t *testing.T) {
+	toolHistory := []providers.Message{
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "1"}, {ID: "2"}}},
+		{Role: "tool", Content: "r"},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{ID: "3"}, {ID: "4"}}},
+	}
+	tests := []struct {
+		name    string
+		hint    TaskClass
+		history []providers.Message
+		want    TaskClass
+	}{
+		{name: "no hint, quiet history", want: TaskChat},
+		{name: "no hint, dense tool calls", history: toolHistory, want: TaskToolHeavy},
+		{name: "hint wins over history", hint: TaskHeartbeat, history: toolHistory, want: TaskHeartbeat},
+		{name: "summarization hint", hint: TaskSummarization, want: TaskSummarization},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyTask(tt.hint, tt.history); got != tt.want {
+				t.Errorf("ClassifyTask() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	r := New(RouterConfig{Routes: map[TaskClass]string{TaskHeartbeat: "cheap", TaskChat: ""}})
+	if model, ok := r.Route(TaskHeartbeat); !ok || model != "cheap" {
+		t.Errorf("Route(heartbeat) = (%q, %v), want (cheap, true)", model, ok)
+	}
+	if _, ok := r.Route(TaskChat); ok {
+		t.Error("Route(chat) with empty model should not match")
+	}
+	if _, ok := r.Route(TaskToolHeavy); ok {
+		t.Error("Route(tool_heavy) without config should not match")
+	}
+}
+
+func TestRouter_SelectModel_NoLightModelKeepsPrimary(t *testing.T) {
+	r := New(RouterConfig{Routes: map[TaskClass]string{TaskHeartbeat: "cheap"}})
+	model, usedLight, _ := r.SelectModel("hi", nil, "primary")
+	if model != "primary" || usedLight {
+		t.Errorf("SelectModel() = (%q, %v), want (primary, false)", model, usedLight)
+	}
+}
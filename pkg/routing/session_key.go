@@ -15,6 +15,24 @@ const (
 	DMScopePerAccountChannelPeer DMScope = "per-account-channel-peer"
 )
 
+// SessionPolicy decides which messages of a channel share a session.
+type SessionPolicy string
+
+const (
+	// SessionPolicyPerUser gives each sender one session for all chats of
+	// the channel, the one their direct chat with the bot uses.
+	SessionPolicyPerUser SessionPolicy = "per-user"
+	// SessionPolicyPerChat gives each direct chat, group and channel its own
+	// session, shared by everyone in it.
+	SessionPolicyPerChat SessionPolicy = "per-chat"
+	// SessionPolicyPerThread is per-chat, with a session of its own for each
+	// thread of a chat.
+	SessionPolicyPerThread SessionPolicy = "per-thread"
+	// SessionPolicyGlobal puts every message of the channel in the agent's
+	// main session.
+	SessionPolicyGlobal SessionPolicy = "global"
+)
+
 // RoutePeer represents a chat peer with kind and ID.
 type RoutePeer struct {
 	Kind string // "direct", "group", "channel"
@@ -29,6 +47,11 @@ type SessionKeyParams struct {
 	Peer          *RoutePeer
 	DMScope       DMScope
 	IdentityLinks map[string][]string
+	// Policy overrides how the key is scoped; empty keeps the DMScope rule
+	// for direct chats and a session per group or channel.
+	Policy   SessionPolicy
+	SenderID string // used by SessionPolicyPerUser
+	ThreadID string // used by SessionPolicyPerThread
 }
 
 // ParsedSessionKey is the result of parsing an agent-scoped session key.
@@ -42,10 +65,42 @@ func BuildAgentMainSessionKey(agentID string) string {
 	return fmt.Sprintf("agent:%s:%s", NormalizeAgentID(agentID), DefaultMainKey)
 }
 
-// BuildAgentPeerSessionKey constructs a session key based on agent, channel, peer, and DM scope.
+// BuildAgentPeerSessionKey constructs a session key based on agent, channel, peer, DM scope and
+// session policy.
 func BuildAgentPeerSessionKey(params SessionKeyParams) string {
 	agentID := NormalizeAgentID(params.AgentID)
 
+	switch params.Policy {
+	case "":
+		return buildPeerSessionKey(params)
+	case SessionPolicyGlobal:
+		return BuildAgentMainSessionKey(agentID)
+	case SessionPolicyPerUser:
+		if sender := strings.TrimSpace(params.SenderID); sender != "" {
+			params.Peer = &RoutePeer{Kind: "direct", ID: sender}
+		}
+	}
+	// A policy asks for separate sessions, so direct chats may not collapse
+	// into the main one.
+	if params.DMScope == "" || params.DMScope == DMScopeMain {
+		params.DMScope = DMScopePerChannelPeer
+	}
+	key := buildPeerSessionKey(params)
+	if params.Policy == SessionPolicyPerThread {
+		// The thread ID ends up in the session file name, so it may not
+		// contain path separators.
+		thread := strings.ToLower(strings.TrimSpace(params.ThreadID))
+		if thread = strings.NewReplacer("/", "_", `\`, "_").Replace(thread); thread != "" {
+			key += ":thread:" + thread
+		}
+	}
+	return key
+}
+
+// buildPeerSessionKey builds the key without a session policy.
+func buildPeerSessionKey(params SessionKeyParams) string {
+	agentID := NormalizeAgentID(params.AgentID)
+
 	peer := params.Peer
 	if peer == nil {
 		peer = &RoutePeer{Kind: "direct"}
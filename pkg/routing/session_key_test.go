@@ -205,3 +205,72 @@ func TestIsSubagentSessionKey(t *testing.T) {
 		}
 	}
 }
+
+func TestBuildAgentPeerSessionKey_Policies(t *testing.T) {
+	group := &RoutePeer{Kind: "channel", ID: "C123"}
+	dm := &RoutePeer{Kind: "direct", ID: "U9"}
+	tests := []struct {
+		name   string
+		params SessionKeyParams
+		want   string
+	}{
+		{
+			"global",
+			SessionKeyParams{Policy: SessionPolicyGlobal, Peer: group, SenderID: "U9"},
+			"agent:main:main",
+		},
+		{
+			"per-chat group",
+			SessionKeyParams{Policy: SessionPolicyPerChat, Peer: group, SenderID: "U9"},
+			"agent:main:slack:channel:c123",
+		},
+		{
+			"per-chat direct ignores dm_scope main",
+			SessionKeyParams{Policy: SessionPolicyPerChat, Peer: dm, DMScope: DMScopeMain},
+			"agent:main:slack:direct:u9",
+		},
+		{
+			"per-user group shares the direct chat session",
+			SessionKeyParams{Policy: SessionPolicyPerUser, Peer: group, SenderID: "U9"},
+			"agent:main:slack:direct:u9",
+		},
+		{
+			"per-user follows identity links",
+			SessionKeyParams{
+				Policy:        SessionPolicyPerUser,
+				Peer:          group,
+				SenderID:      "U9",
+				DMScope:       DMScopePerPeer,
+				IdentityLinks: map[string][]string{"alice": {"slack:u9"}},
+			},
+			"agent:main:direct:alice",
+		},
+		{
+			"per-user without sender falls back to the chat",
+			SessionKeyParams{Policy: SessionPolicyPerUser, Peer: group},
+			"agent:main:slack:channel:c123",
+		},
+		{
+			"per-thread",
+			SessionKeyParams{Policy: SessionPolicyPerThread, Peer: group, ThreadID: "1712345.678"},
+			"agent:main:slack:channel:c123:thread:1712345.678",
+		},
+		{
+			"per-thread sanitizes separators",
+			SessionKeyParams{Policy: SessionPolicyPerThread, Peer: group, ThreadID: "a/b"},
+			"agent:main:slack:channel:c123:thread:a_b",
+		},
+		{
+			"per-thread outside a thread",
+			SessionKeyParams{Policy: SessionPolicyPerThread, Peer: group},
+			"agent:main:slack:channel:c123",
+		},
+	}
+	for _, tt := range tests {
+		tt.params.AgentID = "main"
+		tt.params.Channel = "slack"
+		if got := BuildAgentPeerSessionKey(tt.params); got != tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package routing
+
+import (
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// TaskClass names the kind of work an LLM call performs. Routes map a class
+// to a model so that background chores can use a cheap model while tool-heavy
+// turns get a strong one, independent of per-message complexity scoring.
+type TaskClass string
+
+const (
+	// TaskChat is an ordinary user-facing conversation turn.
+	TaskChat TaskClass = "chat"
+	// TaskToolHeavy is a turn inside an active agentic workflow, detected
+	// from tool call density in recent history.
+	TaskToolHeavy TaskClass = "tool_heavy"
+	// TaskHeartbeat is a periodic heartbeat check with no session history.
+	TaskHeartbeat TaskClass = "heartbeat"
+	// TaskSummarization is a background call that compresses session history.
+	TaskSummarization TaskClass = "summarization"
+)
+
+// toolHeavyThreshold is the number of recent tool calls above which a turn is
+// classified as TaskToolHeavy. It matches the RuleClassifier's high-density tier.
+const toolHeavyThreshold = 3
+
+// ClassifyTask returns the task class for a turn. An explicit hint from the
+// caller (heartbeat, summarization) wins; otherwise the class is derived from
+// the structure of the recent history.
+func ClassifyTask(hint TaskClass, history []providers.Message) TaskClass {
+	if hint != "" {
+		return hint
+	}
+	if countRecentToolCalls(history) > toolHeavyThreshold {
+		return TaskToolHeavy
+	}
+	return TaskChat
+}
+
+// Route returns the model_name configured for class, if any.
+func (r *Router) Route(class TaskClass) (string, bool) {
+	model, ok := r.cfg.Routes[class]
+	return model, ok && model != ""
+}
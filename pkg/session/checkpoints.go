@@ -0,0 +1,193 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// maxCheckpoints bounds the checkpoints kept per session; taking another
+// drops the oldest.
+const maxCheckpoints = 10
+
+// checkpointsExt is the extension of the file that holds the checkpoints
+// of a session, next to the session's own file.
+const checkpointsExt = ".checkpoints"
+
+// ErrCheckpointNotFound is returned by Rollback for an unknown checkpoint
+// ID, or one of another session.
+var ErrCheckpointNotFound = errors.New("no such checkpoint")
+
+// Checkpoint is a copy of a session's history and summary, taken before an
+// operation on them that may need undoing.
+type Checkpoint struct {
+	ID       string              `json:"id"`
+	Label    string              `json:"label,omitempty"` // what it was taken for, "before summarize"
+	Created  time.Time           `json:"created"`
+	Summary  string              `json:"summary,omitempty"`
+	Messages []providers.Message `json:"messages"`
+}
+
+// CheckpointStore is a Store that keeps the checkpoints of its sessions
+// too. Its Delete removes them along with the session. The manager keeps
+// the checkpoints of a store without it in memory, as it does when a
+// method returns errors.ErrUnsupported.
+type CheckpointStore interface {
+	Store
+	// LoadCheckpoints returns the checkpoints of the session key, the
+	// oldest first.
+	LoadCheckpoints(key string) ([]Checkpoint, error)
+	// SaveCheckpoints stores the checkpoints of s, replacing those there.
+	// Only the key and person of s are used.
+	SaveCheckpoints(s Session, checkpoints []Checkpoint) error
+}
+
+// Checkpoint saves the current history and summary of a session under
+// label and returns the ID to roll back to them with.
+func (sm *SessionManager) Checkpoint(key, label string) (string, error) {
+	sm.mu.RLock()
+	stored, ok := sm.sessions[key]
+	if !ok {
+		sm.mu.RUnlock()
+		return "", fmt.Errorf("session %q not found", key)
+	}
+	owner := Session{Key: stored.Key, Person: stored.Person}
+	cp := Checkpoint{
+		ID:       newCheckpointID(),
+		Label:    label,
+		Created:  time.Now(),
+		Summary:  stored.Summary,
+		Messages: slices.Clone(stored.Messages),
+	}
+	sm.mu.RUnlock()
+
+	sm.checkpointMu.Lock()
+	defer sm.checkpointMu.Unlock()
+	checkpoints, err := sm.loadCheckpoints(key)
+	if err != nil {
+		return "", err
+	}
+	checkpoints = append(checkpoints, cp)
+	if len(checkpoints) > maxCheckpoints {
+		checkpoints = checkpoints[len(checkpoints)-maxCheckpoints:]
+	}
+	if err := sm.saveCheckpoints(owner, checkpoints); err != nil {
+		return "", err
+	}
+	return cp.ID, nil
+}
+
+// Checkpoints returns the checkpoints of a session, the oldest first.
+func (sm *SessionManager) Checkpoints(key string) ([]Checkpoint, error) {
+	sm.checkpointMu.Lock()
+	defer sm.checkpointMu.Unlock()
+	return sm.loadCheckpoints(key)
+}
+
+// Rollback restores the history and summary of a session to a checkpoint
+// and saves it. The checkpoint is kept, so the same state can be restored
+// again.
+func (sm *SessionManager) Rollback(key, checkpointID string) error {
+	sm.checkpointMu.Lock()
+	checkpoints, err := sm.loadCheckpoints(key)
+	sm.checkpointMu.Unlock()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(checkpoints, func(cp Checkpoint) bool { return cp.ID == checkpointID })
+	if i < 0 {
+		return ErrCheckpointNotFound
+	}
+	cp := checkpoints[i]
+
+	sm.mu.Lock()
+	session, ok := sm.sessions[key]
+	if !ok {
+		sm.mu.Unlock()
+		return fmt.Errorf("session %q not found", key)
+	}
+	old := session.Messages
+	session.Messages = slices.Clone(cp.Messages)
+	session.Summary = cp.Summary
+	replaceTopicMessages(session, old)
+	replaceFeedbackMessages(session, old)
+	session.Updated = time.Now()
+	sm.mu.Unlock()
+
+	return sm.Save(key)
+}
+
+// loadCheckpoints reads the checkpoints of key from the store, or from
+// memory when the store does not keep them. Call with checkpointMu held.
+func (sm *SessionManager) loadCheckpoints(key string) ([]Checkpoint, error) {
+	if cs, ok := sm.store.(CheckpointStore); ok {
+		checkpoints, err := cs.LoadCheckpoints(key)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return checkpoints, err
+		}
+	}
+	return slices.Clone(sm.checkpoints[key]), nil
+}
+
+// saveCheckpoints is the counterpart of loadCheckpoints.
+func (sm *SessionManager) saveCheckpoints(owner Session, checkpoints []Checkpoint) error {
+	if cs, ok := sm.store.(CheckpointStore); ok {
+		err := cs.SaveCheckpoints(owner, checkpoints)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return err
+		}
+	}
+	if sm.checkpoints == nil {
+		sm.checkpoints = make(map[string][]Checkpoint)
+	}
+	sm.checkpoints[owner.Key] = checkpoints
+	return nil
+}
+
+func newCheckpointID() string {
+	b := make([]byte, 8)
+	rand.Read(b) // never fails, see crypto/rand.Read
+	return hex.EncodeToString(b)
+}
+
+func (d *DirStore) LoadCheckpoints(key string) ([]Checkpoint, error) {
+	filename := sanitizeFilename(key)
+	if !isLocalFilename(filename) {
+		return nil, os.ErrInvalid
+	}
+	data, err := os.ReadFile(filepath.Join(d.dir, filename+checkpointsExt))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []Checkpoint
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("decode checkpoints of %s: %w", key, err)
+	}
+	return checkpoints, nil
+}
+
+// SaveCheckpoints writes the checkpoints readable by the owner only: they
+// hold whole conversations.
+func (d *DirStore) SaveCheckpoints(s Session, checkpoints []Checkpoint) error {
+	filename := sanitizeFilename(s.Key)
+	if !isLocalFilename(filename) {
+		return os.ErrInvalid
+	}
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(d.dir, filename+checkpointsExt), data, 0o600)
+}
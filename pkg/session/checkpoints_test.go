@@ -0,0 +1,130 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKey = NewKey()
+
+func TestCheckpointAndRollback(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T, dir string) *SessionManager{
+		"memory": func(*testing.T, string) *SessionManager { return NewSessionManager("") },
+		"dir":    func(_ *testing.T, dir string) *SessionManager { return NewSessionManager(dir) },
+		"encrypted": func(t *testing.T, dir string) *SessionManager {
+			sm, err := OpenManager(dir, testKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sm
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sm := open(t, dir)
+			const key = "agent:main:telegram:direct:42"
+			sm.AddMessage(key, "user", "my PIN is 4711")
+			sm.AddMessage(key, "assistant", "Noted.")
+			sm.SetSummary(key, "PIN talk")
+			sm.Save(key)
+
+			id, err := sm.Checkpoint(key, "before summarize")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sm.SetSummary(key, "something else")
+			sm.TruncateHistory(key, 0)
+			sm.Save(key)
+
+			if name != "memory" {
+				sm = open(t, dir) // checkpoints outlive the process
+			}
+			if err := sm.Rollback(key, "nope"); !errors.Is(err, ErrCheckpointNotFound) {
+				t.Errorf("Rollback(unknown) = %v, want ErrCheckpointNotFound", err)
+			}
+			if err := sm.Rollback(key, id); err != nil {
+				t.Fatal(err)
+			}
+			if h := sm.GetHistory(key); len(h) != 2 || h[0].Content != "my PIN is 4711" {
+				t.Errorf("history after rollback = %+v", h)
+			}
+			if got := sm.GetSummary(key); got != "PIN talk" {
+				t.Errorf("summary after rollback = %q", got)
+			}
+			checkpoints, err := sm.Checkpoints(key)
+			if err != nil || len(checkpoints) != 1 || checkpoints[0].Label != "before summarize" {
+				t.Errorf("Checkpoints = %+v, %v", checkpoints, err)
+			}
+
+			if _, err := sm.Delete(key); err != nil {
+				t.Fatal(err)
+			}
+			if checkpoints, _ := sm.Checkpoints(key); len(checkpoints) != 0 {
+				t.Errorf("checkpoints after Delete = %+v", checkpoints)
+			}
+			if files, _ := filepath.Glob(filepath.Join(dir, "*"+checkpointsExt+"*")); len(files) != 0 {
+				t.Errorf("checkpoint files left after Delete: %v", files)
+			}
+		})
+	}
+}
+
+func TestCheckpoint_KeepsTheLatest(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("s", "user", "hi")
+	var ids []string
+	for range maxCheckpoints + 2 {
+		id, err := sm.Checkpoint("s", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	checkpoints, _ := sm.Checkpoints("s")
+	if len(checkpoints) != maxCheckpoints || checkpoints[0].ID != ids[2] {
+		t.Errorf("kept %d checkpoints from %s, want %d from %s", len(checkpoints), checkpoints[0].ID, maxCheckpoints, ids[2])
+	}
+	if _, err := sm.Checkpoint("missing", ""); err == nil {
+		t.Error("Checkpoint of a missing session succeeded")
+	}
+}
+
+func TestCheckpointFiles(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	sm.AddMessage("agent:main:main", "user", "my PIN is 4711")
+	if _, err := sm.Checkpoint("agent:main:main", ""); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "agent_main_main"+checkpointsExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("checkpoint file mode = %o, want 600", perm)
+	}
+
+	dir = t.TempDir()
+	sm, err = OpenManager(dir, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm.AddMessage("agent:main:main", "user", "my PIN is 4711")
+	if _, err := sm.Checkpoint("agent:main:main", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "agent_main_main"+checkpointsExt+encryptedExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "4711") {
+		t.Error("encrypted checkpoint file holds the messages in the clear")
+	}
+	// The checkpoints are not taken for a session when the store is loaded.
+	if sessions, err := sm.store.Load(); err != nil || len(sessions) != 0 {
+		t.Errorf("Load = %+v, %v, want no sessions", sessions, err)
+	}
+}
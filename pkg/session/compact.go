@@ -0,0 +1,228 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// staleAge is how old a file no session uses must be before Compact
+// removes it, so the temporary file of a save under way is left alone.
+const staleAge = 10 * time.Minute
+
+// CompactStore is a Store that can give back the disk space of files no
+// session uses: the temporary files of saves cut short by a crash or a
+// power loss, and the checkpoints of sessions that are gone. The manager
+// treats a store without it, or one whose methods return
+// errors.ErrUnsupported, as having nothing to compact.
+type CompactStore interface {
+	Store
+	// Compact removes those files and returns the bytes it freed.
+	Compact() (int64, error)
+	// Stats measures the files of the store.
+	Stats() (StoreStats, error)
+}
+
+// StoreStats describes the files of a store. Stale files are the ones
+// Compact would remove.
+type StoreStats struct {
+	Sessions   int   `json:"sessions"`
+	Bytes      int64 `json:"bytes"` // of the session and checkpoint files
+	StaleFiles int   `json:"stale_files"`
+	StaleBytes int64 `json:"stale_bytes"`
+	// Compacted and Reclaimed count the Compact runs and the bytes freed by
+	// them and by saves of shorter sessions since the store was opened.
+	Compacted int64 `json:"compacted"`
+	Reclaimed int64 `json:"reclaimed_bytes"`
+}
+
+// SetCompactThreshold makes Save compact the store once TruncateHistory
+// and SetHistory have dropped at least n messages since it last did, so a
+// long-running device does not keep what crashes left behind forever.
+// Zero, the default, leaves compacting to Compact.
+func (sm *SessionManager) SetCompactThreshold(n int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.compactAt = max(n, 0)
+}
+
+// Compact removes the files of the store no session uses and returns the
+// bytes it freed.
+func (sm *SessionManager) Compact() (int64, error) {
+	if cs, ok := sm.store.(CompactStore); ok {
+		freed, err := cs.Compact()
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return freed, err
+		}
+	}
+	return 0, nil
+}
+
+// Stats measures the store; for a store that cannot measure itself only
+// the sessions are counted.
+func (sm *SessionManager) Stats() (StoreStats, error) {
+	if cs, ok := sm.store.(CompactStore); ok {
+		stats, err := cs.Stats()
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return stats, err
+		}
+	}
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return StoreStats{Sessions: len(sm.sessions)}, nil
+}
+
+// compactDue reports whether enough messages were dropped to compact the
+// store, and starts counting anew if so.
+func (sm *SessionManager) compactDue() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.compactAt == 0 || sm.droppedSince < sm.compactAt {
+		return false
+	}
+	sm.droppedSince = 0
+	return true
+}
+
+// compactAfterSave runs Compact when it is due, logging a failure: the
+// session itself was saved.
+func (sm *SessionManager) compactAfterSave() {
+	if !sm.compactDue() {
+		return
+	}
+	freed, err := sm.Compact()
+	if err != nil {
+		logger.WarnCF("session", "Cannot compact the session store", map[string]any{"error": err.Error()})
+		return
+	}
+	logger.DebugCF("session", "Compacted the session store", map[string]any{"freed_bytes": freed})
+}
+
+// diskSpace measures and reclaims the space of a directory of session
+// files, named <key><sessionExt> with their checkpoints in
+// <key><checkpointExt>.
+type diskSpace struct {
+	dir           string
+	sessionExt    string
+	checkpointExt string
+
+	compacted atomic.Int64
+	reclaimed atomic.Int64
+}
+
+// replaced records that a file of before bytes was replaced by one of size
+// bytes.
+func (sp *diskSpace) replaced(before int64, size int) {
+	if freed := before - int64(size); freed > 0 {
+		sp.reclaimed.Add(freed)
+	}
+}
+
+// stale reports whether the file called name is one no session uses: a
+// temporary file, or checkpoints without their session.
+func (sp *diskSpace) stale(name string, info os.FileInfo) bool {
+	if time.Since(info.ModTime()) < staleAge {
+		return false
+	}
+	// DirStore.Save writes session-*.tmp, fileutil.WriteFileAtomic .tmp-*.
+	if strings.HasPrefix(name, ".tmp-") || strings.HasPrefix(name, "session-") && strings.HasSuffix(name, ".tmp") {
+		return true
+	}
+	key, ok := strings.CutSuffix(name, sp.checkpointExt)
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(sp.dir, key+sp.sessionExt))
+	return os.IsNotExist(err)
+}
+
+// walk calls fn for each file in the directory.
+func (sp *diskSpace) walk(fn func(name string, info os.FileInfo)) error {
+	entries, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			fn(entry.Name(), info)
+		}
+	}
+	return nil
+}
+
+func (sp *diskSpace) compact() (int64, error) {
+	var freed int64
+	var errs []error
+	err := sp.walk(func(name string, info os.FileInfo) {
+		if !sp.stale(name, info) {
+			return
+		}
+		if err := os.Remove(filepath.Join(sp.dir, name)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			return
+		}
+		freed += info.Size()
+	})
+	if err != nil {
+		return 0, err
+	}
+	sp.compacted.Add(1)
+	sp.reclaimed.Add(freed)
+	return freed, errors.Join(errs...)
+}
+
+func (sp *diskSpace) stats() (StoreStats, error) {
+	stats := StoreStats{
+		Compacted: sp.compacted.Load(),
+		Reclaimed: sp.reclaimed.Load(),
+	}
+	err := sp.walk(func(name string, info os.FileInfo) {
+		switch {
+		case sp.stale(name, info):
+			stats.StaleFiles++
+			stats.StaleBytes += info.Size()
+			return
+		case strings.HasSuffix(name, sp.sessionExt) && !strings.HasSuffix(name, sp.checkpointExt):
+			stats.Sessions++
+		}
+		stats.Bytes += info.Size()
+	})
+	return stats, err
+}
+
+// fileSize returns the size of the file at path, or 0 if there is none.
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Compact removes the temporary files of interrupted saves and the
+// checkpoints of deleted sessions.
+func (d *DirStore) Compact() (int64, error) {
+	return d.space.compact()
+}
+
+func (d *DirStore) Stats() (StoreStats, error) {
+	return d.space.stats()
+}
+
+// Compact removes the temporary files of interrupted saves and the
+// checkpoints of deleted sessions.
+func (s *EncryptedStore) Compact() (int64, error) {
+	return s.space.compact()
+}
+
+func (s *EncryptedStore) Stats() (StoreStats, error) {
+	return s.space.stats()
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompactThreshold(t *testing.T) {
+	for name, tt := range map[string]struct {
+		open       func(t *testing.T, dir string) *SessionManager
+		checkpoint string // the checkpoints file of a session
+	}{
+		"dir": {
+			open:       func(_ *testing.T, dir string) *SessionManager { return NewSessionManager(dir) },
+			checkpoint: "gone" + checkpointsExt,
+		},
+		"encrypted": {
+			open: func(t *testing.T, dir string) *SessionManager {
+				sm, err := OpenManager(dir, testKey)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return sm
+			},
+			checkpoint: "gone" + checkpointsExt + encryptedExt,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sm := tt.open(t, dir)
+			sm.SetCompactThreshold(3)
+			const key = "telegram:1"
+			for range 6 {
+				sm.AddMessage(key, "user", "a long message about the garden and its tulips")
+			}
+			if err := sm.Save(key); err != nil {
+				t.Fatal(err)
+			}
+
+			// What a crash during a save and a session removed by hand
+			// leave behind, and the temporary file of a save under way.
+			old := time.Now().Add(-time.Hour)
+			for _, name := range []string{"session-123.tmp", tt.checkpoint} {
+				path := filepath.Join(dir, name)
+				if err := os.WriteFile(path, []byte("left over"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				os.Chtimes(path, old, old)
+			}
+			fresh := filepath.Join(dir, ".tmp-1-2")
+			if err := os.WriteFile(fresh, []byte("being written"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := sm.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.Sessions != 1 || stats.StaleFiles != 2 || stats.StaleBytes != 18 {
+				t.Errorf("Stats before compacting = %+v", stats)
+			}
+
+			// Below the threshold Save leaves the store alone.
+			sm.TruncateHistory(key, 4)
+			sm.Save(key)
+			if stats, _ := sm.Stats(); stats.StaleFiles != 2 || stats.Compacted != 0 {
+				t.Errorf("Stats below the threshold = %+v", stats)
+			}
+
+			sm.TruncateHistory(key, 1)
+			sm.Save(key)
+			stats, err = sm.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.StaleFiles != 0 || stats.Compacted != 1 || stats.Reclaimed <= 18 {
+				t.Errorf("Stats after compacting = %+v, want no stale files and more than 18 bytes reclaimed", stats)
+			}
+			if _, err := os.Stat(fresh); err != nil {
+				t.Errorf("the file of a save under way was removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestStats_InMemory(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.SetCompactThreshold(1)
+	sm.AddMessage("s", "user", "hi")
+	sm.TruncateHistory("s", 0)
+	if err := sm.Save("s"); err != nil {
+		t.Fatal(err)
+	}
+	if freed, err := sm.Compact(); freed != 0 || err != nil {
+		t.Errorf("Compact = %d, %v", freed, err)
+	}
+	if stats, err := sm.Stats(); err != nil || stats.Sessions != 1 {
+		t.Errorf("Stats = %+v, %v", stats, err)
+	}
+}
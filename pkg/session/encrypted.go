@@ -0,0 +1,399 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	// encryptedExt is the extension of encrypted session files. DirStore
+	// ignores them.
+	encryptedExt = ".enc"
+	// keyCheckFile holds a value derived from the master key, so a store
+	// opened with another key fails instead of hiding the sessions.
+	keyCheckFile  = ".key-check"
+	masterKeySize = 32
+	// checkpointsPurpose is authenticated with the checkpoints of a
+	// session, so they cannot pass for the session file.
+	checkpointsPurpose = "checkpoints"
+)
+
+// NewKey returns a random master key, encoded for the
+// session.encryption.key setting.
+func NewKey() string {
+	b := make([]byte, masterKeySize)
+	rand.Read(b) // never fails, see crypto/rand.Read
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// EncryptedStore keeps each session as a file in a directory, encrypted
+// with AES-256-GCM. Every identity has a key of its own, derived from the
+// master key with HKDF: the person a direct conversation is with, or else
+// the session itself. A leaked identity key opens that identity's
+// sessions and no others.
+//
+// Plain session files left in the directory, from before encryption was
+// turned on, are encrypted when the store is opened.
+type EncryptedStore struct {
+	dir    string
+	master []byte
+
+	mu         sync.Mutex
+	ciphers    map[string]cipher.AEAD // identity → its cipher
+	unreadable map[string]bool        // keys of the files that failed to open
+
+	space diskSpace
+}
+
+// sealedSession is the file of an encrypted session. The identity is kept
+// in the clear to find the key; it is authenticated along with the session
+// key, so a file cannot be moved to another session or identity.
+type sealedSession struct {
+	Key      string `json:"key"`
+	Identity string `json:"identity"`
+	Sealed   []byte `json:"sealed"` // nonce || ciphertext
+}
+
+// NewEncryptedStore creates a store in dir encrypted under key, a base64
+// master key from NewKey. It fails if the sessions in dir were encrypted
+// with another key.
+func NewEncryptedStore(dir, key string) (*EncryptedStore, error) {
+	master, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(master) != masterKeySize {
+		return nil, errors.New("encryption key must be 32 base64-encoded bytes")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &EncryptedStore{
+		dir:        dir,
+		master:     master,
+		ciphers:    make(map[string]cipher.AEAD),
+		unreadable: make(map[string]bool),
+		space:      diskSpace{dir: dir, sessionExt: encryptedExt, checkpointExt: checkpointsExt + encryptedExt},
+	}
+	if err := s.checkKey(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// checkKey compares the master key with the one the directory was first
+// encrypted with, recording it if there was none.
+func (s *EncryptedStore) checkKey() error {
+	mac := hmac.New(sha256.New, s.master)
+	mac.Write([]byte("picoclaw session key check"))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	path := filepath.Join(s.dir, keyCheckFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fileutil.WriteFileAtomic(path, []byte(want+"\n"), 0o600)
+	}
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(strings.TrimSpace(string(data))), []byte(want)) {
+		return errors.New("the sessions were encrypted with another key")
+	}
+	return nil
+}
+
+// identity returns whose key encrypts s.
+func identity(s Session) string {
+	if s.Person != "" {
+		return s.Person
+	}
+	return s.Key
+}
+
+// cipherFor returns the cipher of an identity.
+func (s *EncryptedStore) cipherFor(id string) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if aead, ok := s.ciphers[id]; ok {
+		return aead, nil
+	}
+	key, err := hkdf.Key(sha256.New, s.master, nil, "picoclaw session v1\x00"+id, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s.ciphers[id] = aead
+	return aead, nil
+}
+
+func additionalData(key, id, purpose string) []byte {
+	if purpose == "" {
+		return []byte(key + "\x00" + id)
+	}
+	return []byte(key + "\x00" + id + "\x00" + purpose)
+}
+
+func (s *EncryptedStore) Load() ([]Session, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	var plain []string
+	loaded := make(map[string]bool)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(s.dir, file.Name())
+		if strings.HasSuffix(file.Name(), checkpointsExt+encryptedExt) {
+			continue
+		}
+		switch filepath.Ext(file.Name()) {
+		case encryptedExt:
+			session, key, err := s.open(path)
+			if err != nil {
+				logger.WarnCF("session", "Cannot decrypt session", map[string]any{
+					"file":  file.Name(),
+					"error": err.Error(),
+				})
+				if key != "" {
+					s.mu.Lock()
+					s.unreadable[key] = true
+					s.mu.Unlock()
+				}
+				continue
+			}
+			sessions = append(sessions, session)
+			loaded[session.Key] = true
+		case ".json":
+			plain = append(plain, path)
+		case checkpointsExt:
+			// Checkpoints from before encryption are dropped rather than
+			// left in the clear.
+			os.Remove(path)
+		}
+	}
+
+	// Sessions stored before encryption was turned on. A plain file next
+	// to an encrypted one is an older copy that Save did not get to remove.
+	for _, path := range plain {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil || session.Key == "" {
+			continue
+		}
+		if loaded[session.Key] {
+			os.Remove(path)
+			continue
+		}
+		if err := s.Save(session); err != nil {
+			return nil, fmt.Errorf("encrypt session %s: %w", session.Key, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// open decrypts the session file at path. When the file names its session,
+// the key is returned even if the session cannot be decrypted.
+func (s *EncryptedStore) open(path string) (Session, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Session{}, "", err
+	}
+	key, plaintext, err := s.unseal(data, "")
+	if err != nil {
+		return Session{}, key, err
+	}
+	var session Session
+	if err := json.Unmarshal(plaintext, &session); err != nil {
+		return Session{}, key, err
+	}
+	if session.Key != key {
+		return Session{}, key, errors.New("session key does not match the file")
+	}
+	return session, key, nil
+}
+
+// seal encrypts plaintext for the session key under the key of identity
+// id. purpose tells the files of a session apart, so that one cannot pass
+// for another.
+func (s *EncryptedStore) seal(key, id, purpose string, plaintext []byte) ([]byte, error) {
+	aead, err := s.cipherFor(id)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return json.Marshal(sealedSession{
+		Key:      key,
+		Identity: id,
+		Sealed:   aead.Seal(nonce, nonce, plaintext, additionalData(key, id, purpose)),
+	})
+}
+
+// unseal decrypts a file written by seal for purpose and returns the
+// session key it names along with the plaintext. The key is returned even
+// if the file cannot be decrypted.
+func (s *EncryptedStore) unseal(data []byte, purpose string) (string, []byte, error) {
+	var sealed sealedSession
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return "", nil, err
+	}
+	aead, err := s.cipherFor(sealed.Identity)
+	if err != nil {
+		return sealed.Key, nil, err
+	}
+	n := aead.NonceSize()
+	if len(sealed.Sealed) < n {
+		return sealed.Key, nil, errors.New("file too short")
+	}
+	plaintext, err := aead.Open(nil, sealed.Sealed[:n], sealed.Sealed[n:],
+		additionalData(sealed.Key, sealed.Identity, purpose))
+	if err != nil {
+		return sealed.Key, nil, err
+	}
+	return sealed.Key, plaintext, nil
+}
+
+// Save encrypts s under the key of its identity. It refuses to replace a
+// file Load could not decrypt, which would lose that session for good.
+func (s *EncryptedStore) Save(session Session) error {
+	filename := sanitizeFilename(session.Key)
+	if !isLocalFilename(filename) {
+		return os.ErrInvalid
+	}
+	s.mu.Lock()
+	unreadable := s.unreadable[session.Key]
+	s.mu.Unlock()
+	if unreadable {
+		return fmt.Errorf("session %s could not be decrypted; not overwriting it", session.Key)
+	}
+
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	data, err := s.seal(session.Key, identity(session), "", plaintext)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, filename+encryptedExt)
+	before := fileSize(path)
+	if err := fileutil.WriteFileAtomic(path, data, 0o600); err != nil {
+		return err
+	}
+	s.space.replaced(before, len(data))
+	// The plain copy from before encryption, if any.
+	if err := os.Remove(filepath.Join(s.dir, filename+".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *EncryptedStore) Delete(key string) error {
+	filename := sanitizeFilename(key)
+	if !isLocalFilename(filename) {
+		return nil
+	}
+	for _, ext := range []string{encryptedExt, ".json", checkpointsExt + encryptedExt, checkpointsExt} {
+		err := os.Remove(filepath.Join(s.dir, filename+ext))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	s.mu.Lock()
+	delete(s.unreadable, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// LoadCheckpoints decrypts the checkpoints of the session key.
+func (s *EncryptedStore) LoadCheckpoints(key string) ([]Checkpoint, error) {
+	filename := sanitizeFilename(key)
+	if !isLocalFilename(filename) {
+		return nil, os.ErrInvalid
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, filename+checkpointsExt+encryptedExt))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sealedKey, plaintext, err := s.unseal(data, checkpointsPurpose)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt checkpoints of %s: %w", key, err)
+	}
+	if sealedKey != key {
+		return nil, fmt.Errorf("checkpoints of %s: session key does not match the file", key)
+	}
+	var checkpoints []Checkpoint
+	if err := json.Unmarshal(plaintext, &checkpoints); err != nil {
+		return nil, fmt.Errorf("decode checkpoints of %s: %w", key, err)
+	}
+	return checkpoints, nil
+}
+
+// SaveCheckpoints encrypts the checkpoints of session under the key of its
+// identity, as Save does the session.
+func (s *EncryptedStore) SaveCheckpoints(session Session, checkpoints []Checkpoint) error {
+	filename := sanitizeFilename(session.Key)
+	if !isLocalFilename(filename) {
+		return os.ErrInvalid
+	}
+	plaintext, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+	data, err := s.seal(session.Key, identity(session), checkpointsPurpose, plaintext)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filepath.Join(s.dir, filename+checkpointsExt+encryptedExt), data, 0o600)
+}
+
+// NewStore returns the store of the sessions in dir: encrypted under key
+// when it is set, otherwise plain JSON files.
+func NewStore(dir, key string) (Store, error) {
+	if key == "" {
+		return NewDirStore(dir), nil
+	}
+	return NewEncryptedStore(dir, key)
+}
+
+// OpenManager returns a manager for the sessions in dir, which are
+// encrypted under key when it is set.
+func OpenManager(dir, key string) (*SessionManager, error) {
+	store, err := NewStore(dir, key)
+	if err != nil {
+		return nil, err
+	}
+	sm := NewSessionManager("")
+	if err := sm.SetStore(store); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
@@ -0,0 +1,136 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func testSession(key, person, content string) Session {
+	now := time.Now().UTC().Truncate(time.Second)
+	return Session{
+		Key:      key,
+		Person:   person,
+		Messages: []providers.Message{{Role: "user", Content: content}},
+		Created:  now,
+		Updated:  now,
+	}
+}
+
+func TestEncryptedStore_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	key := NewKey()
+	store, err := NewEncryptedStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []Session{
+		testSession("agent:main:telegram:direct:42", "telegram:42", "my PIN is 4711"),
+		testSession("agent:main:telegram:group:7", "", "lunch at noon?"),
+	} {
+		if err := store.Save(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "agent_main_telegram_direct_42.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "4711") {
+		t.Error("the session file holds the message in the clear")
+	}
+
+	reopened, err := NewEncryptedStore(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := reopened.Load()
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("Load() = %d sessions, %v", len(sessions), err)
+	}
+	for _, s := range sessions {
+		if s.Key == "agent:main:telegram:direct:42" && s.Messages[0].Content != "my PIN is 4711" {
+			t.Errorf("loaded %+v", s)
+		}
+	}
+
+	if err := reopened.Delete("agent:main:telegram:group:7"); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := reopened.Load(); len(sessions) != 1 {
+		t.Errorf("Load() after Delete = %d sessions", len(sessions))
+	}
+}
+
+func TestEncryptedStore_KeysPerIdentity(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewEncryptedStore(dir, NewKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(testSession("agent:main:telegram:direct:42", "telegram:42", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := store.cipherFor("telegram:42")
+	b, _ := store.cipherFor("telegram:43")
+	if a == b {
+		t.Fatal("two identities share a cipher")
+	}
+
+	// Relabelling the file as another person's does not get it decrypted.
+	path := filepath.Join(dir, "agent_main_telegram_direct_42.enc")
+	data, _ := os.ReadFile(path)
+	var sealed sealedSession
+	json.Unmarshal(data, &sealed)
+	sealed.Identity = "telegram:43"
+	data, _ = json.Marshal(sealed)
+	os.WriteFile(path, data, 0o600)
+
+	sessions, err := store.Load()
+	if err != nil || len(sessions) != 0 {
+		t.Fatalf("Load() = %+v, %v", sessions, err)
+	}
+	if err := store.Save(testSession("agent:main:telegram:direct:42", "telegram:42", "new")); err == nil {
+		t.Error("Save() replaced a session it could not decrypt")
+	}
+}
+
+func TestEncryptedStore_WrongKey(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewEncryptedStore(dir, NewKey()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptedStore(dir, NewKey()); err == nil {
+		t.Error("NewEncryptedStore() accepted another key")
+	}
+	if _, err := NewEncryptedStore(t.TempDir(), "c2hvcnQ="); err == nil {
+		t.Error("NewEncryptedStore() accepted a short key")
+	}
+}
+
+func TestEncryptedStore_EncryptsPlainSessions(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewDirStore(dir).Save(testSession("agent:main:main", "", "from before")); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := OpenManager(dir, NewKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history := sm.GetHistory("agent:main:main"); len(history) != 1 || history[0].Content != "from before" {
+		t.Errorf("history = %+v", history)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "agent_main_main.json")); !os.IsNotExist(err) {
+		t.Error("the plain session file is left behind")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "agent_main_main.enc")); err != nil {
+		t.Errorf("no encrypted session file: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Ratings of an answer.
+const (
+	RatingBad  = -1
+	RatingGood = 1
+)
+
+// Feedback is what a user said about an answer of the assistant: a rating,
+// a correction, or both.
+type Feedback struct {
+	// Message is the index of the rated answer in Messages, or -1 once the
+	// answer was dropped from the history.
+	Message int `json:"message"`
+	// Rating is RatingGood, RatingBad or 0 for a correction alone.
+	Rating     int    `json:"rating,omitempty"`
+	Correction string `json:"correction,omitempty"`
+	// Answer and Question are the rated answer and the user message it
+	// answered, kept so the feedback outlives summarization.
+	Answer   string    `json:"answer"`
+	Question string    `json:"question,omitempty"`
+	From     string    `json:"from,omitempty"` // the account that gave it, "telegram:123456"
+	Created  time.Time `json:"created"`
+}
+
+// Low reports whether fb marks its answer as one to improve: rated bad or
+// corrected.
+func (fb Feedback) Low() bool {
+	return fb.Rating < 0 || fb.Correction != ""
+}
+
+// AddFeedback attaches fb to an answer of a session: the latest one that
+// contains answer, as a channel shows it, or the latest one when answer is
+// empty. A rating replaces the earlier rating of the same account on that
+// answer, and one without a rating or correction takes it back. It reports
+// whether there was an answer to attach to.
+func (sm *SessionManager) AddFeedback(key, answer string, fb Feedback) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return false
+	}
+	i := findAnswer(session.Messages, answer)
+	if i < 0 {
+		return false
+	}
+	fb.Message = i
+	fb.Answer = session.Messages[i].Content
+	for j := i - 1; j >= 0; j-- {
+		if session.Messages[j].Role == "user" {
+			fb.Question = session.Messages[j].Content
+			break
+		}
+	}
+	if fb.Created.IsZero() {
+		fb.Created = time.Now()
+	}
+
+	if fb.Correction == "" {
+		// A rating stands for the account's current verdict on the answer.
+		session.Feedback = slices.DeleteFunc(session.Feedback, func(old Feedback) bool {
+			return old.Message == i && old.From == fb.From && old.Correction == ""
+		})
+	}
+	if fb.Rating != 0 || fb.Correction != "" {
+		session.Feedback = append(session.Feedback, fb)
+	}
+	session.Updated = time.Now()
+	return true
+}
+
+// findAnswer returns the index of the latest assistant message with text
+// that contains answer, or -1. Whitespace is compared loosely, since
+// channels split and reflow what they send.
+func findAnswer(messages []providers.Message, answer string) int {
+	answer = strings.Join(strings.Fields(answer), " ")
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		if m.Role != "assistant" || strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		if answer == "" || strings.Contains(strings.Join(strings.Fields(m.Content), " "), answer) {
+			return i
+		}
+	}
+	return -1
+}
+
+// RatedAnswer is a feedback with the session it was given in.
+type RatedAnswer struct {
+	Session string `json:"session"`
+	Feedback
+}
+
+// FeedbackFilter selects the feedback ListFeedback returns. The zero
+// value selects all of it.
+type FeedbackFilter struct {
+	Low   bool      // only bad ratings and corrections
+	Since time.Time // given at or after Since
+	Limit int       // at most this many, the latest first; 0 for all
+}
+
+// ListFeedback returns the feedback given in all sessions that matches f,
+// the latest first.
+func (sm *SessionManager) ListFeedback(f FeedbackFilter) []RatedAnswer {
+	sm.mu.RLock()
+	var out []RatedAnswer
+	for key, session := range sm.sessions {
+		for _, fb := range session.Feedback {
+			if f.Low && !fb.Low() || fb.Created.Before(f.Since) {
+				continue
+			}
+			out = append(out, RatedAnswer{Session: key, Feedback: fb})
+		}
+	}
+	sm.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Created.Equal(out[j].Created) {
+			return out[i].Created.After(out[j].Created)
+		}
+		return out[i].Session < out[j].Session
+	})
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out
+}
+
+// dropFeedbackMessages moves the feedback of s after the first dropped
+// messages of its history were removed.
+func dropFeedbackMessages(s *Session, dropped int) {
+	for i := range s.Feedback {
+		if fb := &s.Feedback[i]; fb.Message >= 0 {
+			fb.Message -= dropped
+			if fb.Message < 0 {
+				fb.Message = -1
+			}
+		}
+	}
+}
+
+// replaceFeedbackMessages moves the feedback of s from the old history to
+// the one in s.Messages, finding the rated answers by their content.
+func replaceFeedbackMessages(s *Session, old []providers.Message) {
+	for i := range s.Feedback {
+		fb := &s.Feedback[i]
+		if fb.Message < 0 || fb.Message >= len(old) {
+			fb.Message = -1
+			continue
+		}
+		fb.Message = slices.IndexFunc(s.Messages, func(m providers.Message) bool {
+			return m.Role == "assistant" && m.Content == old[fb.Message].Content
+		})
+	}
+}
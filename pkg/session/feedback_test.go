@@ -0,0 +1,111 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func feedbackSession(t *testing.T) (*SessionManager, string) {
+	t.Helper()
+	sm := NewSessionManager(t.TempDir())
+	key := "agent:main:telegram:direct:42"
+	sm.GetOrCreate(key)
+	sm.SetHistory(key, []providers.Message{
+		{Role: "user", Content: "When does the museum close?"},
+		{Role: "assistant", Content: "The museum closes at 8pm."},
+		{Role: "user", Content: "And the café?"},
+		{Role: "assistant", Content: "", ToolCalls: []providers.ToolCall{{ID: "c1", Name: "web_search"}}},
+		{Role: "tool", Content: "café: 9-17", ToolCallID: "c1"},
+		{Role: "assistant", Content: "The café is open\nfrom 9 to 5."},
+	})
+	return sm, key
+}
+
+func TestAddFeedback(t *testing.T) {
+	sm, key := feedbackSession(t)
+
+	if !sm.AddFeedback(key, "", Feedback{Rating: RatingGood, From: "telegram:42"}) {
+		t.Fatal("AddFeedback() found no answer")
+	}
+	// Channels reflow the text they send.
+	if !sm.AddFeedback(key, "The museum closes  at 8pm.", Feedback{Rating: RatingBad, From: "telegram:42"}) {
+		t.Fatal("AddFeedback() did not find the museum answer")
+	}
+	if sm.AddFeedback(key, "Something never said", Feedback{Rating: RatingBad}) {
+		t.Error("AddFeedback() attached to an answer that is not there")
+	}
+
+	snap, _ := sm.Snapshot(key)
+	if len(snap.Feedback) != 2 {
+		t.Fatalf("feedback = %+v", snap.Feedback)
+	}
+	cafe, museum := snap.Feedback[0], snap.Feedback[1]
+	if cafe.Message != 5 || cafe.Question != "And the café?" || cafe.Answer != "The café is open\nfrom 9 to 5." {
+		t.Errorf("café feedback = %+v", cafe)
+	}
+	if museum.Message != 1 || museum.Question != "When does the museum close?" {
+		t.Errorf("museum feedback = %+v", museum)
+	}
+
+	// Changing a reaction replaces the rating; taking it back removes it.
+	sm.AddFeedback(key, "The museum closes at 8pm.", Feedback{Rating: RatingGood, From: "telegram:42"})
+	sm.AddFeedback(key, "The café is open", Feedback{From: "telegram:42"})
+	snap, _ = sm.Snapshot(key)
+	if len(snap.Feedback) != 1 || snap.Feedback[0].Message != 1 || snap.Feedback[0].Rating != RatingGood {
+		t.Errorf("feedback = %+v", snap.Feedback)
+	}
+
+	// A correction is kept next to the rating.
+	sm.AddFeedback(key, "", Feedback{Rating: RatingBad, Correction: "It closes at 4 on Sundays.", From: "telegram:42"})
+	if snap, _ = sm.Snapshot(key); len(snap.Feedback) != 2 {
+		t.Errorf("feedback = %+v", snap.Feedback)
+	}
+}
+
+func TestFeedback_FollowsHistory(t *testing.T) {
+	sm, key := feedbackSession(t)
+	sm.AddFeedback(key, "The museum closes at 8pm.", Feedback{Rating: RatingBad})
+	sm.AddFeedback(key, "", Feedback{Rating: RatingGood})
+
+	// Summarization keeps the last answer and drops the first.
+	history := sm.GetHistory(key)
+	sm.SetHistory(key, history[2:])
+	snap, _ := sm.Snapshot(key)
+	if snap.Feedback[0].Message != -1 || snap.Feedback[1].Message != 3 {
+		t.Errorf("after SetHistory: %+v", snap.Feedback)
+	}
+	if snap.Feedback[0].Answer != "The museum closes at 8pm." {
+		t.Errorf("the dropped answer is lost: %+v", snap.Feedback[0])
+	}
+
+	sm.TruncateHistory(key, 2)
+	snap, _ = sm.Snapshot(key)
+	if snap.Feedback[1].Message != 1 {
+		t.Errorf("after TruncateHistory: %+v", snap.Feedback)
+	}
+
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListFeedback(t *testing.T) {
+	sm, key := feedbackSession(t)
+	old := time.Now().Add(-48 * time.Hour)
+	sm.AddFeedback(key, "The museum closes at 8pm.", Feedback{Rating: RatingBad, Created: old})
+	sm.AddFeedback(key, "", Feedback{Rating: RatingGood, From: "a"})
+	sm.AddFeedback(key, "", Feedback{Correction: "5:30, not 5", From: "b"})
+
+	if got := sm.ListFeedback(FeedbackFilter{}); len(got) != 3 || got[2].Created != old || got[0].Session != key {
+		t.Errorf("all = %+v", got)
+	}
+	low := sm.ListFeedback(FeedbackFilter{Low: true, Since: time.Now().Add(-time.Hour)})
+	if len(low) != 1 || low[0].Correction != "5:30, not 5" {
+		t.Errorf("low = %+v", low)
+	}
+	if got := sm.ListFeedback(FeedbackFilter{Limit: 1}); len(got) != 1 {
+		t.Errorf("limited = %+v", got)
+	}
+}
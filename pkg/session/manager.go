@@ -1,9 +1,10 @@
 package session
 
 import (
-	"encoding/json"
-	"os"
+	"maps"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -17,23 +18,58 @@ type Session struct {
 	Summary  string              `json:"summary,omitempty"`
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
+
+	// Generation holds per-session overrides of the agent's sampling settings.
+	Generation *GenerationParams `json:"generation,omitempty"`
+
+	// Persona names the configured persona the session has switched to.
+	Persona string `json:"persona,omitempty"`
+
+	// Summarization names the strategy the session's history is summarized
+	// with instead of the agent's, set with /summary.
+	Summarization string `json:"summarization,omitempty"`
+
+	// Person is who a direct conversation is with; it is empty for groups.
+	Person string `json:"person,omitempty"`
+
+	// Scratchpad holds named text buffers the agent stashes during the
+	// session with the scratchpad tool.
+	Scratchpad map[string]string `json:"scratchpad,omitempty"`
+
+	// Topics divides Messages into the subjects the conversation went
+	// through, in order.
+	Topics []Topic `json:"topics,omitempty"`
+
+	// PII counts the personal data in the session by kind ("email",
+	// "phone", "card"), when session.pii flags it instead of masking it.
+	PII map[string]int `json:"pii,omitempty"`
+
+	// Feedback holds what users said about the answers, in the order it
+	// was given.
+	Feedback []Feedback `json:"feedback,omitempty"`
 }
 
 type SessionManager struct {
 	sessions map[string]*Session
 	mu       sync.RWMutex
-	storage  string
+	store    Store // nil keeps the sessions in memory only
+
+	compactAt    int // dropped messages that make Save compact the store; 0 never
+	droppedSince int // messages dropped since the store was last compacted
+
+	checkpointMu sync.Mutex
+	checkpoints  map[string][]Checkpoint // kept here when the store does not keep them
 }
 
+// NewSessionManager creates a manager that keeps its sessions as files in
+// the storage directory, or only in memory when storage is empty.
 func NewSessionManager(storage string) *SessionManager {
 	sm := &SessionManager{
 		sessions: make(map[string]*Session),
-		storage:  storage,
 	}
 
 	if storage != "" {
-		os.MkdirAll(storage, 0o755)
-		sm.loadSessions()
+		sm.SetStore(NewDirStore(storage))
 	}
 
 	return sm
@@ -59,21 +95,31 @@ func (sm *SessionManager) GetOrCreate(key string) *Session {
 	return session
 }
 
-func (sm *SessionManager) AddMessage(sessionKey, role, content string) {
-	sm.AddFullMessage(sessionKey, providers.Message{
-		Role:    role,
+// AddMessage adds a text message to the session; see AddFullMessage.
+func (sm *SessionManager) AddMessage(sessionKey, role, content string) error {
+	return sm.AddFullMessage(sessionKey, providers.Message{
+		Role:    providers.Role(role),
 		Content: content,
 	})
 }
 
 // AddFullMessage adds a complete message with tool calls and tool call ID to the session.
 // This is used to save the full conversation flow including tool calls and tool results.
-func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Message) {
+// A message that would corrupt the history, such as a tool result with no
+// matching tool call before it, is refused with a *providers.MessageError.
+func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Message) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	session, ok := sm.sessions[sessionKey]
-	if !ok {
+	if ok {
+		if err := providers.ValidateNext(session.Messages, msg); err != nil {
+			return err
+		}
+	} else {
+		if err := providers.ValidateNext(nil, msg); err != nil {
+			return err
+		}
 		session = &Session{
 			Key:      sessionKey,
 			Messages: []providers.Message{},
@@ -84,9 +130,17 @@ func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Messag
 
 	session.Messages = append(session.Messages, msg)
 	session.Updated = time.Now()
+	return nil
 }
 
 func (sm *SessionManager) GetHistory(key string) []providers.Message {
+	return sm.GetRecentHistory(key, 0)
+}
+
+// GetRecentHistory returns the last n messages of the session, or all of
+// them when n <= 0. Only those messages are copied, so turns of long
+// sessions stay cheap on devices with little memory.
+func (sm *SessionManager) GetRecentHistory(key string, n int) []providers.Message {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
@@ -95,11 +149,48 @@ func (sm *SessionManager) GetHistory(key string) []providers.Message {
 		return []providers.Message{}
 	}
 
-	history := make([]providers.Message, len(session.Messages))
-	copy(history, session.Messages)
+	messages := session.Messages
+	if n > 0 && len(messages) > n {
+		messages = messages[len(messages)-n:]
+	}
+	history := make([]providers.Message, len(messages))
+	copy(history, messages)
 	return history
 }
 
+// Keys returns the keys of all sessions in sorted order.
+func (sm *SessionManager) Keys() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	keys := make([]string, 0, len(sm.sessions))
+	for key := range sm.sessions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Snapshot returns a copy of the session with the given key, including its
+// messages, and reports whether it exists.
+func (sm *SessionManager) Snapshot(key string) (Session, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return Session{}, false
+	}
+	snapshot := *session
+	snapshot.Messages = make([]providers.Message, len(session.Messages))
+	copy(snapshot.Messages, session.Messages)
+	snapshot.Scratchpad = maps.Clone(session.Scratchpad)
+	snapshot.Topics = slices.Clone(session.Topics)
+	snapshot.PII = maps.Clone(session.PII)
+	snapshot.Feedback = slices.Clone(session.Feedback)
+	return snapshot, true
+}
+
 func (sm *SessionManager) GetSummary(key string) string {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -132,6 +223,9 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	}
 
 	if keepLast <= 0 {
+		sm.droppedSince += len(session.Messages)
+		dropFeedbackMessages(session, len(session.Messages))
+		session.Topics = nil
 		session.Messages = []providers.Message{}
 		session.Updated = time.Now()
 		return
@@ -141,7 +235,11 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 		return
 	}
 
-	session.Messages = session.Messages[len(session.Messages)-keepLast:]
+	dropped := len(session.Messages) - keepLast
+	sm.droppedSince += dropped
+	session.Messages = session.Messages[dropped:]
+	dropTopicMessages(session, dropped)
+	dropFeedbackMessages(session, dropped)
 	session.Updated = time.Now()
 }
 
@@ -154,22 +252,20 @@ func sanitizeFilename(key string) string {
 	return strings.ReplaceAll(key, ":", "_")
 }
 
+// isLocalFilename reports whether a session file called filename would be
+// directly inside the storage directory. filepath.IsLocal rejects empty
+// names, "..", absolute paths, and OS-reserved device names (NUL, COM1 … on
+// Windows); the extra checks reject "." and any directory separators.
+func isLocalFilename(filename string) bool {
+	return filename != "." && filepath.IsLocal(filename) && !strings.ContainsAny(filename, `/\`)
+}
+
 func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
+	if sm.store == nil {
 		return nil
 	}
 
-	filename := sanitizeFilename(key)
-
-	// filepath.IsLocal rejects empty names, "..", absolute paths, and
-	// OS-reserved device names (NUL, COM1 … on Windows).
-	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
-	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
-	}
-
-	// Snapshot under read lock, then perform slow file I/O after unlock.
+	// Snapshot under read lock, then perform slow I/O after unlock.
 	sm.mu.RLock()
 	stored, ok := sm.sessions[key]
 	if !ok {
@@ -178,10 +274,21 @@ func (sm *SessionManager) Save(key string) error {
 	}
 
 	snapshot := Session{
-		Key:     stored.Key,
-		Summary: stored.Summary,
-		Created: stored.Created,
-		Updated: stored.Updated,
+		Key:           stored.Key,
+		Summary:       stored.Summary,
+		Created:       stored.Created,
+		Updated:       stored.Updated,
+		Persona:       stored.Persona,
+		Summarization: stored.Summarization,
+		Person:        stored.Person,
+		Scratchpad:    maps.Clone(stored.Scratchpad),
+		Topics:        slices.Clone(stored.Topics),
+		PII:           maps.Clone(stored.PII),
+		Feedback:      slices.Clone(stored.Feedback),
+	}
+	if stored.Generation != nil {
+		generation := stored.Generation.clone()
+		snapshot.Generation = &generation
 	}
 	if len(stored.Messages) > 0 {
 		snapshot.Messages = make([]providers.Message, len(stored.Messages))
@@ -191,82 +298,70 @@ func (sm *SessionManager) Save(key string) error {
 	}
 	sm.mu.RUnlock()
 
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
+	if err := sm.store.Save(snapshot); err != nil {
 		return err
 	}
+	sm.compactAfterSave()
+	return nil
+}
 
-	sessionPath := filepath.Join(sm.storage, filename+".json")
-	tmpFile, err := os.CreateTemp(sm.storage, "session-*.tmp")
-	if err != nil {
-		return err
+// Delete removes the session with the given key and its checkpoints from
+// memory and the store, and reports whether it existed.
+func (sm *SessionManager) Delete(key string) (bool, error) {
+	sm.mu.Lock()
+	_, ok := sm.sessions[key]
+	delete(sm.sessions, key)
+	sm.mu.Unlock()
+	sm.checkpointMu.Lock()
+	delete(sm.checkpoints, key)
+	sm.checkpointMu.Unlock()
+	if !ok || sm.store == nil {
+		return ok, nil
 	}
+	return true, sm.store.Delete(key)
+}
 
-	tmpPath := tmpFile.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpPath)
+// Put stores s under s.Key, replacing the session there, and saves it. A
+// session the store rejects is not kept.
+func (sm *SessionManager) Put(s Session) error {
+	if sm.store != nil {
+		if err := sm.store.Save(s); err != nil {
+			return err
 		}
-	}()
-
-	if _, err := tmpFile.Write(data); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Chmod(0o644); err != nil {
-		_ = tmpFile.Close()
-		return err
-	}
-	if err := tmpFile.Sync(); err != nil {
-		_ = tmpFile.Close()
-		return err
 	}
-	if err := tmpFile.Close(); err != nil {
-		return err
-	}
-
-	if err := os.Rename(tmpPath, sessionPath); err != nil {
-		return err
-	}
-	cleanup = false
+	sm.mu.Lock()
+	sm.sessions[s.Key] = &s
+	sm.mu.Unlock()
 	return nil
 }
 
-func (sm *SessionManager) loadSessions() error {
-	files, err := os.ReadDir(sm.storage)
-	if err != nil {
-		return err
-	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
-		}
-
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
+// SetStore makes store where the sessions are kept, replacing the ones in
+// memory with those it holds. It is meant to be called before the manager
+// is used; nil keeps the sessions in memory only.
+func (sm *SessionManager) SetStore(store Store) error {
+	sessions := make(map[string]*Session)
+	if store != nil {
+		loaded, err := store.Load()
 		if err != nil {
-			continue
+			return err
 		}
-
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
+		for i := range loaded {
+			sessions[loaded[i].Key] = &loaded[i]
 		}
-
-		sm.sessions[session.Key] = &session
 	}
-
+	sm.mu.Lock()
+	sm.sessions, sm.store = sessions, store
+	sm.mu.Unlock()
 	return nil
 }
 
-// SetHistory updates the messages of a session.
-func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
+// SetHistory updates the messages of a session. A history that fails
+// providers.ValidateHistory is refused and the session is left as it was.
+func (sm *SessionManager) SetHistory(key string, history []providers.Message) error {
+	if err := providers.ValidateHistory(history); err != nil {
+		return err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -276,7 +371,12 @@ func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
 		// from the caller's slice.
 		msgs := make([]providers.Message, len(history))
 		copy(msgs, history)
+		old := session.Messages
 		session.Messages = msgs
+		sm.droppedSince += max(len(old)-len(msgs), 0)
+		replaceTopicMessages(session, old)
+		replaceFeedbackMessages(session, old)
 		session.Updated = time.Now()
 	}
+	return nil
 }
@@ -1,9 +1,12 @@
 package session
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func TestSanitizeFilename(t *testing.T) {
@@ -72,3 +75,151 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:123456"
+	sm.AddMessage(key, "user", "hello")
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	ok, err := sm.Delete(key)
+	if !ok || err != nil {
+		t.Fatalf("Delete = %v, %v; want true, nil", ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "telegram_123456.json")); !os.IsNotExist(err) {
+		t.Errorf("session file still exists: %v", err)
+	}
+	if keys := NewSessionManager(tmpDir).Keys(); len(keys) != 0 {
+		t.Errorf("sessions after Delete = %v, want none", keys)
+	}
+
+	if ok, err := sm.Delete(key); ok || err != nil {
+		t.Errorf("second Delete = %v, %v; want false, nil", ok, err)
+	}
+}
+
+func TestGetRecentHistory(t *testing.T) {
+	sm := NewSessionManager("")
+	key := "telegram:1"
+	for _, c := range []string{"a", "b", "c"} {
+		sm.AddMessage(key, "user", c)
+	}
+
+	if got := sm.GetRecentHistory(key, 2); len(got) != 2 || got[0].Content != "b" || got[1].Content != "c" {
+		t.Errorf("GetRecentHistory(2) = %v, want b, c", got)
+	}
+	if got := sm.GetRecentHistory(key, 0); len(got) != 3 {
+		t.Errorf("GetRecentHistory(0) has %d messages, want 3", len(got))
+	}
+	if got := sm.GetRecentHistory("missing", 2); len(got) != 0 {
+		t.Errorf("GetRecentHistory of a missing session = %v", got)
+	}
+}
+
+func TestAddFullMessage_RejectsOrphanToolResult(t *testing.T) {
+	sm := NewSessionManager("")
+	key := "telegram:1"
+	sm.AddMessage(key, "user", "hi")
+
+	orphan := providers.Message{Role: "tool", Content: "42", ToolCallID: "call_1"}
+	err := sm.AddFullMessage(key, orphan)
+	var msgErr *providers.MessageError
+	if !errors.As(err, &msgErr) || !errors.Is(err, providers.ErrOrphanToolResult) {
+		t.Fatalf("AddFullMessage(orphan) = %v, want ErrOrphanToolResult", err)
+	}
+	if h := sm.GetHistory(key); len(h) != 1 {
+		t.Errorf("history after rejected message = %+v", h)
+	}
+
+	call := providers.Message{
+		Role:      "assistant",
+		ToolCalls: []providers.ToolCall{{ID: "call_1", Name: "calc"}},
+	}
+	if err := sm.AddFullMessage(key, call); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.AddFullMessage(key, orphan); err != nil {
+		t.Errorf("AddFullMessage(result) after its call = %v", err)
+	}
+
+	if err := sm.SetHistory(key, []providers.Message{orphan}); !errors.Is(err, providers.ErrOrphanToolResult) {
+		t.Errorf("SetHistory(orphan) = %v, want ErrOrphanToolResult", err)
+	}
+	if h := sm.GetHistory(key); len(h) != 3 {
+		t.Errorf("history after rejected SetHistory has %d messages, want 3", len(h))
+	}
+}
+
+func TestPut(t *testing.T) {
+	tmpDir := t.TempDir()
+	sm := NewSessionManager(tmpDir)
+
+	key := "telegram:1"
+	if err := sm.Put(Session{Key: key, Summary: "about tulips", Messages: []providers.Message{}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if got := NewSessionManager(tmpDir).GetSummary(key); got != "about tulips" {
+		t.Errorf("summary after reload = %q", got)
+	}
+	if err := sm.Put(Session{Key: "../escape"}); err != os.ErrInvalid {
+		t.Errorf("Put with a traversing key = %v, want os.ErrInvalid", err)
+	}
+	if _, ok := sm.Snapshot("../escape"); ok {
+		t.Error("rejected session was stored")
+	}
+}
+
+// mapStore is a Store that keeps the sessions in a map.
+type mapStore map[string]Session
+
+func (m mapStore) Load() ([]Session, error) {
+	var out []Session
+	for _, s := range m {
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (m mapStore) Save(s Session) error {
+	m[s.Key] = s
+	return nil
+}
+
+func (m mapStore) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func TestSetStore(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("telegram:9", "user", "not in the store")
+
+	store := mapStore{"telegram:1": {Key: "telegram:1", Summary: "about tulips"}}
+	if err := sm.SetStore(store); err != nil {
+		t.Fatalf("SetStore failed: %v", err)
+	}
+	if got := sm.GetSummary("telegram:1"); got != "about tulips" {
+		t.Errorf("summary from the store = %q", got)
+	}
+	if _, ok := sm.Snapshot("telegram:9"); ok {
+		t.Error("session from before SetStore kept")
+	}
+
+	sm.AddMessage("telegram:2", "user", "hello")
+	if err := sm.Save("telegram:2"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if got := store["telegram:2"].Messages; len(got) != 1 || got[0].Content != "hello" {
+		t.Errorf("saved messages = %v", got)
+	}
+	if ok, err := sm.Delete("telegram:1"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if _, ok := store["telegram:1"]; ok {
+		t.Error("deleted session still in the store")
+	}
+}
@@ -0,0 +1,220 @@
+package session
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// GenerationParams overrides the agent's sampling settings for one session.
+// Unset fields fall back to the agent defaults.
+type GenerationParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// GenerationParamKeys lists the keys accepted by GenerationParams.Set.
+var GenerationParamKeys = []string{"temperature", "top_p", "max_tokens", "stop"}
+
+// IsZero reports whether p overrides nothing.
+func (p GenerationParams) IsZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == 0 && len(p.Stop) == 0
+}
+
+// Set parses value and assigns it to key. The value "default" clears the
+// override; stop sequences are given as a comma-separated list.
+func (p *GenerationParams) Set(key, value string) error {
+	value = strings.TrimSpace(value)
+	reset := value == "default"
+
+	switch key {
+	case "temperature":
+		if reset {
+			p.Temperature = nil
+			return nil
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > 2 {
+			return fmt.Errorf("temperature must be a number between 0 and 2")
+		}
+		p.Temperature = &v
+	case "top_p":
+		if reset {
+			p.TopP = nil
+			return nil
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 0 || v > 1 {
+			return fmt.Errorf("top_p must be a number in (0, 1]")
+		}
+		p.TopP = &v
+	case "max_tokens":
+		if reset {
+			p.MaxTokens = 0
+			return nil
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("max_tokens must be a positive integer")
+		}
+		p.MaxTokens = v
+	case "stop":
+		if reset {
+			p.Stop = nil
+			return nil
+		}
+		var stop []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				stop = append(stop, s)
+			}
+		}
+		if len(stop) == 0 {
+			return fmt.Errorf("stop must list at least one sequence")
+		}
+		p.Stop = stop
+	default:
+		return fmt.Errorf("unknown parameter %q (want one of %s)", key, strings.Join(GenerationParamKeys, ", "))
+	}
+	return nil
+}
+
+// String formats the overrides as "key=value" pairs, or "defaults".
+func (p GenerationParams) String() string {
+	var parts []string
+	if p.Temperature != nil {
+		parts = append(parts, "temperature="+strconv.FormatFloat(*p.Temperature, 'g', -1, 64))
+	}
+	if p.TopP != nil {
+		parts = append(parts, "top_p="+strconv.FormatFloat(*p.TopP, 'g', -1, 64))
+	}
+	if p.MaxTokens > 0 {
+		parts = append(parts, "max_tokens="+strconv.Itoa(p.MaxTokens))
+	}
+	if len(p.Stop) > 0 {
+		parts = append(parts, "stop="+strings.Join(p.Stop, ","))
+	}
+	if len(parts) == 0 {
+		return "defaults"
+	}
+	return strings.Join(parts, " ")
+}
+
+// clone returns a copy of p that shares no memory with it.
+func (p GenerationParams) clone() GenerationParams {
+	out := GenerationParams{MaxTokens: p.MaxTokens, Stop: slices.Clone(p.Stop)}
+	if p.Temperature != nil {
+		v := *p.Temperature
+		out.Temperature = &v
+	}
+	if p.TopP != nil {
+		v := *p.TopP
+		out.TopP = &v
+	}
+	return out
+}
+
+// GetGenerationParams returns the generation overrides of a session.
+func (sm *SessionManager) GetGenerationParams(key string) GenerationParams {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || session.Generation == nil {
+		return GenerationParams{}
+	}
+	return session.Generation.clone()
+}
+
+// SetGenerationParams replaces the generation overrides of a session,
+// creating it if needed. A zero value removes all overrides.
+func (sm *SessionManager) SetGenerationParams(key string, params GenerationParams) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+
+	if params.IsZero() {
+		session.Generation = nil
+	} else {
+		p := params.clone()
+		session.Generation = &p
+	}
+	session.Updated = time.Now()
+}
+
+// GetPersona returns the persona a session has switched to, or "".
+func (sm *SessionManager) GetPersona(key string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, ok := sm.sessions[key]; ok {
+		return session.Persona
+	}
+	return ""
+}
+
+// SetPersona switches a session to the named persona, creating the session
+// if needed. The empty name switches back to the agent defaults.
+func (sm *SessionManager) SetPersona(key, name string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+	session.Persona = name
+	session.Updated = time.Now()
+}
+
+// GetSummarization returns the summarization strategy a session has
+// switched to, or "".
+func (sm *SessionManager) GetSummarization(key string) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, ok := sm.sessions[key]; ok {
+		return session.Summarization
+	}
+	return ""
+}
+
+// SetSummarization switches a session to the named summarization strategy,
+// creating the session if needed. The empty name switches back to the
+// agent's.
+func (sm *SessionManager) SetSummarization(key, name string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+	session.Summarization = name
+	session.Updated = time.Now()
+}
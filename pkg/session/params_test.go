@@ -0,0 +1,108 @@
+package session
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestGenerationParams_Set(t *testing.T) {
+	var p GenerationParams
+	for key, value := range map[string]string{
+		"temperature": "0.2",
+		"top_p":       "0.9",
+		"max_tokens":  "512",
+		"stop":        "###, END ,",
+	} {
+		if err := p.Set(key, value); err != nil {
+			t.Fatalf("Set(%s, %s) error = %v", key, value, err)
+		}
+	}
+	if got := p.String(); got != "temperature=0.2 top_p=0.9 max_tokens=512 stop=###,END" {
+		t.Errorf("String() = %q", got)
+	}
+
+	if err := p.Set("temperature", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if p.Temperature != nil {
+		t.Errorf("Temperature = %v, want nil after default", *p.Temperature)
+	}
+
+	for _, tc := range [][2]string{
+		{"temperature", "hot"},
+		{"temperature", "3"},
+		{"top_p", "0"},
+		{"max_tokens", "-1"},
+		{"stop", " , "},
+		{"seed", "42"},
+	} {
+		if err := p.Set(tc[0], tc[1]); err == nil {
+			t.Errorf("Set(%s, %q) expected error", tc[0], tc[1])
+		}
+	}
+}
+
+func TestGenerationParams_PersistAcrossReload(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	key := "telegram:42"
+
+	temp := 0.1
+	sm.SetGenerationParams(key, GenerationParams{Temperature: &temp, Stop: []string{"END"}})
+	got := sm.GetGenerationParams(key)
+	got.Stop[0] = "mutated"
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded := NewSessionManager(dir).GetGenerationParams(key)
+	if reloaded.Temperature == nil || *reloaded.Temperature != 0.1 {
+		t.Errorf("Temperature = %v, want 0.1", reloaded.Temperature)
+	}
+	if !slices.Equal(reloaded.Stop, []string{"END"}) {
+		t.Errorf("Stop = %v, want [END]", reloaded.Stop)
+	}
+
+	sm.SetGenerationParams(key, GenerationParams{})
+	if !sm.GetGenerationParams(key).IsZero() {
+		t.Error("zero params should clear overrides")
+	}
+}
+
+func TestPersona_PersistAcrossReload(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	key := "slack:C42"
+
+	sm.SetPersona(key, "ops")
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := NewSessionManager(dir).GetPersona(key); got != "ops" {
+		t.Errorf("GetPersona() after reload = %q, want ops", got)
+	}
+
+	sm.SetPersona(key, "")
+	if got := sm.GetPersona(key); got != "" {
+		t.Errorf("GetPersona() after reset = %q", got)
+	}
+}
+
+func TestSummarization_PersistAcrossReload(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	key := "telegram:42"
+
+	sm.SetSummarization(key, "bullets")
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := NewSessionManager(dir).GetSummarization(key); got != "bullets" {
+		t.Errorf("GetSummarization() after reload = %q, want bullets", got)
+	}
+
+	sm.SetSummarization(key, "")
+	if got := sm.GetSummarization(key); got != "" {
+		t.Errorf("GetSummarization() after reset = %q", got)
+	}
+}
@@ -0,0 +1,79 @@
+package session
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// GetScratchpad returns the named scratchpad buffer of a session and
+// reports whether it exists.
+func (sm *SessionManager) GetScratchpad(key, name string) (string, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return "", false
+	}
+	content, ok := session.Scratchpad[name]
+	return content, ok
+}
+
+// SetScratchpad stores content in the named scratchpad buffer of a session,
+// creating the session if needed.
+func (sm *SessionManager) SetScratchpad(key, name, content string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+	if session.Scratchpad == nil {
+		session.Scratchpad = make(map[string]string)
+	}
+	session.Scratchpad[name] = content
+	session.Updated = time.Now()
+}
+
+// DeleteScratchpad removes the named scratchpad buffer of a session and
+// reports whether it existed.
+func (sm *SessionManager) DeleteScratchpad(key, name string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return false
+	}
+	if _, ok := session.Scratchpad[name]; !ok {
+		return false
+	}
+	delete(session.Scratchpad, name)
+	if len(session.Scratchpad) == 0 {
+		session.Scratchpad = nil
+	}
+	session.Updated = time.Now()
+	return true
+}
+
+// ScratchpadNames returns the names of a session's scratchpad buffers in
+// sorted order.
+func (sm *SessionManager) ScratchpadNames(key string) []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(session.Scratchpad))
+}
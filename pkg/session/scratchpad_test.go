@@ -0,0 +1,42 @@
+package session
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScratchpad_PersistAcrossReload(t *testing.T) {
+	dir := t.TempDir()
+	sm := NewSessionManager(dir)
+	key := "telegram:42"
+
+	sm.SetScratchpad(key, "notes", "first")
+	sm.SetScratchpad(key, "draft", "second")
+	if err := sm.Save(key); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded := NewSessionManager(dir)
+	if got := reloaded.ScratchpadNames(key); !slices.Equal(got, []string{"draft", "notes"}) {
+		t.Errorf("ScratchpadNames() = %v, want [draft notes]", got)
+	}
+	if got, ok := reloaded.GetScratchpad(key, "notes"); !ok || got != "first" {
+		t.Errorf("GetScratchpad(notes) = %q, %v", got, ok)
+	}
+
+	snapshot, _ := reloaded.Snapshot(key)
+	snapshot.Scratchpad["notes"] = "mutated"
+	if got, _ := reloaded.GetScratchpad(key, "notes"); got != "first" {
+		t.Errorf("Snapshot shares the scratchpad map: notes = %q", got)
+	}
+
+	if !reloaded.DeleteScratchpad(key, "notes") {
+		t.Error("DeleteScratchpad(notes) = false, want true")
+	}
+	if reloaded.DeleteScratchpad(key, "notes") {
+		t.Error("DeleteScratchpad of a missing buffer = true, want false")
+	}
+	if _, ok := reloaded.GetScratchpad(key, "notes"); ok {
+		t.Error("deleted buffer still readable")
+	}
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// MessageMatch is a message found by SearchMessages, with its relevance
+// score (higher is better).
+type MessageMatch struct {
+	SessionKey string
+	Updated    time.Time // when the session was last active
+	Role       providers.Role
+	Content    string
+	Score      float64
+}
+
+// SetPerson records who a direct conversation is with, creating the session
+// if needed.
+func (sm *SessionManager) SetPerson(key, person string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok {
+		session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+		}
+		sm.sessions[key] = session
+	}
+	session.Person = person
+}
+
+// SearchMessages scores the user and assistant messages of the sessions keep
+// accepts and returns up to limit of them with a positive score, best first.
+// The session passed to keep must not be modified or retained.
+func (sm *SessionManager) SearchMessages(
+	keep func(s *Session) bool,
+	score func(content string) float64,
+	limit int,
+) []MessageMatch {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var matches []MessageMatch
+	for _, s := range sm.sessions {
+		if !keep(s) {
+			continue
+		}
+		for _, m := range s.Messages {
+			if (m.Role != "user" && m.Role != "assistant") || m.Content == "" {
+				continue
+			}
+			if sc := score(m.Content); sc > 0 {
+				matches = append(matches, MessageMatch{
+					SessionKey: s.Key,
+					Updated:    s.Updated,
+					Role:       m.Role,
+					Content:    m.Content,
+					Score:      sc,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Updated.After(matches[j].Updated)
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
@@ -0,0 +1,40 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchMessages(t *testing.T) {
+	sm := NewSessionManager(t.TempDir())
+	sm.AddMessage("telegram:1", "user", "the backup runs nightly at 3am")
+	sm.AddMessage("telegram:1", "tool", "backup log: ok")
+	sm.AddMessage("telegram:1", "assistant", "Noted, nightly backup at 3am.")
+	sm.AddMessage("telegram:2", "user", "what is the backup schedule?")
+	sm.AddMessage("slack:C1", "user", "backup of the team drive")
+	sm.SetPerson("telegram:1", "alice")
+
+	score := func(content string) float64 {
+		if strings.Contains(content, "nightly") {
+			return 1
+		}
+		if strings.Contains(content, "backup") {
+			return 0.5
+		}
+		return 0
+	}
+	got := sm.SearchMessages(func(s *Session) bool { return s.Key != "telegram:2" }, score, 2)
+	if len(got) != 2 || got[0].Score != 1 || got[1].Score != 1 {
+		t.Fatalf("SearchMessages() = %+v", got)
+	}
+	for _, m := range got {
+		if m.SessionKey != "telegram:1" || m.Role == "tool" {
+			t.Errorf("unexpected match %+v", m)
+		}
+	}
+
+	alice := sm.SearchMessages(func(s *Session) bool { return s.Person == "alice" }, score, 0)
+	if len(alice) != 2 {
+		t.Errorf("got %d matches from alice's sessions, want 2", len(alice))
+	}
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Store is where a SessionManager keeps its sessions between runs. The
+// manager holds every session in memory and calls Save with a copy after
+// each change it is asked to persist, so a Store only has to read them
+// all back at start and write, or remove, one at a time.
+type Store interface {
+	// Load returns all stored sessions.
+	Load() ([]Session, error)
+	// Save stores s under s.Key, replacing what was there.
+	Save(s Session) error
+	// Delete removes the session with the given key; a missing one is not
+	// an error.
+	Delete(key string) error
+}
+
+// DirStore keeps each session as a JSON file in a directory. It is the
+// store NewSessionManager uses.
+type DirStore struct {
+	dir   string
+	space diskSpace
+}
+
+// NewDirStore creates a store in dir, creating the directory if needed.
+func NewDirStore(dir string) *DirStore {
+	os.MkdirAll(dir, 0o755)
+	return &DirStore{
+		dir:   dir,
+		space: diskSpace{dir: dir, sessionExt: ".json", checkpointExt: checkpointsExt},
+	}
+}
+
+func (d *DirStore) Load() ([]Session, error) {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []Session
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		if filepath.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		sessionPath := filepath.Join(d.dir, file.Name())
+		data, err := os.ReadFile(sessionPath)
+		if err != nil {
+			continue
+		}
+
+		var session Session
+		if err := json.Unmarshal(data, &session); err != nil {
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func (d *DirStore) Save(s Session) error {
+	filename := sanitizeFilename(s.Key)
+	if !isLocalFilename(filename) {
+		return os.ErrInvalid
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	sessionPath := filepath.Join(d.dir, filename+".json")
+	tmpFile, err := os.CreateTemp(d.dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmpFile.Name()
+	cleanup := true
+	defer func() {
+		if cleanup {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(0o644); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	before := fileSize(sessionPath)
+	if err := os.Rename(tmpPath, sessionPath); err != nil {
+		return err
+	}
+	cleanup = false
+	d.space.replaced(before, len(data))
+	return nil
+}
+
+func (d *DirStore) Delete(key string) error {
+	filename := sanitizeFilename(key)
+	if !isLocalFilename(filename) {
+		return nil
+	}
+
+	for _, ext := range []string{".json", checkpointsExt} {
+		err := os.Remove(filepath.Join(d.dir, filename+ext))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
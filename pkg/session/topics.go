@@ -0,0 +1,136 @@
+package session
+
+import (
+	"slices"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Topic is a stretch of a session about one subject: the messages from
+// Start up to the Start of the next topic.
+type Topic struct {
+	Title   string    `json:"title"`
+	Start   int       `json:"start"`
+	Created time.Time `json:"created"`
+
+	// End is one past the topic's last message. It is set by ListTopics.
+	End int `json:"-"`
+}
+
+// ListTopics returns the topics of a session in order, with their ends.
+func (sm *SessionManager) ListTopics(key string) []Topic {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || len(session.Topics) == 0 {
+		return nil
+	}
+	topics := slices.Clone(session.Topics)
+	for i := range topics {
+		topics[i].End = len(session.Messages)
+		if i+1 < len(topics) {
+			topics[i].End = topics[i+1].Start
+		}
+	}
+	return topics
+}
+
+// AddTopic starts a topic titled title at message start, which must come
+// after the start of the last topic. It reports whether the topic was
+// added.
+func (sm *SessionManager) AddTopic(key string, start int, title string) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || start < 0 || start >= len(session.Messages) {
+		return false
+	}
+	if n := len(session.Topics); n > 0 && start <= session.Topics[n-1].Start {
+		return false
+	}
+	session.Topics = append(session.Topics, Topic{Title: title, Start: start, Created: time.Now()})
+	session.Updated = time.Now()
+	return true
+}
+
+// TopicMessages returns the messages of the i-th topic of a session.
+func (sm *SessionManager) TopicMessages(key string, i int) ([]providers.Message, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	session, ok := sm.sessions[key]
+	if !ok || i < 0 || i >= len(session.Topics) {
+		return nil, false
+	}
+	end := len(session.Messages)
+	if i+1 < len(session.Topics) {
+		end = session.Topics[i+1].Start
+	}
+	return slices.Clone(session.Messages[session.Topics[i].Start:end]), true
+}
+
+// moveTopics returns topics with their starts moved to where find puts
+// them in the changed history of n messages, -1 meaning the message is
+// gone. Topics whose first message is gone are dropped, except that the
+// last of those before the first topic kept now starts at message 0.
+func moveTopics(topics []Topic, n int, find func(start int) int) []Topic {
+	var out []Topic
+	var cut *Topic
+	for _, t := range topics {
+		i := find(t.Start)
+		if i < 0 {
+			if len(out) == 0 {
+				cut = &t
+			}
+			continue
+		}
+		if len(out) > 0 && i <= out[len(out)-1].Start {
+			continue
+		}
+		if cut != nil && i > 0 {
+			cut.Start = 0
+			out = append(out, *cut)
+		}
+		cut = nil
+		t.Start = i
+		out = append(out, t)
+	}
+	if len(out) == 0 && cut != nil && n > 0 {
+		cut.Start = 0
+		out = append(out, *cut)
+	}
+	return out
+}
+
+// dropTopicMessages moves the topics of s after the first dropped messages
+// of its history were removed.
+func dropTopicMessages(s *Session, dropped int) {
+	s.Topics = moveTopics(s.Topics, len(s.Messages), func(start int) int {
+		if start < dropped {
+			return -1
+		}
+		return start - dropped
+	})
+}
+
+// replaceTopicMessages moves the topics of s from the old history to the
+// one in s.Messages, finding the first message of each topic by role and
+// content.
+func replaceTopicMessages(s *Session, old []providers.Message) {
+	next := 0
+	s.Topics = moveTopics(s.Topics, len(s.Messages), func(start int) int {
+		if start >= len(old) {
+			return -1
+		}
+		for i := next; i < len(s.Messages); i++ {
+			if s.Messages[i].Role == old[start].Role && s.Messages[i].Content == old[start].Content {
+				next = i + 1
+				return i
+			}
+		}
+		return -1
+	})
+}
@@ -0,0 +1,94 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// topicSession returns a manager with a session of ten messages, "m0" to
+// "m9", in topics starting at 0, 4 and 7.
+func topicSession(t *testing.T, dir string) (*SessionManager, string) {
+	t.Helper()
+	sm := NewSessionManager(dir)
+	key := "telegram:42"
+	for i := range 10 {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		sm.AddMessage(key, role, fmt.Sprintf("m%d", i))
+	}
+	for _, topic := range []struct {
+		start int
+		title string
+	}{{0, "Tulips"}, {4, "Taxes"}, {7, "Travel"}} {
+		if !sm.AddTopic(key, topic.start, topic.title) {
+			t.Fatalf("AddTopic(%d, %q) = false", topic.start, topic.title)
+		}
+	}
+	return sm, key
+}
+
+func topicStarts(topics []Topic) string {
+	s := ""
+	for _, t := range topics {
+		s += fmt.Sprintf("%s@%d-%d ", t.Title, t.Start, t.End)
+	}
+	return s
+}
+
+func TestTopics_ListAndMessages(t *testing.T) {
+	dir := t.TempDir()
+	sm, key := topicSession(t, dir)
+
+	if got := topicStarts(sm.ListTopics(key)); got != "Tulips@0-4 Taxes@4-7 Travel@7-10 " {
+		t.Errorf("ListTopics() = %s", got)
+	}
+	if sm.AddTopic(key, 5, "Before the last") || sm.AddTopic(key, 10, "Past the end") {
+		t.Error("AddTopic accepted a start that is not after the last topic and in the history")
+	}
+	msgs, ok := sm.TopicMessages(key, 1)
+	if !ok || len(msgs) != 3 || msgs[0].Content != "m4" || msgs[2].Content != "m6" {
+		t.Errorf("TopicMessages(1) = %v, %v", msgs, ok)
+	}
+	if _, ok := sm.TopicMessages(key, 3); ok {
+		t.Error("TopicMessages of a missing topic = true")
+	}
+
+	if err := sm.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	if got := topicStarts(NewSessionManager(dir).ListTopics(key)); got != "Tulips@0-4 Taxes@4-7 Travel@7-10 " {
+		t.Errorf("ListTopics() after reload = %s", got)
+	}
+}
+
+func TestTopics_TruncateHistory(t *testing.T) {
+	sm, key := topicSession(t, "")
+	sm.TruncateHistory(key, 5)
+	// m5 and m6 are left of Taxes, which starts at 0 now.
+	if got := topicStarts(sm.ListTopics(key)); got != "Taxes@0-2 Travel@2-5 " {
+		t.Errorf("after keeping 5 = %s", got)
+	}
+	sm.TruncateHistory(key, 3)
+	if got := topicStarts(sm.ListTopics(key)); got != "Travel@0-3 " {
+		t.Errorf("after keeping 3 = %s", got)
+	}
+	sm.TruncateHistory(key, 0)
+	if got := sm.ListTopics(key); got != nil {
+		t.Errorf("after clearing = %v", got)
+	}
+}
+
+func TestTopics_SetHistory(t *testing.T) {
+	sm, key := topicSession(t, "")
+	history := sm.GetHistory(key)
+	// Keep m0 and m6 to m9: Taxes lost its first message, and the m6 left
+	// of it goes with Tulips.
+	sm.SetHistory(key, append([]providers.Message{history[0]}, history[6:]...))
+	if got := topicStarts(sm.ListTopics(key)); got != "Tulips@0-2 Travel@2-5 " {
+		t.Errorf("ListTopics() = %s", got)
+	}
+}
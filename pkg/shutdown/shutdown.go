@@ -0,0 +1,96 @@
+// Package shutdown stops the parts of a long-running process in order when
+// it is asked to exit.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+type step struct {
+	name    string
+	timeout time.Duration // 0 for no limit
+	run     func(ctx context.Context) error
+}
+
+// Coordinator runs the shutdown steps in the order they were added.
+type Coordinator struct {
+	steps []step
+}
+
+// New creates a Coordinator without steps.
+func New() *Coordinator {
+	return &Coordinator{}
+}
+
+// Add appends a step that runs fn with a context that is done after
+// timeout.
+func (c *Coordinator) Add(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	c.steps = append(c.steps, step{name: name, timeout: timeout, run: fn})
+}
+
+// AddFunc appends a step that cannot fail or time out, such as stopping a
+// ticker.
+func (c *Coordinator) AddFunc(name string, fn func()) {
+	c.Add(name, 0, func(context.Context) error {
+		fn()
+		return nil
+	})
+}
+
+// MaxDuration returns how long the steps may take at most, not counting
+// the steps without a limit.
+func (c *Coordinator) MaxDuration() time.Duration {
+	var d time.Duration
+	for _, s := range c.steps {
+		d += s.timeout
+	}
+	return d
+}
+
+// Wait blocks until the process receives SIGINT or SIGTERM and returns the
+// signal. A second signal after that exits the process at once, for when a
+// step hangs.
+func Wait() os.Signal {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	sig := <-sigs
+	go func() {
+		<-sigs
+		logger.WarnC("shutdown", "Second signal received, exiting without finishing the shutdown")
+		os.Exit(1)
+	}()
+	return sig
+}
+
+// Run runs every step in order, also after one fails or times out, and
+// returns their errors.
+func (c *Coordinator) Run() error {
+	var errs []error
+	for _, s := range c.steps {
+		start := time.Now()
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if s.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		}
+		err := s.run(ctx)
+		cancel()
+
+		fields := map[string]any{"step": s.name, "duration_ms": time.Since(start).Milliseconds()}
+		if err != nil {
+			fields["error"] = err.Error()
+			logger.WarnCF("shutdown", "Shutdown step failed", fields)
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		logger.DebugCF("shutdown", "Shutdown step done", fields)
+	}
+	return errors.Join(errs...)
+}
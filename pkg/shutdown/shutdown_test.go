@@ -0,0 +1,38 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoordinator_RunsStepsInOrder(t *testing.T) {
+	var order []string
+	c := New()
+	c.AddFunc("channels", func() { order = append(order, "channels") })
+	c.Add("turns", 10*time.Millisecond, func(ctx context.Context) error {
+		order = append(order, "turns")
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.Add("providers", 0, func(ctx context.Context) error {
+		order = append(order, "providers")
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("step without a timeout got a deadline")
+		}
+		return nil
+	})
+
+	err := c.Run()
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "turns") {
+		t.Errorf("Run = %v, want the timeout of the turns step", err)
+	}
+	if got := strings.Join(order, ","); got != "channels,turns,providers" {
+		t.Errorf("order = %s", got)
+	}
+	if d := c.MaxDuration(); d != 10*time.Millisecond {
+		t.Errorf("MaxDuration = %v, want 10ms", d)
+	}
+}
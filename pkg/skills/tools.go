@@ -0,0 +1,137 @@
+package skills
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ToolsFile is the manifest, next to SKILL.md, of the tools a skill
+// provides.
+const ToolsFile = "tools.json"
+
+var toolNamePattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// ToolSpec is a tool a skill provides: a program that gets the arguments of
+// a call as a JSON object on stdin and answers on stdout. The program is
+// either a command or a WebAssembly module run in a sandbox, which can only
+// use the capabilities it lists.
+type ToolSpec struct {
+	Name           string         `json:"name"`
+	Description    string         `json:"description"`
+	Command        []string       `json:"command,omitempty"`      // run in the skill's directory
+	Wasm           string         `json:"wasm,omitempty"`         // module file in the skill's directory
+	Capabilities   []string       `json:"capabilities,omitempty"` // of the module
+	Parameters     map[string]any `json:"parameters,omitempty"`   // JSON schema of the arguments
+	TimeoutSeconds int            `json:"timeout_seconds,omitempty"`
+
+	Skill string `json:"-"`
+	Dir   string `json:"-"` // the skill's directory
+}
+
+// FullName is the name the tool is registered under: skill_<skill>_<name>,
+// so that a skill cannot shadow a built-in tool or another skill's.
+func (t ToolSpec) FullName() string {
+	return "skill_" + strings.ReplaceAll(strings.ToLower(t.Skill), "-", "_") + "_" + t.Name
+}
+
+func (t ToolSpec) validate() error {
+	var errs error
+	if !toolNamePattern.MatchString(t.Name) {
+		errs = errors.Join(errs, fmt.Errorf("name %q must be lowercase letters and digits with underscores", t.Name))
+	} else if len(t.FullName()) > MaxNameLength {
+		errs = errors.Join(errs, fmt.Errorf("%s exceeds %d characters", t.FullName(), MaxNameLength))
+	}
+	if t.Description == "" {
+		errs = errors.Join(errs, errors.New("description is required"))
+	}
+	switch {
+	case t.Wasm != "" && len(t.Command) > 0:
+		errs = errors.Join(errs, errors.New("command and wasm are mutually exclusive"))
+	case t.Wasm != "":
+		if !filepath.IsLocal(t.Wasm) {
+			errs = errors.Join(errs, fmt.Errorf("wasm %q must be a path inside the skill", t.Wasm))
+		}
+	case len(t.Command) == 0 || t.Command[0] == "":
+		errs = errors.Join(errs, errors.New("command or wasm is required"))
+	}
+	if len(t.Capabilities) > 0 && t.Wasm == "" {
+		errs = errors.Join(errs, errors.New("capabilities only apply to wasm tools"))
+	}
+	if t.TimeoutSeconds < 0 {
+		errs = errors.Join(errs, errors.New("timeout_seconds must not be negative"))
+	}
+	return errs
+}
+
+// ListTools returns the tools of the skills ListSkills finds, in the same
+// order. A manifest that does not parse and a tool that does not validate
+// are logged and left out, so one broken skill does not take the others
+// down.
+func (sl *SkillsLoader) ListTools() []ToolSpec {
+	var specs []ToolSpec
+	for _, skill := range sl.ListSkills() {
+		dir := filepath.Dir(skill.Path)
+		data, err := os.ReadFile(filepath.Join(dir, ToolsFile))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		var manifest struct {
+			Tools []ToolSpec `json:"tools"`
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &manifest)
+		}
+		if err != nil {
+			logger.WarnCF("skills", "Skipping the tools of a skill",
+				map[string]any{"skill": skill.Name, "error": err.Error()})
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, t := range manifest.Tools {
+			t.Skill, t.Dir = skill.Name, dir
+			if err := t.validate(); err != nil {
+				logger.WarnCF("skills", "Skipping invalid skill tool",
+					map[string]any{"skill": skill.Name, "tool": t.Name, "error": err.Error()})
+				continue
+			}
+			if seen[t.Name] {
+				continue
+			}
+			seen[t.Name] = true
+			specs = append(specs, t)
+		}
+	}
+	return specs
+}
+
+// ToolsStamp returns a value that changes whenever a skill or a tool
+// manifest is added, removed or modified, so callers can tell when to call
+// ListTools again without parsing anything.
+func (sl *SkillsLoader) ToolsStamp() string {
+	var b strings.Builder
+	for _, root := range sl.SkillRoots() {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			for _, name := range []string{"SKILL.md", ToolsFile} {
+				if info, err := os.Stat(filepath.Join(root, entry.Name(), name)); err == nil {
+					fmt.Fprintf(&b, "%s/%s/%s:%d:%d\n",
+						root, entry.Name(), name, info.Size(), info.ModTime().UnixNano())
+				}
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package skills
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSkill(t *testing.T, root, name, tools string) {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	skill := "---\nname: " + name + "\ndescription: Garden helpers\n---\n# " + name + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(skill), 0o644))
+	if tools != "" {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ToolsFile), []byte(tools), 0o644))
+	}
+}
+
+func TestListTools(t *testing.T) {
+	workspace := t.TempDir()
+	root := filepath.Join(workspace, "skills")
+	writeSkill(t, root, "garden", `{"tools": [
+		{"name": "soil_moisture", "description": "Read a pot's moisture", "command": ["./moisture.sh"],
+		 "parameters": {"type": "object", "properties": {"pot": {"type": "string"}}}, "timeout_seconds": 5},
+		{"name": "Bad-Name", "description": "x", "command": ["true"]},
+		{"name": "no_command", "description": "x"},
+		{"name": "forecast", "description": "Get the forecast", "wasm": "forecast.wasm", "capabilities": ["fetch"]},
+		{"name": "both", "description": "x", "command": ["true"], "wasm": "both.wasm"},
+		{"name": "escape", "description": "x", "wasm": "../other/tool.wasm"},
+		{"name": "caps", "description": "x", "command": ["true"], "capabilities": ["fetch"]}
+	]}`)
+	writeSkill(t, root, "notes-only", "")
+	writeSkill(t, root, "broken", `{"tools": [`)
+
+	specs := NewSkillsLoader(workspace, "", "").ListTools()
+
+	require.Len(t, specs, 2)
+	assert.Equal(t, "skill_garden_soil_moisture", specs[0].FullName())
+	assert.Equal(t, filepath.Join(root, "garden"), specs[0].Dir)
+	assert.Equal(t, 5, specs[0].TimeoutSeconds)
+	assert.Equal(t, "forecast.wasm", specs[1].Wasm)
+	assert.Equal(t, []string{"fetch"}, specs[1].Capabilities)
+}
+
+func TestToolSpec_FullName(t *testing.T) {
+	assert.Equal(t, "skill_home_assistant_lights", ToolSpec{Skill: "Home-Assistant", Name: "lights"}.FullName())
+}
+
+func TestToolsStamp(t *testing.T) {
+	workspace := t.TempDir()
+	root := filepath.Join(workspace, "skills")
+	sl := NewSkillsLoader(workspace, "", "")
+	empty := sl.ToolsStamp()
+
+	writeSkill(t, root, "garden", `{"tools": []}`)
+	added := sl.ToolsStamp()
+	assert.NotEqual(t, empty, added)
+	assert.Equal(t, added, sl.ToolsStamp())
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "garden", ToolsFile), []byte(`{"tools": [{}]}`), 0o644))
+	assert.NotEqual(t, added, sl.ToolsStamp())
+}
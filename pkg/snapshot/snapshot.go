@@ -0,0 +1,475 @@
+// Package snapshot writes the state of an instance into one zip file and
+// restores it, to move picoclaw to another device or to recover it after
+// losing one. A snapshot holds the config file, the stored credentials, the
+// secrets written by picoclaw secret set, the workspace with its memory,
+// sessions and notes, and the log files when they live outside the
+// workspace.
+//
+// Files are read as they are on disk, which the stores only ever replace
+// whole, so a snapshot can be taken while the gateway runs. SQLite
+// databases, such as the WhatsApp store, are copied with VACUUM INTO so
+// that their copy is consistent too.
+package snapshot
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Format is the version of the snapshot layout this package writes and
+// reads.
+const Format = 1
+
+const manifestFile = "manifest.json"
+
+// Parts of a snapshot, which prefix the names of its files.
+const (
+	PartConfig    = "config.json"
+	PartAuth      = "auth.json"
+	PartSecrets   = "secrets"
+	PartWorkspace = "workspace"
+	PartLogs      = "logs"
+)
+
+// Layout says where the state of an instance lives. Empty paths are left
+// out of a snapshot, and out of a restore.
+type Layout struct {
+	Config    string // the config file
+	Auth      string // the credentials of picoclaw auth login
+	Secrets   string // the directory of picoclaw secret set --file
+	Workspace string
+	Logs      string // left out when inside Workspace
+}
+
+// parts returns the paths of l by part, and whether each is a directory.
+func (l Layout) parts() []part {
+	parts := []part{
+		{PartConfig, clean(l.Config), false},
+		{PartAuth, clean(l.Auth), false},
+		{PartSecrets, clean(l.Secrets), true},
+		{PartWorkspace, clean(l.Workspace), true},
+	}
+	if l.Logs != "" && !within(l.Logs, l.Workspace) {
+		parts = append(parts, part{PartLogs, clean(l.Logs), true})
+	}
+	return parts
+}
+
+func clean(p string) string {
+	if p == "" {
+		return ""
+	}
+	return filepath.Clean(p)
+}
+
+type part struct {
+	name string
+	path string
+	dir  bool
+}
+
+// within reports whether path is dir or inside it.
+func within(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Manifest describes a snapshot. It is stored in the snapshot as
+// manifest.json.
+type Manifest struct {
+	Format  int       `json:"format"`
+	Created time.Time `json:"created"`
+	Version string    `json:"version"` // of the picoclaw that wrote it
+	Host    string    `json:"host"`
+	Files   []File    `json:"files"`
+}
+
+// File is a file of a snapshot. Name starts with the part it belongs to,
+// e.g. workspace/memory/facts.jsonl.
+type File struct {
+	Name   string      `json:"name"`
+	Size   int64       `json:"size"`
+	Mode   fs.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+// Size returns the total size of the files.
+func (m *Manifest) Size() int64 {
+	var n int64
+	for _, f := range m.Files {
+		n += f.Size
+	}
+	return n
+}
+
+// Has reports whether the snapshot holds any file of part.
+func (m *Manifest) Has(part string) bool {
+	for _, f := range m.Files {
+		if f.Name == part || strings.HasPrefix(f.Name, part+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes a snapshot of the state in l to w. version is recorded in
+// the manifest.
+func Write(w io.Writer, l Layout, version string) (*Manifest, error) {
+	host, _ := os.Hostname()
+	m := &Manifest{Format: Format, Created: time.Now(), Version: version, Host: host}
+	zw := zip.NewWriter(w)
+	for _, p := range l.parts() {
+		if p.path == "" {
+			continue
+		}
+		var err error
+		if p.dir {
+			err = addDir(zw, m, p.name, p.path)
+		} else {
+			err = addFile(zw, m, p.name, p.path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: %s: %w", p.name, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: manifestFile, Method: zip.Deflate, Modified: m.Created})
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: add manifest: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return nil, fmt.Errorf("snapshot: add manifest: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("snapshot: finish: %w", err)
+	}
+	return m, nil
+}
+
+// addDir adds the regular files under dir; a missing dir adds nothing.
+// Symlinks are not followed.
+func addDir(zw *zip.Writer, m *Manifest, name, dir string) error {
+	// The WAL and journal of a database are part of its VACUUM INTO copy.
+	copied := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == dir {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+			if db, ok := strings.CutSuffix(p, suffix); ok && (copied[db] || isSQLite(db)) {
+				return nil
+			}
+		}
+		if isSQLite(p) {
+			copied[p] = true
+			return addSQLite(zw, m, name+"/"+filepath.ToSlash(rel), p)
+		}
+		return addFile(zw, m, name+"/"+filepath.ToSlash(rel), p)
+	})
+	return err
+}
+
+// addFile adds the file at p as name; a missing file adds nothing.
+func addFile(zw *zip.Writer, m *Manifest, name, p string) error {
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	return addReader(zw, m, name, info, f)
+}
+
+func addReader(zw *zip.Writer, m *Manifest, name string, info fs.FileInfo, r io.Reader) error {
+	hdr := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: info.ModTime()}
+	hdr.SetMode(info.Mode().Perm())
+	fw, err := zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(fw, sum), r)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	m.Files = append(m.Files, File{
+		Name:   name,
+		Size:   n,
+		Mode:   info.Mode().Perm(),
+		SHA256: hex.EncodeToString(sum.Sum(nil)),
+	})
+	return nil
+}
+
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+func isSQLite(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(sqliteMagic))
+	_, err = io.ReadFull(f, head)
+	return err == nil && bytes.Equal(head, sqliteMagic)
+}
+
+// addSQLite adds a consistent copy of the database at p, even while another
+// process writes to it.
+func addSQLite(zw *zip.Writer, m *Manifest, name, p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp("", "picoclaw-snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	db, err := sql.Open("sqlite", "file:"+p+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	copyPath := filepath.Join(tmp, "copy.db")
+	if _, err := db.Exec("VACUUM INTO ?", copyPath); err != nil {
+		return fmt.Errorf("%s: back up database: %w", name, err)
+	}
+	f, err := os.Open(copyPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return addReader(zw, m, name, info, f)
+}
+
+// Snapshot is an open snapshot file.
+type Snapshot struct {
+	Manifest
+
+	zr *zip.ReadCloser
+}
+
+// Open opens the snapshot at path and reads its manifest.
+func Open(path string) (*Snapshot, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	s := &Snapshot{zr: zr}
+	data, err := s.readManifest()
+	if err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("snapshot: %s is not a picoclaw snapshot: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &s.Manifest); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("snapshot: read manifest: %w", err)
+	}
+	if s.Format != Format {
+		zr.Close()
+		return nil, fmt.Errorf("snapshot: format %d is not supported; this picoclaw reads format %d", s.Format, Format)
+	}
+	for _, f := range s.Files {
+		if err := checkName(f.Name); err != nil {
+			zr.Close()
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Close closes the snapshot file.
+func (s *Snapshot) Close() error {
+	return s.zr.Close()
+}
+
+// checkName rejects the names that would be restored outside their part.
+func checkName(name string) error {
+	part, _, _ := strings.Cut(name, "/")
+	if name != path.Clean(name) || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return fmt.Errorf("unsafe file name %q", name)
+	}
+	switch part {
+	case PartConfig, PartAuth:
+		if name != part {
+			return fmt.Errorf("unexpected file %q", name)
+		}
+	case PartSecrets, PartWorkspace, PartLogs:
+		if name == part || slices.Contains(strings.Split(name, "/"), "..") {
+			return fmt.Errorf("unsafe file name %q", name)
+		}
+	default:
+		return fmt.Errorf("unexpected file %q", name)
+	}
+	return nil
+}
+
+// ReadFile returns the contents of a file of the snapshot, such as
+// PartConfig, after checking it against the manifest.
+func (s *Snapshot) ReadFile(name string) ([]byte, error) {
+	for _, f := range s.Files {
+		if f.Name == name {
+			var buf bytes.Buffer
+			if err := s.copy(&buf, f); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("snapshot: no file %s: %w", name, fs.ErrNotExist)
+}
+
+func (s *Snapshot) readManifest() ([]byte, error) {
+	const maxManifestSize = 16 << 20
+	rc, err := s.zr.Open(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxManifestSize))
+}
+
+// copy writes the file f to w, failing when it does not match the manifest.
+func (s *Snapshot) copy(w io.Writer, f File) error {
+	rc, err := s.zr.Open(f.Name)
+	if err != nil {
+		return fmt.Errorf("snapshot: %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, sum), io.LimitReader(rc, f.Size+1))
+	if err != nil {
+		return fmt.Errorf("snapshot: %s: %w", f.Name, err)
+	}
+	if n != f.Size || hex.EncodeToString(sum.Sum(nil)) != f.SHA256 {
+		return fmt.Errorf("snapshot: %s is damaged: it does not match the manifest", f.Name)
+	}
+	return nil
+}
+
+// Restore puts the files of the snapshot in place of the state in l. Parts
+// the snapshot holds but l leaves empty are not restored.
+//
+// Every file is extracted and checked before any is moved into place, so a
+// damaged snapshot changes nothing. State that is replaced is kept beside
+// it with the suffix .before-restore-<time>; Restore returns those paths.
+func (s *Snapshot) Restore(l Layout) ([]string, error) {
+	suffix := time.Now().Format("20060102-150405")
+	var staged []part
+	cleanup := func() {
+		for _, p := range staged {
+			os.RemoveAll(p.path + ".restoring-" + suffix)
+		}
+	}
+
+	for _, p := range l.parts() {
+		if p.path == "" || !s.Has(p.name) {
+			continue
+		}
+		staged = append(staged, p)
+		if err := s.extract(p, p.path+".restoring-"+suffix); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	var kept []string
+	for _, p := range staged {
+		if _, err := os.Lstat(p.path); err == nil {
+			if err := os.Rename(p.path, p.path+".before-restore-"+suffix); err != nil {
+				cleanup()
+				return kept, fmt.Errorf("snapshot: move %s aside: %w", p.path, err)
+			}
+			kept = append(kept, p.path+".before-restore-"+suffix)
+		}
+		if err := os.Rename(p.path+".restoring-"+suffix, p.path); err != nil {
+			cleanup()
+			return kept, fmt.Errorf("snapshot: restore %s: %w", p.path, err)
+		}
+	}
+	return kept, nil
+}
+
+// extract writes the files of part p to dst, which becomes the file itself
+// for a file part and the directory for the others.
+func (s *Snapshot) extract(p part, dst string) error {
+	for _, f := range s.Files {
+		target := dst
+		if p.dir {
+			rel, ok := strings.CutPrefix(f.Name, p.name+"/")
+			if !ok {
+				continue
+			}
+			target = filepath.Join(dst, filepath.FromSlash(rel))
+		} else if f.Name != p.name {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return fmt.Errorf("snapshot: %w", err)
+		}
+		if err := s.extractFile(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Snapshot) extractFile(f File, target string) error {
+	// Owner read and write are kept so that the restored state stays usable.
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, f.Mode.Perm()|0o600)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := s.copy(out, f); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package snapshot
+
+import (
+	"archive/zip"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layout returns the layout of an instance under home.
+func layout(home string) Layout {
+	return Layout{
+		Config:    filepath.Join(home, "config.json"),
+		Auth:      filepath.Join(home, "auth.json"),
+		Secrets:   filepath.Join(home, "secrets"),
+		Workspace: filepath.Join(home, "workspace"),
+		Logs:      filepath.Join(home, "workspace", "logs"),
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+func snapshotOf(t *testing.T, l Layout) (string, *Manifest) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snap.zip")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	m, err := Write(f, l, "test")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return path, m
+}
+
+func TestWriteRestore(t *testing.T) {
+	src := layout(t.TempDir())
+	writeFile(t, src.Config, `{"agents":{}}`)
+	writeFile(t, src.Auth, `{"credentials":{}}`)
+	writeFile(t, filepath.Join(src.Secrets, "openai"), "sk-1\n")
+	writeFile(t, filepath.Join(src.Workspace, "memory", "facts.jsonl"), `{"id":"a"}`+"\n")
+	writeFile(t, filepath.Join(src.Workspace, "sessions", "telegram_1.json"), `{"key":"telegram:1"}`)
+	writeFile(t, filepath.Join(src.Logs, "picoclaw.log"), "started\n")
+
+	path, m := snapshotOf(t, src)
+	assert.Len(t, m.Files, 6)
+	assert.False(t, m.Has(PartLogs), "logs inside the workspace are part of it")
+
+	dst := layout(t.TempDir())
+	writeFile(t, filepath.Join(dst.Workspace, "AGENT.md"), "fresh install")
+
+	s, err := Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, "test", s.Version)
+	kept, err := s.Restore(dst)
+	require.NoError(t, err)
+
+	for _, name := range []string{"memory/facts.jsonl", "sessions/telegram_1.json", "logs/picoclaw.log"} {
+		want, err := os.ReadFile(filepath.Join(src.Workspace, name))
+		require.NoError(t, err)
+		got, err := os.ReadFile(filepath.Join(dst.Workspace, name))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+	secret, err := os.ReadFile(filepath.Join(dst.Secrets, "openai"))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-1\n", string(secret))
+	assert.FileExists(t, dst.Config)
+
+	// The workspace that was there is kept, not lost.
+	require.Len(t, kept, 1)
+	assert.FileExists(t, filepath.Join(kept[0], "AGENT.md"))
+	assert.NoFileExists(t, filepath.Join(dst.Workspace, "AGENT.md"))
+}
+
+func TestWrite_LogsOutsideWorkspace(t *testing.T) {
+	src := layout(t.TempDir())
+	src.Logs = filepath.Join(t.TempDir(), "logs")
+	writeFile(t, filepath.Join(src.Logs, "picoclaw.log"), "started\n")
+
+	_, m := snapshotOf(t, src)
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, "logs/picoclaw.log", m.Files[0].Name)
+}
+
+func TestWrite_SQLite(t *testing.T) {
+	src := layout(t.TempDir())
+	dbPath := filepath.Join(src.Workspace, "whatsapp", "store.db")
+	require.NoError(t, os.MkdirAll(filepath.Dir(dbPath), 0o700))
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`PRAGMA journal_mode = WAL; CREATE TABLE t (v TEXT); INSERT INTO t VALUES ('kept')`)
+	require.NoError(t, err)
+
+	// The database is open and its latest rows may be only in the WAL.
+	path, m := snapshotOf(t, src)
+	require.Len(t, m.Files, 1)
+	assert.Equal(t, "workspace/whatsapp/store.db", m.Files[0].Name)
+
+	dst := layout(t.TempDir())
+	s, err := Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+	_, err = s.Restore(dst)
+	require.NoError(t, err)
+
+	restored, err := sql.Open("sqlite", filepath.Join(dst.Workspace, "whatsapp", "store.db"))
+	require.NoError(t, err)
+	defer restored.Close()
+	var v string
+	require.NoError(t, restored.QueryRow(`SELECT v FROM t`).Scan(&v))
+	assert.Equal(t, "kept", v)
+}
+
+func TestRestore_Damaged(t *testing.T) {
+	src := layout(t.TempDir())
+	writeFile(t, src.Config, `{"agents":{}}`)
+	writeFile(t, filepath.Join(src.Workspace, "memory", "facts.jsonl"), `{"id":"a"}`+"\n")
+	path, _ := snapshotOf(t, src)
+
+	// Rewrite the archive with one file changed but the manifest as it was.
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	damaged := filepath.Join(t.TempDir(), "damaged.zip")
+	out, err := os.Create(damaged)
+	require.NoError(t, err)
+	zw := zip.NewWriter(out)
+	for _, f := range zr.File {
+		w, err := zw.Create(f.Name)
+		require.NoError(t, err)
+		if f.Name == "workspace/memory/facts.jsonl" {
+			_, err = w.Write([]byte(`{"id":"b"}` + "\n"))
+		} else {
+			var rc io.ReadCloser
+			rc, err = f.Open()
+			require.NoError(t, err)
+			_, err = io.Copy(w, rc)
+			rc.Close()
+		}
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, out.Close())
+	zr.Close()
+
+	dst := layout(t.TempDir())
+	writeFile(t, dst.Config, "mine")
+	s, err := Open(damaged)
+	require.NoError(t, err)
+	defer s.Close()
+	_, err = s.Restore(dst)
+	assert.ErrorContains(t, err, "workspace/memory/facts.jsonl is damaged")
+
+	// Nothing changed, not even the parts extracted before the damage.
+	data, err := os.ReadFile(dst.Config)
+	require.NoError(t, err)
+	assert.Equal(t, "mine", string(data))
+	entries, err := os.ReadDir(filepath.Dir(dst.Config))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestCheckName(t *testing.T) {
+	for _, name := range []string{"config.json", "workspace/memory/facts.jsonl", "secrets/openai"} {
+		assert.NoError(t, checkName(name), name)
+	}
+	for _, name := range []string{
+		"../config.json", "/etc/passwd", "workspace", "workspace/../../x", "config.json/x", "other/file", `secrets\x`,
+	} {
+		assert.Error(t, checkName(name), name)
+	}
+}
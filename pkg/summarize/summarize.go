@@ -0,0 +1,184 @@
+// Package summarize condenses the messages that leave a session's history
+// into the session's summary. How is up to a Strategy: a rolling summary
+// updated in one call, a map-reduce over chunks of the messages, or a
+// bullet list of the points worth keeping.
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// The strategies.
+const (
+	Rolling   = "rolling"
+	MapReduce = "map_reduce"
+	Bullets   = "bullets"
+)
+
+// Names lists the strategies, the default first.
+var Names = []string{MapReduce, Rolling, Bullets}
+
+const (
+	defaultChunkMessages = 10
+	// maxBullets is how many points Bullets keeps; the oldest go first.
+	maxBullets = 40
+)
+
+// Complete sends a prompt to the summarization model and returns its
+// answer.
+type Complete func(ctx context.Context, prompt string) (string, error)
+
+// Strategy turns messages, and the summary of the messages that left the
+// history before them, into a new summary of both.
+type Strategy interface {
+	Summarize(ctx context.Context, complete Complete, previous string, messages []providers.Message) (string, error)
+}
+
+// New returns the strategy called name, or MapReduce for "".
+// chunkMessages is the most messages MapReduce summarizes in one call; 0
+// means 10.
+func New(name string, chunkMessages int) (Strategy, error) {
+	switch name {
+	case "", MapReduce:
+		if chunkMessages <= 0 {
+			chunkMessages = defaultChunkMessages
+		}
+		return mapReduce{chunk: chunkMessages}, nil
+	case Rolling:
+		return rolling{}, nil
+	case Bullets:
+		return bullets{}, nil
+	}
+	return nil, fmt.Errorf("unknown summarization strategy %q (want %s)", name, strings.Join(Names, ", "))
+}
+
+// rolling updates the previous summary with all the messages in one call.
+type rolling struct{}
+
+func (rolling) Summarize(
+	ctx context.Context, complete Complete, previous string, messages []providers.Message,
+) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("Provide a concise summary of this conversation segment, preserving core context and key points.\n")
+	if previous != "" {
+		sb.WriteString("Existing context: " + previous + "\n")
+	}
+	sb.WriteString("\nCONVERSATION:\n")
+	writeMessages(&sb, messages)
+	summary, err := complete(ctx, sb.String())
+	return strings.TrimSpace(summary), err
+}
+
+// mapReduce summarizes chunks of the messages one by one, then merges those
+// summaries and the previous one. Few messages are summarized as rolling
+// does.
+type mapReduce struct {
+	chunk int
+}
+
+func (s mapReduce) Summarize(
+	ctx context.Context, complete Complete, previous string, messages []providers.Message,
+) (string, error) {
+	if len(messages) <= s.chunk {
+		return rolling{}.Summarize(ctx, complete, previous, messages)
+	}
+
+	// Chunks of equal size read better than full ones and a short rest.
+	n := (len(messages) + s.chunk - 1) / s.chunk
+	size := (len(messages) + n - 1) / n
+	var parts []string
+	var errs []error
+	for start := 0; start < len(messages); start += size {
+		part, err := rolling{}.Summarize(ctx, complete, "", messages[start:min(start+size, len(messages))])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", errors.Join(errs...)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Merge these conversation summaries, oldest first, into one cohesive summary:\n")
+	if previous != "" {
+		parts = append([]string{previous}, parts...)
+	}
+	for i, part := range parts {
+		fmt.Fprintf(&sb, "\n%d: %s\n", i+1, part)
+	}
+	merged, err := complete(ctx, sb.String())
+	if merged = strings.TrimSpace(merged); err != nil || merged == "" {
+		// The parts still say more than no summary at all.
+		return strings.Join(parts, "\n\n"), nil
+	}
+	return merged, nil
+}
+
+// bullets keeps a list of the facts, decisions and open points of the
+// conversation, in the words used in it, instead of a narrative.
+type bullets struct{}
+
+func (bullets) Summarize(
+	ctx context.Context, complete Complete, previous string, messages []providers.Message,
+) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("Extract the points of this conversation worth remembering as a bullet list: facts about " +
+		"the user, decisions, commitments and open questions. Keep names, numbers and dates exactly as " +
+		"written. One point per line, each starting with \"- \". No headings or other text.\n")
+	if previous != "" {
+		sb.WriteString("\nKeep those of these earlier points that still hold, updated where the " +
+			"conversation changed them:\n" + previous + "\n")
+	}
+	sb.WriteString("\nCONVERSATION:\n")
+	writeMessages(&sb, messages)
+
+	answer, err := complete(ctx, sb.String())
+	if err != nil {
+		return "", err
+	}
+	points := bulletPoints(answer)
+	if len(points) == 0 {
+		return "", errors.New("summarize: the answer has no bullet points")
+	}
+	return strings.Join(points, "\n"), nil
+}
+
+// bulletPoints returns the bullet lines of text as "- point", without
+// duplicates and at most maxBullets of them, the last ones.
+func bulletPoints(text string) []string {
+	var points []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		point, ok := strings.CutPrefix(line, "- ")
+		if !ok {
+			if point, ok = strings.CutPrefix(line, "* "); !ok {
+				continue
+			}
+		}
+		point = strings.TrimSpace(point)
+		if key := strings.ToLower(point); point != "" && !seen[key] {
+			seen[key] = true
+			points = append(points, "- "+point)
+		}
+	}
+	if len(points) > maxBullets {
+		points = points[len(points)-maxBullets:]
+	}
+	return points
+}
+
+func writeMessages(sb *strings.Builder, messages []providers.Message) {
+	for _, m := range messages {
+		fmt.Fprintf(sb, "%s: %s\n", m.Role, m.Content)
+	}
+}
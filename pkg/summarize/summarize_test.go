@@ -0,0 +1,128 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// recorder answers prompts with answer and keeps them.
+type recorder struct {
+	prompts []string
+	answer  func(prompt string) (string, error)
+}
+
+func (r *recorder) complete(_ context.Context, prompt string) (string, error) {
+	r.prompts = append(r.prompts, prompt)
+	return r.answer(prompt)
+}
+
+func messages(n int) []providers.Message {
+	msgs := make([]providers.Message, n)
+	for i := range msgs {
+		msgs[i] = providers.Message{Role: "user", Content: fmt.Sprintf("message %d", i+1)}
+	}
+	return msgs
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range append([]string{""}, Names...) {
+		s, err := New(name, 0)
+		require.NoError(t, err, name)
+		assert.NotNil(t, s)
+	}
+	_, err := New("abstractive", 0)
+	assert.ErrorContains(t, err, "map_reduce, rolling, bullets")
+}
+
+func TestRolling(t *testing.T) {
+	r := &recorder{answer: func(string) (string, error) { return " new summary \n", nil }}
+	s, _ := New(Rolling, 0)
+	got, err := s.Summarize(context.Background(), r.complete, "old summary", messages(30))
+	require.NoError(t, err)
+	assert.Equal(t, "new summary", got)
+	require.Len(t, r.prompts, 1, "one call, however many messages")
+	assert.Contains(t, r.prompts[0], "Existing context: old summary")
+	assert.Contains(t, r.prompts[0], "user: message 30")
+}
+
+func TestMapReduce(t *testing.T) {
+	r := &recorder{answer: func(prompt string) (string, error) {
+		if strings.HasPrefix(prompt, "Merge") {
+			return "merged", nil
+		}
+		return fmt.Sprintf("part %d", strings.Count(prompt, "user: ")), nil
+	}}
+	s, _ := New(MapReduce, 10)
+	got, err := s.Summarize(context.Background(), r.complete, "old summary", messages(21))
+	require.NoError(t, err)
+	assert.Equal(t, "merged", got)
+
+	require.Len(t, r.prompts, 4, "three chunks and the merge")
+	for _, p := range r.prompts[:3] {
+		assert.Equal(t, 7, strings.Count(p, "user: "), "chunks are of equal size")
+		assert.NotContains(t, p, "old summary")
+	}
+	assert.Contains(t, r.prompts[3], "1: old summary\n\n2: part 7\n\n3: part 7\n\n4: part 7")
+}
+
+func TestMapReduce_FewMessages(t *testing.T) {
+	r := &recorder{answer: func(string) (string, error) { return "summary", nil }}
+	s, _ := New(MapReduce, 10)
+	_, err := s.Summarize(context.Background(), r.complete, "old", messages(10))
+	require.NoError(t, err)
+	require.Len(t, r.prompts, 1)
+	assert.Contains(t, r.prompts[0], "Existing context: old")
+}
+
+func TestMapReduce_Failures(t *testing.T) {
+	var calls int
+	r := &recorder{answer: func(prompt string) (string, error) {
+		calls++
+		if calls == 1 || strings.HasPrefix(prompt, "Merge") {
+			return "", errors.New("rate limited")
+		}
+		return "part", nil
+	}}
+	s, _ := New(MapReduce, 5)
+	got, err := s.Summarize(context.Background(), r.complete, "", messages(10))
+	require.NoError(t, err)
+	assert.Equal(t, "part", got, "without a merge the parts that worked are kept")
+
+	r.answer = func(string) (string, error) { return "", errors.New("down") }
+	_, err = s.Summarize(context.Background(), r.complete, "", messages(10))
+	assert.ErrorContains(t, err, "down")
+}
+
+func TestBullets(t *testing.T) {
+	r := &recorder{answer: func(string) (string, error) {
+		return "Here are the points:\n- Flight on 2026-03-04\n* Prefers aisle seats\n\n- flight on 2026-03-04\n-   \n", nil
+	}}
+	s, _ := New(Bullets, 0)
+	got, err := s.Summarize(context.Background(), r.complete, "- Lives in Lyon", messages(3))
+	require.NoError(t, err)
+	assert.Equal(t, "- Flight on 2026-03-04\n- Prefers aisle seats", got)
+	assert.Contains(t, r.prompts[0], "still hold")
+	assert.Contains(t, r.prompts[0], "- Lives in Lyon")
+
+	r.answer = func(string) (string, error) { return "Nothing to note.", nil }
+	_, err = s.Summarize(context.Background(), r.complete, "", messages(3))
+	assert.Error(t, err, "an answer without bullets would wipe the summary")
+}
+
+func TestBulletPoints_Cap(t *testing.T) {
+	var sb strings.Builder
+	for i := range maxBullets + 5 {
+		fmt.Fprintf(&sb, "- point %d\n", i)
+	}
+	points := bulletPoints(sb.String())
+	require.Len(t, points, maxBullets)
+	assert.Equal(t, "- point 5", points[0], "the oldest points go first")
+}
@@ -0,0 +1,153 @@
+// Package systemd speaks the parts of the systemd service protocol the
+// gateway uses when it runs as a unit: readiness and watchdog notifications
+// (sd_notify) and socket activation (sd_listen_fds). Outside systemd every
+// function is a no-op.
+package systemd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Notification states, see sd_notify(3).
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// listenFDsStart is the first file descriptor systemd passes.
+const listenFDsStart = 3
+
+// Status returns the state that shows status as the one-line status of the
+// unit in systemctl status.
+func Status(status string) string {
+	return "STATUS=" + status
+}
+
+// ExtendTimeout returns the state that asks systemd to wait d longer for
+// the current start-up or shutdown.
+func ExtendTimeout(d time.Duration) string {
+	return "EXTEND_TIMEOUT_USEC=" + strconv.FormatInt(d.Microseconds(), 10)
+}
+
+// Notify sends the states to the service manager. It reports false without
+// an error when the process does not run under systemd with Type=notify.
+func Notify(states ...string) (bool, error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace.
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often systemd expects a Watchdog
+// notification, or 0 when the watchdog is off for this process.
+func WatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// RunWatchdog sends a Watchdog notification at half the interval systemd
+// asks for, as long as healthy returns nil, until ctx is done. When healthy
+// fails the notifications stop, so systemd restarts the service once the
+// interval runs out. It returns at once when the watchdog is off.
+func RunWatchdog(ctx context.Context, healthy func() error) {
+	interval, err := WatchdogInterval()
+	if err != nil {
+		logger.WarnCF("systemd", "Watchdog disabled", map[string]any{"error": err.Error()})
+		return
+	}
+	if interval == 0 {
+		return
+	}
+	logger.InfoCF("systemd", "Watchdog enabled", map[string]any{"interval": interval.String()})
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := healthy(); err != nil {
+			logger.WarnCF("systemd", "Unhealthy, withholding the watchdog notification",
+				map[string]any{"error": err.Error()})
+			continue
+		}
+		if _, err := Notify(Watchdog); err != nil {
+			logger.WarnCF("systemd", "Watchdog notification failed", map[string]any{"error": err.Error()})
+		}
+	}
+}
+
+// Listeners returns the sockets systemd passed to the process by socket
+// activation, keyed by their FileDescriptorName=, or nil when there are
+// none. Names default to "LISTEN_FD_<n>" like in sd_listen_fds_with_names(3).
+// It unsets the environment variables so child processes do not take the
+// sockets for theirs.
+func Listeners() (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make(map[string]net.Listener, n)
+	var errs []error
+	for i := range n {
+		fd := listenFDsStart + i
+		name := fmt.Sprintf("LISTEN_FD_%d", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close() // FileListener holds a copy
+		if err != nil {
+			errs = append(errs, fmt.Errorf("socket %s: %w", name, err))
+			continue
+		}
+		listeners[name] = l
+	}
+	return listeners, errors.Join(errs...)
+}
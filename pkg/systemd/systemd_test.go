@@ -0,0 +1,136 @@
+package systemd
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listen creates a notify socket and points NOTIFY_SOCKET at it.
+func listen(t *testing.T) *net.UnixConn {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes; t.TempDir can be longer.
+	dir, err := os.MkdirTemp("", "sd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no notification: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Fatalf("without NOTIFY_SOCKET: sent=%v err=%v", sent, err)
+	}
+
+	conn := listen(t)
+	sent, err := Notify(Ready, Status("Serving 2 channels"))
+	if !sent || err != nil {
+		t.Fatalf("sent=%v err=%v", sent, err)
+	}
+	if got, want := receive(t, conn), "READY=1\nSTATUS=Serving 2 channels"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestExtendTimeout(t *testing.T) {
+	if got := ExtendTimeout(90 * time.Second); got != "EXTEND_TIMEOUT_USEC=90000000" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+		wantErr   bool
+	}{
+		{"", "", 0, false},
+		{"30000000", "", 30 * time.Second, false},
+		{"30000000", pid, 30 * time.Second, false},
+		{"30000000", "1", 0, false}, // meant for another process
+		{"soon", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		got, err := WatchdogInterval()
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("WATCHDOG_USEC=%q WATCHDOG_PID=%q: got %v, %v", tt.usec, tt.pid, got, err)
+		}
+	}
+}
+
+func TestRunWatchdog(t *testing.T) {
+	conn := listen(t)
+	t.Setenv("WATCHDOG_USEC", "100000") // pings every 50ms
+	t.Setenv("WATCHDOG_PID", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	healthy := make(chan error, 1)
+	healthy <- nil
+	go RunWatchdog(ctx, func() error {
+		select {
+		case err := <-healthy:
+			healthy <- err
+			return err
+		default:
+			return nil
+		}
+	})
+
+	if got := receive(t, conn); got != Watchdog {
+		t.Fatalf("got %q, want %q", got, Watchdog)
+	}
+
+	<-healthy
+	healthy <- context.DeadlineExceeded
+	// Drop a notification that was on its way, then expect silence.
+	time.Sleep(60 * time.Millisecond)
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	conn.Read(buf)
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if n, err := conn.Read(buf); err == nil {
+		t.Fatalf("notified while unhealthy: %q", buf[:n])
+	}
+}
+
+func TestListeners_OtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "http")
+
+	ls, err := Listeners()
+	if ls != nil || err != nil {
+		t.Fatalf("got %v, %v", ls, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Fatal("LISTEN_FDS still set")
+	}
+}
@@ -0,0 +1,176 @@
+// Package tasks keeps the plans of multi-step jobs on disk. Each step is
+// checkpointed as it finishes, so a job interrupted by a restart can pick up
+// at the step it was on.
+package tasks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+)
+
+// ErrNotFound is returned for an unknown plan ID.
+var ErrNotFound = errors.New("no such plan")
+
+// Status is the state of a plan or of one of its steps.
+type Status string
+
+const (
+	Pending   Status = "pending"
+	Running   Status = "running"
+	Paused    Status = "paused" // stopped by the user; not resumed on its own
+	Done      Status = "done"
+	Failed    Status = "failed"
+	Cancelled Status = "cancelled"
+)
+
+// Step is one step of a plan.
+type Step struct {
+	Description string    `json:"description"`
+	Status      Status    `json:"status"`
+	Result      string    `json:"result,omitempty"`
+	FinishedAt  time.Time `json:"finished_at,omitzero"`
+}
+
+// Plan is a task broken into steps that are carried out one after another.
+type Plan struct {
+	ID         string `json:"id"`
+	AgentID    string `json:"agent_id"`
+	SessionKey string `json:"session_key"`
+	Goal       string `json:"goal"`
+	Status     Status `json:"status"`
+	Steps      []Step `json:"steps"`
+	// Origin is the message that asked for the plan, without its content.
+	// The plan is resumed on its behalf.
+	Origin    bus.InboundMessage `json:"origin"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// Next returns the index of the first step that is not done, or -1.
+func (p *Plan) Next() int {
+	for i, s := range p.Steps {
+		if s.Status != Done {
+			return i
+		}
+	}
+	return -1
+}
+
+// Finished reports whether the plan has come to an end, either way.
+func (p *Plan) Finished() bool {
+	return p.Status == Done || p.Status == Failed || p.Status == Cancelled
+}
+
+// Queue stores plans as one JSON file each in a directory.
+type Queue struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewQueue creates a queue stored in dir.
+func NewQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("tasks: create directory: %w", err)
+	}
+	return &Queue{dir: dir}, nil
+}
+
+// Add gives p an ID and stores it.
+func (q *Queue) Add(p *Plan) error {
+	var id [6]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return fmt.Errorf("tasks: generate id: %w", err)
+	}
+	p.ID = hex.EncodeToString(id[:])
+	p.CreatedAt = time.Now()
+	return q.Save(p)
+}
+
+// Save stores the current state of p.
+func (q *Queue) Save(p *Plan) error {
+	p.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("tasks: marshal plan: %w", err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := fileutil.WriteFileAtomic(q.path(p.ID), data, 0o644); err != nil {
+		return fmt.Errorf("tasks: save plan: %w", err)
+	}
+	return nil
+}
+
+// Get returns the plan with the given ID.
+func (q *Queue) Get(id string) (*Plan, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if strings.ContainsAny(id, `/\.`) {
+		return nil, ErrNotFound
+	}
+	return q.load(q.path(id))
+}
+
+// List returns the plans that keep returns true for, oldest first.
+func (q *Queue) List(keep func(*Plan) bool) ([]*Plan, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("tasks: list plans: %w", err)
+	}
+	var plans []*Plan
+	for _, path := range paths {
+		p, err := q.load(path)
+		if err != nil {
+			log.Printf("tasks: skipping %s: %v", filepath.Base(path), err)
+			continue
+		}
+		if keep == nil || keep(p) {
+			plans = append(plans, p)
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].CreatedAt.Before(plans[j].CreatedAt) })
+	return plans, nil
+}
+
+// Latest returns the most recent plan of a session, or nil.
+func (q *Queue) Latest(sessionKey string) (*Plan, error) {
+	plans, err := q.List(func(p *Plan) bool { return p.SessionKey == sessionKey })
+	if err != nil || len(plans) == 0 {
+		return nil, err
+	}
+	return plans[len(plans)-1], nil
+}
+
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+func (q *Queue) load(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tasks: read plan: %w", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("tasks: decode plan: %w", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestQueue_SaveAndReload(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewQueue(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plan{
+		SessionKey: "agent:main:main",
+		Goal:       "tidy the downloads folder",
+		Status:     Running,
+		Steps:      []Step{{Description: "list files", Status: Pending}, {Description: "move them", Status: Pending}},
+		Origin:     bus.InboundMessage{Channel: "telegram", ChatID: "42", Role: "guest"},
+	}
+	if err := q.Add(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.ID == "" {
+		t.Fatal("Add did not assign an id")
+	}
+	p.Steps[0].Status, p.Steps[0].Result = Done, "3 files"
+	if err := q.Save(p); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new queue over the same directory, as after a restart.
+	q, _ = NewQueue(dir)
+	got, err := q.Get(p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Next() != 1 || got.Steps[0].Result != "3 files" || got.Origin.Role != "guest" {
+		t.Errorf("reloaded plan = %+v", got)
+	}
+
+	if _, err := q.Get("../secrets"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(../secrets) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestQueue_ListAndLatest(t *testing.T) {
+	dir := t.TempDir()
+	q, _ := NewQueue(dir)
+	for _, goal := range []string{"first", "second"} {
+		if err := q.Add(&Plan{SessionKey: "s1", Goal: goal, Status: Running}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Add(&Plan{SessionKey: "s2", Goal: "other", Status: Done}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	unfinished, err := q.List(func(p *Plan) bool { return !p.Finished() })
+	if err != nil || len(unfinished) != 2 || unfinished[0].Goal != "first" {
+		t.Errorf("List(unfinished) = %v, %v", unfinished, err)
+	}
+	latest, err := q.Latest("s1")
+	if err != nil || latest == nil || latest.Goal != "second" {
+		t.Errorf("Latest(s1) = %+v, %v", latest, err)
+	}
+	if latest, _ := q.Latest("missing"); latest != nil {
+		t.Errorf("Latest(missing) = %+v", latest)
+	}
+}
@@ -0,0 +1,64 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSetup(t *testing.T) {
+	prev := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	ctx := context.Background()
+
+	stop, err := Setup(ctx, config.TelemetryConfig{}, "dev")
+	if err != nil || stop(ctx) != nil {
+		t.Fatalf("disabled Setup = %v", err)
+	}
+	if otel.GetTracerProvider() != prev {
+		t.Error("disabled Setup replaced the tracer provider")
+	}
+
+	stop, err = Setup(ctx, config.TelemetryConfig{
+		Enabled: true, Endpoint: "127.0.0.1:1", Insecure: true, SampleRatio: 1,
+	}, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider); !ok {
+		t.Errorf("tracer provider = %T, want the SDK's", otel.GetTracerProvider())
+	}
+	if err := stop(ctx); err != nil {
+		t.Errorf("shutdown with nothing to export: %v", err)
+	}
+}
+
+func TestEnd_RecordsError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("disk full"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Fatalf("spans = %v, want child under parent", spans)
+	}
+	if spans[0].Status().Code != codes.Error || spans[0].Status().Description != "disk full" {
+		t.Errorf("child status = %+v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Unset {
+		t.Errorf("parent status = %+v", spans[1].Status())
+	}
+}
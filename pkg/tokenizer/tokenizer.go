@@ -0,0 +1,90 @@
+// PicoClaw - Ultra-lightweight personal AI agent
+// License: MIT
+//
+// Copyright (c) 2026 PicoClaw contributors
+
+// Package tokenizer estimates how many tokens text and chat requests take.
+// Provider tokenizers differ and most are not available offline, so the
+// estimates are heuristics tuned to err on the high side: they are meant for
+// budgeting a prompt against a context window, not for billing.
+package tokenizer
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const (
+	// messageOverhead covers the role and framing tokens every chat message
+	// carries in addition to its content.
+	messageOverhead = 4
+	// mediaTokens is charged per attached image or file. Vision models bill
+	// images by resolution; this is a typical mid-size image.
+	mediaTokens = 1000
+)
+
+// Count estimates the tokens in s. CJK runes (U+2E80–U+9FFF, U+F900–U+FAFF,
+// U+AC00–U+D7AF) map to roughly one token each, while other runes average
+// about four per token, which is typical for English text and code.
+func Count(s string) int {
+	total := utf8.RuneCountInString(s)
+	if total == 0 {
+		return 0
+	}
+	cjk := 0
+	for _, r := range s {
+		if r >= 0x2E80 && r <= 0x9FFF || r >= 0xF900 && r <= 0xFAFF || r >= 0xAC00 && r <= 0xD7AF {
+			cjk++
+		}
+	}
+	return cjk + (total-cjk)/4
+}
+
+// CountMessage estimates the tokens one message adds to a request,
+// including tool calls and attached media.
+func CountMessage(m providers.Message) int {
+	n := messageOverhead
+	if m.Content != "" {
+		n += Count(m.Content)
+	} else {
+		// SystemParts repeat Content as structured blocks; only count them
+		// when they are the sole representation.
+		for _, part := range m.SystemParts {
+			n += Count(part.Text)
+		}
+	}
+	n += Count(m.ReasoningContent)
+	for _, tc := range m.ToolCalls {
+		if tc.Function != nil {
+			n += Count(tc.Function.Name) + Count(tc.Function.Arguments)
+		} else {
+			n += Count(tc.Name)
+		}
+	}
+	n += len(m.Media) * mediaTokens
+	return n
+}
+
+// CountMessages estimates the tokens of a message list.
+func CountMessages(messages []providers.Message) int {
+	n := 0
+	for _, m := range messages {
+		n += CountMessage(m)
+	}
+	return n
+}
+
+// CountTools estimates the tokens tool definitions add to a request. Providers
+// render the schemas differently; the JSON encoding is a close upper bound.
+func CountTools(tools []providers.ToolDefinition) int {
+	if len(tools) == 0 {
+		return 0
+	}
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return Count(string(data))
+}
@@ -0,0 +1,78 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestCount(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"empty", "", 0},
+		{"latin", strings.Repeat("a", 40), 10},
+		{"cjk", "你好世界", 4},
+		{"mixed", "你好 abcd", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Count(tt.in); got != tt.want {
+				t.Errorf("Count(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountMessage_SystemPartsNotDoubleCounted(t *testing.T) {
+	text := strings.Repeat("a", 400)
+	withParts := providers.Message{
+		Role:        "system",
+		Content:     text,
+		SystemParts: []providers.ContentBlock{{Type: "text", Text: text}},
+	}
+	partsOnly := providers.Message{
+		Role:        "system",
+		SystemParts: []providers.ContentBlock{{Type: "text", Text: text}},
+	}
+
+	if got, want := CountMessage(withParts), messageOverhead+100; got != want {
+		t.Errorf("CountMessage(content+parts) = %d, want %d", got, want)
+	}
+	if got, want := CountMessage(partsOnly), messageOverhead+100; got != want {
+		t.Errorf("CountMessage(parts) = %d, want %d", got, want)
+	}
+}
+
+func TestCountMessage_IncludesToolCallsAndMedia(t *testing.T) {
+	m := providers.Message{
+		Role: "assistant",
+		ToolCalls: []providers.ToolCall{{
+			ID:       "call_1",
+			Function: &providers.FunctionCall{Name: "exec", Arguments: strings.Repeat("x", 40)},
+		}},
+		Media: []string{"media://1"},
+	}
+	if got, want := CountMessage(m), messageOverhead+1+10+mediaTokens; got != want {
+		t.Errorf("CountMessage() = %d, want %d", got, want)
+	}
+}
+
+func TestCountTools(t *testing.T) {
+	if got := CountTools(nil); got != 0 {
+		t.Errorf("CountTools(nil) = %d, want 0", got)
+	}
+	tools := []providers.ToolDefinition{{
+		Type: "function",
+		Function: providers.ToolFunctionDefinition{
+			Name:        "read_file",
+			Description: strings.Repeat("d", 400),
+		},
+	}}
+	if got := CountTools(tools); got <= 100 {
+		t.Errorf("CountTools() = %d, want more than the description alone", got)
+	}
+}
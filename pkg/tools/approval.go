@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// DefaultApprovalExpiry is how long a pending approval waits for the user
+// when no expiry is configured.
+const DefaultApprovalExpiry = 30 * time.Minute
+
+var (
+	// ErrApprovalNotFound is returned for an unknown or expired approval ID.
+	ErrApprovalNotFound = errors.New("no pending approval with that id (it may have expired)")
+	// ErrApprovalOtherChat is returned when an approval is answered from a
+	// conversation other than the one that requested it.
+	ErrApprovalOtherChat = errors.New("that approval belongs to another conversation")
+)
+
+// ApprovalGate is an optional interface for tools that need the user's
+// confirmation for some calls only, such as a push but not a status check.
+// Calls for which NeedsApproval reports true are held like calls to tools
+// listed in RequireApproval.
+type ApprovalGate interface {
+	NeedsApproval(args map[string]any) bool
+}
+
+var ctxKeyApproved = &toolCtxKey{"approved"}
+
+// WithApproval marks ctx as carrying the user's approval, so the registry
+// runs a tool that would otherwise wait for confirmation.
+func WithApproval(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyApproved, true)
+}
+
+func isApproved(ctx context.Context) bool {
+	v, _ := ctx.Value(ctxKeyApproved).(bool)
+	return v
+}
+
+// PendingApproval is a tool call waiting for the user's confirmation in the
+// conversation that made it.
+type PendingApproval struct {
+	ID        string         `json:"id"`
+	Tool      string         `json:"tool"`
+	Args      map[string]any `json:"args,omitempty"`
+	Channel   string         `json:"channel"`
+	ChatID    string         `json:"chat_id"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// ApprovalStore persists pending approvals in a JSON file, so a request
+// survives a restart until it is answered or expires. Expired entries are
+// dropped whenever the file is read.
+type ApprovalStore struct {
+	path   string
+	expiry time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewApprovalStore creates a store kept at path. A non-positive expiry uses
+// DefaultApprovalExpiry.
+func NewApprovalStore(path string, expiry time.Duration) *ApprovalStore {
+	if expiry <= 0 {
+		expiry = DefaultApprovalExpiry
+	}
+	return &ApprovalStore{path: path, expiry: expiry, now: time.Now}
+}
+
+// Expiry returns how long a new request stays pending.
+func (s *ApprovalStore) Expiry() time.Duration {
+	return s.expiry
+}
+
+// Add records a tool call that needs approval in channel/chatID.
+func (s *ApprovalStore) Add(tool string, args map[string]any, channel, chatID string) (PendingApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, err := s.loadLocked()
+	if err != nil {
+		return PendingApproval{}, err
+	}
+	now := s.now()
+	p := PendingApproval{
+		ID:        newShortID(),
+		Tool:      tool,
+		Args:      args,
+		Channel:   channel,
+		ChatID:    chatID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.expiry),
+	}
+	if err := s.saveLocked(append(pending, p)); err != nil {
+		return PendingApproval{}, err
+	}
+	return p, nil
+}
+
+// Take removes and returns the pending approval with the given ID, provided
+// it was requested from channel/chatID and has not expired.
+func (s *ApprovalStore) Take(id, channel, chatID string) (PendingApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, err := s.loadLocked()
+	if err != nil {
+		return PendingApproval{}, err
+	}
+	for i, p := range pending {
+		if p.ID != id {
+			continue
+		}
+		if p.Channel != channel || p.ChatID != chatID {
+			return PendingApproval{}, ErrApprovalOtherChat
+		}
+		if err := s.saveLocked(append(pending[:i:i], pending[i+1:]...)); err != nil {
+			return PendingApproval{}, err
+		}
+		return p, nil
+	}
+	return PendingApproval{}, ErrApprovalNotFound
+}
+
+// List returns the unexpired approvals pending in channel/chatID, oldest first.
+func (s *ApprovalStore) List(channel, chatID string) ([]PendingApproval, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, err := s.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	var out []PendingApproval
+	for _, p := range pending {
+		if p.Channel == channel && p.ChatID == chatID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// loadLocked reads the store and drops expired entries. Callers must hold s.mu.
+func (s *ApprovalStore) loadLocked() ([]PendingApproval, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read approvals: %w", err)
+	}
+	var pending []PendingApproval
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return nil, fmt.Errorf("parse approvals: %w", err)
+	}
+
+	now := s.now()
+	live := pending[:0]
+	for _, p := range pending {
+		if now.Before(p.ExpiresAt) {
+			live = append(live, p)
+		}
+	}
+	sort.SliceStable(live, func(i, j int) bool { return live[i].CreatedAt.Before(live[j].CreatedAt) })
+	return live, nil
+}
+
+func (s *ApprovalStore) saveLocked(pending []PendingApproval) error {
+	if pending == nil {
+		pending = []PendingApproval{}
+	}
+	data, err := json.MarshalIndent(pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal approvals: %w", err)
+	}
+	return fileutil.WriteFileAtomic(s.path, data, 0o600)
+}
+
+// newShortID returns a random six-character hex ID, short enough to type
+// in a chat reply.
+func newShortID() string {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano()&0xffffff)
+	}
+	return hex.EncodeToString(b)
+}
+
+// approvalRequired builds the result returned in place of running a tool
+// that needs the user's confirmation.
+func approvalRequired(p PendingApproval, expiry time.Duration) *ToolResult {
+	args, _ := json.Marshal(p.Args)
+	return NewToolResult(fmt.Sprintf(
+		"%s was not run: it needs the user's approval first. Tell the user what you want to run (%s %s) and "+
+			"that they can reply /approve %s to run it or /deny %s within %d minutes. "+
+			"Do not call it again; the outcome will be reported to you.",
+		p.Tool, p.Tool, utils.Truncate(string(args), 300), p.ID, p.ID, int(expiry.Minutes())))
+}
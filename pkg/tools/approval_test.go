@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApprovalStore_AddTakeAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals", "pending.json")
+	store := NewApprovalStore(path, time.Hour)
+
+	p, err := store.Add("exec", map[string]any{"command": "rm notes.md"}, "telegram", "42")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// A fresh store over the same file sees the request, as after a restart.
+	reopened := NewApprovalStore(path, time.Hour)
+	pending, err := reopened.List("telegram", "42")
+	if err != nil || len(pending) != 1 || pending[0].ID != p.ID {
+		t.Fatalf("List = %+v, %v; want the stored approval", pending, err)
+	}
+	if pending[0].Args["command"] != "rm notes.md" {
+		t.Errorf("Args = %v", pending[0].Args)
+	}
+
+	if _, err := reopened.Take(p.ID, "telegram", "other"); !errors.Is(err, ErrApprovalOtherChat) {
+		t.Errorf("Take from another chat: err = %v, want ErrApprovalOtherChat", err)
+	}
+	got, err := reopened.Take(p.ID, "telegram", "42")
+	if err != nil || got.Tool != "exec" {
+		t.Fatalf("Take = %+v, %v", got, err)
+	}
+	if _, err := store.Take(p.ID, "telegram", "42"); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("second Take: err = %v, want ErrApprovalNotFound", err)
+	}
+}
+
+func TestApprovalStore_Expiry(t *testing.T) {
+	store := NewApprovalStore(filepath.Join(t.TempDir(), "pending.json"), 10*time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	p, err := store.Add("write_file", nil, "cli", "direct")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if _, err := store.Take(p.ID, "cli", "direct"); !errors.Is(err, ErrApprovalNotFound) {
+		t.Errorf("Take after expiry: err = %v, want ErrApprovalNotFound", err)
+	}
+	if pending, _ := store.List("cli", "direct"); len(pending) != 0 {
+		t.Errorf("List after expiry = %+v, want none", pending)
+	}
+}
+
+func TestToolRegistry_RequireApproval(t *testing.T) {
+	r := NewToolRegistry()
+	store := NewApprovalStore(filepath.Join(t.TempDir(), "pending.json"), 0)
+	r.RequireApproval(store, []string{"guarded"})
+
+	var runs int
+	r.Register(&mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("guarded", ""),
+		fn: func(context.Context) *ToolResult {
+			runs++
+			return SilentResult("ran")
+		},
+	})
+	r.Register(newMockTool("free", ""))
+
+	result := r.ExecuteWithContext(context.Background(), "guarded", map[string]any{"x": 1}, "telegram", "42", nil)
+	if runs != 0 || result.IsError {
+		t.Fatalf("guarded call ran=%d result=%+v; want it held for approval", runs, result)
+	}
+	pending, _ := store.List("telegram", "42")
+	if len(pending) != 1 || !strings.Contains(result.ForLLM, "/approve "+pending[0].ID) {
+		t.Fatalf("ForLLM = %q, pending = %+v", result.ForLLM, pending)
+	}
+
+	ctx := WithApproval(context.Background())
+	if result := r.ExecuteWithContext(ctx, "guarded", nil, "telegram", "42", nil); result.ForLLM != "ran" || runs != 1 {
+		t.Errorf("approved call: result=%+v runs=%d", result, runs)
+	}
+	if result := r.ExecuteWithContext(context.Background(), "free", nil, "telegram", "42", nil); result.ForLLM != "ok" {
+		t.Errorf("unguarded tool result = %+v", result)
+	}
+	if result := r.Execute(context.Background(), "guarded", nil); !result.IsError {
+		t.Errorf("guarded call without a conversation should fail, got %+v", result)
+	}
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// auditedTools are the tools whose calls go to the audit log, with the
+// argument that names what they act on.
+var auditedTools = map[string]struct {
+	kind audit.Kind
+	arg  string
+}{
+	"write_file":     {audit.FileWrite, "path"},
+	"edit_file":      {audit.FileWrite, "path"},
+	"append_file":    {audit.FileWrite, "path"},
+	"exec":           {audit.ShellExec, "command"},
+	"container_exec": {audit.ShellExec, "command"},
+}
+
+// auditCall appends a call of an audited tool to the log set by
+// SetAuditLog, whatever its outcome. Failures are logged, not returned.
+func (r *ToolRegistry) auditCall(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	channel, chatID, outcome string,
+	result *ToolResult,
+) {
+	r.mu.RLock()
+	log := r.audit
+	r.mu.RUnlock()
+	spec, ok := auditedTools[name]
+	if log == nil || !ok {
+		return
+	}
+	target, _ := args[spec.arg].(string)
+	e := audit.Event{
+		Kind:       spec.kind,
+		Actor:      callerID(ToolCaller(ctx)),
+		SessionKey: ToolSessionKey(ctx),
+		Channel:    channel,
+		ChatID:     chatID,
+		Action:     name,
+		Target:     truncateRunes(target, 500),
+		Outcome:    outcome,
+	}
+	if result.IsError {
+		e.Detail = result.ForLLM
+		if result.Err != nil {
+			e.Detail = result.Err.Error()
+		}
+		e.Detail = truncateRunes(e.Detail, 200)
+	}
+	if err := log.Record(ctx, e); err != nil {
+		logger.WarnCF("tool", "Failed to audit tool call", map[string]any{"tool": name, "error": err.Error()})
+	}
+}
@@ -0,0 +1,361 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/chromedp"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+// browserIdleTimeout is how long the browser stays running after the last
+// call before it is shut down to free memory.
+const browserIdleTimeout = 5 * time.Minute
+
+// browserCandidates are the Chrome/Chromium binaries looked up in PATH when
+// no exec_path is configured.
+var browserCandidates = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "headless-shell",
+}
+
+// ErrNoBrowser is returned by NewBrowserTool when no Chrome or Chromium
+// binary can be found.
+var ErrNoBrowser = errors.New("no Chrome or Chromium binary found")
+
+func init() {
+	RegisterFactory("browser", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("browser") {
+			return nil, nil
+		}
+		tool, err := NewBrowserTool(env.Config.Tools.Browser, env.Config.Tools.Web.Proxy, env.Workspace)
+		if errors.Is(err, ErrNoBrowser) {
+			logger.WarnCF("tools", "browser tool is enabled but no Chrome/Chromium was found",
+				map[string]any{"agent_id": env.AgentID, "exec_path": env.Config.Tools.Browser.ExecPath})
+			return nil, nil
+		}
+		return tool, err
+	})
+}
+
+// BrowserTool drives a headless Chrome to load pages that need JavaScript,
+// extract their text and take screenshots. The browser is started on first
+// use, keeps one tab whose page later calls can work on, and is shut down
+// after browserIdleTimeout without calls.
+type BrowserTool struct {
+	execPath   string
+	proxy      string
+	workspace  string
+	timeout    time.Duration
+	maxChars   int
+	mediaStore media.MediaStore
+
+	mu          sync.Mutex
+	allocCancel context.CancelFunc
+	tabCtx      context.Context
+	tabCancel   context.CancelFunc
+	idle        *time.Timer
+}
+
+// NewBrowserTool creates the tool from cfg. proxy, when set, is used for all
+// page loads. Screenshots are saved under workspace when there is no media
+// store to deliver them through.
+func NewBrowserTool(cfg config.BrowserConfig, proxy, workspace string) (*BrowserTool, error) {
+	execPath, err := findBrowser(cfg.ExecPath)
+	if err != nil {
+		return nil, err
+	}
+	timeout := 30 * time.Second
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	maxChars := cfg.MaxChars
+	if maxChars <= 0 {
+		maxChars = 20000
+	}
+	return &BrowserTool{
+		execPath:  execPath,
+		proxy:     proxy,
+		workspace: workspace,
+		timeout:   timeout,
+		maxChars:  maxChars,
+	}, nil
+}
+
+func findBrowser(configured string) (string, error) {
+	candidates := browserCandidates
+	if configured != "" {
+		candidates = []string{configured}
+	}
+	for _, c := range candidates {
+		if p, err := exec.LookPath(c); err == nil {
+			return p, nil
+		}
+	}
+	return "", ErrNoBrowser
+}
+
+// SetMediaStore sets the store screenshots are sent to the user through.
+func (t *BrowserTool) SetMediaStore(store media.MediaStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mediaStore = store
+}
+
+func (t *BrowserTool) Name() string {
+	return "browser"
+}
+
+func (t *BrowserTool) Description() string {
+	return "Use a headless web browser for pages that need JavaScript. " +
+		"navigate opens a URL and returns its title and visible text; extract returns the text of elements " +
+		"matching a CSS selector; screenshot sends an image of the page to the user. " +
+		"extract and screenshot work on the current page unless a url is given. " +
+		"Prefer web_fetch for static pages; it is much cheaper."
+}
+
+func (t *BrowserTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"navigate", "extract", "screenshot"},
+				"description": "Browser action",
+			},
+			"url": map[string]any{
+				"type":        "string",
+				"description": "http(s) URL to load (required for navigate)",
+			},
+			"selector": map[string]any{
+				"type":        "string",
+				"description": "CSS selector for extract (default: body)",
+			},
+			"full_page": map[string]any{
+				"type":        "boolean",
+				"description": "Capture the whole page instead of the visible viewport (screenshot)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *BrowserTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	rawURL, _ := args["url"].(string)
+	if rawURL != "" {
+		if err := validateBrowserURL(rawURL); err != nil {
+			return ErrorResult(err.Error())
+		}
+	}
+
+	switch action {
+	case "navigate":
+		if rawURL == "" {
+			return ErrorResult("url is required for navigate")
+		}
+		return t.navigate(ctx, rawURL)
+	case "extract":
+		selector, _ := args["selector"].(string)
+		if selector == "" {
+			selector = "body"
+		}
+		return t.extract(ctx, rawURL, selector)
+	case "screenshot":
+		fullPage, _ := args["full_page"].(bool)
+		return t.screenshot(ctx, rawURL, fullPage)
+	case "":
+		return ErrorResult("action is required")
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+// validateBrowserURL allows only http and https, so the model cannot read
+// local files or browser-internal pages.
+func validateBrowserURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("only http and https URLs are allowed, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("url has no host")
+	}
+	return nil
+}
+
+func (t *BrowserTool) navigate(ctx context.Context, rawURL string) *ToolResult {
+	var title, location, text string
+	err := t.run(ctx, "",
+		chromedp.Navigate(rawURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Title(&title),
+		chromedp.Location(&location),
+		chromedp.Evaluate(`document.body ? document.body.innerText : ""`, &text),
+	)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to load %s: %v", rawURL, err))
+	}
+	return SilentResult(fmt.Sprintf("Title: %s\nURL: %s\n\n%s", title, location, t.truncate(text)))
+}
+
+func (t *BrowserTool) extract(ctx context.Context, rawURL, selector string) *ToolResult {
+	var texts []string
+	expr := fmt.Sprintf(`Array.from(document.querySelectorAll(%q)).map(e => e.innerText.trim()).filter(Boolean)`,
+		selector)
+	if err := t.run(ctx, rawURL, chromedp.Evaluate(expr, &texts)); err != nil {
+		return ErrorResult(fmt.Sprintf("extract failed: %v", err))
+	}
+	if len(texts) == 0 {
+		return SilentResult(fmt.Sprintf("No text found for selector %q", selector))
+	}
+	return SilentResult(t.truncate(strings.Join(texts, "\n\n")))
+}
+
+func (t *BrowserTool) screenshot(ctx context.Context, rawURL string, fullPage bool) *ToolResult {
+	var buf []byte
+	var location string
+	capture := chromedp.CaptureScreenshot(&buf)
+	if fullPage {
+		capture = chromedp.FullScreenshot(&buf, 90)
+	}
+	if err := t.run(ctx, rawURL, chromedp.Location(&location), capture); err != nil {
+		return ErrorResult(fmt.Sprintf("screenshot failed: %v", err))
+	}
+
+	t.mu.Lock()
+	store := t.mediaStore
+	t.mu.Unlock()
+
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+	if store == nil || channel == "" || chatID == "" {
+		// Nowhere to send it; keep it in the workspace instead.
+		dir := filepath.Join(t.workspace, "screenshots")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to save screenshot: %v", err))
+		}
+		ext := ".png"
+		if fullPage {
+			ext = ".jpg"
+		}
+		path := filepath.Join(dir, "screenshot-"+time.Now().Format("20060102-150405")+ext)
+		if err := os.WriteFile(path, buf, 0o644); err != nil {
+			return ErrorResult(fmt.Sprintf("failed to save screenshot: %v", err))
+		}
+		return SilentResult(fmt.Sprintf("Screenshot of %s saved to %s", location, path))
+	}
+
+	filename, contentType := "screenshot.png", "image/png"
+	if fullPage {
+		// FullScreenshot encodes JPEG for qualities below 100.
+		filename, contentType = "screenshot.jpg", "image/jpeg"
+	}
+	ref, err := storeMediaBytes(ctx, store, buf, filename, contentType, "tool:browser")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to send screenshot: %v", err))
+	}
+	return MediaResult(fmt.Sprintf("Screenshot of %s sent to user", location), []string{ref})
+}
+
+func (t *BrowserTool) truncate(text string) string {
+	return truncateRunes(strings.TrimSpace(text), t.maxChars)
+}
+
+// run executes actions in the browser tab, first navigating to rawURL when
+// it is set. Calls are serialized since they share the tab.
+func (t *BrowserTool) run(ctx context.Context, rawURL string, actions ...chromedp.Action) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tab, err := t.tabLocked()
+	if err != nil {
+		return err
+	}
+	defer t.idle.Reset(browserIdleTimeout)
+
+	if rawURL != "" {
+		actions = append([]chromedp.Action{
+			chromedp.Navigate(rawURL),
+			chromedp.WaitReady("body", chromedp.ByQuery),
+		}, actions...)
+	}
+
+	// chromedp takes its lifetime from the tab context, so the call's own
+	// deadline and cancellation are applied on top of it.
+	runCtx, cancel := context.WithTimeout(tab, t.timeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	err = chromedp.Run(runCtx, actions...)
+	if err != nil && tab.Err() != nil {
+		// The browser went away (crashed or was killed); start fresh next time.
+		t.closeLocked()
+	}
+	return err
+}
+
+// tabLocked returns the browser tab, starting the browser if needed.
+// Callers must hold t.mu.
+func (t *BrowserTool) tabLocked() (context.Context, error) {
+	if t.tabCtx != nil {
+		return t.tabCtx, nil
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ExecPath(t.execPath),
+		chromedp.WindowSize(1280, 800),
+	)
+	if t.proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(t.proxy))
+	}
+	if os.Geteuid() == 0 {
+		// Chrome refuses to start its sandbox as root (common in containers).
+		opts = append(opts, chromedp.NoSandbox)
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	tabCtx, tabCancel := chromedp.NewContext(allocCtx)
+	// Run with no actions starts the browser and opens the tab.
+	if err := chromedp.Run(tabCtx); err != nil {
+		tabCancel()
+		allocCancel()
+		return nil, fmt.Errorf("failed to start browser: %w", err)
+	}
+	logger.InfoCF("tools", "Browser started", map[string]any{"exec_path": t.execPath})
+
+	t.allocCancel, t.tabCtx, t.tabCancel = allocCancel, tabCtx, tabCancel
+	t.idle = time.AfterFunc(browserIdleTimeout, t.Close)
+	return tabCtx, nil
+}
+
+// Close shuts the browser down. It is restarted by the next call.
+func (t *BrowserTool) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+}
+
+func (t *BrowserTool) closeLocked() {
+	if t.tabCtx == nil {
+		return
+	}
+	t.idle.Stop()
+	t.tabCancel()
+	t.allocCancel()
+	t.tabCtx, t.tabCancel, t.allocCancel = nil, nil, nil
+	logger.InfoCF("tools", "Browser stopped", nil)
+}
@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewBrowserTool_NoBrowser(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := NewBrowserTool(config.BrowserConfig{}, "", ""); !errors.Is(err, ErrNoBrowser) {
+		t.Errorf("err = %v, want ErrNoBrowser", err)
+	}
+	cfg := config.BrowserConfig{ExecPath: "/nonexistent/chromium"}
+	if _, err := NewBrowserTool(cfg, "", ""); !errors.Is(err, ErrNoBrowser) {
+		t.Errorf("missing exec_path: err = %v, want ErrNoBrowser", err)
+	}
+}
+
+func TestValidateBrowserURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/a?b=c", "http://192.168.1.10:8080"} {
+		if err := validateBrowserURL(u); err != nil {
+			t.Errorf("%s: %v", u, err)
+		}
+	}
+	for _, u := range []string{"file:///etc/passwd", "chrome://settings", "javascript:alert(1)", "https://"} {
+		if err := validateBrowserURL(u); err == nil {
+			t.Errorf("%s was accepted", u)
+		}
+	}
+}
+
+// TestBrowserTool_NavigateAndExtract runs against a real browser when one
+// is installed.
+func TestBrowserTool_NavigateAndExtract(t *testing.T) {
+	tool, err := NewBrowserTool(config.BrowserConfig{}, "", t.TempDir())
+	if errors.Is(err, ErrNoBrowser) {
+		t.Skip("no Chrome/Chromium installed")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tool.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`<html><head><title>Shop</title></head><body><div id="app"></div><script>
+			document.getElementById("app").innerHTML = "<p class=price>4.20</p><p class=price>9.99</p>";
+		</script></body></html>`))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	result := tool.Execute(ctx, map[string]any{"action": "navigate", "url": srv.URL})
+	if result.IsError || !strings.Contains(result.ForLLM, "Title: Shop") || !strings.Contains(result.ForLLM, "4.20") {
+		t.Fatalf("navigate = %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "extract", "selector": ".price"})
+	if result.ForLLM != "4.20\n\n9.99" {
+		t.Errorf("extract = %q", result.ForLLM)
+	}
+}
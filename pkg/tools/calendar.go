@@ -0,0 +1,512 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	calendarTimeout     = 30 * time.Second
+	calendarDefaultDays = 7
+	calendarMaxDays     = 31
+	calendarMaxEvents   = 100
+)
+
+func init() {
+	RegisterFactory("calendar_list", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("calendar") || env.Config.Tools.Calendar.URL == "" {
+			return nil, nil
+		}
+		client, err := NewCalendarClient(env.Config.Tools.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		return NewCalendarListTool(client), nil
+	})
+	RegisterFactory("calendar_create", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("calendar") || env.Config.Tools.Calendar.URL == "" {
+			return nil, nil
+		}
+		client, err := NewCalendarClient(env.Config.Tools.Calendar)
+		if err != nil {
+			return nil, err
+		}
+		return NewCalendarCreateTool(client), nil
+	})
+}
+
+// CalendarEvent is one occurrence of a calendar event. Recurring events
+// yield one CalendarEvent per occurrence.
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Start, End  time.Time
+	AllDay      bool
+}
+
+// CalendarClient reads and writes one CalDAV calendar collection.
+type CalendarClient struct {
+	client *caldav.Client
+	path   string // collection path, with a trailing slash
+}
+
+// bearerHTTPClient adds an OAuth bearer token to every request.
+type bearerHTTPClient struct {
+	http.Client
+	token string
+}
+
+func (c *bearerHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return c.Client.Do(req)
+}
+
+// NewCalendarClient creates a client for the collection at cfg.URL.
+func NewCalendarClient(cfg config.CalendarConfig) (*CalendarClient, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("calendar: invalid url %q", cfg.URL)
+	}
+	var hc webdav.HTTPClient = &http.Client{Timeout: calendarTimeout}
+	switch {
+	case cfg.Token != "":
+		hc = &bearerHTTPClient{Client: http.Client{Timeout: calendarTimeout}, token: cfg.Token}
+	case cfg.Username != "":
+		hc = webdav.HTTPClientWithBasicAuth(hc, cfg.Username, cfg.Password)
+	}
+	client, err := caldav.NewClient(hc, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %w", err)
+	}
+	path := u.Path
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return &CalendarClient{client: client, path: path}, nil
+}
+
+// Events returns the event occurrences overlapping [from, to), sorted by
+// start time. The server is asked to expand recurring events; masters it
+// returns unexpanded are expanded here.
+func (c *CalendarClient) Events(ctx context.Context, from, to time.Time) ([]CalendarEvent, error) {
+	from, to = from.UTC(), to.UTC()
+	query := &caldav.CalendarQuery{
+		CompRequest: caldav.CalendarCompRequest{
+			Name:   ical.CompCalendar,
+			Comps:  []caldav.CalendarCompRequest{{Name: ical.CompEvent, AllProps: true}},
+			Expand: &caldav.CalendarExpandRequest{Start: from, End: to},
+		},
+		CompFilter: caldav.CompFilter{
+			Name:  ical.CompCalendar,
+			Comps: []caldav.CompFilter{{Name: ical.CompEvent, Start: from, End: to}},
+		},
+	}
+	objects, err := c.client.QueryCalendar(ctx, c.path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []CalendarEvent
+	for _, obj := range objects {
+		for _, ev := range obj.Data.Events() {
+			occurrences, err := eventOccurrences(ev, from, to)
+			if err != nil {
+				logger.WarnCF("tools", "Skipping unreadable calendar event", map[string]any{
+					"path":  obj.Path,
+					"error": err.Error(),
+				})
+				continue
+			}
+			events = append(events, occurrences...)
+		}
+	}
+	slices.SortFunc(events, func(a, b CalendarEvent) int { return a.Start.Compare(b.Start) })
+	return events, nil
+}
+
+// eventOccurrences turns one VEVENT into the occurrences overlapping
+// [from, to). Floating and all-day times are read in the local zone.
+func eventOccurrences(ev ical.Event, from, to time.Time) ([]CalendarEvent, error) {
+	start, err := ev.DateTimeStart(time.Local)
+	if err != nil {
+		return nil, err
+	}
+	end, err := ev.DateTimeEnd(time.Local)
+	if err != nil {
+		return nil, err
+	}
+	base := CalendarEvent{Start: start, End: end}
+	base.UID, _ = ev.Props.Text(ical.PropUID)
+	base.Summary, _ = ev.Props.Text(ical.PropSummary)
+	base.Location, _ = ev.Props.Text(ical.PropLocation)
+	base.Description, _ = ev.Props.Text(ical.PropDescription)
+	if p := ev.Props.Get(ical.PropDateTimeStart); p != nil && p.ValueType() == ical.ValueDate {
+		base.AllDay = true
+	}
+
+	starts := []time.Time{start}
+	if ev.Props.Get(ical.PropRecurrenceID) == nil {
+		set, err := ev.RecurrenceSet(time.Local)
+		if err != nil {
+			return nil, err
+		}
+		if set != nil {
+			// An occurrence that started before from may still be running.
+			starts = set.Between(from.Add(-end.Sub(start)), to, true)
+		}
+	}
+
+	var out []CalendarEvent
+	for _, s := range starts {
+		occ := base
+		occ.Start, occ.End = s, s.Add(end.Sub(start))
+		if occ.End.After(from) && occ.Start.Before(to) {
+			out = append(out, occ)
+		}
+	}
+	return out, nil
+}
+
+// Create stores ev as a new calendar object and returns its UID.
+func (c *CalendarClient) Create(ctx context.Context, ev CalendarEvent) (string, error) {
+	uid := uuid.NewString()
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, uid)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now().UTC())
+	event.Props.SetText(ical.PropSummary, ev.Summary)
+	if ev.AllDay {
+		event.Props.SetDate(ical.PropDateTimeStart, ev.Start)
+		event.Props.SetDate(ical.PropDateTimeEnd, ev.End)
+	} else {
+		// UTC avoids having to ship a VTIMEZONE for the host's zone.
+		event.Props.SetDateTime(ical.PropDateTimeStart, ev.Start.UTC())
+		event.Props.SetDateTime(ical.PropDateTimeEnd, ev.End.UTC())
+	}
+	if ev.Location != "" {
+		event.Props.SetText(ical.PropLocation, ev.Location)
+	}
+	if ev.Description != "" {
+		event.Props.SetText(ical.PropDescription, ev.Description)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//sipeed//picoclaw//EN")
+	cal.Children = append(cal.Children, event.Component)
+
+	if _, err := c.client.PutCalendarObject(ctx, c.path+uid+".ics", cal); err != nil {
+		return "", err
+	}
+	return uid, nil
+}
+
+// formatCalendarEvent renders one event as a single line, with the time
+// until it starts when that is less than a day.
+func formatCalendarEvent(ev CalendarEvent, now time.Time) string {
+	var b strings.Builder
+	if ev.AllDay {
+		b.WriteString(ev.Start.Format("2006-01-02 Mon") + " all day")
+	} else {
+		start, end := ev.Start.In(time.Local), ev.End.In(time.Local)
+		b.WriteString(start.Format("2006-01-02 Mon 15:04") + "-" + end.Format("15:04"))
+		if until := start.Sub(now); until > 0 && until < 24*time.Hour {
+			b.WriteString(" (in " + formatUntil(until) + ")")
+		}
+	}
+	b.WriteString(" | " + ev.Summary)
+	if ev.Location != "" {
+		b.WriteString(" | " + ev.Location)
+	}
+	return b.String()
+}
+
+func formatUntil(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%d min", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// CalendarListTool lists upcoming events.
+type CalendarListTool struct {
+	client *CalendarClient
+}
+
+// NewCalendarListTool creates a CalendarListTool backed by client.
+func NewCalendarListTool(client *CalendarClient) *CalendarListTool {
+	return &CalendarListTool{client: client}
+}
+
+func (t *CalendarListTool) Name() string {
+	return "calendar_list"
+}
+
+func (t *CalendarListTool) Description() string {
+	zone, _ := time.Now().Zone()
+	return "List calendar events from a start date (default today) for a number of days. " +
+		"Times are shown in the host's time zone (" + zone + ")."
+}
+
+func (t *CalendarListTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"from": map[string]any{
+				"type":        "string",
+				"description": "First day to list, as YYYY-MM-DD (default: today)",
+			},
+			"days": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of days to list (default %d, max %d)", calendarDefaultDays, calendarMaxDays),
+			},
+		},
+	}
+}
+
+func (t *CalendarListTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	now := time.Now()
+	from := now
+	if s, _ := args["from"].(string); s != "" {
+		day, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("invalid from date %q, want YYYY-MM-DD", s))
+		}
+		from = day
+	}
+	days := calendarDefaultDays
+	if n, ok := args["days"].(float64); ok && n > 0 {
+		days = min(int(n), calendarMaxDays)
+	}
+	y, m, d := from.Date()
+	to := time.Date(y, m, d+days, 0, 0, 0, 0, time.Local)
+
+	events, err := t.client.Events(ctx, from, to)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to read calendar: %v", err))
+	}
+	if len(events) == 0 {
+		return SilentResult(fmt.Sprintf("No events in the next %d days.", days))
+	}
+	lines := make([]string, 0, min(len(events), calendarMaxEvents)+1)
+	for _, ev := range events[:min(len(events), calendarMaxEvents)] {
+		lines = append(lines, formatCalendarEvent(ev, now))
+	}
+	if len(events) > calendarMaxEvents {
+		lines = append(lines, fmt.Sprintf("(%d more events not shown)", len(events)-calendarMaxEvents))
+	}
+	return SilentResult(strings.Join(lines, "\n"))
+}
+
+// CalendarCreateTool adds events. Every new event waits for the user's
+// approval (see ApprovalGate).
+type CalendarCreateTool struct {
+	client *CalendarClient
+}
+
+// NewCalendarCreateTool creates a CalendarCreateTool backed by client.
+func NewCalendarCreateTool(client *CalendarClient) *CalendarCreateTool {
+	return &CalendarCreateTool{client: client}
+}
+
+func (t *CalendarCreateTool) Name() string {
+	return "calendar_create"
+}
+
+func (t *CalendarCreateTool) Description() string {
+	zone, _ := time.Now().Zone()
+	return "Create a calendar event. The user must approve it before it is saved. " +
+		"Times without a UTC offset are in the host's time zone (" + zone + ")."
+}
+
+func (t *CalendarCreateTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"summary": map[string]any{
+				"type":        "string",
+				"description": "Event title",
+			},
+			"start": map[string]any{
+				"type":        "string",
+				"description": "Start as YYYY-MM-DD HH:MM or RFC 3339; YYYY-MM-DD for all-day events",
+			},
+			"end": map[string]any{
+				"type":        "string",
+				"description": "End, in the same format as start (default: start plus duration_minutes)",
+			},
+			"duration_minutes": map[string]any{
+				"type":        "integer",
+				"description": "Length of the event when end is not given (default 60)",
+			},
+			"all_day": map[string]any{
+				"type":        "boolean",
+				"description": "Create an all-day event",
+			},
+			"location": map[string]any{
+				"type":        "string",
+				"description": "Where the event takes place",
+			},
+			"description": map[string]any{
+				"type":        "string",
+				"description": "Notes for the event",
+			},
+		},
+		"required": []string{"summary", "start"},
+	}
+}
+
+// NeedsApproval implements ApprovalGate: every new event needs confirmation.
+func (t *CalendarCreateTool) NeedsApproval(map[string]any) bool {
+	return true
+}
+
+func (t *CalendarCreateTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	ev, err := newCalendarEvent(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	uid, err := t.client.Create(ctx, ev)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to create event: %v", err))
+	}
+	return SilentResult(fmt.Sprintf("Created %s (uid %s)", formatCalendarEvent(ev, time.Now()), uid))
+}
+
+var calendarTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+
+func parseCalendarTime(s string) (time.Time, error) {
+	for _, layout := range calendarTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, want YYYY-MM-DD HH:MM", s)
+}
+
+// newCalendarEvent validates calendar_create arguments.
+func newCalendarEvent(args map[string]any) (CalendarEvent, error) {
+	var ev CalendarEvent
+	ev.Summary, _ = args["summary"].(string)
+	ev.Summary = strings.TrimSpace(ev.Summary)
+	if ev.Summary == "" {
+		return ev, errors.New("summary is required")
+	}
+	ev.Location, _ = args["location"].(string)
+	ev.Description, _ = args["description"].(string)
+	ev.AllDay, _ = args["all_day"].(bool)
+	start, _ := args["start"].(string)
+	end, _ := args["end"].(string)
+
+	if ev.AllDay {
+		var err error
+		if ev.Start, err = time.ParseInLocation("2006-01-02", start, time.Local); err != nil {
+			return ev, fmt.Errorf("invalid start date %q, want YYYY-MM-DD", start)
+		}
+		ev.End = ev.Start.AddDate(0, 0, 1)
+		if end != "" {
+			last, err := time.ParseInLocation("2006-01-02", end, time.Local)
+			if err != nil {
+				return ev, fmt.Errorf("invalid end date %q, want YYYY-MM-DD", end)
+			}
+			// end is the last day of the event; DTEND is exclusive.
+			ev.End = last.AddDate(0, 0, 1)
+		}
+	} else {
+		var err error
+		if ev.Start, err = parseCalendarTime(start); err != nil {
+			return ev, err
+		}
+		if end != "" {
+			if ev.End, err = parseCalendarTime(end); err != nil {
+				return ev, err
+			}
+		} else {
+			minutes := 60
+			if n, ok := args["duration_minutes"].(float64); ok && n > 0 {
+				minutes = int(n)
+			}
+			ev.End = ev.Start.Add(time.Duration(minutes) * time.Minute)
+		}
+	}
+	if !ev.End.After(ev.Start) {
+		return ev, errors.New("end must be after start")
+	}
+	return ev, nil
+}
+
+// CalendarReminders feeds upcoming events into heartbeat prompts. Each
+// call covers from the end of the previous window to lead+interval ahead,
+// so every event is mentioned by one heartbeat, at least lead before it
+// starts when the heartbeat interval allows.
+type CalendarReminders struct {
+	client   *CalendarClient
+	lead     time.Duration
+	interval time.Duration
+
+	mu    sync.Mutex
+	until time.Time // end of the last window reported
+}
+
+// NewCalendarReminders creates reminders for heartbeats that run every
+// interval.
+func NewCalendarReminders(client *CalendarClient, lead, interval time.Duration) *CalendarReminders {
+	return &CalendarReminders{client: client, lead: lead, interval: interval}
+}
+
+// Context returns the heartbeat prompt section for events starting soon,
+// or "" when there are none. All-day events are left out.
+func (r *CalendarReminders) Context(now time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	from := now
+	if r.until.After(from) {
+		from = r.until
+	}
+	to := now.Add(r.lead + r.interval)
+	if !to.After(from) {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), calendarTimeout)
+	defer cancel()
+	events, err := r.client.Events(ctx, from, to)
+	if err != nil {
+		logger.WarnCF("heartbeat", "Failed to read calendar", map[string]any{"error": err.Error()})
+		return ""
+	}
+	r.until = to
+
+	var lines []string
+	for _, ev := range events {
+		// Events overlapping the window but started earlier were already due.
+		if ev.AllDay || ev.Start.Before(from) {
+			continue
+		}
+		lines = append(lines, "- "+formatCalendarEvent(ev, now))
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "## Upcoming calendar events\n\n" +
+		"Remind the user about these events, saying how soon each one starts:\n\n" +
+		strings.Join(lines, "\n")
+}
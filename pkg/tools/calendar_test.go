@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const icsTime = "20060102T150405Z"
+
+// fakeCalDAV serves a fixed set of iCalendar objects and records PUTs.
+func fakeCalDAV(t *testing.T, objects map[string]string) (*httptest.Server, <-chan string) {
+	t.Helper()
+	puts := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "REPORT":
+			var b strings.Builder
+			b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+				`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">`)
+			for name, data := range objects {
+				fmt.Fprintf(&b, `<d:response><d:href>%s%s</d:href><d:propstat><d:prop>`+
+					`<c:calendar-data>%s</c:calendar-data></d:prop>`+
+					`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, r.URL.Path, name, data)
+			}
+			b.WriteString(`</d:multistatus>`)
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, b.String())
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			puts <- r.URL.Path + "\n" + string(body)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, puts
+}
+
+func icsEvent(uid, summary string, start time.Time, extra string) string {
+	return "BEGIN:VCALENDAR\nVERSION:2.0\nPRODID:test\nBEGIN:VEVENT\nUID:" + uid +
+		"\nDTSTAMP:" + start.UTC().Format(icsTime) +
+		"\nDTSTART:" + start.UTC().Format(icsTime) +
+		"\nDTEND:" + start.Add(30*time.Minute).UTC().Format(icsTime) +
+		"\nSUMMARY:" + summary + "\n" + extra + "END:VEVENT\nEND:VCALENDAR\n"
+}
+
+func newTestCalendarClient(t *testing.T, srv *httptest.Server) *CalendarClient {
+	t.Helper()
+	client, err := NewCalendarClient(config.CalendarConfig{
+		URL:      srv.URL + "/dav/calendars/me/personal/",
+		Username: "me",
+		Password: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestCalendarClient_EventsExpandsRecurrences(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	srv, _ := fakeCalDAV(t, map[string]string{
+		"dentist.ics": icsEvent("a", "Dentist", now.Add(3*time.Hour), ""),
+		"standup.ics": icsEvent("b", "Standup", now.Add(time.Hour).AddDate(0, 0, -7), "RRULE:FREQ=DAILY\n"),
+		"old.ics":     icsEvent("c", "Old", now.AddDate(0, -1, 0), ""),
+	})
+
+	events, err := newTestCalendarClient(t, srv).Events(context.Background(), now, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Summary+"@"+ev.Start.Sub(now).String())
+	}
+	// The daily standup repeats once inside the window, at +1h.
+	if want := "Standup@1h0m0s Dentist@3h0m0s"; strings.Join(got, " ") != want {
+		t.Errorf("events = %v, want %s", got, want)
+	}
+}
+
+func TestCalendarCreateTool(t *testing.T) {
+	srv, puts := fakeCalDAV(t, nil)
+	tool := NewCalendarCreateTool(newTestCalendarClient(t, srv))
+
+	r := NewToolRegistry()
+	store := NewApprovalStore(filepath.Join(t.TempDir(), "pending.json"), 0)
+	r.RequireApproval(store, nil)
+	r.Register(tool)
+
+	args := map[string]any{"summary": "Lunch with Ann", "start": "2031-05-02T12:30:00Z", "location": "Cafe"}
+	held := r.ExecuteWithContext(context.Background(), "calendar_create", args, "telegram", "1", nil)
+	if pending, _ := store.List("telegram", "1"); len(pending) != 1 || !strings.Contains(held.ForLLM, "/approve") {
+		t.Fatalf("create was not held for approval: %+v", held)
+	}
+
+	result := r.ExecuteWithContext(WithApproval(context.Background()), "calendar_create", args, "telegram", "1", nil)
+	if result.IsError {
+		t.Fatalf("create: %s", result.ForLLM)
+	}
+	put := <-puts
+	for _, want := range []string{
+		"/dav/calendars/me/personal/",
+		"SUMMARY:Lunch with Ann",
+		"DTSTART:20310502T123000Z",
+		"DTEND:20310502T133000Z",
+		"LOCATION:Cafe",
+	} {
+		if !strings.Contains(put, want) {
+			t.Errorf("PUT missing %q:\n%s", want, put)
+		}
+	}
+}
+
+func TestNewCalendarEvent_Validation(t *testing.T) {
+	for _, args := range []map[string]any{
+		{"start": "2031-05-02 12:30"},
+		{"summary": "x", "start": "tomorrow"},
+		{"summary": "x", "start": "2031-05-02 12:30", "end": "2031-05-02 12:00"},
+		{"summary": "x", "start": "2031-05-02 12:30", "all_day": true},
+	} {
+		if _, err := newCalendarEvent(args); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+
+	trip := map[string]any{"summary": "Trip", "start": "2031-05-02", "end": "2031-05-04", "all_day": true}
+	ev, err := newCalendarEvent(trip)
+	if err != nil || ev.End.Sub(ev.Start) != 72*time.Hour {
+		t.Errorf("all-day event = %+v, %v", ev, err)
+	}
+}
+
+func TestCalendarReminders_MentionEachEventOnce(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	srv, _ := fakeCalDAV(t, map[string]string{
+		"review.ics": icsEvent("a", "Design review", now.Add(20*time.Minute), ""),
+		"later.ics":  icsEvent("b", "Dinner", now.Add(5*time.Hour), ""),
+	})
+	reminders := NewCalendarReminders(newTestCalendarClient(t, srv), 15*time.Minute, 30*time.Minute)
+
+	first := reminders.Context(now)
+	if !strings.Contains(first, "Design review") || !strings.Contains(first, "(in 20 min)") ||
+		strings.Contains(first, "Dinner") {
+		t.Errorf("first heartbeat = %q", first)
+	}
+	if second := reminders.Context(now.Add(30 * time.Minute)); second != "" {
+		t.Errorf("second heartbeat repeated events: %q", second)
+	}
+}
@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// containerRuntimes are the CLIs container_exec looks for, in order of
+// preference, when no runtime is configured.
+var containerRuntimes = []string{"docker", "podman"}
+
+// containerMaxOutput caps the output returned from one container run.
+const containerMaxOutput = 10000
+
+func init() {
+	RegisterFactory("container_exec", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("container_exec") {
+			return nil, nil
+		}
+		tool, err := NewContainerExecTool(env.Config.Tools.ContainerExec, env.Workspace)
+		if errors.Is(err, ErrNoContainerRuntime) {
+			// Enabled on a host without Docker/Podman: leave the tool out
+			// rather than offering the model something that always fails.
+			logger.WarnCF("tools", "container_exec is enabled but no container runtime was found",
+				map[string]any{"agent_id": env.AgentID, "runtime": env.Config.Tools.ContainerExec.Runtime})
+			return nil, nil
+		}
+		return tool, err
+	})
+}
+
+// ErrNoContainerRuntime is returned by NewContainerExecTool when neither the
+// configured runtime nor any of the known ones is installed.
+var ErrNoContainerRuntime = errors.New("no container runtime (docker or podman) found in PATH")
+
+// ContainerExecTool runs shell commands in a disposable container: the
+// container is removed after each call, has no network unless configured,
+// drops all capabilities and is limited in memory and process count.
+type ContainerExecTool struct {
+	runtime string // path to the docker/podman CLI
+	image   string
+	network bool
+	mounts  []string // validated host:container[:ro] specs
+	timeout time.Duration
+	memory  int // MiB; 0 means no limit
+}
+
+// NewContainerExecTool creates the tool from cfg. Relative host paths in
+// cfg.Mounts are resolved against workspace.
+func NewContainerExecTool(cfg config.ContainerConfig, workspace string) (*ContainerExecTool, error) {
+	runtimePath, err := findContainerRuntime(cfg.Runtime)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Image == "" {
+		return nil, errors.New("container_exec: image is required")
+	}
+
+	mounts := make([]string, 0, len(cfg.Mounts))
+	for _, m := range cfg.Mounts {
+		spec, err := parseContainerMount(m, workspace)
+		if err != nil {
+			return nil, fmt.Errorf("container_exec: %w", err)
+		}
+		mounts = append(mounts, spec)
+	}
+
+	timeout := 60 * time.Second
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+
+	return &ContainerExecTool{
+		runtime: runtimePath,
+		image:   cfg.Image,
+		network: cfg.Network,
+		mounts:  mounts,
+		timeout: timeout,
+		memory:  cfg.MemoryMB,
+	}, nil
+}
+
+// findContainerRuntime resolves the configured runtime, or the first known
+// one installed when name is empty.
+func findContainerRuntime(name string) (string, error) {
+	candidates := containerRuntimes
+	if name != "" {
+		candidates = []string{name}
+	}
+	for _, c := range candidates {
+		if p, err := exec.LookPath(c); err == nil {
+			return p, nil
+		}
+	}
+	return "", ErrNoContainerRuntime
+}
+
+// parseContainerMount validates a "host:container[:ro|rw]" mount spec and
+// makes its host path absolute.
+func parseContainerMount(spec, workspace string) (string, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid mount %q (want host:container[:ro])", spec)
+	}
+	if len(parts) == 3 && parts[2] != "ro" && parts[2] != "rw" {
+		return "", fmt.Errorf("invalid mount mode %q in %q (want ro or rw)", parts[2], spec)
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return "", fmt.Errorf("container path in mount %q must be absolute", spec)
+	}
+	host := parts[0]
+	if !filepath.IsAbs(host) {
+		host = filepath.Join(workspace, host)
+	}
+	parts[0] = filepath.Clean(host)
+	return strings.Join(parts, ":"), nil
+}
+
+func (t *ContainerExecTool) Name() string {
+	return "container_exec"
+}
+
+func (t *ContainerExecTool) Description() string {
+	desc := fmt.Sprintf("Run a shell command in a fresh, disposable %s container and return its output. "+
+		"Use this to execute untrusted or generated code safely; nothing persists between calls", t.image)
+	if len(t.mounts) > 0 {
+		desc += " except in the mounted directories"
+	}
+	desc += "."
+	if !t.network {
+		desc += " The container has no network access."
+	}
+	return desc
+}
+
+func (t *ContainerExecTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"command": map[string]any{
+				"type":        "string",
+				"description": "Shell command to run inside the container (via sh -c)",
+			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "Run as a background job; the output is delivered when the command finishes",
+			},
+		},
+		"required": []string{"command"},
+	}
+}
+
+// RunsAsJob implements JobTool for calls made with background=true.
+func (t *ContainerExecTool) RunsAsJob(args map[string]any) bool {
+	background, _ := args["background"].(bool)
+	return background
+}
+
+func (t *ContainerExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	command, _ := args["command"].(string)
+	if strings.TrimSpace(command) == "" {
+		return ErrorResult("command is required")
+	}
+
+	name := "picoclaw-" + newShortID()
+	runCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, t.runtime, t.runArgs(name, command)...)
+	output := &cappedBuffer{limit: containerMaxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+
+	if runCtx.Err() != nil {
+		// Killing the CLI leaves the container running; remove it.
+		rmCtx, rmCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer rmCancel()
+		_ = exec.CommandContext(rmCtx, t.runtime, "rm", "-f", name).Run()
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return ErrorResult(fmt.Sprintf("Command timed out after %v", t.timeout))
+		}
+		return ErrorResult(fmt.Sprintf("Command cancelled: %v", runCtx.Err()))
+	}
+
+	out := output.String()
+	if output.dropped > 0 {
+		out += fmt.Sprintf("\n... (truncated, %d more chars)", output.dropped)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		if out == "" {
+			out = "(no output)"
+		}
+		return &ToolResult{ForLLM: out, ForUser: out}
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 125:
+		// 125 is the runtime's own failure (daemon down, image missing, ...).
+		return ErrorResult(fmt.Sprintf("container runtime failed: %s", strings.TrimSpace(out)))
+	case errors.As(err, &exitErr):
+		out += fmt.Sprintf("\nExit code: %d", exitErr.ExitCode())
+		return &ToolResult{ForLLM: out, ForUser: out, IsError: true}
+	default:
+		return ErrorResult(fmt.Sprintf("failed to start container: %v", err))
+	}
+}
+
+// runArgs builds the "run" invocation for one command.
+func (t *ContainerExecTool) runArgs(name, command string) []string {
+	args := []string{
+		"run", "--rm",
+		"--name", name,
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--pids-limit", "256",
+	}
+	if !t.network {
+		args = append(args, "--network", "none")
+	}
+	if t.memory > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%dm", t.memory))
+	}
+	for _, m := range t.mounts {
+		args = append(args, "-v", m)
+	}
+	return append(args, t.image, "sh", "-c", command)
+}
@@ -0,0 +1,96 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeContainerRuntime installs a "docker" script that prints its arguments
+// and then runs script, and puts it first in PATH.
+func fakeContainerRuntime(t *testing.T, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	body := "#!/bin/sh\necho \"args: $*\"\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestContainerExecTool_RunsIsolated(t *testing.T) {
+	fakeContainerRuntime(t, "exit 0")
+	workspace := t.TempDir()
+
+	tool, err := NewContainerExecTool(config.ContainerConfig{
+		Image:    "alpine:3",
+		Mounts:   []string{"data:/data:ro"},
+		MemoryMB: 128,
+	}, workspace)
+	if err != nil {
+		t.Fatalf("NewContainerExecTool: %v", err)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"command": "echo hi"})
+	if result.IsError {
+		t.Fatalf("Execute: %s", result.ForLLM)
+	}
+	for _, want := range []string{
+		"run --rm --name picoclaw-",
+		"--cap-drop ALL",
+		"--network none",
+		"--memory 128m",
+		"-v " + filepath.Join(workspace, "data") + ":/data:ro",
+		"alpine:3 sh -c echo hi",
+	} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("runtime args %q missing %q", result.ForLLM, want)
+		}
+	}
+}
+
+func TestContainerExecTool_Failures(t *testing.T) {
+	fakeContainerRuntime(t, "echo 'Cannot connect to the Docker daemon' >&2; exit 125")
+	tool, err := NewContainerExecTool(config.ContainerConfig{Image: "alpine:3", Network: true}, "")
+	if err != nil {
+		t.Fatalf("NewContainerExecTool: %v", err)
+	}
+	result := tool.Execute(context.Background(), map[string]any{"command": "true"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "container runtime failed: ") ||
+		!strings.Contains(result.ForLLM, "Cannot connect") {
+		t.Errorf("daemon failure result = %+v", result)
+	}
+	if strings.Contains(result.ForLLM, "--network none") {
+		t.Errorf("network was disabled despite the config: %s", result.ForLLM)
+	}
+
+	fakeContainerRuntime(t, "exit 3")
+	tool, _ = NewContainerExecTool(config.ContainerConfig{Image: "alpine:3"}, "")
+	if result := tool.Execute(context.Background(), map[string]any{"command": "false"}); !result.IsError ||
+		!strings.HasSuffix(result.ForLLM, "Exit code: 3") {
+		t.Errorf("command failure result = %+v", result)
+	}
+}
+
+func TestNewContainerExecTool_Validation(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := NewContainerExecTool(config.ContainerConfig{Image: "alpine:3"}, ""); !errors.Is(
+		err, ErrNoContainerRuntime) {
+		t.Errorf("without a runtime: err = %v, want ErrNoContainerRuntime", err)
+	}
+
+	fakeContainerRuntime(t, "")
+	for _, mount := range []string{"/src", "/src:data", "/src:/data:rx", ":/data"} {
+		cfg := config.ContainerConfig{Image: "alpine:3", Mounts: []string{mount}}
+		if _, err := NewContainerExecTool(cfg, ""); err == nil {
+			t.Errorf("mount %q was accepted", mount)
+		}
+	}
+}
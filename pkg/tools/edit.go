@@ -12,7 +12,8 @@ import (
 // EditFileTool edits a file by replacing old_text with new_text.
 // The old_text must exist exactly in the file.
 type EditFileTool struct {
-	fs fileSystem
+	fs       fileSystem
+	maxBytes int
 }
 
 // NewEditFileTool creates a new EditFileTool with optional directory restriction.
@@ -21,7 +22,14 @@ func NewEditFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.Re
 	if len(allowPaths) > 0 {
 		patterns = allowPaths[0]
 	}
-	return &EditFileTool{fs: buildFs(workspace, restrict, patterns)}
+	return &EditFileTool{fs: buildFs(workspace, restrict, patterns), maxBytes: DefaultMaxFileBytes}
+}
+
+// SetMaxFileBytes sets the largest file the tool edits. Values <= 0 are ignored.
+func (t *EditFileTool) SetMaxFileBytes(n int) {
+	if n > 0 {
+		t.maxBytes = n
+	}
 }
 
 func (t *EditFileTool) Name() string {
@@ -29,7 +37,8 @@ func (t *EditFileTool) Name() string {
 }
 
 func (t *EditFileTool) Description() string {
-	return "Edit a file by replacing old_text with new_text. The old_text must exist exactly in the file."
+	return "Edit a file by replacing old_text with new_text. The old_text must exist exactly in the file. " +
+		"Returns a diff of the change."
 }
 
 func (t *EditFileTool) Parameters() map[string]any {
@@ -69,14 +78,16 @@ func (t *EditFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("new_text is required")
 	}
 
-	if err := editFile(t.fs, path, oldText, newText); err != nil {
+	diff, err := editFile(t.fs, path, oldText, newText, t.maxBytes)
+	if err != nil {
 		return ErrorResult(err.Error())
 	}
-	return SilentResult(fmt.Sprintf("File edited: %s", path))
+	return SilentResult(fmt.Sprintf("File edited: %s\n%s", path, diff))
 }
 
 type AppendFileTool struct {
-	fs fileSystem
+	fs       fileSystem
+	maxBytes int
 }
 
 func NewAppendFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *AppendFileTool {
@@ -84,7 +95,14 @@ func NewAppendFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.
 	if len(allowPaths) > 0 {
 		patterns = allowPaths[0]
 	}
-	return &AppendFileTool{fs: buildFs(workspace, restrict, patterns)}
+	return &AppendFileTool{fs: buildFs(workspace, restrict, patterns), maxBytes: DefaultMaxFileBytes}
+}
+
+// SetMaxFileBytes sets the largest file the tool appends to. Values <= 0 are ignored.
+func (t *AppendFileTool) SetMaxFileBytes(n int) {
+	if n > 0 {
+		t.maxBytes = n
+	}
 }
 
 func (t *AppendFileTool) Name() string {
@@ -123,39 +141,86 @@ func (t *AppendFileTool) Execute(ctx context.Context, args map[string]any) *Tool
 		return ErrorResult("content is required")
 	}
 
-	if err := appendFile(t.fs, path, content); err != nil {
+	if err := appendFile(t.fs, path, content, t.maxBytes); err != nil {
 		return ErrorResult(err.Error())
 	}
 	return SilentResult(fmt.Sprintf("Appended to %s", path))
 }
 
-// editFile reads the file via sysFs, performs the replacement, and writes back.
+// editFile reads the file via sysFs, performs the replacement, and writes back,
+// returning a diff of the change.
 // It uses a fileSystem interface, allowing the same logic for both restricted and unrestricted modes.
-func editFile(sysFs fileSystem, path, oldText, newText string) error {
-	content, err := sysFs.ReadFile(path)
+func editFile(sysFs fileSystem, path, oldText, newText string, maxBytes int) (string, error) {
+	content, err := readFileLimited(sysFs, path, maxBytes)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	newContent, err := replaceEditContent(content, oldText, newText)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if err := checkWriteSize(len(newContent), maxBytes); err != nil {
+		return "", err
 	}
 
-	return sysFs.WriteFile(path, newContent)
+	if err := sysFs.WriteFile(path, newContent); err != nil {
+		return "", err
+	}
+	return lineDiff(string(content), string(newContent)), nil
 }
 
 // appendFile reads the existing content (if any) via sysFs, appends new content, and writes back.
-func appendFile(sysFs fileSystem, path, appendContent string) error {
-	content, err := sysFs.ReadFile(path)
+func appendFile(sysFs fileSystem, path, appendContent string, maxBytes int) error {
+	content, err := readFileLimited(sysFs, path, maxBytes)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
 
 	newContent := append(content, []byte(appendContent)...)
+	if err := checkWriteSize(len(newContent), maxBytes); err != nil {
+		return err
+	}
 	return sysFs.WriteFile(path, newContent)
 }
 
+// maxDiffLines caps the number of changed lines lineDiff shows per side.
+const maxDiffLines = 40
+
+// lineDiff renders the single changed region between before and after as a
+// unified-diff hunk, so the model can check an edit without re-reading the file.
+func lineDiff(before, after string) string {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	removed := a[prefix : len(a)-suffix]
+	added := b[prefix : len(b)-suffix]
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", prefix+1, len(removed), prefix+1, len(added))
+	writeDiffLines(&sb, "-", removed)
+	writeDiffLines(&sb, "+", added)
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func writeDiffLines(sb *strings.Builder, marker string, lines []string) {
+	for i, line := range lines {
+		if i == maxDiffLines {
+			fmt.Fprintf(sb, "%s... (%d more lines)\n", marker, len(lines)-i)
+			return
+		}
+		sb.WriteString(marker + line + "\n")
+	}
+}
+
 // replaceEditContent handles the core logic of finding and replacing a single occurrence of oldText.
 func replaceEditContent(content []byte, oldText, newText string) ([]byte, error) {
 	contentStr := string(content)
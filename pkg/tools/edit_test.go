@@ -435,3 +435,43 @@ func TestEditFileTool_Restricted_FileNotFound(t *testing.T) {
 	assert.True(t, result.IsError)
 	assert.Contains(t, result.ForLLM, "not found")
 }
+
+// TestEditFileTool_ReturnsDiff verifies that a successful edit reports the
+// changed lines as a diff hunk.
+func TestEditFileTool_ReturnsDiff(t *testing.T) {
+	workspace := t.TempDir()
+	content := "# Tasks\n- [ ] water plants\n- [ ] backup\n"
+	err := os.WriteFile(filepath.Join(workspace, "HEARTBEAT.md"), []byte(content), 0o644)
+	assert.NoError(t, err)
+
+	tool := NewEditFileTool(workspace, true)
+	result := tool.Execute(context.Background(), map[string]any{
+		"path":     "HEARTBEAT.md",
+		"old_text": "- [ ] water plants",
+		"new_text": "- [x] water plants",
+	})
+	assert.False(t, result.IsError, "Expected success, got: %s", result.ForLLM)
+	assert.Contains(t, result.ForLLM, "@@ -2,1 +2,1 @@\n-- [ ] water plants\n+- [x] water plants")
+}
+
+// TestAppendFileTool_MaxFileBytes verifies that appends which would grow a
+// file past the size limit are rejected and leave the file unchanged.
+func TestAppendFileTool_MaxFileBytes(t *testing.T) {
+	workspace := t.TempDir()
+	err := os.WriteFile(filepath.Join(workspace, "notes.md"), []byte("0123456789"), 0o644)
+	assert.NoError(t, err)
+
+	tool := NewAppendFileTool(workspace, true)
+	tool.SetMaxFileBytes(15)
+	result := tool.Execute(context.Background(), map[string]any{"path": "notes.md", "content": "abcdef"})
+	assert.True(t, result.IsError)
+
+	data, err := os.ReadFile(filepath.Join(workspace, "notes.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func TestLineDiff(t *testing.T) {
+	assert.Equal(t, "@@ -2,0 +2,1 @@\n+b", lineDiff("a\nc", "a\nb\nc"))
+	assert.Equal(t, "@@ -1,2 +1,1 @@\n-a\n-b\n+x", lineDiff("a\nb\nc", "x\nc"))
+}
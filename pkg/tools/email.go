@@ -0,0 +1,613 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	imapclient "github.com/emersion/go-imap/client"
+	_ "github.com/emersion/go-message/charset" // decode non-UTF-8 messages
+	gomail "github.com/emersion/go-message/mail"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	emailTimeout      = 30 * time.Second
+	emailDefaultLimit = 10
+	emailMaxLimit     = 50
+	emailMaxBodyChars = 8000
+)
+
+func init() {
+	RegisterFactory("email_send", func(env ToolEnv) (Tool, error) {
+		cfg := env.Config.Tools.Email
+		if !env.Config.Tools.IsToolEnabled("email") || len(cfg.Accounts) == 0 {
+			return nil, nil
+		}
+		return NewEmailSendTool(cfg.Accounts), nil
+	})
+	RegisterFactory("email_check", func(env ToolEnv) (Tool, error) {
+		cfg := env.Config.Tools.Email
+		if !env.Config.Tools.IsToolEnabled("email") || len(cfg.Accounts) == 0 {
+			return nil, nil
+		}
+		return NewEmailCheckTool(cfg.Accounts), nil
+	})
+}
+
+// emailAccounts is the account list shared by the email tools.
+type emailAccounts struct {
+	accounts  []config.EmailAccountConfig
+	tlsConfig *tls.Config // nil uses the system roots; set by tests
+}
+
+func (a emailAccounts) names() []string {
+	names := make([]string, 0, len(a.accounts))
+	for _, acc := range a.accounts {
+		names = append(names, acc.Name)
+	}
+	return names
+}
+
+// pick returns the account named by args["account"], or the first one.
+func (a emailAccounts) pick(args map[string]any) (config.EmailAccountConfig, error) {
+	name, _ := args["account"].(string)
+	if name == "" {
+		return a.accounts[0], nil
+	}
+	for _, acc := range a.accounts {
+		if acc.Name == name || acc.Address == name {
+			return acc, nil
+		}
+	}
+	return config.EmailAccountConfig{}, fmt.Errorf("unknown email account %q (available: %s)",
+		name, strings.Join(a.names(), ", "))
+}
+
+func (a emailAccounts) tlsFor(host string) *tls.Config {
+	if a.tlsConfig != nil {
+		c := a.tlsConfig.Clone()
+		c.ServerName = host
+		return c
+	}
+	return &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+}
+
+func emailUsername(acc config.EmailAccountConfig) string {
+	if acc.Username != "" {
+		return acc.Username
+	}
+	return acc.Address
+}
+
+// EmailSendTool sends plain-text mail over SMTP. Every send waits for the
+// user's approval (see ApprovalGate), so the agent can draft freely but
+// nothing leaves without a confirmation.
+type EmailSendTool struct {
+	emailAccounts
+}
+
+// NewEmailSendTool creates an EmailSendTool for accounts; the first is the
+// default.
+func NewEmailSendTool(accounts []config.EmailAccountConfig) *EmailSendTool {
+	return &EmailSendTool{emailAccounts{accounts: accounts}}
+}
+
+func (t *EmailSendTool) Name() string {
+	return "email_send"
+}
+
+func (t *EmailSendTool) Description() string {
+	return "Send a plain-text email from one of these accounts: " + strings.Join(t.names(), ", ") + ". " +
+		"The user must approve every email before it is sent, so show them the draft first. " +
+		"To reply, pass the original Message-ID as in_reply_to and prefix the subject with \"Re: \"."
+}
+
+func (t *EmailSendTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"account": map[string]any{
+				"type":        "string",
+				"description": "Account to send from (default: " + t.accounts[0].Name + ")",
+			},
+			"to": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Recipient addresses",
+			},
+			"cc": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Carbon-copy addresses",
+			},
+			"subject": map[string]any{
+				"type":        "string",
+				"description": "Subject line",
+			},
+			"body": map[string]any{
+				"type":        "string",
+				"description": "Plain-text message body",
+			},
+			"in_reply_to": map[string]any{
+				"type":        "string",
+				"description": "Message-ID of the email being answered, from email_check",
+			},
+		},
+		"required": []string{"to", "subject", "body"},
+	}
+}
+
+// NeedsApproval implements ApprovalGate: every email needs confirmation.
+func (t *EmailSendTool) NeedsApproval(map[string]any) bool {
+	return true
+}
+
+func (t *EmailSendTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	acc, err := t.pick(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	msg, err := newOutgoingEmail(acc.Address, args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	if err := t.send(ctx, acc, msg); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to send email: %v", err))
+	}
+	return SilentResult(fmt.Sprintf("Email %q sent from %s to %s", msg.subject, acc.Address,
+		strings.Join(append(msg.to, msg.cc...), ", ")))
+}
+
+// outgoingEmail is a validated message ready to be sent.
+type outgoingEmail struct {
+	from      string
+	to, cc    []string
+	subject   string
+	body      string
+	inReplyTo string
+}
+
+func newOutgoingEmail(from string, args map[string]any) (*outgoingEmail, error) {
+	msg := &outgoingEmail{from: from}
+	var err error
+	if msg.to, err = emailAddressList(args["to"]); err != nil {
+		return nil, fmt.Errorf("to: %w", err)
+	}
+	if len(msg.to) == 0 {
+		return nil, errors.New("at least one recipient is required")
+	}
+	if msg.cc, err = emailAddressList(args["cc"]); err != nil {
+		return nil, fmt.Errorf("cc: %w", err)
+	}
+
+	msg.subject, _ = args["subject"].(string)
+	msg.body, _ = args["body"].(string)
+	msg.inReplyTo, _ = args["in_reply_to"].(string)
+	if strings.ContainsAny(msg.subject+msg.inReplyTo, "\r\n") {
+		return nil, errors.New("subject and in_reply_to must be a single line")
+	}
+	if strings.TrimSpace(msg.body) == "" {
+		return nil, errors.New("body is required")
+	}
+	return msg, nil
+}
+
+// emailAddressList parses a list of addresses, accepting a single string too.
+func emailAddressList(v any) ([]string, error) {
+	var raw []string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		raw = strings.Split(v, ",")
+	case []any:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid address %v", item)
+			}
+			raw = append(raw, s)
+		}
+	default:
+		return nil, fmt.Errorf("expected a list of addresses, got %T", v)
+	}
+
+	var out []string
+	for _, s := range raw {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %v", s, err)
+		}
+		out = append(out, addr.Address)
+	}
+	return out, nil
+}
+
+// render formats the message as RFC 5322 text with a quoted-printable body.
+func (m *outgoingEmail) render(now time.Time) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+
+	header("From", m.from)
+	header("To", strings.Join(m.to, ", "))
+	if len(m.cc) > 0 {
+		header("Cc", strings.Join(m.cc, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("utf-8", m.subject))
+	header("Date", now.Format(time.RFC1123Z))
+	domain := "localhost"
+	if i := strings.LastIndex(m.from, "@"); i >= 0 {
+		domain = m.from[i+1:]
+	}
+	header("Message-ID", fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), newShortID(), domain))
+	if m.inReplyTo != "" {
+		header("In-Reply-To", m.inReplyTo)
+		header("References", m.inReplyTo)
+	}
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&b)
+	qp.Write([]byte(strings.ReplaceAll(m.body, "\n", "\r\n")))
+	qp.Close()
+	return b.Bytes()
+}
+
+func (t *EmailSendTool) send(ctx context.Context, acc config.EmailAccountConfig, msg *outgoingEmail) error {
+	if acc.SMTPHost == "" {
+		return fmt.Errorf("account %q has no smtp_host", acc.Name)
+	}
+	port := acc.SMTPPort
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(acc.SMTPHost, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: emailTimeout}
+	var conn net.Conn
+	var err error
+	if port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: t.tlsFor(acc.SMTPHost)}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(2 * emailTimeout))
+
+	c, err := smtp.NewClient(conn, acc.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(t.tlsFor(acc.SMTPHost)); err != nil {
+				return err
+			}
+		}
+	}
+	if acc.Password != "" {
+		// PlainAuth refuses to send credentials without TLS, except to localhost.
+		if err := c.Auth(smtp.PlainAuth("", emailUsername(acc), acc.Password, acc.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(msg.from); err != nil {
+		return err
+	}
+	for _, rcpt := range append(msg.to, msg.cc...) {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.render(time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// EmailCheckTool reads mail over IMAP without changing it: messages are
+// fetched with BODY.PEEK, so they stay unread.
+type EmailCheckTool struct {
+	emailAccounts
+}
+
+// NewEmailCheckTool creates an EmailCheckTool for accounts; the first is the
+// default.
+func NewEmailCheckTool(accounts []config.EmailAccountConfig) *EmailCheckTool {
+	return &EmailCheckTool{emailAccounts{accounts: accounts}}
+}
+
+func (t *EmailCheckTool) Name() string {
+	return "email_check"
+}
+
+func (t *EmailCheckTool) Description() string {
+	return "Check the inbox of one of these email accounts: " + strings.Join(t.names(), ", ") + ". " +
+		"Without uid, lists recent messages (uid, date, sender, subject), unread only by default. " +
+		"With uid, returns that message's headers and text. Messages are not marked as read."
+}
+
+func (t *EmailCheckTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"account": map[string]any{
+				"type":        "string",
+				"description": "Account to check (default: " + t.accounts[0].Name + ")",
+			},
+			"folder": map[string]any{
+				"type":        "string",
+				"description": "Mailbox folder (default: INBOX)",
+			},
+			"unread_only": map[string]any{
+				"type":        "boolean",
+				"description": "List only unread messages (default: true)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum messages to list, newest first (default %d)", emailDefaultLimit),
+			},
+			"uid": map[string]any{
+				"type":        "integer",
+				"description": "UID of a message to read in full",
+			},
+		},
+	}
+}
+
+func (t *EmailCheckTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	acc, err := t.pick(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	folder, _ := args["folder"].(string)
+	if folder == "" {
+		folder = "INBOX"
+	}
+
+	c, err := t.connect(ctx, acc)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to connect to %s: %v", acc.IMAPHost, err))
+	}
+	defer c.Logout()
+
+	if _, err := c.Select(folder, true); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to open %s: %v", folder, err))
+	}
+
+	if uid, ok := args["uid"].(float64); ok && uid > 0 {
+		text, err := readEmail(c, uint32(uid))
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		return SilentResult(text)
+	}
+
+	unread := true
+	if v, ok := args["unread_only"].(bool); ok {
+		unread = v
+	}
+	limit := emailDefaultLimit
+	if n, ok := args["limit"].(float64); ok && n > 0 {
+		limit = min(int(n), emailMaxLimit)
+	}
+	list, err := listEmails(c, unread, limit)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	return SilentResult(list)
+}
+
+func (t *EmailCheckTool) connect(ctx context.Context, acc config.EmailAccountConfig) (*imapclient.Client, error) {
+	if acc.IMAPHost == "" {
+		return nil, fmt.Errorf("account %q has no imap_host", acc.Name)
+	}
+	port := acc.IMAPPort
+	if port == 0 {
+		port = 993
+	}
+	addr := net.JoinHostPort(acc.IMAPHost, strconv.Itoa(port))
+
+	c, err := imapclient.DialWithDialerTLS(&net.Dialer{Timeout: emailTimeout}, addr, t.tlsFor(acc.IMAPHost))
+	if err != nil {
+		return nil, err
+	}
+	c.Timeout = emailTimeout
+	// The client has no context support; close the connection if the call
+	// is cancelled so blocked commands return.
+	stop := context.AfterFunc(ctx, func() { c.Terminate() })
+	go func() {
+		<-c.LoggedOut()
+		stop()
+	}()
+
+	if err := c.Login(emailUsername(acc), acc.Password); err != nil {
+		c.Logout()
+		return nil, err
+	}
+	return c, nil
+}
+
+func listEmails(c *imapclient.Client, unread bool, limit int) (string, error) {
+	criteria := imap.NewSearchCriteria()
+	if unread {
+		criteria.WithoutFlags = []string{imap.SeenFlag}
+	}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return "", fmt.Errorf("search failed: %v", err)
+	}
+	if len(uids) == 0 {
+		if unread {
+			return "No unread messages.", nil
+		}
+		return "No messages.", nil
+	}
+	total := len(uids)
+	if len(uids) > limit {
+		uids = uids[len(uids)-limit:]
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	messages := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, imap.FetchFlags},
+		messages); err != nil {
+		return "", fmt.Errorf("fetch failed: %v", err)
+	}
+
+	var fetched []*imap.Message
+	for m := range messages {
+		fetched = append(fetched, m)
+	}
+	// Newest first.
+	lines := make([]string, 0, len(fetched)+1)
+	for i := len(fetched) - 1; i >= 0; i-- {
+		m := fetched[i]
+		if m.Envelope == nil {
+			continue
+		}
+		unreadMark := ""
+		if !unread && !slices.Contains(m.Flags, imap.SeenFlag) {
+			unreadMark = " [unread]"
+		}
+		lines = append(lines, fmt.Sprintf("uid %d | %s | %s | %s%s", m.Uid, m.Envelope.Date.Format("2006-01-02 15:04"),
+			formatIMAPAddresses(m.Envelope.From), m.Envelope.Subject, unreadMark))
+	}
+	if total > len(fetched) {
+		lines = append(lines, fmt.Sprintf("(%d older messages not shown)", total-len(fetched)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func readEmail(c *imapclient.Client, uid uint32) (string, error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 1)
+	if err := c.UidFetch(seqset, []imap.FetchItem{imap.FetchEnvelope, section.FetchItem()}, messages); err != nil {
+		return "", fmt.Errorf("fetch failed: %v", err)
+	}
+	m := <-messages
+	if m == nil || m.Envelope == nil {
+		return "", fmt.Errorf("no message with uid %d", uid)
+	}
+
+	env := m.Envelope
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\nTo: %s\n", formatIMAPAddresses(env.From), formatIMAPAddresses(env.To))
+	if len(env.Cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\n", formatIMAPAddresses(env.Cc))
+	}
+	fmt.Fprintf(&b, "Date: %s\nSubject: %s\nMessage-ID: %s\n\n", env.Date.Format(time.RFC1123Z), env.Subject,
+		env.MessageId)
+
+	body := m.GetBody(section)
+	if body == nil {
+		return b.String() + "(no body)", nil
+	}
+	text, err := emailText(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message body: %v", err)
+	}
+	b.WriteString(truncateRunes(text, emailMaxBodyChars))
+	return b.String(), nil
+}
+
+var htmlTagRe = regexp.MustCompile(`(?s)<style.*?</style>|<script.*?</script>|<[^>]+>`)
+
+// emailText returns the plain-text part of a message, falling back to its
+// HTML part with the tags stripped.
+func emailText(r io.Reader) (string, error) {
+	mr, err := gomail.CreateReader(r)
+	if err != nil {
+		return "", err
+	}
+	var plain, html string
+	var attachments []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch h := part.Header.(type) {
+		case *gomail.InlineHeader:
+			ct, _, _ := h.ContentType()
+			data, _ := io.ReadAll(io.LimitReader(part.Body, 1<<20))
+			switch {
+			case ct == "text/plain" && plain == "":
+				plain = string(data)
+			case ct == "text/html" && html == "":
+				html = string(data)
+			}
+		case *gomail.AttachmentHeader:
+			name, _ := h.Filename()
+			attachments = append(attachments, name)
+		}
+	}
+
+	text := strings.TrimSpace(plain)
+	if text == "" && html != "" {
+		text = strings.TrimSpace(htmlTagRe.ReplaceAllString(html, " "))
+	}
+	if len(attachments) > 0 {
+		text += "\n\nAttachments: " + strings.Join(attachments, ", ")
+	}
+	return text, nil
+}
+
+func formatIMAPAddresses(addrs []*imap.Address) string {
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if a.PersonalName != "" {
+			out = append(out, fmt.Sprintf("%s <%s>", a.PersonalName, a.Address()))
+		} else {
+			out = append(out, a.Address())
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// truncateRunes cuts s to n runes, noting how much was dropped.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + fmt.Sprintf("\n... (truncated, %d more chars)", len(runes)-n)
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/backend/memory"
+	imapserver "github.com/emersion/go-imap/server"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeSMTPServer accepts one message on 127.0.0.1 without TLS (net/smtp
+// allows PLAIN auth to localhost) and returns what it received.
+func fakeSMTPServer(t *testing.T) (port int, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+		var transcript strings.Builder
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(cmd, "AUTH"):
+				reply("235 ok")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				transcript.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					dl, err := r.ReadString('\n')
+					if err != nil || dl == ".\r\n" {
+						break
+					}
+					transcript.WriteString(dl)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				out <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, out
+}
+
+func TestEmailSendTool_SendsAfterApproval(t *testing.T) {
+	port, received := fakeSMTPServer(t)
+	tool := NewEmailSendTool([]config.EmailAccountConfig{{
+		Name:     "work",
+		Address:  "agent@example.com",
+		Password: "secret",
+		SMTPHost: "127.0.0.1",
+		SMTPPort: port,
+	}})
+
+	r := NewToolRegistry()
+	store := NewApprovalStore(filepath.Join(t.TempDir(), "pending.json"), 0)
+	r.RequireApproval(store, nil)
+	r.Register(tool)
+
+	args := map[string]any{
+		"to":          []any{"Ann <ann@example.org>"},
+		"subject":     "Re: Grüße",
+		"body":        "Thanks!\nSee you.",
+		"in_reply_to": "<abc@example.org>",
+	}
+	held := r.ExecuteWithContext(context.Background(), "email_send", args, "telegram", "1", nil)
+	if pending, _ := store.List("telegram", "1"); len(pending) != 1 || !strings.Contains(held.ForLLM, "/approve") {
+		t.Fatalf("send was not held for approval: %+v", held)
+	}
+
+	result := r.ExecuteWithContext(WithApproval(context.Background()), "email_send", args, "telegram", "1", nil)
+	if result.IsError {
+		t.Fatalf("send: %s", result.ForLLM)
+	}
+	var msg string
+	select {
+	case msg = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server received nothing")
+	}
+	for _, want := range []string{
+		"MAIL FROM:<agent@example.com>",
+		"RCPT TO:<ann@example.org>",
+		"Subject: =?utf-8?q?Re:_Gr=C3=BC=C3=9Fe?=",
+		"In-Reply-To: <abc@example.org>",
+		"Thanks!\r\nSee you.",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestNewOutgoingEmail_Validation(t *testing.T) {
+	for _, args := range []map[string]any{
+		{"to": []any{}, "subject": "s", "body": "b"},
+		{"to": "not an address", "subject": "s", "body": "b"},
+		{"to": "a@example.com", "subject": "s\r\nBcc: x@example.com", "body": "b"},
+		{"to": "a@example.com", "subject": "s", "body": " "},
+	} {
+		if _, err := newOutgoingEmail("me@example.com", args); err == nil {
+			t.Errorf("%v was accepted", args)
+		}
+	}
+}
+
+func TestEmailCheckTool_ListAndRead(t *testing.T) {
+	// Borrow httptest's self-signed certificate for the IMAP server.
+	certSrv := httptest.NewTLSServer(nil)
+	defer certSrv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(certSrv.Certificate())
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certSrv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := imapserver.New(memory.New())
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	tool := NewEmailCheckTool([]config.EmailAccountConfig{{
+		Name:     "home",
+		Address:  "username",
+		Password: "password",
+		IMAPHost: "127.0.0.1",
+		IMAPPort: ln.Addr().(*net.TCPAddr).Port,
+	}})
+	// httptest's certificate covers 127.0.0.1.
+	tool.tlsConfig = &tls.Config{RootCAs: roots}
+
+	ctx := context.Background()
+	// The memory backend's only message is already \Seen.
+	if unread := tool.Execute(ctx, map[string]any{}); unread.ForLLM != "No unread messages." {
+		t.Errorf("unread = %q", unread.ForLLM)
+	}
+	list := tool.Execute(ctx, map[string]any{"unread_only": false})
+	if list.IsError || !strings.Contains(list.ForLLM, "contact@example.org | A little message, just for you") {
+		t.Fatalf("list = %q", list.ForLLM)
+	}
+	uid := strings.Fields(strings.TrimPrefix(list.ForLLM, "uid "))[0]
+	n, _ := strconv.Atoi(uid)
+
+	msg := tool.Execute(ctx, map[string]any{"uid": float64(n)})
+	if msg.IsError || !strings.Contains(msg.ForLLM, "Subject: A little message") ||
+		!strings.HasSuffix(msg.ForLLM, "Hi there :)") {
+		t.Errorf("read = %q", msg.ForLLM)
+	}
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// ToolEnv is what a ToolFactory gets to build a tool for one agent.
+type ToolEnv struct {
+	Config    *config.Config
+	AgentID   string
+	Workspace string
+}
+
+// ToolFactory builds a tool for an agent. It returns a nil tool when the
+// tool is disabled in env.Config or unsupported, so factories can be
+// registered unconditionally. Tools register their factory from init(), and
+// agents pick them up without changes to the agent loop.
+type ToolFactory func(env ToolEnv) (Tool, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]ToolFactory{}
+)
+
+// RegisterFactory registers a named tool factory. Called from init() functions.
+func RegisterFactory(name string, f ToolFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = f
+}
+
+// FactoryNames returns the names of all registered tool factories in sorted order.
+func FactoryNames() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterFromFactories builds a tool from every registered factory and
+// registers the ones that are enabled. Factory errors are logged and the
+// tool is skipped.
+func (r *ToolRegistry) RegisterFromFactories(env ToolEnv) {
+	for _, name := range FactoryNames() {
+		factoriesMu.RLock()
+		f := factories[name]
+		factoriesMu.RUnlock()
+
+		tool, err := f(env)
+		if err != nil {
+			logger.ErrorCF("tools", "Failed to create tool",
+				map[string]any{"tool": name, "agent_id": env.AgentID, "error": err.Error()})
+			continue
+		}
+		if tool != nil {
+			r.Register(tool)
+		}
+	}
+}
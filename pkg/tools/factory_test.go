@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestFactoryNames_IncludesBuiltinFactories(t *testing.T) {
+	names := FactoryNames()
+	for _, want := range []string{"i2c", "spi", "web_fetch"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("FactoryNames() = %v, missing %q", names, want)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Errorf("FactoryNames() = %v, want sorted", names)
+	}
+}
+
+func TestToolRegistry_RegisterFromFactories(t *testing.T) {
+	RegisterFactory("test_factory_enabled", func(env ToolEnv) (Tool, error) {
+		return newMockTool("test_factory_enabled", env.Workspace), nil
+	})
+	RegisterFactory("test_factory_disabled", func(env ToolEnv) (Tool, error) {
+		return nil, nil
+	})
+	RegisterFactory("test_factory_broken", func(env ToolEnv) (Tool, error) {
+		return nil, errors.New("boom")
+	})
+	t.Cleanup(func() {
+		factoriesMu.Lock()
+		defer factoriesMu.Unlock()
+		delete(factories, "test_factory_enabled")
+		delete(factories, "test_factory_disabled")
+		delete(factories, "test_factory_broken")
+	})
+
+	r := NewToolRegistry()
+	r.RegisterFromFactories(ToolEnv{Config: config.DefaultConfig(), Workspace: "/ws"})
+
+	tool, ok := r.Get("test_factory_enabled")
+	if !ok {
+		t.Fatal("enabled factory tool not registered")
+	}
+	if tool.Description() != "/ws" {
+		t.Errorf("factory got workspace %q, want /ws", tool.Description())
+	}
+	for _, name := range []string{"test_factory_disabled", "test_factory_broken"} {
+		if _, ok := r.Get(name); ok {
+			t.Errorf("%s registered, want skipped", name)
+		}
+	}
+}
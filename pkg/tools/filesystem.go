@@ -84,8 +84,13 @@ func isWithinWorkspace(candidate, workspace string) bool {
 	return err == nil && filepath.IsLocal(rel)
 }
 
+// DefaultMaxFileBytes is the largest file the file tools read or write unless
+// configured otherwise (tools.max_file_bytes).
+const DefaultMaxFileBytes = 1 << 20
+
 type ReadFileTool struct {
-	fs fileSystem
+	fs       fileSystem
+	maxBytes int
 }
 
 func NewReadFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *ReadFileTool {
@@ -93,7 +98,14 @@ func NewReadFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.Re
 	if len(allowPaths) > 0 {
 		patterns = allowPaths[0]
 	}
-	return &ReadFileTool{fs: buildFs(workspace, restrict, patterns)}
+	return &ReadFileTool{fs: buildFs(workspace, restrict, patterns), maxBytes: DefaultMaxFileBytes}
+}
+
+// SetMaxFileBytes sets the largest file the tool reads. Values <= 0 are ignored.
+func (t *ReadFileTool) SetMaxFileBytes(n int) {
+	if n > 0 {
+		t.maxBytes = n
+	}
 }
 
 func (t *ReadFileTool) Name() string {
@@ -123,15 +135,33 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("path is required")
 	}
 
-	content, err := t.fs.ReadFile(path)
+	content, err := readFileLimited(t.fs, path, t.maxBytes)
 	if err != nil {
 		return ErrorResult(err.Error())
 	}
 	return NewToolResult(string(content))
 }
 
+// readFileLimited reads a file, refusing files larger than maxBytes before
+// loading them into memory.
+func readFileLimited(sysFs fileSystem, path string, maxBytes int) ([]byte, error) {
+	if info, err := sysFs.Stat(path); err == nil && info.Mode().IsRegular() && info.Size() > int64(maxBytes) {
+		return nil, fmt.Errorf("file is %d bytes, larger than the %d byte limit", info.Size(), maxBytes)
+	}
+	return sysFs.ReadFile(path)
+}
+
+// checkWriteSize rejects writes that would leave a file larger than maxBytes.
+func checkWriteSize(size, maxBytes int) error {
+	if size > maxBytes {
+		return fmt.Errorf("content is %d bytes, larger than the %d byte limit", size, maxBytes)
+	}
+	return nil
+}
+
 type WriteFileTool struct {
-	fs fileSystem
+	fs       fileSystem
+	maxBytes int
 }
 
 func NewWriteFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *WriteFileTool {
@@ -139,7 +169,14 @@ func NewWriteFileTool(workspace string, restrict bool, allowPaths ...[]*regexp.R
 	if len(allowPaths) > 0 {
 		patterns = allowPaths[0]
 	}
-	return &WriteFileTool{fs: buildFs(workspace, restrict, patterns)}
+	return &WriteFileTool{fs: buildFs(workspace, restrict, patterns), maxBytes: DefaultMaxFileBytes}
+}
+
+// SetMaxFileBytes sets the largest file the tool writes. Values <= 0 are ignored.
+func (t *WriteFileTool) SetMaxFileBytes(n int) {
+	if n > 0 {
+		t.maxBytes = n
+	}
 }
 
 func (t *WriteFileTool) Name() string {
@@ -178,6 +215,9 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) *ToolR
 		return ErrorResult("content is required")
 	}
 
+	if err := checkWriteSize(len(content), t.maxBytes); err != nil {
+		return ErrorResult(err.Error())
+	}
 	if err := t.fs.WriteFile(path, []byte(content)); err != nil {
 		return ErrorResult(err.Error())
 	}
@@ -249,6 +289,7 @@ type fileSystem interface {
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, data []byte) error
 	ReadDir(path string) ([]os.DirEntry, error)
+	Stat(path string) (os.FileInfo, error)
 }
 
 // hostFs is an unrestricted fileReadWriter that operates directly on the host filesystem.
@@ -272,6 +313,10 @@ func (h *hostFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return os.ReadDir(path)
 }
 
+func (h *hostFs) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
 func (h *hostFs) WriteFile(path string, data []byte) error {
 	// Use unified atomic write utility with explicit sync for flash storage reliability.
 	// Using 0o600 (owner read/write only) for secure default permissions.
@@ -389,6 +434,19 @@ func (r *sandboxFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return entries, err
 }
 
+func (r *sandboxFs) Stat(path string) (os.FileInfo, error) {
+	var info os.FileInfo
+	err := r.execute(path, func(root *os.Root, relPath string) error {
+		fileInfo, err := root.Stat(relPath)
+		if err != nil {
+			return err
+		}
+		info = fileInfo
+		return nil
+	})
+	return info, err
+}
+
 // whitelistFs wraps a sandboxFs and allows access to specific paths outside
 // the workspace when they match any of the provided patterns.
 type whitelistFs struct {
@@ -427,6 +485,13 @@ func (w *whitelistFs) ReadDir(path string) ([]os.DirEntry, error) {
 	return w.sandbox.ReadDir(path)
 }
 
+func (w *whitelistFs) Stat(path string) (os.FileInfo, error) {
+	if w.matches(path) {
+		return w.host.Stat(path)
+	}
+	return w.sandbox.Stat(path)
+}
+
 // buildFs returns the appropriate fileSystem implementation based on restriction
 // settings and optional path whitelist patterns.
 func buildFs(workspace string, restrict bool, patterns []*regexp.Regexp) fileSystem {
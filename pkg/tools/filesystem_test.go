@@ -520,3 +520,27 @@ func TestWhitelistFs_AllowsMatchingPaths(t *testing.T) {
 		t.Errorf("expected non-whitelisted path to be blocked, got: %s", result.ForLLM)
 	}
 }
+
+// TestFilesystemTool_MaxFileBytes verifies that reads and writes above the
+// configured size limit are rejected.
+func TestFilesystemTool_MaxFileBytes(t *testing.T) {
+	workspace := t.TempDir()
+	err := os.WriteFile(filepath.Join(workspace, "big.txt"), []byte(strings.Repeat("x", 100)), 0o644)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	readTool := NewReadFileTool(workspace, true)
+	readTool.SetMaxFileBytes(50)
+	result := readTool.Execute(ctx, map[string]any{"path": "big.txt"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "larger than the 50 byte limit")
+
+	writeTool := NewWriteFileTool(workspace, true)
+	writeTool.SetMaxFileBytes(50)
+	result = writeTool.Execute(ctx, map[string]any{"path": "new.txt", "content": strings.Repeat("y", 51)})
+	assert.True(t, result.IsError)
+	assert.NoFileExists(t, filepath.Join(workspace, "new.txt"))
+
+	result = writeTool.Execute(ctx, map[string]any{"path": "new.txt", "content": "small"})
+	assert.False(t, result.IsError, "Expected success, got: %s", result.ForLLM)
+}
@@ -0,0 +1,44 @@
+package tools
+
+import "context"
+
+// ToolFunc executes one call of a function-backed tool.
+type ToolFunc func(ctx context.Context, args map[string]any) *ToolResult
+
+// FuncTool is a Tool defined by its name, description, JSON-schema
+// parameters and an execute function, for tools that need no state of their
+// own beyond what the function closes over.
+type FuncTool struct {
+	name        string
+	description string
+	parameters  map[string]any
+	fn          ToolFunc
+}
+
+// NewFuncTool creates a function-backed tool. A nil parameters schema
+// declares a tool that takes no arguments.
+func NewFuncTool(name, description string, parameters map[string]any, fn ToolFunc) *FuncTool {
+	if parameters == nil {
+		parameters = map[string]any{
+			"type":       "object",
+			"properties": map[string]any{},
+		}
+	}
+	return &FuncTool{name: name, description: description, parameters: parameters, fn: fn}
+}
+
+func (t *FuncTool) Name() string {
+	return t.name
+}
+
+func (t *FuncTool) Description() string {
+	return t.description
+}
+
+func (t *FuncTool) Parameters() map[string]any {
+	return t.parameters
+}
+
+func (t *FuncTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	return t.fn(ctx, args)
+}
@@ -0,0 +1,298 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	gitTimeout        = 60 * time.Second
+	gitMaxOutput      = 10000
+	gitDefaultLogSize = 10
+	gitMaxLogSize     = 100
+)
+
+func init() {
+	RegisterFactory("git", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("git") {
+			return nil, nil
+		}
+		tool, err := NewGitTool(env.Workspace, env.Config.Tools.Git.Repos)
+		if errors.Is(err, exec.ErrNotFound) {
+			logger.WarnCF("tools", "git tool is enabled but git is not installed",
+				map[string]any{"agent_id": env.AgentID})
+			return nil, nil
+		}
+		return tool, err
+	})
+}
+
+// GitTool runs a fixed set of git operations in configured repositories.
+// Pushing needs the user's approval (see ApprovalGate); everything else
+// stays local.
+type GitTool struct {
+	git   string   // path to the git binary
+	repos []string // absolute repository paths; the first is the default
+}
+
+// NewGitTool creates a GitTool for repos, resolving relative paths against
+// workspace. With no repos, the workspace itself is used.
+func NewGitTool(workspace string, repos []string) (*GitTool, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		repos = []string{workspace}
+	}
+	abs := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		if repo == "" {
+			continue
+		}
+		if !filepath.IsAbs(repo) {
+			repo = filepath.Join(workspace, repo)
+		}
+		abs = append(abs, filepath.Clean(repo))
+	}
+	if len(abs) == 0 {
+		return nil, errors.New("git: no repositories configured")
+	}
+	return &GitTool{git: gitPath, repos: abs}, nil
+}
+
+func (t *GitTool) Name() string {
+	return "git"
+}
+
+func (t *GitTool) Description() string {
+	return "Run git in one of these repositories: " + strings.Join(t.repos, ", ") + ". " +
+		"Actions: status, diff, log, commit (stages the given paths, or all changes) and push. " +
+		"A push only runs after the user approves it."
+}
+
+func (t *GitTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"status", "diff", "log", "commit", "push"},
+				"description": "Git operation to run",
+			},
+			"repo": map[string]any{
+				"type":        "string",
+				"description": "Repository path or directory name (default: " + t.repos[0] + ")",
+			},
+			"message": map[string]any{
+				"type":        "string",
+				"description": "Commit message (commit)",
+			},
+			"paths": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Paths to limit diff, log or commit to, relative to the repository",
+			},
+			"staged": map[string]any{
+				"type":        "boolean",
+				"description": "Show staged instead of unstaged changes (diff)",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Number of commits to show (log, default %d)", gitDefaultLogSize),
+			},
+			"remote": map[string]any{
+				"type":        "string",
+				"description": "Remote to push to (push, default: the branch's upstream)",
+			},
+			"branch": map[string]any{
+				"type":        "string",
+				"description": "Branch to push (push, default: the current branch)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+// NeedsApproval implements ApprovalGate: pushes leave the machine, so they
+// wait for the user.
+func (t *GitTool) NeedsApproval(args map[string]any) bool {
+	action, _ := args["action"].(string)
+	return action == "push"
+}
+
+func (t *GitTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	repo, err := t.resolveRepo(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	paths, err := gitPaths(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "status":
+		return t.run(ctx, repo, "status", "--short", "--branch")
+	case "diff":
+		gitArgs := []string{"diff", "--stat", "--patch"}
+		if staged, _ := args["staged"].(bool); staged {
+			gitArgs = append(gitArgs, "--cached")
+		}
+		return t.run(ctx, repo, append(gitArgs, withPaths(paths)...)...)
+	case "log":
+		limit := gitDefaultLogSize
+		if n, ok := args["limit"].(float64); ok && n > 0 {
+			limit = min(int(n), gitMaxLogSize)
+		}
+		gitArgs := []string{"log", "-n", strconv.Itoa(limit), "--date=short", "--pretty=format:%h %ad %an: %s"}
+		return t.run(ctx, repo, append(gitArgs, withPaths(paths)...)...)
+	case "commit":
+		return t.commit(ctx, repo, args, paths)
+	case "push":
+		return t.push(ctx, repo, args)
+	case "":
+		return ErrorResult("action is required")
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+func (t *GitTool) commit(ctx context.Context, repo string, args map[string]any, paths []string) *ToolResult {
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return ErrorResult("message is required for commit")
+	}
+
+	addArgs := []string{"add", "--all"}
+	if result := t.run(ctx, repo, append(addArgs, withPaths(paths)...)...); result.IsError {
+		return result
+	}
+
+	var env []string
+	if _, err := t.output(ctx, repo, "config", "user.email"); err != nil {
+		// No identity configured for this repo or user; commit as the agent
+		// rather than failing.
+		env = []string{
+			"GIT_AUTHOR_NAME=picoclaw", "GIT_AUTHOR_EMAIL=picoclaw@localhost",
+			"GIT_COMMITTER_NAME=picoclaw", "GIT_COMMITTER_EMAIL=picoclaw@localhost",
+		}
+	}
+	commitArgs := append([]string{"commit", "-m", message}, withPaths(paths)...)
+	return t.runEnv(ctx, repo, env, commitArgs...)
+}
+
+func (t *GitTool) push(ctx context.Context, repo string, args map[string]any) *ToolResult {
+	remote, _ := args["remote"].(string)
+	branch, _ := args["branch"].(string)
+	if strings.HasPrefix(remote, "-") || strings.HasPrefix(branch, "-") {
+		return ErrorResult("remote and branch must not start with '-'")
+	}
+	if branch != "" && remote == "" {
+		remote = "origin"
+	}
+	pushArgs := []string{"push"}
+	if remote != "" {
+		pushArgs = append(pushArgs, remote)
+	}
+	if branch != "" {
+		pushArgs = append(pushArgs, branch)
+	}
+	return t.run(ctx, repo, pushArgs...)
+}
+
+// resolveRepo picks the repository named by args["repo"], matching either
+// a configured path or its base name.
+func (t *GitTool) resolveRepo(args map[string]any) (string, error) {
+	name, _ := args["repo"].(string)
+	if name == "" {
+		return t.repos[0], nil
+	}
+	for _, repo := range t.repos {
+		if name == repo || filepath.Clean(name) == repo || name == filepath.Base(repo) {
+			return repo, nil
+		}
+	}
+	return "", fmt.Errorf("repository %q is not configured (available: %s)", name, strings.Join(t.repos, ", "))
+}
+
+// gitPaths reads args["paths"], rejecting paths outside the repository.
+func gitPaths(args map[string]any) ([]string, error) {
+	raw, _ := args["paths"].([]any)
+	paths := make([]string, 0, len(raw))
+	for _, v := range raw {
+		p, ok := v.(string)
+		if !ok || p == "" {
+			continue
+		}
+		if filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..") {
+			return nil, fmt.Errorf("path %q must be inside the repository", p)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
+
+func withPaths(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	return append([]string{"--"}, paths...)
+}
+
+// output runs git in repo and returns its trimmed stdout.
+func (t *GitTool) output(ctx context.Context, repo string, args ...string) (string, error) {
+	cmd := t.command(ctx, repo, args...)
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (t *GitTool) command(ctx context.Context, repo string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, t.git, append([]string{"-C", repo}, args...)...)
+	// Never block on a credential or editor prompt; there is nobody to answer it.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_PAGER=cat")
+	return cmd
+}
+
+func (t *GitTool) run(ctx context.Context, repo string, args ...string) *ToolResult {
+	return t.runEnv(ctx, repo, nil, args...)
+}
+
+// runEnv runs git in repo with extra environment variables and returns its
+// combined output as the result.
+func (t *GitTool) runEnv(ctx context.Context, repo string, env []string, args ...string) *ToolResult {
+	runCtx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	cmd := t.command(runCtx, repo, args...)
+	cmd.Env = append(cmd.Env, env...)
+	output := &cappedBuffer{limit: gitMaxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+
+	out := strings.TrimRight(output.String(), "\n")
+	if output.dropped > 0 {
+		out += fmt.Sprintf("\n... (truncated, %d more chars)", output.dropped)
+	}
+	if err != nil {
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return ErrorResult(fmt.Sprintf("git %s timed out after %v", args[0], gitTimeout))
+		}
+		return ErrorResult(fmt.Sprintf("git %s failed: %v\n%s", args[0], err, out))
+	}
+	if out == "" {
+		out = "(no output)"
+	}
+	return SilentResult(out)
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestGitRepo(t *testing.T) (workspace, repo string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// Keep the user's global config (identity, hooks) out of the test.
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	workspace = t.TempDir()
+	repo = filepath.Join(workspace, "notes")
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	return workspace, repo
+}
+
+func TestGitTool_StatusCommitLog(t *testing.T) {
+	workspace, repo := newTestGitRepo(t)
+	tool, err := NewGitTool(workspace, []string{"notes"})
+	if err != nil {
+		t.Fatalf("NewGitTool: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := os.WriteFile(filepath.Join(repo, "todo.md"), []byte("- milk\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "status"}); !strings.Contains(result.ForLLM, "?? todo.md") {
+		t.Errorf("status = %q", result.ForLLM)
+	}
+
+	result := tool.Execute(ctx, map[string]any{"action": "commit", "repo": "notes", "message": "Add todo list"})
+	if result.IsError {
+		t.Fatalf("commit: %s", result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "log"})
+	if result.IsError || !strings.Contains(result.ForLLM, "picoclaw: Add todo list") {
+		t.Errorf("log = %q", result.ForLLM)
+	}
+
+	for _, args := range []map[string]any{
+		{"action": "status", "repo": "elsewhere"},
+		{"action": "diff", "paths": []any{"../secrets"}},
+		{"action": "commit"},
+		{"action": "push", "remote": "--receive-pack=evil"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v: expected an error, got %q", args, result.ForLLM)
+		}
+	}
+}
+
+func TestGitTool_PushNeedsApproval(t *testing.T) {
+	workspace, _ := newTestGitRepo(t)
+	tool, err := NewGitTool(workspace, []string{"notes"})
+	if err != nil {
+		t.Fatalf("NewGitTool: %v", err)
+	}
+
+	r := NewToolRegistry()
+	store := NewApprovalStore(filepath.Join(t.TempDir(), "pending.json"), 0)
+	r.RequireApproval(store, nil)
+	r.Register(tool)
+
+	ctx := context.Background()
+	status := r.ExecuteWithContext(ctx, "git", map[string]any{"action": "status"}, "telegram", "1", nil)
+	if status.IsError || strings.Contains(status.ForLLM, "/approve") {
+		t.Errorf("status should run without approval: %+v", status)
+	}
+	result := r.ExecuteWithContext(ctx, "git", map[string]any{"action": "push"}, "telegram", "1", nil)
+	if pending, _ := store.List("telegram", "1"); len(pending) != 1 || !strings.Contains(result.ForLLM, "/approve") {
+		t.Errorf("push was not held for approval: %+v, pending %v", result, pending)
+	}
+}
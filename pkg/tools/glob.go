@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxGlobResults caps the number of paths one glob call returns.
+const maxGlobResults = 200
+
+// GlobTool finds files whose path matches a glob pattern, so the agent can
+// locate notes and task files without listing every directory.
+type GlobTool struct {
+	fs fileSystem
+}
+
+func NewGlobTool(workspace string, restrict bool, allowPaths ...[]*regexp.Regexp) *GlobTool {
+	var patterns []*regexp.Regexp
+	if len(allowPaths) > 0 {
+		patterns = allowPaths[0]
+	}
+	return &GlobTool{fs: buildFs(workspace, restrict, patterns)}
+}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) Description() string {
+	return "Find files matching a glob pattern such as \"**/*.md\" or \"memory/2026*/*.md\". " +
+		"\"**\" matches any number of directories. Returns paths relative to the search directory."
+}
+
+func (t *GlobTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"pattern": map[string]any{
+				"type":        "string",
+				"description": "Glob pattern to match against file paths",
+			},
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Directory to search in (default: workspace root)",
+			},
+		},
+		"required": []string{"pattern"},
+	}
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	pattern, ok := args["pattern"].(string)
+	if !ok || pattern == "" {
+		return ErrorResult("pattern is required")
+	}
+	pattern = filepath.ToSlash(pattern)
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return ErrorResult(fmt.Sprintf("invalid pattern: %v", err))
+	}
+
+	dir, ok := args["path"].(string)
+	if !ok || dir == "" {
+		dir = "."
+	}
+
+	var matches []string
+	truncated := false
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := t.fs.ReadDir(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			child := path.Join(rel, entry.Name())
+			if entry.IsDir() {
+				if err := walk(child); err != nil {
+					return err
+				}
+			} else if matchGlob(pattern, child) {
+				if len(matches) == maxGlobResults {
+					truncated = true
+					return nil
+				}
+				matches = append(matches, child)
+			}
+			if truncated {
+				return nil
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search directory: %v", err))
+	}
+
+	if len(matches) == 0 {
+		return NewToolResult("No files matched.")
+	}
+	out := strings.Join(matches, "\n")
+	if truncated {
+		out += fmt.Sprintf("\n... (stopped after %d matches; use a more specific pattern)", maxGlobResults)
+	}
+	return NewToolResult(out)
+}
+
+// matchGlob reports whether the slash-separated name matches pattern, where
+// a "**" segment matches zero or more path segments and other segments use
+// path.Match syntax.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
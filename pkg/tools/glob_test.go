@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*.md", "MEMORY.md", true},
+		{"*.md", "memory/MEMORY.md", false},
+		{"**/*.md", "MEMORY.md", true},
+		{"**/*.md", "memory/202610/20261014.md", true},
+		{"memory/**", "memory/202610/20261014.md", true},
+		{"memory/*/2026*.md", "memory/202610/20261014.md", true},
+		{"memory/*.md", "memory/202610/20261014.md", false},
+		{"**/tasks/*.txt", "a/b/tasks/todo.txt", true},
+		{"**/tasks/*.txt", "a/b/tasks/x/todo.txt", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, matchGlob(tt.pattern, tt.name), "%s ~ %s", tt.pattern, tt.name)
+	}
+}
+
+func TestGlobTool_FindsFilesInWorkspace(t *testing.T) {
+	workspace := t.TempDir()
+	for _, name := range []string{"HEARTBEAT.md", "memory/MEMORY.md", "memory/202610/20261014.md", "notes.txt"} {
+		path := filepath.Join(workspace, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("x"), 0o644))
+	}
+
+	tool := NewGlobTool(workspace, true)
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "**/*.md"})
+	assert.False(t, result.IsError, "Expected success, got: %s", result.ForLLM)
+	assert.Equal(t, "HEARTBEAT.md\nmemory/202610/20261014.md\nmemory/MEMORY.md", result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "*.md", "path": "memory"})
+	assert.Equal(t, "MEMORY.md", result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{"pattern": "*.go"})
+	assert.Equal(t, "No files matched.", result.ForLLM)
+}
+
+func TestGlobTool_RejectsPathOutsideWorkspace(t *testing.T) {
+	tool := NewGlobTool(t.TempDir(), true)
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "*", "path": "../"})
+	assert.True(t, result.IsError)
+}
+
+func TestGlobTool_InvalidPattern(t *testing.T) {
+	tool := NewGlobTool(t.TempDir(), true)
+	result := tool.Execute(context.Background(), map[string]any{"pattern": "[a-"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "invalid pattern")
+}
@@ -12,6 +12,15 @@ import (
 // I2CTool provides I2C bus interaction for reading sensors and controlling peripherals.
 type I2CTool struct{}
 
+func init() {
+	RegisterFactory("i2c", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("i2c") {
+			return nil, nil
+		}
+		return NewI2CTool(), nil
+	})
+}
+
 func NewI2CTool() *I2CTool {
 	return &I2CTool{}
 }
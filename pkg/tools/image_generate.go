@@ -0,0 +1,281 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+const (
+	defaultImageOpenAIBase  = "https://api.openai.com/v1"
+	defaultImageOpenAIModel = "gpt-image-1"
+	defaultImageSDWebUIBase = "http://127.0.0.1:7860"
+	maxImageBytes           = 20 << 20
+)
+
+var imageSizePattern = regexp.MustCompile(`^(\d{2,4})x(\d{2,4})$`)
+
+func init() {
+	RegisterFactory("image_generate", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("image_generate") {
+			return nil, nil
+		}
+		cfg := env.Config.Tools.ImageGenerate
+		if cfg.APIKey == "" && (cfg.Provider == "" || cfg.Provider == "openai") {
+			cfg.APIKey = openAIKey(env.Config)
+		}
+		return NewImageGenerateTool(cfg)
+	})
+}
+
+// openAIKey finds an OpenAI API key in the provider config, falling back
+// to any model-list entry that uses the openai/ protocol.
+func openAIKey(cfg *config.Config) string {
+	if key := cfg.Providers.OpenAI.APIKey; key != "" {
+		return key
+	}
+	for _, mc := range cfg.ModelList {
+		if strings.HasPrefix(mc.Model, "openai/") && mc.APIKey != "" {
+			return mc.APIKey
+		}
+	}
+	return ""
+}
+
+// ImageGenerateTool creates images from a text prompt and sends them to the
+// user as attachments.
+type ImageGenerateTool struct {
+	provider string
+	apiBase  string
+	apiKey   string
+	model    string
+	size     string
+	client   *http.Client
+
+	mu         sync.Mutex
+	mediaStore media.MediaStore
+}
+
+// NewImageGenerateTool creates an ImageGenerateTool from cfg.
+func NewImageGenerateTool(cfg config.ImageGenConfig) (*ImageGenerateTool, error) {
+	t := &ImageGenerateTool{
+		provider: cfg.Provider,
+		apiBase:  strings.TrimRight(cfg.APIBase, "/"),
+		apiKey:   cfg.APIKey,
+		model:    cfg.Model,
+		size:     cfg.Size,
+	}
+	switch t.provider {
+	case "", "openai":
+		t.provider = "openai"
+		if t.apiBase == "" {
+			t.apiBase = defaultImageOpenAIBase
+		}
+		if t.model == "" {
+			t.model = defaultImageOpenAIModel
+		}
+	case "sdwebui":
+		if t.apiBase == "" {
+			t.apiBase = defaultImageSDWebUIBase
+		}
+	default:
+		return nil, fmt.Errorf("image_generate: unknown provider %q (want openai or sdwebui)", cfg.Provider)
+	}
+	if t.size == "" {
+		t.size = "1024x1024"
+	}
+	if !imageSizePattern.MatchString(t.size) {
+		return nil, fmt.Errorf("image_generate: invalid size %q, want WIDTHxHEIGHT", t.size)
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 120 * time.Second
+	}
+	t.client = &http.Client{Timeout: timeout}
+	return t, nil
+}
+
+// SetMediaStore sets the store generated images are sent to the user through.
+func (t *ImageGenerateTool) SetMediaStore(store media.MediaStore) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mediaStore = store
+}
+
+func (t *ImageGenerateTool) Name() string {
+	return "image_generate"
+}
+
+func (t *ImageGenerateTool) Description() string {
+	return "Generate an image from a text description and send it to the user. " +
+		"Write a detailed prompt: subject, style, composition, lighting."
+}
+
+func (t *ImageGenerateTool) Parameters() map[string]any {
+	props := map[string]any{
+		"prompt": map[string]any{
+			"type":        "string",
+			"description": "Description of the image to generate",
+		},
+		"size": map[string]any{
+			"type":        "string",
+			"description": "Image size as WIDTHxHEIGHT (default " + t.size + ")",
+		},
+	}
+	if t.provider == "sdwebui" {
+		props["negative_prompt"] = map[string]any{
+			"type":        "string",
+			"description": "What the image should not contain",
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   []string{"prompt"},
+	}
+}
+
+func (t *ImageGenerateTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	prompt, _ := args["prompt"].(string)
+	if strings.TrimSpace(prompt) == "" {
+		return ErrorResult("prompt is required")
+	}
+	size, _ := args["size"].(string)
+	if size == "" {
+		size = t.size
+	}
+	if !imageSizePattern.MatchString(size) {
+		return ErrorResult(fmt.Sprintf("invalid size %q, want WIDTHxHEIGHT such as 1024x1024", size))
+	}
+
+	t.mu.Lock()
+	store := t.mediaStore
+	t.mu.Unlock()
+	if store == nil || ToolChannel(ctx) == "" || ToolChatID(ctx) == "" {
+		return ErrorResult("image_generate needs a chat channel to deliver the image to")
+	}
+
+	var data []byte
+	var err error
+	if t.provider == "sdwebui" {
+		negative, _ := args["negative_prompt"].(string)
+		data, err = t.generateSDWebUI(ctx, prompt, negative, size)
+	} else {
+		data, err = t.generateOpenAI(ctx, prompt, size)
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("image generation failed: %v", err))
+	}
+
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		return ErrorResult(fmt.Sprintf("image generation returned %s, not an image", contentType))
+	}
+	filename := "image." + strings.TrimPrefix(contentType, "image/")
+	ref, err := storeMediaBytes(ctx, store, data, filename, contentType, "tool:image_generate")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to send image: %v", err))
+	}
+	return MediaResult(fmt.Sprintf("Image (%s) generated and sent to user", size), []string{ref})
+}
+
+// generateOpenAI calls POST /images/generations. response_format is not
+// sent since gpt-image-1 rejects it; images come back as b64_json or url.
+func (t *ImageGenerateTool) generateOpenAI(ctx context.Context, prompt, size string) ([]byte, error) {
+	var resp struct {
+		Data []struct {
+			B64JSON       string `json:"b64_json"`
+			URL           string `json:"url"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+	}
+	body := map[string]any{"model": t.model, "prompt": prompt, "size": size, "n": 1}
+	if err := t.postJSON(ctx, t.apiBase+"/images/generations", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, errors.New("no image in response")
+	}
+	if img := resp.Data[0]; img.B64JSON != "" {
+		return base64.StdEncoding.DecodeString(img.B64JSON)
+	} else if img.URL != "" {
+		return t.download(ctx, img.URL)
+	}
+	return nil, errors.New("no image in response")
+}
+
+// generateSDWebUI calls POST /sdapi/v1/txt2img.
+func (t *ImageGenerateTool) generateSDWebUI(ctx context.Context, prompt, negative, size string) ([]byte, error) {
+	m := imageSizePattern.FindStringSubmatch(size)
+	width, _ := strconv.Atoi(m[1])
+	height, _ := strconv.Atoi(m[2])
+	var resp struct {
+		Images []string `json:"images"`
+	}
+	body := map[string]any{"prompt": prompt, "negative_prompt": negative, "width": width, "height": height}
+	if err := t.postJSON(ctx, t.apiBase+"/sdapi/v1/txt2img", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Images) == 0 {
+		return nil, errors.New("no image in response")
+	}
+	return base64.StdEncoding.DecodeString(resp.Images[0])
+}
+
+func (t *ImageGenerateTool) postJSON(ctx context.Context, url string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Base64 inflates images by a third.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes*4/3+4096))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned %s: %s", resp.Status, truncateRunes(strings.TrimSpace(string(data)), 500))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (t *ImageGenerateTool) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image download returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImageBytes))
+}
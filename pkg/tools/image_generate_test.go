@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/media"
+)
+
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageGenerateTool_OpenAI(t *testing.T) {
+	pngData := testPNG(t)
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/images/generations":
+			if r.Header.Get("Authorization") != "Bearer sk-test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&got)
+			// Answer with a URL, as some compatible providers do.
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": []map[string]any{{"url": "http://" + r.Host + "/files/cat.png"}},
+			})
+		case "/files/cat.png":
+			w.Write(pngData)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tool, err := NewImageGenerateTool(config.ImageGenConfig{APIBase: srv.URL + "/v1/", APIKey: "sk-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+	result := tool.Execute(ctx, map[string]any{"prompt": "a cat in a hat", "size": "512x512"})
+	if result.IsError || len(result.Media) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got["model"] != "gpt-image-1" || got["prompt"] != "a cat in a hat" || got["size"] != "512x512" {
+		t.Errorf("request = %v", got)
+	}
+	path, meta, err := store.ResolveWithMeta(result.Media[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.ReleaseAll("tool:image_generate:telegram:42")
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, pngData) || meta.ContentType != "image/png" {
+		t.Errorf("stored %d bytes as %q", len(data), meta.ContentType)
+	}
+}
+
+func TestImageGenerateTool_SDWebUI(t *testing.T) {
+	pngData := testPNG(t)
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sdapi/v1/txt2img" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(map[string]any{"images": []string{base64.StdEncoding.EncodeToString(pngData)}})
+	}))
+	defer srv.Close()
+
+	tool, err := NewImageGenerateTool(config.ImageGenConfig{Provider: "sdwebui", APIBase: srv.URL, Size: "768x512"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := media.NewFileMediaStore()
+	tool.SetMediaStore(store)
+	defer store.ReleaseAll("tool:image_generate:discord:7")
+
+	ctx := WithToolContext(context.Background(), "discord", "7")
+	result := tool.Execute(ctx, map[string]any{"prompt": "lighthouse at dusk", "negative_prompt": "people"})
+	if result.IsError || len(result.Media) != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if got["width"] != float64(768) || got["height"] != float64(512) || got["negative_prompt"] != "people" {
+		t.Errorf("request = %v", got)
+	}
+}
+
+func TestImageGenerateTool_Errors(t *testing.T) {
+	if _, err := NewImageGenerateTool(config.ImageGenConfig{Provider: "midjourney"}); err == nil {
+		t.Error("unknown provider was accepted")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"Your request was rejected by the safety system."}}`))
+	}))
+	defer srv.Close()
+	tool, err := NewImageGenerateTool(config.ImageGenConfig{APIBase: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool.SetMediaStore(media.NewFileMediaStore())
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+
+	for _, args := range []map[string]any{
+		{"prompt": ""},
+		{"prompt": "x", "size": "huge"},
+		{"prompt": "x"},
+	} {
+		if result := tool.Execute(ctx, args); !result.IsError {
+			t.Errorf("%v: expected an error, got %q", args, result.ForLLM)
+		}
+	}
+	if result := tool.Execute(context.Background(), map[string]any{"prompt": "x"}); !result.IsError {
+		t.Error("expected an error without a chat to deliver to")
+	}
+}
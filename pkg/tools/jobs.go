@@ -0,0 +1,411 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const (
+	// maxFinishedJobs is how many finished jobs are kept for status queries.
+	maxFinishedJobs = 50
+	// jobProgressInterval is the minimum gap between two progress
+	// notifications for the same job; updates in between only refresh its
+	// recorded progress.
+	jobProgressInterval = 2 * time.Second
+)
+
+var (
+	// ErrJobNotFound is returned for an unknown job ID, or one started in
+	// another conversation.
+	ErrJobNotFound = errors.New("no job with that id in this conversation")
+	// ErrJobFinished is returned when cancelling a job that is no longer running.
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// JobStatus is the lifecycle state of a background job.
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job describes a tool call running in the background, detached from the
+// turn that started it.
+type Job struct {
+	ID         string
+	Tool       string
+	Channel    string
+	ChatID     string
+	SessionKey string
+	Status     JobStatus
+	Progress   string // latest progress message, if any
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// JobEvent is passed to a JobNotifier. Result is nil for a progress update
+// and set once the job has finished.
+type JobEvent struct {
+	Job    Job
+	Result *ToolResult
+}
+
+// JobNotifier receives a job's progress updates and its final result, so
+// they can be delivered to the conversation that started it.
+type JobNotifier func(JobEvent)
+
+// JobTool is an optional interface for tools whose calls may run as
+// background jobs. When the registry has a JobManager and RunsAsJob reports
+// true for a call, the call is started as a job and the turn continues with
+// the job ID instead of waiting for the result.
+type JobTool interface {
+	RunsAsJob(args map[string]any) bool
+}
+
+type jobEntry struct {
+	job          Job
+	cancel       context.CancelFunc
+	lastNotified time.Time
+}
+
+// JobManager runs tool calls in the background and reports their progress
+// and results through a notifier. Jobs live in memory: they outlast the turn
+// that started them but not a restart.
+type JobManager struct {
+	notify JobNotifier
+	jobs   map[string]*jobEntry
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+// NewJobManager creates a manager that reports job events to notify, which
+// may be nil.
+func NewJobManager(notify JobNotifier) *JobManager {
+	return &JobManager{
+		notify: notify,
+		jobs:   make(map[string]*jobEntry),
+		now:    time.Now,
+	}
+}
+
+var ctxKeyJob = &toolCtxKey{"job"}
+
+type jobRef struct {
+	m  *JobManager
+	id string
+}
+
+// ReportProgress records a progress message for the background job running
+// under ctx and forwards it to the conversation. It does nothing when ctx
+// does not belong to a job, so tools may call it unconditionally.
+func ReportProgress(ctx context.Context, msg string) {
+	ref, ok := ctx.Value(ctxKeyJob).(jobRef)
+	if !ok {
+		return
+	}
+	ref.m.progress(ref.id, msg)
+}
+
+// Start runs fn as a background job for the conversation carried by ctx
+// (see WithToolContext and WithSessionKey) and returns immediately. The job
+// keeps running after ctx is done; it stops only when cancelled through
+// Cancel or when fn returns.
+func (m *JobManager) Start(ctx context.Context, tool string, fn func(ctx context.Context) *ToolResult) Job {
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	m.mu.Lock()
+	id := newShortID()
+	for m.jobs[id] != nil {
+		id = newShortID()
+	}
+	entry := &jobEntry{
+		job: Job{
+			ID:         id,
+			Tool:       tool,
+			Channel:    ToolChannel(ctx),
+			ChatID:     ToolChatID(ctx),
+			SessionKey: ToolSessionKey(ctx),
+			Status:     JobRunning,
+			StartedAt:  m.now(),
+		},
+		cancel: cancel,
+	}
+	m.jobs[id] = entry
+	job := entry.job
+	m.mu.Unlock()
+
+	logger.InfoCF("tool", "Background job started",
+		map[string]any{
+			"job_id":  id,
+			"tool":    tool,
+			"channel": job.Channel,
+		})
+
+	go m.run(context.WithValue(jobCtx, ctxKeyJob, jobRef{m: m, id: id}), id, fn)
+	return job
+}
+
+func (m *JobManager) run(ctx context.Context, id string, fn func(ctx context.Context) *ToolResult) {
+	var result *ToolResult
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.ErrorCF("tool", "Background job panicked",
+					map[string]any{
+						"job_id": id,
+						"panic":  fmt.Sprint(p),
+						"stack":  string(debug.Stack()),
+					})
+				result = ErrorResult(fmt.Sprintf("job crashed: %v", p)).WithError(fmt.Errorf("job panicked: %v", p))
+			}
+		}()
+		result = fn(ctx)
+	}()
+	if result == nil {
+		result = ErrorResult("job returned no result")
+	}
+	m.finish(id, result)
+}
+
+func (m *JobManager) finish(id string, result *ToolResult) {
+	m.mu.Lock()
+	entry := m.jobs[id]
+	entry.cancel()
+	cancelled := entry.job.Status == JobCancelled
+	if !cancelled {
+		entry.job.Status = JobDone
+		if result.IsError {
+			entry.job.Status = JobFailed
+		}
+		entry.job.FinishedAt = m.now()
+	}
+	job := entry.job
+	m.pruneLocked()
+	m.mu.Unlock()
+
+	logger.InfoCF("tool", "Background job finished",
+		map[string]any{
+			"job_id": id,
+			"tool":   job.Tool,
+			"status": string(job.Status),
+		})
+
+	// A cancelled job was stopped from its own conversation, which already
+	// knows; whatever the tool returned afterwards is dropped.
+	if !cancelled && m.notify != nil {
+		m.notify(JobEvent{Job: job, Result: result})
+	}
+}
+
+func (m *JobManager) progress(id, msg string) {
+	m.mu.Lock()
+	entry := m.jobs[id]
+	if entry == nil || entry.job.Status != JobRunning {
+		m.mu.Unlock()
+		return
+	}
+	entry.job.Progress = msg
+	now := m.now()
+	send := now.Sub(entry.lastNotified) >= jobProgressInterval
+	if send {
+		entry.lastNotified = now
+	}
+	job := entry.job
+	m.mu.Unlock()
+
+	if send && m.notify != nil {
+		m.notify(JobEvent{Job: job})
+	}
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs.
+// Callers must hold m.mu.
+func (m *JobManager) pruneLocked() {
+	var finished []*jobEntry
+	for _, e := range m.jobs {
+		if e.job.Status != JobRunning {
+			finished = append(finished, e)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].job.FinishedAt.Before(finished[j].job.FinishedAt) })
+	for _, e := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, e.job.ID)
+	}
+}
+
+// Get returns the job with the given ID if it was started in channel/chatID.
+func (m *JobManager) Get(id, channel, chatID string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.jobs[id]
+	if entry == nil || entry.job.Channel != channel || entry.job.ChatID != chatID {
+		return Job{}, false
+	}
+	return entry.job, true
+}
+
+// List returns the jobs started in channel/chatID, oldest first.
+func (m *JobManager) List(channel, chatID string) []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Job
+	for _, e := range m.jobs {
+		if e.job.Channel == channel && e.job.ChatID == chatID {
+			out = append(out, e.job)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// Cancel stops a running job started in channel/chatID. Its context is
+// cancelled and any result it still produces is discarded.
+func (m *JobManager) Cancel(id, channel, chatID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.jobs[id]
+	if entry == nil || entry.job.Channel != channel || entry.job.ChatID != chatID {
+		return ErrJobNotFound
+	}
+	if entry.job.Status != JobRunning {
+		return ErrJobFinished
+	}
+	entry.job.Status = JobCancelled
+	entry.job.FinishedAt = m.now()
+	entry.cancel()
+	return nil
+}
+
+// Running returns the number of jobs still running.
+func (m *JobManager) Running() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, e := range m.jobs {
+		if e.job.Status == JobRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// CancelAll stops every running job, as Cancel does, and returns how many
+// there were.
+func (m *JobManager) CancelAll() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, e := range m.jobs {
+		if e.job.Status == JobRunning {
+			e.job.Status = JobCancelled
+			e.job.FinishedAt = m.now()
+			e.cancel()
+			n++
+		}
+	}
+	return n
+}
+
+// JobsTool lets the model check on and cancel the background jobs of the
+// current conversation.
+type JobsTool struct {
+	manager *JobManager
+}
+
+// NewJobsTool creates a JobsTool over manager.
+func NewJobsTool(manager *JobManager) *JobsTool {
+	return &JobsTool{manager: manager}
+}
+
+func (t *JobsTool) Name() string {
+	return "jobs"
+}
+
+func (t *JobsTool) Description() string {
+	return "List, inspect or cancel background jobs started in this conversation. " +
+		"Results of finished jobs are delivered automatically; do not poll for them."
+}
+
+func (t *JobsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"list", "status", "cancel"},
+				"description": "Action to perform",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Job ID for status and cancel",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *JobsTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+	action, _ := args["action"].(string)
+	id, _ := args["id"].(string)
+
+	switch action {
+	case "list":
+		jobs := t.manager.List(channel, chatID)
+		if len(jobs) == 0 {
+			return SilentResult("No background jobs in this conversation.")
+		}
+		lines := make([]string, 0, len(jobs))
+		for _, job := range jobs {
+			lines = append(lines, describeJob(job))
+		}
+		return SilentResult(strings.Join(lines, "\n"))
+	case "status":
+		if id == "" {
+			return ErrorResult("id is required for status")
+		}
+		job, ok := t.manager.Get(id, channel, chatID)
+		if !ok {
+			return ErrorResult(ErrJobNotFound.Error())
+		}
+		return SilentResult(describeJob(job))
+	case "cancel":
+		if id == "" {
+			return ErrorResult("id is required for cancel")
+		}
+		if err := t.manager.Cancel(id, channel, chatID); err != nil {
+			return ErrorResult(err.Error())
+		}
+		return SilentResult(fmt.Sprintf("Cancelled job %s", id))
+	case "":
+		return ErrorResult("action is required")
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+func describeJob(job Job) string {
+	s := fmt.Sprintf("%s: %s %s, started %s", job.ID, job.Tool, job.Status, job.StartedAt.Format("15:04:05"))
+	if !job.FinishedAt.IsZero() {
+		s += fmt.Sprintf(", took %s", job.FinishedAt.Sub(job.StartedAt).Round(time.Second))
+	}
+	if job.Progress != "" {
+		s += " — " + utils.Truncate(job.Progress, 200)
+	}
+	return s
+}
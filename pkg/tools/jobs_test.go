@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockJobTool runs as a job when called with background=true.
+type mockJobTool struct {
+	mockFuncRegistryTool
+}
+
+func (m *mockJobTool) RunsAsJob(args map[string]any) bool {
+	background, _ := args["background"].(bool)
+	return background
+}
+
+func waitJobEvent(t *testing.T, events <-chan JobEvent) JobEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a job event")
+		return JobEvent{}
+	}
+}
+
+func TestToolRegistry_RunsJobToolsInBackground(t *testing.T) {
+	events := make(chan JobEvent, 4)
+	jobs := NewJobManager(func(ev JobEvent) { events <- ev })
+
+	release := make(chan struct{})
+	r := NewToolRegistry()
+	r.SetJobManager(jobs)
+	r.Register(&mockJobTool{mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("build", ""),
+		fn: func(ctx context.Context) *ToolResult {
+			ReportProgress(ctx, "compiling")
+			<-release
+			return UserResult("build ok")
+		},
+	}})
+	r.Register(&mockJobTool{mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("quick", ""),
+		fn:               func(context.Context) *ToolResult { return SilentResult("done") },
+	}})
+
+	ctx := WithSessionKey(context.Background(), "agent:main:telegram:42")
+	if result := r.ExecuteWithContext(ctx, "quick", nil, "telegram", "42", nil); result.Async || result.ForLLM != "done" {
+		t.Fatalf("call without background = %+v, want it run in the turn", result)
+	}
+
+	result := r.ExecuteWithContext(ctx, "build", map[string]any{"background": true}, "telegram", "42", nil)
+	if !result.Async || result.IsError {
+		t.Fatalf("background call result = %+v, want an async job result", result)
+	}
+	listed := jobs.List("telegram", "42")
+	if len(listed) != 1 || !strings.Contains(result.ForLLM, listed[0].ID) {
+		t.Fatalf("List = %+v, result = %q", listed, result.ForLLM)
+	}
+
+	ev := waitJobEvent(t, events)
+	if ev.Result != nil || ev.Job.Progress != "compiling" {
+		t.Fatalf("first event = %+v, want a progress update", ev)
+	}
+
+	close(release)
+	ev = waitJobEvent(t, events)
+	if ev.Result == nil || ev.Result.ForLLM != "build ok" || ev.Job.Status != JobDone {
+		t.Fatalf("final event = %+v", ev)
+	}
+	if ev.Job.Channel != "telegram" || ev.Job.ChatID != "42" || ev.Job.SessionKey != "agent:main:telegram:42" {
+		t.Errorf("job conversation = %+v", ev.Job)
+	}
+	if job, ok := jobs.Get(ev.Job.ID, "telegram", "42"); !ok || job.Status != JobDone {
+		t.Errorf("Get after finish = %+v, %v", job, ok)
+	}
+}
+
+func TestJobManager_CancelAndScope(t *testing.T) {
+	events := make(chan JobEvent, 4)
+	jobs := NewJobManager(func(ev JobEvent) { events <- ev })
+
+	stopped := make(chan struct{})
+	ctx := WithToolContext(context.Background(), "discord", "7")
+	job := jobs.Start(ctx, "exec", func(ctx context.Context) *ToolResult {
+		<-ctx.Done()
+		close(stopped)
+		return ErrorResult("killed")
+	})
+
+	tool := NewJobsTool(jobs)
+	other := WithToolContext(context.Background(), "discord", "8")
+	if result := tool.Execute(other, map[string]any{"action": "cancel", "id": job.ID}); !result.IsError {
+		t.Errorf("cancel from another chat = %+v, want an error", result)
+	}
+	if result := tool.Execute(other, map[string]any{"action": "list"}); !strings.HasPrefix(result.ForLLM, "No ") {
+		t.Errorf("list from another chat = %q", result.ForLLM)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "cancel", "id": job.ID}); result.IsError {
+		t.Fatalf("cancel: %s", result.ForLLM)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job context was not cancelled")
+	}
+	if err := jobs.Cancel(job.ID, "discord", "7"); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("second Cancel: err = %v, want ErrJobFinished", err)
+	}
+	status := tool.Execute(ctx, map[string]any{"action": "status", "id": job.ID})
+	if !strings.Contains(status.ForLLM, "exec cancelled") {
+		t.Errorf("status = %q", status.ForLLM)
+	}
+
+	select {
+	case ev := <-events:
+		t.Errorf("cancelled job reported %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestJobManager_ContainsPanic(t *testing.T) {
+	events := make(chan JobEvent, 1)
+	jobs := NewJobManager(func(ev JobEvent) { events <- ev })
+
+	jobs.Start(context.Background(), "boom", func(context.Context) *ToolResult { panic("bad state") })
+
+	ev := waitJobEvent(t, events)
+	if ev.Job.Status != JobFailed || !ev.Result.IsError || !strings.Contains(ev.Result.ForLLM, "bad state") {
+		t.Errorf("event = %+v, result = %+v", ev.Job, ev.Result)
+	}
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+const defaultMemorySearchLimit = 10
+
+// NewMemoryTools returns the memory_save, memory_search and memory_forget
+// tools over one fact store, letting the model decide what to remember
+// long-term instead of relying only on conversation summaries.
+func NewMemoryTools(facts *memory.FactStore) []Tool {
+	return []Tool{
+		&MemorySaveTool{facts: facts},
+		&MemorySearchTool{facts: facts},
+		&MemoryForgetTool{facts: facts},
+	}
+}
+
+// MemorySaveTool stores a fact in long-term memory.
+type MemorySaveTool struct {
+	facts *memory.FactStore
+}
+
+func (t *MemorySaveTool) Name() string {
+	return "memory_save"
+}
+
+func (t *MemorySaveTool) Description() string {
+	return "Save a durable fact to long-term memory (preferences, names, dates, decisions, recurring context). " +
+		"Write one self-contained statement per call, e.g. \"User's daughter Mia was born on 2019-05-02\". " +
+		"Do not save transient chat details."
+}
+
+func (t *MemorySaveTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The fact to remember, as a self-contained statement",
+			},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Optional keywords to find the fact by, e.g. [\"family\", \"birthday\"]",
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *MemorySaveTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	content, _ := args["content"].(string)
+	if strings.TrimSpace(content) == "" {
+		return ErrorResult("content is required")
+	}
+
+	var tags []string
+	if raw, ok := args["tags"].([]any); ok {
+		for _, v := range raw {
+			if tag, ok := v.(string); ok && strings.TrimSpace(tag) != "" {
+				tags = append(tags, strings.TrimSpace(tag))
+			}
+		}
+	}
+
+	source := ""
+	if channel, chatID := ToolChannel(ctx), ToolChatID(ctx); channel != "" {
+		source = channel + ":" + chatID
+	}
+
+	fact, err := t.facts.Save(ctx, content, tags, source)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save fact: %v", err))
+	}
+	return SilentResult(fmt.Sprintf("Saved to memory (id: %s)", fact.ID))
+}
+
+// MemorySearchTool finds facts relevant to a query.
+type MemorySearchTool struct {
+	facts *memory.FactStore
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+func (t *MemorySearchTool) Description() string {
+	return "Search long-term memory for saved facts relevant to a query. " +
+		"Use it before answering questions about the user's preferences, people, plans or past decisions."
+}
+
+func (t *MemorySearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "What to look for",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of facts to return (default 10)",
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return ErrorResult("query is required")
+	}
+	limit := defaultMemorySearchLimit
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = int(v)
+	}
+
+	matches, err := t.facts.Search(ctx, query, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to search memory: %v", err))
+	}
+	if len(matches) == 0 {
+		return SilentResult("No matching facts in memory.")
+	}
+
+	var sb strings.Builder
+	for _, m := range matches {
+		fmt.Fprintf(&sb, "- [%s] %s", m.ID, m.Content)
+		if len(m.Tags) > 0 {
+			fmt.Fprintf(&sb, " (tags: %s)", strings.Join(m.Tags, ", "))
+		}
+		fmt.Fprintf(&sb, " — saved %s\n", m.CreatedAt.Format("2006-01-02"))
+	}
+	return SilentResult(strings.TrimRight(sb.String(), "\n"))
+}
+
+// MemoryForgetTool deletes a fact from long-term memory.
+type MemoryForgetTool struct {
+	facts *memory.FactStore
+}
+
+func (t *MemoryForgetTool) Name() string {
+	return "memory_forget"
+}
+
+func (t *MemoryForgetTool) Description() string {
+	return "Delete a fact from long-term memory by its id (from memory_search), " +
+		"when it is wrong, outdated or the user asks to forget it."
+}
+
+func (t *MemoryForgetTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"id": map[string]any{
+				"type":        "string",
+				"description": "ID of the fact to delete",
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *MemoryForgetTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required")
+	}
+
+	removed, err := t.facts.Forget(ctx, id)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to forget fact: %v", err))
+	}
+	if !removed {
+		return ErrorResult(fmt.Sprintf("fact %s not found", id))
+	}
+	return SilentResult(fmt.Sprintf("Forgot fact %s", id))
+}
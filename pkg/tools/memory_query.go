@@ -0,0 +1,338 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultQueryRows = 50
+	maxQueryRows     = 200
+	maxQueryCellLen  = 300
+	queryTimeout     = 10 * time.Second
+)
+
+// forbiddenSQLWords are keywords that never appear in a read-only query.
+// SQLite allows writes after a WITH clause, so the leading keyword alone is
+// not enough.
+var forbiddenSQLWords = map[string]bool{
+	"alter": true, "analyze": true, "attach": true, "begin": true, "commit": true, "create": true,
+	"delete": true, "detach": true, "drop": true, "insert": true, "into": true, "load_extension": true,
+	"pragma": true, "reindex": true, "release": true, "rollback": true, "savepoint": true,
+	"update": true, "vacuum": true,
+}
+
+// QueryTable is a table exposed to memory_query. Rows is called on every
+// query, so results always reflect the current state of the store.
+type QueryTable struct {
+	Name    string
+	Columns []string // column definitions, e.g. "created_at TEXT"
+	Doc     string   // one-line description shown to the model
+	Rows    func(ctx context.Context) ([][]any, error)
+}
+
+// MemoryQueryTool runs read-only SQL over a snapshot of the agent's own
+// stores (sessions, usage, facts), for questions such as "how many requests
+// did I handle last week?". Each call loads the tables into a private
+// in-memory SQLite database, so a query can never touch the files on disk.
+type MemoryQueryTool struct {
+	tables   []QueryTable
+	cacheKiB int
+}
+
+// NewMemoryQueryTool creates a MemoryQueryTool over tables.
+func NewMemoryQueryTool(tables []QueryTable) *MemoryQueryTool {
+	return &MemoryQueryTool{tables: tables}
+}
+
+// SetCacheKiB caps the SQLite page cache of each snapshot at kib KiB. The
+// snapshot is then kept in a temporary file that SQLite deletes on close,
+// rather than in memory. 0 keeps it in memory.
+func (t *MemoryQueryTool) SetCacheKiB(kib int) {
+	t.cacheKiB = kib
+}
+
+func (t *MemoryQueryTool) Name() string {
+	return "memory_query"
+}
+
+func (t *MemoryQueryTool) Description() string {
+	var sb strings.Builder
+	sb.WriteString("Run a read-only SQLite SELECT over your own conversation and usage history, " +
+		"for counting and analytics questions. Times are UTC text (\"YYYY-MM-DD HH:MM:SS\"), " +
+		"so use e.g. created_at >= datetime('now', '-7 days'). Tables:")
+	for _, table := range t.tables {
+		fmt.Fprintf(&sb, "\n- %s(%s): %s", table.Name, strings.Join(table.Columns, ", "), table.Doc)
+	}
+	return sb.String()
+}
+
+func (t *MemoryQueryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "A single SELECT (or WITH ... SELECT) statement",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum rows to return (default %d, max %d)", defaultQueryRows, maxQueryRows),
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemoryQueryTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, _ := args["query"].(string)
+	query, err := validateReadOnlySQL(query)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	limit := defaultQueryRows
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = min(int(v), maxQueryRows)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	db, err := t.snapshot(ctx)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to load memory: %v", err))
+	}
+	defer db.Close()
+
+	// Fetch one extra row to tell the model whether the result was truncated.
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (%s) LIMIT %d", query, limit+1))
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("query failed: %v", err))
+	}
+	defer rows.Close()
+
+	out, err := formatQueryRows(rows, limit)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("query failed: %v", err))
+	}
+	return SilentResult(out)
+}
+
+// snapshot creates a private database holding the current table rows and
+// switches it to query-only mode.
+func (t *MemoryQueryTool) snapshot(ctx context.Context) (*sql.DB, error) {
+	name := ":memory:"
+	if t.cacheKiB > 0 {
+		name = "" // a temporary file
+	}
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to ":memory:" or "" is a separate database.
+	db.SetMaxOpenConns(1)
+
+	if t.cacheKiB > 0 {
+		// A negative cache_size is in KiB rather than pages.
+		pragmas := fmt.Sprintf("PRAGMA cache_size = -%d; PRAGMA temp_store = FILE", t.cacheKiB)
+		if _, err := db.ExecContext(ctx, pragmas); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := t.load(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (t *MemoryQueryTool) load(ctx context.Context, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range t.tables {
+		create := fmt.Sprintf("CREATE TABLE %s (%s)", table.Name, strings.Join(table.Columns, ", "))
+		if _, err := tx.ExecContext(ctx, create); err != nil {
+			return fmt.Errorf("create %s: %w", table.Name, err)
+		}
+		rows, err := table.Rows(ctx)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", table.Name, err)
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(table.Columns)), ", ")
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (%s)", table.Name, placeholders))
+		if err != nil {
+			return fmt.Errorf("prepare %s: %w", table.Name, err)
+		}
+		for _, row := range rows {
+			if _, err := stmt.ExecContext(ctx, row...); err != nil {
+				stmt.Close()
+				return fmt.Errorf("insert into %s: %w", table.Name, err)
+			}
+		}
+		stmt.Close()
+	}
+	return tx.Commit()
+}
+
+// validateReadOnlySQL checks that query is a single SELECT statement and
+// returns it without a trailing semicolon. It scans tokens outside string
+// literals, quoted identifiers and comments, so a keyword inside a string
+// such as 'drop me a line' is not rejected.
+func validateReadOnlySQL(query string) (string, error) {
+	query = strings.TrimSpace(query)
+	query = strings.TrimSpace(strings.TrimSuffix(query, ";"))
+	if query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+
+	var words []string
+	depth := 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated quote in query")
+			}
+			i += end + 2
+		case c == '[':
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated [identifier] in query")
+			}
+			i += end + 1
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated comment in query")
+			}
+			i += end + 4
+		case c == ';':
+			return "", fmt.Errorf("only a single statement is allowed")
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth--; depth < 0 {
+				return "", fmt.Errorf("unbalanced parentheses in query")
+			}
+			i++
+		case c == '_' || c < utf8.RuneSelf && unicode.IsLetter(rune(c)):
+			start := i
+			for i < len(query) && (query[i] == '_' || query[i] < utf8.RuneSelf &&
+				(unicode.IsLetter(rune(query[i])) || unicode.IsDigit(rune(query[i])))) {
+				i++
+			}
+			words = append(words, strings.ToLower(query[start:i]))
+		default:
+			i++
+		}
+	}
+	if depth != 0 {
+		return "", fmt.Errorf("unbalanced parentheses in query")
+	}
+
+	if len(words) == 0 || (words[0] != "select" && words[0] != "with") {
+		return "", fmt.Errorf("only SELECT queries are allowed")
+	}
+	for _, w := range words {
+		if forbiddenSQLWords[w] {
+			return "", fmt.Errorf("%s is not allowed in a read-only query", strings.ToUpper(w))
+		}
+	}
+	return query, nil
+}
+
+// formatQueryRows renders up to limit rows as a pipe-separated table.
+func formatQueryRows(rows *sql.Rows, limit int) (string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.Join(columns, " | "))
+
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	n, truncated := 0, false
+	for rows.Next() {
+		if n == limit {
+			truncated = true
+			break
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return "", err
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = formatQueryValue(v)
+		}
+		sb.WriteString("\n" + strings.Join(cells, " | "))
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case n == 0:
+		sb.WriteString("\n(no rows)")
+	case n == 1:
+		sb.WriteString("\n(1 row)")
+	case truncated:
+		fmt.Fprintf(&sb, "\n... (%d rows shown; more rows match — aggregate or narrow the query)", n)
+	default:
+		fmt.Fprintf(&sb, "\n(%d rows)", n)
+	}
+	return sb.String(), nil
+}
+
+func formatQueryValue(v any) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		s = string(v)
+	case time.Time:
+		s = v.UTC().Format(time.DateTime)
+	default:
+		s = fmt.Sprint(v)
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) > maxQueryCellLen {
+		s = string([]rune(s)[:maxQueryCellLen]) + "…"
+	}
+	return s
+}
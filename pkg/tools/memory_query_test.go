@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemoryQueryTool() *MemoryQueryTool {
+	return NewMemoryQueryTool([]QueryTable{{
+		Name:    "usage",
+		Columns: []string{"time TEXT", "session_key TEXT", "total_tokens INTEGER"},
+		Doc:     "one row per LLM call",
+		Rows: func(context.Context) ([][]any, error) {
+			return [][]any{
+				{"2026-10-01 09:00:00", "telegram:1", 100},
+				{"2026-10-08 09:00:00", "telegram:1", 200},
+				{"2026-10-09 18:30:00", "discord:2", 300},
+			}, nil
+		},
+	}})
+}
+
+func TestMemoryQueryTool_Select(t *testing.T) {
+	tool := newTestMemoryQueryTool()
+	result := tool.Execute(context.Background(), map[string]any{
+		"query": "SELECT session_key, count(*) AS n, sum(total_tokens) AS tokens FROM usage " +
+			"WHERE time >= '2026-10-07' GROUP BY session_key ORDER BY session_key;",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "session_key | n | tokens\ndiscord:2 | 1 | 300\ntelegram:1 | 1 | 200\n(2 rows)", result.ForLLM)
+	assert.Contains(t, tool.Description(), "usage(time TEXT, session_key TEXT, total_tokens INTEGER)")
+}
+
+func TestMemoryQueryTool_Limit(t *testing.T) {
+	tool := newTestMemoryQueryTool()
+	result := tool.Execute(context.Background(), map[string]any{
+		"query": "WITH t AS (SELECT * FROM usage) SELECT total_tokens FROM t ORDER BY total_tokens",
+		"limit": float64(2),
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	lines := strings.Split(result.ForLLM, "\n")
+	assert.Equal(t, []string{"total_tokens", "100", "200"}, lines[:3])
+	assert.Contains(t, lines[3], "2 rows shown")
+}
+
+func TestMemoryQueryTool_CacheKiB(t *testing.T) {
+	tool := newTestMemoryQueryTool()
+	tool.SetCacheKiB(256)
+	result := tool.Execute(context.Background(), map[string]any{
+		"query": "SELECT (SELECT cache_size FROM pragma_cache_size) AS cache, count(*) AS n FROM usage",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "cache | n\n-256 | 3\n(1 row)", result.ForLLM)
+}
+
+func TestMemoryQueryTool_RejectsWrites(t *testing.T) {
+	tool := newTestMemoryQueryTool()
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty", "  ", "query is required"},
+		{"delete", "DELETE FROM usage", "only SELECT"},
+		{"stacked", "SELECT 1; DROP TABLE usage", "single statement"},
+		{"stacked in comment gap", "SELECT 1 /* x */; ATTACH 'evil.db' AS e", "single statement"},
+		{"cte write", "WITH x AS (SELECT 1) DELETE FROM usage", "DELETE is not allowed"},
+		{"pragma", "SELECT * FROM pragma_table_info('usage') UNION SELECT 1 FROM usage; PRAGMA x", "single statement"},
+		{"breakout", "SELECT 1) UNION SELECT (1", "unbalanced"},
+		{"unterminated", "SELECT 'abc", "unterminated quote"},
+		{"replace into", "WITH x AS (SELECT 1) REPLACE INTO usage VALUES (1, 2, 3)", "INTO is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tool.Execute(context.Background(), map[string]any{"query": tt.query})
+			assert.True(t, result.IsError)
+			assert.Contains(t, result.ForLLM, tt.want)
+		})
+	}
+}
+
+func TestValidateReadOnlySQL_IgnoresKeywordsInLiterals(t *testing.T) {
+	for _, query := range []string{
+		"SELECT count(*) FROM usage WHERE session_key = 'drop; delete'",
+		`SELECT "update" FROM usage -- insert into comment`,
+		"SELECT replace(session_key, ':', '/') FROM usage",
+		"select * from usage /* pragma */ limit 1;",
+	} {
+		_, err := validateReadOnlySQL(query)
+		assert.NoError(t, err, query)
+	}
+}
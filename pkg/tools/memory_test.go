@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func TestMemoryTools_SaveSearchForget(t *testing.T) {
+	facts, err := memory.NewFactStore(t.TempDir())
+	require.NoError(t, err)
+
+	registry := NewToolRegistry()
+	for _, tool := range NewMemoryTools(facts) {
+		registry.Register(tool)
+	}
+	assert.Equal(t, []string{"memory_forget", "memory_save", "memory_search"}, registry.List())
+
+	ctx := context.Background()
+	result := registry.ExecuteWithContext(ctx, "memory_save", map[string]any{
+		"content": "User prefers metric units",
+		"tags":    []any{"preferences", " "},
+	}, "telegram", "42", nil)
+	require.False(t, result.IsError, result.ForLLM)
+	id := regexp.MustCompile(`id: (\w+)`).FindStringSubmatch(result.ForLLM)[1]
+
+	saved, err := facts.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, []string{"preferences"}, saved[0].Tags)
+	assert.Equal(t, "telegram:42", saved[0].Source)
+
+	result = registry.Execute(ctx, "memory_search", map[string]any{"query": "units preferences"})
+	assert.Contains(t, result.ForLLM, "["+id+"] User prefers metric units (tags: preferences)")
+
+	result = registry.Execute(ctx, "memory_forget", map[string]any{"id": id})
+	assert.False(t, result.IsError, result.ForLLM)
+
+	result = registry.Execute(ctx, "memory_search", map[string]any{"query": "units"})
+	assert.Equal(t, "No matching facts in memory.", result.ForLLM)
+
+	result = registry.Execute(ctx, "memory_forget", map[string]any{"id": id})
+	assert.True(t, result.IsError)
+}
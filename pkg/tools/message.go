@@ -3,14 +3,19 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync/atomic"
 )
 
 type SendCallback func(channel, chatID, content string) error
 
+// OptionsCallback sends a message offering the user a choice of replies.
+type OptionsCallback func(channel, chatID, content string, options []string) error
+
 type MessageTool struct {
-	sendCallback SendCallback
-	sentInRound  atomic.Bool // Tracks whether a message was sent in the current processing round
+	sendCallback    SendCallback
+	optionsCallback OptionsCallback
+	sentInRound     atomic.Bool // Tracks whether a message was sent in the current processing round
 }
 
 func NewMessageTool() *MessageTool {
@@ -22,7 +27,8 @@ func (t *MessageTool) Name() string {
 }
 
 func (t *MessageTool) Description() string {
-	return "Send a message to user on a chat channel. Use this when you want to communicate something."
+	return "Send a message to user on a chat channel. Use this when you want to communicate something. " +
+		"To ask the user to choose, list the choices in options; where the chat supports it they become buttons."
 }
 
 func (t *MessageTool) Parameters() map[string]any {
@@ -41,13 +47,18 @@ func (t *MessageTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional: target chat/user ID",
 			},
+			"options": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Optional: short replies the user can pick from; the chosen one comes back as their message",
+			},
 		},
 		"required": []string{"content"},
 	}
 }
 
-// ResetSentInRound resets the per-round send tracker.
-// Called by the agent loop at the start of each inbound message processing round.
+// ResetSentInRound resets the tool-wide send tracker. Turns that run at the
+// same time share it; WithMessageRound tracks a single turn.
 func (t *MessageTool) ResetSentInRound() {
 	t.sentInRound.Store(false)
 }
@@ -57,10 +68,30 @@ func (t *MessageTool) HasSentInRound() bool {
 	return t.sentInRound.Load()
 }
 
+type messageRoundKey struct{}
+
+// WithMessageRound returns a context in which the message tool records
+// whether it sent anything, for SentInMessageRound.
+func WithMessageRound(ctx context.Context) context.Context {
+	return context.WithValue(ctx, messageRoundKey{}, new(atomic.Bool))
+}
+
+// SentInMessageRound reports whether the message tool has sent a message
+// under the round started on ctx by WithMessageRound.
+func SentInMessageRound(ctx context.Context) bool {
+	sent, _ := ctx.Value(messageRoundKey{}).(*atomic.Bool)
+	return sent != nil && sent.Load()
+}
+
 func (t *MessageTool) SetSendCallback(callback SendCallback) {
 	t.sendCallback = callback
 }
 
+// SetOptionsCallback sets the callback used for messages with options.
+func (t *MessageTool) SetOptionsCallback(callback OptionsCallback) {
+	t.optionsCallback = callback
+}
+
 func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	content, ok := args["content"].(string)
 	if !ok {
@@ -81,11 +112,27 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		return &ToolResult{ForLLM: "No target channel/chat specified", IsError: true}
 	}
 
-	if t.sendCallback == nil {
-		return &ToolResult{ForLLM: "Message sending not configured", IsError: true}
+	var options []string
+	if raw, ok := args["options"].([]any); ok {
+		for _, o := range raw {
+			if s, ok := o.(string); ok && strings.TrimSpace(s) != "" {
+				options = append(options, strings.TrimSpace(s))
+			}
+		}
 	}
 
-	if err := t.sendCallback(channel, chatID, content); err != nil {
+	var err error
+	switch {
+	case len(options) > 0 && t.optionsCallback != nil:
+		err = t.optionsCallback(channel, chatID, content, options)
+	case len(options) > 0:
+		return &ToolResult{ForLLM: "Options are not supported here; ask in the message text", IsError: true}
+	case t.sendCallback != nil:
+		err = t.sendCallback(channel, chatID, content)
+	default:
+		return &ToolResult{ForLLM: "Message sending not configured", IsError: true}
+	}
+	if err != nil {
 		return &ToolResult{
 			ForLLM:  fmt.Sprintf("sending message: %v", err),
 			IsError: true,
@@ -94,6 +141,9 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 	}
 
 	t.sentInRound.Store(true)
+	if sent, ok := ctx.Value(messageRoundKey{}).(*atomic.Bool); ok {
+		sent.Store(true)
+	}
 	// Silent: user already received the message directly
 	return &ToolResult{
 		ForLLM: fmt.Sprintf("Message sent to %s:%s", channel, chatID),
@@ -189,6 +189,38 @@ func TestMessageTool_Execute_NotConfigured(t *testing.T) {
 	}
 }
 
+func TestMessageTool_Execute_WithOptions(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetSendCallback(func(channel, chatID, content string) error {
+		t.Error("plain send callback used for a message with options")
+		return nil
+	})
+	var sentOptions []string
+	tool.SetOptionsCallback(func(channel, chatID, content string, options []string) error {
+		sentOptions = options
+		return nil
+	})
+
+	ctx := WithToolContext(context.Background(), "test-channel", "test-chat-id")
+	result := tool.Execute(ctx, map[string]any{
+		"content": "Which one?",
+		"options": []any{"Red", " ", " Blue "},
+	})
+	if result.IsError || !tool.HasSentInRound() {
+		t.Fatalf("result = %+v", result)
+	}
+	if len(sentOptions) != 2 || sentOptions[0] != "Red" || sentOptions[1] != "Blue" {
+		t.Errorf("options = %q, want [Red Blue]", sentOptions)
+	}
+
+	tool = NewMessageTool()
+	tool.SetSendCallback(func(channel, chatID, content string) error { return nil })
+	result = tool.Execute(ctx, map[string]any{"content": "Which one?", "options": []any{"Red"}})
+	if !result.IsError {
+		t.Error("Expected IsError=true without an options callback")
+	}
+}
+
 func TestMessageTool_Name(t *testing.T) {
 	tool := NewMessageTool()
 	if tool.Name() != "message" {
@@ -252,3 +284,20 @@ func TestMessageTool_Parameters(t *testing.T) {
 		t.Error("Expected chat_id type to be 'string'")
 	}
 }
+
+func TestMessageTool_RoundIsPerContext(t *testing.T) {
+	tool := NewMessageTool()
+	tool.SetSendCallback(func(channel, chatID, content string) error { return nil })
+
+	sending := WithMessageRound(WithToolContext(context.Background(), "telegram", "1"))
+	other := WithMessageRound(WithToolContext(context.Background(), "telegram", "2"))
+	if result := tool.Execute(sending, map[string]any{"content": "hi"}); result.IsError {
+		t.Fatalf("result = %+v", result)
+	}
+	if !SentInMessageRound(sending) {
+		t.Error("send not recorded in its round")
+	}
+	if SentInMessageRound(other) || SentInMessageRound(context.Background()) {
+		t.Error("send recorded outside its round")
+	}
+}
@@ -0,0 +1,278 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const maxMQTTPayloadChars = 4000
+
+func init() {
+	RegisterFactory("mqtt", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("mqtt") {
+			return nil, nil
+		}
+		return NewMQTTTool(env.Config.Tools.MQTT)
+	})
+}
+
+// MQTTTool publishes to and reads from topics on an MQTT broker, which is
+// how most home automation and IoT devices are controlled. It connects on
+// first use and stays connected, reconnecting as needed.
+type MQTTTool struct {
+	opts    *mqtt.ClientOptions
+	topics  []string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	client mqtt.Client
+}
+
+// NewMQTTTool creates an MQTTTool from cfg.
+func NewMQTTTool(cfg config.MQTTToolConfig) (*MQTTTool, error) {
+	if cfg.Broker == "" {
+		return nil, errors.New("mqtt: broker is required")
+	}
+	for _, filter := range cfg.Topics {
+		if err := validateMQTTFilter(filter); err != nil {
+			return nil, fmt.Errorf("mqtt: topics: %w", err)
+		}
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "picoclaw-" + uuid.NewString()[:8]
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetConnectTimeout(timeout).
+		SetAutoReconnect(true).
+		SetOrderMatters(false)
+	return &MQTTTool{opts: opts, topics: cfg.Topics, timeout: timeout}, nil
+}
+
+func (t *MQTTTool) Name() string {
+	return "mqtt"
+}
+
+func (t *MQTTTool) Description() string {
+	desc := "Control devices over MQTT. 'publish' sends a payload to a topic, e.g. {\"action\":\"publish\"," +
+		"\"topic\":\"home/lamp/set\",\"payload\":\"ON\"}. 'read' waits for the next message on a topic and " +
+		"returns it; retained state topics answer immediately."
+	if len(t.topics) > 0 {
+		desc += " Allowed topics: " + strings.Join(t.topics, ", ") + "."
+	}
+	return desc
+}
+
+func (t *MQTTTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{"publish", "read"},
+			},
+			"topic": map[string]any{
+				"type":        "string",
+				"description": "Topic to publish to or read from",
+			},
+			"payload": map[string]any{
+				"type":        "string",
+				"description": "Message to publish, often ON/OFF or a JSON object",
+			},
+			"retain": map[string]any{
+				"type":        "boolean",
+				"description": "Ask the broker to keep the message as the topic's current state",
+			},
+			"qos": map[string]any{
+				"type":        "integer",
+				"minimum":     0,
+				"maximum":     2,
+				"description": "MQTT quality of service (default 0)",
+			},
+			"wait_seconds": map[string]any{
+				"type":        "integer",
+				"minimum":     1,
+				"description": "For read: how long to wait for a message (default 5)",
+			},
+		},
+		"required": []string{"action", "topic"},
+	}
+}
+
+func (t *MQTTTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	topic, _ := args["topic"].(string)
+	topic = strings.TrimSpace(topic)
+	if topic == "" {
+		return ErrorResult("topic is required")
+	}
+	if err := t.checkTopic(action, topic); err != nil {
+		return ErrorResult(err.Error())
+	}
+	qos := byte(0)
+	if q, ok := toFloat(args["qos"]); ok {
+		qos = byte(q)
+	}
+
+	client, err := t.connect(ctx)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("mqtt: %v", err)).WithError(err)
+	}
+
+	switch action {
+	case "publish":
+		payload, _ := args["payload"].(string)
+		retain, _ := args["retain"].(bool)
+		if err := waitMQTT(ctx, client.Publish(topic, qos, retain, payload), t.timeout); err != nil {
+			return ErrorResult(fmt.Sprintf("mqtt: publish to %s failed: %v", topic, err)).WithError(err)
+		}
+		return NewToolResult(fmt.Sprintf("Published %q to %s", truncateRunes(payload, 200), topic))
+	case "read":
+		wait := 5 * time.Second
+		if n, ok := toFloat(args["wait_seconds"]); ok && n > 0 {
+			wait = time.Duration(n) * time.Second
+		}
+		return t.read(ctx, client, topic, qos, wait)
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (want publish or read)", action))
+	}
+}
+
+// read subscribes to topic until the first message arrives or wait passes.
+func (t *MQTTTool) read(
+	ctx context.Context,
+	client mqtt.Client,
+	topic string,
+	qos byte,
+	wait time.Duration,
+) *ToolResult {
+	msgs := make(chan mqtt.Message, 1)
+	handler := func(_ mqtt.Client, m mqtt.Message) {
+		select {
+		case msgs <- m:
+		default:
+		}
+	}
+	if err := waitMQTT(ctx, client.Subscribe(topic, qos, handler), t.timeout); err != nil {
+		return ErrorResult(fmt.Sprintf("mqtt: subscribe to %s failed: %v", topic, err)).WithError(err)
+	}
+	defer client.Unsubscribe(topic)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case m := <-msgs:
+		payload := truncateRunes(string(m.Payload()), maxMQTTPayloadChars)
+		if m.Retained() {
+			return NewToolResult(fmt.Sprintf("%s (retained): %s", m.Topic(), payload))
+		}
+		return NewToolResult(fmt.Sprintf("%s: %s", m.Topic(), payload))
+	case <-timer.C:
+		return NewToolResult(fmt.Sprintf("No message on %s within %s", topic, wait))
+	case <-ctx.Done():
+		return ErrorResult("mqtt: read cancelled").WithError(ctx.Err())
+	}
+}
+
+// checkTopic refuses topics outside the configured filters. Wildcards are
+// only allowed for reads, and only when every topic is allowed, since a
+// wildcard could otherwise reach beyond the filters.
+func (t *MQTTTool) checkTopic(action, topic string) error {
+	if strings.ContainsAny(topic, "+#") {
+		if action == "publish" {
+			return errors.New("cannot publish to a topic with wildcards")
+		}
+		if len(t.topics) > 0 {
+			return errors.New("wildcards are not allowed when tools.mqtt.topics is set; read one topic at a time")
+		}
+		return validateMQTTFilter(topic)
+	}
+	if len(t.topics) == 0 {
+		return nil
+	}
+	for _, filter := range t.topics {
+		if mqttTopicMatches(filter, topic) {
+			return nil
+		}
+	}
+	return fmt.Errorf("topic %s is not allowed; allowed topics: %s", topic, strings.Join(t.topics, ", "))
+}
+
+func (t *MQTTTool) connect(ctx context.Context) (mqtt.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// IsConnected stays true while the client reconnects on its own.
+	if t.client != nil && t.client.IsConnected() {
+		return t.client, nil
+	}
+	if t.client == nil {
+		t.client = mqtt.NewClient(t.opts)
+	}
+	if err := waitMQTT(ctx, t.client.Connect(), t.timeout); err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	return t.client, nil
+}
+
+// waitMQTT waits for tok to complete, ctx to end or timeout to pass.
+func waitMQTT(ctx context.Context, tok mqtt.Token, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-tok.Done():
+		return tok.Error()
+	case <-timer.C:
+		return errors.New("timed out")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// mqttTopicMatches reports whether topic matches filter, where "+" matches
+// one level and a trailing "#" matches any number of levels.
+func mqttTopicMatches(filter, topic string) bool {
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+	for i, f := range fl {
+		if f == "#" {
+			return true
+		}
+		if i >= len(tl) || (f != "+" && f != tl[i]) {
+			return false
+		}
+	}
+	return len(fl) == len(tl)
+}
+
+func validateMQTTFilter(filter string) error {
+	if filter == "" {
+		return errors.New("empty topic")
+	}
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#" && i != len(levels)-1:
+			return fmt.Errorf("%s: # must be the last level", filter)
+		case level != "#" && level != "+" && strings.ContainsAny(level, "+#"):
+			return fmt.Errorf("%s: wildcards must fill a whole level", filter)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package tools
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// fakeBroker is a minimal MQTT 3.1.1 broker: it accepts every client,
+// keeps retained messages and forwards publishes to matching subscribers
+// at QoS 0.
+type fakeBroker struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	retained map[string][]byte
+	subs     map[*brokerConn][]string
+}
+
+type brokerConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (c *brokerConn) write(p packets.ControlPacket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = p.Write(c.conn)
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	b := &fakeBroker{ln: ln, retained: map[string][]byte{}, subs: map[*brokerConn][]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&brokerConn{conn: conn})
+		}
+	}()
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *fakeBroker) serve(c *brokerConn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, c)
+		b.mu.Unlock()
+		c.conn.Close()
+	}()
+	for {
+		p, err := packets.ReadPacket(c.conn)
+		if err != nil {
+			return
+		}
+		switch p := p.(type) {
+		case *packets.ConnectPacket:
+			c.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			ack.ReturnCodes = p.Qoss
+			c.write(ack)
+			b.mu.Lock()
+			b.subs[c] = append(b.subs[c], p.Topics...)
+			var retained []*packets.PublishPacket
+			for topic, payload := range b.retained {
+				if slicesMatch(p.Topics, topic) {
+					retained = append(retained, publishPacket(topic, payload, true))
+				}
+			}
+			b.mu.Unlock()
+			for _, r := range retained {
+				c.write(r)
+			}
+		case *packets.UnsubscribePacket:
+			ack := packets.NewControlPacket(packets.Unsuback).(*packets.UnsubackPacket)
+			ack.MessageID = p.MessageID
+			c.write(ack)
+		case *packets.PublishPacket:
+			if p.Qos > 0 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				c.write(ack)
+			}
+			b.mu.Lock()
+			if p.Retain {
+				b.retained[p.TopicName] = p.Payload
+			}
+			var targets []*brokerConn
+			for sub, filters := range b.subs {
+				if slicesMatch(filters, p.TopicName) {
+					targets = append(targets, sub)
+				}
+			}
+			b.mu.Unlock()
+			for _, target := range targets {
+				target.write(publishPacket(p.TopicName, p.Payload, false))
+			}
+		case *packets.PingreqPacket:
+			c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func (b *fakeBroker) retainedPayload(topic string) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	payload, ok := b.retained[topic]
+	return string(payload), ok
+}
+
+func slicesMatch(filters []string, topic string) bool {
+	for _, f := range filters {
+		if mqttTopicMatches(f, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func publishPacket(topic string, payload []byte, retain bool) *packets.PublishPacket {
+	p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	p.TopicName = topic
+	p.Payload = payload
+	p.Retain = retain
+	return p
+}
+
+func TestMQTTTool_PublishAndRead(t *testing.T) {
+	broker := newFakeBroker(t)
+	tool, err := NewMQTTTool(config.MQTTToolConfig{Broker: broker.url(), Topics: []string{"home/+/set", "home/#"}})
+	if err != nil {
+		t.Fatalf("NewMQTTTool: %v", err)
+	}
+	ctx := context.Background()
+
+	result := tool.Execute(ctx, map[string]any{
+		"action": "publish", "topic": "home/lamp/set", "payload": "ON", "retain": true, "qos": 1.0,
+	})
+	if result.IsError {
+		t.Fatalf("publish: %s", result.ForLLM)
+	}
+	if payload, ok := broker.retainedPayload("home/lamp/set"); !ok || payload != "ON" {
+		t.Fatalf("retained = %q, %v", payload, ok)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "read", "topic": "home/lamp/set"})
+	if result.IsError || result.ForLLM != "home/lamp/set (retained): ON" {
+		t.Errorf("read = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "read", "topic": "home/door/state", "wait_seconds": 1.0})
+	if result.IsError || !strings.HasPrefix(result.ForLLM, "No message on home/door/state") {
+		t.Errorf("read without message = %+v", result)
+	}
+}
+
+func TestMQTTTool_TopicRestrictions(t *testing.T) {
+	tool, err := NewMQTTTool(config.MQTTToolConfig{Broker: "tcp://127.0.0.1:1", Topics: []string{"home/+/set"}})
+	if err != nil {
+		t.Fatalf("NewMQTTTool: %v", err)
+	}
+	tests := []struct {
+		action, topic, want string
+	}{
+		{"publish", "office/lamp/set", "not allowed"},
+		{"publish", "home/lamp/set/extra", "not allowed"},
+		{"publish", "home/+/set", "wildcards"},
+		{"read", "home/#", "wildcards are not allowed"},
+	}
+	for _, tt := range tests {
+		result := tool.Execute(context.Background(), map[string]any{"action": tt.action, "topic": tt.topic})
+		if !result.IsError || !strings.Contains(result.ForLLM, tt.want) {
+			t.Errorf("%s %s = %+v, want error containing %q", tt.action, tt.topic, result, tt.want)
+		}
+	}
+
+	if _, err := NewMQTTTool(config.MQTTToolConfig{Broker: "tcp://x", Topics: []string{"home/#/set"}}); err == nil {
+		t.Error("NewMQTTTool accepted a filter with # in the middle")
+	}
+}
+
+func TestMQTTTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"home/lamp", "home/lamp", true},
+		{"home/+", "home/lamp", true},
+		{"home/+", "home/lamp/set", false},
+		{"home/#", "home", true},
+		{"home/#", "home/lamp/set", true},
+		{"#", "anything/at/all", true},
+		{"home/lamp", "home", false},
+	}
+	for _, tt := range tests {
+		if got := mqttTopicMatches(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("mqttTopicMatches(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/notify"
+)
+
+// NotifyTool pushes notifications through the notification router, so
+// alerts reach the user's phone or chat even when nobody is talking to the
+// agent.
+type NotifyTool struct {
+	router *notify.Router
+}
+
+// NewNotifyTool creates a NotifyTool sending through router.
+func NewNotifyTool(router *notify.Router) *NotifyTool {
+	return &NotifyTool{router: router}
+}
+
+func (t *NotifyTool) Name() string {
+	return "notify"
+}
+
+func (t *NotifyTool) Description() string {
+	desc := "Push a notification to the user's configured destinations (phone push, chat, webhook). " +
+		"Use it for alerts and reminders that must reach the user outside this conversation; use message " +
+		"to talk in the conversation. Higher priorities reach more destinations. The same notification is " +
+		"not sent twice within a short time."
+	if names := t.router.Templates(); len(names) > 0 {
+		desc += " Templates: " + strings.Join(names, ", ") + "."
+	}
+	return desc
+}
+
+func (t *NotifyTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"message": map[string]any{
+				"type":        "string",
+				"description": "The notification text (not needed with template)",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Optional short title",
+			},
+			"priority": map[string]any{
+				"type":        "string",
+				"enum":        []string{"low", "normal", "high", "urgent"},
+				"description": "How urgent it is (default normal). Use urgent only when the user must act now",
+			},
+			"template": map[string]any{
+				"type":        "string",
+				"description": "Optional: name of a configured template to render instead of message",
+			},
+			"data": map[string]any{
+				"type":        "object",
+				"description": "Values for the template's fields",
+			},
+			"key": map[string]any{
+				"type":        "string",
+				"description": "Optional: identifies repeats of the same alert, e.g. \"disk-full:/data\"",
+			},
+		},
+	}
+}
+
+func (t *NotifyTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	priorityArg, _ := args["priority"].(string)
+	priority, err := notify.ParsePriority(priorityArg)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+	n := notify.Notification{Priority: priority}
+	n.Title, _ = args["title"].(string)
+	n.Message, _ = args["message"].(string)
+	n.Template, _ = args["template"].(string)
+	n.Data, _ = args["data"].(map[string]any)
+	n.Key, _ = args["key"].(string)
+	if n.Message == "" && n.Template == "" {
+		return ErrorResult("message or template is required")
+	}
+
+	res, err := t.router.Notify(ctx, n)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("notification not sent: %v", err)).WithError(err)
+	}
+	if res.Duplicate {
+		return SilentResult("Not sent: the same notification was sent a short while ago.")
+	}
+	return SilentResult("Notification sent to " + strings.Join(res.Sent, ", "))
+}
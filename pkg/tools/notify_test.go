@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/notify"
+)
+
+func TestNotifyTool(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	}))
+	defer srv.Close()
+	router, err := notify.NewRouter(config.NotificationsConfig{
+		DedupMinutes: 10,
+		Templates:    map[string]string{"door": "{{.who}} is at the door"},
+		Sinks:        []config.NotificationSink{{Name: "phone", Type: "ntfy", URL: srv.URL}},
+	}, bus.NewMessageBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := NewNotifyTool(router)
+	if !strings.Contains(tool.Description(), "Templates: door.") {
+		t.Errorf("description = %q", tool.Description())
+	}
+	ctx := context.Background()
+
+	res := tool.Execute(ctx, map[string]any{"template": "door", "data": map[string]any{"who": "Ann"}})
+	if res.IsError || !res.Silent || res.ForLLM != "Notification sent to phone" {
+		t.Fatalf("result = %+v", res)
+	}
+	res = tool.Execute(ctx, map[string]any{"template": "door", "data": map[string]any{"who": "Ann"}})
+	if res.IsError || !strings.Contains(res.ForLLM, "Not sent") {
+		t.Errorf("repeat result = %+v", res)
+	}
+	if len(bodies) != 1 || bodies[0] != "Ann is at the door" {
+		t.Errorf("sent %q", bodies)
+	}
+
+	if res := tool.Execute(ctx, map[string]any{"message": "x", "priority": "critical"}); !res.IsError {
+		t.Error("unknown priority accepted")
+	}
+	if res := tool.Execute(ctx, map[string]any{}); !res.IsError {
+		t.Error("empty notification accepted")
+	}
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/identity"
+)
+
+// ErrToolDenied marks a tool call refused by the tool policy.
+var ErrToolDenied = errors.New("tool call denied by policy")
+
+// PolicyAction is what the tool policy decides for a call.
+type PolicyAction string
+
+const (
+	PolicyAllow PolicyAction = "allow"
+	PolicyDeny  PolicyAction = "deny"
+	PolicyAsk   PolicyAction = "ask"
+)
+
+// Caller identifies who a tool call is made on behalf of. It is empty for
+// calls not started by a user message, such as cron jobs and heartbeats.
+type Caller struct {
+	SenderID string
+	Sender   bus.SenderInfo
+	PeerKind string // "direct", "group", "channel" or ""
+	Role     string // access role: "owner", "trusted", "guest" or "" when access control is off
+}
+
+// callerID returns the sender ID of caller, preferring the legacy ID the
+// channel reported.
+func callerID(caller Caller) string {
+	if caller.SenderID != "" {
+		return caller.SenderID
+	}
+	return caller.Sender.CanonicalID
+}
+
+var ctxKeyCaller = &toolCtxKey{"caller"}
+
+// WithCaller returns a child context carrying the caller of tool calls.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, ctxKeyCaller, caller)
+}
+
+// ToolCaller extracts the caller from ctx, or the zero Caller if unset.
+func ToolCaller(ctx context.Context) Caller {
+	v, _ := ctx.Value(ctxKeyCaller).(Caller)
+	return v
+}
+
+// ToolPolicy decides per call whether a tool may run, must be approved by
+// the user first, or is refused. See config.ToolPolicyConfig.
+type ToolPolicy struct {
+	rules []config.ToolPolicyRule
+	def   PolicyAction
+}
+
+// NewToolPolicy creates a policy from cfg, which LoadConfig has already
+// validated. Unknown actions are treated as deny.
+func NewToolPolicy(cfg config.ToolPolicyConfig) *ToolPolicy {
+	def := PolicyAction(cfg.Default)
+	if def == "" {
+		def = PolicyAllow
+	}
+	return &ToolPolicy{rules: cfg.Rules, def: def}
+}
+
+// Decide returns the action of the first rule matching the call.
+func (p *ToolPolicy) Decide(tool, channel, chatID string, caller Caller) PolicyAction {
+	action := p.def
+	for _, rule := range p.rules {
+		if ruleMatches(rule, tool, channel, chatID, caller) {
+			action = PolicyAction(rule.Action)
+			break
+		}
+	}
+	switch action {
+	case PolicyAllow, PolicyAsk, PolicyDeny:
+		return action
+	default:
+		return PolicyDeny
+	}
+}
+
+func ruleMatches(rule config.ToolPolicyRule, tool, channel, chatID string, caller Caller) bool {
+	if len(rule.Tools) > 0 && !slices.ContainsFunc(rule.Tools, func(pattern string) bool {
+		ok, _ := path.Match(pattern, tool)
+		return ok
+	}) {
+		return false
+	}
+	if len(rule.Channels) > 0 && !slices.Contains(rule.Channels, channel) {
+		return false
+	}
+	if len(rule.Chats) > 0 && !slices.Contains(rule.Chats, chatID) {
+		return false
+	}
+	if len(rule.Roles) > 0 && !slices.Contains(rule.Roles, caller.Role) {
+		return false
+	}
+	if len(rule.PeerKinds) > 0 && !slices.Contains(rule.PeerKinds, caller.PeerKind) {
+		return false
+	}
+	if len(rule.Users) > 0 && !slices.ContainsFunc(rule.Users, func(user string) bool {
+		return callerMatches(caller, user)
+	}) {
+		return false
+	}
+	return true
+}
+
+// callerMatches compares caller with one allow_from style entry, using the
+// structured sender when the channel provided one.
+func callerMatches(caller Caller, allowed string) bool {
+	if caller.Sender.PlatformID != "" || caller.Sender.CanonicalID != "" {
+		return identity.MatchAllowed(caller.Sender, allowed)
+	}
+	if caller.SenderID == "" {
+		return false
+	}
+	// Legacy sender IDs may be "id|username".
+	id, user, _ := strings.Cut(caller.SenderID, "|")
+	allowed = strings.TrimPrefix(allowed, "@")
+	return caller.SenderID == allowed || id == allowed || (user != "" && user == allowed)
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ownerOnlyExec lets the owner run exec in direct messages, asks before
+// MCP tools and denies exec everywhere else.
+var ownerOnlyExec = config.ToolPolicyConfig{
+	Rules: []config.ToolPolicyRule{
+		{Tools: []string{"exec"}, Users: []string{"telegram:1001"}, PeerKinds: []string{"direct"}, Action: "allow"},
+		{Tools: []string{"exec"}, Action: "deny"},
+		{Tools: []string{"mcp_*"}, Channels: []string{"discord"}, Action: "ask"},
+	},
+}
+
+func TestToolPolicy_Decide(t *testing.T) {
+	owner := bus.SenderInfo{Platform: "telegram", PlatformID: "1001", CanonicalID: "telegram:1001"}
+	stranger := bus.SenderInfo{Platform: "telegram", PlatformID: "2002", CanonicalID: "telegram:2002"}
+	policy := NewToolPolicy(ownerOnlyExec)
+
+	tests := []struct {
+		name    string
+		tool    string
+		channel string
+		caller  Caller
+		want    PolicyAction
+	}{
+		{"owner DM", "exec", "telegram", Caller{Sender: owner, PeerKind: "direct"}, PolicyAllow},
+		{"owner in group", "exec", "telegram", Caller{Sender: owner, PeerKind: "group"}, PolicyDeny},
+		{"stranger DM", "exec", "telegram", Caller{Sender: stranger, PeerKind: "direct"}, PolicyDeny},
+		{"no caller", "exec", "cli", Caller{}, PolicyDeny},
+		{"pattern", "mcp_github_create_issue", "discord", Caller{}, PolicyAsk},
+		{"pattern other channel", "mcp_github_create_issue", "telegram", Caller{}, PolicyAllow},
+		{"default", "read_file", "telegram", Caller{Sender: stranger, PeerKind: "group"}, PolicyAllow},
+	}
+	for _, tt := range tests {
+		if got := policy.Decide(tt.tool, tt.channel, "chat", tt.caller); got != tt.want {
+			t.Errorf("%s: Decide = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// Legacy string sender IDs match as allow_from entries do.
+	legacy := NewToolPolicy(config.ToolPolicyConfig{
+		Default: "deny",
+		Rules:   []config.ToolPolicyRule{{Users: []string{"@alice"}, Action: "allow"}},
+	})
+	if got := legacy.Decide("exec", "slack", "c", Caller{SenderID: "U123|alice"}); got != PolicyAllow {
+		t.Errorf("legacy sender: Decide = %s, want allow", got)
+	}
+	if got := legacy.Decide("exec", "slack", "c", Caller{SenderID: "U999|bob"}); got != PolicyDeny {
+		t.Errorf("other sender: Decide = %s, want deny", got)
+	}
+
+	// Roles come from access control; callers without one do not match.
+	byRole := NewToolPolicy(config.ToolPolicyConfig{
+		Rules: []config.ToolPolicyRule{{Tools: []string{"exec"}, Roles: []string{"guest"}, Action: "deny"}},
+	})
+	for role, want := range map[string]PolicyAction{"owner": PolicyAllow, "guest": PolicyDeny, "": PolicyAllow} {
+		if got := byRole.Decide("exec", "telegram", "c", Caller{Role: role}); got != want {
+			t.Errorf("role %q: Decide = %s, want %s", role, got, want)
+		}
+	}
+}
+
+func TestRegistry_PolicyDenyAndAsk(t *testing.T) {
+	r := NewToolRegistry()
+	store := NewApprovalStore(filepath.Join(t.TempDir(), "pending.json"), 0)
+	r.RequireApproval(store, nil)
+	r.SetPolicy(NewToolPolicy(ownerOnlyExec))
+	r.Register(newMockTool("exec", "run commands"))
+	r.Register(newMockTool("mcp_github_create_issue", "create an issue"))
+
+	group := WithCaller(context.Background(), Caller{SenderID: "telegram:2002", PeerKind: "group"})
+	result := r.ExecuteWithContext(group, "exec", nil, "telegram", "-100", nil)
+	if !result.IsError || !errors.Is(result.Err, ErrToolDenied) {
+		t.Fatalf("group exec = %+v, want denied", result)
+	}
+	// Approval cannot override a deny.
+	if result := r.ExecuteWithContext(WithApproval(group), "exec", nil, "telegram", "-100", nil); !result.IsError {
+		t.Errorf("approved group exec ran: %+v", result)
+	}
+
+	owner := WithCaller(context.Background(), Caller{
+		Sender:   bus.SenderInfo{Platform: "telegram", PlatformID: "1001", CanonicalID: "telegram:1001"},
+		PeerKind: "direct",
+	})
+	if result := r.ExecuteWithContext(owner, "exec", nil, "telegram", "1001", nil); result.IsError {
+		t.Errorf("owner exec = %+v", result)
+	}
+
+	result = r.ExecuteWithContext(context.Background(), "mcp_github_create_issue", nil, "discord", "42", nil)
+	if pending, _ := store.List("discord", "42"); len(pending) != 1 || !strings.Contains(result.ForLLM, "/approve") {
+		t.Errorf("ask rule did not hold the call: %+v", result)
+	}
+}
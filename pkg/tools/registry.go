@@ -2,24 +2,183 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"runtime/debug"
+	"slices"
 	"sort"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/sipeed/picoclaw/pkg/audit"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/telemetry"
 )
 
 type ToolRegistry struct {
-	tools map[string]Tool
-	mu    sync.RWMutex
+	tools        map[string]Tool
+	timeout      time.Duration            // default per-call limit; 0 means none
+	toolTimeouts map[string]time.Duration // per-tool overrides of timeout
+	approvals    *ApprovalStore
+	needApproval map[string]bool
+	jobs         *JobManager
+	policy       *ToolPolicy
+	calls        *memory.ToolCallLog
+	audit        *audit.Log
+	disabled     *toolSwitches
+	mu           sync.RWMutex
+}
+
+// toolSwitches holds the names of the tools turned off at runtime. A
+// registry shares it with its subsets, so turning a tool off hides it from
+// all of them.
+type toolSwitches struct {
+	mu  sync.RWMutex
+	off map[string]bool
+}
+
+func (s *toolSwitches) isOff(name string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.off[name]
 }
 
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
-		tools: make(map[string]Tool),
+		tools:        make(map[string]Tool),
+		toolTimeouts: make(map[string]time.Duration),
+		disabled:     &toolSwitches{},
+	}
+}
+
+// SetTimeout sets how long a tool call may run before the registry gives up
+// on it and returns an error result. Zero (the default) disables the limit.
+func (r *ToolRegistry) SetTimeout(timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeout = timeout
+}
+
+// SetToolTimeout overrides the timeout for one tool. Zero disables the limit
+// for that tool.
+func (r *ToolRegistry) SetToolTimeout(name string, timeout time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.toolTimeouts[name] = timeout
+}
+
+// RequireApproval makes calls to the named tools wait for the user's
+// confirmation: instead of running, such a call is stored in approvals and
+// the user is asked to answer it in the conversation that made it. The call
+// runs once it is re-executed with a context from WithApproval.
+func (r *ToolRegistry) RequireApproval(approvals *ApprovalStore, names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approvals = approvals
+	r.needApproval = make(map[string]bool, len(names))
+	for _, name := range names {
+		r.needApproval[name] = true
+	}
+}
+
+// Approvals returns the store set by RequireApproval, or nil.
+func (r *ToolRegistry) Approvals() *ApprovalStore {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.approvals
+}
+
+// SetJobManager lets calls to tools implementing JobTool run as background
+// jobs in jobs. Without a manager every call runs in the turn that made it.
+func (r *ToolRegistry) SetJobManager(jobs *JobManager) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs = jobs
+}
+
+// Jobs returns the manager set by SetJobManager, or nil.
+func (r *ToolRegistry) Jobs() *JobManager {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.jobs
+}
+
+// SetPolicy makes every call subject to policy, checked before approvals.
+// A nil policy allows everything.
+func (r *ToolRegistry) SetPolicy(policy *ToolPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy = policy
+}
+
+// SetCallLog makes the registry record every call, whatever its outcome,
+// in calls. A nil log disables recording.
+func (r *ToolRegistry) SetCallLog(calls *memory.ToolCallLog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = calls
+}
+
+// SetAuditLog makes the registry audit calls of the tools that write files
+// or run commands.
+func (r *ToolRegistry) SetAuditLog(l *audit.Log) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = l
+}
+
+// SetDisabled turns off the named tools, in r and in the registries made
+// from it by Subset, and turns the other tools back on. A tool that is off
+// stays registered but is not offered to the model and cannot be called.
+func (r *ToolRegistry) SetDisabled(names []string) {
+	off := make(map[string]bool, len(names))
+	for _, name := range names {
+		off[name] = true
+	}
+	r.disabled.mu.Lock()
+	defer r.disabled.mu.Unlock()
+	r.disabled.off = off
+}
+
+func (r *ToolRegistry) policyDecision(ctx context.Context, name, channel, chatID string) PolicyAction {
+	r.mu.RLock()
+	policy := r.policy
+	r.mu.RUnlock()
+	if policy == nil {
+		return PolicyAllow
+	}
+	return policy.Decide(name, channel, chatID, ToolCaller(ctx))
+}
+
+func (r *ToolRegistry) requiresApproval(ctx context.Context, tool Tool, name string, args map[string]any) bool {
+	if isApproved(ctx) {
+		return false
+	}
+	r.mu.RLock()
+	listed := r.needApproval[name]
+	r.mu.RUnlock()
+	if listed {
+		return true
+	}
+	gate, ok := tool.(ApprovalGate)
+	return ok && gate.NeedsApproval(args)
+}
+
+func (r *ToolRegistry) timeoutFor(name string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if timeout, ok := r.toolTimeouts[name]; ok {
+		return timeout
 	}
+	return r.timeout
 }
 
 func (r *ToolRegistry) Register(tool Tool) {
@@ -33,10 +192,54 @@ func (r *ToolRegistry) Register(tool Tool) {
 	r.tools[name] = tool
 }
 
+// RegisterFunc registers a function-backed tool; see NewFuncTool.
+func (r *ToolRegistry) RegisterFunc(name, description string, parameters map[string]any, fn ToolFunc) {
+	r.Register(NewFuncTool(name, description, parameters, fn))
+}
+
+// Unregister removes a tool and reports whether it was registered.
+func (r *ToolRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[name]; !exists {
+		return false
+	}
+	delete(r.tools, name)
+	return true
+}
+
+// Subset returns a registry holding the tools keep accepts, with the same
+// timeouts, approvals, job manager, policy, call log and audit log as r.
+func (r *ToolRegistry) Subset(keep func(name string) bool) *ToolRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sub := &ToolRegistry{
+		tools:        make(map[string]Tool),
+		timeout:      r.timeout,
+		toolTimeouts: maps.Clone(r.toolTimeouts),
+		approvals:    r.approvals,
+		needApproval: maps.Clone(r.needApproval),
+		jobs:         r.jobs,
+		policy:       r.policy,
+		calls:        r.calls,
+		audit:        r.audit,
+		disabled:     r.disabled,
+	}
+	for name, tool := range r.tools {
+		if keep(name) {
+			sub.tools[name] = tool
+		}
+	}
+	return sub
+}
+
 func (r *ToolRegistry) Get(name string) (Tool, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	tool, ok := r.tools[name]
+	if !ok || r.disabled.isOff(name) {
+		return nil, false
+	}
 	return tool, ok
 }
 
@@ -55,6 +258,31 @@ func (r *ToolRegistry) ExecuteWithContext(
 	channel, chatID string,
 	asyncCallback AsyncCallback,
 ) *ToolResult {
+	ctx, span := telemetry.Start(ctx, "tool.execute", attribute.String("picoclaw.tool", name))
+	start := time.Now()
+	result, outcome := r.execute(ctx, name, args, channel, chatID, asyncCallback)
+	r.recordCall(ctx, name, args, channel, chatID, outcome, time.Since(start), result)
+	r.auditCall(ctx, name, args, channel, chatID, outcome, result)
+	span.SetAttributes(attribute.String("picoclaw.tool.outcome", outcome))
+	var err error
+	if result.IsError {
+		if err = result.Err; err == nil {
+			err = errors.New(truncateRunes(result.ForLLM, 200))
+		}
+	}
+	telemetry.End(span, err)
+	return result
+}
+
+// execute runs a call for ExecuteWithContext and reports its outcome for
+// the call log.
+func (r *ToolRegistry) execute(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	channel, chatID string,
+	asyncCallback AsyncCallback,
+) (*ToolResult, string) {
 	logger.InfoCF("tool", "Tool execution started",
 		map[string]any{
 			"tool": name,
@@ -67,30 +295,59 @@ func (r *ToolRegistry) ExecuteWithContext(
 			map[string]any{
 				"tool": name,
 			})
-		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
+		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found")),
+			memory.ToolOutcomeError
 	}
 
 	// Inject channel/chatID into ctx so tools read them via ToolChannel(ctx)/ToolChatID(ctx).
 	// Always inject — tools validate what they require.
 	ctx = WithToolContext(ctx, channel, chatID)
 
-	// If tool implements AsyncExecutor and callback is provided, use ExecuteAsync.
-	// The callback is a call parameter, not mutable state on the tool instance.
-	var result *ToolResult
-	start := time.Now()
-	if asyncExec, ok := tool.(AsyncExecutor); ok && asyncCallback != nil {
-		logger.DebugCF("tool", "Executing async tool via ExecuteAsync",
+	if problems := validateArgs(tool.Parameters(), args); len(problems) > 0 {
+		logger.WarnCF("tool", "Tool arguments rejected",
 			map[string]any{
-				"tool": name,
+				"tool":     name,
+				"problems": problems,
 			})
-		result = asyncExec.ExecuteAsync(ctx, args, asyncCallback)
-	} else {
-		result = tool.Execute(ctx, args)
+		return invalidArgumentsResult(name, problems), memory.ToolOutcomeInvalid
+	}
+
+	decision := r.policyDecision(ctx, name, channel, chatID)
+	if decision == PolicyDeny {
+		logger.WarnCF("tool", "Tool call denied by policy",
+			map[string]any{
+				"tool":    name,
+				"channel": channel,
+				"chat_id": chatID,
+				"sender":  ToolCaller(ctx).SenderID,
+			})
+		return ErrorResult(fmt.Sprintf("%s is not permitted in this conversation", name)).WithError(ErrToolDenied),
+			memory.ToolOutcomeDenied
 	}
+
+	if r.requiresApproval(ctx, tool, name, args) || (decision == PolicyAsk && !isApproved(ctx)) {
+		return r.requestApproval(name, args, channel, chatID), memory.ToolOutcomePending
+	}
+
+	if jobs := r.Jobs(); jobs != nil {
+		if jt, ok := tool.(JobTool); ok && jt.RunsAsJob(args) {
+			job := jobs.Start(ctx, name, func(jobCtx context.Context) *ToolResult {
+				return tool.Execute(jobCtx, args)
+			})
+			return AsyncResult(fmt.Sprintf(
+				"Started %s as background job %s. Its result will be delivered to this conversation when it "+
+					"finishes; do not wait or poll for it.", name, job.ID)), memory.ToolOutcomeStarted
+		}
+	}
+
+	start := time.Now()
+	result := r.run(ctx, tool, name, args, asyncCallback)
 	duration := time.Since(start)
 
 	// Log based on result type
+	outcome := memory.ToolOutcomeOK
 	if result.IsError {
+		outcome = memory.ToolOutcomeError
 		logger.ErrorCF("tool", "Tool execution failed",
 			map[string]any{
 				"tool":     name,
@@ -98,6 +355,7 @@ func (r *ToolRegistry) ExecuteWithContext(
 				"error":    result.ForLLM,
 			})
 	} else if result.Async {
+		outcome = memory.ToolOutcomeStarted
 		logger.InfoCF("tool", "Tool started (async)",
 			map[string]any{
 				"tool":     name,
@@ -112,7 +370,163 @@ func (r *ToolRegistry) ExecuteWithContext(
 			})
 	}
 
-	return result
+	return result, outcome
+}
+
+// recordCall appends a call to the log set by SetCallLog. Failures are
+// logged; they never fail the call.
+func (r *ToolRegistry) recordCall(
+	ctx context.Context,
+	name string,
+	args map[string]any,
+	channel, chatID, outcome string,
+	duration time.Duration,
+	result *ToolResult,
+) {
+	r.mu.RLock()
+	calls := r.calls
+	r.mu.RUnlock()
+	if calls == nil {
+		return
+	}
+	rec := memory.ToolCallRecord{
+		SessionKey: ToolSessionKey(ctx),
+		Channel:    channel,
+		ChatID:     chatID,
+		SenderID:   callerID(ToolCaller(ctx)),
+		Tool:       name,
+		ArgsHash:   memory.HashToolArgs(args),
+		DurationMS: duration.Milliseconds(),
+		Outcome:    outcome,
+	}
+	if result.IsError {
+		msg := result.ForLLM
+		if result.Err != nil {
+			msg = result.Err.Error()
+		}
+		rec.Error = truncateRunes(msg, 200)
+	}
+	if err := calls.Record(ctx, rec); err != nil {
+		logger.WarnCF("tool", "Failed to record tool call",
+			map[string]any{
+				"tool":  name,
+				"error": err.Error(),
+			})
+	}
+}
+
+// requestApproval stores a call that needs confirmation and returns the
+// result asking the user for it.
+func (r *ToolRegistry) requestApproval(name string, args map[string]any, channel, chatID string) *ToolResult {
+	if channel == "" || chatID == "" {
+		return ErrorResult(fmt.Sprintf("%s requires the user's approval, but there is no conversation to ask in", name))
+	}
+	approvals := r.Approvals()
+	if approvals == nil {
+		return ErrorResult(fmt.Sprintf("%s requires the user's approval, but approvals are not set up", name))
+	}
+	p, err := approvals.Add(name, args, channel, chatID)
+	if err != nil {
+		logger.ErrorCF("tool", "Failed to store pending approval",
+			map[string]any{
+				"tool":  name,
+				"error": err.Error(),
+			})
+		return ErrorResult(fmt.Sprintf("%s requires approval, but the request could not be stored: %v", name, err))
+	}
+	logger.InfoCF("tool", "Tool call awaiting approval",
+		map[string]any{
+			"tool":        name,
+			"approval_id": p.ID,
+			"channel":     channel,
+		})
+	return approvalRequired(p, approvals.Expiry())
+}
+
+// run executes tool under the registry's timeout. The call runs in its own
+// goroutine so that a panic becomes an error result instead of crashing the
+// agent, and so that a tool ignoring its context cannot block the turn past
+// the deadline or after ctx is cancelled; such a call is abandoned and left
+// to finish in the background.
+func (r *ToolRegistry) run(
+	ctx context.Context,
+	tool Tool,
+	name string,
+	args map[string]any,
+	asyncCallback AsyncCallback,
+) *ToolResult {
+	asyncExec, isAsync := tool.(AsyncExecutor)
+	isAsync = isAsync && asyncCallback != nil
+
+	timeout := r.timeoutFor(name)
+	var timer <-chan time.Time
+	callCtx := ctx
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+		// Async tools keep using ctx for their background work after
+		// returning, so only synchronous calls get a context that expires.
+		if !isAsync {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+
+	done := make(chan *ToolResult, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				logger.ErrorCF("tool", "Tool panicked",
+					map[string]any{
+						"tool":  name,
+						"panic": fmt.Sprint(p),
+						"stack": string(debug.Stack()),
+					})
+				done <- ErrorResult(fmt.Sprintf("tool %q crashed: %v", name, p)).
+					WithError(fmt.Errorf("tool %q panicked: %v", name, p))
+			}
+		}()
+
+		// If tool implements AsyncExecutor and callback is provided, use ExecuteAsync.
+		// The callback is a call parameter, not mutable state on the tool instance.
+		var result *ToolResult
+		if isAsync {
+			logger.DebugCF("tool", "Executing async tool via ExecuteAsync",
+				map[string]any{
+					"tool": name,
+				})
+			result = asyncExec.ExecuteAsync(callCtx, args, asyncCallback)
+		} else {
+			result = tool.Execute(callCtx, args)
+		}
+		if result == nil {
+			result = ErrorResult(fmt.Sprintf("tool %q returned no result", name))
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-timer:
+		return ErrorResult(fmt.Sprintf("tool %q timed out after %s", name, timeout)).
+			WithError(context.DeadlineExceeded)
+	case <-callCtx.Done():
+		// The tool may have returned at the same moment; prefer its result.
+		select {
+		case result := <-done:
+			return result
+		default:
+		}
+		if errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return ErrorResult(fmt.Sprintf("tool %q timed out after %s", name, timeout)).
+				WithError(context.DeadlineExceeded)
+		}
+		return ErrorResult(fmt.Sprintf("tool %q was cancelled: %v", name, callCtx.Err())).
+			WithError(callCtx.Err())
+	}
 }
 
 // sortedToolNames returns tool names in sorted order for deterministic iteration.
@@ -122,7 +536,9 @@ func (r *ToolRegistry) ExecuteWithContext(
 func (r *ToolRegistry) sortedToolNames() []string {
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
-		names = append(names, name)
+		if !r.disabled.isOff(name) {
+			names = append(names, name)
+		}
 	}
 	sort.Strings(names)
 	return names
@@ -174,7 +590,7 @@ func (r *ToolRegistry) ToProviderDefs() []providers.ToolDefinition {
 	return definitions
 }
 
-// List returns a list of all registered tool names.
+// List returns the names of the registered tools that are not turned off.
 func (r *ToolRegistry) List() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -182,11 +598,21 @@ func (r *ToolRegistry) List() []string {
 	return r.sortedToolNames()
 }
 
-// Count returns the number of registered tools.
+// ListAll returns the names of all registered tools, including those
+// turned off by SetDisabled.
+func (r *ToolRegistry) ListAll() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := slices.Collect(maps.Keys(r.tools))
+	sort.Strings(names)
+	return names
+}
+
+// Count returns the number of registered tools that are not turned off.
 func (r *ToolRegistry) Count() int {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return len(r.tools)
+	return len(r.sortedToolNames())
 }
 
 // GetSummaries returns human-readable summaries of all registered tools.
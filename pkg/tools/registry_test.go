@@ -2,10 +2,14 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/sipeed/picoclaw/pkg/audit"
+	"github.com/sipeed/picoclaw/pkg/memory"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -45,6 +49,25 @@ func (m *mockAsyncRegistryTool) ExecuteAsync(_ context.Context, args map[string]
 	return m.result
 }
 
+type mockFuncRegistryTool struct {
+	mockRegistryTool
+	fn func(ctx context.Context) *ToolResult
+}
+
+func (m *mockFuncRegistryTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	return m.fn(ctx)
+}
+
+type ctxCapturingAsyncTool struct {
+	*mockAsyncRegistryTool
+	ctx *context.Context
+}
+
+func (m *ctxCapturingAsyncTool) ExecuteAsync(ctx context.Context, args map[string]any, cb AsyncCallback) *ToolResult {
+	*m.ctx = ctx
+	return m.mockAsyncRegistryTool.ExecuteAsync(ctx, args, cb)
+}
+
 // --- helpers ---
 
 func newMockTool(name, desc string) *mockRegistryTool {
@@ -358,3 +381,260 @@ func TestToolRegistry_ConcurrentAccess(t *testing.T) {
 		t.Error("expected tools to be registered after concurrent access")
 	}
 }
+
+func TestToolRegistry_RegisterFunc(t *testing.T) {
+	r := NewToolRegistry()
+	r.RegisterFunc("greet", "say hello", nil, func(_ context.Context, args map[string]any) *ToolResult {
+		name, _ := args["name"].(string)
+		return NewToolResult("hello " + name)
+	})
+
+	result := r.Execute(context.Background(), "greet", map[string]any{"name": "pico"})
+	if result.IsError || result.ForLLM != "hello pico" {
+		t.Errorf("result = %+v, want hello pico", result)
+	}
+
+	defs := r.ToProviderDefs()
+	if len(defs) != 1 || defs[0].Function.Name != "greet" || defs[0].Function.Description != "say hello" {
+		t.Fatalf("defs = %+v", defs)
+	}
+	if defs[0].Function.Parameters["type"] != "object" {
+		t.Errorf("parameters = %v, want an empty object schema", defs[0].Function.Parameters)
+	}
+}
+
+func TestToolRegistry_Unregister(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("a", ""))
+
+	if !r.Unregister("a") {
+		t.Error("Unregister(a) = false, want true")
+	}
+	if r.Unregister("a") {
+		t.Error("second Unregister(a) = true, want false")
+	}
+	if _, ok := r.Get("a"); ok {
+		t.Error("tool still registered")
+	}
+}
+
+func TestToolRegistry_Execute_RecoversPanic(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(&mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("boom", ""),
+		fn:               func(context.Context) *ToolResult { panic("nil map write") },
+	})
+
+	result := r.Execute(context.Background(), "boom", nil)
+	if !result.IsError {
+		t.Fatal("expected an error result from a panicking tool")
+	}
+	if !strings.Contains(result.ForLLM, "crashed") || !strings.Contains(result.ForLLM, "nil map write") {
+		t.Errorf("ForLLM = %q, want crash message", result.ForLLM)
+	}
+}
+
+func TestToolRegistry_Execute_NilResult(t *testing.T) {
+	r := NewToolRegistry()
+	tool := newMockTool("nil", "")
+	tool.result = nil
+	r.Register(tool)
+
+	if result := r.Execute(context.Background(), "nil", nil); !result.IsError {
+		t.Error("expected an error result for a nil tool result")
+	}
+}
+
+func TestToolRegistry_Execute_Timeout(t *testing.T) {
+	r := NewToolRegistry()
+	r.SetTimeout(20 * time.Millisecond)
+	release := make(chan struct{})
+	defer close(release)
+
+	// Ignores its context, so only the registry can stop waiting for it.
+	r.Register(&mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("stuck", ""),
+		fn: func(context.Context) *ToolResult {
+			<-release
+			return SilentResult("late")
+		},
+	})
+	// Honors its context deadline.
+	var sawDeadline bool
+	r.Register(&mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("polite", ""),
+		fn: func(ctx context.Context) *ToolResult {
+			_, sawDeadline = ctx.Deadline()
+			return SilentResult("ok")
+		},
+	})
+
+	start := time.Now()
+	result := r.Execute(context.Background(), "stuck", nil)
+	if !result.IsError || !strings.Contains(result.ForLLM, "timed out") {
+		t.Fatalf("result = %+v, want timeout error", result)
+	}
+	if !errors.Is(result.Err, context.DeadlineExceeded) {
+		t.Errorf("Err = %v, want DeadlineExceeded", result.Err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Execute took %v, want about the timeout", elapsed)
+	}
+
+	if result := r.Execute(context.Background(), "polite", nil); result.IsError || !sawDeadline {
+		t.Errorf("polite tool: result=%+v sawDeadline=%v", result, sawDeadline)
+	}
+
+	r.SetToolTimeout("polite", 0)
+	if result := r.Execute(context.Background(), "polite", nil); result.IsError || sawDeadline {
+		t.Errorf("override 0 should remove the deadline: result=%+v sawDeadline=%v", result, sawDeadline)
+	}
+}
+
+func TestToolRegistry_Execute_Cancelled(t *testing.T) {
+	r := NewToolRegistry()
+	release := make(chan struct{})
+	defer close(release)
+	r.Register(&mockFuncRegistryTool{
+		mockRegistryTool: *newMockTool("stuck", ""),
+		fn: func(context.Context) *ToolResult {
+			<-release
+			return SilentResult("late")
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	result := r.Execute(ctx, "stuck", nil)
+	if !result.IsError || !strings.Contains(result.ForLLM, "cancelled") {
+		t.Fatalf("result = %+v, want cancellation error", result)
+	}
+	if !errors.Is(result.Err, context.Canceled) {
+		t.Errorf("Err = %v, want Canceled", result.Err)
+	}
+}
+
+func TestToolRegistry_ExecuteAsync_ContextOutlivesCall(t *testing.T) {
+	r := NewToolRegistry()
+	r.SetTimeout(time.Minute)
+	var asyncCtx context.Context
+	tool := &mockAsyncRegistryTool{mockRegistryTool: *newMockTool("bg", "")}
+	tool.result = AsyncResult("started")
+	r.Register(&ctxCapturingAsyncTool{mockAsyncRegistryTool: tool, ctx: &asyncCtx})
+
+	r.ExecuteWithContext(context.Background(), "bg", nil, "", "", func(context.Context, *ToolResult) {})
+	if asyncCtx == nil || asyncCtx.Err() != nil {
+		t.Errorf("async tool context must stay usable after the call returns, got %v", asyncCtx)
+	}
+}
+
+func TestToolRegistry_RecordsCalls(t *testing.T) {
+	calls, err := memory.NewToolCallLog(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewToolCallLog: %v", err)
+	}
+	r := NewToolRegistry()
+	r.SetCallLog(calls)
+	r.Register(newMockTool("ok_tool", ""))
+	failing := newMockTool("failing", "")
+	failing.result = ErrorResult("boom")
+	r.Register(failing)
+	r.Register(&mockRegistryTool{name: "strict", params: testSchema, result: SilentResult("ok")})
+
+	ctx := WithCaller(WithSessionKey(context.Background(), "agent:main:telegram:1"), Caller{SenderID: "1001"})
+	r.ExecuteWithContext(ctx, "ok_tool", map[string]any{"path": "a.txt"}, "telegram", "1", nil)
+	r.ExecuteWithContext(ctx, "failing", nil, "telegram", "1", nil)
+	r.ExecuteWithContext(ctx, "strict", map[string]any{}, "telegram", "1", nil)
+	r.ExecuteWithContext(ctx, "missing", nil, "telegram", "1", nil)
+
+	records, err := calls.Records(context.Background(), memory.ToolCallFilter{})
+	if err != nil {
+		t.Fatalf("Records: %v", err)
+	}
+	want := []struct{ tool, outcome string }{
+		{"ok_tool", memory.ToolOutcomeOK},
+		{"failing", memory.ToolOutcomeError},
+		{"strict", memory.ToolOutcomeInvalid},
+		{"missing", memory.ToolOutcomeError},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("recorded %d calls, want %d: %+v", len(records), len(want), records)
+	}
+	for i, w := range want {
+		rec := records[i]
+		if rec.Tool != w.tool || rec.Outcome != w.outcome {
+			t.Errorf("record %d = %s/%s, want %s/%s", i, rec.Tool, rec.Outcome, w.tool, w.outcome)
+		}
+		if rec.SessionKey != "agent:main:telegram:1" || rec.SenderID != "1001" || rec.Channel != "telegram" {
+			t.Errorf("record %d context = %+v", i, rec)
+		}
+	}
+	if records[0].ArgsHash != memory.HashToolArgs(map[string]any{"path": "a.txt"}) {
+		t.Errorf("args hash = %q", records[0].ArgsHash)
+	}
+	if records[1].Error != "boom" {
+		t.Errorf("error = %q, want boom", records[1].Error)
+	}
+}
+
+func TestToolRegistry_AuditsWritesAndShell(t *testing.T) {
+	log, err := audit.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("audit.Open: %v", err)
+	}
+	r := NewToolRegistry()
+	r.SetAuditLog(log)
+	r.Register(newMockTool("write_file", ""))
+	failing := newMockTool("exec", "")
+	failing.result = ErrorResult("permission denied")
+	r.Register(failing)
+	r.Register(newMockTool("read_file", ""))
+
+	ctx := WithCaller(WithSessionKey(context.Background(), "agent:main:telegram:1"), Caller{SenderID: "1001"})
+	r.ExecuteWithContext(ctx, "write_file", map[string]any{"path": "notes.md"}, "telegram", "1", nil)
+	r.ExecuteWithContext(ctx, "exec", map[string]any{"command": "rm -rf /"}, "telegram", "1", nil)
+	r.ExecuteWithContext(ctx, "read_file", map[string]any{"path": "notes.md"}, "telegram", "1", nil)
+
+	events, err := log.Query(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("audited %d calls, want 2: %+v", len(events), events)
+	}
+	if e := events[0]; e.Kind != audit.FileWrite || e.Target != "notes.md" || e.Outcome != memory.ToolOutcomeOK ||
+		e.SessionKey != "agent:main:telegram:1" {
+		t.Errorf("write event = %+v", e)
+	}
+	if e := events[1]; e.Kind != audit.ShellExec || e.Target != "rm -rf /" || e.Detail != "permission denied" {
+		t.Errorf("exec event = %+v", e)
+	}
+}
+
+func TestToolRegistry_SetDisabled(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(newMockTool("exec", ""))
+	r.Register(newMockTool("read_file", ""))
+	sub := r.Subset(func(string) bool { return true })
+
+	r.SetDisabled([]string{"exec"})
+	for _, reg := range []*ToolRegistry{r, sub} {
+		if names := reg.List(); len(names) != 1 || names[0] != "read_file" {
+			t.Errorf("List = %v, want [read_file]", names)
+		}
+		if len(reg.ToProviderDefs()) != 1 || reg.Count() != 1 {
+			t.Errorf("disabled tool still offered: %d defs, count %d", len(reg.ToProviderDefs()), reg.Count())
+		}
+		if res := reg.Execute(context.Background(), "exec", nil); !res.IsError {
+			t.Error("disabled tool ran")
+		}
+	}
+	if names := r.ListAll(); len(names) != 2 {
+		t.Errorf("ListAll = %v, want both tools", names)
+	}
+
+	r.SetDisabled(nil)
+	if res := sub.Execute(context.Background(), "exec", nil); res.IsError {
+		t.Errorf("re-enabled tool failed: %s", res.ForLLM)
+	}
+}
@@ -0,0 +1,293 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adhocore/gronx"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// minScheduleEvery is the shortest interval a recurring schedule may use.
+const minScheduleEvery = time.Minute
+
+// scheduleTimeLayouts are the accepted formats for an absolute "at" time,
+// interpreted in the requested timezone (default: local time).
+var scheduleTimeLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// ScheduleTool lets the model create, list and cancel one-shot and recurring
+// tasks for the current conversation. Tasks are stored as jobs of the cron
+// service, so they survive restarts and fire from the gateway's scheduler.
+type ScheduleTool struct {
+	cronService *cron.CronService
+	now         func() time.Time
+}
+
+// NewScheduleTool creates a ScheduleTool backed by cronService.
+func NewScheduleTool(cronService *cron.CronService) *ScheduleTool {
+	return &ScheduleTool{cronService: cronService, now: time.Now}
+}
+
+func (t *ScheduleTool) Name() string {
+	return "schedule"
+}
+
+func (t *ScheduleTool) Description() string {
+	return "Create, list or cancel future tasks for this conversation. " +
+		"For \"remind me tomorrow at 9\" use action=create with at=\"YYYY-MM-DD 09:00\" (resolve the date from the " +
+		"current time). Use 'in' for relative times (\"10m\", \"2h\"), 'every' for fixed intervals and 'cron' for " +
+		"calendar schedules. mode=remind sends the message as-is when due; mode=task runs it as an instruction " +
+		"and reports the result."
+}
+
+func (t *ScheduleTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type":        "string",
+				"enum":        []string{"create", "list", "cancel"},
+				"description": "Action to perform",
+			},
+			"message": map[string]any{
+				"type":        "string",
+				"description": "Reminder text (mode=remind) or instruction to carry out (mode=task)",
+			},
+			"at": map[string]any{
+				"type":        "string",
+				"description": "One-shot absolute time: \"YYYY-MM-DD HH:MM\", RFC 3339, or \"HH:MM\" for the next occurrence",
+			},
+			"in": map[string]any{
+				"type":        "string",
+				"description": "One-shot relative time as a duration, e.g. \"90s\", \"10m\", \"1h30m\"",
+			},
+			"every": map[string]any{
+				"type":        "string",
+				"description": "Recurring interval as a duration, e.g. \"30m\", \"24h\" (minimum 1m)",
+			},
+			"cron": map[string]any{
+				"type":        "string",
+				"description": "Recurring cron expression, e.g. \"0 9 * * 1-5\" for weekdays at 9:00",
+			},
+			"timezone": map[string]any{
+				"type":        "string",
+				"description": "IANA timezone for 'at', e.g. \"Europe/Berlin\" (default: device local time)",
+			},
+			"mode": map[string]any{
+				"type":        "string",
+				"enum":        []string{"remind", "task"},
+				"description": "remind (default) delivers the message; task has the agent run it",
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "Task ID to cancel (see list)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ScheduleTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	switch action {
+	case "create":
+		return t.create(ctx, args)
+	case "list":
+		return t.list(ctx)
+	case "cancel":
+		return t.cancel(ctx, args)
+	case "":
+		return ErrorResult("action is required")
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action: %s", action))
+	}
+}
+
+func (t *ScheduleTool) create(ctx context.Context, args map[string]any) *ToolResult {
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+	if channel == "" || chatID == "" {
+		return ErrorResult("no session context (channel/chat_id not set). Use this tool in an active conversation.")
+	}
+
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return ErrorResult("message is required for create")
+	}
+
+	mode, _ := args["mode"].(string)
+	if mode == "" {
+		mode = "remind"
+	}
+	if mode != "remind" && mode != "task" {
+		return ErrorResult(fmt.Sprintf("unknown mode: %s (use remind or task)", mode))
+	}
+
+	schedule, err := t.parseSchedule(args)
+	if err != nil {
+		return ErrorResult(err.Error())
+	}
+
+	job, err := t.cronService.AddJob(utils.Truncate(message, 30), schedule, message, mode == "remind", channel, chatID)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to schedule task: %v", err))
+	}
+
+	return SilentResult(fmt.Sprintf("Scheduled %q (id: %s, %s, next run: %s)",
+		job.Name, job.ID, describeSchedule(job.Schedule), formatNextRun(job)))
+}
+
+// parseSchedule builds a cron schedule from exactly one of at, in, every or cron.
+func (t *ScheduleTool) parseSchedule(args map[string]any) (cron.CronSchedule, error) {
+	var given []string
+	for _, key := range []string{"at", "in", "every", "cron"} {
+		if v, _ := args[key].(string); strings.TrimSpace(v) != "" {
+			given = append(given, key)
+		}
+	}
+	if len(given) != 1 {
+		return cron.CronSchedule{}, fmt.Errorf("exactly one of at, in, every or cron is required")
+	}
+	value := strings.TrimSpace(args[given[0]].(string))
+	now := t.now()
+
+	switch given[0] {
+	case "at":
+		tzName, _ := args["timezone"].(string)
+		at, err := parseScheduleTime(value, tzName, now)
+		if err != nil {
+			return cron.CronSchedule{}, err
+		}
+		if !at.After(now) {
+			return cron.CronSchedule{}, fmt.Errorf("at %s is in the past", at.Format("2006-01-02 15:04 MST"))
+		}
+		atMS := at.UnixMilli()
+		return cron.CronSchedule{Kind: "at", AtMS: &atMS}, nil
+	case "in":
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return cron.CronSchedule{}, fmt.Errorf("invalid in %q: use a positive duration like 10m or 2h", value)
+		}
+		atMS := now.Add(d).UnixMilli()
+		return cron.CronSchedule{Kind: "at", AtMS: &atMS}, nil
+	case "every":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < minScheduleEvery {
+			return cron.CronSchedule{}, fmt.Errorf("invalid every %q: use a duration of at least %s", value, minScheduleEvery)
+		}
+		everyMS := d.Milliseconds()
+		return cron.CronSchedule{Kind: "every", EveryMS: &everyMS}, nil
+	default:
+		if !gronx.New().IsValid(value) {
+			return cron.CronSchedule{}, fmt.Errorf("invalid cron expression %q", value)
+		}
+		return cron.CronSchedule{Kind: "cron", Expr: value}, nil
+	}
+}
+
+// parseScheduleTime parses an absolute time in tzName (local time if empty).
+// A bare "HH:MM" means its next occurrence after now.
+func parseScheduleTime(value, tzName string, now time.Time) (time.Time, error) {
+	loc := now.Location()
+	if tzName != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzName); err != nil {
+			return time.Time{}, fmt.Errorf("unknown timezone %q", tzName)
+		}
+	}
+
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	for _, layout := range scheduleTimeLayouts {
+		if at, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return at, nil
+		}
+	}
+	if clock, err := time.ParseInLocation("15:04", value, loc); err == nil {
+		local := now.In(loc)
+		at := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid at %q: use \"YYYY-MM-DD HH:MM\", RFC 3339 or \"HH:MM\"", value)
+}
+
+func (t *ScheduleTool) list(ctx context.Context) *ToolResult {
+	channel, chatID := ToolChannel(ctx), ToolChatID(ctx)
+
+	var lines []string
+	for _, job := range t.cronService.ListJobs(false) {
+		if !ownsJob(job, channel, chatID) {
+			continue
+		}
+		mode := "task"
+		if job.Payload.Deliver {
+			mode = "remind"
+		}
+		lines = append(lines, fmt.Sprintf("- %s (id: %s, %s, %s, next run: %s)",
+			job.Name, job.ID, mode, describeSchedule(job.Schedule), formatNextRun(&job)))
+	}
+	if len(lines) == 0 {
+		return SilentResult("No scheduled tasks")
+	}
+	return SilentResult("Scheduled tasks:\n" + strings.Join(lines, "\n"))
+}
+
+func (t *ScheduleTool) cancel(ctx context.Context, args map[string]any) *ToolResult {
+	id, _ := args["id"].(string)
+	if id == "" {
+		return ErrorResult("id is required for cancel")
+	}
+
+	for _, job := range t.cronService.ListJobs(true) {
+		if job.ID != id {
+			continue
+		}
+		if !ownsJob(job, ToolChannel(ctx), ToolChatID(ctx)) {
+			return ErrorResult(fmt.Sprintf("task %s belongs to another conversation", id))
+		}
+		t.cronService.RemoveJob(id)
+		return SilentResult(fmt.Sprintf("Cancelled task %q (id: %s)", job.Name, id))
+	}
+	return ErrorResult(fmt.Sprintf("task %s not found", id))
+}
+
+// ownsJob reports whether job was scheduled from the given conversation.
+// Without a conversation context (e.g. the CLI) every job is visible.
+func ownsJob(job cron.CronJob, channel, chatID string) bool {
+	if channel == "" || chatID == "" {
+		return true
+	}
+	return job.Payload.Channel == channel && job.Payload.To == chatID
+}
+
+func describeSchedule(s cron.CronSchedule) string {
+	switch {
+	case s.Kind == "at":
+		return "one-time"
+	case s.Kind == "every" && s.EveryMS != nil:
+		return "every " + (time.Duration(*s.EveryMS) * time.Millisecond).String()
+	case s.Kind == "cron":
+		return "cron " + s.Expr
+	default:
+		return "unknown"
+	}
+}
+
+func formatNextRun(job *cron.CronJob) string {
+	if job.State.NextRunAtMS == nil {
+		return "none"
+	}
+	return time.UnixMilli(*job.State.NextRunAtMS).Format("2006-01-02 15:04 MST")
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/cron"
+)
+
+func newTestScheduleTool(t *testing.T, now time.Time) (*ScheduleTool, *cron.CronService) {
+	t.Helper()
+	cs := cron.NewCronService(filepath.Join(t.TempDir(), "cron", "jobs.json"), nil)
+	tool := NewScheduleTool(cs)
+	tool.now = func() time.Time { return now }
+	return tool, cs
+}
+
+func TestScheduleTool_CreateReminderAt(t *testing.T) {
+	now := time.Now().Truncate(time.Minute)
+	tool, cs := newTestScheduleTool(t, now)
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+
+	tomorrow := now.AddDate(0, 0, 1)
+	at := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 9, 0, 0, 0, time.Local)
+	result := tool.Execute(ctx, map[string]any{
+		"action":  "create",
+		"message": "Call the dentist",
+		"at":      at.Format("2006-01-02 15:04"),
+	})
+	require.False(t, result.IsError, result.ForLLM)
+
+	jobs := cs.ListJobs(false)
+	require.Len(t, jobs, 1)
+	job := jobs[0]
+	assert.Equal(t, "at", job.Schedule.Kind)
+	assert.Equal(t, at.UnixMilli(), *job.Schedule.AtMS)
+	assert.True(t, job.Payload.Deliver)
+	assert.True(t, job.DeleteAfterRun)
+	assert.Equal(t, "telegram", job.Payload.Channel)
+	assert.Equal(t, "42", job.Payload.To)
+}
+
+func TestScheduleTool_CreateRecurringTask(t *testing.T) {
+	tool, cs := newTestScheduleTool(t, time.Now())
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+
+	result := tool.Execute(ctx, map[string]any{
+		"action":  "create",
+		"message": "Summarize unread mail",
+		"cron":    "0 8 * * 1-5",
+		"mode":    "task",
+	})
+	require.False(t, result.IsError, result.ForLLM)
+
+	jobs := cs.ListJobs(false)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "cron", jobs[0].Schedule.Kind)
+	assert.False(t, jobs[0].Payload.Deliver)
+	assert.NotNil(t, jobs[0].State.NextRunAtMS)
+}
+
+func TestScheduleTool_CreateValidation(t *testing.T) {
+	tool, _ := newTestScheduleTool(t, time.Now())
+	ctx := WithToolContext(context.Background(), "telegram", "42")
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"no schedule", map[string]any{"message": "x"}, "exactly one of"},
+		{"two schedules", map[string]any{"message": "x", "in": "1h", "every": "1h"}, "exactly one of"},
+		{"past time", map[string]any{"message": "x", "at": "2001-01-01 09:00"}, "in the past"},
+		{"bad duration", map[string]any{"message": "x", "in": "soon"}, "invalid in"},
+		{"too frequent", map[string]any{"message": "x", "every": "5s"}, "at least"},
+		{"bad cron", map[string]any{"message": "x", "cron": "every day"}, "invalid cron"},
+		{"bad timezone", map[string]any{"message": "x", "at": "10:00", "timezone": "Mars/Base"}, "unknown timezone"},
+		{"no message", map[string]any{"in": "1h"}, "message is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args["action"] = "create"
+			result := tool.Execute(ctx, tt.args)
+			assert.True(t, result.IsError)
+			assert.Contains(t, result.ForLLM, tt.want)
+		})
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{"action": "create", "message": "x", "in": "1h"})
+	assert.True(t, result.IsError, "create without a conversation should fail")
+}
+
+func TestScheduleTool_ListAndCancelAreScopedToConversation(t *testing.T) {
+	tool, cs := newTestScheduleTool(t, time.Now())
+	alice := WithToolContext(context.Background(), "telegram", "alice")
+	bob := WithToolContext(context.Background(), "telegram", "bob")
+
+	result := tool.Execute(alice, map[string]any{"action": "create", "message": "Water plants", "in": "1h"})
+	require.False(t, result.IsError, result.ForLLM)
+	result = tool.Execute(bob, map[string]any{"action": "create", "message": "Feed cat", "every": "12h"})
+	require.False(t, result.IsError, result.ForLLM)
+
+	list := tool.Execute(alice, map[string]any{"action": "list"})
+	assert.Contains(t, list.ForLLM, "Water plants")
+	assert.NotContains(t, list.ForLLM, "Feed cat")
+
+	var bobJobID string
+	for _, job := range cs.ListJobs(false) {
+		if job.Payload.To == "bob" {
+			bobJobID = job.ID
+		}
+	}
+	result = tool.Execute(alice, map[string]any{"action": "cancel", "id": bobJobID})
+	assert.True(t, result.IsError)
+	assert.Len(t, cs.ListJobs(false), 2)
+
+	result = tool.Execute(bob, map[string]any{"action": "cancel", "id": bobJobID})
+	assert.False(t, result.IsError, result.ForLLM)
+	assert.Len(t, cs.ListJobs(false), 1)
+
+	list = tool.Execute(bob, map[string]any{"action": "list"})
+	assert.True(t, strings.HasPrefix(list.ForLLM, "No scheduled tasks"))
+}
+
+func TestParseScheduleTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	now := time.Date(2026, 10, 14, 20, 30, 0, 0, berlin)
+
+	at, err := parseScheduleTime("09:00", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 15, 9, 0, 0, 0, berlin), at, "past clock time rolls over to tomorrow")
+
+	at, err = parseScheduleTime("21:15", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 14, 21, 15, 0, 0, berlin), at)
+
+	at, err = parseScheduleTime("2026-10-15 09:00", "UTC", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC).Unix(), at.Unix())
+
+	at, err = parseScheduleTime("2026-10-15T09:00:00+02:00", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 15, 9, 0, 0, 0, berlin).Unix(), at.Unix())
+
+	_, err = parseScheduleTime("tomorrow", "", now)
+	assert.Error(t, err)
+}
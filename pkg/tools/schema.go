@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidArguments marks a tool call rejected because its arguments do
+// not match the tool's parameter schema.
+var ErrInvalidArguments = errors.New("invalid tool arguments")
+
+// validateArgs checks args against a tool's parameter schema and returns
+// one message per problem. It covers the JSON Schema subset tool schemas
+// use: type, properties, required, enum, items, minimum and maximum. Other
+// keywords are ignored, so schemas from elsewhere (MCP servers) are never
+// rejected for what this does not understand. Properties missing from the
+// schema are allowed, and null counts as absent.
+func validateArgs(schema map[string]any, args map[string]any) []string {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var problems []string
+	validateValue("", schema, args, &problems)
+	return problems
+}
+
+func validateValue(path string, schema map[string]any, v any, problems *[]string) {
+	report := func(format string, a ...any) {
+		name := path
+		if name == "" {
+			name = "arguments"
+		}
+		*problems = append(*problems, name+": "+fmt.Sprintf(format, a...))
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool {
+		return matchesType(t, v)
+	}) {
+		report("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(v))
+		return
+	}
+
+	if enum := anySlice(schema["enum"]); enum != nil && !slices.ContainsFunc(enum, func(e any) bool {
+		return jsonEqual(e, v)
+	}) {
+		report("must be one of %s, got %s", formatEnum(enum), formatJSON(v))
+		return
+	}
+
+	if n, ok := toFloat(v); ok {
+		if lo, ok := toFloat(schema["minimum"]); ok && n < lo {
+			report("must be at least %v, got %v", lo, n)
+		}
+		if hi, ok := toFloat(schema["maximum"]); ok && n > hi {
+			report("must be at most %v, got %v", hi, n)
+		}
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, name := range anySlice(schema["required"]) {
+			key, _ := name.(string)
+			if val[key] == nil {
+				*problems = append(*problems, joinPath(path, key)+": required")
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			propSchema, ok := props[key].(map[string]any)
+			if ok && val[key] != nil {
+				validateValue(joinPath(path, key), propSchema, val[key], problems)
+			}
+		}
+	default:
+		itemSchema, ok := schema["items"].(map[string]any)
+		if !ok {
+			return
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return
+		}
+		for i := range rv.Len() {
+			validateValue(fmt.Sprintf("%s[%d]", path, i), itemSchema, rv.Index(i).Interface(), problems)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// schemaTypes reads "type", which may be a single name or a list.
+func schemaTypes(v any) []string {
+	if s, ok := v.(string); ok {
+		return []string{s}
+	}
+	var types []string
+	for _, t := range anySlice(v) {
+		if s, ok := t.(string); ok {
+			types = append(types, s)
+		}
+	}
+	return types
+}
+
+func matchesType(t string, v any) bool {
+	switch t {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "integer":
+		n, ok := toFloat(v)
+		return ok && n == float64(int64(n))
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		return v != nil && reflect.TypeOf(v).Kind() == reflect.Slice
+	case "null":
+		return v == nil
+	default:
+		return true
+	}
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	if reflect.TypeOf(v).Kind() == reflect.Slice {
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// toFloat converts JSON numbers (float64 after decoding) and the Go
+// integer types callers pass directly.
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// anySlice returns the elements of a []any or typed slice such as the
+// []string tools use for enum and required, or nil.
+func anySlice(v any) []any {
+	if v == nil {
+		return nil
+	}
+	if s, ok := v.([]any); ok {
+		return s
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	out := make([]any, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
+func jsonEqual(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func formatJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return truncateRunes(string(data), 100)
+}
+
+func formatEnum(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = formatJSON(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// invalidArgumentsResult describes problems so the model can correct the
+// call and try again.
+func invalidArgumentsResult(name string, problems []string) *ToolResult {
+	msg := fmt.Sprintf("Invalid arguments for tool %q:\n- %s\nFix the arguments and call the tool again.",
+		name, strings.Join(problems, "\n- "))
+	return ErrorResult(msg).WithError(fmt.Errorf("%w: %s", ErrInvalidArguments, strings.Join(problems, "; ")))
+}
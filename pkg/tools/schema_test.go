@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var testSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"action": map[string]any{"type": "string", "enum": []string{"list", "add"}},
+		"count":  map[string]any{"type": "integer", "minimum": 1.0, "maximum": 10.0},
+		"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		"options": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"dry_run": map[string]any{"type": "boolean"}},
+		},
+		"note": map[string]any{"type": []any{"string", "null"}},
+	},
+	"required": []string{"action"},
+}
+
+func TestValidateArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]any
+		want []string
+	}{
+		{"valid", map[string]any{"action": "add", "count": 3.0, "tags": []any{"a"}, "extra": 1}, nil},
+		{"go ints and typed slices", map[string]any{"action": "list", "count": 3, "tags": []string{"a"}}, nil},
+		{"null optional", map[string]any{"action": "list", "count": nil, "note": nil}, nil},
+		{"missing required", map[string]any{}, []string{"action: required"}},
+		{"nil args", nil, []string{"action: required"}},
+		{
+			"wrong types",
+			map[string]any{"action": "list", "count": "3", "options": map[string]any{"dry_run": "yes"}},
+			[]string{"count: expected integer, got string", "options.dry_run: expected boolean, got string"},
+		},
+		{"fraction", map[string]any{"action": "list", "count": 2.5}, []string{"count: expected integer, got number"}},
+		{"range", map[string]any{"action": "list", "count": 0.0}, []string{"count: must be at least 1, got 0"}},
+		{"enum", map[string]any{"action": "delete"}, []string{`action: must be one of "list", "add", got "delete"`}},
+		{
+			"items",
+			map[string]any{"action": "list", "tags": []any{"a", 2.0}},
+			[]string{"tags[1]: expected string, got number"},
+		},
+	}
+	for _, tt := range tests {
+		got := validateArgs(testSchema, tt.args)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: problems = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateArgs_UnknownKeywordsIgnored(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"value": map[string]any{"anyOf": []any{map[string]any{"type": "string"}}, "type": "mystery"},
+		},
+	}
+	if problems := validateArgs(schema, map[string]any{"value": 1.0}); len(problems) != 0 {
+		t.Errorf("problems = %q", problems)
+	}
+}
+
+func TestRegistry_RejectsInvalidArguments(t *testing.T) {
+	called := false
+	tool := &mockFuncRegistryTool{
+		mockRegistryTool: mockRegistryTool{name: "counter", params: testSchema},
+		fn: func(context.Context) *ToolResult {
+			called = true
+			return SilentResult("ok")
+		},
+	}
+	r := NewToolRegistry()
+	r.Register(tool)
+
+	result := r.Execute(context.Background(), "counter", map[string]any{"count": 20.0})
+	if called {
+		t.Fatal("tool ran with invalid arguments")
+	}
+	if !result.IsError || !errors.Is(result.Err, ErrInvalidArguments) {
+		t.Fatalf("result = %+v", result)
+	}
+	for _, want := range []string{"action: required", "count: must be at most 10, got 20", "call the tool again"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("ForLLM missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+
+	if result := r.Execute(context.Background(), "counter", map[string]any{"action": "list"}); result.IsError || !called {
+		t.Errorf("valid call failed: %+v", result)
+	}
+}
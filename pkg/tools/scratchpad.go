@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxScratchpadBuffers       = 32
+	maxScratchpadBufferChars   = 200_000
+	maxScratchpadNameChars     = 64
+	defaultScratchpadReadChars = 8000
+)
+
+// ScratchpadStore keeps the named buffers of each session.
+// session.SessionManager implements it.
+type ScratchpadStore interface {
+	GetScratchpad(sessionKey, name string) (string, bool)
+	SetScratchpad(sessionKey, name, content string)
+	DeleteScratchpad(sessionKey, name string) bool
+	ScratchpadNames(sessionKey string) []string
+	Save(sessionKey string) error
+}
+
+// ScratchpadTool lets the model stash intermediate results in named buffers
+// that belong to the session and outlive the context window, then read them
+// back piece by piece in later steps.
+type ScratchpadTool struct {
+	store ScratchpadStore
+}
+
+// NewScratchpadTool creates a ScratchpadTool over store.
+func NewScratchpadTool(store ScratchpadStore) *ScratchpadTool {
+	return &ScratchpadTool{store: store}
+}
+
+func (t *ScratchpadTool) Name() string {
+	return "scratchpad"
+}
+
+func (t *ScratchpadTool) Description() string {
+	return "Named text buffers for this conversation. Stash intermediate results that are too long to keep " +
+		"in context (notes, partial drafts, collected data) with 'write' or 'append', and fetch them later " +
+		"with 'read', in chunks via offset and length. 'list' shows the buffers, 'delete' removes one."
+}
+
+func (t *ScratchpadTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{"write", "append", "read", "list", "delete"},
+			},
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Buffer name, e.g. \"findings\" (not needed for list)",
+			},
+			"content": map[string]any{
+				"type":        "string",
+				"description": "For write and append: the text to store",
+			},
+			"offset": map[string]any{
+				"type":        "integer",
+				"minimum":     0,
+				"description": "For read: first character to return (default 0)",
+			},
+			"length": map[string]any{
+				"type":        "integer",
+				"minimum":     1,
+				"description": fmt.Sprintf("For read: number of characters to return (default %d)", defaultScratchpadReadChars),
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ScratchpadTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	sessionKey := ToolSessionKey(ctx)
+	if sessionKey == "" {
+		return ErrorResult("scratchpad is only available inside a conversation")
+	}
+	action, _ := args["action"].(string)
+	if action == "list" {
+		return t.list(sessionKey)
+	}
+
+	name, _ := args["name"].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ErrorResult("name is required")
+	}
+	if utf8.RuneCountInString(name) > maxScratchpadNameChars {
+		return ErrorResult(fmt.Sprintf("name must be at most %d characters", maxScratchpadNameChars))
+	}
+
+	switch action {
+	case "write", "append":
+		content, _ := args["content"].(string)
+		return t.write(sessionKey, name, content, action == "append")
+	case "read":
+		offset, length := 0, defaultScratchpadReadChars
+		if n, ok := toFloat(args["offset"]); ok && n > 0 {
+			offset = int(n)
+		}
+		if n, ok := toFloat(args["length"]); ok && n >= 1 {
+			length = int(n)
+		}
+		return t.read(sessionKey, name, offset, length)
+	case "delete":
+		if !t.store.DeleteScratchpad(sessionKey, name) {
+			return ErrorResult(fmt.Sprintf("no buffer named %q", name))
+		}
+		return t.save(sessionKey, SilentResult(fmt.Sprintf("Deleted buffer %q", name)))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (want write, append, read, list or delete)", action))
+	}
+}
+
+func (t *ScratchpadTool) write(sessionKey, name, content string, appendTo bool) *ToolResult {
+	existing, exists := t.store.GetScratchpad(sessionKey, name)
+	if !exists && len(t.store.ScratchpadNames(sessionKey)) >= maxScratchpadBuffers {
+		return ErrorResult(fmt.Sprintf("too many buffers (max %d); delete one first", maxScratchpadBuffers))
+	}
+	if appendTo {
+		content = existing + content
+	}
+	size := utf8.RuneCountInString(content)
+	if size > maxScratchpadBufferChars {
+		return ErrorResult(fmt.Sprintf("buffer %q would hold %d characters (max %d)",
+			name, size, maxScratchpadBufferChars))
+	}
+	t.store.SetScratchpad(sessionKey, name, content)
+	return t.save(sessionKey, SilentResult(fmt.Sprintf("Buffer %q now holds %d characters", name, size)))
+}
+
+func (t *ScratchpadTool) read(sessionKey, name string, offset, length int) *ToolResult {
+	content, ok := t.store.GetScratchpad(sessionKey, name)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("no buffer named %q", name))
+	}
+	runes := []rune(content)
+	if offset >= len(runes) {
+		return NewToolResult(fmt.Sprintf("Buffer %q holds %d characters; nothing at offset %d",
+			name, len(runes), offset))
+	}
+	end := min(offset+length, len(runes))
+	header := fmt.Sprintf("[%s: characters %d-%d of %d]", name, offset, end, len(runes))
+	if end < len(runes) {
+		header += fmt.Sprintf(" (continue with offset %d)", end)
+	}
+	return NewToolResult(header + "\n" + string(runes[offset:end]))
+}
+
+func (t *ScratchpadTool) list(sessionKey string) *ToolResult {
+	names := t.store.ScratchpadNames(sessionKey)
+	if len(names) == 0 {
+		return NewToolResult("No buffers")
+	}
+	var sb strings.Builder
+	sb.WriteString("Buffers:")
+	for _, name := range names {
+		content, _ := t.store.GetScratchpad(sessionKey, name)
+		fmt.Fprintf(&sb, "\n- %s (%d characters): %s", name, utf8.RuneCountInString(content),
+			truncateRunes(strings.Join(strings.Fields(content), " "), 80))
+	}
+	return NewToolResult(sb.String())
+}
+
+// save persists the session so buffers survive a restart mid-turn.
+func (t *ScratchpadTool) save(sessionKey string, result *ToolResult) *ToolResult {
+	if err := t.store.Save(sessionKey); err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save buffer: %v", err)).WithError(err)
+	}
+	return result
+}
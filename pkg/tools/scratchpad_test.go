@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestScratchpadTool_WriteAppendRead(t *testing.T) {
+	sm := session.NewSessionManager(t.TempDir())
+	tool := NewScratchpadTool(sm)
+	ctx := WithSessionKey(context.Background(), "cli:direct")
+
+	result := tool.Execute(ctx, map[string]any{"action": "write", "name": "notes", "content": "hello "})
+	if result.IsError {
+		t.Fatalf("write: %s", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "append", "name": "notes", "content": "world"})
+	if result.IsError || !strings.Contains(result.ForLLM, "11 characters") {
+		t.Fatalf("append = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "read", "name": "notes", "offset": 6.0, "length": 3.0})
+	want := "[notes: characters 6-9 of 11] (continue with offset 9)\nwor"
+	if result.IsError || result.ForLLM != want {
+		t.Errorf("read = %q, want %q", result.ForLLM, want)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, "- notes (11 characters): hello world") {
+		t.Errorf("list = %q", result.ForLLM)
+	}
+
+	// Buffers belong to one session.
+	other := WithSessionKey(context.Background(), "cli:other")
+	if result := tool.Execute(other, map[string]any{"action": "read", "name": "notes"}); !result.IsError {
+		t.Errorf("read from another session = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "delete", "name": "notes"})
+	if result.IsError {
+		t.Fatalf("delete: %s", result.ForLLM)
+	}
+	if got := tool.Execute(ctx, map[string]any{"action": "list"}); got.ForLLM != "No buffers" {
+		t.Errorf("list after delete = %q", got.ForLLM)
+	}
+}
+
+func TestScratchpadTool_Limits(t *testing.T) {
+	tool := NewScratchpadTool(session.NewSessionManager(""))
+	ctx := WithSessionKey(context.Background(), "cli:direct")
+
+	if result := tool.Execute(context.Background(), map[string]any{"action": "list"}); !result.IsError {
+		t.Error("scratchpad without a session should fail")
+	}
+
+	big := strings.Repeat("x", maxScratchpadBufferChars)
+	if result := tool.Execute(ctx, map[string]any{"action": "write", "name": "big", "content": big}); result.IsError {
+		t.Fatalf("write at limit: %s", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "append", "name": "big", "content": "y"}); !result.IsError {
+		t.Error("append over the size limit should fail")
+	}
+
+	for i := 1; i < maxScratchpadBuffers; i++ {
+		tool.Execute(ctx, map[string]any{"action": "write", "name": strings.Repeat("n", i), "content": "x"})
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "write", "name": "extra", "content": "x"}); !result.IsError {
+		t.Error("write over the buffer limit should fail")
+	}
+}
@@ -148,3 +148,38 @@ func detectMediaType(path string) string {
 
 	return "application/octet-stream"
 }
+
+// storeMediaBytes writes data to a temporary file and registers it with
+// store for delivery to the chat in ctx. The store owns the file
+// afterwards. It returns the media ref.
+func storeMediaBytes(
+	ctx context.Context,
+	store media.MediaStore,
+	data []byte,
+	filename, contentType, source string,
+) (string, error) {
+	f, err := os.CreateTemp("", "picoclaw-*"+filepath.Ext(filename))
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	scope := fmt.Sprintf("%s:%s:%s", source, ToolChannel(ctx), ToolChatID(ctx))
+	ref, err := store.Store(f.Name(), media.MediaMeta{
+		Filename:    filename,
+		ContentType: contentType,
+		Source:      source,
+	}, scope)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return ref, nil
+}
@@ -23,8 +23,22 @@ type ExecTool struct {
 	allowPatterns       []*regexp.Regexp
 	customAllowPatterns []*regexp.Regexp
 	restrictToWorkspace bool
+	cpuSeconds          int
+	maxOutputChars      int
+	askApproval         bool
+	approver            ExecApprover
 }
 
+// ExecApprover asks the user whether a command that matched a deny pattern
+// may run anyway. It is consulted only when approval is set to "ask".
+type ExecApprover func(ctx context.Context, command string) bool
+
+const (
+	defaultMaxOutputChars = 10000
+
+	blockedDangerous = "Command blocked by safety guard (dangerous pattern detected)"
+)
+
 var (
 	defaultDenyPatterns = []*regexp.Regexp{
 		regexp.MustCompile(`\brm\s+-[rf]{1,2}\b`),
@@ -127,6 +141,11 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 			}
 			customAllowPatterns = append(customAllowPatterns, re)
 		}
+		switch execConfig.Approval {
+		case "", "deny", "ask":
+		default:
+			return nil, fmt.Errorf("invalid exec approval %q (want deny or ask)", execConfig.Approval)
+		}
 	} else {
 		denyPatterns = append(denyPatterns, defaultDenyPatterns...)
 	}
@@ -136,14 +155,27 @@ func NewExecToolWithConfig(workingDir string, restrict bool, config *config.Conf
 		timeout = time.Duration(config.Tools.Exec.TimeoutSeconds) * time.Second
 	}
 
-	return &ExecTool{
+	tool := &ExecTool{
 		workingDir:          workingDir,
 		timeout:             timeout,
 		denyPatterns:        denyPatterns,
 		allowPatterns:       nil,
 		customAllowPatterns: customAllowPatterns,
 		restrictToWorkspace: restrict,
-	}, nil
+		maxOutputChars:      defaultMaxOutputChars,
+	}
+	if config != nil {
+		execConfig := config.Tools.Exec
+		if err := tool.SetAllowPatterns(execConfig.AllowPatterns); err != nil {
+			return nil, err
+		}
+		tool.cpuSeconds = execConfig.CPUSeconds
+		if execConfig.MaxOutputChars > 0 {
+			tool.maxOutputChars = execConfig.MaxOutputChars
+		}
+		tool.askApproval = execConfig.Approval == "ask"
+	}
+	return tool, nil
 }
 
 func (t *ExecTool) Name() string {
@@ -166,11 +198,23 @@ func (t *ExecTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional working directory for the command",
 			},
+			"background": map[string]any{
+				"type": "boolean",
+				"description": "Run as a background job so the conversation can continue; the output is " +
+					"delivered when the command finishes (the usual exec timeout still applies)",
+			},
 		},
 		"required": []string{"command"},
 	}
 }
 
+// RunsAsJob implements JobTool: commands called with background=true run
+// as background jobs when the registry has a job manager.
+func (t *ExecTool) RunsAsJob(args map[string]any) bool {
+	background, _ := args["background"].(bool)
+	return background
+}
+
 func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	command, ok := args["command"].(string)
 	if !ok {
@@ -197,7 +241,14 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		}
 	}
 
-	if guardError := t.guardCommand(command, cwd); guardError != "" {
+	guardError := t.guardCommand(command, cwd)
+	if guardError == blockedDangerous && t.askApproval {
+		if t.approver == nil || !t.approver(ctx, command) {
+			return ErrorResult(blockedDangerous + ": the user did not approve it")
+		}
+		guardError = t.checkCommand(command, cwd, true)
+	}
+	if guardError != "" {
 		return ErrorResult(guardError)
 	}
 
@@ -215,6 +266,10 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	if runtime.GOOS == "windows" {
 		cmd = exec.CommandContext(cmdCtx, "powershell", "-NoProfile", "-NonInteractive", "-Command", command)
 	} else {
+		if t.cpuSeconds > 0 {
+			// The limit is inherited by everything the shell starts.
+			command = fmt.Sprintf("ulimit -t %d || exit 1\n%s", t.cpuSeconds, command)
+		}
 		cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
 	}
 	if cwd != "" {
@@ -223,9 +278,10 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 
 	prepareCommandForTermination(cmd)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout := &cappedBuffer{limit: t.maxOutputChars}
+	stderr := &cappedBuffer{limit: t.maxOutputChars}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	if err := cmd.Start(); err != nil {
 		return ErrorResult(fmt.Sprintf("failed to start command: %v", err))
@@ -255,6 +311,7 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 	if stderr.Len() > 0 {
 		output += "\nSTDERR:\n" + stderr.String()
 	}
+	dropped := stdout.dropped + stderr.dropped
 
 	if err != nil {
 		if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
@@ -272,9 +329,13 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 		output = "(no output)"
 	}
 
-	maxLen := 10000
-	if len(output) > maxLen {
-		output = output[:maxLen] + fmt.Sprintf("\n... (truncated, %d more chars)", len(output)-maxLen)
+	maxLen := t.maxOutputChars
+	if len(output) > maxLen || dropped > 0 {
+		if len(output) > maxLen {
+			dropped += len(output) - maxLen
+			output = output[:maxLen]
+		}
+		output += fmt.Sprintf("\n... (truncated, %d more chars)", dropped)
 	}
 
 	if err != nil {
@@ -293,11 +354,17 @@ func (t *ExecTool) Execute(ctx context.Context, args map[string]any) *ToolResult
 }
 
 func (t *ExecTool) guardCommand(command, cwd string) string {
+	return t.checkCommand(command, cwd, false)
+}
+
+// checkCommand returns why command may not run in cwd, or "". An approved
+// command skips the deny patterns but not the allowlist or workspace checks.
+func (t *ExecTool) checkCommand(command, cwd string, approved bool) string {
 	cmd := strings.TrimSpace(command)
 	lower := strings.ToLower(cmd)
 
 	// Custom allow patterns exempt a command from deny checks.
-	explicitlyAllowed := false
+	explicitlyAllowed := approved
 	for _, pattern := range t.customAllowPatterns {
 		if pattern.MatchString(lower) {
 			explicitlyAllowed = true
@@ -308,7 +375,7 @@ func (t *ExecTool) guardCommand(command, cwd string) string {
 	if !explicitlyAllowed {
 		for _, pattern := range t.denyPatterns {
 			if pattern.MatchString(lower) {
-				return "Command blocked by safety guard (dangerous pattern detected)"
+				return blockedDangerous
 			}
 		}
 	}
@@ -370,6 +437,12 @@ func (t *ExecTool) SetRestrictToWorkspace(restrict bool) {
 	t.restrictToWorkspace = restrict
 }
 
+// SetApprover sets the function asked about dangerous commands when
+// approval is "ask". Without one, such commands stay blocked.
+func (t *ExecTool) SetApprover(approver ExecApprover) {
+	t.approver = approver
+}
+
 func (t *ExecTool) SetAllowPatterns(patterns []string) error {
 	t.allowPatterns = make([]*regexp.Regexp, 0, len(patterns))
 	for _, p := range patterns {
@@ -381,3 +454,25 @@ func (t *ExecTool) SetAllowPatterns(patterns []string) error {
 	}
 	return nil
 }
+
+// cappedBuffer keeps the first limit bytes written to it and counts the rest,
+// so a command flooding its output cannot exhaust memory.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit   int
+	dropped int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - b.Len()
+	if room <= 0 {
+		b.dropped += len(p)
+		return len(p), nil
+	}
+	if len(p) > room {
+		b.dropped += len(p) - room
+		b.Buffer.Write(p[:room])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -443,3 +443,97 @@ func TestShellTool_CustomAllowPatterns(t *testing.T) {
 		t.Errorf("'git push upstream main' should still be blocked by deny pattern")
 	}
 }
+
+func TestShellTool_AllowPatternsRestrictCommands(t *testing.T) {
+	cfg := &config.Config{
+		Tools: config.ToolsConfig{
+			Exec: config.ExecConfig{AllowPatterns: []string{`^echo\b`}},
+		},
+	}
+	tool, err := NewExecToolWithConfig("", false, cfg)
+	if err != nil {
+		t.Fatalf("unable to configure exec tool: %s", err)
+	}
+
+	if result := tool.Execute(context.Background(), map[string]any{"command": "echo hi"}); result.IsError {
+		t.Errorf("allowlisted command failed: %s", result.ForLLM)
+	}
+	result := tool.Execute(context.Background(), map[string]any{"command": "ls"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "not in allowlist") {
+		t.Errorf("expected allowlist block, got: %s", result.ForLLM)
+	}
+}
+
+func TestShellTool_MaxOutputChars(t *testing.T) {
+	cfg := &config.Config{
+		Tools: config.ToolsConfig{
+			Exec: config.ExecConfig{MaxOutputChars: 100},
+		},
+	}
+	tool, err := NewExecToolWithConfig("", false, cfg)
+	if err != nil {
+		t.Fatalf("unable to configure exec tool: %s", err)
+	}
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"command": "for i in $(seq 1 500); do echo 0123456789; done",
+	})
+	if !strings.HasPrefix(result.ForLLM, "0123456789\n") {
+		t.Errorf("output should keep its beginning, got: %q", result.ForLLM[:20])
+	}
+	if !strings.Contains(result.ForLLM, "(truncated, 5400 more chars)") {
+		t.Errorf("expected truncation note, got: %s", result.ForLLM[100:])
+	}
+}
+
+func TestShellTool_ApprovalAsk(t *testing.T) {
+	cfg := &config.Config{
+		Tools: config.ToolsConfig{
+			Exec: config.ExecConfig{EnableDenyPatterns: true, Approval: "ask"},
+		},
+	}
+	tool, err := NewExecToolWithConfig(t.TempDir(), true, cfg)
+	if err != nil {
+		t.Fatalf("unable to configure exec tool: %s", err)
+	}
+	args := map[string]any{"command": "echo x > f && rm -rf f && echo removed"}
+
+	result := tool.Execute(context.Background(), args)
+	if !result.IsError || !strings.Contains(result.ForLLM, "did not approve") {
+		t.Errorf("without an approver the command should stay blocked, got: %s", result.ForLLM)
+	}
+
+	var asked string
+	tool.SetApprover(func(_ context.Context, command string) bool {
+		asked = command
+		return false
+	})
+	if result := tool.Execute(context.Background(), args); !result.IsError {
+		t.Errorf("denied command ran: %s", result.ForLLM)
+	}
+	if asked != args["command"] {
+		t.Errorf("approver asked about %q", asked)
+	}
+
+	tool.SetApprover(func(context.Context, string) bool { return true })
+	if result := tool.Execute(context.Background(), args); result.IsError || !strings.Contains(result.ForLLM, "removed") {
+		t.Errorf("approved command failed: %s", result.ForLLM)
+	}
+
+	// Approval lifts deny patterns only, not the workspace restriction.
+	result = tool.Execute(context.Background(), map[string]any{"command": "rm -rf /tmp/outside"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "path outside working dir") {
+		t.Errorf("expected workspace block, got: %s", result.ForLLM)
+	}
+}
+
+func TestShellTool_InvalidApproval(t *testing.T) {
+	cfg := &config.Config{
+		Tools: config.ToolsConfig{
+			Exec: config.ExecConfig{Approval: "maybe"},
+		},
+	}
+	if _, err := NewExecToolWithConfig("", false, cfg); err == nil {
+		t.Error("expected error for invalid approval mode")
+	}
+}
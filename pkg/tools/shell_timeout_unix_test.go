@@ -11,6 +11,8 @@ import (
 	"syscall"
 	"testing"
 	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
 )
 
 func processExists(pid int) bool {
@@ -63,3 +65,24 @@ func TestShellTool_TimeoutKillsChildProcess(t *testing.T) {
 
 	t.Fatalf("child process %d is still running after timeout", childPID)
 }
+
+func TestShellTool_CPUSecondsLimit(t *testing.T) {
+	cfg := &config.Config{
+		Tools: config.ToolsConfig{
+			Exec: config.ExecConfig{CPUSeconds: 1, TimeoutSeconds: 30},
+		},
+	}
+	tool, err := NewExecToolWithConfig(t.TempDir(), false, cfg)
+	if err != nil {
+		t.Fatalf("unable to configure exec tool: %s", err)
+	}
+
+	start := time.Now()
+	result := tool.Execute(context.Background(), map[string]any{"command": "while :; do :; done"})
+	if !result.IsError {
+		t.Fatalf("expected CPU limit to stop the command, got: %s", result.ForLLM)
+	}
+	if elapsed := time.Since(start); elapsed > 15*time.Second {
+		t.Errorf("command ran for %v, CPU limit was not applied", elapsed)
+	}
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// SkillTool runs a program that a skill declares in its tools.json. The
+// arguments of a call go to the program as a JSON object on stdin; what it
+// prints on stdout is the result, and exiting non-zero makes it an error
+// with stderr attached.
+type SkillTool struct {
+	spec      skills.ToolSpec
+	workspace string
+}
+
+// NewSkillTool creates the tool for spec. workspace is passed to the
+// program as PICOCLAW_WORKSPACE.
+func NewSkillTool(spec skills.ToolSpec, workspace string) *SkillTool {
+	return &SkillTool{spec: spec, workspace: workspace}
+}
+
+func (t *SkillTool) Name() string {
+	return t.spec.FullName()
+}
+
+func (t *SkillTool) Description() string {
+	return t.spec.Description + " (from the " + t.spec.Skill + " skill)"
+}
+
+func (t *SkillTool) Parameters() map[string]any {
+	if t.spec.Parameters != nil {
+		return t.spec.Parameters
+	}
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+// Timeout is the timeout the skill asks for, 0 for the registry's default.
+func (t *SkillTool) Timeout() time.Duration {
+	return time.Duration(t.spec.TimeoutSeconds) * time.Second
+}
+
+func (t *SkillTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	input, err := json.Marshal(args)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("encode arguments: %v", err))
+	}
+
+	cmd := exec.CommandContext(ctx, t.spec.Command[0], t.spec.Command[1:]...)
+	cmd.Dir = t.spec.Dir
+	cmd.Env = append(os.Environ(),
+		"PICOCLAW_WORKSPACE="+t.workspace,
+		"PICOCLAW_SKILL_DIR="+t.spec.Dir,
+		"PICOCLAW_CHANNEL="+ToolChannel(ctx),
+		"PICOCLAW_CHAT_ID="+ToolChatID(ctx),
+	)
+	cmd.Stdin = strings.NewReader(string(input))
+	stdout := &cappedBuffer{limit: defaultMaxOutputChars}
+	stderr := &cappedBuffer{limit: defaultMaxOutputChars}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	prepareCommandForTermination(cmd)
+	cmd.Cancel = func() error { return terminateProcessTree(cmd) }
+	// Children that keep the pipes open must not hold the call up.
+	cmd.WaitDelay = 2 * time.Second
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return ErrorResult(fmt.Sprintf("%s was stopped: %v", t.Name(), context.Cause(ctx)))
+	}
+	return programResult(t.Name(), stdout, stderr, err)
+}
+
+// programResult turns what a tool program printed and how it exited into a
+// result: stdout on success, an error with stderr attached otherwise.
+func programResult(name string, stdout, stderr *cappedBuffer, err error) *ToolResult {
+	output := strings.TrimRight(stdout.String(), "\n")
+	if stdout.dropped > 0 {
+		output += fmt.Sprintf("\n... (truncated, %d more chars)", stdout.dropped)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("%s failed: %v", name, err)
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			msg += "\nSTDERR:\n" + s
+		}
+		if output != "" {
+			msg += "\nSTDOUT:\n" + output
+		}
+		return &ToolResult{ForLLM: msg, IsError: true, Err: err}
+	}
+	if output == "" {
+		output = "(no output)"
+	}
+	return NewToolResult(output)
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+func skillScript(t *testing.T, script string) skills.ToolSpec {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"+script), 0o755))
+	return skills.ToolSpec{
+		Skill: "garden", Name: "water", Description: "Water a pot", Command: []string{"./run.sh"}, Dir: dir,
+	}
+}
+
+func TestSkillTool_Execute(t *testing.T) {
+	tool := NewSkillTool(skillScript(t, `cat; echo; echo "in $PICOCLAW_WORKSPACE"`), "/ws")
+
+	assert.Equal(t, "skill_garden_water", tool.Name())
+	assert.Contains(t, tool.Description(), "garden skill")
+	result := tool.Execute(context.Background(), map[string]any{"pot": "basil"})
+
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "{\"pot\":\"basil\"}\nin /ws", result.ForLLM)
+}
+
+func TestSkillTool_Failure(t *testing.T) {
+	tool := NewSkillTool(skillScript(t, `echo "pot not found" >&2; exit 3`), "/ws")
+
+	result := tool.Execute(context.Background(), map[string]any{})
+
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "exit status 3")
+	assert.Contains(t, result.ForLLM, "pot not found")
+}
+
+func TestSkillTool_Cancelled(t *testing.T) {
+	tool := NewSkillTool(skillScript(t, `sleep 30`), "/ws")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result := tool.Execute(ctx, map[string]any{})
+
+	assert.True(t, result.IsError)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -12,6 +12,15 @@ import (
 // SPITool provides SPI bus interaction for high-speed peripheral communication.
 type SPITool struct{}
 
+func init() {
+	RegisterFactory("spi", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("spi") {
+			return nil, nil
+		}
+		return NewSPITool(), nil
+	})
+}
+
 func NewSPITool() *SPITool {
 	return &SPITool{}
 }
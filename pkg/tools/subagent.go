@@ -3,7 +3,10 @@ package tools
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
@@ -33,9 +36,15 @@ type SubagentManager struct {
 	temperature    float64
 	hasMaxTokens   bool
 	hasTemperature bool
+	tokenBudget    int
 	nextID         int
+	running        atomic.Int32 // tasks started by Spawn that have not returned
 }
 
+// nestedTools are withheld from subagents, which may not start subagents of
+// their own.
+var nestedTools = map[string]bool{"spawn": true, "subagent": true}
+
 func NewSubagentManager(
 	provider providers.LLMProvider,
 	defaultModel, workspace string,
@@ -69,6 +78,18 @@ func (sm *SubagentManager) SetTools(tools *ToolRegistry) {
 	sm.tools = tools
 }
 
+// SetLimits bounds each subagent's tool loop to maxIterations rounds and
+// tokenBudget tokens in all. 0 keeps the default of 10 rounds, or sets no
+// token limit.
+func (sm *SubagentManager) SetLimits(maxIterations, tokenBudget int) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if maxIterations > 0 {
+		sm.maxIterations = maxIterations
+	}
+	sm.tokenBudget = tokenBudget
+}
+
 // RegisterTool registers a tool for subagent execution.
 func (sm *SubagentManager) RegisterTool(tool Tool) {
 	sm.mu.Lock()
@@ -100,7 +121,11 @@ func (sm *SubagentManager) Spawn(
 	sm.tasks[taskID] = subagentTask
 
 	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	sm.running.Add(1)
+	go func() {
+		defer sm.running.Add(-1)
+		sm.runTask(ctx, subagentTask, callback)
+	}()
 
 	if label != "" {
 		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
@@ -108,6 +133,11 @@ func (sm *SubagentManager) Spawn(
 	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
 }
 
+// Running returns the number of spawned subagents that have not finished.
+func (sm *SubagentManager) Running() int {
+	return int(sm.running.Load())
+}
+
 func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
 	task.Status = "running"
 	task.Created = time.Now().UnixMilli()
@@ -139,35 +169,12 @@ After completing the task, provide a clear summary of what was done.`
 	default:
 	}
 
-	// Run tool loop with access to tools
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
+	tools, err := sm.scopedTools(nil)
+	var loopResult *ToolLoopResult
+	if err == nil {
+		loopResult, err = RunToolLoop(ctx, sm.loopConfig(tools, 0), messages, task.OriginChannel, task.OriginChatID)
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, task.OriginChannel, task.OriginChatID)
-
 	sm.mu.Lock()
 	var result *ToolResult
 	defer func() {
@@ -197,10 +204,15 @@ After completing the task, provide a clear summary of what was done.`
 	} else {
 		task.Status = "completed"
 		task.Result = loopResult.Content
+		outcome := "completed"
+		if loopResult.BudgetExceeded {
+			outcome = "stopped at its token budget"
+		}
 		result = &ToolResult{
 			ForLLM: fmt.Sprintf(
-				"Subagent '%s' completed (iterations: %d): %s",
+				"Subagent '%s' %s (iterations: %d): %s",
 				task.Label,
+				outcome,
 				loopResult.Iterations,
 				loopResult.Content,
 			),
@@ -212,6 +224,51 @@ After completing the task, provide a clear summary of what was done.`
 	}
 }
 
+// scopedTools returns the tools a subagent may use: the named ones, or all
+// when names is empty, but never the nested subagent tools.
+func (sm *SubagentManager) scopedTools(names []string) (*ToolRegistry, error) {
+	sm.mu.RLock()
+	tools := sm.tools
+	sm.mu.RUnlock()
+	for _, name := range names {
+		if _, ok := tools.Get(name); !ok || nestedTools[name] {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+	}
+	return tools.Subset(func(name string) bool {
+		return !nestedTools[name] && (len(names) == 0 || slices.Contains(names, name))
+	}), nil
+}
+
+// loopConfig configures a subagent's tool loop. budget lowers the
+// configured token budget when it is positive.
+func (sm *SubagentManager) loopConfig(tools *ToolRegistry, budget int) ToolLoopConfig {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var llmOptions map[string]any
+	if sm.hasMaxTokens || sm.hasTemperature {
+		llmOptions = map[string]any{}
+		if sm.hasMaxTokens {
+			llmOptions["max_tokens"] = sm.maxTokens
+		}
+		if sm.hasTemperature {
+			llmOptions["temperature"] = sm.temperature
+		}
+	}
+	if budget <= 0 || (sm.tokenBudget > 0 && budget > sm.tokenBudget) {
+		budget = sm.tokenBudget
+	}
+	return ToolLoopConfig{
+		Provider:      sm.provider,
+		Model:         sm.defaultModel,
+		Tools:         tools,
+		MaxIterations: sm.maxIterations,
+		LLMOptions:    llmOptions,
+		TokenBudget:   budget,
+	}
+}
+
 func (sm *SubagentManager) GetTask(taskID string) (*SubagentTask, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -248,7 +305,11 @@ func (t *SubagentTool) Name() string {
 }
 
 func (t *SubagentTool) Description() string {
-	return "Execute a subagent task synchronously and return the result. Use this for delegating specific tasks to an independent agent instance. Returns execution summary to user and full details to LLM."
+	return "Execute a subagent task synchronously and return the result. " +
+		"Use this for delegating specific tasks to an independent agent instance, " +
+		"optionally with its own system prompt, a subset of your tools and a token budget. " +
+		"Several subagent calls in one turn run in parallel. " +
+		"Returns execution summary to user and full details to LLM."
 }
 
 func (t *SubagentTool) Parameters() map[string]any {
@@ -263,6 +324,20 @@ func (t *SubagentTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional short label for the task (for display)",
 			},
+			"system_prompt": map[string]any{
+				"type":        "string",
+				"description": "Optional system prompt giving the subagent its role and instructions",
+			},
+			"tools": map[string]any{
+				"type":  "array",
+				"items": map[string]any{"type": "string"},
+				"description": "Optional names of the tools the subagent may use " +
+					"(default: all of yours except spawn and subagent)",
+			},
+			"token_budget": map[string]any{
+				"type":        "integer",
+				"description": "Optional limit on the tokens the subagent may use; it stops when the budget is spent",
+			},
 		},
 		"required": []string{"task"},
 	}
@@ -280,11 +355,33 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("Subagent manager not configured").WithError(fmt.Errorf("manager is nil"))
 	}
 
+	systemPrompt, _ := args["system_prompt"].(string)
+	if strings.TrimSpace(systemPrompt) == "" {
+		systemPrompt = "You are a subagent. Complete the given task independently and provide a clear, concise result."
+	}
+	var toolNames []string
+	if list, ok := args["tools"].([]any); ok {
+		for _, v := range list {
+			name, ok := v.(string)
+			if !ok {
+				return ErrorResult("tools must be a list of tool names")
+			}
+			toolNames = append(toolNames, name)
+		}
+	}
+	budget, _ := args["token_budget"].(float64)
+
+	sm := t.manager
+	tools, err := sm.scopedTools(toolNames)
+	if err != nil {
+		return ErrorResult(err.Error()).WithError(err)
+	}
+
 	// Build messages for subagent
 	messages := []providers.Message{
 		{
 			Role:    "system",
-			Content: "You are a subagent. Complete the given task independently and provide a clear, concise result.",
+			Content: systemPrompt,
 		},
 		{
 			Role:    "user",
@@ -292,28 +389,6 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		},
 	}
 
-	// Use RunToolLoop to execute with tools (same as async SpawnTool)
-	sm := t.manager
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
-	}
-
 	// Fall back to "cli"/"direct" for non-conversation callers (e.g., CLI, tests)
 	// to preserve the same defaults as the original NewSubagentTool constructor.
 	channel := ToolChannel(ctx)
@@ -325,13 +400,8 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		chatID = "direct"
 	}
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, channel, chatID)
+	// Use RunToolLoop to execute with tools (same as async SpawnTool)
+	loopResult, err := RunToolLoop(ctx, sm.loopConfig(tools, int(budget)), messages, channel, chatID)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
@@ -348,8 +418,12 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	if labelStr == "" {
 		labelStr = "(unnamed)"
 	}
-	llmContent := fmt.Sprintf("Subagent task completed:\nLabel: %s\nIterations: %d\nResult: %s",
-		labelStr, loopResult.Iterations, loopResult.Content)
+	status := "completed"
+	if loopResult.BudgetExceeded {
+		status = "stopped at its token budget before finishing"
+	}
+	llmContent := fmt.Sprintf("Subagent task %s:\nLabel: %s\nIterations: %d\nTokens: %d\nResult: %s",
+		status, labelStr, loopResult.Iterations, loopResult.TokensUsed, loopResult.Content)
 
 	return &ToolResult{
 		ForLLM:  llmContent,
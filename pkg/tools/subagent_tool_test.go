@@ -325,3 +325,106 @@ func TestSubagentTool_ForUserTruncation(t *testing.T) {
 		t.Error("ForLLM should contain reference to original task")
 	}
 }
+
+// toolCallingProvider calls the echo tool until it has done so calls times,
+// then answers. Every response reports usage tokens.
+type toolCallingProvider struct {
+	calls     int
+	usage     int
+	systems   []string
+	toolNames [][]string
+}
+
+func (p *toolCallingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	p.systems = append(p.systems, messages[0].Content)
+	var names []string
+	for _, d := range tools {
+		names = append(names, d.Function.Name)
+	}
+	p.toolNames = append(p.toolNames, names)
+	usage := &providers.UsageInfo{TotalTokens: p.usage}
+	if len(messages)-2 >= 2*p.calls {
+		return &providers.LLMResponse{Content: "done", Usage: usage}, nil
+	}
+	return &providers.LLMResponse{
+		Content:   "working",
+		ToolCalls: []providers.ToolCall{{ID: "c", Name: "echo", Arguments: map[string]any{}}},
+		Usage:     usage,
+	}, nil
+}
+
+func (p *toolCallingProvider) GetDefaultModel() string { return "test-model" }
+
+func newScopedManager(provider providers.LLMProvider) *SubagentManager {
+	parent := NewToolRegistry()
+	echo := func(ctx context.Context, args map[string]any) *ToolResult { return NewToolResult("echoed") }
+	parent.RegisterFunc("echo", "Echo", map[string]any{"type": "object"}, echo)
+	parent.RegisterFunc("read_file", "Read", map[string]any{"type": "object"}, echo)
+	manager := NewSubagentManager(provider, "test-model", "/tmp/test")
+	manager.SetTools(parent)
+	parent.Register(NewSubagentTool(manager))
+	return manager
+}
+
+func TestSubagentTool_ScopedTools(t *testing.T) {
+	provider := &toolCallingProvider{calls: 1}
+	tool := NewSubagentTool(newScopedManager(provider))
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"task":          "Echo something",
+		"system_prompt": "You are a researcher.",
+		"tools":         []any{"echo"},
+	})
+	if result.IsError {
+		t.Fatalf("Execute() = %s", result.ForLLM)
+	}
+	if provider.systems[0] != "You are a researcher." {
+		t.Errorf("system prompt = %q", provider.systems[0])
+	}
+	if got := provider.toolNames[0]; len(got) != 1 || got[0] != "echo" {
+		t.Errorf("subagent tools = %v, want [echo]", got)
+	}
+
+	// Without a list the subagent gets every tool but the nested ones.
+	provider.systems, provider.toolNames = nil, nil
+	tool.Execute(context.Background(), map[string]any{"task": "Echo"})
+	if got := provider.toolNames[0]; len(got) != 2 || got[0] != "echo" || got[1] != "read_file" {
+		t.Errorf("subagent tools = %v, want [echo read_file]", got)
+	}
+
+	for _, name := range []string{"subagent", "missing"} {
+		result = tool.Execute(context.Background(), map[string]any{"task": "x", "tools": []any{name}})
+		if !result.IsError {
+			t.Errorf("tools [%s] accepted", name)
+		}
+	}
+}
+
+func TestSubagentTool_TokenBudget(t *testing.T) {
+	provider := &toolCallingProvider{calls: 5, usage: 400}
+	manager := newScopedManager(provider)
+	manager.SetLimits(10, 1000)
+	tool := NewSubagentTool(manager)
+
+	// The call asks for less than the configured budget.
+	result := tool.Execute(context.Background(), map[string]any{"task": "Echo", "token_budget": float64(700)})
+	if result.IsError {
+		t.Fatalf("Execute() = %s", result.ForLLM)
+	}
+	if !strings.Contains(result.ForLLM, "token budget") || !strings.Contains(result.ForLLM, "Tokens: 800") {
+		t.Errorf("ForLLM = %s", result.ForLLM)
+	}
+
+	// It cannot ask for more.
+	provider.systems = nil
+	tool.Execute(context.Background(), map[string]any{"task": "Echo", "token_budget": float64(1e6)})
+	if len(provider.systems) != 3 {
+		t.Errorf("subagent made %d LLM calls, want 3", len(provider.systems))
+	}
+}
@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/cpu"
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/host"
+	"github.com/shirou/gopsutil/v4/load"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/sensors"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+const (
+	// cpuSampleInterval is how long system_info measures CPU usage for.
+	cpuSampleInterval = 250 * time.Millisecond
+	// healthRemindAfter is how long a warning that persists stays out of
+	// heartbeat prompts after it was reported.
+	healthRemindAfter = 24 * time.Hour
+)
+
+func init() {
+	RegisterFactory("system_info", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("system_info") {
+			return nil, nil
+		}
+		return NewSystemInfoTool(NewSystemMonitor(env.Config.Tools.SystemInfo, env.Workspace)), nil
+	})
+}
+
+// SystemSnapshot is one reading of the device's resources. Sections that
+// could not be read are left zero and noted in Errors.
+type SystemSnapshot struct {
+	Hostname   string
+	Platform   string
+	Uptime     time.Duration
+	CPUs       int
+	CPUPercent float64 // < 0 when not sampled
+	Load       [3]float64
+	Memory     *mem.VirtualMemoryStat
+	Swap       *mem.SwapMemoryStat
+	Disks      []*disk.UsageStat
+	Temps      []sensors.TemperatureStat
+	Errors     []string
+}
+
+// SystemMonitor reads CPU, memory, disk and temperature figures and checks
+// them against warning thresholds.
+type SystemMonitor struct {
+	disks      []string
+	diskWarn   float64
+	memoryWarn float64
+	tempWarn   float64
+
+	mu       sync.Mutex
+	reported map[string]time.Time // warning key -> when Context last reported it
+}
+
+// systemWarning is a figure over its threshold. key names the resource, so
+// the same condition is recognised across readings.
+type systemWarning struct {
+	key, text string
+}
+
+// NewSystemMonitor creates a monitor from cfg. Without configured disks it
+// reports "/" and the file system holding workspace.
+func NewSystemMonitor(cfg config.SystemInfoConfig, workspace string) *SystemMonitor {
+	disks := cfg.Disks
+	if len(disks) == 0 {
+		disks = []string{"/"}
+		if workspace != "" {
+			disks = append(disks, workspace)
+		}
+	}
+	return &SystemMonitor{
+		disks:      disks,
+		diskWarn:   float64(cfg.DiskWarnPercent),
+		memoryWarn: float64(cfg.MemoryWarnPercent),
+		tempWarn:   float64(cfg.TempWarnCelsius),
+		reported:   map[string]time.Time{},
+	}
+}
+
+// Snapshot reads the current figures.
+func (m *SystemMonitor) Snapshot(ctx context.Context) SystemSnapshot {
+	return m.snapshot(ctx, true)
+}
+
+// snapshot reads the figures, measuring CPU usage over cpuSampleInterval
+// if sampleCPU is set.
+func (m *SystemMonitor) snapshot(ctx context.Context, sampleCPU bool) SystemSnapshot {
+	s := SystemSnapshot{CPUs: runtime.NumCPU(), CPUPercent: -1, Platform: runtime.GOOS + "/" + runtime.GOARCH}
+	fail := func(what string, err error) {
+		s.Errors = append(s.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	s.Hostname, _ = os.Hostname()
+	if info, err := host.InfoWithContext(ctx); err == nil {
+		s.Uptime = time.Duration(info.Uptime) * time.Second
+		if info.Platform != "" {
+			s.Platform = fmt.Sprintf("%s %s (%s)", info.Platform, info.PlatformVersion, s.Platform)
+		}
+	}
+	if sampleCPU {
+		if pct, err := cpu.PercentWithContext(ctx, cpuSampleInterval, false); err == nil && len(pct) > 0 {
+			s.CPUPercent = pct[0]
+		}
+	}
+	if avg, err := load.AvgWithContext(ctx); err == nil {
+		s.Load = [3]float64{avg.Load1, avg.Load5, avg.Load15}
+	}
+	if vm, err := mem.VirtualMemoryWithContext(ctx); err == nil {
+		s.Memory = vm
+	} else {
+		fail("memory", err)
+	}
+	if swap, err := mem.SwapMemoryWithContext(ctx); err == nil && swap.Total > 0 {
+		s.Swap = swap
+	}
+
+	seen := map[string]bool{}
+	for _, path := range m.disks {
+		usage, err := disk.UsageWithContext(ctx, path)
+		if err != nil {
+			fail("disk "+path, err)
+			continue
+		}
+		// The workspace usually lives on the root file system.
+		key := fmt.Sprintf("%d/%d", usage.Total, usage.Used)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		s.Disks = append(s.Disks, usage)
+	}
+
+	// Sensors may fail partially; keep what was read.
+	temps, _ := sensors.TemperaturesWithContext(ctx)
+	for _, t := range temps {
+		if t.Temperature > 0 {
+			s.Temps = append(s.Temps, t)
+		}
+	}
+	slices.SortFunc(s.Temps, func(a, b sensors.TemperatureStat) int {
+		return strings.Compare(a.SensorKey, b.SensorKey)
+	})
+	return s
+}
+
+// Warnings lists the figures in s over their thresholds.
+func (m *SystemMonitor) Warnings(s SystemSnapshot) []string {
+	var texts []string
+	for _, w := range m.warnings(s) {
+		texts = append(texts, w.text)
+	}
+	return texts
+}
+
+func (m *SystemMonitor) warnings(s SystemSnapshot) []systemWarning {
+	var warnings []systemWarning
+	for _, d := range s.Disks {
+		if m.diskWarn > 0 && d.UsedPercent >= m.diskWarn {
+			warnings = append(warnings, systemWarning{"disk:" + d.Path, fmt.Sprintf("Disk %s is %.0f%% full (%s free)",
+				d.Path, d.UsedPercent, formatBytes(d.Free))})
+		}
+	}
+	if vm := s.Memory; vm != nil && m.memoryWarn > 0 && vm.UsedPercent >= m.memoryWarn {
+		warnings = append(warnings, systemWarning{"memory", fmt.Sprintf("Memory is %.0f%% used (%s available)",
+			vm.UsedPercent, formatBytes(vm.Available))})
+	}
+	for _, t := range s.Temps {
+		if m.tempWarn > 0 && t.Temperature >= m.tempWarn {
+			warnings = append(warnings, systemWarning{
+				"temp:" + t.SensorKey,
+				fmt.Sprintf("%s is at %.1f°C", t.SensorKey, t.Temperature),
+			})
+		}
+	}
+	return warnings
+}
+
+// Context returns a "## Device health" section for heartbeat prompts with
+// the figures over their thresholds, or "". Heartbeats have no history, so
+// the monitor remembers what it reported: a warning that persists is
+// repeated once a day, and one that clears is reported again if it returns.
+func (m *SystemMonitor) Context(now time.Time) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// CPU usage is never warned about, so skip the sampling delay.
+	warnings := m.warnings(m.snapshot(ctx, false))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := make(map[string]bool, len(warnings))
+	var texts []string
+	for _, w := range warnings {
+		current[w.key] = true
+		if last, ok := m.reported[w.key]; ok && now.Sub(last) < healthRemindAfter {
+			continue
+		}
+		m.reported[w.key] = now
+		texts = append(texts, w.text)
+	}
+	for key := range m.reported {
+		if !current[key] {
+			delete(m.reported, key)
+		}
+	}
+	if len(texts) == 0 {
+		return ""
+	}
+	return "## Device health\n\nThese readings from the device you run on are over their warning thresholds. " +
+		"Tell the user.\n\n- " + strings.Join(texts, "\n- ")
+}
+
+// SystemInfoTool reports the figures of a SystemMonitor.
+type SystemInfoTool struct {
+	monitor *SystemMonitor
+}
+
+// NewSystemInfoTool creates a SystemInfoTool reading from monitor.
+func NewSystemInfoTool(monitor *SystemMonitor) *SystemInfoTool {
+	return &SystemInfoTool{monitor: monitor}
+}
+
+func (t *SystemInfoTool) Name() string {
+	return "system_info"
+}
+
+func (t *SystemInfoTool) Description() string {
+	return "Report the health of the device you run on: CPU usage and load, memory, disk space and " +
+		"temperatures, with warnings for anything over its threshold."
+}
+
+func (t *SystemInfoTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{},
+	}
+}
+
+func (t *SystemInfoTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	s := t.monitor.Snapshot(ctx)
+	return NewToolResult(formatSystemSnapshot(s, t.monitor.Warnings(s)))
+}
+
+func formatSystemSnapshot(s SystemSnapshot, warnings []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Host: %s, %s", s.Hostname, s.Platform)
+	if s.Uptime > 0 {
+		fmt.Fprintf(&sb, ", up %s", formatUptime(s.Uptime))
+	}
+	fmt.Fprintf(&sb, "\nCPU: %d cores", s.CPUs)
+	if s.CPUPercent >= 0 {
+		fmt.Fprintf(&sb, ", %.1f%% used", s.CPUPercent)
+	}
+	if s.Load != [3]float64{} {
+		fmt.Fprintf(&sb, ", load %.2f %.2f %.2f", s.Load[0], s.Load[1], s.Load[2])
+	}
+	if vm := s.Memory; vm != nil {
+		fmt.Fprintf(&sb, "\nMemory: %s / %s used (%.0f%%), %s available",
+			formatBytes(vm.Used), formatBytes(vm.Total), vm.UsedPercent, formatBytes(vm.Available))
+	}
+	if sw := s.Swap; sw != nil {
+		fmt.Fprintf(&sb, "\nSwap: %s / %s used (%.0f%%)", formatBytes(sw.Used), formatBytes(sw.Total), sw.UsedPercent)
+	}
+	for _, d := range s.Disks {
+		fmt.Fprintf(&sb, "\nDisk %s: %s / %s used (%.0f%%), %s free",
+			d.Path, formatBytes(d.Used), formatBytes(d.Total), d.UsedPercent, formatBytes(d.Free))
+	}
+	if len(s.Temps) > 0 {
+		parts := make([]string, len(s.Temps))
+		for i, t := range s.Temps {
+			parts[i] = fmt.Sprintf("%s %.1f°C", t.SensorKey, t.Temperature)
+		}
+		sb.WriteString("\nTemperature: " + strings.Join(parts, ", "))
+	} else {
+		sb.WriteString("\nTemperature: no sensors found")
+	}
+	for _, e := range s.Errors {
+		sb.WriteString("\nUnavailable: " + e)
+	}
+	for _, w := range warnings {
+		sb.WriteString("\nWarning: " + w)
+	}
+	return sb.String()
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit && exp < 4; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+func formatUptime(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%dd%dh", days, hours)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, int(d.Minutes())%60)
+}
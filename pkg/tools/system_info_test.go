@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/mem"
+	"github.com/shirou/gopsutil/v4/sensors"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestSystemMonitor_Warnings(t *testing.T) {
+	monitor := NewSystemMonitor(config.SystemInfoConfig{
+		DiskWarnPercent: 90, MemoryWarnPercent: 90, TempWarnCelsius: 80,
+	}, "")
+	s := SystemSnapshot{
+		CPUs:       4,
+		CPUPercent: 12.5,
+		Memory:     &mem.VirtualMemoryStat{Total: 4 << 30, Used: 1 << 30, Available: 3 << 30, UsedPercent: 25},
+		Disks: []*disk.UsageStat{
+			{Path: "/", Total: 100 << 30, Used: 95 << 30, Free: 5 << 30, UsedPercent: 95},
+			{Path: "/data", Total: 100 << 30, Used: 10 << 30, Free: 90 << 30, UsedPercent: 10},
+		},
+		Temps: []sensors.TemperatureStat{{SensorKey: "cpu_thermal", Temperature: 83.4}},
+	}
+
+	want := []string{"Disk / is 95% full (5.0 GiB free)", "cpu_thermal is at 83.4°C"}
+	if got := monitor.Warnings(s); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Warnings = %q, want %q", got, want)
+	}
+
+	out := formatSystemSnapshot(s, want)
+	for _, line := range []string{
+		"CPU: 4 cores, 12.5% used",
+		"Memory: 1.0 GiB / 4.0 GiB used (25%), 3.0 GiB available",
+		"Disk /data: 10.0 GiB / 100.0 GiB used (10%), 90.0 GiB free",
+		"Temperature: cpu_thermal 83.4°C",
+		"Warning: Disk / is 95% full",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("output missing %q:\n%s", line, out)
+		}
+	}
+
+	off := NewSystemMonitor(config.SystemInfoConfig{}, "")
+	if got := off.Warnings(s); len(got) != 0 {
+		t.Errorf("Warnings with thresholds off = %q", got)
+	}
+}
+
+func TestSystemInfoTool_ReadsHost(t *testing.T) {
+	monitor := NewSystemMonitor(config.SystemInfoConfig{DiskWarnPercent: 0}, t.TempDir())
+	result := NewSystemInfoTool(monitor).Execute(context.Background(), nil)
+	if result.IsError {
+		t.Fatalf("Execute: %s", result.ForLLM)
+	}
+	for _, want := range []string{"Host: ", "CPU: ", "Disk /: "} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("output missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+
+	// Any used disk is over a 0.01% threshold, so the heartbeat gets a warning.
+	monitor.diskWarn = 0.01
+	now := time.Now()
+	if got := monitor.Context(now); !strings.HasPrefix(got, "## Device health") ||
+		!strings.Contains(got, "- Disk / is") {
+		t.Errorf("Context = %q", got)
+	}
+	if got := monitor.Context(now.Add(time.Hour)); got != "" {
+		t.Errorf("warning repeated within a day: %q", got)
+	}
+	if got := monitor.Context(now.Add(25 * time.Hour)); !strings.Contains(got, "- Disk / is") {
+		t.Errorf("warning not repeated after a day: %q", got)
+	}
+
+	// Once the condition clears, a recurrence is reported at once.
+	monitor.diskWarn = 0
+	if got := monitor.Context(now.Add(26 * time.Hour)); got != "" {
+		t.Errorf("Context without disk threshold = %q", got)
+	}
+	monitor.diskWarn = 0.01
+	if got := monitor.Context(now.Add(27 * time.Hour)); !strings.Contains(got, "- Disk / is") {
+		t.Errorf("recurring warning not reported: %q", got)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{512: "512 B", 1536: "1.5 KiB", 3 << 30: "3.0 GiB"}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+// taskRemindEvery is how long heartbeats wait before reminding the user of
+// an overdue task again.
+const taskRemindEvery = 24 * time.Hour
+
+// TasksTool keeps the user's to-do list: tasks with a state and an optional
+// due date, which outlive the conversation they were added in.
+type TasksTool struct {
+	store *memory.TaskStore
+}
+
+// NewTasksTool creates a TasksTool over store.
+func NewTasksTool(store *memory.TaskStore) *TasksTool {
+	return &TasksTool{store: store}
+}
+
+func (t *TasksTool) Name() string {
+	return "tasks"
+}
+
+func (t *TasksTool) Description() string {
+	return "The user's to-do list. 'add' a task when the user asks to note something to do, with a due date " +
+		"if they give one; 'update' its state as work goes on (pending, in_progress, blocked, done) or change " +
+		"its title, notes or due date; 'list' shows the open tasks (state \"all\" includes done ones); " +
+		"'delete' removes one. Overdue tasks come up in heartbeats on their own."
+}
+
+func (t *TasksTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{"add", "list", "update", "delete"},
+			},
+			"id": map[string]any{
+				"type":        "string",
+				"description": "For update and delete: the task's id, from list",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "For add and update: what is to be done, e.g. \"Renew the passport\"",
+			},
+			"notes": map[string]any{
+				"type":        "string",
+				"description": "For add and update: details, or why the task is blocked",
+			},
+			"state": map[string]any{
+				"type":        "string",
+				"enum":        []string{"pending", "in_progress", "blocked", "done", "all"},
+				"description": "For add and update: the new state. For list: only tasks in this state",
+			},
+			"due": map[string]any{
+				"type": "string",
+				"description": "For add and update: \"YYYY-MM-DD HH:MM\" in local time, or \"YYYY-MM-DD\" for the " +
+					"end of that day; \"none\" removes the due date",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *TasksTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	action, _ := args["action"].(string)
+	id, _ := args["id"].(string)
+	switch action {
+	case "add":
+		return t.add(ctx, args)
+	case "list":
+		return t.list(ctx, args)
+	case "update":
+		if id == "" {
+			return ErrorResult("id is required")
+		}
+		return t.update(ctx, id, args)
+	case "delete":
+		if id == "" {
+			return ErrorResult("id is required")
+		}
+		removed, err := t.store.Delete(ctx, id)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("failed to delete task: %v", err))
+		}
+		if !removed {
+			return ErrorResult(fmt.Sprintf("task %s not found", id))
+		}
+		return SilentResult(fmt.Sprintf("Deleted task %s", id))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (want add, list, update or delete)", action))
+	}
+}
+
+func (t *TasksTool) add(ctx context.Context, args map[string]any) *ToolResult {
+	task := memory.Task{Source: ToolSessionKey(ctx)}
+	task.Title, _ = args["title"].(string)
+	task.Notes, _ = args["notes"].(string)
+	if strings.TrimSpace(task.Title) == "" {
+		return ErrorResult("title is required")
+	}
+	state, _ := args["state"].(string)
+	var err error
+	if task.State, err = memory.ParseTaskState(state); err != nil {
+		return ErrorResult(err.Error())
+	}
+	if due, ok := args["due"].(string); ok {
+		if task.Due, err = parseTaskDue(due); err != nil {
+			return ErrorResult(err.Error())
+		}
+	}
+
+	task, err = t.store.Add(ctx, task)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to add task: %v", err))
+	}
+	return SilentResult("Added " + formatTask(task, time.Now()))
+}
+
+func (t *TasksTool) list(ctx context.Context, args map[string]any) *ToolResult {
+	var states []memory.TaskState
+	switch state, _ := args["state"].(string); state {
+	case "":
+		states = []memory.TaskState{memory.TaskPending, memory.TaskInProgress, memory.TaskBlocked}
+	case "all":
+	default:
+		s, err := memory.ParseTaskState(state)
+		if err != nil {
+			return ErrorResult(err.Error())
+		}
+		states = []memory.TaskState{s}
+	}
+
+	tasks, err := t.store.List(ctx, states...)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to list tasks: %v", err))
+	}
+	if len(tasks) == 0 {
+		return SilentResult("No tasks.")
+	}
+	now := time.Now()
+	lines := make([]string, len(tasks))
+	for i, task := range tasks {
+		lines[i] = "- " + formatTask(task, now)
+	}
+	return SilentResult(strings.Join(lines, "\n"))
+}
+
+func (t *TasksTool) update(ctx context.Context, id string, args map[string]any) *ToolResult {
+	// Check every argument before changing anything.
+	var (
+		state    memory.TaskState
+		due      time.Time
+		err      error
+		setState bool
+		setDue   bool
+	)
+	if s, ok := args["state"].(string); ok && s != "" {
+		if state, err = memory.ParseTaskState(s); err != nil {
+			return ErrorResult(err.Error())
+		}
+		setState = true
+	}
+	if d, ok := args["due"].(string); ok && d != "" {
+		if due, err = parseTaskDue(d); err != nil {
+			return ErrorResult(err.Error())
+		}
+		setDue = true
+	}
+
+	task, err := t.store.Update(ctx, id, func(task *memory.Task) {
+		if title, ok := args["title"].(string); ok && strings.TrimSpace(title) != "" {
+			task.Title = title
+		}
+		if notes, ok := args["notes"].(string); ok {
+			task.Notes = notes
+		}
+		if setState {
+			task.State = state
+		}
+		if setDue {
+			task.Due = due
+		}
+	})
+	if errors.Is(err, memory.ErrTaskNotFound) {
+		return ErrorResult(fmt.Sprintf("task %s not found", id))
+	}
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to update task: %v", err))
+	}
+	return SilentResult("Updated " + formatTask(task, time.Now()))
+}
+
+// parseTaskDue parses a due date; "none" and "" are no due date. A bare
+// date is due at the end of that day.
+func parseTaskDue(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "none") {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return day.Add(24*time.Hour - time.Minute), nil
+	}
+	due, err := parseCalendarTime(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid due %q, want YYYY-MM-DD HH:MM or YYYY-MM-DD", s)
+	}
+	return due, nil
+}
+
+// formatTask describes task for the model, e.g. "Renew the passport (id:
+// 3f2a9c1e, pending, due 2026-03-04 18:00, overdue by 2 days)".
+func formatTask(task memory.Task, now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s (id: %s, %s", task.Title, task.ID, task.State)
+	if !task.Due.IsZero() {
+		fmt.Fprintf(&sb, ", due %s", task.Due.Local().Format("2006-01-02 15:04"))
+		if task.Overdue(now) {
+			fmt.Fprintf(&sb, ", overdue by %s", formatOverdue(now.Sub(task.Due)))
+		}
+	}
+	sb.WriteString(")")
+	if notes := strings.TrimSpace(task.Notes); notes != "" {
+		sb.WriteString(": " + strings.Join(strings.Fields(notes), " "))
+	}
+	return sb.String()
+}
+
+func formatOverdue(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%d minutes", max(int(d/time.Minute), 1))
+	}
+}
+
+// TaskReminders adds the overdue tasks to heartbeat prompts, so the agent
+// reminds the user of them. A task comes up at most once a day.
+type TaskReminders struct {
+	store    *memory.TaskStore
+	mu       sync.Mutex
+	reminded map[string]time.Time // task ID → last heartbeat that listed it
+}
+
+// NewTaskReminders creates reminders of the overdue tasks in store.
+func NewTaskReminders(store *memory.TaskStore) *TaskReminders {
+	return &TaskReminders{store: store, reminded: make(map[string]time.Time)}
+}
+
+// Context returns the heartbeat prompt section listing the tasks overdue at
+// now that were not listed in the last day, or "" when there are none.
+func (r *TaskReminders) Context(now time.Time) string {
+	tasks, err := r.store.Overdue(context.Background(), now)
+	if err != nil {
+		logger.WarnCF("heartbeat", "Failed to read tasks", map[string]any{"error": err.Error()})
+		return ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	overdue := make(map[string]bool, len(tasks))
+	var lines []string
+	for _, task := range tasks {
+		overdue[task.ID] = true
+		if last, ok := r.reminded[task.ID]; ok && now.Sub(last) < taskRemindEvery {
+			continue
+		}
+		r.reminded[task.ID] = now
+		lines = append(lines, "- "+formatTask(task, now))
+	}
+	// Forget tasks that were done, deleted or moved to a later date.
+	for id := range r.reminded {
+		if !overdue[id] {
+			delete(r.reminded, id)
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "## Overdue tasks\n\n" +
+		"These tasks are past their due date. Remind the user of them, and ask whether to move the date " +
+		"of the ones that cannot be done yet:\n\n" +
+		strings.Join(lines, "\n")
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+func TestTasksTool(t *testing.T) {
+	store, err := memory.NewTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTaskStore: %v", err)
+	}
+	tool := NewTasksTool(store)
+	ctx := WithSessionKey(context.Background(), "agent:main:main")
+
+	result := tool.Execute(ctx, map[string]any{
+		"action": "add", "title": "Renew the passport", "due": "2020-01-02",
+	})
+	if result.IsError || !strings.Contains(result.ForLLM, "due 2020-01-02 23:59, overdue by") {
+		t.Fatalf("add = %+v", result)
+	}
+	tasks, _ := store.List(ctx)
+	if len(tasks) != 1 || tasks[0].Source != "agent:main:main" {
+		t.Fatalf("stored %+v", tasks)
+	}
+	id := tasks[0].ID
+
+	if result := tool.Execute(ctx, map[string]any{"action": "add", "title": "x", "due": "soon"}); !result.IsError {
+		t.Errorf("add with a bad due date = %+v", result)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "add"}); !result.IsError {
+		t.Errorf("add without a title = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{
+		"action": "update", "id": id, "state": "blocked", "notes": "waiting for photos", "due": "none",
+	})
+	if result.IsError || !strings.HasSuffix(result.ForLLM, "(id: "+id+", blocked): waiting for photos") {
+		t.Errorf("update = %+v", result)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "update", "id": id, "state": "later"}); !result.IsError {
+		t.Errorf("update with a bad state = %+v", result)
+	}
+
+	result = tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, "- Renew the passport") {
+		t.Errorf("list = %q", result.ForLLM)
+	}
+	tool.Execute(ctx, map[string]any{"action": "update", "id": id, "state": "done"})
+	if result := tool.Execute(ctx, map[string]any{"action": "list"}); result.ForLLM != "No tasks." {
+		t.Errorf("list after done = %q, want the done task left out", result.ForLLM)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "list", "state": "all"}); !strings.Contains(
+		result.ForLLM, "Renew the passport") {
+		t.Errorf("list all = %q", result.ForLLM)
+	}
+
+	if result := tool.Execute(ctx, map[string]any{"action": "delete", "id": id}); result.IsError {
+		t.Errorf("delete = %+v", result)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "delete", "id": id}); !result.IsError {
+		t.Errorf("second delete = %+v", result)
+	}
+}
+
+func TestTaskReminders_Context(t *testing.T) {
+	store, err := memory.NewTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewTaskStore: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	late, _ := store.Add(ctx, memory.Task{Title: "Renew the passport", Due: now.Add(-72 * time.Hour)})
+	_, _ = store.Add(ctx, memory.Task{Title: "Pay the rent", Due: now.Add(time.Hour)})
+
+	reminders := NewTaskReminders(store)
+	got := reminders.Context(now)
+	if !strings.HasPrefix(got, "## Overdue tasks") || !strings.Contains(got, "Renew the passport") ||
+		!strings.Contains(got, "overdue by 3 days") || strings.Contains(got, "Pay the rent") {
+		t.Errorf("Context = %q", got)
+	}
+	if got := reminders.Context(now.Add(time.Hour)); got != "" {
+		t.Errorf("Context an hour later = %q, want no repeat", got)
+	}
+	if got := reminders.Context(now.Add(25 * time.Hour)); !strings.Contains(got, "Renew the passport") {
+		t.Errorf("Context a day later = %q, want a reminder again", got)
+	}
+
+	store.Update(ctx, late.ID, func(t *memory.Task) { t.State = memory.TaskDone })
+	got = reminders.Context(now.Add(50 * time.Hour))
+	if strings.Contains(got, "Renew the passport") || !strings.Contains(got, "Pay the rent") {
+		t.Errorf("Context after done = %q, want only the rent, overdue by then", got)
+	}
+}
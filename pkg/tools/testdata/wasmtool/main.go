@@ -0,0 +1,55 @@
+//go:build wasip1
+
+// Command wasmtool is the WebAssembly tool the WasmTool tests run. What it
+// does depends on the "do" argument.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+//go:wasmimport picoclaw fetch
+func fetch(reqPtr unsafe.Pointer, reqLen uint32) int32
+
+//go:wasmimport picoclaw fetch_result
+func fetchResult(bufPtr unsafe.Pointer, bufLen uint32) int32
+
+func main() {
+	var args map[string]string
+	if err := json.NewDecoder(os.Stdin).Decode(&args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	switch args["do"] {
+	case "echo":
+		fmt.Printf("hello %s", args["name"])
+	case "read":
+		data, err := os.ReadFile(os.Getenv("PICOCLAW_WORKSPACE") + "/" + args["file"])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	case "write":
+		if err := os.WriteFile(os.Getenv("PICOCLAW_WORKSPACE")+"/"+args["file"], []byte("x"), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Print("written")
+	case "fetch":
+		req, _ := json.Marshal(map[string]string{"url": args["url"]})
+		n := fetch(unsafe.Pointer(&req[0]), uint32(len(req)))
+		buf := make([]byte, n)
+		fetchResult(unsafe.Pointer(&buf[0]), uint32(n))
+		fmt.Print(string(buf))
+	case "spin":
+		for {
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "unknown action")
+		os.Exit(3)
+	}
+}
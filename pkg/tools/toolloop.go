@@ -24,12 +24,19 @@ type ToolLoopConfig struct {
 	Tools         *ToolRegistry
 	MaxIterations int
 	LLMOptions    map[string]any
+	// TokenBudget stops the loop once its LLM calls have used this many
+	// tokens in all, as reported by the provider. 0 means no limit.
+	TokenBudget int
 }
 
 // ToolLoopResult contains the result of running the tool loop.
 type ToolLoopResult struct {
 	Content    string
 	Iterations int
+	TokensUsed int
+	// BudgetExceeded is set when the loop stopped at its token budget, in
+	// which case Content is whatever the model had said by then.
+	BudgetExceeded bool
 }
 
 // RunToolLoop executes the LLM + tool call iteration loop.
@@ -41,6 +48,7 @@ func RunToolLoop(
 	channel, chatID string,
 ) (*ToolLoopResult, error) {
 	iteration := 0
+	tokensUsed := 0
 	var finalContent string
 
 	for iteration < config.MaxIterations {
@@ -73,6 +81,13 @@ func RunToolLoop(
 				})
 			return nil, fmt.Errorf("LLM call failed: %w", err)
 		}
+		if u := response.Usage; u != nil {
+			if u.TotalTokens > 0 {
+				tokensUsed += u.TotalTokens
+			} else {
+				tokensUsed += u.PromptTokens + u.CompletionTokens
+			}
+		}
 
 		// 4. If no tool calls, we're done
 		if len(response.ToolCalls) == 0 {
@@ -85,6 +100,21 @@ func RunToolLoop(
 			break
 		}
 
+		if config.TokenBudget > 0 && tokensUsed >= config.TokenBudget {
+			logger.WarnCF("toolloop", "Token budget exhausted",
+				map[string]any{
+					"iteration":   iteration,
+					"tokens_used": tokensUsed,
+					"budget":      config.TokenBudget,
+				})
+			return &ToolLoopResult{
+				Content:        response.Content,
+				Iterations:     iteration,
+				TokensUsed:     tokensUsed,
+				BudgetExceeded: true,
+			}, nil
+		}
+
 		normalizedToolCalls := make([]providers.ToolCall, 0, len(response.ToolCalls))
 		for _, tc := range response.ToolCalls {
 			normalizedToolCalls = append(normalizedToolCalls, providers.NormalizeToolCall(tc))
@@ -175,5 +205,6 @@ func RunToolLoop(
 	return &ToolLoopResult{
 		Content:    finalContent,
 		Iterations: iteration,
+		TokensUsed: tokensUsed,
 	}, nil
 }
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+const maxTopicReadChars = 16000
+
+// TopicStore holds the topics of each session. session.SessionManager
+// implements it.
+type TopicStore interface {
+	ListTopics(sessionKey string) []session.Topic
+	TopicMessages(sessionKey string, i int) ([]providers.Message, bool)
+}
+
+// TopicsTool lets the model look through the topics of a long
+// conversation and read back one whose messages are no longer in its
+// context.
+type TopicsTool struct {
+	store TopicStore
+}
+
+// NewTopicsTool creates a TopicsTool over store.
+func NewTopicsTool(store TopicStore) *TopicsTool {
+	return &TopicsTool{store: store}
+}
+
+func (t *TopicsTool) Name() string {
+	return "topics"
+}
+
+func (t *TopicsTool) Description() string {
+	return "The topics this conversation went through. 'list' shows them numbered, with how many messages " +
+		"each has; 'read' returns the messages of one, for when the user refers back to something earlier " +
+		"in the conversation that is no longer in context."
+}
+
+func (t *TopicsTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{
+				"type": "string",
+				"enum": []string{"list", "read"},
+			},
+			"topic": map[string]any{
+				"type":        "integer",
+				"minimum":     1,
+				"description": "For read: the number of the topic as list shows it",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *TopicsTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	sessionKey := ToolSessionKey(ctx)
+	if sessionKey == "" {
+		return ErrorResult("topics are only available inside a conversation")
+	}
+	action, _ := args["action"].(string)
+	switch action {
+	case "list":
+		return t.list(sessionKey)
+	case "read":
+		n, ok := toFloat(args["topic"])
+		if !ok || n < 1 {
+			return ErrorResult("topic is required")
+		}
+		return t.read(sessionKey, int(n))
+	default:
+		return ErrorResult(fmt.Sprintf("unknown action %q (want list or read)", action))
+	}
+}
+
+func (t *TopicsTool) list(sessionKey string) *ToolResult {
+	topics := t.store.ListTopics(sessionKey)
+	if len(topics) == 0 {
+		return NewToolResult("No topics yet")
+	}
+	var sb strings.Builder
+	sb.WriteString("Topics:")
+	for i, topic := range topics {
+		fmt.Fprintf(&sb, "\n%d. %s (%d messages, from %s)", i+1, topic.Title, topic.End-topic.Start,
+			topic.Created.Format("2006-01-02 15:04"))
+	}
+	return NewToolResult(sb.String())
+}
+
+func (t *TopicsTool) read(sessionKey string, n int) *ToolResult {
+	msgs, ok := t.store.TopicMessages(sessionKey, n-1)
+	if !ok {
+		return ErrorResult(fmt.Sprintf("no topic %d", n))
+	}
+	var sb strings.Builder
+	for _, m := range msgs {
+		if (m.Role != "user" && m.Role != "assistant") || strings.TrimSpace(m.Content) == "" {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%s] %s\n", m.Role, m.Content)
+	}
+	text := sb.String()
+	if text == "" {
+		return NewToolResult(fmt.Sprintf("Topic %d has no messages with text", n))
+	}
+	return NewToolResult(truncateRunes(strings.TrimRight(text, "\n"), maxTopicReadChars))
+}
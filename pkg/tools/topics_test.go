@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func TestTopicsTool_ListRead(t *testing.T) {
+	sm := session.NewSessionManager("")
+	tool := NewTopicsTool(sm)
+	ctx := WithSessionKey(context.Background(), "cli:direct")
+
+	if result := tool.Execute(ctx, map[string]any{"action": "list"}); result.ForLLM != "No topics yet" {
+		t.Errorf("list without topics = %q", result.ForLLM)
+	}
+	sm.AddMessage("cli:direct", "user", "which tulips flower first?")
+	sm.AddMessage("cli:direct", "assistant", "Kaufmanniana tulips.")
+	sm.AddMessage("cli:direct", "user", "when is the tax deadline?")
+	sm.AddMessage("cli:direct", "assistant", "April 30.")
+	sm.AddTopic("cli:direct", 0, "Early tulips")
+	sm.AddTopic("cli:direct", 2, "Tax deadline")
+
+	result := tool.Execute(ctx, map[string]any{"action": "list"})
+	if !strings.Contains(result.ForLLM, "1. Early tulips (2 messages") ||
+		!strings.Contains(result.ForLLM, "2. Tax deadline (2 messages") {
+		t.Errorf("list = %q", result.ForLLM)
+	}
+	result = tool.Execute(ctx, map[string]any{"action": "read", "topic": 1.0})
+	want := "[user] which tulips flower first?\n[assistant] Kaufmanniana tulips."
+	if result.IsError || result.ForLLM != want {
+		t.Errorf("read = %q, want %q", result.ForLLM, want)
+	}
+	if result := tool.Execute(ctx, map[string]any{"action": "read", "topic": 3.0}); !result.IsError {
+		t.Errorf("read of a missing topic = %+v", result)
+	}
+	if result := tool.Execute(context.Background(), map[string]any{"action": "list"}); !result.IsError {
+		t.Errorf("list outside a conversation = %+v", result)
+	}
+}
@@ -0,0 +1,293 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+// The capabilities a WebAssembly tool can be granted.
+const (
+	WasmFetch          = "fetch"           // the picoclaw.fetch host function
+	WasmReadWorkspace  = "read_workspace"  // the workspace mounted read-only at /workspace
+	WasmWriteWorkspace = "write_workspace" // the workspace mounted read-write at /workspace
+)
+
+// wasmGuestWorkspace is where the workspace appears inside the sandbox.
+const wasmGuestWorkspace = "/workspace"
+
+// wasmFetchLimit caps the body of a response handed to a module.
+const wasmFetchLimit = 1 << 20
+
+// wasmCache keeps the compiled code of modules, so that the agents sharing a
+// skill, and a tool reloaded unchanged, do not compile it again. Entries are
+// kept for the life of the process.
+var wasmCache = wazero.NewCompilationCache()
+
+// WasmTool runs a WebAssembly module that a skill declares in its
+// tools.json, in a wazero sandbox. The module is a WASI command: like a
+// SkillTool it reads the arguments as JSON on stdin and prints the result,
+// but it sees no files, network or environment beyond what its
+// capabilities grant.
+//
+// With the fetch capability the module can import two functions from the
+// "picoclaw" module:
+//
+//	fetch(req_ptr, req_len u32) i32
+//	fetch_result(buf_ptr, buf_len u32) i32
+//
+// fetch takes a JSON request {"method", "url", "headers", "body"}, makes it
+// and returns the length of the JSON response {"status", "headers", "body",
+// "truncated"}, or of {"error"} when it failed; fetch_result copies that
+// response into buf and returns the number of bytes copied.
+type WasmTool struct {
+	*SkillTool
+	caps        []string
+	memoryPages uint32
+	client      *http.Client
+
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	stamp    string // size and mtime of the compiled file
+}
+
+// NewWasmTool creates the tool for spec, which must be a wasm tool. It
+// fails when spec asks for a capability cfg does not grant. The module is
+// compiled on the first call, and again whenever the file changes.
+func NewWasmTool(spec skills.ToolSpec, workspace string, cfg config.WasmToolsConfig) (*WasmTool, error) {
+	for _, c := range spec.Capabilities {
+		if !slices.Contains(config.WasmCapabilities, c) {
+			return nil, fmt.Errorf("unknown capability %q", c)
+		}
+		if !slices.Contains(cfg.Capabilities, c) {
+			return nil, fmt.Errorf("capability %q is not granted in tools.wasm.capabilities", c)
+		}
+	}
+	t := &WasmTool{
+		SkillTool:   NewSkillTool(spec, workspace),
+		caps:        spec.Capabilities,
+		memoryPages: uint32(cfg.MemoryLimitMB) * 16, // 64 KiB pages
+	}
+	if slices.Contains(t.caps, WasmFetch) {
+		// createHTTPClient cannot fail with an empty proxy string.
+		t.client, _ = createHTTPClient("", fetchTimeout)
+	}
+	return t, nil
+}
+
+func (t *WasmTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	input, err := json.Marshal(args)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("encode arguments: %v", err))
+	}
+	runtime, compiled, err := t.load(ctx)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("%s could not be loaded: %v", t.Name(), err))
+	}
+
+	stdout := &cappedBuffer{limit: defaultMaxOutputChars}
+	stderr := &cappedBuffer{limit: defaultMaxOutputChars}
+	mc := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(t.Name()).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithEnv("PICOCLAW_CHANNEL", ToolChannel(ctx)).
+		WithEnv("PICOCLAW_CHAT_ID", ToolChatID(ctx)).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+	switch {
+	case slices.Contains(t.caps, WasmWriteWorkspace):
+		mc = mc.WithFSConfig(wazero.NewFSConfig().WithDirMount(t.workspace, wasmGuestWorkspace)).
+			WithEnv("PICOCLAW_WORKSPACE", wasmGuestWorkspace)
+	case slices.Contains(t.caps, WasmReadWorkspace):
+		mc = mc.WithFSConfig(wazero.NewFSConfig().WithReadOnlyDirMount(t.workspace, wasmGuestWorkspace)).
+			WithEnv("PICOCLAW_WORKSPACE", wasmGuestWorkspace)
+	}
+
+	mod, err := runtime.InstantiateModule(context.WithValue(ctx, wasmCallKey{}, &wasmCall{}), compiled, mc)
+	if mod != nil {
+		mod.Close(context.Background())
+	}
+	if ctx.Err() != nil {
+		return ErrorResult(fmt.Sprintf("%s was stopped: %v", t.Name(), context.Cause(ctx)))
+	}
+	return programResult(t.Name(), stdout, stderr, err)
+}
+
+// Close releases the runtime; calls still running are stopped.
+func (t *WasmTool) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.runtime == nil {
+		return nil
+	}
+	err := t.runtime.Close(context.Background())
+	t.runtime, t.compiled, t.stamp = nil, nil, ""
+	return err
+}
+
+// load returns the runtime and the compiled module, compiling the file
+// when it changed since the last call.
+func (t *WasmTool) load(ctx context.Context) (wazero.Runtime, wazero.CompiledModule, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	path := filepath.Join(t.spec.Dir, t.spec.Wasm)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	stamp := fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+	if t.compiled != nil && stamp == t.stamp {
+		return t.runtime, t.compiled, nil
+	}
+	if t.runtime == nil {
+		rc := wazero.NewRuntimeConfig().WithCloseOnContextDone(true).WithCompilationCache(wasmCache)
+		if t.memoryPages > 0 {
+			rc = rc.WithMemoryLimitPages(t.memoryPages)
+		}
+		runtime := wazero.NewRuntimeWithConfig(context.Background(), rc)
+		if err := t.instantiateHost(runtime); err != nil {
+			runtime.Close(context.Background())
+			return nil, nil, err
+		}
+		t.runtime = runtime
+	}
+
+	bin, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	compiled, err := t.runtime.CompileModule(ctx, bin)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Calls still running on the old module are not affected.
+	if t.compiled != nil {
+		t.compiled.Close(context.Background())
+	}
+	t.compiled, t.stamp = compiled, stamp
+	return t.runtime, t.compiled, nil
+}
+
+// instantiateHost adds WASI and the picoclaw host module to runtime.
+func (t *WasmTool) instantiateHost(runtime wazero.Runtime) error {
+	ctx := context.Background()
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return err
+	}
+	_, err := runtime.NewHostModuleBuilder("picoclaw").
+		NewFunctionBuilder().WithFunc(t.hostFetch).Export("fetch").
+		NewFunctionBuilder().WithFunc(hostFetchResult).Export("fetch_result").
+		Instantiate(ctx)
+	return err
+}
+
+// wasmCall is the state of one call that the host functions share.
+type wasmCall struct {
+	response []byte // of the last fetch
+}
+
+type wasmCallKey struct{}
+
+type wasmFetchRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+type wasmFetchResponse struct {
+	Status    int               `json:"status,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+func (t *WasmTool) hostFetch(ctx context.Context, m api.Module, reqPtr, reqLen uint32) int32 {
+	call, _ := ctx.Value(wasmCallKey{}).(*wasmCall)
+	if call == nil {
+		return -1
+	}
+	resp := t.fetch(ctx, m, reqPtr, reqLen)
+	call.response, _ = json.Marshal(resp)
+	return int32(len(call.response))
+}
+
+func (t *WasmTool) fetch(ctx context.Context, m api.Module, reqPtr, reqLen uint32) wasmFetchResponse {
+	if t.client == nil {
+		return wasmFetchResponse{Error: "the fetch capability is not granted to this tool"}
+	}
+	data, ok := m.Memory().Read(reqPtr, reqLen)
+	if !ok {
+		return wasmFetchResponse{Error: "request out of memory bounds"}
+	}
+	var in wasmFetchRequest
+	if err := json.Unmarshal(data, &in); err != nil {
+		return wasmFetchResponse{Error: "invalid request: " + err.Error()}
+	}
+	if !strings.HasPrefix(in.URL, "http://") && !strings.HasPrefix(in.URL, "https://") {
+		return wasmFetchResponse{Error: "only http/https URLs are allowed"}
+	}
+	if in.Method == "" {
+		in.Method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, in.Method, in.URL, strings.NewReader(in.Body))
+	if err != nil {
+		return wasmFetchResponse{Error: err.Error()}
+	}
+	for k, v := range in.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return wasmFetchResponse{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, wasmFetchLimit+1))
+	if err != nil {
+		return wasmFetchResponse{Error: err.Error()}
+	}
+	out := wasmFetchResponse{Status: resp.StatusCode, Headers: make(map[string]string, len(resp.Header))}
+	if len(body) > wasmFetchLimit {
+		body, out.Truncated = body[:wasmFetchLimit], true
+	}
+	out.Body = string(body)
+	for k := range resp.Header {
+		out.Headers[k] = resp.Header.Get(k)
+	}
+	return out
+}
+
+func hostFetchResult(ctx context.Context, m api.Module, bufPtr, bufLen uint32) int32 {
+	call, _ := ctx.Value(wasmCallKey{}).(*wasmCall)
+	if call == nil {
+		return -1
+	}
+	n := min(int(bufLen), len(call.response))
+	if !m.Memory().Write(bufPtr, call.response[:n]) {
+		return -1
+	}
+	return int32(n)
+}
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/skills"
+)
+
+var (
+	wasmModuleOnce sync.Once
+	wasmModule     []byte
+	wasmModuleErr  error
+)
+
+// wasmSpec returns a wasm tool spec for the program in testdata/wasmtool,
+// which is built once per test run.
+func wasmSpec(t *testing.T, caps ...string) skills.ToolSpec {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds a WebAssembly module")
+	}
+	wasmModuleOnce.Do(func() {
+		out := filepath.Join(os.TempDir(), "picoclaw-wasmtool-test.wasm")
+		cmd := exec.Command("go", "build", "-o", out, "./testdata/wasmtool")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			wasmModuleErr = err
+			t.Logf("%s", output)
+			return
+		}
+		wasmModule, wasmModuleErr = os.ReadFile(out)
+		os.Remove(out)
+	})
+	if wasmModuleErr != nil {
+		t.Skipf("cannot build the test module: %v", wasmModuleErr)
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tool.wasm"), wasmModule, 0o644))
+	return skills.ToolSpec{
+		Skill: "garden", Name: "sense", Description: "Read a sensor", Wasm: "tool.wasm", Capabilities: caps, Dir: dir,
+	}
+}
+
+func wasmConfig(caps ...string) config.WasmToolsConfig {
+	return config.WasmToolsConfig{ToolConfig: config.ToolConfig{Enabled: true}, Capabilities: caps, MemoryLimitMB: 64}
+}
+
+func TestWasmTool_Execute(t *testing.T) {
+	tool, err := NewWasmTool(wasmSpec(t), t.TempDir(), wasmConfig())
+	require.NoError(t, err)
+	defer tool.Close()
+
+	assert.Equal(t, "skill_garden_sense", tool.Name())
+	result := tool.Execute(context.Background(), map[string]any{"do": "echo", "name": "tulip"})
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Equal(t, "hello tulip", result.ForLLM)
+
+	result = tool.Execute(context.Background(), map[string]any{"do": "nothing"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "exit_code(3)")
+	assert.Contains(t, result.ForLLM, "unknown action")
+}
+
+func TestWasmTool_Workspace(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "notes.md"), []byte("water on Fridays"), 0o644))
+	read := map[string]any{"do": "read", "file": "notes.md"}
+	write := map[string]any{"do": "write", "file": "new.md"}
+
+	// Without a capability the module sees no files at all.
+	none, err := NewWasmTool(wasmSpec(t), workspace, wasmConfig())
+	require.NoError(t, err)
+	defer none.Close()
+	assert.True(t, none.Execute(context.Background(), read).IsError)
+
+	ro, err := NewWasmTool(wasmSpec(t, WasmReadWorkspace), workspace, wasmConfig(WasmReadWorkspace))
+	require.NoError(t, err)
+	defer ro.Close()
+	assert.Equal(t, "water on Fridays", ro.Execute(context.Background(), read).ForLLM)
+	assert.True(t, ro.Execute(context.Background(), write).IsError)
+	assert.NoFileExists(t, filepath.Join(workspace, "new.md"))
+
+	rw, err := NewWasmTool(wasmSpec(t, WasmWriteWorkspace), workspace, wasmConfig(WasmWriteWorkspace))
+	require.NoError(t, err)
+	defer rw.Close()
+	result := rw.Execute(context.Background(), write)
+	require.False(t, result.IsError, result.ForLLM)
+	assert.FileExists(t, filepath.Join(workspace, "new.md"))
+}
+
+func TestWasmTool_Fetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sunny"))
+	}))
+	defer srv.Close()
+	args := map[string]any{"do": "fetch", "url": srv.URL}
+
+	tool, err := NewWasmTool(wasmSpec(t, WasmFetch), t.TempDir(), wasmConfig(WasmFetch))
+	require.NoError(t, err)
+	defer tool.Close()
+	result := tool.Execute(context.Background(), args)
+	require.False(t, result.IsError, result.ForLLM)
+	assert.Contains(t, result.ForLLM, `"status":200`)
+	assert.Contains(t, result.ForLLM, `"body":"sunny"`)
+
+	denied, err := NewWasmTool(wasmSpec(t), t.TempDir(), wasmConfig(WasmFetch))
+	require.NoError(t, err)
+	defer denied.Close()
+	assert.Contains(t, denied.Execute(context.Background(), args).ForLLM, "not granted")
+}
+
+func TestWasmTool_Timeout(t *testing.T) {
+	tool, err := NewWasmTool(wasmSpec(t), t.TempDir(), wasmConfig())
+	require.NoError(t, err)
+	defer tool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	result := tool.Execute(ctx, map[string]any{"do": "spin"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "was stopped")
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestNewWasmTool_Capabilities(t *testing.T) {
+	spec := skills.ToolSpec{Skill: "garden", Name: "sense", Wasm: "tool.wasm", Capabilities: []string{WasmFetch}}
+	_, err := NewWasmTool(spec, t.TempDir(), wasmConfig(WasmReadWorkspace))
+	assert.ErrorContains(t, err, "not granted")
+
+	spec.Capabilities = []string{"shell"}
+	_, err = NewWasmTool(spec, t.TempDir(), wasmConfig("shell"))
+	assert.ErrorContains(t, err, "unknown capability")
+}
@@ -6,11 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -24,12 +26,20 @@ const (
 
 	defaultMaxChars = 50000
 	maxRedirects    = 5
+
+	defaultFetchCacheTTL = 5 * time.Minute
+	maxFetchCacheEntries = 16
 )
 
 // Pre-compiled regexes for HTML text extraction
 var (
-	reScript     = regexp.MustCompile(`<script[\s\S]*?</script>`)
-	reStyle      = regexp.MustCompile(`<style[\s\S]*?</style>`)
+	reScript    = regexp.MustCompile(`(?i)<script[\s\S]*?</script>`)
+	reStyle     = regexp.MustCompile(`(?i)<style[\s\S]*?</style>`)
+	reNoscript  = regexp.MustCompile(`(?i)<noscript[\s\S]*?</noscript>`)
+	reComment   = regexp.MustCompile(`<!--[\s\S]*?-->`)
+	reBlockTags = regexp.MustCompile(
+		`(?i)</?(p|div|br|li|tr|h[1-6]|ul|ol|table|section|article|header|footer|blockquote|pre)\b[^>]*>`)
+	reTitle      = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
 	reTags       = regexp.MustCompile(`<[^>]+>`)
 	reWhitespace = regexp.MustCompile(`[^\S\n]+`)
 	reBlankLines = regexp.MustCompile(`\n{3,}`)
@@ -704,6 +714,25 @@ type WebFetchTool struct {
 	proxy           string
 	client          *http.Client
 	fetchLimitBytes int64
+	cache           *fetchCache
+}
+
+func init() {
+	RegisterFactory("web_fetch", func(env ToolEnv) (Tool, error) {
+		if !env.Config.Tools.IsToolEnabled("web_fetch") {
+			return nil, nil
+		}
+		web := env.Config.Tools.Web
+		tool, err := NewWebFetchToolWithProxy(50000, web.Proxy, web.FetchLimitBytes)
+		if err != nil {
+			return nil, err
+		}
+		if web.FetchTimeoutSeconds > 0 {
+			tool.SetTimeout(time.Duration(web.FetchTimeoutSeconds) * time.Second)
+		}
+		tool.SetCacheTTL(time.Duration(web.FetchCacheTTLSeconds) * time.Second)
+		return tool, nil
+	})
 }
 
 func NewWebFetchTool(maxChars int, fetchLimitBytes int64) (*WebFetchTool, error) {
@@ -733,15 +762,34 @@ func NewWebFetchToolWithProxy(maxChars int, proxy string, fetchLimitBytes int64)
 		proxy:           proxy,
 		client:          client,
 		fetchLimitBytes: fetchLimitBytes,
+		cache:           newFetchCache(defaultFetchCacheTTL),
 	}, nil
 }
 
+// SetTimeout sets how long one fetch, including redirects and reading the
+// body, may take.
+func (t *WebFetchTool) SetTimeout(timeout time.Duration) {
+	t.client.Timeout = timeout
+}
+
+// SetCacheTTL sets how long successful fetches are served from cache.
+// A ttl <= 0 disables caching.
+func (t *WebFetchTool) SetCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		t.cache = nil
+		return
+	}
+	t.cache = newFetchCache(ttl)
+}
+
 func (t *WebFetchTool) Name() string {
 	return "web_fetch"
 }
 
 func (t *WebFetchTool) Description() string {
-	return "Fetch a URL and extract readable content (HTML to text). Use this to get weather info, news, articles, or any web content."
+	return "Fetch a URL and extract readable content (HTML to text, JSON pretty-printed). " +
+		"Use this to get weather info, news, articles, API responses or any web content. " +
+		"Recent results are cached."
 }
 
 func (t *WebFetchTool) Parameters() map[string]any {
@@ -788,6 +836,10 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		}
 	}
 
+	if page, ok := t.cache.get(urlStr, maxChars); ok {
+		return page.result(urlStr, maxChars, true)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("failed to create request: %v", err))
@@ -815,7 +867,7 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 
 	contentType := resp.Header.Get("Content-Type")
 
-	var text, extractor string
+	var text, extractor, title string
 
 	if strings.Contains(contentType, "application/json") {
 		var jsonData any
@@ -830,25 +882,54 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	} else if strings.Contains(contentType, "text/html") || len(body) > 0 &&
 		(strings.HasPrefix(string(body), "<!DOCTYPE") || strings.HasPrefix(strings.ToLower(string(body)), "<html")) {
 		text = t.extractText(string(body))
+		title = extractTitle(string(body))
 		extractor = "text"
 	} else {
 		text = string(body)
 		extractor = "raw"
 	}
 
-	truncated := len(text) > maxChars
-	if truncated {
-		text = text[:maxChars]
+	page := fetchedPage{status: resp.StatusCode, extractor: extractor, title: title, text: text}
+	if len(text) > maxChars {
+		page.text = text[:maxChars]
+		page.truncated = true
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		t.cache.put(urlStr, page)
+	}
+	return page.result(urlStr, maxChars, false)
+}
+
+// fetchedPage is the extracted content of one fetch, cut to the maxChars of
+// the call that fetched it.
+type fetchedPage struct {
+	status    int
+	extractor string
+	title     string
+	text      string
+	truncated bool
+}
+
+func (p fetchedPage) result(urlStr string, maxChars int, cached bool) *ToolResult {
+	text, truncated := p.text, p.truncated
+	if len(text) > maxChars {
+		text, truncated = text[:maxChars], true
 	}
 
 	result := map[string]any{
 		"url":       urlStr,
-		"status":    resp.StatusCode,
-		"extractor": extractor,
+		"status":    p.status,
+		"extractor": p.extractor,
 		"truncated": truncated,
 		"length":    len(text),
 		"text":      text,
 	}
+	if p.title != "" {
+		result["title"] = p.title
+	}
+	if cached {
+		result["cached"] = true
+	}
 
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 
@@ -858,16 +939,83 @@ func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 			"Fetched %d bytes from %s (extractor: %s, truncated: %v)",
 			len(text),
 			urlStr,
-			extractor,
+			p.extractor,
 			truncated,
 		),
 	}
 }
 
+// fetchCache keeps recent successful fetches so repeated reads of the same
+// URL within a turn, or across nearby turns, skip the network. A nil cache
+// is disabled.
+type fetchCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]fetchCacheEntry
+}
+
+type fetchCacheEntry struct {
+	page      fetchedPage
+	fetchedAt time.Time
+}
+
+func newFetchCache(ttl time.Duration) *fetchCache {
+	return &fetchCache{ttl: ttl, entries: make(map[string]fetchCacheEntry)}
+}
+
+// get returns the cached page for urlStr if it is fresh and holds at least
+// maxChars characters of text (or all of it).
+func (c *fetchCache) get(urlStr string, maxChars int) (fetchedPage, bool) {
+	if c == nil {
+		return fetchedPage{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[urlStr]
+	if !ok || time.Since(entry.fetchedAt) >= c.ttl {
+		return fetchedPage{}, false
+	}
+	if entry.page.truncated && len(entry.page.text) < maxChars {
+		return fetchedPage{}, false
+	}
+	return entry.page, true
+}
+
+func (c *fetchCache) put(urlStr string, page fetchedPage) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.Sub(entry.fetchedAt) >= c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	if _, exists := c.entries[urlStr]; !exists && len(c.entries) >= maxFetchCacheEntries {
+		oldestKey := ""
+		var oldest time.Time
+		for key, entry := range c.entries {
+			if oldestKey == "" || entry.fetchedAt.Before(oldest) {
+				oldestKey, oldest = key, entry.fetchedAt
+			}
+		}
+		delete(c.entries, oldestKey)
+	}
+	c.entries[urlStr] = fetchCacheEntry{page: page, fetchedAt: now}
+}
+
 func (t *WebFetchTool) extractText(htmlContent string) string {
 	result := reScript.ReplaceAllLiteralString(htmlContent, "")
 	result = reStyle.ReplaceAllLiteralString(result, "")
+	result = reNoscript.ReplaceAllLiteralString(result, "")
+	result = reComment.ReplaceAllLiteralString(result, "")
+	result = reBlockTags.ReplaceAllLiteralString(result, "\n")
 	result = reTags.ReplaceAllLiteralString(result, "")
+	result = html.UnescapeString(result)
 
 	result = strings.TrimSpace(result)
 
@@ -885,3 +1033,12 @@ func (t *WebFetchTool) extractText(htmlContent string) string {
 
 	return strings.Join(cleanLines, "\n")
 }
+
+// extractTitle returns the document's <title>, or "" if it has none.
+func extractTitle(htmlContent string) string {
+	m := reTitle.FindStringSubmatch(htmlContent)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -381,6 +382,15 @@ func TestWebFetchTool_extractText(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:  "decodes entities and breaks lines at block tags",
+			input: "<div>Fish &amp; chips</div><div>caf&eacute;<br>open</div><!-- hidden -->",
+			wantFunc: func(t *testing.T, got string) {
+				if got != "Fish & chips\ncafé\nopen" {
+					t.Errorf("Unexpected extraction: %q", got)
+				}
+			},
+		},
 		{
 			name:  "empty input",
 			input: "",
@@ -400,6 +410,77 @@ func TestWebFetchTool_extractText(t *testing.T) {
 	}
 }
 
+// TestWebTool_WebFetch_Cache verifies that successful fetches are served from
+// cache and that failed ones are fetched again.
+func TestWebTool_WebFetch_Cache(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><title>Forecast &amp; News</title></head><body><p>Sunny</p></body></html>"))
+	}))
+	defer server.Close()
+
+	tool, err := NewWebFetchTool(50000, testFetchLimit)
+	if err != nil {
+		t.Fatalf("Failed to create web fetch tool: %v", err)
+	}
+	ctx := context.Background()
+
+	first := tool.Execute(ctx, map[string]any{"url": server.URL})
+	second := tool.Execute(ctx, map[string]any{"url": server.URL})
+	if first.IsError || second.IsError {
+		t.Fatalf("Expected success, got: %s / %s", first.ForLLM, second.ForLLM)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected 1 request with caching, got %d", hits.Load())
+	}
+	if !strings.Contains(second.ForLLM, `"cached": true`) {
+		t.Errorf("Expected cached marker, got: %s", second.ForLLM)
+	}
+	if !strings.Contains(first.ForLLM, `"title": "Forecast \u0026 News"`) {
+		t.Errorf("Expected decoded title, got: %s", first.ForLLM)
+	}
+
+	tool.Execute(ctx, map[string]any{"url": server.URL + "/missing"})
+	tool.Execute(ctx, map[string]any{"url": server.URL + "/missing"})
+	if hits.Load() != 3 {
+		t.Errorf("Expected error responses not to be cached, got %d requests", hits.Load())
+	}
+
+	tool.SetCacheTTL(0)
+	tool.Execute(ctx, map[string]any{"url": server.URL})
+	if hits.Load() != 4 {
+		t.Errorf("Expected a request with caching disabled, got %d requests", hits.Load())
+	}
+}
+
+// TestWebTool_WebFetch_Timeout verifies that SetTimeout bounds slow fetches.
+func TestWebTool_WebFetch_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	tool, err := NewWebFetchTool(50000, testFetchLimit)
+	if err != nil {
+		t.Fatalf("Failed to create web fetch tool: %v", err)
+	}
+	tool.SetTimeout(50 * time.Millisecond)
+
+	result := tool.Execute(context.Background(), map[string]any{"url": server.URL})
+	if !result.IsError {
+		t.Errorf("Expected timeout error, got: %s", result.ForLLM)
+	}
+}
+
 // TestWebTool_WebFetch_MissingDomain verifies error handling for URL without domain
 func TestWebTool_WebFetch_MissingDomain(t *testing.T) {
 	tool, err := NewWebFetchTool(50000, testFetchLimit)
@@ -0,0 +1,135 @@
+package transcript
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// keywords are highlighted in code of any language. SQL keywords match in
+// either case, in SQL code only.
+var (
+	keywords = setOf(
+		"and", "as", "async", "await", "break", "case", "catch", "chan", "class", "const", "continue", "def",
+		"default", "defer", "del", "elif", "else", "enum", "except", "export", "extends", "false", "False",
+		"finally", "fn", "for", "from", "func", "function", "go", "if", "impl", "import", "in", "interface",
+		"is", "lambda", "let", "map", "match", "mut", "new", "nil", "None", "not", "null", "or", "package",
+		"pass", "pub", "raise", "range", "return", "select", "self", "static", "struct", "switch", "this",
+		"throw", "true", "True", "try", "type", "use", "var", "void", "while", "with", "yield",
+	)
+	sqlKeywords = setOf(
+		"and", "as", "by", "create", "delete", "desc", "from", "group", "insert", "into", "join", "left",
+		"limit", "not", "null", "on", "or", "order", "select", "set", "table", "update", "values", "where",
+	)
+
+	// hashComments and dashComments are the languages whose line comments
+	// start with # or --; the rest use //.
+	hashComments = setOf("bash", "conf", "dockerfile", "ini", "makefile", "perl", "py", "python", "r", "rb",
+		"ruby", "sh", "shell", "toml", "yaml", "yml", "zsh")
+	dashComments = setOf("haskell", "hs", "lua", "sql")
+)
+
+func setOf(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}
+
+// highlight returns code as HTML, with comments, strings, numbers and
+// keywords in spans of the classes c, s, n and k. It knows no grammar, only
+// what most languages share, which is enough to make code easy to read.
+func highlight(code, lang string) string {
+	lang = strings.ToLower(lang)
+	lineComment := "//"
+	switch {
+	case hashComments[lang]:
+		lineComment = "#"
+	case dashComments[lang]:
+		lineComment = "--"
+	case lang == "json" || lang == "text" || lang == "txt":
+		lineComment = ""
+	}
+
+	var sb strings.Builder
+	span := func(class, text string) {
+		sb.WriteString(`<span class="` + class + `">` + html.EscapeString(text) + `</span>`)
+	}
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		switch c := rune(code[i]); {
+		case lineComment != "" && strings.HasPrefix(rest, lineComment):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			span("c", rest[:end])
+			i += end
+		case lineComment == "//" && strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 4
+			}
+			span("c", rest[:end])
+			i += end
+		case c == '"' || c == '\'' || c == '`':
+			end := stringEnd(rest)
+			span("s", rest[:end])
+			i += end
+		case c >= '0' && c <= '9' && (i == 0 || !isWord(rune(code[i-1]))):
+			end := 1
+			for end < len(rest) && (isWord(rune(rest[end])) || rest[end] == '.') {
+				end++
+			}
+			span("n", rest[:end])
+			i += end
+		case isWord(c):
+			end := 1
+			for end < len(rest) && isWord(rune(rest[end])) {
+				end++
+			}
+			word := rest[:end]
+			if keywords[word] && lang != "sql" || lang == "sql" && sqlKeywords[strings.ToLower(word)] {
+				span("k", word)
+			} else {
+				sb.WriteString(html.EscapeString(word))
+			}
+			i += end
+		default:
+			// Copy up to the next byte any case above is about.
+			end := 1
+			for end < len(rest) && !strings.ContainsRune("\"'`/#-", rune(rest[end])) &&
+				!isWord(rune(rest[end])) {
+				end++
+			}
+			sb.WriteString(html.EscapeString(rest[:end]))
+			i += end
+		}
+	}
+	return sb.String()
+}
+
+// stringEnd returns the length of the string literal s starts with,
+// honouring backslash escapes except in backquoted strings. A string
+// without its closing quote ends at the end of the line.
+func stringEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == quote:
+			return i + 1
+		case s[i] == '\n' && quote != '`':
+			return i
+		}
+	}
+	return len(s)
+}
+
+func isWord(c rune) bool {
+	return c == '_' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) || c >= unicode.MaxASCII
+}
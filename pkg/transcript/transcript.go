@@ -0,0 +1,249 @@
+// Package transcript renders a session as a self-contained HTML page, to
+// share how a conversation went with someone who has no access to the
+// agent. The page needs no scripts or outside files: tool calls and their
+// results are folded away in <details> elements and code is highlighted
+// when the page is written.
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+// Options selects what WriteHTML shows.
+type Options struct {
+	// From and To are the first and last message shown, counted from 1 as
+	// picoclaw sessions show does; 0 means the first or last message.
+	From, To int
+	// Times holds when messages were sent, by index in the session. The
+	// history itself has no times; callers take them from the turn traces.
+	Times map[int]time.Time
+}
+
+// WriteHTML writes s as an HTML page.
+func WriteHTML(w io.Writer, s session.Session, opts Options) error {
+	from, to := opts.From, opts.To
+	if from <= 0 {
+		from = 1
+	}
+	if to <= 0 || to > len(s.Messages) {
+		to = len(s.Messages)
+	}
+	if from > to && len(s.Messages) > 0 {
+		return fmt.Errorf("no messages %d-%d in a session of %d", opts.From, opts.To, len(s.Messages))
+	}
+
+	page := pageData{
+		Title:    s.Key,
+		Created:  s.Created.Local().Format(time.DateTime),
+		Updated:  s.Updated.Local().Format(time.DateTime),
+		Total:    len(s.Messages),
+		Exported: time.Now().Local().Format(time.DateTime),
+	}
+	if from > 1 || to < len(s.Messages) {
+		page.Range = fmt.Sprintf("messages %d-%d", from, to)
+	} else {
+		page.Summary = render(s.Summary)
+	}
+
+	toolNames := make(map[string]string)
+	for i := from - 1; i < to && i < len(s.Messages); i++ {
+		m := s.Messages[i]
+		for _, t := range s.Topics {
+			if t.Start == i {
+				page.Messages = append(page.Messages, messageData{Topic: t.Title})
+			}
+		}
+		data := messageData{Number: i + 1, Role: m.Role}
+		if t, ok := opts.Times[i]; ok {
+			data.Time = t.Local().Format(time.DateTime)
+		}
+		switch m.Role {
+		case "tool":
+			data.Folded = "Result of " + valueOr(toolNames[m.ToolCallID], "a tool")
+			data.Body = codeBlock(m.Content, "")
+		case "system":
+			data.Folded = "System prompt"
+			data.Body = render(m.Content)
+		default:
+			data.Body = render(m.Content)
+		}
+		for _, tc := range m.ToolCalls {
+			name, args := toolCall(tc)
+			toolNames[tc.ID] = name
+			data.Calls = append(data.Calls, callData{Name: name, Args: codeBlock(args, "json")})
+		}
+		page.Messages = append(page.Messages, data)
+	}
+	return pageTemplate.Execute(w, page)
+}
+
+type pageData struct {
+	Title, Created, Updated, Exported, Range string
+	Total                                    int
+	Summary                                  template.HTML
+	Messages                                 []messageData
+}
+
+type messageData struct {
+	Topic  string // set for a topic heading instead of a message
+	Number int
+	Role   providers.Role
+	Time   string
+	Folded string // the summary of a message shown folded
+	Body   template.HTML
+	Calls  []callData
+}
+
+type callData struct {
+	Name string
+	Args template.HTML
+}
+
+// toolCall returns the name of tc and its arguments, indented.
+func toolCall(tc providers.ToolCall) (string, string) {
+	name, args := tc.Name, ""
+	if tc.Function != nil {
+		name, args = tc.Function.Name, tc.Function.Arguments
+	} else if len(tc.Arguments) > 0 {
+		data, _ := json.Marshal(tc.Arguments)
+		args = string(data)
+	}
+	var v any
+	if json.Unmarshal([]byte(args), &v) == nil {
+		if data, err := json.MarshalIndent(v, "", "  "); err == nil {
+			args = string(data)
+		}
+	}
+	return name, args
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+var (
+	fencePattern  = regexp.MustCompile("(?ms)^```([A-Za-z0-9_+-]*)[^\n]*\n(.*?)(?:^```[ \t]*$|\\z)")
+	inlinePattern = regexp.MustCompile("`[^`\n]+`|\\*\\*[^*\n]+\\*\\*|https?://[^\\s<>\"')]+")
+)
+
+// render turns the Markdown that models write into HTML: fenced code
+// blocks, paragraphs, inline code, bold text and links. Everything else is
+// shown as written.
+func render(text string) template.HTML {
+	text = strings.TrimSpace(text)
+	var sb strings.Builder
+	last := 0
+	for _, m := range fencePattern.FindAllStringSubmatchIndex(text, -1) {
+		paragraphs(&sb, text[last:m[0]])
+		sb.WriteString(string(codeBlock(strings.TrimSuffix(text[m[4]:m[5]], "\n"), text[m[2]:m[3]])))
+		last = m[1]
+	}
+	paragraphs(&sb, text[last:])
+	return template.HTML(sb.String())
+}
+
+func paragraphs(sb *strings.Builder, text string) {
+	for _, p := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		sb.WriteString("<p>")
+		last := 0
+		for _, m := range inlinePattern.FindAllStringIndex(p, -1) {
+			sb.WriteString(lines(p[last:m[0]]))
+			switch tok := p[m[0]:m[1]]; {
+			case strings.HasPrefix(tok, "`"):
+				sb.WriteString("<code>" + html.EscapeString(tok[1:len(tok)-1]) + "</code>")
+			case strings.HasPrefix(tok, "**"):
+				sb.WriteString("<strong>" + html.EscapeString(tok[2:len(tok)-2]) + "</strong>")
+			default:
+				// A sentence may end right after a link.
+				url := strings.TrimRight(tok, ".,:;!?")
+				sb.WriteString(`<a href="` + html.EscapeString(url) + `">` + html.EscapeString(url) + "</a>")
+				m[1] -= len(tok) - len(url)
+			}
+			last = m[1]
+		}
+		sb.WriteString(lines(p[last:]))
+		sb.WriteString("</p>\n")
+	}
+}
+
+func lines(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n")
+}
+
+func codeBlock(code, lang string) template.HTML {
+	if code == "" {
+		return ""
+	}
+	return template.HTML("<pre><code>" + highlight(code, lang) + "</code></pre>\n")
+}
+
+var pageTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+:root { --bg: #fff; --fg: #1f2328; --muted: #656d76; --line: #d0d7de; --user: #eef4ff; --code: #f6f8fa;
+  --k: #cf222e; --s: #0a3069; --n: #0550ae; --c: #6e7781; }
+@media (prefers-color-scheme: dark) {
+  :root { --bg: #0d1117; --fg: #e6edf3; --muted: #8d96a0; --line: #30363d; --user: #15233a; --code: #161b22;
+    --k: #ff7b72; --s: #a5d6ff; --n: #79c0ff; --c: #8b949e; }
+}
+body { background: var(--bg); color: var(--fg); font: 15px/1.5 system-ui, sans-serif; margin: 0; }
+main { max-width: 50rem; margin: 0 auto; padding: 1.5rem 1rem 3rem; }
+header { border-bottom: 1px solid var(--line); margin-bottom: 1rem; }
+h1 { font-size: 1.3rem; margin: 0 0 .25rem; word-break: break-all; }
+h2 { font-size: 1rem; color: var(--muted); border-bottom: 1px solid var(--line); margin: 2rem 0 .5rem; }
+.meta, .head { color: var(--muted); font-size: .85rem; }
+.summary, .msg { border: 1px solid var(--line); border-radius: 8px; padding: .5rem .9rem; margin: .75rem 0; }
+.user { background: var(--user); }
+.head { display: flex; justify-content: space-between; gap: 1rem; }
+.role { font-weight: 600; text-transform: capitalize; }
+p { margin: .5rem 0; overflow-wrap: anywhere; }
+pre { background: var(--code); border-radius: 6px; padding: .6rem .8rem; overflow-x: auto; font-size: .85rem; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; }
+p code { background: var(--code); border-radius: 4px; padding: .1rem .3rem; }
+details { margin: .4rem 0; }
+summary { cursor: pointer; color: var(--muted); }
+a { color: var(--n); }
+.k { color: var(--k); } .s { color: var(--s); } .n { color: var(--n); } .c { color: var(--c); font-style: italic; }
+</style>
+</head>
+<body>
+<main>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Total}} messages, from {{.Created}} to {{.Updated}}{{if .Range}}; showing {{.Range}}{{end}}.
+Exported {{.Exported}}.</p>
+</header>
+{{if .Summary}}<div class="summary"><div class="head"><span class="role">Summary of earlier messages</span></div>
+{{.Summary}}</div>
+{{end}}{{range .Messages}}{{if .Topic}}<h2>{{.Topic}}</h2>
+{{else}}<div class="msg {{.Role}}" id="m{{.Number}}">
+<div class="head"><span class="role">{{.Role}}</span><span>#{{.Number}}{{if .Time}} · {{.Time}}{{end}}</span></div>
+{{if .Folded}}<details><summary>{{.Folded}}</summary>
+{{.Body}}</details>
+{{else}}{{.Body}}{{end}}{{range .Calls}}<details><summary>Called {{.Name}}</summary>
+{{.Args}}</details>
+{{end}}</div>
+{{end}}{{end}}</main>
+</body>
+</html>
+`))
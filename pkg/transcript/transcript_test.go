@@ -0,0 +1,89 @@
+package transcript
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func testSession() session.Session {
+	return session.Session{
+		Key:     "agent:main:main",
+		Summary: "Talked about **scripts**.",
+		Messages: []providers.Message{
+			{Role: "user", Content: "Count the lines of <main.go>"},
+			{Role: "assistant", ToolCalls: []providers.ToolCall{{
+				ID:       "call_1",
+				Function: &providers.FunctionCall{Name: "exec", Arguments: `{"command":"wc -l main.go"}`},
+			}}},
+			{Role: "tool", Content: "42 main.go", ToolCallID: "call_1"},
+			{Role: "assistant", Content: "It has `42` lines. Try:\n\n```go\n// count\nn := 42\n```\n\nSee https://go.dev."},
+		},
+		Topics: []session.Topic{{Title: "Line counts", Start: 0}, {Title: "Wrap-up", Start: 3}},
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var sb strings.Builder
+	times := map[int]time.Time{0: time.Date(2026, 3, 6, 9, 30, 0, 0, time.Local)}
+	require.NoError(t, WriteHTML(&sb, testSession(), Options{Times: times}))
+	out := sb.String()
+
+	assert.Contains(t, out, "<title>agent:main:main</title>")
+	assert.Contains(t, out, "<strong>scripts</strong>")
+	assert.Contains(t, out, "<h2>Line counts</h2>")
+	assert.Contains(t, out, "<h2>Wrap-up</h2>")
+	assert.Contains(t, out, "Count the lines of &lt;main.go&gt;")
+	assert.NotContains(t, out, "<main.go>")
+	assert.Contains(t, out, "#1 · 2026-03-06 09:30:00")
+
+	assert.Contains(t, out, "<details><summary>Called exec</summary>")
+	assert.Contains(t, out, `<span class="s">&#34;wc -l main.go&#34;</span>`)
+	assert.Contains(t, out, "<details><summary>Result of exec</summary>")
+
+	assert.Contains(t, out, "It has <code>42</code> lines.")
+	assert.Contains(t, out, `<span class="c">// count</span>`)
+	assert.Contains(t, out, `<span class="n">42</span>`)
+	assert.Contains(t, out, `See <a href="https://go.dev">https://go.dev</a>.`)
+	assert.NotContains(t, out, "<script")
+}
+
+func TestWriteHTML_Range(t *testing.T) {
+	var sb strings.Builder
+	require.NoError(t, WriteHTML(&sb, testSession(), Options{From: 3, To: 4}))
+	out := sb.String()
+	assert.Contains(t, out, "showing messages 3-4")
+	assert.NotContains(t, out, "Count the lines", "messages before the range are left out")
+	assert.NotContains(t, out, "Talked about", "the summary is of the whole session")
+	assert.Contains(t, out, "Result of a tool", "the call is outside the range")
+
+	assert.Error(t, WriteHTML(&sb, testSession(), Options{From: 4, To: 2}))
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		code, lang, want string
+	}{
+		{
+			`if x == "a" { return 1 }`, "go",
+			`<span class="k">if</span> x == <span class="s">&#34;a&#34;</span> { ` +
+				`<span class="k">return</span> <span class="n">1</span> }`,
+		},
+		{"x = 1 # note", "python", `x = <span class="n">1</span> <span class="c"># note</span>`},
+		{
+			"SELECT id FROM t -- all", "sql",
+			`<span class="k">SELECT</span> id <span class="k">FROM</span> t <span class="c">-- all</span>`,
+		},
+		{`{"a": "b<c"}`, "json", `{<span class="s">&#34;a&#34;</span>: <span class="s">&#34;b&lt;c&#34;</span>}`},
+		{"v2 x1", "", "v2 x1"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, highlight(tt.code, tt.lang), tt.code)
+	}
+}
@@ -7,13 +7,17 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 )
 
-// Ensure GroqTranscriber satisfies the Transcriber interface at compile time.
-var _ Transcriber = (*GroqTranscriber)(nil)
+// Ensure the transcribers satisfy the Transcriber interface at compile time.
+var (
+	_ Transcriber = (*GroqTranscriber)(nil)
+	_ Transcriber = (*WhisperCppTranscriber)(nil)
+)
 
 func TestGroqTranscriberName(t *testing.T) {
 	tr := NewGroqTranscriber("sk-test")
@@ -158,3 +162,30 @@ func TestTranscribe(t *testing.T) {
 		}
 	})
 }
+
+func TestWhisperCppTranscriber(t *testing.T) {
+	// A stand-in for whisper-cli that checks its arguments and prints
+	// what whisper.cpp would.
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "whisper-cli")
+	script := "#!/bin/sh\n" +
+		"[ \"$2\" = model.bin ] && [ \"$4\" = in.wav ] && [ \"$6\" = en ] || { echo bad args >&2; exit 1; }\n" +
+		"echo ' [BLANK_AUDIO]'\necho ' Turn on the (coughs) lights.'\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := NewWhisperCppTranscriber(bin, "model.bin", "en")
+	got, err := tr.Transcribe(context.Background(), "in.wav")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if got.Text != "Turn on the lights." {
+		t.Errorf("Text = %q", got.Text)
+	}
+
+	if _, err := NewWhisperCppTranscriber(bin, "other.bin", "en").Transcribe(context.Background(), "in.wav"); err == nil ||
+		!strings.Contains(err.Error(), "bad args") {
+		t.Errorf("err = %v, want the program's stderr", err)
+	}
+}
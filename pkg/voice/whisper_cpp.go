@@ -0,0 +1,65 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// whisperNoiseRe matches the markers whisper.cpp prints instead of speech,
+// such as [BLANK_AUDIO], [Music] or (wind blowing).
+var whisperNoiseRe = regexp.MustCompile(`\[[^\]]*\]|\([^)]*\)`)
+
+// WhisperCppTranscriber transcribes audio on the device by running the
+// whisper.cpp command-line program, so no audio leaves the machine.
+type WhisperCppTranscriber struct {
+	binary   string
+	model    string
+	language string
+}
+
+// NewWhisperCppTranscriber runs binary (default "whisper-cli") with the
+// ggml model file at model. language is a code such as "en", or "" to let
+// whisper detect it.
+func NewWhisperCppTranscriber(binary, model, language string) *WhisperCppTranscriber {
+	if binary == "" {
+		binary = "whisper-cli"
+	}
+	if language == "" {
+		language = "auto"
+	}
+	return &WhisperCppTranscriber{binary: binary, model: model, language: language}
+}
+
+func (t *WhisperCppTranscriber) Name() string {
+	return "whisper.cpp"
+}
+
+// Transcribe runs whisper.cpp on a 16 kHz WAV file.
+func (t *WhisperCppTranscriber) Transcribe(ctx context.Context, audioFilePath string) (*TranscriptionResponse, error) {
+	args := []string{"-m", t.model, "-f", audioFilePath, "-l", t.language, "--no-timestamps", "--no-prints"}
+	cmd := exec.CommandContext(ctx, t.binary, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%s failed: %s", t.binary, utils.Truncate(msg, 500))
+	}
+
+	text := whisperNoiseRe.ReplaceAllString(stdout.String(), " ")
+	text = strings.Join(strings.Fields(text), " ")
+	logger.DebugCF("voice", "Local transcription completed", map[string]any{
+		"transcription_preview": utils.Truncate(text, 50),
+	})
+	return &TranscriptionResponse{Text: text}, nil
+}